@@ -0,0 +1,1302 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: api/gorefactor/v1/refactor.proto
+
+// The gRPC API of the refactoring engine, served by
+// gorefactor-mcp serve -grpc. It plans, previews and applies refactorings of
+// the workspace the server loaded and runs analyzers on it.
+
+package gorefactorv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// How sure an operation is that a change is wanted.
+type Confidence int32
+
+const (
+	Confidence_CONFIDENCE_UNSPECIFIED Confidence = 0
+	Confidence_CONFIDENCE_CERTAIN     Confidence = 1
+	Confidence_CONFIDENCE_LIKELY      Confidence = 2
+	Confidence_CONFIDENCE_HEURISTIC   Confidence = 3
+)
+
+// Enum value maps for Confidence.
+var (
+	Confidence_name = map[int32]string{
+		0: "CONFIDENCE_UNSPECIFIED",
+		1: "CONFIDENCE_CERTAIN",
+		2: "CONFIDENCE_LIKELY",
+		3: "CONFIDENCE_HEURISTIC",
+	}
+	Confidence_value = map[string]int32{
+		"CONFIDENCE_UNSPECIFIED": 0,
+		"CONFIDENCE_CERTAIN":     1,
+		"CONFIDENCE_LIKELY":      2,
+		"CONFIDENCE_HEURISTIC":   3,
+	}
+)
+
+func (x Confidence) Enum() *Confidence {
+	p := new(Confidence)
+	*p = x
+	return p
+}
+
+func (x Confidence) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Confidence) Descriptor() protoreflect.EnumDescriptor {
+	return file_api_gorefactor_v1_refactor_proto_enumTypes[0].Descriptor()
+}
+
+func (Confidence) Type() protoreflect.EnumType {
+	return &file_api_gorefactor_v1_refactor_proto_enumTypes[0]
+}
+
+func (x Confidence) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Confidence.Descriptor instead.
+func (Confidence) EnumDescriptor() ([]byte, []int) {
+	return file_api_gorefactor_v1_refactor_proto_rawDescGZIP(), []int{0}
+}
+
+type Severity int32
+
+const (
+	Severity_SEVERITY_UNSPECIFIED Severity = 0
+	Severity_SEVERITY_ERROR       Severity = 1
+	Severity_SEVERITY_WARNING     Severity = 2
+	Severity_SEVERITY_INFO        Severity = 3
+)
+
+// Enum value maps for Severity.
+var (
+	Severity_name = map[int32]string{
+		0: "SEVERITY_UNSPECIFIED",
+		1: "SEVERITY_ERROR",
+		2: "SEVERITY_WARNING",
+		3: "SEVERITY_INFO",
+	}
+	Severity_value = map[string]int32{
+		"SEVERITY_UNSPECIFIED": 0,
+		"SEVERITY_ERROR":       1,
+		"SEVERITY_WARNING":     2,
+		"SEVERITY_INFO":        3,
+	}
+)
+
+func (x Severity) Enum() *Severity {
+	p := new(Severity)
+	*p = x
+	return p
+}
+
+func (x Severity) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Severity) Descriptor() protoreflect.EnumDescriptor {
+	return file_api_gorefactor_v1_refactor_proto_enumTypes[1].Descriptor()
+}
+
+func (Severity) Type() protoreflect.EnumType {
+	return &file_api_gorefactor_v1_refactor_proto_enumTypes[1]
+}
+
+func (x Severity) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Severity.Descriptor instead.
+func (Severity) EnumDescriptor() ([]byte, []int) {
+	return file_api_gorefactor_v1_refactor_proto_rawDescGZIP(), []int{1}
+}
+
+type ListPackagesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListPackagesRequest) Reset() {
+	*x = ListPackagesRequest{}
+	mi := &file_api_gorefactor_v1_refactor_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListPackagesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListPackagesRequest) ProtoMessage() {}
+
+func (x *ListPackagesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_gorefactor_v1_refactor_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListPackagesRequest.ProtoReflect.Descriptor instead.
+func (*ListPackagesRequest) Descriptor() ([]byte, []int) {
+	return file_api_gorefactor_v1_refactor_proto_rawDescGZIP(), []int{0}
+}
+
+type ListPackagesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Packages      []*Package             `protobuf:"bytes,1,rep,name=packages,proto3" json:"packages,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListPackagesResponse) Reset() {
+	*x = ListPackagesResponse{}
+	mi := &file_api_gorefactor_v1_refactor_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListPackagesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListPackagesResponse) ProtoMessage() {}
+
+func (x *ListPackagesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_gorefactor_v1_refactor_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListPackagesResponse.ProtoReflect.Descriptor instead.
+func (*ListPackagesResponse) Descriptor() ([]byte, []int) {
+	return file_api_gorefactor_v1_refactor_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ListPackagesResponse) GetPackages() []*Package {
+	if x != nil {
+		return x.Packages
+	}
+	return nil
+}
+
+type Package struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ImportPath    string                 `protobuf:"bytes,1,opt,name=import_path,json=importPath,proto3" json:"import_path,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Dir           string                 `protobuf:"bytes,3,opt,name=dir,proto3" json:"dir,omitempty"`
+	Files         []string               `protobuf:"bytes,4,rep,name=files,proto3" json:"files,omitempty"`
+	TestFiles     []string               `protobuf:"bytes,5,rep,name=test_files,json=testFiles,proto3" json:"test_files,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Package) Reset() {
+	*x = Package{}
+	mi := &file_api_gorefactor_v1_refactor_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Package) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Package) ProtoMessage() {}
+
+func (x *Package) ProtoReflect() protoreflect.Message {
+	mi := &file_api_gorefactor_v1_refactor_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Package.ProtoReflect.Descriptor instead.
+func (*Package) Descriptor() ([]byte, []int) {
+	return file_api_gorefactor_v1_refactor_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Package) GetImportPath() string {
+	if x != nil {
+		return x.ImportPath
+	}
+	return ""
+}
+
+func (x *Package) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Package) GetDir() string {
+	if x != nil {
+		return x.Dir
+	}
+	return ""
+}
+
+func (x *Package) GetFiles() []string {
+	if x != nil {
+		return x.Files
+	}
+	return nil
+}
+
+func (x *Package) GetTestFiles() []string {
+	if x != nil {
+		return x.TestFiles
+	}
+	return nil
+}
+
+type PlanRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Operation as named in batch files, such as rename_symbol or move_symbol.
+	Operation string `protobuf:"bytes,1,opt,name=operation,proto3" json:"operation,omitempty"`
+	// Request fields of the operation. gorefactor-mcp schema <operation> prints
+	// their JSON Schema.
+	Request       *structpb.Struct `protobuf:"bytes,2,opt,name=request,proto3" json:"request,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PlanRequest) Reset() {
+	*x = PlanRequest{}
+	mi := &file_api_gorefactor_v1_refactor_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PlanRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PlanRequest) ProtoMessage() {}
+
+func (x *PlanRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_gorefactor_v1_refactor_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PlanRequest.ProtoReflect.Descriptor instead.
+func (*PlanRequest) Descriptor() ([]byte, []int) {
+	return file_api_gorefactor_v1_refactor_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *PlanRequest) GetOperation() string {
+	if x != nil {
+		return x.Operation
+	}
+	return ""
+}
+
+func (x *PlanRequest) GetRequest() *structpb.Struct {
+	if x != nil {
+		return x.Request
+	}
+	return nil
+}
+
+// An edit of a file: the bytes from start to end, which must read old_text,
+// are replaced with new_text.
+type Change struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	File          string                 `protobuf:"bytes,1,opt,name=file,proto3" json:"file,omitempty"`
+	Start         int64                  `protobuf:"varint,2,opt,name=start,proto3" json:"start,omitempty"`
+	End           int64                  `protobuf:"varint,3,opt,name=end,proto3" json:"end,omitempty"`
+	OldText       string                 `protobuf:"bytes,4,opt,name=old_text,json=oldText,proto3" json:"old_text,omitempty"`
+	NewText       string                 `protobuf:"bytes,5,opt,name=new_text,json=newText,proto3" json:"new_text,omitempty"`
+	Description   string                 `protobuf:"bytes,6,opt,name=description,proto3" json:"description,omitempty"`
+	Confidence    Confidence             `protobuf:"varint,7,opt,name=confidence,proto3,enum=gorefactor.v1.Confidence" json:"confidence,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Change) Reset() {
+	*x = Change{}
+	mi := &file_api_gorefactor_v1_refactor_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Change) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Change) ProtoMessage() {}
+
+func (x *Change) ProtoReflect() protoreflect.Message {
+	mi := &file_api_gorefactor_v1_refactor_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Change.ProtoReflect.Descriptor instead.
+func (*Change) Descriptor() ([]byte, []int) {
+	return file_api_gorefactor_v1_refactor_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Change) GetFile() string {
+	if x != nil {
+		return x.File
+	}
+	return ""
+}
+
+func (x *Change) GetStart() int64 {
+	if x != nil {
+		return x.Start
+	}
+	return 0
+}
+
+func (x *Change) GetEnd() int64 {
+	if x != nil {
+		return x.End
+	}
+	return 0
+}
+
+func (x *Change) GetOldText() string {
+	if x != nil {
+		return x.OldText
+	}
+	return ""
+}
+
+func (x *Change) GetNewText() string {
+	if x != nil {
+		return x.NewText
+	}
+	return ""
+}
+
+func (x *Change) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *Change) GetConfidence() Confidence {
+	if x != nil {
+		return x.Confidence
+	}
+	return Confidence_CONFIDENCE_UNSPECIFIED
+}
+
+// A problem found while planning.
+type Issue struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Description   string                 `protobuf:"bytes,1,opt,name=description,proto3" json:"description,omitempty"`
+	File          string                 `protobuf:"bytes,2,opt,name=file,proto3" json:"file,omitempty"`
+	Line          int64                  `protobuf:"varint,3,opt,name=line,proto3" json:"line,omitempty"`
+	Severity      Severity               `protobuf:"varint,4,opt,name=severity,proto3,enum=gorefactor.v1.Severity" json:"severity,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Issue) Reset() {
+	*x = Issue{}
+	mi := &file_api_gorefactor_v1_refactor_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Issue) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Issue) ProtoMessage() {}
+
+func (x *Issue) ProtoReflect() protoreflect.Message {
+	mi := &file_api_gorefactor_v1_refactor_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Issue.ProtoReflect.Descriptor instead.
+func (*Issue) Descriptor() ([]byte, []int) {
+	return file_api_gorefactor_v1_refactor_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Issue) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *Issue) GetFile() string {
+	if x != nil {
+		return x.File
+	}
+	return ""
+}
+
+func (x *Issue) GetLine() int64 {
+	if x != nil {
+		return x.Line
+	}
+	return 0
+}
+
+func (x *Issue) GetSeverity() Severity {
+	if x != nil {
+		return x.Severity
+	}
+	return Severity_SEVERITY_UNSPECIFIED
+}
+
+type Plan struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Operation string                 `protobuf:"bytes,1,opt,name=operation,proto3" json:"operation,omitempty"`
+	// Digest of the changes; Apply checks it.
+	PlanHash      string    `protobuf:"bytes,2,opt,name=plan_hash,json=planHash,proto3" json:"plan_hash,omitempty"`
+	Changes       []*Change `protobuf:"bytes,3,rep,name=changes,proto3" json:"changes,omitempty"`
+	AffectedFiles []string  `protobuf:"bytes,4,rep,name=affected_files,json=affectedFiles,proto3" json:"affected_files,omitempty"`
+	Issues        []*Issue  `protobuf:"bytes,5,rep,name=issues,proto3" json:"issues,omitempty"`
+	Reversible    bool      `protobuf:"varint,6,opt,name=reversible,proto3" json:"reversible,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Plan) Reset() {
+	*x = Plan{}
+	mi := &file_api_gorefactor_v1_refactor_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Plan) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Plan) ProtoMessage() {}
+
+func (x *Plan) ProtoReflect() protoreflect.Message {
+	mi := &file_api_gorefactor_v1_refactor_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Plan.ProtoReflect.Descriptor instead.
+func (*Plan) Descriptor() ([]byte, []int) {
+	return file_api_gorefactor_v1_refactor_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Plan) GetOperation() string {
+	if x != nil {
+		return x.Operation
+	}
+	return ""
+}
+
+func (x *Plan) GetPlanHash() string {
+	if x != nil {
+		return x.PlanHash
+	}
+	return ""
+}
+
+func (x *Plan) GetChanges() []*Change {
+	if x != nil {
+		return x.Changes
+	}
+	return nil
+}
+
+func (x *Plan) GetAffectedFiles() []string {
+	if x != nil {
+		return x.AffectedFiles
+	}
+	return nil
+}
+
+func (x *Plan) GetIssues() []*Issue {
+	if x != nil {
+		return x.Issues
+	}
+	return nil
+}
+
+func (x *Plan) GetReversible() bool {
+	if x != nil {
+		return x.Reversible
+	}
+	return false
+}
+
+// Progress of the engine through a phase: parse, index, plan or apply.
+type Progress struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Phase string                 `protobuf:"bytes,1,opt,name=phase,proto3" json:"phase,omitempty"`
+	// Package or file the event relates to, if any.
+	Package string `protobuf:"bytes,2,opt,name=package,proto3" json:"package,omitempty"`
+	Current int64  `protobuf:"varint,3,opt,name=current,proto3" json:"current,omitempty"`
+	// Zero when unknown.
+	Total         int64  `protobuf:"varint,4,opt,name=total,proto3" json:"total,omitempty"`
+	Message       string `protobuf:"bytes,5,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Progress) Reset() {
+	*x = Progress{}
+	mi := &file_api_gorefactor_v1_refactor_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Progress) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Progress) ProtoMessage() {}
+
+func (x *Progress) ProtoReflect() protoreflect.Message {
+	mi := &file_api_gorefactor_v1_refactor_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Progress.ProtoReflect.Descriptor instead.
+func (*Progress) Descriptor() ([]byte, []int) {
+	return file_api_gorefactor_v1_refactor_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Progress) GetPhase() string {
+	if x != nil {
+		return x.Phase
+	}
+	return ""
+}
+
+func (x *Progress) GetPackage() string {
+	if x != nil {
+		return x.Package
+	}
+	return ""
+}
+
+func (x *Progress) GetCurrent() int64 {
+	if x != nil {
+		return x.Current
+	}
+	return 0
+}
+
+func (x *Progress) GetTotal() int64 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *Progress) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type PlanEvent struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Event:
+	//
+	//	*PlanEvent_Progress
+	//	*PlanEvent_Plan
+	Event         isPlanEvent_Event `protobuf_oneof:"event"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PlanEvent) Reset() {
+	*x = PlanEvent{}
+	mi := &file_api_gorefactor_v1_refactor_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PlanEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PlanEvent) ProtoMessage() {}
+
+func (x *PlanEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_api_gorefactor_v1_refactor_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PlanEvent.ProtoReflect.Descriptor instead.
+func (*PlanEvent) Descriptor() ([]byte, []int) {
+	return file_api_gorefactor_v1_refactor_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *PlanEvent) GetEvent() isPlanEvent_Event {
+	if x != nil {
+		return x.Event
+	}
+	return nil
+}
+
+func (x *PlanEvent) GetProgress() *Progress {
+	if x != nil {
+		if x, ok := x.Event.(*PlanEvent_Progress); ok {
+			return x.Progress
+		}
+	}
+	return nil
+}
+
+func (x *PlanEvent) GetPlan() *Plan {
+	if x != nil {
+		if x, ok := x.Event.(*PlanEvent_Plan); ok {
+			return x.Plan
+		}
+	}
+	return nil
+}
+
+type isPlanEvent_Event interface {
+	isPlanEvent_Event()
+}
+
+type PlanEvent_Progress struct {
+	Progress *Progress `protobuf:"bytes,1,opt,name=progress,proto3,oneof"`
+}
+
+type PlanEvent_Plan struct {
+	Plan *Plan `protobuf:"bytes,2,opt,name=plan,proto3,oneof"`
+}
+
+func (*PlanEvent_Progress) isPlanEvent_Event() {}
+
+func (*PlanEvent_Plan) isPlanEvent_Event() {}
+
+type PreviewResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Plan  *Plan                  `protobuf:"bytes,1,opt,name=plan,proto3" json:"plan,omitempty"`
+	// The changes listed by file, as the engine previews them.
+	Preview       string `protobuf:"bytes,2,opt,name=preview,proto3" json:"preview,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PreviewResponse) Reset() {
+	*x = PreviewResponse{}
+	mi := &file_api_gorefactor_v1_refactor_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PreviewResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PreviewResponse) ProtoMessage() {}
+
+func (x *PreviewResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_gorefactor_v1_refactor_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PreviewResponse.ProtoReflect.Descriptor instead.
+func (*PreviewResponse) Descriptor() ([]byte, []int) {
+	return file_api_gorefactor_v1_refactor_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *PreviewResponse) GetPlan() *Plan {
+	if x != nil {
+		return x.Plan
+	}
+	return nil
+}
+
+func (x *PreviewResponse) GetPreview() string {
+	if x != nil {
+		return x.Preview
+	}
+	return ""
+}
+
+type ApplyRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Plan          *Plan                  `protobuf:"bytes,1,opt,name=plan,proto3" json:"plan,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ApplyRequest) Reset() {
+	*x = ApplyRequest{}
+	mi := &file_api_gorefactor_v1_refactor_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ApplyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ApplyRequest) ProtoMessage() {}
+
+func (x *ApplyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_gorefactor_v1_refactor_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ApplyRequest.ProtoReflect.Descriptor instead.
+func (*ApplyRequest) Descriptor() ([]byte, []int) {
+	return file_api_gorefactor_v1_refactor_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ApplyRequest) GetPlan() *Plan {
+	if x != nil {
+		return x.Plan
+	}
+	return nil
+}
+
+type ApplyResult struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ModifiedFiles []string               `protobuf:"bytes,1,rep,name=modified_files,json=modifiedFiles,proto3" json:"modified_files,omitempty"`
+	ChangeCount   int64                  `protobuf:"varint,2,opt,name=change_count,json=changeCount,proto3" json:"change_count,omitempty"`
+	// Warning-level issues found while planning.
+	Warnings []string `protobuf:"bytes,3,rep,name=warnings,proto3" json:"warnings,omitempty"`
+	// Changes left out for being below the minimum confidence.
+	Skipped []*Change `protobuf:"bytes,4,rep,name=skipped,proto3" json:"skipped,omitempty"`
+	// The plan was staged in memory instead of written.
+	Staged        bool `protobuf:"varint,5,opt,name=staged,proto3" json:"staged,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ApplyResult) Reset() {
+	*x = ApplyResult{}
+	mi := &file_api_gorefactor_v1_refactor_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ApplyResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ApplyResult) ProtoMessage() {}
+
+func (x *ApplyResult) ProtoReflect() protoreflect.Message {
+	mi := &file_api_gorefactor_v1_refactor_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ApplyResult.ProtoReflect.Descriptor instead.
+func (*ApplyResult) Descriptor() ([]byte, []int) {
+	return file_api_gorefactor_v1_refactor_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ApplyResult) GetModifiedFiles() []string {
+	if x != nil {
+		return x.ModifiedFiles
+	}
+	return nil
+}
+
+func (x *ApplyResult) GetChangeCount() int64 {
+	if x != nil {
+		return x.ChangeCount
+	}
+	return 0
+}
+
+func (x *ApplyResult) GetWarnings() []string {
+	if x != nil {
+		return x.Warnings
+	}
+	return nil
+}
+
+func (x *ApplyResult) GetSkipped() []*Change {
+	if x != nil {
+		return x.Skipped
+	}
+	return nil
+}
+
+func (x *ApplyResult) GetStaged() bool {
+	if x != nil {
+		return x.Staged
+	}
+	return false
+}
+
+type ApplyEvent struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Event:
+	//
+	//	*ApplyEvent_Progress
+	//	*ApplyEvent_Result
+	Event         isApplyEvent_Event `protobuf_oneof:"event"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ApplyEvent) Reset() {
+	*x = ApplyEvent{}
+	mi := &file_api_gorefactor_v1_refactor_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ApplyEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ApplyEvent) ProtoMessage() {}
+
+func (x *ApplyEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_api_gorefactor_v1_refactor_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ApplyEvent.ProtoReflect.Descriptor instead.
+func (*ApplyEvent) Descriptor() ([]byte, []int) {
+	return file_api_gorefactor_v1_refactor_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *ApplyEvent) GetEvent() isApplyEvent_Event {
+	if x != nil {
+		return x.Event
+	}
+	return nil
+}
+
+func (x *ApplyEvent) GetProgress() *Progress {
+	if x != nil {
+		if x, ok := x.Event.(*ApplyEvent_Progress); ok {
+			return x.Progress
+		}
+	}
+	return nil
+}
+
+func (x *ApplyEvent) GetResult() *ApplyResult {
+	if x != nil {
+		if x, ok := x.Event.(*ApplyEvent_Result); ok {
+			return x.Result
+		}
+	}
+	return nil
+}
+
+type isApplyEvent_Event interface {
+	isApplyEvent_Event()
+}
+
+type ApplyEvent_Progress struct {
+	Progress *Progress `protobuf:"bytes,1,opt,name=progress,proto3,oneof"`
+}
+
+type ApplyEvent_Result struct {
+	Result *ApplyResult `protobuf:"bytes,2,opt,name=result,proto3,oneof"`
+}
+
+func (*ApplyEvent_Progress) isApplyEvent_Event() {}
+
+func (*ApplyEvent_Result) isApplyEvent_Event() {}
+
+type AnalyzeRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// complexity or unused.
+	Analyzer string `protobuf:"bytes,1,opt,name=analyzer,proto3" json:"analyzer,omitempty"`
+	// Arguments of the analyzer's MCP tool.
+	Arguments     *structpb.Struct `protobuf:"bytes,2,opt,name=arguments,proto3" json:"arguments,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AnalyzeRequest) Reset() {
+	*x = AnalyzeRequest{}
+	mi := &file_api_gorefactor_v1_refactor_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AnalyzeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AnalyzeRequest) ProtoMessage() {}
+
+func (x *AnalyzeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_gorefactor_v1_refactor_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AnalyzeRequest.ProtoReflect.Descriptor instead.
+func (*AnalyzeRequest) Descriptor() ([]byte, []int) {
+	return file_api_gorefactor_v1_refactor_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *AnalyzeRequest) GetAnalyzer() string {
+	if x != nil {
+		return x.Analyzer
+	}
+	return ""
+}
+
+func (x *AnalyzeRequest) GetArguments() *structpb.Struct {
+	if x != nil {
+		return x.Arguments
+	}
+	return nil
+}
+
+type AnalyzeResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The payload of the analyzer's MCP tool.
+	Result        *structpb.Value `protobuf:"bytes,1,opt,name=result,proto3" json:"result,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AnalyzeResponse) Reset() {
+	*x = AnalyzeResponse{}
+	mi := &file_api_gorefactor_v1_refactor_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AnalyzeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AnalyzeResponse) ProtoMessage() {}
+
+func (x *AnalyzeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_gorefactor_v1_refactor_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AnalyzeResponse.ProtoReflect.Descriptor instead.
+func (*AnalyzeResponse) Descriptor() ([]byte, []int) {
+	return file_api_gorefactor_v1_refactor_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *AnalyzeResponse) GetResult() *structpb.Value {
+	if x != nil {
+		return x.Result
+	}
+	return nil
+}
+
+var File_api_gorefactor_v1_refactor_proto protoreflect.FileDescriptor
+
+const file_api_gorefactor_v1_refactor_proto_rawDesc = "" +
+	"\n" +
+	" api/gorefactor/v1/refactor.proto\x12\rgorefactor.v1\x1a\x1cgoogle/protobuf/struct.proto\"\x15\n" +
+	"\x13ListPackagesRequest\"J\n" +
+	"\x14ListPackagesResponse\x122\n" +
+	"\bpackages\x18\x01 \x03(\v2\x16.gorefactor.v1.PackageR\bpackages\"\x85\x01\n" +
+	"\aPackage\x12\x1f\n" +
+	"\vimport_path\x18\x01 \x01(\tR\n" +
+	"importPath\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x10\n" +
+	"\x03dir\x18\x03 \x01(\tR\x03dir\x12\x14\n" +
+	"\x05files\x18\x04 \x03(\tR\x05files\x12\x1d\n" +
+	"\n" +
+	"test_files\x18\x05 \x03(\tR\ttestFiles\"^\n" +
+	"\vPlanRequest\x12\x1c\n" +
+	"\toperation\x18\x01 \x01(\tR\toperation\x121\n" +
+	"\arequest\x18\x02 \x01(\v2\x17.google.protobuf.StructR\arequest\"\xd7\x01\n" +
+	"\x06Change\x12\x12\n" +
+	"\x04file\x18\x01 \x01(\tR\x04file\x12\x14\n" +
+	"\x05start\x18\x02 \x01(\x03R\x05start\x12\x10\n" +
+	"\x03end\x18\x03 \x01(\x03R\x03end\x12\x19\n" +
+	"\bold_text\x18\x04 \x01(\tR\aoldText\x12\x19\n" +
+	"\bnew_text\x18\x05 \x01(\tR\anewText\x12 \n" +
+	"\vdescription\x18\x06 \x01(\tR\vdescription\x129\n" +
+	"\n" +
+	"confidence\x18\a \x01(\x0e2\x19.gorefactor.v1.ConfidenceR\n" +
+	"confidence\"\x86\x01\n" +
+	"\x05Issue\x12 \n" +
+	"\vdescription\x18\x01 \x01(\tR\vdescription\x12\x12\n" +
+	"\x04file\x18\x02 \x01(\tR\x04file\x12\x12\n" +
+	"\x04line\x18\x03 \x01(\x03R\x04line\x123\n" +
+	"\bseverity\x18\x04 \x01(\x0e2\x17.gorefactor.v1.SeverityR\bseverity\"\xe7\x01\n" +
+	"\x04Plan\x12\x1c\n" +
+	"\toperation\x18\x01 \x01(\tR\toperation\x12\x1b\n" +
+	"\tplan_hash\x18\x02 \x01(\tR\bplanHash\x12/\n" +
+	"\achanges\x18\x03 \x03(\v2\x15.gorefactor.v1.ChangeR\achanges\x12%\n" +
+	"\x0eaffected_files\x18\x04 \x03(\tR\raffectedFiles\x12,\n" +
+	"\x06issues\x18\x05 \x03(\v2\x14.gorefactor.v1.IssueR\x06issues\x12\x1e\n" +
+	"\n" +
+	"reversible\x18\x06 \x01(\bR\n" +
+	"reversible\"\x84\x01\n" +
+	"\bProgress\x12\x14\n" +
+	"\x05phase\x18\x01 \x01(\tR\x05phase\x12\x18\n" +
+	"\apackage\x18\x02 \x01(\tR\apackage\x12\x18\n" +
+	"\acurrent\x18\x03 \x01(\x03R\acurrent\x12\x14\n" +
+	"\x05total\x18\x04 \x01(\x03R\x05total\x12\x18\n" +
+	"\amessage\x18\x05 \x01(\tR\amessage\"v\n" +
+	"\tPlanEvent\x125\n" +
+	"\bprogress\x18\x01 \x01(\v2\x17.gorefactor.v1.ProgressH\x00R\bprogress\x12)\n" +
+	"\x04plan\x18\x02 \x01(\v2\x13.gorefactor.v1.PlanH\x00R\x04planB\a\n" +
+	"\x05event\"T\n" +
+	"\x0fPreviewResponse\x12'\n" +
+	"\x04plan\x18\x01 \x01(\v2\x13.gorefactor.v1.PlanR\x04plan\x12\x18\n" +
+	"\apreview\x18\x02 \x01(\tR\apreview\"7\n" +
+	"\fApplyRequest\x12'\n" +
+	"\x04plan\x18\x01 \x01(\v2\x13.gorefactor.v1.PlanR\x04plan\"\xbc\x01\n" +
+	"\vApplyResult\x12%\n" +
+	"\x0emodified_files\x18\x01 \x03(\tR\rmodifiedFiles\x12!\n" +
+	"\fchange_count\x18\x02 \x01(\x03R\vchangeCount\x12\x1a\n" +
+	"\bwarnings\x18\x03 \x03(\tR\bwarnings\x12/\n" +
+	"\askipped\x18\x04 \x03(\v2\x15.gorefactor.v1.ChangeR\askipped\x12\x16\n" +
+	"\x06staged\x18\x05 \x01(\bR\x06staged\"\x82\x01\n" +
+	"\n" +
+	"ApplyEvent\x125\n" +
+	"\bprogress\x18\x01 \x01(\v2\x17.gorefactor.v1.ProgressH\x00R\bprogress\x124\n" +
+	"\x06result\x18\x02 \x01(\v2\x1a.gorefactor.v1.ApplyResultH\x00R\x06resultB\a\n" +
+	"\x05event\"c\n" +
+	"\x0eAnalyzeRequest\x12\x1a\n" +
+	"\banalyzer\x18\x01 \x01(\tR\banalyzer\x125\n" +
+	"\targuments\x18\x02 \x01(\v2\x17.google.protobuf.StructR\targuments\"A\n" +
+	"\x0fAnalyzeResponse\x12.\n" +
+	"\x06result\x18\x01 \x01(\v2\x16.google.protobuf.ValueR\x06result*q\n" +
+	"\n" +
+	"Confidence\x12\x1a\n" +
+	"\x16CONFIDENCE_UNSPECIFIED\x10\x00\x12\x16\n" +
+	"\x12CONFIDENCE_CERTAIN\x10\x01\x12\x15\n" +
+	"\x11CONFIDENCE_LIKELY\x10\x02\x12\x18\n" +
+	"\x14CONFIDENCE_HEURISTIC\x10\x03*a\n" +
+	"\bSeverity\x12\x18\n" +
+	"\x14SEVERITY_UNSPECIFIED\x10\x00\x12\x12\n" +
+	"\x0eSEVERITY_ERROR\x10\x01\x12\x14\n" +
+	"\x10SEVERITY_WARNING\x10\x02\x12\x11\n" +
+	"\rSEVERITY_INFO\x10\x032\xfe\x02\n" +
+	"\x0fRefactorService\x12W\n" +
+	"\fListPackages\x12\".gorefactor.v1.ListPackagesRequest\x1a#.gorefactor.v1.ListPackagesResponse\x12>\n" +
+	"\x04Plan\x12\x1a.gorefactor.v1.PlanRequest\x1a\x18.gorefactor.v1.PlanEvent0\x01\x12E\n" +
+	"\aPreview\x12\x1a.gorefactor.v1.PlanRequest\x1a\x1e.gorefactor.v1.PreviewResponse\x12A\n" +
+	"\x05Apply\x12\x1b.gorefactor.v1.ApplyRequest\x1a\x19.gorefactor.v1.ApplyEvent0\x01\x12H\n" +
+	"\aAnalyze\x12\x1d.gorefactor.v1.AnalyzeRequest\x1a\x1e.gorefactor.v1.AnalyzeResponseB=Z;github.com/mamaar/gorefactor/api/gorefactor/v1;gorefactorv1b\x06proto3"
+
+var (
+	file_api_gorefactor_v1_refactor_proto_rawDescOnce sync.Once
+	file_api_gorefactor_v1_refactor_proto_rawDescData []byte
+)
+
+func file_api_gorefactor_v1_refactor_proto_rawDescGZIP() []byte {
+	file_api_gorefactor_v1_refactor_proto_rawDescOnce.Do(func() {
+		file_api_gorefactor_v1_refactor_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_api_gorefactor_v1_refactor_proto_rawDesc), len(file_api_gorefactor_v1_refactor_proto_rawDesc)))
+	})
+	return file_api_gorefactor_v1_refactor_proto_rawDescData
+}
+
+var file_api_gorefactor_v1_refactor_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_api_gorefactor_v1_refactor_proto_msgTypes = make([]protoimpl.MessageInfo, 15)
+var file_api_gorefactor_v1_refactor_proto_goTypes = []any{
+	(Confidence)(0),              // 0: gorefactor.v1.Confidence
+	(Severity)(0),                // 1: gorefactor.v1.Severity
+	(*ListPackagesRequest)(nil),  // 2: gorefactor.v1.ListPackagesRequest
+	(*ListPackagesResponse)(nil), // 3: gorefactor.v1.ListPackagesResponse
+	(*Package)(nil),              // 4: gorefactor.v1.Package
+	(*PlanRequest)(nil),          // 5: gorefactor.v1.PlanRequest
+	(*Change)(nil),               // 6: gorefactor.v1.Change
+	(*Issue)(nil),                // 7: gorefactor.v1.Issue
+	(*Plan)(nil),                 // 8: gorefactor.v1.Plan
+	(*Progress)(nil),             // 9: gorefactor.v1.Progress
+	(*PlanEvent)(nil),            // 10: gorefactor.v1.PlanEvent
+	(*PreviewResponse)(nil),      // 11: gorefactor.v1.PreviewResponse
+	(*ApplyRequest)(nil),         // 12: gorefactor.v1.ApplyRequest
+	(*ApplyResult)(nil),          // 13: gorefactor.v1.ApplyResult
+	(*ApplyEvent)(nil),           // 14: gorefactor.v1.ApplyEvent
+	(*AnalyzeRequest)(nil),       // 15: gorefactor.v1.AnalyzeRequest
+	(*AnalyzeResponse)(nil),      // 16: gorefactor.v1.AnalyzeResponse
+	(*structpb.Struct)(nil),      // 17: google.protobuf.Struct
+	(*structpb.Value)(nil),       // 18: google.protobuf.Value
+}
+var file_api_gorefactor_v1_refactor_proto_depIdxs = []int32{
+	4,  // 0: gorefactor.v1.ListPackagesResponse.packages:type_name -> gorefactor.v1.Package
+	17, // 1: gorefactor.v1.PlanRequest.request:type_name -> google.protobuf.Struct
+	0,  // 2: gorefactor.v1.Change.confidence:type_name -> gorefactor.v1.Confidence
+	1,  // 3: gorefactor.v1.Issue.severity:type_name -> gorefactor.v1.Severity
+	6,  // 4: gorefactor.v1.Plan.changes:type_name -> gorefactor.v1.Change
+	7,  // 5: gorefactor.v1.Plan.issues:type_name -> gorefactor.v1.Issue
+	9,  // 6: gorefactor.v1.PlanEvent.progress:type_name -> gorefactor.v1.Progress
+	8,  // 7: gorefactor.v1.PlanEvent.plan:type_name -> gorefactor.v1.Plan
+	8,  // 8: gorefactor.v1.PreviewResponse.plan:type_name -> gorefactor.v1.Plan
+	8,  // 9: gorefactor.v1.ApplyRequest.plan:type_name -> gorefactor.v1.Plan
+	6,  // 10: gorefactor.v1.ApplyResult.skipped:type_name -> gorefactor.v1.Change
+	9,  // 11: gorefactor.v1.ApplyEvent.progress:type_name -> gorefactor.v1.Progress
+	13, // 12: gorefactor.v1.ApplyEvent.result:type_name -> gorefactor.v1.ApplyResult
+	17, // 13: gorefactor.v1.AnalyzeRequest.arguments:type_name -> google.protobuf.Struct
+	18, // 14: gorefactor.v1.AnalyzeResponse.result:type_name -> google.protobuf.Value
+	2,  // 15: gorefactor.v1.RefactorService.ListPackages:input_type -> gorefactor.v1.ListPackagesRequest
+	5,  // 16: gorefactor.v1.RefactorService.Plan:input_type -> gorefactor.v1.PlanRequest
+	5,  // 17: gorefactor.v1.RefactorService.Preview:input_type -> gorefactor.v1.PlanRequest
+	12, // 18: gorefactor.v1.RefactorService.Apply:input_type -> gorefactor.v1.ApplyRequest
+	15, // 19: gorefactor.v1.RefactorService.Analyze:input_type -> gorefactor.v1.AnalyzeRequest
+	3,  // 20: gorefactor.v1.RefactorService.ListPackages:output_type -> gorefactor.v1.ListPackagesResponse
+	10, // 21: gorefactor.v1.RefactorService.Plan:output_type -> gorefactor.v1.PlanEvent
+	11, // 22: gorefactor.v1.RefactorService.Preview:output_type -> gorefactor.v1.PreviewResponse
+	14, // 23: gorefactor.v1.RefactorService.Apply:output_type -> gorefactor.v1.ApplyEvent
+	16, // 24: gorefactor.v1.RefactorService.Analyze:output_type -> gorefactor.v1.AnalyzeResponse
+	20, // [20:25] is the sub-list for method output_type
+	15, // [15:20] is the sub-list for method input_type
+	15, // [15:15] is the sub-list for extension type_name
+	15, // [15:15] is the sub-list for extension extendee
+	0,  // [0:15] is the sub-list for field type_name
+}
+
+func init() { file_api_gorefactor_v1_refactor_proto_init() }
+func file_api_gorefactor_v1_refactor_proto_init() {
+	if File_api_gorefactor_v1_refactor_proto != nil {
+		return
+	}
+	file_api_gorefactor_v1_refactor_proto_msgTypes[8].OneofWrappers = []any{
+		(*PlanEvent_Progress)(nil),
+		(*PlanEvent_Plan)(nil),
+	}
+	file_api_gorefactor_v1_refactor_proto_msgTypes[12].OneofWrappers = []any{
+		(*ApplyEvent_Progress)(nil),
+		(*ApplyEvent_Result)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_api_gorefactor_v1_refactor_proto_rawDesc), len(file_api_gorefactor_v1_refactor_proto_rawDesc)),
+			NumEnums:      2,
+			NumMessages:   15,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_api_gorefactor_v1_refactor_proto_goTypes,
+		DependencyIndexes: file_api_gorefactor_v1_refactor_proto_depIdxs,
+		EnumInfos:         file_api_gorefactor_v1_refactor_proto_enumTypes,
+		MessageInfos:      file_api_gorefactor_v1_refactor_proto_msgTypes,
+	}.Build()
+	File_api_gorefactor_v1_refactor_proto = out.File
+	file_api_gorefactor_v1_refactor_proto_goTypes = nil
+	file_api_gorefactor_v1_refactor_proto_depIdxs = nil
+}
@@ -0,0 +1,304 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: api/gorefactor/v1/refactor.proto
+
+// The gRPC API of the refactoring engine, served by
+// gorefactor-mcp serve -grpc. It plans, previews and applies refactorings of
+// the workspace the server loaded and runs analyzers on it.
+
+package gorefactorv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	RefactorService_ListPackages_FullMethodName = "/gorefactor.v1.RefactorService/ListPackages"
+	RefactorService_Plan_FullMethodName         = "/gorefactor.v1.RefactorService/Plan"
+	RefactorService_Preview_FullMethodName      = "/gorefactor.v1.RefactorService/Preview"
+	RefactorService_Apply_FullMethodName        = "/gorefactor.v1.RefactorService/Apply"
+	RefactorService_Analyze_FullMethodName      = "/gorefactor.v1.RefactorService/Analyze"
+)
+
+// RefactorServiceClient is the client API for RefactorService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type RefactorServiceClient interface {
+	// Lists the packages of the workspace.
+	ListPackages(ctx context.Context, in *ListPackagesRequest, opts ...grpc.CallOption) (*ListPackagesResponse, error)
+	// Plans an operation without applying it. The engine's progress is
+	// streamed while it plans, and the last event carries the plan.
+	Plan(ctx context.Context, in *PlanRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[PlanEvent], error)
+	// Plans an operation and describes its changes file by file, without
+	// applying it.
+	Preview(ctx context.Context, in *PlanRequest, opts ...grpc.CallOption) (*PreviewResponse, error)
+	// Applies a plan returned by Plan or Preview. The engine's progress is
+	// streamed while it applies, and the last event carries the result. Plans
+	// whose plan_hash doesn't match their changes, that write outside the
+	// workspace, or whose files changed since they were made are refused.
+	Apply(ctx context.Context, in *ApplyRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ApplyEvent], error)
+	// Runs an analyzer tool, such as complexity or unused.
+	Analyze(ctx context.Context, in *AnalyzeRequest, opts ...grpc.CallOption) (*AnalyzeResponse, error)
+}
+
+type refactorServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewRefactorServiceClient(cc grpc.ClientConnInterface) RefactorServiceClient {
+	return &refactorServiceClient{cc}
+}
+
+func (c *refactorServiceClient) ListPackages(ctx context.Context, in *ListPackagesRequest, opts ...grpc.CallOption) (*ListPackagesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListPackagesResponse)
+	err := c.cc.Invoke(ctx, RefactorService_ListPackages_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *refactorServiceClient) Plan(ctx context.Context, in *PlanRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[PlanEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &RefactorService_ServiceDesc.Streams[0], RefactorService_Plan_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[PlanRequest, PlanEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type RefactorService_PlanClient = grpc.ServerStreamingClient[PlanEvent]
+
+func (c *refactorServiceClient) Preview(ctx context.Context, in *PlanRequest, opts ...grpc.CallOption) (*PreviewResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PreviewResponse)
+	err := c.cc.Invoke(ctx, RefactorService_Preview_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *refactorServiceClient) Apply(ctx context.Context, in *ApplyRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ApplyEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &RefactorService_ServiceDesc.Streams[1], RefactorService_Apply_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ApplyRequest, ApplyEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type RefactorService_ApplyClient = grpc.ServerStreamingClient[ApplyEvent]
+
+func (c *refactorServiceClient) Analyze(ctx context.Context, in *AnalyzeRequest, opts ...grpc.CallOption) (*AnalyzeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AnalyzeResponse)
+	err := c.cc.Invoke(ctx, RefactorService_Analyze_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RefactorServiceServer is the server API for RefactorService service.
+// All implementations must embed UnimplementedRefactorServiceServer
+// for forward compatibility.
+type RefactorServiceServer interface {
+	// Lists the packages of the workspace.
+	ListPackages(context.Context, *ListPackagesRequest) (*ListPackagesResponse, error)
+	// Plans an operation without applying it. The engine's progress is
+	// streamed while it plans, and the last event carries the plan.
+	Plan(*PlanRequest, grpc.ServerStreamingServer[PlanEvent]) error
+	// Plans an operation and describes its changes file by file, without
+	// applying it.
+	Preview(context.Context, *PlanRequest) (*PreviewResponse, error)
+	// Applies a plan returned by Plan or Preview. The engine's progress is
+	// streamed while it applies, and the last event carries the result. Plans
+	// whose plan_hash doesn't match their changes, that write outside the
+	// workspace, or whose files changed since they were made are refused.
+	Apply(*ApplyRequest, grpc.ServerStreamingServer[ApplyEvent]) error
+	// Runs an analyzer tool, such as complexity or unused.
+	Analyze(context.Context, *AnalyzeRequest) (*AnalyzeResponse, error)
+	mustEmbedUnimplementedRefactorServiceServer()
+}
+
+// UnimplementedRefactorServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedRefactorServiceServer struct{}
+
+func (UnimplementedRefactorServiceServer) ListPackages(context.Context, *ListPackagesRequest) (*ListPackagesResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListPackages not implemented")
+}
+func (UnimplementedRefactorServiceServer) Plan(*PlanRequest, grpc.ServerStreamingServer[PlanEvent]) error {
+	return status.Error(codes.Unimplemented, "method Plan not implemented")
+}
+func (UnimplementedRefactorServiceServer) Preview(context.Context, *PlanRequest) (*PreviewResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Preview not implemented")
+}
+func (UnimplementedRefactorServiceServer) Apply(*ApplyRequest, grpc.ServerStreamingServer[ApplyEvent]) error {
+	return status.Error(codes.Unimplemented, "method Apply not implemented")
+}
+func (UnimplementedRefactorServiceServer) Analyze(context.Context, *AnalyzeRequest) (*AnalyzeResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Analyze not implemented")
+}
+func (UnimplementedRefactorServiceServer) mustEmbedUnimplementedRefactorServiceServer() {}
+func (UnimplementedRefactorServiceServer) testEmbeddedByValue()                         {}
+
+// UnsafeRefactorServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to RefactorServiceServer will
+// result in compilation errors.
+type UnsafeRefactorServiceServer interface {
+	mustEmbedUnimplementedRefactorServiceServer()
+}
+
+func RegisterRefactorServiceServer(s grpc.ServiceRegistrar, srv RefactorServiceServer) {
+	// If the following call panics, it indicates UnimplementedRefactorServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&RefactorService_ServiceDesc, srv)
+}
+
+func _RefactorService_ListPackages_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListPackagesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RefactorServiceServer).ListPackages(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RefactorService_ListPackages_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RefactorServiceServer).ListPackages(ctx, req.(*ListPackagesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RefactorService_Plan_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(PlanRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RefactorServiceServer).Plan(m, &grpc.GenericServerStream[PlanRequest, PlanEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type RefactorService_PlanServer = grpc.ServerStreamingServer[PlanEvent]
+
+func _RefactorService_Preview_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PlanRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RefactorServiceServer).Preview(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RefactorService_Preview_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RefactorServiceServer).Preview(ctx, req.(*PlanRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RefactorService_Apply_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ApplyRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RefactorServiceServer).Apply(m, &grpc.GenericServerStream[ApplyRequest, ApplyEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type RefactorService_ApplyServer = grpc.ServerStreamingServer[ApplyEvent]
+
+func _RefactorService_Analyze_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AnalyzeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RefactorServiceServer).Analyze(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RefactorService_Analyze_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RefactorServiceServer).Analyze(ctx, req.(*AnalyzeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// RefactorService_ServiceDesc is the grpc.ServiceDesc for RefactorService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var RefactorService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "gorefactor.v1.RefactorService",
+	HandlerType: (*RefactorServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListPackages",
+			Handler:    _RefactorService_ListPackages_Handler,
+		},
+		{
+			MethodName: "Preview",
+			Handler:    _RefactorService_Preview_Handler,
+		},
+		{
+			MethodName: "Analyze",
+			Handler:    _RefactorService_Analyze_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Plan",
+			Handler:       _RefactorService_Plan_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Apply",
+			Handler:       _RefactorService_Apply_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "api/gorefactor/v1/refactor.proto",
+}
@@ -0,0 +1,67 @@
+// Command benchcmp compares two `go test -bench` output files and exits
+// non-zero when any benchmark regressed by more than the given threshold.
+//
+//	benchcmp -threshold 10 old.txt new.txt
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mamaar/gorefactor/internal/benchcmp"
+)
+
+func main() {
+	threshold := flag.Float64("threshold", 10, "maximum allowed increase in percent")
+	metrics := flag.String("metrics", "ns/op,B/op,allocs/op", "comma-separated metrics to compare")
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		fmt.Fprintln(os.Stderr, "usage: benchcmp [-threshold pct] [-metrics list] old.txt new.txt")
+		os.Exit(2)
+	}
+
+	old, err := parseFile(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	cur, err := parseFile(flag.Arg(1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	var selected []benchcmp.Metric
+	for m := range strings.SplitSeq(*metrics, ",") {
+		if m = strings.TrimSpace(m); m != "" {
+			selected = append(selected, benchcmp.Metric(m))
+		}
+	}
+
+	regressions := benchcmp.Compare(old, cur, *threshold, selected...)
+	if len(regressions) == 0 {
+		fmt.Printf("no regressions above %.1f%%\n", *threshold)
+		return
+	}
+	fmt.Printf("%d regression(s) above %.1f%%:\n", len(regressions), *threshold)
+	for _, r := range regressions {
+		fmt.Println("  " + r.String())
+	}
+	os.Exit(1)
+}
+
+func parseFile(path string) (benchcmp.Results, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	results, err := benchcmp.Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return results, nil
+}
@@ -0,0 +1,74 @@
+// Command gorefactor-lsp is a language server for Go workspaces, answering
+// go to definition, find references and workspace symbol search over stdio
+// with the symbol resolution and reference index of the refactoring tools,
+// and publishing the findings of the analyzers as diagnostics, with their
+// suggested fixes as quick fixes.
+//
+//	gorefactor-lsp [-workspace dir] [-log-file path] [-pprof addr] [-metrics addr] [-profile cpu|mem]
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/mamaar/gorefactor/internal/logging"
+	"github.com/mamaar/gorefactor/internal/lsp"
+	internalmcp "github.com/mamaar/gorefactor/internal/mcp"
+	"github.com/mamaar/gorefactor/internal/profiling"
+)
+
+func main() {
+	workspace := flag.String("workspace", "", "workspace to serve instead of the root the editor names")
+	// stdout and stdin carry the protocol, so logs go to a file by default.
+	logFlags := logging.Flags{Level: slog.LevelInfo, File: filepath.Join(logging.StateDir(), "gorefactor-lsp.log")}
+	logFlags.Register(flag.CommandLine)
+	var profileFlags profiling.Flags
+	profileFlags.Register(flag.CommandLine)
+	flag.Parse()
+
+	logger, closeLog, err := logFlags.New()
+	if err != nil {
+		fatal(err)
+	}
+	logger.Info("language server starting", "version", "1.0.0")
+	stopProfile, err := profileFlags.Start(logger)
+	if err != nil {
+		fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	state := internalmcp.NewMCPServer(logger)
+	err = lsp.NewServer(state, logger, *workspace).Serve(ctx, os.Stdin, os.Stdout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	if serr := state.Shutdown(shutdownCtx); serr != nil {
+		logger.Error("unclean shutdown", "err", serr)
+	}
+	cancel()
+	if perr := stopProfile(); perr != nil {
+		logger.Error("failed to write profile", "err", perr)
+	}
+	logger.Info("language server stopped", "err", err)
+	_ = closeLog()
+	if errors.Is(err, lsp.ErrExitWithoutShutdown) {
+		os.Exit(1)
+	}
+	if err != nil {
+		fatal(err)
+	}
+}
+
+// fatal prints err to stderr and exits with status 1.
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "gorefactor-lsp:", err)
+	os.Exit(1)
+}
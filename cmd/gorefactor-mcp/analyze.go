@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// formatSARIF is the analyze subcommand's SARIF 2.1.0 output format.
+const formatSARIF = "sarif"
+
+const analyzeUsage = `usage: gorefactor-mcp analyze [flags] [analyzer ...]
+
+Runs the code quality analyzers (all of them unless some are named) over a
+workspace and prints every finding, using the thresholds of the workspace's
+.gorefactor.yaml. With -format sarif the output is a SARIF 2.1.0 log that
+can be uploaded to GitHub code scanning.
+
+With -since, only the packages holding Go files changed since that git
+revision are analyzed, only findings in those files are reported, and
+nothing is printed when no Go file changed. -fail sets exit status 1 when
+anything is reported, for pre-commit hooks and CI.
+
+Findings recorded in the workspace's gorefactor-baseline.json (or the file
+given with -baseline) are not reported, so a project can adopt the
+analyzers and fail only on new findings. -write-baseline records every
+current finding there. A "//gorefactor:ignore rule[,rule...] [reason]"
+comment suppresses findings on its line and on the line after it.
+
+Analyzers: complexity, unused, ifinit, errorwrap, errorsentinel,
+booleanbranch, deepifelse, envbool, missingctx, pipeline, magicnumber,
+errorreturn, positionallit, doccoverage, naming, modernize, loopconv,
+testsetup
+
+Flags:
+`
+
+// runAnalyze implements the analyze subcommand on top of the run_analyzers
+// tool.
+func runAnalyze(ctx context.Context, stdout io.Writer, args []string) (err error) {
+	fs := flag.NewFlagSet("analyze", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), analyzeUsage)
+		fs.PrintDefaults()
+	}
+	workspace := fs.String("workspace", ".", "workspace root (go.mod directory)")
+	format := fs.String("format", formatText, "output format: text, json or sarif")
+	pkg := fs.String("package", "", "only analyze this package")
+	output := fs.String("o", "", "write the report to this file instead of stdout")
+	since := fs.String("since", "", "only analyze Go files changed since this git revision")
+	fail := fs.Bool("fail", false, "exit with status 1 when there are findings")
+	baseline := fs.String("baseline", "", "baseline file of accepted findings (default: gorefactor-baseline.json in the workspace)")
+	noBaseline := fs.Bool("no-baseline", false, "report the findings recorded in the baseline too")
+	writeBaseline := fs.Bool("write-baseline", false, "record every current finding in the baseline file")
+	logFlags := registerCommandFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	toolArgs := map[string]any{"format": "json"}
+	opts := runOptions{workspace: *workspace, format: *format, log: *logFlags}
+	switch *format {
+	case formatText, formatJSON:
+	case formatSARIF:
+		toolArgs["format"] = formatSARIF
+		opts.format = formatJSON
+	default:
+		return fmt.Errorf("unknown format %q: want %s, %s or %s", *format, formatText, formatJSON, formatSARIF)
+	}
+	if *pkg != "" {
+		toolArgs["package"] = *pkg
+	}
+	if fs.NArg() > 0 {
+		toolArgs["analyzers"] = fs.Args()
+	}
+	if *baseline != "" {
+		path, err := filepath.Abs(*baseline)
+		if err != nil {
+			return err
+		}
+		toolArgs["baseline"] = path
+	}
+	if *noBaseline {
+		toolArgs["no_baseline"] = true
+	}
+	if *writeBaseline {
+		if *since != "" {
+			return fmt.Errorf("-write-baseline and -since can't be combined")
+		}
+		toolArgs["write_baseline"] = true
+	}
+	if *since != "" {
+		if *pkg != "" {
+			return fmt.Errorf("-since and -package can't be combined")
+		}
+		root, err := filepath.Abs(*workspace)
+		if err != nil {
+			return err
+		}
+		files, err := changedGoFiles(ctx, root, *since)
+		if err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			return nil
+		}
+		toolArgs["files"] = files
+	}
+
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+		}()
+		stdout = f
+	}
+	if !*fail {
+		return invoke(ctx, stdout, opts, "run_analyzers", toolArgs)
+	}
+	var buf bytes.Buffer
+	err = invoke(ctx, io.MultiWriter(stdout, &buf), opts, "run_analyzers", toolArgs)
+	if err == nil && reportedFindings(buf.Bytes()) {
+		err = errCheckFailed
+	}
+	return err
+}
+
+// reportedFindings reports whether analyze output, in any of its formats,
+// lists a finding. YAML parses the JSON formats too.
+func reportedFindings(out []byte) bool {
+	var report struct {
+		TotalCount int `yaml:"total_count"`
+		Runs       []struct {
+			Results []any `yaml:"results"`
+		} `yaml:"runs"`
+	}
+	if err := yaml.Unmarshal(out, &report); err != nil {
+		return true
+	}
+	for _, run := range report.Runs {
+		report.TotalCount += len(run.Results)
+	}
+	return report.TotalCount > 0
+}
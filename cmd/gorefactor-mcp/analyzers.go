@@ -0,0 +1,19 @@
+package main
+
+import (
+	"golang.org/x/tools/go/analysis/passes/assign"
+	"golang.org/x/tools/go/analysis/passes/bools"
+	"golang.org/x/tools/go/analysis/passes/stringintconv"
+	"golang.org/x/tools/go/analysis/passes/unusedresult"
+
+	"github.com/mamaar/gorefactor/pkg/analyzers"
+)
+
+// Standard vet passes exposed as detect_/fix_ tools. Builds of gorefactor
+// add their own analyzers the same way.
+func init() {
+	analyzers.MustRegister(analyzers.Registration{Analyzer: assign.Analyzer, Fixable: true})
+	analyzers.MustRegister(analyzers.Registration{Analyzer: bools.Analyzer})
+	analyzers.MustRegister(analyzers.Registration{Analyzer: stringintconv.Analyzer, Fixable: true})
+	analyzers.MustRegister(analyzers.Registration{Analyzer: unusedresult.Analyzer})
+}
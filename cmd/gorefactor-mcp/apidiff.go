@@ -0,0 +1,202 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/mamaar/gorefactor/pkg/analysis"
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+const apidiffUsage = `usage: gorefactor-mcp apidiff [flags] <ref>
+
+Compares the exported API of the workspace's packages with their API at a
+git revision, read from the repository without touching the working tree,
+and prints every addition, removal and signature change, marked breaking
+when code using the old API may no longer compile. Use it after a
+refactoring session to check that the public API was preserved:
+
+  gorefactor-mcp apidiff main
+
+Commands and internal packages are left out unless -internal is given.
+Exits with status 1 when there is a breaking change, for CI.
+
+Flags:
+`
+
+// runAPIDiff implements the apidiff subcommand.
+func runAPIDiff(ctx context.Context, stdout io.Writer, args []string) (err error) {
+	fs := flag.NewFlagSet("apidiff", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), apidiffUsage)
+		fs.PrintDefaults()
+	}
+	workspace := fs.String("workspace", ".", "workspace root (go.mod directory)")
+	format := fs.String("format", formatText, "output format: text or json")
+	internal := fs.Bool("internal", false, "include internal packages and commands")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	if *format != formatText && *format != formatJSON {
+		return fmt.Errorf("unknown format %q: want %s or %s", *format, formatText, formatJSON)
+	}
+	ref := fs.Arg(0)
+	root, err := filepath.Abs(*workspace)
+	if err != nil {
+		return err
+	}
+
+	oldRoot, err := os.MkdirTemp("", "gorefactor-apidiff-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(oldRoot)
+	if err := extractRevision(ctx, root, ref, oldRoot); err != nil {
+		return err
+	}
+	oldAPI, err := workspaceAPI(oldRoot, *internal)
+	if err != nil {
+		return fmt.Errorf("%s: %w", ref, err)
+	}
+	newAPI, err := workspaceAPI(root, *internal)
+	if err != nil {
+		return err
+	}
+	changes := analysis.DiffAPI(oldAPI, newAPI)
+
+	if *format == formatJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(map[string]any{"ref": ref, "changes": changes})
+	} else {
+		err = writeAPIChanges(stdout, ref, changes)
+	}
+	if err == nil && slices.ContainsFunc(changes, func(c analysis.APIChange) bool { return c.Breaking }) {
+		err = errCheckFailed
+	}
+	return err
+}
+
+// extractRevision writes the go.mod and Go files of the directory root is
+// in, as they were at ref, to dir.
+func extractRevision(ctx context.Context, root, ref, dir string) error {
+	prefix, err := git(ctx, root, "rev-parse", "--show-prefix")
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, "git", "archive", "--format=tar", ref+":"+prefix)
+	cmd.Dir = root
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("git archive: %s", msg)
+		}
+		return fmt.Errorf("git archive: %w", err)
+	}
+
+	tr := tar.NewReader(bytes.NewReader(out))
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := filepath.FromSlash(hdr.Name)
+		if hdr.Typeflag != tar.TypeReg || !filepath.IsLocal(name) ||
+			filepath.Base(name) != "go.mod" && !strings.HasSuffix(name, ".go") {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			return err
+		}
+	}
+}
+
+// workspaceAPI returns the exported API of the packages of the workspace
+// at root, without commands and, unless internal is set, internal packages.
+func workspaceAPI(root string, internal bool) ([]*analysis.PackageAPI, error) {
+	ws, err := analysis.NewParser(slog.New(slog.NewTextHandler(io.Discard, nil))).ParseWorkspace(root)
+	if err != nil {
+		return nil, err
+	}
+	var apis []*analysis.PackageAPI
+	for _, pkg := range ws.Packages {
+		if !internal && (pkg.Name == "main" || isInternal(pkg)) {
+			continue
+		}
+		apis = append(apis, analysis.ExtractPackageAPI(ws, pkg))
+	}
+	return apis, nil
+}
+
+// isInternal reports whether pkg can only be imported from its own module.
+func isInternal(pkg *types.Package) bool {
+	return slices.Contains(strings.Split(pkg.ImportPath, "/"), "internal")
+}
+
+// writeAPIChanges prints changes grouped by package, breaking ones marked,
+// with the old and new signatures of each.
+func writeAPIChanges(w io.Writer, ref string, changes []analysis.APIChange) error {
+	var sb bytes.Buffer
+	breaking, pkg := 0, ""
+	for _, c := range changes {
+		if c.Package != pkg {
+			pkg = c.Package
+			fmt.Fprintf(&sb, "%s\n", pkg)
+		}
+		mark := "compatible"
+		if c.Breaking {
+			mark = "BREAKING"
+			breaking++
+		}
+		line := fmt.Sprintf("\t%s: %s", mark, c.Kind)
+		if c.Symbol != "" {
+			line += " " + c.Symbol
+		}
+		if c.Reason != "" {
+			line += " (" + c.Reason + ")"
+		}
+		sb.WriteString(line + "\n")
+		for _, sig := range []struct{ mark, text string }{{"-", c.Old}, {"+", c.New}} {
+			for l := range strings.Lines(sig.text) {
+				fmt.Fprintf(&sb, "\t\t%s %s", sig.mark, strings.TrimSuffix(l, "\n")+"\n")
+			}
+		}
+	}
+	if len(changes) == 0 {
+		fmt.Fprintf(&sb, "no API changes since %s\n", ref)
+	} else {
+		fmt.Fprintf(&sb, "%d changes since %s, %d breaking\n", len(changes), ref, breaking)
+	}
+	_, err := w.Write(sb.Bytes())
+	return err
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAPIDiff(t *testing.T) {
+	dir := gitWorkspace(t)
+	util := filepath.Join(dir, "util")
+	if err := os.MkdirAll(util, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(src string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(util, "util.go"), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("package util\n\nfunc Double(n int) int { return 2 * n }\n\nfunc Half(n int) int { return n / 2 }\n")
+	for _, args := range [][]string{
+		{"add", "-A"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "util"},
+	} {
+		if _, err := git(context.Background(), dir, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := runAPIDiff(context.Background(), &out, []string{"-workspace", dir, "HEAD"}); err != nil {
+		t.Fatalf("unchanged workspace: %v\n%s", err, out.String())
+	}
+	if !strings.Contains(out.String(), "no API changes since HEAD") {
+		t.Errorf("unchanged workspace:\n%s", out.String())
+	}
+
+	write("package util\n\nfunc Double(x int) int { return 2 * x }\n\nfunc Triple(n int) int { return 3 * n }\n")
+	out.Reset()
+	err := runAPIDiff(context.Background(), &out, []string{"-workspace", dir, "HEAD"})
+	if !errors.Is(err, errCheckFailed) {
+		t.Fatalf("removing Half: got %v, want a failed check\n%s", err, out.String())
+	}
+	for _, want := range []string{"BREAKING: removed Half", "compatible: added Triple", "2 changes since HEAD, 1 breaking"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output is missing %q:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "Double") {
+		t.Errorf("renaming a parameter was reported:\n%s", out.String())
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// formatDOT is the callgraph subcommand's Graphviz output format.
+const formatDOT = "dot"
+
+const callgraphUsage = `usage: gorefactor-mcp callgraph [flags]
+
+Prints the static call graph of a workspace, or with -root the part of it
+reachable from the functions of one package. Calls through an interface
+method also lead to the workspace methods implementing it. -format dot
+writes a Graphviz digraph with a cluster per package:
+
+  gorefactor-mcp callgraph -root pkg/foo -format dot | dot -Tsvg > foo.svg
+
+Flags:
+`
+
+// runCallgraph implements the callgraph subcommand on top of the call_graph
+// tool.
+func runCallgraph(ctx context.Context, stdout io.Writer, args []string) (err error) {
+	fs := flag.NewFlagSet("callgraph", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), callgraphUsage)
+		fs.PrintDefaults()
+	}
+	workspace := fs.String("workspace", ".", "workspace root (go.mod directory)")
+	root := fs.String("root", "", "package whose functions start the graph (default: the whole workspace)")
+	depth := fs.Int("depth", 0, "calls to follow from -root (default: all)")
+	format := fs.String("format", formatJSON, "output format: json or dot")
+	output := fs.String("o", "", "write the graph to this file instead of stdout")
+	logFlags := registerCommandFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	toolArgs := map[string]any{"format": *format}
+	opts := runOptions{workspace: *workspace, format: formatJSON, log: *logFlags}
+	switch *format {
+	case formatJSON:
+	case formatDOT:
+		// The tool returns DOT as plain text, which text output prints as is.
+		opts.format = formatText
+	default:
+		return fmt.Errorf("unknown format %q: want %s or %s", *format, formatJSON, formatDOT)
+	}
+	if *root != "" {
+		toolArgs["root"] = *root
+	}
+	if *depth != 0 {
+		toolArgs["depth"] = *depth
+	}
+
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+		}()
+		stdout = f
+	}
+	return invoke(ctx, stdout, opts, "call_graph", toolArgs)
+}
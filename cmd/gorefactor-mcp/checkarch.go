@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/mamaar/gorefactor/pkg/analysis"
+)
+
+const checkArchUsage = `usage: gorefactor-mcp check-arch [flags]
+
+Checks the imports of a workspace against the architecture rules of its
+.gorefactor.yaml and prints every violation with the offending import line
+and the move_symbol calls that would remove it:
+
+  architecture:
+    - package: internal/domain/...
+      forbid: [internal/http/..., database/sql]
+      reason: the domain is transport agnostic
+    - package: internal/store
+      allow: [internal/domain/...]
+
+Exits with status 1 when there is a violation, for pre-commit hooks and CI.
+
+Flags:
+`
+
+// runCheckArch implements the check-arch subcommand on top of the
+// check_architecture tool.
+func runCheckArch(ctx context.Context, stdout io.Writer, args []string) (err error) {
+	fs := flag.NewFlagSet("check-arch", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), checkArchUsage)
+		fs.PrintDefaults()
+	}
+	workspace := fs.String("workspace", ".", "workspace root (go.mod directory)")
+	format := fs.String("format", formatText, "output format: text or json")
+	pkg := fs.String("package", "", "only check this package")
+	output := fs.String("o", "", "write the report to this file instead of stdout")
+	logFlags := registerCommandFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != formatText && *format != formatJSON {
+		return fmt.Errorf("unknown format %q: want %s or %s", *format, formatText, formatJSON)
+	}
+	toolArgs := map[string]any{}
+	if *pkg != "" {
+		toolArgs["package"] = *pkg
+	}
+
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+		}()
+		stdout = f
+	}
+	var buf bytes.Buffer
+	if err := invoke(ctx, &buf, runOptions{workspace: *workspace, format: formatJSON, log: *logFlags}, "check_architecture", toolArgs); err != nil {
+		// Tool errors are reported as JSON; show them as the tool wrote them.
+		_, _ = stdout.Write(buf.Bytes())
+		return err
+	}
+	var report struct {
+		Violations []*analysis.ArchViolation `json:"violations"`
+		Count      int                       `json:"count"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		return err
+	}
+
+	if *format == formatJSON {
+		_, err = stdout.Write(buf.Bytes())
+	} else {
+		err = writeViolations(stdout, *workspace, report.Violations)
+	}
+	if err == nil && report.Count > 0 {
+		err = errCheckFailed
+	}
+	return err
+}
+
+// writeViolations prints violations as file:line diagnostics, relative to
+// the workspace root, each followed by its import line and fixes.
+func writeViolations(w io.Writer, workspace string, violations []*analysis.ArchViolation) error {
+	root, err := filepath.Abs(workspace)
+	if err != nil {
+		return err
+	}
+	var sb bytes.Buffer
+	for _, v := range violations {
+		file := v.File
+		if rel, err := filepath.Rel(root, file); err == nil {
+			file = rel
+		}
+		fmt.Fprintf(&sb, "%s:%d: %s\n", file, v.Line, v.Message)
+		if v.Reason != "" {
+			fmt.Fprintf(&sb, "\treason: %s\n", v.Reason)
+		}
+		fmt.Fprintf(&sb, "\t%s\n", v.Source)
+		for _, fix := range v.Fixes {
+			fmt.Fprintf(&sb, "\tfix: move_symbol %s from %s to %s\n", fix.Symbol, fix.FromPackage, fix.ToPackage)
+		}
+	}
+	_, err = w.Write(sb.Bytes())
+	return err
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+const historyUsage = `usage: gorefactor-mcp history [flags] list
+
+Lists the refactorings applied to a workspace, oldest first, from the
+journal in .gorefactor/history. Their IDs are what rollback -to takes.
+
+Flags:
+`
+
+// runHistory implements the history subcommand on top of the history_list
+// tool.
+func runHistory(ctx context.Context, stdout io.Writer, args []string) error {
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), historyUsage)
+		fs.PrintDefaults()
+	}
+	workspace := fs.String("workspace", ".", "workspace root (go.mod directory)")
+	format := fs.String("format", formatText, "output format: text or json (the MCP tool payload)")
+	logFlags := registerCommandFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != formatText && *format != formatJSON {
+		return fmt.Errorf("unknown format %q: want %s or %s", *format, formatText, formatJSON)
+	}
+	if fs.NArg() != 1 || fs.Arg(0) != "list" {
+		fs.Usage()
+		return fmt.Errorf("expected the list command")
+	}
+	opts := runOptions{workspace: *workspace, format: *format, log: *logFlags}
+	return invoke(ctx, stdout, opts, "history_list", map[string]any{})
+}
+
+const rollbackUsage = `usage: gorefactor-mcp rollback [flags] [-to <id>]
+
+Reverts applied refactorings from the journal in .gorefactor/history,
+newest first, down to and including the entry -to names; without -to, the
+most recent one. Files edited since a refactoring was applied stop the
+rollback unless -force is given.
+
+Example:
+  gorefactor-mcp history list
+  gorefactor-mcp rollback -to 20240102T150405.000000000Z
+
+Flags:
+`
+
+// runRollback implements the rollback subcommand on top of the rollback
+// tool.
+func runRollback(ctx context.Context, stdout io.Writer, args []string) error {
+	fs := flag.NewFlagSet("rollback", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), rollbackUsage)
+		fs.PrintDefaults()
+	}
+	workspace := fs.String("workspace", ".", "workspace root (go.mod directory)")
+	format := fs.String("format", formatText, "output format: text or json (the MCP tool payload)")
+	to := fs.String("to", "", "history entry to roll back to, as history list prints it; default: the most recent")
+	force := fs.Bool("force", false, "overwrite files edited after a refactoring was applied")
+	logFlags := registerCommandFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != formatText && *format != formatJSON {
+		return fmt.Errorf("unknown format %q: want %s or %s", *format, formatText, formatJSON)
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return fmt.Errorf("unexpected arguments %q", fs.Args())
+	}
+	toolArgs := map[string]any{"force": *force}
+	if *to != "" {
+		toolArgs["to_id"] = *to
+	}
+	opts := runOptions{workspace: *workspace, format: *format, log: *logFlags}
+	return invoke(ctx, stdout, opts, "rollback", toolArgs)
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/mamaar/gorefactor/pkg/config"
+	"github.com/mamaar/gorefactor/pkg/refactor"
+)
+
+// errCheckFailed reports that a check found problems, which have already
+// been printed.
+var errCheckFailed = errors.New("check failed")
+
+// hookMarker identifies hooks written by install-hooks, which it may
+// overwrite.
+const hookMarker = "# gorefactor pre-commit hook"
+
+const installHooksUsage = `usage: gorefactor-mcp install-hooks [flags]
+
+Writes a git pre-commit hook that checks the staged Go files: their imports
+must be tidy (tidy-imports -check -staged) and the analyzers must report
+nothing in the files changed since HEAD (analyze -since HEAD -fail). The
+hooks section of .gorefactor.yaml adds a format check and picks the
+analyzers:
+
+  hooks:
+    format: true
+    analyzers: [errorwrap, ifinit]
+
+Re-run install-hooks after changing that section.
+
+Flags:
+`
+
+// runInstallHooks implements the install-hooks subcommand.
+func runInstallHooks(ctx context.Context, stdout io.Writer, args []string) error {
+	fs := flag.NewFlagSet("install-hooks", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), installHooksUsage)
+		fs.PrintDefaults()
+	}
+	workspace := fs.String("workspace", ".", "workspace root (go.mod directory)")
+	bin := fs.String("bin", "gorefactor-mcp", "gorefactor-mcp command the hook runs")
+	force := fs.Bool("force", false, "replace an existing pre-commit hook not written by install-hooks")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	root, err := filepath.Abs(*workspace)
+	if err != nil {
+		return err
+	}
+	cfg, err := config.LoadWorkspace(root)
+	if err != nil {
+		return err
+	}
+	hooksDir, err := git(ctx, root, "rev-parse", "--git-path", "hooks")
+	if err != nil {
+		return err
+	}
+	if !filepath.IsAbs(hooksDir) {
+		hooksDir = filepath.Join(root, hooksDir)
+	}
+
+	path := filepath.Join(hooksDir, "pre-commit")
+	if existing, err := os.ReadFile(path); err == nil && !*force && !bytes.Contains(existing, []byte(hookMarker)) {
+		return fmt.Errorf("%s exists and was not written by install-hooks; pass -force to replace it", path)
+	}
+	if err := os.MkdirAll(hooksDir, 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(preCommitHook(root, *bin, cfg.Hooks)), 0o755); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(stdout, "installed %s\n", path)
+	return err
+}
+
+// preCommitHook returns the hook script for the workspace at root.
+func preCommitHook(root, bin string, hooks config.HooksConfig) string {
+	tidy := []string{shellQuote(bin), "tidy-imports", "-check", "-staged"}
+	if hooks.Format {
+		tidy = append(tidy, "-format")
+	}
+	analyze := []string{shellQuote(bin), "analyze", "-since", "HEAD", "-fail"}
+	for _, a := range hooks.Analyzers {
+		analyze = append(analyze, shellQuote(a))
+	}
+	return fmt.Sprintf(`#!/bin/sh
+%s, written by gorefactor-mcp install-hooks.
+# Re-run install-hooks after changing the hooks section of .gorefactor.yaml.
+set -e
+cd %s
+%s
+%s
+`, hookMarker, shellQuote(root), strings.Join(tidy, " "), strings.Join(analyze, " "))
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+const tidyImportsUsage = `usage: gorefactor-mcp tidy-imports [flags] [file ...]
+
+Groups the imports of Go files the way refactorings write them, following
+the format section of .gorefactor.yaml, and gofmts them. Only the named
+files (or the staged ones) are read, not the whole workspace. With -check
+nothing is written; the files that would change are listed and the exit
+status is 1.
+
+Flags:
+`
+
+// runTidyImports implements the tidy-imports subcommand.
+func runTidyImports(ctx context.Context, stdout io.Writer, args []string) error {
+	fs := flag.NewFlagSet("tidy-imports", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), tidyImportsUsage)
+		fs.PrintDefaults()
+	}
+	workspace := fs.String("workspace", ".", "workspace root (go.mod directory)")
+	check := fs.Bool("check", false, "list the files that are not tidy instead of rewriting them")
+	staged := fs.Bool("staged", false, "tidy the Go files staged for commit")
+	withFormat := fs.Bool("format", false, "also run the formatter configured in .gorefactor.yaml")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	root, err := filepath.Abs(*workspace)
+	if err != nil {
+		return err
+	}
+	cfg, err := config.LoadWorkspace(root)
+	if err != nil {
+		return err
+	}
+	files := fs.Args()
+	if *staged {
+		changed, err := changedGoFiles(ctx, root, "--cached")
+		if err != nil {
+			return err
+		}
+		files = append(files, changed...)
+	}
+	if len(files) == 0 && !*staged {
+		fs.Usage()
+		return fmt.Errorf("no files: name Go files or pass -staged")
+	}
+
+	tidier, err := refactor.NewImportTidier(root, cfg.FormatStyle())
+	if err != nil {
+		return err
+	}
+	failed := false
+	for _, file := range excludeFiles(root, files, cfg.Exclude) {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		var out []byte
+		if *withFormat {
+			out, err = tidier.Format(filepath.Dir(file), src)
+		} else {
+			out, err = tidier.Tidy(src)
+		}
+		rel, _ := filepath.Rel(root, file)
+		switch {
+		case err != nil:
+			fmt.Fprintf(stdout, "%s: %v\n", rel, err)
+			failed = true
+		case bytes.Equal(src, out):
+		case *check:
+			fmt.Fprintf(stdout, "%s: not tidy\n", rel)
+			failed = true
+		default:
+			if err := os.WriteFile(file, out, 0o644); err != nil {
+				return err
+			}
+			fmt.Fprintf(stdout, "%s: tidied\n", rel)
+		}
+	}
+	if failed {
+		return errCheckFailed
+	}
+	return nil
+}
+
+// excludeFiles makes files absolute and drops those in excluded
+// directories of the workspace at root.
+func excludeFiles(root string, files, exclude []string) []string {
+	var out []string
+	for _, f := range files {
+		abs, err := filepath.Abs(f)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(root, abs)
+		if err != nil {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		if slices.ContainsFunc(exclude, func(dir string) bool {
+			dir = strings.TrimSuffix(filepath.ToSlash(dir), "/")
+			return strings.HasPrefix(rel, dir+"/")
+		}) {
+			continue
+		}
+		out = append(out, abs)
+	}
+	return out
+}
+
+// changedGoFiles returns the absolute paths of the Go files under root that
+// git diff reports with the given arguments, leaving out deleted files.
+func changedGoFiles(ctx context.Context, root string, diffArgs ...string) ([]string, error) {
+	args := append([]string{"diff", "--name-only", "-z", "--relative", "--diff-filter=ACMR"}, diffArgs...)
+	out, err := git(ctx, root, append(args, "--", "*.go")...)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for name := range strings.SplitSeq(out, "\x00") {
+		if name != "" {
+			files = append(files, filepath.Join(root, name))
+		}
+	}
+	return files, nil
+}
+
+// git runs git in dir and returns its trimmed output.
+func git(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
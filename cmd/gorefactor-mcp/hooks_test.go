@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// gitWorkspace returns a workspace committed to a new git repository.
+func gitWorkspace(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := writeWorkspace(t)
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "-A"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "init"},
+	} {
+		if _, err := git(context.Background(), dir, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestTidyImports(t *testing.T) {
+	dir := writeWorkspace(t)
+	path := filepath.Join(dir, "main.go")
+	src := "package main\n\nimport (\n\t\"example.com/calc/util\"\n\t\"fmt\"\n)\n\nfunc main() {\n\tfmt.Println(util.X)\n}\n"
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	err := runTidyImports(context.Background(), &out, []string{"-workspace", dir, "-check", path})
+	if !errors.Is(err, errCheckFailed) || !strings.Contains(out.String(), "main.go: not tidy") {
+		t.Fatalf("expected main.go to be reported, got %v:\n%s", err, out.String())
+	}
+	if got, _ := os.ReadFile(path); string(got) != src {
+		t.Errorf("-check modified main.go:\n%s", got)
+	}
+
+	if err := runTidyImports(context.Background(), io.Discard, []string{"-workspace", dir, path}); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := os.ReadFile(path)
+	if !strings.Contains(string(got), "\"fmt\"\n\n\t\"example.com/calc/util\"") {
+		t.Errorf("imports were not grouped:\n%s", got)
+	}
+	if err := runTidyImports(context.Background(), io.Discard, []string{"-workspace", dir, "-check", path}); err != nil {
+		t.Errorf("tidied file still fails the check: %v", err)
+	}
+}
+
+func TestInstallHooks(t *testing.T) {
+	dir := gitWorkspace(t)
+	if err := os.WriteFile(filepath.Join(dir, ".gorefactor.yaml"), []byte("hooks:\n  format: true\n  analyzers: [ifinit]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := runInstallHooks(context.Background(), io.Discard, []string{"-workspace", dir}); err != nil {
+		t.Fatal(err)
+	}
+	hook, err := os.ReadFile(filepath.Join(dir, ".git", "hooks", "pre-commit"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"tidy-imports -check -staged -format", "analyze -since HEAD -fail 'ifinit'"} {
+		if !strings.Contains(string(hook), want) {
+			t.Errorf("hook is missing %q:\n%s", want, hook)
+		}
+	}
+	// Reinstalling replaces our own hook, but not someone else's.
+	if err := runInstallHooks(context.Background(), io.Discard, []string{"-workspace", dir}); err != nil {
+		t.Errorf("reinstall: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".git", "hooks", "pre-commit"), []byte("#!/bin/sh\nmake lint\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := runInstallHooks(context.Background(), io.Discard, []string{"-workspace", dir}); err == nil {
+		t.Error("expected an error for a foreign hook")
+	}
+}
+
+func TestRunAnalyze_Since(t *testing.T) {
+	dir := gitWorkspace(t)
+	var out bytes.Buffer
+	args := []string{"-workspace", dir, "-format", "json", "-since", "HEAD", "-fail", "ifinit"}
+	if err := runAnalyze(context.Background(), &out, args); err != nil || out.Len() != 0 {
+		t.Fatalf("expected no output without changes, got %v:\n%s", err, out.String())
+	}
+
+	src := `package main
+
+func Add(a, b int) (int, error) { return a + b, nil }
+
+func main() {
+	if x, err := Add(1, 2); err != nil {
+		panic(err)
+	}
+	_ = x
+}
+`
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err := runAnalyze(context.Background(), &out, args)
+	if !errors.Is(err, errCheckFailed) || !strings.Contains(out.String(), "main.go") {
+		t.Errorf("expected an ifinit finding in main.go, got %v:\n%s", err, out.String())
+	}
+}
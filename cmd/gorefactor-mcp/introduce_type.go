@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const introduceTypeUsage = `usage: gorefactor-mcp introduce-type [flags] -package <pkg> -name <Type> -underlying <type> [-target <decl> ...]
+
+Declares a named type for a recurring primitive, such as type UserID string,
+and retypes the chosen parameters, results, fields and variables to it.
+Conversions are added where values cross between the new type and the
+underlying one. Declarations that look like candidates but weren't targeted
+are listed as suggestions.
+
+Targets are Func.param, Type.Method.param, Type.Field or Var, optionally
+prefixed with the package, e.g. internal/store:Store.Get.id.
+
+Example:
+  gorefactor-mcp introduce-type -package ./internal/user -name UserID -underlying string \
+    -target User.ID -target GetUser.id
+
+Flags:
+`
+
+// runIntroduceType implements the introduce-type subcommand on top of the
+// introduce_type tool.
+func runIntroduceType(ctx context.Context, stdout io.Writer, args []string) error {
+	fs := flag.NewFlagSet("introduce-type", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), introduceTypeUsage)
+		fs.PrintDefaults()
+	}
+	workspace := fs.String("workspace", ".", "workspace root (go.mod directory)")
+	format := fs.String("format", formatText, "output format: text or json (the MCP tool payload)")
+	preview := fs.Bool("preview", false, "print the planned changes instead of applying them")
+	pkg := fs.String("package", "", "package to declare the type in")
+	name := fs.String("name", "", "name of the new type")
+	underlying := fs.String("underlying", "", "type the new type is defined as")
+	var targets targetsFlag
+	fs.Var(&targets, "target", "declaration to retype (repeatable)")
+	logFlags := registerCommandFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != formatText && *format != formatJSON {
+		return fmt.Errorf("unknown format %q: want %s or %s", *format, formatText, formatJSON)
+	}
+	if *pkg == "" || *name == "" || *underlying == "" {
+		fs.Usage()
+		return fmt.Errorf("-package, -name and -underlying are required")
+	}
+
+	toolArgs := map[string]any{
+		"type_name":  *name,
+		"underlying": *underlying,
+		"package":    *pkg,
+	}
+	if len(targets) > 0 {
+		toolArgs["targets"] = []string(targets)
+	}
+	opts := runOptions{workspace: *workspace, format: *format, preview: *preview, log: *logFlags}
+	return invoke(ctx, stdout, opts, "introduce_type", toolArgs)
+}
+
+// targetsFlag collects the values of a repeated -target flag.
+type targetsFlag []string
+
+func (f *targetsFlag) String() string { return strings.Join(*f, ",") }
+
+func (f *targetsFlag) Set(s string) error {
+	*f = append(*f, s)
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunIntroduceType(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/users\n\ngo 1.21\n",
+		"main.go": `package main
+
+type User struct {
+	ID   string
+	Name string
+}
+
+func GetUser(id string) *User {
+	return &User{ID: id}
+}
+
+func main() {
+	key := "42"
+	println(GetUser(key).Name)
+}
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	args := []string{"-workspace", dir, "-package", ".", "-name", "UserID", "-underlying", "string", "-target", "User.ID", "-target", "GetUser.id"}
+	if err := runIntroduceType(context.Background(), io.Discard, args); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "main.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"type UserID string", "ID   UserID", "func GetUser(id UserID) *User", "GetUser(UserID(key))"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("main.go lacks %q:\n%s", want, data)
+		}
+	}
+}
+
+func TestRunIntroduceType_MissingFlags(t *testing.T) {
+	dir := writeWorkspace(t)
+	err := runIntroduceType(context.Background(), io.Discard, []string{"-workspace", dir, "-name", "Operand"})
+	if err == nil || !strings.Contains(err.Error(), "required") {
+		t.Errorf("got %v, want an error about required flags", err)
+	}
+}
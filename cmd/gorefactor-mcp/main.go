@@ -2,42 +2,166 @@ package main
 
 import (
 	"context"
-	"log"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"log/slog"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
 
 	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
 
+	"github.com/mamaar/gorefactor/internal/logging"
 	internalmcp "github.com/mamaar/gorefactor/internal/mcp"
+	"github.com/mamaar/gorefactor/internal/profiling"
+	"github.com/mamaar/gorefactor/internal/telemetry"
+	"github.com/mamaar/gorefactor/pkg/types"
 )
 
+// subcommands are the command-line modes besides serving MCP over stdio.
+var subcommands = map[string]func(ctx context.Context, stdout io.Writer, args []string) error{
+	"run":            runTool,
+	"analyze":        runAnalyze,
+	"tidy-imports":   runTidyImports,
+	"install-hooks":  runInstallHooks,
+	"selftest":       runSelftest,
+	"callgraph":      runCallgraph,
+	"check-arch":     runCheckArch,
+	"introduce-type": runIntroduceType,
+	"generate-mock":  runGenerateMock,
+	"update-mocks":   runUpdateMocks,
+	"organize-file":  runOrganizeFile,
+	"split-file":     runSplitFile,
+	"plan":           runPlan,
+	"apidiff":        runAPIDiff,
+	"serve":          runServe,
+	"validate":       runValidate,
+	"search":         runSearch,
+	"rename":         runRename,
+	"rewrite":        runRewrite,
+	"migrate":        runMigrate,
+	"stats":          runStats,
+	"history":        runHistory,
+	"rollback":       runRollback,
+	"suggest-home":   runSuggestHome,
+}
+
 func main() {
-	// Create simple file logger
-	logFile, err := os.OpenFile("/tmp/gorefactor.log",
-		os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if len(os.Args) > 1 && os.Args[1] == "schema" {
+		if err := printSchemas(os.Stdout, os.Args[2:]); err != nil {
+			fatal(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && subcommands[os.Args[1]] != nil {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		err := subcommands[os.Args[1]](ctx, os.Stdout, os.Args[2:])
+		stop()
+		switch {
+		case errors.Is(err, errToolFailed), errors.Is(err, errCheckFailed), errors.Is(err, flag.ErrHelp):
+			os.Exit(1)
+		case err != nil:
+			fatal(err)
+		}
+		return
+	}
+
+	var profileFlags profiling.Flags
+	profileFlags.Register(flag.CommandLine)
+	verifyInternal := flag.Bool("verify-internal", false, "check workspace data structures after loading and after each refactoring, failing with a bug report on corruption")
+	// stdout and stdin carry the protocol, so logs go to a file by default.
+	logFlags := logging.Flags{Level: slog.LevelDebug, File: filepath.Join(logging.StateDir(), "gorefactor.log")}
+	logFlags.Register(flag.CommandLine)
+	flag.Parse()
+
+	logger, closeLog, err := logFlags.New()
 	if err != nil {
-		log.Fatalf("Failed to open log file: %v", err)
+		fatal(err)
 	}
-	logger := slog.New(slog.NewTextHandler(logFile, &slog.HandlerOptions{
-		Level: slog.LevelDebug,
-	}))
 	logger.Info("MCP server starting", "version", "1.0.0")
 
+	stopProfile, err := profileFlags.Start(logger)
+	if err != nil {
+		fatal(err)
+	}
+	stopTracing, err := telemetry.SetupTracing(context.Background(), "1.0.0")
+	if err != nil {
+		fatal(err)
+	}
+
 	s := mcpsdk.NewServer(&mcpsdk.Implementation{
 		Name:    "gorefactor",
 		Version: "1.0.0",
 	}, nil)
 
 	state := internalmcp.NewMCPServer(logger)
+	state.SetVerifyInternal(*verifyInternal)
 
 	internalmcp.RegisterAllTools(s, state)
 
+	// SIGINT/SIGTERM end the session; in-flight plans still finish writing
+	// before the process exits so no plan is left half-applied.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	logger.Info("MCP server ready")
-	err = s.Run(context.Background(), &mcpsdk.StdioTransport{})
+	err = s.Run(ctx, &mcpsdk.StdioTransport{})
+	if ctx.Err() != nil {
+		logger.Info("received shutdown signal")
+		err = nil
+	}
 	logger.Info("MCP server shutting down")
-	state.Close()
-	_ = logFile.Close()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	if serr := state.Shutdown(shutdownCtx); serr != nil {
+		logger.Error("unclean shutdown", "err", serr)
+	} else {
+		logger.Info("MCP server stopped cleanly")
+	}
+	if terr := stopTracing(shutdownCtx); terr != nil {
+		logger.Error("failed to export traces", "err", terr)
+	}
+	cancel()
+	if perr := stopProfile(); perr != nil {
+		logger.Error("failed to write profile", "err", perr)
+	}
+	_ = closeLog()
 	if err != nil {
-		log.Fatal(err)
+		fatal(err)
+	}
+}
+
+// fatal prints err to stderr and exits with status 1.
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "gorefactor-mcp:", err)
+	os.Exit(1)
+}
+
+// printSchemas writes the JSON Schema of the named operations' requests, or of
+// every request and the plan when no names are given.
+func printSchemas(w io.Writer, names []string) error {
+	var out any
+	switch len(names) {
+	case 0:
+		schemas, err := types.Schemas()
+		if err != nil {
+			return err
+		}
+		out = schemas
+	case 1:
+		s, err := types.RequestSchema(names[0])
+		if err != nil {
+			return fmt.Errorf("%w (known: %v)", err, types.RequestNames())
+		}
+		out = s
+	default:
+		return fmt.Errorf("usage: gorefactor-mcp schema [operation]")
 	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
 }
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/mamaar/gorefactor/internal/telemetry"
+)
+
+func TestTelemetry_Spans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(prev)
+
+	dir := writeWorkspace(t)
+	if err := runTool(context.Background(), io.Discard, []string{"-workspace", dir, "rename_symbol", "symbol=Add", "new_name=Sum"}); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, s := range recorder.Ended() {
+		spans[s.Name()] = s
+	}
+	call := spans["tools/call rename_symbol"]
+	if call == nil {
+		t.Fatalf("no span of the rename_symbol call among %v", recorder.Ended())
+	}
+	for _, name := range []string{telemetry.SpanLoadWorkspace, telemetry.SpanBuildReferenceIndex, telemetry.SpanPlan, telemetry.SpanExecutePlan} {
+		if spans[name] == nil {
+			t.Errorf("no %s span", name)
+		}
+	}
+	for _, name := range []string{telemetry.SpanPlan, telemetry.SpanExecutePlan} {
+		if s := spans[name]; s != nil && s.Parent().SpanID() != call.SpanContext().SpanID() {
+			t.Errorf("%s span isn't a child of the tool call", name)
+		}
+	}
+	if s := spans[telemetry.SpanPlan]; s != nil && s.StartTime().Before(call.StartTime()) {
+		t.Errorf("Plan span starts before its call")
+	}
+}
+
+func TestTelemetry_Metrics(t *testing.T) {
+	dir := writeWorkspace(t)
+	api, err := newAPIServer(context.Background(), io.Discard, slog.New(slog.NewTextHandler(io.Discard, nil)), map[string]any{"path": dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer api.close()
+	srv := httptest.NewServer(api.handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/workspace/packages")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	resp, err = http.Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	for _, want := range []string{
+		`gorefactor_api_requests_total{api="http",code="200",method="GET /workspace/packages"}`,
+		`gorefactor_tool_calls_total{outcome="ok",tool="load_workspace"}`,
+		`gorefactor_workspace_load_duration_seconds_count{outcome="ok"}`,
+		`gorefactor_workspace_packages 1`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("metrics lack %s", want)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+const migrateUsage = `usage: gorefactor-mcp migrate [flags] pack
+
+Applies a migration pack, a YAML bundle of rewrite rules for an upgrade,
+as one plan. The built-in packs are ioutil (io/ioutil to os and io),
+pkg-errors (github.com/pkg/errors to fmt and errors) and x-net-context
+(golang.org/x/net/context to context); a .yaml or .yml argument is a pack
+file:
+
+  name: mylog
+  rules:
+    - name: Printf
+      pattern: '"example.com/mylog".Printf(:[args...])'
+      replacement: '"log".Printf(:[args...])'
+      note: mylog.Printf added a newline
+
+The output counts the expressions each rule rewrote; the notes of the
+rules that rewrote any are reported as issues to review.
+
+Example:
+  gorefactor-mcp migrate -preview pkg-errors
+
+Flags:
+`
+
+// runMigrate implements the migrate subcommand on top of the migrate tool.
+func runMigrate(ctx context.Context, stdout io.Writer, args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), migrateUsage)
+		fs.PrintDefaults()
+	}
+	workspace := fs.String("workspace", ".", "workspace root (go.mod directory)")
+	format := fs.String("format", formatText, "output format: text or json (the MCP tool payload)")
+	preview := fs.Bool("preview", false, "print the planned changes instead of applying them")
+	pkg := fs.String("package", "", "only migrate this package")
+	list := fs.Bool("list", false, "list the built-in packs and their rules")
+	logFlags := registerCommandFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != formatText && *format != formatJSON {
+		return fmt.Errorf("unknown format %q: want %s or %s", *format, formatText, formatJSON)
+	}
+	toolArgs := map[string]any{}
+	switch {
+	case *list && fs.NArg() == 0:
+	case !*list && fs.NArg() == 1:
+		toolArgs["pack"] = fs.Arg(0)
+		toolArgs["package"] = *pkg
+	default:
+		fs.Usage()
+		return fmt.Errorf("expected a pack, or -list")
+	}
+
+	opts := runOptions{workspace: *workspace, format: *format, preview: *preview, log: *logFlags}
+	return invoke(ctx, stdout, opts, "migrate", toolArgs)
+}
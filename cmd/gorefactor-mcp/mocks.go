@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+const generateMockUsage = `usage: gorefactor-mcp generate-mock [flags] -interface <Name>
+
+Writes a mock implementation of an interface to mock_<interface>.go in the
+target package. The func style has a function field per method and returns
+zero values for fields left nil; the moq style follows the layout of
+github.com/matryer/moq, recording calls and panicking on nil fields. The
+file records the interface it mocks; update-mocks regenerates it.
+
+Example:
+  gorefactor-mcp generate-mock -package ./internal/store -interface Store -target mocks -style moq
+
+Flags:
+`
+
+const updateMocksUsage = `usage: gorefactor-mcp update-mocks [flags]
+
+Regenerates the mocks written by generate-mock from the current declarations
+of their interfaces. Mocks whose interface no longer exists are left alone
+and reported as warnings.
+
+Flags:
+`
+
+// runGenerateMock implements the generate-mock subcommand on top of the
+// generate_mock tool.
+func runGenerateMock(ctx context.Context, stdout io.Writer, args []string) error {
+	fs := flag.NewFlagSet("generate-mock", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), generateMockUsage)
+		fs.PrintDefaults()
+	}
+	workspace := fs.String("workspace", ".", "workspace root (go.mod directory)")
+	format := fs.String("format", formatText, "output format: text or json (the MCP tool payload)")
+	preview := fs.Bool("preview", false, "print the planned changes instead of applying them")
+	iface := fs.String("interface", "", "name of the interface to mock")
+	pkg := fs.String("package", "", "package declaring the interface (default: search the workspace)")
+	target := fs.String("target", "", "package to write the mock to (default: the interface's)")
+	name := fs.String("name", "", "name of the mock type (default: <Interface>Mock)")
+	style := fs.String("style", "", "mock style: func or moq (default func)")
+	logFlags := registerCommandFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != formatText && *format != formatJSON {
+		return fmt.Errorf("unknown format %q: want %s or %s", *format, formatText, formatJSON)
+	}
+	if *iface == "" {
+		fs.Usage()
+		return fmt.Errorf("-interface is required")
+	}
+
+	toolArgs := map[string]any{"interface_name": *iface}
+	for key, value := range map[string]string{"package": *pkg, "target_package": *target, "mock_name": *name, "style": *style} {
+		if value != "" {
+			toolArgs[key] = value
+		}
+	}
+	opts := runOptions{workspace: *workspace, format: *format, preview: *preview, log: *logFlags}
+	return invoke(ctx, stdout, opts, "generate_mock", toolArgs)
+}
+
+// runUpdateMocks implements the update-mocks subcommand on top of the
+// update_mocks tool.
+func runUpdateMocks(ctx context.Context, stdout io.Writer, args []string) error {
+	fs := flag.NewFlagSet("update-mocks", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), updateMocksUsage)
+		fs.PrintDefaults()
+	}
+	workspace := fs.String("workspace", ".", "workspace root (go.mod directory)")
+	format := fs.String("format", formatText, "output format: text or json (the MCP tool payload)")
+	preview := fs.Bool("preview", false, "print the planned changes instead of applying them")
+	pkg := fs.String("package", "", "only update the mocks in this package")
+	logFlags := registerCommandFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != formatText && *format != formatJSON {
+		return fmt.Errorf("unknown format %q: want %s or %s", *format, formatText, formatJSON)
+	}
+
+	toolArgs := map[string]any{}
+	if *pkg != "" {
+		toolArgs["package"] = *pkg
+	}
+	opts := runOptions{workspace: *workspace, format: *format, preview: *preview, log: *logFlags}
+	return invoke(ctx, stdout, opts, "update_mocks", toolArgs)
+}
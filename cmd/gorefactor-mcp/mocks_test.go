@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunGenerateAndUpdateMocks(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":         "module example.com/shop\n\ngo 1.21\n",
+		"store/store.go": "package store\n\ntype Store interface {\n\tGet(id string) (string, error)\n}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	args := []string{"-workspace", dir, "-interface", "Store", "-target", "mocks"}
+	if err := runGenerateMock(context.Background(), io.Discard, args); err != nil {
+		t.Fatal(err)
+	}
+	mock := filepath.Join(dir, "store", "mocks", "mock_store.go")
+	data, err := os.ReadFile(mock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "GetFunc func(id string) (string, error)") {
+		t.Errorf("unexpected mock:\n%s", data)
+	}
+
+	source := "package store\n\ntype Store interface {\n\tGet(id string) (string, error)\n\tList() []string\n}\n"
+	if err := os.WriteFile(filepath.Join(dir, "store", "store.go"), []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := runUpdateMocks(context.Background(), io.Discard, []string{"-workspace", dir}); err != nil {
+		t.Fatal(err)
+	}
+	data, err = os.ReadFile(mock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "ListFunc func() []string") {
+		t.Errorf("mock was not updated:\n%s", data)
+	}
+}
+
+func TestRunGenerateMock_MissingInterface(t *testing.T) {
+	dir := writeWorkspace(t)
+	err := runGenerateMock(context.Background(), io.Discard, []string{"-workspace", dir})
+	if err == nil || !strings.Contains(err.Error(), "required") {
+		t.Errorf("got %v, want an error about the required flag", err)
+	}
+}
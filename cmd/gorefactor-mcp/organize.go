@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+const organizeFileUsage = `usage: gorefactor-mcp organize-file [flags] <file>
+
+Reorders the top-level declarations of a file. The kind policy puts
+constants, variables and init functions first, then each type with its
+constructors, exported and unexported methods, then exported and
+unexported functions. The call_order policy puts each function after the
+first function calling it. Declarations keep their comments, and variables
+and init functions keep their relative order.
+
+With -split, a file longer than -max-lines lines has each type with
+methods moved into a file named after the type.
+
+Example:
+  gorefactor-mcp organize-file -policy call_order -preview internal/store/store.go
+
+Flags:
+`
+
+// runOrganizeFile implements the organize-file subcommand on top of the
+// organize_file tool.
+func runOrganizeFile(ctx context.Context, stdout io.Writer, args []string) error {
+	fs := flag.NewFlagSet("organize-file", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), organizeFileUsage)
+		fs.PrintDefaults()
+	}
+	workspace := fs.String("workspace", ".", "workspace root (go.mod directory)")
+	format := fs.String("format", formatText, "output format: text or json (the MCP tool payload)")
+	preview := fs.Bool("preview", false, "print the planned changes instead of applying them")
+	policy := fs.String("policy", "", "declaration order: kind or call_order (default kind)")
+	split := fs.Bool("split", false, "move each type with methods of an oversized file to a file of its own")
+	maxLines := fs.Int("max-lines", 0, "lines above which -split splits the file (default 500)")
+	logFlags := registerCommandFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != formatText && *format != formatJSON {
+		return fmt.Errorf("unknown format %q: want %s or %s", *format, formatText, formatJSON)
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected exactly one file")
+	}
+
+	toolArgs := map[string]any{"file": fs.Arg(0)}
+	if *policy != "" {
+		toolArgs["policy"] = *policy
+	}
+	if *split {
+		toolArgs["split_by_receiver"] = true
+	}
+	if *maxLines > 0 {
+		toolArgs["max_lines"] = *maxLines
+	}
+	opts := runOptions{workspace: *workspace, format: *format, preview: *preview, log: *logFlags}
+	return invoke(ctx, stdout, opts, "organize_file", toolArgs)
+}
+
+const splitFileUsage = `usage: gorefactor-mcp split-file [flags] <file>
+
+Moves groups of the declarations of a file longer than -max-lines lines
+into new files of the same package. The receiver strategy moves each type
+with methods to <type>.go, the prefix strategy the declarations whose
+names start with the same word to <word>.go, and the dependency strategy
+each cluster of declarations referring to one another, but the largest, to
+a file named after its main type or function. Declarations keep their
+comments; variables and init functions stay. The split package is
+type-checked before anything is written.
+
+Example:
+  gorefactor-mcp split-file -strategy prefix -preview internal/store/store.go
+
+Flags:
+`
+
+// runSplitFile implements the split-file subcommand on top of the
+// split_file tool.
+func runSplitFile(ctx context.Context, stdout io.Writer, args []string) error {
+	fs := flag.NewFlagSet("split-file", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), splitFileUsage)
+		fs.PrintDefaults()
+	}
+	workspace := fs.String("workspace", ".", "workspace root (go.mod directory)")
+	format := fs.String("format", formatText, "output format: text or json (the MCP tool payload)")
+	preview := fs.Bool("preview", false, "print the planned changes instead of applying them")
+	strategy := fs.String("strategy", "", "grouping: receiver, prefix or dependency (default receiver)")
+	maxLines := fs.Int("max-lines", 0, "lines the file must exceed to be split (default 500)")
+	logFlags := registerCommandFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != formatText && *format != formatJSON {
+		return fmt.Errorf("unknown format %q: want %s or %s", *format, formatText, formatJSON)
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected exactly one file")
+	}
+
+	toolArgs := map[string]any{"file": fs.Arg(0)}
+	if *strategy != "" {
+		toolArgs["strategy"] = *strategy
+	}
+	if *maxLines > 0 {
+		toolArgs["max_lines"] = *maxLines
+	}
+	opts := runOptions{workspace: *workspace, format: *format, preview: *preview, log: *logFlags}
+	return invoke(ctx, stdout, opts, "split_file", toolArgs)
+}
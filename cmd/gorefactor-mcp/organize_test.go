@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunOrganizeFile(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":       "module example.com/shop\n\ngo 1.21\n",
+		"shop/shop.go": "package shop\n\nfunc helper() int { return 1 }\n\nconst limit = 2\n\n// Run runs.\nfunc Run() int { return helper() + limit }\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := runOrganizeFile(context.Background(), io.Discard, []string{"-workspace", dir, "shop/shop.go"}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "shop", "shop.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "package shop\n\nconst limit = 2\n\n// Run runs.\nfunc Run() int { return helper() + limit }\n\nfunc helper() int { return 1 }\n"
+	if string(data) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", data, want)
+	}
+}
+
+func TestRunOrganizeFile_MissingFile(t *testing.T) {
+	dir := writeWorkspace(t)
+	err := runOrganizeFile(context.Background(), io.Discard, []string{"-workspace", dir})
+	if err == nil || !strings.Contains(err.Error(), "exactly one file") {
+		t.Errorf("got %v, want an error about the missing file", err)
+	}
+}
+
+func TestRunSplitFile(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":       "module example.com/shop\n\ngo 1.21\n",
+		"shop/shop.go": "package shop\n\nfunc Run() int { return 1 }\n\nfunc parseA() int { return parseB() }\n\nfunc parseB() int { return 2 }\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := runSplitFile(context.Background(), io.Discard, []string{"-workspace", dir, "-strategy", "prefix", "-max-lines", "3", "shop/shop.go"}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "shop", "parse.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "package shop\n\nfunc parseA() int { return parseB() }\n\nfunc parseB() int { return 2 }\n"
+	if string(data) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", data, want)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	internalmcp "github.com/mamaar/gorefactor/internal/mcp"
+	"github.com/mamaar/gorefactor/pkg/refactor"
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+const planUsage = `usage: gorefactor-mcp plan [flags] operation [name=value ...]
+       gorefactor-mcp plan [flags] -file batch.yaml
+
+Plans a refactoring, given as an operation of a batch file step and its
+request fields, or a whole batch file, and lists its changes by file
+without applying them. Values are parsed as JSON when they are valid JSON
+and taken as strings otherwise:
+
+  gorefactor-mcp plan -interactive rename_symbol symbol_name=Add new_name=Sum
+
+With -interactive the changes can be toggled one by one or a file at a
+time, and their diffs shown, before the accepted ones are validated again
+on their own and applied. A batch file plans one change per file.
+
+Changes found without type information are marked likely, and those matched
+by name alone heuristic; -min-confidence, or engine.min_confidence in
+.gorefactor.yaml, leaves out the changes below a level.
+
+-record writes the plan, its inputs and every change with its offsets,
+confidence and diff, to a golden snapshot file instead of listing it;
+-verify plans again and fails when the plan differs from the snapshot, to
+catch changes in behavior across gorefactor upgrades:
+
+  gorefactor-mcp plan -record testdata/rename.snap rename_symbol symbol_name=Add new_name=Sum
+  gorefactor-mcp plan -verify testdata/rename.snap rename_symbol symbol_name=Add new_name=Sum
+
+Flags:
+`
+
+// runPlan implements the plan subcommand. It loads the workspace through
+// the load_workspace tool, so the project config applies, and then plans
+// and applies with the server's engine directly.
+func runPlan(ctx context.Context, stdout io.Writer, args []string) error {
+	fs := flag.NewFlagSet("plan", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), planUsage)
+		fs.PrintDefaults()
+	}
+	workspace := fs.String("workspace", ".", "workspace root (go.mod directory)")
+	interactive := fs.Bool("interactive", false, "review the changes and apply the accepted ones")
+	file := fs.String("file", "", "plan this batch file instead of one operation")
+	minConfidence := fs.String("min-confidence", "", "leave out planned changes less sure than this: certain, likely or heuristic")
+	record := fs.String("record", "", "write the plan to this golden snapshot file")
+	verify := fs.String("verify", "", "fail unless the plan matches this golden snapshot file")
+	logFlags := registerCommandFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if (*file == "") == (fs.NArg() == 0) {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	if *record != "" && *verify != "" || *interactive && (*record != "" || *verify != "") {
+		return fmt.Errorf("-record, -verify and -interactive can't be combined")
+	}
+	var request map[string]any
+	if fs.NArg() > 0 {
+		var err error
+		if request, err = parseToolArgs(fs.Args()[1:]); err != nil {
+			return err
+		}
+	}
+
+	logger, closeLog, err := logFlags.New()
+	if err != nil {
+		return err
+	}
+	defer closeLog()
+	state := internalmcp.NewMCPServer(logger)
+	defer state.Close()
+	session, err := connectInMemory(ctx, state)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	load := map[string]any{"path": *workspace}
+	if *minConfidence != "" {
+		load["min_confidence"] = *minConfidence
+	}
+	if err := callTool(ctx, session, stdout, formatText, "load_workspace", load, true); err != nil {
+		return err
+	}
+	ws, err := state.GetWorkspace()
+	if err != nil {
+		return err
+	}
+	engine := state.GetEngine()
+
+	var plan *types.RefactoringPlan
+	if *file != "" {
+		plan, err = engine.BatchOperations(ws, types.BatchOperationRequest{File: *file})
+	} else {
+		plan, err = engine.PlanStep(ws, types.BatchStep{Name: fs.Arg(0), Operation: fs.Arg(0), Request: request})
+	}
+	if err != nil {
+		return err
+	}
+	plan, skipped := refactor.SelectConfidence(plan, engine.Config().MinConfidence)
+	if *record != "" || *verify != "" {
+		inputs := "-file " + *file
+		if *file == "" {
+			inputs = strings.Join(append([]string{fs.Arg(0)}, slices.Sorted(slices.Values(fs.Args()[1:]))...), " ")
+		}
+		if *record != "" {
+			if err := recordSnapshot(*record, inputs, plan, len(skipped), ws.RootPath); err != nil {
+				return err
+			}
+			fmt.Fprintf(stdout, "Recorded %d changes to %s.\n", len(plan.Changes), *record)
+			return nil
+		}
+		if err := verifySnapshot(*verify, inputs, plan, len(skipped), ws.RootPath); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "The plan matches %s.\n", *verify)
+		return nil
+	}
+	if len(skipped) > 0 {
+		fmt.Fprintf(stdout, "Left out %d changes below %s confidence.\n", len(skipped), engine.Config().MinConfidence)
+	}
+	if len(plan.Changes) == 0 {
+		fmt.Fprintln(stdout, "The plan changes nothing.")
+		return nil
+	}
+
+	review := newPlanReview(plan, ws.RootPath)
+	if !*interactive {
+		review.list(stdout)
+		return nil
+	}
+	_, err = review.run(os.Stdin, stdout, engine.ValidateRefactoring, func(plan *types.RefactoringPlan) error {
+		return engine.ExecutePlanContext(ctx, plan)
+	})
+	return err
+}
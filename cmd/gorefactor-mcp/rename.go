@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const renameUsage = `usage: gorefactor-mcp rename [flags] [-map file] [old=new ...]
+
+Renames many symbols and methods as one plan with bulk_rename. The renames
+come from a mapping file, from old=new arguments, or both. Old names are
+Name or Type.Method, optionally prefixed with the package path and a
+colon; unqualified names are renamed in every package declaring them.
+
+A .json mapping file holds an array of {"old", "new", "package"} objects
+or an object of old to new names; any other file is CSV with
+old,new[,package] records, an optional header and # comments:
+
+  old,new,package
+  GetUserId,GetUserID,
+  Client.DoRequest,Do,internal/http
+
+Every rename is checked before anything is written. Missing symbols, names
+already taken and renames colliding with each other are all reported, and
+nothing is applied while there is one.
+
+Example:
+  gorefactor-mcp rename -map renames.csv -preview
+
+Flags:
+`
+
+// runRename implements the rename subcommand on top of the bulk_rename
+// tool.
+func runRename(ctx context.Context, stdout io.Writer, args []string) error {
+	fs := flag.NewFlagSet("rename", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), renameUsage)
+		fs.PrintDefaults()
+	}
+	workspace := fs.String("workspace", ".", "workspace root (go.mod directory)")
+	format := fs.String("format", formatText, "output format: text or json (the MCP tool payload)")
+	preview := fs.Bool("preview", false, "print the planned changes instead of applying them")
+	mapFile := fs.String("map", "", "CSV or JSON file mapping old names to new ones")
+	logFlags := registerCommandFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != formatText && *format != formatJSON {
+		return fmt.Errorf("unknown format %q: want %s or %s", *format, formatText, formatJSON)
+	}
+	if *mapFile == "" && fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("expected -map or old=new arguments")
+	}
+
+	toolArgs := map[string]any{}
+	if *mapFile != "" {
+		path, err := filepath.Abs(*mapFile)
+		if err != nil {
+			return err
+		}
+		toolArgs["file"] = path
+	}
+	var renames []map[string]string
+	for _, arg := range fs.Args() {
+		oldName, newName, ok := strings.Cut(arg, "=")
+		if !ok {
+			return fmt.Errorf("argument %q: want old=new", arg)
+		}
+		renames = append(renames, map[string]string{"old": oldName, "new": newName})
+	}
+	if len(renames) > 0 {
+		toolArgs["renames"] = renames
+	}
+	opts := runOptions{workspace: *workspace, format: *format, preview: *preview, log: *logFlags}
+	return invoke(ctx, stdout, opts, "bulk_rename", toolArgs)
+}
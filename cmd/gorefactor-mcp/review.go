@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mamaar/gorefactor/pkg/refactor"
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+const reviewHelp = `Commands:
+  1 2.3 ...   toggle file 1 and change 3 of file 2
+  d 1 | d 2.3 show the diff of file 1 or of change 3 of file 2
+  a | n       accept all changes | reject all changes
+  l           list the changes again
+  y           validate and apply the accepted changes
+  q           quit without applying anything
+`
+
+// planReview is an interactive review of a plan's changes, grouped by file,
+// that ends by applying the accepted ones.
+type planReview struct {
+	plan    *types.RefactoringPlan
+	root    string
+	files   []string // Changed files, sorted
+	changes [][]int  // Indexes into plan.Changes, by file and position
+	keep    []bool   // Whether each change of plan.Changes is accepted
+	sources map[string][]byte
+}
+
+// newPlanReview starts a review of plan, with every change accepted. File
+// names are shown relative to root.
+func newPlanReview(plan *types.RefactoringPlan, root string) *planReview {
+	r := &planReview{plan: plan, root: root, keep: make([]bool, len(plan.Changes)), sources: make(map[string][]byte)}
+	byFile := make(map[string][]int)
+	for i, c := range plan.Changes {
+		byFile[c.File] = append(byFile[c.File], i)
+		r.keep[i] = true
+	}
+	r.files = slices.Sorted(maps.Keys(byFile))
+	for _, f := range r.files {
+		idx := byFile[f]
+		slices.SortStableFunc(idx, func(a, b int) int { return plan.Changes[a].Start - plan.Changes[b].Start })
+		r.changes = append(r.changes, idx)
+	}
+	return r
+}
+
+// run reads commands from in, writing to out, until the accepted changes
+// are applied or the review is quit. The accepted changes are validated
+// before apply is called; a plan that doesn't validate is reported and the
+// review goes on. It reports whether anything was applied.
+func (r *planReview) run(in io.Reader, out io.Writer, validate, apply func(*types.RefactoringPlan) error) (bool, error) {
+	r.list(out)
+	fmt.Fprint(out, "\n"+reviewHelp)
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(out, "> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(out, "\nNothing applied.")
+			return false, scanner.Err()
+		}
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "q":
+			fmt.Fprintln(out, "Nothing applied.")
+			return false, nil
+		case "?", "h", "help":
+			fmt.Fprint(out, reviewHelp)
+		case "l":
+			r.list(out)
+		case "a", "n":
+			for i := range r.keep {
+				r.keep[i] = fields[0] == "a"
+			}
+			r.list(out)
+		case "d":
+			for _, ref := range fields[1:] {
+				file, change, ok := r.parseRef(ref)
+				if !ok {
+					fmt.Fprintf(out, "no file or change %s\n", ref)
+					continue
+				}
+				for _, i := range r.refChanges(file, change) {
+					r.diff(out, r.plan.Changes[i])
+				}
+			}
+		case "y":
+			plan := r.selected()
+			if len(plan.Changes) == 0 {
+				fmt.Fprintln(out, "No change is accepted.")
+				continue
+			}
+			if err := validate(plan); err != nil {
+				fmt.Fprintf(out, "The accepted changes don't validate: %s\n", describeValidation(err))
+				continue
+			}
+			if err := apply(plan); err != nil {
+				return false, err
+			}
+			fmt.Fprintf(out, "Applied %d of %d changes to %d files.\n", len(plan.Changes), len(r.plan.Changes), len(plan.AffectedFiles))
+			return true, nil
+		default:
+			toggled := false
+			for _, ref := range fields {
+				file, change, ok := r.parseRef(ref)
+				if !ok {
+					fmt.Fprintf(out, "unknown command %q; ? lists the commands\n", ref)
+					continue
+				}
+				r.toggle(file, change)
+				toggled = true
+			}
+			if toggled {
+				r.list(out)
+			}
+		}
+	}
+}
+
+// list writes the files of the plan and their changes, each marked [x] when
+// accepted and followed by its confidence unless it is certain; a file with
+// only some changes accepted is marked [~].
+func (r *planReview) list(w io.Writer) {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d changes in %d files:\n", len(r.plan.Changes), len(r.files))
+	for f, file := range r.files {
+		accepted := 0
+		for _, i := range r.changes[f] {
+			if r.keep[i] {
+				accepted++
+			}
+		}
+		mark := "[~]"
+		switch accepted {
+		case 0:
+			mark = "[ ]"
+		case len(r.changes[f]):
+			mark = "[x]"
+		}
+		fmt.Fprintf(&sb, "%s %d %s (%d of %d changes)\n", mark, f+1, r.rel(file), accepted, len(r.changes[f]))
+		for n, i := range r.changes[f] {
+			c := r.plan.Changes[i]
+			mark = "[ ]"
+			if r.keep[i] {
+				mark = "[x]"
+			}
+			desc := c.Description
+			if c.Confidence != "" && c.Confidence != types.ConfidenceCertain {
+				desc += " (" + string(c.Confidence) + ")"
+			}
+			fmt.Fprintf(&sb, "    %s %d.%d line %d: %s\n", mark, f+1, n+1, r.line(c), desc)
+		}
+	}
+	_, _ = io.WriteString(w, sb.String())
+}
+
+// parseRef parses a file number, "2", or a change number, "2.3", into
+// 0-based indexes; change is -1 for a whole file.
+func (r *planReview) parseRef(ref string) (file, change int, ok bool) {
+	fileStr, changeStr, hasChange := strings.Cut(ref, ".")
+	file, err := strconv.Atoi(fileStr)
+	if err != nil || file < 1 || file > len(r.files) {
+		return 0, 0, false
+	}
+	if !hasChange {
+		return file - 1, -1, true
+	}
+	change, err = strconv.Atoi(changeStr)
+	if err != nil || change < 1 || change > len(r.changes[file-1]) {
+		return 0, 0, false
+	}
+	return file - 1, change - 1, true
+}
+
+// refChanges returns the changes a parsed reference stands for.
+func (r *planReview) refChanges(file, change int) []int {
+	if change < 0 {
+		return r.changes[file]
+	}
+	return r.changes[file][change : change+1]
+}
+
+// toggle flips a change, or a whole file: on when any of its changes is
+// off, off otherwise.
+func (r *planReview) toggle(file, change int) {
+	idx := r.refChanges(file, change)
+	on := slices.ContainsFunc(idx, func(i int) bool { return !r.keep[i] })
+	for _, i := range idx {
+		r.keep[i] = on
+	}
+}
+
+// selected returns the plan narrowed to the accepted changes.
+func (r *planReview) selected() *types.RefactoringPlan {
+	return refactor.SelectChanges(r.plan, func(i int) bool { return r.keep[i] })
+}
+
+// source returns the current content of file, empty for a new file.
+func (r *planReview) source(file string) []byte {
+	if src, ok := r.sources[file]; ok {
+		return src
+	}
+	src, _ := os.ReadFile(file)
+	r.sources[file] = src
+	return src
+}
+
+// line returns the 1-based line a change starts at.
+func (r *planReview) line(c types.Change) int {
+	src := r.source(c.File)
+	return 1 + bytes.Count(src[:min(max(c.Start, 0), len(src))], []byte("\n"))
+}
+
+// diff writes the lines a change rewrites, without the lines it leaves
+// unchanged around the edit.
+func (r *planReview) diff(w io.Writer, c types.Change) {
+	src := r.source(c.File)
+	var before, after string
+	first := 1
+	if c.Start >= 0 && c.Start <= c.End && c.End <= len(src) {
+		lineStart := bytes.LastIndexByte(src[:c.Start], '\n') + 1
+		lineEnd := len(src)
+		if n := bytes.IndexByte(src[c.End:], '\n'); n >= 0 {
+			lineEnd = c.End + n
+		}
+		before = string(src[lineStart:lineEnd])
+		after = string(src[lineStart:c.Start]) + c.NewText + string(src[c.End:lineEnd])
+		first = r.line(types.Change{File: c.File, Start: lineStart})
+	} else {
+		before, after = c.OldText, c.NewText
+	}
+
+	oldLines, newLines := splitLines(before), splitLines(after)
+	for len(oldLines) > 0 && len(newLines) > 0 && oldLines[0] == newLines[0] {
+		oldLines, newLines = oldLines[1:], newLines[1:]
+		first++
+	}
+	for len(oldLines) > 0 && len(newLines) > 0 && oldLines[len(oldLines)-1] == newLines[len(newLines)-1] {
+		oldLines, newLines = oldLines[:len(oldLines)-1], newLines[:len(newLines)-1]
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s:%d %s\n", r.rel(c.File), first, c.Description)
+	for _, l := range oldLines {
+		sb.WriteString("-" + l + "\n")
+	}
+	for _, l := range newLines {
+		sb.WriteString("+" + l + "\n")
+	}
+	_, _ = io.WriteString(w, sb.String())
+}
+
+// rel returns file relative to the workspace root when it lies below it.
+func (r *planReview) rel(file string) string {
+	if rel, err := filepath.Rel(r.root, file); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return file
+}
+
+// splitLines splits s into lines; an empty s has none.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// describeValidation returns err with the issues of a validation error.
+func describeValidation(err error) string {
+	var verr *types.ValidationError
+	if !errors.As(err, &verr) {
+		return err.Error()
+	}
+	descs := make([]string, len(verr.Issues))
+	for i, issue := range verr.Issues {
+		descs[i] = issue.Description
+	}
+	return err.Error() + ": " + strings.Join(descs, "; ")
+}
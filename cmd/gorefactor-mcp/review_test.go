@@ -0,0 +1,75 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+func TestPlanReview(t *testing.T) {
+	dir := t.TempDir()
+	src := "package a\n\nfunc A() {}\n\nfunc B() { A() }\n"
+	file := filepath.Join(dir, "a.go")
+	if err := os.WriteFile(file, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	at := strings.Index(src, "A() }")
+	plan := &types.RefactoringPlan{
+		Changes: []types.Change{
+			{File: file, Start: at, End: at + 1, OldText: "A", NewText: "C", Description: "Rename reference", Confidence: types.ConfidenceLikely},
+			{File: file, Start: 16, End: 17, OldText: "A", NewText: "C", Description: "Rename definition"},
+			{File: filepath.Join(dir, "b.go"), NewText: "package a\n", Description: "Create b.go"},
+		},
+		AffectedFiles: []string{file, filepath.Join(dir, "b.go")},
+	}
+
+	var out strings.Builder
+	var validated []*types.RefactoringPlan
+	validate := func(p *types.RefactoringPlan) error {
+		validated = append(validated, p)
+		if len(validated) == 1 {
+			return &types.ValidationError{Issues: []types.Issue{{Description: "B calls an undefined A"}}}
+		}
+		return nil
+	}
+	var applied *types.RefactoringPlan
+	apply := func(p *types.RefactoringPlan) error {
+		applied = p
+		return nil
+	}
+	in := strings.NewReader("1.1\ny\nd 1.1\n2\n9\ny\n")
+	ok, err := (newPlanReview(plan, dir)).run(in, &out, validate, apply)
+	if err != nil || !ok {
+		t.Fatalf("run = %v, %v\n%s", ok, err, out.String())
+	}
+	for _, want := range []string{
+		"[x] 1 a.go (2 of 2 changes)\n    [x] 1.1 line 3: Rename definition\n    [x] 1.2 line 5: Rename reference (likely)\n",
+		"[~] 1 a.go (1 of 2 changes)\n",
+		"The accepted changes don't validate: validation failed with 1 issues: B calls an undefined A\n",
+		"--- a.go:3 Rename definition\n-func A() {}\n+func C() {}\n",
+		"[ ] 2 b.go (0 of 1 changes)\n",
+		"unknown command \"9\"",
+		"Applied 1 of 3 changes to 1 files.\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output lacks %q:\n%s", want, out.String())
+		}
+	}
+	if len(applied.Changes) != 1 || applied.Changes[0].Description != "Rename reference" {
+		t.Errorf("applied %+v", applied.Changes)
+	}
+	if len(applied.AffectedFiles) != 1 || applied.AffectedFiles[0] != file {
+		t.Errorf("affected files = %v", applied.AffectedFiles)
+	}
+
+	out.Reset()
+	if ok, err := newPlanReview(plan, dir).run(strings.NewReader("n\ny\nq\n"), &out, validate, apply); ok || err != nil {
+		t.Errorf("run = %v, %v", ok, err)
+	}
+	if !strings.Contains(out.String(), "No change is accepted.\n") {
+		t.Errorf("output:\n%s", out.String())
+	}
+}
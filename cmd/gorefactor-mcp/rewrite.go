@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const rewriteUsage = `usage: gorefactor-mcp rewrite [flags] pattern replacement
+
+Replaces every expression matching pattern with replacement, like gofmt -r
+with comby-style holes. :[name] matches any expression, :[name...] any
+number of list elements such as call arguments and :[name~regexp] an
+expression whose source matches regexp; :[_] matches without binding. A
+selector on a quoted import path names a package by its path, so that
+"github.com/pkg/errors".Wrap only matches that package's Wrap. Imports the
+replacements need are added and unused ones removed.
+
+Example:
+  gorefactor-mcp rewrite -where err=error -preview \
+    '"github.com/pkg/errors".Wrap(:[err], :[msg])' \
+    'fmt.Errorf("%s: %w", :[msg], :[err])'
+
+Flags:
+`
+
+// runRewrite implements the rewrite subcommand on top of the rewrite tool.
+func runRewrite(ctx context.Context, stdout io.Writer, args []string) error {
+	fs := flag.NewFlagSet("rewrite", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		io.WriteString(fs.Output(), rewriteUsage)
+		fs.PrintDefaults()
+	}
+	workspace := fs.String("workspace", ".", "workspace root (go.mod directory)")
+	format := fs.String("format", formatText, "output format: text or json (the MCP tool payload)")
+	preview := fs.Bool("preview", false, "print the planned changes instead of applying them")
+	pkg := fs.String("package", "", "only rewrite this package")
+	where := whereFlag{}
+	fs.Var(where, "where", "hole=type: the hole's expressions must be assignable to type (repeatable)")
+	logFlags := registerCommandFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != formatText && *format != formatJSON {
+		return fmt.Errorf("unknown format %q: want %s or %s", *format, formatText, formatJSON)
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return fmt.Errorf("expected a pattern and a replacement")
+	}
+
+	toolArgs := map[string]any{
+		"pattern":     fs.Arg(0),
+		"replacement": fs.Arg(1),
+		"package":     *pkg,
+	}
+	if len(where) > 0 {
+		toolArgs["where"] = map[string]string(where)
+	}
+	opts := runOptions{workspace: *workspace, format: *format, preview: *preview, log: *logFlags}
+	return invoke(ctx, stdout, opts, "rewrite", toolArgs)
+}
+
+// whereFlag collects the hole=type values of a repeated -where flag.
+type whereFlag map[string]string
+
+func (f whereFlag) String() string {
+	var parts []string
+	for hole, typ := range f {
+		parts = append(parts, hole+"="+typ)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (f whereFlag) Set(s string) error {
+	hole, typ, ok := strings.Cut(s, "=")
+	if !ok || hole == "" || typ == "" {
+		return fmt.Errorf("want hole=type, got %q", s)
+	}
+	f[hole] = typ
+	return nil
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
+	"gopkg.in/yaml.v3"
+
+	"github.com/mamaar/gorefactor/internal/logging"
+	internalmcp "github.com/mamaar/gorefactor/internal/mcp"
+	"github.com/mamaar/gorefactor/internal/profiling"
+	"github.com/mamaar/gorefactor/pkg/refactor"
+)
+
+// Output formats of the run subcommand.
+const (
+	formatText = "text"
+	formatJSON = "json"
+)
+
+const runUsage = `usage: gorefactor-mcp run [flags] <tool> [name=value ...]
+
+Runs one MCP tool against a workspace without an MCP client and prints its
+result. Values that parse as JSON (numbers, booleans, arrays, objects) are
+passed as such; anything else is a string.
+
+Examples:
+  gorefactor-mcp run -format json complexity package=./pkg/refactor min_complexity=20
+  gorefactor-mcp run -preview rename_symbol symbol=Add new_name=Sum
+  gorefactor-mcp run -keep-alias rename_symbol symbol=Add new_name=Sum package=./calc
+  gorefactor-mcp run -verify-tests=all rename_symbol symbol=Add new_name=Sum
+  gorefactor-mcp run -git-branch refactor/sum rename_symbol symbol=Add new_name=Sum
+
+-git-branch applies the refactoring on a new branch, in a temporary
+worktree, and commits it there, leaving the checkout untouched; -worktree
+does the same but keeps the worktree for review and only commits with
+-git-commit. -git-commit alone commits the refactoring's files in the
+checkout. Commit messages describe the refactoring.
+
+-verify-tests runs go test on the packages of the changed files (or, with
+-verify-tests=all, on ./...) before and after the refactoring is applied,
+and rolls it back if tests fail that passed before.
+
+Flags:
+`
+
+// errToolFailed reports that the tool ran and returned an error result,
+// which has already been printed.
+var errToolFailed = errors.New("tool failed")
+
+// runTool implements the run subcommand.
+func runTool(ctx context.Context, stdout io.Writer, args []string) error {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), runUsage)
+		fs.PrintDefaults()
+	}
+	workspace := fs.String("workspace", ".", "workspace root (go.mod directory)")
+	format := fs.String("format", formatText, "output format: text or json (the MCP tool payload)")
+	preview := fs.Bool("preview", false, "return the changes a refactoring plans instead of applying them")
+	allowGenerated := fs.Bool("allow-generated", false, "allow refactorings to edit generated files")
+	minConfidence := fs.String("min-confidence", "", "skip planned changes less sure than this: certain, likely or heuristic")
+	verifyInternal := fs.Bool("verify-internal", false, "check workspace data structures after loading and after the tool runs")
+	keepAlias := fs.Bool("keep-alias", false, "with rename_symbol, keep the old name of an exported symbol as a deprecated alias")
+	var verifyTests verifyTestsFlag
+	fs.Var(&verifyTests, "verify-tests", "run the tests of the changed packages, or =all for ./..., before and after applying and roll back on new failures")
+	gitBranch := fs.String("git-branch", "", "apply on this new branch in a temporary worktree and commit there")
+	gitCommit := fs.Bool("git-commit", false, "commit the changed files with a message describing the refactoring")
+	worktree := fs.Bool("worktree", false, "apply in a new worktree, on -git-branch or a generated branch, and keep it")
+	logFlags := registerCommandFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != formatText && *format != formatJSON {
+		return fmt.Errorf("unknown format %q: want %s or %s", *format, formatText, formatJSON)
+	}
+	if *preview && (*gitBranch != "" || *gitCommit || *worktree) {
+		return fmt.Errorf("-preview applies nothing, so it can't be combined with -git-branch, -git-commit or -worktree")
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("missing tool name")
+	}
+	tool := fs.Arg(0)
+	toolArgs, err := parseToolArgs(fs.Args()[1:])
+	if err != nil {
+		return err
+	}
+	if *keepAlias {
+		if tool != "rename_symbol" {
+			return fmt.Errorf("-keep-alias applies to rename_symbol, not %s", tool)
+		}
+		toolArgs["keep_alias"] = true
+	}
+
+	opts := runOptions{
+		workspace:      *workspace,
+		format:         *format,
+		preview:        *preview,
+		allowGenerated: *allowGenerated,
+		minConfidence:  *minConfidence,
+		verifyInternal: *verifyInternal,
+		verifyTests:    string(verifyTests),
+		gitBranch:      *gitBranch,
+		gitCommit:      *gitCommit,
+		worktree:       *worktree,
+		log:            *logFlags,
+	}
+	return invoke(ctx, stdout, opts, tool, toolArgs)
+}
+
+// runOptions configures the in-process server a subcommand calls.
+type runOptions struct {
+	workspace      string
+	format         string
+	preview        bool
+	allowGenerated bool
+	minConfidence  string
+	verifyInternal bool
+	verifyTests    string
+	gitBranch      string
+	gitCommit      bool
+	worktree       bool
+	scope          []string
+	mapSources     bool
+	log            commandFlags
+}
+
+// commandFlags are the logging and profiling flags of a subcommand that
+// prints its results.
+type commandFlags struct {
+	logging.Flags
+	profile profiling.Flags
+}
+
+// registerCommandFlags defines the logging and profiling flags of a
+// subcommand that prints its results. Such subcommands only log errors, to
+// stderr, by default.
+func registerCommandFlags(fs *flag.FlagSet) *commandFlags {
+	f := &commandFlags{Flags: logging.Flags{Level: slog.LevelError}}
+	f.Flags.Register(fs)
+	f.profile.Register(fs)
+	return f
+}
+
+// New returns the logger the flags configure, having started the profiling
+// they ask for, and a function writing the profile and closing the log
+// file.
+func (f *commandFlags) New() (*slog.Logger, func() error, error) {
+	logger, closeLog, err := f.Flags.New()
+	if err != nil {
+		return nil, nil, err
+	}
+	stopProfile, err := f.profile.Start(logger)
+	if err != nil {
+		_ = closeLog()
+		return nil, nil, err
+	}
+	return logger, func() error { return errors.Join(stopProfile(), closeLog()) }, nil
+}
+
+// verifyTestsFlag is the -verify-tests flag: alone it tests the affected
+// packages, and it also takes a scope, -verify-tests=all.
+type verifyTestsFlag refactor.TestVerification
+
+func (f *verifyTestsFlag) String() string { return string(*f) }
+
+func (f *verifyTestsFlag) IsBoolFlag() bool { return true }
+
+func (f *verifyTestsFlag) Set(s string) error {
+	switch s {
+	case "true":
+		s = string(refactor.VerifyTestsAffected)
+	case "false":
+		s = string(refactor.VerifyTestsOff)
+	}
+	scope, err := refactor.ParseTestVerification(s)
+	if err != nil {
+		return err
+	}
+	*f = verifyTestsFlag(scope)
+	return nil
+}
+
+// invoke loads the workspace into an in-process server and calls tool,
+// printing its result in opts.format.
+func invoke(ctx context.Context, stdout io.Writer, opts runOptions, tool string, toolArgs map[string]any) error {
+	logger, closeLog, err := opts.log.New()
+	if err != nil {
+		return err
+	}
+	defer closeLog()
+	state := internalmcp.NewMCPServer(logger)
+	defer state.Close()
+	state.SetPreview(opts.preview)
+	state.SetVerifyInternal(opts.verifyInternal)
+	state.SetGitApply(opts.gitBranch, opts.gitCommit, opts.worktree)
+
+	session, err := connectInMemory(ctx, state)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	load := map[string]any{"path": opts.workspace, "allow_generated": opts.allowGenerated}
+	if opts.minConfidence != "" {
+		load["min_confidence"] = opts.minConfidence
+	}
+	if opts.verifyTests != "" {
+		load["verify_tests"] = opts.verifyTests
+	}
+	if len(opts.scope) > 0 {
+		load["scope"] = opts.scope
+	}
+	if opts.mapSources {
+		load["map_sources"] = true
+	}
+	if err := callTool(ctx, session, stdout, opts.format, "load_workspace", load, true); err != nil {
+		return err
+	}
+	return callTool(ctx, session, stdout, opts.format, tool, toolArgs, false)
+}
+
+// connectInMemory serves the tools of state over an in-memory transport and
+// returns a client session connected to it.
+func connectInMemory(ctx context.Context, state *internalmcp.MCPServer) (*mcpsdk.ClientSession, error) {
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "gorefactor", Version: "1.0.0"}, nil)
+	internalmcp.RegisterAllTools(server, state)
+	serverT, clientT := mcpsdk.NewInMemoryTransports()
+	if _, err := server.Connect(ctx, serverT, nil); err != nil {
+		return nil, fmt.Errorf("start server: %w", err)
+	}
+	client := mcpsdk.NewClient(&mcpsdk.Implementation{Name: "gorefactor-cli", Version: "1.0.0"}, nil)
+	session, err := client.Connect(ctx, clientT, nil)
+	if err != nil {
+		return nil, fmt.Errorf("connect: %w", err)
+	}
+	return session, nil
+}
+
+// parseToolArgs turns name=value pairs into tool arguments.
+func parseToolArgs(pairs []string) (map[string]any, error) {
+	args := make(map[string]any)
+	for _, pair := range pairs {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("argument %q is not name=value", pair)
+		}
+		var v any
+		if err := json.Unmarshal([]byte(value), &v); err != nil {
+			v = value
+		}
+		args[name] = v
+	}
+	return args, nil
+}
+
+// callTool calls a tool and prints its payload in format. Quiet calls only
+// print errors. A tool error is printed like a payload and returned as
+// errToolFailed.
+func callTool(ctx context.Context, session *mcpsdk.ClientSession, w io.Writer, format, name string, args map[string]any, quiet bool) error {
+	res, err := session.CallTool(ctx, &mcpsdk.CallToolParams{Name: name, Arguments: args})
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	if quiet && !res.IsError {
+		return nil
+	}
+	if err := writeResult(w, format, res); err != nil {
+		return err
+	}
+	if res.IsError {
+		return errToolFailed
+	}
+	return nil
+}
+
+// writeResult prints a tool result. JSON output is the tool's payload
+// unchanged; errors become {"error": ..., "code": ..., "suggestions": ...}. Text output
+// renders the same data as YAML.
+func writeResult(w io.Writer, format string, res *mcpsdk.CallToolResult) error {
+	var texts []string
+	for _, c := range res.Content {
+		if t, ok := c.(*mcpsdk.TextContent); ok {
+			texts = append(texts, t.Text)
+		}
+	}
+	var payload any
+	if res.IsError {
+		out := map[string]any{"error": ""}
+		for i, text := range texts {
+			if i == 0 {
+				out["error"] = text
+				continue
+			}
+			// The code, issues and suggestions follow the message as a JSON block.
+			_ = json.Unmarshal([]byte(text), &out)
+		}
+		payload = out
+	} else if len(texts) == 1 && json.Valid([]byte(texts[0])) {
+		if format == formatJSON {
+			_, err := fmt.Fprintln(w, texts[0])
+			return err
+		}
+		if err := json.Unmarshal([]byte(texts[0]), &payload); err != nil {
+			return err
+		}
+	} else {
+		payload = strings.Join(texts, "\n")
+	}
+
+	if format == formatJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(payload)
+	}
+	if s, ok := payload.(string); ok {
+		_, err := fmt.Fprintln(w, s)
+		return err
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(payload); err != nil {
+		return err
+	}
+	return enc.Close()
+}
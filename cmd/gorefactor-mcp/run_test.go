@@ -0,0 +1,384 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeWorkspace(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/calc\n\ngo 1.21\n",
+		"main.go": `package main
+
+func Add(a, b int) int {
+	return a + b
+}
+
+func main() {
+	_ = Add(1, 2)
+}
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestRunTool_JSON(t *testing.T) {
+	dir := writeWorkspace(t)
+	var out bytes.Buffer
+	err := runTool(context.Background(), &out, []string{"-workspace", dir, "-format", "json", "complexity", "min_complexity=1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var payload struct {
+		Count   int `json:"count"`
+		Results []struct {
+			Function string `json:"function"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &payload); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, out.String())
+	}
+	if payload.Count != 2 || len(payload.Results) != 2 {
+		t.Errorf("got %d results, want 2:\n%s", payload.Count, out.String())
+	}
+}
+
+func TestRunTool_Preview(t *testing.T) {
+	dir := writeWorkspace(t)
+	before, _ := os.ReadFile(filepath.Join(dir, "main.go"))
+
+	var out bytes.Buffer
+	err := runTool(context.Background(), &out, []string{"-workspace", dir, "-format", "json", "-preview", "rename_symbol", "symbol=Add", "new_name=Sum"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var plan struct {
+		Preview bool `json:"preview"`
+		Changes []struct {
+			NewText string `json:"new_text"`
+		} `json:"changes"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &plan); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, out.String())
+	}
+	if !plan.Preview || len(plan.Changes) == 0 {
+		t.Errorf("expected a previewed plan, got:\n%s", out.String())
+	}
+	after, _ := os.ReadFile(filepath.Join(dir, "main.go"))
+	if !bytes.Equal(before, after) {
+		t.Errorf("preview modified main.go:\n%s", after)
+	}
+}
+
+func TestRunTool_MovePackagePreview(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":         "module example.com/shop\n\ngo 1.21\n",
+		"util/util.go":   "package util\n\nfunc Double(n int) int { return 2 * n }\n",
+		"order/order.go": "package order\n\nimport \"example.com/shop/util\"\n\nvar Total = util.Double(2)\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var out bytes.Buffer
+	err := runTool(context.Background(), &out, []string{"-workspace", dir, "-format", "json", "move_package", "source_package=util", "target_package=internal/util", "preview=true"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var plan struct {
+		Preview bool `json:"preview"`
+		Changes []struct {
+			NewText string `json:"new_text"`
+		} `json:"changes"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &plan); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, out.String())
+	}
+	if !plan.Preview || !strings.Contains(out.String(), "example.com/shop/internal/util") {
+		t.Errorf("expected a previewed move updating the import, got:\n%s", out.String())
+	}
+	if _, err := os.Stat(filepath.Join(dir, "util", "util.go")); err != nil {
+		t.Errorf("preview moved the package: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "internal")); err == nil {
+		t.Error("preview created the target directory")
+	}
+}
+
+func TestRunTool_Errors(t *testing.T) {
+	dir := writeWorkspace(t)
+	var out bytes.Buffer
+	err := runTool(context.Background(), &out, []string{"-workspace", dir, "-format", "json", "rename_symbol", "symbol=Missing", "new_name=X"})
+	if !errors.Is(err, errToolFailed) {
+		t.Fatalf("expected errToolFailed, got %v", err)
+	}
+	var payload map[string]any
+	if err := json.Unmarshal(out.Bytes(), &payload); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, out.String())
+	}
+	if msg, _ := payload["error"].(string); !strings.Contains(msg, "Missing") {
+		t.Errorf("error payload = %v", payload)
+	}
+
+	if err := runTool(context.Background(), &out, []string{"-format", "xml", "complexity"}); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+	if _, err := parseToolArgs([]string{"novalue"}); err == nil {
+		t.Error("expected an error for an argument without =")
+	}
+}
+
+func TestParseToolArgs(t *testing.T) {
+	args, err := parseToolArgs([]string{"package=./pkg", "min_complexity=20", "fixers=[\"any\"]", "force=true"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args["package"] != "./pkg" || args["min_complexity"] != float64(20) || args["force"] != true {
+		t.Errorf("unexpected args: %#v", args)
+	}
+	if list, ok := args["fixers"].([]any); !ok || len(list) != 1 {
+		t.Errorf("fixers = %#v, want a one-element list", args["fixers"])
+	}
+}
+
+func TestRunAnalyze_SARIF(t *testing.T) {
+	dir := writeWorkspace(t)
+	out := filepath.Join(t.TempDir(), "report.sarif")
+	err := runAnalyze(context.Background(), io.Discard, []string{"-workspace", dir, "-format", "sarif", "-o", out, "complexity"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var log struct {
+		Version string `json:"version"`
+		Runs    []struct {
+			Results []json.RawMessage `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(b, &log); err != nil {
+		t.Fatalf("report is not JSON: %v\n%s", err, b)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Errorf("unexpected SARIF log:\n%s", b)
+	}
+
+	if err := runAnalyze(context.Background(), io.Discard, []string{"-format", "xml"}); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestRunTool_RegisteredAnalyzer(t *testing.T) {
+	dir := writeWorkspace(t)
+	src := "package main\n\nfunc main() {\n\tx := 1\n\tx = x\n\t_ = x\n}\n"
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := runTool(context.Background(), &out, []string{"-workspace", dir, "-format", "json", "detect_assign"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "self-assignment of x") {
+		t.Errorf("expected a self-assignment diagnostic, got:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := runTool(context.Background(), &out, []string{"-workspace", dir, "-format", "json", "fix_assign"}); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := os.ReadFile(filepath.Join(dir, "main.go"))
+	if strings.Contains(string(got), "x = x") {
+		t.Errorf("fix_assign left the self-assignment:\n%s", got)
+	}
+}
+
+func TestRunCheckArch(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":           "module example.com/shop\n\ngo 1.21\n",
+		".gorefactor.yaml": "architecture:\n  - package: domain\n    forbid: [web]\n    reason: keep the domain pure\n",
+		"web/status.go":    "package web\n\nfunc Status() int { return 200 }\n",
+		"domain/order.go":  "package domain\n\nimport \"example.com/shop/web\"\n\nvar Code = web.Status()\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var out bytes.Buffer
+	err := runCheckArch(context.Background(), &out, []string{"-workspace", dir})
+	if !errors.Is(err, errCheckFailed) {
+		t.Fatalf("expected errCheckFailed, got %v", err)
+	}
+	want := "domain/order.go:3: domain imports web, but domain must not import web\n" +
+		"\treason: keep the domain pure\n" +
+		"\timport \"example.com/shop/web\"\n" +
+		"\tfix: move_symbol Status from web to domain\n"
+	if out.String() != want {
+		t.Errorf("output:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestRunTool_LogFile(t *testing.T) {
+	dir := writeWorkspace(t)
+	logFile := filepath.Join(t.TempDir(), "logs", "run.log")
+	args := []string{"-workspace", dir, "-log-level", "info", "-log-format", "json", "-log-file", logFile, "complexity"}
+	if err := runTool(context.Background(), io.Discard, args); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var calls int
+	for line := range bytes.Lines(data) {
+		var record struct {
+			Msg  string `json:"msg"`
+			Tool string `json:"tool"`
+			OpID string `json:"op_id"`
+		}
+		if err := json.Unmarshal(line, &record); err != nil {
+			t.Fatalf("log line is not JSON: %v\n%s", err, line)
+		}
+		if record.Msg == "tool call" {
+			calls++
+			if record.OpID == "" {
+				t.Errorf("tool call of %s logged without op_id", record.Tool)
+			}
+		}
+	}
+	if calls != 2 {
+		t.Errorf("logged %d tool calls, want load_workspace and complexity:\n%s", calls, data)
+	}
+}
+
+func TestRunTool_Profile(t *testing.T) {
+	dir := writeWorkspace(t)
+	profile := filepath.Join(t.TempDir(), "mem.pprof")
+	args := []string{"-workspace", dir, "-profile", "mem", "-profile-out", profile, "complexity"}
+	if err := runTool(context.Background(), io.Discard, args); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(profile); err != nil || info.Size() == 0 {
+		t.Errorf("expected a heap profile at %s: %v", profile, err)
+	}
+	args = []string{"-workspace", dir, "-profile", "disk", "-profile-out", profile, "complexity"}
+	if err := runTool(context.Background(), io.Discard, args); err == nil || !strings.Contains(err.Error(), "unknown profile") {
+		t.Errorf("expected an unknown profile error, got %v", err)
+	}
+}
+
+func TestRunHistoryAndRollback(t *testing.T) {
+	dir := writeWorkspace(t)
+	before, err := os.ReadFile(filepath.Join(dir, "main.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := runTool(context.Background(), io.Discard, []string{"-workspace", dir, "rename_symbol", "symbol=Add", "new_name=Sum"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := runHistory(context.Background(), &out, []string{"-workspace", dir, "-format", "json", "list"}); err != nil {
+		t.Fatal(err)
+	}
+	var history struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &history); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, out.String())
+	}
+	if len(history.Data) != 1 {
+		t.Fatalf("got %d history entries, want 1:\n%s", len(history.Data), out.String())
+	}
+
+	if err := runRollback(context.Background(), io.Discard, []string{"-workspace", dir, "-to", history.Data[0].ID}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, "main.go")); !bytes.Equal(got, before) {
+		t.Errorf("after rollback main.go is\n%s\nwant\n%s", got, before)
+	}
+	if err := runHistory(context.Background(), io.Discard, []string{"-workspace", dir, "ls"}); err == nil {
+		t.Error("expected an unknown history command to fail")
+	}
+}
+
+func TestRunSuggestHome(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":         "module example.com/home\n\ngo 1.21\n",
+		"util/util.go":   "package util\n\nfunc Greeting(name string) string {\n\treturn \"hello \" + name\n}\n",
+		"greet/greet.go": "package greet\n\nimport \"example.com/home/util\"\n\nfunc Hello() string { return util.Greeting(\"a\") }\n\nfunc Bye() string { return util.Greeting(\"b\") }\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := runSuggestHome(context.Background(), &out, []string{"-workspace", dir, "-format", "json", "-symbol", "Greeting"}); err != nil {
+		t.Fatalf("%v\n%s", err, out.String())
+	}
+	var result struct {
+		Candidates []struct {
+			Package string `json:"package"`
+			Current bool   `json:"current"`
+		} `json:"candidates"`
+		MoveSymbol struct {
+			FromPackage string `json:"from_package"`
+			ToPackage   string `json:"to_package"`
+		} `json:"move_symbol"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &result); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, out.String())
+	}
+	if len(result.Candidates) == 0 || result.Candidates[0].Package != "greet" {
+		t.Fatalf("want greet ranked first, got:\n%s", out.String())
+	}
+	if result.MoveSymbol.FromPackage != "util" || result.MoveSymbol.ToPackage != "greet" {
+		t.Errorf("want a move from util to greet, got %+v", result.MoveSymbol)
+	}
+
+	if err := runSuggestHome(context.Background(), io.Discard, []string{"-workspace", dir, "-symbol", "Missing"}); err == nil {
+		t.Error("expected an undeclared symbol to fail")
+	}
+	if err := runSuggestHome(context.Background(), io.Discard, []string{"-workspace", dir}); err == nil {
+		t.Error("expected a missing -symbol to fail")
+	}
+}
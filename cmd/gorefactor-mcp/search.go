@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mamaar/gorefactor/pkg/analysis"
+)
+
+const searchUsage = `usage: gorefactor-mcp search [flags] [pattern]
+
+Searches the package-level declarations and methods of a workspace and
+prints one line per symbol with its location and signature. The pattern is
+a glob on names, or on Type.Method for methods; with -regex a regular
+expression matched anywhere in the name:
+
+  gorefactor-mcp search -kind func,method -signature context.Context 'New*'
+  internal/store/store.go:14:6: func NewStore(ctx context.Context) *Store
+
+Flags:
+`
+
+// runSearch implements the search subcommand on top of the search_symbols
+// tool.
+func runSearch(ctx context.Context, stdout io.Writer, args []string) error {
+	fs := flag.NewFlagSet("search", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), searchUsage)
+		fs.PrintDefaults()
+	}
+	workspace := fs.String("workspace", ".", "workspace root (go.mod directory)")
+	format := fs.String("format", formatText, "output format: text or json")
+	regex := fs.Bool("regex", false, "treat the pattern as a regular expression")
+	kinds := fs.String("kind", "", "comma-separated kinds to include: func, method, type, const, var")
+	exported := fs.Bool("exported", false, "only exported symbols")
+	packages := fs.String("package", "", "comma-separated package patterns, e.g. internal/store/...")
+	signature := fs.String("signature", "", "substring the signature must contain")
+	tests := fs.Bool("tests", false, "search test files too")
+	limit := fs.Int("limit", 0, "maximum number of symbols (default 100)")
+	logFlags := registerCommandFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != formatText && *format != formatJSON {
+		return fmt.Errorf("unknown format %q: want %s or %s", *format, formatText, formatJSON)
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return fmt.Errorf("expected at most one pattern")
+	}
+	toolArgs := map[string]any{"pattern": fs.Arg(0), "regex": *regex, "exported": *exported, "tests": *tests}
+	if *kinds != "" {
+		toolArgs["kinds"] = strings.Split(*kinds, ",")
+	}
+	if *packages != "" {
+		toolArgs["packages"] = strings.Split(*packages, ",")
+	}
+	if *signature != "" {
+		toolArgs["signature"] = *signature
+	}
+	if *limit != 0 {
+		toolArgs["limit"] = *limit
+	}
+
+	var buf bytes.Buffer
+	if err := invoke(ctx, &buf, runOptions{workspace: *workspace, format: formatJSON, log: *logFlags}, "search_symbols", toolArgs); err != nil {
+		// Tool errors are reported as JSON; show them as the tool wrote them.
+		_, _ = stdout.Write(buf.Bytes())
+		return err
+	}
+	if *format == formatJSON {
+		_, err := stdout.Write(buf.Bytes())
+		return err
+	}
+	var result struct {
+		Symbols   []*analysis.SymbolMatch `json:"symbols"`
+		Truncated bool                    `json:"truncated"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &result); err != nil {
+		return err
+	}
+	return writeSymbols(stdout, *workspace, result.Symbols, result.Truncated)
+}
+
+// writeSymbols prints symbols as file:line:column lines, relative to the
+// workspace root, followed by the first line of their signature.
+func writeSymbols(w io.Writer, workspace string, symbols []*analysis.SymbolMatch, truncated bool) error {
+	root, err := filepath.Abs(workspace)
+	if err != nil {
+		return err
+	}
+	var sb bytes.Buffer
+	for _, s := range symbols {
+		file := s.File
+		if rel, err := filepath.Rel(root, file); err == nil {
+			file = rel
+		}
+		signature, _, _ := strings.Cut(s.Signature, "\n")
+		fmt.Fprintf(&sb, "%s:%d:%d: %s\n", file, s.Line, s.Column, signature)
+	}
+	if truncated {
+		fmt.Fprintf(&sb, "(first %d symbols; raise -limit for more)\n", len(symbols))
+	}
+	_, err = w.Write(sb.Bytes())
+	return err
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mamaar/gorefactor/internal/selftest"
+)
+
+const selftestUsage = `usage: gorefactor-mcp selftest -corpus dir [flags]
+
+Qualifies gorefactor against your own code before you trust it with large
+refactorings. Every Go module in the corpus directory (or the directory
+itself, when it holds a go.mod) is copied, built and, with -tests, tested as
+checked out; then each operation of the matrix is applied to a few of its
+symbols or packages, each on a fresh copy, and the copy is built and tested
+again. The corpus is never written.
+
+A refused refactoring is not a failure: failures are plans that were
+applied and broke the build or the tests, and make the exit status 1.
+
+Operations: rename_symbol, rename_type, rename_method, rename_package,
+move_symbol
+
+Flags:
+`
+
+// runSelftest implements the selftest subcommand.
+func runSelftest(ctx context.Context, stdout io.Writer, args []string) (err error) {
+	fs := flag.NewFlagSet("selftest", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), selftestUsage)
+		fs.PrintDefaults()
+	}
+	corpus := fs.String("corpus", "", "directory of Go modules to refactor, or a single module")
+	ops := fs.String("ops", "", "comma-separated operations to run (default all)")
+	targets := fs.Int("targets", 3, "symbols or packages each operation is tried on per module")
+	tests := fs.Bool("tests", true, "run go test ./... as well as go build ./...")
+	format := fs.String("format", formatText, "output format: text or json")
+	output := fs.String("o", "", "write the report to this file instead of stdout")
+	verbose := fs.Bool("v", false, "log each run to stderr")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *corpus == "" {
+		fs.Usage()
+		return fmt.Errorf("missing -corpus")
+	}
+	if *format != formatText && *format != formatJSON {
+		return fmt.Errorf("unknown format %q: want %s or %s", *format, formatText, formatJSON)
+	}
+
+	opts := selftest.Options{Targets: *targets, Tests: *tests}
+	if *ops != "" {
+		opts.Operations = strings.Split(*ops, ",")
+	}
+	if *verbose {
+		opts.Logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+	}
+	report, err := selftest.Run(ctx, *corpus, opts)
+	if err != nil {
+		return err
+	}
+
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+		}()
+		stdout = f
+	}
+	if *format == formatJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(report)
+	} else {
+		err = writeSelftestReport(stdout, report)
+	}
+	if err == nil && report.Failed() {
+		err = errCheckFailed
+	}
+	return err
+}
+
+// writeSelftestReport prints the per-operation totals, then the skipped
+// modules and every result that didn't pass.
+func writeSelftestReport(w io.Writer, report *selftest.Report) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "OPERATION\tRUNS\tPASSED\tREFUSED\tFAILED")
+	for _, s := range report.Operations {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\n", s.Operation, s.Runs, s.Passed, s.Refused, s.Failed)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	for _, repo := range report.Repos {
+		if repo.Skipped != "" {
+			fmt.Fprintf(w, "\n%s: skipped: %s\n", repo.Name, repo.Skipped)
+			continue
+		}
+		for _, res := range repo.Results {
+			if res.Status == selftest.StatusPassed {
+				continue
+			}
+			fmt.Fprintf(w, "\n%s: %s %s: %s\n", repo.Name, res.Operation, res.Target, res.Status)
+			if res.Error != "" {
+				fmt.Fprintf(w, "    %s\n", strings.ReplaceAll(res.Error, "\n", "\n    "))
+			}
+		}
+	}
+	return nil
+}
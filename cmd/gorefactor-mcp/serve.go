@@ -0,0 +1,572 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+
+	gorefactorv1 "github.com/mamaar/gorefactor/api/gorefactor/v1"
+	"github.com/mamaar/gorefactor/internal/logging"
+	internalmcp "github.com/mamaar/gorefactor/internal/mcp"
+	"github.com/mamaar/gorefactor/internal/profiling"
+	"github.com/mamaar/gorefactor/internal/telemetry"
+	"github.com/mamaar/gorefactor/pkg/refactor"
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+const serveUsage = `usage: gorefactor-mcp serve [flags]
+
+Serves the refactoring engine as a JSON API over HTTP, for tools and bots
+that don't speak MCP:
+
+  GET  /workspace/packages   the packages of the workspace
+  POST /plan/{operation}     plan an operation from its request fields
+  POST /apply                apply a plan returned by /plan
+  GET  /analyze/{analyzer}   complexity or unused, with the arguments of
+                             the tool as query parameters
+
+Operations are those of batch file steps; /plan/rename is rename_symbol and
+/plan/move is move_symbol:
+
+  curl -H 'Content-Type: application/json' \
+    -d '{"symbol_name":"Add","new_name":"Sum"}' localhost:8080/plan/rename
+
+/apply takes the response of /plan as it is. It refuses a plan whose
+plan_hash doesn't match its changes, that edits files outside the
+workspace, or whose files changed since it was planned. Errors are returned
+as {"error": ...}, with the error's code, such as GR1001, when it has one.
+
+With -grpc the same API is also served over gRPC, with progress streamed
+while plans are made and applied; api/gorefactor/v1/refactor.proto defines
+the service. -http "" serves gRPC alone.
+
+GET /metrics returns Prometheus metrics, which -metrics also serves on an
+address of its own. -pprof serves the pprof endpoints, and -profile writes
+a cpu or mem profile when the server stops. OTEL_EXPORTER_OTLP_ENDPOINT
+exports traces.
+
+The server doesn't authenticate requests: keep it on localhost, the
+default, or behind a proxy that does. So that web pages can't call it from
+the browser, POST bodies must be sent as application/json, requests must be
+addressed to a loopback host or one -allow-host names, and requests from
+pages of another origin are refused.
+
+Flags:
+`
+
+// maxRequestBody bounds the JSON bodies the HTTP API reads.
+const maxRequestBody = 32 << 20
+
+// planAliases are the short names of operations under /plan.
+var planAliases = map[string]string{
+	"rename": "rename_symbol",
+	"move":   "move_symbol",
+}
+
+// apiAnalyzers are the read-only tools served under /analyze and by the
+// Analyze RPC.
+var apiAnalyzers = map[string]string{
+	"complexity": "complexity",
+	"unused":     "unused",
+}
+
+// Errors the HTTP and gRPC APIs report with their own status codes.
+var (
+	errUnknownAnalyzer = errors.New("unknown analyzer")
+	errInvalidPlan     = errors.New("invalid plan")
+)
+
+// runServe implements the serve subcommand.
+func runServe(ctx context.Context, stdout io.Writer, args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), serveUsage)
+		fs.PrintDefaults()
+	}
+	workspace := fs.String("workspace", ".", "workspace root (go.mod directory)")
+	httpAddr := fs.String("http", "localhost:8080", "address to serve the HTTP API on; empty for none")
+	grpcAddr := fs.String("grpc", "", "address to serve the gRPC API on")
+	allowHosts := fs.String("allow-host", "", "comma-separated host names, besides localhost and loopback addresses, the HTTP API answers requests for, such as that of a proxy in front of it")
+	allowGenerated := fs.Bool("allow-generated", false, "allow plans to edit generated files")
+	minConfidence := fs.String("min-confidence", "", "skip planned changes less sure than this when applying: certain, likely or heuristic")
+	var verifyTests verifyTestsFlag
+	fs.Var(&verifyTests, "verify-tests", "run the tests of the changed packages, or =all for ./..., around each applied plan and roll back on new failures")
+	logFlags := logging.Flags{Level: slog.LevelInfo}
+	logFlags.Register(fs)
+	var profileFlags profiling.Flags
+	profileFlags.Register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 || *httpAddr == "" && *grpcAddr == "" {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	load := map[string]any{"path": *workspace, "allow_generated": *allowGenerated}
+	if *minConfidence != "" {
+		load["min_confidence"] = *minConfidence
+	}
+	if verifyTests != "" {
+		load["verify_tests"] = string(verifyTests)
+	}
+	logger, closeLog, err := logFlags.New()
+	if err != nil {
+		return err
+	}
+	defer closeLog()
+	stopTracing, err := telemetry.SetupTracing(ctx, "1.0.0")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if terr := stopTracing(shutdownCtx); terr != nil {
+			logger.Error("failed to export traces", "err", terr)
+		}
+	}()
+	stopProfile, err := profileFlags.Start(logger)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if perr := stopProfile(); perr != nil {
+			logger.Error("failed to write profile", "err", perr)
+		}
+	}()
+	api, err := newAPIServer(ctx, stdout, logger, load)
+	if err != nil {
+		return err
+	}
+	defer api.close()
+	for h := range strings.SplitSeq(*allowHosts, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			api.allowHosts = append(api.allowHosts, h)
+		}
+	}
+
+	errc := make(chan error, 2)
+	var httpSrv *http.Server
+	var grpcSrv *grpc.Server
+	if *httpAddr != "" {
+		ln, err := net.Listen("tcp", *httpAddr)
+		if err != nil {
+			return err
+		}
+		httpSrv = &http.Server{Handler: api.handler(), ReadHeaderTimeout: 10 * time.Second}
+		go func() { errc <- httpSrv.Serve(ln) }()
+		fmt.Fprintf(stdout, "serving %s over HTTP on http://%s\n", *workspace, ln.Addr())
+	}
+	if *grpcAddr != "" {
+		ln, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			if httpSrv != nil {
+				_ = httpSrv.Close()
+			}
+			return err
+		}
+		grpcSrv = grpc.NewServer(api.grpcOptions()...)
+		gorefactorv1.RegisterRefactorServiceServer(grpcSrv, &grpcServer{api: api})
+		go func() { errc <- grpcSrv.Serve(ln) }()
+		fmt.Fprintf(stdout, "serving %s over gRPC on %s\n", *workspace, ln.Addr())
+	}
+
+	select {
+	case err = <-errc:
+	case <-ctx.Done():
+	}
+	// Let in-flight requests, and the plans they apply, finish.
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if httpSrv != nil {
+		if serr := httpSrv.Shutdown(shutdownCtx); err == nil {
+			err = serr
+		}
+	}
+	if grpcSrv != nil {
+		stopped := make(chan struct{})
+		go func() {
+			grpcSrv.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-shutdownCtx.Done():
+			grpcSrv.Stop()
+		}
+	}
+	if serr := api.state.Shutdown(shutdownCtx); err == nil {
+		err = serr
+	}
+	if errors.Is(err, http.ErrServerClosed) || errors.Is(err, grpc.ErrServerStopped) {
+		err = nil
+	}
+	return err
+}
+
+// apiServer answers the HTTP and gRPC APIs from the state of an in-process
+// MCP server, whose tools serve the analyses.
+type apiServer struct {
+	state   *internalmcp.MCPServer
+	session *mcpsdk.ClientSession
+	logger  *slog.Logger
+
+	allowHosts []string // Host names the HTTP API answers besides loopback ones
+}
+
+// newAPIServer loads a workspace, with the arguments load of the
+// load_workspace tool, into an in-process server. A load error is printed
+// to stdout like the run subcommand prints it.
+func newAPIServer(ctx context.Context, stdout io.Writer, logger *slog.Logger, load map[string]any) (*apiServer, error) {
+	state := internalmcp.NewMCPServer(logger)
+	session, err := connectInMemory(ctx, state)
+	if err != nil {
+		state.Close()
+		return nil, err
+	}
+	api := &apiServer{state: state, session: session, logger: logger}
+	if err := callTool(ctx, session, stdout, formatText, "load_workspace", load, true); err != nil {
+		api.close()
+		return nil, err
+	}
+	return api, nil
+}
+
+func (api *apiServer) close() {
+	_ = api.session.Close()
+	api.state.Close()
+}
+
+// apiPackage is a package as /workspace/packages lists it.
+type apiPackage struct {
+	ImportPath string   `json:"import_path"`
+	Name       string   `json:"name"`
+	Dir        string   `json:"dir"`
+	Files      []string `json:"files"`
+	TestFiles  []string `json:"test_files,omitempty"`
+}
+
+// apiPlan is a plan as /plan returns it and /apply takes it.
+type apiPlan struct {
+	Operation  string                 `json:"operation"`
+	PlanHash   string                 `json:"plan_hash"`
+	Plan       *types.RefactoringPlan `json:"plan"`
+	Generation uint64                 `json:"generation,omitempty"` // Of the workspace planned against; /apply refuses the plan if its files changed since
+}
+
+// packages lists the packages of the workspace by import path.
+func (api *apiServer) packages() ([]apiPackage, error) {
+	api.state.RLock()
+	defer api.state.RUnlock()
+	ws, err := api.state.GetWorkspace()
+	if err != nil {
+		return nil, err
+	}
+	pkgs := []apiPackage{}
+	for _, pkg := range ws.Packages {
+		pkgs = append(pkgs, apiPackage{
+			ImportPath: pkg.ImportPath,
+			Name:       pkg.Name,
+			Dir:        pkg.Dir,
+			Files:      slices.Sorted(maps.Keys(pkg.Files)),
+			TestFiles:  slices.Sorted(maps.Keys(pkg.TestFiles)),
+		})
+	}
+	slices.SortFunc(pkgs, func(a, b apiPackage) int { return strings.Compare(a.ImportPath, b.ImportPath) })
+	return pkgs, nil
+}
+
+// plan plans op, or the operation it is short for, from its request fields.
+func (api *apiServer) plan(ctx context.Context, op string, request map[string]any) (_ *apiPlan, err error) {
+	if name, ok := planAliases[op]; ok {
+		op = name
+	}
+	_, span := telemetry.Tracer().Start(ctx, telemetry.SpanPlan, trace.WithAttributes(attribute.String("gorefactor.plan.operation", op)))
+	defer func() { telemetry.End(span, err) }()
+	api.state.RLock()
+	defer api.state.RUnlock()
+	// Read before the workspace, so a change in between makes the plan stale
+	// rather than unnoticed.
+	generation := api.state.Generation()
+	ws, err := api.state.GetWorkspace()
+	if err != nil {
+		return nil, err
+	}
+	plan, err := api.state.GetEngine().PlanStep(ws, types.BatchStep{Name: op, Operation: op, Request: request})
+	if err != nil {
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("gorefactor.plan.changes", len(plan.Changes)), attribute.Int("gorefactor.plan.files", len(plan.AffectedFiles)))
+	telemetry.ObservePlan(len(plan.Changes))
+	return &apiPlan{Operation: op, PlanHash: refactor.PlanHash(plan), Plan: plan, Generation: generation}, nil
+}
+
+// apply applies a plan returned by plan. Plans that don't match their hash
+// or that write outside the workspace are refused with errInvalidPlan.
+func (api *apiServer) apply(ctx context.Context, in *apiPlan) (*internalmcp.PlanResult, error) {
+	if in.Plan == nil {
+		return nil, fmt.Errorf("%w: missing plan", errInvalidPlan)
+	}
+	if in.PlanHash != refactor.PlanHash(in.Plan) {
+		return nil, fmt.Errorf("%w: plan_hash doesn't match the changes of the plan", errInvalidPlan)
+	}
+	if err := api.checkPlanFiles(in.Plan); err != nil {
+		return nil, err
+	}
+	desc := in.Operation
+	if desc == "" {
+		desc = "apply plan"
+	}
+	if in.Generation != 0 {
+		ctx = internalmcp.PlannedAt(ctx, in.Generation)
+	}
+	return api.state.ApplyPlan(ctx, in.Plan, desc)
+}
+
+// checkPlanFiles refuses plans that write outside the workspace.
+func (api *apiServer) checkPlanFiles(plan *types.RefactoringPlan) error {
+	api.state.RLock()
+	defer api.state.RUnlock()
+	ws, err := api.state.GetWorkspace()
+	if err != nil {
+		return err
+	}
+	files := slices.Clone(plan.AffectedFiles)
+	for _, c := range plan.Changes {
+		files = append(files, c.File)
+	}
+	for _, f := range files {
+		rel, err := filepath.Rel(ws.RootPath, f)
+		if !filepath.IsAbs(f) || err != nil || !filepath.IsLocal(rel) {
+			return fmt.Errorf("%w: it edits %s, outside the workspace %s", errInvalidPlan, f, ws.RootPath)
+		}
+	}
+	return nil
+}
+
+// analyze calls the tool of the named analyzer.
+func (api *apiServer) analyze(ctx context.Context, name string, args map[string]any) (*mcpsdk.CallToolResult, error) {
+	tool, ok := apiAnalyzers[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %q; available: %s", errUnknownAnalyzer, name,
+			strings.Join(slices.Sorted(maps.Keys(apiAnalyzers)), ", "))
+	}
+	params := &mcpsdk.CallToolParams{Name: tool, Arguments: args}
+	if id := logging.Operation(ctx); id != "" {
+		params.Meta = mcpsdk.Meta{internalmcp.OperationMetaKey: id}
+	}
+	return api.session.CallTool(ctx, params)
+}
+
+// handler serves the HTTP API.
+func (api *apiServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /workspace/packages", api.servePackages)
+	mux.HandleFunc("POST /plan/{operation}", api.servePlan)
+	mux.HandleFunc("POST /apply", api.serveApply)
+	mux.HandleFunc("GET /analyze/{analyzer}", api.serveAnalyze)
+	mux.Handle("GET /metrics", telemetry.Handler())
+	return api.logRequests(api.checkOrigin(mux))
+}
+
+// checkOrigin refuses requests a web page could have made: those addressed
+// to a host other than a loopback one or one of allowHosts, which DNS
+// rebinding would send; those a page of another origin sent; and POSTs
+// whose body isn't declared as JSON, which pages can send cross-origin
+// without asking first.
+func (api *apiServer) checkOrigin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !api.allowedHost(r.Host) {
+			writeError(w, http.StatusForbidden, fmt.Errorf("host %q not allowed; pass -allow-host to serve it", r.Host))
+			return
+		}
+		if origin := r.Header.Get("Origin"); origin != "" {
+			u, err := url.Parse(origin)
+			if err != nil || u.Host != r.Host {
+				writeError(w, http.StatusForbidden, fmt.Errorf("cross-origin request from %q not allowed", origin))
+				return
+			}
+		}
+		if r.Method == http.MethodPost {
+			mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if mediaType != "application/json" {
+				writeError(w, http.StatusUnsupportedMediaType, fmt.Errorf("content type %q not supported; send application/json", r.Header.Get("Content-Type")))
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allowedHost reports whether the HTTP API answers requests with the Host
+// header host.
+func (api *apiServer) allowedHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if strings.EqualFold(host, "localhost") || slices.ContainsFunc(api.allowHosts, func(h string) bool { return strings.EqualFold(h, host) }) {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// logRequests gives each request an operation ID, returned in the
+// X-Operation-Id header, traces it under the trace of the client, if any,
+// and logs it and records it in the metrics once served.
+func (api *apiServer) logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := logging.NewOperationID()
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx = logging.WithOperation(ctx, id)
+		ctx, span := telemetry.Tracer().Start(ctx, "HTTP "+r.Method, trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(attribute.String("http.request.method", r.Method), attribute.String("gorefactor.op_id", id)))
+		w.Header().Set("X-Operation-Id", id)
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		r = r.WithContext(ctx)
+		next.ServeHTTP(rec, r)
+
+		// The mux set the pattern the request matched.
+		route := cmp.Or(r.Pattern, "unmatched")
+		span.SetName("HTTP " + route)
+		span.SetAttributes(attribute.String("http.route", route), attribute.Int("http.response.status_code", rec.status))
+		if rec.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(rec.status))
+		}
+		span.End()
+		telemetry.ObserveAPIRequest("http", route, strconv.Itoa(rec.status), time.Since(start))
+		api.logger.InfoContext(ctx, "http request", "method", r.Method, "path", r.URL.Path, "status", rec.status, "duration", time.Since(start))
+	})
+}
+
+// statusRecorder remembers the status of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (api *apiServer) servePackages(w http.ResponseWriter, r *http.Request) {
+	pkgs, err := api.packages()
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, pkgs)
+}
+
+func (api *apiServer) servePlan(w http.ResponseWriter, r *http.Request) {
+	var request map[string]any
+	if err := readJSON(w, r, &request); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	plan, err := api.plan(r.Context(), r.PathValue("operation"), request)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, plan)
+}
+
+func (api *apiServer) serveApply(w http.ResponseWriter, r *http.Request) {
+	var in apiPlan
+	if err := readJSON(w, r, &in); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	result, err := api.apply(r.Context(), &in)
+	switch {
+	case errors.Is(err, errInvalidPlan):
+		writeError(w, http.StatusBadRequest, err)
+	case err != nil:
+		writeError(w, http.StatusConflict, err)
+	default:
+		writeJSON(w, http.StatusOK, result)
+	}
+}
+
+func (api *apiServer) serveAnalyze(w http.ResponseWriter, r *http.Request) {
+	var pairs []string
+	for name, values := range r.URL.Query() {
+		pairs = append(pairs, name+"="+values[len(values)-1])
+	}
+	args, err := parseToolArgs(pairs)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	res, err := api.analyze(r.Context(), r.PathValue("analyzer"), args)
+	switch {
+	case errors.Is(err, errUnknownAnalyzer):
+		writeError(w, http.StatusNotFound, err)
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if res.IsError {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+	_ = writeResult(w, formatJSON, res)
+}
+
+// readJSON decodes the JSON body of r into v.
+func readJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("request body: %w", err)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
+
+// writeError writes err as {"error": ..., "code": ...}, the code being the
+// stable code the error carries, if any.
+func writeError(w http.ResponseWriter, status int, err error) {
+	body := map[string]any{"error": err.Error()}
+	if code := types.CodeOf(err); code != "" {
+		body["code"] = code
+	}
+	writeJSON(w, status, body)
+}
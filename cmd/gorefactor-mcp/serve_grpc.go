@@ -0,0 +1,312 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	gorefactorv1 "github.com/mamaar/gorefactor/api/gorefactor/v1"
+	"github.com/mamaar/gorefactor/internal/logging"
+	"github.com/mamaar/gorefactor/internal/telemetry"
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// grpcServer serves the API of an apiServer as gorefactor.v1.RefactorService.
+type grpcServer struct {
+	gorefactorv1.UnimplementedRefactorServiceServer
+	api *apiServer
+}
+
+func (g *grpcServer) ListPackages(ctx context.Context, req *gorefactorv1.ListPackagesRequest) (*gorefactorv1.ListPackagesResponse, error) {
+	pkgs, err := g.api.packages()
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	resp := &gorefactorv1.ListPackagesResponse{}
+	for _, pkg := range pkgs {
+		resp.Packages = append(resp.Packages, &gorefactorv1.Package{
+			ImportPath: pkg.ImportPath,
+			Name:       pkg.Name,
+			Dir:        pkg.Dir,
+			Files:      pkg.Files,
+			TestFiles:  pkg.TestFiles,
+		})
+	}
+	return resp, nil
+}
+
+func (g *grpcServer) Plan(req *gorefactorv1.PlanRequest, stream grpc.ServerStreamingServer[gorefactorv1.PlanEvent]) error {
+	var plan *apiPlan
+	err := withProgress(g.api, stream, func(p *gorefactorv1.Progress) *gorefactorv1.PlanEvent {
+		return &gorefactorv1.PlanEvent{Event: &gorefactorv1.PlanEvent_Progress{Progress: p}}
+	}, func() (err error) {
+		plan, err = g.api.plan(stream.Context(), req.GetOperation(), req.GetRequest().AsMap())
+		return err
+	})
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return stream.Send(&gorefactorv1.PlanEvent{Event: &gorefactorv1.PlanEvent_Plan{Plan: planToProto(plan)}})
+}
+
+func (g *grpcServer) Preview(ctx context.Context, req *gorefactorv1.PlanRequest) (*gorefactorv1.PreviewResponse, error) {
+	plan, err := g.api.plan(ctx, req.GetOperation(), req.GetRequest().AsMap())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	preview, err := g.api.state.GetEngine().PreviewPlan(plan.Plan)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &gorefactorv1.PreviewResponse{Plan: planToProto(plan), Preview: preview}, nil
+}
+
+func (g *grpcServer) Apply(req *gorefactorv1.ApplyRequest, stream grpc.ServerStreamingServer[gorefactorv1.ApplyEvent]) error {
+	if req.GetPlan() == nil {
+		return status.Error(codes.InvalidArgument, "missing plan")
+	}
+	var result *gorefactorv1.ApplyResult
+	err := withProgress(g.api, stream, func(p *gorefactorv1.Progress) *gorefactorv1.ApplyEvent {
+		return &gorefactorv1.ApplyEvent{Event: &gorefactorv1.ApplyEvent_Progress{Progress: p}}
+	}, func() error {
+		applied, err := g.api.apply(stream.Context(), planFromProto(req.GetPlan()))
+		if err != nil {
+			return err
+		}
+		result = &gorefactorv1.ApplyResult{
+			ModifiedFiles: applied.ModifiedFiles,
+			ChangeCount:   int64(applied.ChangeCount),
+			Warnings:      applied.Warnings,
+			Skipped:       changesToProto(applied.Skipped),
+			Staged:        applied.Staged,
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, errInvalidPlan):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case err != nil:
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return stream.Send(&gorefactorv1.ApplyEvent{Event: &gorefactorv1.ApplyEvent_Result{Result: result}})
+}
+
+func (g *grpcServer) Analyze(ctx context.Context, req *gorefactorv1.AnalyzeRequest) (*gorefactorv1.AnalyzeResponse, error) {
+	res, err := g.api.analyze(ctx, req.GetAnalyzer(), req.GetArguments().AsMap())
+	switch {
+	case errors.Is(err, errUnknownAnalyzer):
+		return nil, status.Error(codes.NotFound, err.Error())
+	case err != nil:
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	text := resultText(res)
+	if res.IsError {
+		return nil, status.Error(codes.InvalidArgument, text)
+	}
+	var payload any
+	if err := json.Unmarshal([]byte(text), &payload); err != nil {
+		payload = text
+	}
+	value, err := structpb.NewValue(payload)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &gorefactorv1.AnalyzeResponse{Result: value}, nil
+}
+
+// withProgress runs fn, sending the engine's progress meanwhile on stream
+// as the events event makes of it.
+func withProgress[E any](api *apiServer, stream grpc.ServerStreamingServer[E], event func(*gorefactorv1.Progress) *E, fn func() error) error {
+	var mu sync.Mutex
+	stop := api.state.WatchProgress(func(e types.ProgressEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		_ = stream.Send(event(&gorefactorv1.Progress{
+			Phase:   string(e.Phase),
+			Package: e.Package,
+			Current: int64(e.Current),
+			Total:   int64(e.Total),
+			Message: e.Message,
+		}))
+	})
+	err := fn()
+	stop()
+	// Wait out a send in progress before the caller sends on stream.
+	mu.Lock()
+	defer mu.Unlock()
+	return err
+}
+
+// grpcOptions give each call an operation ID, trace it under the trace of
+// the client, if any, and log it and record it in the metrics once served.
+func (api *apiServer) grpcOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			ctx, end := api.startCall(ctx, info.FullMethod)
+			resp, err := handler(ctx, req)
+			end(err)
+			return resp, err
+		}),
+		grpc.ChainStreamInterceptor(func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			ctx, end := api.startCall(ss.Context(), info.FullMethod)
+			err := handler(srv, operationStream{ss, ctx})
+			end(err)
+			return err
+		}),
+	}
+}
+
+// startCall starts serving the gRPC call of method, which end finishes.
+func (api *apiServer) startCall(ctx context.Context, method string) (_ context.Context, end func(error)) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+	id := logging.NewOperationID()
+	ctx = logging.WithOperation(ctx, id)
+	ctx, span := telemetry.Tracer().Start(ctx, method, trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attribute.String("rpc.method", method), attribute.String("gorefactor.op_id", id)))
+	start := time.Now()
+	return ctx, func(err error) {
+		code := status.Code(err)
+		span.SetAttributes(attribute.String("rpc.grpc.status_code", code.String()))
+		telemetry.End(span, err)
+		telemetry.ObserveAPIRequest("grpc", method, code.String(), time.Since(start))
+		api.logger.InfoContext(ctx, "grpc call", "method", method, "code", code, "duration", time.Since(start))
+	}
+}
+
+// operationStream is a server stream whose context carries an operation ID
+// and the span of the call.
+type operationStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s operationStream) Context() context.Context { return s.ctx }
+
+// metadataCarrier reads the trace context a client sent in the metadata of
+// a call.
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	if vals := metadata.MD(c).Get(key); len(vals) > 0 {
+		return vals[0]
+	}
+	return ""
+}
+
+func (c metadataCarrier) Set(key, value string) { metadata.MD(c).Set(key, value) }
+
+func (c metadataCarrier) Keys() []string { return slices.Collect(maps.Keys(c)) }
+
+// resultText returns the text content of a tool result.
+func resultText(res *mcpsdk.CallToolResult) string {
+	var texts []string
+	for _, c := range res.Content {
+		if t, ok := c.(*mcpsdk.TextContent); ok {
+			texts = append(texts, t.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+func confidenceToProto(c types.Confidence) gorefactorv1.Confidence {
+	switch c {
+	case types.ConfidenceLikely:
+		return gorefactorv1.Confidence_CONFIDENCE_LIKELY
+	case types.ConfidenceHeuristic:
+		return gorefactorv1.Confidence_CONFIDENCE_HEURISTIC
+	}
+	return gorefactorv1.Confidence_CONFIDENCE_CERTAIN
+}
+
+func confidenceFromProto(c gorefactorv1.Confidence) types.Confidence {
+	switch c {
+	case gorefactorv1.Confidence_CONFIDENCE_LIKELY:
+		return types.ConfidenceLikely
+	case gorefactorv1.Confidence_CONFIDENCE_HEURISTIC:
+		return types.ConfidenceHeuristic
+	}
+	return types.ConfidenceCertain
+}
+
+func severityToProto(s types.IssueSeverity) gorefactorv1.Severity {
+	switch s {
+	case types.Error:
+		return gorefactorv1.Severity_SEVERITY_ERROR
+	case types.Warning:
+		return gorefactorv1.Severity_SEVERITY_WARNING
+	case types.Info:
+		return gorefactorv1.Severity_SEVERITY_INFO
+	}
+	return gorefactorv1.Severity_SEVERITY_UNSPECIFIED
+}
+
+func planToProto(p *apiPlan) *gorefactorv1.Plan {
+	out := &gorefactorv1.Plan{
+		Operation:     p.Operation,
+		PlanHash:      p.PlanHash,
+		Changes:       changesToProto(p.Plan.Changes),
+		AffectedFiles: p.Plan.AffectedFiles,
+		Reversible:    p.Plan.Reversible,
+	}
+	if p.Plan.Impact != nil {
+		for _, issue := range p.Plan.Impact.PotentialIssues {
+			out.Issues = append(out.Issues, &gorefactorv1.Issue{
+				Description: issue.Description,
+				File:        issue.File,
+				Line:        int64(issue.Line),
+				Severity:    severityToProto(issue.Severity),
+			})
+		}
+	}
+	return out
+}
+
+// planFromProto converts a plan returned by Plan or Preview back for apply.
+// Issues aren't needed to apply it and are left out.
+func planFromProto(p *gorefactorv1.Plan) *apiPlan {
+	plan := &types.RefactoringPlan{AffectedFiles: p.GetAffectedFiles(), Reversible: p.GetReversible()}
+	for _, c := range p.GetChanges() {
+		plan.Changes = append(plan.Changes, types.Change{
+			File:        c.GetFile(),
+			Start:       int(c.GetStart()),
+			End:         int(c.GetEnd()),
+			OldText:     c.GetOldText(),
+			NewText:     c.GetNewText(),
+			Description: c.GetDescription(),
+			Confidence:  confidenceFromProto(c.GetConfidence()),
+		})
+	}
+	return &apiPlan{Operation: p.GetOperation(), PlanHash: p.GetPlanHash(), Plan: plan}
+}
+
+func changesToProto(changes []types.Change) []*gorefactorv1.Change {
+	var out []*gorefactorv1.Change
+	for _, c := range changes {
+		out = append(out, &gorefactorv1.Change{
+			File:        c.File,
+			Start:       int64(c.Start),
+			End:         int64(c.End),
+			OldText:     c.OldText,
+			NewText:     c.NewText,
+			Description: c.Description,
+			Confidence:  confidenceToProto(c.Confidence),
+		})
+	}
+	return out
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	gorefactorv1 "github.com/mamaar/gorefactor/api/gorefactor/v1"
+)
+
+func TestServeGRPC(t *testing.T) {
+	dir := writeWorkspace(t)
+	ctx := context.Background()
+	api, err := newAPIServer(ctx, io.Discard, slog.New(slog.NewTextHandler(io.Discard, nil)), map[string]any{"path": dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer api.close()
+
+	ln := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	gorefactorv1.RegisterRefactorServiceServer(srv, &grpcServer{api: api})
+	go srv.Serve(ln)
+	defer srv.Stop()
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return ln.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := gorefactorv1.NewRefactorServiceClient(conn)
+
+	pkgs, err := client.ListPackages(ctx, &gorefactorv1.ListPackagesRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pkgs.GetPackages()) != 1 || pkgs.GetPackages()[0].GetImportPath() != "example.com/calc" {
+		t.Errorf("packages = %v", pkgs.GetPackages())
+	}
+
+	args, _ := structpb.NewStruct(map[string]any{"min_complexity": 1})
+	analysis, err := client.Analyze(ctx, &gorefactorv1.AnalyzeRequest{Analyzer: "complexity", Arguments: args})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count := analysis.GetResult().GetStructValue().GetFields()["count"].GetNumberValue(); count != 2 {
+		t.Errorf("complexity count = %v, want 2", count)
+	}
+	if _, err := client.Analyze(ctx, &gorefactorv1.AnalyzeRequest{Analyzer: "missing"}); status.Code(err) != codes.NotFound {
+		t.Errorf("unknown analyzer: %v, want NotFound", err)
+	}
+
+	plan := func(symbol string) (*gorefactorv1.Plan, error) {
+		request, _ := structpb.NewStruct(map[string]any{"symbol_name": symbol, "new_name": "Sum"})
+		stream, err := client.Plan(ctx, &gorefactorv1.PlanRequest{Operation: "rename", Request: request})
+		if err != nil {
+			return nil, err
+		}
+		for {
+			event, err := stream.Recv()
+			if err != nil {
+				return nil, err
+			}
+			if p := event.GetPlan(); p != nil {
+				return p, nil
+			}
+		}
+	}
+	if _, err := plan("Missing"); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("planning an unknown symbol: %v, want InvalidArgument", err)
+	}
+	p, err := plan("Add")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.GetChanges()) == 0 || p.GetChanges()[0].GetConfidence() != gorefactorv1.Confidence_CONFIDENCE_CERTAIN {
+		t.Errorf("plan changes = %v", p.GetChanges())
+	}
+
+	apply := func(p *gorefactorv1.Plan) (*gorefactorv1.ApplyResult, error) {
+		stream, err := client.Apply(ctx, &gorefactorv1.ApplyRequest{Plan: p})
+		if err != nil {
+			return nil, err
+		}
+		for {
+			event, err := stream.Recv()
+			if err != nil {
+				return nil, err
+			}
+			if r := event.GetResult(); r != nil {
+				return r, nil
+			}
+		}
+	}
+	tampered := &gorefactorv1.Plan{Operation: p.GetOperation(), PlanHash: "0", Changes: p.GetChanges()}
+	if _, err := apply(tampered); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("applying a tampered plan: %v, want InvalidArgument", err)
+	}
+	result, err := apply(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.GetChangeCount() != int64(len(p.GetChanges())) {
+		t.Errorf("change count = %d, want %d", result.GetChangeCount(), len(p.GetChanges()))
+	}
+	if src, _ := os.ReadFile(filepath.Join(dir, "main.go")); !bytes.Contains(src, []byte("func Sum(")) {
+		t.Errorf("apply didn't rename Add:\n%s", src)
+	}
+	if _, err := apply(p); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("applying a stale plan: %v, want FailedPrecondition", err)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestServe(t *testing.T) {
+	dir := writeWorkspace(t)
+	api, err := newAPIServer(context.Background(), io.Discard, slog.New(slog.NewTextHandler(io.Discard, nil)), map[string]any{"path": dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer api.close()
+	srv := httptest.NewServer(api.handler())
+	defer srv.Close()
+
+	do := func(method, path, body string, wantStatus int) []byte {
+		t.Helper()
+		req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if method == "POST" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != wantStatus {
+			t.Fatalf("%s %s: status %d, want %d:\n%s", method, path, resp.StatusCode, wantStatus, data)
+		}
+		return data
+	}
+
+	var pkgs []apiPackage
+	if err := json.Unmarshal(do("GET", "/workspace/packages", "", http.StatusOK), &pkgs); err != nil {
+		t.Fatal(err)
+	}
+	if len(pkgs) != 1 || pkgs[0].ImportPath != "example.com/calc" || pkgs[0].Files[0] != "main.go" {
+		t.Errorf("packages = %+v", pkgs)
+	}
+
+	var complexity struct {
+		Count int `json:"count"`
+	}
+	if err := json.Unmarshal(do("GET", "/analyze/complexity?min_complexity=1", "", http.StatusOK), &complexity); err != nil {
+		t.Fatal(err)
+	}
+	if complexity.Count != 2 {
+		t.Errorf("complexity count = %d, want 2", complexity.Count)
+	}
+	do("GET", "/analyze/missing", "", http.StatusNotFound)
+
+	plan := do("POST", "/plan/rename", `{"symbol_name":"Add","new_name":"Sum"}`, http.StatusOK)
+	rival := do("POST", "/plan/rename", `{"symbol_name":"Add","new_name":"Plus"}`, http.StatusOK)
+	do("POST", "/plan/rename", `{"symbol_name":"Missing","new_name":"Sum"}`, http.StatusUnprocessableEntity)
+	if src, _ := os.ReadFile(filepath.Join(dir, "main.go")); bytes.Contains(src, []byte("Sum")) {
+		t.Fatalf("planning wrote main.go:\n%s", src)
+	}
+
+	tampered := bytes.Replace(plan, []byte(`"new_text": "Sum"`), []byte(`"new_text": "Total"`), 1)
+	do("POST", "/apply", string(tampered), http.StatusBadRequest)
+	do("POST", "/apply", string(plan), http.StatusOK)
+	if src, _ := os.ReadFile(filepath.Join(dir, "main.go")); !bytes.Contains(src, []byte("func Sum(")) {
+		t.Errorf("apply didn't rename Add:\n%s", src)
+	}
+	// The files changed since the plan was made.
+	do("POST", "/apply", string(plan), http.StatusConflict)
+	if body := do("POST", "/apply", string(rival), http.StatusConflict); !bytes.Contains(body, []byte(`"GR3002"`)) {
+		t.Errorf("expected a plan made before the other was applied to be stale, got %s", body)
+	}
+	// Follow-up plans see the renamed function.
+	do("POST", "/plan/rename", `{"symbol_name":"Add","new_name":"Total"}`, http.StatusUnprocessableEntity)
+	do("POST", "/plan/rename", `{"symbol_name":"Sum","new_name":"Total"}`, http.StatusOK)
+}
+
+func TestServe_RefusesBrowserRequests(t *testing.T) {
+	dir := writeWorkspace(t)
+	api, err := newAPIServer(context.Background(), io.Discard, slog.New(slog.NewTextHandler(io.Discard, nil)), map[string]any{"path": dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer api.close()
+	api.allowHosts = []string{"refactor.internal"}
+	srv := httptest.NewServer(api.handler())
+	defer srv.Close()
+
+	rename := `{"symbol_name":"Add","new_name":"Sum"}`
+	for _, tt := range []struct {
+		name        string
+		method      string
+		path        string
+		host        string
+		origin      string
+		contentType string
+		want        int
+	}{
+		{"loopback", "GET", "/workspace/packages", "", "", "", http.StatusOK},
+		{"localhost", "GET", "/workspace/packages", "localhost:8080", "", "", http.StatusOK},
+		{"allowed host", "GET", "/workspace/packages", "refactor.internal", "", "", http.StatusOK},
+		{"rebound host", "GET", "/workspace/packages", "attacker.example:8080", "", "", http.StatusForbidden},
+		{"same origin", "POST", "/plan/rename", "", "http://{host}", "application/json; charset=utf-8", http.StatusOK},
+		{"cross origin", "POST", "/plan/rename", "", "http://attacker.example", "application/json", http.StatusForbidden},
+		{"null origin", "POST", "/plan/rename", "", "null", "application/json", http.StatusForbidden},
+		{"text body", "POST", "/plan/rename", "", "", "text/plain", http.StatusUnsupportedMediaType},
+		{"form body", "POST", "/apply", "", "", "application/x-www-form-urlencoded", http.StatusUnsupportedMediaType},
+		{"no content type", "POST", "/apply", "", "", "", http.StatusUnsupportedMediaType},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, srv.URL+tt.path, strings.NewReader(rename))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.host != "" {
+				req.Host = tt.host
+			}
+			if tt.origin != "" {
+				req.Header.Set("Origin", strings.ReplaceAll(tt.origin, "{host}", req.URL.Host))
+			}
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			data, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.want {
+				t.Errorf("status %d, want %d:\n%s", resp.StatusCode, tt.want, data)
+			}
+		})
+	}
+	if src, _ := os.ReadFile(filepath.Join(dir, "main.go")); bytes.Contains(src, []byte("Sum")) {
+		t.Errorf("a refused request wrote main.go:\n%s", src)
+	}
+}
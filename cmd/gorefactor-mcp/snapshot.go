@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// snapshotHeader starts every plan snapshot.
+const snapshotHeader = "# gorefactor plan snapshot"
+
+// writeSnapshot writes the golden snapshot of a plan: the inputs it was
+// planned from, then every change, by file and position, with its offsets,
+// confidence and the lines it rewrites. File names are relative to the
+// workspace root, so snapshots recorded in one checkout verify in another.
+func writeSnapshot(w io.Writer, inputs string, plan *types.RefactoringPlan, skipped int, root string) error {
+	r := newPlanReview(plan, root)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s\n# inputs: %s\n", snapshotHeader, inputs)
+	if skipped > 0 {
+		fmt.Fprintf(&sb, "# left out: %d changes below the minimum confidence\n", skipped)
+	}
+	fmt.Fprintf(&sb, "%d changes in %d files\n", len(plan.Changes), len(r.files))
+	for f := range r.files {
+		for n, i := range r.changes[f] {
+			c := plan.Changes[i]
+			confidence := c.Confidence
+			if confidence == "" {
+				confidence = types.ConfidenceCertain
+			}
+			fmt.Fprintf(&sb, "\n## %d.%d bytes %d-%d %s\n", f+1, n+1, c.Start, c.End, confidence)
+			r.diff(&sb, c)
+		}
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// recordSnapshot writes the snapshot of a plan to path.
+func recordSnapshot(path, inputs string, plan *types.RefactoringPlan, skipped int, root string) error {
+	var buf bytes.Buffer
+	if err := writeSnapshot(&buf, inputs, plan, skipped, root); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
+
+// verifySnapshot compares the snapshot of a plan with the one recorded at
+// path, returning an error that shows the first line they differ at.
+func verifySnapshot(path, inputs string, plan *types.RefactoringPlan, skipped int, root string) error {
+	recorded, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read snapshot: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := writeSnapshot(&buf, inputs, plan, skipped, root); err != nil {
+		return err
+	}
+	if bytes.Equal(recorded, buf.Bytes()) {
+		return nil
+	}
+	want, got := strings.Split(string(recorded), "\n"), strings.Split(buf.String(), "\n")
+	line := 0
+	for line < len(want) && line < len(got) && want[line] == got[line] {
+		line++
+	}
+	return fmt.Errorf("the plan differs from %s at line %d:\n  recorded: %s\n  planned:  %s\nrecord it again with -record if the new plan is right",
+		path, line+1, lineAt(want, line), lineAt(got, line))
+}
+
+// lineAt returns lines[i] quoted, or a note that there is no such line.
+func lineAt(lines []string, i int) string {
+	if i >= len(lines) {
+		return "(end of snapshot)"
+	}
+	return fmt.Sprintf("%q", lines[i])
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunPlan_RecordVerify(t *testing.T) {
+	dir := writeWorkspace(t)
+	snap := filepath.Join(t.TempDir(), "rename.snap")
+	plan := func(flag string) error {
+		return runPlan(context.Background(), io.Discard, []string{"-workspace", dir, flag, snap, "rename_symbol", "symbol_name=Add", "new_name=Sum"})
+	}
+
+	if err := plan("-record"); err != nil {
+		t.Fatal(err)
+	}
+	recorded, err := os.ReadFile(snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# inputs: rename_symbol new_name=Sum symbol_name=Add\n2 changes in 1 files\n",
+		"## 1.1 bytes 19-22 certain\n--- main.go:3 ",
+		"-func Add(a, b int) int {\n+func Sum(a, b int) int {\n",
+		"-\t_ = Add(1, 2)\n+\t_ = Sum(1, 2)\n",
+	} {
+		if !strings.Contains(string(recorded), want) {
+			t.Errorf("snapshot lacks %q:\n%s", want, recorded)
+		}
+	}
+	if src, _ := os.ReadFile(filepath.Join(dir, "main.go")); bytes.Contains(src, []byte("Sum")) {
+		t.Errorf("recording applied the plan:\n%s", src)
+	}
+
+	if err := plan("-verify"); err != nil {
+		t.Errorf("verifying an unchanged workspace: %v", err)
+	}
+
+	src, _ := os.ReadFile(filepath.Join(dir, "main.go"))
+	src = bytes.Replace(src, []byte("_ = Add(1, 2)"), []byte("_ = Add(1, 2) + Add(3, 4)"), 1)
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), src, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err = plan("-verify")
+	if err == nil || !strings.Contains(err.Error(), `recorded: "2 changes in 1 files"`) {
+		t.Errorf("got %v, want the plan to differ from the snapshot", err)
+	}
+}
+
+func TestRunPlan_SnapshotFlagsConflict(t *testing.T) {
+	dir := writeWorkspace(t)
+	err := runPlan(context.Background(), io.Discard, []string{"-workspace", dir, "-record", "a.snap", "-verify", "a.snap", "rename_symbol", "symbol_name=Add", "new_name=Sum"})
+	if err == nil || !strings.Contains(err.Error(), "can't be combined") {
+		t.Errorf("got %v, want an error about combining -record and -verify", err)
+	}
+}
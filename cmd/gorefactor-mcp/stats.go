@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mamaar/gorefactor/pkg/analysis"
+)
+
+const statsUsage = `usage: gorefactor-mcp stats -memory [flags]
+
+Reports what holding a workspace costs. With -memory it prints, per package
+and largest first, the file contents on the heap and memory-mapped, the
+syntax tree nodes, and estimated syntax tree and type information bytes,
+then the totals and the process's heap after loading. -scope and
+-map-sources load the workspace as the load_workspace options of the same
+names do, to compare what they save:
+
+  gorefactor-mcp stats -memory -top 10 -scope internal/billing/... -map-sources
+
+Flags:
+`
+
+// runStats implements the stats subcommand on top of the memory_stats tool.
+func runStats(ctx context.Context, stdout io.Writer, args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), statsUsage)
+		fs.PrintDefaults()
+	}
+	workspace := fs.String("workspace", ".", "workspace root (go.mod directory)")
+	format := fs.String("format", formatText, "output format: text or json")
+	memory := fs.Bool("memory", false, "report the memory each package holds")
+	pkg := fs.String("package", "", "only report this package")
+	top := fs.Int("top", 0, "only report this many packages, largest first (default: all)")
+	scope := fs.String("scope", "", "comma-separated package directories to limit the workspace to, e.g. internal/billing/...")
+	mapSources := fs.Bool("map-sources", false, "keep file contents in memory-mapped files")
+	logFlags := registerCommandFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != formatText && *format != formatJSON {
+		return fmt.Errorf("unknown format %q: want %s or %s", *format, formatText, formatJSON)
+	}
+	if !*memory {
+		fs.Usage()
+		return fmt.Errorf("nothing to report: pass -memory")
+	}
+	toolArgs := map[string]any{}
+	if *pkg != "" {
+		toolArgs["package"] = *pkg
+	}
+	if *top > 0 {
+		toolArgs["top"] = *top
+	}
+	opts := runOptions{workspace: *workspace, format: formatJSON, mapSources: *mapSources, log: *logFlags}
+	if *scope != "" {
+		opts.scope = strings.Split(*scope, ",")
+	}
+
+	var buf bytes.Buffer
+	if err := invoke(ctx, &buf, opts, "memory_stats", toolArgs); err != nil {
+		_, _ = stdout.Write(buf.Bytes())
+		return err
+	}
+	if *format == formatJSON {
+		_, err := stdout.Write(buf.Bytes())
+		return err
+	}
+	var report struct {
+		HeapAlloc      uint64                   `json:"heap_alloc"`
+		SourceBytes    int64                    `json:"source_bytes"`
+		MappedBytes    int64                    `json:"mapped_bytes"`
+		PrunedPackages int                      `json:"pruned_packages"`
+		Packages       []analysis.PackageMemory `json:"packages"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		return err
+	}
+	return writeMemoryReport(stdout, report.Packages, report.HeapAlloc, report.SourceBytes, report.MappedBytes, report.PrunedPackages)
+}
+
+// writeMemoryReport prints the packages as a table, then the totals.
+func writeMemoryReport(w io.Writer, packages []analysis.PackageMemory, heap uint64, source, mapped int64, pruned int) error {
+	var sb bytes.Buffer
+	tw := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "HEAP\tSOURCE\tMAPPED\tAST NODES\tAST\tTYPES\tFILES\t PACKAGE\t")
+	for _, p := range packages {
+		name := p.Package
+		if p.Pruned {
+			name += " (pruned)"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s\t%d\t %s\t\n", formatSize(p.HeapBytes), formatSize(p.SourceBytes), formatSize(p.MappedBytes),
+			p.ASTNodes, formatSize(p.ASTBytes), formatSize(p.TypesBytes), p.Files, name)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(&sb, "\nsources: %s on the heap, %s mapped; %d packages pruned; process heap: %s\n",
+		formatSize(source), formatSize(mapped), pruned, formatSize(int64(heap)))
+	_, err := w.Write(sb.Bytes())
+	return err
+}
+
+// formatSize renders a byte count with a binary unit.
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
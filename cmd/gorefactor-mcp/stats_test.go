@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRunStats_Memory(t *testing.T) {
+	dir := writeWorkspace(t)
+
+	var out bytes.Buffer
+	if err := runStats(context.Background(), &out, []string{"-workspace", dir, "-memory"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"AST NODES", "example.com/calc", "process heap:"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("report lacks %q:\n%s", want, out.String())
+		}
+	}
+
+	out.Reset()
+	if err := runStats(context.Background(), &out, []string{"-workspace", dir, "-memory", "-format", "json", "-map-sources"}); err != nil {
+		t.Fatal(err)
+	}
+	var report struct {
+		Packages []struct {
+			Package     string `json:"package"`
+			MappedBytes int64  `json:"mapped_bytes"`
+			ASTNodes    int    `json:"ast_nodes"`
+		} `json:"packages"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+		t.Fatalf("%v:\n%s", err, out.String())
+	}
+	if len(report.Packages) != 1 || report.Packages[0].Package != "example.com/calc" || report.Packages[0].ASTNodes == 0 {
+		t.Errorf("packages = %+v", report.Packages)
+	}
+}
+
+func TestRunStats_NothingToReport(t *testing.T) {
+	dir := writeWorkspace(t)
+	err := runStats(context.Background(), io.Discard, []string{"-workspace", dir})
+	if err == nil || !strings.Contains(err.Error(), "-memory") {
+		t.Errorf("got %v, want an error asking for -memory", err)
+	}
+}
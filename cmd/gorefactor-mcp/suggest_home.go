@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+const suggestHomeUsage = `usage: gorefactor-mcp suggest-home [flags] -symbol <name>
+
+Ranks the packages a symbol could live in by where it is referenced from,
+the imports a move would add or cycle through, and the layers of
+.gorefactor.yaml, and prints each candidate's score and reasons. When a
+better home than the current one exists, the move_symbol arguments moving
+the symbol there are printed too.
+
+Example:
+  gorefactor-mcp suggest-home -symbol ParseConfig
+  gorefactor-mcp suggest-home -symbol Store -package internal/legacy
+
+Flags:
+`
+
+// runSuggestHome implements the suggest-home subcommand on top of the
+// suggest_home tool.
+func runSuggestHome(ctx context.Context, stdout io.Writer, args []string) error {
+	fs := flag.NewFlagSet("suggest-home", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), suggestHomeUsage)
+		fs.PrintDefaults()
+	}
+	workspace := fs.String("workspace", ".", "workspace root (go.mod directory)")
+	format := fs.String("format", formatText, "output format: text or json (the MCP tool payload)")
+	symbol := fs.String("symbol", "", "symbol to find a home for")
+	pkg := fs.String("package", "", "package declaring the symbol; default: the only package declaring it")
+	logFlags := registerCommandFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != formatText && *format != formatJSON {
+		return fmt.Errorf("unknown format %q: want %s or %s", *format, formatText, formatJSON)
+	}
+	if *symbol == "" || fs.NArg() > 0 {
+		fs.Usage()
+		return fmt.Errorf("expected -symbol and no arguments")
+	}
+	toolArgs := map[string]any{"symbol": *symbol}
+	if *pkg != "" {
+		toolArgs["package"] = *pkg
+	}
+	opts := runOptions{workspace: *workspace, format: *format, log: *logFlags}
+	return invoke(ctx, stdout, opts, "suggest_home", toolArgs)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/mamaar/gorefactor/pkg/analysis"
+)
+
+const validateUsage = `usage: gorefactor-mcp validate [flags]
+
+Checks that the packages of a workspace build and prints a diagnostic for
+every syntax error, type error, import that resolves to no package and file
+whose package clause disagrees with its directory:
+
+  internal/store/store.go:12:9: type: undefined: Recrod
+  internal/store/store.go:3:8: import: could not import example.com/gone: no such package
+
+Test files are checked too. Exits with status 1 when there is an error, for
+pre-commit hooks and CI; warnings, for packages that couldn't be checked,
+don't fail.
+
+Flags:
+`
+
+// runValidate implements the validate subcommand on top of the
+// validate_workspace tool.
+func runValidate(ctx context.Context, stdout io.Writer, args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), validateUsage)
+		fs.PrintDefaults()
+	}
+	workspace := fs.String("workspace", ".", "workspace root (go.mod directory)")
+	format := fs.String("format", formatText, "output format: text or json")
+	pkg := fs.String("package", "", "only report this package")
+	logFlags := registerCommandFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != formatText && *format != formatJSON {
+		return fmt.Errorf("unknown format %q: want %s or %s", *format, formatText, formatJSON)
+	}
+	toolArgs := map[string]any{}
+	if *pkg != "" {
+		toolArgs["package"] = *pkg
+	}
+
+	var buf bytes.Buffer
+	if err := invoke(ctx, &buf, runOptions{workspace: *workspace, format: formatJSON, log: *logFlags}, "validate_workspace", toolArgs); err != nil {
+		// Tool errors are reported as JSON; show them as the tool wrote them.
+		_, _ = stdout.Write(buf.Bytes())
+		return err
+	}
+	var report struct {
+		Diagnostics []*analysis.WorkspaceDiagnostic `json:"diagnostics"`
+		ErrorCount  int                             `json:"error_count"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		return err
+	}
+
+	var err error
+	if *format == formatJSON {
+		_, err = stdout.Write(buf.Bytes())
+	} else {
+		err = writeDiagnostics(stdout, *workspace, report.Diagnostics)
+	}
+	if err == nil && report.ErrorCount > 0 {
+		err = errCheckFailed
+	}
+	return err
+}
+
+// writeDiagnostics prints diagnostics as file:line:column diagnostics,
+// relative to the workspace root.
+func writeDiagnostics(w io.Writer, workspace string, diags []*analysis.WorkspaceDiagnostic) error {
+	root, err := filepath.Abs(workspace)
+	if err != nil {
+		return err
+	}
+	var sb bytes.Buffer
+	for _, d := range diags {
+		file := d.File
+		if rel, err := filepath.Rel(root, file); err == nil {
+			file = rel
+		}
+		severity := ""
+		if d.Severity != analysis.SeverityError {
+			severity = d.Severity + ": "
+		}
+		fmt.Fprintf(&sb, "%s:%d:%d: %s%s: %s\n", file, d.Line, d.Column, severity, d.Kind, d.Message)
+	}
+	_, err = w.Write(sb.Bytes())
+	return err
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunValidate(t *testing.T) {
+	dir := writeWorkspace(t)
+	var out bytes.Buffer
+	if err := runValidate(context.Background(), &out, []string{"-workspace", dir}); err != nil {
+		t.Fatalf("runValidate: %v\n%s", err, out.String())
+	}
+	if out.Len() != 0 {
+		t.Errorf("got diagnostics for a workspace that builds:\n%s", out.String())
+	}
+
+	broken := "package main\n\nfunc Sub(a, b int) int {\n\treturn a - c\n}\n"
+	if err := os.WriteFile(filepath.Join(dir, "sub.go"), []byte(broken), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	err := runValidate(context.Background(), &out, []string{"-workspace", dir})
+	if !errors.Is(err, errCheckFailed) {
+		t.Errorf("got %v, want errCheckFailed", err)
+	}
+	if want := "sub.go:4:13: type: undefined: c"; !strings.Contains(out.String(), want) {
+		t.Errorf("output lacks %q:\n%s", want, out.String())
+	}
+}
@@ -0,0 +1,125 @@
+// Package benchcmp compares two sets of `go test -bench` results and reports
+// benchmarks whose cost regressed beyond a threshold.
+package benchcmp
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Metric identifies a per-op measurement reported by the testing package.
+type Metric string
+
+const (
+	NsPerOp     Metric = "ns/op"
+	BytesPerOp  Metric = "B/op"
+	AllocsPerOp Metric = "allocs/op"
+)
+
+// Results maps a benchmark name (without the -GOMAXPROCS suffix) to the
+// samples collected for each metric. Running with -count=N yields N samples.
+type Results map[string]map[Metric][]float64
+
+// Regression describes a benchmark metric that got worse than allowed.
+type Regression struct {
+	Name    string
+	Metric  Metric
+	Old     float64
+	New     float64
+	Percent float64 // Relative change, e.g. 12.5 for +12.5%
+}
+
+func (r Regression) String() string {
+	return fmt.Sprintf("%s %s: %.0f -> %.0f (%+.1f%%)", r.Name, r.Metric, r.Old, r.New, r.Percent)
+}
+
+var procSuffix = regexp.MustCompile(`-\d+$`)
+
+// Parse reads benchmark output and collects the samples of every benchmark line.
+// Non-benchmark lines (goos, pkg, PASS, ...) are ignored.
+func Parse(r io.Reader) (Results, error) {
+	results := make(Results)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") {
+			continue
+		}
+		if _, err := strconv.Atoi(fields[1]); err != nil {
+			continue
+		}
+		name := procSuffix.ReplaceAllString(fields[0], "")
+		for i := 2; i+1 < len(fields); i += 2 {
+			value, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("benchmark %s: invalid value %q: %w", name, fields[i], err)
+			}
+			metric := Metric(fields[i+1])
+			if results[name] == nil {
+				results[name] = make(map[Metric][]float64)
+			}
+			results[name][metric] = append(results[name][metric], value)
+		}
+	}
+	return results, scanner.Err()
+}
+
+// Compare reports every metric whose median increased by more than threshold
+// percent between old and new. Benchmarks missing from either side are skipped.
+// The result is sorted by benchmark name and metric.
+func Compare(old, new Results, threshold float64, metrics ...Metric) []Regression {
+	if len(metrics) == 0 {
+		metrics = []Metric{NsPerOp, BytesPerOp, AllocsPerOp}
+	}
+	var regressions []Regression
+	for name, newMetrics := range new {
+		oldMetrics, ok := old[name]
+		if !ok {
+			continue
+		}
+		for _, metric := range metrics {
+			oldSamples, newSamples := oldMetrics[metric], newMetrics[metric]
+			if len(oldSamples) == 0 || len(newSamples) == 0 {
+				continue
+			}
+			o, n := median(oldSamples), median(newSamples)
+			var pct float64
+			switch {
+			case o == 0 && n == 0:
+				continue
+			case o == 0:
+				// Any growth from zero (e.g. a zero-alloc path that now allocates)
+				// is a regression regardless of the threshold.
+				pct = 100
+			default:
+				pct = (n - o) / o * 100
+			}
+			if pct > threshold {
+				regressions = append(regressions, Regression{Name: name, Metric: metric, Old: o, New: n, Percent: pct})
+			}
+		}
+	}
+	sort.Slice(regressions, func(i, j int) bool {
+		if regressions[i].Name != regressions[j].Name {
+			return regressions[i].Name < regressions[j].Name
+		}
+		return regressions[i].Metric < regressions[j].Metric
+	})
+	return regressions
+}
+
+func median(samples []float64) float64 {
+	s := slices.Clone(samples)
+	slices.Sort(s)
+	mid := len(s) / 2
+	if len(s)%2 == 0 {
+		return (s[mid-1] + s[mid]) / 2
+	}
+	return s[mid]
+}
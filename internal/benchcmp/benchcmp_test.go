@@ -0,0 +1,67 @@
+package benchcmp_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mamaar/gorefactor/internal/benchcmp"
+)
+
+const oldOutput = `goos: linux
+pkg: github.com/mamaar/gorefactor/pkg/analysis
+BenchmarkBuildReferenceIndex/Typed_10_pkgs-8   	    1000	    100000 ns/op	   5000 B/op	     50 allocs/op
+BenchmarkBuildReferenceIndex/Typed_10_pkgs-8   	    1000	    110000 ns/op	   5000 B/op	     50 allocs/op
+BenchmarkBuildReferenceIndex/Typed_10_pkgs-8   	    1000	    105000 ns/op	   5000 B/op	     50 allocs/op
+BenchmarkFindReferencesIndexed_ObjectPath-8    	  500000	      2000 ns/op	      0 B/op	      0 allocs/op
+PASS
+`
+
+const newOutput = `BenchmarkBuildReferenceIndex/Typed_10_pkgs-8   	    1000	    130000 ns/op	   5100 B/op	     50 allocs/op
+BenchmarkBuildReferenceIndex/Typed_10_pkgs-8   	    1000	    125000 ns/op	   5100 B/op	     50 allocs/op
+BenchmarkBuildReferenceIndex/Typed_10_pkgs-8   	    1000	    120000 ns/op	   5100 B/op	     50 allocs/op
+BenchmarkFindReferencesIndexed_ObjectPath-8    	  500000	      2010 ns/op	     16 B/op	      1 allocs/op
+BenchmarkParseWorkspace/10_pkgs-8              	     100	    400000 ns/op
+`
+
+func TestParse(t *testing.T) {
+	results, err := benchcmp.Parse(strings.NewReader(oldOutput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples := results["BenchmarkBuildReferenceIndex/Typed_10_pkgs"][benchcmp.NsPerOp]
+	if len(samples) != 3 {
+		t.Fatalf("expected 3 ns/op samples, got %v", samples)
+	}
+	if _, ok := results["BenchmarkFindReferencesIndexed_ObjectPath"][benchcmp.AllocsPerOp]; !ok {
+		t.Error("expected allocs/op to be parsed")
+	}
+}
+
+func TestCompare(t *testing.T) {
+	old, err := benchcmp.Parse(strings.NewReader(oldOutput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cur, err := benchcmp.Parse(strings.NewReader(newOutput))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	regressions := benchcmp.Compare(old, cur, 10)
+	var got []string
+	for _, r := range regressions {
+		got = append(got, r.Name+" "+string(r.Metric))
+	}
+	want := []string{
+		"BenchmarkBuildReferenceIndex/Typed_10_pkgs ns/op",
+		"BenchmarkFindReferencesIndexed_ObjectPath B/op",
+		"BenchmarkFindReferencesIndexed_ObjectPath allocs/op",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("regressions:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	if r := benchcmp.Compare(old, cur, 25, benchcmp.NsPerOp); len(r) != 0 {
+		t.Errorf("expected no ns/op regressions above 25%%, got %v", r)
+	}
+}
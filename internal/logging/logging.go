@@ -0,0 +1,124 @@
+// Package logging sets up the slog loggers of the commands from their
+// -log-level, -log-format and -log-file flags, and tags log lines with the ID
+// of the operation they were logged for.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// Log formats of the -log-format flag.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Flags are the logging flags of a command. The values they hold when
+// registered are the flags' defaults.
+type Flags struct {
+	Level  slog.Level
+	Format string // FormatText or FormatJSON; empty is text
+	File   string // Empty or "-" is stderr
+}
+
+// Register defines -log-level, -log-format and -log-file on fs.
+func (f *Flags) Register(fs *flag.FlagSet) {
+	if f.Format == "" {
+		f.Format = FormatText
+	}
+	fs.TextVar(&f.Level, "log-level", f.Level, "minimum level to log: debug, info, warn or error")
+	fs.StringVar(&f.Format, "log-format", f.Format, "log format: text or json")
+	fs.StringVar(&f.File, "log-file", f.File, "append logs to this file; - for stderr")
+}
+
+// New returns the logger the flags configure, and a function closing its
+// log file.
+func (f *Flags) New() (logger *slog.Logger, closeLog func() error, err error) {
+	var w io.Writer = os.Stderr
+	closeLog = func() error { return nil }
+	if f.File != "" && f.File != "-" {
+		if err := os.MkdirAll(filepath.Dir(f.File), 0o755); err != nil {
+			return nil, nil, err
+		}
+		file, err := os.OpenFile(f.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("open log file: %w", err)
+		}
+		w, closeLog = file, file.Close
+	}
+	opts := &slog.HandlerOptions{Level: f.Level}
+	var h slog.Handler
+	switch f.Format {
+	case "", FormatText:
+		h = slog.NewTextHandler(w, opts)
+	case FormatJSON:
+		h = slog.NewJSONHandler(w, opts)
+	default:
+		_ = closeLog()
+		return nil, nil, fmt.Errorf("unknown log format %q: want %s or %s", f.Format, FormatText, FormatJSON)
+	}
+	return slog.New(operationHandler{h}), closeLog, nil
+}
+
+// StateDir returns the directory gorefactor keeps its logs and profiles in:
+// $XDG_STATE_HOME/gorefactor, or ~/.local/state/gorefactor when that is
+// unset. Without a home directory it falls back to the temporary directory.
+func StateDir() string {
+	if dir := os.Getenv("XDG_STATE_HOME"); filepath.IsAbs(dir) {
+		return filepath.Join(dir, "gorefactor")
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".local", "state", "gorefactor")
+	}
+	return filepath.Join(os.TempDir(), "gorefactor")
+}
+
+type operationKey struct{}
+
+// NewOperationID returns a random ID for an operation, such as an MCP call.
+func NewOperationID() string {
+	var b [6]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// WithOperation returns a copy of ctx whose log lines carry the operation ID
+// id, as op_id.
+func WithOperation(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, operationKey{}, id)
+}
+
+// Operation returns the operation ID of ctx, or "" when it has none.
+func Operation(ctx context.Context) string {
+	id, _ := ctx.Value(operationKey{}).(string)
+	return id
+}
+
+// operationHandler adds the operation ID of the context to the records it
+// handles.
+type operationHandler struct {
+	slog.Handler
+}
+
+func (h operationHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := Operation(ctx); id != "" {
+		r.AddAttrs(slog.String("op_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h operationHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return operationHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h operationHandler) WithGroup(name string) slog.Handler {
+	return operationHandler{h.Handler.WithGroup(name)}
+}
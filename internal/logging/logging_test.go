@@ -0,0 +1,54 @@
+package logging_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mamaar/gorefactor/internal/logging"
+)
+
+func TestFlags(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gorefactor.log")
+	f := logging.Flags{Format: logging.FormatJSON, File: path}
+	logger, closeLog, err := f.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.DebugContext(context.Background(), "below the level")
+	logger.InfoContext(logging.WithOperation(context.Background(), "abc123"), "planned", "changes", 2)
+	if err := closeLog(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var record map[string]any
+	if err := json.Unmarshal(data, &record); err != nil {
+		t.Fatalf("want one JSON record: %v\n%s", err, data)
+	}
+	if record["msg"] != "planned" || record["op_id"] != "abc123" {
+		t.Errorf("record = %v", record)
+	}
+
+	f.Format = "xml"
+	if _, _, err := f.New(); err == nil {
+		t.Error("unknown format accepted")
+	}
+}
+
+func TestStateDir(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", "/state")
+	if got := logging.StateDir(); got != filepath.Join("/state", "gorefactor") {
+		t.Errorf("StateDir() = %s", got)
+	}
+	t.Setenv("XDG_STATE_HOME", "")
+	t.Setenv("HOME", "/home/u")
+	if got := logging.StateDir(); got != filepath.Join("/home/u", ".local", "state", "gorefactor") {
+		t.Errorf("StateDir() without XDG_STATE_HOME = %s", got)
+	}
+}
@@ -0,0 +1,117 @@
+package lsp
+
+import (
+	"cmp"
+	"context"
+	"slices"
+
+	"golang.org/x/tools/go/analysis"
+
+	"github.com/mamaar/gorefactor/pkg/refactor"
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// codeAction offers the fixes the analyzers suggested for the diagnostics
+// in the requested range as quick fixes. Each edits the one diagnostic it
+// fixes and the imports of its file, unlike the fix_* tools, which fix a
+// whole package.
+func (s *Server) codeAction(ctx context.Context, params CodeActionParams) ([]CodeAction, error) {
+	actions := []CodeAction{}
+	if len(params.Context.Only) > 0 && !slices.Contains(params.Context.Only, CodeActionQuickFix) {
+		return actions, nil
+	}
+	s.state.RLock()
+	defer s.state.RUnlock()
+
+	ws, err := s.state.GetWorkspace()
+	if err != nil {
+		return nil, err
+	}
+	path := uriPath(params.TextDocument.URI)
+	file, err := workspaceFile(ws, path)
+	if err != nil {
+		return actions, nil
+	}
+	cfg, disabled, err := s.settings.apply(s.state.ProjectConfig())
+	if err != nil {
+		return nil, err
+	}
+	findings, err := s.state.AnalyzeFiles([]string{path}, cfg, func(rule string) bool { return disabled[rule] })
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range findings {
+		if f.Pos.Filename != path || len(f.SuggestedFixes) == 0 {
+			continue
+		}
+		diag := findingDiagnostic(file, f)
+		if !overlaps(diag.Range, params.Range) {
+			continue
+		}
+		for _, fix := range f.SuggestedFixes {
+			edit, err := fixEdit(ws, params.TextDocument.URI, file, fix, cfg.AliasRules())
+			if err != nil {
+				return nil, err
+			}
+			title := fix.Message
+			if title == "" {
+				title = "Fix: " + diag.Message
+			}
+			actions = append(actions, CodeAction{
+				Title:       title,
+				Kind:        CodeActionQuickFix,
+				Diagnostics: []Diagnostic{diag},
+				IsPreferred: len(f.SuggestedFixes) == 1,
+				Edit:        edit,
+			})
+		}
+	}
+	s.logger.DebugContext(ctx, "code actions", "uri", params.TextDocument.URI, "actions", len(actions))
+	return actions, nil
+}
+
+// fixEdit returns the workspace edit applying fix, whose edits are in the
+// document uri naming file unless they say otherwise. Like the fix_* tools,
+// it adds the imports of the packages the fix newly refers to, such as
+// slices for slices.Sort, and removes those it leaves unused.
+func fixEdit(ws *types.Workspace, uri DocumentURI, file *types.File, fix analysis.SuggestedFix, aliases []types.AliasRule) (*WorkspaceEdit, error) {
+	plan := &types.RefactoringPlan{}
+	for _, te := range fix.TextEdits {
+		end := te.End
+		if !end.IsValid() {
+			end = te.Pos // An insertion
+		}
+		start, stop := ws.FileSet.Position(te.Pos), ws.FileSet.Position(end)
+		plan.Changes = append(plan.Changes, types.Change{File: start.Filename, Start: start.Offset, End: stop.Offset, NewText: string(te.NewText)})
+	}
+	imports := refactor.NewImportManager(ws, aliases)
+	imports.Offer("slices", "maps", "cmp")
+	imports.Update(plan)
+
+	edit := &WorkspaceEdit{Changes: make(map[DocumentURI][]TextEdit)}
+	for _, c := range plan.Changes {
+		f, doc := file, uri
+		if c.File != file.Path {
+			var err error
+			if f, err = workspaceFile(ws, c.File); err != nil {
+				return nil, err
+			}
+			doc = pathURI(f.Path)
+		}
+		edit.Changes[doc] = append(edit.Changes[doc], TextEdit{
+			Range:   Range{Start: positionOf(f.OriginalContent, c.Start), End: positionOf(f.OriginalContent, c.End)},
+			NewText: c.NewText,
+		})
+	}
+	return edit, nil
+}
+
+// overlaps reports whether two ranges share a position, their ends
+// included, so that a cursor at either end of a diagnostic is on it.
+func overlaps(a, b Range) bool {
+	return comparePositions(a.Start, b.End) <= 0 && comparePositions(b.Start, a.End) <= 0
+}
+
+func comparePositions(a, b Position) int {
+	return cmp.Or(cmp.Compare(a.Line, b.Line), cmp.Compare(a.Character, b.Character))
+}
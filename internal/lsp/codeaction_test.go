@@ -0,0 +1,159 @@
+package lsp
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	gotypes "go/types"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// applyEdits returns content with edits, which don't overlap, applied.
+func applyEdits(t *testing.T, content string, edits []TextEdit) string {
+	t.Helper()
+	edits = slices.Clone(edits)
+	slices.SortFunc(edits, func(a, b TextEdit) int { return comparePositions(b.Range.Start, a.Range.Start) })
+	out := []byte(content)
+	for _, e := range edits {
+		start, err := offsetOf(out, e.Range.Start)
+		if err != nil {
+			t.Fatal(err)
+		}
+		end, err := offsetOf(out, e.Range.End)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = slices.Concat(out[:start], []byte(e.NewText), out[end:])
+	}
+	return string(out)
+}
+
+func TestServer_CodeAction(t *testing.T) {
+	root := t.TempDir()
+	// Two if-inits: the quick fix of one leaves the other.
+	src := parseSource + `
+func Quote(s string) string {
+	if q := strconv.Quote(s); q != "" {
+		return q
+	}
+	return s
+}
+`
+	writeFiles(t, root, map[string]string{
+		"go.mod":     "module example.com/fix\n\ngo 1.22\n",
+		"lib/lib.go": src,
+	})
+	c := startServer(t, root)
+	uri := pathURI(filepath.Join(root, "lib", "lib.go"))
+	cursor := Range{Start: Position{Line: 5, Character: 3}, End: Position{Line: 5, Character: 3}}
+
+	var actions []CodeAction
+	if err := c.call("textDocument/codeAction", CodeActionParams{TextDocument: TextDocumentIdentifier{URI: uri}, Range: cursor}, &actions); err != nil {
+		t.Fatal(err)
+	}
+	i := slices.IndexFunc(actions, func(a CodeAction) bool { return len(a.Diagnostics) == 1 && a.Diagnostics[0].Code == "ifinit" })
+	if i < 0 {
+		t.Fatalf("got actions %+v, want the ifinit quick fix", actions)
+	}
+	fix := actions[i]
+	if fix.Kind != CodeActionQuickFix || fix.Title != "Split if-init assignment into separate assignment and if-check" || !fix.IsPreferred {
+		t.Errorf("got action %q of kind %q, preferred %v", fix.Title, fix.Kind, fix.IsPreferred)
+	}
+	if fix.Diagnostics[0].Range.Start.Line != 5 {
+		t.Errorf("fix is for the diagnostic at %+v, want the one on line 6", fix.Diagnostics[0].Range)
+	}
+	if len(fix.Edit.Changes) != 1 || fix.Edit.Changes[uri] == nil {
+		t.Fatalf("got changes to %v, want changes to %s only", fix.Edit.Changes, uri)
+	}
+	got := applyEdits(t, src, fix.Edit.Changes[uri])
+	want := `package lib
+
+import "strconv"
+
+func Parse(s string) int {
+	n, err := strconv.Atoi(s)
+	if err == nil {
+		return n
+	}
+	return 0
+}
+
+func Quote(s string) string {
+	if q := strconv.Quote(s); q != "" {
+		return q
+	}
+	return s
+}
+`
+	if got != want {
+		t.Errorf("after the fix:\n%s\nwant:\n%s", got, want)
+	}
+
+	if err := c.call("textDocument/codeAction", CodeActionParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Range:        cursor,
+		Context:      CodeActionContext{Only: []CodeActionKind{"refactor"}},
+	}, &actions); err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 0 {
+		t.Errorf("with only refactor actions asked for: got %+v, want none", actions)
+	}
+
+	blank := Range{Start: Position{Line: 1}, End: Position{Line: 1}}
+	if err := c.call("textDocument/codeAction", CodeActionParams{TextDocument: TextDocumentIdentifier{URI: uri}, Range: blank}, &actions); err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 0 {
+		t.Errorf("on a line without diagnostics: got %+v, want none", actions)
+	}
+}
+
+func TestServer_CodeActionImports(t *testing.T) {
+	root := t.TempDir()
+	src := `package lib
+
+import "sort"
+
+// Sorted sorts names.
+func Sorted(names []string) []string {
+	sort.Strings(names)
+	return names
+}
+`
+	writeFiles(t, root, map[string]string{
+		"go.mod":     "module example.com/fix\n\ngo 1.22\n",
+		"lib/lib.go": src,
+	})
+	c := startServer(t, root)
+	path := filepath.Join(root, "lib", "lib.go")
+	uri := pathURI(path)
+	cursor := Range{Start: Position{Line: 6, Character: 2}, End: Position{Line: 6, Character: 2}}
+
+	var actions []CodeAction
+	if err := c.call("textDocument/codeAction", CodeActionParams{TextDocument: TextDocumentIdentifier{URI: uri}, Range: cursor}, &actions); err != nil {
+		t.Fatal(err)
+	}
+	i := slices.IndexFunc(actions, func(a CodeAction) bool { return len(a.Diagnostics) == 1 && a.Diagnostics[0].Code == "modernize" })
+	if i < 0 {
+		t.Fatalf("got actions %+v, want the modernize quick fix", actions)
+	}
+	got := applyEdits(t, src, actions[i].Edit.Changes[uri])
+	if !strings.Contains(got, "slices.Sort(names)") || !strings.Contains(got, `"slices"`) || strings.Contains(got, `"sort"`) {
+		t.Errorf("after the fix:\n%s\nwant slices.Sort with the slices import instead of sort", got)
+	}
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, got, 0)
+	if err != nil {
+		t.Fatalf("after the fix:\n%s\n%v", got, err)
+	}
+	conf := gotypes.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err := conf.Check("example.com/fix/lib", fset, []*ast.File{f}, nil); err != nil {
+		t.Errorf("after the fix:\n%s\n%v", got, err)
+	}
+}
@@ -0,0 +1,142 @@
+package lsp
+
+import (
+	"context"
+	"maps"
+	"slices"
+
+	internalmcp "github.com/mamaar/gorefactor/internal/mcp"
+	"github.com/mamaar/gorefactor/pkg/sarif"
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// diagnosticSource names the server in the diagnostics it publishes.
+const diagnosticSource = "gorefactor"
+
+// didOpen publishes the diagnostics of a document the client opened.
+func (s *Server) didOpen(ctx context.Context, params DidOpenTextDocumentParams) error {
+	s.open[params.TextDocument.URI] = true
+	return s.publishDiagnostics(ctx, []DocumentURI{params.TextDocument.URI})
+}
+
+// didSave brings the workspace up to date with a saved document without
+// waiting for the watcher, then publishes the diagnostics of every open
+// document again: the change can affect the packages importing it.
+func (s *Server) didSave(ctx context.Context, params DidSaveTextDocumentParams) error {
+	if path := uriPath(params.TextDocument.URI); path != "" {
+		if err := s.state.SyncWorkspaceChanges([]string{path}); err != nil {
+			return err
+		}
+	}
+	return s.publishDiagnostics(ctx, s.openDocuments())
+}
+
+// didClose clears the diagnostics of a document the client closed.
+func (s *Server) didClose(ctx context.Context, params DidCloseTextDocumentParams) error {
+	delete(s.open, params.TextDocument.URI)
+	return s.conn.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: params.TextDocument.URI, Diagnostics: []Diagnostic{}})
+}
+
+// didChangeConfiguration takes the analyzer settings of the client and
+// publishes the diagnostics of the open documents under them. Invalid
+// settings are shown to the user and the previous ones kept.
+func (s *Server) didChangeConfiguration(ctx context.Context, params DidChangeConfigurationParams) error {
+	s.state.RLock()
+	st, err := parseSettings(params.Settings, s.state.ProjectConfig())
+	s.state.RUnlock()
+	if err != nil {
+		return s.conn.notify("window/showMessage", ShowMessageParams{Type: MessageError, Message: err.Error()})
+	}
+	s.settings = st
+	return s.publishDiagnostics(ctx, s.openDocuments())
+}
+
+// openDocuments returns the documents the client has open, sorted.
+func (s *Server) openDocuments() []DocumentURI {
+	return slices.Sorted(maps.Keys(s.open))
+}
+
+// publishDiagnostics runs the analyzers over the packages of the documents
+// and publishes their findings in each document, reading the documents as
+// last saved. Documents outside the workspace get none.
+func (s *Server) publishDiagnostics(ctx context.Context, uris []DocumentURI) error {
+	if len(uris) == 0 {
+		return nil
+	}
+	s.state.RLock()
+	diagnostics, err := s.diagnose(uris)
+	s.state.RUnlock()
+	if err != nil {
+		return err
+	}
+	for _, uri := range uris {
+		diags, ok := diagnostics[uri]
+		if !ok {
+			continue
+		}
+		if err := s.conn.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: uri, Diagnostics: diags}); err != nil {
+			return err
+		}
+	}
+	s.logger.DebugContext(ctx, "published diagnostics", "documents", len(diagnostics))
+	return nil
+}
+
+// diagnose returns the diagnostics of each document that is a workspace
+// file, possibly none. Callers hold the state's read lock.
+func (s *Server) diagnose(uris []DocumentURI) (map[DocumentURI][]Diagnostic, error) {
+	ws, err := s.state.GetWorkspace()
+	if err != nil {
+		return nil, err
+	}
+	cfg, disabled, err := s.settings.apply(s.state.ProjectConfig())
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]*types.File)
+	docs := make(map[string]DocumentURI) // As the client names them
+	out := make(map[DocumentURI][]Diagnostic)
+	for _, uri := range uris {
+		path := uriPath(uri)
+		if file, err := workspaceFile(ws, path); err == nil {
+			files[path], docs[path] = file, uri
+			out[uri] = []Diagnostic{}
+		}
+	}
+	findings, err := s.state.AnalyzeFiles(slices.Sorted(maps.Keys(files)), cfg, func(rule string) bool { return disabled[rule] })
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range findings {
+		file := files[f.Pos.Filename]
+		if file == nil {
+			continue
+		}
+		uri := docs[f.Pos.Filename]
+		out[uri] = append(out[uri], findingDiagnostic(file, f))
+	}
+	return out, nil
+}
+
+// findingDiagnostic returns the diagnostic publishing a finding in file.
+func findingDiagnostic(file *types.File, f internalmcp.Finding) Diagnostic {
+	start := positionOf(file.OriginalContent, f.Pos.Offset)
+	end := start
+	if f.End.IsValid() && f.End.Filename == f.Pos.Filename {
+		end = positionOf(file.OriginalContent, f.End.Offset)
+	}
+	severity := SeverityInformation
+	switch f.Level {
+	case sarif.LevelError:
+		severity = SeverityError
+	case sarif.LevelWarning:
+		severity = SeverityWarning
+	}
+	return Diagnostic{
+		Range:    Range{Start: start, End: end},
+		Severity: severity,
+		Code:     f.Rule,
+		Source:   diagnosticSource,
+		Message:  f.Message,
+	}
+}
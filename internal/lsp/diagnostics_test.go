@@ -0,0 +1,119 @@
+package lsp
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+const parseSource = `package lib
+
+import "strconv"
+
+func Parse(s string) int {
+	if n, err := strconv.Atoi(s); err == nil {
+		return n
+	}
+	return 0
+}
+`
+
+// publishedCodes waits for the diagnostics of uri and returns their codes.
+func (c *testClient) publishedCodes(uri DocumentURI) ([]string, []Diagnostic) {
+	c.t.Helper()
+	var params PublishDiagnosticsParams
+	for params.URI != uri {
+		msg := c.notification("textDocument/publishDiagnostics")
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			c.t.Fatal(err)
+		}
+	}
+	var codes []string
+	for _, d := range params.Diagnostics {
+		codes = append(codes, d.Code)
+	}
+	return codes, params.Diagnostics
+}
+
+func TestServer_Diagnostics(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"go.mod":     "module example.com/diag\n\ngo 1.22\n",
+		"lib/lib.go": parseSource,
+	})
+	c := startServer(t, root)
+	uri := pathURI(filepath.Join(root, "lib", "lib.go"))
+
+	if err := c.conn.notify("textDocument/didOpen", DidOpenTextDocumentParams{TextDocument: TextDocumentItem{URI: uri, LanguageID: "go", Text: parseSource}}); err != nil {
+		t.Fatal(err)
+	}
+	codes, diags := c.publishedCodes(uri)
+	if !slices.Equal(codes, []string{"doccoverage", "ifinit"}) {
+		t.Fatalf("on open: got %v, want doccoverage and ifinit", codes)
+	}
+	want := Diagnostic{
+		Range:    Range{Start: Position{Line: 5, Character: 1}, End: Position{Line: 7, Character: 2}},
+		Severity: SeverityInformation,
+		Code:     "ifinit",
+		Source:   diagnosticSource,
+		Message:  "if-init assignment should be split into separate assignment and if-check",
+	}
+	if diags[1] != want {
+		t.Errorf("ifinit diagnostic:\n got %+v\nwant %+v", diags[1], want)
+	}
+
+	settings := `{"gorefactor": {"analyzers": {"doccoverage": {"enabled": false}, "complexity": {"min_complexity": 2}}}}`
+	if err := c.conn.notify("workspace/didChangeConfiguration", DidChangeConfigurationParams{Settings: json.RawMessage(settings)}); err != nil {
+		t.Fatal(err)
+	}
+	if codes, _ := c.publishedCodes(uri); !slices.Equal(codes, []string{"complexity", "ifinit"}) {
+		t.Errorf("with doccoverage off and min_complexity 2: got %v, want complexity and ifinit", codes)
+	}
+
+	for _, bad := range []string{
+		`{"gorefactor": {"analyzers": {"complexity": {"min_complexity": "high"}}}}`,
+		`{"gorefactor": {"analyzers": {"complexity": {"max_complexity": 3}}}}`,
+		`{"gorefactor": {"analyzers": {"ifinit": {"min_branches": 3}}}}`,
+		`{"gorefactor": {"analyzers": {"nosuch": {"enabled": false}}}}`,
+	} {
+		if err := c.conn.notify("workspace/didChangeConfiguration", DidChangeConfigurationParams{Settings: json.RawMessage(bad)}); err != nil {
+			t.Fatal(err)
+		}
+		var shown ShowMessageParams
+		if err := json.Unmarshal(c.notification("window/showMessage").Params, &shown); err != nil {
+			t.Fatal(err)
+		}
+		if shown.Type != MessageError {
+			t.Errorf("settings %s: got message %+v, want an error", bad, shown)
+		}
+	}
+
+	// The fixed file is read on save; the invalid settings were not taken.
+	writeFiles(t, root, map[string]string{"lib/lib.go": `package lib
+
+import "strconv"
+
+// Parse returns the int s holds, or 0.
+func Parse(s string) int {
+	n, err := strconv.Atoi(s)
+	if err == nil {
+		return n
+	}
+	return 0
+}
+`})
+	if err := c.conn.notify("textDocument/didSave", DidSaveTextDocumentParams{TextDocument: TextDocumentIdentifier{URI: uri}}); err != nil {
+		t.Fatal(err)
+	}
+	if codes, _ := c.publishedCodes(uri); !slices.Equal(codes, []string{"complexity"}) {
+		t.Errorf("after saving the fix: got %v, want complexity only", codes)
+	}
+
+	if err := c.conn.notify("textDocument/didClose", DidCloseTextDocumentParams{TextDocument: TextDocumentIdentifier{URI: uri}}); err != nil {
+		t.Fatal(err)
+	}
+	if codes, _ := c.publishedCodes(uri); len(codes) != 0 {
+		t.Errorf("after close: got %v, want the diagnostics cleared", codes)
+	}
+}
@@ -0,0 +1,199 @@
+package lsp
+
+import (
+	"cmp"
+	"context"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/mamaar/gorefactor/pkg/analysis"
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// maxWorkspaceSymbols bounds the results of a workspace/symbol request.
+const maxWorkspaceSymbols = 100
+
+// definition answers textDocument/definition with the declaration of the
+// identifier at the position, or null when there is none in the workspace.
+func (s *Server) definition(ctx context.Context, params TextDocumentPositionParams) ([]Location, error) {
+	s.state.RLock()
+	defer s.state.RUnlock()
+
+	ws, err := s.state.GetWorkspace()
+	if err != nil {
+		return nil, err
+	}
+	sym, err := s.symbolAt(ctx, ws, params)
+	if sym == nil || err != nil {
+		return nil, err
+	}
+	loc, ok := symbolLocation(ws, sym)
+	if !ok {
+		return nil, nil
+	}
+	return []Location{loc}, nil
+}
+
+// references answers textDocument/references with the uses of the symbol
+// at the position across the workspace, found with the reference index.
+func (s *Server) references(ctx context.Context, params ReferenceParams) ([]Location, error) {
+	s.state.RLock()
+	defer s.state.RUnlock()
+
+	ws, err := s.state.GetWorkspace()
+	if err != nil {
+		return nil, err
+	}
+	sym, err := s.symbolAt(ctx, ws, params.TextDocumentPositionParams)
+	if sym == nil || err != nil {
+		return nil, err
+	}
+	idx, err := s.state.EnsureReferenceIndex(ws)
+	if err != nil {
+		return nil, err
+	}
+	refs, err := s.state.SymbolResolver(ws).FindReferencesIndexed(sym, idx)
+	if err != nil {
+		return nil, err
+	}
+
+	locs := []Location{}
+	if decl, ok := symbolLocation(ws, sym); ok && params.Context.IncludeDeclaration {
+		locs = append(locs, decl)
+	}
+	for _, ref := range refs {
+		if ref.File == sym.File && ref.Position == sym.Position {
+			continue // The declaration, included above when asked for
+		}
+		file, err := workspaceFile(ws, ref.File)
+		if err != nil {
+			continue
+		}
+		locs = append(locs, nameLocation(file, ref.Offset, sym.Name))
+	}
+	slices.SortFunc(locs, compareLocations)
+	return locs, nil
+}
+
+// workspaceSymbol answers workspace/symbol with the package-level
+// declarations and methods whose names contain the query, ignoring case.
+// Methods also match on Type.Method.
+func (s *Server) workspaceSymbol(ctx context.Context, params WorkspaceSymbolParams) ([]SymbolInformation, error) {
+	s.state.RLock()
+	defer s.state.RUnlock()
+
+	ws, err := s.state.GetWorkspace()
+	if err != nil {
+		return nil, err
+	}
+	matches, _, err := analysis.SearchSymbols(ws, analysis.SymbolQuery{
+		Pattern: "(?i)" + regexp.QuoteMeta(params.Query),
+		Regex:   true,
+		Limit:   maxWorkspaceSymbols,
+	})
+	if err != nil {
+		return nil, err
+	}
+	symbols := []SymbolInformation{}
+	for _, m := range matches {
+		file, err := workspaceFile(ws, m.File)
+		if err != nil || file.AST == nil {
+			continue
+		}
+		tf := ws.FileSet.File(file.AST.Pos())
+		if tf == nil || m.Line > tf.LineCount() {
+			continue
+		}
+		info := SymbolInformation{
+			Name:          m.Name,
+			Kind:          symbolKind(m),
+			Location:      nameLocation(file, tf.Offset(tf.LineStart(m.Line))+m.Column-1, m.Name),
+			ContainerName: m.Package,
+		}
+		if m.Receiver != "" {
+			info.ContainerName = m.Receiver
+		}
+		symbols = append(symbols, info)
+	}
+	return symbols, nil
+}
+
+// symbolAt resolves the identifier at a position to the symbol it declares
+// or refers to. It returns nil without an error when the document isn't
+// part of the workspace or there is no identifier the resolver knows.
+func (s *Server) symbolAt(ctx context.Context, ws *types.Workspace, params TextDocumentPositionParams) (*types.Symbol, error) {
+	path := uriPath(params.TextDocument.URI)
+	file, err := workspaceFile(ws, path)
+	if err != nil || file.AST == nil {
+		s.logger.DebugContext(ctx, "document is not in the workspace", "uri", params.TextDocument.URI)
+		return nil, nil
+	}
+	off, err := offsetOf(file.OriginalContent, params.Position)
+	if err != nil {
+		return nil, &ResponseError{Code: CodeInvalidParams, Message: err.Error()}
+	}
+	tf := ws.FileSet.File(file.AST.Pos())
+	if tf == nil || off > tf.Size() {
+		return nil, nil
+	}
+	sym, err := s.state.SymbolResolver(ws).FindDefinition(path, tf.Pos(off))
+	if err != nil {
+		s.logger.DebugContext(ctx, "no symbol at position", "uri", params.TextDocument.URI, "offset", off, "err", err)
+		return nil, nil
+	}
+	return sym, nil
+}
+
+// symbolLocation returns the location of the name of a symbol's
+// declaration.
+func symbolLocation(ws *types.Workspace, sym *types.Symbol) (Location, bool) {
+	if sym.File == "" || !sym.Position.IsValid() {
+		return Location{}, false
+	}
+	file, err := workspaceFile(ws, sym.File)
+	if err != nil {
+		return Location{}, false
+	}
+	return nameLocation(file, ws.FileSet.Position(sym.Position).Offset, sym.Name), true
+}
+
+// nameLocation returns the location of name at a byte offset in file.
+func nameLocation(file *types.File, off int, name string) Location {
+	return Location{
+		URI: pathURI(file.Path),
+		Range: Range{
+			Start: positionOf(file.OriginalContent, off),
+			End:   positionOf(file.OriginalContent, off+len(name)),
+		},
+	}
+}
+
+func compareLocations(a, b Location) int {
+	return cmp.Or(
+		strings.Compare(string(a.URI), string(b.URI)),
+		cmp.Compare(a.Range.Start.Line, b.Range.Start.Line),
+		cmp.Compare(a.Range.Start.Character, b.Range.Start.Character),
+	)
+}
+
+// symbolKind returns the LSP kind of a declaration.
+func symbolKind(m *analysis.SymbolMatch) SymbolKind {
+	switch m.Kind {
+	case analysis.SymbolKindFunc:
+		return SymbolKindFunction
+	case analysis.SymbolKindMethod:
+		return SymbolKindMethod
+	case analysis.SymbolKindConst:
+		return SymbolKindConstant
+	case analysis.SymbolKindVar:
+		return SymbolKindVariable
+	}
+	switch {
+	case strings.HasSuffix(m.Signature, " struct"):
+		return SymbolKindStruct
+	case strings.HasSuffix(m.Signature, " interface"):
+		return SymbolKindInterface
+	}
+	return SymbolKindClass
+}
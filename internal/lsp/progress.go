@@ -0,0 +1,63 @@
+package lsp
+
+import (
+	"encoding/json"
+	"strconv"
+	"sync"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// workDone reports the progress of the engine to the client as work done
+// progress titled title, until the returned function is called. Clients
+// that can't create work done progress get none. The server doesn't wait
+// for the client to create it: clients handle messages in the order sent.
+func (s *Server) workDone(title string) (end func()) {
+	if !s.workDoneProgress {
+		return func() {}
+	}
+	s.nextID++
+	token := ProgressToken(strconv.Quote("gorefactor-" + strconv.Itoa(s.nextID)))
+	if err := s.conn.request(json.RawMessage(strconv.Itoa(s.nextID)), "window/workDoneProgress/create", WorkDoneProgressCreateParams{Token: token}); err != nil {
+		s.logger.Debug("work done progress not created", "err", err)
+		return func() {}
+	}
+	_ = s.conn.notify("$/progress", ProgressParams{Token: token, Value: WorkDoneProgressBegin{Kind: "begin", Title: title}})
+
+	// Events may still be delivered once the watch stops, and must not
+	// follow the end of the progress.
+	var mu sync.Mutex
+	ended := false
+	stop := s.state.WatchProgress(func(event types.ProgressEvent) {
+		report := WorkDoneProgressReport{Kind: "report", Message: progressMessage(event)}
+		if event.Total > 0 {
+			percentage := uint32(event.Percent())
+			report.Percentage = &percentage
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if !ended {
+			_ = s.conn.notify("$/progress", ProgressParams{Token: token, Value: report})
+		}
+	})
+	return func() {
+		stop()
+		mu.Lock()
+		ended = true
+		mu.Unlock()
+		_ = s.conn.notify("$/progress", ProgressParams{Token: token, Value: WorkDoneProgressEnd{Kind: "end"}})
+	}
+}
+
+// progressMessage describes an event; how far its phase has come is in the
+// percentage of the report.
+func progressMessage(event types.ProgressEvent) string {
+	msg := string(event.Phase)
+	if event.Package != "" {
+		msg += " " + event.Package
+	}
+	if event.Message != "" {
+		msg += ": " + event.Message
+	}
+	return msg
+}
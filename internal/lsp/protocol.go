@@ -0,0 +1,257 @@
+package lsp
+
+import "encoding/json"
+
+// The subset of the Language Server Protocol 3.17 the server speaks. Field
+// names follow the specification.
+
+// DocumentURI is a file:// URI naming a document.
+type DocumentURI string
+
+// Position is a zero-based line and character offset in a document.
+// Characters count UTF-16 code units, as the protocol's default position
+// encoding does.
+type Position struct {
+	Line      uint32 `json:"line"`
+	Character uint32 `json:"character"`
+}
+
+// Range is a half-open range between two positions.
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// Location is a range in a document.
+type Location struct {
+	URI   DocumentURI `json:"uri"`
+	Range Range       `json:"range"`
+}
+
+type TextDocumentIdentifier struct {
+	URI DocumentURI `json:"uri"`
+}
+
+type TextDocumentPositionParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Position     Position               `json:"position"`
+}
+
+type ReferenceContext struct {
+	IncludeDeclaration bool `json:"includeDeclaration"`
+}
+
+type ReferenceParams struct {
+	TextDocumentPositionParams
+	Context ReferenceContext `json:"context"`
+}
+
+type WorkspaceSymbolParams struct {
+	Query string `json:"query"`
+}
+
+// SymbolKind is the kind of a symbol in workspace/symbol results.
+type SymbolKind int
+
+const (
+	SymbolKindClass     SymbolKind = 5
+	SymbolKindMethod    SymbolKind = 6
+	SymbolKindInterface SymbolKind = 11
+	SymbolKindFunction  SymbolKind = 12
+	SymbolKindVariable  SymbolKind = 13
+	SymbolKindConstant  SymbolKind = 14
+	SymbolKindStruct    SymbolKind = 23
+)
+
+type SymbolInformation struct {
+	Name          string     `json:"name"`
+	Kind          SymbolKind `json:"kind"`
+	Location      Location   `json:"location"`
+	ContainerName string     `json:"containerName,omitempty"` // Receiver type of a method, otherwise the import path
+}
+
+type WorkspaceFolder struct {
+	URI  DocumentURI `json:"uri"`
+	Name string      `json:"name"`
+}
+
+type InitializeParams struct {
+	ProcessID        *int               `json:"processId"`
+	RootURI          DocumentURI        `json:"rootUri,omitempty"`
+	RootPath         string             `json:"rootPath,omitempty"` // Deprecated by the protocol in favor of RootURI
+	WorkspaceFolders []WorkspaceFolder  `json:"workspaceFolders,omitempty"`
+	Capabilities     ClientCapabilities `json:"capabilities"`
+}
+
+type ClientCapabilities struct {
+	Window *WindowClientCapabilities `json:"window,omitempty"`
+}
+
+type WindowClientCapabilities struct {
+	WorkDoneProgress bool `json:"workDoneProgress,omitempty"`
+}
+
+type ServerCapabilities struct {
+	TextDocumentSync        *TextDocumentSyncOptions `json:"textDocumentSync,omitempty"`
+	DefinitionProvider      bool                     `json:"definitionProvider,omitempty"`
+	ReferencesProvider      bool                     `json:"referencesProvider,omitempty"`
+	WorkspaceSymbolProvider bool                     `json:"workspaceSymbolProvider,omitempty"`
+	CodeActionProvider      *CodeActionOptions       `json:"codeActionProvider,omitempty"`
+}
+
+type CodeActionOptions struct {
+	CodeActionKinds []CodeActionKind `json:"codeActionKinds,omitempty"`
+}
+
+// TextDocumentSyncKind is how the client sends document changes.
+type TextDocumentSyncKind int
+
+const (
+	TextDocumentSyncNone TextDocumentSyncKind = 0
+	TextDocumentSyncFull TextDocumentSyncKind = 1
+)
+
+type TextDocumentSyncOptions struct {
+	OpenClose bool                 `json:"openClose"`
+	Change    TextDocumentSyncKind `json:"change"`
+	Save      *SaveOptions         `json:"save,omitempty"`
+}
+
+type SaveOptions struct {
+	IncludeText bool `json:"includeText"`
+}
+
+type ServerInfo struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type InitializeResult struct {
+	Capabilities ServerCapabilities `json:"capabilities"`
+	ServerInfo   *ServerInfo        `json:"serverInfo,omitempty"`
+}
+
+type TextDocumentItem struct {
+	URI        DocumentURI `json:"uri"`
+	LanguageID string      `json:"languageId"`
+	Version    int32       `json:"version"`
+	Text       string      `json:"text"`
+}
+
+type DidOpenTextDocumentParams struct {
+	TextDocument TextDocumentItem `json:"textDocument"`
+}
+
+type DidSaveTextDocumentParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+type DidCloseTextDocumentParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+type DidChangeConfigurationParams struct {
+	Settings json.RawMessage `json:"settings"`
+}
+
+// DiagnosticSeverity is how serious a diagnostic is.
+type DiagnosticSeverity int
+
+const (
+	SeverityError       DiagnosticSeverity = 1
+	SeverityWarning     DiagnosticSeverity = 2
+	SeverityInformation DiagnosticSeverity = 3
+	SeverityHint        DiagnosticSeverity = 4
+)
+
+type Diagnostic struct {
+	Range    Range              `json:"range"`
+	Severity DiagnosticSeverity `json:"severity"`
+	Code     string             `json:"code"` // Name of the analyzer
+	Source   string             `json:"source"`
+	Message  string             `json:"message"`
+}
+
+type PublishDiagnosticsParams struct {
+	URI         DocumentURI  `json:"uri"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+// MessageType is the kind of a window/showMessage notification.
+type MessageType int
+
+const (
+	MessageError   MessageType = 1
+	MessageWarning MessageType = 2
+	MessageInfo    MessageType = 3
+)
+
+type ShowMessageParams struct {
+	Type    MessageType `json:"type"`
+	Message string      `json:"message"`
+}
+
+// CodeActionKind is the kind of a code action, a dotted hierarchy such as
+// "quickfix" or "refactor.extract".
+type CodeActionKind string
+
+const CodeActionQuickFix CodeActionKind = "quickfix"
+
+type CodeActionContext struct {
+	Diagnostics []Diagnostic     `json:"diagnostics"`
+	Only        []CodeActionKind `json:"only,omitempty"`
+}
+
+type CodeActionParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Range        Range                  `json:"range"`
+	Context      CodeActionContext      `json:"context"`
+}
+
+type TextEdit struct {
+	Range   Range  `json:"range"`
+	NewText string `json:"newText"`
+}
+
+type WorkspaceEdit struct {
+	Changes map[DocumentURI][]TextEdit `json:"changes"`
+}
+
+type CodeAction struct {
+	Title       string         `json:"title"`
+	Kind        CodeActionKind `json:"kind,omitempty"`
+	Diagnostics []Diagnostic   `json:"diagnostics,omitempty"`
+	IsPreferred bool           `json:"isPreferred,omitempty"`
+	Edit        *WorkspaceEdit `json:"edit,omitempty"`
+}
+
+// ProgressToken identifies a progress: an integer or a string.
+type ProgressToken = json.RawMessage
+
+type WorkDoneProgressCreateParams struct {
+	Token ProgressToken `json:"token"`
+}
+
+// ProgressParams is a $/progress notification. Its value is a
+// WorkDoneProgressBegin, WorkDoneProgressReport or WorkDoneProgressEnd.
+type ProgressParams struct {
+	Token ProgressToken `json:"token"`
+	Value any           `json:"value"`
+}
+
+type WorkDoneProgressBegin struct {
+	Kind    string `json:"kind"` // "begin"
+	Title   string `json:"title"`
+	Message string `json:"message,omitempty"`
+}
+
+type WorkDoneProgressReport struct {
+	Kind       string  `json:"kind"` // "report"
+	Message    string  `json:"message,omitempty"`
+	Percentage *uint32 `json:"percentage,omitempty"`
+}
+
+type WorkDoneProgressEnd struct {
+	Kind    string `json:"kind"` // "end"
+	Message string `json:"message,omitempty"`
+}
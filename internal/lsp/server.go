@@ -0,0 +1,305 @@
+// Package lsp serves a gorefactor workspace over the Language Server
+// Protocol, so editors can navigate it with the same symbol resolution and
+// reference index the refactoring tools use.
+package lsp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	internalmcp "github.com/mamaar/gorefactor/internal/mcp"
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// ErrExitWithoutShutdown is returned by Serve when the client sent exit
+// without shutdown first; the process should then exit with status 1.
+var ErrExitWithoutShutdown = errors.New("exit without shutdown")
+
+// Server is a language server answering from a workspace loaded into the
+// state of an MCP server, whose watcher keeps it in sync with the files on
+// disk. It handles one request at a time, in the order they arrive.
+type Server struct {
+	state  *internalmcp.MCPServer
+	logger *slog.Logger
+	root   string // Workspace to load: the client's root unless set by NewServer
+	conn   *conn
+
+	initialized      bool
+	shutdown         bool
+	workDoneProgress bool // The client creates work done progress on request
+	nextID           int  // Of the last request sent to the client
+
+	open     map[DocumentURI]bool // Documents the client has open
+	settings Settings             // Of the client, from workspace/didChangeConfiguration
+}
+
+// NewServer returns a server answering from state. When root isn't empty
+// it is loaded in place of the workspace the client names.
+func NewServer(state *internalmcp.MCPServer, logger *slog.Logger, root string) *Server {
+	return &Server{state: state, logger: logger, root: root, open: make(map[DocumentURI]bool)}
+}
+
+// Serve reads requests from r and writes responses to w until the client
+// sends exit or closes r.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	s.conn = newConn(r, w)
+	for {
+		body, err := s.conn.read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var msg message
+		if err := json.Unmarshal(body, &msg); err != nil {
+			if err := s.conn.reply(nil, nil, &ResponseError{Code: CodeParseError, Message: err.Error()}); err != nil {
+				return err
+			}
+			continue
+		}
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return ErrExitWithoutShutdown
+			}
+			return nil
+		}
+		if msg.Method == "" {
+			// A response to a request of the server, which doesn't wait
+			// for them.
+			if msg.Error != nil {
+				s.logger.DebugContext(ctx, "client request failed", "id", string(msg.ID), "err", msg.Error)
+			}
+			continue
+		}
+		if !msg.isRequest() {
+			s.handleNotification(ctx, &msg)
+			continue
+		}
+		result, err := s.handle(ctx, &msg)
+		if err != nil {
+			s.logger.DebugContext(ctx, "request failed", "method", msg.Method, "err", err)
+		}
+		if err := s.conn.reply(msg.ID, result, err); err != nil {
+			return err
+		}
+	}
+}
+
+// handle answers a request.
+func (s *Server) handle(ctx context.Context, msg *message) (any, error) {
+	switch {
+	case msg.Method == "initialize":
+		if s.initialized {
+			return nil, &ResponseError{Code: CodeInvalidRequest, Message: "server is already initialized"}
+		}
+		return call(ctx, msg.Params, s.initialize)
+	case !s.initialized:
+		return nil, &ResponseError{Code: CodeServerNotInitialized, Message: "server is not initialized"}
+	case s.shutdown:
+		return nil, &ResponseError{Code: CodeInvalidRequest, Message: "server is shutting down"}
+	}
+
+	switch msg.Method {
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/definition":
+		return call(ctx, msg.Params, s.definition)
+	case "textDocument/references":
+		return call(ctx, msg.Params, s.references)
+	case "workspace/symbol":
+		return call(ctx, msg.Params, s.workspaceSymbol)
+	case "textDocument/codeAction":
+		return call(ctx, msg.Params, s.codeAction)
+	}
+	return nil, &ResponseError{Code: CodeMethodNotFound, Message: "method not supported: " + msg.Method}
+}
+
+// handleNotification handles a notification. Unknown ones, such as
+// $/cancelRequest, and those before initialize or after shutdown are
+// ignored.
+func (s *Server) handleNotification(ctx context.Context, msg *message) {
+	if !s.initialized || s.shutdown {
+		return
+	}
+	var err error
+	switch msg.Method {
+	case "initialized":
+		err = s.load(ctx)
+	case "textDocument/didOpen":
+		err = notice(ctx, msg.Params, s.didOpen)
+	case "textDocument/didSave":
+		err = notice(ctx, msg.Params, s.didSave)
+	case "textDocument/didClose":
+		err = notice(ctx, msg.Params, s.didClose)
+	case "workspace/didChangeConfiguration":
+		err = notice(ctx, msg.Params, s.didChangeConfiguration)
+	default:
+		s.logger.DebugContext(ctx, "notification ignored", "method", msg.Method)
+	}
+	if err != nil {
+		s.logger.ErrorContext(ctx, "notification failed", "method", msg.Method, "err", err)
+	}
+}
+
+// call decodes params and passes them to f.
+func call[P, R any](ctx context.Context, params json.RawMessage, f func(context.Context, P) (R, error)) (any, error) {
+	var p P
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, &ResponseError{Code: CodeInvalidParams, Message: err.Error()}
+		}
+	}
+	return f(ctx, p)
+}
+
+// notice decodes params and passes them to f.
+func notice[P any](ctx context.Context, params json.RawMessage, f func(context.Context, P) error) error {
+	var p P
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &p); err != nil {
+			return err
+		}
+	}
+	return f(ctx, p)
+}
+
+// initialize takes the workspace root and capabilities of the client. The
+// workspace is loaded once the client has answered with initialized, as
+// reporting its progress needs requests the server can't send before.
+func (s *Server) initialize(ctx context.Context, params InitializeParams) (*InitializeResult, error) {
+	root := s.root
+	switch {
+	case root != "":
+	case params.RootURI != "":
+		root = uriPath(params.RootURI)
+	case params.RootPath != "":
+		root = params.RootPath
+	case len(params.WorkspaceFolders) > 0:
+		root = uriPath(params.WorkspaceFolders[0].URI)
+	}
+	if root == "" {
+		return nil, &ResponseError{Code: CodeInvalidParams, Message: "no workspace root: the client sent no rootUri or workspace folder"}
+	}
+	s.root = root
+	s.workDoneProgress = params.Capabilities.Window != nil && params.Capabilities.Window.WorkDoneProgress
+	s.initialized = true
+	return &InitializeResult{
+		Capabilities: ServerCapabilities{
+			TextDocumentSync: &TextDocumentSyncOptions{
+				OpenClose: true,
+				Change:    TextDocumentSyncNone, // Diagnostics are of the saved files
+				Save:      &SaveOptions{},
+			},
+			DefinitionProvider:      true,
+			ReferencesProvider:      true,
+			WorkspaceSymbolProvider: true,
+			CodeActionProvider:      &CodeActionOptions{CodeActionKinds: []CodeActionKind{CodeActionQuickFix}},
+		},
+		ServerInfo: &ServerInfo{Name: "gorefactor-lsp", Version: "1.0.0"},
+	}, nil
+}
+
+// load loads the workspace, reporting the progress of the engine to the
+// client. A workspace that fails to load is shown to the user; requests
+// then fail until the server is restarted.
+func (s *Server) load(ctx context.Context) error {
+	end := s.workDone("Loading workspace")
+	_, err := s.state.LoadWorkspace(ctx, s.root)
+	end()
+	if err != nil {
+		s.logger.ErrorContext(ctx, "workspace failed to load", "root", s.root, "err", err)
+		return s.conn.notify("window/showMessage", ShowMessageParams{Type: MessageError, Message: "gorefactor: " + err.Error()})
+	}
+	return nil
+}
+
+// uriPath returns the file path of a file:// URI, or "" for other URIs.
+func uriPath(uri DocumentURI) string {
+	u, err := url.Parse(string(uri))
+	if err != nil || u.Scheme != "file" {
+		return ""
+	}
+	p := u.Path
+	if runtime.GOOS == "windows" {
+		p = strings.TrimPrefix(p, "/") // file:///C:/dir
+	}
+	return filepath.Clean(filepath.FromSlash(p))
+}
+
+// pathURI returns the file:// URI of an absolute file path.
+func pathURI(path string) DocumentURI {
+	p := filepath.ToSlash(path)
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+	return DocumentURI((&url.URL{Scheme: "file", Path: p}).String())
+}
+
+// workspaceFile returns the file of the workspace at path, test files
+// included.
+func workspaceFile(ws *types.Workspace, path string) (*types.File, error) {
+	name := filepath.Base(path)
+	for _, pkg := range ws.Packages {
+		if f := pkg.Files[name]; f != nil && f.Path == path {
+			return f, nil
+		}
+		if f := pkg.TestFiles[name]; f != nil && f.Path == path {
+			return f, nil
+		}
+	}
+	return nil, fmt.Errorf("%s is not a file of the workspace", path)
+}
+
+// offsetOf returns the byte offset in content of an LSP position.
+// Characters past the end of a line are clamped to it.
+func offsetOf(content []byte, pos Position) (int, error) {
+	off := 0
+	for line := uint32(0); line < pos.Line; line++ {
+		i := bytes.IndexByte(content[off:], '\n')
+		if i < 0 {
+			return 0, fmt.Errorf("line %d is past the end of the file", pos.Line+1)
+		}
+		off += i + 1
+	}
+	for units := uint32(0); units < pos.Character && off < len(content) && content[off] != '\n'; {
+		r, size := utf8.DecodeRune(content[off:])
+		units += uint32(utf16Len(r))
+		off += size
+	}
+	return off, nil
+}
+
+// positionOf returns the LSP position of a byte offset in content.
+func positionOf(content []byte, off int) Position {
+	off = min(off, len(content))
+	start := bytes.LastIndexByte(content[:off], '\n') + 1
+	pos := Position{Line: uint32(bytes.Count(content[:start], []byte{'\n'}))}
+	for i := start; i < off; {
+		r, size := utf8.DecodeRune(content[i:])
+		pos.Character += uint32(utf16Len(r))
+		i += size
+	}
+	return pos
+}
+
+// utf16Len returns the UTF-16 code units encoding r; invalid bytes count
+// as one.
+func utf16Len(r rune) int {
+	if n := utf16.RuneLen(r); n > 0 {
+		return n
+	}
+	return 1
+}
@@ -0,0 +1,354 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+
+	internalmcp "github.com/mamaar/gorefactor/internal/mcp"
+)
+
+// testClient drives a Server over in-memory pipes.
+type testClient struct {
+	t      *testing.T
+	conn   *conn
+	nextID int
+	done   chan error // Result of Serve
+
+	notifications []message // Received while waiting for responses
+}
+
+// startServer serves the workspace at root and initializes the server.
+func startServer(t *testing.T, root string) *testClient {
+	t.Helper()
+	return startServerWith(t, InitializeParams{RootURI: pathURI(root)})
+}
+
+// startServerWith initializes the server with params.
+func startServerWith(t *testing.T, params InitializeParams) *testClient {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	state := internalmcp.NewMCPServer(logger)
+	t.Cleanup(state.Close)
+
+	clientR, serverW := io.Pipe()
+	serverR, clientW := io.Pipe()
+	c := &testClient{t: t, conn: newConn(clientR, clientW), done: make(chan error, 1)}
+	go func() {
+		c.done <- NewServer(state, logger, "").Serve(context.Background(), serverR, serverW)
+		serverW.Close()
+	}()
+	t.Cleanup(func() { clientW.Close() })
+
+	var result InitializeResult
+	if err := c.call("initialize", params, &result); err != nil {
+		t.Fatalf("initialize: %v", err)
+	}
+	if err := c.conn.notify("initialized", struct{}{}); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// call sends a request and decodes its result into result.
+func (c *testClient) call(method string, params, result any) error {
+	c.t.Helper()
+	c.nextID++
+	id := json.RawMessage(strconv.Itoa(c.nextID))
+	if err := c.conn.write(struct {
+		JSONRPC string          `json:"jsonrpc"`
+		ID      json.RawMessage `json:"id"`
+		Method  string          `json:"method"`
+		Params  any             `json:"params"`
+	}{"2.0", id, method, params}); err != nil {
+		c.t.Fatal(err)
+	}
+	for {
+		body, err := c.conn.read()
+		if err != nil {
+			c.t.Fatalf("%s: %v", method, err)
+		}
+		var msg message
+		if err := json.Unmarshal(body, &msg); err != nil {
+			c.t.Fatal(err)
+		}
+		if msg.Method != "" {
+			c.notifications = append(c.notifications, msg)
+			continue
+		}
+		if string(msg.ID) != string(id) {
+			c.t.Fatalf("%s: response to request %s, want %s", method, msg.ID, id)
+		}
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if result != nil {
+			if err := json.Unmarshal(msg.Result, result); err != nil {
+				c.t.Fatal(err)
+			}
+		}
+		return nil
+	}
+}
+
+// notification returns the next notification of method, first from those
+// received while waiting for responses. Other notifications are dropped.
+func (c *testClient) notification(method string) message {
+	c.t.Helper()
+	for len(c.notifications) > 0 {
+		msg := c.notifications[0]
+		c.notifications = c.notifications[1:]
+		if msg.Method == method {
+			return msg
+		}
+	}
+	for {
+		body, err := c.conn.read()
+		if err != nil {
+			c.t.Fatalf("waiting for %s: %v", method, err)
+		}
+		var msg message
+		if err := json.Unmarshal(body, &msg); err != nil {
+			c.t.Fatal(err)
+		}
+		if msg.Method == method {
+			return msg
+		}
+	}
+}
+
+// writeFiles writes files, by slash-separated path relative to dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// writeNavWorkspace writes a module whose app package calls into lib.
+func writeNavWorkspace(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/nav\n\ngo 1.22\n",
+		"lib/lib.go": `package lib
+
+// Store keeps values.
+type Store struct{ values map[string]int }
+
+// Get returns the value of key.
+func (s *Store) Get(key string) int { return s.values[key] }
+
+// Add adds two ints.
+func Add(a, b int) int { return a + b }
+`,
+		"app/app.go": `package app
+
+import "example.com/nav/lib"
+
+func Run(s *lib.Store) int {
+	greeting := "héllo 😀"; n := lib.Add(1, 2)
+	_ = greeting
+	return lib.Add(n, s.Get("k"))
+}
+`,
+	}
+	writeFiles(t, dir, files)
+	return dir
+}
+
+func TestServer_Navigation(t *testing.T) {
+	root := writeNavWorkspace(t)
+	c := startServer(t, root)
+	app := pathURI(filepath.Join(root, "app", "app.go"))
+	lib := pathURI(filepath.Join(root, "lib", "lib.go"))
+	addDecl := Location{URI: lib, Range: Range{Start: Position{Line: 9, Character: 5}, End: Position{Line: 9, Character: 8}}}
+
+	// On line 5, Add follows é, two bytes but one UTF-16 unit, and 😀, four
+	// bytes and two units.
+	var defs []Location
+	at := TextDocumentPositionParams{TextDocument: TextDocumentIdentifier{URI: app}, Position: Position{Line: 5, Character: 35}}
+	if err := c.call("textDocument/definition", at, &defs); err != nil {
+		t.Fatal(err)
+	}
+	if len(defs) != 1 || defs[0] != addDecl {
+		t.Errorf("definition of lib.Add: got %+v, want %+v", defs, addDecl)
+	}
+
+	var refs []Location
+	if err := c.call("textDocument/references", ReferenceParams{TextDocumentPositionParams: at, Context: ReferenceContext{IncludeDeclaration: true}}, &refs); err != nil {
+		t.Fatal(err)
+	}
+	want := []Location{
+		{URI: app, Range: Range{Start: Position{Line: 5, Character: 34}, End: Position{Line: 5, Character: 37}}},
+		{URI: app, Range: Range{Start: Position{Line: 7, Character: 12}, End: Position{Line: 7, Character: 15}}},
+		addDecl,
+	}
+	if !slices.Equal(refs, want) {
+		t.Errorf("references of lib.Add:\n got %+v\nwant %+v", refs, want)
+	}
+
+	// Without the declaration, asked for from the declaration itself.
+	refs = nil
+	fromDecl := ReferenceParams{TextDocumentPositionParams: TextDocumentPositionParams{TextDocument: TextDocumentIdentifier{URI: lib}, Position: Position{Line: 9, Character: 6}}}
+	if err := c.call("textDocument/references", fromDecl, &refs); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(refs, want[:2]) {
+		t.Errorf("references of lib.Add from its declaration:\n got %+v\nwant %+v", refs, want[:2])
+	}
+
+	var symbols []SymbolInformation
+	if err := c.call("workspace/symbol", WorkspaceSymbolParams{Query: "store"}, &symbols); err != nil {
+		t.Fatal(err)
+	}
+	// Get matches as Store.Get.
+	if len(symbols) != 2 || symbols[0].Name != "Store" || symbols[0].Kind != SymbolKindStruct || symbols[0].ContainerName != "example.com/nav/lib" || symbols[1].Name != "Get" {
+		t.Errorf("workspace/symbol store: got %+v, want the Store struct and its Get method", symbols)
+	}
+	symbols = nil
+	if err := c.call("workspace/symbol", WorkspaceSymbolParams{Query: "Store.Get"}, &symbols); err != nil {
+		t.Fatal(err)
+	}
+	wantGet := SymbolInformation{
+		Name:          "Get",
+		Kind:          SymbolKindMethod,
+		Location:      Location{URI: lib, Range: Range{Start: Position{Line: 6, Character: 16}, End: Position{Line: 6, Character: 19}}},
+		ContainerName: "Store",
+	}
+	if len(symbols) != 1 || symbols[0] != wantGet {
+		t.Errorf("workspace/symbol Store.Get: got %+v, want %+v", symbols, wantGet)
+	}
+}
+
+func TestServer_Lifecycle(t *testing.T) {
+	c := startServer(t, writeNavWorkspace(t))
+
+	err := c.call("textDocument/hover", struct{}{}, nil)
+	var rerr *ResponseError
+	if !errors.As(err, &rerr) || rerr.Code != CodeMethodNotFound {
+		t.Errorf("unsupported method: got %v, want code %d", err, CodeMethodNotFound)
+	}
+	var defs []Location
+	outside := TextDocumentPositionParams{TextDocument: TextDocumentIdentifier{URI: "file:///elsewhere/main.go"}}
+	if err := c.call("textDocument/definition", outside, &defs); err != nil || defs != nil {
+		t.Errorf("definition outside the workspace: got %v, %v, want null", defs, err)
+	}
+
+	if err := c.call("shutdown", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.conn.notify("exit", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-c.done; err != nil {
+		t.Errorf("Serve after shutdown and exit: %v", err)
+	}
+}
+
+func TestServer_ExitWithoutShutdown(t *testing.T) {
+	c := startServer(t, writeNavWorkspace(t))
+	if err := c.conn.notify("exit", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-c.done; !errors.Is(err, ErrExitWithoutShutdown) {
+		t.Errorf("got %v, want ErrExitWithoutShutdown", err)
+	}
+}
+
+func TestServer_WorkDoneProgress(t *testing.T) {
+	root := writeNavWorkspace(t)
+	c := startServerWith(t, InitializeParams{
+		RootURI:      pathURI(root),
+		Capabilities: ClientCapabilities{Window: &WindowClientCapabilities{WorkDoneProgress: true}},
+	})
+
+	create := c.notification("window/workDoneProgress/create")
+	if !create.isRequest() {
+		t.Fatalf("window/workDoneProgress/create is not a request: %+v", create)
+	}
+	var created WorkDoneProgressCreateParams
+	if err := json.Unmarshal(create.Params, &created); err != nil {
+		t.Fatal(err)
+	}
+
+	// The progress of the load runs from begin through the reports of the
+	// engine's phases to end, all under the created token.
+	var kinds, messages []string
+	for !slices.Contains(kinds, "end") {
+		var progress struct {
+			Token ProgressToken `json:"token"`
+			Value struct {
+				Kind    string `json:"kind"`
+				Title   string `json:"title"`
+				Message string `json:"message"`
+			} `json:"value"`
+		}
+		if err := json.Unmarshal(c.notification("$/progress").Params, &progress); err != nil {
+			t.Fatal(err)
+		}
+		if string(progress.Token) != string(created.Token) {
+			t.Fatalf("progress token %s, want %s", progress.Token, created.Token)
+		}
+		if progress.Value.Kind == "begin" && progress.Value.Title != "Loading workspace" {
+			t.Errorf("progress title %q", progress.Value.Title)
+		}
+		kinds = append(kinds, progress.Value.Kind)
+		messages = append(messages, progress.Value.Message)
+	}
+	if kinds[0] != "begin" || len(kinds) < 3 {
+		t.Errorf("progress kinds %v, want begin, reports and end", kinds)
+	}
+	if !slices.Contains(messages, "index: building dependency graph") {
+		t.Errorf("no report of the dependency graph in %q", messages)
+	}
+
+	// The server reads the answer to its request and goes on serving.
+	if err := c.conn.reply(create.ID, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	var symbols []SymbolInformation
+	if err := c.call("workspace/symbol", WorkspaceSymbolParams{Query: "Add"}, &symbols); err != nil {
+		t.Fatal(err)
+	}
+	if len(symbols) != 1 {
+		t.Errorf("got %d symbols after the load, want 1: %+v", len(symbols), symbols)
+	}
+}
+
+func TestPositionOffsetRoundTrip(t *testing.T) {
+	content := []byte("a := \"é😀\"\nb")
+	for _, tc := range []struct {
+		pos Position
+		off int
+	}{
+		{Position{0, 0}, 0},
+		{Position{0, 6}, 6},  // é
+		{Position{0, 7}, 8},  // 😀, two bytes for é before it
+		{Position{0, 9}, 12}, // the closing quote, after two UTF-16 units
+		{Position{1, 1}, 15},
+	} {
+		if got, err := offsetOf(content, tc.pos); err != nil || got != tc.off {
+			t.Errorf("offsetOf(%v) = %d, %v, want %d", tc.pos, got, err, tc.off)
+		}
+		if got := positionOf(content, tc.off); got != tc.pos {
+			t.Errorf("positionOf(%d) = %v, want %v", tc.off, got, tc.pos)
+		}
+	}
+	if _, err := offsetOf(content, Position{Line: 5}); err == nil {
+		t.Error("offsetOf past the last line: got no error")
+	}
+}
@@ -0,0 +1,108 @@
+package lsp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	internalmcp "github.com/mamaar/gorefactor/internal/mcp"
+	"github.com/mamaar/gorefactor/pkg/config"
+)
+
+// settingsSection is the section of the client settings the server reads.
+const settingsSection = "gorefactor"
+
+// analyzerSections maps the analyzers with thresholds to their section of
+// the analyzers config in .gorefactor.yaml.
+var analyzerSections = map[string]string{
+	"complexity":    "complexity",
+	"deepifelse":    "deep_if_else",
+	"booleanbranch": "boolean_branch",
+	"envbool":       "env_bool",
+	"errorwrap":     "error_wrap",
+	"pipeline":      "pipeline",
+	"magicnumber":   "magic_number",
+}
+
+// Settings are the client settings under the gorefactor section of
+// workspace/didChangeConfiguration.
+type Settings struct {
+	// Analyzers configures the analyzers by name. "enabled": false turns
+	// one off; other keys override its thresholds from .gorefactor.yaml,
+	// named as there, such as "min_complexity" for complexity.
+	Analyzers map[string]map[string]any `json:"analyzers"`
+}
+
+// parseSettings reads the gorefactor section of the settings a client sent
+// and checks them against the project config.
+func parseSettings(raw json.RawMessage, base *config.Config) (Settings, error) {
+	var all map[string]json.RawMessage
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &all); err != nil {
+			return Settings{}, fmt.Errorf("settings: %w", err)
+		}
+	}
+	var st Settings
+	if section, ok := all[settingsSection]; ok {
+		if err := json.Unmarshal(section, &st); err != nil {
+			return Settings{}, fmt.Errorf("%s settings: %w", settingsSection, err)
+		}
+	}
+	if _, _, err := st.apply(base); err != nil {
+		return Settings{}, err
+	}
+	return st, nil
+}
+
+// apply returns base with the thresholds of st, and the analyzers st turns
+// off.
+func (st Settings) apply(base *config.Config) (*config.Config, map[string]bool, error) {
+	cfg := *base
+	disabled := make(map[string]bool)
+	known := internalmcp.AnalyzerNames()
+	sections := make(map[string]map[string]any)
+	for _, name := range slices.Sorted(maps.Keys(st.Analyzers)) {
+		if !slices.Contains(known, name) {
+			return nil, nil, fmt.Errorf("unknown analyzer %q in %s settings; known analyzers: %s", name, settingsSection, strings.Join(known, ", "))
+		}
+		thresholds := maps.Clone(st.Analyzers[name])
+		if enabled, ok := thresholds["enabled"]; ok {
+			on, ok := enabled.(bool)
+			if !ok {
+				return nil, nil, fmt.Errorf("%s settings: enabled of %s must be true or false", settingsSection, name)
+			}
+			disabled[name] = !on
+			delete(thresholds, "enabled")
+		}
+		if len(thresholds) == 0 {
+			continue
+		}
+		section, ok := analyzerSections[name]
+		if !ok {
+			return nil, nil, fmt.Errorf("%s settings: analyzer %s has no thresholds", settingsSection, name)
+		}
+		sections[section] = thresholds
+	}
+	if len(sections) > 0 {
+		// Decoding the thresholds as YAML over the project's checks them
+		// like .gorefactor.yaml is checked.
+		doc, err := yaml.Marshal(sections)
+		if err != nil {
+			return nil, nil, err
+		}
+		dec := yaml.NewDecoder(bytes.NewReader(doc))
+		dec.KnownFields(true)
+		if err := dec.Decode(&cfg.Analyzers); err != nil {
+			return nil, nil, fmt.Errorf("%s settings: %w", settingsSection, err)
+		}
+		if err := cfg.Validate(); err != nil {
+			return nil, nil, fmt.Errorf("%s settings: %w", settingsSection, err)
+		}
+	}
+	return &cfg, disabled, nil
+}
@@ -3,6 +3,7 @@ package mcp
 import (
 	"context"
 	"fmt"
+	"slices"
 
 	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
 
@@ -11,10 +12,21 @@ import (
 	"github.com/mamaar/gorefactor/pkg/analyzers/booleanbranch"
 	"github.com/mamaar/gorefactor/pkg/analyzers/complexity"
 	"github.com/mamaar/gorefactor/pkg/analyzers/deepifelse"
+	"github.com/mamaar/gorefactor/pkg/analyzers/doccoverage"
 	"github.com/mamaar/gorefactor/pkg/analyzers/envbool"
+	"github.com/mamaar/gorefactor/pkg/analyzers/errorreturn"
+	"github.com/mamaar/gorefactor/pkg/analyzers/errorsentinel"
 	"github.com/mamaar/gorefactor/pkg/analyzers/errorwrap"
 	"github.com/mamaar/gorefactor/pkg/analyzers/ifinit"
+	"github.com/mamaar/gorefactor/pkg/analyzers/loopconv"
+	"github.com/mamaar/gorefactor/pkg/analyzers/magicnumber"
 	"github.com/mamaar/gorefactor/pkg/analyzers/missingctx"
+	"github.com/mamaar/gorefactor/pkg/analyzers/modernize"
+	"github.com/mamaar/gorefactor/pkg/analyzers/naming"
+	"github.com/mamaar/gorefactor/pkg/analyzers/pipeline"
+	"github.com/mamaar/gorefactor/pkg/analyzers/positionallit"
+	"github.com/mamaar/gorefactor/pkg/analyzers/testsetup"
+	"github.com/mamaar/gorefactor/pkg/refactor"
 	"github.com/mamaar/gorefactor/pkg/types"
 )
 
@@ -44,6 +56,19 @@ type ReferenceInfo struct {
 	Column int    `json:"column"`
 }
 
+// --- search_symbols ---
+
+type SearchSymbolsInput struct {
+	Pattern   string   `json:"pattern,omitempty" jsonschema:"glob on symbol names, e.g. New* or Store.* for the methods of Store (empty matches every name)"`
+	Regex     bool     `json:"regex,omitempty" jsonschema:"treat pattern as a regular expression matched anywhere in the name"`
+	Kinds     []string `json:"kinds,omitempty" jsonschema:"only these kinds: func, method, type, const, var"`
+	Exported  bool     `json:"exported,omitempty" jsonschema:"only exported symbols and methods of exported types"`
+	Packages  []string `json:"packages,omitempty" jsonschema:"only packages matching these patterns: directory relative to the workspace root or import path, with path.Match wildcards per element and a trailing /... for subpackages"`
+	Signature string   `json:"signature,omitempty" jsonschema:"substring the declaration's signature must contain, e.g. context.Context or error)"`
+	Tests     bool     `json:"tests,omitempty" jsonschema:"search test files too"`
+	Limit     int      `json:"limit,omitempty" jsonschema:"maximum number of symbols to return (default 100)"`
+}
+
 // --- complexity ---
 
 type ComplexityInput struct {
@@ -196,6 +221,30 @@ type FixErrorWrappingInput struct {
 	SeverityLevel string `json:"severity_level,omitempty" jsonschema:"fix violations at this severity or higher: critical, warning, or info (default critical)"`
 }
 
+// --- detect_error_string_checks ---
+
+type DetectErrorStringChecksInput struct {
+	Package string `json:"package,omitempty" jsonschema:"specific package to analyze"`
+}
+
+type ErrorStringCheckItem struct {
+	File          string `json:"file"`
+	Line          int    `json:"line"`
+	Column        int    `json:"column"`
+	Function      string `json:"function_name"`
+	ViolationType string `json:"violation_type"`
+	CurrentCode   string `json:"current_code"`
+	Message       string `json:"message"`
+	Sentinel      string `json:"sentinel,omitempty"`
+	NewSentinel   bool   `json:"new_sentinel,omitempty"`
+}
+
+// --- fix_error_string_checks ---
+
+type FixErrorStringChecksInput struct {
+	Package string `json:"package,omitempty" jsonschema:"specific package to fix"`
+}
+
 // --- detect_environment_booleans ---
 
 type DetectEnvBooleansInput struct {
@@ -216,11 +265,220 @@ type EnvBooleanViolationItem struct {
 	Suggestion       string   `json:"suggestion"`
 }
 
+// --- detect_pipeline_loops ---
+
+type DetectPipelineLoopsInput struct {
+	Package       string `json:"package,omitempty" jsonschema:"specific package to analyze"`
+	MinStatements int    `json:"min_statements,omitempty" jsonschema:"minimum number of statements in the loop body (default 6)"`
+	MinStages     int    `json:"min_stages,omitempty" jsonschema:"minimum number of stages the body must split into (default 3)"`
+	MaxLive       int    `json:"max_live,omitempty" jsonschema:"maximum number of values crossing a stage boundary (default 2)"`
+}
+
+type PipelineLoopItem struct {
+	File                       string            `json:"file"`
+	Line                       int               `json:"line"`
+	Column                     int               `json:"column"`
+	Function                   string            `json:"function_name"`
+	Statements                 int               `json:"statements"`
+	Stages                     []*pipeline.Stage `json:"stages"`
+	Decomposition              string            `json:"decomposition"`
+	LongestStage               int               `json:"longest_stage"`
+	ComplexityReductionPercent int               `json:"complexity_reduction_estimate"`
+	Suggestion                 string            `json:"suggestion"`
+}
+
+// --- detect_duplicate_test_setup ---
+
+type DetectDuplicateTestSetupInput struct {
+	Package       string `json:"package,omitempty" jsonschema:"specific package to analyze"`
+	MinStatements int    `json:"min_statements,omitempty" jsonschema:"minimum number of shared leading statements to report (default 2)"`
+}
+
+type DuplicateTestSetupItem struct {
+	File       string   `json:"file"`
+	Line       int      `json:"line"`
+	Package    string   `json:"package"`
+	Tests      []string `json:"tests"`
+	Statements int      `json:"statements"`
+	Code       string   `json:"code"`
+	Teardown   bool     `json:"teardown,omitempty"`
+}
+
+// --- detect_magic_numbers ---
+
+type DetectMagicNumbersInput struct {
+	Package        string `json:"package,omitempty" jsonschema:"specific package to analyze"`
+	MinOccurrences int    `json:"min_occurrences,omitempty" jsonschema:"times a literal has to repeat in a package to be reported outside conditionals (default 3)"`
+}
+
+type MagicNumberItem struct {
+	File          string `json:"file"`
+	Line          int    `json:"line"`
+	Column        int    `json:"column"`
+	Function      string `json:"function_name,omitempty"`
+	Value         string `json:"value"`
+	Kind          string `json:"kind"`
+	Occurrences   int    `json:"occurrences"`
+	InConditional bool   `json:"in_conditional"`
+	SuggestedName string `json:"suggested_name"`
+}
+
+// --- fix_magic_numbers ---
+
+type FixMagicNumbersInput struct {
+	Package        string            `json:"package,omitempty" jsonschema:"specific package to fix"`
+	MinOccurrences int               `json:"min_occurrences,omitempty" jsonschema:"times a literal has to repeat in a package to be replaced outside conditionals (default 3)"`
+	Names          map[string]string `json:"names,omitempty" jsonschema:"constant names by literal as written, e.g. {\"3600\": \"secondsPerHour\"}; the suggested names are used for the rest"`
+	Preview        bool              `json:"preview,omitempty" jsonschema:"return the planned changes without applying them"`
+}
+
+// --- detect_error_returns ---
+
+type DetectErrorReturnsInput struct {
+	Package string `json:"package,omitempty" jsonschema:"specific package to analyze"`
+}
+
+type ErrorReturnItem struct {
+	File          string `json:"file"`
+	Line          int    `json:"line"`
+	Column        int    `json:"column"`
+	Function      string `json:"function_name"`
+	Kind          string `json:"kind"`
+	Name          string `json:"name,omitempty"`
+	ReturnOrder   []int  `json:"return_order,omitempty"`
+	SuggestedName string `json:"suggested_name,omitempty"`
+}
+
+// --- fix_error_returns ---
+
+type FixErrorReturnsInput struct {
+	Package string `json:"package,omitempty" jsonschema:"specific package to fix"`
+	Preview bool   `json:"preview,omitempty" jsonschema:"return the planned changes without applying them"`
+}
+
+// --- detect_positional_literals ---
+
+type DetectPositionalLiteralsInput struct {
+	Package string   `json:"package,omitempty" jsonschema:"specific package to analyze"`
+	Structs []string `json:"structs,omitempty" jsonschema:"only literals of these struct types, each Name, pkg.Name or import/path.Name"`
+}
+
+type PositionalLiteralItem struct {
+	File   string   `json:"file"`
+	Line   int      `json:"line"`
+	Column int      `json:"column"`
+	Type   string   `json:"type"`
+	Fields []string `json:"fields"`
+}
+
+// --- fix_positional_literals ---
+
+type FixPositionalLiteralsInput struct {
+	Package string   `json:"package,omitempty" jsonschema:"specific package to fix"`
+	Structs []string `json:"structs,omitempty" jsonschema:"only literals of these struct types, each Name, pkg.Name or import/path.Name"`
+	Preview bool     `json:"preview,omitempty" jsonschema:"return the planned changes without applying them"`
+}
+
+// --- detect_missing_docs ---
+
+type DetectMissingDocsInput struct {
+	Package string `json:"package,omitempty" jsonschema:"specific package to analyze"`
+}
+
+type MissingDocItem struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	Name   string `json:"name"`
+	Kind   string `json:"kind"`
+}
+
+// --- fix_missing_docs ---
+
+type FixMissingDocsInput struct {
+	Package string `json:"package,omitempty" jsonschema:"specific package to fix"`
+	Style   string `json:"style,omitempty" jsonschema:"'stub' (default) for '// Name ...', 'template' for a sentence derived from the signature such as '// NewStore returns a new Store ...'"`
+	Preview bool   `json:"preview,omitempty" jsonschema:"return the planned changes without applying them"`
+}
+
+// --- detect_naming_issues ---
+
+type DetectNamingIssuesInput struct {
+	Package string `json:"package,omitempty" jsonschema:"specific package to analyze"`
+}
+
+type NamingIssueItem struct {
+	File          string   `json:"file"`
+	Line          int      `json:"line"`
+	Column        int      `json:"column"`
+	Name          string   `json:"name"` // Type.Method for methods
+	Kind          string   `json:"kind"`
+	Violations    []string `json:"violations"`
+	SuggestedName string   `json:"suggested_name"`
+}
+
+// --- fix_naming ---
+
+type FixNamingInput struct {
+	Package string   `json:"package,omitempty" jsonschema:"specific package to fix"`
+	Names   []string `json:"names,omitempty" jsonschema:"only rename these reported names, each Name or Type.Method as detect_naming_issues lists them (default: all)"`
+	Preview bool     `json:"preview,omitempty" jsonschema:"return the planned changes without applying them"`
+}
+
+// --- detect_modernize ---
+
+type DetectModernizeInput struct {
+	Package string   `json:"package,omitempty" jsonschema:"specific package to analyze"`
+	Checks  []string `json:"checks,omitempty" jsonschema:"checks to run (default: all the module's Go version allows): replaceall (go1.12), any (go1.18), cutprefix (go1.20), sortslice (go1.21), minmax (go1.21), rangeint (go1.22)"`
+}
+
+type ModernizeItem struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Check   string `json:"check"`
+	Version string `json:"version"`
+	Message string `json:"message"`
+}
+
+// --- fix_modernize ---
+
+type FixModernizeInput struct {
+	Package string   `json:"package,omitempty" jsonschema:"specific package to fix"`
+	Checks  []string `json:"checks,omitempty" jsonschema:"checks to apply (default: all the module's Go version allows)"`
+	Preview bool     `json:"preview,omitempty" jsonschema:"return the planned changes without applying them"`
+}
+
+// --- detect_loop_conversions ---
+
+type DetectLoopConversionsInput struct {
+	Package string   `json:"package,omitempty" jsonschema:"specific package to analyze"`
+	Kinds   []string `json:"kinds,omitempty" jsonschema:"conversions to look for (default: all): contains, index, filter, sort, keys, copy"`
+}
+
+type LoopConversionItem struct {
+	File        string `json:"file"`
+	Line        int    `json:"line"`
+	Column      int    `json:"column"`
+	Kind        string `json:"kind"`
+	Replacement string `json:"replacement"`
+	Message     string `json:"message"`
+}
+
+// --- fix_loop_conversions ---
+
+type FixLoopConversionsInput struct {
+	Package string   `json:"package,omitempty" jsonschema:"specific package to fix"`
+	Kinds   []string `json:"kinds,omitempty" jsonschema:"conversions to apply (default: all)"`
+	Preview bool     `json:"preview,omitempty" jsonschema:"return the planned changes without applying them"`
+}
+
 // --- analyze_dependencies ---
 
 type AnalyzeDependenciesInput struct {
-	DetectBackwards bool `json:"detect_backwards,omitempty" jsonschema:"detect backwards dependencies"`
-	SuggestMoves    bool `json:"suggest_moves,omitempty" jsonschema:"suggest symbol moves to improve structure"`
+	DetectBackwards bool   `json:"detect_backwards,omitempty" jsonschema:"detect backwards dependencies"`
+	SuggestMoves    bool   `json:"suggest_moves,omitempty" jsonschema:"suggest symbol moves to improve structure"`
+	Format          string `json:"format,omitempty" jsonschema:"'json' (default) for the analysis; 'dot' (Graphviz) or 'mermaid' for a diagram of the package dependency graph with import cycles in red and packages colored by layer"`
 }
 
 func registerAnalysisTools(s *mcpsdk.Server, state *MCPServer) {
@@ -275,6 +533,41 @@ func registerAnalysisTools(s *mcpsdk.Server, state *MCPServer) {
 		return textResult(info), nil, nil
 	})
 
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "search_symbols",
+		Description: "Search the package-level declarations and methods of the workspace by name (glob or regex), kind, exportedness, package and signature substring. Returns each symbol's kind, package, signature and location, so one call replaces listing packages and calling analyze_symbol on each.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in SearchSymbolsInput) (*mcpsdk.CallToolResult, any, error) {
+		state.RLock()
+		defer state.RUnlock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		limit := in.Limit
+		if limit <= 0 {
+			limit = 100
+		}
+		symbols, truncated, err := analysis.SearchSymbols(ws, analysis.SymbolQuery{
+			Pattern:   in.Pattern,
+			Regex:     in.Regex,
+			Kinds:     in.Kinds,
+			Exported:  in.Exported,
+			Packages:  in.Packages,
+			Signature: in.Signature,
+			Tests:     in.Tests,
+			Limit:     limit,
+		})
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		return textResult(map[string]any{
+			"symbols":   symbols,
+			"count":     len(symbols),
+			"truncated": truncated,
+		}), nil, nil
+	})
+
 	mcpsdk.AddTool(s, &mcpsdk.Tool{
 		Name:        "complexity",
 		Description: "Analyze cyclomatic and cognitive complexity of functions. Returns functions exceeding the threshold, sorted by complexity.",
@@ -289,7 +582,7 @@ func registerAnalysisTools(s *mcpsdk.Server, state *MCPServer) {
 
 		minC := in.MinComplexity
 		if minC <= 0 {
-			minC = 10
+			minC = state.ProjectConfig().Analyzers.Complexity.MinComplexity
 		}
 
 		a := complexity.NewAnalyzer(complexity.WithMinComplexity(minC))
@@ -446,7 +739,7 @@ func registerAnalysisTools(s *mcpsdk.Server, state *MCPServer) {
 		}
 
 		plan := analyzers.ChangesToPlan(changes)
-		result, err := executePlanWithUnlock(state, plan, "Fix if-init assignments")
+		result, err := executePlanWithUnlock(ctx, state, plan, "Fix if-init assignments")
 		if err != nil {
 			return errResult(err), nil, nil
 		}
@@ -507,7 +800,7 @@ func registerAnalysisTools(s *mcpsdk.Server, state *MCPServer) {
 
 		minBranches := in.MinBranches
 		if minBranches <= 0 {
-			minBranches = 2
+			minBranches = state.ProjectConfig().Analyzers.BooleanBranch.MinBranches
 		}
 		a := booleanbranch.NewAnalyzer(booleanbranch.WithMinBranches(minBranches))
 		rr, err := analyzers.Run(ws, a, in.Package)
@@ -551,7 +844,7 @@ func registerAnalysisTools(s *mcpsdk.Server, state *MCPServer) {
 
 		minBranches := in.MinBranches
 		if minBranches <= 0 {
-			minBranches = 2
+			minBranches = state.ProjectConfig().Analyzers.BooleanBranch.MinBranches
 		}
 		a := booleanbranch.NewAnalyzer(booleanbranch.WithMinBranches(minBranches))
 		rr, err := analyzers.Run(ws, a, in.Package)
@@ -583,7 +876,7 @@ func registerAnalysisTools(s *mcpsdk.Server, state *MCPServer) {
 		}
 
 		plan := analyzers.ChangesToPlan(changes)
-		result, err := executePlanWithUnlock(state, plan, "Fix boolean branching")
+		result, err := executePlanWithUnlock(ctx, state, plan, "Fix boolean branching")
 		if err != nil {
 			return errResult(err), nil, nil
 		}
@@ -609,11 +902,11 @@ func registerAnalysisTools(s *mcpsdk.Server, state *MCPServer) {
 
 		maxNesting := in.MaxNestingDepth
 		if maxNesting <= 0 {
-			maxNesting = 2
+			maxNesting = state.ProjectConfig().Analyzers.DeepIfElse.MaxNesting
 		}
 		minElseLines := in.MinElseLines
 		if minElseLines <= 0 {
-			minElseLines = 3
+			minElseLines = state.ProjectConfig().Analyzers.DeepIfElse.MinElseLines
 		}
 		a := deepifelse.NewAnalyzer(
 			deepifelse.WithMaxNesting(maxNesting),
@@ -661,11 +954,11 @@ func registerAnalysisTools(s *mcpsdk.Server, state *MCPServer) {
 
 		maxNesting := in.MaxNestingDepth
 		if maxNesting <= 0 {
-			maxNesting = 2
+			maxNesting = state.ProjectConfig().Analyzers.DeepIfElse.MaxNesting
 		}
 		minElseLines := in.MinElseLines
 		if minElseLines <= 0 {
-			minElseLines = 3
+			minElseLines = state.ProjectConfig().Analyzers.DeepIfElse.MinElseLines
 		}
 		a := deepifelse.NewAnalyzer(
 			deepifelse.WithMaxNesting(maxNesting),
@@ -699,7 +992,7 @@ func registerAnalysisTools(s *mcpsdk.Server, state *MCPServer) {
 		}
 
 		plan := analyzers.ChangesToPlan(changes)
-		result, err := executePlanWithUnlock(state, plan, "Fix deep if-else chains")
+		result, err := executePlanWithUnlock(ctx, state, plan, "Fix deep if-else chains")
 		if err != nil {
 			return errResult(err), nil, nil
 		}
@@ -725,7 +1018,7 @@ func registerAnalysisTools(s *mcpsdk.Server, state *MCPServer) {
 
 		sev := errorwrap.Severity(in.SeverityLevel)
 		if sev == "" {
-			sev = errorwrap.SeverityCritical
+			sev = errorwrap.Severity(state.ProjectConfig().Analyzers.ErrorWrap.Severity)
 		}
 		a := errorwrap.NewAnalyzer(errorwrap.WithSeverity(sev))
 		rr, err := analyzers.Run(ws, a, in.Package)
@@ -769,7 +1062,7 @@ func registerAnalysisTools(s *mcpsdk.Server, state *MCPServer) {
 
 		sev := errorwrap.Severity(in.SeverityLevel)
 		if sev == "" {
-			sev = errorwrap.SeverityCritical
+			sev = errorwrap.Severity(state.ProjectConfig().Analyzers.ErrorWrap.Severity)
 		}
 		a := errorwrap.NewAnalyzer(errorwrap.WithSeverity(sev))
 		rr, err := analyzers.Run(ws, a, in.Package)
@@ -809,7 +1102,7 @@ func registerAnalysisTools(s *mcpsdk.Server, state *MCPServer) {
 		}
 
 		plan := analyzers.ChangesToPlan(changes)
-		result, err := executePlanWithUnlock(state, plan, "Fix error wrapping")
+		result, err := executePlanWithUnlock(ctx, state, plan, "Fix error wrapping")
 		if err != nil {
 			return errResult(err), nil, nil
 		}
@@ -822,6 +1115,103 @@ func registerAnalysisTools(s *mcpsdk.Server, state *MCPServer) {
 		}), nil, nil
 	})
 
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "detect_error_string_checks",
+		Description: "Detect errors checked by their message text: `err.Error() == \"...\"`, `strings.Contains(err.Error(), \"...\")` and `==` comparisons against `errors.New`/`fmt.Errorf` results. Reports the sentinel each check can be rewritten against, if the package produces that error.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in DetectErrorStringChecksInput) (*mcpsdk.CallToolResult, any, error) {
+		state.RLock()
+		defer state.RUnlock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+
+		rr, err := analyzers.Run(ws, errorsentinel.Analyzer, in.Package)
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+
+		var items []ErrorStringCheckItem
+		if results, ok := rr.Result.([]*errorsentinel.Result); ok {
+			items = make([]ErrorStringCheckItem, len(results))
+			for i, v := range results {
+				items[i] = ErrorStringCheckItem{
+					File:          v.File,
+					Line:          v.Line,
+					Column:        v.Column,
+					Function:      v.Function,
+					ViolationType: v.ViolationType,
+					CurrentCode:   v.CurrentCode,
+					Message:       v.Message,
+					Sentinel:      v.Sentinel,
+					NewSentinel:   v.NewSentinel,
+				}
+			}
+		}
+		return textResult(map[string]any{
+			"violations":  items,
+			"total_count": len(items),
+		}), nil, nil
+	})
+
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "fix_error_string_checks",
+		Description: "Rewrite error message comparisons to `errors.Is`. Introduces `var ErrX = errors.New(...)` sentinels for messages the package produces, replaces the matching `errors.New`/`fmt.Errorf` calls with them, and reuses existing sentinels. Checks against errors the package doesn't produce are left alone.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in FixErrorStringChecksInput) (*mcpsdk.CallToolResult, any, error) {
+		state.RLock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+
+		rr, err := analyzers.Run(ws, errorsentinel.Analyzer, in.Package)
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+
+		changes := analyzers.DiagnosticsToChanges(ws.FileSet, rr.Diagnostics)
+		if len(changes) == 0 {
+			state.RUnlock()
+			return textResult(map[string]any{
+				"files_modified":    []string{},
+				"changes_count":     0,
+				"checks_rewritten":  0,
+				"sentinels_created": []string{},
+				"message":           "No fixable error string checks found",
+			}), nil, nil
+		}
+
+		checksRewritten := 0
+		var sentinelsCreated []string
+		if results, ok := rr.Result.([]*errorsentinel.Result); ok {
+			for _, r := range results {
+				if r.Sentinel == "" {
+					continue
+				}
+				checksRewritten++
+				if r.NewSentinel && !slices.Contains(sentinelsCreated, r.Sentinel) {
+					sentinelsCreated = append(sentinelsCreated, r.Sentinel)
+				}
+			}
+		}
+
+		plan := analyzers.ChangesToPlan(changes)
+		result, err := executePlanWithUnlock(ctx, state, plan, "Fix error string checks")
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		return textResult(map[string]any{
+			"files_modified":    result.ModifiedFiles,
+			"changes_count":     result.ChangeCount,
+			"checks_rewritten":  checksRewritten,
+			"sentinels_created": sentinelsCreated,
+		}), nil, nil
+	})
+
 	mcpsdk.AddTool(s, &mcpsdk.Tool{
 		Name:        "detect_environment_booleans",
 		Description: "Detect isProd/isTest/devMode boolean parameters passed down call stacks. These should be replaced with interface implementations or concrete values resolved at initialization time.",
@@ -836,7 +1226,7 @@ func registerAnalysisTools(s *mcpsdk.Server, state *MCPServer) {
 
 		maxDepth := in.MaxDepth
 		if maxDepth <= 0 {
-			maxDepth = 1
+			maxDepth = state.ProjectConfig().Analyzers.EnvBool.MaxDepth
 		}
 		a := envbool.NewAnalyzer(envbool.WithMaxDepth(maxDepth))
 		rr, err := analyzers.Run(ws, a, in.Package)
@@ -869,9 +1259,9 @@ func registerAnalysisTools(s *mcpsdk.Server, state *MCPServer) {
 	})
 
 	mcpsdk.AddTool(s, &mcpsdk.Tool{
-		Name:        "analyze_dependencies",
-		Description: "Analyze the dependency graph of the workspace. Optionally detect backwards dependencies and suggest moves.",
-	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in AnalyzeDependenciesInput) (*mcpsdk.CallToolResult, any, error) {
+		Name:        "detect_pipeline_loops",
+		Description: "Detect long loops whose bodies run as produce, transform and consume stages, each passing a few values to the next. Reports the stage boundaries, the values crossing them and the estimated decomposition; extract_pipeline_stages moves the stages into functions.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in DetectPipelineLoopsInput) (*mcpsdk.CallToolResult, any, error) {
 		state.RLock()
 		defer state.RUnlock()
 
@@ -879,19 +1269,735 @@ func registerAnalysisTools(s *mcpsdk.Server, state *MCPServer) {
 		if err != nil {
 			return errResult(err), nil, nil
 		}
-		plan, err := state.GetEngine().AnalyzeDependencies(ws, types.AnalyzeDependenciesRequest{
-			Workspace:           ws.RootPath,
-			DetectBackwardsDeps: in.DetectBackwards,
-			SuggestMoves:        in.SuggestMoves,
-		})
+
+		cfg := state.ProjectConfig().Analyzers.Pipeline
+		minStatements, minStages := in.MinStatements, in.MinStages
+		if minStatements <= 0 {
+			minStatements = cfg.MinStatements
+		}
+		if minStages <= 0 {
+			minStages = cfg.MinStages
+		}
+		opts := []pipeline.Option{pipeline.WithMinStatements(minStatements), pipeline.WithMinStages(minStages)}
+		if in.MaxLive > 0 {
+			opts = append(opts, pipeline.WithMaxLive(in.MaxLive))
+		}
+		rr, err := analyzers.Run(ws, pipeline.NewAnalyzer(opts...), in.Package)
 		if err != nil {
 			return errResult(err), nil, nil
 		}
+
+		var items []PipelineLoopItem
+		if results, ok := rr.Result.([]*pipeline.Result); ok {
+			items = make([]PipelineLoopItem, len(results))
+			for i, v := range results {
+				items[i] = PipelineLoopItem{
+					File:                       v.File,
+					Line:                       v.Line,
+					Column:                     v.Column,
+					Function:                   v.Function,
+					Statements:                 v.Statements,
+					Stages:                     v.Stages,
+					Decomposition:              v.Decomposition,
+					LongestStage:               v.LongestStage,
+					ComplexityReductionPercent: v.ComplexityReductionPercent,
+					Suggestion:                 v.Suggestion,
+				}
+			}
+		}
 		return textResult(map[string]any{
-			"affected_files":   plan.AffectedFiles,
-			"change_count":     len(plan.Changes),
-			"impact":           plan.Impact,
-			"dependency_graph": ws.Dependencies,
+			"violations":  items,
+			"total_count": len(items),
+		}), nil, nil
+	})
+
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "detect_duplicate_test_setup",
+		Description: "Detect setup statements repeated at the start of several tests in _test.go files, including deferred teardown. Each group can be extracted with extract_test_helper.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in DetectDuplicateTestSetupInput) (*mcpsdk.CallToolResult, any, error) {
+		state.RLock()
+		defer state.RUnlock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+
+		var opts []testsetup.Option
+		if in.MinStatements > 0 {
+			opts = append(opts, testsetup.WithMinStatements(in.MinStatements))
+		}
+		rr, err := analyzers.RunTests(ws, testsetup.NewAnalyzer(opts...), in.Package)
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+
+		var items []DuplicateTestSetupItem
+		if results, ok := rr.Result.([]*testsetup.Result); ok {
+			items = make([]DuplicateTestSetupItem, len(results))
+			for i, v := range results {
+				items[i] = DuplicateTestSetupItem{
+					File:       v.File,
+					Line:       v.Line,
+					Package:    v.Package,
+					Tests:      v.Tests,
+					Statements: v.Statements,
+					Code:       v.Code,
+					Teardown:   v.Teardown,
+				}
+			}
+		}
+		return textResult(map[string]any{
+			"duplicates":  items,
+			"total_count": len(items),
+		}), nil, nil
+	})
+
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "detect_magic_numbers",
+		Description: "Detect numeric and string literals repeated across a package or compared in if, for and switch conditions. 0, 1, empty strings, constant declarations, import paths and struct tags are ignored. fix_magic_numbers replaces them with named constants.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in DetectMagicNumbersInput) (*mcpsdk.CallToolResult, any, error) {
+		state.RLock()
+		defer state.RUnlock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+
+		minOccurrences := in.MinOccurrences
+		if minOccurrences <= 0 {
+			minOccurrences = state.ProjectConfig().Analyzers.MagicNumber.MinOccurrences
+		}
+		rr, err := analyzers.Run(ws, magicnumber.NewAnalyzer(magicnumber.WithMinOccurrences(minOccurrences)), in.Package)
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+
+		var items []MagicNumberItem
+		if results, ok := rr.Result.([]*magicnumber.Result); ok {
+			items = make([]MagicNumberItem, len(results))
+			for i, v := range results {
+				items[i] = MagicNumberItem{
+					File:          v.File,
+					Line:          v.Line,
+					Column:        v.Column,
+					Function:      v.Function,
+					Value:         v.Value,
+					Kind:          v.Kind,
+					Occurrences:   v.Occurrences,
+					InConditional: v.InConditional,
+					SuggestedName: v.SuggestedName,
+				}
+			}
+		}
+		return textResult(map[string]any{
+			"violations":  items,
+			"total_count": len(items),
 		}), nil, nil
 	})
+
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "fix_magic_numbers",
+		Description: "Replace the literals detect_magic_numbers reports with named constants. Each value becomes one untyped package-level constant, declared after the imports of the file it first appears in, and every occurrence of it in the package is replaced.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in FixMagicNumbersInput) (*mcpsdk.CallToolResult, any, error) {
+		state.RLock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+
+		minOccurrences := in.MinOccurrences
+		if minOccurrences <= 0 {
+			minOccurrences = state.ProjectConfig().Analyzers.MagicNumber.MinOccurrences
+		}
+		pkgPath := in.Package
+		if pkgPath != "" {
+			pkgPath = types.ResolvePackagePath(ws, pkgPath)
+		}
+		plan, err := state.GetEngine().FixMagicNumbers(ws, types.FixMagicNumbersRequest{
+			Package:        pkgPath,
+			MinOccurrences: minOccurrences,
+			Names:          in.Names,
+		})
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+		if len(plan.Changes) == 0 {
+			state.RUnlock()
+			return textResult(map[string]any{
+				"files_modified": []string{},
+				"changes_count":  0,
+				"message":        "No magic numbers found",
+			}), nil, nil
+		}
+		result, err := previewPlanWithUnlock(ctx, state, plan, "Fix magic numbers", in.Preview)
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		return textResult(result), nil, nil
+	})
+
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "detect_error_returns",
+		Description: "Detect functions returning an error other than as their last result, and error parameters, results and variables not named err (errRead, readErr and the like are accepted). fix_error_returns fixes them.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in DetectErrorReturnsInput) (*mcpsdk.CallToolResult, any, error) {
+		state.RLock()
+		defer state.RUnlock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+
+		// Local variables are only recognized in type-checked packages.
+		state.GetEngine().EnsureTypeChecked(ws, analyzers.SelectPackages(ws, in.Package)...)
+		rr, err := analyzers.Run(ws, errorreturn.Analyzer, in.Package)
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+
+		var items []ErrorReturnItem
+		if results, ok := rr.Result.([]*errorreturn.Result); ok {
+			items = make([]ErrorReturnItem, len(results))
+			for i, v := range results {
+				items[i] = ErrorReturnItem{
+					File:          v.File,
+					Line:          v.Line,
+					Column:        v.Column,
+					Function:      v.Function,
+					Kind:          v.Kind,
+					Name:          v.Name,
+					ReturnOrder:   v.ReturnOrder,
+					SuggestedName: v.SuggestedName,
+				}
+			}
+		}
+		return textResult(map[string]any{
+			"violations":  items,
+			"total_count": len(items),
+		}), nil, nil
+	})
+
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "fix_error_returns",
+		Description: "Fix what detect_error_returns reports. Error results are moved last as change_signature would, updating return statements and the assignments from every call; calls whose results are passed on whole are reported to update by hand, and methods implementing an interface are skipped. Error variables are renamed err unless the function already uses that name. Fixes whose edits overlap are left to a second run.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in FixErrorReturnsInput) (*mcpsdk.CallToolResult, any, error) {
+		state.RLock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+
+		pkgPath := in.Package
+		if pkgPath != "" {
+			pkgPath = types.ResolvePackagePath(ws, pkgPath)
+		}
+		plan, err := state.GetEngine().FixErrorReturns(ws, types.FixErrorReturnsRequest{Package: pkgPath})
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+		if len(plan.Changes) == 0 {
+			state.RUnlock()
+			return textResult(map[string]any{
+				"files_modified": []string{},
+				"changes_count":  0,
+				"message":        "No error results or names to fix",
+			}), nil, nil
+		}
+		result, err := previewPlanWithUnlock(ctx, state, plan, "Fix error returns", in.Preview)
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		return textResult(result), nil, nil
+	})
+
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "detect_positional_literals",
+		Description: "Detect struct literals that list their values without field names, such as Point{1, 2}, which adding or reordering fields breaks silently. Needs type information, so packages that don't type-check are skipped. fix_positional_literals names the fields.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in DetectPositionalLiteralsInput) (*mcpsdk.CallToolResult, any, error) {
+		state.RLock()
+		defer state.RUnlock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+
+		state.GetEngine().EnsureTypeChecked(ws, analyzers.SelectPackages(ws, in.Package)...)
+		rr, err := analyzers.Run(ws, positionallit.NewAnalyzer(positionallit.WithStructs(in.Structs...)), in.Package)
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+
+		var items []PositionalLiteralItem
+		if results, ok := rr.Result.([]*positionallit.Result); ok {
+			items = make([]PositionalLiteralItem, len(results))
+			for i, v := range results {
+				items[i] = PositionalLiteralItem{
+					File:   v.File,
+					Line:   v.Line,
+					Column: v.Column,
+					Type:   v.Type,
+					Fields: v.Fields,
+				}
+			}
+		}
+		return textResult(map[string]any{
+			"violations":  items,
+			"total_count": len(items),
+		}), nil, nil
+	})
+
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "fix_positional_literals",
+		Description: "Name the fields of the struct literals detect_positional_literals reports, across the workspace or a package, optionally only for the given struct types: Point{1, 2} becomes Point{X: 1, Y: 2}. Keyed literals keep working when fields are added or reordered.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in FixPositionalLiteralsInput) (*mcpsdk.CallToolResult, any, error) {
+		state.RLock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+
+		state.GetEngine().EnsureTypeChecked(ws, analyzers.SelectPackages(ws, in.Package)...)
+		rr, err := analyzers.Run(ws, positionallit.NewAnalyzer(positionallit.WithStructs(in.Structs...)), in.Package)
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+
+		changes := analyzers.DiagnosticsToChanges(ws.FileSet, rr.Diagnostics)
+		if len(changes) == 0 {
+			state.RUnlock()
+			return textResult(map[string]any{
+				"files_modified": []string{},
+				"changes_count":  0,
+				"message":        "No positional struct literals found",
+			}), nil, nil
+		}
+		result, err := previewPlanWithUnlock(ctx, state, analyzers.ChangesToPlan(changes), "Fix positional literals", in.Preview)
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		return textResult(result), nil, nil
+	})
+
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "detect_missing_docs",
+		Description: "Detect exported functions, methods, types, constants and variables without doc comments, and report the share of each package's exported declarations that are documented. fix_missing_docs inserts comments to fill in.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in DetectMissingDocsInput) (*mcpsdk.CallToolResult, any, error) {
+		state.RLock()
+		defer state.RUnlock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+
+		rr, err := analyzers.Run(ws, doccoverage.Analyzer, in.Package)
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+
+		items := []MissingDocItem{}
+		results, _ := rr.Result.([]*doccoverage.Result)
+		documented := 0
+		for _, v := range results {
+			if v.Documented {
+				documented++
+				continue
+			}
+			items = append(items, MissingDocItem{
+				File:   v.File,
+				Line:   v.Line,
+				Column: v.Column,
+				Name:   v.Name,
+				Kind:   v.Kind,
+			})
+		}
+		return textResult(map[string]any{
+			"violations":       items,
+			"total_count":      len(items),
+			"packages":         doccoverage.Coverage(results),
+			"coverage_percent": doccoverage.Percent(documented, len(results)),
+		}), nil, nil
+	})
+
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "fix_missing_docs",
+		Description: "Insert doc comments above the exported declarations detect_missing_docs reports, as a reviewable plan: '// Name ...' stubs, or with style 'template' sentences derived from the signature ('// NewStore returns a new Store ...', '// IsValid reports whether ...'). The '...' marks what is left to write.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in FixMissingDocsInput) (*mcpsdk.CallToolResult, any, error) {
+		style := doccoverage.Style(in.Style)
+		switch style {
+		case "":
+			style = doccoverage.StyleStub
+		case doccoverage.StyleStub, doccoverage.StyleTemplate:
+		default:
+			return errResult(fmt.Errorf("unknown style %q: want stub or template", in.Style)), nil, nil
+		}
+
+		state.RLock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+
+		rr, err := analyzers.Run(ws, doccoverage.NewAnalyzer(doccoverage.WithStyle(style)), in.Package)
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+
+		changes := analyzers.DiagnosticsToChanges(ws.FileSet, rr.Diagnostics)
+		if len(changes) == 0 {
+			state.RUnlock()
+			return textResult(map[string]any{
+				"files_modified": []string{},
+				"changes_count":  0,
+				"message":        "No undocumented exported declarations found",
+			}), nil, nil
+		}
+		result, err := previewPlanWithUnlock(ctx, state, analyzers.ChangesToPlan(changes), "Add doc comments", in.Preview)
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		return textResult(result), nil, nil
+	})
+
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "detect_naming_issues",
+		Description: "Detect package-level names and methods breaking Go naming conventions: initialisms in mixed case (UserId for UserID), underscores (max_size) and exported names repeating their package's name (client.ClientConfig). Each comes with the suggested name; fix_naming renames the accepted ones.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in DetectNamingIssuesInput) (*mcpsdk.CallToolResult, any, error) {
+		state.RLock()
+		defer state.RUnlock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+
+		rr, err := analyzers.Run(ws, naming.Analyzer, in.Package)
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+
+		var items []NamingIssueItem
+		if results, ok := rr.Result.([]*naming.Result); ok {
+			items = make([]NamingIssueItem, len(results))
+			for i, v := range results {
+				name := v.Name
+				if v.Receiver != "" {
+					name = v.Receiver + "." + v.Name
+				}
+				items[i] = NamingIssueItem{
+					File:          v.File,
+					Line:          v.Line,
+					Column:        v.Column,
+					Name:          name,
+					Kind:          v.Kind,
+					Violations:    v.Violations,
+					SuggestedName: v.SuggestedName,
+				}
+			}
+		}
+		return textResult(map[string]any{
+			"violations":  items,
+			"total_count": len(items),
+		}), nil, nil
+	})
+
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "fix_naming",
+		Description: "Rename what detect_naming_issues reports to the suggested names, or only the listed names, as one plan: symbols as rename_symbol would and methods as rename_method would, updating every reference. Renames that conflict with an existing name, with another rename of the batch or with its edits are left out and reported as issues, so the rest can be applied.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in FixNamingInput) (*mcpsdk.CallToolResult, any, error) {
+		state.RLock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+
+		pkgPath := in.Package
+		if pkgPath != "" {
+			pkgPath = types.ResolvePackagePath(ws, pkgPath)
+		}
+		plan, err := state.GetEngine().FixNaming(ws, types.FixNamingRequest{Package: pkgPath, Names: in.Names})
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+		if len(plan.Changes) == 0 {
+			state.RUnlock()
+			return textResult(map[string]any{
+				"files_modified": []string{},
+				"changes_count":  0,
+				"message":        "No names to fix",
+				"issues":         plan.Impact.PotentialIssues,
+			}), nil, nil
+		}
+		result, err := previewPlanWithUnlock(ctx, state, plan, "Fix naming", in.Preview)
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		return textResult(result), nil, nil
+	})
+
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "detect_modernize",
+		Description: "Detect older idioms with a newer replacement the module's Go version, from the go directive of go.mod, allows: interface{} → any, sort.Strings → slices.Sort, if/else picking the smaller of two integers → min, HasPrefix then TrimPrefix → CutPrefix, for i := 0; i < n; i++ → for i := range n, Replace with n -1 → ReplaceAll. A //go:build constraint on a newer version enables the newer checks in its file. Lists the checks the module's version rules out. fix_modernize applies the suggestions.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in DetectModernizeInput) (*mcpsdk.CallToolResult, any, error) {
+		state.RLock()
+		defer state.RUnlock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		opts, err := modernizeOptions(ws, in.Checks)
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+
+		// minmax and rangeint only check the types of type-checked packages.
+		state.GetEngine().EnsureTypeChecked(ws, analyzers.SelectPackages(ws, in.Package)...)
+		rr, err := analyzers.Run(ws, modernize.NewAnalyzer(opts...), in.Package)
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+
+		items := []ModernizeItem{}
+		if results, ok := rr.Result.([]*modernize.Result); ok {
+			for _, v := range results {
+				items = append(items, ModernizeItem{
+					File:    v.File,
+					Line:    v.Line,
+					Column:  v.Column,
+					Check:   v.Check,
+					Version: v.Version,
+					Message: v.Message,
+				})
+			}
+		}
+		_, unavailable := modernize.Available(moduleGoVersion(ws), in.Checks...)
+		return textResult(map[string]any{
+			"violations":  items,
+			"total_count": len(items),
+			"go_version":  moduleGoVersion(ws),
+			"unavailable": unavailable,
+		}), nil, nil
+	})
+
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "fix_modernize",
+		Description: "Apply what detect_modernize reports, as a reviewable plan, adding the slices import and dropping the sort imports left unused. Only rewrites the module's Go version allows are made.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in FixModernizeInput) (*mcpsdk.CallToolResult, any, error) {
+		state.RLock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+		opts, err := modernizeOptions(ws, in.Checks)
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+
+		state.GetEngine().EnsureTypeChecked(ws, analyzers.SelectPackages(ws, in.Package)...)
+		rr, err := analyzers.Run(ws, modernize.NewAnalyzer(opts...), in.Package)
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+
+		changes := analyzers.DiagnosticsToChanges(ws.FileSet, rr.Diagnostics)
+		if len(changes) == 0 {
+			state.RUnlock()
+			return textResult(map[string]any{
+				"files_modified": []string{},
+				"changes_count":  0,
+				"message":        "Nothing to modernize for Go " + moduleGoVersion(ws),
+			}), nil, nil
+		}
+		// Loop headers and if conditions aren't Go on their own.
+		analyzers.CheckChanges(ws, changes)
+		plan := analyzers.ChangesToPlan(changes)
+		imports := refactor.NewImportManager(ws, state.ProjectConfig().AliasRules())
+		imports.Offer("slices")
+		imports.Update(plan)
+		result, err := previewPlanWithUnlock(ctx, state, plan, "Modernize", in.Preview)
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		return textResult(result), nil, nil
+	})
+
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "detect_loop_conversions",
+		Description: "Detect loops a function of the slices or maps packages replaces: searching a slice for a value → slices.Contains or slices.Index, filtering a slice in place → slices.DeleteFunc, sort.Slice comparing elements or a field → slices.Sort or slices.SortFunc, collecting a map's keys or values → slices.Collect or slices.Sorted of maps.Keys, copying a map → maps.Copy. Only loops the call does the same as are reported: the search may do nothing but return or set a flag and break, and the filtered slice has to be local and unused after. Needs Go 1.21, and 1.23 for maps.Keys. fix_loop_conversions applies the suggestions.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in DetectLoopConversionsInput) (*mcpsdk.CallToolResult, any, error) {
+		state.RLock()
+		defer state.RUnlock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		opts, err := loopconvOptions(ws, in.Kinds)
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+
+		state.GetEngine().EnsureTypeChecked(ws, analyzers.SelectPackages(ws, in.Package)...)
+		rr, err := analyzers.Run(ws, loopconv.NewAnalyzer(opts...), in.Package)
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+
+		items := []LoopConversionItem{}
+		if results, ok := rr.Result.([]*loopconv.Result); ok {
+			for _, v := range results {
+				items = append(items, LoopConversionItem{
+					File:        v.File,
+					Line:        v.Line,
+					Column:      v.Column,
+					Kind:        v.Kind,
+					Replacement: v.Replacement,
+					Message:     v.Message,
+				})
+			}
+		}
+		return textResult(map[string]any{
+			"violations":  items,
+			"total_count": len(items),
+			"go_version":  moduleGoVersion(ws),
+		}), nil, nil
+	})
+
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "fix_loop_conversions",
+		Description: "Replace what detect_loop_conversions reports with the slices and maps calls, as a reviewable plan, adding the slices, maps and cmp imports and dropping the sort imports left unused.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in FixLoopConversionsInput) (*mcpsdk.CallToolResult, any, error) {
+		state.RLock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+		opts, err := loopconvOptions(ws, in.Kinds)
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+
+		state.GetEngine().EnsureTypeChecked(ws, analyzers.SelectPackages(ws, in.Package)...)
+		rr, err := analyzers.Run(ws, loopconv.NewAnalyzer(opts...), in.Package)
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+
+		changes := analyzers.DiagnosticsToChanges(ws.FileSet, rr.Diagnostics)
+		if len(changes) == 0 {
+			state.RUnlock()
+			return textResult(map[string]any{
+				"files_modified": []string{},
+				"changes_count":  0,
+				"message":        "No loops to convert",
+			}), nil, nil
+		}
+		analyzers.CheckChanges(ws, changes)
+		plan := analyzers.ChangesToPlan(changes)
+		imports := refactor.NewImportManager(ws, state.ProjectConfig().AliasRules())
+		imports.Offer("slices", "maps", "cmp")
+		imports.Update(plan)
+		result, err := previewPlanWithUnlock(ctx, state, plan, "Convert loops", in.Preview)
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		return textResult(result), nil, nil
+	})
+
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "analyze_dependencies",
+		Description: "Analyze the dependency graph of the workspace. Optionally detect backwards dependencies and suggest moves, or export the graph as a Graphviz or Mermaid diagram to paste into docs or PRs; layers come from the layers section of .gorefactor.yaml, else from package naming conventions.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in AnalyzeDependenciesInput) (*mcpsdk.CallToolResult, any, error) {
+		state.RLock()
+		defer state.RUnlock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		format := in.Format
+		if format == "json" {
+			format = ""
+		}
+		layers := state.ProjectConfig().Layers
+		plan, err := state.GetEngine().AnalyzeDependencies(ws, types.AnalyzeDependenciesRequest{
+			Workspace:           ws.RootPath,
+			DetectBackwardsDeps: in.DetectBackwards,
+			SuggestMoves:        in.SuggestMoves,
+			Format:              format,
+			DomainLayer:         layers.Domain,
+			InfrastructureLayer: layers.Infrastructure,
+			ApplicationLayer:    layers.Application,
+		})
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		if format != "" {
+			return &mcpsdk.CallToolResult{Content: []mcpsdk.Content{&mcpsdk.TextContent{Text: plan.Changes[0].NewText}}}, nil, nil
+		}
+		return textResult(map[string]any{
+			"affected_files":   plan.AffectedFiles,
+			"change_count":     len(plan.Changes),
+			"impact":           plan.Impact,
+			"dependency_graph": ws.Dependencies,
+		}), nil, nil
+	})
+}
+
+// moduleGoVersion returns the Go version of the go directive of the
+// workspace's go.mod, or "" if it has none.
+func moduleGoVersion(ws *types.Workspace) string {
+	if ws.Module == nil {
+		return ""
+	}
+	return ws.Module.GoVersion
+}
+
+// modernizeOptions configures the modernize analyzer for the workspace's
+// Go version, limited to checks if any are given.
+func modernizeOptions(ws *types.Workspace, checks []string) ([]modernize.Option, error) {
+	for _, c := range checks {
+		if !modernize.IsCheck(c) {
+			return nil, fmt.Errorf("unknown check %q", c)
+		}
+	}
+	return []modernize.Option{modernize.WithGoVersion(moduleGoVersion(ws)), modernize.WithChecks(checks...)}, nil
+}
+
+// loopconvOptions configures the loopconv analyzer for the workspace's Go
+// version, limited to kinds if any are given.
+func loopconvOptions(ws *types.Workspace, kinds []string) ([]loopconv.Option, error) {
+	for _, k := range kinds {
+		if !slices.Contains(loopconv.Kinds, k) {
+			return nil, fmt.Errorf("unknown conversion %q", k)
+		}
+	}
+	return []loopconv.Option{loopconv.WithGoVersion(moduleGoVersion(ws)), loopconv.WithKinds(kinds...)}, nil
 }
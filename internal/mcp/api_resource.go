@@ -0,0 +1,68 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strings"
+
+	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/mamaar/gorefactor/pkg/analysis"
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// apiResourcePrefix starts the URI of a package's API resource; the
+// package follows, as an import path, a directory or a package name.
+const apiResourcePrefix = "workspace://api/"
+
+func registerAPIResource(s *mcpsdk.Server, state *MCPServer) {
+	s.AddResourceTemplate(&mcpsdk.ResourceTemplate{
+		Name: "package_api",
+		// {+package} lets the import path keep its slashes unescaped.
+		URITemplate: apiResourcePrefix + "{+package}",
+		MIMEType:    "application/json",
+		Description: "Public API surface of a workspace package: its exported functions, types with their methods, constants and variables, with signatures and doc comments. Read it to learn a package's contract before moving symbols out of it or extracting interfaces. The package is an import path, a directory relative to the workspace root, or a unique package name.",
+	}, func(ctx context.Context, req *mcpsdk.ReadResourceRequest) (*mcpsdk.ReadResourceResult, error) {
+		state.RLock()
+		defer state.RUnlock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			return nil, err
+		}
+		pkg, err := resourcePackage(ws, req.Params.URI, apiResourcePrefix)
+		if err != nil {
+			return nil, err
+		}
+		return jsonResource(req.Params.URI, analysis.ExtractPackageAPI(ws, pkg))
+	})
+}
+
+// resourcePackage returns the package named by the rest of uri after prefix.
+func resourcePackage(ws *types.Workspace, uri, prefix string) (*types.Package, error) {
+	name, err := url.PathUnescape(strings.TrimPrefix(uri, prefix))
+	if err != nil || name == "" {
+		return nil, mcpsdk.ResourceNotFoundError(uri)
+	}
+	dir, ok := ws.ImportToPath[name]
+	if !ok {
+		dir = types.ResolvePackagePath(ws, name)
+	}
+	pkg := ws.Packages[dir]
+	if pkg == nil {
+		return nil, mcpsdk.ResourceNotFoundError(uri)
+	}
+	return pkg, nil
+}
+
+// jsonResource returns v as the JSON contents of uri.
+func jsonResource(uri string, v any) (*mcpsdk.ReadResourceResult, error) {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return &mcpsdk.ReadResourceResult{
+		Contents: []*mcpsdk.ResourceContents{{URI: uri, MIMEType: "application/json", Text: string(b)}},
+	}, nil
+}
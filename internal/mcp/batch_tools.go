@@ -11,14 +11,17 @@ import (
 // --- batch_operations ---
 
 type BatchOperationsInput struct {
-	Operations        []string `json:"operations" jsonschema:"list of refactoring command strings to execute as a batch"`
-	RollbackOnFailure bool     `json:"rollback_on_failure,omitempty" jsonschema:"rollback all operations if any single operation fails"`
+	Operations        []string          `json:"operations,omitempty" jsonschema:"list of refactoring command strings to execute as a batch"`
+	Steps             []types.BatchStep `json:"steps,omitempty" jsonschema:"named steps, each with an operation name, its request and the steps it depends on"`
+	File              string            `json:"file,omitempty" jsonschema:"YAML or JSON batch file relative to the workspace root, instead of operations or steps"`
+	RollbackOnFailure bool              `json:"rollback_on_failure,omitempty" jsonschema:"fail the whole batch if any step fails, including optional ones"`
+	AllowBreaking     *bool             `json:"allow_breaking,omitempty" jsonschema:"allow or forbid breaking changes for this batch, overriding allow_breaking and breaking_policy of the project config"`
 }
 
 func registerBatchTools(s *mcpsdk.Server, state *MCPServer) {
 	mcpsdk.AddTool(s, &mcpsdk.Tool{
 		Name:        "batch_operations",
-		Description: "Execute multiple refactoring operations as an atomic batch. Optionally rolls back all changes if any operation fails.",
+		Description: "Execute multiple refactoring operations as an atomic batch. Each step is planned against the code the earlier steps produce, and nothing is written unless every required step succeeds. Steps take the same requests as the individual tools and may depend on each other by name.",
 	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in BatchOperationsInput) (*mcpsdk.CallToolResult, any, error) {
 		state.RLock()
 
@@ -29,14 +32,17 @@ func registerBatchTools(s *mcpsdk.Server, state *MCPServer) {
 		}
 		plan, err := state.GetEngine().BatchOperations(ws, types.BatchOperationRequest{
 			Operations:        in.Operations,
+			Steps:             in.Steps,
+			File:              in.File,
 			RollbackOnFailure: in.RollbackOnFailure,
 			DryRun:            false,
+			AllowBreaking:     in.AllowBreaking,
 		})
 		if err != nil {
 			state.RUnlock()
 			return errResult(err), nil, nil
 		}
-		result, err := executePlanWithUnlock(state, plan, "batch operations")
+		result, err := executePlanWithUnlock(ctx, state, plan, "batch operations")
 		if err != nil {
 			return errResult(err), nil, nil
 		}
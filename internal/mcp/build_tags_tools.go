@@ -0,0 +1,54 @@
+package mcp
+
+import (
+	"context"
+
+	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// --- build_tags ---
+
+type BuildTagsInput struct {
+	MigrateLegacy bool              `json:"migrate_legacy,omitempty" jsonschema:"replace '// +build' lines with an equivalent '//go:build' line"`
+	KeepLegacy    bool              `json:"keep_legacy,omitempty" jsonschema:"keep '// +build' lines in sync with '//go:build' for Go versions before 1.17"`
+	RenameTags    map[string]string `json:"rename_tags,omitempty" jsonschema:"custom build tags to rename, old name to new name"`
+	FileSuffixes  string            `json:"file_suffixes,omitempty" jsonschema:"'add' renames files constrained only by GOOS/GOARCH to use _GOOS_GOARCH suffixes; 'remove' turns suffixes into //go:build lines"`
+	Package       string            `json:"package,omitempty" jsonschema:"restrict to a specific package (empty for workspace-wide)"`
+}
+
+func registerBuildTagsTools(s *mcpsdk.Server, state *MCPServer) {
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "build_tags",
+		Description: "Migrate '// +build' lines to '//go:build', rename custom build tags, and move GOOS/GOARCH constraints into or out of file name suffixes across the workspace. -tags flags and file references in Makefiles, scripts and CI configs are updated too.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in BuildTagsInput) (*mcpsdk.CallToolResult, any, error) {
+		state.RLock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+		pkgPath := in.Package
+		if pkgPath != "" {
+			pkgPath = types.ResolvePackagePath(ws, pkgPath)
+		}
+		plan, err := state.GetEngine().BuildTags(ws, types.BuildTagsRequest{
+			MigrateLegacy: in.MigrateLegacy,
+			KeepLegacy:    in.KeepLegacy,
+			RenameTags:    in.RenameTags,
+			FileSuffixes:  types.FileSuffixMode(in.FileSuffixes),
+			Package:       pkgPath,
+		})
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+		result, err := executePlanWithUnlock(ctx, state, plan, "build tags")
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		return textResult(result), nil, nil
+	})
+}
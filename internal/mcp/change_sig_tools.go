@@ -20,12 +20,15 @@ type ParamSpec struct {
 type ChangeSignatureInput struct {
 	FunctionName string     `json:"function_name" jsonschema:"function or method name (use Type.Method for methods)"`
 	SourceFile   string     `json:"source_file" jsonschema:"file containing the function"`
-	Subcommand   string     `json:"subcommand" jsonschema:"operation: add_param, remove_param, add_return, or remove_return"`
+	Subcommand   string     `json:"subcommand" jsonschema:"operation: add_param, remove_param, add_return, remove_return, or reorder_returns"`
 	Params       []ParamSpec `json:"params,omitempty" jsonschema:"full new parameter list (for add_param/remove_param)"`
 	Returns      []string   `json:"returns,omitempty" jsonschema:"full new return type list (for add_return/remove_return)"`
 	DefaultValue string     `json:"default_value,omitempty" jsonschema:"default value for new parameter at call sites"`
 	Position     int        `json:"position,omitempty" jsonschema:"position index of the new parameter (for add_param)"`
+	ReturnOrder  []int      `json:"return_order,omitempty" jsonschema:"new order of the results as old indices, e.g. [1, 0] swaps two (for reorder_returns)"`
 	Propagate    bool       `json:"propagate,omitempty" jsonschema:"propagate changes to interface declarations and sibling implementations"`
+
+	CheckDependents bool `json:"check_dependents,omitempty" jsonschema:"report the uses of the function in the dependent repositories of .gorefactor.yaml, which the change breaks"`
 }
 
 func registerChangeSignatureTools(s *mcpsdk.Server, state *MCPServer) {
@@ -36,6 +39,7 @@ func registerChangeSignatureTools(s *mcpsdk.Server, state *MCPServer) {
 - remove_param: remove a parameter (provide params list without the removed param)
 - add_return: add a new return value (provide returns list with the new type included)
 - remove_return: remove a return value (provide returns list without the removed type)
+- reorder_returns: reorder the return values (provide return_order); return statements and the assignments from calls are permuted to match
 All call sites are updated automatically.`,
 	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in ChangeSignatureInput) (*mcpsdk.CallToolResult, any, error) {
 		state.RLock()
@@ -66,7 +70,9 @@ All call sites are updated automatically.`,
 			PropagateToInterface: in.Propagate,
 			DefaultValue:         in.DefaultValue,
 			NewParamPosition:     in.Position,
+			ReturnOrder:          in.ReturnOrder,
 			CachedIndex:          idx,
+			CheckDependents:      in.CheckDependents,
 		})
 		if err != nil {
 			state.RUnlock()
@@ -75,7 +81,7 @@ All call sites are updated automatically.`,
 
 		state.RUnlock()
 
-		result, err := executePlan(state, plan, fmt.Sprintf("change signature: %s %s", in.Subcommand, in.FunctionName))
+		result, err := executePlan(ctx, state, plan, fmt.Sprintf("change signature: %s %s", in.Subcommand, in.FunctionName))
 		if err != nil {
 			return errResult(err), nil, nil
 		}
@@ -0,0 +1,287 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/mamaar/gorefactor/internal/telemetry"
+	"github.com/mamaar/gorefactor/pkg/analysis"
+	"github.com/mamaar/gorefactor/pkg/types"
+	"github.com/mamaar/gorefactor/pkg/watch"
+)
+
+// Agents issue tool calls concurrently, and the calls of a session share
+// its workspace. They are kept from corrupting it as follows:
+//
+//   - The workspace is published in generations, snapshots that are not
+//     changed once published. A change, the watcher's or the syncing of an
+//     applied plan, is made to a copy of the current generation sharing the
+//     packages it leaves alone, which is then published as the next
+//     generation, recording the files whose contents changed. Changes hold
+//     the session's update lock, so they are made one at a time.
+//   - Read-only analyses work on the generation current when they start,
+//     holding the read lock, which keeps only the session's settings still.
+//     Neither they nor changes wait for the other. The parts filled in
+//     lazily, type information and missing symbol tables, are filled under
+//     the engine's own locks, so readers of generations sharing a package
+//     never write it at the same time.
+//   - Applying a plan takes the session's writer slot, so applies queue and
+//     run one at a time in the order they arrive. A plan editing a file
+//     that changed after the generation its tool call started at is refused
+//     with a StaleWorkspace error, as its offsets are into contents that are
+//     gone.
+//   - Staged plans are the exception: they change the staged workspace in
+//     place, so staging a plan, and a change made while plans are staged,
+//     also hold the write lock, and readers arriving meanwhile queue behind
+//     them.
+//
+// The update lock is taken before the read-write lock.
+
+// snapshot is a generation of a session's workspace.
+type snapshot struct {
+	workspace  *types.Workspace
+	resolver   *analysis.SymbolResolver // Kept in sync with the workspace
+	generation uint64                   // Advanced by every change to the workspace
+
+	indexMu sync.RWMutex
+	index   *analysis.ReferenceIndex // Updated from the previous generation's, or built on first use
+}
+
+// referenceIndex returns the reference index of the generation, or nil
+// when none was built.
+func (g *snapshot) referenceIndex() *analysis.ReferenceIndex {
+	g.indexMu.RLock()
+	defer g.indexMu.RUnlock()
+	return g.index
+}
+
+// ensureReferenceIndex returns the reference index of the generation,
+// building it if necessary. Uses double-checked locking for thread safety.
+func (g *snapshot) ensureReferenceIndex(logger *slog.Logger) (*analysis.ReferenceIndex, error) {
+	if idx := g.referenceIndex(); idx != nil {
+		return idx, nil
+	}
+	g.indexMu.Lock()
+	defer g.indexMu.Unlock()
+	if g.index != nil {
+		return g.index, nil
+	}
+
+	logger.Info("building reference index for workspace")
+	start := time.Now()
+	idx := g.resolver.BuildReferenceIndex()
+	telemetry.ObserveIndexBuild(time.Since(start), idx != nil)
+	if idx == nil {
+		return nil, fmt.Errorf("failed to build reference index")
+	}
+	g.index = idx
+	return idx, nil
+}
+
+// current returns the current generation of the session's workspace, or
+// nil when none is loaded.
+func (s *session) current() *snapshot {
+	return s.snap.Load()
+}
+
+// workspace returns the workspace of the current generation, or nil.
+func (s *session) workspace() *types.Workspace {
+	if snap := s.current(); snap != nil {
+		return snap.workspace
+	}
+	return nil
+}
+
+// writerQueue admits one plan application at a time, in arrival order.
+type writerQueue struct {
+	slot    chan struct{} // Holds a token while a plan is applied
+	pending atomic.Int32  // Applies waiting for the slot or holding it
+}
+
+func newWriterQueue() *writerQueue {
+	return &writerQueue{slot: make(chan struct{}, 1)}
+}
+
+// acquire waits for the slot, or for ctx to end. The returned function
+// releases it.
+func (q *writerQueue) acquire(ctx context.Context) (func(), error) {
+	q.pending.Add(1)
+	select {
+	case q.slot <- struct{}{}:
+		return func() {
+			<-q.slot
+			q.pending.Add(-1)
+		}, nil
+	case <-ctx.Done():
+		q.pending.Add(-1)
+		return nil, fmt.Errorf("waiting for other plans to be applied: %w", ctx.Err())
+	}
+}
+
+// generationKey is the context key of the generation a call started at.
+type generationKey struct{}
+
+// PlannedAt returns a context that applies plans as made at generation of
+// the workspace: one editing a file changed since is refused. Tool calls
+// get it from the pinGeneration middleware; other callers of ApplyPlan can
+// pass the Generation they planned at.
+func PlannedAt(ctx context.Context, generation uint64) context.Context {
+	return context.WithValue(ctx, generationKey{}, generation)
+}
+
+// Generation returns the generation of the session's workspace, which
+// every change to it advances.
+func (s *MCPServer) Generation() uint64 {
+	if snap := s.current(); snap != nil {
+		return snap.generation
+	}
+	return 0
+}
+
+// pinGeneration is middleware that records the generation of the workspace
+// each tool call starts at, to check the plan it applies against.
+func (s *MCPServer) pinGeneration(next mcpsdk.MethodHandler) mcpsdk.MethodHandler {
+	return func(ctx context.Context, method string, req mcpsdk.Request) (mcpsdk.Result, error) {
+		if _, ok := req.(*mcpsdk.CallToolRequest); ok {
+			ctx = PlannedAt(ctx, s.Generation())
+		}
+		return next(ctx, method, req)
+	}
+}
+
+// checkFresh refuses plan when a file it edits changed after the
+// generation ctx was planned at, if known.
+func (s *MCPServer) checkFresh(ctx context.Context, plan *types.RefactoringPlan) error {
+	planned, ok := ctx.Value(generationKey{}).(uint64)
+	if !ok {
+		return nil
+	}
+	s.updateMu.Lock()
+	defer s.updateMu.Unlock()
+	if planned < s.loadedAt {
+		return &types.RefactorError{Type: types.StaleWorkspace, Message: "the workspace was reloaded while the plan was made; call the tool again"}
+	}
+	for _, c := range plan.Changes {
+		if s.changedAt[c.File] > planned {
+			return &types.RefactorError{Type: types.StaleWorkspace, Message: fmt.Sprintf("%s changed while the plan was made; call the tool again", c.File)}
+		}
+	}
+	return nil
+}
+
+// publish makes next, in which files changed, the current generation.
+// Called with updateMu held.
+func (s *session) publish(next *snapshot, files ...string) {
+	next.generation = 1
+	if prev := s.current(); prev != nil {
+		next.generation = prev.generation + 1
+	}
+	if s.changedAt == nil {
+		s.changedAt = make(map[string]uint64)
+	}
+	for _, f := range files {
+		s.changedAt[f] = next.generation
+	}
+	s.snap.Store(next)
+}
+
+// update applies file events to the workspace of sess, publishing the next
+// generation with the files whose contents they changed: the watcher
+// reports the files an applied plan wrote again after they were synced.
+// The events are applied to a copy of the workspace, except while plans
+// are staged, when they change it in place with mu held for writing.
+func (s *MCPServer) update(sess *session, events []watch.ChangeEvent) {
+	sess.updateMu.Lock()
+	defer sess.updateMu.Unlock()
+	if sess.updater == nil {
+		return
+	}
+	if sess.staged == nil {
+		sess.apply(sess.updater.Fork(), events, s.logger)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess.apply(sess.updater, events, s.logger)
+}
+
+// apply has u handle events and publishes its workspace as the next
+// generation. The reference index is updated for the files rather than
+// rebuilt. Called with updateMu held.
+func (s *session) apply(u *watch.WorkspaceUpdater, events []watch.ChangeEvent, logger *slog.Logger) {
+	prev := s.current()
+	before := make(map[string][]byte, len(events))
+	paths := make([]string, 0, len(events))
+	for _, ev := range events {
+		before[ev.Path] = fileContent(prev.workspace, ev.Path)
+		paths = append(paths, ev.Path)
+	}
+	var typed []string
+	for _, pkg := range prev.workspace.Packages {
+		if pkg.TypesPkg != nil {
+			typed = append(typed, pkg.Path)
+		}
+	}
+	u.HandleChanges(events)
+
+	next := &snapshot{workspace: u.Workspace(), resolver: u.Resolver()}
+	if s.staged != nil {
+		// The updater's resolver missed the staged changes.
+		next.resolver = analysis.NewSymbolResolver(next.workspace, logger)
+	}
+	var changed []string
+	for path, old := range before {
+		content := fileContent(next.workspace, path)
+		if old == nil || content == nil || !bytes.Equal(old, content) {
+			changed = append(changed, path)
+		}
+	}
+	next.index = s.updateReferenceIndex(prev, next, typed, paths)
+	s.updater = u
+	s.publish(next, changed...)
+	s.queries.invalidate()
+}
+
+// updateReferenceIndex returns the reference index of next, in which the
+// files at paths changed, updated from that of prev rather than rebuilt,
+// or nil when prev has none. The packages at typed, which had type
+// information in prev, are type-checked again first, as the watcher drops
+// the information of changed packages and their importers.
+func (s *session) updateReferenceIndex(prev, next *snapshot, typed, paths []string) *analysis.ReferenceIndex {
+	idx := prev.referenceIndex()
+	if idx == nil {
+		return nil
+	}
+	var recheck []*types.Package
+	for _, path := range typed {
+		if pkg := next.workspace.Packages[path]; pkg != nil && pkg.TypesPkg == nil {
+			recheck = append(recheck, pkg)
+		}
+	}
+	s.engine.EnsureTypeChecked(next.workspace, recheck...)
+	return next.resolver.UpdateReferenceIndex(idx, paths)
+}
+
+// fileContent returns the contents the workspace holds for path, or nil.
+func fileContent(ws *types.Workspace, path string) []byte {
+	pkg := ws.Packages[filepath.Dir(path)]
+	if pkg == nil {
+		return nil
+	}
+	base := filepath.Base(path)
+	if f := pkg.Files[base]; f != nil {
+		return f.OriginalContent
+	}
+	if f := pkg.TestFiles[base]; f != nil {
+		return f.OriginalContent
+	}
+	return nil
+}
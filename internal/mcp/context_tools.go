@@ -20,6 +20,15 @@ type AddContextParameterInput struct {
 	Propagate    bool   `json:"propagate,omitempty" jsonschema:"propagate changes to interface declarations and sibling implementations"`
 }
 
+// --- thread_context ---
+
+type ThreadContextInput struct {
+	FunctionName string `json:"function_name" jsonschema:"function or method name (use Type.Method for methods)"`
+	Package      string `json:"package" jsonschema:"package declaring the function"`
+	Root         string `json:"root,omitempty" jsonschema:"caller to stop at (use Type.Method for methods); only callers on a call path from it get ctx. Default: all callers"`
+	DefaultValue string `json:"default_value,omitempty" jsonschema:"value at call sites outside the chain, defaults to context.TODO()"`
+}
+
 func registerContextTools(s *mcpsdk.Server, state *MCPServer) {
 	mcpsdk.AddTool(s, &mcpsdk.Tool{
 		Name: "add_context_parameter",
@@ -93,11 +102,39 @@ Pairs with detect_missing_context_params to find functions that need it.`,
 
 		state.RUnlock()
 
-		result, err := executePlan(state, plan, fmt.Sprintf("add context parameter: %s", in.FunctionName))
+		result, err := executePlan(ctx, state, plan, fmt.Sprintf("add context parameter: %s", in.FunctionName))
 		if err != nil {
 			return errResult(err), nil, nil
 		}
 		return textResult(result), nil, nil
 	})
-}
 
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name: "thread_context",
+		Description: `Thread ctx context.Context through a function and its transitive callers up to a root: adds the parameter to each function in the chain, passes ctx at every call site, and replaces context.TODO()/context.Background() inside the chain with the parameter.
+Callers that already accept a context pass theirs; main and init pass context.Background(). Builds on detect_missing_context_params.`,
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in ThreadContextInput) (*mcpsdk.CallToolResult, any, error) {
+		state.RLock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+		plan, err := state.GetEngine().ThreadContext(ws, pkgtypes.ThreadContextRequest{
+			FunctionName: in.FunctionName,
+			Package:      pkgtypes.ResolvePackagePath(ws, in.Package),
+			Root:         in.Root,
+			DefaultValue: in.DefaultValue,
+		})
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+		result, err := executePlanWithUnlock(ctx, state, plan, "thread context through "+in.FunctionName)
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		return textResult(result), nil, nil
+	})
+}
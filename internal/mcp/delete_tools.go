@@ -38,7 +38,7 @@ func registerDeleteTools(s *mcpsdk.Server, state *MCPServer) {
 			state.RUnlock()
 			return errResult(err), nil, nil
 		}
-		result, err := executePlanWithUnlock(state, plan, "safe delete "+in.Symbol)
+		result, err := executePlanWithUnlock(ctx, state, plan, "safe delete "+in.Symbol)
 		if err != nil {
 			return errResult(err), nil, nil
 		}
@@ -1,10 +1,14 @@
 package mcp
 
 import (
+	"cmp"
 	"context"
+	"fmt"
+	"strings"
 
 	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
 
+	"github.com/mamaar/gorefactor/pkg/config"
 	"github.com/mamaar/gorefactor/pkg/types"
 )
 
@@ -19,18 +23,38 @@ type MoveByDependenciesInput struct {
 // --- organize_by_layers ---
 
 type OrganizeByLayersInput struct {
-	DomainLayer         string `json:"domain_layer,omitempty" jsonschema:"directory for domain layer (e.g. modules/)"`
-	InfrastructureLayer string `json:"infrastructure_layer,omitempty" jsonschema:"directory for infrastructure layer (e.g. pkg/)"`
-	ApplicationLayer    string `json:"application_layer,omitempty" jsonschema:"directory for application layer (e.g. internal/)"`
-	ReorderImports      bool   `json:"reorder_imports,omitempty" jsonschema:"whether to reorder imports according to layers"`
+	DomainLayer         string            `json:"domain_layer,omitempty" jsonschema:"directory for domain layer (e.g. modules/; default: layers.domain from .gorefactor.yaml)"`
+	InfrastructureLayer string            `json:"infrastructure_layer,omitempty" jsonschema:"directory for infrastructure layer (e.g. pkg/; default: layers.infrastructure from .gorefactor.yaml)"`
+	ApplicationLayer    string            `json:"application_layer,omitempty" jsonschema:"directory for application layer (e.g. internal/; default: layers.application from .gorefactor.yaml)"`
+	ReorderImports      bool              `json:"reorder_imports,omitempty" jsonschema:"whether to reorder imports according to layers"`
+	Layers              map[string]string `json:"layers,omitempty" jsonschema:"layer (domain, infrastructure or application) of packages by directory relative to the workspace root, as infer_layers proposes; takes precedence over the layer directories"`
 }
 
+// --- infer_layers ---
+
+type InferLayersInput struct{}
+
 // --- fix_cycles ---
 
 type FixCyclesInput struct {
 	AutoFix bool `json:"auto_fix,omitempty" jsonschema:"attempt to automatically fix detected cycles"`
 }
 
+// --- call_graph ---
+
+type CallGraphInput struct {
+	Root   string `json:"root,omitempty" jsonschema:"package whose functions start the graph: import path, directory relative to the workspace root, or package name (default: the whole workspace)"`
+	Depth  int    `json:"depth,omitempty" jsonschema:"calls to follow from the root package (default: all)"`
+	Format string `json:"format,omitempty" jsonschema:"'json' (default) for nodes and edges, 'dot' for a Graphviz digraph"`
+}
+
+// --- check_architecture ---
+
+type CheckArchitectureInput struct {
+	Package string             `json:"package,omitempty" jsonschema:"only check this package: import path, directory relative to the workspace root, or package name (default: every package)"`
+	Rules   []types.ImportRule `json:"rules,omitempty" jsonschema:"rules to check instead of the architecture section of .gorefactor.yaml"`
+}
+
 func registerDependencyTools(s *mcpsdk.Server, state *MCPServer) {
 	mcpsdk.AddTool(s, &mcpsdk.Tool{
 		Name:        "move_by_dependencies",
@@ -62,7 +86,7 @@ func registerDependencyTools(s *mcpsdk.Server, state *MCPServer) {
 				"impact":         plan.Impact,
 			}), nil, nil
 		}
-		result, err := executePlanWithUnlock(state, plan, "move by dependencies")
+		result, err := executePlanWithUnlock(ctx, state, plan, "move by dependencies")
 		if err != nil {
 			return errResult(err), nil, nil
 		}
@@ -73,6 +97,8 @@ func registerDependencyTools(s *mcpsdk.Server, state *MCPServer) {
 		Name:        "organize_by_layers",
 		Description: "Organize packages according to an architectural layer structure (domain, infrastructure, application).",
 	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in OrganizeByLayersInput) (*mcpsdk.CallToolResult, any, error) {
+		defer state.trackProgress(ctx, req)()
+
 		state.RLock()
 
 		ws, err := state.GetWorkspace()
@@ -80,24 +106,51 @@ func registerDependencyTools(s *mcpsdk.Server, state *MCPServer) {
 			state.RUnlock()
 			return errResult(err), nil, nil
 		}
-		plan, err := state.GetEngine().OrganizeByLayers(ws, types.OrganizeByLayersRequest{
+		layers := state.ProjectConfig().Layers
+		plan, err := state.GetEngine().OrganizeByLayersContext(ctx, ws, types.OrganizeByLayersRequest{
 			Workspace:           ws.RootPath,
-			DomainLayer:         in.DomainLayer,
-			InfrastructureLayer: in.InfrastructureLayer,
-			ApplicationLayer:    in.ApplicationLayer,
+			DomainLayer:         cmp.Or(in.DomainLayer, layers.Domain),
+			InfrastructureLayer: cmp.Or(in.InfrastructureLayer, layers.Infrastructure),
+			ApplicationLayer:    cmp.Or(in.ApplicationLayer, layers.Application),
 			ReorderImports:      in.ReorderImports,
+			Layers:              in.Layers,
 		})
 		if err != nil {
 			state.RUnlock()
 			return errResult(err), nil, nil
 		}
-		result, err := executePlanWithUnlock(state, plan, "organize by layers")
+		result, err := executePlanWithUnlock(ctx, state, plan, "organize by layers")
 		if err != nil {
 			return errResult(err), nil, nil
 		}
 		return textResult(result), nil, nil
 	})
 
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "infer_layers",
+		Description: "Propose an architectural layer (domain, infrastructure or application) for every package from the import graph: its stratum, how many workspace packages import it and it imports, and whether it does I/O. Each assignment says why; review or edit the returned layers and pass them to organize_by_layers.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in InferLayersInput) (*mcpsdk.CallToolResult, any, error) {
+		state.RLock()
+		defer state.RUnlock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		assignments, err := state.GetEngine().InferLayers(ws)
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		layers := make(map[string]string, len(assignments))
+		for _, a := range assignments {
+			layers[a.Package] = a.Layer
+		}
+		return textResult(map[string]any{
+			"assignments": assignments,
+			"layers":      layers,
+		}), nil, nil
+	})
+
 	mcpsdk.AddTool(s, &mcpsdk.Tool{
 		Name:        "fix_cycles",
 		Description: "Detect and fix circular dependencies in the package graph.",
@@ -122,13 +175,86 @@ func registerDependencyTools(s *mcpsdk.Server, state *MCPServer) {
 			return textResult(map[string]any{
 				"description":  "cycle detection",
 				"cycles_found": len(plan.Changes),
+				"report":       plan.Changes[0].NewText,
 				"impact":       plan.Impact,
 			}), nil, nil
 		}
-		result, err := executePlanWithUnlock(state, plan, "fix cycles")
+		result, err := executePlanWithUnlock(ctx, state, plan, "fix cycles")
 		if err != nil {
 			return errResult(err), nil, nil
 		}
 		return textResult(result), nil, nil
 	})
+
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "call_graph",
+		Description: "Static call graph of the workspace: which functions and methods call which, with call counts. Calls through an interface method also lead, as dynamic edges, to the workspace methods implementing it. Start from a root package to see what its code reaches, optionally only a few calls deep. Useful to see what a move would drag along or which package a function's callers live in.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in CallGraphInput) (*mcpsdk.CallToolResult, any, error) {
+		state.RLock()
+		defer state.RUnlock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		if in.Format != "" && in.Format != "json" && in.Format != "dot" {
+			return errResult(fmt.Errorf("unknown format %q: want json or dot", in.Format)), nil, nil
+		}
+		root := in.Root
+		if root != "" {
+			if dir, ok := ws.ImportToPath[root]; ok {
+				root = dir
+			} else {
+				root = types.ResolvePackagePath(ws, root)
+			}
+		}
+		graph, err := state.GetEngine().CallGraph(ws, types.CallGraphRequest{Root: root, Depth: in.Depth})
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		if in.Format == "dot" {
+			var dot strings.Builder
+			if err := graph.WriteDOT(&dot); err != nil {
+				return errResult(err), nil, nil
+			}
+			return &mcpsdk.CallToolResult{Content: []mcpsdk.Content{&mcpsdk.TextContent{Text: dot.String()}}}, nil, nil
+		}
+		return textResult(graph), nil, nil
+	})
+
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "check_architecture",
+		Description: "Check the imports of the workspace packages against architecture rules, by default those of the architecture section of .gorefactor.yaml (e.g. internal/domain/... must not import internal/http/...). Each violation names the offending import line and, for a workspace package, the move_symbol calls that would bring the symbols the file uses from it into the importing package.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in CheckArchitectureInput) (*mcpsdk.CallToolResult, any, error) {
+		state.RLock()
+		defer state.RUnlock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		rules := in.Rules
+		if len(rules) == 0 {
+			rules = state.ProjectConfig().ImportRules()
+		}
+		if len(rules) == 0 {
+			return errResult(fmt.Errorf("no architecture rules: pass rules or add an architecture section to %s", config.FileName)), nil, nil
+		}
+		pkg := in.Package
+		if pkg != "" {
+			if dir, ok := ws.ImportToPath[pkg]; ok {
+				pkg = dir
+			} else {
+				pkg = types.ResolvePackagePath(ws, pkg)
+			}
+		}
+		violations, err := state.GetEngine().CheckArchitecture(ws, types.CheckArchitectureRequest{Rules: rules, Package: pkg})
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		return textResult(map[string]any{
+			"violations": violations,
+			"count":      len(violations),
+		}), nil, nil
+	})
 }
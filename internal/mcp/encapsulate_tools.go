@@ -0,0 +1,58 @@
+package mcp
+
+import (
+	"context"
+
+	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// --- encapsulate_field ---
+
+type EncapsulateFieldInput struct {
+	TypeName     string `json:"type_name" jsonschema:"name of the struct type that declares the field"`
+	FieldName    string `json:"field_name" jsonschema:"field to encapsulate"`
+	PackagePath  string `json:"package_path,omitempty" jsonschema:"package path of the type (empty for workspace-wide)"`
+	KeepField    bool   `json:"keep_field,omitempty" jsonschema:"keep the field's name and visibility instead of unexporting it"`
+	NewFieldName string `json:"new_field_name,omitempty" jsonschema:"new field name (default: field name with a lowercase first letter)"`
+	GetterName   string `json:"getter_name,omitempty" jsonschema:"getter method name (default: Get<Field>)"`
+	SetterName   string `json:"setter_name,omitempty" jsonschema:"setter method name (default: Set<Field>)"`
+}
+
+func registerEncapsulateTools(s *mcpsdk.Server, state *MCPServer) {
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "encapsulate_field",
+		Description: "Unexport a struct field, generate Get/Set accessors and rewrite direct accesses from other packages to use them. Accesses that can't be converted (composite literals, taking the address) are returned as warnings.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in EncapsulateFieldInput) (*mcpsdk.CallToolResult, any, error) {
+		state.RLock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+		pkgPath := in.PackagePath
+		if pkgPath != "" {
+			pkgPath = types.ResolvePackagePath(ws, pkgPath)
+		}
+		plan, err := state.GetEngine().EncapsulateField(ws, types.EncapsulateFieldRequest{
+			TypeName:     in.TypeName,
+			FieldName:    in.FieldName,
+			Package:      pkgPath,
+			KeepField:    in.KeepField,
+			NewFieldName: in.NewFieldName,
+			GetterName:   in.GetterName,
+			SetterName:   in.SetterName,
+		})
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+		result, err := executePlanWithUnlock(ctx, state, plan, "encapsulate "+in.TypeName+"."+in.FieldName)
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		return textResult(result), nil, nil
+	})
+}
@@ -33,8 +33,10 @@ type ExtractFunctionInput struct {
 type ExtractInterfaceInput struct {
 	SourceStruct  string   `json:"source_struct" jsonschema:"name of the struct to extract methods from"`
 	InterfaceName string   `json:"interface_name" jsonschema:"name for the new interface"`
-	Methods       []string `json:"methods" jsonschema:"list of method names to include in the interface"`
+	Methods       []string `json:"methods,omitempty" jsonschema:"list of method names to include in the interface; when omitted, the methods the adopted declarations are used for"`
 	TargetPackage string   `json:"target_package,omitempty" jsonschema:"package to place the new interface in (empty for same package)"`
+	TargetFile    string   `json:"target_file,omitempty" jsonschema:"file of the target package to declare the interface in, appended to when it exists (default interfaces.go)"`
+	Adopt         []string `json:"adopt,omitempty" jsonschema:"parameters, results, fields and variables of the struct's type to retype to the interface: Func.param, Type.Method.param, Type.Field or Var, optionally prefixed with package:"`
 }
 
 // --- extract_variable ---
@@ -47,6 +49,25 @@ type ExtractVariableInput struct {
 	Expression   string `json:"expression,omitempty" jsonschema:"the expression text to extract (helps disambiguation)"`
 }
 
+// --- extract_pipeline_stages ---
+
+type ExtractPipelineStagesInput struct {
+	SourceFile string   `json:"source_file" jsonschema:"path to the source file"`
+	Line       int      `json:"line" jsonschema:"line of the for statement"`
+	StageNames []string `json:"stage_names,omitempty" jsonschema:"function name of each stage in order (empty entries are derived from the values the stage passes on)"`
+	MaxLive    int      `json:"max_live,omitempty" jsonschema:"maximum number of values crossing a stage boundary (default 2)"`
+}
+
+// --- extract_test_helper ---
+
+type ExtractTestHelperInput struct {
+	Package       string `json:"package" jsonschema:"package whose tests share setup"`
+	Test          string `json:"test,omitempty" jsonschema:"only extract the setup shared with this test"`
+	HelperName    string `json:"helper_name,omitempty" jsonschema:"name for the helper (default setup plus the common prefix of the test names)"`
+	TestMain      bool   `json:"test_main,omitempty" jsonschema:"move the setup into TestMain instead of a t.Helper() function"`
+	MinStatements int    `json:"min_statements,omitempty" jsonschema:"minimum number of shared leading statements (default 2)"`
+}
+
 func resolveFile(ws *types.Workspace, path string) string {
 	if filepath.IsAbs(path) {
 		return path
@@ -78,7 +99,7 @@ func registerExtractTools(s *mcpsdk.Server, state *MCPServer) {
 			return errResult(err), nil, nil
 		}
 		// executePlanWithUnlock releases the read lock, so no defer RUnlock needed
-		result, err := executePlanWithUnlock(state, plan, "extract method "+in.NewMethodName)
+		result, err := executePlanWithUnlock(ctx, state, plan, "extract method "+in.NewMethodName)
 		if err != nil {
 			return errResult(err), nil, nil
 		}
@@ -106,7 +127,7 @@ func registerExtractTools(s *mcpsdk.Server, state *MCPServer) {
 			state.RUnlock()
 			return errResult(err), nil, nil
 		}
-		result, err := executePlanWithUnlock(state, plan, "extract function "+in.NewFunctionName)
+		result, err := executePlanWithUnlock(ctx, state, plan, "extract function "+in.NewFunctionName)
 		if err != nil {
 			return errResult(err), nil, nil
 		}
@@ -115,7 +136,7 @@ func registerExtractTools(s *mcpsdk.Server, state *MCPServer) {
 
 	mcpsdk.AddTool(s, &mcpsdk.Tool{
 		Name:        "extract_interface",
-		Description: "Extract an interface from a struct's method set. The new interface contains the specified methods.",
+		Description: "Extract an interface from a struct's method set. The new interface contains the specified methods and is appended to the target file when it exists. Declarations listed in adopt are retyped from the struct to the interface; without methods, the interface gets exactly the methods they are used for.",
 	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in ExtractInterfaceInput) (*mcpsdk.CallToolResult, any, error) {
 		state.RLock()
 
@@ -133,12 +154,14 @@ func registerExtractTools(s *mcpsdk.Server, state *MCPServer) {
 			InterfaceName: in.InterfaceName,
 			Methods:       in.Methods,
 			TargetPackage: tgt,
+			TargetFile:    in.TargetFile,
+			Adopt:         in.Adopt,
 		})
 		if err != nil {
 			state.RUnlock()
 			return errResult(err), nil, nil
 		}
-		result, err := executePlanWithUnlock(state, plan, "extract interface "+in.InterfaceName)
+		result, err := executePlanWithUnlock(ctx, state, plan, "extract interface "+in.InterfaceName)
 		if err != nil {
 			return errResult(err), nil, nil
 		}
@@ -167,7 +190,64 @@ func registerExtractTools(s *mcpsdk.Server, state *MCPServer) {
 			state.RUnlock()
 			return errResult(err), nil, nil
 		}
-		result, err := executePlanWithUnlock(state, plan, "extract variable "+in.VariableName)
+		result, err := executePlanWithUnlock(ctx, state, plan, "extract variable "+in.VariableName)
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		return textResult(result), nil, nil
+	})
+
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "extract_pipeline_stages",
+		Description: "Extract the produce, transform and consume stages of a long loop into functions called in sequence. Each function takes the values its stage reads and returns those later stages read; stages that continue the loop also return whether the iteration goes on. Stages assigning variables declared outside them stay inline.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in ExtractPipelineStagesInput) (*mcpsdk.CallToolResult, any, error) {
+		state.RLock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+		plan, err := state.GetEngine().ExtractPipelineStages(ws, types.ExtractPipelineStagesRequest{
+			SourceFile: resolveFile(ws, in.SourceFile),
+			Line:       in.Line,
+			StageNames: in.StageNames,
+			MaxLive:    in.MaxLive,
+		})
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+		result, err := executePlanWithUnlock(ctx, state, plan, "extract pipeline stages")
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		return textResult(result), nil, nil
+	})
+
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "extract_test_helper",
+		Description: "Extract setup statements repeated at the start of several tests into a t.Helper() function returning the values the tests use, or into TestMain. Deferred teardown becomes t.Cleanup in helpers and runs after m.Run() in TestMain.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in ExtractTestHelperInput) (*mcpsdk.CallToolResult, any, error) {
+		state.RLock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+		plan, err := state.GetEngine().ExtractTestHelper(ws, types.ExtractTestHelperRequest{
+			Package:       types.ResolvePackagePath(ws, in.Package),
+			Test:          in.Test,
+			HelperName:    in.HelperName,
+			TestMain:      in.TestMain,
+			MinStatements: in.MinStatements,
+		})
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+		result, err := executePlanWithUnlock(ctx, state, plan, "extract test helper")
 		if err != nil {
 			return errResult(err), nil, nil
 		}
@@ -27,6 +27,8 @@ type GenerateFacadesInput struct {
 	ModulesDir  string   `json:"modules_dir" jsonschema:"directory containing the modules to generate facades for"`
 	TargetDir   string   `json:"target_dir" jsonschema:"directory where facade packages will be created"`
 	ExportTypes []string `json:"export_types,omitempty" jsonschema:"types of symbols to export (e.g. commands, models, events)"`
+	Include     []string `json:"include,omitempty" jsonschema:"name patterns (path.Match syntax, e.g. New*) of the symbols to export; default all"`
+	Exclude     []string `json:"exclude,omitempty" jsonschema:"name patterns of the symbols not to export"`
 }
 
 // --- update_facades ---
@@ -34,6 +36,9 @@ type GenerateFacadesInput struct {
 type UpdateFacadesInput struct {
 	FacadePackages []string `json:"facade_packages,omitempty" jsonschema:"list of facade package paths to update"`
 	AutoDetect     bool     `json:"auto_detect,omitempty" jsonschema:"automatically detect facade packages to update"`
+	Include        []string `json:"include,omitempty" jsonschema:"name patterns (path.Match syntax, e.g. New*) of the symbols to export; default all"`
+	Exclude        []string `json:"exclude,omitempty" jsonschema:"name patterns of the symbols not to export"`
+	OnlyExisting   bool     `json:"only_existing,omitempty" jsonschema:"refresh the facade's existing re-exports without adding newly exported symbols"`
 }
 
 func registerFacadeTools(s *mcpsdk.Server, state *MCPServer) {
@@ -64,7 +69,7 @@ func registerFacadeTools(s *mcpsdk.Server, state *MCPServer) {
 			state.RUnlock()
 			return errResult(err), nil, nil
 		}
-		result, err := executePlanWithUnlock(state, plan, "create facade")
+		result, err := executePlanWithUnlock(ctx, state, plan, "create facade")
 		if err != nil {
 			return errResult(err), nil, nil
 		}
@@ -86,12 +91,14 @@ func registerFacadeTools(s *mcpsdk.Server, state *MCPServer) {
 			ModulesDir:  in.ModulesDir,
 			TargetDir:   in.TargetDir,
 			ExportTypes: in.ExportTypes,
+			Include:     in.Include,
+			Exclude:     in.Exclude,
 		})
 		if err != nil {
 			state.RUnlock()
 			return errResult(err), nil, nil
 		}
-		result, err := executePlanWithUnlock(state, plan, "generate facades")
+		result, err := executePlanWithUnlock(ctx, state, plan, "generate facades")
 		if err != nil {
 			return errResult(err), nil, nil
 		}
@@ -100,7 +107,7 @@ func registerFacadeTools(s *mcpsdk.Server, state *MCPServer) {
 
 	mcpsdk.AddTool(s, &mcpsdk.Tool{
 		Name:        "update_facades",
-		Description: "Update existing facade packages to reflect changes in the underlying packages they re-export from.",
+		Description: "Update existing facade packages to reflect changes in the underlying packages they re-export from. Aliases are kept; re-exports of symbols that no longer exist are dropped with a warning.",
 	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in UpdateFacadesInput) (*mcpsdk.CallToolResult, any, error) {
 		state.RLock()
 
@@ -112,12 +119,15 @@ func registerFacadeTools(s *mcpsdk.Server, state *MCPServer) {
 		plan, err := state.GetEngine().UpdateFacades(ws, types.UpdateFacadesRequest{
 			FacadePackages: in.FacadePackages,
 			AutoDetect:     in.AutoDetect,
+			Include:        in.Include,
+			Exclude:        in.Exclude,
+			OnlyExisting:   in.OnlyExisting,
 		})
 		if err != nil {
 			state.RUnlock()
 			return errResult(err), nil, nil
 		}
-		result, err := executePlanWithUnlock(state, plan, "update facades")
+		result, err := executePlanWithUnlock(ctx, state, plan, "update facades")
 		if err != nil {
 			return errResult(err), nil, nil
 		}
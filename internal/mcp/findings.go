@@ -0,0 +1,149 @@
+package mcp
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"go/token"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+
+	"github.com/mamaar/gorefactor/pkg/analyzers"
+	"github.com/mamaar/gorefactor/pkg/config"
+)
+
+// Finding is a finding of an analyzer in a file, as AnalyzeFiles reports
+// it.
+type Finding struct {
+	Rule    string
+	Level   string // SARIF level of the rule: error, warning or note
+	Message string
+	Pos     token.Position
+	End     token.Position // Invalid when the analyzer reported a position only
+	Fixable bool           // A fix_* tool rewrites the finding
+
+	// The fixes the analyzer suggested, when Fixable
+	SuggestedFixes []analysis.SuggestedFix
+}
+
+// AnalyzerNames returns the names of the analyzers run_analyzers and
+// AnalyzeFiles run, built-in and registered.
+func AnalyzerNames() []string {
+	var names []string
+	for _, r := range analyzerRules(config.Default(), "") {
+		names = append(names, r.rule.ID)
+	}
+	return names
+}
+
+// AnalyzeFiles runs the analyzers over the packages holding files and
+// returns their findings in those files, ordered by file and position. The
+// thresholds come from cfg, or from the project config when cfg is nil;
+// the analyzers skip reports true for are left out. Ignore directives and
+// the workspace's baseline suppress findings as they do for run_analyzers.
+// Callers hold the read lock.
+func (s *MCPServer) AnalyzeFiles(files []string, cfg *config.Config, skip func(rule string) bool) ([]Finding, error) {
+	ws, err := s.GetWorkspace()
+	if err != nil {
+		return nil, err
+	}
+	if cfg == nil {
+		cfg = s.ProjectConfig()
+	}
+	inBaseline := func(rule, file, message string) bool { return false }
+	baseline, err := analyzers.LoadBaseline(filepath.Join(ws.RootPath, analyzers.BaselineFile))
+	switch {
+	case err == nil:
+		inBaseline = baseline.Matcher()
+	case !errors.Is(err, fs.ErrNotExist):
+		return nil, err
+	}
+
+	wanted := make(map[string]bool)
+	var pkgFilters []string
+	for _, f := range files {
+		wanted[f] = true
+		if dir := filepath.Dir(f); !slices.Contains(pkgFilters, dir) {
+			if _, ok := ws.Packages[dir]; ok {
+				pkgFilters = append(pkgFilters, dir)
+			}
+		}
+	}
+	slices.Sort(pkgFilters)
+	if len(pkgFilters) == 0 {
+		return nil, nil
+	}
+
+	ignores := workspaceIgnores(ws)
+	var findings []Finding
+	add := func(r analyzerRule, pos, end token.Position, message string, fixes []analysis.SuggestedFix) {
+		if !wanted[pos.Filename] || ignores.Ignored(r.rule.ID, pos.Filename, pos.Line) {
+			return
+		}
+		rel, err := filepath.Rel(ws.RootPath, pos.Filename)
+		if err != nil {
+			rel = pos.Filename
+		}
+		if inBaseline(r.rule.ID, filepath.ToSlash(rel), message) {
+			return
+		}
+		f := Finding{
+			Rule:    r.rule.ID,
+			Level:   r.rule.DefaultConfiguration.Level,
+			Message: message,
+			Pos:     pos,
+			End:     end,
+			Fixable: r.fixable,
+		}
+		if r.fixable {
+			f.SuggestedFixes = fixes
+		}
+		findings = append(findings, f)
+	}
+
+	for _, r := range analyzerRules(cfg, moduleGoVersion(ws)) {
+		if skip != nil && skip(r.rule.ID) {
+			continue
+		}
+		if r.analyzer == nil {
+			unused, err := unusedSymbols(ws, s, "")
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", r.rule.ID, err)
+			}
+			for _, u := range unused {
+				sym := u.Symbol
+				msg := fmt.Sprintf("%s %s is unused", strings.ToLower(sym.Kind.String()), sym.Name)
+				if u.Reason != "" {
+					msg += ": " + u.Reason
+				}
+				add(r, ws.FileSet.Position(sym.Position), token.Position{}, msg, nil)
+			}
+			continue
+		}
+		for _, pkgFilter := range pkgFilters {
+			rr, err := runRule(ws, s, r, pkgFilter)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", r.rule.ID, err)
+			}
+			for _, d := range rr.Diagnostics {
+				var end token.Position
+				if d.End.IsValid() {
+					end = ws.FileSet.Position(d.End)
+				}
+				add(r, ws.FileSet.Position(d.Pos), end, d.Message, d.SuggestedFixes)
+			}
+		}
+	}
+
+	slices.SortStableFunc(findings, func(a, b Finding) int {
+		return cmp.Or(
+			strings.Compare(a.Pos.Filename, b.Pos.Filename),
+			cmp.Compare(a.Pos.Offset, b.Pos.Offset),
+		)
+	})
+	return findings, nil
+}
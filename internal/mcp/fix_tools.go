@@ -80,6 +80,8 @@ func registerFixTools(s *mcpsdk.Server, state *MCPServer) {
 
 		// Build command args
 		args := []string{"fix"}
+		// In preview mode go fix only reports what it would change.
+		in.DiffOnly = in.DiffOnly || state.preview
 		if in.DiffOnly {
 			args = append(args, "-diff")
 		}
@@ -0,0 +1,113 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// --- history_list ---
+
+type HistoryListInput struct{}
+
+// HistoryEntry summarizes one applied plan in the history journal.
+type HistoryEntry struct {
+	ID          string    `json:"id"`
+	Time        time.Time `json:"time"`
+	Description string    `json:"description"`
+	FileCount   int       `json:"file_count"`
+}
+
+// --- rollback ---
+
+type RollbackInput struct {
+	ToID  string `json:"to_id,omitempty" jsonschema:"history entry to roll back to; it and every later entry are reverted. Defaults to the most recent entry"`
+	Force bool   `json:"force,omitempty" jsonschema:"overwrite files that were edited after the plan was applied"`
+}
+
+func registerHistoryTools(s *mcpsdk.Server, state *MCPServer) {
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "history_list",
+		Description: "List applied refactorings recorded in the workspace's .gorefactor/history journal, oldest first.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in HistoryListInput) (*mcpsdk.CallToolResult, any, error) {
+		state.RLock()
+		defer state.RUnlock()
+
+		if _, err := state.GetWorkspace(); err != nil {
+			return errResult(err), nil, nil
+		}
+		journal := state.GetEngine().Journal()
+		if journal == nil {
+			return errResult(fmt.Errorf("history journal is disabled (engine.journal in .gorefactor.yaml)")), nil, nil
+		}
+		entries, err := journal.List()
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+
+		history := make([]HistoryEntry, len(entries))
+		for i, e := range entries {
+			history[i] = HistoryEntry{ID: e.ID, Time: e.Time, Description: e.Description, FileCount: len(e.Files)}
+		}
+		return textResult(&AnalysisResult{
+			Description: fmt.Sprintf("%d applied refactorings", len(history)),
+			Data:        history,
+		}), nil, nil
+	})
+
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "rollback",
+		Description: "Revert applied refactorings from the history journal, newest first, down to and including to_id. Refuses to overwrite files edited since unless force is true.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in RollbackInput) (*mcpsdk.CallToolResult, any, error) {
+		state.RLock()
+		preview := state.preview
+		_, err := state.GetWorkspace()
+		state.RUnlock()
+		if preview {
+			return errResult(fmt.Errorf("rollback restores files directly and can't be previewed")), nil, nil
+		}
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+
+		// Restoring files is applying a plan: it waits for the writer slot
+		// like the others, rather than writing under the read lock.
+		release, err := state.writer.acquire(ctx)
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		defer release()
+		done, err := state.beginApply()
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		defer done()
+
+		plan, err := state.GetEngine().RollbackOperations(types.RollbackOperationRequest{
+			LastBatch: in.ToID == "",
+			ToID:      in.ToID,
+			Force:     in.Force,
+		})
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+
+		// The files are already restored; only the workspace needs to catch up.
+		if err := state.SyncWorkspaceChanges(plan.AffectedFiles); err != nil {
+			state.logger.WarnContext(ctx, "workspace sync failed", "err", err)
+		}
+		if err := state.verifyWorkspace("after rollback"); err != nil {
+			return errResult(err), nil, nil
+		}
+		return textResult(&PlanResult{
+			Description:   plan.Operations[0].Description(),
+			AffectedFiles: plan.AffectedFiles,
+			ModifiedFiles: plan.AffectedFiles,
+			Success:       true,
+		}), nil, nil
+	})
+}
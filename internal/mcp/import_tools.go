@@ -11,7 +11,9 @@ import (
 // --- clean_aliases ---
 
 type CleanAliasesInput struct {
-	PreserveConflicts bool `json:"preserve_conflicts,omitempty" jsonschema:"keep aliases only where needed to resolve naming conflicts"`
+	PreserveConflicts bool     `json:"preserve_conflicts,omitempty" jsonschema:"keep aliases only where needed to resolve naming conflicts"`
+	Packages          []string `json:"packages,omitempty" jsonschema:"package paths to limit the operation to (default: every package)"`
+	Preview           bool     `json:"preview,omitempty" jsonschema:"return the planned changes without applying them"`
 }
 
 // --- standardize_imports ---
@@ -22,20 +24,36 @@ type AliasRuleInput struct {
 }
 
 type StandardizeImportsInput struct {
-	Rules []AliasRuleInput `json:"rules" jsonschema:"list of alias rules to apply"`
+	Rules    []AliasRuleInput `json:"rules,omitempty" jsonschema:"list of alias rules to apply (default: import_aliases from .gorefactor.yaml)"`
+	Packages []string         `json:"packages,omitempty" jsonschema:"package paths to limit the operation to (default: every package)"`
+	Preview  bool             `json:"preview,omitempty" jsonschema:"return the planned changes without applying them"`
 }
 
 // --- resolve_alias_conflicts ---
 
 type ResolveAliasConflictsInput struct {
-	Strategy string `json:"strategy,omitempty" jsonschema:"conflict resolution strategy: full_names, shortest_unique, or custom_alias (default: full_names)"`
+	Strategy string   `json:"strategy,omitempty" jsonschema:"conflict resolution strategy: full_names, shortest_unique, or custom_alias (default: full_names)"`
+	Packages []string `json:"packages,omitempty" jsonschema:"package paths to limit the operation to (default: every package)"`
+	Preview  bool     `json:"preview,omitempty" jsonschema:"return the planned changes without applying them"`
 }
 
 // --- convert_aliases ---
 
 type ConvertAliasesInput struct {
-	ToFullNames   bool `json:"to_full_names,omitempty" jsonschema:"convert short imports to fully-qualified alias names"`
-	FromFullNames bool `json:"from_full_names,omitempty" jsonschema:"convert fully-qualified alias names back to short imports"`
+	ToFullNames   bool     `json:"to_full_names,omitempty" jsonschema:"convert short imports to fully-qualified alias names"`
+	FromFullNames bool     `json:"from_full_names,omitempty" jsonschema:"convert fully-qualified alias names back to short imports"`
+	Packages      []string `json:"packages,omitempty" jsonschema:"package paths to limit the operation to (default: every package)"`
+	Preview       bool     `json:"preview,omitempty" jsonschema:"return the planned changes without applying them"`
+}
+
+// resolvePackagePaths resolves the package paths given to a tool like
+// types.ResolvePackagePath does.
+func resolvePackagePaths(ws *types.Workspace, paths []string) []string {
+	resolved := make([]string, len(paths))
+	for i, p := range paths {
+		resolved[i] = types.ResolvePackagePath(ws, p)
+	}
+	return resolved
 }
 
 func registerImportTools(s *mcpsdk.Server, state *MCPServer) {
@@ -53,12 +71,13 @@ func registerImportTools(s *mcpsdk.Server, state *MCPServer) {
 		plan, err := state.GetEngine().CleanAliases(ws, types.CleanAliasesRequest{
 			Workspace:         ws.RootPath,
 			PreserveConflicts: in.PreserveConflicts,
+			Packages:          resolvePackagePaths(ws, in.Packages),
 		})
 		if err != nil {
 			state.RUnlock()
 			return errResult(err), nil, nil
 		}
-		result, err := executePlanWithUnlock(state, plan, "clean aliases")
+		result, err := previewPlanWithUnlock(ctx, state, plan, "clean aliases", in.Preview)
 		if err != nil {
 			return errResult(err), nil, nil
 		}
@@ -83,15 +102,19 @@ func registerImportTools(s *mcpsdk.Server, state *MCPServer) {
 				Alias:          r.Alias,
 			}
 		}
+		if len(rules) == 0 {
+			rules = state.ProjectConfig().AliasRules()
+		}
 		plan, err := state.GetEngine().StandardizeImports(ws, types.StandardizeImportsRequest{
 			Workspace: ws.RootPath,
 			Rules:     rules,
+			Packages:  resolvePackagePaths(ws, in.Packages),
 		})
 		if err != nil {
 			state.RUnlock()
 			return errResult(err), nil, nil
 		}
-		result, err := executePlanWithUnlock(state, plan, "standardize imports")
+		result, err := previewPlanWithUnlock(ctx, state, plan, "standardize imports", in.Preview)
 		if err != nil {
 			return errResult(err), nil, nil
 		}
@@ -119,12 +142,13 @@ func registerImportTools(s *mcpsdk.Server, state *MCPServer) {
 		plan, err := state.GetEngine().ResolveAliasConflicts(ws, types.ResolveAliasConflictsRequest{
 			Workspace: ws.RootPath,
 			Strategy:  strategy,
+			Packages:  resolvePackagePaths(ws, in.Packages),
 		})
 		if err != nil {
 			state.RUnlock()
 			return errResult(err), nil, nil
 		}
-		result, err := executePlanWithUnlock(state, plan, "resolve alias conflicts")
+		result, err := previewPlanWithUnlock(ctx, state, plan, "resolve alias conflicts", in.Preview)
 		if err != nil {
 			return errResult(err), nil, nil
 		}
@@ -146,12 +170,13 @@ func registerImportTools(s *mcpsdk.Server, state *MCPServer) {
 			Workspace:     ws.RootPath,
 			ToFullNames:   in.ToFullNames,
 			FromFullNames: in.FromFullNames,
+			Packages:      resolvePackagePaths(ws, in.Packages),
 		})
 		if err != nil {
 			state.RUnlock()
 			return errResult(err), nil, nil
 		}
-		result, err := executePlanWithUnlock(state, plan, "convert aliases")
+		result, err := previewPlanWithUnlock(ctx, state, plan, "convert aliases", in.Preview)
 		if err != nil {
 			return errResult(err), nil, nil
 		}
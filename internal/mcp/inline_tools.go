@@ -21,6 +21,7 @@ type InlineMethodInput struct {
 type InlineVariableInput struct {
 	VariableName string `json:"variable_name" jsonschema:"name of the variable to inline"`
 	SourceFile   string `json:"source_file" jsonschema:"file containing the variable declaration"`
+	Force        bool   `json:"force,omitempty" jsonschema:"inline even when the initializer has side effects (calls, channel receives, allocations) that inlining would repeat or drop, or reads what is written before the use"`
 }
 
 // --- inline_function ---
@@ -55,7 +56,7 @@ func registerInlineTools(s *mcpsdk.Server, state *MCPServer) {
 			state.RUnlock()
 			return errResult(err), nil, nil
 		}
-		result, err := executePlanWithUnlock(state, plan, "inline method "+in.SourceStruct+"."+in.MethodName)
+		result, err := executePlanWithUnlock(ctx, state, plan, "inline method "+in.SourceStruct+"."+in.MethodName)
 		if err != nil {
 			return errResult(err), nil, nil
 		}
@@ -64,7 +65,7 @@ func registerInlineTools(s *mcpsdk.Server, state *MCPServer) {
 
 	mcpsdk.AddTool(s, &mcpsdk.Tool{
 		Name:        "inline_variable",
-		Description: "Inline a variable: replace all occurrences with its assigned value and remove the declaration.",
+		Description: "Inline a variable: replace all occurrences with its assigned value and remove the declaration. An initializer with side effects (calls, channel receives, allocations) is refused when the variable is used more than once, never, or in a loop or closure, and so is one whose map element, field or variable is written before the variable's use, unless force is set; its effects are listed in the plan's issues.",
 	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in InlineVariableInput) (*mcpsdk.CallToolResult, any, error) {
 		state.RLock()
 
@@ -76,12 +77,13 @@ func registerInlineTools(s *mcpsdk.Server, state *MCPServer) {
 		plan, err := state.GetEngine().InlineVariable(ws, types.InlineVariableRequest{
 			VariableName: in.VariableName,
 			SourceFile:   resolveFile(ws, in.SourceFile),
+			Force:        in.Force,
 		})
 		if err != nil {
 			state.RUnlock()
 			return errResult(err), nil, nil
 		}
-		result, err := executePlanWithUnlock(state, plan, "inline variable "+in.VariableName)
+		result, err := executePlanWithUnlock(ctx, state, plan, "inline variable "+in.VariableName)
 		if err != nil {
 			return errResult(err), nil, nil
 		}
@@ -115,7 +117,7 @@ func registerInlineTools(s *mcpsdk.Server, state *MCPServer) {
 			state.RUnlock()
 			return errResult(err), nil, nil
 		}
-		result, err := executePlanWithUnlock(state, plan, "inline function "+in.FunctionName)
+		result, err := executePlanWithUnlock(ctx, state, plan, "inline function "+in.FunctionName)
 		if err != nil {
 			return errResult(err), nil, nil
 		}
@@ -0,0 +1,167 @@
+package mcp
+
+import (
+	"context"
+
+	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/mamaar/gorefactor/pkg/analysis"
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// --- interface_usage ---
+
+type InterfaceUsageInput struct {
+	Package    string `json:"package,omitempty" jsonschema:"filter to interfaces declared in a specific package"`
+	UnusedOnly bool   `json:"unused_only,omitempty" jsonschema:"only report interfaces that have unused methods"`
+}
+
+// --- shrink_interface ---
+
+type ShrinkInterfaceInput struct {
+	InterfaceName       string                `json:"interface_name" jsonschema:"name of the interface to shrink"`
+	PackagePath         string                `json:"package_path,omitempty" jsonschema:"package path of the interface (empty for workspace-wide)"`
+	Keep                []string              `json:"keep,omitempty" jsonschema:"methods to keep (default: the methods in use)"`
+	Roles               []types.RoleInterface `json:"roles,omitempty" jsonschema:"role interfaces to split kept methods into; the interface embeds them"`
+	KeepImplementations bool                  `json:"keep_implementations,omitempty" jsonschema:"don't delete implementer methods that become unused"`
+}
+
+// --- segregate_interface ---
+
+type SegregateInterfaceInput struct {
+	InterfaceName string                `json:"interface_name" jsonschema:"name of the interface to split"`
+	PackagePath   string                `json:"package_path,omitempty" jsonschema:"package path of the interface (empty for workspace-wide)"`
+	Roles         []types.RoleInterface `json:"roles,omitempty" jsonschema:"role interfaces to split into (default: one per distinct set of methods a consumer calls)"`
+}
+
+// --- type_hierarchy ---
+
+type TypeHierarchyInput struct {
+	TypeName   string `json:"type_name" jsonschema:"name of the type to inspect"`
+	Package    string `json:"package" jsonschema:"package declaring the type (relative to workspace root)"`
+	MaxMissing int    `json:"max_missing,omitempty" jsonschema:"report interfaces missing at most this many methods as nearly satisfied (default 2)"`
+}
+
+func registerInterfaceTools(s *mcpsdk.Server, state *MCPServer) {
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "interface_usage",
+		Description: "For each interface in the workspace, report which methods are called through values of the interface type, which are required to satisfy other interfaces, and which are unused.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in InterfaceUsageInput) (*mcpsdk.CallToolResult, any, error) {
+		state.RLock()
+		defer state.RUnlock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		usages, err := state.GetEngine().InterfaceUsage(ws)
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+
+		items := make([]*analysis.InterfaceUsage, 0, len(usages))
+		for _, u := range usages {
+			if in.Package != "" && u.Package != in.Package && u.Package != types.ResolvePackagePath(ws, in.Package) {
+				continue
+			}
+			if in.UnusedOnly && len(u.Unused) == 0 {
+				continue
+			}
+			items = append(items, u)
+		}
+		return textResult(map[string]any{
+			"interfaces": items,
+			"count":      len(items),
+		}), nil, nil
+	})
+
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "type_hierarchy",
+		Description: "For a type, list the types it embeds and the workspace types embedding it (both transitively), the interfaces it satisfies, and the interfaces it nearly satisfies along with the methods it lacks. Useful before extracting an interface or moving a type.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in TypeHierarchyInput) (*mcpsdk.CallToolResult, any, error) {
+		state.RLock()
+		defer state.RUnlock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		maxMissing := in.MaxMissing
+		if maxMissing <= 0 {
+			maxMissing = 2
+		}
+		h, err := state.GetEngine().TypeHierarchy(ws, types.TypeHierarchyRequest{
+			TypeName:   in.TypeName,
+			Package:    types.ResolvePackagePath(ws, in.Package),
+			MaxMissing: maxMissing,
+		})
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		return textResult(h), nil, nil
+	})
+
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "shrink_interface",
+		Description: "Remove the methods of an interface that are never called through it, optionally splitting the rest into role interfaces the original embeds. Implementations of removed methods are deleted where nothing else uses them.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in ShrinkInterfaceInput) (*mcpsdk.CallToolResult, any, error) {
+		state.RLock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+		pkgPath := in.PackagePath
+		if pkgPath != "" {
+			pkgPath = types.ResolvePackagePath(ws, pkgPath)
+		}
+		plan, err := state.GetEngine().ShrinkInterface(ws, types.ShrinkInterfaceRequest{
+			InterfaceName:       in.InterfaceName,
+			Package:             pkgPath,
+			Keep:                in.Keep,
+			Roles:               in.Roles,
+			KeepImplementations: in.KeepImplementations,
+		})
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+		result, err := executePlanWithUnlock(ctx, state, plan, "shrink interface "+in.InterfaceName)
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		return textResult(result), nil, nil
+	})
+
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "segregate_interface",
+		Description: "Split an interface into role interfaces based on which methods each consumer calls, and narrow consumer parameters to the smallest role they need. The original interface embeds the roles so existing code keeps compiling.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in SegregateInterfaceInput) (*mcpsdk.CallToolResult, any, error) {
+		state.RLock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+		pkgPath := in.PackagePath
+		if pkgPath != "" {
+			pkgPath = types.ResolvePackagePath(ws, pkgPath)
+		}
+		plan, err := state.GetEngine().SegregateInterface(ws, types.SegregateInterfaceRequest{
+			InterfaceName: in.InterfaceName,
+			Package:       pkgPath,
+			Roles:         in.Roles,
+		})
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+		result, err := executePlanWithUnlock(ctx, state, plan, "segregate interface "+in.InterfaceName)
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		return textResult(result), nil, nil
+	})
+}
@@ -0,0 +1,93 @@
+package mcp
+
+import (
+	"context"
+
+	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// --- generate_mock ---
+
+type GenerateMockInput struct {
+	InterfaceName string `json:"interface_name" jsonschema:"name of the interface to mock"`
+	Package       string `json:"package,omitempty" jsonschema:"package path of the interface (empty for workspace-wide)"`
+	TargetPackage string `json:"target_package,omitempty" jsonschema:"package to write the mock to (default the interface's); a new one is created relative to the interface's package, e.g. mocks"`
+	MockName      string `json:"mock_name,omitempty" jsonschema:"name of the mock type (default <Interface>Mock)"`
+	Style         string `json:"style,omitempty" jsonschema:"func (default): a function field per method, returning zero values when unset; moq: the layout of github.com/matryer/moq, recording calls"`
+	Preview       bool   `json:"preview,omitempty" jsonschema:"return the planned changes without applying them"`
+}
+
+// --- update_mocks ---
+
+type UpdateMocksInput struct {
+	Package string `json:"package,omitempty" jsonschema:"only update the mocks in this package (empty for every mock)"`
+	Preview bool   `json:"preview,omitempty" jsonschema:"return the planned changes without applying them"`
+}
+
+func registerMockTools(s *mcpsdk.Server, state *MCPServer) {
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "generate_mock",
+		Description: "Generate a mock implementation of an interface into mock_<interface>.go of the target package. The file records the interface it mocks, so update_mocks can regenerate it when the interface changes.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in GenerateMockInput) (*mcpsdk.CallToolResult, any, error) {
+		state.RLock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+		pkgPath := in.Package
+		if pkgPath != "" {
+			pkgPath = types.ResolvePackagePath(ws, pkgPath)
+		}
+		targetPath := in.TargetPackage
+		if targetPath != "" {
+			targetPath = types.ResolvePackagePath(ws, targetPath)
+		}
+		plan, err := state.GetEngine().GenerateMock(ws, types.GenerateMockRequest{
+			InterfaceName: in.InterfaceName,
+			Package:       pkgPath,
+			TargetPackage: targetPath,
+			MockName:      in.MockName,
+			Style:         types.MockStyle(in.Style),
+		})
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+		result, err := previewPlanWithUnlock(ctx, state, plan, "generate mock of "+in.InterfaceName, in.Preview)
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		return textResult(result), nil, nil
+	})
+
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "update_mocks",
+		Description: "Regenerate the mocks written by generate_mock from the current declarations of their interfaces. Mocks whose interface no longer exists are left alone and reported as warnings.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in UpdateMocksInput) (*mcpsdk.CallToolResult, any, error) {
+		state.RLock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+		pkgPath := in.Package
+		if pkgPath != "" {
+			pkgPath = types.ResolvePackagePath(ws, pkgPath)
+		}
+		plan, err := state.GetEngine().UpdateMocks(ws, types.UpdateMocksRequest{Package: pkgPath})
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+		result, err := previewPlanWithUnlock(ctx, state, plan, "update mocks", in.Preview)
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		return textResult(result), nil, nil
+	})
+}
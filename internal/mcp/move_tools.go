@@ -1,7 +1,9 @@
 package mcp
 
 import (
+	"cmp"
 	"context"
+	"path/filepath"
 
 	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
 
@@ -14,6 +16,17 @@ type MoveSymbolInput struct {
 	Symbol      string `json:"symbol" jsonschema:"symbol name to move"`
 	FromPackage string `json:"from_package" jsonschema:"source package path (relative to workspace root)"`
 	ToPackage   string `json:"to_package" jsonschema:"target package path (relative to workspace root)"`
+	MoveTests   bool   `json:"move_tests,omitempty" jsonschema:"also move the test functions exercising the symbol"`
+}
+
+// --- suggest_home ---
+
+type SuggestHomeInput struct {
+	Symbol              string `json:"symbol" jsonschema:"symbol name to find a home for"`
+	Package             string `json:"package,omitempty" jsonschema:"package currently declaring the symbol (relative to workspace root); default: the only package declaring it"`
+	DomainLayer         string `json:"domain_layer,omitempty" jsonschema:"directory of the domain layer (default: layers.domain from .gorefactor.yaml)"`
+	InfrastructureLayer string `json:"infrastructure_layer,omitempty" jsonschema:"directory of the infrastructure layer (default: layers.infrastructure from .gorefactor.yaml)"`
+	ApplicationLayer    string `json:"application_layer,omitempty" jsonschema:"directory of the application layer (default: layers.application from .gorefactor.yaml)"`
 }
 
 // --- move_package ---
@@ -21,6 +34,8 @@ type MoveSymbolInput struct {
 type MovePackageInput struct {
 	SourcePackage string `json:"source_package" jsonschema:"source package path"`
 	TargetPackage string `json:"target_package" jsonschema:"target package path"`
+	MoveTests     bool   `json:"move_tests,omitempty" jsonschema:"also move the package's test files"`
+	Preview       bool   `json:"preview,omitempty" jsonschema:"return the planned changes without applying them"`
 }
 
 // --- move_dir ---
@@ -29,6 +44,7 @@ type MoveDirInput struct {
 	SourceDir         string `json:"source_dir" jsonschema:"source directory"`
 	TargetDir         string `json:"target_dir" jsonschema:"target directory"`
 	PreserveStructure bool   `json:"preserve_structure,omitempty" jsonschema:"preserve directory structure"`
+	Preview           bool   `json:"preview,omitempty" jsonschema:"return the planned changes without applying them"`
 }
 
 // --- move_packages ---
@@ -41,6 +57,26 @@ type PackageMappingInput struct {
 type MovePackagesInput struct {
 	Packages  []PackageMappingInput `json:"packages" jsonschema:"list of source→target package mappings"`
 	TargetDir string                `json:"target_dir,omitempty" jsonschema:"common target directory (used when packages list uses relative targets)"`
+	Preview   bool                  `json:"preview,omitempty" jsonschema:"return the planned changes without applying them"`
+}
+
+// --- merge_packages ---
+
+type MergePackagesInput struct {
+	SourcePackages []string `json:"source_packages" jsonschema:"package paths to merge into the target"`
+	TargetPackage  string   `json:"target_package" jsonschema:"existing package path to merge them into"`
+}
+
+// --- organize_by_domain ---
+
+type DomainInput struct {
+	Name    string   `json:"name" jsonschema:"domain name; its declarations go to <name>.go"`
+	Symbols []string `json:"symbols" jsonschema:"package-level names of the domain; types bring their methods and constructors"`
+}
+
+type OrganizeByDomainInput struct {
+	Package string        `json:"package" jsonschema:"package path (relative to workspace root)"`
+	Domains []DomainInput `json:"domains" jsonschema:"domains and the symbols belonging to each"`
 }
 
 func registerMoveTools(s *mcpsdk.Server, state *MCPServer) {
@@ -61,22 +97,75 @@ func registerMoveTools(s *mcpsdk.Server, state *MCPServer) {
 			SymbolName:  in.Symbol,
 			FromPackage: from,
 			ToPackage:   to,
+			MoveTests:   in.MoveTests,
 		})
 		if err != nil {
 			state.RUnlock()
 			return errResult(err), nil, nil
 		}
-		result, err := executePlanWithUnlock(state, plan, "move symbol "+in.Symbol)
+		result, err := executePlanWithUnlock(ctx, state, plan, "move symbol "+in.Symbol)
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		return textResult(result), nil, nil
+	})
+
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "suggest_home",
+		Description: "Rank the packages a symbol could live in by where it is referenced from, the imports a move would add or cycle through, and layer rules. When a better home than the current one exists, returns ready-made move_symbol arguments.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in SuggestHomeInput) (*mcpsdk.CallToolResult, any, error) {
+		state.RLock()
+		defer state.RUnlock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		layers := state.ProjectConfig().Layers
+		var pkg string
+		if in.Package != "" {
+			pkg = types.ResolvePackagePath(ws, in.Package)
+		}
+		candidates, err := state.GetEngine().SuggestHome(ws, types.SuggestHomeRequest{
+			SymbolName:          in.Symbol,
+			Package:             pkg,
+			DomainLayer:         cmp.Or(in.DomainLayer, layers.Domain),
+			InfrastructureLayer: cmp.Or(in.InfrastructureLayer, layers.Infrastructure),
+			ApplicationLayer:    cmp.Or(in.ApplicationLayer, layers.Application),
+		})
 		if err != nil {
 			return errResult(err), nil, nil
 		}
+
+		var current string
+		for _, c := range candidates {
+			if rel, err := filepath.Rel(ws.RootPath, c.Package); err == nil {
+				c.Package = filepath.ToSlash(rel)
+			}
+			if c.Current {
+				current = c.Package
+			}
+		}
+		result := map[string]any{
+			"symbol":     in.Symbol,
+			"candidates": candidates,
+		}
+		if len(candidates) > 0 && !candidates[0].Current && !candidates[0].Blocked {
+			result["move_symbol"] = MoveSymbolInput{
+				Symbol:      in.Symbol,
+				FromPackage: current,
+				ToPackage:   candidates[0].Package,
+			}
+		}
 		return textResult(result), nil, nil
 	})
 
 	mcpsdk.AddTool(s, &mcpsdk.Tool{
 		Name:        "move_package",
-		Description: "Move an entire package to a new location. Updates all import paths across the workspace.",
+		Description: "Move an entire package to a new location. Updates all import paths across the workspace. With preview, returns the planned changes without applying them.",
 	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in MovePackageInput) (*mcpsdk.CallToolResult, any, error) {
+		defer state.trackProgress(ctx, req)()
+
 		state.RLock()
 
 		ws, err := state.GetWorkspace()
@@ -86,15 +175,16 @@ func registerMoveTools(s *mcpsdk.Server, state *MCPServer) {
 		}
 		src := types.ResolvePackagePath(ws, in.SourcePackage)
 		tgt := types.ResolvePackagePath(ws, in.TargetPackage)
-		plan, err := state.GetEngine().MovePackage(ws, types.MovePackageRequest{
+		plan, err := state.GetEngine().MovePackageContext(ctx, ws, types.MovePackageRequest{
 			SourcePackage: src,
 			TargetPackage: tgt,
+			MoveTests:     in.MoveTests,
 		})
 		if err != nil {
 			state.RUnlock()
 			return errResult(err), nil, nil
 		}
-		result, err := executePlanWithUnlock(state, plan, "move package")
+		result, err := previewPlanWithUnlock(ctx, state, plan, "move package", in.Preview)
 		if err != nil {
 			return errResult(err), nil, nil
 		}
@@ -103,8 +193,10 @@ func registerMoveTools(s *mcpsdk.Server, state *MCPServer) {
 
 	mcpsdk.AddTool(s, &mcpsdk.Tool{
 		Name:        "move_dir",
-		Description: "Move a directory (and all packages inside it) to a new location.",
+		Description: "Move a directory (and all packages inside it) to a new location. With preview, returns the planned changes without applying them.",
 	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in MoveDirInput) (*mcpsdk.CallToolResult, any, error) {
+		defer state.trackProgress(ctx, req)()
+
 		state.RLock()
 
 		ws, err := state.GetWorkspace()
@@ -112,7 +204,7 @@ func registerMoveTools(s *mcpsdk.Server, state *MCPServer) {
 			state.RUnlock()
 			return errResult(err), nil, nil
 		}
-		plan, err := state.GetEngine().MoveDir(ws, types.MoveDirRequest{
+		plan, err := state.GetEngine().MoveDirContext(ctx, ws, types.MoveDirRequest{
 			SourceDir:         in.SourceDir,
 			TargetDir:         in.TargetDir,
 			PreserveStructure: in.PreserveStructure,
@@ -121,7 +213,7 @@ func registerMoveTools(s *mcpsdk.Server, state *MCPServer) {
 			state.RUnlock()
 			return errResult(err), nil, nil
 		}
-		result, err := executePlanWithUnlock(state, plan, "move directory")
+		result, err := previewPlanWithUnlock(ctx, state, plan, "move directory", in.Preview)
 		if err != nil {
 			return errResult(err), nil, nil
 		}
@@ -130,8 +222,10 @@ func registerMoveTools(s *mcpsdk.Server, state *MCPServer) {
 
 	mcpsdk.AddTool(s, &mcpsdk.Tool{
 		Name:        "move_packages",
-		Description: "Move multiple packages atomically. All import references are updated in a single operation.",
+		Description: "Move multiple packages atomically. All import references are updated in a single operation. With preview, returns the planned changes without applying them.",
 	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in MovePackagesInput) (*mcpsdk.CallToolResult, any, error) {
+		defer state.trackProgress(ctx, req)()
+
 		state.RLock()
 
 		ws, err := state.GetWorkspace()
@@ -146,7 +240,7 @@ func registerMoveTools(s *mcpsdk.Server, state *MCPServer) {
 				TargetPackage: types.ResolvePackagePath(ws, m.Target),
 			}
 		}
-		plan, err := state.GetEngine().MovePackages(ws, types.MovePackagesRequest{
+		plan, err := state.GetEngine().MovePackagesContext(ctx, ws, types.MovePackagesRequest{
 			Packages:  mappings,
 			TargetDir: in.TargetDir,
 		})
@@ -154,7 +248,67 @@ func registerMoveTools(s *mcpsdk.Server, state *MCPServer) {
 			state.RUnlock()
 			return errResult(err), nil, nil
 		}
-		result, err := executePlanWithUnlock(state, plan, "move packages")
+		result, err := previewPlanWithUnlock(ctx, state, plan, "move packages", in.Preview)
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		return textResult(result), nil, nil
+	})
+
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "merge_packages",
+		Description: "Merge packages into an existing package. Their files move to the target directory, a file whose name is taken is prefixed with its package's name along with its test file, examples of a whole merged package are renamed Example_<package>, and all imports and qualifiers are updated. Refused when names clash or the merge would create an import cycle.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in MergePackagesInput) (*mcpsdk.CallToolResult, any, error) {
+		state.RLock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+		sources := make([]string, len(in.SourcePackages))
+		for i, p := range in.SourcePackages {
+			sources[i] = types.ResolvePackagePath(ws, p)
+		}
+		plan, err := state.GetEngine().MergePackages(ws, types.MergePackagesRequest{
+			SourcePackages: sources,
+			TargetPackage:  types.ResolvePackagePath(ws, in.TargetPackage),
+		})
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+		result, err := executePlanWithUnlock(ctx, state, plan, "merge packages")
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		return textResult(result), nil, nil
+	})
+
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "organize_by_domain",
+		Description: "Regroup a package's declarations into one file per domain. The listed symbols move to <domain>.go, types with their methods and NewT constructors, and the tests, benchmarks, fuzz tests and examples named after them to <domain>_test.go. Files left empty are removed.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in OrganizeByDomainInput) (*mcpsdk.CallToolResult, any, error) {
+		state.RLock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+		domains := make([]types.Domain, len(in.Domains))
+		for i, d := range in.Domains {
+			domains[i] = types.Domain{Name: d.Name, Symbols: d.Symbols}
+		}
+		plan, err := state.GetEngine().OrganizeByDomain(ws, types.OrganizeByDomainRequest{
+			Package: types.ResolvePackagePath(ws, in.Package),
+			Domains: domains,
+		})
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+		result, err := executePlanWithUnlock(ctx, state, plan, "organize by domain")
 		if err != nil {
 			return errResult(err), nil, nil
 		}
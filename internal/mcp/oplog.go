@@ -0,0 +1,46 @@
+package mcp
+
+import (
+	"context"
+	"time"
+
+	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/mamaar/gorefactor/internal/logging"
+)
+
+// OperationMetaKey is the _meta key of a tool call under which a client can
+// pass the operation ID to log the call with, to find it in the log.
+const OperationMetaKey = "gorefactor/op_id"
+
+// logOperations gives each request an operation ID, carried as op_id by the
+// lines logged for it, and logs tool calls with their outcome.
+func (s *MCPServer) logOperations(next mcpsdk.MethodHandler) mcpsdk.MethodHandler {
+	return func(ctx context.Context, method string, req mcpsdk.Request) (mcpsdk.Result, error) {
+		call, ok := req.(*mcpsdk.CallToolRequest)
+		if !ok {
+			ctx = logging.WithOperation(ctx, logging.NewOperationID())
+			s.logger.DebugContext(ctx, "request", "method", method)
+			return next(ctx, method, req)
+		}
+		id, _ := call.Params.Meta[OperationMetaKey].(string)
+		if id == "" {
+			id = logging.NewOperationID()
+		}
+		ctx = logging.WithOperation(ctx, id)
+
+		start := time.Now()
+		s.logger.InfoContext(ctx, "tool call", "tool", call.Params.Name)
+		res, err := next(ctx, method, req)
+		attrs := []any{"tool", call.Params.Name, "duration", time.Since(start)}
+		switch result, _ := res.(*mcpsdk.CallToolResult); {
+		case err != nil:
+			s.logger.ErrorContext(ctx, "tool call failed", append(attrs, "err", err)...)
+		case result != nil && result.IsError:
+			s.logger.InfoContext(ctx, "tool call returned an error", attrs...)
+		default:
+			s.logger.InfoContext(ctx, "tool call done", attrs...)
+		}
+		return res, err
+	}
+}
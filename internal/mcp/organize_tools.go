@@ -0,0 +1,101 @@
+package mcp
+
+import (
+	"context"
+
+	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// --- organize_file ---
+
+type OrganizeFileInput struct {
+	File            string `json:"file" jsonschema:"file to organize"`
+	Policy          string `json:"policy,omitempty" jsonschema:"kind (default): constants, variables, init, each type with its constructors and methods, exported then unexported functions; call_order: constants, variables and types, then each function after its first caller"`
+	SplitByReceiver bool   `json:"split_by_receiver,omitempty" jsonschema:"when the file is oversized, move each type with methods, with its constructors, to a file named after it"`
+	MaxLines        int    `json:"max_lines,omitempty" jsonschema:"lines above which split_by_receiver splits the file (default 500)"`
+	Preview         bool   `json:"preview,omitempty" jsonschema:"return the planned changes without applying them"`
+}
+
+// --- split_file ---
+
+type SplitFileInput struct {
+	File     string `json:"file" jsonschema:"file to split"`
+	Strategy string `json:"strategy,omitempty" jsonschema:"receiver (default): each type with methods, with its constants and constructors, to <type>.go; prefix: declarations whose names start with the same word to <word>.go; dependency: each cluster of declarations referring to one another, but the largest, to a file named after its main type or function"`
+	MaxLines int    `json:"max_lines,omitempty" jsonschema:"lines the file must exceed to be split (default 500)"`
+	Preview  bool   `json:"preview,omitempty" jsonschema:"return the proposed files without creating them"`
+}
+
+func registerOrganizeTools(s *mcpsdk.Server, state *MCPServer) {
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "organize_file",
+		Description: "Reorder the top-level declarations of a file by a policy, keeping doc comments and the comments before each declaration with it. Variables and init functions keep their relative order, so initialization is unchanged. Optionally splits an oversized file by receiver type.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in OrganizeFileInput) (*mcpsdk.CallToolResult, any, error) {
+		state.RLock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+		plan, err := state.GetEngine().OrganizeFile(ws, types.OrganizeFileRequest{
+			File:            resolveFile(ws, in.File),
+			Policy:          types.FileOrderPolicy(in.Policy),
+			SplitByReceiver: in.SplitByReceiver,
+			MaxLines:        in.MaxLines,
+		})
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+		if len(plan.Changes) == 0 {
+			state.RUnlock()
+			return textResult(map[string]any{
+				"files_modified": []string{},
+				"changes_count":  0,
+				"message":        "The file is already organized",
+			}), nil, nil
+		}
+		result, err := previewPlanWithUnlock(ctx, state, plan, "organize "+in.File, in.Preview)
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		return textResult(result), nil, nil
+	})
+
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "split_file",
+		Description: "Move groups of the declarations of an oversized file into new files of the same package, each with its doc comment and the imports it uses. References are unchanged since package scope is; the result is formatted and type-checked before it is proposed. Variables and init functions stay, keeping initialization order. Examples and benchmarks of moved declarations in the file's own _test.go file move to the new file's, and a split test file is grouped by the subjects its test functions are named after.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in SplitFileInput) (*mcpsdk.CallToolResult, any, error) {
+		state.RLock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+		plan, err := state.GetEngine().SplitFile(ws, types.SplitFileRequest{
+			File:     resolveFile(ws, in.File),
+			Strategy: types.SplitStrategy(in.Strategy),
+			MaxLines: in.MaxLines,
+		})
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+		if len(plan.Changes) == 0 {
+			state.RUnlock()
+			return textResult(map[string]any{
+				"files_modified": []string{},
+				"changes_count":  0,
+				"message":        "No group of declarations to move out of the file",
+			}), nil, nil
+		}
+		result, err := previewPlanWithUnlock(ctx, state, plan, "split "+in.File, in.Preview)
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		return textResult(result), nil, nil
+	})
+}
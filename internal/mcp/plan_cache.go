@@ -0,0 +1,264 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/mamaar/gorefactor/pkg/config"
+	"github.com/mamaar/gorefactor/pkg/refactor"
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// planCacheDir holds the cached plans of a workspace when cache.plans is disk.
+const planCacheDir = ".gorefactor/cache/plans"
+
+// maxCachedPlans bounds the plans kept in memory.
+const maxCachedPlans = 64
+
+// uncachedTools read files the workspace fingerprint doesn't cover, so
+// their plans can't be reused.
+var uncachedTools = map[string]bool{
+	"batch_operations": true, // The batch file
+	"build_tags":       true, // Files excluded by their build constraints
+}
+
+// cachedPlan is the plan a tool call made and the result it returned.
+type cachedPlan struct {
+	Plan        *types.RefactoringPlan `json:"plan,omitempty"` // Nil once the plan is applied: the call is done
+	Description string                 `json:"description"`
+	Result      []string               `json:"result"` // Text content of the tool result
+}
+
+// planCache remembers the plans mutating tools made, keyed by the tool
+// call and the workspace it was planned against. Keys are content hashes,
+// so an entry never goes stale; a change to the workspace only makes the
+// fingerprint it is looked up by differ.
+type planCache struct {
+	mu          sync.Mutex
+	mode        string // cache.plans of the project config
+	dir         string // Where entries are kept when mode is disk
+	entries     map[string]*cachedPlan
+	order       []string // Keys of entries, oldest first
+	fingerprint string   // Of the workspace at generation; empty until computed
+	generation  uint64
+}
+
+// reset empties the cache for the workspace at root.
+func (c *planCache) reset(cfg *config.Config, root string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.mode = cfg.Cache.Plans
+	c.dir = filepath.Join(root, planCacheDir)
+	c.entries = make(map[string]*cachedPlan)
+	c.order = nil
+	c.fingerprint = ""
+}
+
+// workspaceFingerprint returns the fingerprint of the workspace of snap,
+// computing it once per generation.
+func (c *planCache) workspaceFingerprint(snap *snapshot) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.fingerprint == "" || c.generation != snap.generation {
+		c.fingerprint = refactor.WorkspaceFingerprint(snap.workspace)
+		c.generation = snap.generation
+	}
+	return c.fingerprint
+}
+
+func (c *planCache) get(key string) *cachedPlan {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if entry := c.entries[key]; entry != nil {
+		return entry
+	}
+	if c.mode != "disk" {
+		return nil
+	}
+	data, err := os.ReadFile(filepath.Join(c.dir, key+".json"))
+	if err != nil {
+		return nil
+	}
+	var entry cachedPlan
+	if json.Unmarshal(data, &entry) != nil {
+		return nil
+	}
+	c.add(key, &entry)
+	return &entry
+}
+
+func (c *planCache) put(key string, entry *cachedPlan) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.add(key, entry)
+	if c.mode != "disk" {
+		return nil
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(c.dir, key+".json"), data, 0o644)
+}
+
+// add stores entry in memory, evicting the oldest entry when full.
+func (c *planCache) add(key string, entry *cachedPlan) {
+	if _, ok := c.entries[key]; !ok {
+		c.order = append(c.order, key)
+	}
+	c.entries[key] = entry
+	if len(c.order) > maxCachedPlans {
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+}
+
+// planCaptureKey is the context key of the planCapture of a tool call.
+type planCaptureKey struct{}
+
+// planCapture records the plans executePlan is given during a tool call.
+type planCapture struct {
+	count int
+	plan  *types.RefactoringPlan
+	desc  string
+}
+
+// capturePlan records plan in the planCapture of ctx, if any.
+func capturePlan(ctx context.Context, plan *types.RefactoringPlan, desc string) {
+	if c, ok := ctx.Value(planCaptureKey{}).(*planCapture); ok {
+		c.count++
+		c.plan, c.desc = clonePlan(plan), desc
+	}
+}
+
+// clonePlan copies plan deeply enough that executing one copy leaves the
+// other as it was.
+func clonePlan(plan *types.RefactoringPlan) *types.RefactoringPlan {
+	p := *plan
+	p.Changes = slices.Clone(plan.Changes)
+	p.AffectedFiles = slices.Clone(plan.AffectedFiles)
+	return &p
+}
+
+// planKey returns the cache key of a tool call: a hash of the workspace
+// fingerprint, the project config, how plans are executed and the call
+// itself. It reports false when the call can't be cached.
+func (s *MCPServer) planKey(call *mcpsdk.CallToolRequest) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	snap := s.current()
+	if snap == nil || s.config.Cache.Plans == "off" || uncachedTools[call.Params.Name] {
+		return "", false
+	}
+	canonical, ok := canonicalArguments(call)
+	if !ok {
+		return "", false
+	}
+	cfg, err := json.Marshal(s.config)
+	if err != nil {
+		return "", false
+	}
+	mode := "apply"
+	switch {
+	case s.preview:
+		mode = "preview"
+	case s.staged != nil:
+		mode = "stage"
+	}
+	return hashParts([]byte(s.plans.workspaceFingerprint(snap)), cfg, []byte(mode), []byte(call.Params.Name), canonical), true
+}
+
+// cachePlans is middleware that answers a tool call from the plan cache
+// when the same call was planned against the same workspace before: the
+// cached plan is executed without planning it again, and a call repeated
+// right after it was applied returns its result again without applying
+// anything.
+func (s *MCPServer) cachePlans(next mcpsdk.MethodHandler) mcpsdk.MethodHandler {
+	return func(ctx context.Context, method string, req mcpsdk.Request) (mcpsdk.Result, error) {
+		call, ok := req.(*mcpsdk.CallToolRequest)
+		if !ok {
+			return next(ctx, method, req)
+		}
+		key, ok := s.planKey(call)
+		if !ok {
+			return next(ctx, method, req)
+		}
+		if entry := s.plans.get(key); entry != nil {
+			return s.replayPlan(ctx, call, entry), nil
+		}
+
+		capture := &planCapture{}
+		res, err := next(context.WithValue(ctx, planCaptureKey{}, capture), method, req)
+		result, ok := res.(*mcpsdk.CallToolResult)
+		if err != nil || !ok || result.IsError || capture.count != 1 {
+			return res, err // Tools running several plans aren't cached
+		}
+		if texts, ok := resultTexts(result); ok {
+			s.rememberPlan(ctx, key, call, &cachedPlan{Plan: capture.plan, Description: capture.desc, Result: texts})
+		}
+		return res, err
+	}
+}
+
+// replayPlan answers call from entry.
+func (s *MCPServer) replayPlan(ctx context.Context, call *mcpsdk.CallToolRequest, entry *cachedPlan) *mcpsdk.CallToolResult {
+	if entry.Plan != nil {
+		if _, err := executePlan(ctx, s, clonePlan(entry.Plan), entry.Description); err != nil {
+			return errResult(err)
+		}
+		s.rememberApplied(ctx, call, entry)
+	}
+	result := &mcpsdk.CallToolResult{}
+	for _, text := range entry.Result {
+		result.Content = append(result.Content, &mcpsdk.TextContent{Text: text})
+	}
+	return result
+}
+
+// rememberPlan caches the plan call made under key, and its result under
+// the workspace the plan left.
+func (s *MCPServer) rememberPlan(ctx context.Context, key string, call *mcpsdk.CallToolRequest, entry *cachedPlan) {
+	if err := s.plans.put(key, entry); err != nil {
+		s.logger.WarnContext(ctx, "plan cache write failed", "err", err)
+	}
+	s.rememberApplied(ctx, call, entry)
+}
+
+// rememberApplied caches the result of call for the workspace its plan
+// left, unless the plan was only previewed.
+func (s *MCPServer) rememberApplied(ctx context.Context, call *mcpsdk.CallToolRequest, entry *cachedPlan) {
+	s.mu.RLock()
+	preview := s.preview
+	s.mu.RUnlock()
+	if preview {
+		return
+	}
+	if key, ok := s.planKey(call); ok {
+		if err := s.plans.put(key, &cachedPlan{Description: entry.Description, Result: entry.Result}); err != nil {
+			s.logger.WarnContext(ctx, "plan cache write failed", "err", err)
+		}
+	}
+}
+
+// resultTexts returns the text content of result, or false if it has other
+// content.
+func resultTexts(result *mcpsdk.CallToolResult) ([]string, bool) {
+	texts := make([]string, 0, len(result.Content))
+	for _, c := range result.Content {
+		tc, ok := c.(*mcpsdk.TextContent)
+		if !ok {
+			return nil, false
+		}
+		texts = append(texts, tc.Text)
+	}
+	return texts, true
+}
@@ -0,0 +1,96 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// progressHub fans engine progress events out to the tool calls currently
+// waiting on the engine. The engine has a single reporter, so concurrent
+// long-running calls may observe each other's events.
+type progressHub struct {
+	mu    sync.Mutex
+	next  int
+	sinks map[int]types.ProgressReporter
+}
+
+func (h *progressHub) report(event types.ProgressEvent) {
+	h.mu.Lock()
+	sinks := make([]types.ProgressReporter, 0, len(h.sinks))
+	for _, sink := range h.sinks {
+		sinks = append(sinks, sink)
+	}
+	h.mu.Unlock()
+	for _, sink := range sinks {
+		sink(event)
+	}
+}
+
+func (h *progressHub) add(sink types.ProgressReporter) (remove func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.sinks == nil {
+		h.sinks = make(map[int]types.ProgressReporter)
+	}
+	id := h.next
+	h.next++
+	h.sinks[id] = sink
+	return func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(h.sinks, id)
+	}
+}
+
+// WatchProgress passes engine progress events to sink until the returned
+// function is called, for servers that report progress outside MCP.
+func (s *MCPServer) WatchProgress(sink types.ProgressReporter) (stop func()) {
+	return s.progress.add(sink)
+}
+
+// trackProgress forwards engine progress to the client that issued req until
+// the returned function is called. Clients that sent a progress token receive
+// notifications/progress; others receive info-level log messages.
+func (s *MCPServer) trackProgress(ctx context.Context, req *mcpsdk.CallToolRequest) (stop func()) {
+	if req == nil || req.Session == nil {
+		return func() {}
+	}
+	token := req.Params.GetProgressToken()
+	return s.progress.add(func(event types.ProgressEvent) {
+		msg := progressMessage(event)
+		if token != nil {
+			_ = req.Session.NotifyProgress(ctx, &mcpsdk.ProgressNotificationParams{
+				ProgressToken: token,
+				Message:       msg,
+				Progress:      float64(event.Current),
+				Total:         float64(event.Total),
+			})
+			return
+		}
+		_ = req.Session.Log(ctx, &mcpsdk.LoggingMessageParams{
+			Level:  "info",
+			Logger: "gorefactor",
+			Data:   msg,
+		})
+	})
+}
+
+// progressMessage renders an event as a single human-readable line.
+func progressMessage(event types.ProgressEvent) string {
+	msg := string(event.Phase)
+	if event.Package != "" {
+		msg += " " + event.Package
+	}
+	if event.Message != "" {
+		msg += ": " + event.Message
+	}
+	if event.Total > 0 {
+		msg += fmt.Sprintf(" (%d/%d)", event.Current, event.Total)
+	}
+	return msg
+}
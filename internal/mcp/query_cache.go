@@ -0,0 +1,211 @@
+package mcp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"sync"
+
+	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/mamaar/gorefactor/pkg/config"
+)
+
+// maxCachedQueries bounds the query results kept in memory.
+const maxCachedQueries = 256
+
+// queryTools are the read-only analyses whose results are cached, besides
+// the detect_ tools. Their results depend only on the workspace files, the
+// project config and their arguments.
+var queryTools = map[string]bool{
+	"analyze_symbol":       true,
+	"search_symbols":       true,
+	"complexity":           true,
+	"unused":               true,
+	"run_analyzers":        true,
+	"analyze_dependencies": true,
+	"infer_layers":         true,
+	"call_graph":           true,
+	"check_architecture":   true,
+	"interface_usage":      true,
+	"type_hierarchy":       true,
+	"suggest_home":         true,
+}
+
+// isQueryTool reports whether the results of the tool are cached.
+func isQueryTool(name string) bool {
+	return queryTools[name] || strings.HasPrefix(name, "detect_")
+}
+
+// QueryCacheStats counts how the query cache answered tool calls.
+type QueryCacheStats struct {
+	Enabled       bool `json:"enabled"`
+	Entries       int  `json:"entries"`
+	Hits          int  `json:"hits"`
+	Misses        int  `json:"misses"`
+	Invalidations int  `json:"invalidations"` // Times the workspace changed and the entries were dropped
+}
+
+// queryCache remembers the results of read-only analyses, keyed like
+// planCache by the tool call and the workspace fingerprint. Unlike plans,
+// results are only worth keeping for the workspace as it is, so a change
+// to it drops them all.
+type queryCache struct {
+	mu      sync.Mutex
+	enabled bool
+	entries map[string][]string // Text content of the results
+	order   []string            // Keys of entries, oldest first
+	stats   QueryCacheStats
+}
+
+// reset empties the cache and its statistics for a newly loaded workspace.
+func (c *queryCache) reset(cfg *config.Config) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.enabled = cfg.Cache.Queries != "off"
+	c.entries = make(map[string][]string)
+	c.order = nil
+	c.stats = QueryCacheStats{}
+}
+
+// invalidate drops every entry after the workspace changed.
+func (c *queryCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) > 0 {
+		c.stats.Invalidations++
+	}
+	clear(c.entries)
+	c.order = nil
+}
+
+func (c *queryCache) get(key string) ([]string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	texts, ok := c.entries[key]
+	if ok {
+		c.stats.Hits++
+	} else {
+		c.stats.Misses++
+	}
+	return texts, ok
+}
+
+// put stores the result texts under key, evicting the oldest entry when
+// full.
+func (c *queryCache) put(key string, texts []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		return // No workspace was loaded
+	}
+	if _, ok := c.entries[key]; !ok {
+		c.order = append(c.order, key)
+	}
+	c.entries[key] = texts
+	if len(c.order) > maxCachedQueries {
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+}
+
+// snapshot returns the statistics of the cache.
+func (c *queryCache) snapshot() QueryCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Enabled = c.enabled
+	stats.Entries = len(c.entries)
+	return stats
+}
+
+// queryKey returns the cache key of a query tool call: a hash of the
+// workspace fingerprint, the project config and the call itself. It
+// reports false when the call can't be cached.
+func (s *MCPServer) queryKey(call *mcpsdk.CallToolRequest) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	snap := s.current()
+	if snap == nil || !isQueryTool(call.Params.Name) {
+		return "", false
+	}
+	s.queries.mu.Lock()
+	enabled := s.queries.enabled
+	s.queries.mu.Unlock()
+	if !enabled {
+		return "", false
+	}
+	canonical, ok := canonicalArguments(call)
+	if !ok {
+		return "", false
+	}
+	cfg, err := json.Marshal(s.config)
+	if err != nil {
+		return "", false
+	}
+	return hashParts([]byte(s.plans.workspaceFingerprint(snap)), cfg, []byte(call.Params.Name), canonical), true
+}
+
+// cacheQueries is middleware that answers a read-only analysis from the
+// query cache when the same call ran against the same workspace before.
+func (s *MCPServer) cacheQueries(next mcpsdk.MethodHandler) mcpsdk.MethodHandler {
+	return func(ctx context.Context, method string, req mcpsdk.Request) (mcpsdk.Result, error) {
+		call, ok := req.(*mcpsdk.CallToolRequest)
+		if !ok {
+			return next(ctx, method, req)
+		}
+		generation := s.Generation()
+		key, ok := s.queryKey(call)
+		if !ok {
+			return next(ctx, method, req)
+		}
+		if texts, ok := s.queries.get(key); ok {
+			result := &mcpsdk.CallToolResult{}
+			for _, text := range texts {
+				result.Content = append(result.Content, &mcpsdk.TextContent{Text: text})
+			}
+			return result, nil
+		}
+
+		res, err := next(ctx, method, req)
+		// A result may be of a later generation than the key.
+		if s.Generation() != generation {
+			return res, err
+		}
+		if result, ok := res.(*mcpsdk.CallToolResult); ok && err == nil && !result.IsError {
+			if texts, ok := resultTexts(result); ok {
+				s.queries.put(key, texts)
+			}
+		}
+		return res, err
+	}
+}
+
+// canonicalArguments returns the arguments of call with object keys
+// sorted, so equal arguments hash alike.
+func canonicalArguments(call *mcpsdk.CallToolRequest) ([]byte, bool) {
+	var args any
+	if len(call.Params.Arguments) > 0 {
+		if err := json.Unmarshal(call.Params.Arguments, &args); err != nil {
+			return nil, false
+		}
+	}
+	canonical, err := json.Marshal(args) // Object keys come out sorted
+	if err != nil {
+		return nil, false
+	}
+	return canonical, true
+}
+
+// hashParts returns the hex SHA-256 of parts, each terminated by a zero
+// byte so adjacent parts can't run into each other.
+func hashParts(parts ...[]byte) string {
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write(part)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
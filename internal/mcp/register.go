@@ -2,11 +2,15 @@ package mcp
 
 import mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
 
-// RegisterAllTools wires every gorefactor tool into the MCP server.
+// RegisterAllTools wires every gorefactor tool and resource into the MCP
+// server.
 func RegisterAllTools(s *mcpsdk.Server, state *MCPServer) {
+	s.AddReceivingMiddleware(state.logOperations, state.traceCalls, state.routeSessions, state.pinGeneration, state.cachePlans, state.cacheQueries)
 	registerWorkspaceTools(s, state)
+	registerSessionTools(s, state)
 	registerMoveTools(s, state)
 	registerRenameTools(s, state)
+	registerRewriteTools(s, state)
 	registerExtractTools(s, state)
 	registerInlineTools(s, state)
 	registerAnalysisTools(s, state)
@@ -17,5 +21,18 @@ func RegisterAllTools(s *mcpsdk.Server, state *MCPServer) {
 	registerChangeSignatureTools(s, state)
 	registerContextTools(s, state)
 	registerDeleteTools(s, state)
+	registerEncapsulateTools(s, state)
+	registerTypeTools(s, state)
+	registerInterfaceTools(s, state)
+	registerMockTools(s, state)
+	registerOrganizeTools(s, state)
+	registerBuildTagsTools(s, state)
 	registerFixTools(s, state)
+	registerHistoryTools(s, state)
+	registerStagingTools(s, state)
+	registerReportTools(s, state)
+	registerRegistryTools(s, state)
+	registerAPIResource(s, state)
+	registerXRefResource(s, state)
+	registerStatsResource(s, state)
 }
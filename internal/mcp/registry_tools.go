@@ -0,0 +1,152 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+
+	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/mamaar/gorefactor/pkg/analyzers"
+	"github.com/mamaar/gorefactor/pkg/sarif"
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// --- detect_<name> / fix_<name> for registered analyzers ---
+
+type RegisteredAnalyzerInput struct {
+	Package string `json:"package,omitempty" jsonschema:"specific package to analyze (empty for the whole workspace)"`
+}
+
+type DiagnosticItem struct {
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	EndLine   int    `json:"end_line,omitempty"`
+	EndColumn int    `json:"end_column,omitempty"`
+	Category  string `json:"category,omitempty"`
+	Message   string `json:"message"`
+	Fixable   bool   `json:"fixable,omitempty"` // The diagnostic carries a suggested fix
+}
+
+// registerRegistryTools exposes every analyzer in the analyzers registry.
+// Names that clash with a built-in analyzer are skipped.
+func registerRegistryTools(s *mcpsdk.Server, state *MCPServer) {
+	builtin := make(map[string]bool)
+	for _, r := range builtinAnalyzerRules(state.ProjectConfig(), "") {
+		builtin[r.rule.ID] = true
+	}
+	for _, reg := range analyzers.Registered() {
+		if builtin[reg.Name] {
+			state.logger.Warn("registered analyzer shadows a built-in analyzer; skipping", "analyzer", reg.Name)
+			continue
+		}
+		registerDetectTool(s, state, reg)
+		if reg.Fixable {
+			registerFixTool(s, state, reg)
+		}
+	}
+}
+
+func registerDetectTool(s *mcpsdk.Server, state *MCPServer, reg analyzers.Registration) {
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "detect_" + reg.Name,
+		Description: fmt.Sprintf("Run the %s analyzer and report its diagnostics. %s", reg.Name, reg.Description),
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in RegisteredAnalyzerInput) (*mcpsdk.CallToolResult, any, error) {
+		state.RLock()
+		defer state.RUnlock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+
+		rr, err := runRegistered(ws, state, reg, in.Package)
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+
+		items := make([]DiagnosticItem, len(rr.Diagnostics))
+		for i, d := range rr.Diagnostics {
+			start := ws.FileSet.Position(d.Pos)
+			items[i] = DiagnosticItem{
+				File:     start.Filename,
+				Line:     start.Line,
+				Column:   start.Column,
+				Category: d.Category,
+				Message:  d.Message,
+				Fixable:  reg.Fixable && len(d.SuggestedFixes) > 0,
+			}
+			if d.End.IsValid() {
+				end := ws.FileSet.Position(d.End)
+				items[i].EndLine, items[i].EndColumn = end.Line, end.Column
+			}
+		}
+		return textResult(map[string]any{
+			"diagnostics": items,
+			"total_count": len(items),
+		}), nil, nil
+	})
+}
+
+func registerFixTool(s *mcpsdk.Server, state *MCPServer, reg analyzers.Registration) {
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "fix_" + reg.Name,
+		Description: fmt.Sprintf("Apply the suggested fixes of the %s analyzer. %s", reg.Name, reg.Description),
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in RegisteredAnalyzerInput) (*mcpsdk.CallToolResult, any, error) {
+		state.RLock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+
+		rr, err := runRegistered(ws, state, reg, in.Package)
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+
+		changes := analyzers.DiagnosticsToChanges(ws.FileSet, rr.Diagnostics)
+		if len(changes) == 0 {
+			state.RUnlock()
+			return textResult(map[string]any{
+				"files_modified": []string{},
+				"changes_count":  0,
+				"message":        fmt.Sprintf("No fixable %s diagnostics found", reg.Name),
+			}), nil, nil
+		}
+
+		plan := analyzers.ChangesToPlan(changes)
+		result, err := executePlanWithUnlock(ctx, state, plan, "Fix "+reg.Name+" diagnostics")
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		return textResult(map[string]any{
+			"files_modified": result.ModifiedFiles,
+			"changes_count":  result.ChangeCount,
+		}), nil, nil
+	})
+}
+
+// runRegistered type-checks the analyzed packages, which standard
+// analyzers rely on, and runs reg over them.
+func runRegistered(ws *types.Workspace, state *MCPServer, reg analyzers.Registration, pkgFilter string) (*analyzers.RunResult, error) {
+	state.engine.EnsureTypeChecked(ws, analyzers.SelectPackages(ws, pkgFilter)...)
+	return analyzers.RunRegistered(ws, reg, pkgFilter)
+}
+
+// registeredRules returns a rule for each registered analyzer, for
+// run_analyzers.
+func registeredRules() []analyzerRule {
+	var rules []analyzerRule
+	for _, reg := range analyzers.Registered() {
+		rules = append(rules, analyzerRule{
+			rule:       newRule(reg.Name, sarif.LevelWarning, reg.Description, reg.Analyzer.Doc),
+			analyzer:   reg.Analyzer,
+			fixable:    reg.Fixable,
+			registered: true,
+		})
+	}
+	return rules
+}
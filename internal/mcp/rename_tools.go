@@ -2,6 +2,7 @@ package mcp
 
 import (
 	"context"
+	"slices"
 
 	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
 
@@ -11,9 +12,14 @@ import (
 // --- rename_symbol ---
 
 type RenameSymbolInput struct {
-	Symbol  string `json:"symbol" jsonschema:"current symbol name"`
-	NewName string `json:"new_name" jsonschema:"new name for the symbol"`
-	Package string `json:"package,omitempty" jsonschema:"package path (empty for workspace-wide)"`
+	Symbol      string `json:"symbol" jsonschema:"current symbol name"`
+	NewName     string `json:"new_name" jsonschema:"new name for the symbol"`
+	Package     string `json:"package,omitempty" jsonschema:"package path (empty for workspace-wide)"`
+	RenameTests bool   `json:"rename_tests,omitempty" jsonschema:"also rename the test, benchmark, example and fuzz functions named after the symbol"`
+	KeepAlias   bool   `json:"keep_alias,omitempty" jsonschema:"keep the old name of an exported symbol as a deprecated alias (wrapper function, type alias, var or const) so code outside the workspace keeps building"`
+
+	CheckDependents  bool `json:"check_dependents,omitempty" jsonschema:"report the uses of the symbol in the dependent repositories of .gorefactor.yaml, which the rename breaks"`
+	DependentPatches bool `json:"dependent_patches,omitempty" jsonschema:"with check_dependents, include a patch per dependent repository renaming its uses"`
 }
 
 // --- rename_package ---
@@ -21,6 +27,7 @@ type RenameSymbolInput struct {
 type RenamePackageInput struct {
 	PackagePath    string `json:"package_path" jsonschema:"path to the package directory"`
 	NewPackageName string `json:"new_package_name" jsonschema:"new package name"`
+	Preview        bool   `json:"preview,omitempty" jsonschema:"return the planned changes without applying them"`
 }
 
 // --- rename_method ---
@@ -32,6 +39,20 @@ type RenameMethodInput struct {
 	PackagePath   string `json:"package_path,omitempty" jsonschema:"package path (empty for workspace-wide)"`
 }
 
+// --- bulk_rename ---
+
+type BulkRenameInput struct {
+	Renames []RenameEntry `json:"renames,omitempty" jsonschema:"renames to make"`
+	File    string        `json:"file,omitempty" jsonschema:"mapping file relative to the workspace root: CSV with old,new[,package] records, or JSON with an array of {old, new, package} objects or an object of old to new names"`
+	Preview bool          `json:"preview,omitempty" jsonschema:"return the planned changes without applying them"`
+}
+
+type RenameEntry struct {
+	Old     string `json:"old" jsonschema:"current name, or Type.Method for a method, optionally prefixed with package:"`
+	New     string `json:"new" jsonschema:"new name"`
+	Package string `json:"package,omitempty" jsonschema:"package path declaring the symbol (empty for every package declaring it)"`
+}
+
 func registerRenameTools(s *mcpsdk.Server, state *MCPServer) {
 	mcpsdk.AddTool(s, &mcpsdk.Tool{
 		Name:        "rename_symbol",
@@ -51,16 +72,21 @@ func registerRenameTools(s *mcpsdk.Server, state *MCPServer) {
 			scope = types.PackageScope
 		}
 		plan, err := state.GetEngine().RenameSymbol(ws, types.RenameSymbolRequest{
-			SymbolName: in.Symbol,
-			NewName:    in.NewName,
-			Package:    pkg,
-			Scope:      scope,
+			SymbolName:  in.Symbol,
+			NewName:     in.NewName,
+			Package:     pkg,
+			Scope:       scope,
+			RenameTests: in.RenameTests,
+			KeepAlias:   in.KeepAlias,
+
+			CheckDependents:  in.CheckDependents,
+			DependentPatches: in.DependentPatches,
 		})
 		if err != nil {
 			state.RUnlock()
 			return errResult(err), nil, nil
 		}
-		result, err := executePlanWithUnlock(state, plan, "rename "+in.Symbol+" → "+in.NewName)
+		result, err := executePlanWithUnlock(ctx, state, plan, "rename "+in.Symbol+" → "+in.NewName)
 		if err != nil {
 			return errResult(err), nil, nil
 		}
@@ -69,7 +95,7 @@ func registerRenameTools(s *mcpsdk.Server, state *MCPServer) {
 
 	mcpsdk.AddTool(s, &mcpsdk.Tool{
 		Name:        "rename_package",
-		Description: "Rename a Go package. Updates the package declaration in all files and import statements across the workspace.",
+		Description: "Rename a Go package. Updates the package declaration in all files and import statements across the workspace. With preview, returns the planned changes without applying them.",
 	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in RenamePackageInput) (*mcpsdk.CallToolResult, any, error) {
 		state.RLock()
 
@@ -94,7 +120,7 @@ func registerRenameTools(s *mcpsdk.Server, state *MCPServer) {
 			state.RUnlock()
 			return errResult(err), nil, nil
 		}
-		result, err := executePlanWithUnlock(state, plan, "rename package → "+in.NewPackageName)
+		result, err := previewPlanWithUnlock(ctx, state, plan, "rename package → "+in.NewPackageName, in.Preview)
 		if err != nil {
 			return errResult(err), nil, nil
 		}
@@ -126,7 +152,43 @@ func registerRenameTools(s *mcpsdk.Server, state *MCPServer) {
 			state.RUnlock()
 			return errResult(err), nil, nil
 		}
-		result, err := executePlanWithUnlock(state, plan, "rename method "+in.TypeName+"."+in.MethodName+" → "+in.NewMethodName)
+		result, err := executePlanWithUnlock(ctx, state, plan, "rename method "+in.TypeName+"."+in.MethodName+" → "+in.NewMethodName)
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		return textResult(result), nil, nil
+	})
+
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "bulk_rename",
+		Description: "Rename many symbols and methods in one plan, listed inline or in a CSV or JSON mapping file, for API standardization. Each rename updates every reference as rename_symbol or rename_method would. All renames are checked before anything is written: missing symbols, names already taken and renames colliding with each other are reported together, and any of them keeps the plan from being applied.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in BulkRenameInput) (*mcpsdk.CallToolResult, any, error) {
+		state.RLock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+		request := types.BulkRenameRequest{File: in.File}
+		if in.File != "" {
+			request.File = resolveFile(ws, in.File)
+		}
+		for _, r := range in.Renames {
+			request.Renames = append(request.Renames, types.SymbolRename{Old: r.Old, New: r.New, Package: r.Package})
+		}
+		plan, err := state.GetEngine().BulkRename(ws, request)
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+		// Report every conflict at once rather than the first one applying finds.
+		conflicts := slices.DeleteFunc(slices.Clone(plan.Impact.PotentialIssues), func(i types.Issue) bool { return i.Severity != types.Error })
+		if !in.Preview && len(conflicts) > 0 {
+			state.RUnlock()
+			return errResult(&types.ValidationError{Issues: conflicts}), nil, nil
+		}
+		result, err := previewPlanWithUnlock(ctx, state, plan, "bulk rename", in.Preview)
 		if err != nil {
 			return errResult(err), nil, nil
 		}
@@ -0,0 +1,420 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
+	"golang.org/x/tools/go/analysis"
+
+	wsanalysis "github.com/mamaar/gorefactor/pkg/analysis"
+	"github.com/mamaar/gorefactor/pkg/analyzers"
+	"github.com/mamaar/gorefactor/pkg/analyzers/booleanbranch"
+	"github.com/mamaar/gorefactor/pkg/analyzers/complexity"
+	"github.com/mamaar/gorefactor/pkg/analyzers/deepifelse"
+	"github.com/mamaar/gorefactor/pkg/analyzers/doccoverage"
+	"github.com/mamaar/gorefactor/pkg/analyzers/envbool"
+	"github.com/mamaar/gorefactor/pkg/analyzers/errorreturn"
+	"github.com/mamaar/gorefactor/pkg/analyzers/errorsentinel"
+	"github.com/mamaar/gorefactor/pkg/analyzers/errorwrap"
+	"github.com/mamaar/gorefactor/pkg/analyzers/ifinit"
+	"github.com/mamaar/gorefactor/pkg/analyzers/loopconv"
+	"github.com/mamaar/gorefactor/pkg/analyzers/magicnumber"
+	"github.com/mamaar/gorefactor/pkg/analyzers/missingctx"
+	"github.com/mamaar/gorefactor/pkg/analyzers/modernize"
+	"github.com/mamaar/gorefactor/pkg/analyzers/naming"
+	"github.com/mamaar/gorefactor/pkg/analyzers/pipeline"
+	"github.com/mamaar/gorefactor/pkg/analyzers/positionallit"
+	"github.com/mamaar/gorefactor/pkg/analyzers/testsetup"
+	"github.com/mamaar/gorefactor/pkg/config"
+	"github.com/mamaar/gorefactor/pkg/sarif"
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// --- run_analyzers ---
+
+type RunAnalyzersInput struct {
+	Package   string   `json:"package,omitempty" jsonschema:"package path to analyze (empty for the whole workspace)"`
+	Analyzers []string `json:"analyzers,omitempty" jsonschema:"analyzers to run (default: all): complexity, unused, ifinit, errorwrap, errorsentinel, booleanbranch, deepifelse, envbool, missingctx, pipeline, magicnumber, errorreturn, positionallit, doccoverage, naming, modernize, loopconv, testsetup, and any registered analyzers"`
+	Format    string   `json:"format,omitempty" jsonschema:"'json' (default) for a list of findings, 'sarif' for a SARIF 2.1.0 log for code scanning uploads"`
+	Files     []string `json:"files,omitempty" jsonschema:"only analyze the packages containing these files and report findings in them, for incremental checks of changed files"`
+
+	Baseline      string `json:"baseline,omitempty" jsonschema:"baseline file of accepted findings, which are not reported (default: gorefactor-baseline.json in the workspace root, if it exists)"`
+	NoBaseline    bool   `json:"no_baseline,omitempty" jsonschema:"report the findings recorded in the baseline too"`
+	WriteBaseline bool   `json:"write_baseline,omitempty" jsonschema:"record every finding in the baseline file, replacing its contents; requires a full run, without package, files or analyzers"`
+}
+
+type FindingItem struct {
+	Rule      string `json:"rule"`
+	Level     string `json:"level"`
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	EndLine   int    `json:"end_line,omitempty"`
+	EndColumn int    `json:"end_column,omitempty"`
+	Message   string `json:"message"`
+	Fixable   bool   `json:"fixable,omitempty"` // A fix_* tool can rewrite the finding
+}
+
+// analyzerRule ties an analyzer to the rule its findings are reported under.
+type analyzerRule struct {
+	rule       *sarif.Rule
+	analyzer   *analysis.Analyzer // nil for unused, which is not an analysis pass
+	tests      bool               // Runs on _test.go files
+	fixable    bool               // A fix_* tool rewrites the findings
+	typed      bool               // Needs type information
+	registered bool               // From the analyzers registry; needs type information
+}
+
+func newRule(id, level, short, full string) *sarif.Rule {
+	return &sarif.Rule{
+		ID:                   id,
+		ShortDescription:     sarif.Message{Text: short},
+		FullDescription:      &sarif.Message{Text: full},
+		HelpURI:              "https://github.com/mamaar/gorefactor#tools",
+		DefaultConfiguration: &sarif.Configuration{Level: level},
+	}
+}
+
+// analyzerRules returns every analyzer: the built-in ones, then those in
+// the analyzers registry that don't shadow a built-in one.
+func analyzerRules(cfg *config.Config, goVersion string) []analyzerRule {
+	rules := builtinAnalyzerRules(cfg, goVersion)
+	for _, r := range registeredRules() {
+		if !slices.ContainsFunc(rules, func(b analyzerRule) bool { return b.rule.ID == r.rule.ID }) {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
+
+// builtinAnalyzerRules returns gorefactor's own analyzers, configured from
+// the project config and the module's Go version the same way the detect_*
+// tools are.
+func builtinAnalyzerRules(cfg *config.Config, goVersion string) []analyzerRule {
+	a := cfg.Analyzers
+	return []analyzerRule{
+		{
+			rule:     newRule("complexity", sarif.LevelNote, "Function is too complex", fmt.Sprintf("The function's cyclomatic complexity is at least %d. Split it with extract_function or extract_method.", a.Complexity.MinComplexity)),
+			analyzer: complexity.NewAnalyzer(complexity.WithMinComplexity(a.Complexity.MinComplexity)),
+		},
+		{
+			rule: newRule("unused", sarif.LevelWarning, "Unused symbol", "The unexported symbol is never referenced. Remove it with safe_delete."),
+		},
+		{
+			rule:     newRule("ifinit", sarif.LevelNote, "If-init assignment", "An assignment inside an if statement's init should be a separate statement. Fix with fix_if_init_assignments."),
+			analyzer: ifinit.Analyzer,
+			fixable:  true,
+		},
+		{
+			rule:     newRule("errorwrap", sarif.LevelWarning, "Error returned without context", "An error is returned bare, or wrapped with %v instead of %w. Fix with fix_error_wrapping."),
+			analyzer: errorwrap.NewAnalyzer(errorwrap.WithSeverity(errorwrap.Severity(a.ErrorWrap.Severity))),
+			fixable:  true,
+		},
+		{
+			rule:     newRule("errorsentinel", sarif.LevelWarning, "Error checked by message", "An error is identified by comparing its message string. Rewrite the check to errors.Is with fix_error_string_checks."),
+			analyzer: errorsentinel.Analyzer,
+			fixable:  true,
+		},
+		{
+			rule:     newRule("booleanbranch", sarif.LevelNote, "Boolean variables drive branching", "Intermediate boolean variables select between branches that a switch would express directly. Fix with fix_boolean_branching."),
+			analyzer: booleanbranch.NewAnalyzer(booleanbranch.WithMinBranches(a.BooleanBranch.MinBranches)),
+			fixable:  true,
+		},
+		{
+			rule: newRule("deepifelse", sarif.LevelWarning, "Deeply nested if-else chain", "The happy path is nested in if-else chains that early returns would flatten. Fix with fix_deep_if_else_chains."),
+			analyzer: deepifelse.NewAnalyzer(
+				deepifelse.WithMaxNesting(a.DeepIfElse.MaxNesting),
+				deepifelse.WithMinElseLines(a.DeepIfElse.MinElseLines),
+			),
+			fixable: true,
+		},
+		{
+			rule:     newRule("envbool", sarif.LevelWarning, "Environment boolean passed down", "A boolean such as isProd is passed down the call stack; resolve the behaviour once at initialization instead."),
+			analyzer: envbool.NewAnalyzer(envbool.WithMaxDepth(a.EnvBool.MaxDepth)),
+		},
+		{
+			rule:     newRule("missingctx", sarif.LevelWarning, "Context created instead of accepted", "The function creates its own context.Context instead of accepting one. Fix with add_context_parameter or thread_context."),
+			analyzer: missingctx.Analyzer,
+		},
+		{
+			rule: newRule("pipeline", sarif.LevelNote, "Loop runs as pipeline stages", "A long loop body runs as produce, transform and consume stages. Split it with extract_pipeline_stages."),
+			analyzer: pipeline.NewAnalyzer(
+				pipeline.WithMinStatements(a.Pipeline.MinStatements),
+				pipeline.WithMinStages(a.Pipeline.MinStages),
+			),
+		},
+		{
+			rule:     newRule("magicnumber", sarif.LevelNote, "Magic number", "A literal is repeated across the package or compared in a conditional. Replace it with a named constant with fix_magic_numbers."),
+			analyzer: magicnumber.NewAnalyzer(magicnumber.WithMinOccurrences(a.MagicNumber.MinOccurrences)),
+		},
+		{
+			rule:     newRule("errorreturn", sarif.LevelWarning, "Error not returned last or unconventionally named", "An error result precedes the function's other results, or an error variable isn't named err. Fix with fix_error_returns."),
+			analyzer: errorreturn.Analyzer,
+			typed:    true,
+		},
+		{
+			rule:     newRule("positionallit", sarif.LevelNote, "Struct literal without field names", "A struct literal lists its values by position, which adding or reordering fields breaks. Name them with fix_positional_literals."),
+			analyzer: positionallit.Analyzer,
+			fixable:  true,
+			typed:    true,
+		},
+		{
+			rule:     newRule("doccoverage", sarif.LevelNote, "Exported declaration without doc comment", "An exported function, method, type, constant or variable has no doc comment. Insert one to fill in with fix_missing_docs."),
+			analyzer: doccoverage.Analyzer,
+			fixable:  true,
+		},
+		{
+			rule:     newRule("naming", sarif.LevelNote, "Unconventional name", "A name has an initialism in mixed case, underscores, or repeats its package's name. Rename it with fix_naming."),
+			analyzer: naming.Analyzer,
+		},
+		{
+			rule:     newRule("modernize", sarif.LevelNote, "Older idiom", "Code uses an idiom with a newer replacement the module's Go version allows, such as interface{} for any or a three-clause loop for ranging over an int. Apply it with fix_modernize."),
+			analyzer: modernize.NewAnalyzer(modernize.WithGoVersion(goVersion)),
+			fixable:  true,
+			typed:    true,
+		},
+		{
+			rule:     newRule("loopconv", sarif.LevelNote, "Loop with a slices or maps equivalent", "A loop searches, filters or sorts a slice, or collects or copies a map, as a function of the slices or maps packages does. Replace it with fix_loop_conversions."),
+			analyzer: loopconv.NewAnalyzer(loopconv.WithGoVersion(goVersion)),
+			fixable:  true,
+			typed:    true,
+		},
+		{
+			rule:     newRule("testsetup", sarif.LevelNote, "Duplicated test setup", "Several tests start with the same setup statements. Extract them with extract_test_helper."),
+			analyzer: testsetup.NewAnalyzer(),
+			tests:    true,
+		},
+	}
+}
+
+func registerReportTools(s *mcpsdk.Server, state *MCPServer) {
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "run_analyzers",
+		Description: "Run every code quality analyzer (or the named ones) in one pass and report all findings, either as a list or as a SARIF 2.1.0 log for GitHub code scanning and other dashboards.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in RunAnalyzersInput) (*mcpsdk.CallToolResult, any, error) {
+		state.RLock()
+		defer state.RUnlock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		if in.Format != "" && in.Format != "json" && in.Format != "sarif" {
+			return errResult(fmt.Errorf("unknown format %q: want json or sarif", in.Format)), nil, nil
+		}
+		if in.Package != "" && len(in.Files) > 0 {
+			return errResult(fmt.Errorf("specify package or files, not both")), nil, nil
+		}
+		if in.WriteBaseline && (in.Package != "" || len(in.Files) > 0 || len(in.Analyzers) > 0) {
+			return errResult(fmt.Errorf("write_baseline records a full run: it can't be combined with package, files or analyzers")), nil, nil
+		}
+		baselinePath := filepath.Join(ws.RootPath, analyzers.BaselineFile)
+		if in.Baseline != "" {
+			baselinePath = resolveFile(ws, in.Baseline)
+		}
+		inBaseline := func(rule, file, message string) bool { return false }
+		if !in.NoBaseline && !in.WriteBaseline {
+			baseline, err := analyzers.LoadBaseline(baselinePath)
+			switch {
+			case err == nil:
+				inBaseline = baseline.Matcher()
+			case !errors.Is(err, fs.ErrNotExist) || in.Baseline != "":
+				return errResult(err), nil, nil
+			}
+		}
+		pkgFilters, files := []string{in.Package}, make(map[string]bool)
+		if len(in.Files) > 0 {
+			pkgFilters = nil
+			for _, f := range in.Files {
+				f = resolveFile(ws, f)
+				files[f] = true
+				if dir := filepath.Dir(f); !slices.Contains(pkgFilters, dir) {
+					if _, ok := ws.Packages[dir]; ok {
+						pkgFilters = append(pkgFilters, dir)
+					}
+				}
+			}
+			slices.Sort(pkgFilters)
+		}
+
+		rules := analyzerRules(state.ProjectConfig(), moduleGoVersion(ws))
+		if len(in.Analyzers) > 0 {
+			var known []string
+			for _, r := range rules {
+				known = append(known, r.rule.ID)
+			}
+			for _, name := range in.Analyzers {
+				if !slices.Contains(known, name) {
+					return errResult(fmt.Errorf("unknown analyzer %q; known analyzers: %s", name, strings.Join(known, ", "))), nil, nil
+				}
+			}
+			rules = slices.DeleteFunc(rules, func(r analyzerRule) bool { return !slices.Contains(in.Analyzers, r.rule.ID) })
+		}
+
+		run := sarif.NewRun("gorefactor", "1.0.0", "https://github.com/mamaar/gorefactor", ws.RootPath)
+		var items []FindingItem
+		byID := make(map[string]analyzerRule)
+		for _, r := range rules {
+			run.AddRule(r.rule)
+			byID[r.rule.ID] = r
+		}
+		// Findings are checked against ignore directives here as well as
+		// by the runner, for unused and for registered analyzers whose
+		// rule ID differs from their name.
+		ignores := workspaceIgnores(ws)
+		var recorded []analyzers.BaselineFinding
+		suppressed := 0
+		keep := func(rule, file string, line int, message string) bool {
+			if ignores.Ignored(rule, file, line) {
+				return false
+			}
+			rel, err := filepath.Rel(ws.RootPath, file)
+			if err != nil {
+				rel = file
+			}
+			rel = filepath.ToSlash(rel)
+			if inBaseline(rule, rel, message) {
+				suppressed++
+				return false
+			}
+			recorded = append(recorded, analyzers.BaselineFinding{Rule: rule, File: rel, Line: line, Message: message})
+			return true
+		}
+		add := func(res *sarif.Result, file string) {
+			region := res.Locations[0].PhysicalLocation.Region
+			items = append(items, FindingItem{
+				Rule:      res.RuleID,
+				Level:     byID[res.RuleID].rule.DefaultConfiguration.Level,
+				File:      file,
+				Line:      region.StartLine,
+				Column:    region.StartColumn,
+				EndLine:   region.EndLine,
+				EndColumn: region.EndColumn,
+				Message:   res.Message.Text,
+				Fixable:   byID[res.RuleID].fixable,
+			})
+		}
+
+		reported := func(file string) bool { return len(files) == 0 || files[file] }
+
+		for _, r := range rules {
+			if r.analyzer == nil {
+				unused, err := unusedSymbols(ws, state, in.Package)
+				if err != nil {
+					return errResult(err), nil, nil
+				}
+				for _, u := range unused {
+					sym := u.Symbol
+					if !reported(sym.File) {
+						continue
+					}
+					msg := fmt.Sprintf("%s %s is unused", strings.ToLower(sym.Kind.String()), sym.Name)
+					if u.Reason != "" {
+						msg += ": " + u.Reason
+					}
+					if !keep(r.rule.ID, sym.File, sym.Line, msg) {
+						continue
+					}
+					region := &sarif.Region{StartLine: sym.Line, StartColumn: sym.Column}
+					add(run.AddResult(r.rule.ID, "", msg, sym.File, region), sym.File)
+				}
+				continue
+			}
+
+			for _, pkgFilter := range pkgFilters {
+				rr, err := runRule(ws, state, r, pkgFilter)
+				if err != nil {
+					return errResult(fmt.Errorf("%s: %w", r.rule.ID, err)), nil, nil
+				}
+				for _, d := range rr.Diagnostics {
+					pos := ws.FileSet.Position(d.Pos)
+					if reported(pos.Filename) && keep(r.rule.ID, pos.Filename, pos.Line, d.Message) {
+						add(run.AddDiagnostic(ws.FileSet, r.rule.ID, "", d), pos.Filename)
+					}
+				}
+			}
+		}
+
+		if in.WriteBaseline {
+			if err := (&analyzers.Baseline{Findings: recorded}).Write(baselinePath); err != nil {
+				return errResult(fmt.Errorf("write baseline: %w", err)), nil, nil
+			}
+		}
+
+		if in.Format == "sarif" {
+			run.SortResults()
+			return textResult(sarif.NewLog(run)), nil, nil
+		}
+		slices.SortStableFunc(items, func(a, b FindingItem) int {
+			if c := strings.Compare(a.File, b.File); c != 0 {
+				return c
+			}
+			if a.Line != b.Line {
+				return a.Line - b.Line
+			}
+			return a.Column - b.Column
+		})
+		counts := make(map[string]int)
+		for _, item := range items {
+			counts[item.Rule]++
+		}
+		result := map[string]any{
+			"findings":    items,
+			"total_count": len(items),
+			"counts":      counts,
+		}
+		if suppressed > 0 {
+			result["baseline_suppressed"] = suppressed
+		}
+		if in.WriteBaseline {
+			result["baseline_written"] = baselinePath
+		}
+		return textResult(result), nil, nil
+	})
+}
+
+// runRule runs the analysis pass of r over the packages pkgFilter selects.
+func runRule(ws *types.Workspace, state *MCPServer, r analyzerRule, pkgFilter string) (*analyzers.RunResult, error) {
+	switch {
+	case r.registered || r.typed:
+		return runRegistered(ws, state, analyzers.Registration{Name: r.rule.ID, Analyzer: r.analyzer}, pkgFilter)
+	case r.tests:
+		return analyzers.RunTests(ws, r.analyzer, pkgFilter)
+	}
+	return analyzers.Run(ws, r.analyzer, pkgFilter)
+}
+
+// workspaceIgnores collects the ignore directives of every file of ws.
+func workspaceIgnores(ws *types.Workspace) analyzers.Ignores {
+	var asts []*ast.File
+	for _, pkg := range ws.Packages {
+		for _, files := range []map[string]*types.File{pkg.Files, pkg.TestFiles} {
+			for _, f := range files {
+				if f.AST != nil {
+					asts = append(asts, f.AST)
+				}
+			}
+		}
+	}
+	return analyzers.NewIgnores(ws.FileSet, asts)
+}
+
+// unusedSymbols returns the unexported symbols nothing references, limited
+// to pkgFilter when it is set.
+func unusedSymbols(ws *types.Workspace, state *MCPServer, pkgFilter string) ([]*wsanalysis.UnusedSymbol, error) {
+	unused, err := wsanalysis.NewUnusedAnalyzer(ws, state.logger).GetUnusedUnexportedSymbols()
+	if err != nil || pkgFilter == "" {
+		return unused, err
+	}
+	resolved := types.ResolvePackagePath(ws, pkgFilter)
+	return slices.DeleteFunc(unused, func(u *wsanalysis.UnusedSymbol) bool {
+		return u.Symbol.Package != pkgFilter && u.Symbol.Package != resolved
+	}), nil
+}
@@ -1,11 +1,16 @@
 package mcp
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
+	"go.opentelemetry.io/otel/attribute"
 
+	"github.com/mamaar/gorefactor/internal/telemetry"
+	"github.com/mamaar/gorefactor/pkg/refactor"
 	"github.com/mamaar/gorefactor/pkg/types"
 )
 
@@ -16,6 +21,16 @@ type PlanResult struct {
 	ChangeCount   int      `json:"change_count"`
 	ModifiedFiles []string `json:"modified_files"`
 	Success       bool     `json:"success"`
+	Warnings      []string `json:"warnings,omitempty"` // Warning-level issues found while planning
+	PlanHash      string   `json:"plan_hash"`          // Equal for plans making the same changes
+
+	Preview bool           `json:"preview,omitempty"` // The plan was not applied
+	Staged  bool           `json:"staged,omitempty"`  // The plan was staged in memory; apply_staged writes it
+	Changes []types.Change `json:"changes,omitempty"` // Planned changes, set in preview mode
+	Skipped []types.Change `json:"skipped,omitempty"` // Changes not applied for being below the minimum confidence
+
+	Compatibility []types.CompatibilityReport `json:"compatibility,omitempty"` // Uses in dependent repositories, when checked
+	Git           *refactor.GitApplyResult    `json:"git,omitempty"`           // Branch, commit and worktree, when applied through git
 }
 
 // AnalysisResult is the structured output returned by read-only analysis tools.
@@ -25,32 +40,169 @@ type AnalysisResult struct {
 }
 
 // executePlan validates, executes, and returns a PlanResult for the given plan.
-func executePlan(state *MCPServer, plan *types.RefactoringPlan, desc string) (*PlanResult, error) {
-	if err := state.GetEngine().ExecutePlan(plan); err != nil {
+func executePlan(ctx context.Context, state *MCPServer, plan *types.RefactoringPlan, desc string) (_ *PlanResult, err error) {
+	capturePlan(ctx, plan, desc)
+	observePlan(ctx, plan, desc)
+	state.mu.RLock()
+	preview, gitApply := state.preview, state.git
+	state.mu.RUnlock()
+	if preview {
+		return previewPlan(plan, desc), nil
+	}
+
+	release, err := state.writer.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	if err := state.checkFresh(ctx, plan); err != nil {
+		return nil, err
+	}
+
+	if staged, err := stagePlan(state, plan); staged || err != nil {
+		if err != nil {
+			return nil, fmt.Errorf("stage plan: %w", err)
+		}
+		return &PlanResult{
+			Description:   desc,
+			AffectedFiles: plan.AffectedFiles,
+			ChangeCount:   len(plan.Changes),
+			ModifiedFiles: []string{},
+			Success:       true,
+			Warnings:      planWarnings(plan),
+			PlanHash:      refactor.PlanHash(plan),
+			Compatibility: planCompatibility(plan),
+			Staged:        true,
+		}, nil
+	}
+
+	done, err := state.beginApply()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	start := time.Now()
+	ctx, span := telemetry.Tracer().Start(ctx, telemetry.SpanExecutePlan)
+	span.SetAttributes(
+		attribute.String("gorefactor.plan.description", desc),
+		attribute.Int("gorefactor.plan.changes", len(plan.Changes)),
+		attribute.Int("gorefactor.plan.files", len(plan.AffectedFiles)),
+	)
+	defer func() {
+		telemetry.End(span, err)
+		telemetry.ObservePlanExecution(time.Since(start), err)
+	}()
+
+	var gitResult *refactor.GitApplyResult
+	if gitApply != nil {
+		git := *gitApply
+		git.Engine = state.GetEngine()
+		if gitResult, err = git.Apply(ctx, plan); err != nil {
+			return nil, fmt.Errorf("execute plan: %w", err)
+		}
+	} else if err := state.GetEngine().ExecutePlanContext(ctx, plan); err != nil {
 		return nil, fmt.Errorf("execute plan: %w", err)
 	}
+	// ExecutePlanContext left out the changes below the minimum confidence.
+	applied, skipped := refactor.SelectConfidence(plan, state.GetEngine().Config().MinConfidence)
 
-	// Synchronously update workspace state with the changes we just wrote
-	if err := state.SyncWorkspaceChanges(plan.AffectedFiles); err != nil {
-		state.logger.Warn("workspace sync failed", "err", err)
-		// Don't fail the operation - changes are already on disk
+	// A plan applied in a worktree left the workspace as it was.
+	modified := applied.AffectedFiles
+	if gitResult != nil && !gitResult.InPlace {
+		modified = []string{}
+	} else {
+		// Synchronously update workspace state with the changes we just wrote
+		if err := state.SyncWorkspaceChanges(applied.AffectedFiles); err != nil {
+			state.logger.WarnContext(ctx, "workspace sync failed", "err", err)
+			// Don't fail the operation - changes are already on disk
+		}
+		if err := state.verifyWorkspace("after " + desc); err != nil {
+			return nil, err
+		}
 	}
 
+	return &PlanResult{
+		Description:   desc,
+		AffectedFiles: applied.AffectedFiles,
+		ChangeCount:   len(applied.Changes),
+		ModifiedFiles: modified,
+		Success:       true,
+		Warnings:      planWarnings(plan),
+		PlanHash:      refactor.PlanHash(plan),
+		Compatibility: planCompatibility(plan),
+		Skipped:       skipped,
+		Git:           gitResult,
+	}, nil
+}
+
+// previewPlan returns the PlanResult of plan without applying it.
+func previewPlan(plan *types.RefactoringPlan, desc string) *PlanResult {
 	return &PlanResult{
 		Description:   desc,
 		AffectedFiles: plan.AffectedFiles,
 		ChangeCount:   len(plan.Changes),
-		ModifiedFiles: plan.AffectedFiles,
+		ModifiedFiles: []string{},
 		Success:       true,
-	}, nil
+		Warnings:      planWarnings(plan),
+		PlanHash:      refactor.PlanHash(plan),
+		Compatibility: planCompatibility(plan),
+		Preview:       true,
+		Changes:       plan.Changes,
+	}
+}
+
+// planWarnings formats the Warning-level issues of plan.
+func planWarnings(plan *types.RefactoringPlan) []string {
+	var warnings []string
+	if plan.Impact != nil {
+		for _, issue := range plan.Impact.PotentialIssues {
+			if issue.Severity == types.Warning {
+				warnings = append(warnings, fmt.Sprintf("%s:%d: %s", issue.File, issue.Line, issue.Description))
+			}
+		}
+	}
+	return warnings
+}
+
+// planCompatibility returns the compatibility reports of plan, if it checked
+// dependent repositories.
+func planCompatibility(plan *types.RefactoringPlan) []types.CompatibilityReport {
+	if plan.Impact == nil {
+		return nil
+	}
+	return plan.Impact.Compatibility
+}
+
+// ApplyPlan applies a plan made outside a tool call, such as one sent to
+// the HTTP server, the way the mutating tools apply theirs: staged while
+// staging, through git when configured, and synced into the workspace.
+func (s *MCPServer) ApplyPlan(ctx context.Context, plan *types.RefactoringPlan, desc string) (*PlanResult, error) {
+	s.sessionMu.RLock()
+	defer s.sessionMu.RUnlock()
+	return executePlan(ctx, s, plan, desc)
 }
 
 // executePlanWithUnlock releases the read lock before calling executePlan.
-// This prevents deadlock when executePlan calls SyncWorkspaceChanges which needs a write lock.
+// This prevents deadlock when executePlan stages the plan or syncs the
+// workspace, which take the write lock while plans are staged.
 // Use this when the caller holds a read lock with defer RUnlock().
-func executePlanWithUnlock(state *MCPServer, plan *types.RefactoringPlan, desc string) (*PlanResult, error) {
+func executePlanWithUnlock(ctx context.Context, state *MCPServer, plan *types.RefactoringPlan, desc string) (*PlanResult, error) {
+	state.RUnlock()
+	return executePlan(ctx, state, plan, desc)
+}
+
+// previewPlanWithUnlock is executePlanWithUnlock for tools with a preview
+// input: when preview is set the plan is returned without being applied, as
+// in preview mode.
+func previewPlanWithUnlock(ctx context.Context, state *MCPServer, plan *types.RefactoringPlan, desc string, preview bool) (*PlanResult, error) {
+	if !preview {
+		return executePlanWithUnlock(ctx, state, plan, desc)
+	}
 	state.RUnlock()
-	return executePlan(state, plan, desc)
+	capturePlan(ctx, plan, desc)
+	observePlan(ctx, plan, desc)
+	return previewPlan(plan, desc), nil
 }
 
 // textResult is a convenience that marshals v to JSON and wraps it in a
@@ -64,9 +216,25 @@ func textResult(v any) *mcpsdk.CallToolResult {
 	}
 }
 
-// errResult returns a CallToolResult that signals an error.
+// errResult returns a CallToolResult that signals an error. The error's
+// code, validation issues and recovery suggestions are appended as a JSON
+// text block.
 func errResult(err error) *mcpsdk.CallToolResult {
 	r := &mcpsdk.CallToolResult{}
 	r.SetError(err)
+	details := map[string]any{}
+	if code := types.CodeOf(err); code != "" {
+		details["code"] = code
+	}
+	if issues := types.IssuesOf(err); len(issues) > 0 {
+		details["issues"] = issues
+	}
+	if suggestions := types.SuggestionsOf(err); len(suggestions) > 0 {
+		details["suggestions"] = suggestions
+	}
+	if len(details) > 0 {
+		b, _ := json.MarshalIndent(details, "", "  ")
+		r.Content = append(r.Content, &mcpsdk.TextContent{Text: string(b)})
+	}
 	return r
 }
@@ -0,0 +1,132 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+
+	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/mamaar/gorefactor/pkg/refactor"
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// --- rewrite ---
+
+type RewriteInput struct {
+	Pattern     string            `json:"pattern" jsonschema:"Go expression to find, with holes: :[name] matches any expression, :[name...] any number of list elements such as call arguments, :[name~regexp] an expression whose source matches regexp; :[_] matches without binding. A selector on a quoted import path, as in \"github.com/pkg/errors\".Wrap, only matches references to that package"`
+	Replacement string            `json:"replacement" jsonschema:"Go expression to replace each match with, using the pattern's holes; a selector on a quoted import path is written with the file's name for the package, importing it as needed"`
+	Where       map[string]string `json:"where,omitempty" jsonschema:"type by hole that the hole's expressions must be assignable to, e.g. {\"err\": \"error\"}; needs the package to type-check"`
+	Package     string            `json:"package,omitempty" jsonschema:"only rewrite this package (default: all)"`
+	Preview     bool              `json:"preview,omitempty" jsonschema:"return the planned changes without applying them"`
+}
+
+// --- migrate ---
+
+type MigrateInput struct {
+	Pack    string `json:"pack,omitempty" jsonschema:"built-in migration pack (ioutil, pkg-errors, x-net-context) or a YAML pack file relative to the workspace root; empty lists the built-in packs"`
+	Package string `json:"package,omitempty" jsonschema:"only migrate this package (default: all)"`
+	Preview bool   `json:"preview,omitempty" jsonschema:"return the planned changes without applying them"`
+}
+
+// MigrateResult is the result of a migration, with how many expressions
+// each rule of the pack rewrote.
+type MigrateResult struct {
+	*PlanResult
+	Rules []refactor.MigrationRuleStats `json:"rules"`
+}
+
+func registerRewriteTools(s *mcpsdk.Server, state *MCPServer) {
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "rewrite",
+		Description: "Structural search and replace, like gofmt -r with comby-style holes: replace every expression matching a pattern, e.g. errors.Wrap(:[err], :[msg]) → fmt.Errorf(\"%s: %w\", :[msg], :[err]). Matches are syntactic, ignoring layout and comments; where guards restrict holes to expressions of a type. Imports the replacements need are added and the ones the rewritten files no longer use are removed.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in RewriteInput) (*mcpsdk.CallToolResult, any, error) {
+		state.RLock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+		pkgPath := in.Package
+		if pkgPath != "" {
+			pkgPath = types.ResolvePackagePath(ws, pkgPath)
+		}
+		plan, err := state.GetEngine().Rewrite(ws, types.RewriteRequest{
+			Pattern:     in.Pattern,
+			Replacement: in.Replacement,
+			Where:       in.Where,
+			Package:     pkgPath,
+		})
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+		if len(plan.Changes) == 0 {
+			state.RUnlock()
+			return textResult(map[string]any{
+				"files_modified": []string{},
+				"changes_count":  0,
+				"message":        "No expressions match " + in.Pattern,
+				"issues":         plan.Impact.PotentialIssues,
+			}), nil, nil
+		}
+		desc := "Rewrite " + in.Pattern
+		if op, ok := plan.Operations[0].(*refactor.RewriteOperation); ok {
+			desc = fmt.Sprintf("Rewrite %d matches of %s", op.Matches, in.Pattern)
+		}
+		result, err := previewPlanWithUnlock(ctx, state, plan, desc, in.Preview)
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		return textResult(result), nil, nil
+	})
+
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "migrate",
+		Description: "Apply a migration pack, a YAML bundle of rewrite rules for an upgrade, as one plan: ioutil moves io/ioutil calls to os and io, pkg-errors moves github.com/pkg/errors to fmt and errors, x-net-context moves golang.org/x/net/context to context. A pack file holds {name, description, rules: [{name, pattern, replacement, where, note}]} with rules in the syntax of rewrite. The result counts the expressions each rule rewrote; the notes of the rules that rewrote any, such as semantics that differ, are reported as issues. Without a pack, lists the built-in packs.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in MigrateInput) (*mcpsdk.CallToolResult, any, error) {
+		if in.Pack == "" {
+			packs, err := refactor.MigrationPacks()
+			if err != nil {
+				return errResult(err), nil, nil
+			}
+			return textResult(map[string]any{"packs": packs}), nil, nil
+		}
+
+		state.RLock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+		pkgPath := in.Package
+		if pkgPath != "" {
+			pkgPath = types.ResolvePackagePath(ws, pkgPath)
+		}
+		plan, err := state.GetEngine().Migrate(ws, types.MigrateRequest{Pack: in.Pack, Package: pkgPath})
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+		var stats []refactor.MigrationRuleStats
+		if op, ok := plan.Operations[0].(*refactor.MigrateOperation); ok {
+			stats = op.Stats
+		}
+		if len(plan.Changes) == 0 {
+			state.RUnlock()
+			return textResult(map[string]any{
+				"files_modified": []string{},
+				"changes_count":  0,
+				"message":        "Nothing to migrate with " + in.Pack,
+				"rules":          stats,
+				"issues":         plan.Impact.PotentialIssues,
+			}), nil, nil
+		}
+		result, err := previewPlanWithUnlock(ctx, state, plan, "Migrate "+in.Pack, in.Preview)
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		return textResult(&MigrateResult{PlanResult: result, Rules: stats}), nil, nil
+	})
+}
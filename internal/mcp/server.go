@@ -2,98 +2,188 @@ package mcp
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/mamaar/gorefactor/internal/telemetry"
 	"github.com/mamaar/gorefactor/pkg/analysis"
+	"github.com/mamaar/gorefactor/pkg/config"
 	"github.com/mamaar/gorefactor/pkg/refactor"
 	"github.com/mamaar/gorefactor/pkg/types"
 	"github.com/mamaar/gorefactor/pkg/watch"
 )
 
-// MCPServer holds the shared state for the MCP tool handlers:
-// a loaded workspace, its refactoring engine, and an optional
-// filesystem watcher that incrementally updates the workspace.
+// MCPServer holds the shared state for the MCP tool handlers: the open
+// workspace sessions, each with a loaded workspace, its refactoring engine,
+// and an optional filesystem watcher that incrementally updates the
+// workspace. The handlers see the session of the current tool call through
+// the embedded session; see routeSessions.
 type MCPServer struct {
-	mu        sync.RWMutex
-	engine    *refactor.DefaultEngine
-	workspace *types.Workspace
-	resolver  any // *analysis.SymbolResolver (from WatchContext)
-	watcher   *watch.Watcher
-	updater   *watch.WorkspaceUpdater
-	cancel    context.CancelFunc // stops watcher goroutine
-	logger    *slog.Logger
+	mu       sync.RWMutex
+	logger   *slog.Logger
+	progress progressHub // forwards engine progress to in-flight tool calls
+	preview  bool        // mutating tools return their plan instead of applying it
+	verify   bool        // check workspace invariants after loading and after each plan
+
+	// The session of the current tool call, otherwise the default session
+	// used by calls without a workspace_id. Replaced only with sessionMu held
+	// for writing; tool calls hold it for reading.
+	*session
+	sessionMu sync.RWMutex
+	sessions  []*session // Open sessions, in the order they were opened
+
+	// Set by SetGitApply: mutating tools apply their plans through git
+	git *refactor.GitApplier
+
+	// In-flight plan executions; Shutdown waits for them before releasing the watcher
+	applyMu      sync.Mutex
+	applying     sync.WaitGroup
+	shuttingDown bool
+}
 
-	// Cached reference index for performance (invalidated on workspace changes)
-	refIndexMu    sync.RWMutex
-	refIndex      any // *analysis.ReferenceIndex
-	refIndexValid bool
+// session is the state of one workspace open in the server.
+type session struct {
+	id      string // Set when the workspace is first loaded
+	engine  *refactor.DefaultEngine
+	config  *config.Config // project config (.gorefactor.yaml) of the loaded workspace
+	options workspaceOptions
+	watcher *watch.Watcher
+	updater *watch.WorkspaceUpdater // Forked for each change to the workspace
+	cancel  context.CancelFunc      // stops watcher goroutine
+
+	// Set by begin_staging: mutating tools stage their plans in memory until
+	// apply_staged or discard_staged
+	staged *refactor.VirtualWorkspace
+
+	plans   planCache  // Plans of earlier tool calls, for repeated calls
+	queries queryCache // Results of earlier read-only analyses
+
+	// Concurrent tool calls; see concurrency.go
+	snap      atomic.Pointer[snapshot] // Current generation of the workspace; nil until loaded
+	updateMu  sync.Mutex               // Held while the workspace changes
+	loadedAt  uint64                   // Generation the workspace was last loaded at
+	changedAt map[string]uint64        // Generation each file last changed in, by path
+	writer    *writerQueue             // Held while a plan is applied
 }
 
 // NewMCPServer creates a new MCPServer with the given logger.
 func NewMCPServer(logger *slog.Logger) *MCPServer {
-	eng := refactor.CreateEngineWithConfig(&refactor.EngineConfig{
+	s := &MCPServer{logger: logger}
+	s.session = s.newSession()
+	s.sessions = []*session{s.session}
+	return s
+}
+
+// newSession returns a session without a workspace, its engine reporting
+// progress to the server's tool calls.
+func (s *MCPServer) newSession() *session {
+	eng := refactor.CreateEngineWithConfig(defaultEngineConfig(), s.logger).(*refactor.DefaultEngine)
+	eng.SetProgressReporter(func(event types.ProgressEvent) {
+		s.logger.Debug("progress", "phase", event.Phase, "package", event.Package,
+			"current", event.Current, "total", event.Total, "message", event.Message)
+		s.progress.report(event)
+	})
+	return &session{engine: eng, config: config.Default(), writer: newWriterQueue()}
+}
+
+// defaultEngineConfig returns the engine options the MCP server uses unless
+// the workspace's .gorefactor.yaml overrides them.
+func defaultEngineConfig() *refactor.EngineConfig {
+	return &refactor.EngineConfig{
 		SkipCompilation: true,
 		AllowBreaking:   true,
-	}, logger)
-	return &MCPServer{
-		engine: eng.(*refactor.DefaultEngine),
-		logger: logger,
+		Journal:         true,
 	}
 }
 
 // LoadWorkspace loads (or reloads) a workspace at the given path.
 // It builds the reference index upfront and starts a background watcher for incremental updates.
 // Returns (indexBuilt, error) where indexBuilt indicates if the reference index was successfully built.
-func (s *MCPServer) LoadWorkspace(ctx context.Context, path string) (bool, error) {
+func (s *MCPServer) LoadWorkspace(ctx context.Context, path string) (_ bool, err error) {
+	s.updateMu.Lock()
+	defer s.updateMu.Unlock()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	start := time.Now()
+	ctx, span := telemetry.Tracer().Start(ctx, telemetry.SpanLoadWorkspace, trace.WithAttributes(attribute.String("gorefactor.workspace.path", path)))
+	defer func() {
+		packages := 0
+		if err == nil {
+			packages = len(s.workspace().Packages)
+			span.SetAttributes(attribute.Int("gorefactor.workspace.packages", packages))
+		}
+		telemetry.End(span, err)
+		telemetry.ObserveWorkspaceLoad(packages, time.Since(start), err)
+	}()
+
 	// Stop any existing watcher.
-	if s.cancel != nil {
-		s.cancel()
-		s.cancel = nil
+	s.stopWatcher()
+
+	s.logger.InfoContext(ctx, "loading workspace", "path", path)
+	cfg, err := config.LoadWorkspace(path)
+	if err != nil {
+		return false, fmt.Errorf("load config: %w", err)
 	}
-	if s.watcher != nil {
-		_ = s.watcher.Close()
-		s.watcher = nil
+	if cfg.Path != "" {
+		s.logger.InfoContext(ctx, "using project config", "path", cfg.Path)
+	}
+	s.config = cfg
+	s.staged = nil
+	engineConfig := s.engine.Config()
+	*engineConfig = *defaultEngineConfig()
+	cfg.ApplyEngine(engineConfig)
+	s.options.apply(engineConfig)
+	engineConfig.VerifyInternal = s.verify
+	sess := s.session
+	engineConfig.ReferenceIndex = func(ws *types.Workspace) *analysis.ReferenceIndex {
+		return sess.sharedReferenceIndex(ws, s.logger)
 	}
 
-	s.logger.Info("loading workspace", "path", path)
-	wctx, err := s.engine.LoadWorkspaceForWatch(path)
+	wctx, err := s.engine.LoadWorkspaceForWatchContext(ctx, path)
 	if err != nil {
 		return false, fmt.Errorf("load workspace: %w", err)
 	}
-	s.workspace = wctx.Workspace
-	s.resolver = wctx.Resolver
-
-	// Invalidate cached reference index since workspace changed
-	s.InvalidateReferenceIndex()
+	if ws := s.workspace(); ws == nil || ws.RootPath != wctx.Workspace.RootPath {
+		s.id = s.sessionID(wctx.Workspace.RootPath)
+	}
+	snap := &snapshot{workspace: wctx.Workspace, resolver: wctx.Resolver}
+	s.changedAt = nil
+	s.publish(snap)
+	s.loadedAt = snap.generation
+	s.updater = watch.NewUpdater(wctx.Workspace, wctx.Parser, wctx.Resolver, wctx.Analyzer, s.logger)
+	s.plans.reset(cfg, wctx.Workspace.RootPath)
+	s.queries.reset(cfg)
 
 	// Build reference index upfront (this may take a moment for large workspaces)
-	s.logger.Info("building reference index", "packages", len(s.workspace.Packages))
-	indexBuilt := s.buildReferenceIndexLocked()
+	s.logger.InfoContext(ctx, "building reference index", "packages", len(wctx.Workspace.Packages))
+	indexBuilt := s.buildReferenceIndex(ctx, snap)
 	if indexBuilt {
-		s.logger.Info("reference index built successfully")
+		s.logger.InfoContext(ctx, "reference index built successfully")
 	} else {
-		s.logger.Warn("failed to build reference index")
+		s.logger.WarnContext(ctx, "failed to build reference index")
 	}
 
 	// Start watcher.
 	w, err := watch.NewWatcher(path, 200*time.Millisecond, s.logger)
 	if err != nil {
-		s.logger.Warn("watcher unavailable, workspace will not auto-update", "err", err)
+		s.logger.WarnContext(ctx, "watcher unavailable, workspace will not auto-update", "err", err)
 		return indexBuilt, nil
 	}
 	s.watcher = w
-	s.updater = watch.NewUpdater(wctx.Workspace, wctx.Parser, wctx.Resolver, wctx.Analyzer, s.logger)
 
 	watchCtx, cancel := context.WithCancel(context.Background())
 	s.cancel = cancel
 
+	// The session may not be the current one when files change
 	ch := make(chan []watch.ChangeEvent, 4)
 	go func() {
 		if err := w.Run(watchCtx, ch); err != nil && watchCtx.Err() == nil {
@@ -102,21 +192,99 @@ func (s *MCPServer) LoadWorkspace(ctx context.Context, path string) (bool, error
 	}()
 	go func() {
 		for events := range ch {
-			s.mu.Lock()
-			s.updater.HandleChanges(events)
-			s.mu.Unlock()
+			s.update(sess, events)
 		}
 	}()
 
 	return indexBuilt, nil
 }
 
-// GetWorkspace returns the loaded workspace or an error if none is loaded.
+// SetAllowGenerated toggles whether plans may edit generated files. Like
+// the other workspace options it is kept when the workspace is reloaded.
+func (s *MCPServer) SetAllowGenerated(allow bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.options.allowGenerated = allow
+	s.engine.Config().AllowGenerated = allow
+}
+
+// SetMinConfidence makes executed plans skip the changes less sure than
+// level.
+func (s *MCPServer) SetMinConfidence(level types.Confidence) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.options.minConfidence = level
+	s.engine.Config().MinConfidence = level
+}
+
+// SetVerifyTests selects the tests run before and after each plan is
+// applied; a plan that makes tests fail is rolled back.
+func (s *MCPServer) SetVerifyTests(scope refactor.TestVerification) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.options.verifyTests = scope
+	s.engine.Config().VerifyTests = scope
+}
+
+// SetPreview makes mutating tools return the changes they plan instead of
+// writing them.
+func (s *MCPServer) SetPreview(preview bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.preview = preview
+}
+
+// SetGitApply makes mutating tools apply their plans through git: on a new
+// branch in a worktree, committed, or both. See refactor.GitApplier; plans
+// are applied with the engine of their session.
+func (s *MCPServer) SetGitApply(branch string, commit, worktree bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if branch == "" && !commit && !worktree {
+		s.git = nil
+		return
+	}
+	s.git = &refactor.GitApplier{Branch: branch, Commit: commit, Worktree: worktree}
+}
+
+// SetVerifyInternal turns on workspace invariant checks for workspaces
+// loaded from now on.
+func (s *MCPServer) SetVerifyInternal(verify bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.verify = verify
+}
+
+// verifyWorkspace checks the workspace invariants after stage when internal
+// verification is on.
+func (s *MCPServer) verifyWorkspace(stage string) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ws := s.workspace()
+	if ws == nil {
+		return nil
+	}
+	if err := s.engine.VerifyWorkspace(ws, stage); err != nil {
+		return fmt.Errorf("changes were written, but %w\ncall load_workspace to reload the workspace", err)
+	}
+	return nil
+}
+
+// GetWorkspace returns the current generation of the loaded workspace or an
+// error if none is loaded. Later changes leave it as it is, except while
+// plans are staged.
 func (s *MCPServer) GetWorkspace() (*types.Workspace, error) {
-	if s.workspace == nil {
+	ws := s.workspace()
+	if ws == nil {
 		return nil, fmt.Errorf("no workspace loaded — call load_workspace first")
 	}
-	return s.workspace, nil
+	return ws, nil
+}
+
+// ProjectConfig returns the project config of the loaded workspace, or the
+// defaults when none is loaded.
+func (s *MCPServer) ProjectConfig() *config.Config {
+	return s.config
 }
 
 // GetEngine returns the refactoring engine.
@@ -127,86 +295,78 @@ func (s *MCPServer) GetEngine() *refactor.DefaultEngine {
 // EnsureReferenceIndex returns a cached reference index, building one if necessary.
 // Uses double-checked locking for thread safety.
 func (s *MCPServer) EnsureReferenceIndex(ws *types.Workspace) (*analysis.ReferenceIndex, error) {
-	// Fast path: check if valid cached index exists
-	s.refIndexMu.RLock()
-	if s.refIndexValid && s.refIndex != nil {
-		idx := s.refIndex.(*analysis.ReferenceIndex)
-		s.refIndexMu.RUnlock()
-		return idx, nil
-	}
-	s.refIndexMu.RUnlock()
-
-	// Slow path: build and cache
-	s.refIndexMu.Lock()
-	defer s.refIndexMu.Unlock()
-
-	// Double-check after acquiring write lock
-	if s.refIndexValid && s.refIndex != nil {
-		return s.refIndex.(*analysis.ReferenceIndex), nil
-	}
-
-	// Build the index using the resolver if available, otherwise create a new one
-	var resolver *analysis.SymbolResolver
-	if s.resolver != nil {
-		var ok bool
-		resolver, ok = s.resolver.(*analysis.SymbolResolver)
-		if !ok {
-			resolver = analysis.NewSymbolResolver(ws, s.logger)
-		}
-	} else {
-		resolver = analysis.NewSymbolResolver(ws, s.logger)
+	return s.ensureReferenceIndex(ws, s.logger)
+}
+
+func (s *session) ensureReferenceIndex(ws *types.Workspace, logger *slog.Logger) (*analysis.ReferenceIndex, error) {
+	if snap := s.current(); snap != nil && snap.workspace == ws {
+		return snap.ensureReferenceIndex(logger)
 	}
+	// An earlier generation, of a call that started before the latest
+	// change: its index isn't kept.
+	snap := &snapshot{workspace: ws, resolver: analysis.NewSymbolResolver(ws, logger)}
+	return snap.ensureReferenceIndex(logger)
+}
 
-	s.logger.Info("building reference index for workspace")
-	idx := resolver.BuildReferenceIndex()
-	if idx == nil {
-		return nil, fmt.Errorf("failed to build reference index")
+// sharedReferenceIndex returns the reference index the operations of the
+// session's engine share, which is that of the current generation of the
+// session's workspace. Other workspaces, such as earlier generations or
+// the scratch copies of batches, get none, so operations index them
+// themselves.
+func (s *session) sharedReferenceIndex(ws *types.Workspace, logger *slog.Logger) *analysis.ReferenceIndex {
+	snap := s.current()
+	if snap == nil || ws != snap.workspace {
+		return nil
 	}
-	s.refIndex = idx
-	s.refIndexValid = true
-	return idx, nil
+	idx, err := snap.ensureReferenceIndex(logger)
+	if err != nil {
+		logger.Warn("operations will index references themselves", "err", err)
+		return nil
+	}
+	return idx
 }
 
-// InvalidateReferenceIndex marks the cached reference index as stale.
-func (s *MCPServer) InvalidateReferenceIndex() {
-	s.refIndexMu.Lock()
-	defer s.refIndexMu.Unlock()
-	s.refIndexValid = false
-	s.refIndex = nil
+// SymbolResolver returns the resolver kept in sync with ws when it is the
+// current generation of the workspace, or a new one for ws otherwise.
+func (s *MCPServer) SymbolResolver(ws *types.Workspace) *analysis.SymbolResolver {
+	return s.symbolResolver(ws, s.logger)
 }
 
-// buildReferenceIndexLocked builds the reference index (must be called with s.mu held).
-// Returns true if successful, false otherwise.
-func (s *MCPServer) buildReferenceIndexLocked() bool {
-	if s.workspace == nil || s.resolver == nil {
-		s.logger.Warn("workspace or resolver not available")
-		return false
+func (s *session) symbolResolver(ws *types.Workspace, logger *slog.Logger) *analysis.SymbolResolver {
+	if snap := s.current(); snap != nil && snap.workspace == ws {
+		return snap.resolver
 	}
+	return analysis.NewSymbolResolver(ws, logger)
+}
 
-	// Cast resolver and build index
-	resolver, ok := s.resolver.(*analysis.SymbolResolver)
-	if !ok {
-		s.logger.Error("resolver type assertion failed")
+// buildReferenceIndex builds the reference index of snap.
+// Returns true if successful, false otherwise (including when ctx is canceled;
+// the index is then built lazily on first use).
+func (s *MCPServer) buildReferenceIndex(ctx context.Context, snap *snapshot) bool {
+	s.logger.DebugContext(ctx, "building reference index...")
+	start := time.Now()
+	ctx, span := telemetry.Tracer().Start(ctx, telemetry.SpanBuildReferenceIndex, trace.WithAttributes(attribute.Int("gorefactor.workspace.packages", len(snap.workspace.Packages))))
+	idx, err := snap.resolver.BuildReferenceIndexContext(ctx)
+	telemetry.End(span, err)
+	telemetry.ObserveIndexBuild(time.Since(start), err == nil && idx != nil)
+	if err != nil {
+		s.logger.WarnContext(ctx, "reference index build canceled", "err", err)
 		return false
 	}
-
-	s.logger.Debug("building reference index...")
-	idx := resolver.BuildReferenceIndex()
 	if idx != nil {
-		s.refIndexMu.Lock()
-		s.refIndex = idx
-		s.refIndexValid = true
-		s.refIndexMu.Unlock()
+		snap.indexMu.Lock()
+		snap.index = idx
+		snap.indexMu.Unlock()
 		return true
 	}
-	s.logger.Warn("failed to build reference index")
+	s.logger.WarnContext(ctx, "failed to build reference index")
 	return false
 }
 
 // SyncWorkspaceChanges forces an immediate workspace update for the given files.
 // This is called after MCP operations write files to ensure workspace state is current.
 func (s *MCPServer) SyncWorkspaceChanges(files []string) error {
-	if s.updater == nil || len(files) == 0 {
+	if len(files) == 0 {
 		return nil
 	}
 
@@ -219,15 +379,7 @@ func (s *MCPServer) SyncWorkspaceChanges(files []string) error {
 		}
 	}
 
-	// Acquire write lock and update synchronously
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	s.updater.HandleChanges(events)
-
-	// Invalidate cached reference index since files changed
-	s.InvalidateReferenceIndex()
-
+	s.update(s.session, events)
 	return nil
 }
 
@@ -237,10 +389,58 @@ func (s *MCPServer) RLock() { s.mu.RLock() }
 // RUnlock releases the read lock.
 func (s *MCPServer) RUnlock() { s.mu.RUnlock() }
 
-// Close stops the watcher and releases resources.
+// errShuttingDown is returned for plans submitted after Shutdown has started.
+var errShuttingDown = errors.New("server is shutting down")
+
+// beginApply registers a plan execution that Shutdown must wait for. The
+// returned function marks it finished.
+func (s *MCPServer) beginApply() (func(), error) {
+	s.applyMu.Lock()
+	defer s.applyMu.Unlock()
+	if s.shuttingDown {
+		return nil, errShuttingDown
+	}
+	s.applying.Add(1)
+	return s.applying.Done, nil
+}
+
+// Shutdown refuses new plan executions, waits for in-flight ones to finish
+// writing (and journaling) their changes, then closes the server. If ctx ends
+// first the server is closed anyway and an error reports the unfinished work.
+func (s *MCPServer) Shutdown(ctx context.Context) error {
+	s.applyMu.Lock()
+	s.shuttingDown = true
+	s.applyMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.applying.Wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("plan execution still in progress: %w", ctx.Err())
+	}
+	s.Close()
+	return err
+}
+
+// Close stops the watchers and releases resources.
 func (s *MCPServer) Close() {
+	s.sessionMu.RLock()
+	defer s.sessionMu.RUnlock()
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	for _, sess := range s.sessions {
+		sess.stopWatcher()
+	}
+}
+
+// stopWatcher stops the session's watcher, if any.
+func (s *session) stopWatcher() {
 	if s.cancel != nil {
 		s.cancel()
 		s.cancel = nil
@@ -0,0 +1,186 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// --- list_workspaces ---
+
+type ListWorkspacesInput struct{}
+
+// WorkspaceSession describes an open workspace.
+type WorkspaceSession struct {
+	WorkspaceID  string   `json:"workspace_id"`
+	RootPath     string   `json:"root_path"`
+	Module       string   `json:"module,omitempty"`
+	PackageCount int      `json:"package_count"`
+	Default      bool     `json:"default"`                // Used by tool calls without a workspace_id
+	StagedFiles  []string `json:"staged_files,omitempty"` // Files with changes staged since begin_staging
+}
+
+// --- reload_workspace, close_workspace ---
+
+type WorkspaceIDInput struct {
+	WorkspaceID string `json:"workspace_id,omitempty" jsonschema:"workspace as returned by open_workspace (default: the default workspace)"`
+}
+
+type CloseWorkspaceOutput struct {
+	Closed             string `json:"closed"`
+	DefaultWorkspaceID string `json:"default_workspace_id,omitempty"` // Empty when no workspace is left open
+}
+
+func registerSessionTools(s *mcpsdk.Server, state *MCPServer) {
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "open_workspace",
+		Description: "Open a Go workspace in a new session, next to the workspaces already open, and make it the default for tool calls without a workspace_id. Every other tool takes an optional workspace_id to run in another open workspace. Opening a workspace that is already open makes it the default without reloading it.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in LoadWorkspaceInput) (*mcpsdk.CallToolResult, any, error) {
+		defer state.trackProgress(ctx, req)()
+		opts, err := parseWorkspaceOptions(in)
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		root, err := filepath.Abs(in.Path)
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+
+		state.sessionMu.Lock()
+		defer state.sessionMu.Unlock()
+		if sess := state.sessionAt(root); sess != nil {
+			state.session = sess
+			return textResult(state.loadOutput(false)), nil, nil
+		}
+		prev := state.session
+		if state.workspace() != nil {
+			state.session = state.newSession()
+		}
+		state.options = opts
+		indexBuilt, err := state.LoadWorkspace(ctx, root)
+		if err != nil {
+			if state.session != prev {
+				state.session = prev
+			}
+			return errResult(err), nil, nil
+		}
+		if state.session != prev {
+			state.sessions = append(state.sessions, state.session)
+		}
+		return textResult(state.loadOutput(indexBuilt)), nil, nil
+	})
+
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "list_workspaces",
+		Description: "List the open workspaces with their workspace_id, root, module and staged files, marking the default one.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in ListWorkspacesInput) (*mcpsdk.CallToolResult, any, error) {
+		state.sessionMu.RLock()
+		defer state.sessionMu.RUnlock()
+		state.RLock()
+		defer state.RUnlock()
+
+		out := []WorkspaceSession{}
+		for _, sess := range state.sessions {
+			ws := sess.workspace()
+			if ws == nil {
+				continue
+			}
+			info := WorkspaceSession{
+				WorkspaceID:  sess.id,
+				RootPath:     ws.RootPath,
+				PackageCount: len(ws.Packages),
+				Default:      sess == state.session,
+				StagedFiles:  sess.stagedFiles(),
+			}
+			if ws.Module != nil {
+				info.Module = ws.Module.Path
+			}
+			out = append(out, info)
+		}
+		return textResult(out), nil, nil
+	})
+
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "reload_workspace",
+		Description: "Reload an open workspace from disk with the options it was opened with, rebuilding its reference index. Refused while changes are staged in it.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in WorkspaceIDInput) (*mcpsdk.CallToolResult, any, error) {
+		defer state.trackProgress(ctx, req)()
+		state.sessionMu.Lock()
+		defer state.sessionMu.Unlock()
+
+		sess, err := state.openSession(in.WorkspaceID)
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		prev := state.session
+		state.session = sess
+		defer func() { state.session = prev }()
+		indexBuilt, err := state.LoadWorkspace(ctx, sess.workspace().RootPath)
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		return textResult(state.loadOutput(indexBuilt)), nil, nil
+	})
+
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "close_workspace",
+		Description: "Close an open workspace, stopping its file watcher. When it was the default, the most recently opened remaining workspace becomes the default. Refused while changes are staged in it.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in WorkspaceIDInput) (*mcpsdk.CallToolResult, any, error) {
+		state.sessionMu.Lock()
+		defer state.sessionMu.Unlock()
+
+		sess, err := state.openSession(in.WorkspaceID)
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		state.mu.Lock()
+		sess.stopWatcher()
+		state.mu.Unlock()
+		state.removeSession(sess)
+		out := CloseWorkspaceOutput{Closed: sess.id}
+		if state.workspace() != nil {
+			out.DefaultWorkspaceID = state.id
+		}
+		return textResult(out), nil, nil
+	})
+}
+
+// openSession returns the session with the given ID, or the default one
+// when id is empty, for reload_workspace and close_workspace, which must
+// not drop staged changes. The caller holds sessionMu.
+func (s *MCPServer) openSession(id string) (*session, error) {
+	sess := s.session
+	if id != "" {
+		sess = s.findSession(id)
+	}
+	switch {
+	case sess == nil:
+		return nil, fmt.Errorf("unknown %s %q; call list_workspaces for the open workspaces", workspaceIDParam, id)
+	case sess.workspace() == nil:
+		return nil, fmt.Errorf("no workspace loaded — call open_workspace first")
+	case sess.staged != nil:
+		return nil, fmt.Errorf("workspace %s has staged changes; call apply_staged or discard_staged first", sess.id)
+	}
+	return sess, nil
+}
+
+// loadOutput describes the workspace of the current session after it was
+// loaded.
+func (s *MCPServer) loadOutput(indexBuilt bool) LoadWorkspaceOutput {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ws := s.workspace()
+	out := LoadWorkspaceOutput{
+		WorkspaceID:         s.id,
+		PackageCount:        len(ws.Packages),
+		RootPath:            ws.RootPath,
+		ReferenceIndexBuilt: indexBuilt,
+		ConfigFile:          s.config.Path,
+	}
+	if ws.Module != nil {
+		out.Module = ws.Module.Path
+	}
+	return out
+}
@@ -0,0 +1,231 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/mamaar/gorefactor/pkg/refactor"
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// workspaceIDParam is the argument that runs a tool call in the session of
+// another open workspace than the default one.
+const workspaceIDParam = "workspace_id"
+
+// sessionTools manage the sessions themselves, so they aren't routed to one
+// and take workspace_id as an ordinary argument.
+var sessionTools = map[string]bool{
+	"open_workspace":   true,
+	"list_workspaces":  true,
+	"reload_workspace": true,
+	"close_workspace":  true,
+}
+
+// workspaceOptions are the load_workspace options of a session, applied
+// over the project config each time its workspace is loaded.
+type workspaceOptions struct {
+	allowGenerated bool
+	minConfidence  types.Confidence          // Empty keeps the project config's
+	verifyTests    refactor.TestVerification // Empty keeps the project config's
+	scope          []string                  // Empty keeps the project config's
+	mapSources     bool
+}
+
+// parseWorkspaceOptions returns the options of a load_workspace call.
+func parseWorkspaceOptions(in LoadWorkspaceInput) (workspaceOptions, error) {
+	opts := workspaceOptions{allowGenerated: in.AllowGenerated, scope: in.Scope, mapSources: in.MapSources}
+	if in.MinConfidence != "" {
+		level, err := types.ParseConfidence(in.MinConfidence)
+		if err != nil {
+			return opts, err
+		}
+		opts.minConfidence = level
+	}
+	if in.VerifyTests != "" {
+		scope, err := refactor.ParseTestVerification(in.VerifyTests)
+		if err != nil {
+			return opts, err
+		}
+		opts.verifyTests = scope
+	}
+	return opts, nil
+}
+
+func (o workspaceOptions) apply(c *refactor.EngineConfig) {
+	if o.allowGenerated {
+		c.AllowGenerated = true
+	}
+	if o.minConfidence != "" {
+		c.MinConfidence = o.minConfidence
+	}
+	if o.verifyTests != "" {
+		c.VerifyTests = o.verifyTests
+	}
+	if len(o.scope) > 0 {
+		c.Scope = o.scope
+	}
+	if o.mapSources {
+		c.MapSources = true
+	}
+}
+
+// sessionID returns an ID for a session of the workspace at root: the
+// directory's name, numbered when another session has it already.
+func (s *MCPServer) sessionID(root string) string {
+	base := filepath.Base(root)
+	id := base
+	for n := 2; ; n++ {
+		if other := s.findSession(id); other == nil || other == s.session {
+			return id
+		}
+		id = fmt.Sprintf("%s-%d", base, n)
+	}
+}
+
+// findSession returns the open session with the given ID, or nil.
+func (s *MCPServer) findSession(id string) *session {
+	for _, sess := range s.sessions {
+		if sess.id == id && sess.workspace() != nil {
+			return sess
+		}
+	}
+	return nil
+}
+
+// sessionAt returns the open session of the workspace at root, or nil.
+func (s *MCPServer) sessionAt(root string) *session {
+	for _, sess := range s.sessions {
+		if ws := sess.workspace(); ws != nil && ws.RootPath == root {
+			return sess
+		}
+	}
+	return nil
+}
+
+// routeSessions is middleware that runs each tool call in the session its
+// workspace_id argument names, or the default session without one, and
+// advertises the argument in the input schema of every tool. Calls in the
+// default session run concurrently; a call in another session runs alone,
+// with that session swapped in for its duration.
+func (s *MCPServer) routeSessions(next mcpsdk.MethodHandler) mcpsdk.MethodHandler {
+	return func(ctx context.Context, method string, req mcpsdk.Request) (mcpsdk.Result, error) {
+		switch r := req.(type) {
+		case *mcpsdk.CallToolRequest:
+			if sessionTools[r.Params.Name] {
+				return next(ctx, method, req)
+			}
+			id, err := takeWorkspaceID(r)
+			if err != nil {
+				return errResult(err), nil
+			}
+			return s.inSession(id, func() (mcpsdk.Result, error) {
+				return next(ctx, method, req)
+			})
+		case *mcpsdk.ListToolsRequest:
+			res, err := next(ctx, method, req)
+			if list, ok := res.(*mcpsdk.ListToolsResult); ok && err == nil {
+				addWorkspaceIDParam(list)
+			}
+			return res, err
+		}
+		s.sessionMu.RLock()
+		defer s.sessionMu.RUnlock()
+		return next(ctx, method, req)
+	}
+}
+
+// inSession runs fn in the session with the given ID, or the default
+// session when id is empty.
+func (s *MCPServer) inSession(id string, fn func() (mcpsdk.Result, error)) (mcpsdk.Result, error) {
+	s.sessionMu.RLock()
+	s.mu.RLock()
+	current := s.session.id // load_workspace may change it
+	s.mu.RUnlock()
+	if id == "" || id == current {
+		defer s.sessionMu.RUnlock()
+		return fn()
+	}
+	s.sessionMu.RUnlock()
+
+	s.sessionMu.Lock()
+	defer s.sessionMu.Unlock()
+	sess := s.findSession(id)
+	if sess == nil {
+		return errResult(fmt.Errorf("unknown %s %q; call list_workspaces for the open workspaces", workspaceIDParam, id)), nil
+	}
+	prev := s.session
+	s.session = sess
+	defer func() { s.session = prev }()
+	return fn()
+}
+
+// takeWorkspaceID removes the workspace_id argument from call, whose tool
+// doesn't declare it, and returns it.
+func takeWorkspaceID(call *mcpsdk.CallToolRequest) (string, error) {
+	if len(call.Params.Arguments) == 0 {
+		return "", nil
+	}
+	var args map[string]json.RawMessage
+	if err := json.Unmarshal(call.Params.Arguments, &args); err != nil {
+		return "", nil // Left for the tool to reject
+	}
+	raw, ok := args[workspaceIDParam]
+	if !ok {
+		return "", nil
+	}
+	var id string
+	if err := json.Unmarshal(raw, &id); err != nil {
+		return "", fmt.Errorf("%s must be a string", workspaceIDParam)
+	}
+	delete(args, workspaceIDParam)
+	stripped, err := json.Marshal(args)
+	if err != nil {
+		return "", err
+	}
+	call.Params.Arguments = stripped
+	return id, nil
+}
+
+// addWorkspaceIDParam adds the workspace_id argument to the input schemas
+// of the tools of list, copying the tools, which the server owns.
+func addWorkspaceIDParam(list *mcpsdk.ListToolsResult) {
+	for i, tool := range list.Tools {
+		schema, ok := tool.InputSchema.(*jsonschema.Schema)
+		if !ok || sessionTools[tool.Name] {
+			continue
+		}
+		withID := *schema
+		withID.Properties = maps.Clone(schema.Properties)
+		if withID.Properties == nil {
+			withID.Properties = make(map[string]*jsonschema.Schema)
+		}
+		withID.Properties[workspaceIDParam] = &jsonschema.Schema{
+			Type:        "string",
+			Description: "workspace to run the tool in, as returned by open_workspace (default: the workspace opened last)",
+		}
+		copied := *tool
+		copied.InputSchema = &withID
+		list.Tools[i] = &copied
+	}
+}
+
+// removeSession drops sess from the open sessions. When it was the default
+// session the most recently opened remaining one takes its place, or a new
+// empty session when none is left.
+func (s *MCPServer) removeSession(sess *session) {
+	s.sessions = slices.DeleteFunc(s.sessions, func(other *session) bool { return other == sess })
+	if s.session != sess {
+		return
+	}
+	if len(s.sessions) == 0 {
+		s.sessions = append(s.sessions, s.newSession())
+	}
+	s.session = s.sessions[len(s.sessions)-1]
+}
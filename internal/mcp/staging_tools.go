@@ -0,0 +1,164 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+
+	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/mamaar/gorefactor/pkg/analysis"
+	"github.com/mamaar/gorefactor/pkg/refactor"
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// --- begin_staging ---
+
+type BeginStagingInput struct{}
+
+// --- apply_staged ---
+
+type ApplyStagedInput struct {
+	VerifyTests string `json:"verify_tests,omitempty" jsonschema:"run go test before and after writing the staged changes and roll them back when tests fail that passed before: off, affected or all (default: the workspace setting)"`
+}
+
+// --- discard_staged ---
+
+type DiscardStagedInput struct{}
+
+// stagePlan applies plan to the staged workspace in memory. It reports
+// false, and does nothing, when staging is off.
+func stagePlan(state *MCPServer, plan *types.RefactoringPlan) (bool, error) {
+	state.updateMu.Lock()
+	defer state.updateMu.Unlock()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if state.staged == nil {
+		return false, nil
+	}
+	if err := state.staged.Apply(plan); err != nil {
+		return true, err
+	}
+	// The staged workspace changed in place; its next generation gets a new
+	// resolver and index.
+	ws := state.staged.Workspace()
+	state.publish(&snapshot{workspace: ws, resolver: analysis.NewSymbolResolver(ws, state.logger)}, plan.AffectedFiles...)
+	state.queries.invalidate()
+	return true, nil
+}
+
+// stagedFiles returns the files the staged changes write.
+func (s *session) stagedFiles() []string {
+	if s.staged == nil {
+		return nil
+	}
+	return s.staged.Pending().AffectedFiles
+}
+
+func registerStagingTools(s *mcpsdk.Server, state *MCPServer) {
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "begin_staging",
+		Description: "Stage the plans of subsequent refactoring tools in memory instead of writing them. Each tool sees the code as the earlier staged plans left it, so several refactorings can be composed and then written at once with apply_staged, or dropped with discard_staged. Files edited on disk meanwhile make apply_staged fail.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in BeginStagingInput) (*mcpsdk.CallToolResult, any, error) {
+		state.updateMu.Lock()
+		defer state.updateMu.Unlock()
+		state.mu.Lock()
+		defer state.mu.Unlock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		if state.preview {
+			return errResult(fmt.Errorf("staging is not available in preview mode")), nil, nil
+		}
+		if state.staged != nil {
+			return errResult(fmt.Errorf("already staging; call apply_staged or discard_staged first")), nil, nil
+		}
+		state.staged = refactor.NewVirtualWorkspace(state.engine, ws)
+		return textResult(&AnalysisResult{Description: "staging started", Data: map[string]any{}}), nil, nil
+	})
+
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "apply_staged",
+		Description: "Write the changes staged since begin_staging to disk as one plan, and stop staging.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in ApplyStagedInput) (*mcpsdk.CallToolResult, any, error) {
+		done, err := state.beginApply()
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		defer done()
+		release, err := state.writer.acquire(ctx)
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		defer release()
+
+		unlock := func() {
+			state.mu.Unlock()
+			state.updateMu.Unlock()
+		}
+		state.updateMu.Lock()
+		state.mu.Lock()
+		staged := state.staged
+		if staged == nil {
+			unlock()
+			return errResult(fmt.Errorf("nothing staged; call begin_staging first")), nil, nil
+		}
+		plan := staged.Pending()
+		config := state.engine.Config()
+		verifyTests := config.VerifyTests
+		if in.VerifyTests != "" {
+			scope, err := refactor.ParseTestVerification(in.VerifyTests)
+			if err != nil {
+				unlock()
+				return errResult(err), nil, nil
+			}
+			config.VerifyTests = scope
+		}
+		err = staged.Flush(ctx)
+		config.VerifyTests = verifyTests
+		if err != nil {
+			unlock()
+			return errResult(fmt.Errorf("apply staged changes: %w", err)), nil, nil
+		}
+		state.staged = nil
+		unlock()
+
+		if err := state.SyncWorkspaceChanges(plan.AffectedFiles); err != nil {
+			state.logger.WarnContext(ctx, "workspace sync failed", "err", err)
+		}
+		if err := state.verifyWorkspace("after apply_staged"); err != nil {
+			return errResult(err), nil, nil
+		}
+		return textResult(&PlanResult{
+			Description:   "Apply staged changes",
+			AffectedFiles: plan.AffectedFiles,
+			ChangeCount:   len(plan.Changes),
+			ModifiedFiles: plan.AffectedFiles,
+			Success:       true,
+		}), nil, nil
+	})
+
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "discard_staged",
+		Description: "Drop the changes staged since begin_staging and reload the workspace from disk.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in DiscardStagedInput) (*mcpsdk.CallToolResult, any, error) {
+		state.RLock()
+		ws, err := state.GetWorkspace()
+		staging := state.staged != nil
+		state.RUnlock()
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		if !staging {
+			return errResult(fmt.Errorf("nothing staged; call begin_staging first")), nil, nil
+		}
+
+		// Staged plans changed the workspace in place; only a reload undoes them.
+		defer state.trackProgress(ctx, req)()
+		if _, err := state.LoadWorkspace(ctx, ws.RootPath); err != nil {
+			return errResult(err), nil, nil
+		}
+		return textResult(&AnalysisResult{Description: "staged changes discarded", Data: map[string]any{}}), nil, nil
+	})
+}
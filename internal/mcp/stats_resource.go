@@ -0,0 +1,53 @@
+package mcp
+
+import (
+	"context"
+
+	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// statsResourceURI is the URI of the workspace statistics resource.
+const statsResourceURI = "workspace://stats"
+
+// WorkspaceStats is the contents of the workspace statistics resource.
+type WorkspaceStats struct {
+	WorkspaceID    string `json:"workspace_id"`
+	RootPath       string `json:"root_path"`
+	Packages       int    `json:"packages"`
+	Files          int    `json:"files"` // Test files included
+	Generation     uint64 `json:"generation"`
+	PendingApplies int    `json:"pending_applies"`
+	ReferenceIndex bool   `json:"reference_index"` // Whether a reference index is built
+
+	QueryCache QueryCacheStats `json:"query_cache"`
+}
+
+func registerStatsResource(s *mcpsdk.Server, state *MCPServer) {
+	s.AddResource(&mcpsdk.Resource{
+		Name:        "workspace_stats",
+		URI:         statsResourceURI,
+		MIMEType:    "application/json",
+		Description: "Statistics of the loaded workspace: its package and file counts, generation, queued plan applies, whether the reference index is built, and how the query cache answered read-only analyses (analyze_symbol, complexity, unused, the detect_ tools and others): its entries, hits, misses, and how often workspace changes emptied it.",
+	}, func(ctx context.Context, req *mcpsdk.ReadResourceRequest) (*mcpsdk.ReadResourceResult, error) {
+		state.RLock()
+		defer state.RUnlock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			return nil, err
+		}
+		stats := WorkspaceStats{
+			WorkspaceID:    state.id,
+			RootPath:       ws.RootPath,
+			Packages:       len(ws.Packages),
+			Generation:     state.Generation(),
+			PendingApplies: int(state.writer.pending.Load()),
+			QueryCache:     state.queries.snapshot(),
+		}
+		for _, pkg := range ws.Packages {
+			stats.Files += len(pkg.Files) + len(pkg.TestFiles)
+		}
+		stats.ReferenceIndex = state.current().referenceIndex() != nil
+		return jsonResource(req.Params.URI, stats)
+	})
+}
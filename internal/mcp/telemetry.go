@@ -0,0 +1,63 @@
+package mcp
+
+import (
+	"context"
+	"time"
+
+	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/mamaar/gorefactor/internal/logging"
+	"github.com/mamaar/gorefactor/internal/telemetry"
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+type callStartKey struct{}
+
+// traceCalls records each tool call as a span, under which the spans of
+// its steps nest, and in the tool call metrics.
+func (s *MCPServer) traceCalls(next mcpsdk.MethodHandler) mcpsdk.MethodHandler {
+	return func(ctx context.Context, method string, req mcpsdk.Request) (mcpsdk.Result, error) {
+		call, ok := req.(*mcpsdk.CallToolRequest)
+		if !ok {
+			return next(ctx, method, req)
+		}
+		tool := call.Params.Name
+		ctx, span := telemetry.Tracer().Start(ctx, "tools/call "+tool,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(attribute.String("mcp.tool", tool), attribute.String("gorefactor.op_id", logging.Operation(ctx))))
+		start := time.Now()
+		res, err := next(context.WithValue(ctx, callStartKey{}, start), method, req)
+
+		outcome := telemetry.OutcomeOK
+		switch result, _ := res.(*mcpsdk.CallToolResult); {
+		case err != nil:
+			outcome = telemetry.OutcomeError
+		case result != nil && result.IsError:
+			outcome = telemetry.OutcomeToolError
+			span.SetStatus(codes.Error, "tool returned an error")
+		}
+		telemetry.End(span, err)
+		telemetry.ObserveToolCall(tool, outcome, time.Since(start))
+		return res, err
+	}
+}
+
+// observePlan records the plan a tool call made as a span from the start of
+// the call, which is when planning began, and in the plan metrics. Plans
+// made outside tool calls are left to their callers.
+func observePlan(ctx context.Context, plan *types.RefactoringPlan, desc string) {
+	start, ok := ctx.Value(callStartKey{}).(time.Time)
+	if !ok {
+		return
+	}
+	_, span := telemetry.Tracer().Start(ctx, telemetry.SpanPlan, trace.WithTimestamp(start), trace.WithAttributes(
+		attribute.String("gorefactor.plan.description", desc),
+		attribute.Int("gorefactor.plan.changes", len(plan.Changes)),
+		attribute.Int("gorefactor.plan.files", len(plan.AffectedFiles)),
+	))
+	span.End()
+	telemetry.ObservePlan(len(plan.Changes))
+}
@@ -0,0 +1,184 @@
+package mcp
+
+import (
+	"context"
+
+	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// --- convert_type_alias ---
+
+type ConvertTypeAliasInput struct {
+	TypeName string `json:"type_name" jsonschema:"name of the type alias or defined type"`
+	Package  string `json:"package,omitempty" jsonschema:"package path of the type (empty for workspace-wide)"`
+	ToAlias  bool   `json:"to_alias,omitempty" jsonschema:"make a defined type an alias (type A = B); by default an alias becomes a defined type (type A B)"`
+}
+
+// --- convert_receivers ---
+
+type ConvertReceiversInput struct {
+	TypeName string `json:"type_name" jsonschema:"name of the type whose methods to convert"`
+	Package  string `json:"package,omitempty" jsonschema:"package path of the type (empty for workspace-wide)"`
+	Receiver string `json:"receiver,omitempty" jsonschema:"'pointer' or 'value'; by default the form most of the methods have, pointer on a tie"`
+	Preview  bool   `json:"preview,omitempty" jsonschema:"return the planned changes without applying them"`
+}
+
+// --- add_field ---
+
+type AddFieldInput struct {
+	TypeName     string `json:"type_name" jsonschema:"name of the struct type"`
+	FieldName    string `json:"field_name" jsonschema:"name of the new field"`
+	FieldType    string `json:"field_type" jsonschema:"type of the new field as written in the struct's file, e.g. time.Duration"`
+	Package      string `json:"package,omitempty" jsonschema:"package path of the type (empty for workspace-wide)"`
+	Before       string `json:"before,omitempty" jsonschema:"field to add it before"`
+	After        string `json:"after,omitempty" jsonschema:"field to add it after; it is added last when neither before nor after is set"`
+	Tag          string `json:"tag,omitempty" jsonschema:"struct tag without the backquotes, e.g. json:\"timeout\""`
+	Default      string `json:"default,omitempty" jsonschema:"value positional literals and constructor calls get; the field's zero value by default"`
+	Constructors bool   `json:"constructors,omitempty" jsonschema:"also add a parameter for the field to New<Type> functions that set every field from a parameter"`
+	Preview      bool   `json:"preview,omitempty" jsonschema:"return the planned changes without applying them"`
+}
+
+// --- introduce_type ---
+
+type IntroduceTypeInput struct {
+	TypeName   string   `json:"type_name" jsonschema:"name of the new type, e.g. UserID"`
+	Underlying string   `json:"underlying" jsonschema:"type it is defined as, e.g. string"`
+	Package    string   `json:"package" jsonschema:"package to declare the type in"`
+	Targets    []string `json:"targets,omitempty" jsonschema:"declarations to retype: Func.param, Type.Method.param, Type.Field or Var, each optionally prefixed with package: (e.g. internal/store:Store.Get.id)"`
+}
+
+func registerTypeTools(s *mcpsdk.Server, state *MCPServer) {
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "convert_type_alias",
+		Description: "Turn a type alias (type A = B) into a defined type (type A B), or with to_alias a defined type into an alias. Uses the conversion may break, such as assignments from the aliased type or type switch cases that become duplicates, are returned as warnings.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in ConvertTypeAliasInput) (*mcpsdk.CallToolResult, any, error) {
+		state.RLock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+		pkgPath := in.Package
+		if pkgPath != "" {
+			pkgPath = types.ResolvePackagePath(ws, pkgPath)
+		}
+		plan, err := state.GetEngine().ConvertTypeAlias(ws, types.ConvertTypeAliasRequest{
+			TypeName: in.TypeName,
+			Package:  pkgPath,
+			ToAlias:  in.ToAlias,
+		})
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+		desc := "convert type alias " + in.TypeName
+		if in.ToAlias {
+			desc = "convert " + in.TypeName + " to a type alias"
+		}
+		result, err := executePlanWithUnlock(ctx, state, plan, desc)
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		return textResult(result), nil, nil
+	})
+
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "convert_receivers",
+		Description: "Give every method of a type the same receiver form, pointer or value. Receiver uses in the converted methods keep their type (r becomes *r or &r), calls on composite literals and method expressions are fixed to take a pointer. Returned as warnings: methods whose writes to the receiver change meaning, interfaces the type starts or stops implementing, values used as an interface it no longer implements or called on where they aren't addressable, and locks value receivers copy.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in ConvertReceiversInput) (*mcpsdk.CallToolResult, any, error) {
+		state.RLock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+		pkgPath := in.Package
+		if pkgPath != "" {
+			pkgPath = types.ResolvePackagePath(ws, pkgPath)
+		}
+		plan, err := state.GetEngine().ConvertReceivers(ws, types.ConvertReceiversRequest{
+			TypeName: in.TypeName,
+			Package:  pkgPath,
+			Receiver: types.ReceiverKind(in.Receiver),
+		})
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+		result, err := previewPlanWithUnlock(ctx, state, plan, "convert receivers of "+in.TypeName, in.Preview)
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		return textResult(result), nil, nil
+	})
+
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "add_field",
+		Description: "Add a field to a struct type. Keyed literals are left as they are; positional literals get the default or the field's zero value at the field's position. With constructors, New<Type> functions that build the struct from one parameter per field get a parameter for it, and their callers pass the default.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in AddFieldInput) (*mcpsdk.CallToolResult, any, error) {
+		state.RLock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+		pkgPath := in.Package
+		if pkgPath != "" {
+			pkgPath = types.ResolvePackagePath(ws, pkgPath)
+		}
+		plan, err := state.GetEngine().AddField(ws, types.AddFieldRequest{
+			TypeName:     in.TypeName,
+			FieldName:    in.FieldName,
+			FieldType:    in.FieldType,
+			Package:      pkgPath,
+			Before:       in.Before,
+			After:        in.After,
+			Tag:          in.Tag,
+			Default:      in.Default,
+			Constructors: in.Constructors,
+		})
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+		result, err := previewPlanWithUnlock(ctx, state, plan, "add field "+in.TypeName+"."+in.FieldName, in.Preview)
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		return textResult(result), nil, nil
+	})
+
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name: "introduce_type",
+		Description: `Declare a named type for a recurring primitive (e.g. type UserID string) and retype the chosen parameters, results, struct fields and package-level variables across the workspace to it. Non-constant values written to them (arguments, assignments, literals, returns) are converted to the new type.
+Other declarations of the underlying type named like the new type are listed as candidates for targets; preview first to pick them.`,
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in IntroduceTypeInput) (*mcpsdk.CallToolResult, any, error) {
+		state.RLock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+		plan, err := state.GetEngine().IntroduceType(ws, types.IntroduceTypeRequest{
+			TypeName:   in.TypeName,
+			Underlying: in.Underlying,
+			Package:    types.ResolvePackagePath(ws, in.Package),
+			Targets:    in.Targets,
+		})
+		if err != nil {
+			state.RUnlock()
+			return errResult(err), nil, nil
+		}
+		result, err := executePlanWithUnlock(ctx, state, plan, "introduce type "+in.TypeName)
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		return textResult(result), nil, nil
+	})
+}
@@ -1,23 +1,37 @@
 package mcp
 
 import (
+	"cmp"
 	"context"
+	"fmt"
+	"path/filepath"
+	"runtime"
 	"sort"
 
 	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/mamaar/gorefactor/pkg/analysis"
+	"github.com/mamaar/gorefactor/pkg/types"
 )
 
 // --- load_workspace ---
 
 type LoadWorkspaceInput struct {
-	Path string `json:"path" jsonschema:"absolute path to workspace root (go.mod directory)"`
+	Path           string   `json:"path" jsonschema:"absolute path to workspace root (go.mod directory)"`
+	AllowGenerated bool     `json:"allow_generated,omitempty" jsonschema:"allow refactorings to edit generated files (Code generated ... DO NOT EDIT, *.pb.go, mock files and directories)"`
+	MinConfidence  string   `json:"min_confidence,omitempty" jsonschema:"skip planned changes less sure than this when applying: certain, likely or heuristic (default: engine.min_confidence of .gorefactor.yaml, else apply all)"`
+	VerifyTests    string   `json:"verify_tests,omitempty" jsonschema:"run go test before and after applying each plan and roll the plan back when tests fail that passed before: off, affected (the packages of the changed files) or all (default: engine.verify_tests of .gorefactor.yaml, else off)"`
+	Scope          []string `json:"scope,omitempty" jsonschema:"package directories relative to the root that operations are limited to, e.g. internal/billing/...; other packages, except the ones importing them, are loaded without syntax trees to save memory (default: engine.scope of .gorefactor.yaml, else everything)"`
+	MapSources     bool     `json:"map_sources,omitempty" jsonschema:"keep file contents in memory-mapped files instead of the heap (default: engine.map_sources of .gorefactor.yaml)"`
 }
 
 type LoadWorkspaceOutput struct {
-	Module             string `json:"module"`
-	PackageCount       int    `json:"package_count"`
-	RootPath           string `json:"root_path"`
+	WorkspaceID         string `json:"workspace_id"` // For the workspace_id argument of the tools
+	Module              string `json:"module"`
+	PackageCount        int    `json:"package_count"`
+	RootPath            string `json:"root_path"`
 	ReferenceIndexBuilt bool   `json:"reference_index_built"`
+	ConfigFile          string `json:"config_file,omitempty"`
 }
 
 // --- workspace_status ---
@@ -26,36 +40,69 @@ type WorkspaceStatusInput struct{}
 
 type WorkspaceStatusOutput struct {
 	Loaded       bool     `json:"loaded"`
+	WorkspaceID  string   `json:"workspace_id,omitempty"`
 	Module       string   `json:"module,omitempty"`
 	RootPath     string   `json:"root_path,omitempty"`
 	PackageCount int      `json:"package_count"`
 	Packages     []string `json:"packages,omitempty"`
+	StagedFiles  []string `json:"staged_files,omitempty"` // Files with changes staged since begin_staging
+
+	Generation     uint64 `json:"generation"`                // Advanced by every change to the workspace
+	PendingApplies int    `json:"pending_applies,omitempty"` // Plans being applied or queued to be
+}
+
+// --- memory_stats ---
+
+type MemoryStatsInput struct {
+	Package string `json:"package,omitempty" jsonschema:"only report this package: import path, directory relative to the workspace root, or package name (default: every package)"`
+	Top     int    `json:"top,omitempty" jsonschema:"only report this many packages, largest first (default: all)"`
+}
+
+type MemoryStatsOutput struct {
+	HeapAlloc      uint64                   `json:"heap_alloc"`   // Bytes of live objects on the process's heap
+	HeapSys        uint64                   `json:"heap_sys"`     // Bytes of heap obtained from the OS
+	SourceBytes    int64                    `json:"source_bytes"` // File contents on the heap, all packages
+	MappedBytes    int64                    `json:"mapped_bytes"` // File contents in memory-mapped files, all packages
+	PrunedPackages int                      `json:"pruned_packages,omitempty"`
+	Packages       []analysis.PackageMemory `json:"packages"`
+}
+
+// --- validate_workspace ---
+
+type ValidateWorkspaceInput struct {
+	Package string `json:"package,omitempty" jsonschema:"only report this package: import path, directory relative to the workspace root, or package name (default: every package)"`
+}
+
+type ValidateWorkspaceOutput struct {
+	Valid        bool                            `json:"valid"` // No diagnostic is an error
+	ErrorCount   int                             `json:"error_count"`
+	WarningCount int                             `json:"warning_count"`
+	Diagnostics  []*analysis.WorkspaceDiagnostic `json:"diagnostics"`
 }
 
 func registerWorkspaceTools(s *mcpsdk.Server, state *MCPServer) {
 	mcpsdk.AddTool(s, &mcpsdk.Tool{
 		Name:        "load_workspace",
-		Description: "Load a Go workspace into memory for refactoring. Must be called before any other tool.",
+		Description: "Load a Go workspace into memory for refactoring, replacing the workspace of the session. Must be called before any other tool; use open_workspace to work on several workspaces at once.",
 	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in LoadWorkspaceInput) (*mcpsdk.CallToolResult, any, error) {
-		indexBuilt, err := state.LoadWorkspace(ctx, in.Path)
+		defer state.trackProgress(ctx, req)()
+		opts, err := parseWorkspaceOptions(in)
 		if err != nil {
 			return errResult(err), nil, nil
 		}
-		ws, _ := state.GetWorkspace()
-		out := LoadWorkspaceOutput{
-			PackageCount:        len(ws.Packages),
-			RootPath:            ws.RootPath,
-			ReferenceIndexBuilt: indexBuilt,
-		}
-		if ws.Module != nil {
-			out.Module = ws.Module.Path
+		state.mu.Lock()
+		state.options = opts
+		state.mu.Unlock()
+		indexBuilt, err := state.LoadWorkspace(ctx, in.Path)
+		if err != nil {
+			return errResult(err), nil, nil
 		}
-		return textResult(out), nil, nil
+		return textResult(state.loadOutput(indexBuilt)), nil, nil
 	})
 
 	mcpsdk.AddTool(s, &mcpsdk.Tool{
 		Name:        "workspace_status",
-		Description: "Return the current workspace status: loaded state, module name, package count, and package list.",
+		Description: "Return the current workspace status: loaded state, module name, package count, and package list, the generation every change to the workspace advances, and the number of plans being applied or queued behind one. Plans are applied one at a time; one editing a file that changed while it was planned is refused (GR3002).",
 	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in WorkspaceStatusInput) (*mcpsdk.CallToolResult, any, error) {
 		state.RLock()
 		defer state.RUnlock()
@@ -66,6 +113,7 @@ func registerWorkspaceTools(s *mcpsdk.Server, state *MCPServer) {
 		}
 		out := WorkspaceStatusOutput{
 			Loaded:       true,
+			WorkspaceID:  state.id,
 			RootPath:     ws.RootPath,
 			PackageCount: len(ws.Packages),
 		}
@@ -76,6 +124,94 @@ func registerWorkspaceTools(s *mcpsdk.Server, state *MCPServer) {
 			out.Packages = append(out.Packages, pkg.ImportPath)
 		}
 		sort.Strings(out.Packages)
+		out.StagedFiles = state.stagedFiles()
+		out.Generation = state.Generation()
+		out.PendingApplies = int(state.writer.pending.Load())
+		return textResult(out), nil, nil
+	})
+
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "memory_stats",
+		Description: "Estimate the memory the loaded workspace holds, per package and largest first: file contents on the heap and memory-mapped, syntax tree nodes and bytes, and type information bytes, with the process's heap size. Packages outside the load_workspace scope are marked pruned. Sizes are estimates from node and map entry counts.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in MemoryStatsInput) (*mcpsdk.CallToolResult, any, error) {
+		state.RLock()
+		defer state.RUnlock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		var ms runtime.MemStats
+		runtime.ReadMemStats(&ms)
+		out := MemoryStatsOutput{HeapAlloc: ms.HeapAlloc, HeapSys: ms.HeapSys, Packages: []analysis.PackageMemory{}}
+		var only *types.Package
+		if in.Package != "" {
+			if only = ws.Packages[types.ResolvePackagePath(ws, in.Package)]; only == nil {
+				return errResult(&types.RefactorError{Type: types.InvalidOperation, Message: fmt.Sprintf("package %q is not in the workspace", in.Package)}), nil, nil
+			}
+		}
+		for _, pm := range state.GetEngine().MemoryReport(ws) {
+			out.SourceBytes += pm.SourceBytes
+			out.MappedBytes += pm.MappedBytes
+			if pm.Pruned {
+				out.PrunedPackages++
+			}
+			if only != nil && pm.Package != cmp.Or(only.ImportPath, only.Path) {
+				continue
+			}
+			if in.Top <= 0 || len(out.Packages) < in.Top {
+				out.Packages = append(out.Packages, pm)
+			}
+		}
+		return textResult(out), nil, nil
+	})
+
+	mcpsdk.AddTool(s, &mcpsdk.Tool{
+		Name:        "validate_workspace",
+		Description: "Check that the workspace builds: report syntax errors, type errors, imports that resolve to no package and files whose package clause disagrees with their directory, each with file, line, column, severity (error, or warning for a package that couldn't be checked) and kind (parse, type, import or package). Test files are included; files the build constraints exclude are not. valid is false when there is an error.",
+	}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in ValidateWorkspaceInput) (*mcpsdk.CallToolResult, any, error) {
+		state.RLock()
+		defer state.RUnlock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		diags, err := state.GetEngine().ValidateWorkspace(ctx, ws)
+		if err != nil {
+			return errResult(err), nil, nil
+		}
+		if in.Package != "" {
+			dir, ok := ws.ImportToPath[in.Package]
+			if !ok {
+				dir = types.ResolvePackagePath(ws, in.Package)
+			}
+			// Packages with syntax errors don't load, so may only be named by
+			// their directory
+			if ws.Packages[dir] == nil && !filepath.IsAbs(dir) {
+				dir = filepath.Join(ws.RootPath, dir)
+			}
+			var filtered []*analysis.WorkspaceDiagnostic
+			for _, d := range diags {
+				if filepath.Dir(d.File) == dir {
+					filtered = append(filtered, d)
+				}
+			}
+			diags = filtered
+		}
+
+		out := ValidateWorkspaceOutput{Diagnostics: diags}
+		for _, d := range diags {
+			if d.Severity == analysis.SeverityError {
+				out.ErrorCount++
+			} else {
+				out.WarningCount++
+			}
+		}
+		out.Valid = out.ErrorCount == 0
+		if out.Diagnostics == nil {
+			out.Diagnostics = []*analysis.WorkspaceDiagnostic{}
+		}
 		return textResult(out), nil, nil
 	})
 }
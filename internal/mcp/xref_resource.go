@@ -0,0 +1,44 @@
+package mcp
+
+import (
+	"context"
+
+	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/mamaar/gorefactor/pkg/analysis"
+)
+
+// xrefResourcePrefix starts the URI of a package's cross-reference graph.
+const xrefResourcePrefix = "workspace://xref/"
+
+func registerXRefResource(s *mcpsdk.Server, state *MCPServer) {
+	s.AddResourceTemplate(&mcpsdk.ResourceTemplate{
+		Name:        "package_xref",
+		URITemplate: xrefResourcePrefix + "{+package}",
+		MIMEType:    "application/json",
+		Description: "Symbol cross-reference graph around a workspace package: a node per top-level function, method, type, variable and constant, with its kind, file and whether it is exported, and an edge with a reference count for each declaration that uses another. Covers the package's own symbols, who uses them across the workspace, and which workspace symbols outside the package it uses. Read it to see what depends on a symbol before moving, renaming or deleting it. The package is an import path, a directory relative to the workspace root, or a unique package name.",
+	}, func(ctx context.Context, req *mcpsdk.ReadResourceRequest) (*mcpsdk.ReadResourceResult, error) {
+		state.RLock()
+		defer state.RUnlock()
+
+		ws, err := state.GetWorkspace()
+		if err != nil {
+			return nil, err
+		}
+		pkg, err := resourcePackage(ws, req.Params.URI, xrefResourcePrefix)
+		if err != nil {
+			return nil, err
+		}
+		state.GetEngine().EnsureTypeChecked(ws, pkg)
+
+		idx, err := state.EnsureReferenceIndex(ws)
+		if err != nil {
+			return nil, err
+		}
+		graph, err := analysis.BuildXRefGraph(ws, analysis.NewReferenceFinder(state.SymbolResolver(ws), idx), pkg)
+		if err != nil {
+			return nil, err
+		}
+		return jsonResource(req.Params.URI, graph)
+	})
+}
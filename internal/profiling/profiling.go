@@ -0,0 +1,144 @@
+// Package profiling serves the pprof endpoints and Prometheus metrics of
+// the commands and writes their CPU and memory profiles, from their -pprof,
+// -metrics, -profile and -profile-out flags.
+package profiling
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"path/filepath"
+	"runtime"
+	runtimepprof "runtime/pprof"
+
+	"github.com/mamaar/gorefactor/internal/logging"
+	"github.com/mamaar/gorefactor/internal/telemetry"
+)
+
+// Flags are the profiling flags of a command. The values they hold when
+// registered are the flags' defaults.
+type Flags struct {
+	Pprof      string // Address to serve net/http/pprof on; empty for none
+	Metrics    string // Address to serve Prometheus metrics on; empty for none
+	Profile    string // "cpu" or "mem" to write a profile when the command ends; empty for none
+	ProfileOut string // Where to write the profile; empty is <profile>.pprof in logging.StateDir
+}
+
+// Register defines -pprof, -metrics, -profile and -profile-out on fs.
+func (f *Flags) Register(fs *flag.FlagSet) {
+	fs.StringVar(&f.Pprof, "pprof", f.Pprof, "serve net/http/pprof endpoints on this address (e.g. localhost:6060)")
+	fs.StringVar(&f.Metrics, "metrics", f.Metrics, "serve Prometheus metrics at /metrics on this address (e.g. localhost:9090)")
+	fs.StringVar(&f.Profile, "profile", f.Profile, "write a cpu or mem profile when the command ends")
+	fs.StringVar(&f.ProfileOut, "profile-out", f.ProfileOut, "profile output path (default <profile>.pprof in the state directory)")
+}
+
+// Start starts what the flags ask for and returns a function writing the
+// profile, if any, to be called when the command ends.
+func (f *Flags) Start(logger *slog.Logger) (stop func() error, err error) {
+	stop = func() error { return nil }
+	if f.Pprof != "" {
+		if err := startPprof(f.Pprof, logger); err != nil {
+			return nil, err
+		}
+	}
+	if f.Metrics != "" {
+		if err := startMetrics(f.Metrics, logger); err != nil {
+			return nil, err
+		}
+	}
+	if f.Profile == "" {
+		return stop, nil
+	}
+	path := f.ProfileOut
+	if path == "" {
+		path = filepath.Join(logging.StateDir(), f.Profile+".pprof")
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, err
+		}
+	}
+	if stop, err = startProfile(f.Profile, path); err != nil {
+		return nil, err
+	}
+	logger.Info("profiling enabled", "profile", f.Profile, "path", path)
+	return stop, nil
+}
+
+// startPprof serves the net/http/pprof handlers on addr in the background.
+// The handlers are registered on their own mux so nothing else is exposed.
+func startPprof(addr string, logger *slog.Logger) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("pprof listen: %w", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	logger.Info("pprof endpoints listening", "addr", ln.Addr().String())
+	go func() {
+		if err := http.Serve(ln, mux); err != nil {
+			logger.Warn("pprof server stopped", "err", err)
+		}
+	}()
+	return nil
+}
+
+// startMetrics serves the Prometheus metrics at /metrics on addr in the
+// background.
+func startMetrics(addr string, logger *slog.Logger) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("metrics listen: %w", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", telemetry.Handler())
+
+	logger.Info("metrics endpoint listening", "addr", ln.Addr().String())
+	go func() {
+		if err := http.Serve(ln, mux); err != nil {
+			logger.Warn("metrics server stopped", "err", err)
+		}
+	}()
+	return nil
+}
+
+// startProfile starts a "cpu" or "mem" profile and returns a function that
+// writes it to path. CPU profiles cover everything until the returned function
+// is called; memory profiles are a heap snapshot taken at that point.
+func startProfile(kind, path string) (func() error, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("create profile: %w", err)
+	}
+	switch kind {
+	case "cpu":
+		if err := runtimepprof.StartCPUProfile(f); err != nil {
+			_ = f.Close()
+			return nil, fmt.Errorf("start cpu profile: %w", err)
+		}
+		return func() error {
+			runtimepprof.StopCPUProfile()
+			return f.Close()
+		}, nil
+	case "mem":
+		return func() error {
+			runtime.GC()
+			if err := runtimepprof.WriteHeapProfile(f); err != nil {
+				_ = f.Close()
+				return fmt.Errorf("write heap profile: %w", err)
+			}
+			return f.Close()
+		}, nil
+	default:
+		_ = f.Close()
+		_ = os.Remove(path)
+		return nil, fmt.Errorf("unknown profile %q (want cpu or mem)", kind)
+	}
+}
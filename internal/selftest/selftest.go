@@ -0,0 +1,526 @@
+// Package selftest qualifies gorefactor against a corpus of Go repositories.
+// It applies a matrix of refactorings to copies of each repository and checks
+// that the code still builds, and that its tests still pass, after each plan.
+package selftest
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mamaar/gorefactor/pkg/config"
+	"github.com/mamaar/gorefactor/pkg/refactor"
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// Statuses of a Result.
+const (
+	StatusPassed      = "passed"       // The plan was applied and the code still builds and passes its tests
+	StatusRefused     = "refused"      // Planning or validation refused the refactoring; nothing was written
+	StatusApplyFailed = "apply_failed" // Writing the plan failed
+	StatusBuildFailed = "build_failed" // The code no longer builds
+	StatusTestFailed  = "test_failed"  // The code builds but its tests no longer pass
+)
+
+// Operations are the refactorings of the matrix, in the order they run.
+var Operations = []string{"rename_symbol", "rename_type", "rename_method", "rename_package", "move_symbol"}
+
+// Options configure a Run.
+type Options struct {
+	Operations []string     // Operations to run; all of Operations when empty
+	Targets    int          // Symbols or packages each operation is tried on per repository (default 3)
+	Tests      bool         // Also run go test ./... before and after each plan
+	Logger     *slog.Logger // Receives a line per run; nil discards them
+}
+
+// Report is the outcome of a Run.
+type Report struct {
+	Corpus     string              `json:"corpus"`
+	Repos      []*RepoReport       `json:"repos"`
+	Operations []*OperationSummary `json:"operations"`
+}
+
+// RepoReport holds the results for one repository of the corpus.
+type RepoReport struct {
+	Name    string    `json:"name"`
+	Skipped string    `json:"skipped,omitempty"` // Why the repository was left out, e.g. it doesn't build as checked out
+	Results []*Result `json:"results"`
+}
+
+// Result is one operation applied to one target.
+type Result struct {
+	Operation string  `json:"operation"`
+	Target    string  `json:"target"` // Package directory relative to the repository, then the symbol
+	Status    string  `json:"status"`
+	Changes   int     `json:"changes,omitempty"`
+	Error     string  `json:"error,omitempty"`
+	Seconds   float64 `json:"seconds"`
+}
+
+// OperationSummary counts the results of an operation across the corpus.
+type OperationSummary struct {
+	Operation string `json:"operation"`
+	Runs      int    `json:"runs"`
+	Passed    int    `json:"passed"`
+	Refused   int    `json:"refused"`
+	Failed    int    `json:"failed"` // Applied, then broke the build or the tests
+}
+
+// Failed reports whether any plan was applied and broke its repository.
+func (r *Report) Failed() bool {
+	return slices.ContainsFunc(r.Operations, func(s *OperationSummary) bool { return s.Failed > 0 })
+}
+
+// target is what an operation is applied to. Dirs are relative to the
+// repository root.
+type target struct {
+	dir      string
+	name     string // Symbol, method or package name
+	typeName string // Receiver of a method
+	toDir    string // Destination of a move
+}
+
+func (t target) String() string {
+	switch {
+	case t.typeName != "":
+		return t.dir + ": " + t.typeName + "." + t.name
+	case t.toDir != "":
+		return t.dir + ": " + t.name + " -> " + t.toDir
+	}
+	return t.dir + ": " + t.name
+}
+
+// operation picks the targets of a refactoring in a workspace and plans it.
+type operation struct {
+	targets func(ws *types.Workspace) []target
+	plan    func(e refactor.RefactorEngine, ws *types.Workspace, t target) (*types.RefactoringPlan, error)
+}
+
+var operations = map[string]operation{
+	"rename_symbol": {
+		targets: func(ws *types.Workspace) []target {
+			return symbolTargets(ws, func(s *types.SymbolTable) map[string]*types.Symbol { return s.Functions })
+		},
+		plan: func(e refactor.RefactorEngine, ws *types.Workspace, t target) (*types.RefactoringPlan, error) {
+			return e.RenameSymbol(ws, types.RenameSymbolRequest{
+				SymbolName: t.name,
+				NewName:    t.name + "Selftest",
+				Package:    filepath.Join(ws.RootPath, t.dir),
+				Scope:      types.PackageScope,
+			})
+		},
+	},
+	"rename_type": {
+		targets: func(ws *types.Workspace) []target {
+			return symbolTargets(ws, func(s *types.SymbolTable) map[string]*types.Symbol { return s.Types })
+		},
+		plan: func(e refactor.RefactorEngine, ws *types.Workspace, t target) (*types.RefactoringPlan, error) {
+			return e.RenameSymbol(ws, types.RenameSymbolRequest{
+				SymbolName: t.name,
+				NewName:    t.name + "Selftest",
+				Package:    filepath.Join(ws.RootPath, t.dir),
+				Scope:      types.PackageScope,
+			})
+		},
+	},
+	"rename_method": {
+		targets: methodTargets,
+		plan: func(e refactor.RefactorEngine, ws *types.Workspace, t target) (*types.RefactoringPlan, error) {
+			return e.RenameMethod(ws, types.RenameMethodRequest{
+				TypeName:      t.typeName,
+				MethodName:    t.name,
+				NewMethodName: t.name + "Selftest",
+				PackagePath:   filepath.Join(ws.RootPath, t.dir),
+			})
+		},
+	},
+	"rename_package": {
+		targets: func(ws *types.Workspace) []target {
+			var targets []target
+			for _, pkg := range libraryPackages(ws) {
+				targets = append(targets, target{dir: relDir(ws, pkg.Path), name: pkg.Name})
+			}
+			return targets
+		},
+		plan: func(e refactor.RefactorEngine, ws *types.Workspace, t target) (*types.RefactoringPlan, error) {
+			return e.RenamePackage(ws, types.RenamePackageRequest{
+				OldPackageName: t.name,
+				NewPackageName: t.name + "selftest",
+				PackagePath:    filepath.Join(ws.RootPath, t.dir),
+				UpdateImports:  true,
+			})
+		},
+	},
+	"move_symbol": {
+		targets: moveTargets,
+		plan: func(e refactor.RefactorEngine, ws *types.Workspace, t target) (*types.RefactoringPlan, error) {
+			return e.MoveSymbol(ws, types.MoveSymbolRequest{
+				SymbolName:  t.name,
+				FromPackage: filepath.Join(ws.RootPath, t.dir),
+				ToPackage:   filepath.Join(ws.RootPath, t.toDir),
+			})
+		},
+	},
+}
+
+// Run applies the operation matrix to every repository of corpus: corpus
+// itself when it holds a go.mod, otherwise each of its directories that
+// does. Repositories are copied before anything is written, so the corpus
+// is left as it is.
+func Run(ctx context.Context, corpus string, opts Options) (*Report, error) {
+	if len(opts.Operations) == 0 {
+		opts.Operations = Operations
+	}
+	for _, op := range opts.Operations {
+		if _, ok := operations[op]; !ok {
+			return nil, fmt.Errorf("unknown operation %q (known: %s)", op, strings.Join(Operations, ", "))
+		}
+	}
+	if opts.Targets <= 0 {
+		opts.Targets = 3
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+
+	corpus, err := filepath.Abs(corpus)
+	if err != nil {
+		return nil, err
+	}
+	repos, err := findRepos(corpus)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{Corpus: corpus}
+	summaries := make(map[string]*OperationSummary)
+	for _, op := range opts.Operations {
+		summaries[op] = &OperationSummary{Operation: op}
+		report.Operations = append(report.Operations, summaries[op])
+	}
+	for _, repo := range repos {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		rr := runRepo(ctx, repo, opts)
+		rr.Name, _ = filepath.Rel(corpus, repo)
+		if rr.Name == "." {
+			rr.Name = filepath.Base(repo)
+		}
+		for _, res := range rr.Results {
+			s := summaries[res.Operation]
+			s.Runs++
+			switch res.Status {
+			case StatusPassed:
+				s.Passed++
+			case StatusRefused:
+				s.Refused++
+			default:
+				s.Failed++
+			}
+		}
+		report.Repos = append(report.Repos, rr)
+	}
+	return report, ctx.Err()
+}
+
+// findRepos returns the module roots of corpus.
+func findRepos(corpus string) ([]string, error) {
+	if _, err := os.Stat(filepath.Join(corpus, "go.mod")); err == nil {
+		return []string{corpus}, nil
+	}
+	entries, err := os.ReadDir(corpus)
+	if err != nil {
+		return nil, err
+	}
+	var repos []string
+	for _, entry := range entries {
+		dir := filepath.Join(corpus, entry.Name())
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); entry.IsDir() && err == nil {
+			repos = append(repos, dir)
+		}
+	}
+	if len(repos) == 0 {
+		return nil, fmt.Errorf("no Go modules in %s or its directories", corpus)
+	}
+	return repos, nil
+}
+
+// runRepo checks that repo builds as checked out, then runs every operation
+// on up to opts.Targets targets, each on a fresh copy.
+func runRepo(ctx context.Context, repo string, opts Options) *RepoReport {
+	rr := &RepoReport{Results: []*Result{}}
+	baseline, err := copyRepo(repo)
+	if err != nil {
+		rr.Skipped = err.Error()
+		return rr
+	}
+	defer os.RemoveAll(baseline)
+	if status, err := check(ctx, baseline, opts.Tests); err != nil {
+		rr.Skipped = fmt.Sprintf("%s before any refactoring: %v", status, err)
+		return rr
+	}
+	_, ws, err := loadRepo(baseline)
+	if err != nil {
+		rr.Skipped = err.Error()
+		return rr
+	}
+
+	for _, name := range opts.Operations {
+		op := operations[name]
+		targets := op.targets(ws)
+		for _, t := range targets[:min(len(targets), opts.Targets)] {
+			if ctx.Err() != nil {
+				return rr
+			}
+			start := time.Now()
+			res := runOne(ctx, repo, op, t, opts.Tests)
+			res.Operation, res.Target, res.Seconds = name, t.String(), time.Since(start).Seconds()
+			opts.Logger.Info("selftest", "repo", repo, "operation", name, "target", res.Target, "status", res.Status)
+			rr.Results = append(rr.Results, res)
+		}
+	}
+	return rr
+}
+
+// runOne applies op to t on a fresh copy of repo and checks the result.
+func runOne(ctx context.Context, repo string, op operation, t target, tests bool) *Result {
+	dir, err := copyRepo(repo)
+	if err != nil {
+		return &Result{Status: StatusApplyFailed, Error: err.Error()}
+	}
+	defer os.RemoveAll(dir)
+
+	engine, ws, err := loadRepo(dir)
+	if err != nil {
+		return &Result{Status: StatusApplyFailed, Error: err.Error()}
+	}
+	plan, err := op.plan(engine, ws, t)
+	if err == nil {
+		err = refused(engine, plan)
+	}
+	if err != nil {
+		return &Result{Status: StatusRefused, Error: err.Error()}
+	}
+	res := &Result{Status: StatusPassed, Changes: len(plan.Changes)}
+	if err := engine.ExecutePlanContext(ctx, plan); err != nil {
+		res.Status, res.Error = StatusApplyFailed, err.Error()
+		return res
+	}
+	if status, err := check(ctx, dir, tests); err != nil {
+		res.Status, res.Error = status, err.Error()
+	}
+	return res
+}
+
+// refused returns why the engine would refuse to apply plan, or nil.
+func refused(engine refactor.RefactorEngine, plan *types.RefactoringPlan) error {
+	if err := engine.ValidateRefactoring(plan); err != nil {
+		return err
+	}
+	if plan.Impact != nil {
+		for _, issue := range plan.Impact.PotentialIssues {
+			if issue.Severity == types.Error {
+				return errors.New(issue.Description)
+			}
+		}
+	}
+	return nil
+}
+
+// loadRepo loads the workspace at dir with an engine configured by its
+// .gorefactor.yaml. The engine doesn't check builds itself; check does.
+func loadRepo(dir string) (refactor.RefactorEngine, *types.Workspace, error) {
+	cfg, err := config.LoadWorkspace(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	ec := refactor.DefaultConfig()
+	cfg.ApplyEngine(ec)
+	ec.SkipCompilation, ec.Journal = true, false
+	engine := refactor.CreateEngineWithConfig(ec, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ws, err := engine.LoadWorkspace(dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("load workspace: %w", err)
+	}
+	return engine, ws, nil
+}
+
+// check builds the module at dir and, with tests, runs its tests. It
+// returns the status a failure gives a result.
+func check(ctx context.Context, dir string, tests bool) (string, error) {
+	if err := goCommand(ctx, dir, "build", "./..."); err != nil {
+		return StatusBuildFailed, err
+	}
+	if tests {
+		if err := goCommand(ctx, dir, "test", "./..."); err != nil {
+			return StatusTestFailed, err
+		}
+	}
+	return StatusPassed, nil
+}
+
+// maxOutputLines bounds the go command output kept in an error.
+const maxOutputLines = 20
+
+func goCommand(ctx context.Context, dir string, args ...string) error {
+	cmd := exec.CommandContext(ctx, "go", args...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	if err == nil {
+		return nil
+	}
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	if len(lines) > maxOutputLines {
+		lines = append(lines[:maxOutputLines], "...")
+	}
+	return fmt.Errorf("go %s: %w\n%s", strings.Join(args, " "), err, strings.Join(lines, "\n"))
+}
+
+// copyRepo copies repo, without its .git directory, to a new temporary
+// directory.
+func copyRepo(repo string) (string, error) {
+	dst, err := os.MkdirTemp("", "gorefactor-selftest-")
+	if err != nil {
+		return "", err
+	}
+	err = filepath.WalkDir(repo, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(repo, path)
+		target := filepath.Join(dst, rel)
+		switch {
+		case d.IsDir() && d.Name() == ".git":
+			return filepath.SkipDir
+		case d.IsDir():
+			return os.MkdirAll(target, 0o755)
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case !d.Type().IsRegular():
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, content, info.Mode().Perm())
+	})
+	if err != nil {
+		_ = os.RemoveAll(dst)
+		return "", fmt.Errorf("copy %s: %w", repo, err)
+	}
+	return dst, nil
+}
+
+// symbolTargets returns an exported symbol from each package, taken from
+// the table of table and declared outside tests, for a spread of targets.
+func symbolTargets(ws *types.Workspace, table func(*types.SymbolTable) map[string]*types.Symbol) []target {
+	var targets []target
+	for _, pkg := range sortedPackages(ws) {
+		if pkg.Symbols == nil {
+			continue
+		}
+		for _, sym := range sortedSymbols(table(pkg.Symbols)) {
+			if sym.Exported && !strings.HasSuffix(sym.File, "_test.go") {
+				targets = append(targets, target{dir: relDir(ws, pkg.Path), name: sym.Name})
+				break
+			}
+		}
+	}
+	return targets
+}
+
+// methodTargets returns an exported method of a struct type from each
+// package.
+func methodTargets(ws *types.Workspace) []target {
+	var targets []target
+	for _, pkg := range sortedPackages(ws) {
+		if pkg.Symbols == nil {
+			continue
+		}
+	types:
+		for _, typeName := range slices.Sorted(maps.Keys(pkg.Symbols.Methods)) {
+			if typ := pkg.Symbols.Types[typeName]; typ == nil || typ.Kind != types.TypeSymbol {
+				continue
+			}
+			for _, m := range pkg.Symbols.Methods[typeName] {
+				if m.Exported && !strings.HasSuffix(m.File, "_test.go") {
+					targets = append(targets, target{dir: relDir(ws, pkg.Path), name: m.Name, typeName: typeName})
+					break types
+				}
+			}
+		}
+	}
+	return targets
+}
+
+// moveTargets moves an exported function of each library package to the
+// next library package.
+func moveTargets(ws *types.Workspace) []target {
+	libs := libraryPackages(ws)
+	if len(libs) < 2 {
+		return nil
+	}
+	var targets []target
+	for i, pkg := range libs {
+		for _, sym := range sortedSymbols(pkg.Symbols.Functions) {
+			if sym.Exported && !strings.HasSuffix(sym.File, "_test.go") {
+				to := libs[(i+1)%len(libs)]
+				targets = append(targets, target{dir: relDir(ws, pkg.Path), name: sym.Name, toDir: relDir(ws, to.Path)})
+				break
+			}
+		}
+	}
+	return targets
+}
+
+// libraryPackages returns the importable packages of ws.
+func libraryPackages(ws *types.Workspace) []*types.Package {
+	var libs []*types.Package
+	for _, pkg := range sortedPackages(ws) {
+		if pkg.Name != "main" && !strings.HasSuffix(pkg.Name, "_test") && pkg.Symbols != nil && len(pkg.Files) > 0 {
+			libs = append(libs, pkg)
+		}
+	}
+	return libs
+}
+
+func sortedPackages(ws *types.Workspace) []*types.Package {
+	pkgs := slices.Collect(maps.Values(ws.Packages))
+	slices.SortFunc(pkgs, func(a, b *types.Package) int { return cmp.Compare(a.Path, b.Path) })
+	return pkgs
+}
+
+func sortedSymbols(m map[string]*types.Symbol) []*types.Symbol {
+	syms := slices.Collect(maps.Values(m))
+	slices.SortFunc(syms, func(a, b *types.Symbol) int { return cmp.Compare(a.Name, b.Name) })
+	return syms
+}
+
+func relDir(ws *types.Workspace, dir string) string {
+	rel, err := filepath.Rel(ws.RootPath, dir)
+	if err != nil {
+		return dir
+	}
+	return filepath.ToSlash(rel)
+}
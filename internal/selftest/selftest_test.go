@@ -0,0 +1,102 @@
+package selftest_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mamaar/gorefactor/internal/selftest"
+)
+
+func writeCorpus(t *testing.T) string {
+	t.Helper()
+	corpus := t.TempDir()
+	files := map[string]string{
+		"calc/go.mod": "module example.com/calc\n\ngo 1.21\n",
+		"calc/calc/calc.go": `package calc
+
+type Calc struct{ total int }
+
+func (c *Calc) Sum(n int) int { c.total += n; return c.total }
+
+func Add(a, b int) int { return a + b }
+`,
+		"calc/calc/calc_test.go": `package calc
+
+import "testing"
+
+func TestAdd(t *testing.T) {
+	if Add(1, 2) != 3 {
+		t.Fatal("Add")
+	}
+	if (&Calc{}).Sum(2) != 2 {
+		t.Fatal("Sum")
+	}
+}
+`,
+		"calc/main.go": `package main
+
+import "example.com/calc/calc"
+
+func main() { _ = calc.Add(1, 2) }
+`,
+		"broken/go.mod":  "module example.com/broken\n\ngo 1.21\n",
+		"broken/main.go": "package main\n\nfunc main() { undefined() }\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(corpus, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return corpus
+}
+
+func TestRun(t *testing.T) {
+	corpus := writeCorpus(t)
+	report, err := selftest.Run(context.Background(), corpus, selftest.Options{
+		Operations: []string{"rename_symbol", "rename_type"},
+		Tests:      true,
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(report.Repos) != 2 {
+		t.Fatalf("expected 2 repos, got %+v", report.Repos)
+	}
+	broken, calc := report.Repos[0], report.Repos[1]
+	if broken.Skipped == "" || len(broken.Results) != 0 {
+		t.Errorf("expected the broken repo to be skipped, got %+v", broken)
+	}
+	if len(calc.Results) != 2 {
+		t.Fatalf("expected 2 results, got %+v", calc.Results)
+	}
+	for _, res := range calc.Results {
+		if res.Status != selftest.StatusPassed {
+			t.Errorf("%s %s: %s: %s", res.Operation, res.Target, res.Status, res.Error)
+		}
+	}
+	if report.Failed() {
+		t.Errorf("report failed: %+v", report.Operations)
+	}
+
+	// The corpus itself is never written.
+	content, err := os.ReadFile(filepath.Join(corpus, "calc/calc/calc.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "func Add(a, b int) int") {
+		t.Errorf("corpus was modified:\n%s", content)
+	}
+}
+
+func TestRun_UnknownOperation(t *testing.T) {
+	if _, err := selftest.Run(context.Background(), writeCorpus(t), selftest.Options{Operations: []string{"reformat"}}); err == nil {
+		t.Error("expected an error for an unknown operation")
+	}
+}
@@ -0,0 +1,139 @@
+package telemetry
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Outcomes of a tool call or plan execution, as the outcome label.
+const (
+	OutcomeOK        = "ok"
+	OutcomeToolError = "tool_error" // The tool returned an error result
+	OutcomeError     = "error"
+)
+
+// durationBuckets spans 10ms to about 80s, the range from a cached plan to
+// loading a large workspace.
+var durationBuckets = prometheus.ExponentialBuckets(0.01, 2, 14)
+
+var (
+	registry = prometheus.NewRegistry()
+
+	toolCalls = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "gorefactor",
+		Name:      "tool_calls_total",
+		Help:      "MCP tool calls by tool and outcome.",
+	}, []string{"tool", "outcome"})
+	toolDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "gorefactor",
+		Name:      "tool_call_duration_seconds",
+		Help:      "Duration of MCP tool calls.",
+		Buckets:   durationBuckets,
+	}, []string{"tool"})
+	workspaceLoads = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "gorefactor",
+		Name:      "workspace_load_duration_seconds",
+		Help:      "Duration of workspace loads by outcome.",
+		Buckets:   durationBuckets,
+	}, []string{"outcome"})
+	workspacePackages = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "gorefactor",
+		Name:      "workspace_packages",
+		Help:      "Packages of the workspace loaded last.",
+	})
+	indexBuilds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "gorefactor",
+		Name:      "reference_index_build_duration_seconds",
+		Help:      "Duration of reference index builds by outcome.",
+		Buckets:   durationBuckets,
+	}, []string{"outcome"})
+	planChanges = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "gorefactor",
+		Name:      "plan_changes",
+		Help:      "Changes of the plans made.",
+		Buckets:   prometheus.ExponentialBuckets(1, 4, 8),
+	})
+	planExecutions = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "gorefactor",
+		Name:      "plan_execution_duration_seconds",
+		Help:      "Duration of plan executions by outcome.",
+		Buckets:   durationBuckets,
+	}, []string{"outcome"})
+	apiRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "gorefactor",
+		Name:      "api_requests_total",
+		Help:      "Requests to the HTTP and gRPC APIs by API, method and status code.",
+	}, []string{"api", "method", "code"})
+	apiDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "gorefactor",
+		Name:      "api_request_duration_seconds",
+		Help:      "Duration of requests to the HTTP and gRPC APIs.",
+		Buckets:   durationBuckets,
+	}, []string{"api", "method"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		toolCalls, toolDuration, workspaceLoads, workspacePackages, indexBuilds,
+		planChanges, planExecutions, apiRequests, apiDuration,
+	)
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{Registry: registry})
+}
+
+// ObserveToolCall records an MCP tool call.
+func ObserveToolCall(tool, outcome string, d time.Duration) {
+	toolCalls.WithLabelValues(tool, outcome).Inc()
+	toolDuration.WithLabelValues(tool).Observe(d.Seconds())
+}
+
+// ObserveWorkspaceLoad records a workspace load and, when it succeeded, the
+// packages it loaded.
+func ObserveWorkspaceLoad(packages int, d time.Duration, err error) {
+	workspaceLoads.WithLabelValues(outcome(err)).Observe(d.Seconds())
+	if err == nil {
+		workspacePackages.Set(float64(packages))
+	}
+}
+
+// ObserveIndexBuild records a reference index build.
+func ObserveIndexBuild(d time.Duration, ok bool) {
+	result := OutcomeOK
+	if !ok {
+		result = OutcomeError
+	}
+	indexBuilds.WithLabelValues(result).Observe(d.Seconds())
+}
+
+// ObservePlan records the changes of a plan made.
+func ObservePlan(changes int) {
+	planChanges.Observe(float64(changes))
+}
+
+// ObservePlanExecution records a plan execution.
+func ObservePlanExecution(d time.Duration, err error) {
+	planExecutions.WithLabelValues(outcome(err)).Observe(d.Seconds())
+}
+
+// ObserveAPIRequest records a request to the HTTP or gRPC API; code is its
+// HTTP status or gRPC code.
+func ObserveAPIRequest(api, method, code string, d time.Duration) {
+	apiRequests.WithLabelValues(api, method, code).Inc()
+	apiDuration.WithLabelValues(api, method).Observe(d.Seconds())
+}
+
+func outcome(err error) string {
+	if err != nil {
+		return OutcomeError
+	}
+	return OutcomeOK
+}
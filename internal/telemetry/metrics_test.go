@@ -0,0 +1,31 @@
+package telemetry_test
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mamaar/gorefactor/internal/telemetry"
+)
+
+func TestHandler(t *testing.T) {
+	telemetry.ObserveToolCall("rename_symbol", telemetry.OutcomeToolError, 20*time.Millisecond)
+	telemetry.ObservePlanExecution(time.Second, errors.New("conflict"))
+
+	rec := httptest.NewRecorder()
+	telemetry.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+	for _, want := range []string{
+		`gorefactor_tool_calls_total{outcome="tool_error",tool="rename_symbol"} 1`,
+		`gorefactor_tool_call_duration_seconds_count{tool="rename_symbol"} 1`,
+		`gorefactor_plan_execution_duration_seconds_count{outcome="error"} 1`,
+		`go_goroutines`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("metrics lack %s:\n%s", want, body)
+		}
+	}
+}
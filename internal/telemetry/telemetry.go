@@ -0,0 +1,72 @@
+// Package telemetry traces the slow steps of serving refactorings with
+// OpenTelemetry and counts them in Prometheus metrics. Spans go to the
+// global tracer provider, which does nothing until SetupTracing installs an
+// exporter; metrics are kept in a registry of their own that servers expose
+// with Handler.
+package telemetry
+
+import (
+	"context"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Span names of the instrumented steps.
+const (
+	SpanLoadWorkspace       = "LoadWorkspace"
+	SpanBuildReferenceIndex = "BuildReferenceIndex"
+	SpanPlan                = "Plan"
+	SpanExecutePlan         = "ExecutePlan"
+)
+
+// Tracer returns the tracer of gorefactor's spans, from the tracer provider
+// installed last.
+func Tracer() trace.Tracer {
+	return otel.Tracer("github.com/mamaar/gorefactor")
+}
+
+// SetupTracing exports spans over OTLP/gRPC when an endpoint is configured
+// with the standard OTEL_EXPORTER_OTLP_ENDPOINT or
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT variables, which the exporter reads
+// along with the rest of its OTEL_* settings. Without one it installs
+// nothing. The returned function flushes the spans left and stops
+// exporting.
+func SetupTracing(ctx context.Context, version string) (shutdown func(context.Context) error, err error) {
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	exporter, err := otlptracegrpc.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the service.
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName("gorefactor"), semconv.ServiceVersion(version)),
+		resource.WithTelemetrySDK(),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown, nil
+}
+
+// End ends span, marking it failed when err isn't nil.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
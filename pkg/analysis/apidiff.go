@@ -0,0 +1,405 @@
+package analysis
+
+import (
+	"cmp"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	gotypes "go/types"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// APIChangeKind is what happened to a declaration between two versions of
+// a package's API.
+type APIChangeKind string
+
+const (
+	APIAdded   APIChangeKind = "added"
+	APIRemoved APIChangeKind = "removed"
+	APIChanged APIChangeKind = "changed"
+)
+
+// APIChange is a difference between two versions of a package's API.
+// Breaking changes can stop code using the old version from compiling.
+type APIChange struct {
+	Package  string        `json:"package"`
+	Symbol   string        `json:"symbol,omitempty"` // Type.Method for methods, empty for the package itself
+	Kind     APIChangeKind `json:"kind"`
+	Breaking bool          `json:"breaking"`
+	Reason   string        `json:"reason,omitempty"`
+	Old      string        `json:"old,omitempty"` // Signature in the old version
+	New      string        `json:"new,omitempty"` // Signature in the new version
+}
+
+// DiffAPI compares two versions of a set of packages' APIs, matched by
+// import path, and returns the changes sorted by package and symbol.
+// Additions are compatible and removals breaking. A changed declaration is
+// breaking unless only struct fields were added, a method's receiver went
+// from a pointer to a value, or an interface that can't be implemented
+// outside its package gained methods; parameter and receiver names don't
+// count. Variables are compared by declared type, so a change of an
+// initializer's type goes unnoticed.
+func DiffAPI(old, new []*PackageAPI) []APIChange {
+	byPath := func(apis []*PackageAPI) map[string]*PackageAPI {
+		m := make(map[string]*PackageAPI, len(apis))
+		for _, api := range apis {
+			m[api.Package] = api
+		}
+		return m
+	}
+	oldPkgs, newPkgs := byPath(old), byPath(new)
+
+	var changes []APIChange
+	for _, path := range slices.Sorted(maps.Keys(oldPkgs)) {
+		if newPkgs[path] == nil {
+			changes = append(changes, APIChange{Package: path, Kind: APIRemoved, Breaking: true, Reason: "package removed"})
+			continue
+		}
+		changes = append(changes, diffPackageAPI(oldPkgs[path], newPkgs[path])...)
+	}
+	for _, path := range slices.Sorted(maps.Keys(newPkgs)) {
+		if oldPkgs[path] == nil {
+			changes = append(changes, APIChange{Package: path, Kind: APIAdded, Reason: "package added"})
+		}
+	}
+	slices.SortStableFunc(changes, func(a, b APIChange) int {
+		return cmp.Or(cmp.Compare(a.Package, b.Package), cmp.Compare(a.Symbol, b.Symbol))
+	})
+	return changes
+}
+
+// diffPackageAPI compares the declarations of two versions of a package.
+func diffPackageAPI(old, new *PackageAPI) []APIChange {
+	var changes []APIChange
+	add := func(c APIChange) {
+		c.Package = new.Package
+		changes = append(changes, c)
+	}
+	diffSymbols(old.Functions, new.Functions, "", diffFunc, add)
+	diffSymbols(old.Constants, new.Constants, "", diffConst, add)
+	diffSymbols(old.Variables, new.Variables, "", diffVar, add)
+
+	oldTypes := make(map[string]*APIType)
+	for _, t := range old.Types {
+		oldTypes[t.Name] = t
+	}
+	for _, t := range new.Types {
+		o := oldTypes[t.Name]
+		if o == nil {
+			add(APIChange{Symbol: t.Name, Kind: APIAdded, New: t.Signature})
+			continue
+		}
+		delete(oldTypes, t.Name)
+		if breaking, reason := diffType(o, t); reason != "" {
+			add(APIChange{Symbol: t.Name, Kind: APIChanged, Breaking: breaking, Reason: reason, Old: o.Signature, New: t.Signature})
+		}
+		diffSymbols(o.Methods, t.Methods, t.Name+".", diffMethod, add)
+	}
+	for _, t := range oldTypes {
+		add(APIChange{Symbol: t.Name, Kind: APIRemoved, Breaking: true, Old: t.Signature})
+	}
+	return changes
+}
+
+// diffSymbols reports the symbols added to, removed from and changed
+// between old and new, naming them with prefix. diff compares two
+// signatures and returns a reason when they differ.
+func diffSymbols(old, new []*APISymbol, prefix string, diff func(old, new string) (bool, string), add func(APIChange)) {
+	oldSyms := make(map[string]*APISymbol)
+	for _, s := range old {
+		oldSyms[s.Name] = s
+	}
+	for _, s := range new {
+		o := oldSyms[s.Name]
+		if o == nil {
+			add(APIChange{Symbol: prefix + s.Name, Kind: APIAdded, New: s.Signature})
+			continue
+		}
+		delete(oldSyms, s.Name)
+		if breaking, reason := diff(o.Signature, s.Signature); reason != "" {
+			add(APIChange{Symbol: prefix + s.Name, Kind: APIChanged, Breaking: breaking, Reason: reason, Old: o.Signature, New: s.Signature})
+		}
+	}
+	for _, s := range oldSyms {
+		add(APIChange{Symbol: prefix + s.Name, Kind: APIRemoved, Breaking: true, Old: s.Signature})
+	}
+}
+
+// diffFunc compares two signatures of a function.
+func diffFunc(old, new string) (bool, string) {
+	o, n := parseAPIDecl[*ast.FuncDecl](old), parseAPIDecl[*ast.FuncDecl](new)
+	if o == nil || n == nil {
+		return diffText(old, new)
+	}
+	if funcShape(o.Type) != funcShape(n.Type) {
+		return true, "signature changed"
+	}
+	return false, ""
+}
+
+// diffMethod compares two signatures of a method. Moving a method from a
+// pointer to a value receiver adds it to the value's method set, which
+// breaks nothing; the reverse does.
+func diffMethod(old, new string) (bool, string) {
+	o, n := parseAPIDecl[*ast.FuncDecl](old), parseAPIDecl[*ast.FuncDecl](new)
+	if o == nil || n == nil || o.Recv == nil || n.Recv == nil || len(o.Recv.List) == 0 || len(n.Recv.List) == 0 {
+		return diffText(old, new)
+	}
+	if funcShape(o.Type) != funcShape(n.Type) {
+		return true, "signature changed"
+	}
+	_, oldPtr := o.Recv.List[0].Type.(*ast.StarExpr)
+	_, newPtr := n.Recv.List[0].Type.(*ast.StarExpr)
+	switch {
+	case oldPtr && !newPtr:
+		return false, "receiver changed from pointer to value"
+	case !oldPtr && newPtr:
+		return true, "receiver changed from value to pointer"
+	}
+	return false, ""
+}
+
+// diffConst compares two declarations of a constant. A new value can break
+// array lengths and switch cases, so it counts as breaking.
+func diffConst(old, new string) (bool, string) {
+	o, n := parseAPIValue(old), parseAPIValue(new)
+	if o == nil || n == nil {
+		return diffText(old, new)
+	}
+	if typ := exprString(o.Type); typ != exprString(n.Type) {
+		return true, fmt.Sprintf("type changed from %s to %s", typ, exprString(n.Type))
+	}
+	if val := exprsString(o.Values); val != exprsString(n.Values) {
+		return true, fmt.Sprintf("value changed from %s to %s", val, exprsString(n.Values))
+	}
+	return false, ""
+}
+
+// diffVar compares the declared types of two declarations of a variable.
+func diffVar(old, new string) (bool, string) {
+	o, n := parseAPIValue(old), parseAPIValue(new)
+	if o == nil || n == nil {
+		return diffText(old, new)
+	}
+	if o.Type == nil || n.Type == nil {
+		return false, ""
+	}
+	if typ := exprString(o.Type); typ != exprString(n.Type) {
+		return true, fmt.Sprintf("type changed from %s to %s", typ, exprString(n.Type))
+	}
+	return false, ""
+}
+
+// diffType compares two declarations of a type, without their methods.
+func diffType(old, new *APIType) (bool, string) {
+	o, n := parseAPITypeSpec(old.Signature), parseAPITypeSpec(new.Signature)
+	if o == nil || n == nil {
+		return diffText(old.Signature, new.Signature)
+	}
+	if old.Kind != new.Kind {
+		return true, fmt.Sprintf("changed from %s to %s", old.Kind, new.Kind)
+	}
+	if fieldListString(o.TypeParams) != fieldListString(n.TypeParams) {
+		return true, "type parameters changed"
+	}
+
+	ost, oStruct := o.Type.(*ast.StructType)
+	nst, nStruct := n.Type.(*ast.StructType)
+	oit, oIface := o.Type.(*ast.InterfaceType)
+	nit, nIface := n.Type.(*ast.InterfaceType)
+	switch {
+	case oStruct && nStruct:
+		added, removed, changed := diffMembers(structFields(ost), structFields(nst))
+		reasons := memberReasons("field", added, removed, changed)
+		return len(removed)+len(changed) > 0, strings.Join(reasons, "; ")
+	case oIface && nIface:
+		added, removed, changed := diffMembers(interfaceElems(oit), interfaceElems(nit))
+		reasons := memberReasons("method", added, removed, changed)
+		breaking := len(removed)+len(changed) > 0 || len(added) > 0 && !old.HasUnexported
+		if new.HasUnexported && !old.HasUnexported {
+			reasons = append(reasons, "unexported method added")
+			breaking = true
+		}
+		return breaking, strings.Join(reasons, "; ")
+	}
+	if typ := exprString(o.Type); typ != exprString(n.Type) {
+		return true, fmt.Sprintf("type changed from %s to %s", typ, exprString(n.Type))
+	}
+	return false, ""
+}
+
+// diffText compares signatures that could not be parsed as they are.
+func diffText(old, new string) (bool, string) {
+	if old == new {
+		return false, ""
+	}
+	return true, "declaration changed"
+}
+
+// diffMembers returns the names of the members of new that old lacks, the
+// members of old that new lacks and the members whose types differ.
+func diffMembers(old, new map[string]string) (added, removed, changed []string) {
+	for name, typ := range new {
+		o, ok := old[name]
+		switch {
+		case !ok:
+			added = append(added, name)
+		case o != typ:
+			changed = append(changed, name)
+		}
+	}
+	for name := range old {
+		if _, ok := new[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+	slices.Sort(added)
+	slices.Sort(removed)
+	slices.Sort(changed)
+	return added, removed, changed
+}
+
+// memberReasons describes the result of diffMembers.
+func memberReasons(member string, added, removed, changed []string) []string {
+	var reasons []string
+	for _, r := range []struct {
+		verb  string
+		names []string
+	}{{"removed", removed}, {"changed", changed}, {"added", added}} {
+		if len(r.names) > 0 {
+			reasons = append(reasons, fmt.Sprintf("%s %s %s", member, strings.Join(r.names, ", "), r.verb))
+		}
+	}
+	return reasons
+}
+
+// structFields returns the types of a struct's fields by name, embedded
+// fields by the name of their type.
+func structFields(s *ast.StructType) map[string]string {
+	fields := make(map[string]string)
+	for _, f := range s.Fields.List {
+		if len(f.Names) == 0 {
+			fields[embeddedName(f.Type)] = exprString(f.Type)
+		}
+		for _, name := range f.Names {
+			fields[name.Name] = exprString(f.Type)
+		}
+	}
+	return fields
+}
+
+// interfaceElems returns the signatures of an interface's methods by name
+// and its embedded elements by their text.
+func interfaceElems(i *ast.InterfaceType) map[string]string {
+	elems := make(map[string]string)
+	for _, f := range i.Methods.List {
+		if len(f.Names) == 0 {
+			elems[exprString(f.Type)] = ""
+			continue
+		}
+		if ft, ok := f.Type.(*ast.FuncType); ok {
+			elems[f.Names[0].Name] = funcShape(ft)
+		}
+	}
+	return elems
+}
+
+// embeddedName returns the field name of an embedded type.
+func embeddedName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return embeddedName(t.X)
+	case *ast.SelectorExpr:
+		return t.Sel.Name
+	case *ast.IndexExpr:
+		return embeddedName(t.X)
+	case *ast.IndexListExpr:
+		return embeddedName(t.X)
+	}
+	return exprString(expr)
+}
+
+// funcShape returns the text of a function type without parameter and
+// result names, which callers don't depend on.
+func funcShape(ft *ast.FuncType) string {
+	unnamed := func(fl *ast.FieldList) *ast.FieldList {
+		if fl == nil {
+			return nil
+		}
+		out := &ast.FieldList{}
+		for _, f := range fl.List {
+			for range max(len(f.Names), 1) {
+				out.List = append(out.List, &ast.Field{Type: f.Type})
+			}
+		}
+		return out
+	}
+	shape := &ast.FuncType{Params: unnamed(ft.Params), Results: unnamed(ft.Results)}
+	return fieldListString(ft.TypeParams) + exprString(shape)
+}
+
+// fieldListString returns the text of a type parameter list.
+func fieldListString(fl *ast.FieldList) string {
+	if fl == nil {
+		return ""
+	}
+	var parts []string
+	for _, f := range fl.List {
+		var names []string
+		for _, name := range f.Names {
+			names = append(names, name.Name)
+		}
+		parts = append(parts, strings.Join(names, ", ")+" "+exprString(f.Type))
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+func exprString(expr ast.Expr) string {
+	if expr == nil {
+		return ""
+	}
+	return gotypes.ExprString(expr)
+}
+
+func exprsString(exprs []ast.Expr) string {
+	var parts []string
+	for _, e := range exprs {
+		parts = append(parts, exprString(e))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// parseAPIDecl parses a signature of ExtractPackageAPI back into its
+// declaration, or returns nil if it is not a D.
+func parseAPIDecl[D ast.Decl](sig string) D {
+	var zero D
+	f, err := parser.ParseFile(token.NewFileSet(), "", "package p\n"+sig, parser.SkipObjectResolution)
+	if err != nil || len(f.Decls) != 1 {
+		return zero
+	}
+	d, _ := f.Decls[0].(D)
+	return d
+}
+
+// parseAPIValue parses the signature of a constant or variable.
+func parseAPIValue(sig string) *ast.ValueSpec {
+	d := parseAPIDecl[*ast.GenDecl](sig)
+	if d == nil || len(d.Specs) != 1 {
+		return nil
+	}
+	s, _ := d.Specs[0].(*ast.ValueSpec)
+	return s
+}
+
+// parseAPITypeSpec parses the signature of a type.
+func parseAPITypeSpec(sig string) *ast.TypeSpec {
+	d := parseAPIDecl[*ast.GenDecl](sig)
+	if d == nil || len(d.Specs) != 1 {
+		return nil
+	}
+	s, _ := d.Specs[0].(*ast.TypeSpec)
+	return s
+}
@@ -0,0 +1,139 @@
+package analysis
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// packageAPIOf parses a module holding src as package example.com/m/p and
+// returns its API.
+func packageAPIOf(t *testing.T, src string) *PackageAPI {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/m\n\ngo 1.21\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "p"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "p", "p.go"), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ws, err := NewParser(slog.New(slog.NewTextHandler(io.Discard, nil))).ParseWorkspace(dir)
+	if err != nil {
+		t.Fatalf("ParseWorkspace: %v", err)
+	}
+	return ExtractPackageAPI(ws, ws.Packages[filepath.Join(dir, "p")])
+}
+
+func TestDiffAPI(t *testing.T) {
+	old := packageAPIOf(t, `package p
+
+type Config struct {
+	Name string
+}
+
+type Store interface {
+	Get(key string) string
+}
+
+type Shape interface {
+	Area() float64
+	seal()
+}
+
+func (c Config) Valid() bool { return true }
+
+func (c *Config) Reset() {}
+
+func Load(path string) (*Config, error) { return nil, nil }
+
+func Remove(path string) error { return nil }
+
+func Rename(from, to string) error { return nil }
+
+const Limit = 10
+
+var Default *Config
+`)
+	new := packageAPIOf(t, `package p
+
+type Config struct {
+	Name    string
+	Timeout int
+}
+
+type Store interface {
+	Get(key string) string
+	Put(key, value string)
+}
+
+type Shape interface {
+	Area() float64
+	Perimeter() float64
+	seal()
+}
+
+func (cfg *Config) Valid() bool { return true }
+
+func (cfg Config) Reset() {}
+
+func Load(file string) (*Config, error) { return nil, nil }
+
+func Rename(from string, to string, force bool) error { return nil }
+
+func Open(path string) error { return nil }
+
+const Limit = 20
+
+var Default Config
+`)
+
+	want := map[string]struct {
+		kind     APIChangeKind
+		breaking bool
+	}{
+		"Config":       {APIChanged, false},
+		"Config.Valid": {APIChanged, true},
+		"Config.Reset": {APIChanged, false},
+		"Store":        {APIChanged, true},
+		"Shape":        {APIChanged, false},
+		"Remove":       {APIRemoved, true},
+		"Rename":       {APIChanged, true},
+		"Open":         {APIAdded, false},
+		"Limit":        {APIChanged, true},
+		"Default":      {APIChanged, true},
+	}
+	changes := DiffAPI([]*PackageAPI{old}, []*PackageAPI{new})
+	got := make(map[string]APIChange)
+	for _, c := range changes {
+		got[c.Symbol] = c
+	}
+	for symbol, w := range want {
+		c, ok := got[symbol]
+		if !ok {
+			t.Errorf("%s: no change reported", symbol)
+			continue
+		}
+		if c.Kind != w.kind || c.Breaking != w.breaking {
+			t.Errorf("%s: got %s (breaking %v, %s), want %s (breaking %v)", symbol, c.Kind, c.Breaking, c.Reason, w.kind, w.breaking)
+		}
+	}
+	if c, ok := got["Load"]; ok {
+		t.Errorf("renaming a parameter was reported: %+v", c)
+	}
+	if len(got) != len(want) {
+		t.Errorf("got %d changes, want %d: %+v", len(got), len(want), changes)
+	}
+	if got["Config"].Reason != "field Timeout added" {
+		t.Errorf("Config reason = %q", got["Config"].Reason)
+	}
+
+	removed := DiffAPI([]*PackageAPI{old}, nil)
+	if len(removed) != 1 || removed[0].Kind != APIRemoved || !removed[0].Breaking || removed[0].Symbol != "" {
+		t.Errorf("removing the package: %+v", removed)
+	}
+}
@@ -0,0 +1,283 @@
+package analysis
+
+import (
+	"bytes"
+	"cmp"
+	"go/ast"
+	"go/printer"
+	"go/token"
+	gotypes "go/types"
+	"maps"
+	"slices"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// PackageAPI is the exported surface of a package: the declarations other
+// packages can use, as go doc shows them.
+type PackageAPI struct {
+	Package   string       `json:"package"` // Import path
+	Name      string       `json:"name"`
+	Dir       string       `json:"dir"`
+	Doc       string       `json:"doc,omitempty"`
+	Functions []*APISymbol `json:"functions"`
+	Types     []*APIType   `json:"types"`
+	Constants []*APISymbol `json:"constants"`
+	Variables []*APISymbol `json:"variables"`
+}
+
+// APISymbol is an exported declaration. Signature is its source without
+// bodies, comments or unexported fields and methods.
+type APISymbol struct {
+	Name      string `json:"name"`
+	Signature string `json:"signature"`
+	Doc       string `json:"doc,omitempty"`
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+}
+
+// APIType is an exported type and its exported methods, on T and *T.
+type APIType struct {
+	APISymbol
+	Kind          string       `json:"kind"`                     // struct, interface, alias, or the kind of the underlying type
+	HasUnexported bool         `json:"has_unexported,omitempty"` // Fields or interface methods were left out
+	Methods       []*APISymbol `json:"methods"`
+}
+
+// ExtractPackageAPI returns the exported surface of pkg, read from the
+// syntax of its non-test files.
+func ExtractPackageAPI(ws *types.Workspace, pkg *types.Package) *PackageAPI {
+	api := &PackageAPI{
+		Package:   pkg.ImportPath,
+		Name:      pkg.Name,
+		Dir:       pkg.Dir,
+		Functions: []*APISymbol{},
+		Types:     []*APIType{},
+		Constants: []*APISymbol{},
+		Variables: []*APISymbol{},
+	}
+	methods := make(map[string][]*APISymbol) // By receiver type
+	for _, name := range slices.Sorted(maps.Keys(pkg.Files)) {
+		file := pkg.Files[name].AST
+		if file == nil {
+			continue
+		}
+		if file.Doc != nil && api.Doc == "" {
+			api.Doc = file.Doc.Text()
+		}
+		for _, decl := range file.Decls {
+			switch d := decl.(type) {
+			case *ast.FuncDecl:
+				if !d.Name.IsExported() {
+					continue
+				}
+				fn := *d
+				fn.Doc, fn.Body = nil, nil
+				sym := apiSymbol(ws.FileSet, d.Name, &fn, d.Doc)
+				if d.Recv == nil {
+					api.Functions = append(api.Functions, sym)
+				} else if recv := receiverBaseName(d.Recv); recv != "" {
+					methods[recv] = append(methods[recv], sym)
+				}
+			case *ast.GenDecl:
+				api.addGenDecl(ws.FileSet, d)
+			}
+		}
+	}
+
+	for _, t := range api.Types {
+		t.Methods = methods[t.Name]
+		if t.Methods == nil {
+			t.Methods = []*APISymbol{}
+		}
+		sortAPISymbols(t.Methods)
+	}
+	sortAPISymbols(api.Functions)
+	sortAPISymbols(api.Constants)
+	sortAPISymbols(api.Variables)
+	slices.SortFunc(api.Types, func(a, b *APIType) int { return cmp.Compare(a.Name, b.Name) })
+	return api
+}
+
+// addGenDecl adds the exported types, constants and variables of d.
+func (api *PackageAPI) addGenDecl(fset *token.FileSet, d *ast.GenDecl) {
+	for _, spec := range d.Specs {
+		// A lone spec is documented by its declaration's comment.
+		doc := d.Doc
+		switch s := spec.(type) {
+		case *ast.TypeSpec:
+			if !s.Name.IsExported() {
+				continue
+			}
+			if s.Doc != nil || len(d.Specs) > 1 {
+				doc = s.Doc
+			}
+			ts := *s
+			ts.Doc, ts.Comment = nil, nil
+			var unexported bool
+			ts.Type, unexported = exportedType(s.Type)
+			api.Types = append(api.Types, &APIType{
+				APISymbol:     *apiSymbol(fset, s.Name, &ast.GenDecl{Tok: token.TYPE, Specs: []ast.Spec{&ts}}, doc),
+				Kind:          typeSpecKind(s),
+				HasUnexported: unexported,
+			})
+		case *ast.ValueSpec:
+			if s.Doc != nil || len(d.Specs) > 1 {
+				doc = s.Doc
+			}
+			for i, name := range s.Names {
+				if !name.IsExported() {
+					continue
+				}
+				vs := &ast.ValueSpec{Names: []*ast.Ident{name}, Type: s.Type}
+				if i < len(s.Values) && len(s.Values) == len(s.Names) {
+					vs.Values = []ast.Expr{s.Values[i]}
+				}
+				sym := apiSymbol(fset, name, &ast.GenDecl{Tok: d.Tok, Specs: []ast.Spec{vs}}, doc)
+				if d.Tok == token.CONST {
+					api.Constants = append(api.Constants, sym)
+				} else {
+					api.Variables = append(api.Variables, sym)
+				}
+			}
+		}
+	}
+}
+
+// apiSymbol describes the declaration node of name.
+func apiSymbol(fset *token.FileSet, name *ast.Ident, node ast.Node, doc *ast.CommentGroup) *APISymbol {
+	var buf bytes.Buffer
+	_ = printer.Fprint(&buf, fset, node)
+	pos := fset.Position(name.Pos())
+	return &APISymbol{
+		Name:      name.Name,
+		Signature: buf.String(),
+		Doc:       doc.Text(),
+		File:      pos.Filename,
+		Line:      pos.Line,
+	}
+}
+
+// exportedType returns expr without the unexported fields of a struct or
+// the unexported methods of an interface, and whether any were dropped.
+func exportedType(expr ast.Expr) (ast.Expr, bool) {
+	var fields *ast.FieldList
+	switch t := expr.(type) {
+	case *ast.StructType:
+		fields = t.Fields
+	case *ast.InterfaceType:
+		fields = t.Methods
+	default:
+		return expr, false
+	}
+	if fields == nil {
+		return expr, false
+	}
+
+	kept := &ast.FieldList{Opening: fields.Opening, Closing: fields.Closing}
+	dropped := false
+	for _, field := range fields.List {
+		f := *field
+		f.Doc, f.Comment, f.Names = nil, nil, nil
+		for _, name := range field.Names {
+			if name.IsExported() {
+				f.Names = append(f.Names, name)
+			}
+		}
+		switch {
+		case len(field.Names) == 0 && !embeddedExported(field.Type):
+			dropped = true
+		case len(field.Names) > 0 && len(f.Names) < len(field.Names):
+			dropped = true
+			if len(f.Names) == 0 {
+				continue
+			}
+			kept.List = append(kept.List, &f)
+		default:
+			kept.List = append(kept.List, &f)
+		}
+	}
+	switch t := expr.(type) {
+	case *ast.StructType:
+		s := *t
+		s.Fields = kept
+		return &s, dropped
+	case *ast.InterfaceType:
+		i := *t
+		i.Methods = kept
+		return &i, dropped
+	}
+	return expr, dropped
+}
+
+// embeddedExported reports whether an embedded field or interface element
+// is visible outside the package: exported and predeclared types, types
+// from other packages and constraint unions are.
+func embeddedExported(expr ast.Expr) bool {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return t.IsExported() || gotypes.Universe.Lookup(t.Name) != nil
+	case *ast.StarExpr:
+		return embeddedExported(t.X)
+	case *ast.IndexExpr:
+		return embeddedExported(t.X)
+	case *ast.IndexListExpr:
+		return embeddedExported(t.X)
+	}
+	return true
+}
+
+// typeSpecKind names the kind of a declared type.
+func typeSpecKind(s *ast.TypeSpec) string {
+	if s.Assign.IsValid() {
+		return "alias"
+	}
+	switch t := s.Type.(type) {
+	case *ast.StructType:
+		return "struct"
+	case *ast.InterfaceType:
+		return "interface"
+	case *ast.FuncType:
+		return "func"
+	case *ast.MapType:
+		return "map"
+	case *ast.ArrayType:
+		if t.Len == nil {
+			return "slice"
+		}
+		return "array"
+	case *ast.ChanType:
+		return "chan"
+	case *ast.StarExpr:
+		return "pointer"
+	}
+	return "named"
+}
+
+// receiverBaseName returns the name of the type a method is declared on.
+func receiverBaseName(recv *ast.FieldList) string {
+	if len(recv.List) == 0 {
+		return ""
+	}
+	expr := recv.List[0].Type
+	for {
+		switch t := expr.(type) {
+		case *ast.StarExpr:
+			expr = t.X
+		case *ast.IndexExpr:
+			expr = t.X
+		case *ast.IndexListExpr:
+			expr = t.X
+		case *ast.ParenExpr:
+			expr = t.X
+		case *ast.Ident:
+			return t.Name
+		default:
+			return ""
+		}
+	}
+}
+
+func sortAPISymbols(syms []*APISymbol) {
+	slices.SortFunc(syms, func(a, b *APISymbol) int { return cmp.Compare(a.Name, b.Name) })
+}
@@ -0,0 +1,90 @@
+package analysis
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExtractPackageAPI(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/shapes\n\ngo 1.21\n",
+		"store/store.go": `// Package store keeps values.
+package store
+
+// Store holds values by key.
+type Store struct {
+	Name  string
+	items map[string]string
+}
+
+// New returns an empty store.
+func New(name string) *Store { return &Store{Name: name} }
+
+// Get returns the value of key.
+func (s *Store) Get(key string) string { return s.items[key] }
+
+func (s *Store) grow() {}
+
+const (
+	// MaxKeys bounds a store.
+	MaxKeys = 100
+	minKeys = 1
+)
+
+var Default, other = New("default"), New("other")
+
+type Getter interface {
+	Get(key string) string
+	reset()
+}
+
+type helper struct{}
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ws, err := NewParser(slog.New(slog.NewTextHandler(io.Discard, nil))).ParseWorkspace(dir)
+	if err != nil {
+		t.Fatalf("ParseWorkspace: %v", err)
+	}
+
+	api := ExtractPackageAPI(ws, ws.Packages[filepath.Join(dir, "store")])
+	if api.Package != "example.com/shapes/store" || api.Doc != "Package store keeps values.\n" {
+		t.Errorf("package = %q, doc = %q", api.Package, api.Doc)
+	}
+	if len(api.Functions) != 1 || api.Functions[0].Signature != "func New(name string) *Store" || api.Functions[0].Doc != "New returns an empty store.\n" {
+		t.Errorf("functions = %+v", api.Functions)
+	}
+	if len(api.Constants) != 1 || api.Constants[0].Signature != "const MaxKeys = 100" || api.Constants[0].Doc == "" {
+		t.Errorf("constants = %+v", api.Constants)
+	}
+	if len(api.Variables) != 1 || api.Variables[0].Signature != `var Default = New("default")` {
+		t.Errorf("variables = %+v", api.Variables)
+	}
+
+	if len(api.Types) != 2 {
+		t.Fatalf("types = %+v", api.Types)
+	}
+	getter, store := api.Types[0], api.Types[1]
+	if getter.Kind != "interface" || !getter.HasUnexported || strings.Contains(getter.Signature, "reset") {
+		t.Errorf("Getter = %+v", getter)
+	}
+	if store.Kind != "struct" || !store.HasUnexported || strings.Contains(store.Signature, "items") || !strings.Contains(store.Signature, "Name string") {
+		t.Errorf("Store = %+v", store)
+	}
+	if len(store.Methods) != 1 || store.Methods[0].Signature != "func (s *Store) Get(key string) string" {
+		t.Errorf("Store methods = %+v", store.Methods)
+	}
+}
@@ -0,0 +1,263 @@
+package analysis
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"maps"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// ArchViolation is an import that breaks an architecture rule.
+type ArchViolation struct {
+	Package string     `json:"package"` // Directory of the importing package, relative to the workspace root
+	Import  string     `json:"import"`  // Import path of the offending import
+	File    string     `json:"file"`
+	Line    int        `json:"line"`
+	Source  string     `json:"source"` // The import line as written
+	Rule    string     `json:"rule"`   // Package pattern of the broken rule
+	Message string     `json:"message"`
+	Reason  string     `json:"reason,omitempty"`
+	Fixes   []*ArchFix `json:"fixes,omitempty"`
+}
+
+// ArchFix is a move_symbol call that removes a use of an offending import
+// by moving the used symbol into the importing package.
+type ArchFix struct {
+	Symbol      string `json:"symbol"`
+	FromPackage string `json:"from_package"` // Directories relative to the workspace root
+	ToPackage   string `json:"to_package"`
+}
+
+// ValidateImportRule reports a rule without a package, without imports to
+// check, or with a malformed pattern.
+func ValidateImportRule(r types.ImportRule) error {
+	if r.Package == "" || filepath.IsAbs(r.Package) {
+		return fmt.Errorf("rule package must be relative to the workspace root, got %q", r.Package)
+	}
+	if len(r.Allow) == 0 && len(r.Forbid) == 0 {
+		return fmt.Errorf("rule for %s needs allow or forbid patterns", r.Package)
+	}
+	for _, p := range slices.Concat([]string{r.Package}, r.Allow, r.Forbid) {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("rule for %s: bad pattern %q", r.Package, p)
+		}
+	}
+	return nil
+}
+
+// matchImportPattern reports whether pattern matches name, a slash
+// separated directory or import path. Each path element of the pattern is
+// matched with path.Match; a trailing "/..." also matches every path below.
+func matchImportPattern(pattern, name string) bool {
+	pattern = strings.TrimSuffix(filepath.ToSlash(pattern), "/")
+	base, subtree := strings.CutSuffix(pattern, "/...")
+	if pattern == "..." {
+		base, subtree = ".", true
+	}
+	base = path.Clean(base)
+	if base == "." {
+		return subtree || name == "."
+	}
+	patterns, names := strings.Split(base, "/"), strings.Split(name, "/")
+	if len(names) < len(patterns) || !subtree && len(names) != len(patterns) {
+		return false
+	}
+	for i, p := range patterns {
+		if ok, _ := path.Match(p, names[i]); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckImportRules returns the imports in the non-test files of the
+// workspace packages that break rules, ordered by file and line. An import
+// of a workspace package comes with a move_symbol fix for every symbol of
+// it the file uses, unless that package uses the symbol too, as moving it
+// would then have the package import the importer.
+func CheckImportRules(ws *types.Workspace, rules []types.ImportRule) ([]*ArchViolation, error) {
+	for _, r := range rules {
+		if err := ValidateImportRule(r); err != nil {
+			return nil, err
+		}
+	}
+
+	violations := []*ArchViolation{}
+	for _, dir := range slices.Sorted(maps.Keys(ws.Packages)) {
+		pkg := ws.Packages[dir]
+		rel := workspaceRel(ws, pkg.Path)
+		var applied []types.ImportRule
+		for _, r := range rules {
+			if matchImportPattern(r.Package, rel) {
+				applied = append(applied, r)
+			}
+		}
+		if len(applied) == 0 {
+			continue
+		}
+
+		for _, name := range slices.Sorted(maps.Keys(pkg.Files)) {
+			file := pkg.Files[name]
+			if file.AST == nil {
+				continue
+			}
+			for _, spec := range file.AST.Imports {
+				importPath, err := strconv.Unquote(spec.Path.Value)
+				if err != nil {
+					continue
+				}
+				names := []string{importPath}
+				target := ws.Packages[ws.ImportToPath[importPath]]
+				if target != nil {
+					names = append(names, workspaceRel(ws, target.Path))
+				}
+				for _, r := range applied {
+					broken := brokenRule(r, names, target != nil)
+					if broken == "" {
+						continue
+					}
+					pos := ws.FileSet.Position(spec.Pos())
+					v := &ArchViolation{
+						Package: rel,
+						Import:  importPath,
+						File:    file.Path,
+						Line:    pos.Line,
+						Source:  sourceLine(file.OriginalContent, pos.Line),
+						Rule:    r.Package,
+						Message: fmt.Sprintf("%s imports %s, but %s %s", rel, names[len(names)-1], r.Package, broken),
+						Reason:  r.Reason,
+					}
+					if target != nil {
+						v.Fixes = archFixes(file.AST, spec, target, rel, workspaceRel(ws, target.Path))
+					}
+					violations = append(violations, v)
+				}
+			}
+		}
+	}
+	return violations, nil
+}
+
+// brokenRule returns how an import, known by names, breaks r, or "" when
+// it doesn't. Allow only constrains imports of workspace packages.
+func brokenRule(r types.ImportRule, names []string, workspace bool) string {
+	matches := func(pattern string) bool {
+		return slices.ContainsFunc(names, func(name string) bool { return matchImportPattern(pattern, name) })
+	}
+	for _, p := range r.Forbid {
+		if matches(p) {
+			return "must not import " + p
+		}
+	}
+	if workspace && len(r.Allow) > 0 && !slices.ContainsFunc(r.Allow, matches) {
+		return "may only import " + strings.Join(r.Allow, ", ")
+	}
+	return ""
+}
+
+// archFixes returns a move of each top-level symbol of target that file
+// uses through spec into the package in dir, skipping the symbols target
+// uses itself.
+func archFixes(file *ast.File, spec *ast.ImportSpec, target *types.Package, dir, targetDir string) []*ArchFix {
+	local := target.Name
+	if spec.Name != nil {
+		local = spec.Name.Name
+	}
+	if local == "_" || local == "." {
+		return nil
+	}
+	used := make(map[string]bool)
+	ast.Inspect(file, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if id, ok := sel.X.(*ast.Ident); ok && id.Name == local {
+				used[sel.Sel.Name] = true
+			}
+		}
+		return true
+	})
+
+	var fixes []*ArchFix
+	for _, symbol := range slices.Sorted(maps.Keys(used)) {
+		if declaredAt(target, symbol) && !usedWithin(target, symbol) {
+			fixes = append(fixes, &ArchFix{Symbol: symbol, FromPackage: targetDir, ToPackage: dir})
+		}
+	}
+	return fixes
+}
+
+// declaredAt reports whether pkg declares name at package level.
+func declaredAt(pkg *types.Package, name string) bool {
+	for _, file := range pkg.Files {
+		if file.AST == nil {
+			continue
+		}
+		for _, decl := range file.AST.Decls {
+			switch d := decl.(type) {
+			case *ast.FuncDecl:
+				if d.Recv == nil && d.Name.Name == name {
+					return true
+				}
+			case *ast.GenDecl:
+				for _, spec := range d.Specs {
+					switch s := spec.(type) {
+					case *ast.TypeSpec:
+						if s.Name.Name == name {
+							return true
+						}
+					case *ast.ValueSpec:
+						for _, id := range s.Names {
+							if id.Name == name {
+								return true
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+// usedWithin reports whether an identifier named name, other than the one
+// declaring it, appears in the files of pkg. Fields and methods sharing the
+// name count as uses, which errs on the side of suggesting no move.
+func usedWithin(pkg *types.Package, name string) bool {
+	count := 0
+	for _, file := range pkg.Files {
+		if file.AST == nil {
+			continue
+		}
+		ast.Inspect(file.AST, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok && id.Name == name {
+				count++
+			}
+			return true
+		})
+	}
+	return count > 1
+}
+
+// workspaceRel returns dir relative to the workspace root, slash separated.
+func workspaceRel(ws *types.Workspace, dir string) string {
+	rel, err := filepath.Rel(ws.RootPath, dir)
+	if err != nil {
+		return filepath.ToSlash(dir)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// sourceLine returns the 1-based line of src without surrounding blanks.
+func sourceLine(src []byte, line int) string {
+	lines := bytes.Split(src, []byte("\n"))
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+	return string(bytes.TrimSpace(lines[line-1]))
+}
@@ -0,0 +1,87 @@
+package analysis
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+func TestMatchImportPattern(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"internal/domain", "internal/domain", true},
+		{"internal/domain", "internal/domain/order", false},
+		{"internal/domain/...", "internal/domain/order", true},
+		{"internal/*/http", "internal/api/http", true},
+		{"internal/*", "internalx/api", false},
+		{"./...", "cmd/tool", true},
+		{".", ".", true},
+		{"github.com/lib/pq", "github.com/lib/pq", true},
+	}
+	for _, tt := range tests {
+		if got := matchImportPattern(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("%q matches %q = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckImportRules(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":                        "module example.com/arch\n\ngo 1.21\n",
+		"internal/http/status.go":       "package http\n\nfunc StatusFor(code int) int { return code }\n\nfunc Serve() { helper() }\n\nfunc helper() {}\n",
+		"internal/domain/order.go":      "package domain\n\nimport (\n\t\"database/sql\"\n\n\tweb \"example.com/arch/internal/http\"\n)\n\nvar _ *sql.DB\n\nfunc Code() int { return web.StatusFor(1) }\n",
+		"internal/domain/order_test.go": "package domain\n\nimport \"example.com/arch/internal/http\"\n\nvar _ = http.Serve\n",
+		"internal/store/store.go":       "package store\n\nimport \"example.com/arch/internal/domain\"\n\nvar _ = domain.Code\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ws, err := NewParser(slog.New(slog.NewTextHandler(io.Discard, nil))).ParseWorkspace(dir)
+	if err != nil {
+		t.Fatalf("ParseWorkspace: %v", err)
+	}
+
+	violations, err := CheckImportRules(ws, []types.ImportRule{
+		{Package: "internal/domain/...", Forbid: []string{"internal/http/...", "database/*"}, Reason: "the domain is transport agnostic"},
+		{Package: "internal/store", Allow: []string{"internal/domain"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(violations) != 2 {
+		t.Fatalf("violations = %+v", violations)
+	}
+	sqlImport, httpImport := violations[0], violations[1]
+	if sqlImport.Import != "database/sql" || sqlImport.Line != 4 || sqlImport.Fixes != nil {
+		t.Errorf("sql violation = %+v", sqlImport)
+	}
+	if httpImport.Source != `web "example.com/arch/internal/http"` || httpImport.Line != 6 {
+		t.Errorf("http violation = %+v", httpImport)
+	}
+	if want := "internal/domain imports internal/http, but internal/domain/... must not import internal/http/..."; httpImport.Message != want {
+		t.Errorf("message = %q, want %q", httpImport.Message, want)
+	}
+	if httpImport.Reason != "the domain is transport agnostic" {
+		t.Errorf("reason = %q", httpImport.Reason)
+	}
+	if len(httpImport.Fixes) != 1 || *httpImport.Fixes[0] != (ArchFix{Symbol: "StatusFor", FromPackage: "internal/http", ToPackage: "internal/domain"}) {
+		t.Errorf("fixes = %+v", httpImport.Fixes)
+	}
+
+	if _, err := CheckImportRules(ws, []types.ImportRule{{Package: "internal/domain"}}); err == nil {
+		t.Error("expected an error for a rule without allow or forbid")
+	}
+}
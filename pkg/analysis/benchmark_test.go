@@ -9,6 +9,8 @@ import (
 	gotypes "go/types"
 	"io"
 	"log/slog"
+	"os"
+	"path/filepath"
 	"runtime"
 	"testing"
 
@@ -809,3 +811,66 @@ func BenchmarkMemoryUsage_TypeIndex(b *testing.B) {
 		})
 	}
 }
+
+// writeBenchmarkModule writes a synthetic module with numPackages packages to a
+// temporary directory. Each package imports its predecessor so that loading
+// exercises cross-package type checking.
+func writeBenchmarkModule(tb testing.TB, numPackages int) string {
+	tb.Helper()
+	root := tb.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "go.mod"), []byte("module example.com/bench\n\ngo 1.22\n"), 0o644); err != nil {
+		tb.Fatal(err)
+	}
+
+	for pkgNum := range numPackages {
+		var imports, uses string
+		if pkgNum > 0 {
+			imports = fmt.Sprintf("import \"example.com/bench/pkg%d\"\n", pkgNum-1)
+			uses = fmt.Sprintf("\t_ = pkg%d.Function%d()\n", pkgNum-1, pkgNum-1)
+		}
+		src := fmt.Sprintf(`package pkg%d
+
+%s
+type MyStruct%d struct {
+	Field1 string
+	Field2 int
+}
+
+func (s *MyStruct%d) Method1() string { return s.Field1 }
+
+func Function%d() string { return "hello" }
+
+func Caller%d() {
+	s := &MyStruct%d{}
+	_ = s.Method1()
+	_ = Function%d()
+%s}
+`, pkgNum, imports, pkgNum, pkgNum, pkgNum, pkgNum, pkgNum, pkgNum, uses)
+
+		dir := filepath.Join(root, fmt.Sprintf("pkg%d", pkgNum))
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			tb.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("pkg%d.go", pkgNum)), []byte(src), 0o644); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	return root
+}
+
+func BenchmarkParseWorkspace(b *testing.B) {
+	sizes := []int{10, 50, 100}
+	for _, size := range sizes {
+		b.Run(fmt.Sprintf("%d_pkgs", size), func(b *testing.B) {
+			root := writeBenchmarkModule(b, size)
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			b.ResetTimer()
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := NewParser(logger).ParseWorkspace(root); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,299 @@
+package analysis
+
+import (
+	"cmp"
+	"fmt"
+	"go/ast"
+	gotypes "go/types"
+	"io"
+	"log/slog"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/types/typeutil"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// CallGraph is the static call graph of the workspace: which functions and
+// methods call which. Only calls between workspace declarations are kept.
+type CallGraph struct {
+	Nodes []*CallNode `json:"nodes"`
+	Edges []*CallEdge `json:"edges"`
+}
+
+// CallNode is a function or method declared in the workspace.
+type CallNode struct {
+	ID        string `json:"id"` // Import path, then the name; Type.Method for methods
+	Name      string `json:"name"`
+	Package   string `json:"package"`
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	Interface bool   `json:"interface,omitempty"` // An interface method, dispatched to its implementations
+}
+
+// CallEdge counts the call sites in Caller that call Callee.
+type CallEdge struct {
+	Caller  string `json:"caller"`
+	Callee  string `json:"callee"`
+	Count   int    `json:"count"`
+	Dynamic bool   `json:"dynamic,omitempty"` // Through an interface method, to a workspace type implementing it
+}
+
+// CallGraphAnalyzer builds the call graph of a workspace from the type
+// information of its packages.
+type CallGraphAnalyzer struct {
+	workspace *types.Workspace
+	logger    *slog.Logger
+}
+
+func NewCallGraphAnalyzer(ws *types.Workspace, logger *slog.Logger) *CallGraphAnalyzer {
+	return &CallGraphAnalyzer{
+		workspace: ws,
+		logger:    logger,
+	}
+}
+
+// BuildCallGraph returns the call graph of the type-checked packages of the
+// workspace; packages without type information are left out, and test files
+// aren't read. Calls from function literals count as calls from the
+// declaration holding them. A call through an interface method is an edge
+// to the interface method plus a dynamic edge to each workspace type
+// implementing it.
+func (ca *CallGraphAnalyzer) BuildCallGraph() *CallGraph {
+	b := &callGraphBuilder{
+		ws:     ca.workspace,
+		nodes:  make(map[string]*CallNode),
+		counts: make(map[callEdgeKey]int),
+		impls:  make(map[*gotypes.Func][]*gotypes.Func),
+	}
+	for _, dir := range slices.Sorted(maps.Keys(ca.workspace.Packages)) {
+		pkg := ca.workspace.Packages[dir]
+		if pkg.TypesInfo == nil {
+			ca.logger.Debug("call graph: package not type-checked", "package", dir)
+			continue
+		}
+		for _, file := range pkg.Files {
+			if file.AST != nil {
+				b.addFile(pkg.TypesInfo, file.AST)
+			}
+		}
+	}
+
+	g := &CallGraph{Nodes: []*CallNode{}, Edges: []*CallEdge{}}
+	for _, id := range slices.Sorted(maps.Keys(b.nodes)) {
+		g.Nodes = append(g.Nodes, b.nodes[id])
+	}
+	for key, count := range b.counts {
+		g.Edges = append(g.Edges, &CallEdge{Caller: key.caller, Callee: key.callee, Count: count, Dynamic: key.dynamic})
+	}
+	g.sortEdges()
+	return g
+}
+
+type callEdgeKey struct {
+	caller, callee string
+	dynamic        bool
+}
+
+type callGraphBuilder struct {
+	ws     *types.Workspace
+	nodes  map[string]*CallNode              // By ID
+	counts map[callEdgeKey]int               // Call sites by edge
+	impls  map[*gotypes.Func][]*gotypes.Func // Implementations by interface method
+}
+
+// addFile adds the calls made by the function declarations of file.
+func (b *callGraphBuilder) addFile(info *gotypes.Info, file *ast.File) {
+	for _, decl := range file.Decls {
+		fd, ok := decl.(*ast.FuncDecl)
+		if !ok || fd.Body == nil {
+			continue
+		}
+		fn, _ := info.Defs[fd.Name].(*gotypes.Func)
+		caller := b.node(fn)
+		if caller == nil {
+			continue
+		}
+		ast.Inspect(fd.Body, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			callee, _ := typeutil.Callee(info, call).(*gotypes.Func)
+			if callee == nil {
+				return true
+			}
+			callee = callee.Origin()
+			to := b.node(callee)
+			if to == nil {
+				return true
+			}
+			b.counts[callEdgeKey{caller.ID, to.ID, false}]++
+			if to.Interface {
+				for _, impl := range b.implementations(callee) {
+					if n := b.node(impl); n != nil {
+						b.counts[callEdgeKey{caller.ID, n.ID, true}]++
+					}
+				}
+			}
+			return true
+		})
+	}
+}
+
+// node returns the node of fn, adding it if new, or nil when fn isn't
+// declared in the workspace.
+func (b *callGraphBuilder) node(fn *gotypes.Func) *CallNode {
+	if fn == nil || fn.Pkg() == nil {
+		return nil
+	}
+	if _, ok := b.ws.ImportToPath[fn.Pkg().Path()]; !ok {
+		return nil
+	}
+	name, iface := fn.Name(), false
+	if recv := fn.Signature().Recv(); recv != nil {
+		named := namedOf(recv.Type())
+		if named == nil {
+			return nil // Method of an interface literal
+		}
+		name, iface = named.Obj().Name()+"."+name, gotypes.IsInterface(named)
+	}
+	id := fn.Pkg().Path() + "." + name
+	if n := b.nodes[id]; n != nil {
+		return n
+	}
+	position := b.ws.FileSet.Position(fn.Pos())
+	n := &CallNode{
+		ID:        id,
+		Name:      name,
+		Package:   fn.Pkg().Path(),
+		File:      position.Filename,
+		Line:      position.Line,
+		Interface: iface,
+	}
+	b.nodes[id] = n
+	return n
+}
+
+// implementations returns the methods of the workspace types, or of their
+// pointers, that implement the interface declaring m.
+func (b *callGraphBuilder) implementations(m *gotypes.Func) []*gotypes.Func {
+	if impls, ok := b.impls[m]; ok {
+		return impls
+	}
+	var impls []*gotypes.Func
+	iface, _ := namedOf(m.Signature().Recv().Type()).Underlying().(*gotypes.Interface)
+	for _, named := range workspaceNamed(b.ws) {
+		if gotypes.IsInterface(named) || named.TypeParams().Len() > 0 {
+			continue
+		}
+		var typ gotypes.Type = named
+		if !gotypes.Implements(typ, iface) {
+			if typ = gotypes.NewPointer(named); !gotypes.Implements(typ, iface) {
+				continue
+			}
+		}
+		obj, _, _ := gotypes.LookupFieldOrMethod(typ, true, m.Pkg(), m.Name())
+		if fn, ok := obj.(*gotypes.Func); ok {
+			impls = append(impls, fn.Origin())
+		}
+	}
+	b.impls[m] = impls
+	return impls
+}
+
+// Reachable returns the part of g reachable from the nodes root matches,
+// following at most depth calls from them; all calls when depth <= 0.
+func (g *CallGraph) Reachable(root func(*CallNode) bool, depth int) *CallGraph {
+	out := make(map[string][]*CallEdge)
+	for _, e := range g.Edges {
+		out[e.Caller] = append(out[e.Caller], e)
+	}
+	seen := make(map[string]bool)
+	var frontier []string
+	for _, n := range g.Nodes {
+		if root(n) {
+			seen[n.ID] = true
+			frontier = append(frontier, n.ID)
+		}
+	}
+	sub := &CallGraph{Nodes: []*CallNode{}, Edges: []*CallEdge{}}
+	for step := 0; len(frontier) > 0 && (depth <= 0 || step < depth); step++ {
+		var next []string
+		for _, id := range frontier {
+			for _, e := range out[id] {
+				sub.Edges = append(sub.Edges, e)
+				if !seen[e.Callee] {
+					seen[e.Callee] = true
+					next = append(next, e.Callee)
+				}
+			}
+		}
+		frontier = next
+	}
+	for _, n := range g.Nodes {
+		if seen[n.ID] {
+			sub.Nodes = append(sub.Nodes, n)
+		}
+	}
+	sub.sortEdges()
+	return sub
+}
+
+// WriteDOT writes g in the Graphviz DOT language, with a cluster per
+// package. Interface methods are boxes and dynamic calls dashed.
+func (g *CallGraph) WriteDOT(w io.Writer) error {
+	var sb strings.Builder
+	sb.WriteString("digraph callgraph {\n\trankdir=LR;\n")
+	byPackage := make(map[string][]*CallNode)
+	for _, n := range g.Nodes {
+		byPackage[n.Package] = append(byPackage[n.Package], n)
+	}
+	for i, pkg := range slices.Sorted(maps.Keys(byPackage)) {
+		fmt.Fprintf(&sb, "\tsubgraph cluster_%d {\n\t\tlabel=%s;\n", i, strconv.Quote(pkg))
+		for _, n := range byPackage[pkg] {
+			shape := "ellipse"
+			if n.Interface {
+				shape = "box"
+			}
+			fmt.Fprintf(&sb, "\t\t%s [label=%s, shape=%s];\n", strconv.Quote(n.ID), strconv.Quote(n.Name), shape)
+		}
+		sb.WriteString("\t}\n")
+	}
+	for _, e := range g.Edges {
+		var attrs []string
+		if e.Count > 1 {
+			attrs = append(attrs, fmt.Sprintf("label=\"%d\"", e.Count))
+		}
+		if e.Dynamic {
+			attrs = append(attrs, "style=dashed")
+		}
+		fmt.Fprintf(&sb, "\t%s -> %s", strconv.Quote(e.Caller), strconv.Quote(e.Callee))
+		if len(attrs) > 0 {
+			fmt.Fprintf(&sb, " [%s]", strings.Join(attrs, ", "))
+		}
+		sb.WriteString(";\n")
+	}
+	sb.WriteString("}\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func (g *CallGraph) sortEdges() {
+	slices.SortFunc(g.Edges, func(a, b *CallEdge) int {
+		return cmp.Or(cmp.Compare(a.Caller, b.Caller), cmp.Compare(a.Callee, b.Callee), compareBool(a.Dynamic, b.Dynamic))
+	})
+}
+
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	}
+	return -1
+}
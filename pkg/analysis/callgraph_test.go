@@ -0,0 +1,112 @@
+package analysis
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuildCallGraph(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/calls\n\ngo 1.21\n",
+		"store/store.go": `package store
+
+type Store interface{ Get(key string) string }
+
+type mem struct{}
+
+func (m *mem) Get(key string) string { return normalize(key) }
+
+func normalize(key string) string { return key }
+
+func New() Store { return &mem{} }
+`,
+		"app/app.go": `package app
+
+import (
+	"fmt"
+
+	"example.com/calls/store"
+)
+
+func Run(s store.Store) {
+	fmt.Println(s.Get("a"), s.Get("b"))
+	f := func() { setup() }
+	f()
+}
+
+func setup() { store.New() }
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	parser := NewParser(logger)
+	ws, err := parser.ParseWorkspace(dir)
+	if err != nil {
+		t.Fatalf("ParseWorkspace: %v", err)
+	}
+	for _, pkg := range ws.Packages {
+		parser.EnsureTypeChecked(ws, pkg)
+	}
+
+	graph := NewCallGraphAnalyzer(ws, logger).BuildCallGraph()
+	type edge struct {
+		caller, callee string
+		dynamic        bool
+	}
+	counts := make(map[edge]int)
+	for _, e := range graph.Edges {
+		counts[edge{e.Caller, e.Callee, e.Dynamic}] = e.Count
+	}
+	want := map[edge]int{
+		{"example.com/calls/app.Run", "example.com/calls/store.Store.Get", false}:       2,
+		{"example.com/calls/app.Run", "example.com/calls/store.mem.Get", true}:          2,
+		{"example.com/calls/app.Run", "example.com/calls/app.setup", false}:             1,
+		{"example.com/calls/app.setup", "example.com/calls/store.New", false}:           1,
+		{"example.com/calls/store.mem.Get", "example.com/calls/store.normalize", false}: 1,
+	}
+	for e, n := range want {
+		if counts[e] != n {
+			t.Errorf("edge %v = %d, want %d", e, counts[e], n)
+		}
+	}
+	if len(counts) != len(want) {
+		t.Errorf("edges = %v", counts)
+	}
+	for _, n := range graph.Nodes {
+		if n.Interface != (n.ID == "example.com/calls/store.Store.Get") {
+			t.Errorf("node %+v", n)
+		}
+	}
+
+	// One call deep from app, the store's internals are out of reach.
+	sub := graph.Reachable(func(n *CallNode) bool { return n.Package == "example.com/calls/app" }, 1)
+	for _, n := range sub.Nodes {
+		if n.ID == "example.com/calls/store.normalize" {
+			t.Errorf("normalize reached in one call")
+		}
+	}
+	if len(sub.Edges) != 4 {
+		t.Errorf("reachable edges = %+v", sub.Edges)
+	}
+
+	var dot strings.Builder
+	if err := graph.WriteDOT(&dot); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(dot.String(), `"example.com/calls/app.Run" -> "example.com/calls/store.mem.Get" [label="2", style=dashed];`) {
+		t.Errorf("DOT:\n%s", dot.String())
+	}
+}
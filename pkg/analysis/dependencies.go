@@ -2,6 +2,7 @@ package analysis
 
 import (
 	"log/slog"
+	"maps"
 	"slices"
 
 	"github.com/mamaar/gorefactor/pkg/types"
@@ -42,8 +43,8 @@ func (da *DependencyAnalyzer) BuildDependencyGraph() (*types.DependencyGraph, er
 	// Build transitive closure of dependencies
 	graph.PackageDeps = da.transitiveClose(imports)
 
-	// Detect import cycles
-	cycles := da.detectCycles(imports)
+	// Detect import cycles among the workspace packages, by import path
+	cycles := da.detectCycles(da.workspaceImports(imports))
 	graph.ImportCycles = cycles
 
 	if len(cycles) > 0 {
@@ -121,7 +122,7 @@ func (da *DependencyAnalyzer) detectCycles(imports map[string][]string) [][]stri
 		return nil
 	}
 
-	for pkg := range imports {
+	for _, pkg := range slices.Sorted(maps.Keys(imports)) {
 		if !visited[pkg] {
 			if cycle := dfs(pkg, []string{}); cycle != nil {
 				cycles = append(cycles, cycle)
@@ -132,6 +133,24 @@ func (da *DependencyAnalyzer) detectCycles(imports map[string][]string) [][]stri
 	return cycles
 }
 
+// workspaceImports returns the imports between workspace packages, keyed
+// by the importing package's import path rather than its directory.
+func (da *DependencyAnalyzer) workspaceImports(imports map[string][]string) map[string][]string {
+	out := make(map[string][]string)
+	for dir, paths := range imports {
+		pkg := da.workspace.Packages[dir]
+		if pkg == nil || pkg.ImportPath == "" {
+			continue
+		}
+		for _, path := range paths {
+			if _, ok := da.workspace.ImportToPath[path]; ok && path != pkg.ImportPath {
+				out[pkg.ImportPath] = append(out[pkg.ImportPath], path)
+			}
+		}
+	}
+	return out
+}
+
 func (da *DependencyAnalyzer) buildSymbolDependencies(graph *types.DependencyGraph) error {
 	// Simplified implementation for now
 	graph.SymbolDeps = make(map[string]map[string][]string)
@@ -0,0 +1,230 @@
+package analysis
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// DependencyDiagram is the import graph of the workspace packages, ready to
+// be rendered as a Graphviz DOT or Mermaid diagram.
+type DependencyDiagram struct {
+	Packages []*DiagramPackage `json:"packages"`
+	Imports  []*DiagramImport  `json:"imports"`
+}
+
+// DiagramPackage is a workspace package of a DependencyDiagram.
+type DiagramPackage struct {
+	ID    string `json:"id"`              // Import path
+	Label string `json:"label"`           // Directory relative to the workspace root
+	Layer string `json:"layer,omitempty"` // Architectural layer; empty when unassigned
+}
+
+// DiagramImport is an import of one workspace package by another.
+type DiagramImport struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Cycle bool   `json:"cycle,omitempty"` // Part of an import cycle
+}
+
+// layerColors are the fill colors of the conventional layers; other
+// layers take the colors of extraLayerColors in turn.
+var (
+	layerColors = map[string]string{
+		"domain":         "#d5e8d4",
+		"application":    "#dae8fc",
+		"infrastructure": "#ffe6cc",
+	}
+	extraLayerColors = []string{"#e1d5e7", "#fff2cc", "#f8cecc", "#f5f5f5"}
+)
+
+// cycleColor draws the imports of a cycle.
+const cycleColor = "#d62728"
+
+// BuildDependencyDiagram returns the import graph of the workspace packages.
+// layer assigns each package to an architectural layer and may be nil. An
+// import is part of a cycle when the imported package imports the importer
+// back, directly or not, so every cycle is highlighted, not just one per
+// package.
+func BuildDependencyDiagram(ws *types.Workspace, layer func(*types.Package) string) *DependencyDiagram {
+	d := &DependencyDiagram{Packages: []*DiagramPackage{}, Imports: []*DiagramImport{}}
+	imports := make(map[string][]string)
+	for _, dir := range slices.Sorted(maps.Keys(ws.Packages)) {
+		pkg := ws.Packages[dir]
+		if pkg.ImportPath == "" {
+			continue
+		}
+		label, err := filepath.Rel(ws.RootPath, pkg.Path)
+		if err != nil || label == "." {
+			label = pkg.ImportPath
+		}
+		dp := &DiagramPackage{ID: pkg.ImportPath, Label: filepath.ToSlash(label)}
+		if layer != nil {
+			dp.Layer = layer(pkg)
+		}
+		d.Packages = append(d.Packages, dp)
+
+		seen := make(map[string]bool)
+		for _, file := range pkg.Files {
+			if file.AST == nil {
+				continue
+			}
+			for _, spec := range file.AST.Imports {
+				path, err := strconv.Unquote(spec.Path.Value)
+				if _, ok := ws.ImportToPath[path]; err == nil && ok && path != pkg.ImportPath && !seen[path] {
+					seen[path] = true
+					imports[pkg.ImportPath] = append(imports[pkg.ImportPath], path)
+				}
+			}
+		}
+	}
+
+	component := stronglyConnected(imports)
+	for from, tos := range imports {
+		for _, to := range tos {
+			d.Imports = append(d.Imports, &DiagramImport{From: from, To: to, Cycle: component[from] == component[to]})
+		}
+	}
+	slices.SortFunc(d.Imports, func(a, b *DiagramImport) int {
+		return cmp.Or(cmp.Compare(a.From, b.From), cmp.Compare(a.To, b.To))
+	})
+	return d
+}
+
+// stronglyConnected numbers the strongly connected components of graph,
+// by node, with Tarjan's algorithm.
+func stronglyConnected(graph map[string][]string) map[string]int {
+	index := make(map[string]int)
+	low := make(map[string]int)
+	onStack := make(map[string]bool)
+	component := make(map[string]int)
+	var stack []string
+	next, components := 0, 0
+
+	var visit func(v string)
+	visit = func(v string) {
+		index[v], low[v] = next, next
+		next++
+		stack = append(stack, v)
+		onStack[v] = true
+		for _, w := range graph[v] {
+			if _, ok := index[w]; !ok {
+				visit(w)
+				if low[w] < low[v] {
+					low[v] = low[w]
+				}
+			} else if onStack[w] && index[w] < low[v] {
+				low[v] = index[w]
+			}
+		}
+		if low[v] == index[v] {
+			for {
+				w := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[w] = false
+				component[w] = components
+				if w == v {
+					break
+				}
+			}
+			components++
+		}
+	}
+	for _, v := range slices.Sorted(maps.Keys(graph)) {
+		if _, ok := index[v]; !ok {
+			visit(v)
+		}
+	}
+	return component
+}
+
+// layerColor returns the fill color of each layer of d.
+func (d *DependencyDiagram) layerColor() map[string]string {
+	colors := make(map[string]string)
+	extra := 0
+	for _, p := range d.Packages {
+		if p.Layer == "" || colors[p.Layer] != "" {
+			continue
+		}
+		if c, ok := layerColors[p.Layer]; ok {
+			colors[p.Layer] = c
+			continue
+		}
+		colors[p.Layer] = extraLayerColors[extra%len(extraLayerColors)]
+		extra++
+	}
+	return colors
+}
+
+// WriteDOT writes d as a Graphviz digraph. Packages are filled with the
+// color of their layer, which their label names, and the imports of
+// cycles are drawn in red.
+func (d *DependencyDiagram) WriteDOT(w io.Writer) error {
+	colors := d.layerColor()
+	var sb strings.Builder
+	sb.WriteString("digraph dependencies {\n\trankdir=LR;\n\tnode [shape=box, style=\"rounded,filled\", fillcolor=white];\n")
+	for _, p := range d.Packages {
+		if p.Layer == "" {
+			fmt.Fprintf(&sb, "\t%s [label=%s];\n", strconv.Quote(p.ID), strconv.Quote(p.Label))
+			continue
+		}
+		fmt.Fprintf(&sb, "\t%s [label=%s, fillcolor=%s];\n", strconv.Quote(p.ID), strconv.Quote(p.Label+"\n"+p.Layer), strconv.Quote(colors[p.Layer]))
+	}
+	for _, imp := range d.Imports {
+		fmt.Fprintf(&sb, "\t%s -> %s", strconv.Quote(imp.From), strconv.Quote(imp.To))
+		if imp.Cycle {
+			fmt.Fprintf(&sb, " [color=%s, penwidth=2]", strconv.Quote(cycleColor))
+		}
+		sb.WriteString(";\n")
+	}
+	sb.WriteString("}\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// WriteMermaid writes d as a Mermaid flowchart, which GitHub renders in
+// Markdown. Packages are classed, and filled, by layer, and the imports of
+// cycles are drawn in red.
+func (d *DependencyDiagram) WriteMermaid(w io.Writer) error {
+	colors := d.layerColor()
+	ids := make(map[string]string)
+	var sb strings.Builder
+	sb.WriteString("graph LR\n")
+	for i, p := range d.Packages {
+		ids[p.ID] = fmt.Sprintf("p%d", i)
+		label := p.Label
+		if p.Layer != "" {
+			label += "<br/><i>" + p.Layer + "</i>"
+		}
+		fmt.Fprintf(&sb, "    %s[\"%s\"]\n", ids[p.ID], strings.ReplaceAll(label, `"`, "#quot;"))
+	}
+	var cycle []string
+	for i, imp := range d.Imports {
+		fmt.Fprintf(&sb, "    %s --> %s\n", ids[imp.From], ids[imp.To])
+		if imp.Cycle {
+			cycle = append(cycle, strconv.Itoa(i))
+		}
+	}
+	if len(cycle) > 0 {
+		fmt.Fprintf(&sb, "    linkStyle %s stroke:%s,stroke-width:2px\n", strings.Join(cycle, ","), cycleColor)
+	}
+	// Layer names needn't be identifiers, so classes are numbered.
+	for i, layer := range slices.Sorted(maps.Keys(colors)) {
+		var members []string
+		for _, p := range d.Packages {
+			if p.Layer == layer {
+				members = append(members, ids[p.ID])
+			}
+		}
+		fmt.Fprintf(&sb, "    classDef layer%d fill:%s\n    class %s layer%d\n", i, colors[layer], strings.Join(members, ","), i)
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
@@ -0,0 +1,92 @@
+package analysis
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+func TestBuildDependencyDiagram(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":      "module example.com/arch\n\ngo 1.21\n",
+		"domain/d.go": "package domain\n\nimport \"example.com/arch/store\"\n\nvar _ = store.S\n",
+		"store/s.go":  "package store\n\nimport \"example.com/arch/domain\"\n\nvar S = 1\n\nvar _ = domain.D\n",
+		"domain/x.go": "package domain\n\nvar D = 1\n",
+		"cmd/main.go": "package main\n\nimport (\n\t\"fmt\"\n\n\t\"example.com/arch/domain\"\n)\n\nfunc main() { fmt.Println(domain.D) }\n",
+		"tools/t.go":  "package tools\n",
+		"tools/t2.go": "package tools\n\nimport _ \"example.com/arch/cmd\"\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ws, err := NewParser(slog.New(slog.NewTextHandler(io.Discard, nil))).ParseWorkspace(dir)
+	if err != nil {
+		t.Fatalf("ParseWorkspace: %v", err)
+	}
+
+	layers := map[string]string{"domain": "domain", "store": "infrastructure", "tools": "tooling"}
+	d := BuildDependencyDiagram(ws, func(pkg *types.Package) string { return layers[filepath.Base(pkg.Path)] })
+	cycles := make(map[string]bool)
+	for _, imp := range d.Imports {
+		cycles[imp.From+" -> "+imp.To] = imp.Cycle
+	}
+	want := map[string]bool{
+		"example.com/arch/domain -> example.com/arch/store": true,
+		"example.com/arch/store -> example.com/arch/domain": true,
+		"example.com/arch/cmd -> example.com/arch/domain":   false,
+		"example.com/arch/tools -> example.com/arch/cmd":    false,
+	}
+	if len(cycles) != len(want) {
+		t.Errorf("imports = %v", cycles)
+	}
+	for imp, cycle := range want {
+		if got, ok := cycles[imp]; !ok || got != cycle {
+			t.Errorf("%s: cycle = %v, present = %v", imp, got, ok)
+		}
+	}
+
+	var dot strings.Builder
+	if err := d.WriteDOT(&dot); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		`"example.com/arch/domain" [label="domain\ndomain", fillcolor="#d5e8d4"];`,
+		`"example.com/arch/cmd" [label="cmd"];`,
+		`"example.com/arch/store" -> "example.com/arch/domain" [color="#d62728", penwidth=2];`,
+		`"example.com/arch/cmd" -> "example.com/arch/domain";`,
+	} {
+		if !strings.Contains(dot.String(), line) {
+			t.Errorf("DOT lacks %s:\n%s", line, dot.String())
+		}
+	}
+
+	var mermaid strings.Builder
+	if err := d.WriteMermaid(&mermaid); err != nil {
+		t.Fatal(err)
+	}
+	// Packages sort by directory: cmd, domain, store, tools.
+	for _, line := range []string{
+		"graph LR\n",
+		`    p1["domain<br/><i>domain</i>"]`,
+		"    p0 --> p1\n",
+		"    linkStyle 1,2 stroke:#d62728,stroke-width:2px\n",
+		"    classDef layer0 fill:#d5e8d4\n    class p1 layer0\n",
+		"    classDef layer2 fill:#e1d5e7\n    class p3 layer2\n",
+	} {
+		if !strings.Contains(mermaid.String(), line) {
+			t.Errorf("Mermaid lacks %q:\n%s", line, mermaid.String())
+		}
+	}
+}
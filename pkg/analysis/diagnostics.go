@@ -7,9 +7,8 @@ import (
 	"maps"
 	"sort"
 	"strings"
-
-	"golang.org/x/text/cases"
-	"golang.org/x/text/language"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/mamaar/gorefactor/pkg/types"
 )
@@ -119,7 +118,7 @@ func (de *DiagnosticEngine) AnalyzeVisibilityError(symbol *types.Symbol, accessi
 	if symbol.Package == accessingPackage {
 		suggestions = append(suggestions, "This symbol should be accessible within the same package - this might be a scoping issue")
 	} else {
-		exportedName := cases.Title(language.English).String(symbol.Name)
+		exportedName := exportName(symbol.Name)
 		if exportedName != symbol.Name {
 			suggestions = append(suggestions, fmt.Sprintf("To make it accessible, rename it to '%s' (capitalize first letter)", exportedName))
 		}
@@ -457,3 +456,13 @@ func (re *ResolutionError) FormatError() string {
 func (re *ResolutionError) Error() string {
 	return re.FormatError()
 }
+
+// exportName returns name with its first letter upper-cased, leaving the rest
+// untouched (fooBar → FooBar, ünïcode → Ünïcode).
+func exportName(name string) string {
+	r, size := utf8.DecodeRuneInString(name)
+	if r == utf8.RuneError {
+		return name
+	}
+	return string(unicode.ToUpper(r)) + name[size:]
+}
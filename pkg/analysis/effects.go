@@ -0,0 +1,102 @@
+package analysis
+
+import (
+	"go/ast"
+	"go/token"
+	gotypes "go/types"
+	"slices"
+)
+
+// EffectKind classifies what evaluating an expression does besides
+// producing its value.
+type EffectKind string
+
+const (
+	EffectCall       EffectKind = "call"       // Calls a function or method, which may do anything
+	EffectReceive    EffectKind = "receive"    // Receives from a channel, consuming a value
+	EffectAllocation EffectKind = "allocation" // Allocates a value whose identity is observable: &T{}, new, make
+)
+
+// Effect is an operation in an expression whose evaluation can't be
+// repeated, dropped or reordered without changing what the program does.
+type Effect struct {
+	Kind        EffectKind
+	Pos         token.Pos
+	Description string // e.g. "call to load", "receive from ch"
+}
+
+// pureBuiltins are the builtin functions without effects.
+var pureBuiltins = []string{"len", "cap", "complex", "real", "imag", "min", "max"}
+
+// ExprEffects returns the effects of evaluating expr, in source order.
+// Conversions and pure builtins have none, and function literals are not
+// looked into since creating one runs nothing. info may be nil; calls are
+// then told from conversions by syntax alone, which takes conversions to
+// named types for calls.
+func ExprEffects(expr ast.Expr, info *gotypes.Info) []Effect {
+	var effects []Effect
+	ast.Inspect(expr, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.UnaryExpr:
+			if n.Op == token.ARROW {
+				effects = append(effects, Effect{Kind: EffectReceive, Pos: n.Pos(), Description: "receive from " + gotypes.ExprString(n.X)})
+			}
+			if lit, ok := ast.Unparen(n.X).(*ast.CompositeLit); ok && n.Op == token.AND {
+				effects = append(effects, Effect{Kind: EffectAllocation, Pos: n.Pos(), Description: "allocation of " + gotypes.ExprString(lit.Type)})
+			}
+		case *ast.CallExpr:
+			if isConversion(n, info) {
+				return true
+			}
+			name := gotypes.ExprString(n.Fun)
+			switch builtin := builtinName(n.Fun, info); {
+			case slices.Contains(pureBuiltins, builtin):
+			case builtin == "new" || builtin == "make":
+				effects = append(effects, Effect{Kind: EffectAllocation, Pos: n.Pos(), Description: "allocation by " + name})
+			default:
+				effects = append(effects, Effect{Kind: EffectCall, Pos: n.Pos(), Description: "call to " + name})
+			}
+		}
+		return true
+	})
+	return effects
+}
+
+// isConversion reports whether call converts a value to a type.
+func isConversion(call *ast.CallExpr, info *gotypes.Info) bool {
+	if info != nil {
+		if tv, ok := info.Types[call.Fun]; ok {
+			return tv.IsType()
+		}
+	}
+	switch fun := ast.Unparen(call.Fun).(type) {
+	case *ast.ArrayType, *ast.ChanType, *ast.FuncType, *ast.MapType, *ast.InterfaceType, *ast.StructType, *ast.StarExpr:
+		return true
+	case *ast.Ident:
+		_, ok := gotypes.Universe.Lookup(fun.Name).(*gotypes.TypeName)
+		return ok
+	}
+	return false
+}
+
+// builtinName returns the name of the builtin function fun refers to, or
+// "" for other functions.
+func builtinName(fun ast.Expr, info *gotypes.Info) string {
+	id, ok := ast.Unparen(fun).(*ast.Ident)
+	if !ok {
+		return ""
+	}
+	if info != nil {
+		if obj, ok := info.Uses[id]; ok {
+			if _, ok := obj.(*gotypes.Builtin); !ok {
+				return ""
+			}
+		}
+	}
+	if _, ok := gotypes.Universe.Lookup(id.Name).(*gotypes.Builtin); ok {
+		return id.Name
+	}
+	return ""
+}
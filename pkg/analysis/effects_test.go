@@ -0,0 +1,35 @@
+package analysis
+
+import (
+	"go/parser"
+	"slices"
+	"testing"
+)
+
+func TestExprEffects(t *testing.T) {
+	tests := []struct {
+		expr string
+		want []EffectKind
+	}{
+		{"a + len(b)", nil},
+		{"[]byte(s)", nil},
+		{"func() int { return f() }", nil},
+		{"f(x) + <-ch", []EffectKind{EffectCall, EffectReceive}},
+		{"&T{N: g()}", []EffectKind{EffectAllocation, EffectCall}},
+		{"make([]int, n)", []EffectKind{EffectAllocation}},
+		{"s.Load().Value", []EffectKind{EffectCall}},
+	}
+	for _, tt := range tests {
+		expr, err := parser.ParseExpr(tt.expr)
+		if err != nil {
+			t.Fatalf("ParseExpr(%q): %v", tt.expr, err)
+		}
+		var got []EffectKind
+		for _, e := range ExprEffects(expr, nil) {
+			got = append(got, e.Kind)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("ExprEffects(%q) = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
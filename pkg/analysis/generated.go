@@ -0,0 +1,110 @@
+package analysis
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// DefaultGeneratedDirs lists directory names whose contents are treated as
+// generated code (protobuf output and mock packages) even without a header.
+var DefaultGeneratedDirs = []string{"mocks", "mock", "pb", "proto"}
+
+// DefaultMockSuffixes lists the file name suffixes of mock generators.
+var DefaultMockSuffixes = []string{"_mock.go", "_mocks.go"}
+
+// generatedSuffixes lists the file name suffixes of protobuf generators,
+// whose output is generated whatever its header says.
+var generatedSuffixes = []string{".pb.go", ".pb.gw.go"}
+
+// GeneratedFileDetector recognizes generated Go files by their
+// `// Code generated ... DO NOT EDIT.` header, well-known generator file
+// suffixes, or by living inside a configured generated-code directory.
+type GeneratedFileDetector struct {
+	root     string
+	dirs     []string
+	suffixes []string
+}
+
+// NewGeneratedFileDetector creates a detector for the workspace at root, treating
+// the given directory names as generated. When dirs is nil,
+// DefaultGeneratedDirs is used; an empty list names none. Directory
+// matching only considers path components below root, so a workspace that
+// itself lives in e.g. a "proto" directory is not treated as generated.
+func NewGeneratedFileDetector(root string, dirs ...string) *GeneratedFileDetector {
+	if dirs == nil {
+		dirs = DefaultGeneratedDirs
+	}
+	return &GeneratedFileDetector{root: root, dirs: dirs, suffixes: DefaultMockSuffixes}
+}
+
+// WithMockSuffixes replaces DefaultMockSuffixes with suffixes, unless they
+// are nil, and returns d. The protobuf suffixes always count.
+func (d *GeneratedFileDetector) WithMockSuffixes(suffixes []string) *GeneratedFileDetector {
+	if suffixes != nil {
+		d.suffixes = suffixes
+	}
+	return d
+}
+
+// IsGeneratedPath reports whether the path alone identifies a generated file,
+// either by its suffix or by a path component matching a generated directory.
+func (d *GeneratedFileDetector) IsGeneratedPath(path string) bool {
+	base := filepath.Base(path)
+	for _, suffix := range slices.Concat(generatedSuffixes, d.suffixes) {
+		if strings.HasSuffix(base, suffix) {
+			return true
+		}
+	}
+	dir := filepath.Dir(path)
+	if d.root != "" {
+		rel, err := filepath.Rel(d.root, dir)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return false
+		}
+		dir = rel
+	} else {
+		// Without a root only the immediate parent directory is considered.
+		dir = filepath.Base(dir)
+	}
+	for _, part := range strings.Split(filepath.ToSlash(dir), "/") {
+		if slices.Contains(d.dirs, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsGeneratedFile reports whether a parsed workspace file is generated.
+func (d *GeneratedFileDetector) IsGeneratedFile(file *types.File) bool {
+	if d.IsGeneratedPath(file.Path) {
+		return true
+	}
+	return file.AST != nil && ast.IsGenerated(file.AST)
+}
+
+// IsGenerated reports whether the file at path is generated, reading only its
+// header from disk. Files that do not exist yet are never considered generated.
+func (d *GeneratedFileDetector) IsGenerated(path string) bool {
+	if !strings.HasSuffix(path, ".go") {
+		return false
+	}
+	if d.IsGeneratedPath(path) {
+		return true
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	astFile, err := parser.ParseFile(token.NewFileSet(), path, content, parser.PackageClauseOnly|parser.ParseComments)
+	if err != nil {
+		return false
+	}
+	return ast.IsGenerated(astFile)
+}
@@ -0,0 +1,77 @@
+package analysis
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGeneratedFileDetector_IsGeneratedPath(t *testing.T) {
+	root := filepath.Join("/work", "proto")
+	d := NewGeneratedFileDetector(root)
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{filepath.Join(root, "api", "service.pb.go"), true},
+		{filepath.Join(root, "api", "service.pb.gw.go"), true},
+		{filepath.Join(root, "store", "store_mock.go"), true},
+		{filepath.Join(root, "internal", "mocks", "store.go"), true},
+		{filepath.Join(root, "pb", "v1", "types.go"), true},
+		{filepath.Join(root, "api", "service.go"), false},
+		// The workspace root itself is named "proto" and must not match.
+		{filepath.Join(root, "main.go"), false},
+		{filepath.Join("/elsewhere", "mocks", "store.go"), false},
+	}
+	for _, tt := range tests {
+		if got := d.IsGeneratedPath(tt.path); got != tt.want {
+			t.Errorf("IsGeneratedPath(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestGeneratedFileDetector_CustomDirs(t *testing.T) {
+	d := NewGeneratedFileDetector("/work", "gen")
+	if !d.IsGeneratedPath("/work/gen/types.go") {
+		t.Error("expected file in custom generated dir to be detected")
+	}
+	if d.IsGeneratedPath("/work/mocks/store.go") {
+		t.Error("expected default dirs to be replaced by custom dirs")
+	}
+}
+
+func TestGeneratedFileDetector_NoDefaults(t *testing.T) {
+	d := NewGeneratedFileDetector("/work", []string{}...).WithMockSuffixes([]string{})
+	for _, path := range []string{"/work/mocks/store.go", "/work/store/store_mock.go"} {
+		if d.IsGeneratedPath(path) {
+			t.Errorf("expected %s not to be generated with no dirs and mock suffixes configured", path)
+		}
+	}
+	if !d.IsGeneratedPath("/work/api/service.pb.go") {
+		t.Error("expected protobuf output to stay generated")
+	}
+}
+
+func TestGeneratedFileDetector_IsGenerated_Header(t *testing.T) {
+	dir := t.TempDir()
+	generated := filepath.Join(dir, "zz_generated.go")
+	handwritten := filepath.Join(dir, "handwritten.go")
+	if err := os.WriteFile(generated, []byte("// Code generated by stringer; DO NOT EDIT.\n\npackage p\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(handwritten, []byte("package p\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewGeneratedFileDetector(dir)
+	if !d.IsGenerated(generated) {
+		t.Error("expected file with generated header to be detected")
+	}
+	if d.IsGenerated(handwritten) {
+		t.Error("expected handwritten file not to be detected")
+	}
+	if d.IsGenerated(filepath.Join(dir, "missing.go")) {
+		t.Error("expected missing file not to be detected")
+	}
+}
@@ -0,0 +1,373 @@
+package analysis
+
+import (
+	"cmp"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// LayerRules names the directories, relative to the workspace root, of each
+// architectural layer. Application packages may import infrastructure and
+// domain packages, infrastructure packages may import domain packages, and
+// domain packages import neither. Empty entries disable the layer.
+type LayerRules struct {
+	Domain         string
+	Infrastructure string
+	Application    string
+}
+
+// HomeCandidate is a package scored as a destination for a symbol.
+type HomeCandidate struct {
+	Package    string   `json:"package"`
+	ImportPath string   `json:"import_path"`
+	Layer      string   `json:"layer,omitempty"`
+	Score      int      `json:"score"`
+	References int      `json:"references"` // references to the symbol from this package
+	Current    bool     `json:"current,omitempty"`
+	Blocked    bool     `json:"blocked,omitempty"` // moving here would create an import cycle
+	Reasons    []string `json:"reasons"`
+}
+
+// Score weights. Locality dominates; each import edge the move adds costs a
+// little, and breaking a layer rule costs about half the locality range.
+const (
+	homeLocalityWeight = 100
+	homeCurrentBonus   = 5
+	homeNewImportCost  = 5
+	homeUnexportedCost = 20
+	homeLayerCost      = 50
+)
+
+// SuggestHome ranks the packages a symbol could live in: the package that
+// declares it, the packages that reference it and the workspace packages it
+// depends on. Candidates are scored by the share of references they hold,
+// the import edges the move would add and the layer rules those edges
+// break; candidates the move would put in an import cycle are listed last
+// and marked blocked.
+func SuggestHome(ws *types.Workspace, finder *ReferenceFinder, symbol *types.Symbol, rules LayerRules) ([]*HomeCandidate, error) {
+	filePkg := make(map[string]*types.Package)
+	byImport := make(map[string]*types.Package)
+	for _, pkg := range ws.Packages {
+		byImport[pkg.ImportPath] = pkg
+		for _, f := range pkg.Files {
+			filePkg[f.Path] = pkg
+		}
+	}
+	home := byImport[symbol.Package]
+	if home == nil {
+		home = ws.Packages[symbol.Package]
+	}
+	if home == nil {
+		return nil, fmt.Errorf("package %s not in workspace", symbol.Package)
+	}
+	refs, err := finder.FindReferences(symbol)
+	if err != nil {
+		return nil, err
+	}
+	imports := packageImports(ws, byImport)
+
+	decl := declNode(home, symbol)
+	refCount := make(map[string]int)
+	total := 0
+	for _, ref := range refs {
+		pkg := filePkg[ref.File]
+		if pkg == nil {
+			continue
+		}
+		// Recursive uses move with the declaration.
+		if pkg == home && decl != nil && ref.Position >= decl.Pos() && ref.Position < decl.End() {
+			continue
+		}
+		refCount[pkg.Path]++
+		total++
+	}
+
+	deps, unexported := declDependencies(home, decl, symbol.Name, byImport)
+
+	candidates := map[string]bool{home.Path: true}
+	for pkg := range refCount {
+		candidates[pkg] = true
+	}
+	for _, dep := range deps {
+		candidates[dep] = true
+	}
+
+	var out []*HomeCandidate
+	for _, path := range slices.Sorted(maps.Keys(candidates)) {
+		pkg := ws.Packages[path]
+		c := &HomeCandidate{
+			Package:    path,
+			ImportPath: pkg.ImportPath,
+			Layer:      rules.layerOf(ws, pkg),
+			References: refCount[path],
+			Current:    pkg == home,
+		}
+		if total > 0 {
+			c.Score = homeLocalityWeight * c.References / total
+			c.Reasons = append(c.Reasons, fmt.Sprintf("%d of %d references come from this package", c.References, total))
+		} else {
+			c.Reasons = append(c.Reasons, "the symbol has no references outside its declaration")
+		}
+		if c.Current {
+			c.Score += homeCurrentBonus
+			c.Reasons = append(c.Reasons, "current location; no move needed")
+		} else if len(unexported) > 0 {
+			c.Score -= homeUnexportedCost
+			c.Reasons = append(c.Reasons, fmt.Sprintf("uses unexported %s of %s; move or export them too", strings.Join(unexported, ", "), home.Name))
+		}
+
+		// Every referencing package would import the candidate...
+		for _, user := range slices.Sorted(maps.Keys(refCount)) {
+			if user != path {
+				scoreEdge(c, ws, rules, imports, user, path)
+			}
+		}
+		// ...and the candidate would import what the symbol depends on.
+		for _, dep := range deps {
+			if dep != path {
+				scoreEdge(c, ws, rules, imports, path, dep)
+			}
+		}
+		out = append(out, c)
+	}
+
+	slices.SortStableFunc(out, func(a, b *HomeCandidate) int {
+		if a.Blocked != b.Blocked {
+			if a.Blocked {
+				return 1
+			}
+			return -1
+		}
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Package, b.Package)
+	})
+	return out, nil
+}
+
+// scoreEdge accounts for from importing to once the symbol lives in c.
+func scoreEdge(c *HomeCandidate, ws *types.Workspace, rules LayerRules, imports map[string][]string, from, to string) {
+	fromPkg, toPkg := ws.Packages[from], ws.Packages[to]
+	if reaches(imports, to, from) {
+		c.Blocked = true
+		c.Reasons = append(c.Reasons, fmt.Sprintf("%s would import %s, which already depends on it (import cycle)", fromPkg.ImportPath, toPkg.ImportPath))
+		return
+	}
+	if !slices.Contains(imports[from], to) {
+		c.Score -= homeNewImportCost
+		c.Reasons = append(c.Reasons, fmt.Sprintf("adds an import of %s to %s", toPkg.ImportPath, fromPkg.ImportPath))
+	}
+	fromLayer, toLayer := rules.layerOf(ws, fromPkg), rules.layerOf(ws, toPkg)
+	if fromLayer != "" && toLayer != "" && layerRank(fromLayer) < layerRank(toLayer) {
+		c.Score -= homeLayerCost
+		c.Reasons = append(c.Reasons, fmt.Sprintf("%s package %s would import %s package %s", fromLayer, fromPkg.ImportPath, toLayer, toPkg.ImportPath))
+	}
+}
+
+func (r LayerRules) layerOf(ws *types.Workspace, pkg *types.Package) string {
+	rel, err := filepath.Rel(ws.RootPath, pkg.Path)
+	if err != nil {
+		return ""
+	}
+	rel = filepath.ToSlash(rel) + "/"
+	for _, layer := range []struct{ name, dir string }{
+		{"domain", r.Domain},
+		{"infrastructure", r.Infrastructure},
+		{"application", r.Application},
+	} {
+		dir := strings.Trim(filepath.ToSlash(layer.dir), "/")
+		if dir != "" && strings.HasPrefix(rel, dir+"/") {
+			return layer.name
+		}
+	}
+	return ""
+}
+
+// layerRank orders layers so a package may only import packages of equal or
+// lower rank.
+func layerRank(layer string) int {
+	switch layer {
+	case "infrastructure":
+		return 1
+	case "application":
+		return 2
+	}
+	return 0
+}
+
+// packageImports maps each workspace package to the workspace packages its
+// non-test files import.
+func packageImports(ws *types.Workspace, byImport map[string]*types.Package) map[string][]string {
+	imports := make(map[string][]string)
+	for _, pkg := range ws.Packages {
+		for _, f := range pkg.Files {
+			if f.AST == nil || strings.HasSuffix(f.Path, "_test.go") {
+				continue
+			}
+			for _, imp := range f.AST.Imports {
+				path, err := strconv.Unquote(imp.Path.Value)
+				if err != nil {
+					continue
+				}
+				if dep := byImport[path]; dep != nil && !slices.Contains(imports[pkg.Path], dep.Path) {
+					imports[pkg.Path] = append(imports[pkg.Path], dep.Path)
+				}
+			}
+		}
+	}
+	return imports
+}
+
+// reaches reports whether from imports to, directly or transitively.
+func reaches(imports map[string][]string, from, to string) bool {
+	seen := map[string]bool{from: true}
+	queue := []string{from}
+	for len(queue) > 0 {
+		pkg := queue[0]
+		queue = queue[1:]
+		for _, dep := range imports[pkg] {
+			if dep == to {
+				return true
+			}
+			if !seen[dep] {
+				seen[dep] = true
+				queue = append(queue, dep)
+			}
+		}
+	}
+	return false
+}
+
+// declNode returns the declaration of symbol: the FuncDecl, or the spec
+// within a GenDecl.
+func declNode(pkg *types.Package, symbol *types.Symbol) ast.Node {
+	for _, f := range pkg.Files {
+		if f.Path != symbol.File || f.AST == nil {
+			continue
+		}
+		for _, decl := range f.AST.Decls {
+			if symbol.Position < decl.Pos() || symbol.Position >= decl.End() {
+				continue
+			}
+			if gd, ok := decl.(*ast.GenDecl); ok {
+				for _, spec := range gd.Specs {
+					if symbol.Position >= spec.Pos() && symbol.Position < spec.End() {
+						return spec
+					}
+				}
+			}
+			return decl
+		}
+	}
+	return nil
+}
+
+// declDependencies returns the workspace packages a declaration uses,
+// including its own package when it refers to other package-level names,
+// and the unexported names of its package it refers to.
+func declDependencies(pkg *types.Package, decl ast.Node, name string, byImport map[string]*types.Package) ([]string, []string) {
+	if decl == nil {
+		return nil, nil
+	}
+	var file *ast.File
+	pkgNames := make(map[string]bool)
+	for _, f := range pkg.Files {
+		if f.AST == nil || strings.HasSuffix(f.Path, "_test.go") {
+			continue
+		}
+		if decl.Pos() >= f.AST.Pos() && decl.End() <= f.AST.End() {
+			file = f.AST
+		}
+		for _, d := range f.AST.Decls {
+			for _, n := range declaredNames(d) {
+				pkgNames[n] = true
+			}
+		}
+	}
+	if file == nil {
+		return nil, nil
+	}
+	importNames := make(map[string]string)
+	for _, imp := range file.Imports {
+		path, err := strconv.Unquote(imp.Path.Value)
+		if err != nil || byImport[path] == nil {
+			continue
+		}
+		local := byImport[path].Name
+		if imp.Name != nil {
+			local = imp.Name.Name
+		}
+		importNames[local] = byImport[path].Path
+	}
+
+	deps := make(map[string]bool)
+	unexported := make(map[string]bool)
+	var visit func(ast.Node) bool
+	visit = func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			if x, ok := n.X.(*ast.Ident); ok && x.Obj == nil {
+				if dep, ok := importNames[x.Name]; ok {
+					deps[dep] = true
+					return false
+				}
+			}
+			// The selected name is a field or method, not a package-level name.
+			ast.Inspect(n.X, visit)
+			return false
+		case *ast.KeyValueExpr:
+			// Keys of struct literals are field names.
+			if _, ok := n.Key.(*ast.Ident); ok {
+				ast.Inspect(n.Value, visit)
+				return false
+			}
+		case *ast.Ident:
+			if n.Name == name || !pkgNames[n.Name] {
+				return true
+			}
+			// Locals, parameters and fields of the declaration shadow
+			// package-level names.
+			if n.Obj != nil && n.Obj.Pos() >= decl.Pos() && n.Obj.Pos() < decl.End() {
+				return true
+			}
+			deps[pkg.Path] = true
+			if !token.IsExported(n.Name) {
+				unexported[n.Name] = true
+			}
+		}
+		return true
+	}
+	ast.Inspect(decl, visit)
+	return slices.Sorted(maps.Keys(deps)), slices.Sorted(maps.Keys(unexported))
+}
+
+func declaredNames(decl ast.Decl) []string {
+	var names []string
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		if d.Recv == nil {
+			names = append(names, d.Name.Name)
+		}
+	case *ast.GenDecl:
+		for _, spec := range d.Specs {
+			switch s := spec.(type) {
+			case *ast.TypeSpec:
+				names = append(names, s.Name.Name)
+			case *ast.ValueSpec:
+				for _, n := range s.Names {
+					names = append(names, n.Name)
+				}
+			}
+		}
+	}
+	return names
+}
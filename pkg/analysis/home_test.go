@@ -0,0 +1,121 @@
+package analysis
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSuggestHome(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/home\n\ngo 1.21\n",
+		"domain/order.go": `package domain
+
+type Order struct {
+	ID    string
+	Total int
+}
+`,
+		"util/util.go": `package util
+
+import (
+	"fmt"
+
+	"example.com/home/domain"
+)
+
+func FormatInvoice(o domain.Order) string {
+	return fmt.Sprintf("%s: %d", o.ID, o.Total)
+}
+`,
+		"billing/billing.go": `package billing
+
+import (
+	"example.com/home/domain"
+	"example.com/home/util"
+)
+
+func Charge(o domain.Order) []string {
+	return []string{util.FormatInvoice(o), util.FormatInvoice(o), util.FormatInvoice(o)}
+}
+`,
+		"app/app.go": `package app
+
+import (
+	"example.com/home/billing"
+	"example.com/home/domain"
+	"example.com/home/util"
+)
+
+func Run(o domain.Order) {
+	_ = billing.Charge(o)
+	_ = util.FormatInvoice(o)
+}
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ws, err := NewParser(logger).ParseWorkspace(dir)
+	if err != nil {
+		t.Fatalf("ParseWorkspace: %v", err)
+	}
+	resolver := NewSymbolResolver(ws, logger)
+	for _, pkg := range ws.Packages {
+		if _, err := resolver.BuildSymbolTable(pkg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	symbol, err := resolver.ResolveSymbol(ws.Packages[filepath.Join(dir, "util")], "FormatInvoice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rules := LayerRules{Domain: "billing", Infrastructure: "util", Application: "app"}
+	candidates, err := SuggestHome(ws, NewReferenceFinder(resolver, nil), symbol, rules)
+	if err != nil {
+		t.Fatalf("SuggestHome: %v", err)
+	}
+
+	var order []string
+	byName := make(map[string]*HomeCandidate)
+	for _, c := range candidates {
+		name := filepath.Base(c.Package)
+		order = append(order, name)
+		byName[name] = c
+	}
+	// billing holds 3 of 4 references; app would close a cycle with billing.
+	if want := "billing,domain,util,app"; strings.Join(order, ",") != want {
+		t.Fatalf("order = %v, want %s", order, want)
+	}
+	if c := byName["billing"]; c.References != 3 || c.Score != 75 {
+		t.Errorf("billing = %+v, want 3 references scoring 75", c)
+	}
+	if !byName["app"].Blocked {
+		t.Errorf("app should be blocked by an import cycle: %+v", byName["app"])
+	}
+	if c := byName["util"]; !c.Current || !hasReason(c, "domain package example.com/home/billing would import infrastructure package") {
+		t.Errorf("util should be current and break the layer rule: %+v", c)
+	}
+}
+
+func hasReason(c *HomeCandidate, substr string) bool {
+	for _, r := range c.Reasons {
+		if strings.Contains(r, substr) {
+			return true
+		}
+	}
+	return false
+}
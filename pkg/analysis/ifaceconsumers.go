@@ -0,0 +1,124 @@
+package analysis
+
+import (
+	"go/ast"
+	"go/token"
+	gotypes "go/types"
+	"maps"
+	"slices"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// InterfaceConsumer is a function parameter of an interface type and the
+// methods the function calls through it.
+type InterfaceConsumer struct {
+	Function string   `json:"function"` // Type.Method for methods
+	Package  string   `json:"package"`
+	File     string   `json:"file"`
+	Line     int      `json:"line"`
+	Param    string   `json:"param"`
+	Methods  []string `json:"methods"`           // Methods called through the parameter, sorted
+	Escapes  string   `json:"escapes,omitempty"` // Why the parameter needs the full interface, if it does
+
+	Pos token.Pos `json:"-"` // Position of the parameter name
+}
+
+// AnalyzeInterfaceConsumers reports every parameter of a function declared
+// in a non-test workspace file whose type is the interface named. A
+// parameter escapes when it is used other than to select its methods, for
+// example passed on, stored or compared; such uses may rely on the full
+// interface. Packages should be type-checked first.
+func AnalyzeInterfaceConsumers(ws *types.Workspace, named *gotypes.Named) []*InterfaceConsumer {
+	var consumers []*InterfaceConsumer
+	for _, path := range slices.Sorted(maps.Keys(ws.Packages)) {
+		pkg := ws.Packages[path]
+		info := pkg.TypesInfo
+		if info == nil {
+			continue
+		}
+		for _, name := range slices.Sorted(maps.Keys(pkg.Files)) {
+			file := pkg.Files[name]
+			if file.AST == nil {
+				continue
+			}
+			for _, decl := range file.AST.Decls {
+				fd, ok := decl.(*ast.FuncDecl)
+				if !ok || fd.Body == nil {
+					continue
+				}
+				for _, field := range fd.Type.Params.List {
+					if t := info.TypeOf(field.Type); t == nil || !gotypes.Identical(t, named) {
+						continue
+					}
+					for _, ident := range field.Names {
+						v, ok := info.Defs[ident].(*gotypes.Var)
+						if !ok || ident.Name == "_" {
+							continue
+						}
+						c := &InterfaceConsumer{
+							Function: funcDeclName(fd),
+							Package:  pkg.Path,
+							File:     file.Path,
+							Line:     ws.FileSet.Position(ident.Pos()).Line,
+							Param:    ident.Name,
+							Pos:      ident.Pos(),
+						}
+						c.Methods, c.Escapes = paramUses(info, fd.Body, v)
+						consumers = append(consumers, c)
+					}
+				}
+			}
+		}
+	}
+	return consumers
+}
+
+// paramUses returns the methods selected through v in body, and why v
+// escapes when it is used any other way.
+func paramUses(info *gotypes.Info, body *ast.BlockStmt, v *gotypes.Var) ([]string, string) {
+	methods := make(map[string]bool)
+	selected := make(map[*ast.Ident]bool)
+	ast.Inspect(body, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if x, ok := sel.X.(*ast.Ident); ok && info.Uses[x] == v {
+			if _, ok := info.Uses[sel.Sel].(*gotypes.Func); ok {
+				methods[sel.Sel.Name] = true
+				selected[x] = true
+			}
+		}
+		return true
+	})
+
+	escapes := ""
+	ast.Inspect(body, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && escapes == "" && info.Uses[id] == v && !selected[id] {
+			escapes = "used other than to call its methods"
+		}
+		return escapes == ""
+	})
+	return slices.Sorted(maps.Keys(methods)), escapes
+}
+
+func funcDeclName(fd *ast.FuncDecl) string {
+	if fd.Recv == nil || len(fd.Recv.List) == 0 {
+		return fd.Name.Name
+	}
+	t := fd.Recv.List[0].Type
+	if star, ok := t.(*ast.StarExpr); ok {
+		t = star.X
+	}
+	switch x := t.(type) {
+	case *ast.IndexExpr:
+		t = x.X
+	case *ast.IndexListExpr:
+		t = x.X
+	}
+	if id, ok := t.(*ast.Ident); ok {
+		return id.Name + "." + fd.Name.Name
+	}
+	return fd.Name.Name
+}
@@ -0,0 +1,86 @@
+package analysis
+
+import (
+	gotypes "go/types"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestAnalyzeInterfaceConsumers(t *testing.T) {
+	dir := t.TempDir()
+	src := `package store
+
+type Store interface {
+	Get(key string) string
+	Put(key, value string)
+}
+
+func Copy(src, dst Store) {
+	dst.Put("k", src.Get("k"))
+}
+
+func Keep(s Store) Store {
+	_ = s.Get
+	return s
+}
+
+type Cache struct{}
+
+func (c *Cache) Fill(s Store, _ Store) {
+	s.Put("k", "v")
+}
+`
+	for name, content := range map[string]string{
+		"go.mod":   "module example.com/consumers\n\ngo 1.21\n",
+		"store.go": src,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	parser := NewParser(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ws, err := parser.ParseWorkspace(dir)
+	if err != nil {
+		t.Fatalf("ParseWorkspace: %v", err)
+	}
+	pkg := ws.Packages[dir]
+	parser.EnsureTypeChecked(ws, pkg)
+	named := pkg.TypesPkg.Scope().Lookup("Store").Type().(*gotypes.Named)
+
+	got := make(map[string]*InterfaceConsumer)
+	for _, c := range AnalyzeInterfaceConsumers(ws, named) {
+		got[c.Function+"/"+c.Param] = c
+	}
+	if len(got) != 4 {
+		t.Fatalf("expected 4 consumers, got %d: %v", len(got), got)
+	}
+
+	tests := []struct {
+		key     string
+		methods []string
+		escapes bool
+	}{
+		{"Copy/src", []string{"Get"}, false},
+		{"Copy/dst", []string{"Put"}, false},
+		{"Keep/s", []string{"Get"}, true},
+		{"Cache.Fill/s", []string{"Put"}, false},
+	}
+	for _, tt := range tests {
+		c := got[tt.key]
+		if c == nil {
+			t.Errorf("%s: missing", tt.key)
+			continue
+		}
+		if !slices.Equal(c.Methods, tt.methods) {
+			t.Errorf("%s: methods = %v, want %v", tt.key, c.Methods, tt.methods)
+		}
+		if (c.Escapes != "") != tt.escapes {
+			t.Errorf("%s: escapes = %q, want escaping %v", tt.key, c.Escapes, tt.escapes)
+		}
+	}
+}
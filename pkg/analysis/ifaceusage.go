@@ -0,0 +1,242 @@
+package analysis
+
+import (
+	"go/ast"
+	gotypes "go/types"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// InterfaceUsage describes how the methods of one workspace interface are used.
+type InterfaceUsage struct {
+	Name       string   `json:"name"`
+	Package    string   `json:"package"`
+	File       string   `json:"file"`
+	Line       int      `json:"line"`
+	Methods    []string `json:"methods"`               // Full method set, including embedded methods
+	Called     []string `json:"called"`                // Called through values (or type parameters) of the interface
+	Required   []string `json:"required,omitempty"`    // Needed to keep satisfying RequiredBy
+	RequiredBy []string `json:"required_by,omitempty"` // Other interfaces it implements; values may be converted to them
+	Unused     []string `json:"unused"`                // Neither called nor required; candidates for removal
+}
+
+// dynamicMethods are called by the standard library through runtime type
+// assertions (fmt, errors), which no static call site shows.
+var dynamicMethods = []string{"Error", "String", "GoString", "Format"}
+
+// AnalyzeInterfaceUsage reports, for every interface declared at package level
+// in the workspace, which of its methods are called through values of that
+// interface type. It relies on go/types information, so packages should be
+// type-checked first; calls in packages without type information are missed.
+//
+// A method also counts as used when the interface implements another
+// interface that has it (values may be converted to that interface and the
+// method called through it), or when the standard library may call it
+// dynamically (Error, String, ...). Methods that are neither are Unused.
+func AnalyzeInterfaceUsage(ws *types.Workspace) []*InterfaceUsage {
+	type declared struct {
+		named *gotypes.Named
+		pkg   *types.Package
+		file  *types.File
+		spec  *ast.TypeSpec
+	}
+	var ifaces []declared
+	called := make(map[*gotypes.Named]map[string]bool)
+
+	for _, path := range slices.Sorted(maps.Keys(ws.Packages)) {
+		pkg := ws.Packages[path]
+		info := pkg.TypesInfo
+		if info == nil {
+			continue
+		}
+		for _, name := range slices.Sorted(maps.Keys(pkg.Files)) {
+			file := pkg.Files[name]
+			if file.AST == nil {
+				continue
+			}
+			for _, decl := range file.AST.Decls {
+				gen, ok := decl.(*ast.GenDecl)
+				if !ok {
+					continue
+				}
+				for _, spec := range gen.Specs {
+					ts, ok := spec.(*ast.TypeSpec)
+					if !ok {
+						continue
+					}
+					if tn, ok := info.Defs[ts.Name].(*gotypes.TypeName); ok {
+						if named, ok := tn.Type().(*gotypes.Named); ok && gotypes.IsInterface(named) {
+							ifaces = append(ifaces, declared{named: named, pkg: pkg, file: file, spec: ts})
+						}
+					}
+				}
+			}
+
+			ast.Inspect(file.AST, func(n ast.Node) bool {
+				sel, ok := n.(*ast.SelectorExpr)
+				if !ok {
+					return true
+				}
+				fn, ok := info.Uses[sel.Sel].(*gotypes.Func)
+				if !ok {
+					return true
+				}
+				// Credit both the interface the call goes through and the one
+				// declaring the method, which differ for embedded interfaces.
+				for _, t := range []gotypes.Type{info.TypeOf(sel.X), recvType(fn)} {
+					if named := interfaceOf(t); named != nil {
+						if called[named] == nil {
+							called[named] = make(map[string]bool)
+						}
+						called[named][fn.Name()] = true
+					}
+				}
+				return true
+			})
+		}
+	}
+
+	referenced := ReferencedInterfaces(ws)
+	isDeclared := make(map[*gotypes.Named]bool)
+	for _, d := range ifaces {
+		isDeclared[d.named] = true
+	}
+	required := make(map[*gotypes.Named]map[string]bool)
+	requiredBy := make(map[*gotypes.Named][]string)
+	for _, d := range ifaces {
+		iface := d.named.Underlying().(*gotypes.Interface)
+		required[d.named] = make(map[string]bool)
+		for _, other := range referenced {
+			if other == d.named {
+				continue
+			}
+			otherIface := other.Underlying().(*gotypes.Interface)
+			if otherIface.NumMethods() == 0 || !gotypes.Implements(d.named, otherIface) {
+				continue
+			}
+			// Methods both get from the same embedded workspace interface
+			// are governed by that interface's own usage.
+			needed := false
+			for m := range otherIface.Methods() {
+				obj, _, _ := gotypes.LookupFieldOrMethod(d.named, false, m.Pkg(), m.Name())
+				if obj != m || !isDeclared[interfaceOf(recvType(m))] {
+					required[d.named][m.Name()] = true
+					needed = true
+				}
+			}
+			if needed {
+				requiredBy[d.named] = append(requiredBy[d.named], qualifiedName(other))
+			}
+		}
+		for m := range iface.Methods() {
+			if slices.Contains(dynamicMethods, m.Name()) {
+				required[d.named][m.Name()] = true
+			}
+		}
+	}
+	// An interface embedding another needs the embedded methods it requires.
+	for _, d := range ifaces {
+		for m := range d.named.Underlying().(*gotypes.Interface).Methods() {
+			owner := interfaceOf(recvType(m))
+			if owner != d.named && isDeclared[owner] && required[d.named][m.Name()] {
+				required[owner][m.Name()] = true
+			}
+		}
+	}
+
+	var usages []*InterfaceUsage
+	for _, d := range ifaces {
+		iface := d.named.Underlying().(*gotypes.Interface)
+		methods := make([]string, 0, iface.NumMethods())
+		for m := range iface.Methods() {
+			methods = append(methods, m.Name())
+		}
+		used := called[d.named]
+		required := required[d.named]
+		requiredBy := requiredBy[d.named]
+
+		u := &InterfaceUsage{
+			Name:       d.named.Obj().Name(),
+			Package:    d.pkg.Path,
+			File:       d.file.Path,
+			Line:       ws.FileSet.Position(d.spec.Pos()).Line,
+			Methods:    methods,
+			RequiredBy: requiredBy,
+			Called:     []string{},
+			Unused:     []string{},
+		}
+		for _, m := range methods {
+			switch {
+			case used[m]:
+				u.Called = append(u.Called, m)
+			case required[m]:
+				u.Required = append(u.Required, m)
+			default:
+				u.Unused = append(u.Unused, m)
+			}
+		}
+		usages = append(usages, u)
+	}
+	return usages
+}
+
+// ReferencedInterfaces returns the named interfaces referenced by name
+// anywhere in the type-checked workspace, including ones declared outside it,
+// sorted by qualified name.
+func ReferencedInterfaces(ws *types.Workspace) []*gotypes.Named {
+	seen := make(map[*gotypes.Named]bool)
+	var ifaces []*gotypes.Named
+	for _, pkg := range ws.Packages {
+		if pkg.TypesInfo == nil {
+			continue
+		}
+		for _, obj := range pkg.TypesInfo.Uses {
+			tn, ok := obj.(*gotypes.TypeName)
+			if !ok {
+				continue
+			}
+			named, ok := gotypes.Unalias(tn.Type()).(*gotypes.Named)
+			if !ok || !gotypes.IsInterface(named) || seen[named.Origin()] {
+				continue
+			}
+			seen[named.Origin()] = true
+			ifaces = append(ifaces, named.Origin())
+		}
+	}
+	slices.SortFunc(ifaces, func(a, b *gotypes.Named) int {
+		return strings.Compare(qualifiedName(a), qualifiedName(b))
+	})
+	return ifaces
+}
+
+// recvType returns the receiver type of a method; for interface methods the
+// interface declaring it.
+func recvType(fn *gotypes.Func) gotypes.Type {
+	if recv := fn.Type().(*gotypes.Signature).Recv(); recv != nil {
+		return recv.Type()
+	}
+	return nil
+}
+
+// interfaceOf returns the named interface a method is selected through: the
+// type of the receiver expression, or the constraint of a type parameter.
+func interfaceOf(t gotypes.Type) *gotypes.Named {
+	if tp, ok := gotypes.Unalias(t).(*gotypes.TypeParam); ok {
+		t = tp.Constraint()
+	}
+	named, ok := gotypes.Unalias(t).(*gotypes.Named)
+	if !ok || !gotypes.IsInterface(named) {
+		return nil
+	}
+	return named.Origin()
+}
+
+func qualifiedName(named *gotypes.Named) string {
+	if pkg := named.Obj().Pkg(); pkg != nil {
+		return pkg.Path() + "." + named.Obj().Name()
+	}
+	return named.Obj().Name()
+}
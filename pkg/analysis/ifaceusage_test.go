@@ -0,0 +1,108 @@
+package analysis
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestAnalyzeInterfaceUsage(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/usage\n\ngo 1.21\n",
+		"store/store.go": `package store
+
+import "io"
+
+type Store interface {
+	Get(key string) string
+	Put(key, value string)
+	Stats() int
+	String() string
+	io.Closer
+}
+
+type Base interface {
+	Ping()
+	Unused()
+}
+
+type Full interface {
+	Base
+	Extra()
+}
+`,
+		"app/app.go": `package app
+
+import "example.com/usage/store"
+
+func Run(s store.Store, f store.Full) {
+	_ = s.Get("k")
+	f.Ping()
+}
+
+func Each[T store.Store](items []T) {
+	for _, item := range items {
+		item.Put("k", "v")
+	}
+}
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	parser := NewParser(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ws, err := parser.ParseWorkspace(dir)
+	if err != nil {
+		t.Fatalf("ParseWorkspace: %v", err)
+	}
+	for _, pkg := range ws.Packages {
+		parser.EnsureTypeChecked(ws, pkg)
+	}
+
+	usages := make(map[string]*InterfaceUsage)
+	for _, u := range AnalyzeInterfaceUsage(ws) {
+		usages[u.Name] = u
+	}
+
+	tests := []struct {
+		name     string
+		called   []string
+		required []string
+		unused   []string
+	}{
+		// Put is called through a type parameter, Close is needed for io.Closer,
+		// String may be called by fmt.
+		{"Store", []string{"Get", "Put"}, []string{"Close", "String"}, []string{"Stats"}},
+		// Ping is called through Full, which embeds Base.
+		{"Base", []string{"Ping"}, nil, []string{"Unused"}},
+		{"Full", []string{"Ping"}, nil, []string{"Extra", "Unused"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := usages[tt.name]
+			if u == nil {
+				t.Fatalf("no usage reported for %s", tt.name)
+			}
+			if !slices.Equal(u.Called, tt.called) {
+				t.Errorf("Called = %v, want %v", u.Called, tt.called)
+			}
+			if !slices.Equal(u.Required, tt.required) {
+				t.Errorf("Required = %v, want %v", u.Required, tt.required)
+			}
+			if !slices.Equal(u.Unused, tt.unused) {
+				t.Errorf("Unused = %v, want %v", u.Unused, tt.unused)
+			}
+		})
+	}
+}
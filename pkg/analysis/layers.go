@@ -0,0 +1,155 @@
+package analysis
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// Layer names, as organize_by_layers and dependency diagrams use them.
+const (
+	LayerDomain         = "domain"
+	LayerApplication    = "application"
+	LayerInfrastructure = "infrastructure"
+)
+
+// LayerAssignment is the architectural layer inferred for a package.
+type LayerAssignment struct {
+	Package    string `json:"package"` // Directory relative to the workspace root
+	ImportPath string `json:"import_path"`
+	Layer      string `json:"layer"`
+	Stratum    int    `json:"stratum"` // Longest import chain down to a package importing no workspace package; a cycle's packages share one
+	FanIn      int    `json:"fan_in"`  // Workspace packages importing the package
+	FanOut     int    `json:"fan_out"` // Workspace packages the package imports
+	Reason     string `json:"reason"`
+}
+
+// ioPackages are the standard library packages whose import marks a
+// package as an adapter to the outside world.
+var ioPackages = []string{"database/sql", "net", "net/http", "net/rpc", "net/smtp", "os", "os/exec", "syscall"}
+
+// InferLayers proposes a layer for every workspace package from the import
+// graph of their non-test files, in directory order:
+//
+//   - application: package main, or a package no workspace package imports
+//     but which imports some;
+//   - infrastructure: a package importing one of ioPackages;
+//   - application: a package at the top stratum;
+//   - domain: a package importing no workspace package, or imported by at
+//     least as many as it imports;
+//   - application: the rest, which mostly coordinate other packages.
+func InferLayers(ws *types.Workspace) []*LayerAssignment {
+	imports := make(map[string][]string)
+	ioImport := make(map[string]string)
+	var paths []string
+	for _, dir := range slices.Sorted(maps.Keys(ws.Packages)) {
+		pkg := ws.Packages[dir]
+		if pkg.ImportPath == "" {
+			continue
+		}
+		paths = append(paths, pkg.ImportPath)
+		imports[pkg.ImportPath] = nil
+		for _, path := range fileImports(pkg) {
+			if _, ok := ws.ImportToPath[path]; ok && path != pkg.ImportPath {
+				imports[pkg.ImportPath] = append(imports[pkg.ImportPath], path)
+			} else if ioImport[pkg.ImportPath] == "" && slices.Contains(ioPackages, path) {
+				ioImport[pkg.ImportPath] = path
+			}
+		}
+	}
+
+	fanIn := make(map[string]int)
+	for _, tos := range imports {
+		for _, to := range tos {
+			fanIn[to]++
+		}
+	}
+	strata := importStrata(imports)
+	top := 0
+	for _, s := range strata {
+		top = max(top, s)
+	}
+
+	assignments := make([]*LayerAssignment, 0, len(paths))
+	for _, path := range paths {
+		pkg := ws.Packages[ws.ImportToPath[path]]
+		a := &LayerAssignment{
+			Package:    workspaceRel(ws, pkg.Path),
+			ImportPath: path,
+			Stratum:    strata[path],
+			FanIn:      fanIn[path],
+			FanOut:     len(imports[path]),
+		}
+		switch {
+		case pkg.Name == "main":
+			a.Layer, a.Reason = LayerApplication, "package main"
+		case a.FanIn == 0 && a.FanOut > 0:
+			a.Layer, a.Reason = LayerApplication, fmt.Sprintf("imported by no workspace package, imports %d", a.FanOut)
+		case ioImport[path] != "":
+			a.Layer, a.Reason = LayerInfrastructure, "imports "+ioImport[path]
+		case top > 0 && a.Stratum == top:
+			a.Layer, a.Reason = LayerApplication, fmt.Sprintf("at the top of the import graph, stratum %d", top)
+		case a.FanOut == 0:
+			a.Layer, a.Reason = LayerDomain, "imports no workspace package"
+		case a.FanIn >= a.FanOut:
+			a.Layer, a.Reason = LayerDomain, fmt.Sprintf("stable: imported by %d workspace packages, imports %d", a.FanIn, a.FanOut)
+		default:
+			a.Layer, a.Reason = LayerApplication, fmt.Sprintf("imports %d workspace packages, imported by %d", a.FanOut, a.FanIn)
+		}
+		assignments = append(assignments, a)
+	}
+	return assignments
+}
+
+// fileImports returns the distinct import paths of the non-test files of
+// pkg.
+func fileImports(pkg *types.Package) []string {
+	seen := make(map[string]bool)
+	for _, file := range pkg.Files {
+		if file.AST == nil {
+			continue
+		}
+		for _, spec := range file.AST.Imports {
+			if path, err := strconv.Unquote(spec.Path.Value); err == nil {
+				seen[path] = true
+			}
+		}
+	}
+	return slices.Sorted(maps.Keys(seen))
+}
+
+// importStrata returns the stratum of every node of graph: 0 for a node
+// whose edges stay within its strongly connected component, otherwise one
+// more than the highest stratum it has an edge to.
+func importStrata(graph map[string][]string) map[string]int {
+	component := stronglyConnected(graph)
+	members := make(map[int][]string)
+	for v, c := range component {
+		members[c] = append(members[c], v)
+	}
+	strata := make(map[int]int)
+	var stratum func(c int) int
+	stratum = func(c int) int {
+		if s, ok := strata[c]; ok {
+			return s
+		}
+		s := 0
+		for _, v := range members[c] {
+			for _, w := range graph[v] {
+				if d := component[w]; d != c {
+					s = max(s, stratum(d)+1)
+				}
+			}
+		}
+		strata[c] = s
+		return s
+	}
+	out := make(map[string]int, len(component))
+	for v, c := range component {
+		out[v] = stratum(c)
+	}
+	return out
+}
@@ -0,0 +1,55 @@
+package analysis
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInferLayers(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":                  "module example.com/shop\n\ngo 1.21\n",
+		"cmd/shop/main.go":        "package main\n\nimport (\n\t\"example.com/shop/internal/orders\"\n\t\"example.com/shop/internal/store\"\n)\n\nfunc main() { orders.Place(store.New()) }\n",
+		"internal/orders/svc.go":  "package orders\n\nimport (\n\t\"example.com/shop/internal/model\"\n\t\"example.com/shop/internal/money\"\n)\n\nfunc Place(r model.Repo) { _ = money.Zero }\n",
+		"internal/store/db.go":    "package store\n\nimport (\n\t\"database/sql\"\n\n\t\"example.com/shop/internal/model\"\n)\n\nvar _ *sql.DB\n\nfunc New() model.Repo { return nil }\n",
+		"internal/model/model.go": "package model\n\nimport \"example.com/shop/internal/money\"\n\ntype Repo interface{ Total() money.Amount }\n",
+		"internal/money/money.go": "package money\n\ntype Amount int\n\nvar Zero Amount\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ws, err := NewParser(slog.New(slog.NewTextHandler(io.Discard, nil))).ParseWorkspace(dir)
+	if err != nil {
+		t.Fatalf("ParseWorkspace: %v", err)
+	}
+
+	want := map[string]struct {
+		layer   string
+		stratum int
+	}{
+		"cmd/shop":        {LayerApplication, 3},
+		"internal/orders": {LayerApplication, 2},
+		"internal/store":  {LayerInfrastructure, 2},
+		"internal/model":  {LayerDomain, 1},
+		"internal/money":  {LayerDomain, 0},
+	}
+	assignments := InferLayers(ws)
+	if len(assignments) != len(want) {
+		t.Fatalf("assignments = %d, want %d", len(assignments), len(want))
+	}
+	for _, a := range assignments {
+		w := want[a.Package]
+		if a.Layer != w.layer || a.Stratum != w.stratum {
+			t.Errorf("%s: layer %s, stratum %d (%s); want %s, %d", a.Package, a.Layer, a.Stratum, a.Reason, w.layer, w.stratum)
+		}
+	}
+}
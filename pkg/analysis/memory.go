@@ -0,0 +1,331 @@
+package analysis
+
+import (
+	"cmp"
+	"errors"
+	"go/ast"
+	"go/token"
+	gotypes "go/types"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	uniq "unique"
+	"unsafe"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// Holding every file's contents, syntax tree and type information takes
+// several GB on large monorepos. Three things bound it: LimitScope prunes
+// the syntax trees of the packages an operation can't touch,
+// SetMappedSources keeps file contents in memory-mapped files instead of
+// the Go heap, and the reference index interns the strings it holds.
+// MemoryReport estimates what each package holds.
+
+// LimitScope keeps the syntax trees and type information of only the
+// packages in scope and of the packages importing them, directly or not,
+// which hold every reference to their symbols. Patterns are directories
+// relative to the workspace root, where a trailing "/..." also matches the
+// directories below. The packages in scope are type-checked first, so the
+// packages they import are type-checked from their full syntax trees. The
+// other packages keep their symbol tables and type-checked packages, so
+// references to them still resolve, but their files are pruned to the
+// package clause and imports; RestorePruned parses them again. It returns
+// the pruned packages, sorted by path.
+func (p *GoParser) LimitScope(ws *types.Workspace, scope []string) []*types.Package {
+	targets := make(map[*types.Package]bool)
+	for _, pkg := range ws.Packages {
+		rel, err := filepath.Rel(ws.RootPath, pkg.Path)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		if slices.ContainsFunc(scope, func(pattern string) bool { return matchImportPattern(pattern, rel) }) {
+			targets[pkg] = true
+		}
+	}
+	inScope := importerClosure(ws, targets)
+
+	for pkg := range inScope {
+		p.EnsureTypeChecked(ws, pkg)
+	}
+	var pruned []*types.Package
+	for _, pkg := range ws.Packages {
+		if inScope[pkg] {
+			continue
+		}
+		for _, f := range pkg.Files {
+			pruneFile(f)
+		}
+		for _, f := range pkg.TestFiles {
+			pruneFile(f)
+		}
+		pkg.TypesInfo = nil
+		pruned = append(pruned, pkg)
+	}
+	slices.SortFunc(pruned, func(a, b *types.Package) int { return cmp.Compare(a.Path, b.Path) })
+	p.logger.Info("limited workspace to scope", "scope", scope, "packages", len(inScope), "pruned", len(pruned))
+	return pruned
+}
+
+// pruneFile replaces the syntax tree of f by one holding only its package
+// clause and import declarations.
+func pruneFile(f *types.File) {
+	if f.AST == nil || f.Pruned {
+		return
+	}
+	stub := &ast.File{
+		Package:   f.AST.Package,
+		Name:      f.AST.Name,
+		FileStart: f.AST.FileStart,
+		FileEnd:   f.AST.FileEnd,
+		Imports:   f.AST.Imports,
+		GoVersion: f.AST.GoVersion,
+	}
+	for _, decl := range f.AST.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			stub.Decls = append(stub.Decls, gen)
+		}
+	}
+	f.AST = stub
+	f.Pruned = true
+}
+
+// RestorePruned parses the pruned files of pkg again from their contents,
+// reporting whether there were any. The caller then rebuilds the package's
+// symbol table, whose positions are into the old syntax trees.
+func (p *GoParser) RestorePruned(pkg *types.Package) (bool, error) {
+	restored := false
+	for _, f := range packageFiles(pkg) {
+		if !f.Pruned {
+			continue
+		}
+		parsed, err := p.ParseSource(f.Path, f.OriginalContent)
+		if err != nil {
+			return restored, err
+		}
+		f.AST, f.Pruned = parsed.AST, false
+		restored = true
+	}
+	return restored, nil
+}
+
+// SetMappedSources makes ParseFile keep the contents of the files it reads
+// in memory-mapped temporary files rather than on the Go heap, where the
+// kernel can page them out and the garbage collector doesn't scan them.
+// The mappings last as long as the parser. It reports false on platforms
+// without memory mapping, where contents stay on the heap.
+func (p *GoParser) SetMappedSources(on bool) bool {
+	if !on {
+		p.sources = nil
+		return true
+	}
+	if !canMapSources {
+		return false
+	}
+	if p.sources == nil {
+		p.sources = &sourceArena{}
+	}
+	return true
+}
+
+// sourceChunk is the size of the mappings file contents are stored in;
+// larger files get a mapping of their own.
+const sourceChunk = 64 << 20
+
+// sourceArena stores file contents in read-only shared mappings of unlinked
+// temporary files. Contents are written with WriteAt, so a full disk fails
+// the write, and the contents stay on the heap, instead of faulting on
+// access to the mapping.
+type sourceArena struct {
+	mu       sync.Mutex
+	file     *os.File // Backs the current mapping
+	data     []byte   // The current mapping
+	used     int      // Bytes of data stored
+	mappings [][]byte // Every mapping made
+}
+
+// store returns content as stored in the arena, or content itself when it
+// can't be.
+func (a *sourceArena) store(content []byte) []byte {
+	if len(content) == 0 {
+		return content
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.data)-a.used < len(content) {
+		if err := a.grow(len(content)); err != nil {
+			return content
+		}
+	}
+	if _, err := a.file.WriteAt(content, int64(a.used)); err != nil {
+		return content
+	}
+	end := a.used + len(content)
+	stored := a.data[a.used:end:end]
+	a.used = end
+	return stored
+}
+
+// grow starts a new mapping with room for need bytes.
+func (a *sourceArena) grow(need int) error {
+	size := max(sourceChunk, need)
+	f, err := os.CreateTemp("", "gorefactor-sources-*")
+	if err != nil {
+		return err
+	}
+	_ = os.Remove(f.Name())
+	if err := f.Truncate(int64(size)); err != nil {
+		f.Close()
+		return err
+	}
+	data, err := mapFile(f, size)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	// Mappings outlive the file they were made from.
+	if a.file != nil {
+		a.file.Close()
+	}
+	a.file, a.data, a.used = f, data, 0
+	a.mappings = append(a.mappings, data)
+	return nil
+}
+
+// holds reports whether b is stored in the arena.
+func (a *sourceArena) holds(b []byte) bool {
+	if a == nil || len(b) == 0 {
+		return false
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	ptr := uintptr(unsafe.Pointer(unsafe.SliceData(b)))
+	for _, m := range a.mappings {
+		start := uintptr(unsafe.Pointer(unsafe.SliceData(m)))
+		if ptr >= start && ptr < start+uintptr(len(m)) {
+			return true
+		}
+	}
+	return false
+}
+
+// packageFiles returns the files of pkg, test files included.
+func packageFiles(pkg *types.Package) []*types.File {
+	files := slices.Collect(maps.Values(pkg.Files))
+	return append(files, slices.Collect(maps.Values(pkg.TestFiles))...)
+}
+
+// errNoMapping is returned by mapFile where memory mapping is unsupported.
+var errNoMapping = errors.New("memory-mapped sources are not supported on this platform")
+
+// intern returns the canonical copy of s, so the many index entries naming
+// the same identifier share one string rather than each keeping its
+// syntax tree's alive.
+func intern(s string) string {
+	return uniq.Make(s).Value()
+}
+
+// PackageMemory estimates the memory the files of a package hold.
+type PackageMemory struct {
+	Package     string `json:"package"` // Import path, or directory without one
+	Files       int    `json:"files"`
+	Pruned      bool   `json:"pruned,omitempty"` // Outside the scope the workspace was limited to
+	SourceBytes int64  `json:"source_bytes"`     // File contents on the Go heap
+	MappedBytes int64  `json:"mapped_bytes"`     // File contents in memory-mapped files
+	ASTNodes    int    `json:"ast_nodes"`        // Nodes of the syntax trees
+	ASTBytes    int64  `json:"ast_bytes"`        // Estimated size of the syntax trees
+	TypesBytes  int64  `json:"types_bytes"`      // Estimated size of the type information
+	HeapBytes   int64  `json:"heap_bytes"`       // Estimated total on the Go heap
+}
+
+// mapEntryOverhead approximates the bytes a Go map spends per entry beyond
+// its key and value.
+const mapEntryOverhead = 8
+
+// MemoryReport estimates the memory each package of ws holds, largest on
+// the heap first. Sizes of syntax trees and type information count their
+// nodes and map entries, not what they share with other packages.
+func (p *GoParser) MemoryReport(ws *types.Workspace) []PackageMemory {
+	var report []PackageMemory
+	for _, pkg := range ws.Packages {
+		pm := PackageMemory{Package: cmp.Or(pkg.ImportPath, pkg.Path)}
+		for _, f := range packageFiles(pkg) {
+			pm.Files++
+			pm.Pruned = pm.Pruned || f.Pruned
+			if p.sources.holds(f.OriginalContent) {
+				pm.MappedBytes += int64(len(f.OriginalContent))
+			} else {
+				pm.SourceBytes += int64(len(f.OriginalContent))
+			}
+			nodes, size := astSize(f.AST)
+			pm.ASTNodes += nodes
+			pm.ASTBytes += size
+		}
+		pm.TypesBytes = typesInfoSize(pkg.TypesInfo)
+		pm.HeapBytes = pm.SourceBytes + pm.ASTBytes + pm.TypesBytes
+		report = append(report, pm)
+	}
+	slices.SortFunc(report, func(a, b PackageMemory) int {
+		return cmp.Or(cmp.Compare(b.HeapBytes, a.HeapBytes), cmp.Compare(a.Package, b.Package))
+	})
+	return report
+}
+
+// astSize counts the nodes of f and estimates their size: each node's
+// struct and the text of its identifiers, literals and comments.
+func astSize(f *ast.File) (int, int64) {
+	if f == nil {
+		return 0, 0
+	}
+	nodes, size := 0, int64(0)
+	count := func(n ast.Node) {
+		nodes++
+		size += int64(reflect.TypeOf(n).Elem().Size())
+		switch n := n.(type) {
+		case *ast.Ident:
+			size += int64(len(n.Name))
+		case *ast.BasicLit:
+			size += int64(len(n.Value))
+		case *ast.Comment:
+			size += int64(len(n.Text))
+		}
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n.(type) {
+		case nil:
+			return false
+		case *ast.CommentGroup:
+			// Counted with the file's comments below.
+			return false
+		}
+		count(n)
+		return true
+	})
+	for _, group := range f.Comments {
+		count(group)
+		for _, c := range group.List {
+			count(c)
+		}
+	}
+	return nodes, size
+}
+
+// typesInfoSize estimates the size of the entries of info's maps.
+func typesInfoSize(info *gotypes.Info) int64 {
+	if info == nil {
+		return 0
+	}
+	return mapSize(info.Types) + mapSize(info.Defs) + mapSize(info.Uses) + mapSize(info.Implicits) +
+		mapSize(info.Selections) + mapSize(info.Instances) + mapSize(info.Scopes) + mapSize(info.FileVersions)
+}
+
+func mapSize[K comparable, V any](m map[K]V) int64 {
+	var k K
+	var v V
+	return int64(len(m)) * int64(unsafe.Sizeof(k)+unsafe.Sizeof(v)+mapEntryOverhead)
+}
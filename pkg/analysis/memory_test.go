@@ -0,0 +1,137 @@
+package analysis
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// writeScopeWorkspace writes a module where app imports store, which imports
+// lib, and other stands alone, and parses it with p.
+func writeScopeWorkspace(t *testing.T, p *GoParser) *types.Workspace {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/scope\n\ngo 1.21\n",
+		"lib/lib.go": `package lib
+
+// Key normalizes a key.
+func Key(s string) string { return s }
+`,
+		"store/store.go": `package store
+
+import "example.com/scope/lib"
+
+func Get(key string) string { return lib.Key(key) }
+`,
+		"app/app.go": `package app
+
+import "example.com/scope/store"
+
+func Run() string { return store.Get("a") }
+`,
+		"other/other.go": `package other
+
+func Alone() int { return 42 }
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ws, err := p.ParseWorkspace(dir)
+	if err != nil {
+		t.Fatalf("ParseWorkspace: %v", err)
+	}
+	resolver := NewSymbolResolver(ws, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	for _, pkg := range ws.Packages {
+		if _, err := resolver.BuildSymbolTable(pkg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return ws
+}
+
+func TestLimitScope(t *testing.T) {
+	p := NewParser(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ws := writeScopeWorkspace(t, p)
+	pkg := func(name string) *types.Package { return ws.Packages[filepath.Join(ws.RootPath, name)] }
+
+	pruned := p.LimitScope(ws, []string{"store"})
+	if len(pruned) != 2 || pruned[0] != pkg("lib") || pruned[1] != pkg("other") {
+		t.Fatalf("expected lib and other to be pruned, got %v", pruned)
+	}
+	// app imports store, so holds references to it.
+	for _, name := range []string{"store", "app"} {
+		if f := pkg(name).Files[name+".go"]; f.Pruned || len(f.AST.Decls) == 0 || pkg(name).TypesInfo == nil {
+			t.Errorf("%s: expected full syntax tree and type information", name)
+		}
+	}
+
+	lib := pkg("lib")
+	f := lib.Files["lib.go"]
+	if !f.Pruned || len(f.AST.Decls) != 0 || lib.TypesInfo != nil {
+		t.Errorf("lib: expected a pruned file without type information, got %d declarations", len(f.AST.Decls))
+	}
+	if lib.TypesPkg == nil || lib.TypesPkg.Scope().Lookup("Key") == nil || lib.Symbols.Functions["Key"] == nil {
+		t.Error("lib: expected its types and symbols to be kept for store to resolve")
+	}
+	if pkg("other").TypesPkg != nil {
+		t.Error("other: nothing in scope imports it, so it shouldn't be type-checked")
+	}
+
+	report := p.MemoryReport(ws)
+	for _, pm := range report {
+		if pm.Package == "example.com/scope/lib" && (!pm.Pruned || pm.TypesBytes != 0) {
+			t.Errorf("unexpected report for lib: %+v", pm)
+		}
+		if pm.Package == "example.com/scope/store" && (pm.Pruned || pm.ASTNodes == 0 || pm.TypesBytes == 0) {
+			t.Errorf("unexpected report for store: %+v", pm)
+		}
+	}
+
+	if restored, err := p.RestorePruned(lib); err != nil || !restored {
+		t.Fatalf("RestorePruned: %v, %v", restored, err)
+	}
+	if f := lib.Files["lib.go"]; f.Pruned || len(f.AST.Decls) != 1 || f.AST.Comments == nil {
+		t.Error("lib: expected RestorePruned to parse the file in full")
+	}
+}
+
+func TestMappedSources(t *testing.T) {
+	p := NewParser(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if !p.SetMappedSources(true) {
+		t.Skip("memory-mapped sources are not supported on this platform")
+	}
+	ws := writeScopeWorkspace(t, p)
+
+	for _, pkg := range ws.Packages {
+		for _, f := range pkg.Files {
+			content, err := os.ReadFile(f.Path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(f.OriginalContent, content) {
+				t.Errorf("%s: mapped contents differ from the file", f.Path)
+			}
+			if cap(f.OriginalContent) != len(f.OriginalContent) {
+				t.Errorf("%s: appending to the contents would write into the mapping", f.Path)
+			}
+		}
+	}
+	for _, pm := range p.MemoryReport(ws) {
+		if pm.MappedBytes == 0 || pm.SourceBytes != 0 {
+			t.Errorf("%s: expected contents to be mapped, got %+v", pm.Package, pm)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd)
+
+package analysis
+
+import "os"
+
+// canMapSources reports whether file contents can be memory-mapped.
+const canMapSources = false
+
+// mapFile fails: file contents stay on the heap on this platform.
+func mapFile(f *os.File, size int) ([]byte, error) {
+	return nil, errNoMapping
+}
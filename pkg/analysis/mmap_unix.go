@@ -0,0 +1,17 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+
+package analysis
+
+import (
+	"os"
+	"syscall"
+)
+
+// canMapSources reports whether file contents can be memory-mapped.
+const canMapSources = true
+
+// mapFile maps the first size bytes of f read-only and shared, so writes to
+// f show through the mapping.
+func mapFile(f *os.File, size int) ([]byte, error) {
+	return syscall.Mmap(int(f.Fd()), 0, size, syscall.PROT_READ, syscall.MAP_SHARED)
+}
@@ -0,0 +1,233 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	gotypes "go/types"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/go/packages"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// packagesLoadMode is what LoadWorkspacePackages asks go/packages for:
+// syntax and type information of the workspace's packages and, through
+// NeedDeps, of everything they import.
+const packagesLoadMode = packages.NeedName | packages.NeedFiles | packages.NeedCompiledGoFiles |
+	packages.NeedImports | packages.NeedDeps | packages.NeedSyntax | packages.NeedTypes |
+	packages.NeedTypesInfo | packages.NeedModule
+
+// LoadWorkspacePackages loads the packages of the module at rootPath with
+// go/packages instead of parsing its directories, so that the build system
+// decides which files make up each package: cgo, vendoring, build
+// constraints and replace directives are handled the way go build handles
+// them. Packages are type-checked against their real dependencies as part
+// of loading, and those dependencies stay available to later type checks.
+//
+// The workspace has the same shape as ParseWorkspace's. The Go files of a
+// package's directory that its build excludes, and the cgo files whose
+// compiled form is generated, are parsed on their own and have no type
+// information. Packages of nested modules and directories the go command
+// ignores (testdata, _ and . prefixed) are not loaded.
+func (p *GoParser) LoadWorkspacePackages(ctx context.Context, rootPath string) (*types.Workspace, error) {
+	p.logger.Info("loading workspace packages", "path", rootPath)
+
+	workspace, err := p.newWorkspace(rootPath)
+	if err != nil {
+		return nil, err
+	}
+	root := workspace.RootPath
+
+	// The content of the workspace's files, as go/packages read it
+	var mu sync.Mutex
+	sources := make(map[string][]byte)
+	cfg := &packages.Config{
+		Context: ctx,
+		Mode:    packagesLoadMode,
+		Dir:     root,
+		Fset:    p.fileSet,
+		Tests:   true,
+		ParseFile: func(fset *token.FileSet, filename string, src []byte) (*ast.File, error) {
+			if !withinDir(root, filename) {
+				return parser.ParseFile(fset, filename, src, parser.AllErrors|parser.SkipObjectResolution)
+			}
+			mu.Lock()
+			sources[filename] = src
+			mu.Unlock()
+			return parser.ParseFile(fset, filename, src, parser.AllErrors|parser.ParseComments)
+		},
+	}
+	loaded, err := packages.Load(cfg, "./...")
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
+	if err != nil {
+		p.logger.Error("loading packages failed", "path", rootPath, "err", err)
+		return nil, &types.RefactorError{
+			Type:    types.ParseError,
+			Message: fmt.Sprintf("failed to load packages: %v", err),
+			File:    rootPath,
+			Cause:   err,
+		}
+	}
+
+	// Every package loaded, dependencies included, for the importer
+	deps := make(map[string]*gotypes.Package)
+	packages.Visit(loaded, nil, func(lp *packages.Package) {
+		if lp.Types != nil && lp.ID == lp.PkgPath {
+			deps[lp.PkgPath] = lp.Types
+		}
+	})
+	importer := newWorkspaceImporter(workspace, p.fileSet, p, deps)
+
+	// Test variants hold the _test.go files, in-package and external
+	testFiles := make(map[string]map[string]*types.File)
+	for _, lp := range loaded {
+		for _, f := range lp.Syntax {
+			name := p.fileSet.File(f.FileStart).Name()
+			dir := filepath.Dir(name)
+			if !strings.HasSuffix(name, "_test.go") || !withinDir(root, name) {
+				continue
+			}
+			if testFiles[dir] == nil {
+				testFiles[dir] = make(map[string]*types.File)
+			}
+			if _, ok := testFiles[dir][filepath.Base(name)]; !ok {
+				testFiles[dir][filepath.Base(name)] = &types.File{
+					Path:            name,
+					AST:             f,
+					OriginalContent: sources[name],
+					Modifications:   make([]types.Modification, 0),
+				}
+			}
+		}
+	}
+
+	var plain []*packages.Package
+	for _, lp := range loaded {
+		if lp.ID == lp.PkgPath && len(lp.GoFiles) > 0 {
+			plain = append(plain, lp)
+		}
+	}
+	for i, lp := range plain {
+		dir := filepath.Dir(lp.GoFiles[0])
+		if !withinDir(root, dir) || p.isExcluded(root, dir) {
+			continue
+		}
+		for _, e := range lp.Errors {
+			p.logger.Debug("package loaded with errors", "package", lp.PkgPath, "err", e)
+		}
+		pkg, err := p.packageFromLoaded(lp, dir, sources, testFiles[dir])
+		if err != nil {
+			return nil, err
+		}
+		if len(lp.TypeErrors) == 0 && !lp.IllTyped {
+			pkg.TypesPkg = lp.Types
+		} else {
+			importer.setPartial(pkg.ImportPath, lp.Types)
+		}
+		workspace.Packages[dir] = pkg
+		workspace.ImportToPath[pkg.ImportPath] = dir
+		if p.progress != nil {
+			p.progress(types.ProgressEvent{
+				Phase:   types.PhaseParse,
+				Package: dir,
+				Current: i + 1,
+				Total:   len(plain),
+			})
+		}
+	}
+
+	p.logger.Info("workspace packages loaded", "packages", len(workspace.Packages), "module", workspace.Module)
+	p.importer = importer
+	return workspace, nil
+}
+
+// packageFromLoaded converts a package go/packages loaded into the
+// workspace's model, with tests as its test files.
+func (p *GoParser) packageFromLoaded(lp *packages.Package, dir string, sources map[string][]byte, tests map[string]*types.File) (*types.Package, error) {
+	pkg := &types.Package{
+		Path:       dir,
+		Dir:        dir,
+		ImportPath: lp.PkgPath,
+		Name:       lp.Name,
+		Files:      make(map[string]*types.File),
+		TestFiles:  make(map[string]*types.File),
+		Imports:    make([]string, 0),
+		TypesInfo:  lp.TypesInfo,
+	}
+
+	compiled := make(map[string]*ast.File)
+	for _, f := range lp.Syntax {
+		compiled[p.fileSet.File(f.FileStart).Name()] = f
+	}
+	for _, name := range slices.Concat(lp.GoFiles, lp.IgnoredFiles) {
+		if !strings.HasSuffix(name, ".go") || filepath.Dir(name) != dir {
+			continue
+		}
+		base := filepath.Base(name)
+		if strings.HasSuffix(name, "_test.go") {
+			if tests[base] != nil {
+				continue
+			}
+		} else if pkg.Files[base] != nil {
+			continue
+		}
+
+		file := &types.File{Path: name, Modifications: make([]types.Modification, 0)}
+		if f, ok := compiled[name]; ok {
+			file.AST, file.OriginalContent = f, sources[name]
+		} else {
+			// Excluded from the build, or compiled from generated cgo output
+			parsed, err := p.ParseFile(name)
+			if err != nil {
+				return nil, err
+			}
+			file = parsed
+		}
+		if strings.HasSuffix(name, "_test.go") {
+			tests = withFile(tests, base, file)
+		} else {
+			pkg.Files[base] = file
+		}
+	}
+	maps.Copy(pkg.TestFiles, tests)
+	for _, file := range pkg.TestFiles {
+		file.Package = pkg
+	}
+
+	for _, base := range slices.Sorted(maps.Keys(pkg.Files)) {
+		file := pkg.Files[base]
+		file.Package = pkg
+		for _, imp := range file.AST.Imports {
+			importPath := strings.Trim(imp.Path.Value, "\"")
+			if !contains(pkg.Imports, importPath) {
+				pkg.Imports = append(pkg.Imports, importPath)
+			}
+		}
+	}
+	return pkg, nil
+}
+
+// withFile adds file to files under base, allocating the map if needed.
+func withFile(files map[string]*types.File, base string, file *types.File) map[string]*types.File {
+	if files == nil {
+		files = make(map[string]*types.File)
+	}
+	files[base] = file
+	return files
+}
+
+// withinDir reports whether path is dir or lies below it.
+func withinDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
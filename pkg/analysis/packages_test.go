@@ -0,0 +1,84 @@
+package analysis
+
+import (
+	"context"
+	"go/ast"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadWorkspacePackages(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":         "module example.com/m\n\ngo 1.22\n",
+		"a/a.go":         "package a\n\nimport \"strings\"\n\nfunc Upper(s string) string { return strings.ToUpper(s) }\n",
+		"a/ignored.go":   "//go:build ignore\n\npackage a\n\nfunc Ignored() {}\n",
+		"a/a_test.go":    "package a\n\nimport \"testing\"\n\nfunc TestUpper(t *testing.T) { Upper(\"a\") }\n",
+		"a/ext_test.go":  "package a_test\n\nimport (\n\t\"testing\"\n\n\t\"example.com/m/a\"\n)\n\nfunc TestExt(t *testing.T) { a.Upper(\"a\") }\n",
+		"b/b.go":         "package b\n\nimport \"example.com/m/a\"\n\nvar B = a.Upper(\"b\")\n",
+		"testdata/t.go":  "package testdata\n",
+		"only/x_test.go": "package only\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p := NewParser(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ws, err := p.LoadWorkspacePackages(context.Background(), dir)
+	if err != nil {
+		t.Fatalf("LoadWorkspacePackages: %v", err)
+	}
+	if len(ws.Packages) != 2 {
+		t.Fatalf("expected packages a and b, got %d packages", len(ws.Packages))
+	}
+
+	a := ws.Packages[filepath.Join(dir, "a")]
+	if a == nil || a.ImportPath != "example.com/m/a" || a.Name != "a" {
+		t.Fatalf("unexpected package a: %+v", a)
+	}
+	if ws.ImportToPath["example.com/m/a"] != a.Path {
+		t.Errorf("ImportToPath maps a to %q", ws.ImportToPath["example.com/m/a"])
+	}
+	for _, name := range []string{"a.go", "ignored.go"} {
+		if f := a.Files[name]; f == nil || f.AST == nil || len(f.OriginalContent) == 0 || f.Package != a {
+			t.Errorf("file %s not loaded", name)
+		}
+	}
+	for _, name := range []string{"a_test.go", "ext_test.go"} {
+		if f := a.TestFiles[name]; f == nil || f.AST == nil || len(f.OriginalContent) == 0 {
+			t.Errorf("test file %s not loaded", name)
+		}
+	}
+	if a.TypesPkg == nil || a.TypesInfo == nil {
+		t.Fatal("package a is not type-checked")
+	}
+
+	// The call of strings.ToUpper resolves to the standard library's function
+	var toUpper *ast.Ident
+	ast.Inspect(a.Files["a.go"].AST, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok && sel.Sel.Name == "ToUpper" {
+			toUpper = sel.Sel
+		}
+		return true
+	})
+	if obj := a.TypesInfo.Uses[toUpper]; obj == nil || obj.Pkg().Path() != "strings" {
+		t.Errorf("strings.ToUpper resolved to %v", obj)
+	}
+
+	// Type-checking b again after loading imports a's loaded types
+	b := ws.Packages[filepath.Join(dir, "b")]
+	b.TypesPkg = nil
+	p.TypeCheckPackage(ws, b)
+	if b.TypesPkg == nil || b.TypesPkg.Imports()[0] != a.TypesPkg {
+		t.Errorf("re-checked b doesn't import the loaded a")
+	}
+}
@@ -1,6 +1,7 @@
 package analysis
 
 import (
+	"context"
 	"fmt"
 	"go/ast"
 	"go/importer"
@@ -9,21 +10,27 @@ import (
 	gotypes "go/types"
 	"io/fs"
 	"log/slog"
+	"maps"
 	"os"
 	"path/filepath"
 	"runtime"
 	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/mamaar/gorefactor/pkg/types"
 )
 
 // Parser handles Go code parsing and AST management
 type GoParser struct {
-	fileSet  *token.FileSet
-	logger   *slog.Logger
-	importer *workspaceImporter
+	fileSet     *token.FileSet
+	logger      *slog.Logger
+	importer    *workspaceImporter
+	excludeDirs []string
+	progress    types.ProgressReporter
+	checkMu     sync.Mutex   // Held while EnsureTypeChecked fills in a package
+	sources     *sourceArena // Set by SetMappedSources
 }
 
 func NewParser(logger *slog.Logger) *GoParser {
@@ -33,6 +40,40 @@ func NewParser(logger *slog.Logger) *GoParser {
 	}
 }
 
+// SetExcludedDirs sets directories, relative to the workspace root, that
+// ParseWorkspace skips along with everything below them.
+func (p *GoParser) SetExcludedDirs(dirs []string) {
+	p.excludeDirs = dirs
+}
+
+// SetProgressReporter sets a reporter that receives a PhaseParse event for
+// every package parsed by ParseWorkspace.
+func (p *GoParser) SetProgressReporter(r types.ProgressReporter) {
+	p.progress = r
+}
+
+// isExcluded reports whether dir lies within one of the excluded directories.
+func (p *GoParser) isExcluded(root, dir string) bool {
+	if len(p.excludeDirs) == 0 {
+		return false
+	}
+	rel, err := filepath.Rel(root, dir)
+	if err != nil {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+	for _, ex := range p.excludeDirs {
+		ex = strings.Trim(filepath.ToSlash(filepath.Clean(ex)), "/")
+		if ex == "." || ex == "" {
+			continue
+		}
+		if rel == ex || strings.HasPrefix(rel, ex+"/") {
+			return true
+		}
+	}
+	return false
+}
+
 // ParseFile parses a single Go file
 func (p *GoParser) ParseFile(filename string) (*types.File, error) {
 	content, err := os.ReadFile(filename)
@@ -44,7 +85,16 @@ func (p *GoParser) ParseFile(filename string) (*types.File, error) {
 			Cause:   err,
 		}
 	}
+	if p.sources != nil {
+		content = p.sources.store(content)
+	}
 
+	return p.ParseSource(filename, content)
+}
+
+// ParseSource parses content as the Go file filename, without reading it
+// from disk.
+func (p *GoParser) ParseSource(filename string, content []byte) (*types.File, error) {
 	astFile, err := parser.ParseFile(p.fileSet, filename, content, parser.ParseComments)
 	if err != nil {
 		return nil, &types.RefactorError{
@@ -144,65 +194,25 @@ func (p *GoParser) ParsePackage(dir string) (*types.Package, error) {
 // Package directories are discovered sequentially, then parsed in parallel
 // using a bounded worker pool (runtime.NumCPU goroutines).
 func (p *GoParser) ParseWorkspace(rootPath string) (*types.Workspace, error) {
+	return p.ParseWorkspaceContext(context.Background(), rootPath)
+}
+
+// ParseWorkspaceContext is like ParseWorkspace but stops discovering and
+// parsing packages once ctx is canceled, returning ctx.Err().
+func (p *GoParser) ParseWorkspaceContext(ctx context.Context, rootPath string) (*types.Workspace, error) {
 	p.logger.Info("parsing workspace", "path", rootPath)
 
-	// Convert to absolute path for consistency
-	absRootPath, err := filepath.Abs(rootPath)
+	workspace, err := p.newWorkspace(rootPath)
 	if err != nil {
-		p.logger.Error("failed to get absolute path", "path", rootPath, "err", err)
-		return nil, &types.RefactorError{
-			Type:    types.FileSystemError,
-			Message: fmt.Sprintf("failed to get absolute path for workspace: %v", err),
-			File:    rootPath,
-		}
-	}
-
-	workspace := &types.Workspace{
-		RootPath:     absRootPath,
-		Packages:     make(map[string]*types.Package),
-		ImportToPath: make(map[string]string),
-		FileSet:      p.fileSet,
-	}
-
-	// Try to find and parse go.mod
-	goModPath := filepath.Join(absRootPath, "go.mod")
-	if modContent, err := os.ReadFile(goModPath); err == nil {
-		module, err := p.parseGoMod(modContent)
-		if err != nil {
-			return nil, err
-		}
-		workspace.Module = module
+		return nil, err
 	}
+	absRootPath := workspace.RootPath
 
 	// Phase 1: Discover package directories (sequential — filesystem walk is I/O bound and fast)
-	var pkgDirs []string
-	err = filepath.WalkDir(absRootPath, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-
-		// Skip hidden directories and vendor
-		if d.IsDir() {
-			name := d.Name()
-			if strings.HasPrefix(name, ".") || name == "vendor" {
-				return filepath.SkipDir
-			}
-		}
-
-		// Collect directories containing .go files
-		if d.IsDir() {
-			hasGoFiles, err := p.hasGoFiles(path)
-			if err != nil {
-				return err
-			}
-			if hasGoFiles {
-				pkgDirs = append(pkgDirs, path)
-			}
-		}
-
-		return nil
-	})
-
+	pkgDirs, err := p.discoverPackageDirs(ctx, absRootPath)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
 	if err != nil {
 		p.logger.Error("workspace discovery failed", "path", rootPath, "err", err)
 		return nil, &types.RefactorError{
@@ -230,6 +240,7 @@ func (p *GoParser) ParseWorkspace(rootPath string) (*types.Workspace, error) {
 	}
 
 	var wg sync.WaitGroup
+	var parsed atomic.Int64
 	dirCh := make(chan int, len(pkgDirs))
 
 	for i := range pkgDirs {
@@ -240,13 +251,27 @@ func (p *GoParser) ParseWorkspace(rootPath string) (*types.Workspace, error) {
 	for w := 0; w < workers; w++ {
 		wg.Go(func() {
 			for idx := range dirCh {
+				if ctx.Err() != nil {
+					return
+				}
 				pkg, err := p.ParsePackage(pkgDirs[idx])
 				results[idx] = pkgResult{pkg: pkg, err: err}
+				if p.progress != nil {
+					p.progress(types.ProgressEvent{
+						Phase:   types.PhaseParse,
+						Package: pkgDirs[idx],
+						Current: int(parsed.Add(1)),
+						Total:   len(pkgDirs),
+					})
+				}
 			}
 		})
 	}
 
 	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
 	// Collect results
 	for i, res := range results {
@@ -271,8 +296,79 @@ func (p *GoParser) ParseWorkspace(rootPath string) (*types.Workspace, error) {
 
 	// Create a single importer instance for this workspace to ensure consistent
 	// stdlib type identities across all TypeCheckPackage calls.
-	p.importer = &workspaceImporter{ws: workspace, fset: workspace.FileSet, parser: p}
+	p.importer = newWorkspaceImporter(workspace, workspace.FileSet, p, nil)
+
+	return workspace, nil
+}
+
+// discoverPackageDirs returns the directories under root holding Go files,
+// skipping hidden, vendor and excluded directories.
+func (p *GoParser) discoverPackageDirs(ctx context.Context, root string) ([]string, error) {
+	var pkgDirs []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// Skip hidden directories and vendor
+		if d.IsDir() {
+			name := d.Name()
+			if strings.HasPrefix(name, ".") || name == "vendor" {
+				return filepath.SkipDir
+			}
+			if p.isExcluded(root, path) {
+				return filepath.SkipDir
+			}
+		}
+
+		// Collect directories containing .go files
+		if d.IsDir() {
+			hasGoFiles, err := p.hasGoFiles(path)
+			if err != nil {
+				return err
+			}
+			if hasGoFiles {
+				pkgDirs = append(pkgDirs, path)
+			}
+		}
+
+		return nil
+	})
+	return pkgDirs, err
+}
+
+// newWorkspace returns an empty workspace rooted at rootPath, with the module
+// declared by its go.mod if it has one.
+func (p *GoParser) newWorkspace(rootPath string) (*types.Workspace, error) {
+	absRootPath, err := filepath.Abs(rootPath)
+	if err != nil {
+		p.logger.Error("failed to get absolute path", "path", rootPath, "err", err)
+		return nil, &types.RefactorError{
+			Type:    types.FileSystemError,
+			Message: fmt.Sprintf("failed to get absolute path for workspace: %v", err),
+			File:    rootPath,
+		}
+	}
+
+	workspace := &types.Workspace{
+		RootPath:     absRootPath,
+		Packages:     make(map[string]*types.Package),
+		ImportToPath: make(map[string]string),
+		FileSet:      p.fileSet,
+	}
 
+	// Try to find and parse go.mod
+	goModPath := filepath.Join(absRootPath, "go.mod")
+	if modContent, err := os.ReadFile(goModPath); err == nil {
+		module, err := p.parseGoMod(modContent)
+		if err != nil {
+			return nil, err
+		}
+		workspace.Module = module
+	}
 	return workspace, nil
 }
 
@@ -316,6 +412,9 @@ func (p *GoParser) parseGoMod(content []byte) (*types.Module, error) {
 		if strings.HasPrefix(line, "module ") {
 			module.Path = strings.TrimSpace(strings.TrimPrefix(line, "module"))
 		}
+		if v, ok := strings.CutPrefix(line, "go "); ok {
+			module.GoVersion, _, _ = strings.Cut(strings.TrimSpace(v), " ")
+		}
 		// Note: Version parsing would be more complex in real implementation
 	}
 
@@ -393,7 +492,11 @@ func contains(slice []string, item string) bool {
 
 // EnsureTypeChecked runs type-checking on a package if it hasn't been done yet.
 // This enables lazy/on-demand type-checking instead of eager upfront checking.
+// Concurrent callers, such as read-only tool calls sharing a workspace, are
+// serialized, so a package is checked once and never by two at a time.
 func (p *GoParser) EnsureTypeChecked(ws *types.Workspace, pkg *types.Package) {
+	p.checkMu.Lock()
+	defer p.checkMu.Unlock()
 	if pkg.TypesPkg != nil {
 		return
 	}
@@ -416,13 +519,20 @@ func (p *GoParser) TypeCheckPackage(ws *types.Workspace, pkg *types.Package) {
 	}
 
 	conf := gotypes.Config{
-		Importer: p.importer,
+		Importer: p.importerFor(ws),
 		Error:    func(err error) {}, // silently ignore type errors
 	}
+	// Beyond what the refactorings use, fill every map go/analysis passes
+	// expect to find.
 	info := &gotypes.Info{
-		Types: make(map[ast.Expr]gotypes.TypeAndValue),
-		Defs:  make(map[*ast.Ident]gotypes.Object),
-		Uses:  make(map[*ast.Ident]gotypes.Object),
+		Types:        make(map[ast.Expr]gotypes.TypeAndValue),
+		Defs:         make(map[*ast.Ident]gotypes.Object),
+		Uses:         make(map[*ast.Ident]gotypes.Object),
+		Implicits:    make(map[ast.Node]gotypes.Object),
+		Selections:   make(map[*ast.SelectorExpr]*gotypes.Selection),
+		Instances:    make(map[*ast.Ident]gotypes.Instance),
+		Scopes:       make(map[ast.Node]*gotypes.Scope),
+		FileVersions: make(map[*ast.File]string),
 	}
 
 	typesPkg, err := conf.Check(pkg.ImportPath, ws.FileSet, files, info)
@@ -430,19 +540,90 @@ func (p *GoParser) TypeCheckPackage(ws *types.Workspace, pkg *types.Package) {
 		p.logger.Debug("type-checking failed (falling back to AST inference)", "package", pkg.ImportPath, "err", err)
 		// Still store partial results — go/types populates info even on errors
 		pkg.TypesInfo = info
+		if p.importer != nil {
+			p.importer.setPartial(pkg.ImportPath, typesPkg)
+		}
 		return
 	}
 	pkg.TypesInfo = info
 	pkg.TypesPkg = typesPkg
 }
 
+// TypeCheckTests type-checks the test files of a package and returns their
+// type information, which is not stored on the package. In-package test
+// files are checked together with the package's other files, external
+// (package x_test) ones on their own. Errors are ignored as in
+// TypeCheckPackage, so the information may be partial.
+func (p *GoParser) TypeCheckTests(ws *types.Workspace, pkg *types.Package) *gotypes.Info {
+	p.EnsureTypeChecked(ws, pkg)
+	info := &gotypes.Info{
+		Types: make(map[ast.Expr]gotypes.TypeAndValue),
+		Defs:  make(map[*ast.Ident]gotypes.Object),
+		Uses:  make(map[*ast.Ident]gotypes.Object),
+	}
+	var internal, external []*ast.File
+	for _, name := range slices.Sorted(maps.Keys(pkg.TestFiles)) {
+		f := pkg.TestFiles[name]
+		switch {
+		case f.AST == nil:
+		case pkg.Name != "" && f.AST.Name.Name != pkg.Name:
+			external = append(external, f.AST)
+		default:
+			internal = append(internal, f.AST)
+		}
+	}
+
+	conf := gotypes.Config{
+		Importer: p.importerFor(ws),
+		Error:    func(err error) {},
+	}
+	if len(internal) > 0 {
+		files := internal
+		for _, name := range slices.Sorted(maps.Keys(pkg.Files)) {
+			if f := pkg.Files[name]; f.AST != nil {
+				files = append(files, f.AST)
+			}
+		}
+		_, _ = conf.Check(pkg.ImportPath, ws.FileSet, files, info)
+	}
+	if len(external) > 0 {
+		_, _ = conf.Check(pkg.ImportPath+"_test", ws.FileSet, external, info)
+	}
+	return info
+}
+
 // workspaceImporter implements go/types.Importer using workspace-local packages
 // with fallback to source-based importing for stdlib/external packages.
 type workspaceImporter struct {
-	ws     *types.Workspace
+	ws *types.Workspace
+	*importerState
+}
+
+// importerState is shared by the importers of every version of a workspace,
+// so stdlib and dependency packages keep one identity across them.
+type importerState struct {
 	fset   *token.FileSet
 	parser *GoParser
 	std    gotypes.Importer
+	loaded map[string]*gotypes.Package // Dependencies go/packages loaded, by import path
+
+	mu       sync.Mutex
+	checking map[string]bool             // Workspace packages being type-checked, by import path
+	partial  map[string]*gotypes.Package // Last result of packages that failed type-checking
+}
+
+func newWorkspaceImporter(ws *types.Workspace, fset *token.FileSet, parser *GoParser, loaded map[string]*gotypes.Package) *workspaceImporter {
+	return &workspaceImporter{ws: ws, importerState: &importerState{fset: fset, parser: parser, loaded: loaded}}
+}
+
+// importerFor returns the parser's importer resolving workspace packages in
+// ws, which may be a later version of the workspace it loaded, such as one
+// the watcher updated a copy of.
+func (p *GoParser) importerFor(ws *types.Workspace) *workspaceImporter {
+	if p.importer == nil || p.importer.ws == ws {
+		return p.importer
+	}
+	return &workspaceImporter{ws: ws, importerState: p.importer.importerState}
 }
 
 func (imp *workspaceImporter) Import(path string) (*gotypes.Package, error) {
@@ -452,16 +633,66 @@ func (imp *workspaceImporter) Import(path string) (*gotypes.Package, error) {
 			if pkg.TypesPkg != nil {
 				return pkg.TypesPkg, nil
 			}
-			// Type-check this dependency first (lazy/recursive)
+			// Type-check this dependency first (lazy/recursive). An import
+			// cycle would recurse forever, so it fails the import instead.
+			if !imp.begin(path) {
+				return nil, fmt.Errorf("import cycle through %s", path)
+			}
 			imp.parser.TypeCheckPackage(imp.ws, pkg)
+			imp.end(path)
 			if pkg.TypesPkg != nil {
 				return pkg.TypesPkg, nil
 			}
+			// A package with errors still declares most of its objects,
+			// e.g. every package of an import cycle; importers can resolve
+			// those.
+			if partial := imp.getPartial(path); partial != nil {
+				return partial, nil
+			}
 		}
 	}
+	if pkg, ok := imp.loaded[path]; ok {
+		return pkg, nil
+	}
 	// Fall back to stdlib/export data
 	if imp.std == nil {
 		imp.std = importer.Default()
 	}
 	return imp.std.Import(path)
 }
+
+// begin marks the package at path as being type-checked, reporting false
+// when it already is.
+func (imp *workspaceImporter) begin(path string) bool {
+	imp.mu.Lock()
+	defer imp.mu.Unlock()
+	if imp.checking[path] {
+		return false
+	}
+	if imp.checking == nil {
+		imp.checking = make(map[string]bool)
+	}
+	imp.checking[path] = true
+	return true
+}
+
+func (imp *workspaceImporter) end(path string) {
+	imp.mu.Lock()
+	defer imp.mu.Unlock()
+	delete(imp.checking, path)
+}
+
+func (imp *workspaceImporter) setPartial(path string, pkg *gotypes.Package) {
+	imp.mu.Lock()
+	defer imp.mu.Unlock()
+	if imp.partial == nil {
+		imp.partial = make(map[string]*gotypes.Package)
+	}
+	imp.partial[path] = pkg
+}
+
+func (imp *workspaceImporter) getPartial(path string) *gotypes.Package {
+	imp.mu.Lock()
+	defer imp.mu.Unlock()
+	return imp.partial[path]
+}
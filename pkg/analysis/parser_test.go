@@ -438,3 +438,35 @@ func Test() {
 		t.Error("Expected AST to remain unchanged when no modifications")
 	}
 }
+
+func TestParser_ParseWorkspace_ExcludedDirs(t *testing.T) {
+	parser := NewParser(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	parser.SetExcludedDirs([]string{"third_party"})
+
+	tempDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tempDir, "go.mod"), []byte("module test/workspace\n\ngo 1.21\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{"lib", filepath.Join("third_party", "dep")} {
+		if err := os.MkdirAll(filepath.Join(tempDir, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+		src := "package " + filepath.Base(dir) + "\n"
+		if err := os.WriteFile(filepath.Join(tempDir, dir, "x.go"), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ws, err := parser.ParseWorkspace(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to parse workspace: %v", err)
+	}
+	for _, pkg := range ws.Packages {
+		if pkg.Name == "dep" {
+			t.Errorf("expected excluded package %s to be skipped", pkg.Path)
+		}
+	}
+	if len(ws.Packages) != 1 {
+		t.Errorf("expected 1 package, got %d", len(ws.Packages))
+	}
+}
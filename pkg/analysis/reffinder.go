@@ -0,0 +1,112 @@
+package analysis
+
+import (
+	"go/token"
+	"sync"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// symbolKey identifies a symbol independently of the *types.Symbol pointer, so
+// symbols resolved through different resolvers share cached results.
+type symbolKey struct {
+	pkg  string
+	name string
+	kind types.SymbolKind
+	pos  token.Pos
+}
+
+// ReferenceFinder answers reference queries for the lifetime of a single plan
+// computation. It reuses the ReferenceIndex given up front, or else builds
+// on first use an index of just the packages that can refer to the symbols
+// of a package (see BuildReferenceIndexFor), and memoizes results per
+// symbol, so repeated lookups — e.g. in an operation's Validate and Execute —
+// walk the workspace only once.
+type ReferenceFinder struct {
+	resolver *SymbolResolver
+	once     sync.Once
+	idx      *ReferenceIndex
+
+	mu      sync.Mutex
+	results map[symbolKey][]*types.Reference
+	scoped  map[string]*ReferenceIndex // By symbol package, while idx is nil
+}
+
+// NewReferenceFinder creates a finder backed by resolver. If idx is nil
+// indexes are built lazily on the first queries.
+func NewReferenceFinder(resolver *SymbolResolver, idx *ReferenceIndex) *ReferenceFinder {
+	return &ReferenceFinder{
+		resolver: resolver,
+		idx:      idx,
+		results:  make(map[symbolKey][]*types.Reference),
+		scoped:   make(map[string]*ReferenceIndex),
+	}
+}
+
+// Resolver returns the resolver the finder queries.
+func (f *ReferenceFinder) Resolver() *SymbolResolver {
+	return f.resolver
+}
+
+// Index returns the reference index of the workspace, building it if
+// necessary. Queries use it from then on.
+func (f *ReferenceFinder) Index() *ReferenceIndex {
+	f.once.Do(func() {
+		f.mu.Lock()
+		idx := f.idx
+		f.mu.Unlock()
+		if idx == nil {
+			idx = f.resolver.BuildReferenceIndex()
+		}
+		f.mu.Lock()
+		f.idx = idx
+		f.mu.Unlock()
+	})
+	return f.idx
+}
+
+// indexFor returns the index to find references to symbol in.
+func (f *ReferenceFinder) indexFor(symbol *types.Symbol) *ReferenceIndex {
+	f.mu.Lock()
+	idx := f.idx
+	if idx == nil {
+		idx = f.scoped[symbol.Package]
+	}
+	f.mu.Unlock()
+	if idx != nil {
+		return idx
+	}
+
+	// Two queries may build the same index; the first stored wins.
+	idx = f.resolver.BuildReferenceIndexFor([]*types.Symbol{symbol}, nil)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if stored := f.scoped[symbol.Package]; stored != nil {
+		return stored
+	}
+	f.scoped[symbol.Package] = idx
+	return idx
+}
+
+// FindReferences returns the non-declaration references to symbol. Results
+// are shared between callers and must not be modified.
+func (f *ReferenceFinder) FindReferences(symbol *types.Symbol) ([]*types.Reference, error) {
+	key := symbolKey{pkg: symbol.Package, name: symbol.Name, kind: symbol.Kind, pos: symbol.Position}
+
+	f.mu.Lock()
+	refs, ok := f.results[key]
+	f.mu.Unlock()
+	if ok {
+		return refs, nil
+	}
+
+	refs, err := f.resolver.FindReferencesIndexed(symbol, f.indexFor(symbol))
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	f.results[key] = refs
+	f.mu.Unlock()
+	return refs, nil
+}
@@ -0,0 +1,49 @@
+package analysis
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+func TestReferenceFinder_MatchesIndexedLookupAndCaches(t *testing.T) {
+	workspace := createTypedBenchmarkWorkspace(t, 3)
+	resolver := NewSymbolResolver(workspace, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	var symbol *types.Symbol
+	for _, pkg := range workspace.Packages {
+		if s, ok := pkg.Symbols.Functions["Function"+pkg.Name[len("pkg"):]]; ok {
+			symbol = s
+			break
+		}
+	}
+	if symbol == nil {
+		t.Fatal("no function symbol found")
+	}
+
+	want, err := resolver.FindReferencesIndexed(symbol, resolver.BuildReferenceIndex())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	finder := NewReferenceFinder(resolver, nil)
+	got, err := finder.FindReferences(symbol)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) || len(got) == 0 {
+		t.Fatalf("expected %d references, got %d", len(want), len(got))
+	}
+
+	// A copy of the symbol (as produced by a second resolver) hits the cache.
+	copied := *symbol
+	again, err := finder.FindReferences(&copied)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(again) != len(got) || again[0] != got[0] {
+		t.Error("expected cached references to be returned for an equivalent symbol")
+	}
+}
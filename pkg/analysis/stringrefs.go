@@ -0,0 +1,153 @@
+package analysis
+
+import (
+	"go/ast"
+	"go/token"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// StringRefKind says why a string literal may refer to an identifier.
+type StringRefKind string
+
+const (
+	StringRefStructTag StringRefKind = "struct_tag" // A struct tag value, e.g. `json:"Name"`
+	StringRefReflect   StringRefKind = "reflect"    // An argument of a by-name lookup, e.g. FieldByName("Name")
+	StringRefTemplate  StringRefKind = "template"   // A text/template action, e.g. "{{.Name}}"
+	StringRefLiteral   StringRefKind = "literal"    // A string equal to the name
+)
+
+// StringReference is a string literal that may refer to an identifier by
+// name, which renaming the identifier leaves as it is.
+type StringReference struct {
+	File   string
+	Line   int
+	Column int
+	Kind   StringRefKind
+	Text   string // The literal as written
+}
+
+// byNameLookups are the reflect methods that look up a Go identifier by
+// its name.
+var byNameLookups = map[string]bool{
+	"FieldByName":  true,
+	"MethodByName": true,
+}
+
+// tagValues matches the key:"value" pairs of a struct tag.
+var tagValues = regexp.MustCompile(`(\w+):"((?:[^"\\]|\\.)*)"`)
+
+// FindStringReferences returns the string literals of the workspace's Go
+// files that may refer to the identifier name: struct tag values naming it
+// (compared case-insensitively, as encoding/json does), arguments of
+// reflect's by-name lookups, template.FuncMap keys spelling it in any case,
+// text/template actions mentioning it, and strings equal to it. It is a
+// heuristic: strings that happen to spell the name are reported as well.
+func FindStringReferences(ws *types.Workspace, name string) []*StringReference {
+	if name == "" {
+		return nil
+	}
+	action := regexp.MustCompile(`\{\{[^}]*\b` + regexp.QuoteMeta(name) + `\b[^}]*\}\}`)
+
+	var refs []*StringReference
+	for _, dir := range slices.Sorted(maps.Keys(ws.Packages)) {
+		pkg := ws.Packages[dir]
+		for _, files := range []map[string]*types.File{pkg.Files, pkg.TestFiles} {
+			for _, base := range slices.Sorted(maps.Keys(files)) {
+				if f := files[base]; f.AST != nil {
+					refs = append(refs, fileStringReferences(ws.FileSet, f, name, action)...)
+				}
+			}
+		}
+	}
+	return refs
+}
+
+// fileStringReferences returns the string references to name in file.
+func fileStringReferences(fset *token.FileSet, file *types.File, name string, action *regexp.Regexp) []*StringReference {
+	var refs []*StringReference
+	seen := make(map[*ast.BasicLit]bool)
+	add := func(lit *ast.BasicLit, kind StringRefKind) {
+		seen[lit] = true
+		pos := fset.Position(lit.Pos())
+		refs = append(refs, &StringReference{
+			File:   file.Path,
+			Line:   pos.Line,
+			Column: pos.Column,
+			Kind:   kind,
+			Text:   lit.Value,
+		})
+	}
+
+	ast.Inspect(file.AST, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Field:
+			if n.Tag == nil {
+				break
+			}
+			seen[n.Tag] = true
+			tag, err := strconv.Unquote(n.Tag.Value)
+			if err != nil {
+				break
+			}
+			for _, m := range tagValues.FindAllStringSubmatch(tag, -1) {
+				value, _, _ := strings.Cut(m[2], ",")
+				if strings.EqualFold(value, name) {
+					add(n.Tag, StringRefStructTag)
+					break
+				}
+			}
+		case *ast.CallExpr:
+			sel, ok := n.Fun.(*ast.SelectorExpr)
+			if !ok || !byNameLookups[sel.Sel.Name] {
+				break
+			}
+			for _, arg := range n.Args {
+				if lit, ok := arg.(*ast.BasicLit); ok && lit.Kind == token.STRING && stringValue(lit) == name {
+					add(lit, StringRefReflect)
+				}
+			}
+		case *ast.CompositeLit:
+			// Keys of a template.FuncMap, usually the function's name
+			// in lower camel case
+			if sel, ok := n.Type.(*ast.SelectorExpr); !ok || sel.Sel.Name != "FuncMap" {
+				break
+			}
+			for _, elt := range n.Elts {
+				kv, ok := elt.(*ast.KeyValueExpr)
+				if !ok {
+					continue
+				}
+				if lit, ok := kv.Key.(*ast.BasicLit); ok && lit.Kind == token.STRING && strings.EqualFold(stringValue(lit), name) {
+					add(lit, StringRefTemplate)
+				}
+			}
+		case *ast.BasicLit:
+			if n.Kind != token.STRING || seen[n] {
+				break
+			}
+			switch value := stringValue(n); {
+			case value == name:
+				add(n, StringRefLiteral)
+			case action.MatchString(value):
+				add(n, StringRefTemplate)
+			}
+		}
+		return true
+	})
+	return refs
+}
+
+// stringValue returns the value of a string literal, or "" if it is malformed.
+func stringValue(lit *ast.BasicLit) string {
+	value, err := strconv.Unquote(lit.Value)
+	if err != nil {
+		return ""
+	}
+	return value
+}
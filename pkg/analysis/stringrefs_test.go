@@ -0,0 +1,72 @@
+package analysis
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindStringReferences(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/strs\n\ngo 1.21\n",
+		"user/user.go": `package user
+
+import (
+	"reflect"
+	"text/template"
+)
+
+type User struct {
+	Name  string ` + "`json:\"name,omitempty\"`" + `
+	Email string ` + "`json:\"email\"`" + `
+}
+
+var page = template.Must(template.New("p").Funcs(template.FuncMap{"name": Name}).Parse("{{.Name}} <{{.Email}}>"))
+
+func Name(u User) string {
+	return reflect.ValueOf(u).FieldByName("Name").String()
+}
+
+const label = "Name"
+
+const other = "Names"
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	parser := NewParser(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ws, err := parser.ParseWorkspace(dir)
+	if err != nil {
+		t.Fatalf("ParseWorkspace: %v", err)
+	}
+
+	got := make(map[int]StringRefKind)
+	for _, ref := range FindStringReferences(ws, "Name") {
+		got[ref.Line] = ref.Kind
+	}
+	want := map[int]StringRefKind{
+		9:  StringRefStructTag,
+		13: StringRefTemplate, // The FuncMap key and the action
+		16: StringRefReflect,
+		19: StringRefLiteral,
+	}
+	if len(got) != len(want) {
+		t.Errorf("got references on lines %v, want %v", got, want)
+	}
+	for line, kind := range want {
+		if got[line] != kind {
+			t.Errorf("line %d: got %q, want %q", line, got[line], kind)
+		}
+	}
+}
@@ -1,16 +1,18 @@
 package analysis
 
 import (
+	"context"
 	"fmt"
 	"go/ast"
 	"go/token"
 	gotypes "go/types"
 	"log/slog"
+	"maps"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
 	"sync"
-	"unicode"
 
 	"github.com/mamaar/gorefactor/pkg/types"
 	"golang.org/x/tools/go/ast/inspector"
@@ -45,10 +47,12 @@ type objectEntry struct {
 // ReferenceIndex maps identifier names to all their occurrences across the workspace.
 // The workspaceIndex provides O(1) lookups by types.Object pointer identity when type
 // information is available, using varint-compressed cursor indexes for ~10x memory
-// reduction vs the previous objectIndex.
+// reduction vs the previous objectIndex. Names, package aliases and receiver names
+// are interned, so entries share a string per identifier.
 type ReferenceIndex struct {
 	nameIndex    map[string][]indexEntry
 	workspaceIdx *workspaceIndex
+	indexedInfo  map[string]*gotypes.Info // Type info each package was indexed with, by package path
 }
 
 // SymbolResolver handles symbol resolution and reference finding
@@ -58,6 +62,7 @@ type SymbolResolver struct {
 	cache         *SymbolCache
 	diagnostics   *DiagnosticEngine
 	logger        *slog.Logger
+	fillMu        *sync.Mutex // Held while EnsureSymbolTable builds a missing table; shared with forks
 }
 
 func NewSymbolResolver(ws *types.Workspace, logger *slog.Logger) *SymbolResolver {
@@ -65,12 +70,22 @@ func NewSymbolResolver(ws *types.Workspace, logger *slog.Logger) *SymbolResolver
 		workspace: ws,
 		cache:     NewSymbolCache(),
 		logger:    logger,
+		fillMu:    &sync.Mutex{},
 	}
 	sr.scopeAnalyzer = NewScopeAnalyzer(sr)
 	sr.diagnostics = NewDiagnosticEngine(sr)
 	return sr
 }
 
+// Fork returns a resolver for ws, a copy of the resolver's workspace changed
+// apart from it, with a cache of its own. Symbol tables missing from the
+// packages the two workspaces share are still built one at a time.
+func (sr *SymbolResolver) Fork(ws *types.Workspace) *SymbolResolver {
+	fork := NewSymbolResolver(ws, sr.logger)
+	fork.fillMu = sr.fillMu
+	return fork
+}
+
 // getPackageIdentifier returns the best available package identifier for a package.
 // Prefers ImportPath (e.g., "github.com/foo/bar"), falls back to Path if ImportPath is empty.
 func getPackageIdentifier(pkg *types.Package) string {
@@ -81,6 +96,18 @@ func getPackageIdentifier(pkg *types.Package) string {
 	return pkg.Path
 }
 
+// EnsureSymbolTable returns the symbol table of pkg, building it if the
+// package has none. Unlike BuildSymbolTable it leaves an existing table in
+// place, so read-only analyses running concurrently can call it.
+func (sr *SymbolResolver) EnsureSymbolTable(pkg *types.Package) (*types.SymbolTable, error) {
+	sr.fillMu.Lock()
+	defer sr.fillMu.Unlock()
+	if pkg.Symbols != nil {
+		return pkg.Symbols, nil
+	}
+	return sr.BuildSymbolTable(pkg)
+}
+
 // BuildSymbolTable builds complete symbol table for a package
 func (sr *SymbolResolver) BuildSymbolTable(pkg *types.Package) (*types.SymbolTable, error) {
 	symbolTable := &types.SymbolTable{
@@ -154,11 +181,93 @@ func (sr *SymbolResolver) FindReferences(symbol *types.Symbol) ([]*types.Referen
 // and pre-computes declaration and selector information, eliminating the need for
 // repeated nested AST walks in identifierRefersToSymbol/getQualifyingPackage/isDeclarationContext.
 func (sr *SymbolResolver) BuildReferenceIndex() *ReferenceIndex {
-	sr.logger.Info("building reference index", "packages", len(sr.workspace.Packages))
+	idx, _ := sr.BuildReferenceIndexContext(context.Background())
+	return idx
+}
+
+// BuildReferenceIndexContext is like BuildReferenceIndex but stops indexing once
+// ctx is canceled, returning a nil index and ctx.Err().
+func (sr *SymbolResolver) BuildReferenceIndexContext(ctx context.Context) (*ReferenceIndex, error) {
+	return sr.buildReferenceIndex(ctx, slices.Collect(maps.Values(sr.workspace.Packages)))
+}
+
+// BuildReferenceIndexFor builds a reference index of only the packages that
+// can refer to the given symbols and to the members of the given packages:
+// the packages declaring them and the packages importing those, directly or
+// not. Indirect importers count as a value of a type can reach a package
+// that doesn't import the type's, and refer to its methods and fields. For
+// a refactoring local to a few packages this indexes a fraction of the
+// workspace; references to anything else are missing from the index, so it
+// suits the single operation it was built for rather than caching. A symbol
+// whose package isn't in the workspace makes it index every package.
+func (sr *SymbolResolver) BuildReferenceIndexFor(symbols []*types.Symbol, pkgs []*types.Package) *ReferenceIndex {
+	ws := sr.workspace
+	targets := make(map[*types.Package]bool)
+	for _, pkg := range pkgs {
+		targets[pkg] = true
+	}
+	for _, sym := range symbols {
+		pkg := ws.Packages[sym.Package]
+		if pkg == nil {
+			pkg = ws.Packages[ws.ImportToPath[sym.Package]]
+		}
+		if pkg == nil {
+			sr.logger.Debug("symbol package not in workspace, indexing every package", "symbol", sym.Name, "package", sym.Package)
+			return sr.BuildReferenceIndex()
+		}
+		targets[pkg] = true
+	}
+	reach := importerClosure(ws, targets)
+	idx, _ := sr.buildReferenceIndex(context.Background(), slices.Collect(maps.Keys(reach)))
+	return idx
+}
+
+// importerClosure returns the packages of targets and every package
+// importing one of them, directly or not. Test files count: an external
+// test package refers to the package it tests by its import path too.
+func importerClosure(ws *types.Workspace, targets map[*types.Package]bool) map[*types.Package]bool {
+	importers := make(map[string][]*types.Package)
+	for _, pkg := range ws.Packages {
+		seen := make(map[string]bool)
+		for _, f := range packageFiles(pkg) {
+			if f.AST == nil {
+				continue
+			}
+			for _, imp := range f.AST.Imports {
+				path := strings.Trim(imp.Path.Value, `"`)
+				if !seen[path] {
+					seen[path] = true
+					importers[path] = append(importers[path], pkg)
+				}
+			}
+		}
+	}
+
+	reach := maps.Clone(targets)
+	queue := slices.Collect(maps.Keys(targets))
+	for len(queue) > 0 {
+		pkg := queue[0]
+		queue = queue[1:]
+		if pkg.ImportPath == "" {
+			continue
+		}
+		for _, importer := range importers[pkg.ImportPath] {
+			if !reach[importer] {
+				reach[importer] = true
+				queue = append(queue, importer)
+			}
+		}
+	}
+	return reach
+}
+
+// buildReferenceIndex indexes the files of pkgs.
+func (sr *SymbolResolver) buildReferenceIndex(ctx context.Context, pkgs []*types.Package) (*ReferenceIndex, error) {
+	sr.logger.Info("building reference index", "packages", len(pkgs), "workspace_packages", len(sr.workspace.Packages))
 
 	// Collect all files into a flat slice
 	var files []*types.File
-	for _, pkg := range sr.workspace.Packages {
+	for _, pkg := range pkgs {
 		for _, f := range pkg.Files {
 			files = append(files, f)
 		}
@@ -176,7 +285,7 @@ func (sr *SymbolResolver) BuildReferenceIndex() *ReferenceIndex {
 	if workers == 0 {
 		return &ReferenceIndex{
 			nameIndex: make(map[string][]indexEntry),
-		}
+		}, nil
 	}
 
 	// Each worker builds local name indexes to avoid lock contention
@@ -194,6 +303,9 @@ func (sr *SymbolResolver) BuildReferenceIndex() *ReferenceIndex {
 		go func(local map[string][]indexEntry) {
 			defer wg.Done()
 			for i := range ch {
+				if ctx.Err() != nil {
+					return
+				}
 				f := files[i]
 				if f.Package != nil && f.Package.TypesInfo != nil {
 					sr.indexFileTyped(f, local, f.Package.TypesInfo)
@@ -204,10 +316,17 @@ func (sr *SymbolResolver) BuildReferenceIndex() *ReferenceIndex {
 		}(localResults[w])
 	}
 	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
 	// Merge local indexes into the final index
 	idx := &ReferenceIndex{
-		nameIndex: make(map[string][]indexEntry),
+		nameIndex:   make(map[string][]indexEntry),
+		indexedInfo: make(map[string]*gotypes.Info),
+	}
+	for _, pkg := range pkgs {
+		idx.indexedInfo[pkg.Path] = pkg.TypesInfo
 	}
 	for _, local := range localResults {
 		for name, entries := range local {
@@ -222,7 +341,7 @@ func (sr *SymbolResolver) BuildReferenceIndex() *ReferenceIndex {
 		files []*ast.File
 	}
 	var pkgWorkList []pkgWork
-	for _, pkg := range sr.workspace.Packages {
+	for _, pkg := range pkgs {
 		if pkg.TypesInfo == nil || pkg.TypesPkg == nil {
 			continue
 		}
@@ -249,11 +368,17 @@ func (sr *SymbolResolver) BuildReferenceIndex() *ReferenceIndex {
 			wg2.Add(1)
 			go func(i int, pw pkgWork) {
 				defer wg2.Done()
+				if ctx.Err() != nil {
+					return
+				}
 				ins := inspector.New(pw.files)
 				pkgResults[i] = newPackageIndex(ins, pw.pkg.TypesPkg, pw.pkg.TypesInfo)
 			}(i, pw)
 		}
 		wg2.Wait()
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 
 		// Merge results into workspaceIndex (single-threaded)
 		for i, pw := range pkgWorkList {
@@ -282,7 +407,90 @@ func (sr *SymbolResolver) BuildReferenceIndex() *ReferenceIndex {
 	sr.logger.Info("reference index built successfully",
 		"name_entries", len(idx.nameIndex),
 		"workspace_packages", len(wsIdx.packages))
-	return idx
+	return idx, nil
+}
+
+// UpdateReferenceIndex returns idx brought up to date after files changed,
+// re-indexing only their packages and the packages whose type information
+// changed since idx was built, e.g. after the importers of a changed
+// package were type-checked again. Entries of other packages are shared with
+// idx, which is left as it was for callers still holding it.
+func (sr *SymbolResolver) UpdateReferenceIndex(idx *ReferenceIndex, files []string) *ReferenceIndex {
+	ws := sr.workspace
+	stale := make(map[string]bool)
+	for _, f := range files {
+		stale[filepath.Dir(f)] = true
+	}
+	for path, info := range idx.indexedInfo {
+		if pkg := ws.Packages[path]; pkg == nil || pkg.TypesInfo != info {
+			stale[path] = true
+		}
+	}
+
+	updated := &ReferenceIndex{
+		nameIndex:   make(map[string][]indexEntry, len(idx.nameIndex)),
+		indexedInfo: maps.Clone(idx.indexedInfo),
+	}
+	if updated.indexedInfo == nil {
+		updated.indexedInfo = make(map[string]*gotypes.Info)
+	}
+	for name, entries := range idx.nameIndex {
+		kept := slices.DeleteFunc(slices.Clone(entries), func(e indexEntry) bool {
+			return stale[filepath.Dir(e.File.Path)]
+		})
+		if len(kept) > 0 {
+			updated.nameIndex[name] = kept
+		}
+	}
+
+	wsIdx := newWorkspaceIndex(ws.FileSet)
+	if idx.workspaceIdx != nil {
+		for tp, pi := range idx.workspaceIdx.packages {
+			if pkg := ws.Packages[ws.ImportToPath[tp.Path()]]; pkg != nil && pkg.TypesPkg == tp && !stale[pkg.Path] {
+				wsIdx.packages[tp] = pi
+			}
+		}
+		for tf, f := range idx.workspaceIdx.tokenToFile {
+			if !stale[filepath.Dir(f.Path)] {
+				wsIdx.tokenToFile[tf] = f
+			}
+		}
+	}
+
+	reindexed := 0
+	for dir := range stale {
+		pkg := ws.Packages[dir]
+		if pkg == nil {
+			delete(updated.indexedInfo, dir)
+			continue
+		}
+		updated.indexedInfo[dir] = pkg.TypesInfo
+		reindexed++
+		var astFiles []*ast.File
+		for _, f := range packageFiles(pkg) {
+			if pkg.TypesInfo != nil {
+				sr.indexFileTyped(f, updated.nameIndex, pkg.TypesInfo)
+			} else {
+				sr.indexFileLocal(f, updated.nameIndex)
+			}
+			if f.AST != nil {
+				astFiles = append(astFiles, f.AST)
+				if tf := ws.FileSet.File(f.AST.Pos()); tf != nil {
+					wsIdx.tokenToFile[tf] = f
+				}
+			}
+		}
+		if pkg.TypesInfo != nil && pkg.TypesPkg != nil && len(astFiles) > 0 {
+			wsIdx.packages[pkg.TypesPkg] = newPackageIndex(inspector.New(astFiles), pkg.TypesPkg, pkg.TypesInfo)
+		}
+	}
+	updated.workspaceIdx = wsIdx
+
+	sr.logger.Info("reference index updated",
+		"files", len(files),
+		"packages_reindexed", reindexed,
+		"name_entries", len(updated.nameIndex))
+	return updated
 }
 
 // indexFileLocal performs a single AST walk over a file using the cursor-based
@@ -329,7 +537,7 @@ func (sr *SymbolResolver) indexFileLocal(file *types.File, nameIndex map[string]
 				}
 				if alias, found := selectorMap[node.Pos()]; found {
 					entry.IsSelector = true
-					entry.PkgAlias = alias
+					entry.PkgAlias = intern(alias)
 				}
 				// Use cursor parent chain to detect method calls without a pre-pass.
 				// Pattern: CallExpr -> SelectorExpr -> Ident (the .Sel)
@@ -342,14 +550,14 @@ func (sr *SymbolResolver) indexFileLocal(file *types.File, nameIndex map[string]
 							if _, isCall := grandparent.Node().(*ast.CallExpr); isCall {
 								if receiverIdent, ok := selExpr.X.(*ast.Ident); ok {
 									entry.IsMethodCall = true
-									entry.ReceiverName = receiverIdent.Name
+									entry.ReceiverName = intern(receiverIdent.Name)
 									entry.ReceiverPos = receiverIdent.Pos()
 								}
 							}
 						}
 					}
 				}
-				nameIndex[node.Name] = append(nameIndex[node.Name], entry)
+				nameIndex[intern(node.Name)] = append(nameIndex[node.Name], entry)
 			}
 			return true
 		},
@@ -380,7 +588,7 @@ func (sr *SymbolResolver) indexFileTyped(file *types.File, nameIndex map[string]
 			IsDeclaration: true,
 			TypesObject:   obj,
 		}
-		nameIndex[ident.Name] = append(nameIndex[ident.Name], entry)
+		nameIndex[intern(ident.Name)] = append(nameIndex[ident.Name], entry)
 	}
 
 	// Process uses (Uses maps using identifiers to their objects)
@@ -406,7 +614,7 @@ func (sr *SymbolResolver) indexFileTyped(file *types.File, nameIndex map[string]
 			if sel.Sel.Pos() == ident.Pos() {
 				if pkgIdent, ok := sel.X.(*ast.Ident); ok {
 					entry.IsSelector = true
-					entry.PkgAlias = pkgIdent.Name
+					entry.PkgAlias = intern(pkgIdent.Name)
 					// Check for method call pattern
 					// We'd need parent context, but for typed index we rely on TypesObject
 				}
@@ -415,7 +623,7 @@ func (sr *SymbolResolver) indexFileTyped(file *types.File, nameIndex map[string]
 			return true
 		})
 
-		nameIndex[ident.Name] = append(nameIndex[ident.Name], entry)
+		nameIndex[intern(ident.Name)] = append(nameIndex[ident.Name], entry)
 	}
 }
 
@@ -539,8 +747,11 @@ func (sr *SymbolResolver) FindReferencesIndexedFiltered(symbol *types.Symbol, id
 
 		// Fast path: when both entry and target have types.Object, use pointer equality.
 		// This eliminates false positives from name shadowing across packages.
+		confidence := types.ConfidenceCertain
 		if targetObj != nil && entry.TypesObject != nil {
-			if entry.TypesObject != targetObj {
+			// Promoted methods of generic embedded types are used through
+			// their instantiations
+			if canonicalObject(entry.TypesObject) != targetObj {
 				skippedReasons["types_object_mismatch"]++
 				continue
 			}
@@ -553,12 +764,14 @@ func (sr *SymbolResolver) FindReferencesIndexedFiltered(symbol *types.Symbol, id
 					skippedReasons["method_call_no_match"]++
 					continue
 				}
+				confidence = types.ConfidenceLikely
 			} else {
 				// Qualified reference (pkg.Symbol) — check if the alias refers to the symbol's package
 				if !sr.importAliasRefersToPackage(entry.PkgAlias, entry.File, symbol.Package) {
 					skippedReasons["import_alias_mismatch"]++
 					continue
 				}
+				confidence = types.ConfidenceLikely
 			}
 		} else {
 			// Unqualified reference — must be in the same package
@@ -566,17 +779,19 @@ func (sr *SymbolResolver) FindReferencesIndexedFiltered(symbol *types.Symbol, id
 				skippedReasons["package_mismatch"]++
 				continue
 			}
+			confidence = types.ConfidenceLikely
 		}
 
 		pos := sr.workspace.FileSet.Position(entry.Pos)
 		ref := &types.Reference{
-			Symbol:   symbol,
-			Position: entry.Pos,
-			Offset:   pos.Offset,
-			File:     entry.File.Path,
-			Line:     pos.Line,
-			Column:   pos.Column,
-			Context:  sr.extractContext2(entry.File, pos.Line),
+			Symbol:     symbol,
+			Position:   entry.Pos,
+			Offset:     pos.Offset,
+			File:       entry.File.Path,
+			Line:       pos.Line,
+			Column:     pos.Column,
+			Context:    sr.extractContext2(entry.File, pos.Line),
+			Confidence: confidence,
 		}
 		references = append(references, ref)
 	}
@@ -1026,6 +1241,8 @@ func (sr *SymbolResolver) findReferencesInFile(file *types.File, symbol *types.S
 						Line:     pos.Line,
 						Column:   pos.Column,
 						Context:  sr.extractContext(ident, file),
+						// Matched by name and package, not by type identity
+						Confidence: types.ConfidenceLikely,
 					}
 					references = append(references, ref)
 				}
@@ -1278,7 +1495,7 @@ func (sr *SymbolResolver) extractFunctionSignature(funcDecl *ast.FuncDecl) strin
 }
 
 func (sr *SymbolResolver) isExported(name string) bool {
-	return len(name) > 0 && unicode.IsUpper(rune(name[0]))
+	return token.IsExported(name)
 }
 
 // Advanced resolution methods
@@ -1843,6 +2060,12 @@ func (sr *SymbolResolver) methodBelongsToType(methodSym *types.Symbol, typeSym *
 		return true
 	}
 
+	// Promoted match: typeSym embeds the method's receiver type and doesn't
+	// declare a method of the same name itself
+	if typeSym.Kind == types.TypeSymbol && sr.promotesMethod(typeSym, methodSym, make(map[*types.Symbol]bool)) {
+		return true
+	}
+
 	// Interface match: check if typeSym implements the interface that methodSym is part of
 	if methodReceiverType.Kind == types.InterfaceSymbol {
 		var methods []*types.Symbol
@@ -1906,6 +2129,47 @@ func (sr *SymbolResolver) methodBelongsToType(methodSym *types.Symbol, typeSym *
 	return false
 }
 
+// promotesMethod reports whether methodSym is promoted into typeSym: a type
+// typeSym embeds, directly or through other embedded types, declares it, and
+// no type on the way declares a method of the same name, which would shadow
+// it.
+func (sr *SymbolResolver) promotesMethod(typeSym, methodSym *types.Symbol, seen map[*types.Symbol]bool) bool {
+	if seen[typeSym] || sr.declaresMethod(typeSym, methodSym.Name) {
+		return false
+	}
+	seen[typeSym] = true
+	embedded, err := sr.ResolveEmbeddedFields(typeSym)
+	if err != nil {
+		return false
+	}
+	receiver := methodSym.Parent
+	for _, e := range embedded {
+		if strings.TrimPrefix(e.Name, "*") == strings.TrimPrefix(receiver.Name, "*") && e.Package == receiver.Package {
+			return true
+		}
+	}
+	for _, e := range embedded {
+		if e.Kind == types.TypeSymbol && sr.promotesMethod(e, methodSym, seen) {
+			return true
+		}
+	}
+	return false
+}
+
+// declaresMethod reports whether typeSym declares a method named name.
+func (sr *SymbolResolver) declaresMethod(typeSym *types.Symbol, name string) bool {
+	pkg := sr.workspace.Packages[typeSym.Package]
+	if pkg == nil {
+		pkg = sr.workspace.Packages[sr.workspace.ImportToPath[typeSym.Package]]
+	}
+	if pkg == nil || pkg.Symbols == nil {
+		return false
+	}
+	return slices.ContainsFunc(pkg.Symbols.Methods[typeSym.Name], func(m *types.Symbol) bool {
+		return m.Name == name
+	})
+}
+
 // resolveTypesObject resolves a Symbol to its go/types.Object by looking up
 // the identifier at the symbol's position in the TypesInfo of its package.
 // Returns nil if type info is unavailable.
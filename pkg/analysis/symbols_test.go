@@ -1,6 +1,8 @@
 package analysis
 
 import (
+	"context"
+	"errors"
 	"go/ast"
 	"go/importer"
 	"go/parser"
@@ -8,7 +10,10 @@ import (
 	gotypes "go/types"
 	"io"
 	"log/slog"
+	"os"
+	"path/filepath"
 	"sort"
+	"strings"
 	"testing"
 
 	"github.com/mamaar/gorefactor/pkg/types"
@@ -430,6 +435,10 @@ func TestSymbolResolver_isExported(t *testing.T) {
 		{"MixedCase", true},
 		{"_underscore", false},
 		{"", false},
+		{"Ölfeld", true},
+		{"ölfeld", false},
+		{"Δelta", true},
+		{"变量", false}, // Han has no case, so it is never exported
 	}
 
 	for _, tc := range testCases {
@@ -569,6 +578,22 @@ var MyVar = "global"
 	return ws, resolver
 }
 
+func TestBuildReferenceIndexContext_Canceled(t *testing.T) {
+	_, resolver := createTypedTestWorkspace(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	idx, err := resolver.BuildReferenceIndexContext(ctx)
+	if !errors.Is(err, context.Canceled) || idx != nil {
+		t.Fatalf("expected nil index and context.Canceled, got %v, %v", idx, err)
+	}
+
+	idx, err = resolver.BuildReferenceIndexContext(context.Background())
+	if err != nil || idx == nil {
+		t.Fatalf("expected index, got %v, %v", idx, err)
+	}
+}
+
 // TestObjectIndex_MatchesNameIndex verifies that the object-path and name-path
 // return identical reference sets (differential test).
 func TestObjectIndex_MatchesNameIndex(t *testing.T) {
@@ -1062,4 +1087,214 @@ func Caller() string { return pkga.SharedFunc() }
 	if !resolver.HasNonDeclarationReference(sharedFunc, idx) {
 		t.Error("Expected HasNonDeclarationReference to find cross-package usage")
 	}
-}
\ No newline at end of file
+}
+
+// TestFindReferencesIndexed_PromotedMethodWithoutTypesInfo verifies the name
+// path matches calls of a method promoted through an embedded field, unless
+// the embedding type shadows it.
+func TestFindReferencesIndexed_PromotedMethodWithoutTypesInfo(t *testing.T) {
+	fileSet := token.NewFileSet()
+	src := `package untyped
+
+type Inner struct{}
+
+func (Inner) Run() {}
+
+type Outer struct{ Inner }
+
+type Shadow struct{ *Inner }
+
+func (Shadow) Run() {}
+
+func Use(o Outer, s Shadow) {
+	o.Run()
+	s.Run()
+}
+`
+	astFile, err := parser.ParseFile(fileSet, "untyped.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	file := &types.File{Path: "untyped.go", AST: astFile, OriginalContent: []byte(src)}
+	pkg := &types.Package{
+		Name:  "untyped",
+		Path:  "test/untyped",
+		Files: map[string]*types.File{"untyped.go": file},
+	}
+	file.Package = pkg
+	ws := &types.Workspace{
+		Packages: map[string]*types.Package{"test/untyped": pkg},
+		FileSet:  fileSet,
+	}
+
+	resolver := NewSymbolResolver(ws, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if _, err := resolver.BuildSymbolTable(pkg); err != nil {
+		t.Fatalf("Failed to build symbol table: %v", err)
+	}
+	var run *types.Symbol
+	for _, m := range pkg.Symbols.Methods["Inner"] {
+		if m.Name == "Run" {
+			run = m
+		}
+	}
+	if run == nil {
+		t.Fatal("Expected to find Inner.Run")
+	}
+
+	refs, err := resolver.FindReferencesIndexed(run, resolver.BuildReferenceIndex())
+	if err != nil {
+		t.Fatalf("FindReferencesIndexed failed: %v", err)
+	}
+	if len(refs) != 1 || refs[0].Line != 14 {
+		t.Errorf("Expected the promoted call o.Run() on line 14, got %d references: %v", len(refs), refs)
+	}
+}
+
+// TestUpdateReferenceIndex verifies that updating an index after a rename
+// across packages finds what a rebuilt one does, re-indexing only the
+// packages whose files or type information changed.
+func TestUpdateReferenceIndex(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/upd\n\ngo 1.21\n",
+		"store/store.go": `package store
+
+func Get(key string) string { return key }
+`,
+		"app/app.go": `package app
+
+import "example.com/upd/store"
+
+func Run() string { return store.Get("a") + store.Get("b") }
+`,
+		"util/util.go": `package util
+
+func Trim(s string) string { return s }
+
+func Both(a, b string) string { return Trim(a) + Trim(b) }
+`,
+	}
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	for name, content := range files {
+		write(name, content)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	p := NewParser(logger)
+	ws, err := p.ParseWorkspace(dir)
+	if err != nil {
+		t.Fatalf("ParseWorkspace: %v", err)
+	}
+	resolver := NewSymbolResolver(ws, logger)
+	for _, pkg := range ws.Packages {
+		p.EnsureTypeChecked(ws, pkg)
+		if _, err := resolver.EnsureSymbolTable(pkg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	idx := resolver.BuildReferenceIndex()
+	utilPkg := ws.Packages[filepath.Join(dir, "util")]
+	utilIdx := idx.workspaceIdx.packages[utilPkg.TypesPkg]
+
+	// Rename store.Get to Fetch, as an applied plan would, and bring the
+	// workspace up to date as the watcher does.
+	changed := []string{
+		write("store/store.go", strings.Replace(files["store/store.go"], "Get", "Fetch", 1)),
+		write("app/app.go", strings.ReplaceAll(files["app/app.go"], "Get", "Fetch")),
+	}
+	for _, path := range changed {
+		f, err := p.ParseFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pkg := ws.Packages[filepath.Dir(path)]
+		f.Package = pkg
+		pkg.Files[filepath.Base(path)] = f
+		pkg.TypesInfo, pkg.TypesPkg = nil, nil
+	}
+	for _, path := range changed {
+		pkg := ws.Packages[filepath.Dir(path)]
+		p.EnsureTypeChecked(ws, pkg)
+		resolver.InvalidateCacheForPackage(pkg.Path)
+		if _, err := resolver.BuildSymbolTable(pkg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	updated := resolver.UpdateReferenceIndex(idx, changed)
+	if len(updated.nameIndex["Get"]) != 0 {
+		t.Errorf("updated index still has %d entries for Get", len(updated.nameIndex["Get"]))
+	}
+	if len(idx.nameIndex["Get"]) == 0 {
+		t.Error("updating changed the original index")
+	}
+	if updated.workspaceIdx.packages[utilPkg.TypesPkg] != utilIdx {
+		t.Error("expected the unchanged util package's index to be kept")
+	}
+
+	rebuilt := resolver.BuildReferenceIndex()
+	for _, sym := range []*types.Symbol{
+		ws.Packages[filepath.Join(dir, "store")].Symbols.Functions["Fetch"],
+		utilPkg.Symbols.Functions["Trim"],
+	} {
+		got, err := resolver.FindReferencesIndexed(sym, updated)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := resolver.FindReferencesIndexed(sym, rebuilt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(want) || len(got) < 2 {
+			t.Errorf("%s: updated index finds %d references, rebuilt one %d", sym.Name, len(got), len(want))
+		}
+	}
+}
+
+func TestBuildReferenceIndexFor(t *testing.T) {
+	p := NewParser(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ws := writeScopeWorkspace(t, p)
+	resolver := NewSymbolResolver(ws, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	key := ws.Packages[filepath.Join(ws.RootPath, "lib")].Symbols.Functions["Key"]
+
+	idx := resolver.BuildReferenceIndexFor([]*types.Symbol{key}, nil)
+	// app only imports lib through store, but is indexed all the same.
+	for _, name := range []string{"Key", "Get", "Run"} {
+		if _, ok := idx.NameEntries(name); !ok {
+			t.Errorf("expected %s to be indexed", name)
+		}
+	}
+	if _, ok := idx.NameEntries("Alone"); ok {
+		t.Error("other can't refer to lib, so shouldn't be indexed")
+	}
+
+	got, err := resolver.FindReferencesIndexed(key, idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := resolver.FindReferencesIndexed(key, resolver.BuildReferenceIndex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || len(got) != len(want) || got[0].File != want[0].File || got[0].Line != want[0].Line {
+		t.Errorf("got references %v, want %v", got, want)
+	}
+
+	// Nothing imports other.
+	alone := ws.Packages[filepath.Join(ws.RootPath, "other")].Symbols.Functions["Alone"]
+	idx = resolver.BuildReferenceIndexFor(nil, []*types.Package{ws.Packages[filepath.Join(ws.RootPath, "other")]})
+	if _, ok := idx.NameEntries(alone.Name); !ok {
+		t.Error("expected other to be indexed")
+	}
+	if _, ok := idx.NameEntries("Key"); ok {
+		t.Error("expected only other to be indexed")
+	}
+}
@@ -0,0 +1,206 @@
+package analysis
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"go/ast"
+	"go/printer"
+	"go/token"
+	gotypes "go/types"
+	"maps"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// Symbol kinds SymbolQuery.Kinds selects and SymbolMatch.Kind reports.
+const (
+	SymbolKindFunc   = "func"
+	SymbolKindMethod = "method"
+	SymbolKindType   = "type"
+	SymbolKindConst  = "const"
+	SymbolKindVar    = "var"
+)
+
+// SymbolQuery selects the package-level declarations and methods
+// SearchSymbols returns. Empty fields match every declaration.
+type SymbolQuery struct {
+	Pattern   string   // path.Match glob on the name, or on Type.Method for methods
+	Regex     bool     // Pattern is a regular expression instead, matched anywhere in the name
+	Kinds     []string // Any of func, method, type, const and var
+	Exported  bool     // Only exported declarations, and methods of exported types
+	Packages  []string // Package patterns matched like ImportRule.Package, against the workspace-relative directory or the import path
+	Signature string   // Substring of the declaration's signature, e.g. "context.Context"
+	Tests     bool     // Search test files too
+	Limit     int      // At most this many matches; all when 0
+}
+
+// SymbolMatch is a declaration SearchSymbols found. Signature is its
+// source without the body, doc comment or, for types, fields and methods:
+// "type Store struct". Variables and constants declared without a type
+// show the type inferred for them when the package is type-checked.
+type SymbolMatch struct {
+	Name      string `json:"name"`
+	Kind      string `json:"kind"`
+	Receiver  string `json:"receiver,omitempty"` // Base type name of a method's receiver
+	Package   string `json:"package"`            // Import path
+	Signature string `json:"signature"`
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+}
+
+// SearchSymbols returns the declarations of the workspace packages that q
+// selects, ordered by package, file and position, and whether Limit cut
+// the matches short.
+func SearchSymbols(ws *types.Workspace, q SymbolQuery) ([]*SymbolMatch, bool, error) {
+	match, err := q.nameMatcher()
+	if err != nil {
+		return nil, false, err
+	}
+	for _, kind := range q.Kinds {
+		if !slices.Contains([]string{SymbolKindFunc, SymbolKindMethod, SymbolKindType, SymbolKindConst, SymbolKindVar}, kind) {
+			return nil, false, fmt.Errorf("unknown symbol kind %q: want func, method, type, const or var", kind)
+		}
+	}
+	for _, p := range q.Packages {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, false, fmt.Errorf("bad package pattern %q", p)
+		}
+	}
+
+	matches := []*SymbolMatch{}
+	for _, dir := range slices.Sorted(maps.Keys(ws.Packages)) {
+		pkg := ws.Packages[dir]
+		if len(q.Packages) > 0 && !slices.ContainsFunc(q.Packages, func(p string) bool {
+			return matchImportPattern(p, workspaceRel(ws, pkg.Path)) || matchImportPattern(p, pkg.ImportPath)
+		}) {
+			continue
+		}
+		files := slices.Collect(maps.Values(pkg.Files))
+		if q.Tests {
+			files = append(files, slices.Collect(maps.Values(pkg.TestFiles))...)
+		}
+		slices.SortFunc(files, func(a, b *types.File) int { return cmp.Compare(a.Path, b.Path) })
+		for _, file := range files {
+			if file.AST == nil {
+				continue
+			}
+			for _, m := range fileSymbols(ws.FileSet, pkg, file) {
+				if !q.selects(m, match) {
+					continue
+				}
+				if q.Limit > 0 && len(matches) == q.Limit {
+					return matches, true, nil
+				}
+				matches = append(matches, m)
+			}
+		}
+	}
+	return matches, false, nil
+}
+
+// nameMatcher returns the test Pattern puts names to.
+func (q SymbolQuery) nameMatcher() (func(string) bool, error) {
+	switch {
+	case q.Pattern == "":
+		return func(string) bool { return true }, nil
+	case q.Regex:
+		re, err := regexp.Compile(q.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("bad pattern %q: %w", q.Pattern, err)
+		}
+		return re.MatchString, nil
+	}
+	if _, err := path.Match(q.Pattern, ""); err != nil {
+		return nil, fmt.Errorf("bad pattern %q: %w", q.Pattern, err)
+	}
+	return func(name string) bool {
+		ok, _ := path.Match(q.Pattern, name)
+		return ok
+	}, nil
+}
+
+// selects reports whether q selects m, whose name match tests.
+func (q SymbolQuery) selects(m *SymbolMatch, match func(string) bool) bool {
+	if len(q.Kinds) > 0 && !slices.Contains(q.Kinds, m.Kind) {
+		return false
+	}
+	if q.Exported && (!token.IsExported(m.Name) || m.Receiver != "" && !token.IsExported(m.Receiver)) {
+		return false
+	}
+	if q.Signature != "" && !strings.Contains(m.Signature, q.Signature) {
+		return false
+	}
+	return match(m.Name) || m.Receiver != "" && match(m.Receiver+"."+m.Name)
+}
+
+// fileSymbols returns the package-level declarations and methods of file.
+func fileSymbols(fset *token.FileSet, pkg *types.Package, file *types.File) []*SymbolMatch {
+	var symbols []*SymbolMatch
+	add := func(name *ast.Ident, kind string, node ast.Node) *SymbolMatch {
+		var buf bytes.Buffer
+		_ = printer.Fprint(&buf, fset, node)
+		pos := fset.Position(name.Pos())
+		m := &SymbolMatch{
+			Name:      name.Name,
+			Kind:      kind,
+			Package:   pkg.ImportPath,
+			Signature: buf.String(),
+			File:      file.Path,
+			Line:      pos.Line,
+			Column:    pos.Column,
+		}
+		symbols = append(symbols, m)
+		return m
+	}
+
+	for _, decl := range file.AST.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			fn := *d
+			fn.Doc, fn.Body = nil, nil
+			if d.Recv == nil {
+				add(d.Name, SymbolKindFunc, &fn)
+			} else if recv := receiverBaseName(d.Recv); recv != "" {
+				add(d.Name, SymbolKindMethod, &fn).Receiver = recv
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					ts := *s
+					ts.Doc, ts.Comment = nil, nil
+					switch ts.Type.(type) {
+					case *ast.StructType:
+						ts.Type = ast.NewIdent("struct")
+					case *ast.InterfaceType:
+						ts.Type = ast.NewIdent("interface")
+					}
+					add(s.Name, SymbolKindType, &ast.GenDecl{Tok: token.TYPE, Specs: []ast.Spec{&ts}})
+				case *ast.ValueSpec:
+					kind := SymbolKindVar
+					if d.Tok == token.CONST {
+						kind = SymbolKindConst
+					}
+					for _, name := range s.Names {
+						if name.Name == "_" {
+							continue
+						}
+						vs := &ast.ValueSpec{Names: []*ast.Ident{name}, Type: s.Type}
+						m := add(name, kind, &ast.GenDecl{Tok: d.Tok, Specs: []ast.Spec{vs}})
+						// An inferred type is only known after type-checking.
+						if s.Type == nil && pkg.TypesInfo != nil && pkg.TypesInfo.Defs[name] != nil {
+							m.Signature += " " + gotypes.TypeString(pkg.TypesInfo.Defs[name].Type(), gotypes.RelativeTo(pkg.TypesPkg))
+						}
+					}
+				}
+			}
+		}
+	}
+	return symbols
+}
@@ -0,0 +1,114 @@
+package analysis
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestSearchSymbols(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/search\n\ngo 1.21\n",
+		"store/store.go": `package store
+
+import "context"
+
+// Store holds values by key.
+type Store struct {
+	items map[string]string
+}
+
+func NewStore() *Store { return &Store{} }
+
+func (s *Store) Get(ctx context.Context, key string) string { return s.items[key] }
+
+func (s *Store) grow() {}
+
+const MaxKeys = 100
+
+var defaultStore = NewStore()
+`,
+		"store/store_test.go": "package store\n\nfunc newTestStore() *Store { return NewStore() }\n",
+		"api/handler.go": `package api
+
+import "context"
+
+func Handle(ctx context.Context) error { return nil }
+
+type handler interface {
+	Serve()
+}
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ws, err := NewParser(slog.New(slog.NewTextHandler(io.Discard, nil))).ParseWorkspace(dir)
+	if err != nil {
+		t.Fatalf("ParseWorkspace: %v", err)
+	}
+
+	names := func(q SymbolQuery) []string {
+		t.Helper()
+		matches, _, err := SearchSymbols(ws, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, m := range matches {
+			names = append(names, m.Name)
+		}
+		return names
+	}
+	for _, tc := range []struct {
+		name  string
+		query SymbolQuery
+		want  []string
+	}{
+		{"glob", SymbolQuery{Pattern: "New*"}, []string{"NewStore"}},
+		{"method glob", SymbolQuery{Pattern: "Store.*"}, []string{"Get", "grow"}},
+		{"regex", SymbolQuery{Pattern: "(?i)store$", Regex: true}, []string{"Store", "NewStore", "defaultStore"}},
+		{"kinds", SymbolQuery{Kinds: []string{"const", "var"}}, []string{"MaxKeys", "defaultStore"}},
+		{"exported", SymbolQuery{Exported: true, Packages: []string{"store"}}, []string{"Store", "NewStore", "Get", "MaxKeys"}},
+		{"package", SymbolQuery{Packages: []string{"example.com/search/api"}}, []string{"Handle", "handler"}},
+		{"signature", SymbolQuery{Signature: "context.Context"}, []string{"Handle", "Get"}},
+		{"tests", SymbolQuery{Pattern: "new*", Tests: true}, []string{"newTestStore"}},
+	} {
+		if got := names(tc.query); !slices.Equal(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	matches, truncated, err := SearchSymbols(ws, SymbolQuery{Pattern: "Get"})
+	if err != nil || len(matches) != 1 {
+		t.Fatalf("SearchSymbols(Get) = %v, %v", matches, err)
+	}
+	get := matches[0]
+	if get.Kind != SymbolKindMethod || get.Receiver != "Store" || get.Package != "example.com/search/store" || get.Line != 12 || truncated {
+		t.Errorf("Get = %+v", get)
+	}
+	if want := "func (s *Store) Get(ctx context.Context, key string) string"; get.Signature != want {
+		t.Errorf("signature = %q, want %q", get.Signature, want)
+	}
+	if store, _, _ := SearchSymbols(ws, SymbolQuery{Pattern: "Store", Kinds: []string{"type"}}); len(store) != 1 || store[0].Signature != "type Store struct" {
+		t.Errorf("Store = %+v", store)
+	}
+	if _, truncated, _ := SearchSymbols(ws, SymbolQuery{Limit: 2}); !truncated {
+		t.Error("expected Limit to truncate the matches")
+	}
+	for _, q := range []SymbolQuery{{Pattern: "["}, {Pattern: "(", Regex: true}, {Kinds: []string{"field"}}} {
+		if _, _, err := SearchSymbols(ws, q); err == nil {
+			t.Errorf("expected %+v to fail", q)
+		}
+	}
+}
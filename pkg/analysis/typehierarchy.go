@@ -0,0 +1,313 @@
+package analysis
+
+import (
+	"cmp"
+	"fmt"
+	gotypes "go/types"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// TypeHierarchy describes where a named type sits in the workspace's
+// embedding graph and which interfaces it satisfies.
+type TypeHierarchy struct {
+	Name            string            `json:"name"`
+	Package         string            `json:"package"`
+	File            string            `json:"file"`
+	Line            int               `json:"line"`
+	Kind            string            `json:"kind"`        // struct, interface or the underlying type's kind
+	Embeds          []*EmbeddingEdge  `json:"embeds"`      // Types it embeds, transitively
+	EmbeddedBy      []*EmbeddingEdge  `json:"embedded_by"` // Workspace types embedding it, transitively
+	Satisfies       []*InterfaceMatch `json:"satisfies"`
+	NearlySatisfies []*InterfaceMatch `json:"nearly_satisfies"` // Interfaces it misses a few methods of
+}
+
+// EmbeddingEdge is a type reached through embedding. Via lists the types
+// in between, so a direct embedding has none.
+type EmbeddingEdge struct {
+	Type    string   `json:"type"`
+	File    string   `json:"file,omitempty"` // Empty for types outside the workspace
+	Line    int      `json:"line,omitempty"`
+	Pointer bool     `json:"pointer,omitempty"` // Embedded as *T
+	Via     []string `json:"via,omitempty"`
+}
+
+// InterfaceMatch is an interface compared against a type's method set.
+type InterfaceMatch struct {
+	Interface   string   `json:"interface"`
+	InWorkspace bool     `json:"in_workspace,omitempty"`
+	PointerOnly bool     `json:"pointer_only,omitempty"` // Only *T has all the methods
+	Missing     []string `json:"missing,omitempty"`      // Methods absent from the type, with the signature the interface wants
+	Mismatched  []string `json:"mismatched,omitempty"`   // Methods present with a different signature
+}
+
+// AnalyzeTypeHierarchy reports the embedding graph around the named type
+// declared in pkg, and how it relates to every interface declared in or
+// referenced by the workspace. Interfaces it has some of the methods of but
+// misses at most maxMissing (counting mismatched signatures) are reported
+// as nearly satisfied; empty interfaces are ignored. The workspace should be
+// type-checked first.
+func AnalyzeTypeHierarchy(ws *types.Workspace, pkg *types.Package, name string, maxMissing int) (*TypeHierarchy, error) {
+	named := lookupNamed(pkg, name)
+	if named == nil {
+		return nil, &types.RefactorError{
+			Type:    types.SymbolNotFound,
+			Message: fmt.Sprintf("type %s not found in package %s", name, pkg.Path),
+		}
+	}
+
+	pos := ws.FileSet.Position(named.Obj().Pos())
+	h := &TypeHierarchy{
+		Name:            name,
+		Package:         pkg.Path,
+		File:            pos.Filename,
+		Line:            pos.Line,
+		Kind:            typeKind(named),
+		Embeds:          walkEmbeds(ws, named),
+		EmbeddedBy:      walkEmbedders(ws, named),
+		Satisfies:       []*InterfaceMatch{},
+		NearlySatisfies: []*InterfaceMatch{},
+	}
+
+	for _, iface := range candidateInterfaces(ws) {
+		if iface == named {
+			continue
+		}
+		match := matchInterface(named, iface)
+		if match == nil {
+			continue
+		}
+		match.InWorkspace = declaredInWorkspace(ws, iface)
+		switch n := len(match.Missing) + len(match.Mismatched); {
+		case n == 0:
+			h.Satisfies = append(h.Satisfies, match)
+		case n <= maxMissing && n < iface.Underlying().(*gotypes.Interface).NumMethods():
+			h.NearlySatisfies = append(h.NearlySatisfies, match)
+		}
+	}
+	slices.SortStableFunc(h.NearlySatisfies, func(a, b *InterfaceMatch) int {
+		return cmp.Compare(len(a.Missing)+len(a.Mismatched), len(b.Missing)+len(b.Mismatched))
+	})
+	return h, nil
+}
+
+// lookupNamed finds a package-level named type by name.
+func lookupNamed(pkg *types.Package, name string) *gotypes.Named {
+	var obj gotypes.Object
+	if pkg.TypesPkg != nil {
+		obj = pkg.TypesPkg.Scope().Lookup(name)
+	} else if pkg.TypesInfo != nil {
+		for id, def := range pkg.TypesInfo.Defs {
+			if id.Name == name && def != nil && def.Pkg() != nil && def.Parent() == def.Pkg().Scope() {
+				obj = def
+				break
+			}
+		}
+	}
+	tn, ok := obj.(*gotypes.TypeName)
+	if !ok || tn.IsAlias() {
+		return nil
+	}
+	named, _ := tn.Type().(*gotypes.Named)
+	return named
+}
+
+func typeKind(named *gotypes.Named) string {
+	switch u := named.Underlying().(type) {
+	case *gotypes.Struct:
+		return "struct"
+	case *gotypes.Interface:
+		return "interface"
+	case *gotypes.Basic:
+		return u.Name()
+	case *gotypes.Signature:
+		return "func"
+	case *gotypes.Slice:
+		return "slice"
+	case *gotypes.Map:
+		return "map"
+	case *gotypes.Chan:
+		return "chan"
+	case *gotypes.Array:
+		return "array"
+	case *gotypes.Pointer:
+		return "pointer"
+	}
+	return "type"
+}
+
+// embedded returns the types named directly embeds, with whether each is
+// embedded through a pointer.
+func embedded(named *gotypes.Named) []embedding {
+	var out []embedding
+	switch u := named.Underlying().(type) {
+	case *gotypes.Struct:
+		for f := range u.Fields() {
+			if !f.Embedded() {
+				continue
+			}
+			t, ptr := f.Type(), false
+			if p, ok := gotypes.Unalias(t).(*gotypes.Pointer); ok {
+				t, ptr = p.Elem(), true
+			}
+			if n, ok := gotypes.Unalias(t).(*gotypes.Named); ok {
+				out = append(out, embedding{n.Origin(), ptr})
+			}
+		}
+	case *gotypes.Interface:
+		for t := range u.EmbeddedTypes() {
+			if n, ok := gotypes.Unalias(t).(*gotypes.Named); ok {
+				out = append(out, embedding{n.Origin(), false})
+			}
+		}
+	}
+	return out
+}
+
+type embedding struct {
+	named   *gotypes.Named
+	pointer bool
+}
+
+// walkEmbeds returns the types root embeds, transitively.
+func walkEmbeds(ws *types.Workspace, root *gotypes.Named) []*EmbeddingEdge {
+	return walkEmbedding(ws, root, embedded)
+}
+
+// walkEmbedders returns the workspace types that embed root, directly or
+// through other types.
+func walkEmbedders(ws *types.Workspace, root *gotypes.Named) []*EmbeddingEdge {
+	embeddedBy := make(map[*gotypes.Named][]embedding)
+	for _, named := range workspaceNamed(ws) {
+		for _, e := range embedded(named) {
+			embeddedBy[e.named] = append(embeddedBy[e.named], embedding{named, e.pointer})
+		}
+	}
+	return walkEmbedding(ws, root, func(n *gotypes.Named) []embedding { return embeddedBy[n] })
+}
+
+// walkEmbedding follows next breadth first from root, so each type is
+// reported at its shallowest depth.
+func walkEmbedding(ws *types.Workspace, root *gotypes.Named, next func(*gotypes.Named) []embedding) []*EmbeddingEdge {
+	type item struct {
+		named *gotypes.Named
+		via   []string
+	}
+	edges := []*EmbeddingEdge{}
+	seen := map[*gotypes.Named]bool{root.Origin(): true}
+	queue := []item{{named: root.Origin()}}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, e := range next(cur.named) {
+			if seen[e.named] {
+				continue
+			}
+			seen[e.named] = true
+			edges = append(edges, newEdge(ws, e, cur.via))
+			queue = append(queue, item{named: e.named, via: append(slices.Clone(cur.via), qualifiedName(e.named))})
+		}
+	}
+	return edges
+}
+
+func newEdge(ws *types.Workspace, e embedding, via []string) *EmbeddingEdge {
+	edge := &EmbeddingEdge{Type: qualifiedName(e.named), Pointer: e.pointer, Via: via}
+	if declaredInWorkspace(ws, e.named) {
+		pos := ws.FileSet.Position(e.named.Obj().Pos())
+		edge.File, edge.Line = pos.Filename, pos.Line
+	}
+	return edge
+}
+
+// workspaceNamed returns the package-level named types of the type-checked
+// workspace packages, in a stable order.
+func workspaceNamed(ws *types.Workspace) []*gotypes.Named {
+	var out []*gotypes.Named
+	for _, path := range slices.Sorted(maps.Keys(ws.Packages)) {
+		tp := ws.Packages[path].TypesPkg
+		if tp == nil {
+			continue
+		}
+		scope := tp.Scope()
+		for _, name := range scope.Names() {
+			tn, ok := scope.Lookup(name).(*gotypes.TypeName)
+			if !ok || tn.IsAlias() {
+				continue
+			}
+			if named, ok := tn.Type().(*gotypes.Named); ok {
+				out = append(out, named)
+			}
+		}
+	}
+	return out
+}
+
+// candidateInterfaces returns the non-empty interfaces declared in the
+// workspace or referenced from it, sorted by qualified name.
+func candidateInterfaces(ws *types.Workspace) []*gotypes.Named {
+	seen := make(map[*gotypes.Named]bool)
+	var out []*gotypes.Named
+	add := func(named *gotypes.Named) {
+		named = named.Origin()
+		if seen[named] || !gotypes.IsInterface(named) || named.TypeParams().Len() > 0 {
+			return
+		}
+		seen[named] = true
+		if named.Underlying().(*gotypes.Interface).NumMethods() > 0 {
+			out = append(out, named)
+		}
+	}
+	for _, named := range workspaceNamed(ws) {
+		add(named)
+	}
+	for _, named := range ReferencedInterfaces(ws) {
+		add(named)
+	}
+	slices.SortFunc(out, func(a, b *gotypes.Named) int {
+		return strings.Compare(qualifiedName(a), qualifiedName(b))
+	})
+	return out
+}
+
+// matchInterface compares the method sets of named and *named with iface.
+// It returns nil when the interface can't apply, such as when it requires
+// unexported methods of another package.
+func matchInterface(named, ifaceNamed *gotypes.Named) *InterfaceMatch {
+	iface := ifaceNamed.Underlying().(*gotypes.Interface)
+	m := &InterfaceMatch{Interface: qualifiedName(ifaceNamed)}
+	for method := range iface.Methods() {
+		if !method.Exported() && method.Pkg() != named.Obj().Pkg() {
+			return nil
+		}
+		want := method.Type().(*gotypes.Signature)
+		obj, _, _ := gotypes.LookupFieldOrMethod(gotypes.NewPointer(named), false, method.Pkg(), method.Name())
+		fn, ok := obj.(*gotypes.Func)
+		switch {
+		case !ok:
+			m.Missing = append(m.Missing, method.Name()+signatureString(want))
+		case !gotypes.Identical(fn.Type(), want):
+			m.Mismatched = append(m.Mismatched, method.Name()+signatureString(want))
+		}
+	}
+	if len(m.Missing) == 0 && len(m.Mismatched) == 0 {
+		m.PointerOnly = !gotypes.Implements(named, iface)
+	}
+	return m
+}
+
+func signatureString(sig *gotypes.Signature) string {
+	return strings.TrimPrefix(gotypes.TypeString(sig, func(p *gotypes.Package) string { return p.Name() }), "func")
+}
+
+func declaredInWorkspace(ws *types.Workspace, named *gotypes.Named) bool {
+	pkg := named.Obj().Pkg()
+	if pkg == nil {
+		return false
+	}
+	_, ok := ws.ImportToPath[pkg.Path()]
+	return ok
+}
@@ -0,0 +1,119 @@
+package analysis
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestAnalyzeTypeHierarchy(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/shapes\n\ngo 1.21\n",
+		"store/store.go": `package store
+
+import "io"
+
+type Store interface {
+	Get(key string) string
+	Put(key, value string)
+	Delete(key string) error
+}
+
+type Named interface {
+	Name() string
+}
+
+type Base struct{}
+
+func (b *Base) Get(key string) string  { return key }
+func (b *Base) Close() error           { return nil }
+func (b Base) Name() string            { return "base" }
+func (b *Base) Delete(key string) bool { return false }
+
+type Mid struct {
+	*Base
+	io.Reader
+}
+
+type Cache struct {
+	Mid
+	size int
+}
+
+var _ io.Closer = (*Cache)(nil)
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	parser := NewParser(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ws, err := parser.ParseWorkspace(dir)
+	if err != nil {
+		t.Fatalf("ParseWorkspace: %v", err)
+	}
+	for _, pkg := range ws.Packages {
+		parser.EnsureTypeChecked(ws, pkg)
+	}
+	pkg := ws.Packages[filepath.Join(dir, "store")]
+
+	h, err := AnalyzeTypeHierarchy(ws, pkg, "Mid", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var embeds []string
+	for _, e := range h.Embeds {
+		embeds = append(embeds, e.Type)
+	}
+	if want := []string{"example.com/shapes/store.Base", "io.Reader"}; !slices.Equal(embeds, want) {
+		t.Errorf("Embeds = %v, want %v", embeds, want)
+	}
+	if !h.Embeds[0].Pointer || h.Embeds[0].File == "" || h.Embeds[1].File != "" {
+		t.Errorf("unexpected embedding details: %+v, %+v", h.Embeds[0], h.Embeds[1])
+	}
+	if len(h.EmbeddedBy) != 1 || h.EmbeddedBy[0].Type != "example.com/shapes/store.Cache" {
+		t.Errorf("EmbeddedBy = %+v, want Cache", h.EmbeddedBy)
+	}
+
+	h, err = AnalyzeTypeHierarchy(ws, pkg, "Base", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	satisfies := make(map[string]*InterfaceMatch)
+	for _, m := range h.Satisfies {
+		satisfies[m.Interface] = m
+	}
+	if m := satisfies["io.Closer"]; m == nil || !m.PointerOnly || m.InWorkspace {
+		t.Errorf("io.Closer = %+v, want a pointer-only match outside the workspace", m)
+	}
+	if m := satisfies["example.com/shapes/store.Named"]; m == nil || m.PointerOnly || !m.InWorkspace {
+		t.Errorf("Named = %+v, want a value match in the workspace", m)
+	}
+	if len(h.NearlySatisfies) != 1 {
+		t.Fatalf("NearlySatisfies = %+v, want Store only", h.NearlySatisfies)
+	}
+	store := h.NearlySatisfies[0]
+	if !slices.Equal(store.Missing, []string{"Put(key string, value string)"}) || !slices.Equal(store.Mismatched, []string{"Delete(key string) error"}) {
+		t.Errorf("Store match = %+v", store)
+	}
+	if embedders := len(h.EmbeddedBy); embedders != 2 || h.EmbeddedBy[1].Via[0] != "example.com/shapes/store.Mid" {
+		t.Errorf("EmbeddedBy = %+v, want Mid and Cache via Mid", h.EmbeddedBy)
+	}
+
+	if h, _ = AnalyzeTypeHierarchy(ws, pkg, "Base", 1); len(h.NearlySatisfies) != 0 {
+		t.Errorf("max 1 missing method should drop Store: %+v", h.NearlySatisfies)
+	}
+	if _, err := AnalyzeTypeHierarchy(ws, pkg, "Missing", 2); err == nil {
+		t.Error("expected an error for an unknown type")
+	}
+}
@@ -38,9 +38,9 @@ func NewUnusedAnalyzer(ws *types.Workspace, logger *slog.Logger) *UnusedAnalyzer
 func (ua *UnusedAnalyzer) FindUnusedSymbols() ([]*UnusedSymbol, error) {
 	var unusedSymbols []*UnusedSymbol
 
-	// Build symbol tables for all packages
+	// Build the symbol tables packages lack
 	for _, pkg := range ua.workspace.Packages {
-		if _, err := ua.resolver.BuildSymbolTable(pkg); err != nil {
+		if _, err := ua.resolver.EnsureSymbolTable(pkg); err != nil {
 			return nil, fmt.Errorf("failed to build symbol table for package %s: %v", pkg.Path, err)
 		}
 	}
@@ -0,0 +1,303 @@
+package analysis
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	gotypes "go/types"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// Kinds of workspace diagnostics.
+const (
+	DiagnosticParse   = "parse"   // The file doesn't parse
+	DiagnosticType    = "type"    // The package doesn't type-check
+	DiagnosticImport  = "import"  // An import resolves to no package
+	DiagnosticPackage = "package" // The package clause disagrees with the directory's other files
+)
+
+// Severities of workspace diagnostics.
+const (
+	SeverityError   = "error"   // The package doesn't build
+	SeverityWarning = "warning" // The package wasn't fully checked
+)
+
+// WorkspaceDiagnostic is a problem that keeps a workspace package from
+// building, or a package that couldn't be checked fully.
+type WorkspaceDiagnostic struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column,omitempty"`
+	Severity string `json:"severity"` // error or warning
+	Kind     string `json:"kind"`     // parse, type, import or package
+	Message  string `json:"message"`
+}
+
+// ValidateWorkspace parses every Go file under the workspace root that the
+// default build context includes, test files too, and type-checks the
+// packages that parse and agree on their package name. Files are read from
+// the workspace when it holds them and from disk otherwise, which is where
+// the files of packages that failed to load are. Diagnostics are sorted by
+// file and position.
+func (p *GoParser) ValidateWorkspace(ctx context.Context, ws *types.Workspace) ([]*WorkspaceDiagnostic, error) {
+	dirs, err := p.discoverPackageDirs(ctx, ws.RootPath)
+	if err != nil {
+		return nil, err
+	}
+	if p.importer == nil {
+		p.importer = newWorkspaceImporter(ws, ws.FileSet, p, nil)
+	}
+
+	var diags []*WorkspaceDiagnostic
+	for _, dir := range dirs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		dirDiags, err := p.validateDir(ws, dir)
+		if err != nil {
+			return nil, err
+		}
+		diags = append(diags, dirDiags...)
+	}
+	slices.SortFunc(diags, func(a, b *WorkspaceDiagnostic) int {
+		return cmp.Or(cmp.Compare(a.File, b.File), cmp.Compare(a.Line, b.Line), cmp.Compare(a.Column, b.Column))
+	})
+	return diags, nil
+}
+
+// validateDir checks the Go files of one directory.
+func (p *GoParser) validateDir(ws *types.Workspace, dir string) ([]*WorkspaceDiagnostic, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	pkg := ws.Packages[dir]
+
+	var diags []*WorkspaceDiagnostic
+	var files, tests []*ast.File
+	broken := false
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") {
+			continue
+		}
+		if match, err := build.Default.MatchFile(dir, name); err == nil && !match {
+			continue
+		}
+		f, parseDiags := p.validationFile(ws, pkg, filepath.Join(dir, name))
+		if len(parseDiags) > 0 {
+			diags = append(diags, parseDiags...)
+			broken = true
+		}
+		switch {
+		case f == nil || f.Name == nil:
+		case strings.HasSuffix(name, "_test.go"):
+			tests = append(tests, f)
+		default:
+			files = append(files, f)
+		}
+	}
+
+	// go/build takes the package name from the first file, preferring
+	// non-test files; test files may also be in package <name>_test
+	var want string
+	var wantFile *ast.File
+	for _, f := range slices.Concat(files, tests) {
+		if wantFile == nil {
+			want, wantFile = strings.TrimSuffix(f.Name.Name, "_test"), f
+		}
+		isTest := slices.Contains(tests, f)
+		if f.Name.Name == want || isTest && f.Name.Name == want+"_test" {
+			continue
+		}
+		diags = append(diags, p.diagnostic(ws, f.Name.Pos(), DiagnosticPackage,
+			fmt.Sprintf("found package %s, but %s is package %s", f.Name.Name, filepath.Base(p.filename(ws, wantFile)), want)))
+		broken = true
+	}
+
+	// go build would stop at these errors too
+	if broken || wantFile == nil {
+		return diags, nil
+	}
+	if pkg == nil || len(files) == 0 {
+		d := p.diagnostic(ws, wantFile.Name.Pos(), DiagnosticPackage,
+			fmt.Sprintf("package %s was not type-checked: the workspace didn't load it", want))
+		d.Severity = SeverityWarning
+		return append(diags, d), nil
+	}
+	return append(diags, p.typeErrors(ws, pkg, want, files, tests)...), nil
+}
+
+// validationFile returns the syntax tree of the file at path, from pkg when
+// it was loaded and parsed from disk otherwise, with its syntax errors.
+func (p *GoParser) validationFile(ws *types.Workspace, pkg *types.Package, path string) (*ast.File, []*WorkspaceDiagnostic) {
+	if pkg != nil {
+		base := filepath.Base(path)
+		for _, files := range []map[string]*types.File{pkg.Files, pkg.TestFiles} {
+			if file := files[base]; file != nil && file.AST != nil {
+				return file.AST, nil
+			}
+		}
+	}
+
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, []*WorkspaceDiagnostic{{File: path, Severity: SeverityError, Kind: DiagnosticParse, Message: err.Error()}}
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, src, parser.AllErrors)
+	var list scanner.ErrorList
+	if !errors.As(err, &list) {
+		if err != nil {
+			return f, []*WorkspaceDiagnostic{{File: path, Severity: SeverityError, Kind: DiagnosticParse, Message: err.Error()}}
+		}
+		// The file parses; only its package clause is checked, positioned
+		// in the workspace's file set like the loaded files
+		f, _ = parser.ParseFile(ws.FileSet, path, src, parser.PackageClauseOnly)
+		return f, nil
+	}
+	// Like go build, report one error per line
+	list.RemoveMultiples()
+	var diags []*WorkspaceDiagnostic
+	for _, e := range list {
+		diags = append(diags, &WorkspaceDiagnostic{
+			File:     e.Pos.Filename,
+			Line:     e.Pos.Line,
+			Column:   e.Pos.Column,
+			Severity: SeverityError,
+			Kind:     DiagnosticParse,
+			Message:  e.Msg,
+		})
+	}
+	return nil, diags
+}
+
+// typeErrors type-checks a package with its in-package test files, and its
+// external test package, reporting imports that resolve to no package
+// apart from other type errors.
+func (p *GoParser) typeErrors(ws *types.Workspace, pkg *types.Package, name string, files, tests []*ast.File) []*WorkspaceDiagnostic {
+	var diags []*WorkspaceDiagnostic
+	conf := gotypes.Config{
+		Importer: p.importerFor(ws),
+		Error: func(err error) {
+			var terr gotypes.Error
+			if !errors.As(err, &terr) {
+				return
+			}
+			kind, msg := DiagnosticType, terr.Msg
+			if path, ok := strings.CutPrefix(msg, "could not import "); ok {
+				kind, msg = DiagnosticImport, importMessage(path)
+			}
+			pos := terr.Fset.Position(terr.Pos)
+			diags = append(diags, &WorkspaceDiagnostic{
+				File:     pos.Filename,
+				Line:     pos.Line,
+				Column:   pos.Column,
+				Severity: SeverityError,
+				Kind:     kind,
+				Message:  msg,
+			})
+		},
+	}
+
+	var internal, external []*ast.File
+	for _, f := range tests {
+		if f.Name.Name == name {
+			internal = append(internal, f)
+		} else {
+			external = append(external, f)
+		}
+	}
+	_, _ = conf.Check(pkg.ImportPath, ws.FileSet, slices.Concat(files, internal), nil)
+	if len(external) > 0 {
+		_, _ = conf.Check(pkg.ImportPath+"_test", ws.FileSet, external, nil)
+	}
+	return diags
+}
+
+// NewTypeErrors type-checks pkg with the sources of some of its files,
+// keyed by path, replaced or added, and returns the errors the new sources
+// introduce: those the package as loaded doesn't report with the same
+// message. A nil source removes the file. Test files, in-package and
+// external, are checked along with the package.
+func (p *GoParser) NewTypeErrors(ws *types.Workspace, pkg *types.Package, sources map[string][]byte) []*WorkspaceDiagnostic {
+	check := func(replaced map[string][]byte) []*WorkspaceDiagnostic {
+		contents := make(map[string][]byte)
+		for _, f := range pkg.Files {
+			contents[f.Path] = f.OriginalContent
+		}
+		for _, f := range pkg.TestFiles {
+			contents[f.Path] = f.OriginalContent
+		}
+		maps.Copy(contents, replaced)
+
+		fset := token.NewFileSet()
+		var files, tests []*ast.File
+		for _, path := range slices.Sorted(maps.Keys(contents)) {
+			if contents[path] == nil {
+				continue
+			}
+			f, err := parser.ParseFile(fset, path, contents[path], parser.ParseComments)
+			if err != nil {
+				return []*WorkspaceDiagnostic{{File: path, Severity: SeverityError, Kind: DiagnosticParse, Message: err.Error()}}
+			}
+			if strings.HasSuffix(path, "_test.go") {
+				tests = append(tests, f)
+			} else {
+				files = append(files, f)
+			}
+		}
+		return p.typeErrors(&types.Workspace{RootPath: ws.RootPath, FileSet: fset}, pkg, pkg.Name, files, tests)
+	}
+
+	before := make(map[string]bool)
+	for _, d := range check(nil) {
+		before[d.Message] = true
+	}
+	var introduced []*WorkspaceDiagnostic
+	for _, d := range check(sources) {
+		if !before[d.Message] {
+			introduced = append(introduced, d)
+		}
+	}
+	return introduced
+}
+
+// importMessage shortens the type checker's message for a failed import,
+// "<path> (<cause>)", whose cause may list every directory searched.
+func importMessage(msg string) string {
+	path, cause, _ := strings.Cut(msg, " (")
+	cause, _, _ = strings.Cut(strings.TrimSuffix(cause, ")"), "\n")
+	if strings.Contains(cause, "cannot find package") || strings.Contains(cause, "can't find import") {
+		cause = "no such package"
+	}
+	return fmt.Sprintf("could not import %s: %s", path, cause)
+}
+
+func (p *GoParser) diagnostic(ws *types.Workspace, pos token.Pos, kind, msg string) *WorkspaceDiagnostic {
+	position := ws.FileSet.Position(pos)
+	return &WorkspaceDiagnostic{
+		File:     position.Filename,
+		Line:     position.Line,
+		Column:   position.Column,
+		Severity: SeverityError,
+		Kind:     kind,
+		Message:  msg,
+	}
+}
+
+func (p *GoParser) filename(ws *types.Workspace, f *ast.File) string {
+	return ws.FileSet.Position(f.Package).Filename
+}
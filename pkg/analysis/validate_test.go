@@ -0,0 +1,113 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+func TestValidateWorkspace(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":           "module example.com/v\n\ngo 1.21\n",
+		"ok/ok.go":         "package ok\n\nfunc OK() int { return 1 }\n",
+		"ok/ok_test.go":    "package ok_test\n\nimport \"example.com/v/ok\"\n\nvar _ = ok.OK()\n",
+		"imp/imp.go":       "package imp\n\nimport \"example.com/v/gone\"\n\nvar _ = gone.X\n",
+		"typ/typ.go":       "package typ\n\nfunc F() string { return 1 }\n",
+		"typ/typ_test.go":  "package typ\n\nfunc g() { unused := 1 }\n",
+		"syntax/syntax.go": "package syntax\n\nfunc F( {\n",
+		"mixed/a.go":       "package mixed\n",
+		"mixed/b.go":       "package other\n",
+		"tests/x_test.go":  "package tests\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p := NewParser(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ws, err := p.ParseWorkspace(dir)
+	if err != nil {
+		t.Fatalf("ParseWorkspace: %v", err)
+	}
+
+	diags, err := p.ValidateWorkspace(context.Background(), ws)
+	if err != nil {
+		t.Fatalf("ValidateWorkspace: %v", err)
+	}
+	var got []string
+	for _, d := range diags {
+		rel, _ := filepath.Rel(dir, d.File)
+		got = append(got, fmt.Sprintf("%s:%d %s %s: %s", filepath.ToSlash(rel), d.Line, d.Severity, d.Kind, d.Message))
+	}
+	want := []string{
+		"imp/imp.go:3 error import: could not import example.com/v/gone: no such package",
+		"mixed/b.go:1 error package: found package other, but a.go is package mixed",
+		"syntax/syntax.go:3 error parse: expected ')', found '{'",
+		"tests/x_test.go:1 warning package: package tests was not type-checked: the workspace didn't load it",
+		"typ/typ.go:3 error type: cannot use 1 (untyped int constant) as string value in return statement",
+		"typ/typ_test.go:3 error type: declared and not used: unused",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("diagnostics:\n%q\nwant:\n%q", got, want)
+	}
+}
+
+func TestNewTypeErrors(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":     "module example.com/v\n\ngo 1.21\n",
+		"typ/typ.go": "package typ\n\nfunc F() string { return 1 }\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p := NewParser(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ws, err := p.ParseWorkspace(dir)
+	if err != nil {
+		t.Fatalf("ParseWorkspace: %v", err)
+	}
+	var pkg *types.Package
+	for _, candidate := range ws.Packages {
+		if candidate.Name == "typ" {
+			pkg = candidate
+		}
+	}
+	if pkg == nil {
+		t.Fatal("package typ not loaded")
+	}
+	typ, extra := filepath.Join(dir, "typ", "typ.go"), filepath.Join(dir, "typ", "extra.go")
+
+	if diags := p.NewTypeErrors(ws, pkg, map[string][]byte{extra: []byte("package typ\n\nfunc G() string { return F() }\n")}); len(diags) != 0 {
+		t.Errorf("expected the package's own error to be left out, got %v", diags[0].Message)
+	}
+	diags := p.NewTypeErrors(ws, pkg, map[string][]byte{
+		typ:   nil,
+		extra: []byte("package typ\n\nfunc G() string { return F() }\n"),
+	})
+	if len(diags) != 1 || diags[0].File != extra || diags[0].Message != "undefined: F" {
+		t.Errorf("expected removing typ.go to leave F undefined in extra.go, got %+v", diags)
+	}
+	test := filepath.Join(dir, "typ", "typ_test.go")
+	diags = p.NewTypeErrors(ws, pkg, map[string][]byte{test: []byte("package typ\n\nvar _ = H()\n")})
+	if len(diags) != 1 || diags[0].File != test || diags[0].Message != "undefined: H" {
+		t.Errorf("expected the test file to be checked, got %+v", diags)
+	}
+}
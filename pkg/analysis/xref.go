@@ -0,0 +1,268 @@
+package analysis
+
+import (
+	"cmp"
+	"go/ast"
+	"go/token"
+	gotypes "go/types"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// XRefGraph is the reference graph around a package: which top-level
+// declarations use which. It holds every package-level symbol of the
+// package, the declarations anywhere in the workspace that reference them,
+// and the workspace symbols outside the package that its code references.
+type XRefGraph struct {
+	Package string      `json:"package"`
+	Nodes   []*XRefNode `json:"nodes"`
+	Edges   []*XRefEdge `json:"edges"`
+}
+
+// XRefNode is a top-level declaration: a function, method, type, variable
+// or constant.
+type XRefNode struct {
+	ID       string `json:"id"` // Import path, then the name; Type.Method for methods
+	Name     string `json:"name"`
+	Package  string `json:"package"`
+	Kind     string `json:"kind"` // function, method, type, interface, variable or constant
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Exported bool   `json:"exported"`
+	External bool   `json:"external,omitempty"` // Declared outside the package
+}
+
+// XRefEdge counts the references from the declaration of From to To.
+type XRefEdge struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Count int    `json:"count"`
+}
+
+// BuildXRefGraph returns the reference graph around pkg. References to its
+// symbols come from finder; references out of it to other workspace
+// packages come from its type information, so pkg should be type-checked
+// first. Recursive references are left out.
+func BuildXRefGraph(ws *types.Workspace, finder *ReferenceFinder, pkg *types.Package) (*XRefGraph, error) {
+	g := &xrefBuilder{
+		ws:     ws,
+		pkg:    pkg,
+		files:  make(map[string]*types.File),
+		nodes:  make(map[string]*XRefNode),
+		counts: make(map[[2]string]int),
+	}
+	for _, p := range ws.Packages {
+		for _, files := range []map[string]*types.File{p.Files, p.TestFiles} {
+			for _, f := range files {
+				g.files[f.Path] = f
+			}
+		}
+	}
+
+	symbols, err := finder.Resolver().EnsureSymbolTable(pkg)
+	if err != nil {
+		return nil, err
+	}
+	targets := make(map[string]*types.Symbol) // By node name
+	for _, m := range []map[string]*types.Symbol{symbols.Functions, symbols.Types, symbols.Variables, symbols.Constants} {
+		maps.Copy(targets, m)
+	}
+	for typeName, methods := range symbols.Methods {
+		for _, m := range methods {
+			targets[typeName+"."+m.Name] = m
+		}
+	}
+
+	// References into the package.
+	for _, name := range slices.Sorted(maps.Keys(targets)) {
+		sym := targets[name]
+		to := g.node(packageID(pkg), name, xrefKinds[sym.Kind], sym.Position, false)
+		refs, err := finder.FindReferences(sym)
+		if err != nil {
+			return nil, err
+		}
+		for _, ref := range refs {
+			if from := g.enclosing(ref.File, ref.Position); from != nil && from.ID != to.ID {
+				g.counts[[2]string{from.ID, to.ID}]++
+			}
+		}
+	}
+
+	// References out of the package, to the rest of the workspace.
+	if pkg.TypesInfo != nil {
+		for ident, obj := range pkg.TypesInfo.Uses {
+			to := g.objectNode(obj)
+			if to == nil || !to.External {
+				continue
+			}
+			if from := g.enclosing(ws.FileSet.Position(ident.Pos()).Filename, ident.Pos()); from != nil {
+				g.counts[[2]string{from.ID, to.ID}]++
+			}
+		}
+	}
+
+	out := &XRefGraph{Package: packageID(pkg), Nodes: []*XRefNode{}, Edges: []*XRefEdge{}}
+	for _, id := range slices.Sorted(maps.Keys(g.nodes)) {
+		out.Nodes = append(out.Nodes, g.nodes[id])
+	}
+	for edge, count := range g.counts {
+		out.Edges = append(out.Edges, &XRefEdge{From: edge[0], To: edge[1], Count: count})
+	}
+	slices.SortFunc(out.Edges, func(a, b *XRefEdge) int {
+		return cmp.Or(cmp.Compare(a.From, b.From), cmp.Compare(a.To, b.To))
+	})
+	return out, nil
+}
+
+type xrefBuilder struct {
+	ws     *types.Workspace
+	pkg    *types.Package
+	files  map[string]*types.File // By path
+	nodes  map[string]*XRefNode   // By ID
+	counts map[[2]string]int      // References by (from, to)
+}
+
+// node returns the node of the named declaration, adding it if new.
+func (g *xrefBuilder) node(pkg, name, kind string, pos token.Pos, external bool) *XRefNode {
+	id := pkg + "." + name
+	if n := g.nodes[id]; n != nil {
+		return n
+	}
+	position := g.ws.FileSet.Position(pos)
+	n := &XRefNode{
+		ID:       id,
+		Name:     name,
+		Package:  pkg,
+		Kind:     kind,
+		File:     position.Filename,
+		Line:     position.Line,
+		Exported: token.IsExported(name[strings.LastIndex(name, ".")+1:]),
+		External: external,
+	}
+	g.nodes[id] = n
+	return n
+}
+
+// objectNode returns the node of a package-level object or method declared
+// in the workspace, or nil for anything else.
+func (g *xrefBuilder) objectNode(obj gotypes.Object) *XRefNode {
+	if obj == nil || obj.Pkg() == nil {
+		return nil
+	}
+	dir, ok := g.ws.ImportToPath[obj.Pkg().Path()]
+	if !ok {
+		return nil
+	}
+	name, kind := obj.Name(), ""
+	switch o := obj.(type) {
+	case *gotypes.Func:
+		kind = "function"
+		if recv := o.Signature().Recv(); recv != nil {
+			named := namedOf(recv.Type())
+			if named == nil {
+				return nil // Interface method reached through an embedded interface literal
+			}
+			name, kind = named.Obj().Name()+"."+name, "method"
+		}
+	case *gotypes.TypeName:
+		kind = "type"
+		if gotypes.IsInterface(o.Type()) {
+			kind = "interface"
+		}
+	case *gotypes.Var:
+		kind = "variable"
+	case *gotypes.Const:
+		kind = "constant"
+	default:
+		return nil
+	}
+	if kind != "method" && obj.Parent() != obj.Pkg().Scope() {
+		return nil
+	}
+	return g.node(obj.Pkg().Path(), name, kind, obj.Pos(), dir != g.pkg.Path)
+}
+
+// enclosing returns the node of the top-level declaration of file holding
+// pos, or nil when there is none.
+func (g *xrefBuilder) enclosing(file string, pos token.Pos) *XRefNode {
+	f := g.files[file]
+	if f == nil || f.AST == nil || f.Package == nil {
+		return nil
+	}
+	pkg := packageID(f.Package)
+	external := f.Package != g.pkg
+	for _, decl := range f.AST.Decls {
+		if pos < decl.Pos() || pos >= decl.End() {
+			continue
+		}
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Recv != nil {
+				if recv := receiverBaseName(d.Recv); recv != "" {
+					return g.node(pkg, recv+"."+d.Name.Name, "method", d.Name.Pos(), external)
+				}
+			}
+			return g.node(pkg, d.Name.Name, "function", d.Name.Pos(), external)
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				if pos < spec.Pos() || pos >= spec.End() {
+					continue
+				}
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					kind := "type"
+					if _, ok := s.Type.(*ast.InterfaceType); ok {
+						kind = "interface"
+					}
+					return g.node(pkg, s.Name.Name, kind, s.Name.Pos(), external)
+				case *ast.ValueSpec:
+					kind := "variable"
+					if d.Tok == token.CONST {
+						kind = "constant"
+					}
+					name := s.Names[0]
+					if len(s.Values) == len(s.Names) {
+						for i, v := range s.Values {
+							if pos >= v.Pos() && pos < v.End() {
+								name = s.Names[i]
+							}
+						}
+					}
+					return g.node(pkg, name.Name, kind, name.Pos(), external)
+				}
+			}
+		}
+		return nil
+	}
+	return nil
+}
+
+var xrefKinds = map[types.SymbolKind]string{
+	types.FunctionSymbol:  "function",
+	types.MethodSymbol:    "method",
+	types.TypeSymbol:      "type",
+	types.InterfaceSymbol: "interface",
+	types.VariableSymbol:  "variable",
+	types.ConstantSymbol:  "constant",
+}
+
+// packageID returns the import path of pkg, or its directory when it has
+// none.
+func packageID(pkg *types.Package) string {
+	if pkg.ImportPath != "" {
+		return pkg.ImportPath
+	}
+	return pkg.Path
+}
+
+// namedOf returns the named type of t or *t.
+func namedOf(t gotypes.Type) *gotypes.Named {
+	if p, ok := t.(*gotypes.Pointer); ok {
+		t = p.Elem()
+	}
+	named, _ := gotypes.Unalias(t).(*gotypes.Named)
+	return named
+}
@@ -0,0 +1,103 @@
+package analysis
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBuildXRefGraph(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/shop\n\ngo 1.21\n",
+		"cart/cart.go": `package cart
+
+type Cart struct{ items []int }
+
+func (c *Cart) Add(n int) { c.items = append(c.items, n) }
+
+func (c *Cart) Total() int { return len(c.items) + base }
+
+const base = 0
+`,
+		"app/app.go": `package app
+
+import "example.com/shop/cart"
+
+func Run() int {
+	c := &cart.Cart{}
+	c.Add(1)
+	c.Add(2)
+	return c.Total()
+}
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	parser := NewParser(logger)
+	ws, err := parser.ParseWorkspace(dir)
+	if err != nil {
+		t.Fatalf("ParseWorkspace: %v", err)
+	}
+	for _, pkg := range ws.Packages {
+		parser.EnsureTypeChecked(ws, pkg)
+	}
+	finder := NewReferenceFinder(NewSymbolResolver(ws, logger), nil)
+
+	graph, err := BuildXRefGraph(ws, finder, ws.Packages[filepath.Join(dir, "cart")])
+	if err != nil {
+		t.Fatalf("BuildXRefGraph: %v", err)
+	}
+	nodes := make(map[string]*XRefNode)
+	for _, n := range graph.Nodes {
+		nodes[n.ID] = n
+	}
+	if n := nodes["example.com/shop/cart.Cart.Add"]; n == nil || n.Kind != "method" || !n.Exported || n.External {
+		t.Errorf("Cart.Add = %+v", n)
+	}
+	if n := nodes["example.com/shop/cart.base"]; n == nil || n.Kind != "constant" || n.Exported {
+		t.Errorf("base = %+v", n)
+	}
+	if n := nodes["example.com/shop/app.Run"]; n == nil || !n.External {
+		t.Errorf("Run = %+v", n)
+	}
+
+	counts := make(map[[2]string]int)
+	for _, e := range graph.Edges {
+		counts[[2]string{e.From, e.To}] = e.Count
+	}
+	for edge, want := range map[[2]string]int{
+		{"example.com/shop/app.Run", "example.com/shop/cart.Cart.Add"}:     2,
+		{"example.com/shop/app.Run", "example.com/shop/cart.Cart.Total"}:   1,
+		{"example.com/shop/cart.Cart.Total", "example.com/shop/cart.base"}: 1,
+		{"example.com/shop/cart.Cart.Add", "example.com/shop/cart.Cart"}:   1,
+	} {
+		if counts[edge] != want {
+			t.Errorf("edge %v = %d, want %d; edges: %v", edge, counts[edge], want, counts)
+		}
+	}
+
+	// From the caller's side, the same calls are references out of app.
+	graph, err = BuildXRefGraph(ws, finder, ws.Packages[filepath.Join(dir, "app")])
+	if err != nil {
+		t.Fatalf("BuildXRefGraph: %v", err)
+	}
+	for _, e := range graph.Edges {
+		if e.From == "example.com/shop/app.Run" && e.To == "example.com/shop/cart.Cart.Add" && e.Count != 2 {
+			t.Errorf("Run -> Cart.Add = %d, want 2", e.Count)
+		}
+	}
+	if len(graph.Edges) != 3 {
+		t.Errorf("app edges = %+v", graph.Edges)
+	}
+}
@@ -0,0 +1,80 @@
+package analyzers
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+)
+
+// BaselineFile is the name of the baseline file in the workspace root.
+const BaselineFile = "gorefactor-baseline.json"
+
+// Baseline records the findings a project has accepted, usually those
+// present when it adopted the analyzers, so that later runs report only
+// new ones. Findings are matched by rule, file and message, not by line,
+// so they stay matched when code above them moves; a finding recorded
+// once suppresses one occurrence.
+type Baseline struct {
+	Findings []BaselineFinding `json:"findings"`
+}
+
+// BaselineFinding is a finding recorded in a baseline.
+type BaselineFinding struct {
+	Rule    string `json:"rule"`
+	File    string `json:"file"`           // Slash-separated, relative to the workspace root
+	Line    int    `json:"line,omitempty"` // Where the finding was recorded; not matched
+	Message string `json:"message"`
+}
+
+// LoadBaseline reads the baseline at path.
+func LoadBaseline(path string) (*Baseline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var b Baseline
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("parse baseline %s: %w", path, err)
+	}
+	return &b, nil
+}
+
+// Write saves the baseline at path, its findings sorted by file, line and
+// rule so that regenerating it gives small diffs.
+func (b *Baseline) Write(path string) error {
+	findings := slices.Clone(b.Findings)
+	slices.SortFunc(findings, func(x, y BaselineFinding) int {
+		return cmp.Or(
+			cmp.Compare(x.File, y.File),
+			cmp.Compare(x.Line, y.Line),
+			cmp.Compare(x.Rule, y.Rule),
+			cmp.Compare(x.Message, y.Message),
+		)
+	})
+	data, err := json.MarshalIndent(Baseline{Findings: findings}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// Matcher returns a function reporting whether a finding is in the
+// baseline. Each recorded finding matches once, so a second identical
+// finding in the same file is reported as new.
+func (b *Baseline) Matcher() func(rule, file, message string) bool {
+	type key struct{ rule, file, message string }
+	remaining := make(map[key]int)
+	for _, f := range b.Findings {
+		remaining[key{f.Rule, f.File, f.Message}]++
+	}
+	return func(rule, file, message string) bool {
+		k := key{rule, file, message}
+		if remaining[k] == 0 {
+			return false
+		}
+		remaining[k]--
+		return true
+	}
+}
@@ -0,0 +1,41 @@
+package analyzers
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"testing"
+)
+
+func TestBaseline(t *testing.T) {
+	path := filepath.Join(t.TempDir(), BaselineFile)
+	if _, err := LoadBaseline(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("LoadBaseline of a missing file: got %v, want fs.ErrNotExist", err)
+	}
+
+	b := &Baseline{Findings: []BaselineFinding{
+		{Rule: "unused", File: "b/b.go", Line: 9, Message: "function f is unused"},
+		{Rule: "errorwrap", File: "a/a.go", Line: 4, Message: "bare error"},
+		{Rule: "errorwrap", File: "a/a.go", Line: 2, Message: "bare error"},
+	}}
+	if err := b.Write(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadBaseline(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first := loaded.Findings[0]; first.File != "a/a.go" || first.Line != 2 {
+		t.Errorf("first finding = %+v, want a/a.go:2", first)
+	}
+
+	matches := loaded.Matcher()
+	for i, want := range []bool{true, true, false} {
+		if got := matches("errorwrap", "a/a.go", "bare error"); got != want {
+			t.Errorf("match %d of the recorded errorwrap findings = %v, want %v", i, got, want)
+		}
+	}
+	if matches("unused", "b/b.go", "function g is unused") {
+		t.Error("a finding with a different message matched")
+	}
+}
@@ -0,0 +1,342 @@
+// Package doccoverage provides a go/analysis analyzer that reports exported
+// declarations without doc comments and measures how many of a package's
+// exported declarations are documented.
+package doccoverage
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"go/ast"
+	"go/printer"
+	"go/token"
+	"slices"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+
+	"github.com/mamaar/gorefactor/pkg/analyzers/filedata"
+)
+
+// Style selects the comment the suggested fix inserts.
+type Style string
+
+const (
+	// StyleStub inserts "// Name ...".
+	StyleStub Style = "stub"
+	// StyleTemplate inserts a sentence derived from the declaration, such as
+	// "// NewStore returns a new Store ..." or "// IsValid reports whether ...".
+	StyleTemplate Style = "template"
+)
+
+// Result is the typed result returned for MCP consumption. There is one
+// for every exported declaration, documented or not, so coverage can be
+// computed from the results.
+type Result struct {
+	File       string `json:"file"`
+	Line       int    `json:"line"`
+	Column     int    `json:"column"`
+	Package    string `json:"package"` // Import path
+	Name       string `json:"name"`    // Type.Method for methods
+	Kind       string `json:"kind"`    // func, method, type, const or var
+	Documented bool   `json:"documented"`
+}
+
+// PackageCoverage counts the documented exported declarations of a package.
+type PackageCoverage struct {
+	Package    string  `json:"package"`
+	Exported   int     `json:"exported"`
+	Documented int     `json:"documented"`
+	Percent    float64 `json:"percent"` // 100 for packages without exported declarations
+}
+
+// Coverage groups results by package, ordered by import path.
+func Coverage(results []*Result) []PackageCoverage {
+	byPkg := make(map[string]*PackageCoverage)
+	for _, r := range results {
+		pc := byPkg[r.Package]
+		if pc == nil {
+			pc = &PackageCoverage{Package: r.Package}
+			byPkg[r.Package] = pc
+		}
+		pc.Exported++
+		if r.Documented {
+			pc.Documented++
+		}
+	}
+	coverage := make([]PackageCoverage, 0, len(byPkg))
+	for _, pc := range byPkg {
+		pc.Percent = Percent(pc.Documented, pc.Exported)
+		coverage = append(coverage, *pc)
+	}
+	slices.SortFunc(coverage, func(a, b PackageCoverage) int { return cmp.Compare(a.Package, b.Package) })
+	return coverage
+}
+
+// Percent returns documented as a percentage of exported, rounded down to
+// one decimal.
+func Percent(documented, exported int) float64 {
+	if exported == 0 {
+		return 100
+	}
+	return float64(documented*1000/exported) / 10
+}
+
+type config struct {
+	style Style
+}
+
+// Option configures the analyzer.
+type Option func(*config)
+
+// WithStyle sets the comment the suggested fixes insert; StyleStub by
+// default.
+func WithStyle(s Style) Option {
+	return func(c *config) { c.style = s }
+}
+
+const doc = "detects exported declarations without doc comments"
+
+var Analyzer = NewAnalyzer()
+
+// NewAnalyzer creates a configured analyzer. A declaration in a group with
+// a doc comment, such as a documented const block, counts as documented.
+// Methods count only when their receiver type is exported too.
+func NewAnalyzer(opts ...Option) *analysis.Analyzer {
+	cfg := config{style: StyleStub}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return &analysis.Analyzer{
+		Name:     "doccoverage",
+		Doc:      doc,
+		Run:      makeRun(cfg),
+		Requires: []*analysis.Analyzer{filedata.Analyzer},
+	}
+}
+
+func makeRun(cfg config) func(*analysis.Pass) (any, error) {
+	return func(pass *analysis.Pass) (any, error) {
+		fd := pass.ResultOf[filedata.Analyzer].(*filedata.Data)
+		var results []*Result
+
+		for _, file := range pass.Files {
+			content := fd.Content[pass.Fset.File(file.Pos()).Name()]
+			check := func(name *ast.Ident, kind, display string, docs *ast.CommentGroup, at ast.Node, comment string) {
+				p := pass.Fset.Position(name.Pos())
+				results = append(results, &Result{
+					File:       p.Filename,
+					Line:       p.Line,
+					Column:     p.Column,
+					Package:    pass.Pkg.Path(),
+					Name:       display,
+					Kind:       kind,
+					Documented: docs != nil,
+				})
+				if docs != nil {
+					return
+				}
+				pass.Report(analysis.Diagnostic{
+					Pos:     name.Pos(),
+					End:     name.End(),
+					Message: fmt.Sprintf("exported %s %s should have a doc comment", kind, display),
+					SuggestedFixes: []analysis.SuggestedFix{{
+						Message:   "Add a doc comment to " + display,
+						TextEdits: []analysis.TextEdit{insertComment(pass.Fset, content, at.Pos(), comment)},
+					}},
+				})
+			}
+
+			for _, decl := range file.Decls {
+				switch d := decl.(type) {
+				case *ast.FuncDecl:
+					if !d.Name.IsExported() {
+						continue
+					}
+					kind, display := "func", d.Name.Name
+					if d.Recv != nil {
+						recv := receiverName(d.Recv)
+						if !token.IsExported(recv) {
+							continue
+						}
+						kind, display = "method", recv+"."+d.Name.Name
+					}
+					check(d.Name, kind, display, d.Doc, d, cfg.funcComment(d))
+				case *ast.GenDecl:
+					grouped := d.Lparen.IsValid()
+					for _, spec := range d.Specs {
+						switch s := spec.(type) {
+						case *ast.TypeSpec:
+							if !s.Name.IsExported() {
+								continue
+							}
+							docs, at := specDoc(d, s.Doc, s, grouped)
+							check(s.Name, "type", s.Name.Name, docs, at, cfg.typeComment(pass.Fset, s))
+						case *ast.ValueSpec:
+							i := slices.IndexFunc(s.Names, (*ast.Ident).IsExported)
+							if i < 0 {
+								continue
+							}
+							kind := "var"
+							if d.Tok == token.CONST {
+								kind = "const"
+							}
+							docs, at := specDoc(d, s.Doc, s, grouped)
+							check(s.Names[i], kind, s.Names[i].Name, docs, at, cfg.valueComment(s.Names[i].Name, kind))
+						}
+					}
+				}
+			}
+		}
+		return results, nil
+	}
+}
+
+// specDoc returns the doc comment documenting a spec of d, which is the
+// group's comment for grouped specs without their own, and the node a new
+// comment goes above.
+func specDoc(d *ast.GenDecl, doc *ast.CommentGroup, spec ast.Spec, grouped bool) (*ast.CommentGroup, ast.Node) {
+	if !grouped {
+		return d.Doc, d
+	}
+	if doc == nil {
+		doc = d.Doc
+	}
+	return doc, spec
+}
+
+// insertComment returns the edit inserting comment on a line of its own
+// above pos, indented like the line pos is on.
+func insertComment(fset *token.FileSet, content []byte, pos token.Pos, comment string) analysis.TextEdit {
+	offset := fset.Position(pos).Offset
+	indent := ""
+	if offset <= len(content) {
+		lineStart := bytes.LastIndexByte(content[:offset], '\n') + 1
+		if prefix := content[lineStart:offset]; len(bytes.TrimLeft(prefix, " \t")) == 0 {
+			indent = string(prefix)
+		}
+	}
+	return analysis.TextEdit{Pos: pos, End: pos, NewText: []byte(comment + "\n" + indent)}
+}
+
+// funcComment returns the comment for a function or method.
+func (cfg config) funcComment(fn *ast.FuncDecl) string {
+	name := fn.Name.Name
+	if cfg.style != StyleTemplate {
+		return "// " + name + " ..."
+	}
+
+	var results []ast.Expr
+	if fn.Type.Results != nil {
+		for _, f := range fn.Type.Results.List {
+			for range max(len(f.Names), 1) {
+				results = append(results, f.Type)
+			}
+		}
+	}
+	returnsError := len(results) > 0 && isIdent(results[len(results)-1], "error")
+	if returnsError {
+		results = results[:len(results)-1]
+	}
+
+	var sentence string
+	switch {
+	case strings.HasPrefix(name, "New") && len(results) == 1:
+		sentence = name + " returns a new " + typeName(results[0]) + " ..."
+	case len(results) == 1 && isIdent(results[0], "bool"):
+		sentence = name + " reports whether ..."
+	case len(results) > 0:
+		sentence = name + " returns ..."
+	default:
+		sentence = name + " ..."
+	}
+	if returnsError {
+		sentence += " It returns an error if ..."
+	}
+	return "// " + sentence
+}
+
+// typeComment returns the comment for a type.
+func (cfg config) typeComment(fset *token.FileSet, s *ast.TypeSpec) string {
+	name := s.Name.Name
+	if cfg.style != StyleTemplate {
+		return "// " + name + " ..."
+	}
+	switch t := s.Type.(type) {
+	case *ast.StructType:
+		return "// " + name + " holds ..."
+	case *ast.InterfaceType:
+		return "// " + name + " is implemented by ..."
+	case *ast.FuncType:
+		return "// " + name + " is a function that ..."
+	default:
+		if s.Assign.IsValid() {
+			return "// " + name + " is an alias for " + exprString(fset, t) + " ..."
+		}
+		return "// " + name + " is a " + exprString(fset, t) + " ..."
+	}
+}
+
+// valueComment returns the comment for a constant or variable.
+func (cfg config) valueComment(name, kind string) string {
+	if cfg.style != StyleTemplate {
+		return "// " + name + " ..."
+	}
+	if kind == "const" {
+		return "// " + name + " is the ..."
+	}
+	return "// " + name + " holds the ..."
+}
+
+// receiverName returns the base type name of a method receiver.
+func receiverName(recv *ast.FieldList) string {
+	if len(recv.List) == 0 {
+		return ""
+	}
+	t := recv.List[0].Type
+	if star, ok := t.(*ast.StarExpr); ok {
+		t = star.X
+	}
+	switch x := t.(type) {
+	case *ast.IndexExpr:
+		t = x.X
+	case *ast.IndexListExpr:
+		t = x.X
+	}
+	if id, ok := t.(*ast.Ident); ok {
+		return id.Name
+	}
+	return ""
+}
+
+// typeName returns the name of the type t, without pointers and package
+// qualifiers.
+func typeName(t ast.Expr) string {
+	for {
+		switch x := t.(type) {
+		case *ast.StarExpr:
+			t = x.X
+		case *ast.SelectorExpr:
+			return x.Sel.Name
+		case *ast.IndexExpr:
+			t = x.X
+		case *ast.IndexListExpr:
+			t = x.X
+		case *ast.Ident:
+			return x.Name
+		default:
+			return "value"
+		}
+	}
+}
+
+func isIdent(e ast.Expr, name string) bool {
+	id, ok := e.(*ast.Ident)
+	return ok && id.Name == name
+}
+
+func exprString(fset *token.FileSet, e ast.Expr) string {
+	var buf bytes.Buffer
+	_ = printer.Fprint(&buf, fset, e)
+	return buf.String()
+}
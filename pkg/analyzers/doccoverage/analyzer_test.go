@@ -0,0 +1,138 @@
+package doccoverage_test
+
+import (
+	"go/parser"
+	"go/token"
+	"slices"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/analyzers"
+	"github.com/mamaar/gorefactor/pkg/analyzers/doccoverage"
+	wstypes "github.com/mamaar/gorefactor/pkg/types"
+)
+
+func createTestWorkspace(t *testing.T, src string) *wstypes.Workspace {
+	t.Helper()
+	fileSet := token.NewFileSet()
+
+	astFile, err := parser.ParseFile(fileSet, "testpkg.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("Failed to parse test source: %v", err)
+	}
+
+	file := &wstypes.File{
+		Path:            "testpkg.go",
+		AST:             astFile,
+		OriginalContent: []byte(src),
+	}
+	pkg := &wstypes.Package{
+		Name:       "testpkg",
+		Path:       "test/testpkg",
+		ImportPath: "test/testpkg",
+		Files:      map[string]*wstypes.File{"testpkg.go": file},
+	}
+	file.Package = pkg
+
+	return &wstypes.Workspace{
+		Packages: map[string]*wstypes.Package{"test/testpkg": pkg},
+		FileSet:  fileSet,
+	}
+}
+
+const src = `package testpkg
+
+// Store holds values.
+type Store struct{}
+
+func NewStore() *Store { return &Store{} }
+
+func (s *Store) Valid() bool { return true }
+
+func (s *Store) Load(key string) (string, error) { return "", nil }
+
+// Close releases the store.
+func (s *Store) Close() {}
+
+type ID string
+
+// Limits of the store.
+const (
+	MaxKeys = 100
+	MinKeys = 1
+)
+
+var (
+	Default = NewStore()
+	fallback = NewStore()
+)
+
+type private struct{}
+
+func (p private) Exported() {}
+`
+
+func TestDocCoverage(t *testing.T) {
+	ws := createTestWorkspace(t, src)
+	rr, err := analyzers.Run(ws, doccoverage.Analyzer, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := rr.Result.([]*doccoverage.Result)
+	var missing []string
+	for _, r := range results {
+		if !r.Documented {
+			missing = append(missing, r.Kind+" "+r.Name)
+		}
+	}
+	want := []string{"func NewStore", "method Store.Valid", "method Store.Load", "type ID", "var Default"}
+	if !slices.Equal(missing, want) {
+		t.Errorf("missing = %v, want %v", missing, want)
+	}
+	if len(rr.Diagnostics) != len(want) {
+		t.Errorf("got %d diagnostics, want %d", len(rr.Diagnostics), len(want))
+	}
+
+	coverage := doccoverage.Coverage(results)
+	if len(coverage) != 1 {
+		t.Fatalf("coverage = %+v", coverage)
+	}
+	if c := coverage[0]; c.Package != "test/testpkg" || c.Exported != 9 || c.Documented != 4 || c.Percent != 44.4 {
+		t.Errorf("coverage = %+v", c)
+	}
+}
+
+func TestDocCoverageFix(t *testing.T) {
+	for _, tc := range []struct {
+		style doccoverage.Style
+		want  []string
+	}{
+		{doccoverage.StyleStub, []string{
+			"// NewStore ...\n",
+			"// Valid ...\n",
+			"// Load ...\n",
+			"// ID ...\n",
+			"// Default ...\n\t",
+		}},
+		{doccoverage.StyleTemplate, []string{
+			"// NewStore returns a new Store ...\n",
+			"// Valid reports whether ...\n",
+			"// Load returns ... It returns an error if ...\n",
+			"// ID is a string ...\n",
+			"// Default holds the ...\n\t",
+		}},
+	} {
+		ws := createTestWorkspace(t, src)
+		rr, err := analyzers.Run(ws, doccoverage.NewAnalyzer(doccoverage.WithStyle(tc.style)), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, c := range analyzers.DiagnosticsToChanges(ws.FileSet, rr.Diagnostics) {
+			got = append(got, c.NewText)
+		}
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("%s: comments = %q, want %q", tc.style, got, tc.want)
+		}
+	}
+}
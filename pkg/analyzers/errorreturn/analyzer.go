@@ -0,0 +1,181 @@
+// Package errorreturn provides a go/analysis analyzer that detects functions
+// returning an error other than as their last result, and error variables,
+// parameters and named results not named err.
+package errorreturn
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+	"unicode"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+// Violation kinds.
+const (
+	ErrorNotLast = "error_not_last" // An error result precedes other results
+	ErrorName    = "error_name"     // An error variable has an unconventional name
+)
+
+// Result is the typed result returned for MCP consumption.
+type Result struct {
+	File          string `json:"file"`
+	Line          int    `json:"line"`
+	Column        int    `json:"column"`
+	Function      string `json:"function"` // Type.Method for methods
+	Kind          string `json:"kind"`
+	Name          string `json:"name,omitempty"`         // The variable, for ErrorName
+	ReturnOrder   []int  `json:"return_order,omitempty"` // Result i of the conventional order is result ReturnOrder[i], for ErrorNotLast
+	SuggestedName string `json:"suggested_name,omitempty"`
+
+	Pos token.Pos `json:"-"` // The function's name, or the variable's declaration, for fixers
+}
+
+const doc = "detects functions returning an error other than as their last result, and error variables not named err"
+
+var Analyzer = NewAnalyzer()
+
+// NewAnalyzer creates the analyzer. Local variables are only checked in
+// type-checked packages; results and parameters are recognized by their
+// type's name otherwise.
+func NewAnalyzer() *analysis.Analyzer {
+	return &analysis.Analyzer{
+		Name:     "errorreturn",
+		Doc:      doc,
+		Run:      run,
+		Requires: []*analysis.Analyzer{inspect.Analyzer},
+	}
+}
+
+func run(pass *analysis.Pass) (any, error) {
+	insp := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	var results []*Result
+	report := func(pos token.Pos, r *Result, msg string) {
+		p := pass.Fset.Position(pos)
+		r.File, r.Line, r.Column, r.Pos = p.Filename, p.Line, p.Column, pos
+		pass.Report(analysis.Diagnostic{Pos: pos, Message: msg})
+		results = append(results, r)
+	}
+
+	for cur := range insp.Root().Preorder((*ast.FuncDecl)(nil)) {
+		fd := cur.Node().(*ast.FuncDecl)
+		name := fd.Name.Name
+		if fd.Recv != nil && len(fd.Recv.List) > 0 {
+			name = receiverName(fd.Recv.List[0].Type) + "." + name
+		}
+
+		if order := conventionalOrder(pass, fd.Type.Results); order != nil {
+			report(fd.Name.Pos(), &Result{Function: name, Kind: ErrorNotLast, ReturnOrder: order},
+				name+" returns an error before its other results; return it last")
+		}
+
+		for _, id := range errorVars(pass, fd) {
+			report(id.Pos(), &Result{Function: name, Kind: ErrorName, Name: id.Name, SuggestedName: "err"},
+				"error variable "+id.Name+" in "+name+" should be named err")
+		}
+	}
+	return results, nil
+}
+
+// conventionalOrder returns the order that moves the error results of a
+// function last, keeping the order of the others, or nil if they already
+// are last.
+func conventionalOrder(pass *analysis.Pass, results *ast.FieldList) []int {
+	if results == nil {
+		return nil
+	}
+	var others, errs []int
+	i := 0
+	for _, field := range results.List {
+		for range max(len(field.Names), 1) {
+			if isError(pass, field.Type) {
+				errs = append(errs, i)
+			} else {
+				others = append(others, i)
+			}
+			i++
+		}
+	}
+	if len(errs) == 0 || len(others) == 0 || errs[0] > others[len(others)-1] {
+		return nil
+	}
+	return append(others, errs...)
+}
+
+// errorVars returns the parameters, named results and, when the package is
+// type-checked, local variables of fd of type error whose names aren't
+// conventional.
+func errorVars(pass *analysis.Pass, fd *ast.FuncDecl) []*ast.Ident {
+	var vars []*ast.Ident
+	for _, list := range []*ast.FieldList{fd.Type.Params, fd.Type.Results} {
+		if list == nil {
+			continue
+		}
+		for _, field := range list.List {
+			if !isError(pass, field.Type) {
+				continue
+			}
+			for _, id := range field.Names {
+				if !conventional(id.Name) {
+					vars = append(vars, id)
+				}
+			}
+		}
+	}
+	if fd.Body == nil {
+		return vars
+	}
+	ast.Inspect(fd.Body, func(n ast.Node) bool {
+		id, ok := n.(*ast.Ident)
+		if !ok || conventional(id.Name) {
+			return true
+		}
+		if v, ok := pass.TypesInfo.Defs[id].(*types.Var); ok && !v.IsField() && types.Identical(v.Type(), errorType) {
+			vars = append(vars, id)
+		}
+		return true
+	})
+	return vars
+}
+
+var errorType = types.Universe.Lookup("error").Type()
+
+// isError reports whether expr denotes the error type.
+func isError(pass *analysis.Pass, expr ast.Expr) bool {
+	if t := pass.TypesInfo.TypeOf(expr); t != nil {
+		return types.Identical(t, errorType)
+	}
+	id, ok := expr.(*ast.Ident)
+	return ok && id.Name == "error"
+}
+
+// conventional reports whether name is an acceptable name for an error:
+// err, or one qualifying it such as errRead or readErr.
+func conventional(name string) bool {
+	if name == "_" || name == "err" || strings.HasSuffix(name, "Err") || strings.HasSuffix(name, "Error") {
+		return true
+	}
+	rest, ok := strings.CutPrefix(name, "err")
+	if !ok {
+		rest, ok = strings.CutPrefix(name, "Err")
+	}
+	return ok && rest != "" && (unicode.IsUpper(rune(rest[0])) || unicode.IsDigit(rune(rest[0])))
+}
+
+func receiverName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverName(t.X)
+	case *ast.IndexExpr:
+		return receiverName(t.X)
+	case *ast.IndexListExpr:
+		return receiverName(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
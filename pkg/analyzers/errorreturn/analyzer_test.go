@@ -0,0 +1,128 @@
+package errorreturn_test
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"slices"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/analyzers"
+	"github.com/mamaar/gorefactor/pkg/analyzers/errorreturn"
+	wstypes "github.com/mamaar/gorefactor/pkg/types"
+)
+
+func createTestWorkspace(t *testing.T, src string, typeCheck bool) *wstypes.Workspace {
+	t.Helper()
+	fileSet := token.NewFileSet()
+
+	astFile, err := parser.ParseFile(fileSet, "testpkg.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("Failed to parse test source: %v", err)
+	}
+
+	file := &wstypes.File{
+		Path:            "testpkg.go",
+		AST:             astFile,
+		OriginalContent: []byte(src),
+	}
+
+	pkg := &wstypes.Package{
+		Name:  "testpkg",
+		Path:  "test/testpkg",
+		Files: map[string]*wstypes.File{"testpkg.go": file},
+	}
+	file.Package = pkg
+	if typeCheck {
+		info := &types.Info{Types: map[ast.Expr]types.TypeAndValue{}, Defs: map[*ast.Ident]types.Object{}, Uses: map[*ast.Ident]types.Object{}}
+		typesPkg, err := new(types.Config).Check("test/testpkg", fileSet, []*ast.File{astFile}, info)
+		if err != nil {
+			t.Fatalf("Failed to type-check test source: %v", err)
+		}
+		pkg.TypesPkg, pkg.TypesInfo = typesPkg, info
+	}
+
+	return &wstypes.Workspace{
+		Packages: map[string]*wstypes.Package{"test/testpkg": pkg},
+		FileSet:  fileSet,
+	}
+}
+
+func run(t *testing.T, src string, typeCheck bool) []*errorreturn.Result {
+	t.Helper()
+	ws := createTestWorkspace(t, src, typeCheck)
+	rr, err := analyzers.Run(ws, errorreturn.NewAnalyzer(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, ok := rr.Result.([]*errorreturn.Result)
+	if !ok && rr.Result != nil {
+		t.Fatalf("Expected []*errorreturn.Result, got %T", rr.Result)
+	}
+	if len(rr.Diagnostics) != len(results) {
+		t.Errorf("Expected one diagnostic per result, got %d for %d", len(rr.Diagnostics), len(results))
+	}
+	return results
+}
+
+const src = `package testpkg
+
+type Store struct{}
+
+func (s *Store) Load(key string) (error, string, int) {
+	return nil, key, 0
+}
+
+func Parse(s string) (n int, e error) {
+	return len(s), nil
+}
+
+func Handle(failure error) error {
+	e := failure
+	var readErr, errClose error
+	_, _ = readErr, errClose
+	return e
+}
+
+func Fine() (int, error) {
+	err := Handle(nil)
+	return 0, err
+}
+`
+
+func TestErrorReturn(t *testing.T) {
+	results := run(t, src, true)
+
+	var order []int
+	var names []string
+	for _, r := range results {
+		switch r.Kind {
+		case errorreturn.ErrorNotLast:
+			if r.Function != "Store.Load" {
+				t.Errorf("Unexpected ordering finding in %s", r.Function)
+			}
+			order = r.ReturnOrder
+		case errorreturn.ErrorName:
+			names = append(names, r.Function+":"+r.Name)
+		}
+	}
+	if !slices.Equal(order, []int{1, 2, 0}) {
+		t.Errorf("ReturnOrder = %v, want [1 2 0]", order)
+	}
+	if want := []string{"Parse:e", "Handle:failure", "Handle:e"}; !slices.Equal(names, want) {
+		t.Errorf("Names = %v, want %v", names, want)
+	}
+}
+
+func TestErrorReturn_Untyped(t *testing.T) {
+	var names []string
+	for _, r := range run(t, src, false) {
+		if r.Kind == errorreturn.ErrorName {
+			names = append(names, r.Name)
+		}
+	}
+	if want := []string{"e", "failure"}; !slices.Equal(names, want) {
+		t.Errorf("Without type information, names = %v, want %v", names, want)
+	}
+}
@@ -0,0 +1,672 @@
+// Package errorsentinel provides a go/analysis analyzer that detects error
+// checks made by comparing message text — err.Error() == "...",
+// strings.Contains(err.Error(), "...") — and == comparisons against freshly
+// built errors, and rewrites them to errors.Is against sentinel values.
+package errorsentinel
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+
+	"github.com/mamaar/gorefactor/pkg/analyzers/filedata"
+)
+
+// Violation type constants.
+const (
+	StringCompare  = "error_string_compare"
+	StringContains = "error_string_contains"
+	CompareToNew   = "compare_to_new_error"
+)
+
+// Result is the typed result returned for MCP consumption.
+type Result struct {
+	File          string `json:"file"`
+	Line          int    `json:"line"`
+	Column        int    `json:"column"`
+	Function      string `json:"function_name"`
+	ViolationType string `json:"violation_type"`
+	CurrentCode   string `json:"current_code"`
+	Message       string `json:"message"`
+	// Sentinel is the variable the check is rewritten against; empty when
+	// no error with this message is produced in the package.
+	Sentinel    string `json:"sentinel,omitempty"`
+	NewSentinel bool   `json:"new_sentinel,omitempty"`
+}
+
+// Analyzer is the default error sentinel analyzer.
+var Analyzer = NewAnalyzer()
+
+// NewAnalyzer creates a configured *analysis.Analyzer.
+func NewAnalyzer() *analysis.Analyzer {
+	return &analysis.Analyzer{
+		Name:       "errorsentinel",
+		Doc:        "detects error checks that compare message strings and rewrites them to errors.Is against sentinel values",
+		Run:        run,
+		Requires:   []*analysis.Analyzer{filedata.Analyzer, inspect.Analyzer},
+		ResultType: reflect.TypeOf(([]*Result)(nil)),
+	}
+}
+
+const fixMessage = "Replace error string check with errors.Is"
+
+// check is a string-based error check found in the package.
+type check struct {
+	result  *Result
+	file    *ast.File
+	node    ast.Expr // the comparison or strings.Contains call
+	errExpr ast.Expr // the error being checked
+	negate  bool
+	removes map[string]int // package uses removed by the rewrite, keyed by import path
+}
+
+// producer is an errors.New or fmt.Errorf call building a fixed message
+// inside a function body.
+type producer struct {
+	file *ast.File
+	call *ast.CallExpr
+	pkg  string
+}
+
+// sentinel is the package-level variable matched to a message.
+type sentinel struct {
+	name     string
+	existing bool
+	used     bool
+}
+
+func run(pass *analysis.Pass) (any, error) {
+	fd := pass.ResultOf[filedata.Analyzer].(*filedata.Data)
+	insp := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+
+	sentinels := make(map[string]*sentinel)
+	scope := make(map[string]bool)
+	for _, f := range pass.Files {
+		// Sentinels declared in test files can't be used by the package.
+		if isTestFile(pass, f) {
+			collectScope(f, scope, nil)
+		} else {
+			collectScope(f, scope, sentinels)
+		}
+	}
+
+	var checks []*check
+	var producers []*producer
+	operands := make(map[*ast.CallExpr]bool)
+	producersByMsg := make(map[string][]*producer)
+
+	var file *ast.File
+	var content []byte
+	var fn *ast.FuncDecl
+	funcName := func(n ast.Node) string {
+		if fn == nil || n.Pos() < fn.Pos() || n.End() > fn.End() {
+			return ""
+		}
+		return fn.Name.Name
+	}
+	nodeFilter := []ast.Node{(*ast.File)(nil), (*ast.FuncDecl)(nil), (*ast.BinaryExpr)(nil), (*ast.CallExpr)(nil)}
+	insp.Root().Inspect(nodeFilter, func(cur inspector.Cursor) bool {
+		switch node := cur.Node().(type) {
+		case *ast.File:
+			file = node
+			content = fd.Content[pass.Fset.Position(node.Pos()).Filename]
+			fn = nil
+		case *ast.FuncDecl:
+			fn = node
+			if node.Body == nil {
+				return false
+			}
+		case *ast.BinaryExpr:
+			if c := binaryCheck(pass, file, node, operands); c != nil {
+				c.result.Function = funcName(node)
+				c.result.CurrentCode = sourceText(pass.Fset, content, node.Pos(), node.End())
+				checks = append(checks, c)
+			}
+		case *ast.CallExpr:
+			if funcName(node) == "" {
+				return true
+			}
+			if c := containsCheck(pass, file, node); c != nil {
+				c.result.Function = funcName(node)
+				c.result.CurrentCode = sourceText(pass.Fset, content, node.Pos(), node.End())
+				checks = append(checks, c)
+				return true
+			}
+			if operands[node] {
+				return true
+			}
+			if pkg, msg, ok := fixedError(file, node); ok && !isTestFile(pass, file) {
+				p := &producer{file: file, call: node, pkg: pkg}
+				producers = append(producers, p)
+				producersByMsg[msg] = append(producersByMsg[msg], p)
+			}
+		}
+		return true
+	})
+
+	byPosition := func(a, b token.Pos) int {
+		pa, pb := pass.Fset.Position(a), pass.Fset.Position(b)
+		if c := strings.Compare(pa.Filename, pb.Filename); c != 0 {
+			return c
+		}
+		return pa.Offset - pb.Offset
+	}
+	slices.SortFunc(checks, func(a, b *check) int { return byPosition(a.node.Pos(), b.node.Pos()) })
+	slices.SortFunc(producers, func(a, b *producer) int { return byPosition(a.call.Pos(), b.call.Pos()) })
+
+	// Match each check to the message it tests for, creating a sentinel
+	// for messages the package produces but has no variable for yet.
+	for _, c := range checks {
+		msg := c.result.Message
+		if c.result.ViolationType == StringContains {
+			msg = containedMessage(msg, sentinels, producersByMsg)
+		}
+		s := sentinels[msg]
+		if s == nil && len(producersByMsg[msg]) > 0 {
+			name := sentinelName(msg, scope)
+			if name == "" {
+				continue
+			}
+			scope[name] = true
+			s = &sentinel{name: name}
+			sentinels[msg] = s
+		}
+		if s == nil {
+			continue
+		}
+		s.used = true
+		c.result.Sentinel = s.name
+		c.result.NewSentinel = !s.existing
+	}
+
+	fixes := buildFixes(pass, fd, checks, producers, sentinels)
+
+	results := make([]*Result, 0, len(checks))
+	for _, c := range checks {
+		var suggested []analysis.SuggestedFix
+		if edits, ok := fixes[c]; ok {
+			suggested = []analysis.SuggestedFix{{Message: fixMessage, TextEdits: edits}}
+		}
+		pass.Report(analysis.Diagnostic{
+			Pos:            c.node.Pos(),
+			End:            c.node.End(),
+			Message:        diagnosticMessage(c.result),
+			SuggestedFixes: suggested,
+		})
+		results = append(results, c.result)
+	}
+	return results, nil
+}
+
+// collectScope records the package-level names of a file and any existing
+// `var ErrX = errors.New("...")` sentinels.
+func collectScope(f *ast.File, scope map[string]bool, sentinels map[string]*sentinel) {
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Recv == nil {
+				scope[d.Name.Name] = true
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					scope[s.Name.Name] = true
+				case *ast.ValueSpec:
+					for _, name := range s.Names {
+						scope[name.Name] = true
+					}
+					if sentinels == nil || d.Tok != token.VAR || len(s.Names) != 1 || len(s.Values) != 1 {
+						continue
+					}
+					call, ok := s.Values[0].(*ast.CallExpr)
+					if !ok {
+						continue
+					}
+					if pkg, msg, ok := fixedError(f, call); ok && pkg == "errors" {
+						if _, dup := sentinels[msg]; !dup {
+							sentinels[msg] = &sentinel{name: s.Names[0].Name, existing: true}
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// binaryCheck recognises `x.Error() == "msg"` and `x == errors.New("msg")`
+// in either operand order.
+func binaryCheck(pass *analysis.Pass, file *ast.File, expr *ast.BinaryExpr, operands map[*ast.CallExpr]bool) *check {
+	if expr.Op != token.EQL && expr.Op != token.NEQ {
+		return nil
+	}
+	for _, pair := range [][2]ast.Expr{{expr.X, expr.Y}, {expr.Y, expr.X}} {
+		if errExpr := errorCall(pass, pair[0]); errExpr != nil {
+			msg, ok := stringLit(pair[1])
+			if !ok {
+				continue
+			}
+			return newCheck(pass, file, expr, errExpr, expr.Op == token.NEQ, StringCompare, msg, nil)
+		}
+		if call, ok := pair[1].(*ast.CallExpr); ok {
+			pkg, msg, ok := fixedError(file, call)
+			if !ok {
+				continue
+			}
+			operands[call] = true
+			return newCheck(pass, file, expr, pair[0], expr.Op == token.NEQ, CompareToNew, msg, map[string]int{pkg: 1})
+		}
+	}
+	return nil
+}
+
+// containsCheck recognises `strings.Contains(x.Error(), "msg")`.
+func containsCheck(pass *analysis.Pass, file *ast.File, call *ast.CallExpr) *check {
+	if !isPkgCall(file, call, "strings", "Contains") || len(call.Args) != 2 {
+		return nil
+	}
+	errExpr := errorCall(pass, call.Args[0])
+	if errExpr == nil {
+		return nil
+	}
+	msg, ok := stringLit(call.Args[1])
+	if !ok {
+		return nil
+	}
+	return newCheck(pass, file, call, errExpr, false, StringContains, msg, map[string]int{"strings": 1})
+}
+
+func newCheck(pass *analysis.Pass, file *ast.File, node, errExpr ast.Expr, negate bool, kind, msg string, removes map[string]int) *check {
+	pos := pass.Fset.Position(node.Pos())
+	return &check{
+		result: &Result{
+			File:          pos.Filename,
+			Line:          pos.Line,
+			Column:        pos.Column,
+			ViolationType: kind,
+			Message:       msg,
+		},
+		file:    file,
+		node:    node,
+		errExpr: errExpr,
+		negate:  negate,
+		removes: removes,
+	}
+}
+
+// errorCall returns x for a call `x.Error()` where x is (or, without type
+// information, may be) an error.
+func errorCall(pass *analysis.Pass, expr ast.Expr) ast.Expr {
+	call, ok := ast.Unparen(expr).(*ast.CallExpr)
+	if !ok || len(call.Args) != 0 {
+		return nil
+	}
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != "Error" {
+		return nil
+	}
+	if t := pass.TypesInfo.TypeOf(sel.X); t != nil {
+		errType := types.Universe.Lookup("error").Type().Underlying().(*types.Interface)
+		if !types.Implements(t, errType) {
+			return nil
+		}
+	}
+	return sel.X
+}
+
+// fixedError reports whether call is errors.New("msg") or fmt.Errorf("msg")
+// with a message containing no formatting verbs, returning the package and
+// message.
+func fixedError(file *ast.File, call *ast.CallExpr) (string, string, bool) {
+	if len(call.Args) != 1 {
+		return "", "", false
+	}
+	var pkg string
+	switch {
+	case isPkgCall(file, call, "errors", "New"):
+		pkg = "errors"
+	case isPkgCall(file, call, "fmt", "Errorf"):
+		pkg = "fmt"
+	default:
+		return "", "", false
+	}
+	msg, ok := stringLit(call.Args[0])
+	if !ok || msg == "" || (pkg == "fmt" && strings.Contains(msg, "%")) {
+		return "", "", false
+	}
+	return pkg, msg, true
+}
+
+// isPkgCall reports whether call is pkg.name(...) for the standard library
+// package with import path pkg.
+func isPkgCall(file *ast.File, call *ast.CallExpr, pkg, name string) bool {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != name {
+		return false
+	}
+	ident, ok := sel.X.(*ast.Ident)
+	return ok && ident.Obj == nil && ident.Name == importName(file, pkg)
+}
+
+func stringLit(expr ast.Expr) (string, bool) {
+	lit, ok := ast.Unparen(expr).(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	s, err := strconv.Unquote(lit.Value)
+	return s, err == nil
+}
+
+// containedMessage resolves the substring of a strings.Contains check to
+// the one known message containing it, or returns it unchanged when the
+// match is exact or ambiguous.
+func containedMessage(sub string, sentinels map[string]*sentinel, producers map[string][]*producer) string {
+	if sentinels[sub] != nil || len(producers[sub]) > 0 {
+		return sub
+	}
+	candidates := make(map[string]bool)
+	for msg := range sentinels {
+		if strings.Contains(msg, sub) {
+			candidates[msg] = true
+		}
+	}
+	for msg := range producers {
+		if strings.Contains(msg, sub) {
+			candidates[msg] = true
+		}
+	}
+	if len(candidates) != 1 {
+		return sub
+	}
+	for msg := range candidates {
+		return msg
+	}
+	return sub
+}
+
+// sentinelName derives an Err-prefixed name from the first words of a
+// message, adding a numeric suffix when the name is already taken.
+func sentinelName(msg string, scope map[string]bool) string {
+	words := strings.FieldsFunc(msg, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) == 0 {
+		return ""
+	}
+	if len(words) > 4 {
+		words = words[:4]
+	}
+	var b strings.Builder
+	b.WriteString("Err")
+	for _, w := range words {
+		r := []rune(w)
+		b.WriteString(strings.ToUpper(string(r[0])) + string(r[1:]))
+	}
+	name := b.String()
+	for i := 2; scope[name]; i++ {
+		name = b.String() + strconv.Itoa(i)
+	}
+	return name
+}
+
+// fileEdits accumulates the edits and import bookkeeping for one file.
+type fileEdits struct {
+	file    *ast.File
+	edits   []analysis.TextEdit
+	removed map[string]int
+	decls   []string
+}
+
+// buildFixes computes the edits for every fixable check. Edits that are
+// shared between checks — sentinel declarations, replaced producers and
+// import changes — are attached to the first fixable check, since only
+// one fix per diagnostic is applied.
+func buildFixes(pass *analysis.Pass, fd *filedata.Data, checks []*check, producers []*producer, sentinels map[string]*sentinel) map[*check][]analysis.TextEdit {
+	fixes := make(map[*check][]analysis.TextEdit)
+	files := make(map[*ast.File]*fileEdits)
+	var order []*ast.File
+	forFile := func(f *ast.File) *fileEdits {
+		fe := files[f]
+		if fe == nil {
+			fe = &fileEdits{file: f, removed: make(map[string]int)}
+			files[f] = fe
+			order = append(order, f)
+		}
+		return fe
+	}
+
+	var first *check
+	for _, c := range checks {
+		if c.result.Sentinel == "" {
+			continue
+		}
+		if first == nil {
+			first = c
+		}
+		content := fd.Content[pass.Fset.Position(c.file.Pos()).Filename]
+		text := "errors.Is(" + sourceText(pass.Fset, content, c.errExpr.Pos(), c.errExpr.End()) + ", " + c.result.Sentinel + ")"
+		if c.negate {
+			text = "!" + text
+		}
+		fixes[c] = []analysis.TextEdit{{Pos: c.node.Pos(), End: c.node.End(), NewText: []byte(text)}}
+		fe := forFile(c.file)
+		for pkg, n := range c.removes {
+			fe.removed[pkg] += n
+		}
+		fe.removed["errors"]--
+	}
+	if first == nil {
+		return fixes
+	}
+
+	declared := make(map[string]bool)
+	for _, p := range producers {
+		_, msg, _ := fixedError(p.file, p.call)
+		s := sentinels[msg]
+		if s == nil || !s.used {
+			continue
+		}
+		fe := forFile(p.file)
+		fe.edits = append(fe.edits, analysis.TextEdit{Pos: p.call.Pos(), End: p.call.End(), NewText: []byte(s.name)})
+		fe.removed[p.pkg]++
+		if !s.existing && !declared[s.name] {
+			declared[s.name] = true
+			fe.decls = append(fe.decls, s.name+" = errors.New("+strconv.Quote(msg)+")")
+			fe.removed["errors"]--
+		}
+	}
+
+	var shared []analysis.TextEdit
+	for _, f := range order {
+		fe := files[f]
+		shared = append(shared, fe.edits...)
+		shared = append(shared, fe.importEdits(pass)...)
+	}
+	fixes[first] = append(fixes[first], shared...)
+	return fixes
+}
+
+// importEdits removes imports left unused by the rewrite, adds "errors"
+// where it is newly needed and inserts the file's sentinel declarations.
+func (fe *fileEdits) importEdits(pass *analysis.Pass) []analysis.TextEdit {
+	uses := countUses(fe.file)
+	var unused []*ast.ImportSpec
+	needErrors := false
+	for _, pkg := range []string{"errors", "fmt", "strings"} {
+		remaining := uses[pkg] - fe.removed[pkg]
+		spec := importSpec(fe.file, pkg)
+		switch {
+		case spec == nil && remaining > 0 && pkg == "errors":
+			needErrors = true
+		case spec != nil && remaining <= 0 && fe.removed[pkg] > 0:
+			unused = append(unused, spec)
+		}
+	}
+
+	var edits []analysis.TextEdit
+	for _, spec := range unused {
+		if needErrors {
+			// Reuse the spec being dropped so the new import lands where
+			// an import already was.
+			edits = append(edits, analysis.TextEdit{Pos: spec.Pos(), End: spec.End(), NewText: []byte(`"errors"`)})
+			needErrors = false
+			continue
+		}
+		edits = append(edits, removeImport(pass, fe.file, spec))
+	}
+
+	var header string
+	if needErrors {
+		if decl := firstImportDecl(fe.file); decl != nil {
+			if decl.Lparen.IsValid() {
+				edits = append(edits, analysis.TextEdit{Pos: decl.Lparen + 1, End: decl.Lparen + 1, NewText: []byte("\n\t\"errors\"")})
+			} else {
+				edits = append(edits, analysis.TextEdit{Pos: decl.Pos(), End: decl.Pos(), NewText: []byte("import \"errors\"\n")})
+			}
+		} else {
+			header = "\n\nimport \"errors\""
+		}
+	}
+
+	if len(fe.decls) > 0 || header != "" {
+		pos := fe.file.Name.End()
+		if decl := lastImportDecl(fe.file); decl != nil {
+			pos = decl.End()
+		}
+		text := header
+		if len(fe.decls) > 0 {
+			text += "\n\n// Sentinel errors; match them with errors.Is.\nvar (\n"
+			for _, d := range fe.decls {
+				text += "\t" + d + "\n"
+			}
+			text += ")"
+		}
+		edits = append(edits, analysis.TextEdit{Pos: pos, End: pos, NewText: []byte(text)})
+	}
+	return edits
+}
+
+// countUses counts the selector expressions referring to each of the
+// imports the rewrite touches.
+func countUses(file *ast.File) map[string]int {
+	names := make(map[string]string)
+	for _, pkg := range []string{"errors", "fmt", "strings"} {
+		names[importName(file, pkg)] = pkg
+	}
+	uses := make(map[string]int)
+	ast.Inspect(file, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if ident, ok := sel.X.(*ast.Ident); ok && ident.Obj == nil {
+			if pkg, ok := names[ident.Name]; ok {
+				uses[pkg]++
+			}
+		}
+		return true
+	})
+	return uses
+}
+
+func importSpec(file *ast.File, path string) *ast.ImportSpec {
+	for _, spec := range file.Imports {
+		if p, err := strconv.Unquote(spec.Path.Value); err == nil && p == path {
+			return spec
+		}
+	}
+	return nil
+}
+
+// importName returns the name a file refers to an import by, defaulting to
+// the last path element when the package isn't imported.
+func importName(file *ast.File, path string) string {
+	if spec := importSpec(file, path); spec != nil && spec.Name != nil {
+		return spec.Name.Name
+	}
+	return filepath.Base(path)
+}
+
+// removeImport deletes an import spec, or the whole declaration when it is
+// the only spec in it.
+func removeImport(pass *analysis.Pass, file *ast.File, spec *ast.ImportSpec) analysis.TextEdit {
+	for _, decl := range file.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.IMPORT || !slices.Contains(gd.Specs, ast.Spec(spec)) {
+			continue
+		}
+		if len(gd.Specs) == 1 {
+			return analysis.TextEdit{Pos: gd.Pos(), End: gd.End()}
+		}
+	}
+	// Take the whole line so no blank line is left behind.
+	tf := pass.Fset.File(spec.Pos())
+	line := tf.Line(spec.Pos())
+	start := tf.LineStart(line)
+	end := spec.End()
+	if line < tf.LineCount() {
+		end = tf.LineStart(line + 1)
+	}
+	return analysis.TextEdit{Pos: start, End: end}
+}
+
+func firstImportDecl(file *ast.File) *ast.GenDecl {
+	for _, decl := range file.Decls {
+		if gd, ok := decl.(*ast.GenDecl); ok && gd.Tok == token.IMPORT {
+			return gd
+		}
+	}
+	return nil
+}
+
+func lastImportDecl(file *ast.File) *ast.GenDecl {
+	var last *ast.GenDecl
+	for _, decl := range file.Decls {
+		if gd, ok := decl.(*ast.GenDecl); ok && gd.Tok == token.IMPORT {
+			last = gd
+		}
+	}
+	return last
+}
+
+func isTestFile(pass *analysis.Pass, file *ast.File) bool {
+	return strings.HasSuffix(pass.Fset.Position(file.Pos()).Filename, "_test.go")
+}
+
+func diagnosticMessage(r *Result) string {
+	var what string
+	switch r.ViolationType {
+	case StringContains:
+		what = "error checked by searching its message"
+	case CompareToNew:
+		what = "error compared with a newly created error, which is never equal"
+	default:
+		what = "error checked by comparing its message"
+	}
+	if r.Sentinel == "" {
+		return what + ": introduce a sentinel error and use errors.Is"
+	}
+	return what + ": use errors.Is(err, " + r.Sentinel + ")"
+}
+
+func sourceText(fset *token.FileSet, content []byte, from, to token.Pos) string {
+	if len(content) == 0 {
+		return ""
+	}
+	start := fset.Position(from).Offset
+	end := fset.Position(to).Offset
+	if start < 0 || end < 0 || start >= len(content) || end > len(content) || start >= end {
+		return ""
+	}
+	return string(content[start:end])
+}
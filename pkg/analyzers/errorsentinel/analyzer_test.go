@@ -0,0 +1,231 @@
+package errorsentinel_test
+
+import (
+	"go/format"
+	"go/parser"
+	"go/token"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/analyzers"
+	"github.com/mamaar/gorefactor/pkg/analyzers/errorsentinel"
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+func createTestWorkspace(t *testing.T, src string) *types.Workspace {
+	t.Helper()
+	fileSet := token.NewFileSet()
+
+	astFile, err := parser.ParseFile(fileSet, "testpkg.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("Failed to parse test source: %v", err)
+	}
+
+	file := &types.File{
+		Path:            "testpkg.go",
+		AST:             astFile,
+		OriginalContent: []byte(src),
+	}
+
+	pkg := &types.Package{
+		Name:  "testpkg",
+		Path:  "test/testpkg",
+		Files: map[string]*types.File{"testpkg.go": file},
+	}
+	file.Package = pkg
+
+	return &types.Workspace{
+		Packages: map[string]*types.Package{"test/testpkg": pkg},
+		FileSet:  fileSet,
+	}
+}
+
+func run(t *testing.T, src string) ([]*errorsentinel.Result, string) {
+	t.Helper()
+	ws := createTestWorkspace(t, src)
+	rr, err := analyzers.Run(ws, errorsentinel.Analyzer, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, ok := rr.Result.([]*errorsentinel.Result)
+	if !ok {
+		t.Fatalf("Expected []*errorsentinel.Result, got %T", rr.Result)
+	}
+
+	changes := analyzers.DiagnosticsToChanges(ws.FileSet, rr.Diagnostics)
+	slices.SortFunc(changes, func(a, b types.Change) int { return b.Start - a.Start })
+	out := src
+	for _, c := range changes {
+		out = out[:c.Start] + c.NewText + out[c.End:]
+	}
+	formatted, err := format.Source([]byte(out))
+	if err != nil {
+		t.Fatalf("fixed source does not parse: %v\n%s", err, out)
+	}
+	return results, string(formatted)
+}
+
+func TestErrorSentinel_StringCompare(t *testing.T) {
+	src := `package testpkg
+
+import (
+	"errors"
+	"fmt"
+)
+
+func find(id string) error {
+	if id == "" {
+		return fmt.Errorf("user not found")
+	}
+	return errors.New("user not found")
+}
+
+func handle() bool {
+	err := find("x")
+	return err.Error() == "user not found"
+}
+
+func other() bool {
+	return find("y").Error() != "user not found"
+}
+`
+	results, fixed := run(t, src)
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 violations, got %d", len(results))
+	}
+	for _, r := range results {
+		if r.ViolationType != errorsentinel.StringCompare {
+			t.Errorf("Expected violation type %q, got %q", errorsentinel.StringCompare, r.ViolationType)
+		}
+		if r.Sentinel != "ErrUserNotFound" || !r.NewSentinel {
+			t.Errorf("Expected new sentinel ErrUserNotFound, got %q (new=%v)", r.Sentinel, r.NewSentinel)
+		}
+	}
+
+	want := `package testpkg
+
+import (
+	"errors"
+)
+
+// Sentinel errors; match them with errors.Is.
+var (
+	ErrUserNotFound = errors.New("user not found")
+)
+
+func find(id string) error {
+	if id == "" {
+		return ErrUserNotFound
+	}
+	return ErrUserNotFound
+}
+
+func handle() bool {
+	err := find("x")
+	return errors.Is(err, ErrUserNotFound)
+}
+
+func other() bool {
+	return !errors.Is(find("y"), ErrUserNotFound)
+}
+`
+	if fixed != want {
+		t.Errorf("Fixed source mismatch:\n%s", fixed)
+	}
+}
+
+func TestErrorSentinel_ContainsReusesExisting(t *testing.T) {
+	src := `package testpkg
+
+import (
+	"errors"
+	"strings"
+)
+
+var errClosed = errors.New("connection closed by peer")
+
+func read() error { return errClosed }
+
+func retry() bool {
+	err := read()
+	return strings.Contains(err.Error(), "closed by peer")
+}
+`
+	results, fixed := run(t, src)
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 violation, got %d", len(results))
+	}
+	if r := results[0]; r.ViolationType != errorsentinel.StringContains || r.Sentinel != "errClosed" || r.NewSentinel {
+		t.Errorf("Unexpected result %+v", r)
+	}
+	if strings.Contains(fixed, `"strings"`) {
+		t.Errorf("Expected unused strings import to be removed:\n%s", fixed)
+	}
+	if !strings.Contains(fixed, "return errors.Is(err, errClosed)") {
+		t.Errorf("Expected errors.Is rewrite:\n%s", fixed)
+	}
+}
+
+func TestErrorSentinel_CompareToNew(t *testing.T) {
+	src := `package testpkg
+
+import "fmt"
+
+func open() error { return fmt.Errorf("busy") }
+
+func busy() bool {
+	return open() == fmt.Errorf("busy")
+}
+`
+	results, fixed := run(t, src)
+	if len(results) != 1 || results[0].ViolationType != errorsentinel.CompareToNew {
+		t.Fatalf("Expected 1 %s violation, got %+v", errorsentinel.CompareToNew, results)
+	}
+	if !strings.Contains(fixed, `import "errors"`) || strings.Contains(fixed, `"fmt"`) {
+		t.Errorf("Expected fmt import to be replaced by errors:\n%s", fixed)
+	}
+	if !strings.Contains(fixed, "return errors.Is(open(), ErrBusy)") {
+		t.Errorf("Expected errors.Is rewrite:\n%s", fixed)
+	}
+}
+
+func TestErrorSentinel_NoProducerNotFixed(t *testing.T) {
+	src := `package testpkg
+
+import "os"
+
+func missing(err error) bool {
+	return err.Error() == "file does not exist"
+}
+
+var _ = os.Open
+`
+	results, fixed := run(t, src)
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 violation, got %d", len(results))
+	}
+	if results[0].Sentinel != "" {
+		t.Errorf("Expected no sentinel, got %q", results[0].Sentinel)
+	}
+	if fixed != src {
+		t.Errorf("Expected no changes, got:\n%s", fixed)
+	}
+}
+
+func TestErrorSentinel_NameConflict(t *testing.T) {
+	src := `package testpkg
+
+import "errors"
+
+type ErrTimeout struct{}
+
+func wait() error { return errors.New("timeout") }
+
+func timedOut(err error) bool { return err.Error() == "timeout" }
+`
+	results, _ := run(t, src)
+	if len(results) != 1 || results[0].Sentinel != "ErrTimeout2" {
+		t.Fatalf("Expected sentinel ErrTimeout2, got %+v", results)
+	}
+}
@@ -9,8 +9,11 @@ import (
 	"reflect"
 	"slices"
 	"strings"
+	"unicode"
 
 	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
 
 	"github.com/mamaar/gorefactor/pkg/analyzers/filedata"
 )
@@ -72,7 +75,7 @@ func NewAnalyzer(opts ...Option) *analysis.Analyzer {
 		Name:       "errorwrap",
 		Doc:        "detects improper error wrapping: bare returns, %v instead of %w, and no descriptive context",
 		Run:        makeRun(cfg),
-		Requires:   []*analysis.Analyzer{filedata.Analyzer},
+		Requires:   []*analysis.Analyzer{filedata.Analyzer, inspect.Analyzer},
 		ResultType: reflect.TypeOf(([]*Result)(nil)),
 	}
 }
@@ -80,48 +83,41 @@ func NewAnalyzer(opts ...Option) *analysis.Analyzer {
 func makeRun(cfg *config) func(*analysis.Pass) (any, error) {
 	return func(pass *analysis.Pass) (any, error) {
 		fd := pass.ResultOf[filedata.Analyzer].(*filedata.Data)
+		insp := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
 		var results []*Result
-
-		for _, file := range pass.Files {
-			filename := pass.Fset.Position(file.Pos()).Filename
-			content := fd.Content[filename]
-			results = append(results, analyzeFile(pass, cfg, file, content)...)
-		}
+		var file *ast.File
+		var content []byte
+		var currentFunc string
+		funcReturnsError := false
+
+		nodeFilter := []ast.Node{(*ast.File)(nil), (*ast.FuncDecl)(nil), (*ast.ReturnStmt)(nil)}
+		insp.Root().Inspect(nodeFilter, func(cur inspector.Cursor) bool {
+			switch node := cur.Node().(type) {
+			case *ast.File:
+				file = node
+				content = fd.Content[pass.Fset.Position(node.Pos()).Filename]
+				currentFunc = ""
+				funcReturnsError = false
+			case *ast.FuncDecl:
+				currentFunc = node.Name.Name
+				funcReturnsError = returnsError(node)
+				if !funcReturnsError {
+					return false
+				}
+			case *ast.ReturnStmt:
+				if !funcReturnsError {
+					return true
+				}
+				violations := checkReturnStmt(pass, cfg, file, content, node, currentFunc)
+				results = append(results, violations...)
+			}
+			return true
+		})
 
 		return results, nil
 	}
 }
 
-func analyzeFile(pass *analysis.Pass, cfg *config, file *ast.File, content []byte) []*Result {
-	var results []*Result
-	var currentFunc string
-	funcReturnsError := false
-
-	ast.Inspect(file, func(n ast.Node) bool {
-		if n == nil {
-			return false
-		}
-
-		switch node := n.(type) {
-		case *ast.FuncDecl:
-			currentFunc = node.Name.Name
-			funcReturnsError = returnsError(node)
-			if !funcReturnsError {
-				return false
-			}
-		case *ast.ReturnStmt:
-			if !funcReturnsError {
-				return true
-			}
-			violations := checkReturnStmt(pass, cfg, file, content, node, currentFunc)
-			results = append(results, violations...)
-		}
-		return true
-	})
-
-	return results
-}
-
 func returnsError(fn *ast.FuncDecl) bool {
 	if fn.Type.Results == nil {
 		return false
@@ -392,7 +388,7 @@ func suggestContext(funcName string) string {
 	var words []string
 	current := strings.Builder{}
 	for i, r := range funcName {
-		if i > 0 && r >= 'A' && r <= 'Z' {
+		if i > 0 && unicode.IsUpper(r) {
 			if current.Len() > 0 {
 				words = append(words, strings.ToLower(current.String()))
 				current.Reset()
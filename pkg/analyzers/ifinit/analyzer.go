@@ -7,6 +7,8 @@ import (
 	"strings"
 
 	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
 
 	"github.com/mamaar/gorefactor/pkg/analyzers/filedata"
 )
@@ -26,32 +28,22 @@ var Analyzer = &analysis.Analyzer{
 	Name:     "ifinit",
 	Doc:      "detects if-init assignments that should be split into separate assignment and if-check",
 	Run:      run,
-	Requires: []*analysis.Analyzer{filedata.Analyzer},
+	Requires: []*analysis.Analyzer{filedata.Analyzer, inspect.Analyzer},
 }
 
 func run(pass *analysis.Pass) (any, error) {
 	fd := pass.ResultOf[filedata.Analyzer].(*filedata.Data)
+	insp := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
 	var results []*Result
-
-	for _, file := range pass.Files {
-		pos := pass.Fset.Position(file.Pos())
-		content := fd.Content[pos.Filename]
-		results = append(results, analyzeFile(pass, file, content)...)
-	}
-
-	return results, nil
-}
-
-func analyzeFile(pass *analysis.Pass, file *ast.File, content []byte) []*Result {
-	var results []*Result
+	var content []byte
 	var currentFunc string
 
-	ast.Inspect(file, func(n ast.Node) bool {
-		if n == nil {
-			return false
-		}
-
-		switch node := n.(type) {
+	nodeFilter := []ast.Node{(*ast.File)(nil), (*ast.FuncDecl)(nil), (*ast.IfStmt)(nil)}
+	insp.Root().Inspect(nodeFilter, func(cur inspector.Cursor) bool {
+		switch node := cur.Node().(type) {
+		case *ast.File:
+			content = fd.Content[pass.Fset.Position(node.Pos()).Filename]
+			currentFunc = ""
 		case *ast.FuncDecl:
 			currentFunc = node.Name.Name
 		case *ast.IfStmt:
@@ -126,7 +118,7 @@ func analyzeFile(pass *analysis.Pass, file *ast.File, content []byte) []*Result
 		return true
 	})
 
-	return results
+	return results, nil
 }
 
 func sourceText(fset *token.FileSet, content []byte, from, to token.Pos) string {
@@ -0,0 +1,90 @@
+package analyzers
+
+import (
+	"go/ast"
+	"go/token"
+	"reflect"
+	"slices"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// IgnoreDirective starts a comment that suppresses findings:
+//
+//	//gorefactor:ignore rule[,rule...] [reason]
+//
+// It covers its own line and the line after its comment group, so it can
+// follow a statement or precede it, in a doc comment or on its own. The
+// rule "all" suppresses every analyzer's findings.
+const IgnoreDirective = "//gorefactor:ignore"
+
+// Ignores records the rules ignore directives suppress, by file and line.
+type Ignores map[string]map[int][]string
+
+// NewIgnores collects the ignore directives of files.
+func NewIgnores(fset *token.FileSet, files []*ast.File) Ignores {
+	ig := make(Ignores)
+	for _, f := range files {
+		for _, group := range f.Comments {
+			for _, c := range group.List {
+				rest, ok := strings.CutPrefix(c.Text, IgnoreDirective)
+				if !ok || rest != "" && rest[0] != ' ' && rest[0] != '\t' {
+					continue
+				}
+				fields := strings.Fields(rest)
+				if len(fields) == 0 {
+					continue
+				}
+				rules := strings.Split(fields[0], ",")
+				pos := fset.Position(c.Pos())
+				ig.add(pos.Filename, pos.Line, rules)
+				ig.add(pos.Filename, fset.Position(group.End()).Line+1, rules)
+			}
+		}
+	}
+	return ig
+}
+
+func (ig Ignores) add(file string, line int, rules []string) {
+	if ig[file] == nil {
+		ig[file] = make(map[int][]string)
+	}
+	ig[file][line] = append(ig[file][line], rules...)
+}
+
+// Ignored reports whether a finding of rule at line of file is suppressed.
+func (ig Ignores) Ignored(rule, file string, line int) bool {
+	rules := ig[file][line]
+	return slices.Contains(rules, rule) || slices.Contains(rules, "all")
+}
+
+// filterDiagnostics drops the diagnostics of rule that ig suppresses.
+func (ig Ignores) filterDiagnostics(fset *token.FileSet, rule string, diags []analysis.Diagnostic) []analysis.Diagnostic {
+	return slices.DeleteFunc(diags, func(d analysis.Diagnostic) bool {
+		pos := fset.Position(d.Pos)
+		return ig.Ignored(rule, pos.Filename, pos.Line)
+	})
+}
+
+// filterResult drops the findings of rule that ig suppresses from an
+// analyzer's result, when it is a slice of structs, or pointers to them,
+// with File and Line fields. Other results are returned as they are.
+func (ig Ignores) filterResult(rule string, result any) any {
+	v := reflect.ValueOf(result)
+	if len(ig) == 0 || v.Kind() != reflect.Slice {
+		return result
+	}
+	kept := reflect.MakeSlice(v.Type(), 0, v.Len())
+	for i := range v.Len() {
+		elem := v.Index(i)
+		if s := reflect.Indirect(elem); s.Kind() == reflect.Struct {
+			file, line := s.FieldByName("File"), s.FieldByName("Line")
+			if file.Kind() == reflect.String && line.CanInt() && ig.Ignored(rule, file.String(), int(line.Int())) {
+				continue
+			}
+		}
+		kept = reflect.Append(kept, elem)
+	}
+	return kept.Interface()
+}
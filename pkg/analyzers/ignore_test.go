@@ -0,0 +1,67 @@
+package analyzers
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+const ignoreSrc = `package p
+
+//gorefactor:ignore complexity,errorwrap legacy code
+// f is complicated.
+func f() {
+	g() //gorefactor:ignore all
+	g()
+}
+
+//gorefactor:ignoreall nothing
+func g() {}
+`
+
+type ignoreFinding struct {
+	File string
+	Line int
+}
+
+func TestIgnores(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", ignoreSrc, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ig := NewIgnores(fset, []*ast.File{f})
+
+	tests := []struct {
+		rule string
+		line int
+		want bool
+	}{
+		{"complexity", 3, true}, // The directive's own line
+		{"complexity", 5, true}, // The line after its comment group
+		{"errorwrap", 5, true},
+		{"ifinit", 5, false},
+		{"ifinit", 6, true},
+		{"ifinit", 7, true}, // The line after a trailing directive
+		{"complexity", 11, false},
+	}
+	for _, tt := range tests {
+		if got := ig.Ignored(tt.rule, "p.go", tt.line); got != tt.want {
+			t.Errorf("Ignored(%s, line %d) = %v, want %v", tt.rule, tt.line, got, tt.want)
+		}
+	}
+
+	results := ig.filterResult("complexity", []*ignoreFinding{{"p.go", 5}, {"p.go", 11}}).([]*ignoreFinding)
+	if len(results) != 1 || results[0].Line != 11 {
+		t.Errorf("filterResult kept %v, want the finding on line 11", results)
+	}
+
+	pos := func(line int) token.Pos { return fset.File(f.Pos()).LineStart(line) }
+	diags := ig.filterDiagnostics(fset, "errorwrap", []analysis.Diagnostic{{Pos: pos(5)}, {Pos: pos(8)}})
+	if len(diags) != 1 || diags[0].Pos != pos(8) {
+		t.Errorf("filterDiagnostics kept %v, want the diagnostic on line 8", diags)
+	}
+}
@@ -0,0 +1,919 @@
+// Package loopconv provides a go/analysis analyzer that finds loops doing
+// what a function of the slices or maps packages does, such as searching a
+// slice or collecting a map's keys, and suggests the call instead.
+package loopconv
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"go/version"
+	"slices"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+
+	"github.com/mamaar/gorefactor/pkg/analyzers/filedata"
+)
+
+// Conversion kinds.
+const (
+	Contains = "contains" // A search for a value → slices.Contains
+	Index    = "index"    // A search for a value's index → slices.Index
+	Filter   = "filter"   // Appending the kept elements to s[:0] → slices.DeleteFunc
+	Sort     = "sort"     // sort.Slice comparing elements or a field of them → slices.Sort or slices.SortFunc
+	Keys     = "keys"     // Appending a map's keys or values to a nil slice → slices.Collect or slices.Sorted of maps.Keys or maps.Values
+	Copy     = "copy"     // Copying a map's entries into another → maps.Copy
+)
+
+// Kinds lists the conversion kinds.
+var Kinds = []string{Contains, Index, Filter, Sort, Keys, Copy}
+
+// Result is the typed result returned for MCP consumption.
+type Result struct {
+	File        string `json:"file"`
+	Line        int    `json:"line"`
+	Column      int    `json:"column"`
+	Kind        string `json:"kind"`
+	Replacement string `json:"replacement"` // The function replacing the loop, such as slices.Contains
+	Message     string `json:"message"`
+}
+
+type config struct {
+	goVersion string
+	kinds     []string
+}
+
+// Option configures the analyzer.
+type Option func(*config)
+
+// WithGoVersion sets the Go version of the module, as in the go directive
+// of its go.mod ("1.22" or "go1.22"). The slices and maps packages need
+// go1.21, and iterating over maps.Keys go1.23; a //go:build constraint on a
+// newer version raises the version of its file.
+func WithGoVersion(v string) Option {
+	return func(c *config) {
+		if v != "" && !strings.HasPrefix(v, "go") {
+			v = "go" + v
+		}
+		c.goVersion = v
+	}
+}
+
+// WithKinds limits the analyzer to the given conversion kinds.
+func WithKinds(kinds ...string) Option {
+	return func(c *config) { c.kinds = kinds }
+}
+
+const doc = "detects loops that a function of the slices or maps packages can replace"
+
+var Analyzer = NewAnalyzer()
+
+// NewAnalyzer creates a configured analyzer. It needs type information to
+// tell slices from maps and to check that the replacement keeps the
+// types, so it reports nothing in packages that aren't type-checked.
+//
+// A loop is only replaced when the call does the same: searches whose if
+// statement does nothing else than return or set a flag and break, filters
+// of a local slice not used after the loop (slices.DeleteFunc zeroes the
+// elements past the kept ones), and keys collected into a nil slice, since
+// slices.Collect of an empty map is nil where make([]K, 0) isn't.
+func NewAnalyzer(opts ...Option) *analysis.Analyzer {
+	var cfg config
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return &analysis.Analyzer{
+		Name:     "loopconv",
+		Doc:      doc,
+		Run:      makeRun(cfg),
+		Requires: []*analysis.Analyzer{filedata.Analyzer},
+	}
+}
+
+func makeRun(cfg config) func(*analysis.Pass) (any, error) {
+	return func(pass *analysis.Pass) (any, error) {
+		if pass.TypesInfo == nil || len(pass.TypesInfo.Types) == 0 {
+			return []*Result(nil), nil
+		}
+		fd := pass.ResultOf[filedata.Analyzer].(*filedata.Data)
+		var results []*Result
+
+		for _, file := range pass.Files {
+			fileVersion := cfg.goVersion
+			if file.GoVersion != "" {
+				fileVersion = file.GoVersion
+			}
+			allows := func(v string) bool { return fileVersion == "" || version.Compare(fileVersion, v) >= 0 }
+			if !allows("go1.21") {
+				continue
+			}
+
+			c := &converter{
+				pass:    pass,
+				file:    file,
+				content: fd.Content[pass.Fset.File(file.Pos()).Name()],
+				iter:    allows("go1.23"),
+			}
+			c.report = func(kind string, pos, end token.Pos, replacement string, edit analysis.TextEdit) {
+				if len(cfg.kinds) > 0 && !slices.Contains(cfg.kinds, kind) {
+					return
+				}
+				if c.commented(edit.Pos, edit.End) {
+					return
+				}
+				message := fmt.Sprintf("loop can be %s", replacement)
+				if kind == Sort {
+					message = fmt.Sprintf("sort call can be %s", replacement)
+				}
+				p := pass.Fset.Position(pos)
+				results = append(results, &Result{
+					File:        p.Filename,
+					Line:        p.Line,
+					Column:      p.Column,
+					Kind:        kind,
+					Replacement: replacement,
+					Message:     message,
+				})
+				pass.Report(analysis.Diagnostic{
+					Pos:            pos,
+					End:            end,
+					Category:       kind,
+					Message:        message,
+					SuggestedFixes: []analysis.SuggestedFix{{Message: "Use " + replacement, TextEdits: []analysis.TextEdit{edit}}},
+				})
+			}
+			c.run()
+		}
+		return results, nil
+	}
+}
+
+// converter finds the loop conversions of one file.
+type converter struct {
+	pass    *analysis.Pass
+	file    *ast.File
+	content []byte
+	iter    bool // The file may use the iterator functions of go1.23
+	bodies  []*ast.BlockStmt
+	report  func(kind string, pos, end token.Pos, replacement string, edit analysis.TextEdit)
+}
+
+func (c *converter) run() {
+	ast.Inspect(c.file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			if n.Body != nil {
+				c.bodies = append(c.bodies, n.Body)
+			}
+		case *ast.FuncLit:
+			c.bodies = append(c.bodies, n.Body)
+		}
+		return true
+	})
+	ast.Inspect(c.file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.BlockStmt:
+			c.checkList(n.List)
+		case *ast.CaseClause:
+			c.checkList(n.Body)
+		case *ast.CommClause:
+			c.checkList(n.Body)
+		case *ast.ExprStmt:
+			if call, ok := n.X.(*ast.CallExpr); ok {
+				c.checkSort(call)
+			}
+		}
+		return true
+	})
+}
+
+// checkList looks for conversions among a list of statements, which span
+// the loop and the statements around it they replace.
+func (c *converter) checkList(list []ast.Stmt) {
+	for i := 0; i < len(list); i++ {
+		switch {
+		case c.checkSearch(list, i), c.checkCopy(list[i]):
+		case c.checkFilter(list, i), c.checkKeys(list, i):
+			i++ // The loop after the declaration goes with it
+		}
+	}
+}
+
+// loop is a loop over the elements of a slice.
+type loop struct {
+	stmt  ast.Stmt
+	s     ast.Expr
+	elem  *types.Slice
+	body  *ast.BlockStmt
+	index types.Object // The index variable, if any
+	value types.Object // The element variable, if any
+}
+
+// sliceLoop returns the loop stmt is when it goes over the elements of a
+// slice in order: a range statement, or for i := 0; i < len(s); i++.
+func (c *converter) sliceLoop(stmt ast.Stmt) *loop {
+	var l loop
+	switch stmt := stmt.(type) {
+	case *ast.RangeStmt:
+		if stmt.Tok != token.DEFINE && (stmt.Key != nil || stmt.Value != nil) {
+			return nil
+		}
+		l.stmt, l.s, l.body = stmt, stmt.X, stmt.Body
+		l.index, l.value = c.defined(stmt.Key), c.defined(stmt.Value)
+	case *ast.ForStmt:
+		init, ok := stmt.Init.(*ast.AssignStmt)
+		if !ok || init.Tok != token.DEFINE || len(init.Lhs) != 1 || len(init.Rhs) != 1 || !isIntLit(init.Rhs[0], "0") {
+			return nil
+		}
+		i := c.defined(init.Lhs[0])
+		cond, ok := stmt.Cond.(*ast.BinaryExpr)
+		if i == nil || !ok || cond.Op != token.LSS || !c.is(cond.X, i) {
+			return nil
+		}
+		n, ok := cond.Y.(*ast.CallExpr)
+		if !ok || len(n.Args) != 1 || !c.builtin(n.Fun, "len") {
+			return nil
+		}
+		post, ok := stmt.Post.(*ast.IncDecStmt)
+		if !ok || post.Tok != token.INC || !c.is(post.X, i) {
+			return nil
+		}
+		l.stmt, l.s, l.body, l.index = stmt, n.Args[0], stmt.Body, i
+	default:
+		return nil
+	}
+	slice, ok := c.typeOf(l.s).Underlying().(*types.Slice)
+	if !ok || !pure(l.s) {
+		return nil
+	}
+	l.elem = slice
+	return &l
+}
+
+// element reports whether e is the loop's current element: the element
+// variable or s[i].
+func (c *converter) element(l *loop, e ast.Expr) bool {
+	if l.value != nil {
+		return c.is(e, l.value)
+	}
+	ix, ok := e.(*ast.IndexExpr)
+	return ok && l.index != nil && c.is(ix.Index, l.index) && same(ix.X, l.s)
+}
+
+// checkSearch converts a loop returning or recording whether, or where, it
+// finds a value:
+//
+//	for _, v := range s { if v == x { return true } }
+//	return false
+//
+//	found := false
+//	for _, v := range s { if v == x { found = true; break } }
+func (c *converter) checkSearch(list []ast.Stmt, i int) bool {
+	l := c.sliceLoop(list[i])
+	if l == nil || len(l.body.List) != 1 {
+		return false
+	}
+	ifStmt, ok := l.body.List[0].(*ast.IfStmt)
+	if !ok || ifStmt.Init != nil || ifStmt.Else != nil {
+		return false
+	}
+	x := c.searched(l, ifStmt.Cond)
+	if x == nil {
+		return false
+	}
+	// The loop variables may only be used to compare the element.
+	uses := 0
+	for _, obj := range []types.Object{l.index, l.value} {
+		uses += c.uses(l.body, obj)
+	}
+	body := ifStmt.Body.List
+
+	kind, fn, start, end, result := "", "", list[i], list[i], ""
+	switch {
+	case len(body) == 1 && i+1 < len(list):
+		ret, ok := body[0].(*ast.ReturnStmt)
+		after, ok2 := list[i+1].(*ast.ReturnStmt)
+		if !ok || !ok2 || len(ret.Results) != 1 || len(after.Results) != 1 {
+			return false
+		}
+		switch {
+		case isIdent(ret.Results[0], "true") && isIdent(after.Results[0], "false") && uses == 1:
+			kind, fn = Contains, "Contains"
+		case l.index != nil && c.is(ret.Results[0], l.index) && isNegOne(after.Results[0]) && uses == 2:
+			kind, fn = Index, "Index"
+		default:
+			return false
+		}
+		end, result = after, "return "
+	case len(body) == 2 && i > 0:
+		set, ok := body[0].(*ast.AssignStmt)
+		brk, ok2 := body[1].(*ast.BranchStmt)
+		init, ok3 := list[i-1].(*ast.AssignStmt)
+		if !ok || !ok2 || !ok3 || brk.Tok != token.BREAK || brk.Label != nil || set.Tok != token.ASSIGN ||
+			len(set.Lhs) != 1 || len(init.Lhs) != 1 || len(init.Rhs) != 1 || !same(set.Lhs[0], init.Lhs[0]) || !pure(set.Lhs[0]) {
+			return false
+		}
+		switch {
+		case isIdent(set.Rhs[0], "true") && isIdent(init.Rhs[0], "false") && uses == 1:
+			kind, fn = Contains, "Contains"
+		case l.index != nil && c.is(set.Rhs[0], l.index) && isNegOne(init.Rhs[0]) && uses == 2:
+			kind, fn = Index, "Index"
+		default:
+			return false
+		}
+		// found := -1 would declare an int like slices.Index returns.
+		if t := c.typeOf(init.Lhs[0]); kind == Index && !types.Identical(t, types.Typ[types.Int]) {
+			return false
+		}
+		start, result = init, c.src(init.Lhs[0])+" "+init.Tok.String()+" "
+	default:
+		return false
+	}
+	if c.mentions(x, l.index) || c.mentions(x, l.value) {
+		return false
+	}
+
+	pkg, ok := c.packageName("slices", start.Pos())
+	if !ok {
+		return false
+	}
+	replacement := pkg + "." + fn
+	c.report(kind, l.stmt.Pos(), l.stmt.End(), "slices."+fn, analysis.TextEdit{
+		Pos:     start.Pos(),
+		End:     end.End(),
+		NewText: fmt.Appendf(nil, "%s%s(%s, %s)", result, replacement, c.src(l.s), c.src(x)),
+	})
+	return true
+}
+
+// searched returns x when cond compares the loop's element to x, which
+// must be a value the slice can hold.
+func (c *converter) searched(l *loop, cond ast.Expr) ast.Expr {
+	bin, ok := cond.(*ast.BinaryExpr)
+	if !ok || bin.Op != token.EQL {
+		return nil
+	}
+	x := bin.Y
+	switch {
+	case c.element(l, bin.X):
+	case c.element(l, bin.Y):
+		x = bin.X
+	default:
+		return nil
+	}
+	if !pure(x) {
+		return nil
+	}
+	tv, ok := c.pass.TypesInfo.Types[x]
+	if !ok || (tv.Value == nil && !types.AssignableTo(tv.Type, l.elem.Elem())) {
+		return nil
+	}
+	return x
+}
+
+// checkFilter converts filtering a local slice in place:
+//
+//	out := s[:0]
+//	for _, v := range s { if keep(v) { out = append(out, v) } }
+func (c *converter) checkFilter(list []ast.Stmt, i int) bool {
+	if i+1 >= len(list) {
+		return false
+	}
+	init, ok := list[i].(*ast.AssignStmt)
+	if !ok || len(init.Lhs) != 1 || len(init.Rhs) != 1 || !pure(init.Lhs[0]) {
+		return false
+	}
+	head, ok := init.Rhs[0].(*ast.SliceExpr)
+	if !ok || head.Slice3 || (head.Low != nil && !isIntLit(head.Low, "0")) || head.High == nil || !isIntLit(head.High, "0") {
+		return false
+	}
+	l := c.sliceLoop(list[i+1])
+	if l == nil || l.value == nil || l.index != nil || !same(l.s, head.X) || len(l.body.List) != 1 {
+		return false
+	}
+	ifStmt, ok := l.body.List[0].(*ast.IfStmt)
+	if !ok || ifStmt.Init != nil || ifStmt.Else != nil || len(ifStmt.Body.List) != 1 {
+		return false
+	}
+	add, ok := ifStmt.Body.List[0].(*ast.AssignStmt)
+	if !ok || add.Tok != token.ASSIGN || len(add.Lhs) != 1 || len(add.Rhs) != 1 || !same(add.Lhs[0], init.Lhs[0]) {
+		return false
+	}
+	call, ok := add.Rhs[0].(*ast.CallExpr)
+	if !ok || !c.builtin(call.Fun, "append") || len(call.Args) != 2 || call.Ellipsis.IsValid() || !same(call.Args[0], init.Lhs[0]) || !c.is(call.Args[1], l.value) {
+		return false
+	}
+	if c.mentionsName(ifStmt.Cond, root(init.Lhs[0])) || c.mentionsName(ifStmt.Cond, root(l.s)) {
+		return false
+	}
+
+	// slices.DeleteFunc zeroes the elements of s past the kept ones, which
+	// only a local s that isn't used after the loop can't see.
+	s, ok := l.s.(*ast.Ident)
+	if !ok {
+		return false
+	}
+	obj, ok := c.pass.TypesInfo.Uses[s].(*types.Var)
+	body := c.body(l.stmt.Pos())
+	if !ok || body == nil || obj.Pos() < body.Pos() || obj.Pos() > body.End() {
+		return false
+	}
+	usedAfter := false
+	ast.Inspect(body, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && id.Pos() > l.stmt.End() && c.pass.TypesInfo.Uses[id] == obj {
+			usedAfter = true
+		}
+		return !usedAfter
+	})
+	if usedAfter {
+		return false
+	}
+
+	elem, ok := c.typeString(l.elem.Elem())
+	pkg, ok2 := c.packageName("slices", init.Pos())
+	if !ok || !ok2 {
+		return false
+	}
+	v := c.src(l.stmt.(*ast.RangeStmt).Value)
+	c.report(Filter, l.stmt.Pos(), l.stmt.End(), "slices.DeleteFunc", analysis.TextEdit{
+		Pos: init.Pos(),
+		End: l.stmt.End(),
+		NewText: fmt.Appendf(nil, "%s %s %s.DeleteFunc(%s, func(%s %s) bool { return %s })",
+			c.src(init.Lhs[0]), init.Tok, pkg, c.src(l.s), v, elem, c.negate(ifStmt.Cond)),
+	})
+	return true
+}
+
+// checkKeys converts collecting a map's keys or values into a nil slice,
+// sorted if the statement after the loop sorts them:
+//
+//	var keys []K
+//	for k := range m { keys = append(keys, k) }
+//	sort.Strings(keys)
+func (c *converter) checkKeys(list []ast.Stmt, i int) bool {
+	if !c.iter || i+1 >= len(list) {
+		return false
+	}
+	decl, ok := list[i].(*ast.DeclStmt)
+	if !ok {
+		return false
+	}
+	gen, ok := decl.Decl.(*ast.GenDecl)
+	if !ok || gen.Tok != token.VAR || len(gen.Specs) != 1 {
+		return false
+	}
+	spec := gen.Specs[0].(*ast.ValueSpec)
+	if len(spec.Names) != 1 || len(spec.Values) != 0 || spec.Type == nil {
+		return false
+	}
+	rng, ok := list[i+1].(*ast.RangeStmt)
+	if !ok || rng.Tok != token.DEFINE || len(rng.Body.List) != 1 || !pure(rng.X) {
+		return false
+	}
+	m, ok := c.typeOf(rng.X).Underlying().(*types.Map)
+	if !ok {
+		return false
+	}
+	fn, v, elem := "Keys", c.defined(rng.Key), m.Key()
+	if v == nil {
+		fn, v, elem = "Values", c.defined(rng.Value), m.Elem()
+	} else if rng.Value != nil && !isIdent(rng.Value, "_") {
+		return false
+	}
+	if v == nil {
+		return false
+	}
+	add, ok := rng.Body.List[0].(*ast.AssignStmt)
+	if !ok || add.Tok != token.ASSIGN || len(add.Lhs) != 1 || len(add.Rhs) != 1 || !isIdent(add.Lhs[0], spec.Names[0].Name) {
+		return false
+	}
+	call, ok := add.Rhs[0].(*ast.CallExpr)
+	if !ok || !c.builtin(call.Fun, "append") || len(call.Args) != 2 || call.Ellipsis.IsValid() || !isIdent(call.Args[0], spec.Names[0].Name) || !c.is(call.Args[1], v) {
+		return false
+	}
+	// keys := would declare the slice with the type slices.Collect returns.
+	slice, ok := c.typeOf(spec.Names[0]).(*types.Slice)
+	if !ok || !types.Identical(slice.Elem(), elem) {
+		return false
+	}
+
+	collect, end := "Collect", list[i+1]
+	if i+2 < len(list) && c.sorts(list[i+2], spec.Names[0].Name, elem) {
+		collect, end = "Sorted", list[i+2]
+	}
+	slicesName, ok := c.packageName("slices", decl.Pos())
+	mapsName, ok2 := c.packageName("maps", decl.Pos())
+	if !ok || !ok2 {
+		return false
+	}
+	c.report(Keys, rng.Pos(), rng.End(), "slices."+collect+"(maps."+fn+")", analysis.TextEdit{
+		Pos:     decl.Pos(),
+		End:     end.End(),
+		NewText: fmt.Appendf(nil, "%s := %s.%s(%s.%s(%s))", spec.Names[0].Name, slicesName, collect, mapsName, fn, c.src(rng.X)),
+	})
+	return true
+}
+
+// sorts reports whether stmt sorts the slice name of ordered elements in
+// their natural order.
+func (c *converter) sorts(stmt ast.Stmt, name string, elem types.Type) bool {
+	expr, ok := stmt.(*ast.ExprStmt)
+	if !ok {
+		return false
+	}
+	call, ok := expr.X.(*ast.CallExpr)
+	if !ok || len(call.Args) != 1 || !isIdent(call.Args[0], name) || !ordered(elem) {
+		return false
+	}
+	pkg, fn := c.packageFunc(call)
+	switch pkg {
+	case "sort":
+		return fn == "Strings" || fn == "Ints"
+	case "slices":
+		return fn == "Sort"
+	}
+	return false
+}
+
+// checkCopy converts copying a map's entries into another:
+//
+//	for k, v := range src { dst[k] = v }
+func (c *converter) checkCopy(stmt ast.Stmt) bool {
+	rng, ok := stmt.(*ast.RangeStmt)
+	if !ok || rng.Tok != token.DEFINE || len(rng.Body.List) != 1 || !pure(rng.X) {
+		return false
+	}
+	k, v := c.defined(rng.Key), c.defined(rng.Value)
+	set, ok := rng.Body.List[0].(*ast.AssignStmt)
+	if k == nil || v == nil || !ok || set.Tok != token.ASSIGN || len(set.Lhs) != 1 || len(set.Rhs) != 1 || !c.is(set.Rhs[0], v) {
+		return false
+	}
+	ix, ok := set.Lhs[0].(*ast.IndexExpr)
+	if !ok || !c.is(ix.Index, k) || !pure(ix.X) || c.mentions(ix.X, k) || c.mentions(ix.X, v) {
+		return false
+	}
+	src, ok := c.typeOf(rng.X).Underlying().(*types.Map)
+	dst, ok2 := c.typeOf(ix.X).Underlying().(*types.Map)
+	if !ok || !ok2 || !types.Identical(src.Key(), dst.Key()) || !types.Identical(src.Elem(), dst.Elem()) {
+		return false
+	}
+	pkg, ok := c.packageName("maps", rng.Pos())
+	if !ok {
+		return false
+	}
+	c.report(Copy, rng.Pos(), rng.End(), "maps.Copy", analysis.TextEdit{
+		Pos:     rng.Pos(),
+		End:     rng.End(),
+		NewText: fmt.Appendf(nil, "%s.Copy(%s, %s)", pkg, c.src(ix.X), c.src(rng.X)),
+	})
+	return true
+}
+
+// checkSort converts sort.Slice and sort.SliceStable calls comparing the
+// elements, or a field of them, with < or >:
+//
+//	sort.Slice(s, func(i, j int) bool { return s[i].Name < s[j].Name })
+func (c *converter) checkSort(call *ast.CallExpr) {
+	pkg, fn := c.packageFunc(call)
+	if pkg != "sort" || (fn != "Slice" && fn != "SliceStable") || len(call.Args) != 2 || !pure(call.Args[0]) {
+		return
+	}
+	s := call.Args[0]
+	slice, ok := c.typeOf(s).Underlying().(*types.Slice)
+	if !ok {
+		return
+	}
+	less, ok := call.Args[1].(*ast.FuncLit)
+	if !ok || len(less.Type.Params.List) != 1 || len(less.Type.Params.List[0].Names) != 2 || len(less.Body.List) != 1 {
+		return
+	}
+	ret, ok := less.Body.List[0].(*ast.ReturnStmt)
+	if !ok || len(ret.Results) != 1 {
+		return
+	}
+	cmp, ok := ret.Results[0].(*ast.BinaryExpr)
+	if !ok || (cmp.Op != token.LSS && cmp.Op != token.GTR) {
+		return
+	}
+	i, j := c.defined(less.Type.Params.List[0].Names[0]), c.defined(less.Type.Params.List[0].Names[1])
+	left, li := c.keyPath(cmp.X, s, i, j)
+	right, ri := c.keyPath(cmp.Y, s, i, j)
+	if li == nil || ri == nil || li == ri || !slices.Equal(left, right) {
+		return
+	}
+	// The closure compares s[i] with s[j] ascending, or the other way around.
+	ascending := (li == i) == (cmp.Op == token.LSS)
+	key := c.typeOf(cmp.X)
+	if key == nil {
+		return
+	}
+
+	slicesName, ok := c.packageName("slices", call.Pos())
+	if !ok {
+		return
+	}
+	// Stable sorting of integers or strings by themselves is sorting them.
+	if len(left) == 0 && ascending && ordered(key) && !isFloat(key) {
+		c.report(Sort, call.Pos(), call.End(), "slices.Sort", analysis.TextEdit{
+			Pos: call.Pos(), End: call.End(), NewText: fmt.Appendf(nil, "%s.Sort(%s)", slicesName, c.src(s)),
+		})
+		return
+	}
+	if !ordered(key) {
+		return
+	}
+	elem, ok := c.typeString(slice.Elem())
+	cmpName, ok2 := c.packageName("cmp", call.Pos())
+	if !ok || !ok2 || elem == "a" || elem == "b" || strings.HasPrefix(elem, "a.") || strings.HasPrefix(elem, "b.") {
+		return
+	}
+	a, b := "a", "b"
+	if !ascending {
+		a, b = b, a
+	}
+	path := ""
+	for _, f := range left {
+		path += "." + f
+	}
+	sortFunc := "SortFunc"
+	if fn == "SliceStable" {
+		sortFunc = "SortStableFunc"
+	}
+	c.report(Sort, call.Pos(), call.End(), "slices."+sortFunc, analysis.TextEdit{
+		Pos: call.Pos(),
+		End: call.End(),
+		NewText: fmt.Appendf(nil, "%s.%s(%s, func(a, b %s) int { return %s.Compare(%s%s, %s%s) })",
+			slicesName, sortFunc, c.src(s), elem, cmpName, a, path, b, path),
+	})
+}
+
+// keyPath returns the fields e selects from s[i] or s[j], and which of i
+// and j it indexes s with.
+func (c *converter) keyPath(e ast.Expr, s ast.Expr, i, j types.Object) ([]string, types.Object) {
+	var path []string
+	for {
+		switch x := e.(type) {
+		case *ast.SelectorExpr:
+			if sel, ok := c.pass.TypesInfo.Selections[x]; !ok || sel.Kind() != types.FieldVal {
+				return nil, nil
+			}
+			path = append([]string{x.Sel.Name}, path...)
+			e = x.X
+		case *ast.IndexExpr:
+			if !same(x.X, s) {
+				return nil, nil
+			}
+			switch {
+			case c.is(x.Index, i):
+				return path, i
+			case c.is(x.Index, j):
+				return path, j
+			}
+			return nil, nil
+		default:
+			return nil, nil
+		}
+	}
+}
+
+// negate returns the source of the negation of cond.
+func (c *converter) negate(cond ast.Expr) string {
+	switch e := cond.(type) {
+	case *ast.UnaryExpr:
+		if e.Op == token.NOT {
+			return c.src(e.X)
+		}
+	case *ast.BinaryExpr:
+		// Only equality inverts exactly: !(a < b) isn't a >= b for NaNs.
+		switch e.Op {
+		case token.EQL:
+			return c.src(e.X) + " != " + c.src(e.Y)
+		case token.NEQ:
+			return c.src(e.X) + " == " + c.src(e.Y)
+		}
+	case *ast.Ident, *ast.CallExpr, *ast.SelectorExpr, *ast.IndexExpr, *ast.ParenExpr:
+		return "!" + c.src(cond)
+	}
+	return "!(" + c.src(cond) + ")"
+}
+
+// defined returns the variable e declares, if it is an identifier other
+// than _.
+func (c *converter) defined(e ast.Expr) types.Object {
+	id, ok := e.(*ast.Ident)
+	if !ok || id.Name == "_" {
+		return nil
+	}
+	return c.pass.TypesInfo.ObjectOf(id)
+}
+
+// is reports whether e is an identifier referring to obj.
+func (c *converter) is(e ast.Expr, obj types.Object) bool {
+	id, ok := e.(*ast.Ident)
+	return ok && obj != nil && c.pass.TypesInfo.ObjectOf(id) == obj
+}
+
+// uses counts the references to obj in node.
+func (c *converter) uses(node ast.Node, obj types.Object) int {
+	if obj == nil {
+		return 0
+	}
+	n := 0
+	ast.Inspect(node, func(node ast.Node) bool {
+		if id, ok := node.(*ast.Ident); ok && c.pass.TypesInfo.Uses[id] == obj {
+			n++
+		}
+		return true
+	})
+	return n
+}
+
+// mentions reports whether node refers to obj.
+func (c *converter) mentions(node ast.Node, obj types.Object) bool {
+	return c.uses(node, obj) > 0
+}
+
+// mentionsName reports whether node refers to the variable id names.
+func (c *converter) mentionsName(node ast.Node, id *ast.Ident) bool {
+	return id != nil && c.mentions(node, c.pass.TypesInfo.ObjectOf(id))
+}
+
+// builtin reports whether e names the predeclared function name.
+func (c *converter) builtin(e ast.Expr, name string) bool {
+	id, ok := e.(*ast.Ident)
+	if !ok || id.Name != name {
+		return false
+	}
+	_, ok = c.pass.TypesInfo.Uses[id].(*types.Builtin)
+	return ok
+}
+
+// packageFunc returns the import path and name of the package-level
+// function call calls.
+func (c *converter) packageFunc(call *ast.CallExpr) (string, string) {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return "", ""
+	}
+	x, ok := sel.X.(*ast.Ident)
+	if !ok {
+		return "", ""
+	}
+	if pn, ok := c.pass.TypesInfo.Uses[x].(*types.PkgName); ok {
+		return pn.Imported().Path(), sel.Sel.Name
+	}
+	return "", ""
+}
+
+// packageName returns the name to refer to the standard package path by at
+// pos: the name the file imports it by, or its own name when that is free
+// for the import the fix leaves to add.
+func (c *converter) packageName(path string, pos token.Pos) (string, bool) {
+	for _, spec := range c.file.Imports {
+		if strings.Trim(spec.Path.Value, `"`) != path {
+			continue
+		}
+		if spec.Name == nil {
+			return path, true
+		}
+		if spec.Name.Name != "_" && spec.Name.Name != "." {
+			return spec.Name.Name, true
+		}
+	}
+	if scope := c.pass.Pkg.Scope().Innermost(pos); scope != nil {
+		if _, obj := scope.LookupParent(path, pos); obj != nil {
+			return "", false
+		}
+	}
+	return path, true
+}
+
+// typeString returns t as the file refers to it, if it can: its packages
+// have to be imported by the file.
+func (c *converter) typeString(t types.Type) (string, bool) {
+	ok := true
+	s := types.TypeString(t, func(p *types.Package) string {
+		if p == c.pass.Pkg {
+			return ""
+		}
+		for _, spec := range c.file.Imports {
+			if strings.Trim(spec.Path.Value, `"`) != p.Path() {
+				continue
+			}
+			if spec.Name == nil {
+				return p.Name()
+			}
+			if spec.Name.Name != "_" && spec.Name.Name != "." {
+				return spec.Name.Name
+			}
+		}
+		ok = false
+		return p.Name()
+	})
+	return s, ok
+}
+
+// body returns the innermost function body around pos.
+func (c *converter) body(pos token.Pos) *ast.BlockStmt {
+	var inner *ast.BlockStmt
+	for _, b := range c.bodies {
+		if b.Pos() <= pos && pos < b.End() && (inner == nil || b.Pos() > inner.Pos()) {
+			inner = b
+		}
+	}
+	return inner
+}
+
+func (c *converter) typeOf(e ast.Expr) types.Type {
+	if t := c.pass.TypesInfo.TypeOf(e); t != nil {
+		return t
+	}
+	return types.Typ[types.Invalid]
+}
+
+// commented reports whether a comment lies between start and end, which
+// rewriting the range would lose.
+func (c *converter) commented(start, end token.Pos) bool {
+	return slices.ContainsFunc(c.file.Comments, func(g *ast.CommentGroup) bool {
+		return g.Pos() < end && g.End() > start
+	})
+}
+
+// src returns the source of e.
+func (c *converter) src(e ast.Expr) string {
+	tf := c.pass.Fset.File(e.Pos())
+	if tf != nil {
+		start, end := tf.Offset(e.Pos()), tf.Offset(e.End())
+		if start >= 0 && end <= len(c.content) && start <= end {
+			return string(c.content[start:end])
+		}
+	}
+	return types.ExprString(e)
+}
+
+// same reports whether a and b are the same expression, ignoring layout.
+func same(a, b ast.Expr) bool {
+	return a != nil && b != nil && types.ExprString(a) == types.ExprString(b)
+}
+
+// pure reports whether evaluating e has no effects and, repeated, gives
+// the same value: identifiers, literals and selections of them.
+func pure(e ast.Expr) bool {
+	switch e := e.(type) {
+	case *ast.Ident, *ast.BasicLit:
+		return true
+	case *ast.SelectorExpr:
+		return pure(e.X)
+	case *ast.ParenExpr:
+		return pure(e.X)
+	case *ast.UnaryExpr:
+		return e.Op == token.SUB && pure(e.X)
+	}
+	return false
+}
+
+// root returns the variable e selects or indexes into, if any.
+func root(e ast.Expr) *ast.Ident {
+	for {
+		switch x := e.(type) {
+		case *ast.Ident:
+			return x
+		case *ast.SelectorExpr:
+			e = x.X
+		case *ast.IndexExpr:
+			e = x.X
+		case *ast.ParenExpr:
+			e = x.X
+		default:
+			return nil
+		}
+	}
+}
+
+// ordered reports whether values of t compare with < as cmp.Ordered ones.
+func ordered(t types.Type) bool {
+	basic, ok := t.Underlying().(*types.Basic)
+	return ok && basic.Info()&types.IsOrdered != 0
+}
+
+func isFloat(t types.Type) bool {
+	basic, ok := t.Underlying().(*types.Basic)
+	return ok && basic.Info()&types.IsFloat != 0
+}
+
+func isIdent(e ast.Expr, name string) bool {
+	id, ok := e.(*ast.Ident)
+	return ok && id.Name == name
+}
+
+func isIntLit(e ast.Expr, value string) bool {
+	lit, ok := e.(*ast.BasicLit)
+	return ok && lit.Kind == token.INT && lit.Value == value
+}
+
+func isNegOne(e ast.Expr) bool {
+	u, ok := e.(*ast.UnaryExpr)
+	return ok && u.Op == token.SUB && isIntLit(u.X, "1")
+}
@@ -0,0 +1,259 @@
+package loopconv_test
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/analyzers"
+	"github.com/mamaar/gorefactor/pkg/analyzers/loopconv"
+	wstypes "github.com/mamaar/gorefactor/pkg/types"
+)
+
+func createTestWorkspace(t *testing.T, src string) *wstypes.Workspace {
+	t.Helper()
+	fileSet := token.NewFileSet()
+
+	astFile, err := parser.ParseFile(fileSet, "testpkg.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("Failed to parse test source: %v", err)
+	}
+
+	file := &wstypes.File{
+		Path:            "testpkg.go",
+		AST:             astFile,
+		OriginalContent: []byte(src),
+	}
+
+	info := &types.Info{
+		Types:      map[ast.Expr]types.TypeAndValue{},
+		Defs:       map[*ast.Ident]types.Object{},
+		Uses:       map[*ast.Ident]types.Object{},
+		Selections: map[*ast.SelectorExpr]*types.Selection{},
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fileSet, "source", nil)}
+	typesPkg, err := conf.Check("test/testpkg", fileSet, []*ast.File{astFile}, info)
+	if err != nil {
+		t.Fatalf("Failed to type-check test source: %v", err)
+	}
+	pkg := &wstypes.Package{
+		Name:      "testpkg",
+		Path:      "test/testpkg",
+		Files:     map[string]*wstypes.File{"testpkg.go": file},
+		TypesPkg:  typesPkg,
+		TypesInfo: info,
+	}
+	file.Package = pkg
+
+	return &wstypes.Workspace{
+		Packages: map[string]*wstypes.Package{"test/testpkg": pkg},
+		FileSet:  fileSet,
+	}
+}
+
+// fix runs the analyzer and returns the kinds it reported and the source
+// with its fixes applied.
+func fix(t *testing.T, src string, opts ...loopconv.Option) ([]string, string) {
+	t.Helper()
+	ws := createTestWorkspace(t, src)
+	rr, err := analyzers.Run(ws, loopconv.NewAnalyzer(opts...), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, _ := rr.Result.([]*loopconv.Result)
+	var kinds []string
+	for _, r := range results {
+		kinds = append(kinds, r.Kind)
+	}
+
+	changes := analyzers.DiagnosticsToChanges(ws.FileSet, rr.Diagnostics)
+	slices.SortFunc(changes, func(a, b wstypes.Change) int { return b.Start - a.Start })
+	out := src
+	for _, c := range changes {
+		out = out[:c.Start] + c.NewText + out[c.End:]
+	}
+	return kinds, out
+}
+
+const src = `package testpkg
+
+import "sort"
+
+type User struct {
+	Name string
+	Age  int
+}
+
+func Has(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func Find(ids []int, id int) int {
+	for i := 0; i < len(ids); i++ {
+		if ids[i] == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func Seen(ids []int) bool {
+	found := false
+	for i := range ids {
+		if 7 == ids[i] {
+			found = true
+			break
+		}
+	}
+	return found
+}
+
+func Adults(load func() []User) []User {
+	users := load()
+	kept := users[:0]
+	for _, u := range users {
+		if u.Age >= 18 {
+			kept = append(kept, u)
+		}
+	}
+	return kept
+}
+
+func Names(m map[string]int) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func Merge(dst, src map[string]int) {
+	for k, v := range src {
+		dst[k] = v
+	}
+}
+
+func ByAge(users []User, ids []int) {
+	sort.Slice(users, func(i, j int) bool { return users[i].Age > users[j].Age })
+	sort.SliceStable(ids, func(a, b int) bool { return ids[a] < ids[b] })
+}
+`
+
+func TestLoopConv(t *testing.T) {
+	kinds, out := fix(t, src, loopconv.WithGoVersion("1.23"))
+
+	slices.Sort(kinds)
+	if want := []string{"contains", "contains", "copy", "filter", "index", "keys", "sort", "sort"}; !slices.Equal(kinds, want) {
+		t.Errorf("got kinds %v, want %v", kinds, want)
+	}
+	for _, want := range []string{
+		"return slices.Contains(names, name)\n}",
+		"return slices.Index(ids, id)\n}",
+		"found := slices.Contains(ids, 7)\n\treturn found",
+		"kept := slices.DeleteFunc(users, func(u User) bool { return !(u.Age >= 18) })\n\treturn kept",
+		"keys := slices.Sorted(maps.Keys(m))\n\treturn keys",
+		"maps.Copy(dst, src)\n}",
+		"slices.SortFunc(users, func(a, b User) int { return cmp.Compare(b.Age, a.Age) })",
+		"slices.Sort(ids)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("fixed source lacks %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestLoopConv_GoVersion(t *testing.T) {
+	kinds, _ := fix(t, src, loopconv.WithGoVersion("1.22"))
+	if slices.Contains(kinds, "keys") || len(kinds) != 7 {
+		t.Errorf("go 1.22: expected all but keys, got %v", kinds)
+	}
+	if kinds, _ = fix(t, src, loopconv.WithGoVersion("1.20")); len(kinds) != 0 {
+		t.Errorf("go 1.20: expected nothing without the slices package, got %v", kinds)
+	}
+	kinds, _ = fix(t, src, loopconv.WithKinds("copy"))
+	if !slices.Equal(kinds, []string{"copy"}) {
+		t.Errorf("got kinds %v, want only copy", kinds)
+	}
+}
+
+func TestLoopConv_Semantics(t *testing.T) {
+	kinds, out := fix(t, `package testpkg
+
+func Search(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			println(n)
+			return true
+		}
+	}
+	return false
+}
+
+func Outer(rows [][]int, x int) bool {
+	for _, r := range rows {
+		for i := range r {
+			if r[i] == x {
+				continue
+			}
+		}
+	}
+	for i, v := range rows[0] {
+		if v == i {
+			return true
+		}
+	}
+	return false
+}
+
+func Keep(ids []int) ([]int, int) {
+	kept := ids[:0]
+	for _, id := range ids {
+		if id > 0 {
+			kept = append(kept, id)
+		}
+	}
+	return kept, len(ids)
+}
+
+func Param(ids []int) []int {
+	kept := ids[:0]
+	for _, id := range ids {
+		if id > 0 {
+			kept = append(kept, id)
+		}
+	}
+	return kept
+}
+
+func Keys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func Found(ids []int, x any) bool {
+	for _, id := range ids {
+		if x == id {
+			return true
+		}
+	}
+	return false
+}
+`)
+	if len(kinds) != 0 {
+		t.Errorf("expected no conversions, got %v:\n%s", kinds, out)
+	}
+}
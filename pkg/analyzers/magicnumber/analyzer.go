@@ -0,0 +1,243 @@
+package magicnumber
+
+import (
+	"go/ast"
+	"go/token"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/edge"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+// DefaultMinOccurrences is how often a literal has to appear in a package
+// before it is reported outside conditionals.
+const DefaultMinOccurrences = 3
+
+// Result is the typed result returned for MCP consumption.
+type Result struct {
+	File          string `json:"file"`
+	Line          int    `json:"line"`
+	Column        int    `json:"column"`
+	Function      string `json:"function,omitempty"`
+	Value         string `json:"value"`
+	Kind          string `json:"kind"`
+	Occurrences   int    `json:"occurrences"`
+	InConditional bool   `json:"in_conditional"`
+	SuggestedName string `json:"suggested_name"`
+
+	Pos token.Pos `json:"-"` // Start of the literal, for fixers
+}
+
+type config struct {
+	minOccurrences int
+}
+
+// Option configures the analyzer.
+type Option func(*config)
+
+// WithMinOccurrences sets how often a literal has to be repeated in a
+// package to be reported when it isn't compared in a conditional.
+func WithMinOccurrences(n int) Option {
+	return func(c *config) { c.minOccurrences = n }
+}
+
+const doc = "detects numeric and string literals repeated across a package or compared in conditionals, which named constants would explain"
+
+var Analyzer = NewAnalyzer()
+
+// NewAnalyzer creates a configured analyzer.
+func NewAnalyzer(opts ...Option) *analysis.Analyzer {
+	cfg := config{minOccurrences: DefaultMinOccurrences}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return &analysis.Analyzer{
+		Name:     "magicnumber",
+		Doc:      doc,
+		Run:      makeRun(cfg),
+		Requires: []*analysis.Analyzer{inspect.Analyzer},
+	}
+}
+
+// ignored are the literals too common to deserve a name.
+var ignored = []string{"0", "1", `""`, "``"}
+
+// occurrence is a literal that counts towards its value's repetitions.
+type occurrence struct {
+	lit         *ast.BasicLit
+	function    string
+	conditional bool
+}
+
+func makeRun(cfg config) func(*analysis.Pass) (any, error) {
+	return func(pass *analysis.Pass) (any, error) {
+		insp := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+
+		var found []occurrence
+		counts := make(map[string]int)
+		for cur := range insp.Root().Preorder((*ast.BasicLit)(nil)) {
+			lit := cur.Node().(*ast.BasicLit)
+			if !candidate(lit) || named(cur) {
+				continue
+			}
+			found = append(found, occurrence{lit: lit, function: enclosingFunction(cur), conditional: conditional(cur)})
+			counts[lit.Value]++
+		}
+
+		var results []*Result
+		for _, o := range found {
+			n := counts[o.lit.Value]
+			if n < cfg.minOccurrences && !o.conditional {
+				continue
+			}
+			pos := pass.Fset.Position(o.lit.Pos())
+			name := SuggestName(o.lit)
+			msg := "magic " + kind(o.lit) + " " + o.lit.Value
+			if o.conditional {
+				msg += " compared in a conditional"
+			} else {
+				msg += " repeated " + strconv.Itoa(n) + " times in the package"
+			}
+			pass.Report(analysis.Diagnostic{
+				Pos:     o.lit.Pos(),
+				End:     o.lit.End(),
+				Message: msg + "; consider a named constant such as " + name,
+			})
+			results = append(results, &Result{
+				File:          pos.Filename,
+				Line:          pos.Line,
+				Column:        pos.Column,
+				Function:      o.function,
+				Value:         o.lit.Value,
+				Kind:          kind(o.lit),
+				Occurrences:   n,
+				InConditional: o.conditional,
+				SuggestedName: name,
+				Pos:           o.lit.Pos(),
+			})
+		}
+		return results, nil
+	}
+}
+
+// candidate reports whether lit is a numeric or string literal worth
+// naming at all.
+func candidate(lit *ast.BasicLit) bool {
+	switch lit.Kind {
+	case token.INT, token.FLOAT, token.STRING:
+	default:
+		return false
+	}
+	for _, v := range ignored {
+		if lit.Value == v {
+			return false
+		}
+	}
+	return true
+}
+
+// named reports whether the literal at cur already has a name or can't be
+// replaced by one: it initializes a constant or package-level variable, or
+// is an import path, struct tag or array length.
+func named(cur inspector.Cursor) bool {
+	switch cur.ParentEdgeKind() {
+	case edge.ImportSpec_Path, edge.Field_Tag, edge.ArrayType_Len:
+		return true
+	case edge.ValueSpec_Values:
+		decl, ok := cur.Parent().Parent().Node().(*ast.GenDecl)
+		if !ok {
+			return false
+		}
+		if decl.Tok == token.CONST {
+			return true
+		}
+		_, global := cur.Parent().Parent().Parent().Node().(*ast.File)
+		return global
+	}
+	for anc := range cur.Enclosing((*ast.GenDecl)(nil)) {
+		return anc.Node().(*ast.GenDecl).Tok == token.CONST
+	}
+	return false
+}
+
+// conditional reports whether the literal at cur is compared in the
+// condition of an if or for statement, or is a switch case.
+func conditional(cur inspector.Cursor) bool {
+	for cur.ParentEdgeKind() == edge.ParenExpr_X {
+		cur = cur.Parent()
+	}
+	if cur.ParentEdgeKind() == edge.CaseClause_List {
+		return true
+	}
+	if k := cur.ParentEdgeKind(); k != edge.BinaryExpr_X && k != edge.BinaryExpr_Y {
+		return false
+	}
+	switch cur.Parent().Node().(*ast.BinaryExpr).Op {
+	case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ:
+	default:
+		return false
+	}
+	for cur = cur.Parent(); ; cur = cur.Parent() {
+		switch cur.ParentEdgeKind() {
+		case edge.IfStmt_Cond, edge.ForStmt_Cond:
+			return true
+		case edge.BinaryExpr_X, edge.BinaryExpr_Y, edge.ParenExpr_X, edge.UnaryExpr_X:
+		default:
+			return false
+		}
+	}
+}
+
+func enclosingFunction(cur inspector.Cursor) string {
+	for anc := range cur.Enclosing((*ast.FuncDecl)(nil)) {
+		return anc.Node().(*ast.FuncDecl).Name.Name
+	}
+	return ""
+}
+
+func kind(lit *ast.BasicLit) string {
+	switch lit.Kind {
+	case token.INT:
+		return "int"
+	case token.FLOAT:
+		return "float"
+	}
+	return "string"
+}
+
+// SuggestName derives an unexported constant name from a literal: the
+// words of a string, or "value" and the digits of a number.
+func SuggestName(lit *ast.BasicLit) string {
+	if lit.Kind != token.STRING {
+		return "value" + strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				return r
+			}
+			return '_'
+		}, strings.TrimLeft(lit.Value, "+-"))
+	}
+
+	words := strings.FieldsFunc(strings.Trim(lit.Value, "\"`"), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) || r > unicode.MaxASCII
+	})
+	if len(words) > 4 {
+		words = words[:4]
+	}
+	var b strings.Builder
+	for i, w := range words {
+		w = strings.ToLower(w)
+		if i > 0 {
+			w = strings.ToUpper(w[:1]) + w[1:]
+		}
+		b.WriteString(w)
+	}
+	name := b.String()
+	if name == "" || unicode.IsDigit(rune(name[0])) || token.IsKeyword(name) {
+		name = "str" + strings.ToUpper(name[:min(len(name), 1)]) + name[min(len(name), 1):]
+	}
+	return name
+}
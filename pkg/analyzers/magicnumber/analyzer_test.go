@@ -0,0 +1,150 @@
+package magicnumber_test
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/analyzers"
+	"github.com/mamaar/gorefactor/pkg/analyzers/magicnumber"
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+func createTestWorkspace(t *testing.T, src string) *types.Workspace {
+	t.Helper()
+	fileSet := token.NewFileSet()
+
+	astFile, err := parser.ParseFile(fileSet, "testpkg.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("Failed to parse test source: %v", err)
+	}
+
+	file := &types.File{
+		Path:            "testpkg.go",
+		AST:             astFile,
+		OriginalContent: []byte(src),
+	}
+
+	pkg := &types.Package{
+		Name:  "testpkg",
+		Path:  "test/testpkg",
+		Files: map[string]*types.File{"testpkg.go": file},
+	}
+	file.Package = pkg
+
+	return &types.Workspace{
+		Packages: map[string]*types.Package{"test/testpkg": pkg},
+		FileSet:  fileSet,
+	}
+}
+
+func run(t *testing.T, src string, opts ...magicnumber.Option) []*magicnumber.Result {
+	t.Helper()
+	ws := createTestWorkspace(t, src)
+	rr, err := analyzers.Run(ws, magicnumber.NewAnalyzer(opts...), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, ok := rr.Result.([]*magicnumber.Result)
+	if !ok && rr.Result != nil {
+		t.Fatalf("Expected []*magicnumber.Result, got %T", rr.Result)
+	}
+	if len(rr.Diagnostics) != len(results) {
+		t.Errorf("Expected one diagnostic per result, got %d for %d", len(rr.Diagnostics), len(results))
+	}
+	return results
+}
+
+const src = `package testpkg
+
+import "strings"
+
+const limit = 42
+
+var defaultName = "guest"
+
+type User struct {
+	Name string ` + "`json:\"name\"`" + `
+	Age  int
+}
+
+func Check(u User, ids [16]byte) bool {
+	if u.Age >= 18 {
+		return true
+	}
+	for i := 0; i < 42; i++ {
+	}
+	switch u.Name {
+	case "admin":
+		return true
+	}
+	return strings.HasPrefix(u.Name, "x") && len(u.Name) > 1
+}
+
+func Scale(a, b, c float64) float64 {
+	return a*2.5 + b*2.5 + c*2.5
+}
+`
+
+func TestMagicNumber(t *testing.T) {
+	results := run(t, src)
+
+	got := make(map[string]*magicnumber.Result)
+	for _, r := range results {
+		got[r.Value] = r
+	}
+	if len(results) != 6 {
+		t.Errorf("Expected 6 findings, got %d: %+v", len(results), results)
+	}
+
+	for value, conditional := range map[string]bool{"18": true, "42": true, `"admin"`: true, "2.5": false} {
+		r, ok := got[value]
+		if !ok {
+			t.Errorf("Expected %s to be reported", value)
+			continue
+		}
+		if r.InConditional != conditional {
+			t.Errorf("%s: InConditional = %v, want %v", value, r.InConditional, conditional)
+		}
+	}
+	if r := got["2.5"]; r != nil && (r.Occurrences != 3 || r.Kind != "float" || r.Function != "Scale") {
+		t.Errorf("Unexpected result for 2.5: %+v", r)
+	}
+	for _, value := range []string{`"strings"`, `"guest"`, "16", `"x"`, "1"} {
+		if _, ok := got[value]; ok {
+			t.Errorf("Did not expect %s to be reported", value)
+		}
+	}
+}
+
+func TestMagicNumber_MinOccurrences(t *testing.T) {
+	for _, r := range run(t, src, magicnumber.WithMinOccurrences(4)) {
+		if r.Value == "2.5" {
+			t.Errorf("Did not expect 2.5 to be reported with 4 required occurrences")
+		}
+	}
+}
+
+func TestSuggestName(t *testing.T) {
+	tests := map[string]string{
+		`"application/json"`: "applicationJson",
+		`"GET"`:              "get",
+		`"404 not found"`:    "str404NotFound",
+		`"func"`:             "strFunc",
+		"3600":               "value3600",
+		"0.5":                "value0_5",
+	}
+	for value, want := range tests {
+		kind := token.INT
+		switch value[0] {
+		case '"':
+			kind = token.STRING
+		case '0':
+			kind = token.FLOAT
+		}
+		if got := magicnumber.SuggestName(&ast.BasicLit{Kind: kind, Value: value}); got != want {
+			t.Errorf("SuggestName(%s) = %q, want %q", value, got, want)
+		}
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"go/token"
 
 	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
 
 	"github.com/mamaar/gorefactor/pkg/analyzers/filedata"
 )
@@ -24,71 +26,61 @@ var Analyzer = &analysis.Analyzer{
 	Name:     "missingctx",
 	Doc:      "detects functions that should accept ctx context.Context but create context internally",
 	Run:      run,
-	Requires: []*analysis.Analyzer{filedata.Analyzer},
+	Requires: []*analysis.Analyzer{filedata.Analyzer, inspect.Analyzer},
 }
 
 func run(pass *analysis.Pass) (any, error) {
 	fd := pass.ResultOf[filedata.Analyzer].(*filedata.Data)
+	insp := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
 	var results []*Result
 
-	for _, file := range pass.Files {
-		pos := pass.Fset.Position(file.Pos())
-		content := fd.Content[pos.Filename]
-		results = append(results, analyzeFile(pass, file, content)...)
+	for cur := range insp.Root().Preorder((*ast.FuncDecl)(nil)) {
+		funcDecl := cur.Node().(*ast.FuncDecl)
+		content := fd.Content[pass.Fset.Position(funcDecl.Pos()).Filename]
+		if r := analyzeFunc(pass, funcDecl, content); r != nil {
+			results = append(results, r)
+		}
 	}
 
 	return results, nil
 }
 
-func analyzeFile(pass *analysis.Pass, file *ast.File, content []byte) []*Result {
-	var results []*Result
-
-	ast.Inspect(file, func(n ast.Node) bool {
-		funcDecl, ok := n.(*ast.FuncDecl)
-		if !ok {
-			return true
-		}
-
-		// Skip main() and init()
-		if funcDecl.Name.Name == "main" || funcDecl.Name.Name == "init" {
-			return false
-		}
-
-		if hasContextParam(funcDecl) {
-			return false
-		}
-
-		if funcDecl.Body == nil {
-			return false
-		}
+func analyzeFunc(pass *analysis.Pass, funcDecl *ast.FuncDecl, content []byte) *Result {
+	// Skip main() and init()
+	if funcDecl.Name.Name == "main" || funcDecl.Name.Name == "init" {
+		return nil
+	}
 
-		calls := detectContextCreation(funcDecl.Body)
-		if len(calls) == 0 {
-			return false
-		}
+	if hasContextParam(funcDecl) {
+		return nil
+	}
 
-		pos := pass.Fset.Position(funcDecl.Pos())
-		sig := extractSignatureText(pass.Fset, content, funcDecl)
+	if funcDecl.Body == nil {
+		return nil
+	}
 
-		pass.Report(analysis.Diagnostic{
-			Pos:     funcDecl.Pos(),
-			End:     funcDecl.End(),
-			Message: "function creates context internally instead of accepting context.Context parameter",
-		})
+	calls := detectContextCreation(funcDecl.Body)
+	if len(calls) == 0 {
+		return nil
+	}
 
-		results = append(results, &Result{
-			File:         pos.Filename,
-			Line:         pos.Line,
-			Column:       pos.Column,
-			FunctionName: funcDecl.Name.Name,
-			Signature:    sig,
-			ContextCalls: calls,
-		})
+	pos := pass.Fset.Position(funcDecl.Pos())
+	sig := extractSignatureText(pass.Fset, content, funcDecl)
 
-		return false
+	pass.Report(analysis.Diagnostic{
+		Pos:     funcDecl.Pos(),
+		End:     funcDecl.End(),
+		Message: "function creates context internally instead of accepting context.Context parameter",
 	})
 
-	return results
+	return &Result{
+		File:         pos.Filename,
+		Line:         pos.Line,
+		Column:       pos.Column,
+		FunctionName: funcDecl.Name.Name,
+		Signature:    sig,
+		ContextCalls: calls,
+	}
 }
 
 func hasContextParam(funcDecl *ast.FuncDecl) bool {
@@ -0,0 +1,755 @@
+// Package modernize provides a go/analysis analyzer that suggests newer
+// language features and standard library functions in place of the idioms
+// they replaced, limited to those the module's Go version allows.
+package modernize
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"go/version"
+	"slices"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+
+	"github.com/mamaar/gorefactor/pkg/analyzers/filedata"
+)
+
+// Check is a modernization the analyzer suggests.
+type Check struct {
+	Name    string `json:"name"`
+	Version string `json:"version"` // Go version introducing the feature, such as "go1.18"
+	Doc     string `json:"doc"`
+}
+
+// Checks lists the modernizations, by the Go version they need.
+var Checks = []Check{
+	{Name: "replaceall", Version: "go1.12", Doc: "strings.Replace and bytes.Replace with n -1 → ReplaceAll"},
+	{Name: "any", Version: "go1.18", Doc: "interface{} → any"},
+	{Name: "cutprefix", Version: "go1.20", Doc: "HasPrefix then TrimPrefix, or HasSuffix then TrimSuffix, of strings or bytes → CutPrefix or CutSuffix"},
+	{Name: "sortslice", Version: "go1.21", Doc: "sort.Strings, sort.Ints and sort.Float64s → slices.Sort"},
+	{Name: "minmax", Version: "go1.21", Doc: "an if statement choosing the smaller or larger of two integers or strings → min or max"},
+	{Name: "rangeint", Version: "go1.22", Doc: "for i := 0; i < n; i++ → for i := range n"},
+}
+
+// Result is the typed result returned for MCP consumption.
+type Result struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Check   string `json:"check"`
+	Version string `json:"version"` // Go version the suggestion needs
+	Message string `json:"message"`
+}
+
+type config struct {
+	goVersion string
+	checks    []string
+}
+
+// Option configures the analyzer.
+type Option func(*config)
+
+// WithGoVersion sets the Go version of the module, as in the go directive
+// of its go.mod ("1.22" or "go1.22"). Checks needing a newer version are
+// skipped, except in files whose //go:build constraint requires one. Without
+// it, only such constraints limit the checks.
+func WithGoVersion(v string) Option {
+	return func(c *config) {
+		if v != "" && !strings.HasPrefix(v, "go") {
+			v = "go" + v
+		}
+		c.goVersion = v
+	}
+}
+
+// WithChecks limits the analyzer to the named checks.
+func WithChecks(names ...string) Option {
+	return func(c *config) { c.checks = names }
+}
+
+const doc = "suggests newer Go features and library functions the module's Go version allows"
+
+var Analyzer = NewAnalyzer()
+
+// NewAnalyzer creates a configured analyzer. The minmax and rangeint checks
+// need type information to tell that a rewrite keeps the types involved, so
+// they report nothing in packages that aren't type-checked.
+func NewAnalyzer(opts ...Option) *analysis.Analyzer {
+	var cfg config
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return &analysis.Analyzer{
+		Name:     "modernize",
+		Doc:      doc,
+		Run:      makeRun(cfg),
+		Requires: []*analysis.Analyzer{filedata.Analyzer},
+	}
+}
+
+// Available returns the checks a module at Go version goVersion allows, and
+// those it doesn't, among the named checks or all of them.
+func Available(goVersion string, names ...string) (allowed, unavailable []Check) {
+	var cfg config
+	WithGoVersion(goVersion)(&cfg)
+	for _, c := range Checks {
+		if len(names) > 0 && !slices.Contains(names, c.Name) {
+			continue
+		}
+		if cfg.goVersion == "" || version.Compare(cfg.goVersion, c.Version) >= 0 {
+			allowed = append(allowed, c)
+		} else {
+			unavailable = append(unavailable, c)
+		}
+	}
+	return allowed, unavailable
+}
+
+// IsCheck reports whether name is one of Checks.
+func IsCheck(name string) bool {
+	return slices.ContainsFunc(Checks, func(c Check) bool { return c.Name == name })
+}
+
+func makeRun(cfg config) func(*analysis.Pass) (any, error) {
+	return func(pass *analysis.Pass) (any, error) {
+		fd := pass.ResultOf[filedata.Analyzer].(*filedata.Data)
+		var results []*Result
+
+		for _, file := range pass.Files {
+			fileVersion := cfg.goVersion
+			if file.GoVersion != "" {
+				fileVersion = file.GoVersion
+			}
+			enabled := make(map[string]string)
+			for _, c := range Checks {
+				if len(cfg.checks) > 0 && !slices.Contains(cfg.checks, c.Name) {
+					continue
+				}
+				if fileVersion == "" || version.Compare(fileVersion, c.Version) >= 0 {
+					enabled[c.Name] = c.Version
+				}
+			}
+			if len(enabled) == 0 {
+				continue
+			}
+
+			m := &modernizer{
+				pass:    pass,
+				file:    file,
+				content: fd.Content[pass.Fset.File(file.Pos()).Name()],
+				typed:   pass.TypesInfo != nil && len(pass.TypesInfo.Types) > 0,
+			}
+			m.report = func(check string, pos, end token.Pos, message string, edits ...analysis.TextEdit) {
+				if _, ok := enabled[check]; !ok {
+					return
+				}
+				p := pass.Fset.Position(pos)
+				results = append(results, &Result{
+					File:    p.Filename,
+					Line:    p.Line,
+					Column:  p.Column,
+					Check:   check,
+					Version: enabled[check],
+					Message: message,
+				})
+				pass.Report(analysis.Diagnostic{
+					Pos:            pos,
+					End:            end,
+					Category:       check,
+					Message:        message,
+					SuggestedFixes: []analysis.SuggestedFix{{Message: message, TextEdits: edits}},
+				})
+			}
+			m.run()
+		}
+		return results, nil
+	}
+}
+
+// modernizer finds the modernizations of one file.
+type modernizer struct {
+	pass    *analysis.Pass
+	file    *ast.File
+	content []byte
+	typed   bool
+	report  func(check string, pos, end token.Pos, message string, edits ...analysis.TextEdit)
+}
+
+func (m *modernizer) run() {
+	ast.Inspect(m.file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.InterfaceType:
+			m.checkAny(n)
+		case *ast.CallExpr:
+			m.checkReplaceAll(n)
+			m.checkSortSlice(n)
+		case *ast.IfStmt:
+			m.checkCutPrefix(n)
+			m.checkMinMaxIfElse(n)
+		case *ast.ForStmt:
+			m.checkRangeInt(n)
+		case *ast.BlockStmt:
+			m.checkMinMaxAssign(n.List)
+		case *ast.CaseClause:
+			m.checkMinMaxAssign(n.Body)
+		case *ast.CommClause:
+			m.checkMinMaxAssign(n.Body)
+		}
+		return true
+	})
+}
+
+// checkAny suggests any for interface{}.
+func (m *modernizer) checkAny(it *ast.InterfaceType) {
+	if len(it.Methods.List) > 0 || strings.TrimSpace(m.text(it.Methods.Opening+1, it.Methods.Closing)) != "" {
+		return
+	}
+	if !m.predeclared("any", it.Pos()) {
+		return
+	}
+	m.report("any", it.Pos(), it.End(), "interface{} can be written any", analysis.TextEdit{Pos: it.Pos(), End: it.End(), NewText: []byte("any")})
+}
+
+// checkReplaceAll suggests ReplaceAll for Replace with n -1.
+func (m *modernizer) checkReplaceAll(call *ast.CallExpr) {
+	pkg, name, sel := m.packageFunc(call)
+	if (pkg != "strings" && pkg != "bytes") || name != "Replace" || len(call.Args) != 4 {
+		return
+	}
+	if u, ok := call.Args[3].(*ast.UnaryExpr); !ok || u.Op != token.SUB || !isIntLit(u.X, "1") {
+		return
+	}
+	m.report("replaceall", call.Pos(), call.End(), fmt.Sprintf("%s.Replace with n -1 can be %s.ReplaceAll", pkg, pkg),
+		analysis.TextEdit{Pos: sel.Sel.Pos(), End: sel.Sel.End(), NewText: []byte("ReplaceAll")},
+		analysis.TextEdit{Pos: call.Args[2].End(), End: call.Args[3].End()},
+	)
+}
+
+// checkSortSlice suggests slices.Sort for the sort functions of basic
+// slices. The import of slices is left to the caller applying the fix.
+func (m *modernizer) checkSortSlice(call *ast.CallExpr) {
+	pkg, name, sel := m.packageFunc(call)
+	if pkg != "sort" || (name != "Strings" && name != "Ints" && name != "Float64s") || len(call.Args) != 1 {
+		return
+	}
+	slicesName := m.importName("slices")
+	if slicesName == "" {
+		if !m.free("slices", call.Pos()) {
+			return
+		}
+		slicesName = "slices"
+	}
+	m.report("sortslice", call.Pos(), call.End(), fmt.Sprintf("sort.%s can be slices.Sort", name),
+		analysis.TextEdit{Pos: sel.Pos(), End: sel.End(), NewText: []byte(slicesName + ".Sort")})
+}
+
+// checkCutPrefix suggests CutPrefix or CutSuffix for an if statement
+// testing for a prefix or suffix and trimming it once in its body.
+func (m *modernizer) checkCutPrefix(stmt *ast.IfStmt) {
+	cond, ok := stmt.Cond.(*ast.CallExpr)
+	if !ok || stmt.Init != nil || len(cond.Args) != 2 {
+		return
+	}
+	pkg, name, sel := m.packageFunc(cond)
+	if pkg != "strings" && pkg != "bytes" {
+		return
+	}
+	affix, ok := strings.CutPrefix(name, "Has")
+	if !ok || (affix != "Prefix" && affix != "Suffix") {
+		return
+	}
+	s, p := cond.Args[0], cond.Args[1]
+	if !pure(s) || !pure(p) {
+		return
+	}
+
+	var trims []*ast.CallExpr
+	ast.Inspect(stmt.Body, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok && len(call.Args) == 2 {
+			if tp, tn, _ := m.packageFunc(call); tp == pkg && tn == "Trim"+affix && m.same(call.Args[0], s) && m.same(call.Args[1], p) {
+				trims = append(trims, call)
+			}
+		}
+		return true
+	})
+	if len(trims) != 1 {
+		return
+	}
+	// The value cut before the body must be the one the body trims.
+	for _, id := range []*ast.Ident{root(s), root(p)} {
+		if id != nil && m.mutated(stmt.Body, id, trims[0].Pos()) {
+			return
+		}
+	}
+
+	rest, cut := "after", "CutPrefix"
+	if affix == "Suffix" {
+		rest, cut = "before", "CutSuffix"
+	}
+	if mentions(stmt, rest) || mentions(stmt, "ok") {
+		return
+	}
+	qual := m.text(sel.X.Pos(), sel.X.End())
+	m.report("cutprefix", stmt.Cond.Pos(), stmt.Cond.End(), fmt.Sprintf("%s.Has%s and Trim%s can be %s.%s", pkg, affix, affix, pkg, cut),
+		analysis.TextEdit{Pos: stmt.Cond.Pos(), End: stmt.Cond.End(), NewText: fmt.Appendf(nil, "%s, ok := %s.%s(%s, %s); ok", rest, qual, cut, m.src(s), m.src(p))},
+		analysis.TextEdit{Pos: trims[0].Pos(), End: trims[0].End(), NewText: []byte(rest)},
+	)
+}
+
+// checkMinMaxIfElse suggests min or max for
+//
+//	if a < b { x = a } else { x = b }
+func (m *modernizer) checkMinMaxIfElse(stmt *ast.IfStmt) {
+	els, ok := stmt.Else.(*ast.BlockStmt)
+	if !ok || stmt.Init != nil {
+		return
+	}
+	cond, ok := stmt.Cond.(*ast.BinaryExpr)
+	if !ok {
+		return
+	}
+	lhs, thenValue := singleAssign(stmt.Body)
+	elseLHS, elseValue := singleAssign(els)
+	if lhs == nil || elseLHS == nil || !m.same(lhs, elseLHS) || !pure(lhs) {
+		return
+	}
+
+	var fn string
+	switch {
+	case m.same(thenValue, cond.X) && m.same(elseValue, cond.Y):
+		fn = smaller(cond.Op, "min", "max")
+	case m.same(thenValue, cond.Y) && m.same(elseValue, cond.X):
+		fn = smaller(cond.Op, "max", "min")
+	}
+	if fn == "" || !pure(cond.X) || !pure(cond.Y) || !m.ordered(cond.X, cond.Y) || !m.predeclared(fn, stmt.Pos()) || m.commented(stmt.Pos(), stmt.End()) {
+		return
+	}
+	m.report("minmax", stmt.Pos(), stmt.End(), "if statement can be "+fn,
+		analysis.TextEdit{Pos: stmt.Pos(), End: stmt.End(), NewText: fmt.Appendf(nil, "%s = %s(%s, %s)", m.src(lhs), fn, m.src(cond.X), m.src(cond.Y))})
+}
+
+// checkMinMaxAssign suggests min or max for statements such as
+//
+//	x := a
+//	if b < x { x = b }
+func (m *modernizer) checkMinMaxAssign(list []ast.Stmt) {
+	for i := 0; i+1 < len(list); i++ {
+		assign, ok := list[i].(*ast.AssignStmt)
+		if !ok || len(assign.Lhs) != 1 || len(assign.Rhs) != 1 || (assign.Tok != token.ASSIGN && assign.Tok != token.DEFINE) {
+			continue
+		}
+		stmt, ok := list[i+1].(*ast.IfStmt)
+		if !ok || stmt.Init != nil || stmt.Else != nil {
+			continue
+		}
+		cond, ok := stmt.Cond.(*ast.BinaryExpr)
+		if !ok {
+			continue
+		}
+		x, a := assign.Lhs[0], assign.Rhs[0]
+		lhs, b := singleAssign(stmt.Body)
+		if lhs == nil || !m.same(lhs, x) || !pure(x) || !pure(b) {
+			continue
+		}
+
+		// Taking b when b is smaller, or x is larger, is min.
+		var fn string
+		switch {
+		case m.same(cond.X, b) && m.same(cond.Y, x):
+			fn = smaller(cond.Op, "min", "max")
+		case m.same(cond.X, x) && m.same(cond.Y, b):
+			fn = smaller(cond.Op, "max", "min")
+		}
+		if fn == "" || !m.ordered(x, a, b) || !m.predeclared(fn, stmt.Pos()) || m.commented(assign.Pos(), stmt.End()) {
+			continue
+		}
+		m.report("minmax", assign.Pos(), stmt.End(), "assignment and if statement can be "+fn,
+			analysis.TextEdit{Pos: assign.Pos(), End: stmt.End(), NewText: fmt.Appendf(nil, "%s %s %s(%s, %s)", m.src(x), assign.Tok, fn, m.src(a), m.src(b))})
+		i++
+	}
+}
+
+// checkRangeInt suggests ranging over an int for
+//
+//	for i := 0; i < n; i++
+//
+// when neither i nor n change in the body.
+func (m *modernizer) checkRangeInt(loop *ast.ForStmt) {
+	if !m.typed {
+		return
+	}
+	init, ok := loop.Init.(*ast.AssignStmt)
+	if !ok || init.Tok != token.DEFINE || len(init.Lhs) != 1 || len(init.Rhs) != 1 || !isIntLit(init.Rhs[0], "0") {
+		return
+	}
+	i, ok := init.Lhs[0].(*ast.Ident)
+	if !ok {
+		return
+	}
+	cond, ok := loop.Cond.(*ast.BinaryExpr)
+	if !ok || cond.Op != token.LSS || !isIdent(cond.X, i.Name) {
+		return
+	}
+	post, ok := loop.Post.(*ast.IncDecStmt)
+	if !ok || post.Tok != token.INC || !isIdent(post.X, i.Name) {
+		return
+	}
+	if m.mutated(loop.Body, i, token.NoPos) || !m.invariant(loop.Body, cond.Y) || m.commented(init.Pos(), post.End()) {
+		return
+	}
+	// i := range n declares i with n's type, which has to be int.
+	tv, ok := m.pass.TypesInfo.Types[cond.Y]
+	if !ok {
+		return
+	}
+	if basic, ok := tv.Type.(*types.Basic); !ok || (basic.Kind() != types.Int && basic.Kind() != types.UntypedInt) {
+		return
+	}
+
+	header := "range " + m.src(cond.Y)
+	if m.uses(loop.Body, i) {
+		header = i.Name + " := " + header
+	}
+	m.report("rangeint", loop.Pos(), loop.Body.Lbrace, "for loop can range over "+m.src(cond.Y),
+		analysis.TextEdit{Pos: init.Pos(), End: post.End(), NewText: []byte(header)})
+}
+
+// invariant reports whether the loop bound n is the same on every
+// iteration of body: a constant, or a local variable or the length of one
+// that body doesn't change.
+func (m *modernizer) invariant(body *ast.BlockStmt, n ast.Expr) bool {
+	if tv, ok := m.pass.TypesInfo.Types[n]; ok && tv.Value != nil {
+		return true
+	}
+	if call, ok := n.(*ast.CallExpr); ok && len(call.Args) == 1 && isIdent(call.Fun, "len") && m.predeclared("len", call.Pos()) {
+		n = call.Args[0]
+	}
+	id, ok := n.(*ast.Ident)
+	if !ok {
+		return false
+	}
+	v, ok := m.pass.TypesInfo.Uses[id].(*types.Var)
+	if !ok || v.Pkg() == nil || v.Parent() == v.Pkg().Scope() {
+		return false
+	}
+	return !m.mutated(body, id, token.NoPos)
+}
+
+// mutated reports whether node assigns to the variable id names or a part
+// of it, takes its address, deletes from it or calls a method on it. When
+// pos is valid, only what happens before evaluating pos counts: the
+// statement assigning the expression at pos doesn't.
+func (m *modernizer) mutated(node ast.Node, id *ast.Ident, pos token.Pos) bool {
+	refers := func(e ast.Expr) bool {
+		r := root(e)
+		return r != nil && m.sameVar(r, id)
+	}
+	found := false
+	ast.Inspect(node, func(n ast.Node) bool {
+		if found || n == nil || (pos.IsValid() && n.Pos() >= pos) {
+			return false
+		}
+		if pos.IsValid() && n.End() > pos {
+			return true
+		}
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			found = slices.ContainsFunc(n.Lhs, refers)
+		case *ast.IncDecStmt:
+			found = refers(n.X)
+		case *ast.RangeStmt:
+			found = (n.Key != nil && refers(n.Key)) || (n.Value != nil && refers(n.Value))
+		case *ast.UnaryExpr:
+			found = n.Op == token.AND && refers(n.X)
+		case *ast.CallExpr:
+			if fn, ok := n.Fun.(*ast.Ident); ok && (fn.Name == "delete" || fn.Name == "clear") {
+				found = len(n.Args) > 0 && refers(n.Args[0])
+			} else if sel, ok := n.Fun.(*ast.SelectorExpr); ok {
+				found = refers(sel.X)
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// sameVar reports whether a and b name the same variable.
+func (m *modernizer) sameVar(a, b *ast.Ident) bool {
+	if a.Name != b.Name {
+		return false
+	}
+	if !m.typed {
+		return true
+	}
+	return m.pass.TypesInfo.ObjectOf(a) == m.pass.TypesInfo.ObjectOf(b)
+}
+
+// uses reports whether node refers to the variable id declares.
+func (m *modernizer) uses(node ast.Node, id *ast.Ident) bool {
+	obj := m.pass.TypesInfo.ObjectOf(id)
+	found := false
+	ast.Inspect(node, func(n ast.Node) bool {
+		if ref, ok := n.(*ast.Ident); ok && ref.Name == id.Name && (obj == nil || m.pass.TypesInfo.Uses[ref] == obj) {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// ordered reports whether the expressions are integers or strings of one
+// type, for which min and max agree with comparing them. Floats are left
+// out: min and max propagate NaNs, which comparisons don't.
+func (m *modernizer) ordered(exprs ...ast.Expr) bool {
+	if !m.typed {
+		return false
+	}
+	var typ types.Type
+	for _, e := range exprs {
+		t := m.pass.TypesInfo.TypeOf(e)
+		if t == nil {
+			return false
+		}
+		basic, ok := t.Underlying().(*types.Basic)
+		if !ok || basic.Info()&(types.IsInteger|types.IsString) == 0 {
+			return false
+		}
+		if basic.Info()&types.IsUntyped != 0 {
+			continue
+		}
+		if typ != nil && !types.Identical(typ, t) {
+			return false
+		}
+		typ = t
+	}
+	return true
+}
+
+// packageFunc returns the import path and name of the package-level
+// function call calls, and the selector naming it.
+func (m *modernizer) packageFunc(call *ast.CallExpr) (string, string, *ast.SelectorExpr) {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return "", "", nil
+	}
+	x, ok := sel.X.(*ast.Ident)
+	if !ok {
+		return "", "", nil
+	}
+	if m.typed {
+		if pn, ok := m.pass.TypesInfo.Uses[x].(*types.PkgName); ok {
+			return pn.Imported().Path(), sel.Sel.Name, sel
+		}
+		return "", "", nil
+	}
+	// Without type information, x names an import when nothing in the file
+	// declares it.
+	if x.Obj != nil {
+		return "", "", nil
+	}
+	for _, spec := range m.file.Imports {
+		path := strings.Trim(spec.Path.Value, `"`)
+		name := path[strings.LastIndex(path, "/")+1:]
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		if name == x.Name {
+			return path, sel.Sel.Name, sel
+		}
+	}
+	return "", "", nil
+}
+
+// importName returns the name the file imports path by, or "" if it
+// doesn't import it by name.
+func (m *modernizer) importName(path string) string {
+	for _, spec := range m.file.Imports {
+		if strings.Trim(spec.Path.Value, `"`) != path {
+			continue
+		}
+		if spec.Name == nil {
+			return path[strings.LastIndex(path, "/")+1:]
+		}
+		if spec.Name.Name != "_" && spec.Name.Name != "." {
+			return spec.Name.Name
+		}
+	}
+	return ""
+}
+
+// predeclared reports whether name refers to the predeclared identifier at
+// pos.
+func (m *modernizer) predeclared(name string, pos token.Pos) bool {
+	if scope := m.pass.Pkg.Scope().Innermost(pos); scope != nil {
+		_, obj := scope.LookupParent(name, pos)
+		return obj == types.Universe.Lookup(name)
+	}
+	// Without type information, only the package's top-level declarations
+	// and the file's imports are known.
+	return m.free(name, pos)
+}
+
+// free reports whether name is neither declared at the top level of the
+// package nor imported by the file, nor, with type information, declared
+// in a scope around pos.
+func (m *modernizer) free(name string, pos token.Pos) bool {
+	if scope := m.pass.Pkg.Scope().Innermost(pos); scope != nil {
+		_, obj := scope.LookupParent(name, pos)
+		return obj == nil || obj == types.Universe.Lookup(name)
+	}
+	for _, f := range m.pass.Files {
+		for _, decl := range f.Decls {
+			if declares(decl, name) {
+				return false
+			}
+		}
+	}
+	for _, spec := range m.file.Imports {
+		path := strings.Trim(spec.Path.Value, `"`)
+		if (spec.Name != nil && spec.Name.Name == name) || (spec.Name == nil && path[strings.LastIndex(path, "/")+1:] == name) {
+			return false
+		}
+	}
+	return true
+}
+
+// commented reports whether a comment lies between start and end, which
+// rewriting the range would lose.
+func (m *modernizer) commented(start, end token.Pos) bool {
+	return slices.ContainsFunc(m.file.Comments, func(g *ast.CommentGroup) bool {
+		return g.Pos() < end && g.End() > start
+	})
+}
+
+// same reports whether a and b are the same expression, ignoring layout.
+func (m *modernizer) same(a, b ast.Expr) bool {
+	return a != nil && b != nil && types.ExprString(a) == types.ExprString(b)
+}
+
+// src returns the source of e.
+func (m *modernizer) src(e ast.Expr) string {
+	if s := m.text(e.Pos(), e.End()); s != "" {
+		return s
+	}
+	return types.ExprString(e)
+}
+
+func (m *modernizer) text(start, end token.Pos) string {
+	tf := m.pass.Fset.File(start)
+	if tf == nil {
+		return ""
+	}
+	s, e := tf.Offset(start), tf.Offset(end)
+	if s < 0 || e > len(m.content) || s > e {
+		return ""
+	}
+	return string(m.content[s:e])
+}
+
+// smaller returns ifLess when op chooses the left operand when it is the
+// smaller one, ifGreater when it does when it is the larger one.
+func smaller(op token.Token, ifLess, ifGreater string) string {
+	switch op {
+	case token.LSS, token.LEQ:
+		return ifLess
+	case token.GTR, token.GEQ:
+		return ifGreater
+	}
+	return ""
+}
+
+// singleAssign returns the sides of block's one statement when it is a
+// plain assignment of one value.
+func singleAssign(block *ast.BlockStmt) (ast.Expr, ast.Expr) {
+	if len(block.List) != 1 {
+		return nil, nil
+	}
+	assign, ok := block.List[0].(*ast.AssignStmt)
+	if !ok || assign.Tok != token.ASSIGN || len(assign.Lhs) != 1 || len(assign.Rhs) != 1 {
+		return nil, nil
+	}
+	return assign.Lhs[0], assign.Rhs[0]
+}
+
+// pure reports whether evaluating e has no effects and, repeated, gives
+// the same value: identifiers, literals and selections of them.
+func pure(e ast.Expr) bool {
+	switch e := e.(type) {
+	case *ast.Ident, *ast.BasicLit:
+		return true
+	case *ast.SelectorExpr:
+		return pure(e.X)
+	case *ast.ParenExpr:
+		return pure(e.X)
+	case *ast.UnaryExpr:
+		return e.Op == token.SUB && pure(e.X)
+	}
+	return false
+}
+
+// root returns the variable e selects or indexes into, if any.
+func root(e ast.Expr) *ast.Ident {
+	for {
+		switch x := e.(type) {
+		case *ast.Ident:
+			return x
+		case *ast.SelectorExpr:
+			e = x.X
+		case *ast.IndexExpr:
+			e = x.X
+		case *ast.StarExpr:
+			e = x.X
+		case *ast.ParenExpr:
+			e = x.X
+		default:
+			return nil
+		}
+	}
+}
+
+// mentions reports whether node refers to name anywhere.
+func mentions(node ast.Node, name string) bool {
+	found := false
+	ast.Inspect(node, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && id.Name == name {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// declares reports whether decl declares name.
+func declares(decl ast.Decl, name string) bool {
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		return d.Recv == nil && d.Name.Name == name
+	case *ast.GenDecl:
+		for _, spec := range d.Specs {
+			switch s := spec.(type) {
+			case *ast.TypeSpec:
+				if s.Name.Name == name {
+					return true
+				}
+			case *ast.ValueSpec:
+				if slices.ContainsFunc(s.Names, func(id *ast.Ident) bool { return id.Name == name }) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func isIdent(e ast.Expr, name string) bool {
+	id, ok := e.(*ast.Ident)
+	return ok && id.Name == name
+}
+
+func isIntLit(e ast.Expr, value string) bool {
+	lit, ok := e.(*ast.BasicLit)
+	return ok && lit.Kind == token.INT && lit.Value == value
+}
@@ -0,0 +1,194 @@
+package modernize_test
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/analyzers"
+	"github.com/mamaar/gorefactor/pkg/analyzers/modernize"
+	wstypes "github.com/mamaar/gorefactor/pkg/types"
+)
+
+func createTestWorkspace(t *testing.T, src string) *wstypes.Workspace {
+	t.Helper()
+	fileSet := token.NewFileSet()
+
+	astFile, err := parser.ParseFile(fileSet, "testpkg.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("Failed to parse test source: %v", err)
+	}
+
+	file := &wstypes.File{
+		Path:            "testpkg.go",
+		AST:             astFile,
+		OriginalContent: []byte(src),
+	}
+
+	info := &types.Info{Types: map[ast.Expr]types.TypeAndValue{}, Defs: map[*ast.Ident]types.Object{}, Uses: map[*ast.Ident]types.Object{}}
+	conf := types.Config{Importer: importer.ForCompiler(fileSet, "source", nil)}
+	typesPkg, err := conf.Check("test/testpkg", fileSet, []*ast.File{astFile}, info)
+	if err != nil {
+		t.Fatalf("Failed to type-check test source: %v", err)
+	}
+	pkg := &wstypes.Package{
+		Name:      "testpkg",
+		Path:      "test/testpkg",
+		Files:     map[string]*wstypes.File{"testpkg.go": file},
+		TypesPkg:  typesPkg,
+		TypesInfo: info,
+	}
+	file.Package = pkg
+
+	return &wstypes.Workspace{
+		Packages: map[string]*wstypes.Package{"test/testpkg": pkg},
+		FileSet:  fileSet,
+	}
+}
+
+// fix runs the analyzer and returns the checks it reported and the source
+// with its fixes applied.
+func fix(t *testing.T, src string, opts ...modernize.Option) ([]string, string) {
+	t.Helper()
+	ws := createTestWorkspace(t, src)
+	rr, err := analyzers.Run(ws, modernize.NewAnalyzer(opts...), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, _ := rr.Result.([]*modernize.Result)
+	var checks []string
+	for _, r := range results {
+		checks = append(checks, r.Check)
+	}
+
+	changes := analyzers.DiagnosticsToChanges(ws.FileSet, rr.Diagnostics)
+	slices.SortFunc(changes, func(a, b wstypes.Change) int { return b.Start - a.Start })
+	out := src
+	for _, c := range changes {
+		out = out[:c.Start] + c.NewText + out[c.End:]
+	}
+	return checks, out
+}
+
+const src = `package testpkg
+
+import (
+	"sort"
+	"strings"
+)
+
+func Describe(v interface{}) string {
+	s := strings.Replace(fmtValue(v), "\t", " ", -1)
+	if strings.HasPrefix(s, "x:") {
+		s = strings.TrimPrefix(s, "x:")
+	}
+	return s
+}
+
+func fmtValue(v any) string { return "" }
+
+func Bound(a, b int, names []string) int {
+	sort.Strings(names)
+	if a < b {
+		a = b
+	} else {
+		a = -b
+	}
+	n := a
+	if b < n {
+		n = b
+	}
+	sum := 0
+	for i := 0; i < len(names); i++ {
+		sum += i
+	}
+	for i := 0; i < n; i++ {
+		n--
+	}
+	return sum
+}
+
+func Smaller(x, y float64) float64 {
+	if x < y {
+		return x
+	}
+	z := x
+	if y < z {
+		z = y
+	}
+	return z
+}
+`
+
+func TestModernize(t *testing.T) {
+	checks, out := fix(t, src, modernize.WithGoVersion("1.22"))
+
+	slices.Sort(checks)
+	if want := []string{"any", "cutprefix", "minmax", "rangeint", "replaceall", "sortslice"}; !slices.Equal(checks, want) {
+		t.Errorf("got checks %v, want %v", checks, want)
+	}
+	for _, want := range []string{
+		"func Describe(v any) string",
+		`strings.ReplaceAll(fmtValue(v), "\t", " ")`,
+		`if after, ok := strings.CutPrefix(s, "x:"); ok {
+		s = after
+	}`,
+		"slices.Sort(names)",
+		"n := min(a, b)",
+		"for i := range len(names) {",
+		"for i := 0; i < n; i++ {", // n changes in the loop
+		"z := x\n",                 // Floats are left alone
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("fixed source lacks %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestModernize_GoVersion(t *testing.T) {
+	checks, _ := fix(t, src, modernize.WithGoVersion("1.20"))
+	slices.Sort(checks)
+	if want := []string{"any", "cutprefix", "replaceall"}; !slices.Equal(checks, want) {
+		t.Errorf("go 1.20: got checks %v, want %v", checks, want)
+	}
+
+	// A build constraint raises the version of its file.
+	checks, _ = fix(t, "//go:build go1.22\n\n"+src, modernize.WithGoVersion("1.17"), modernize.WithChecks("rangeint", "any"))
+	slices.Sort(checks)
+	if want := []string{"any", "rangeint"}; !slices.Equal(checks, want) {
+		t.Errorf("go1.22 constraint: got checks %v, want %v", checks, want)
+	}
+
+	allowed, unavailable := modernize.Available("1.21")
+	if len(allowed) != 5 || len(unavailable) != 1 || unavailable[0].Name != "rangeint" {
+		t.Errorf("go 1.21: got allowed %v, unavailable %v", allowed, unavailable)
+	}
+}
+
+func TestModernize_Shadowed(t *testing.T) {
+	checks, _ := fix(t, `package testpkg
+
+type any struct{}
+
+func min(a, b int) int { return a }
+
+func F(v interface{}, a, b int) int {
+	if a < b {
+		return a
+	}
+	c := a
+	if b < c {
+		c = b
+	}
+	return c
+}
+`)
+	if len(checks) != 0 {
+		t.Errorf("expected no suggestions where any and min are declared, got %v", checks)
+	}
+}
@@ -0,0 +1,232 @@
+// Package naming provides a go/analysis analyzer that detects package-level
+// names and methods breaking Go's naming conventions: initialisms in mixed
+// case (Url instead of URL), underscores (max_size) and names repeating
+// their package's name (client.ClientConfig).
+package naming
+
+import (
+	"go/ast"
+	"go/token"
+	"strings"
+	"unicode"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// Violation kinds.
+const (
+	Initialism = "initialism" // An initialism isn't in a consistent case
+	Underscore = "underscore" // The name has underscores
+	Stutter    = "stutter"    // The name starts with its package's name
+)
+
+// Result is the typed result returned for MCP consumption.
+type Result struct {
+	File          string   `json:"file"`
+	Line          int      `json:"line"`
+	Column        int      `json:"column"`
+	Name          string   `json:"name"`
+	Kind          string   `json:"kind"`               // func, method, type, const or var
+	Receiver      string   `json:"receiver,omitempty"` // Base type name of a method's receiver
+	Package       string   `json:"package"`            // Import path
+	Violations    []string `json:"violations"`
+	SuggestedName string   `json:"suggested_name"`
+
+	Pos token.Pos `json:"-"` // The declaring identifier, for fixers
+}
+
+// initialisms are the words golint and the Go style guide write in a
+// single case, from golint's list.
+var initialisms = map[string]bool{
+	"ACL": true, "API": true, "ASCII": true, "CPU": true, "CSS": true, "DNS": true,
+	"EOF": true, "GUID": true, "HTML": true, "HTTP": true, "HTTPS": true, "ID": true,
+	"IP": true, "JSON": true, "LHS": true, "QPS": true, "RAM": true, "RHS": true,
+	"RPC": true, "SLA": true, "SMTP": true, "SQL": true, "SSH": true, "TCP": true,
+	"TLS": true, "TTL": true, "UDP": true, "UI": true, "UID": true, "UUID": true,
+	"URI": true, "URL": true, "UTF8": true, "VM": true, "XML": true, "XMPP": true,
+	"XSRF": true, "XSS": true,
+}
+
+const doc = "detects names with mixed-case initialisms, underscores or their package's name as a prefix"
+
+var Analyzer = NewAnalyzer()
+
+// NewAnalyzer creates the analyzer. It works from syntax alone, so it
+// reports in packages that aren't type-checked too.
+func NewAnalyzer() *analysis.Analyzer {
+	return &analysis.Analyzer{
+		Name: "naming",
+		Doc:  doc,
+		Run:  run,
+	}
+}
+
+func run(pass *analysis.Pass) (any, error) {
+	var results []*Result
+	check := func(id *ast.Ident, kind, receiver string) {
+		suggested, violations := Suggest(id.Name, pass.Pkg.Name(), kind != "method")
+		if len(violations) == 0 {
+			return
+		}
+		p := pass.Fset.Position(id.Pos())
+		name := id.Name
+		if receiver != "" {
+			name = receiver + "." + name
+		}
+		pass.Report(analysis.Diagnostic{
+			Pos:     id.Pos(),
+			End:     id.End(),
+			Message: kind + " " + name + " should be " + suggested + " (" + strings.Join(violations, ", ") + ")",
+		})
+		results = append(results, &Result{
+			File:          p.Filename,
+			Line:          p.Line,
+			Column:        p.Column,
+			Name:          id.Name,
+			Kind:          kind,
+			Receiver:      receiver,
+			Package:       pass.Pkg.Path(),
+			Violations:    violations,
+			SuggestedName: suggested,
+			Pos:           id.Pos(),
+		})
+	}
+
+	for _, file := range pass.Files {
+		for _, decl := range file.Decls {
+			switch d := decl.(type) {
+			case *ast.FuncDecl:
+				if d.Recv == nil {
+					if d.Name.Name != "init" && d.Name.Name != "main" {
+						check(d.Name, "func", "")
+					}
+				} else if recv := receiverName(d.Recv); recv != "" {
+					check(d.Name, "method", recv)
+				}
+			case *ast.GenDecl:
+				for _, spec := range d.Specs {
+					switch s := spec.(type) {
+					case *ast.TypeSpec:
+						check(s.Name, "type", "")
+					case *ast.ValueSpec:
+						kind := "var"
+						if d.Tok == token.CONST {
+							kind = "const"
+						}
+						for _, name := range s.Names {
+							check(name, kind, "")
+						}
+					}
+				}
+			}
+		}
+	}
+	return results, nil
+}
+
+// Suggest returns the conventional spelling of name and the conventions
+// name breaks, none if it is conventional already. Package-level names
+// that repeat pkg are reported when stutter is set. The suggestion keeps
+// the name's exportedness.
+func Suggest(name, pkg string, stutter bool) (string, []string) {
+	if name == "_" || strings.HasPrefix(name, "_") {
+		return name, nil
+	}
+	var violations []string
+	suggested := name
+	if strings.Contains(strings.Trim(name, "_"), "_") {
+		violations = append(violations, Underscore)
+		suggested = joinUnderscores(name)
+	}
+	if fixed := fixInitialisms(suggested); fixed != suggested {
+		violations = append(violations, Initialism)
+		suggested = fixed
+	}
+	if stutter && pkg != "main" && ast.IsExported(suggested) && len(suggested) > len(pkg) &&
+		strings.EqualFold(suggested[:len(pkg)], pkg) && unicode.IsUpper(rune(suggested[len(pkg)])) {
+		violations = append(violations, Stutter)
+		suggested = suggested[len(pkg):]
+	}
+	return suggested, violations
+}
+
+// joinUnderscores turns snake_case and ALL_CAPS names into camel case:
+// max_size becomes maxSize and MAX_SIZE becomes MaxSize.
+func joinUnderscores(name string) string {
+	parts := strings.FieldsFunc(name, func(r rune) bool { return r == '_' })
+	allCaps := strings.ToUpper(name) == name
+	var sb strings.Builder
+	for i, part := range parts {
+		if allCaps {
+			part = strings.ToLower(part)
+		}
+		if i > 0 || allCaps {
+			part = strings.ToUpper(part[:1]) + part[1:]
+		}
+		sb.WriteString(part)
+	}
+	return sb.String()
+}
+
+// fixInitialisms writes the initialisms among the words of a camel case
+// name in upper case, or in lower case when the name is unexported and
+// starts with one: UserId becomes UserID and IdToken, unexported as
+// idToken, stays that way.
+func fixInitialisms(name string) string {
+	words := splitWords(name)
+	for i, w := range words {
+		upper := strings.ToUpper(w)
+		if !initialisms[upper] || w == upper {
+			continue
+		}
+		if i == 0 && unicode.IsLower(rune(w[0])) {
+			words[i] = strings.ToLower(w)
+		} else {
+			words[i] = upper
+		}
+	}
+	return strings.Join(words, "")
+}
+
+// splitWords splits a camel case name before each upper case letter that
+// follows a lower case one, and before the last letter of a run of upper
+// case letters followed by a lower case one: HTTPServerUrl splits into
+// HTTP, Server and Url. Digits stay with the letters before them.
+func splitWords(name string) []string {
+	runes := []rune(name)
+	var words []string
+	start := 0
+	for i := 1; i < len(runes); i++ {
+		prev, cur := runes[i-1], runes[i]
+		next := rune(0)
+		if i+1 < len(runes) {
+			next = runes[i+1]
+		}
+		if unicode.IsUpper(cur) && (unicode.IsLower(prev) || unicode.IsDigit(prev) || unicode.IsUpper(prev) && unicode.IsLower(next)) {
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+	return append(words, string(runes[start:]))
+}
+
+// receiverName returns the base type name of a method receiver.
+func receiverName(recv *ast.FieldList) string {
+	if len(recv.List) == 0 {
+		return ""
+	}
+	t := recv.List[0].Type
+	if star, ok := t.(*ast.StarExpr); ok {
+		t = star.X
+	}
+	switch x := t.(type) {
+	case *ast.IndexExpr:
+		t = x.X
+	case *ast.IndexListExpr:
+		t = x.X
+	}
+	if id, ok := t.(*ast.Ident); ok {
+		return id.Name
+	}
+	return ""
+}
@@ -0,0 +1,95 @@
+package naming_test
+
+import (
+	"go/parser"
+	"go/token"
+	"slices"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/analyzers"
+	"github.com/mamaar/gorefactor/pkg/analyzers/naming"
+	wstypes "github.com/mamaar/gorefactor/pkg/types"
+)
+
+func TestSuggest(t *testing.T) {
+	for _, tc := range []struct {
+		name, pkg  string
+		want       string
+		violations []string
+	}{
+		{"UserId", "store", "UserID", []string{naming.Initialism}},
+		{"getUrl", "store", "getURL", []string{naming.Initialism}},
+		{"idToken", "store", "idToken", nil},
+		{"HttpServerUrl", "store", "HTTPServerURL", []string{naming.Initialism}},
+		{"Utf8Reader", "store", "UTF8Reader", []string{naming.Initialism}},
+		{"Idle", "store", "Idle", nil},
+		{"max_size", "store", "maxSize", []string{naming.Underscore}},
+		{"MAX_SIZE", "store", "MaxSize", []string{naming.Underscore}},
+		{"user_id", "store", "userID", []string{naming.Underscore, naming.Initialism}},
+		{"ClientConfig", "client", "Config", []string{naming.Stutter}},
+		{"ClientUrl", "client", "URL", []string{naming.Initialism, naming.Stutter}},
+		{"Client", "client", "Client", nil},
+		{"Clients", "client", "Clients", nil},
+		{"clientConfig", "client", "clientConfig", nil},
+		{"_", "store", "_", nil},
+	} {
+		got, violations := naming.Suggest(tc.name, tc.pkg, true)
+		if got != tc.want || !slices.Equal(violations, tc.violations) {
+			t.Errorf("Suggest(%q, %q) = %q, %v; want %q, %v", tc.name, tc.pkg, got, violations, tc.want, tc.violations)
+		}
+	}
+}
+
+const src = `package client
+
+type ClientConfig struct{ BaseUrl string }
+
+func (c *ClientConfig) GetUrl() string { return c.BaseUrl }
+
+func (c *ClientConfig) ClientName() string { return "" }
+
+const default_timeout = 30
+
+var userId int
+
+func main_loop() {}
+`
+
+func TestNaming(t *testing.T) {
+	fileSet := token.NewFileSet()
+	astFile, err := parser.ParseFile(fileSet, "client.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := &wstypes.File{Path: "client.go", AST: astFile, OriginalContent: []byte(src)}
+	pkg := &wstypes.Package{
+		Name:       "client",
+		Path:       "test/client",
+		ImportPath: "test/client",
+		Files:      map[string]*wstypes.File{"client.go": file},
+	}
+	file.Package = pkg
+	ws := &wstypes.Workspace{Packages: map[string]*wstypes.Package{"test/client": pkg}, FileSet: fileSet}
+
+	rr, err := analyzers.Run(ws, naming.Analyzer, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, r := range rr.Result.([]*naming.Result) {
+		got = append(got, r.Kind+" "+r.Name+" -> "+r.SuggestedName)
+	}
+	want := []string{
+		"type ClientConfig -> Config",
+		"method GetUrl -> GetURL",
+		"const default_timeout -> defaultTimeout",
+		"var userId -> userID",
+		"func main_loop -> mainLoop",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("results = %q, want %q", got, want)
+	}
+	if len(rr.Diagnostics) != len(want) {
+		t.Errorf("got %d diagnostics, want %d", len(rr.Diagnostics), len(want))
+	}
+}
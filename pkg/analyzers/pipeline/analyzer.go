@@ -0,0 +1,497 @@
+// Package pipeline detects long loops whose bodies run as a sequence of
+// produce, transform and consume steps, each handing a few values to the
+// next, and reports where the body splits into stage functions.
+package pipeline
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"maps"
+	"slices"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+const (
+	// DefaultMinStatements is the number of statements a loop body needs
+	// to be reported.
+	DefaultMinStatements = 6
+	// DefaultMinStages is the number of stages a loop body must split into
+	// to be reported.
+	DefaultMinStages = 3
+	// DefaultMaxLive is the number of values that may flow from one stage
+	// into the rest of the body.
+	DefaultMaxLive = 2
+	// DefaultMinStageStatements is the smallest stage worth extracting.
+	DefaultMinStageStatements = 2
+)
+
+// Stage kinds.
+const (
+	Produce   = "produce"
+	Transform = "transform"
+	Consume   = "consume"
+)
+
+// Stage is a run of loop body statements that only reads a few values
+// from the statements before it.
+type Stage struct {
+	Kind       string   `json:"kind"`
+	StartLine  int      `json:"start_line"`
+	EndLine    int      `json:"end_line"`
+	Statements int      `json:"statements"`
+	Inputs     []string `json:"inputs,omitempty"`  // Values read from the loop variables or earlier stages
+	Outputs    []string `json:"outputs,omitempty"` // Values later stages read
+	Filter     bool     `json:"filter,omitempty"`  // The stage skips iterations with continue
+
+	Start, End int `json:"-"` // Statement indices into the loop body, End exclusive
+}
+
+// Result is the typed result returned for MCP consumption.
+type Result struct {
+	File                       string   `json:"file"`
+	Line                       int      `json:"line"`
+	Column                     int      `json:"column"`
+	Function                   string   `json:"function_name"`
+	Statements                 int      `json:"statements"`
+	Stages                     []*Stage `json:"stages"`
+	Decomposition              string   `json:"decomposition"`
+	LongestStage               int      `json:"longest_stage"`
+	ComplexityReductionPercent int      `json:"complexity_reduction_estimate"`
+	Suggestion                 string   `json:"suggestion"`
+}
+
+type config struct {
+	minStatements int
+	minStages     int
+	maxLive       int
+}
+
+// Option configures the analyzer.
+type Option func(*config)
+
+// WithMinStatements sets the number of statements a loop body needs.
+func WithMinStatements(n int) Option {
+	return func(c *config) { c.minStatements = n }
+}
+
+// WithMinStages sets the number of stages a loop body must split into.
+func WithMinStages(n int) Option {
+	return func(c *config) { c.minStages = n }
+}
+
+// WithMaxLive sets the number of values that may cross a stage boundary.
+func WithMaxLive(n int) Option {
+	return func(c *config) { c.maxLive = n }
+}
+
+var defaultConfig = config{
+	minStatements: DefaultMinStatements,
+	minStages:     DefaultMinStages,
+	maxLive:       DefaultMaxLive,
+}
+
+var Analyzer = &analysis.Analyzer{
+	Name: "pipeline",
+	Doc:  "detects long loops that run as produce, transform and consume stages",
+	Run:  makeRun(defaultConfig),
+}
+
+// NewAnalyzer creates a configured pipeline analyzer.
+func NewAnalyzer(opts ...Option) *analysis.Analyzer {
+	cfg := defaultConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return &analysis.Analyzer{
+		Name: "pipeline",
+		Doc:  "detects long loops that run as produce, transform and consume stages",
+		Run:  makeRun(cfg),
+	}
+}
+
+func makeRun(cfg config) func(*analysis.Pass) (any, error) {
+	return func(pass *analysis.Pass) (any, error) {
+		var results []*Result
+		for _, file := range pass.Files {
+			for _, decl := range file.Decls {
+				fn, ok := decl.(*ast.FuncDecl)
+				if !ok || fn.Body == nil {
+					continue
+				}
+				ast.Inspect(fn.Body, func(n ast.Node) bool {
+					body := LoopBody(n)
+					if body == nil || len(body.List) < cfg.minStatements {
+						return true
+					}
+					stages := Split(pass.Fset, n.(ast.Stmt), cfg.maxLive, DefaultMinStageStatements)
+					if len(stages) < cfg.minStages {
+						return true
+					}
+					r := newResult(pass.Fset, fn, n, stages)
+					results = append(results, r)
+					pass.Report(analysis.Diagnostic{
+						Pos:     n.Pos(),
+						End:     n.End(),
+						Message: fmt.Sprintf("loop in %s runs %d statements as %d stages (%s); extract them into stage functions", r.Function, r.Statements, len(stages), r.Decomposition),
+					})
+					return true
+				})
+			}
+		}
+		return results, nil
+	}
+}
+
+func newResult(fset *token.FileSet, fn *ast.FuncDecl, loop ast.Node, stages []*Stage) *Result {
+	pos := fset.Position(loop.Pos())
+	r := &Result{
+		File:       pos.Filename,
+		Line:       pos.Line,
+		Column:     pos.Column,
+		Function:   fn.Name.Name,
+		Statements: len(LoopBody(loop).List),
+		Stages:     stages,
+	}
+	var parts []string
+	for _, s := range stages {
+		parts = append(parts, fmt.Sprintf("%s(%d)", s.Kind, s.Statements))
+		r.LongestStage = max(r.LongestStage, s.Statements)
+	}
+	r.Decomposition = strings.Join(parts, " → ")
+	r.ComplexityReductionPercent = (r.Statements - r.LongestStage) * 100 / r.Statements
+	r.Suggestion = fmt.Sprintf("Extract the %d stages into functions called in sequence, or run them as goroutines connected by channels carrying the values that cross each boundary", len(stages))
+	return r
+}
+
+// LoopBody returns the body of a for or range statement, or nil for any
+// other node.
+func LoopBody(n ast.Node) *ast.BlockStmt {
+	switch l := n.(type) {
+	case *ast.ForStmt:
+		return l.Body
+	case *ast.RangeStmt:
+		return l.Body
+	}
+	return nil
+}
+
+// Split cuts the body of loop into stages. A boundary falls before a
+// statement when between one and maxLive loop variables or earlier
+// definitions are read from there on, and every stage keeps at least
+// minStageStatements statements. Bodies that return, break out of the
+// loop, defer or jump to labels can't be split and yield nil.
+func Split(fset *token.FileSet, loop ast.Stmt, maxLive, minStageStatements int) []*Stage {
+	body := LoopBody(loop)
+	if body == nil || !splittable(body) {
+		return nil
+	}
+	stmts := body.List
+	n := len(stmts)
+
+	// defined maps each name to the statement first declaring it, -1 for
+	// the loop variables.
+	defined := make(map[string]int)
+	for _, id := range loopVars(loop) {
+		defined[id.Name] = -1
+	}
+	uses := make([][]string, n)
+	for i, stmt := range stmts {
+		uses[i] = reads(stmt, defined)
+		for _, name := range declares(stmt) {
+			if _, ok := defined[name]; !ok {
+				defined[name] = i
+			}
+		}
+	}
+
+	// live returns the names read by statements [from, to) that are
+	// defined before statement b, in order of definition.
+	live := func(from, b, to int) []string {
+		var names []string
+		for i := from; i < to; i++ {
+			for _, name := range uses[i] {
+				if defined[name] < b && !slices.Contains(names, name) {
+					names = append(names, name)
+				}
+			}
+		}
+		slices.SortStableFunc(names, func(a, b string) int { return defined[a] - defined[b] })
+		return names
+	}
+
+	var bounds []int
+	start := 0
+	for b := 1; b < n; b++ {
+		if b-start < minStageStatements || n-b < minStageStatements {
+			continue
+		}
+		if l := live(b, b, n); len(l) > 0 && len(l) <= maxLive {
+			bounds = append(bounds, b)
+			start = b
+		}
+	}
+	if len(bounds) == 0 {
+		return nil
+	}
+
+	bounds = append([]int{0}, append(bounds, n)...)
+	var stages []*Stage
+	for k := range len(bounds) - 1 {
+		s := &Stage{
+			Kind:       Transform,
+			Start:      bounds[k],
+			End:        bounds[k+1],
+			Statements: bounds[k+1] - bounds[k],
+			StartLine:  fset.Position(stmts[bounds[k]].Pos()).Line,
+			EndLine:    fset.Position(stmts[bounds[k+1]-1].End()).Line,
+			Inputs:     live(bounds[k], bounds[k], bounds[k+1]),
+		}
+		for _, name := range live(bounds[k+1], bounds[k+1], n) {
+			if defined[name] >= s.Start {
+				s.Outputs = append(s.Outputs, name)
+			}
+		}
+		for _, stmt := range stmts[s.Start:s.End] {
+			s.Filter = s.Filter || continues(stmt)
+		}
+		stages = append(stages, s)
+	}
+	stages[0].Kind = Produce
+	stages[len(stages)-1].Kind = Consume
+	return stages
+}
+
+// loopVars returns the variables a loop statement declares.
+func loopVars(loop ast.Stmt) []*ast.Ident {
+	var vars []*ast.Ident
+	switch l := loop.(type) {
+	case *ast.RangeStmt:
+		if l.Tok == token.DEFINE {
+			for _, e := range []ast.Expr{l.Key, l.Value} {
+				if id, ok := e.(*ast.Ident); ok && id.Name != "_" {
+					vars = append(vars, id)
+				}
+			}
+		}
+	case *ast.ForStmt:
+		if l.Init != nil {
+			for _, name := range declares(l.Init) {
+				vars = append(vars, ast.NewIdent(name))
+			}
+		}
+	}
+	return vars
+}
+
+// declares returns the names a statement declares in the enclosing block.
+func declares(stmt ast.Stmt) []string {
+	var names []string
+	switch s := stmt.(type) {
+	case *ast.AssignStmt:
+		if s.Tok == token.DEFINE {
+			for _, lhs := range s.Lhs {
+				if id, ok := lhs.(*ast.Ident); ok && id.Name != "_" {
+					names = append(names, id.Name)
+				}
+			}
+		}
+	case *ast.DeclStmt:
+		if gen, ok := s.Decl.(*ast.GenDecl); ok {
+			for _, spec := range gen.Specs {
+				switch sp := spec.(type) {
+				case *ast.ValueSpec:
+					for _, id := range sp.Names {
+						if id.Name != "_" {
+							names = append(names, id.Name)
+						}
+					}
+				case *ast.TypeSpec:
+					names = append(names, sp.Name.Name)
+				}
+			}
+		}
+	}
+	return names
+}
+
+// reads returns the names of vars that node reads, in order, leaving
+// out those a declaration nested in node shadows.
+func reads(node ast.Node, vars map[string]int) []string {
+	var names []string
+	var walk func(n ast.Node, shadowed map[string]bool)
+	scope := func(shadowed map[string]bool, stmts ...ast.Stmt) map[string]bool {
+		inner := maps.Clone(shadowed)
+		for _, s := range stmts {
+			if s == nil {
+				continue
+			}
+			walk(s, inner)
+			for _, name := range declares(s) {
+				inner[name] = true
+			}
+		}
+		return inner
+	}
+	walk = func(n ast.Node, shadowed map[string]bool) {
+		if n == nil {
+			return
+		}
+		ast.Inspect(n, func(c ast.Node) bool {
+			switch x := c.(type) {
+			case *ast.Ident:
+				if _, ok := vars[x.Name]; ok && !shadowed[x.Name] && !slices.Contains(names, x.Name) {
+					names = append(names, x.Name)
+				}
+			case *ast.AssignStmt:
+				if x.Tok == token.DEFINE {
+					for _, e := range x.Rhs {
+						walk(e, shadowed)
+					}
+					return false
+				}
+			case *ast.ValueSpec:
+				if x.Type != nil {
+					walk(x.Type, shadowed)
+				}
+				for _, e := range x.Values {
+					walk(e, shadowed)
+				}
+				return false
+			case *ast.SelectorExpr:
+				walk(x.X, shadowed)
+				return false
+			case *ast.KeyValueExpr:
+				// Keys of struct literals name fields, not variables.
+				if _, ok := x.Key.(*ast.Ident); ok {
+					walk(x.Value, shadowed)
+					return false
+				}
+			case *ast.BlockStmt:
+				scope(shadowed, x.List...)
+				return false
+			case *ast.CaseClause:
+				for _, e := range x.List {
+					walk(e, shadowed)
+				}
+				scope(shadowed, x.Body...)
+				return false
+			case *ast.CommClause:
+				scope(shadowed, append([]ast.Stmt{x.Comm}, x.Body...)...)
+				return false
+			case *ast.IfStmt:
+				inner := scope(shadowed, x.Init)
+				walk(x.Cond, inner)
+				walk(x.Body, inner)
+				if x.Else != nil {
+					walk(x.Else, inner)
+				}
+				return false
+			case *ast.SwitchStmt:
+				inner := scope(shadowed, x.Init)
+				if x.Tag != nil {
+					walk(x.Tag, inner)
+				}
+				walk(x.Body, inner)
+				return false
+			case *ast.TypeSwitchStmt:
+				walk(x.Body, scope(shadowed, x.Init, x.Assign))
+				return false
+			case *ast.ForStmt:
+				inner := scope(shadowed, x.Init)
+				if x.Cond != nil {
+					walk(x.Cond, inner)
+				}
+				if x.Post != nil {
+					walk(x.Post, inner)
+				}
+				walk(x.Body, inner)
+				return false
+			case *ast.RangeStmt:
+				walk(x.X, shadowed)
+				inner := maps.Clone(shadowed)
+				for _, e := range []ast.Expr{x.Key, x.Value} {
+					if id, ok := e.(*ast.Ident); ok && x.Tok == token.DEFINE {
+						inner[id.Name] = true
+					} else if e != nil {
+						walk(e, shadowed)
+					}
+				}
+				walk(x.Body, inner)
+				return false
+			case *ast.FuncLit:
+				inner := maps.Clone(shadowed)
+				for _, list := range []*ast.FieldList{x.Type.Params, x.Type.Results} {
+					if list == nil {
+						continue
+					}
+					for _, field := range list.List {
+						for _, id := range field.Names {
+							inner[id.Name] = true
+						}
+					}
+				}
+				walk(x.Body, inner)
+				return false
+			}
+			return true
+		})
+	}
+	walk(node, map[string]bool{})
+	return names
+}
+
+// splittable reports whether body only leaves an iteration by running to
+// its end or with an unlabeled continue.
+func splittable(body *ast.BlockStmt) bool {
+	ok := true
+	var walk func(n ast.Node, inLoop, inSwitch bool)
+	walk = func(n ast.Node, inLoop, inSwitch bool) {
+		ast.Inspect(n, func(c ast.Node) bool {
+			if !ok || c == n {
+				return ok
+			}
+			switch x := c.(type) {
+			case *ast.FuncLit:
+				return false
+			case *ast.ReturnStmt, *ast.DeferStmt, *ast.LabeledStmt:
+				ok = false
+			case *ast.BranchStmt:
+				switch {
+				case x.Label != nil || x.Tok == token.GOTO:
+					ok = false
+				case x.Tok == token.BREAK && !inLoop && !inSwitch:
+					ok = false
+				}
+			case *ast.ForStmt, *ast.RangeStmt:
+				walk(c, true, inSwitch)
+				return false
+			case *ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.SelectStmt:
+				walk(c, inLoop, true)
+				return false
+			}
+			return ok
+		})
+	}
+	walk(body, false, false)
+	return ok
+}
+
+// continues reports whether stmt continues the enclosing loop.
+func continues(stmt ast.Stmt) bool {
+	found := false
+	ast.Inspect(stmt, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.FuncLit, *ast.ForStmt, *ast.RangeStmt:
+			return false
+		case *ast.BranchStmt:
+			if x.Tok == token.CONTINUE {
+				found = true
+			}
+		}
+		return !found
+	})
+	return found
+}
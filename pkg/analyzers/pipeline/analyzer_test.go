@@ -0,0 +1,176 @@
+package pipeline_test
+
+import (
+	"go/parser"
+	"go/token"
+	"slices"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/analyzers"
+	"github.com/mamaar/gorefactor/pkg/analyzers/pipeline"
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+func createTestWorkspace(t *testing.T, src string) *types.Workspace {
+	t.Helper()
+	fileSet := token.NewFileSet()
+
+	astFile, err := parser.ParseFile(fileSet, "testpkg.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("Failed to parse test source: %v", err)
+	}
+
+	file := &types.File{
+		Path:            "testpkg.go",
+		AST:             astFile,
+		OriginalContent: []byte(src),
+	}
+
+	pkg := &types.Package{
+		Name:  "testpkg",
+		Path:  "test/testpkg",
+		Files: map[string]*types.File{"testpkg.go": file},
+	}
+	file.Package = pkg
+
+	return &types.Workspace{
+		Packages: map[string]*types.Package{"test/testpkg": pkg},
+		FileSet:  fileSet,
+	}
+}
+
+func run(t *testing.T, src string, opts ...pipeline.Option) []*pipeline.Result {
+	t.Helper()
+	ws := createTestWorkspace(t, src)
+	rr, err := analyzers.Run(ws, pipeline.NewAnalyzer(opts...), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, ok := rr.Result.([]*pipeline.Result)
+	if !ok && rr.Result != nil {
+		t.Fatalf("Expected []*pipeline.Result, got %T", rr.Result)
+	}
+	if len(rr.Diagnostics) != len(results) {
+		t.Errorf("Expected one diagnostic per result, got %d for %d", len(rr.Diagnostics), len(results))
+	}
+	return results
+}
+
+const importSrc = `package testpkg
+
+func Import(lines []string, db *DB) int {
+	total := 0
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		fields := strings.Split(line, ",")
+		if len(fields) < 2 {
+			continue
+		}
+		name := strings.ToUpper(fields[0])
+		age, _ := strconv.Atoi(fields[1])
+		rec := Record{Name: name, Age: age, Row: i}
+		rec.Normalize()
+		db.Save(rec)
+		total += rec.Age
+	}
+	return total
+}
+`
+
+func TestPipeline_Stages(t *testing.T) {
+	results := run(t, importSrc)
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 result, got %d", len(results))
+	}
+	r := results[0]
+	if r.Function != "Import" || r.Line != 5 || r.Statements != 9 {
+		t.Errorf("Unexpected loop: %s line %d with %d statements", r.Function, r.Line, r.Statements)
+	}
+	if r.Decomposition != "produce(2) → transform(4) → consume(3)" {
+		t.Errorf("Unexpected decomposition %q", r.Decomposition)
+	}
+	if r.LongestStage != 4 || r.ComplexityReductionPercent != 55 {
+		t.Errorf("Expected longest stage 4 and 55%% reduction, got %d and %d%%", r.LongestStage, r.ComplexityReductionPercent)
+	}
+
+	produce, transform, consume := r.Stages[0], r.Stages[1], r.Stages[2]
+	if !slices.Equal(produce.Inputs, []string{"line"}) || !slices.Equal(produce.Outputs, []string{"fields"}) {
+		t.Errorf("Unexpected produce stage %v -> %v", produce.Inputs, produce.Outputs)
+	}
+	if !slices.Equal(transform.Inputs, []string{"i", "fields"}) || !slices.Equal(transform.Outputs, []string{"rec"}) {
+		t.Errorf("Unexpected transform stage %v -> %v", transform.Inputs, transform.Outputs)
+	}
+	if !transform.Filter || produce.Filter || consume.Filter {
+		t.Error("Expected only the transform stage to filter")
+	}
+	if transform.StartLine != 8 || transform.EndLine != 13 {
+		t.Errorf("Expected transform stage on lines 8-13, got %d-%d", transform.StartLine, transform.EndLine)
+	}
+	if !slices.Equal(consume.Inputs, []string{"rec"}) || consume.Outputs != nil {
+		t.Errorf("Unexpected consume stage %v -> %v", consume.Inputs, consume.Outputs)
+	}
+}
+
+func TestPipeline_SkipsLoopsThatExit(t *testing.T) {
+	src := `package testpkg
+
+func Find(lines []string) *Record {
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		fields := strings.Split(line, ",")
+		name := strings.ToUpper(fields[0])
+		age, _ := strconv.Atoi(fields[1])
+		rec := &Record{Name: name, Age: age}
+		if rec.Age > 18 {
+			return rec
+		}
+		log.Println(rec)
+	}
+	return nil
+}
+`
+	if results := run(t, src); len(results) != 0 {
+		t.Errorf("Expected loops that return to be skipped, got %d results", len(results))
+	}
+}
+
+func TestPipeline_Options(t *testing.T) {
+	if results := run(t, importSrc, pipeline.WithMinStatements(10)); len(results) != 0 {
+		t.Errorf("Expected no results for a 9 statement loop with min 10, got %d", len(results))
+	}
+	if results := run(t, importSrc, pipeline.WithMinStages(4)); len(results) != 0 {
+		t.Errorf("Expected no results when requiring 4 stages, got %d", len(results))
+	}
+	results := run(t, importSrc, pipeline.WithMaxLive(1))
+	if len(results) != 0 {
+		t.Errorf("Expected the loop not to split into 3 stages with one live value, got %v", results[0].Decomposition)
+	}
+}
+
+func TestPipeline_ShadowedNames(t *testing.T) {
+	src := `package testpkg
+
+func Save(lines []string, sink Sink) {
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		fields := strings.Split(trimmed, ",")
+		age, err := strconv.Atoi(fields[1])
+		if err != nil {
+			continue
+		}
+		rec := Record{Name: fields[0], Age: age}
+		if err := sink.Save(rec); err != nil {
+			log.Println(err)
+		}
+	}
+}
+`
+	results := run(t, src, pipeline.WithMinStatements(5))
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 result, got %d", len(results))
+	}
+	consume := results[0].Stages[len(results[0].Stages)-1]
+	if !slices.Equal(consume.Inputs, []string{"fields", "age"}) {
+		t.Errorf("Expected the err declared by the if statement to shadow the earlier one, got inputs %v", consume.Inputs)
+	}
+}
@@ -0,0 +1,140 @@
+// Package positionallit provides a go/analysis analyzer that detects struct
+// composite literals listing their values without field names, which break
+// silently when fields are added or reordered.
+package positionallit
+
+import (
+	"go/ast"
+	"go/types"
+	"slices"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+// Result is the typed result returned for MCP consumption.
+type Result struct {
+	File   string   `json:"file"`
+	Line   int      `json:"line"`
+	Column int      `json:"column"`
+	Type   string   `json:"type"`   // The struct type, qualified by package name outside its package; "struct{...}" for anonymous ones
+	Fields []string `json:"fields"` // The names the values are keyed with
+}
+
+type config struct {
+	structs []string
+}
+
+// Option configures the analyzer.
+type Option func(*config)
+
+// WithStructs limits the analyzer to literals of the named struct types,
+// each given as Name, pkg.Name or import/path.Name.
+func WithStructs(names ...string) Option {
+	return func(c *config) { c.structs = names }
+}
+
+const doc = "detects struct literals without field names, which adding or reordering fields breaks"
+
+var Analyzer = NewAnalyzer()
+
+// NewAnalyzer creates a configured analyzer. It needs type information to
+// know the fields of a literal's type, so it reports nothing in packages
+// that aren't type-checked.
+func NewAnalyzer(opts ...Option) *analysis.Analyzer {
+	var cfg config
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return &analysis.Analyzer{
+		Name:     "positionallit",
+		Doc:      doc,
+		Run:      makeRun(cfg),
+		Requires: []*analysis.Analyzer{inspect.Analyzer},
+	}
+}
+
+func makeRun(cfg config) func(*analysis.Pass) (any, error) {
+	return func(pass *analysis.Pass) (any, error) {
+		insp := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+		var results []*Result
+
+		for cur := range insp.Root().Preorder((*ast.CompositeLit)(nil)) {
+			lit := cur.Node().(*ast.CompositeLit)
+			if len(lit.Elts) == 0 {
+				continue
+			}
+			if _, keyed := lit.Elts[0].(*ast.KeyValueExpr); keyed {
+				continue
+			}
+			t := pass.TypesInfo.TypeOf(lit)
+			if t == nil {
+				continue
+			}
+			if ptr, ok := t.Underlying().(*types.Pointer); ok {
+				t = ptr.Elem() // An elided &T in a slice or map literal
+			}
+			st, ok := t.Underlying().(*types.Struct)
+			if !ok || st.NumFields() != len(lit.Elts) || !cfg.matches(t) {
+				continue
+			}
+
+			name := "struct{...}"
+			if named, ok := types.Unalias(t).(*types.Named); ok {
+				name = types.TypeString(named, func(p *types.Package) string {
+					if p.Path() == pass.Pkg.Path() {
+						return ""
+					}
+					return p.Name()
+				})
+			}
+
+			fields := make([]string, st.NumFields())
+			edits := make([]analysis.TextEdit, len(lit.Elts))
+			for i, elt := range lit.Elts {
+				fields[i] = st.Field(i).Name()
+				edits[i] = analysis.TextEdit{Pos: elt.Pos(), End: elt.Pos(), NewText: []byte(fields[i] + ": ")}
+			}
+
+			p := pass.Fset.Position(lit.Pos())
+			pass.Report(analysis.Diagnostic{
+				Pos:     lit.Pos(),
+				End:     lit.End(),
+				Message: name + " literal should name its fields",
+				SuggestedFixes: []analysis.SuggestedFix{{
+					Message:   "Name the fields of the " + name + " literal",
+					TextEdits: edits,
+				}},
+			})
+			results = append(results, &Result{
+				File:   p.Filename,
+				Line:   p.Line,
+				Column: p.Column,
+				Type:   name,
+				Fields: fields,
+			})
+		}
+		return results, nil
+	}
+}
+
+// matches reports whether literals of t are to be reported.
+func (cfg config) matches(t types.Type) bool {
+	if len(cfg.structs) == 0 {
+		return true
+	}
+	named, ok := types.Unalias(t).(*types.Named)
+	if !ok || named.Obj().Pkg() == nil {
+		return false
+	}
+	obj := named.Obj()
+	return slices.ContainsFunc(cfg.structs, func(s string) bool {
+		i := strings.LastIndex(s, ".")
+		if s[i+1:] != obj.Name() {
+			return false
+		}
+		return i < 0 || s[:i] == obj.Pkg().Name() || s[:i] == obj.Pkg().Path()
+	})
+}
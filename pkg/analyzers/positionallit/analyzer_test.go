@@ -0,0 +1,102 @@
+package positionallit_test
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"slices"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/analyzers"
+	"github.com/mamaar/gorefactor/pkg/analyzers/positionallit"
+	wstypes "github.com/mamaar/gorefactor/pkg/types"
+)
+
+func createTestWorkspace(t *testing.T, src string) *wstypes.Workspace {
+	t.Helper()
+	fileSet := token.NewFileSet()
+
+	astFile, err := parser.ParseFile(fileSet, "testpkg.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("Failed to parse test source: %v", err)
+	}
+	info := &types.Info{Types: map[ast.Expr]types.TypeAndValue{}, Defs: map[*ast.Ident]types.Object{}, Uses: map[*ast.Ident]types.Object{}}
+	typesPkg, err := new(types.Config).Check("test/testpkg", fileSet, []*ast.File{astFile}, info)
+	if err != nil {
+		t.Fatalf("Failed to type-check test source: %v", err)
+	}
+
+	file := &wstypes.File{
+		Path:            "testpkg.go",
+		AST:             astFile,
+		OriginalContent: []byte(src),
+	}
+	pkg := &wstypes.Package{
+		Name:      "testpkg",
+		Path:      "test/testpkg",
+		Files:     map[string]*wstypes.File{"testpkg.go": file},
+		TypesPkg:  typesPkg,
+		TypesInfo: info,
+	}
+	file.Package = pkg
+
+	return &wstypes.Workspace{
+		Packages: map[string]*wstypes.Package{"test/testpkg": pkg},
+		FileSet:  fileSet,
+	}
+}
+
+const src = `package testpkg
+
+type Point struct{ X, Y int }
+
+type Line struct {
+	From, To Point
+}
+
+var (
+	origin = Point{0, 0}
+	keyed  = Point{X: 1}
+	line   = Line{Point{1, 2}, Point{X: 3, Y: 4}}
+	path   = []*Point{{5, 6}}
+	pair   = struct{ A, B string }{"a", "b"}
+	short  = [2]int{1, 2}
+)
+`
+
+func TestPositionalLit(t *testing.T) {
+	ws := createTestWorkspace(t, src)
+	rr, err := analyzers.Run(ws, positionallit.Analyzer, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, _ := rr.Result.([]*positionallit.Result)
+
+	var found []string
+	for _, r := range results {
+		found = append(found, r.Type)
+	}
+	if want := []string{"Point", "Line", "Point", "Point", "struct{...}"}; !slices.Equal(found, want) {
+		t.Errorf("Reported %v, want %v", found, want)
+	}
+	if len(results) > 1 && !slices.Equal(results[1].Fields, []string{"From", "To"}) {
+		t.Errorf("Fields of the Line literal = %v, want [From To]", results[1].Fields)
+	}
+
+	changes := analyzers.DiagnosticsToChanges(ws.FileSet, rr.Diagnostics)
+	if len(changes) != 10 {
+		t.Errorf("Expected a key for each of 10 values, got %d changes", len(changes))
+	}
+}
+
+func TestPositionalLit_Structs(t *testing.T) {
+	ws := createTestWorkspace(t, src)
+	rr, err := analyzers.Run(ws, positionallit.NewAnalyzer(positionallit.WithStructs("testpkg.Line")), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results, _ := rr.Result.([]*positionallit.Result); len(results) != 1 || results[0].Type != "Line" {
+		t.Errorf("Expected only the Line literal, got %+v", results)
+	}
+}
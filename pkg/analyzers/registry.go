@@ -0,0 +1,95 @@
+package analyzers
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/go/analysis"
+
+	wstypes "github.com/mamaar/gorefactor/pkg/types"
+)
+
+// Registration describes an analyzer registered from outside gorefactor,
+// typically a standard go/analysis pass. The MCP server exposes each one
+// as a detect_<Name> tool, plus a fix_<Name> tool when Fixable is set.
+type Registration struct {
+	Analyzer *analysis.Analyzer
+
+	// Name is used in tool names and findings; it defaults to Analyzer.Name.
+	Name string
+
+	// Description is shown in the tool listing; it defaults to the first
+	// paragraph of Analyzer.Doc.
+	Description string
+
+	// Fixable means the analyzer's diagnostics carry suggested fixes that
+	// are safe to apply unattended.
+	Fixable bool
+}
+
+var (
+	registryMu sync.Mutex
+	registry   = make(map[string]Registration)
+
+	validName = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+)
+
+// Register adds an analyzer to the registry. It must be called before the
+// MCP server starts, usually from an init function or main. Registering
+// two analyzers under the same name is an error.
+func Register(r Registration) error {
+	if r.Analyzer == nil {
+		return fmt.Errorf("register analyzer: nil analyzer")
+	}
+	if r.Name == "" {
+		r.Name = r.Analyzer.Name
+	}
+	if !validName.MatchString(r.Name) {
+		return fmt.Errorf("register analyzer %q: name must be lower case letters, digits and underscores", r.Name)
+	}
+	if r.Description == "" {
+		r.Description, _, _ = strings.Cut(strings.TrimSpace(r.Analyzer.Doc), "\n\n")
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := registry[r.Name]; ok {
+		return fmt.Errorf("register analyzer %q: already registered", r.Name)
+	}
+	registry[r.Name] = r
+	return nil
+}
+
+// MustRegister is like Register but panics on error.
+func MustRegister(r Registration) {
+	if err := Register(r); err != nil {
+		panic(err)
+	}
+}
+
+// Registered returns the registered analyzers sorted by name.
+func Registered() []Registration {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	regs := make([]Registration, 0, len(registry))
+	for _, r := range registry {
+		regs = append(regs, r)
+	}
+	slices.SortFunc(regs, func(a, b Registration) int { return strings.Compare(a.Name, b.Name) })
+	return regs
+}
+
+// RunRegistered runs a registered analyzer like Run, turning a panic in the
+// analyzer into an error. Callers should type-check the packages first:
+// standard analyzers assume complete type information.
+func RunRegistered(ws *wstypes.Workspace, r Registration, pkgFilter string) (rr *RunResult, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("analyzer %s panicked: %v", r.Name, p)
+		}
+	}()
+	return Run(ws, r.Analyzer, pkgFilter)
+}
@@ -0,0 +1,88 @@
+package analyzers
+
+import (
+	"go/token"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+
+	wstypes "github.com/mamaar/gorefactor/pkg/types"
+)
+
+func TestRegister(t *testing.T) {
+	a := &analysis.Analyzer{
+		Name: "registrytest",
+		Doc:  "registrytest reports nothing.\n\nIt exists for tests.",
+		Run:  func(*analysis.Pass) (any, error) { return nil, nil },
+	}
+	if err := Register(Registration{Analyzer: a}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Register(Registration{Analyzer: a}); err == nil || !strings.Contains(err.Error(), "already registered") {
+		t.Errorf("expected a duplicate registration error, got %v", err)
+	}
+	if err := Register(Registration{Analyzer: a, Name: "Bad-Name"}); err == nil {
+		t.Error("expected an error for an invalid name")
+	}
+	if err := Register(Registration{}); err == nil {
+		t.Error("expected an error for a nil analyzer")
+	}
+
+	var got *Registration
+	for _, r := range Registered() {
+		if r.Name == "registrytest" {
+			got = &r
+		}
+	}
+	if got == nil {
+		t.Fatal("registrytest is not listed")
+	}
+	if got.Description != "registrytest reports nothing." {
+		t.Errorf("Description = %q, want the first paragraph of Doc", got.Description)
+	}
+}
+
+func TestRunRegistered_Panic(t *testing.T) {
+	fset := token.NewFileSet()
+	ws := &wstypes.Workspace{FileSet: fset, Packages: map[string]*wstypes.Package{
+		"/ws/p": {Path: "/ws/p", ImportPath: "example.com/p", Name: "p", Files: map[string]*wstypes.File{}},
+	}}
+	r := Registration{Name: "panics", Analyzer: &analysis.Analyzer{
+		Name: "panics",
+		Doc:  "panics panics.",
+		Run:  func(*analysis.Pass) (any, error) { panic("boom") },
+	}}
+	if _, err := RunRegistered(ws, r, ""); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("expected the panic as an error, got %v", err)
+	}
+}
+
+func TestDiagnosticsToChanges_Overlap(t *testing.T) {
+	fset := token.NewFileSet()
+	f := fset.AddFile("/ws/p/p.go", -1, 100)
+	fix := func(start, end int, text string) analysis.Diagnostic {
+		edit := analysis.TextEdit{Pos: f.Pos(start), NewText: []byte(text)}
+		if end >= 0 {
+			edit.End = f.Pos(end)
+		}
+		return analysis.Diagnostic{SuggestedFixes: []analysis.SuggestedFix{{TextEdits: []analysis.TextEdit{edit}}}}
+	}
+	changes := DiagnosticsToChanges(fset, []analysis.Diagnostic{
+		fix(10, 20, "a"),
+		fix(15, 25, "overlaps"),
+		fix(30, -1, "insert"),
+		fix(30, -1, "same insertion point"),
+		fix(20, 30, "adjacent"),
+	})
+	var texts []string
+	for _, c := range changes {
+		texts = append(texts, c.NewText)
+	}
+	if got := strings.Join(texts, ","); got != "a,insert,adjacent" {
+		t.Errorf("kept changes %s, want a,insert,adjacent", got)
+	}
+	if changes[1].Start != 30 || changes[1].End != 30 {
+		t.Errorf("insertion spans %d-%d, want 30-30", changes[1].Start, changes[1].End)
+	}
+}
@@ -2,12 +2,19 @@ package analyzers
 
 import (
 	"go/ast"
+	"go/parser"
 	"go/token"
 	"go/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"slices"
 
 	"golang.org/x/tools/go/analysis"
 	"golang.org/x/tools/go/ast/inspector"
 
+	wsanalysis "github.com/mamaar/gorefactor/pkg/analysis"
 	"github.com/mamaar/gorefactor/pkg/analyzers/filedata"
 	wstypes "github.com/mamaar/gorefactor/pkg/types"
 )
@@ -22,39 +29,149 @@ type RunResult struct {
 // plus any diagnostics reported. If pkgFilter is non-empty, only the matching
 // package is analysed; otherwise all packages are analysed.
 func Run(ws *wstypes.Workspace, a *analysis.Analyzer, pkgFilter string) (*RunResult, error) {
+	results, err := RunAll(ws, []*analysis.Analyzer{a}, pkgFilter)
+	if err != nil {
+		return nil, err
+	}
+	return results[0], nil
+}
+
+// RunAll executes several analyzers against workspace packages, returning one
+// RunResult per analyzer in the order given. Per-package work shared between
+// analyzers — file filtering, file content, the inspector and the results of
+// required analyzers — is computed once and reused, so the ASTs of each file
+// are traversed a single time regardless of how many analyzers are enabled.
+func RunAll(ws *wstypes.Workspace, as []*analysis.Analyzer, pkgFilter string) ([]*RunResult, error) {
+	return runAll(ws, as, pkgFilter, false)
+}
+
+// RunTests executes an analyzer against the _test.go files of workspace
+// packages instead of their other files. Test files are not type-checked,
+// so the analyzer has to work from syntax alone.
+func RunTests(ws *wstypes.Workspace, a *analysis.Analyzer, pkgFilter string) (*RunResult, error) {
+	results, err := runAll(ws, []*analysis.Analyzer{a}, pkgFilter, true)
+	if err != nil {
+		return nil, err
+	}
+	return results[0], nil
+}
+
+// SelectPackages returns the workspace packages a run with pkgFilter
+// analyses: the matching package, or all of them when pkgFilter is empty.
+func SelectPackages(ws *wstypes.Workspace, pkgFilter string) []*wstypes.Package {
 	var packages []*wstypes.Package
 	if pkgFilter != "" {
 		resolved := wstypes.ResolvePackagePath(ws, pkgFilter)
-		pkg, ok := ws.Packages[resolved]
-		if !ok {
-			return &RunResult{}, nil
+		if pkg, ok := ws.Packages[resolved]; ok {
+			packages = []*wstypes.Package{pkg}
 		}
-		packages = []*wstypes.Package{pkg}
 	} else {
 		for _, pkg := range ws.Packages {
 			packages = append(packages, pkg)
 		}
 	}
+	return packages
+}
 
-	combined := &RunResult{}
+func runAll(ws *wstypes.Workspace, as []*analysis.Analyzer, pkgFilter string, tests bool) ([]*RunResult, error) {
+	packages := SelectPackages(ws, pkgFilter)
+
+	combined := make([]*RunResult, len(as))
+	for i := range combined {
+		combined[i] = &RunResult{}
+	}
 
 	for _, pkg := range packages {
-		rr, err := RunPackage(ws, a, pkg)
-		if err != nil {
-			return nil, err
+		pc := newPackageContext(ws, pkg, tests)
+		for i, a := range as {
+			rr, err := pc.run(a)
+			if err != nil {
+				return nil, err
+			}
+			combined[i].Diagnostics = append(combined[i].Diagnostics, rr.Diagnostics...)
+			combined[i].Result = mergeResults(combined[i].Result, rr.Result)
 		}
-		combined.Diagnostics = append(combined.Diagnostics, rr.Diagnostics...)
-		combined.Result = rr.Result
 	}
 
 	return combined, nil
 }
 
+// mergeResults combines the per-package results of an analyzer. Slice
+// results (the common case of one entry per finding) are concatenated;
+// anything else keeps the last package's result.
+func mergeResults(acc, next any) any {
+	if acc == nil {
+		return next
+	}
+	if next == nil {
+		return acc
+	}
+	a, n := reflect.ValueOf(acc), reflect.ValueOf(next)
+	if a.Kind() != reflect.Slice || a.Type() != n.Type() {
+		return next
+	}
+	return reflect.AppendSlice(a, n).Interface()
+}
+
 // RunPackage executes an analyzer against a single workspace package.
 func RunPackage(ws *wstypes.Workspace, a *analysis.Analyzer, pkg *wstypes.Package) (*RunResult, error) {
+	return newPackageContext(ws, pkg, false).run(a)
+}
+
+// packageContext holds the per-package state shared by every analyzer run
+// against the same package.
+type packageContext struct {
+	tests     bool // Analyzing the test files, which have no type information
+	ws        *wstypes.Workspace
+	pkg       *wstypes.Package
+	files     []*ast.File
+	fileData  *filedata.Data
+	inspector *inspector.Inspector
+	required  map[*analysis.Analyzer]any
+	ignores   Ignores // Findings suppressed by //gorefactor:ignore comments
+}
+
+func newPackageContext(ws *wstypes.Workspace, pkg *wstypes.Package, tests bool) *packageContext {
+	pkgFiles := pkg.Files
+	if tests {
+		pkgFiles = pkg.TestFiles
+	}
+
+	// Generated files are excluded: findings there are noise and any
+	// suggested fix would be overwritten by the next regeneration.
+	generated := wsanalysis.NewGeneratedFileDetector(ws.RootPath)
+	files := make([]*ast.File, 0, len(pkgFiles))
+	for _, f := range pkgFiles {
+		if generated.IsGeneratedFile(f) {
+			continue
+		}
+		files = append(files, f.AST)
+	}
+
+	// Build file content map for filedata.
+	fd := &filedata.Data{Content: make(map[string][]byte)}
+	for _, f := range pkgFiles {
+		fd.Content[f.Path] = f.OriginalContent
+	}
+
+	return &packageContext{
+		tests:    tests,
+		ws:       ws,
+		pkg:      pkg,
+		files:    files,
+		fileData: fd,
+		required: make(map[*analysis.Analyzer]any),
+		ignores:  NewIgnores(ws.FileSet, files),
+	}
+}
+
+// run executes a top-level analyzer, collecting its diagnostics. Findings
+// suppressed by ignore directives naming the analyzer are dropped from both
+// its diagnostics and its result.
+func (pc *packageContext) run(a *analysis.Analyzer) (*RunResult, error) {
 	var diags []analysis.Diagnostic
 
-	pass, err := buildPass(ws, pkg, a, func(d analysis.Diagnostic) {
+	pass, err := pc.buildPass(a, func(d analysis.Diagnostic) {
 		diags = append(diags, d)
 	})
 	if err != nil {
@@ -66,67 +183,95 @@ func RunPackage(ws *wstypes.Workspace, a *analysis.Analyzer, pkg *wstypes.Packag
 		return nil, err
 	}
 
-	return &RunResult{Result: res, Diagnostics: diags}, nil
+	return &RunResult{
+		Result:      pc.ignores.filterResult(a.Name, res),
+		Diagnostics: pc.ignores.filterDiagnostics(pc.ws.FileSet, a.Name, diags),
+	}, nil
 }
 
-func buildPass(ws *wstypes.Workspace, pkg *wstypes.Package, a *analysis.Analyzer, report func(analysis.Diagnostic)) (*analysis.Pass, error) {
-	files := make([]*ast.File, 0, len(pkg.Files))
-	for _, f := range pkg.Files {
-		files = append(files, f.AST)
-	}
-
-	typesPkg := pkg.TypesPkg
-	if typesPkg == nil {
-		typesPkg = types.NewPackage(pkg.ImportPath, pkg.Name)
+func (pc *packageContext) buildPass(a *analysis.Analyzer, report func(analysis.Diagnostic)) (*analysis.Pass, error) {
+	typesPkg := pc.pkg.TypesPkg
+	if typesPkg == nil || pc.tests {
+		typesPkg = types.NewPackage(pc.pkg.ImportPath, pc.pkg.Name)
 	}
 
-	typesInfo := pkg.TypesInfo
-	if typesInfo == nil {
+	typesInfo := pc.pkg.TypesInfo
+	if typesInfo == nil || pc.tests {
 		typesInfo = &types.Info{}
 	}
 
 	pass := &analysis.Pass{
-		Analyzer:  a,
-		Fset:      ws.FileSet,
-		Files:     files,
-		Pkg:       typesPkg,
-		TypesInfo: typesInfo,
-		Report:    report,
-		ResultOf:  make(map[*analysis.Analyzer]any),
+		Analyzer:   a,
+		Fset:       pc.ws.FileSet,
+		Files:      pc.files,
+		Pkg:        typesPkg,
+		TypesInfo:  typesInfo,
+		TypesSizes: types.SizesFor("gc", runtime.GOARCH),
+		Report:     report,
+		ResultOf:   make(map[*analysis.Analyzer]any),
+		ReadFile:   pc.readFile,
 	}
+	// Facts are not carried between packages: standard analyzers that
+	// export them still run, but see no facts from their dependencies.
+	pass.ImportObjectFact = func(types.Object, analysis.Fact) bool { return false }
+	pass.ImportPackageFact = func(*types.Package, analysis.Fact) bool { return false }
+	pass.ExportObjectFact = func(types.Object, analysis.Fact) {}
+	pass.ExportPackageFact = func(analysis.Fact) {}
+	pass.AllObjectFacts = func() []analysis.ObjectFact { return nil }
+	pass.AllPackageFacts = func() []analysis.PackageFact { return nil }
 
-	// Build file content map for filedata.
-	fd := &filedata.Data{Content: make(map[string][]byte)}
-	for _, f := range pkg.Files {
-		fd.Content[f.Path] = f.OriginalContent
-	}
-
-	// Pre-compute results for required analyzers.
+	// Resolve results for required analyzers, computing each at most once per package.
 	for _, req := range a.Requires {
-		switch {
-		case req == filedata.Analyzer:
-			pass.ResultOf[req] = fd
-		case req.Name == "inspect":
-			pass.ResultOf[req] = inspector.New(files)
-		default:
-			// Run required analyzer recursively.
-			reqPass, err := buildPass(ws, pkg, req, func(analysis.Diagnostic) {})
-			if err != nil {
-				return nil, err
-			}
-			res, err := req.Run(reqPass)
-			if err != nil {
-				return nil, err
-			}
-			pass.ResultOf[req] = res
+		res, err := pc.requiredResult(req)
+		if err != nil {
+			return nil, err
 		}
+		pass.ResultOf[req] = res
 	}
 
 	return pass, nil
 }
 
+// readFile serves the package's files from memory, so analyzers see the
+// workspace's view of them.
+func (pc *packageContext) readFile(filename string) ([]byte, error) {
+	if content, ok := pc.fileData.Content[filename]; ok {
+		return content, nil
+	}
+	return os.ReadFile(filename)
+}
+
+func (pc *packageContext) requiredResult(req *analysis.Analyzer) (any, error) {
+	switch {
+	case req == filedata.Analyzer:
+		return pc.fileData, nil
+	case req.Name == "inspect":
+		if pc.inspector == nil {
+			pc.inspector = inspector.New(pc.files)
+		}
+		return pc.inspector, nil
+	}
+
+	if res, ok := pc.required[req]; ok {
+		return res, nil
+	}
+	// Run required analyzer recursively; its diagnostics are discarded.
+	reqPass, err := pc.buildPass(req, func(analysis.Diagnostic) {})
+	if err != nil {
+		return nil, err
+	}
+	res, err := req.Run(reqPass)
+	if err != nil {
+		return nil, err
+	}
+	pc.required[req] = res
+	return res, nil
+}
+
 // DiagnosticsToChanges converts diagnostics with SuggestedFixes into types.Change slices.
-// It picks the first SuggestedFix from each diagnostic (if any).
+// It picks the first SuggestedFix from each diagnostic (if any). A fix whose
+// edits overlap those of an earlier fix is dropped, since both can't be
+// applied; running the analyzer again after the plan is applied picks it up.
 func DiagnosticsToChanges(fset *token.FileSet, diags []analysis.Diagnostic) []wstypes.Change {
 	var changes []wstypes.Change
 	for _, d := range diags {
@@ -134,10 +279,15 @@ func DiagnosticsToChanges(fset *token.FileSet, diags []analysis.Diagnostic) []ws
 			continue
 		}
 		fix := d.SuggestedFixes[0]
+		var fixChanges []wstypes.Change
 		for _, edit := range fix.TextEdits {
+			end := edit.End
+			if !end.IsValid() {
+				end = edit.Pos // An insertion
+			}
 			startPos := fset.Position(edit.Pos)
-			endPos := fset.Position(edit.End)
-			changes = append(changes, wstypes.Change{
+			endPos := fset.Position(end)
+			fixChanges = append(fixChanges, wstypes.Change{
 				File:        startPos.Filename,
 				Start:       startPos.Offset,
 				End:         endPos.Offset,
@@ -145,10 +295,71 @@ func DiagnosticsToChanges(fset *token.FileSet, diags []analysis.Diagnostic) []ws
 				Description: fix.Message,
 			})
 		}
+		if !slices.ContainsFunc(fixChanges, func(c wstypes.Change) bool { return overlapsAny(c, changes) }) {
+			changes = append(changes, fixChanges...)
+		}
 	}
 	return changes
 }
 
+// CheckChanges marks the changes of each file Checked when the file parses
+// with all of them applied, so that fixes whose edits aren't Go on their
+// own, such as a rewritten loop header, pass plan validation.
+func CheckChanges(ws *wstypes.Workspace, changes []wstypes.Change) {
+	byFile := make(map[string][]int)
+	for i, c := range changes {
+		byFile[c.File] = append(byFile[c.File], i)
+	}
+	for file, idx := range byFile {
+		pkg := ws.Packages[filepath.Dir(file)]
+		if pkg == nil {
+			continue
+		}
+		f := pkg.Files[filepath.Base(file)]
+		if f == nil {
+			f = pkg.TestFiles[filepath.Base(file)]
+		}
+		if f == nil || f.OriginalContent == nil {
+			continue
+		}
+		// Apply from the end so earlier offsets stay valid.
+		slices.SortFunc(idx, func(a, b int) int { return changes[b].Start - changes[a].Start })
+		src := string(f.OriginalContent)
+		for _, i := range idx {
+			c := changes[i]
+			if c.Start < 0 || c.End > len(src) || c.Start > c.End {
+				src = ""
+				break
+			}
+			src = src[:c.Start] + c.NewText + src[c.End:]
+		}
+		if src == "" {
+			continue
+		}
+		if _, err := parser.ParseFile(token.NewFileSet(), file, src, parser.SkipObjectResolution); err != nil {
+			continue
+		}
+		for _, i := range idx {
+			changes[i].Checked = true
+		}
+	}
+}
+
+// overlapsAny reports whether c shares any bytes with one of changes.
+// Insertions at the same offset count as overlapping, since their order
+// would be ambiguous.
+func overlapsAny(c wstypes.Change, changes []wstypes.Change) bool {
+	for _, o := range changes {
+		if o.File != c.File {
+			continue
+		}
+		if c.Start < o.End && o.Start < c.End || c.Start == o.Start {
+			return true
+		}
+	}
+	return false
+}
+
 // ChangesToPlan creates a RefactoringPlan from a set of changes.
 func ChangesToPlan(changes []wstypes.Change) *wstypes.RefactoringPlan {
 	affectedSet := make(map[string]bool)
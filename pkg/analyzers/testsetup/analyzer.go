@@ -0,0 +1,243 @@
+// Package testsetup detects setup code repeated at the start of several
+// tests. It analyzes _test.go files; run it with analyzers.RunTests.
+package testsetup
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"slices"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// DefaultMinStatements is the number of leading statements tests must share
+// to be reported.
+const DefaultMinStatements = 2
+
+// Result is the typed result returned for MCP consumption.
+type Result struct {
+	File       string   `json:"file"`
+	Line       int      `json:"line"`
+	Package    string   `json:"package"` // Package clause of the test files
+	Tests      []string `json:"tests"`
+	Statements int      `json:"statements"` // Number of shared leading statements
+	Code       string   `json:"code"`
+	Teardown   bool     `json:"teardown,omitempty"` // The setup defers or registers cleanup
+}
+
+// Group is a run of leading statements repeated at the start of several
+// tests of one package clause. Statements match when they print the same,
+// so formatting and comments don't matter.
+type Group struct {
+	Tests      []*ast.FuncDecl
+	Files      []*ast.File // File declaring each test
+	Statements int
+}
+
+// Setup returns the shared statements as they appear in the i-th test.
+func (g *Group) Setup(i int) []ast.Stmt {
+	return g.Tests[i].Body.List[:g.Statements]
+}
+
+type config struct {
+	minStatements int
+}
+
+// Option configures the analyzer.
+type Option func(*config)
+
+// WithMinStatements sets the number of leading statements tests must share.
+func WithMinStatements(n int) Option {
+	return func(c *config) { c.minStatements = n }
+}
+
+var Analyzer = &analysis.Analyzer{
+	Name: "testsetup",
+	Doc:  "detects setup statements repeated at the start of several tests",
+	Run:  makeRun(config{minStatements: DefaultMinStatements}),
+}
+
+// NewAnalyzer creates a configured test setup analyzer.
+func NewAnalyzer(opts ...Option) *analysis.Analyzer {
+	cfg := config{minStatements: DefaultMinStatements}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return &analysis.Analyzer{
+		Name: "testsetup",
+		Doc:  "detects setup statements repeated at the start of several tests",
+		Run:  makeRun(cfg),
+	}
+}
+
+func makeRun(cfg config) func(*analysis.Pass) (any, error) {
+	return func(pass *analysis.Pass) (any, error) {
+		var results []*Result
+		for _, g := range FindDuplicates(pass.Fset, pass.Files, cfg.minStatements) {
+			setup := g.Setup(0)
+			pos := pass.Fset.Position(setup[0].Pos())
+			r := &Result{
+				File:       pos.Filename,
+				Line:       pos.Line,
+				Package:    g.Files[0].Name.Name,
+				Statements: g.Statements,
+				Code:       printStmts(pass.Fset, setup),
+				Teardown:   HasTeardown(setup),
+			}
+			for _, test := range g.Tests {
+				r.Tests = append(r.Tests, test.Name.Name)
+			}
+			results = append(results, r)
+			pass.Report(analysis.Diagnostic{
+				Pos:     setup[0].Pos(),
+				End:     setup[len(setup)-1].End(),
+				Message: fmt.Sprintf("%d tests repeat the same %d setup statements (%s); extract them into a helper", len(g.Tests), g.Statements, strings.Join(r.Tests, ", ")),
+			})
+		}
+		return results, nil
+	}
+}
+
+// FindDuplicates groups the tests of files by their first minStatements
+// statements and returns, for each group of two or more, the longest run of
+// leading statements they all share. Statements that return from the test
+// or jump end the run, since they can't move into a helper.
+func FindDuplicates(fset *token.FileSet, files []*ast.File, minStatements int) []*Group {
+	if minStatements < 1 {
+		minStatements = DefaultMinStatements
+	}
+	type test struct {
+		decl  *ast.FuncDecl
+		file  *ast.File
+		stmts []string
+	}
+	buckets := make(map[string][]*test)
+	var keys []string
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			fd, ok := decl.(*ast.FuncDecl)
+			if !ok || !IsTest(fd) {
+				continue
+			}
+			t := &test{decl: fd, file: file}
+			for _, stmt := range fd.Body.List {
+				if !movable(stmt) {
+					break
+				}
+				t.stmts = append(t.stmts, printStmts(fset, []ast.Stmt{stmt}))
+			}
+			if len(t.stmts) < minStatements {
+				continue
+			}
+			key := file.Name.Name + "\x00" + strings.Join(t.stmts[:minStatements], "\x00")
+			if buckets[key] == nil {
+				keys = append(keys, key)
+			}
+			buckets[key] = append(buckets[key], t)
+		}
+	}
+
+	var groups []*Group
+	for _, key := range keys {
+		tests := buckets[key]
+		if len(tests) < 2 {
+			continue
+		}
+		n := len(tests[0].stmts)
+		for _, t := range tests[1:] {
+			n = min(n, len(t.stmts))
+			for i := minStatements; i < n; i++ {
+				if t.stmts[i] != tests[0].stmts[i] {
+					n = i
+					break
+				}
+			}
+		}
+		g := &Group{Statements: n}
+		for _, t := range tests {
+			g.Tests = append(g.Tests, t.decl)
+			g.Files = append(g.Files, t.file)
+		}
+		groups = append(groups, g)
+	}
+	slices.SortFunc(groups, func(a, b *Group) int {
+		return strings.Compare(fset.Position(a.Tests[0].Pos()).String(), fset.Position(b.Tests[0].Pos()).String())
+	})
+	return groups
+}
+
+// IsTest reports whether fd is a test function: TestXxx(t *testing.T),
+// other than TestMain.
+func IsTest(fd *ast.FuncDecl) bool {
+	if fd.Recv != nil || fd.Body == nil || !strings.HasPrefix(fd.Name.Name, "Test") || fd.Name.Name == "TestMain" {
+		return false
+	}
+	params := fd.Type.Params.List
+	if len(params) != 1 || len(params[0].Names) != 1 {
+		return false
+	}
+	star, ok := params[0].Type.(*ast.StarExpr)
+	if !ok {
+		return false
+	}
+	sel, ok := star.X.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	pkg, ok := sel.X.(*ast.Ident)
+	return ok && pkg.Name == "testing" && sel.Sel.Name == "T"
+}
+
+// HasTeardown reports whether stmts defer work or register cleanup with
+// t.Cleanup.
+func HasTeardown(stmts []ast.Stmt) bool {
+	for _, stmt := range stmts {
+		switch s := stmt.(type) {
+		case *ast.DeferStmt:
+			return true
+		case *ast.ExprStmt:
+			if call, ok := s.X.(*ast.CallExpr); ok {
+				if sel, ok := call.Fun.(*ast.SelectorExpr); ok && sel.Sel.Name == "Cleanup" {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// movable reports whether stmt behaves the same inside a helper: it must
+// not return from the test or jump to a label.
+func movable(stmt ast.Stmt) bool {
+	ok := true
+	ast.Inspect(stmt, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.ReturnStmt, *ast.LabeledStmt:
+			ok = false
+		case *ast.BranchStmt:
+			if n.Tok == token.GOTO || n.Label != nil {
+				ok = false
+			}
+		}
+		return ok
+	})
+	return ok
+}
+
+func printStmts(fset *token.FileSet, stmts []ast.Stmt) string {
+	var lines []string
+	for _, stmt := range stmts {
+		var buf bytes.Buffer
+		if err := format.Node(&buf, fset, stmt); err != nil {
+			return ""
+		}
+		lines = append(lines, buf.String())
+	}
+	return strings.Join(lines, "\n")
+}
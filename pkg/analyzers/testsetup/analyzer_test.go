@@ -0,0 +1,154 @@
+package testsetup_test
+
+import (
+	"go/parser"
+	"go/token"
+	"slices"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/analyzers"
+	"github.com/mamaar/gorefactor/pkg/analyzers/testsetup"
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+func createTestWorkspace(t *testing.T, src string) *types.Workspace {
+	t.Helper()
+	fileSet := token.NewFileSet()
+
+	astFile, err := parser.ParseFile(fileSet, "testpkg_test.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("Failed to parse test source: %v", err)
+	}
+
+	file := &types.File{
+		Path:            "testpkg_test.go",
+		AST:             astFile,
+		OriginalContent: []byte(src),
+	}
+
+	pkg := &types.Package{
+		Name:      "testpkg",
+		Path:      "test/testpkg",
+		Files:     map[string]*types.File{},
+		TestFiles: map[string]*types.File{"testpkg_test.go": file},
+	}
+	file.Package = pkg
+
+	return &types.Workspace{
+		Packages: map[string]*types.Package{"test/testpkg": pkg},
+		FileSet:  fileSet,
+	}
+}
+
+func run(t *testing.T, src string, opts ...testsetup.Option) []*testsetup.Result {
+	t.Helper()
+	ws := createTestWorkspace(t, src)
+	rr, err := analyzers.RunTests(ws, testsetup.NewAnalyzer(opts...), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, ok := rr.Result.([]*testsetup.Result)
+	if !ok && rr.Result != nil {
+		t.Fatalf("Expected []*testsetup.Result, got %T", rr.Result)
+	}
+	if len(rr.Diagnostics) != len(results) {
+		t.Errorf("Expected one diagnostic per result, got %d for %d", len(rr.Diagnostics), len(results))
+	}
+	return results
+}
+
+func TestTestSetup_SharedPrefix(t *testing.T) {
+	src := `package testpkg
+
+import (
+	"os"
+	"testing"
+)
+
+func TestA(t *testing.T) {
+	dir := t.TempDir()
+	f, err := os.Create(dir + "/f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	f.WriteString("a")
+}
+
+func TestB(t *testing.T) {
+	dir := t.TempDir()
+	// Formatting and comments don't matter.
+	f, err := os.Create(dir+"/f")
+	if err != nil { t.Fatal(err) }
+	defer f.Close()
+	f.WriteString("b")
+}
+
+func TestC(t *testing.T) {
+	dir := t.TempDir()
+	_ = dir
+}
+`
+	results := run(t, src)
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 group, got %d", len(results))
+	}
+	r := results[0]
+	if !slices.Equal(r.Tests, []string{"TestA", "TestB"}) {
+		t.Errorf("Expected TestA and TestB, got %v", r.Tests)
+	}
+	if r.Statements != 4 || !r.Teardown {
+		t.Errorf("Expected 4 statements with teardown, got %d (teardown=%v)", r.Statements, r.Teardown)
+	}
+}
+
+func TestTestSetup_StopsAtReturn(t *testing.T) {
+	src := `package testpkg
+
+import "testing"
+
+func TestA(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	x := 1
+	_ = x
+}
+
+func TestB(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	x := 1
+	_ = x
+}
+`
+	if results := run(t, src); len(results) != 0 {
+		t.Errorf("Expected no groups, got %+v", results)
+	}
+}
+
+func TestTestSetup_MinStatements(t *testing.T) {
+	src := `package testpkg
+
+import "testing"
+
+func TestA(t *testing.T) {
+	t.Parallel()
+	x := 1
+	_ = x
+}
+
+func TestB(t *testing.T) {
+	t.Parallel()
+	x := 1
+	_ = x + 1
+}
+`
+	if results := run(t, src); len(results) != 1 || results[0].Statements != 2 {
+		t.Errorf("Expected 1 group of 2 statements, got %+v", results)
+	}
+	if results := run(t, src, testsetup.WithMinStatements(3)); len(results) != 0 {
+		t.Errorf("Expected no groups with 3 statements required, got %+v", results)
+	}
+}
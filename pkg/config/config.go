@@ -0,0 +1,372 @@
+// Package config loads the project-level .gorefactor.yaml file, which sets
+// default engine options, analyzer thresholds and project policies that every
+// frontend applies when a workspace is loaded.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/mamaar/gorefactor/pkg/analysis"
+	"github.com/mamaar/gorefactor/pkg/refactor"
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// FileName is the name of the config file looked up at the workspace root.
+const FileName = ".gorefactor.yaml"
+
+// Config is the parsed contents of a .gorefactor.yaml file.
+type Config struct {
+	Engine        EngineConfig   `yaml:"engine"`
+	Analyzers     AnalyzerConfig `yaml:"analyzers"`
+	Exclude       []string       `yaml:"exclude"`        // Directories (relative to the workspace root) to skip when loading
+	ImportAliases []AliasRule    `yaml:"import_aliases"` // Default rules for standardize_imports
+	Layers        LayerConfig    `yaml:"layers"`         // Default layer directories for organize_by_layers and dependency diagrams
+	Format        FormatConfig   `yaml:"format"`         // Formatting applied to every Go file a refactoring writes
+	Hooks         HooksConfig    `yaml:"hooks"`          // Checks run by the pre-commit hook install-hooks writes
+	Build         BuildConfig    `yaml:"build"`          // How the engine checks that refactored code builds
+	Cache         CacheConfig    `yaml:"cache"`          // Where the MCP server keeps the plans it computed
+	Architecture  []ArchRule     `yaml:"architecture"`   // Import constraints check_architecture enforces
+	Dependents    []string       `yaml:"dependents"`     // Repositories importing the workspace, checked by check_dependents
+
+	// Path is the file the config was loaded from, or empty when defaults are used.
+	Path string `yaml:"-"`
+}
+
+// EngineConfig holds engine option overrides. Nil fields keep the frontend's default.
+type EngineConfig struct {
+	SkipCompilation *bool          `yaml:"skip_compilation"`
+	AllowBreaking   *bool          `yaml:"allow_breaking"`
+	BreakingPolicy  []BreakingRule `yaml:"breaking_policy"` // Per-package overrides of allow_breaking
+	AllowGenerated  *bool          `yaml:"allow_generated"`
+	GeneratedDirs   []string       `yaml:"generated_dirs"` // Replace the default generated directories; [] for none
+	MockSuffixes    []string       `yaml:"mock_suffixes"`  // Replace the default generated mock file suffixes; [] for none
+	Journal         *bool          `yaml:"journal"`
+	MinConfidence   string         `yaml:"min_confidence"` // Skip changes less sure than certain, likely or heuristic
+	ChangeBackend   string         `yaml:"change_backend"` // How extract and inline operations compute changes: ast or text
+	Loader          string         `yaml:"loader"`         // How workspaces are loaded: parser or packages
+	VerifyTests     string         `yaml:"verify_tests"`   // Tests run around applying a plan: off, affected or all
+	Scope           []string       `yaml:"scope"`          // Package directories operations are limited to; others are loaded without syntax trees
+	MapSources      *bool          `yaml:"map_sources"`    // Keep file contents in memory-mapped files
+}
+
+// BreakingRule allows or forbids breaking changes in the packages matching
+// Package, a directory relative to the workspace root; "/..." matches the
+// directories below it too.
+type BreakingRule struct {
+	Package string `yaml:"package"`
+	Allow   bool   `yaml:"allow"`
+}
+
+// AnalyzerConfig holds default thresholds for the code smell analyzers.
+type AnalyzerConfig struct {
+	Complexity    ComplexityConfig    `yaml:"complexity"`
+	DeepIfElse    DeepIfElseConfig    `yaml:"deep_if_else"`
+	BooleanBranch BooleanBranchConfig `yaml:"boolean_branch"`
+	EnvBool       EnvBoolConfig       `yaml:"env_bool"`
+	ErrorWrap     ErrorWrapConfig     `yaml:"error_wrap"`
+	Pipeline      PipelineConfig      `yaml:"pipeline"`
+	MagicNumber   MagicNumberConfig   `yaml:"magic_number"`
+}
+
+type ComplexityConfig struct {
+	MinComplexity int `yaml:"min_complexity"`
+}
+
+type DeepIfElseConfig struct {
+	MaxNesting   int `yaml:"max_nesting"`
+	MinElseLines int `yaml:"min_else_lines"`
+}
+
+type BooleanBranchConfig struct {
+	MinBranches int `yaml:"min_branches"`
+}
+
+type EnvBoolConfig struct {
+	MaxDepth int `yaml:"max_depth"`
+}
+
+type ErrorWrapConfig struct {
+	Severity string `yaml:"severity"`
+}
+
+type PipelineConfig struct {
+	MinStatements int `yaml:"min_statements"`
+	MinStages     int `yaml:"min_stages"`
+}
+
+type MagicNumberConfig struct {
+	MinOccurrences int `yaml:"min_occurrences"`
+}
+
+// FormatConfig selects the formatter, import fixing and import grouping the
+// engine applies after editing a file.
+type FormatConfig struct {
+	Formatter     string   `yaml:"formatter"`      // gofmt, gofumpt or command
+	Command       []string `yaml:"command"`        // Formatter for "command", reading stdin and writing stdout
+	Imports       string   `yaml:"imports"`        // Import grouping: grouped, std or none
+	LocalPrefixes []string `yaml:"local_prefixes"` // Import path prefixes grouped last, like goimports -local
+	FixImports    bool     `yaml:"fix_imports"`    // Add missing and remove unused imports, like goimports
+}
+
+// HooksConfig selects what the generated pre-commit hook checks beyond
+// import grouping.
+type HooksConfig struct {
+	Format    bool     `yaml:"format"`    // Also require files to be formatted by format.formatter
+	Analyzers []string `yaml:"analyzers"` // Analyzers run on changed files; empty runs all of them
+}
+
+// BuildConfig selects the build validator run after a plan is applied,
+// unless engine.skip_compilation is set.
+type BuildConfig struct {
+	Validator string   `yaml:"validator"` // go_build (default), go_vet, bazel or none
+	Command   []string `yaml:"command"`   // Build command for bazel, default [bazel, build]; [plz, build] for Please
+}
+
+// CacheConfig selects where the MCP server caches plans and analysis
+// results, so a repeated tool call on an unchanged workspace skips planning
+// or analyzing again.
+type CacheConfig struct {
+	Plans   string `yaml:"plans"`   // memory (default), disk (also .gorefactor/cache/plans, kept across restarts) or off
+	Queries string `yaml:"queries"` // memory (default) or off
+}
+
+// AliasRule maps an import path pattern to the alias it should use.
+type AliasRule struct {
+	Package string `yaml:"package"`
+	Alias   string `yaml:"alias"`
+}
+
+// ArchRule constrains the imports of the packages matching Package. See
+// types.ImportRule for the pattern syntax.
+type ArchRule struct {
+	Package string   `yaml:"package"`
+	Allow   []string `yaml:"allow"`  // When set, the only workspace packages the package may import
+	Forbid  []string `yaml:"forbid"` // Packages the package must not import
+	Reason  string   `yaml:"reason"`
+}
+
+// LayerConfig names the directories of each architectural layer.
+type LayerConfig struct {
+	Domain         string `yaml:"domain"`
+	Infrastructure string `yaml:"infrastructure"`
+	Application    string `yaml:"application"`
+}
+
+// Default returns the configuration used when no config file exists.
+func Default() *Config {
+	return &Config{
+		Analyzers: AnalyzerConfig{
+			Complexity:    ComplexityConfig{MinComplexity: 10},
+			DeepIfElse:    DeepIfElseConfig{MaxNesting: 2, MinElseLines: 3},
+			BooleanBranch: BooleanBranchConfig{MinBranches: 2},
+			EnvBool:       EnvBoolConfig{MaxDepth: 1},
+			ErrorWrap:     ErrorWrapConfig{Severity: "critical"},
+			Pipeline:      PipelineConfig{MinStatements: 6, MinStages: 3},
+			MagicNumber:   MagicNumberConfig{MinOccurrences: 3},
+		},
+		Format: FormatConfig{Formatter: refactor.FormatterGofmt, Imports: refactor.ImportsGrouped},
+		Cache:  CacheConfig{Plans: "memory", Queries: "memory"},
+	}
+}
+
+// Load reads the config file at path. Settings absent from the file keep
+// their Default values.
+func Load(path string) (*Config, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, &types.RefactorError{
+			Type:    types.FileSystemError,
+			Message: fmt.Sprintf("failed to read config: %v", err),
+			File:    path,
+			Cause:   err,
+		}
+	}
+
+	cfg := Default()
+	if err := yaml.Unmarshal(content, cfg); err != nil {
+		return nil, &types.RefactorError{
+			Type:    types.ParseError,
+			Message: fmt.Sprintf("failed to parse config: %v", err),
+			File:    path,
+			Cause:   err,
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: err.Error(),
+			File:    path,
+		}
+	}
+	cfg.Path = path
+	return cfg, nil
+}
+
+// LoadWorkspace loads the config file at the root of the workspace, falling
+// back to Default when the workspace has none.
+func LoadWorkspace(root string) (*Config, error) {
+	path := filepath.Join(root, FileName)
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		return Default(), nil
+	}
+	return Load(path)
+}
+
+// Validate checks the settings that take one of a few values, for configs
+// changed after Load.
+func (c *Config) Validate() error {
+	switch c.Analyzers.ErrorWrap.Severity {
+	case "critical", "warning", "info":
+	default:
+		return fmt.Errorf("analyzers.error_wrap.severity must be critical, warning, or info, got %q", c.Analyzers.ErrorWrap.Severity)
+	}
+	switch c.Format.Formatter {
+	case refactor.FormatterGofmt, refactor.FormatterGofumpt:
+	case refactor.FormatterCommand:
+		if len(c.Format.Command) == 0 {
+			return fmt.Errorf("format.command is required when format.formatter is command")
+		}
+	default:
+		return fmt.Errorf("format.formatter must be gofmt, gofumpt, or command, got %q", c.Format.Formatter)
+	}
+	switch c.Format.Imports {
+	case refactor.ImportsGrouped, refactor.ImportsStd, refactor.ImportsNone:
+	default:
+		return fmt.Errorf("format.imports must be grouped, std, or none, got %q", c.Format.Imports)
+	}
+	if _, err := refactor.NewBuildValidator(c.Build.Validator, c.Build.Command); err != nil {
+		return fmt.Errorf("build.validator: %w", err)
+	}
+	if len(c.Build.Command) > 0 && c.Build.Validator != refactor.BuildBazel {
+		return fmt.Errorf("build.command only applies to the bazel validator")
+	}
+	switch c.Cache.Plans {
+	case "memory", "disk", "off":
+	default:
+		return fmt.Errorf("cache.plans must be memory, disk, or off, got %q", c.Cache.Plans)
+	}
+	switch c.Cache.Queries {
+	case "memory", "off":
+	default:
+		return fmt.Errorf("cache.queries must be memory or off, got %q", c.Cache.Queries)
+	}
+	for _, dir := range c.Exclude {
+		if filepath.IsAbs(dir) {
+			return fmt.Errorf("exclude entries must be relative to the workspace root, got %q", dir)
+		}
+	}
+	for _, r := range c.Engine.BreakingPolicy {
+		if r.Package == "" || filepath.IsAbs(r.Package) {
+			return fmt.Errorf("engine.breaking_policy packages must be relative to the workspace root, got %q", r.Package)
+		}
+	}
+	if _, err := types.ParseConfidence(c.Engine.MinConfidence); err != nil {
+		return fmt.Errorf("engine.min_confidence: %w", err)
+	}
+	if _, err := refactor.ParseChangeBackend(c.Engine.ChangeBackend); err != nil {
+		return fmt.Errorf("engine.change_backend: %w", err)
+	}
+	if _, err := refactor.ParseWorkspaceLoader(c.Engine.Loader); err != nil {
+		return fmt.Errorf("engine.loader: %w", err)
+	}
+	if _, err := refactor.ParseTestVerification(c.Engine.VerifyTests); err != nil {
+		return fmt.Errorf("engine.verify_tests: %w", err)
+	}
+	for _, r := range c.ImportAliases {
+		if r.Package == "" || r.Alias == "" {
+			return fmt.Errorf("import_aliases entries need both package and alias")
+		}
+	}
+	for _, r := range c.ImportRules() {
+		if err := analysis.ValidateImportRule(r); err != nil {
+			return fmt.Errorf("architecture: %w", err)
+		}
+	}
+	return nil
+}
+
+// ApplyEngine overrides the engine options that the config file sets.
+func (c *Config) ApplyEngine(ec *refactor.EngineConfig) {
+	if c.Engine.SkipCompilation != nil {
+		ec.SkipCompilation = *c.Engine.SkipCompilation
+	}
+	if c.Engine.AllowBreaking != nil {
+		ec.AllowBreaking = *c.Engine.AllowBreaking
+	}
+	if len(c.Engine.BreakingPolicy) > 0 {
+		ec.BreakingPolicy = make([]refactor.BreakingRule, len(c.Engine.BreakingPolicy))
+		for i, r := range c.Engine.BreakingPolicy {
+			ec.BreakingPolicy[i] = refactor.BreakingRule{Package: r.Package, Allow: r.Allow}
+		}
+	}
+	if c.Engine.AllowGenerated != nil {
+		ec.AllowGenerated = *c.Engine.AllowGenerated
+	}
+	if c.Engine.GeneratedDirs != nil {
+		ec.GeneratedDirs = c.Engine.GeneratedDirs
+	}
+	if c.Engine.MockSuffixes != nil {
+		ec.MockSuffixes = c.Engine.MockSuffixes
+	}
+	if c.Engine.Journal != nil {
+		ec.Journal = *c.Engine.Journal
+	}
+	if c.Engine.MinConfidence != "" {
+		ec.MinConfidence = types.Confidence(c.Engine.MinConfidence)
+	}
+	if c.Engine.ChangeBackend != "" {
+		ec.ChangeBackend = refactor.ChangeBackend(c.Engine.ChangeBackend)
+	}
+	if c.Engine.Loader != "" {
+		ec.Loader = refactor.WorkspaceLoader(c.Engine.Loader)
+	}
+	if c.Engine.VerifyTests != "" {
+		ec.VerifyTests = refactor.TestVerification(c.Engine.VerifyTests)
+	}
+	if len(c.Engine.Scope) > 0 {
+		ec.Scope = c.Engine.Scope
+	}
+	if c.Engine.MapSources != nil {
+		ec.MapSources = *c.Engine.MapSources
+	}
+	ec.ExcludeDirs = c.Exclude
+	ec.Dependents = c.Dependents
+	ec.Format = c.FormatStyle()
+	ec.ImportAliases = c.AliasRules()
+	ec.Build, _ = refactor.NewBuildValidator(c.Build.Validator, c.Build.Command)
+}
+
+// FormatStyle returns the configured formatting of Go files.
+func (c *Config) FormatStyle() refactor.FormatStyle {
+	return refactor.FormatStyle{
+		Formatter:     c.Format.Formatter,
+		Command:       c.Format.Command,
+		Imports:       c.Format.Imports,
+		LocalPrefixes: c.Format.LocalPrefixes,
+		FixImports:    c.Format.FixImports,
+	}
+}
+
+// ImportRules returns the configured architecture rules.
+func (c *Config) ImportRules() []types.ImportRule {
+	rules := make([]types.ImportRule, len(c.Architecture))
+	for i, r := range c.Architecture {
+		rules[i] = types.ImportRule{Package: r.Package, Allow: r.Allow, Forbid: r.Forbid, Reason: r.Reason}
+	}
+	return rules
+}
+
+// AliasRules returns the configured import alias standards.
+func (c *Config) AliasRules() []types.AliasRule {
+	rules := make([]types.AliasRule, len(c.ImportAliases))
+	for i, r := range c.ImportAliases {
+		rules[i] = types.AliasRule{PackagePattern: r.Package, Alias: r.Alias}
+	}
+	return rules
+}
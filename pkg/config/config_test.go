@@ -0,0 +1,167 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/config"
+	"github.com/mamaar/gorefactor/pkg/refactor"
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, config.FileName), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestLoadWorkspace_Missing(t *testing.T) {
+	cfg, err := config.LoadWorkspace(t.TempDir())
+	if err != nil {
+		t.Fatalf("LoadWorkspace: %v", err)
+	}
+	if cfg.Path != "" {
+		t.Errorf("expected no config path, got %q", cfg.Path)
+	}
+	if cfg.Analyzers.Complexity.MinComplexity != 10 {
+		t.Errorf("expected default min_complexity 10, got %d", cfg.Analyzers.Complexity.MinComplexity)
+	}
+}
+
+func TestLoadWorkspace(t *testing.T) {
+	dir := writeConfig(t, `
+engine:
+  allow_breaking: false
+  breaking_policy:
+    - {package: internal/..., allow: true}
+  generated_dirs: [gen]
+  mock_suffixes: []
+  min_confidence: likely
+  change_backend: text
+  loader: packages
+  verify_tests: affected
+  scope: [internal/billing/...]
+  map_sources: true
+analyzers:
+  complexity:
+    min_complexity: 15
+  error_wrap:
+    severity: warning
+exclude:
+  - third_party
+import_aliases:
+  - package: github.com/acme/app/pkg/events
+    alias: events
+layers:
+  domain: modules/
+format:
+  formatter: gofumpt
+  local_prefixes: [github.com/acme]
+  fix_imports: true
+architecture:
+  - package: internal/domain/...
+    forbid: [internal/http/...]
+    reason: the domain is transport agnostic
+`)
+	cfg, err := config.LoadWorkspace(dir)
+	if err != nil {
+		t.Fatalf("LoadWorkspace: %v", err)
+	}
+	if cfg.Path != filepath.Join(dir, config.FileName) {
+		t.Errorf("unexpected config path %q", cfg.Path)
+	}
+	if cfg.Analyzers.Complexity.MinComplexity != 15 {
+		t.Errorf("expected min_complexity 15, got %d", cfg.Analyzers.Complexity.MinComplexity)
+	}
+	if cfg.Analyzers.DeepIfElse.MaxNesting != 2 {
+		t.Errorf("expected unset max_nesting to keep default 2, got %d", cfg.Analyzers.DeepIfElse.MaxNesting)
+	}
+	if cfg.Layers.Domain != "modules/" {
+		t.Errorf("expected domain layer modules/, got %q", cfg.Layers.Domain)
+	}
+	rules := cfg.AliasRules()
+	if len(rules) != 1 || rules[0].Alias != "events" {
+		t.Errorf("unexpected alias rules %+v", rules)
+	}
+	importRules := cfg.ImportRules()
+	if len(importRules) != 1 || importRules[0].Package != "internal/domain/..." || len(importRules[0].Forbid) != 1 || importRules[0].Reason == "" {
+		t.Errorf("unexpected import rules %+v", importRules)
+	}
+
+	ec := &refactor.EngineConfig{SkipCompilation: true, AllowBreaking: true}
+	cfg.ApplyEngine(ec)
+	if !ec.SkipCompilation {
+		t.Error("expected unset skip_compilation to keep the frontend default")
+	}
+	if ec.AllowBreaking {
+		t.Error("expected allow_breaking to be overridden")
+	}
+	if len(ec.BreakingPolicy) != 1 || ec.BreakingPolicy[0] != (refactor.BreakingRule{Package: "internal/...", Allow: true}) {
+		t.Errorf("unexpected breaking policy %+v", ec.BreakingPolicy)
+	}
+	if len(ec.GeneratedDirs) != 1 || ec.GeneratedDirs[0] != "gen" {
+		t.Errorf("unexpected generated dirs %v", ec.GeneratedDirs)
+	}
+	if ec.MockSuffixes == nil || len(ec.MockSuffixes) != 0 {
+		t.Errorf("expected an empty mock_suffixes to turn the defaults off, got %#v", ec.MockSuffixes)
+	}
+	if ec.MinConfidence != types.ConfidenceLikely {
+		t.Errorf("unexpected min confidence %q", ec.MinConfidence)
+	}
+	if ec.ChangeBackend != refactor.ChangeBackendText {
+		t.Errorf("unexpected change backend %q", ec.ChangeBackend)
+	}
+	if ec.Loader != refactor.LoaderPackages {
+		t.Errorf("unexpected loader %q", ec.Loader)
+	}
+	if ec.VerifyTests != refactor.VerifyTestsAffected {
+		t.Errorf("unexpected test verification %q", ec.VerifyTests)
+	}
+	if len(ec.Scope) != 1 || ec.Scope[0] != "internal/billing/..." || !ec.MapSources {
+		t.Errorf("unexpected scope %v or map sources %v", ec.Scope, ec.MapSources)
+	}
+	if len(ec.ExcludeDirs) != 1 || ec.ExcludeDirs[0] != "third_party" {
+		t.Errorf("unexpected exclude dirs %v", ec.ExcludeDirs)
+	}
+	if ec.Format.Formatter != refactor.FormatterGofumpt || ec.Format.Imports != refactor.ImportsGrouped || len(ec.Format.LocalPrefixes) != 1 || !ec.Format.FixImports {
+		t.Errorf("unexpected format style %+v", ec.Format)
+	}
+	if len(ec.ImportAliases) != 1 || ec.ImportAliases[0].Alias != "events" {
+		t.Errorf("unexpected import aliases %+v", ec.ImportAliases)
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"syntax":     "engine: [",
+		"severity":   "analyzers:\n  error_wrap:\n    severity: fatal\n",
+		"absolute":   "exclude:\n  - /abs/path\n",
+		"alias":      "import_aliases:\n  - package: example.com/x\n",
+		"formatter":  "format:\n  formatter: prettier\n",
+		"command":    "format:\n  formatter: command\n",
+		"imports":    "format:\n  imports: alphabetical\n",
+		"build":      "build:\n  validator: make\n",
+		"buildcmd":   "build:\n  command: [plz, build]\n",
+		"cache":      "cache:\n  plans: redis\n",
+		"queries":    "cache:\n  queries: disk\n",
+		"breaking":   "engine:\n  breaking_policy:\n    - {package: /abs/pkg, allow: true}\n",
+		"confidence": "engine:\n  min_confidence: maybe\n",
+		"backend":    "engine:\n  change_backend: regex\n",
+		"loader":     "engine:\n  loader: gopls\n",
+		"tests":      "engine:\n  verify_tests: some\n",
+		"arch":       "architecture:\n  - package: internal/domain\n",
+		"archglob":   "architecture:\n  - {package: internal/domain, forbid: [\"internal/[\"]}\n",
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := writeConfig(t, content)
+			if _, err := config.LoadWorkspace(dir); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,292 @@
+// Package history records executed refactoring plans in an on-disk journal
+// under .gorefactor/history so they can be rolled back later, newest first.
+package history
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// Dir is the journal directory relative to the workspace root.
+const Dir = ".gorefactor/history"
+
+// Entry is one executed plan together with the state of every file it touched.
+type Entry struct {
+	ID          string         `json:"id"`
+	Time        time.Time      `json:"time"`
+	Description string         `json:"description"`
+	Files       []FileSnapshot `json:"files"`
+}
+
+// FileSnapshot holds a file's content and mode before a plan was applied and
+// hashes of the content before and after. An empty hash means the file did
+// not exist.
+type FileSnapshot struct {
+	Path       string      `json:"path"`
+	Before     []byte      `json:"before,omitempty"`
+	BeforeHash string      `json:"before_hash,omitempty"`
+	AfterHash  string      `json:"after_hash,omitempty"`
+	Mode       fs.FileMode `json:"mode,omitempty"` // Permission bits; zero in entries older than the field
+}
+
+// Journal stores entries as one JSON file per applied plan.
+type Journal struct {
+	dir string
+	now func() time.Time
+}
+
+// Open returns the journal of the workspace at root. The directory is only
+// created when the first entry is committed.
+func Open(root string) *Journal {
+	return &Journal{dir: filepath.Join(root, Dir), now: time.Now}
+}
+
+// Dir returns the directory the journal is stored in.
+func (j *Journal) Dir() string {
+	return j.dir
+}
+
+// Snapshot captures the current state of paths before a plan named desc is
+// applied. The entry is not stored until Commit is called.
+func (j *Journal) Snapshot(desc string, paths []string) (*Entry, error) {
+	entry := &Entry{Time: j.now().UTC(), Description: desc}
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+		content, hash, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+		snap := FileSnapshot{Path: path, Before: content, BeforeHash: hash}
+		if hash != "" {
+			info, err := os.Stat(path)
+			if err != nil {
+				return nil, journalError("stat file", path, err)
+			}
+			snap.Mode = info.Mode().Perm()
+		}
+		entry.Files = append(entry.Files, snap)
+	}
+	return entry, nil
+}
+
+// Commit records the post-apply hashes of the entry's files and writes the
+// entry to the journal, assigning its ID.
+func (j *Journal) Commit(entry *Entry) error {
+	for i := range entry.Files {
+		_, hash, err := readFile(entry.Files[i].Path)
+		if err != nil {
+			return err
+		}
+		entry.Files[i].AfterHash = hash
+	}
+	if err := os.MkdirAll(j.dir, 0o755); err != nil {
+		return journalError("create journal directory", j.dir, err)
+	}
+
+	base := entry.Time.Format("20060102T150405.000000000Z")
+	entry.ID = base
+	for n := 1; ; n++ {
+		if _, err := os.Stat(j.entryPath(entry.ID)); errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		entry.ID = fmt.Sprintf("%s-%d", base, n)
+	}
+
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return journalError("encode journal entry", entry.ID, err)
+	}
+	if err := os.WriteFile(j.entryPath(entry.ID), data, 0o644); err != nil {
+		return journalError("write journal entry", j.entryPath(entry.ID), err)
+	}
+	return nil
+}
+
+// List returns all entries, oldest first.
+func (j *Journal) List() ([]*Entry, error) {
+	dirEntries, err := os.ReadDir(j.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, journalError("read journal", j.dir, err)
+	}
+	var entries []*Entry
+	for _, de := range dirEntries {
+		id, ok := strings.CutSuffix(de.Name(), ".json")
+		if !ok || de.IsDir() {
+			continue
+		}
+		entry, err := j.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	slices.SortFunc(entries, func(a, b *Entry) int { return strings.Compare(a.ID, b.ID) })
+	return entries, nil
+}
+
+// Get loads a single entry by ID.
+func (j *Journal) Get(id string) (*Entry, error) {
+	data, err := os.ReadFile(j.entryPath(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("no history entry %q", id),
+		}
+	}
+	if err != nil {
+		return nil, journalError("read journal entry", j.entryPath(id), err)
+	}
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, journalError("decode journal entry", j.entryPath(id), err)
+	}
+	return &entry, nil
+}
+
+// Rollback reverts the entry with the given ID and every later entry, newest
+// first, and removes them from the journal. Before reverting an entry its
+// files must still match the state the plan left them in; otherwise the
+// rollback stops with an error, leaving already reverted entries reverted.
+// force skips that check and overwrites later edits.
+func (j *Journal) Rollback(toID string, force bool) ([]*Entry, error) {
+	entries, err := j.List()
+	if err != nil {
+		return nil, err
+	}
+	idx := slices.IndexFunc(entries, func(e *Entry) bool { return e.ID == toID })
+	if idx < 0 {
+		return nil, &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("no history entry %q", toID),
+		}
+	}
+
+	var reverted []*Entry
+	for i := len(entries) - 1; i >= idx; i-- {
+		entry := entries[i]
+		if !force {
+			if err := checkUnchanged(entry); err != nil {
+				return reverted, err
+			}
+		}
+		if err := restore(entry); err != nil {
+			return reverted, err
+		}
+		if err := os.Remove(j.entryPath(entry.ID)); err != nil {
+			return reverted, journalError("remove journal entry", j.entryPath(entry.ID), err)
+		}
+		reverted = append(reverted, entry)
+	}
+	return reverted, nil
+}
+
+// Paths returns the files touched by the given entries, without duplicates.
+func Paths(entries []*Entry) []string {
+	var paths []string
+	for _, entry := range entries {
+		for _, f := range entry.Files {
+			if !slices.Contains(paths, f.Path) {
+				paths = append(paths, f.Path)
+			}
+		}
+	}
+	return paths
+}
+
+func (j *Journal) entryPath(id string) string {
+	return filepath.Join(j.dir, id+".json")
+}
+
+// checkUnchanged verifies that the entry's files are still as the plan left them.
+func checkUnchanged(entry *Entry) error {
+	for _, f := range entry.Files {
+		_, hash, err := readFile(f.Path)
+		if err != nil {
+			return err
+		}
+		if hash != f.AfterHash {
+			return &types.RefactorError{
+				Type:    types.InvalidOperation,
+				Message: fmt.Sprintf("cannot roll back %s (%s): file was modified after the plan was applied", entry.ID, entry.Description),
+				File:    f.Path,
+			}
+		}
+	}
+	return nil
+}
+
+// Restore writes back the content the entry's files had when it was
+// snapshotted, removing files that didn't exist, without consulting or
+// changing the journal. It undoes a plan whose entry was never committed.
+func (e *Entry) Restore() error {
+	return restore(e)
+}
+
+// restore writes back the pre-apply content and mode of the entry's files,
+// removing files the plan created.
+func restore(entry *Entry) error {
+	for _, f := range entry.Files {
+		if f.BeforeHash == "" {
+			if err := os.Remove(f.Path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return journalError("remove file", f.Path, err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(f.Path), 0o755); err != nil {
+			return journalError("create directory", filepath.Dir(f.Path), err)
+		}
+		mode := f.Mode
+		if mode == 0 {
+			mode = 0o644
+		}
+		if err := os.WriteFile(f.Path, f.Before, mode); err != nil {
+			return journalError("restore file", f.Path, err)
+		}
+		// WriteFile keeps the mode of a file that exists, and the umask
+		// narrows the one it creates.
+		if err := os.Chmod(f.Path, mode); err != nil {
+			return journalError("restore file mode", f.Path, err)
+		}
+	}
+	return nil
+}
+
+// readFile returns the content and hash of path, or empty values if it does not exist.
+func readFile(path string) ([]byte, string, error) {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", journalError("read file", path, err)
+	}
+	sum := sha256.Sum256(content)
+	return content, hex.EncodeToString(sum[:]), nil
+}
+
+func journalError(action, file string, err error) error {
+	return &types.RefactorError{
+		Type:    types.FileSystemError,
+		Message: fmt.Sprintf("failed to %s: %v", action, err),
+		File:    file,
+		Cause:   err,
+	}
+}
@@ -0,0 +1,156 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// apply snapshots paths, writes the given contents and commits the entry,
+// mimicking what the engine does around ApplyChanges.
+func apply(t *testing.T, j *Journal, desc string, contents map[string]string) *Entry {
+	t.Helper()
+	var paths []string
+	for path := range contents {
+		paths = append(paths, path)
+	}
+	entry, err := j.Snapshot(desc, paths)
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	for path, content := range contents {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := j.Commit(entry); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	return entry
+}
+
+func readString(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestJournal_RollbackInReverseOrder(t *testing.T) {
+	root := t.TempDir()
+	a := filepath.Join(root, "a.go")
+	b := filepath.Join(root, "b.go")
+	if err := os.WriteFile(a, []byte("v0"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	j := Open(root)
+	first := apply(t, j, "first", map[string]string{a: "v1"})
+	apply(t, j, "second", map[string]string{a: "v2", b: "new"})
+
+	entries, err := j.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Description != "first" || entries[1].Description != "second" {
+		t.Fatalf("List() = %+v, want first then second", entries)
+	}
+
+	reverted, err := j.Rollback(first.ID, false)
+	if err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+	if len(reverted) != 2 || reverted[0].Description != "second" {
+		t.Fatalf("expected second to be reverted before first, got %+v", reverted)
+	}
+	if got := readString(t, a); got != "v0" {
+		t.Errorf("a.go = %q, want %q", got, "v0")
+	}
+	if _, err := os.Stat(b); !os.IsNotExist(err) {
+		t.Errorf("expected file created by the plan to be removed, stat err = %v", err)
+	}
+	if entries, _ := j.List(); len(entries) != 0 {
+		t.Errorf("expected reverted entries to leave the journal, got %d", len(entries))
+	}
+}
+
+func TestJournal_RollbackRefusesModifiedFiles(t *testing.T) {
+	root := t.TempDir()
+	a := filepath.Join(root, "a.go")
+	if err := os.WriteFile(a, []byte("v0"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	j := Open(root)
+	entry := apply(t, j, "edit", map[string]string{a: "v1"})
+	if err := os.WriteFile(a, []byte("hand edit"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := j.Rollback(entry.ID, false); err == nil {
+		t.Fatal("expected rollback of a modified file to fail")
+	}
+	if got := readString(t, a); got != "hand edit" {
+		t.Errorf("a.go = %q, expected it to be left alone", got)
+	}
+
+	if _, err := j.Rollback(entry.ID, true); err != nil {
+		t.Fatalf("forced Rollback: %v", err)
+	}
+	if got := readString(t, a); got != "v0" {
+		t.Errorf("a.go = %q, want %q", got, "v0")
+	}
+}
+
+func TestJournal_RollbackUnknownID(t *testing.T) {
+	if _, err := Open(t.TempDir()).Rollback("missing", false); err == nil {
+		t.Fatal("expected an error for an unknown entry")
+	}
+}
+
+func TestJournal_RollbackRestoresMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not kept on Windows")
+	}
+	root := t.TempDir()
+	script := filepath.Join(root, "gen.go")
+	if err := os.WriteFile(script, []byte("v0"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(script, 0o751); err != nil {
+		t.Fatal(err)
+	}
+
+	j := Open(root)
+	entry, err := j.Snapshot("rewrite", []string{script})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The plan replaces the file, as writing through a temporary file does.
+	if err := os.Remove(script); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(script, []byte("v1"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Commit(entry); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := j.Rollback(entry.ID, false); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+	info, err := os.Stat(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := info.Mode().Perm(); got != 0o751 {
+		t.Errorf("mode after rollback = %v, want %v", got, os.FileMode(0o751))
+	}
+	if got := readString(t, script); got != "v0" {
+		t.Errorf("content after rollback = %q, want v0", got)
+	}
+}
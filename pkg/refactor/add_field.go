@@ -0,0 +1,569 @@
+package refactor
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	gotypes "go/types"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mamaar/gorefactor/pkg/analysis"
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// AddFieldOperation adds a field to a struct type. Keyed literals of the
+// type are left alone; positional ones, in type-checked packages and in
+// test files where they name the type, get the default value or the
+// field's zero value at the field's position. With Constructors, New<Type>
+// functions that set each field of the type from a parameter get a
+// parameter for the new field as well, which their callers pass the
+// default value for.
+type AddFieldOperation struct {
+	Request types.AddFieldRequest
+	Parser  *analysis.GoParser // Type-checks packages on demand; may be nil if they already are
+
+	// Resolved by Validate
+	pkg        *types.Package
+	file       *types.File
+	structType *ast.StructType
+	obj        *gotypes.TypeName
+	index      int        // Of the new field among the fields
+	anchor     *ast.Field // Declaration the new field is added next to; nil to add it last
+	before     bool       // Whether it is added before anchor
+	fieldType  gotypes.Type
+}
+
+func (op *AddFieldOperation) Type() types.OperationType {
+	return types.AddFieldOperation
+}
+
+func (op *AddFieldOperation) Description() string {
+	return fmt.Sprintf("Add field %s %s to %s", op.Request.FieldName, op.Request.FieldType, op.Request.TypeName)
+}
+
+func (op *AddFieldOperation) Validate(ws *types.Workspace) error {
+	req := op.Request
+	if req.TypeName == "" || req.FieldName == "" || req.FieldType == "" {
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: "type name, field name and field type are required",
+		}
+	}
+	if !isValidGoIdentifier(req.FieldName) || token.IsKeyword(req.FieldName) {
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("%q is not a valid field name", req.FieldName),
+		}
+	}
+	typeExpr, err := parser.ParseExpr(req.FieldType)
+	if err != nil {
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("invalid field type %q: %v", req.FieldType, err),
+		}
+	}
+	if req.Default != "" {
+		if _, err := parser.ParseExpr(req.Default); err != nil {
+			return &types.RefactorError{
+				Type:    types.InvalidOperation,
+				Message: fmt.Sprintf("invalid default value %q: %v", req.Default, err),
+			}
+		}
+	}
+	if req.Before != "" && req.After != "" {
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: "set before or after, not both",
+		}
+	}
+	if err := op.findStruct(ws); err != nil {
+		return err
+	}
+	if err := op.place(); err != nil {
+		return err
+	}
+
+	if op.Parser != nil {
+		op.Parser.EnsureTypeChecked(ws, op.pkg)
+	}
+	if op.pkg.TypesPkg == nil || op.pkg.TypesInfo == nil {
+		return &types.RefactorError{
+			Type:    types.CompilationError,
+			Message: fmt.Sprintf("package %s could not be type-checked; the literals of %s cannot be found", op.pkg.ImportPath, req.TypeName),
+		}
+	}
+	op.obj, _ = op.pkg.TypesPkg.Scope().Lookup(req.TypeName).(*gotypes.TypeName)
+	if op.obj == nil {
+		return &types.RefactorError{
+			Type:    types.SymbolNotFound,
+			Message: fmt.Sprintf("type %s not found in package %s", req.TypeName, op.pkg.ImportPath),
+		}
+	}
+	if obj, _, _ := gotypes.LookupFieldOrMethod(gotypes.NewPointer(op.obj.Type()), true, op.pkg.TypesPkg, req.FieldName); obj != nil {
+		return &types.RefactorError{
+			Type:    types.NameConflict,
+			Message: fmt.Sprintf("%s already has a field or method named %s", req.TypeName, req.FieldName),
+		}
+	}
+	// The field's type resolves in the scope of the struct's file; one
+	// from a package the file doesn't import yet is only known by name.
+	if tv, err := gotypes.Eval(ws.FileSet, op.pkg.TypesPkg, op.structType.Pos(), req.FieldType); err == nil && tv.IsType() {
+		op.fieldType = tv.Type
+	} else if _, ok := typeExpr.(*ast.SelectorExpr); !ok {
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("%s is not a type in package %s", req.FieldType, op.pkg.ImportPath),
+		}
+	}
+	return nil
+}
+
+// findStruct locates the struct type declaration, restricted to
+// Request.Package when set.
+func (op *AddFieldOperation) findStruct(ws *types.Workspace) error {
+	req := op.Request
+	found := 0
+	for _, pkgPath := range slices.Sorted(maps.Keys(ws.Packages)) {
+		pkg := ws.Packages[pkgPath]
+		if req.Package != "" && pkg.Path != req.Package && pkg.ImportPath != req.Package {
+			continue
+		}
+		for _, fileName := range slices.Sorted(maps.Keys(pkg.Files)) {
+			file := pkg.Files[fileName]
+			if file.AST == nil {
+				continue
+			}
+			for _, decl := range file.AST.Decls {
+				genDecl, ok := decl.(*ast.GenDecl)
+				if !ok || genDecl.Tok != token.TYPE {
+					continue
+				}
+				for _, spec := range genDecl.Specs {
+					typeSpec := spec.(*ast.TypeSpec)
+					if typeSpec.Name.Name != req.TypeName {
+						continue
+					}
+					found++
+					structType, ok := typeSpec.Type.(*ast.StructType)
+					if !ok {
+						return &types.RefactorError{
+							Type:    types.InvalidOperation,
+							Message: fmt.Sprintf("%s is not a struct type", req.TypeName),
+							File:    file.Path,
+						}
+					}
+					op.pkg, op.file, op.structType = pkg, file, structType
+				}
+			}
+		}
+	}
+
+	switch {
+	case found == 0:
+		return &types.RefactorError{
+			Type:    types.SymbolNotFound,
+			Message: fmt.Sprintf("type %s not found", req.TypeName),
+		}
+	case found > 1:
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("type %s is declared in %d packages; specify the package", req.TypeName, found),
+		}
+	}
+	return nil
+}
+
+// place resolves where the new field goes: next to the declaration naming
+// Before or After, or last. A field declared together with others, as in
+// X, Y int, can only be added before the first or after the last of them.
+func (op *AddFieldOperation) place() error {
+	req := op.Request
+	neighbour := req.After
+	if req.Before != "" {
+		neighbour, op.before = req.Before, true
+	}
+	index := 0
+	for _, field := range op.structType.Fields.List {
+		names := fieldNames(field)
+		i := slices.Index(names, neighbour)
+		if neighbour == "" || i < 0 {
+			index += len(names)
+			continue
+		}
+		switch {
+		case op.before && i == 0:
+			op.index = index
+		case !op.before && i == len(names)-1:
+			op.index = index + len(names)
+		default:
+			return &types.RefactorError{
+				Type:    types.InvalidOperation,
+				Message: fmt.Sprintf("%s.%s is declared together with %s; split the declaration first", req.TypeName, neighbour, strings.Join(slices.DeleteFunc(names, func(n string) bool { return n == neighbour }), ", ")),
+				File:    op.file.Path,
+			}
+		}
+		op.anchor = field
+		return nil
+	}
+	if neighbour != "" {
+		return &types.RefactorError{
+			Type:    types.SymbolNotFound,
+			Message: fmt.Sprintf("field %s.%s not found", req.TypeName, neighbour),
+		}
+	}
+	op.index = index
+	return nil
+}
+
+// fieldNames returns the names field declares, or the name of an
+// embedded field.
+func fieldNames(field *ast.Field) []string {
+	if len(field.Names) == 0 {
+		return []string{embeddedName(field.Type)}
+	}
+	names := make([]string, len(field.Names))
+	for i, name := range field.Names {
+		names[i] = name.Name
+	}
+	return names
+}
+
+func (op *AddFieldOperation) Execute(ws *types.Workspace) (*types.RefactoringPlan, error) {
+	changes := []types.Change{op.declare(ws)}
+	var issues []types.Issue
+
+	for _, pkgPath := range slices.Sorted(maps.Keys(ws.Packages)) {
+		pkg := ws.Packages[pkgPath]
+		if pkg != op.pkg && !slices.Contains(pkg.Imports, op.pkg.ImportPath) {
+			continue
+		}
+		if op.Parser != nil {
+			op.Parser.EnsureTypeChecked(ws, pkg)
+		}
+		for _, file := range packageFiles(pkg) {
+			if file.AST == nil {
+				continue
+			}
+			typed := pkg.TypesInfo != nil && pkg.Files[filepath.Base(file.Path)] == file
+			if !typed && !strings.HasSuffix(file.Path, "_test.go") {
+				issues = append(issues, types.Issue{
+					Type:        types.IssueCompilationError,
+					Severity:    types.Warning,
+					Description: fmt.Sprintf("package %s could not be type-checked; positional literals of %s in it were not updated", pkg.ImportPath, op.Request.TypeName),
+					File:        file.Path,
+				})
+				continue
+			}
+			ast.Inspect(file.AST, func(n ast.Node) bool {
+				if lit, ok := n.(*ast.CompositeLit); ok && op.positional(pkg, file, lit, typed) {
+					changes = append(changes, op.extendLiteral(ws, pkg, file, lit))
+				}
+				return true
+			})
+		}
+	}
+
+	if op.Request.Constructors {
+		ctorChanges, ctorIssues := op.extendConstructors(ws)
+		changes = append(changes, ctorChanges...)
+		issues = append(issues, ctorIssues...)
+	}
+
+	plan := &types.RefactoringPlan{Changes: changes, Reversible: true}
+	impact := &types.ImpactAnalysis{PotentialIssues: issues}
+	for _, change := range changes {
+		if !slices.Contains(plan.AffectedFiles, change.File) {
+			plan.AffectedFiles = append(plan.AffectedFiles, change.File)
+		}
+		if pkg := findPackageForFile(ws, change.File); pkg != nil && !slices.Contains(impact.AffectedPackages, pkg.Path) {
+			impact.AffectedPackages = append(impact.AffectedPackages, pkg.Path)
+		}
+	}
+	impact.AffectedFiles = plan.AffectedFiles
+	plan.Impact = impact
+	return plan, nil
+}
+
+// declare returns the change adding the field to the struct declaration,
+// on a line of its own indented like the other fields.
+func (op *AddFieldOperation) declare(ws *types.Workspace) types.Change {
+	req := op.Request
+	decl := req.FieldName + " " + req.FieldType
+	if req.Tag != "" {
+		decl += " `" + req.Tag + "`"
+	}
+	fields := op.structType.Fields
+	indent := "\t"
+	if len(fields.List) > 0 {
+		indent = lineIndent(op.file.OriginalContent, ws.FileSet.Position(fields.List[0].Pos()).Offset)
+	}
+
+	var at token.Pos
+	var text string
+	switch {
+	case len(fields.List) == 0:
+		at, text = fields.Closing, "\n"+strings.TrimSuffix(indent, "\t")+"\t"+decl+"\n"
+	case op.anchor == nil:
+		at, text = fieldEnd(fields.List[len(fields.List)-1]), "\n"+indent+decl
+	case op.before:
+		start := op.anchor.Pos()
+		if op.anchor.Doc != nil {
+			start = op.anchor.Doc.Pos()
+		}
+		at, text = start, decl+"\n"+indent
+	default:
+		at, text = fieldEnd(op.anchor), "\n"+indent+decl
+	}
+	offset := ws.FileSet.Position(at).Offset
+	return types.Change{
+		File:        op.file.Path,
+		Start:       offset,
+		End:         offset,
+		NewText:     text,
+		Description: op.Description(),
+	}
+}
+
+// fieldEnd returns the end of field's declaration with its line comment.
+func fieldEnd(field *ast.Field) token.Pos {
+	if field.Comment != nil {
+		return field.Comment.End()
+	}
+	return field.End()
+}
+
+// lineIndent returns the whitespace the line holding offset starts with.
+func lineIndent(content []byte, offset int) string {
+	start := offset
+	for start > 0 && content[start-1] != '\n' {
+		start--
+	}
+	end := start
+	for end < len(content) && (content[end] == ' ' || content[end] == '\t') {
+		end++
+	}
+	return string(content[start:end])
+}
+
+// positional reports whether lit is a positional literal of the struct.
+// Without type information, only literals naming the type are recognized.
+func (op *AddFieldOperation) positional(pkg *types.Package, file *types.File, lit *ast.CompositeLit, typed bool) bool {
+	if len(lit.Elts) == 0 {
+		return false
+	}
+	if _, keyed := lit.Elts[0].(*ast.KeyValueExpr); keyed {
+		return false
+	}
+	if typed {
+		t := pkg.TypesInfo.TypeOf(lit)
+		if ptr, ok := t.(*gotypes.Pointer); ok {
+			t = ptr.Elem()
+		}
+		return t != nil && gotypes.Identical(t, op.obj.Type())
+	}
+	switch t := lit.Type.(type) {
+	case *ast.Ident:
+		return pkg == op.pkg && file.AST.Name.Name == op.pkg.Name && t.Name == op.Request.TypeName
+	case *ast.SelectorExpr:
+		x, ok := t.X.(*ast.Ident)
+		return ok && t.Sel.Name == op.Request.TypeName && importedAs(file.AST, op.pkg) == x.Name
+	}
+	return false
+}
+
+// importedAs returns the name file refers to pkg by, or "" if it doesn't
+// import it.
+func importedAs(file *ast.File, pkg *types.Package) string {
+	for _, spec := range file.Imports {
+		if path, err := strconv.Unquote(spec.Path.Value); err != nil || path != pkg.ImportPath {
+			continue
+		}
+		if spec.Name != nil {
+			return spec.Name.Name
+		}
+		return pkg.Name
+	}
+	return ""
+}
+
+// extendLiteral returns the change inserting the field's value into the
+// positional literal lit.
+func (op *AddFieldOperation) extendLiteral(ws *types.Workspace, pkg *types.Package, file *types.File, lit *ast.CompositeLit) types.Change {
+	value := op.value(pkg)
+	var at token.Pos
+	if op.index < len(lit.Elts) {
+		at, value = lit.Elts[op.index].Pos(), value+", "
+	} else {
+		at, value = lit.Elts[len(lit.Elts)-1].End(), ", "+value
+	}
+	offset := ws.FileSet.Position(at).Offset
+	return types.Change{
+		File:        file.Path,
+		Start:       offset,
+		End:         offset,
+		NewText:     value,
+		Description: fmt.Sprintf("Add %s to a %s literal", op.Request.FieldName, op.Request.TypeName),
+	}
+}
+
+// value returns the value literals in pkg get for the field: the default,
+// or the zero value of its type.
+func (op *AddFieldOperation) value(pkg *types.Package) string {
+	if op.Request.Default != "" {
+		return op.Request.Default
+	}
+	if op.fieldType == nil {
+		return zeroValueForType(op.Request.FieldType)
+	}
+	return zeroValue(op.fieldType, func(p *gotypes.Package) string {
+		if p.Path() == pkg.ImportPath {
+			return ""
+		}
+		return p.Name()
+	})
+}
+
+// extendConstructors adds a parameter for the field to the New<Type>
+// functions of the struct's package that return a keyed literal of it
+// setting every field from a parameter of the same name, and sets the
+// field from it. Their callers pass the default value.
+func (op *AddFieldOperation) extendConstructors(ws *types.Workspace) ([]types.Change, []types.Issue) {
+	var changes []types.Change
+	var issues []types.Issue
+	param := unexportName(op.Request.FieldName)
+	if token.IsKeyword(param) {
+		param += "_"
+	}
+	for _, fileName := range slices.Sorted(maps.Keys(op.pkg.Files)) {
+		file := op.pkg.Files[fileName]
+		if file.AST == nil {
+			continue
+		}
+		for _, decl := range file.AST.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv != nil || fn.Name.Name != "New"+op.Request.TypeName || fn.Body == nil {
+				continue
+			}
+			lit, params := op.constructorLiteral(ws, fn)
+			if lit == nil {
+				continue
+			}
+			issue := func(msg string) {
+				issues = append(issues, types.Issue{
+					Type:        types.IssueNameConflict,
+					Severity:    types.Warning,
+					Description: fmt.Sprintf("%s not extended: %s", fn.Name.Name, msg),
+					File:        file.Path,
+					Line:        ws.FileSet.Position(fn.Pos()).Line,
+				})
+			}
+			if slices.ContainsFunc(params, func(p Parameter) bool { return p.Name == param }) || usesName(fn.Body, param) {
+				issue(fmt.Sprintf("it already uses the name %s", param))
+				continue
+			}
+
+			ctor := &ChangeSignatureOperation{
+				FunctionName:       fn.Name.Name,
+				SourceFile:         file.Path,
+				NewParams:          append(params, Parameter{Name: param, Type: op.Request.FieldType}),
+				Scope:              types.WorkspaceScope,
+				DefaultValue:       op.value(op.pkg),
+				NewParamPosition:   len(params),
+				NewReturnPosition:  -1,
+				RemovedReturnIndex: -1,
+			}
+			if err := ctor.Validate(ws); err != nil {
+				issue(err.Error())
+				continue
+			}
+			plan, err := ctor.Execute(ws)
+			if err != nil {
+				issue(err.Error())
+				continue
+			}
+			changes = append(changes, plan.Changes...)
+			issues = append(issues, plan.Impact.PotentialIssues...)
+
+			last := lit.Elts[len(lit.Elts)-1]
+			offset := ws.FileSet.Position(last.End()).Offset
+			changes = append(changes, types.Change{
+				File:        file.Path,
+				Start:       offset,
+				End:         offset,
+				NewText:     fmt.Sprintf(", %s: %s", op.Request.FieldName, param),
+				Description: fmt.Sprintf("Set %s in %s", op.Request.FieldName, fn.Name.Name),
+			})
+		}
+	}
+	return changes, issues
+}
+
+// constructorLiteral returns the keyed literal of the struct fn returns,
+// when it sets every field from a parameter of fn, and fn's parameters.
+func (op *AddFieldOperation) constructorLiteral(ws *types.Workspace, fn *ast.FuncDecl) (*ast.CompositeLit, []Parameter) {
+	var params []Parameter
+	for _, field := range fn.Type.Params.List {
+		typ := nodeText(ws, op.file, field.Type.Pos(), field.Type.End())
+		for _, name := range field.Names {
+			params = append(params, Parameter{Name: name.Name, Type: typ})
+		}
+	}
+
+	var found *ast.CompositeLit
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		ret, ok := n.(*ast.ReturnStmt)
+		if !ok || len(ret.Results) == 0 || found != nil {
+			return found == nil
+		}
+		expr := ret.Results[0]
+		if u, ok := expr.(*ast.UnaryExpr); ok && u.Op == token.AND {
+			expr = u.X
+		}
+		lit, ok := expr.(*ast.CompositeLit)
+		if !ok || len(lit.Elts) != len(fieldsOf(op.structType)) || len(lit.Elts) == 0 {
+			return true
+		}
+		if id, ok := lit.Type.(*ast.Ident); !ok || id.Name != op.Request.TypeName {
+			return true
+		}
+		for _, elt := range lit.Elts {
+			kv, ok := elt.(*ast.KeyValueExpr)
+			if !ok {
+				return true
+			}
+			value, ok := kv.Value.(*ast.Ident)
+			if !ok || !slices.ContainsFunc(params, func(p Parameter) bool { return p.Name == value.Name }) {
+				return true
+			}
+		}
+		found = lit
+		return false
+	})
+	return found, params
+}
+
+// fieldsOf returns the names of the fields of st.
+func fieldsOf(st *ast.StructType) []string {
+	var names []string
+	for _, field := range st.Fields.List {
+		names = append(names, fieldNames(field)...)
+	}
+	return names
+}
+
+// usesName reports whether node has an identifier named name.
+func usesName(node ast.Node, name string) bool {
+	found := false
+	ast.Inspect(node, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && id.Name == name {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
@@ -0,0 +1,137 @@
+package refactor
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+var addFieldFiles = map[string]string{
+	"conf/conf.go": `package conf
+
+import "time"
+
+// Config configures a client.
+type Config struct {
+	Host string // Where to connect
+	Port int
+}
+
+func NewConfig(host string, port int) *Config {
+	return &Config{Host: host, Port: port}
+}
+
+var Default = Config{"localhost", 80}
+
+var Keyed = Config{Host: "localhost"}
+
+var Timeouts = map[string]time.Duration{}
+
+var Pointers = []*Config{{"a", 1}}
+`,
+	"app/app.go": `package app
+
+import "example.com/p/conf"
+
+func Configs() []conf.Config {
+	return []conf.Config{conf.Config{"b", 2}, {"c", 3}, *conf.NewConfig("d", 4)}
+}
+`,
+}
+
+func TestAddField(t *testing.T) {
+	engine, ws, dir := loadTestModuleWith(t, addFieldFiles)
+
+	plan, err := engine.AddField(ws, types.AddFieldRequest{
+		TypeName:  "Config",
+		FieldName: "Timeout",
+		FieldType: "time.Duration",
+		After:     "Host",
+		Tag:       `json:"timeout"`,
+	})
+	if err != nil {
+		t.Fatalf("AddField: %v", err)
+	}
+
+	conf := planContent(t, plan, filepath.Join(dir, "conf", "conf.go"))
+	for _, want := range []string{
+		"\tHost string // Where to connect\n\tTimeout time.Duration `json:\"timeout\"`\n\tPort int\n",
+		`Config{"localhost", 0, 80}`,
+		`Config{Host: "localhost"}`,
+		`[]*Config{{"a", 0, 1}}`,
+		"return &Config{Host: host, Port: port}",
+	} {
+		if !strings.Contains(conf, want) {
+			t.Errorf("conf.go lacks %q:\n%s", want, conf)
+		}
+	}
+	app := planContent(t, plan, filepath.Join(dir, "app", "app.go"))
+	if want := `[]conf.Config{conf.Config{"b", 0, 2}, {"c", 0, 3}, *conf.NewConfig("d", 4)}`; !strings.Contains(app, want) {
+		t.Errorf("app.go lacks %q:\n%s", want, app)
+	}
+}
+
+func TestAddField_Constructors(t *testing.T) {
+	engine, ws, dir := loadTestModuleWith(t, addFieldFiles)
+
+	plan, err := engine.AddField(ws, types.AddFieldRequest{
+		TypeName:     "Config",
+		FieldName:    "Retries",
+		FieldType:    "int",
+		Before:       "Host",
+		Default:      "3",
+		Constructors: true,
+	})
+	if err != nil {
+		t.Fatalf("AddField: %v", err)
+	}
+
+	conf := planContent(t, plan, filepath.Join(dir, "conf", "conf.go"))
+	for _, want := range []string{
+		"type Config struct {\n\tRetries int\n\tHost string",
+		"func NewConfig(host string, port int, retries int) *Config {",
+		"return &Config{Host: host, Port: port, Retries: retries}",
+		`Config{3, "localhost", 80}`,
+	} {
+		if !strings.Contains(conf, want) {
+			t.Errorf("conf.go lacks %q:\n%s", want, conf)
+		}
+	}
+	app := planContent(t, plan, filepath.Join(dir, "app", "app.go"))
+	if !strings.Contains(app, `conf.NewConfig("d", 4, 3)`) {
+		t.Errorf("NewConfig's caller should pass the default:\n%s", app)
+	}
+}
+
+func TestAddField_Errors(t *testing.T) {
+	engine, ws, _ := loadTestModuleWith(t, map[string]string{
+		"conf/conf.go": `package conf
+
+type Config struct {
+	Host, Addr string
+	Port       int
+}
+
+func (c Config) Name() string { return c.Host }
+
+type Alias = int
+`,
+	})
+
+	for name, req := range map[string]types.AddFieldRequest{
+		"existing field":  {TypeName: "Config", FieldName: "Port", FieldType: "int"},
+		"existing method": {TypeName: "Config", FieldName: "Name", FieldType: "string"},
+		"not a struct":    {TypeName: "Alias", FieldName: "X", FieldType: "int"},
+		"unknown type":    {TypeName: "Missing", FieldName: "X", FieldType: "int"},
+		"unknown after":   {TypeName: "Config", FieldName: "X", FieldType: "int", After: "Missing"},
+		"inside a group":  {TypeName: "Config", FieldName: "X", FieldType: "int", After: "Host"},
+		"invalid type":    {TypeName: "Config", FieldName: "X", FieldType: "[]"},
+		"undeclared type": {TypeName: "Config", FieldName: "X", FieldType: "Missing"},
+	} {
+		if _, err := engine.AddField(ws, req); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
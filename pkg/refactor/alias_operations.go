@@ -1,15 +1,72 @@
 package refactor
 
 import (
+	"cmp"
 	"fmt"
 	"go/ast"
 	"go/token"
+	"maps"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/mamaar/gorefactor/pkg/types"
 )
 
+// aliasScope returns the packages an import alias operation covers: those
+// with the given directories, or all of them, in directory order.
+func aliasScope(ws *types.Workspace, packages []string) []*types.Package {
+	var scope []*types.Package
+	for _, dir := range slices.Sorted(maps.Keys(ws.Packages)) {
+		if len(packages) == 0 || slices.Contains(packages, dir) {
+			scope = append(scope, ws.Packages[dir])
+		}
+	}
+	return scope
+}
+
+// validateAliasScope checks that the packages an operation is limited to
+// exist.
+func validateAliasScope(ws *types.Workspace, packages []string) error {
+	for _, dir := range packages {
+		if _, ok := ws.Packages[dir]; !ok {
+			return fmt.Errorf("package %s not found in workspace", dir)
+		}
+	}
+	return nil
+}
+
+// requalifyChanges returns the changes renaming the package qualifier from
+// to to in the selector expressions of file, for an import whose name
+// changes.
+func requalifyChanges(fset *token.FileSet, file *types.File, from, to string) []types.Change {
+	var changes []types.Change
+	if from == to {
+		return nil
+	}
+	ast.Inspect(file.AST, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if id, ok := sel.X.(*ast.Ident); ok && id.Name == from {
+				changes = append(changes, types.Change{
+					File:        file.Path,
+					Start:       fileOffset(fset, id.Pos()),
+					End:         fileOffset(fset, id.End()),
+					OldText:     from,
+					NewText:     to,
+					Description: fmt.Sprintf("Qualify %s.%s as %s.%s", from, sel.Sel.Name, to, sel.Sel.Name),
+				})
+			}
+		}
+		return true
+	})
+	return changes
+}
+
+// fileOffset returns the byte offset of pos in its file.
+func fileOffset(fset *token.FileSet, pos token.Pos) int {
+	return fset.Position(pos).Offset
+}
+
 // CleanAliasesOperation implements cleaning import aliases
 type CleanAliasesOperation struct {
 	Request types.CleanAliasesRequest
@@ -27,7 +84,7 @@ func (op *CleanAliasesOperation) Validate(ws *types.Workspace) error {
 	if op.Request.Workspace == "" {
 		return fmt.Errorf("workspace path cannot be empty")
 	}
-	return nil
+	return validateAliasScope(ws, op.Request.Packages)
 }
 
 func (op *CleanAliasesOperation) Execute(ws *types.Workspace) (*types.RefactoringPlan, error) {
@@ -39,9 +96,9 @@ func (op *CleanAliasesOperation) Execute(ws *types.Workspace) (*types.Refactorin
 	}
 
 	// Analyze all files for import aliases
-	for _, pkg := range ws.Packages {
+	for _, pkg := range aliasScope(ws, op.Request.Packages) {
 		for _, file := range pkg.Files {
-			changes := op.cleanFileAliases(file)
+			changes := op.cleanFileAliases(ws.FileSet, file)
 			plan.Changes = append(plan.Changes, changes...)
 			if len(changes) > 0 {
 				plan.AffectedFiles = append(plan.AffectedFiles, file.Path)
@@ -52,7 +109,7 @@ func (op *CleanAliasesOperation) Execute(ws *types.Workspace) (*types.Refactorin
 	return plan, nil
 }
 
-func (op *CleanAliasesOperation) cleanFileAliases(file *types.File) []types.Change {
+func (op *CleanAliasesOperation) cleanFileAliases(fset *token.FileSet, file *types.File) []types.Change {
 	var changes []types.Change
 
 	if file.AST == nil {
@@ -81,11 +138,12 @@ func (op *CleanAliasesOperation) cleanFileAliases(file *types.File) []types.Chan
 			}
 
 			if !op.Request.PreserveConflicts || !op.wouldCauseConflict(alias, importPath, file) {
+				start, end := fileOffset(fset, importSpec.Name.Pos()), fileOffset(fset, importSpec.Path.Pos()) // Include space after alias
 				changes = append(changes, types.Change{
 					File:        file.Path,
-					Start:       int(importSpec.Name.Pos()),
-					End:         int(importSpec.Name.End()),
-					OldText:     alias,
+					Start:       start,
+					End:         end,
+					OldText:     string(file.OriginalContent[start:end]),
 					NewText:     "",
 					Description: fmt.Sprintf("Remove import alias '%s' for package %s", alias, importPath),
 				})
@@ -185,7 +243,7 @@ func (op *StandardizeImportsOperation) Validate(ws *types.Workspace) error {
 		}
 	}
 
-	return nil
+	return validateAliasScope(ws, op.Request.Packages)
 }
 
 func (op *StandardizeImportsOperation) Execute(ws *types.Workspace) (*types.RefactoringPlan, error) {
@@ -197,9 +255,9 @@ func (op *StandardizeImportsOperation) Execute(ws *types.Workspace) (*types.Refa
 	}
 
 	// Apply standardization rules to all files
-	for _, pkg := range ws.Packages {
+	for _, pkg := range aliasScope(ws, op.Request.Packages) {
 		for _, file := range pkg.Files {
-			changes := op.standardizeFileImports(file)
+			changes := op.standardizeFileImports(ws.FileSet, file)
 			plan.Changes = append(plan.Changes, changes...)
 			if len(changes) > 0 {
 				plan.AffectedFiles = append(plan.AffectedFiles, file.Path)
@@ -210,7 +268,7 @@ func (op *StandardizeImportsOperation) Execute(ws *types.Workspace) (*types.Refa
 	return plan, nil
 }
 
-func (op *StandardizeImportsOperation) standardizeFileImports(file *types.File) []types.Change {
+func (op *StandardizeImportsOperation) standardizeFileImports(fset *token.FileSet, file *types.File) []types.Change {
 	var changes []types.Change
 
 	if file.AST == nil {
@@ -229,15 +287,20 @@ func (op *StandardizeImportsOperation) standardizeFileImports(file *types.File)
 					if importSpec.Name != nil {
 						currentAlias = importSpec.Name.Name
 					}
+					if currentAlias == "." || currentAlias == "_" {
+						break
+					}
 
 					if currentAlias != rule.Alias {
+						// Uses of the package follow its new name
+						changes = append(changes, requalifyChanges(fset, file, cmp.Or(currentAlias, filepath.Base(importPath)), rule.Alias)...)
 						// Need to update alias
 						if importSpec.Name != nil {
 							// Update existing alias
 							changes = append(changes, types.Change{
 								File:        file.Path,
-								Start:       int(importSpec.Name.Pos()),
-								End:         int(importSpec.Name.End()),
+								Start:       fileOffset(fset, importSpec.Name.Pos()),
+								End:         fileOffset(fset, importSpec.Name.End()),
 								OldText:     currentAlias,
 								NewText:     rule.Alias,
 								Description: fmt.Sprintf("Standardize import alias for %s from '%s' to '%s'", importPath, currentAlias, rule.Alias),
@@ -246,8 +309,8 @@ func (op *StandardizeImportsOperation) standardizeFileImports(file *types.File)
 							// Add new alias
 							changes = append(changes, types.Change{
 								File:        file.Path,
-								Start:       int(importSpec.Path.Pos()),
-								End:         int(importSpec.Path.Pos()),
+								Start:       fileOffset(fset, importSpec.Path.Pos()),
+								End:         fileOffset(fset, importSpec.Path.Pos()),
 								OldText:     "",
 								NewText:     rule.Alias + " ",
 								Description: fmt.Sprintf("Add import alias '%s' for package %s", rule.Alias, importPath),
@@ -265,6 +328,12 @@ func (op *StandardizeImportsOperation) standardizeFileImports(file *types.File)
 }
 
 func (op *StandardizeImportsOperation) matchesPattern(importPath, pattern string) bool {
+	return matchesAliasPattern(importPath, pattern)
+}
+
+// matchesAliasPattern reports whether an alias rule's package pattern
+// applies to importPath.
+func matchesAliasPattern(importPath, pattern string) bool {
 	// Simple pattern matching - could be enhanced with glob patterns
 	return strings.Contains(importPath, pattern) || importPath == pattern
 }
@@ -286,7 +355,7 @@ func (op *ResolveAliasConflictsOperation) Validate(ws *types.Workspace) error {
 	if op.Request.Workspace == "" {
 		return fmt.Errorf("workspace path cannot be empty")
 	}
-	return nil
+	return validateAliasScope(ws, op.Request.Packages)
 }
 
 func (op *ResolveAliasConflictsOperation) Execute(ws *types.Workspace) (*types.RefactoringPlan, error) {
@@ -298,10 +367,10 @@ func (op *ResolveAliasConflictsOperation) Execute(ws *types.Workspace) (*types.R
 	}
 
 	// Detect and resolve conflicts in each file
-	for _, pkg := range ws.Packages {
+	for _, pkg := range aliasScope(ws, op.Request.Packages) {
 		for _, file := range pkg.Files {
 			conflicts := op.detectConflicts(file)
-			changes := op.resolveConflicts(file, conflicts)
+			changes := op.resolveConflicts(ws.FileSet, file, conflicts)
 			plan.Changes = append(plan.Changes, changes...)
 			if len(changes) > 0 {
 				plan.AffectedFiles = append(plan.AffectedFiles, file.Path)
@@ -342,7 +411,7 @@ func (op *ResolveAliasConflictsOperation) detectConflicts(file *types.File) map[
 	return conflicts
 }
 
-func (op *ResolveAliasConflictsOperation) resolveConflicts(file *types.File, conflicts map[string][]string) []types.Change {
+func (op *ResolveAliasConflictsOperation) resolveConflicts(fset *token.FileSet, file *types.File, conflicts map[string][]string) []types.Change {
 	if file.AST == nil {
 		return nil
 	}
@@ -361,8 +430,8 @@ func (op *ResolveAliasConflictsOperation) resolveConflicts(file *types.File, con
 			return true
 		}
 		specPositions[specKey{a, importPath}] = [2]int{
-			int(importSpec.Name.Pos()),
-			int(importSpec.Name.End()),
+			fileOffset(fset, importSpec.Name.Pos()),
+			fileOffset(fset, importSpec.Name.End()),
 		}
 		return true
 	})
@@ -431,7 +500,7 @@ func (op *ConvertAliasesOperation) Validate(ws *types.Workspace) error {
 	if !op.Request.ToFullNames && !op.Request.FromFullNames {
 		return fmt.Errorf("must specify either ToFullNames or FromFullNames")
 	}
-	return nil
+	return validateAliasScope(ws, op.Request.Packages)
 }
 
 func (op *ConvertAliasesOperation) Execute(ws *types.Workspace) (*types.RefactoringPlan, error) {
@@ -443,13 +512,13 @@ func (op *ConvertAliasesOperation) Execute(ws *types.Workspace) (*types.Refactor
 	}
 
 	// Convert aliases in all files
-	for _, pkg := range ws.Packages {
+	for _, pkg := range aliasScope(ws, op.Request.Packages) {
 		for _, file := range pkg.Files {
 			var changes []types.Change
 			if op.Request.ToFullNames {
-				changes = op.convertToFullNames(file)
+				changes = op.convertToFullNames(ws.FileSet, file)
 			} else {
-				changes = op.convertToAliases(file)
+				changes = op.convertToAliases(ws.FileSet, file)
 			}
 			
 			plan.Changes = append(plan.Changes, changes...)
@@ -462,7 +531,7 @@ func (op *ConvertAliasesOperation) Execute(ws *types.Workspace) (*types.Refactor
 	return plan, nil
 }
 
-func (op *ConvertAliasesOperation) convertToFullNames(file *types.File) []types.Change {
+func (op *ConvertAliasesOperation) convertToFullNames(fset *token.FileSet, file *types.File) []types.Change {
 	var changes []types.Change
 
 	if file.AST == nil {
@@ -477,18 +546,16 @@ func (op *ConvertAliasesOperation) convertToFullNames(file *types.File) []types.
 				importPath := strings.Trim(importSpec.Path.Value, `"`)
 
 				// Remove the alias from import
+				start, end := fileOffset(fset, importSpec.Name.Pos()), fileOffset(fset, importSpec.Path.Pos()) // Include space after alias
 				changes = append(changes, types.Change{
 					File:        file.Path,
-					Start:       int(importSpec.Name.Pos()),
-					End:         int(importSpec.Name.End() + token.Pos(len(" "))), // Include space after alias
-					OldText:     alias + " ",
+					Start:       start,
+					End:         end,
+					OldText:     string(file.OriginalContent[start:end]),
 					NewText:     "",
 					Description: fmt.Sprintf("Remove alias '%s' for package %s", alias, importPath),
 				})
-
-				// Would need additional logic to find and replace all references
-				// from alias.Symbol to packageName.Symbol
-				// This is a complex operation that requires symbol resolution
+				changes = append(changes, requalifyChanges(fset, file, alias, filepath.Base(importPath))...)
 			}
 		}
 		return true
@@ -497,7 +564,7 @@ func (op *ConvertAliasesOperation) convertToFullNames(file *types.File) []types.
 	return changes
 }
 
-func (op *ConvertAliasesOperation) convertToAliases(file *types.File) []types.Change {
+func (op *ConvertAliasesOperation) convertToAliases(fset *token.FileSet, file *types.File) []types.Change {
 	var changes []types.Change
 
 	if file.AST == nil {
@@ -517,12 +584,13 @@ func (op *ConvertAliasesOperation) convertToAliases(file *types.File) []types.Ch
 				// Add alias to import
 				changes = append(changes, types.Change{
 					File:        file.Path,
-					Start:       int(importSpec.Path.Pos()),
-					End:         int(importSpec.Path.Pos()),
+					Start:       fileOffset(fset, importSpec.Path.Pos()),
+					End:         fileOffset(fset, importSpec.Path.Pos()),
 					OldText:     "",
 					NewText:     alias + " ",
 					Description: fmt.Sprintf("Add alias '%s' for package %s", alias, importPath),
 				})
+				changes = append(changes, requalifyChanges(fset, file, packageName, alias)...)
 			}
 		}
 		return true
@@ -538,8 +606,19 @@ func (op *ConvertAliasesOperation) generateAlias(importPath string) string {
 	
 	// For common patterns, generate reasonable aliases
 	if strings.Contains(importPath, "github.com") && len(parts) >= 3 {
-		return strings.ToLower(parts[len(parts)-1][:3])  // First 3 characters
+		return strings.ToLower(firstRunes(packageName, 3)) // First 3 characters
 	}
 	
 	return packageName
-}
\ No newline at end of file
+}
+
+// firstRunes returns the first n runes of s, or s itself if it is shorter.
+func firstRunes(s string, n int) string {
+	for i := range s {
+		if n == 0 {
+			return s[:i]
+		}
+		n--
+	}
+	return s
+}
@@ -0,0 +1,77 @@
+package refactor
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+var aliasFiles = map[string]string{
+	"a/a.go": "package a\n\nimport strings \"strings\"\n\nvar X = strings.ToUpper(\"a\")\n",
+	"b/b.go": "package b\n\nimport (\n\tfmt \"fmt\"\n\tstr \"strings\"\n)\n\nvar Y = fmt.Sprint(str.TrimSpace(\" 1 \"))\n",
+}
+
+func TestCleanAliases(t *testing.T) {
+	engine, ws, dir := loadTestModuleWith(t, aliasFiles)
+
+	plan, err := engine.CleanAliases(ws, types.CleanAliasesRequest{Workspace: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"a/a.go": "package a\n\nimport \"strings\"\n\nvar X = strings.ToUpper(\"a\")\n",
+		"b/b.go": "package b\n\nimport (\n\t\"fmt\"\n\tstr \"strings\"\n)\n\nvar Y = fmt.Sprint(str.TrimSpace(\" 1 \"))\n",
+	}
+	for name, w := range want {
+		if got := planContent(t, plan, filepath.Join(dir, name)); got != w {
+			t.Errorf("%s:\n%s\nwant:\n%s", name, got, w)
+		}
+	}
+
+	plan, err = engine.CleanAliases(ws, types.CleanAliasesRequest{Workspace: dir, Packages: []string{filepath.Join(dir, "b")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.AffectedFiles) != 1 || plan.AffectedFiles[0] != filepath.Join(dir, "b", "b.go") {
+		t.Errorf("scoped to b, the plan affects %v", plan.AffectedFiles)
+	}
+
+	if _, err := engine.CleanAliases(ws, types.CleanAliasesRequest{Workspace: dir, Packages: []string{filepath.Join(dir, "c")}}); err == nil {
+		t.Error("expected an error for a package that doesn't exist")
+	}
+}
+
+func TestStandardizeImports(t *testing.T) {
+	engine, ws, dir := loadTestModuleWith(t, aliasFiles)
+
+	plan, err := engine.StandardizeImports(ws, types.StandardizeImportsRequest{
+		Workspace: dir,
+		Rules:     []types.AliasRule{{PackagePattern: "strings", Alias: "strs"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"a/a.go": "package a\n\nimport strs \"strings\"\n\nvar X = strs.ToUpper(\"a\")\n",
+		"b/b.go": "package b\n\nimport (\n\tfmt \"fmt\"\n\tstrs \"strings\"\n)\n\nvar Y = fmt.Sprint(strs.TrimSpace(\" 1 \"))\n",
+	}
+	for name, w := range want {
+		if got := planContent(t, plan, filepath.Join(dir, name)); got != w {
+			t.Errorf("%s:\n%s\nwant:\n%s", name, got, w)
+		}
+	}
+}
+
+func TestConvertAliases_ToFullNames(t *testing.T) {
+	engine, ws, dir := loadTestModuleWith(t, aliasFiles)
+
+	plan, err := engine.ConvertAliases(ws, types.ConvertAliasesRequest{Workspace: dir, ToFullNames: true, Packages: []string{filepath.Join(dir, "b")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "package b\n\nimport (\n\t\"fmt\"\n\t\"strings\"\n)\n\nvar Y = fmt.Sprint(strings.TrimSpace(\" 1 \"))\n"
+	if got := planContent(t, plan, filepath.Join(dir, "b", "b.go")); got != want {
+		t.Errorf("b/b.go:\n%s\nwant:\n%s", got, want)
+	}
+}
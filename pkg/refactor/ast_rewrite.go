@@ -0,0 +1,440 @@
+package refactor
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// ChangeBackend selects how an operation turns its edits into changes.
+type ChangeBackend string
+
+const (
+	// ChangeBackendAST addresses edits by the nodes of the file's syntax
+	// tree, prints the edited file with go/printer and emits the line diff
+	// against the original as changes. It is the default.
+	ChangeBackendAST ChangeBackend = "ast"
+	// ChangeBackendText emits edits as changes at offsets computed from
+	// line numbers, as operations did before the AST backend.
+	ChangeBackendText ChangeBackend = "text"
+)
+
+// ParseChangeBackend returns the backend named s; empty is the AST backend.
+func ParseChangeBackend(s string) (ChangeBackend, error) {
+	switch b := ChangeBackend(s); b {
+	case "", ChangeBackendAST:
+		return ChangeBackendAST, nil
+	case ChangeBackendText:
+		return b, nil
+	}
+	return "", fmt.Errorf("unknown change backend %q (want ast or text)", s)
+}
+
+// astRewrite collects the edits of an operation to one Go file and computes
+// its changes from them. Edits are positioned by the file's syntax tree, so
+// tabs, multi-byte characters and several edits on a line don't shift them;
+// edits overlapping each other are an error instead of corrupting the file.
+type astRewrite struct {
+	file  string
+	src   []byte
+	fset  *token.FileSet
+	ast   *ast.File
+	edits []astEdit
+}
+
+// astEdit replaces the bytes start:end of the original source with text.
+type astEdit struct {
+	start, end  int
+	text        string
+	description string
+}
+
+// newASTRewrite parses src, the content of file, for rewriting.
+func newASTRewrite(file string, src []byte) (*astRewrite, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, file, src, parser.ParseComments)
+	if err != nil {
+		return nil, &types.RefactorError{
+			Type:    types.ParseError,
+			Message: fmt.Sprintf("failed to parse %s: %v", file, err),
+		}
+	}
+	return &astRewrite{file: file, src: src, fset: fset, ast: f}, nil
+}
+
+// offset returns the byte offset of pos in the source.
+func (r *astRewrite) offset(pos token.Pos) int {
+	return r.fset.File(pos).Offset(pos)
+}
+
+// replace replaces the source from the start of from to the end of to.
+func (r *astRewrite) replace(from, to ast.Node, text, description string) {
+	r.edit(r.offset(from.Pos()), r.offset(to.End()), text, description)
+}
+
+// insert inserts text at pos.
+func (r *astRewrite) insert(pos token.Pos, text, description string) {
+	off := r.offset(pos)
+	r.edit(off, off, text, description)
+}
+
+// edit replaces the bytes start:end of the source, for offsets taken from
+// another parse of it.
+func (r *astRewrite) edit(start, end int, text, description string) {
+	r.edits = append(r.edits, astEdit{start: start, end: end, text: text, description: description})
+}
+
+// changes applies the edits, prints the result and returns the changes
+// turning the original source into it. A file that was gofmt-formatted is
+// printed with go/printer; others keep their layout outside the edits, so
+// the changes don't reformat code the operation didn't touch.
+func (r *astRewrite) changes() ([]types.Change, error) {
+	edits := slices.Clone(r.edits)
+	sort.SliceStable(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+
+	var b bytes.Buffer
+	prev := 0
+	for i, e := range edits {
+		if e.start < 0 || e.end < e.start || e.end > len(r.src) {
+			return nil, &types.RefactorError{
+				Type:    types.InvalidOperation,
+				Message: fmt.Sprintf("edit outside %s: %s", r.file, e.description),
+			}
+		}
+		if e.start < prev {
+			return nil, &types.RefactorError{
+				Type:    types.InvalidOperation,
+				Message: fmt.Sprintf("overlapping edits in %s: %s and %s", r.file, edits[i-1].description, e.description),
+			}
+		}
+		b.Write(r.src[prev:e.start])
+		b.WriteString(e.text)
+		prev = e.end
+	}
+	b.Write(r.src[prev:])
+	edited := b.Bytes()
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, r.file, edited, parser.ParseComments)
+	if err != nil {
+		return nil, &types.RefactorError{
+			Type:    types.ParseError,
+			Message: fmt.Sprintf("edits to %s don't produce valid Go: %v", r.file, err),
+		}
+	}
+	printed := edited
+	if formatted, err := format.Source(r.src); err == nil && bytes.Equal(formatted, r.src) {
+		var out bytes.Buffer
+		if err := format.Node(&out, fset, f); err != nil {
+			return nil, fmt.Errorf("failed to print %s: %w", r.file, err)
+		}
+		printed = out.Bytes()
+	}
+	return r.diff(edits, string(edited), string(printed)), nil
+}
+
+// diff returns the changes turning the original source into printed, one
+// per run of changed lines, described by the edits they contain. Lines no
+// edit touches are matched to their place in edited, and those to printed
+// by their longest common subsequence, so code an edit moves is never
+// matched to where it came from.
+func (r *astRewrite) diff(edits []astEdit, edited, printed string) []types.Change {
+	a := strings.SplitAfter(string(r.src), "\n")
+	e := strings.SplitAfter(edited, "\n")
+	p := strings.SplitAfter(printed, "\n")
+	offsets := make([]int, len(a)+1)
+	for i, line := range a {
+		offsets[i+1] = offsets[i] + len(line)
+	}
+
+	toPrinted := make(map[int]int)
+	for _, m := range matchLines(e, p) {
+		toPrinted[m[0]] = m[1]
+	}
+	matches := [][2]int{{-1, -1}}
+	shift, next := 0, 0
+	for i := range a {
+		start := offsets[i]
+		end := max(offsets[i+1]-1, start)
+		touched := false
+		for ; next < len(edits) && edits[next].end < start; next++ {
+			shift += strings.Count(edits[next].text, "\n") - strings.Count(string(r.src[edits[next].start:edits[next].end]), "\n")
+		}
+		for _, ed := range edits[next:] {
+			if ed.start > end {
+				break
+			}
+			touched = true
+		}
+		if j, ok := toPrinted[i+shift]; ok && !touched {
+			matches = append(matches, [2]int{i, j})
+		}
+	}
+	matches = append(matches, [2]int{len(a), len(p)})
+
+	var changes []types.Change
+	for k := 1; k < len(matches); k++ {
+		aStart, pStart := matches[k-1][0]+1, matches[k-1][1]+1
+		aEnd, pEnd := matches[k][0], matches[k][1]
+		if aStart == aEnd && pStart == pEnd {
+			continue
+		}
+		start, end := offsets[aStart], offsets[aEnd]
+		var descs []string
+		for _, ed := range edits {
+			if ed.start <= end && ed.end >= start && !slices.Contains(descs, ed.description) {
+				descs = append(descs, ed.description)
+			}
+		}
+		desc := strings.Join(descs, "; ")
+		if desc == "" {
+			desc = "Format edited code"
+		}
+		changes = append(changes, types.Change{
+			File:        r.file,
+			Start:       start,
+			End:         end,
+			OldText:     strings.Join(a[aStart:aEnd], ""),
+			NewText:     strings.Join(p[pStart:pEnd], ""),
+			Description: desc,
+			Checked:     true,
+		})
+	}
+	return changes
+}
+
+// maxDiffCells bounds the longest common subsequence table; lines between
+// the common prefix and suffix of larger differences are left unmatched.
+const maxDiffCells = 1 << 22
+
+// matchLines returns the index pairs of the lines a and b have in common,
+// in order: their common prefix and suffix and the longest common
+// subsequence of the lines between.
+func matchLines(a, b []string) [][2]int {
+	var matches [][2]int
+	lo := 0
+	for lo < len(a) && lo < len(b) && a[lo] == b[lo] {
+		matches = append(matches, [2]int{lo, lo})
+		lo++
+	}
+	ha, hb := len(a), len(b)
+	for ha > lo && hb > lo && a[ha-1] == b[hb-1] {
+		ha--
+		hb--
+	}
+	n, m := ha-lo, hb-lo
+	if n > 0 && m > 0 && n*m <= maxDiffCells {
+		lcs := make([][]int, n+1)
+		for i := range lcs {
+			lcs[i] = make([]int, m+1)
+		}
+		for i := n - 1; i >= 0; i-- {
+			for j := m - 1; j >= 0; j-- {
+				if a[lo+i] == b[lo+j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else {
+					lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+				}
+			}
+		}
+		for i, j := 0, 0; i < n && j < m; {
+			switch {
+			case a[lo+i] == b[lo+j]:
+				matches = append(matches, [2]int{lo + i, lo + j})
+				i++
+				j++
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				i++
+			default:
+				j++
+			}
+		}
+	}
+	for k := 0; ha+k < len(a); k++ {
+		matches = append(matches, [2]int{ha + k, hb + k})
+	}
+	return matches
+}
+
+// rewriteChanges returns changes, made at offsets into src, the content of
+// file, recomputed by an astRewrite.
+func rewriteChanges(file string, src []byte, changes []types.Change) ([]types.Change, error) {
+	r, err := newASTRewrite(file, src)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range changes {
+		r.edit(c.Start, c.End, c.NewText, c.Description)
+	}
+	return r.changes()
+}
+
+// rewritePlanChanges recomputes the changes of each Go file in changes with
+// an astRewrite, reading the files from ws.
+func rewritePlanChanges(ws *types.Workspace, changes []types.Change) ([]types.Change, error) {
+	var files []string
+	byFile := make(map[string][]types.Change)
+	for _, c := range changes {
+		if _, ok := byFile[c.File]; !ok {
+			files = append(files, c.File)
+		}
+		byFile[c.File] = append(byFile[c.File], c)
+	}
+
+	var out []types.Change
+	for _, file := range files {
+		src, ok := workspaceSource(ws, file)
+		if !ok || !strings.HasSuffix(file, ".go") {
+			out = append(out, byFile[file]...)
+			continue
+		}
+		rewritten, err := rewriteChanges(file, src, byFile[file])
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, rewritten...)
+	}
+	return out, nil
+}
+
+// workspaceSource returns the content of the workspace file named by name:
+// its key in a package, its path, or its base name.
+func workspaceSource(ws *types.Workspace, name string) ([]byte, bool) {
+	var byBase *types.File
+	for _, pkg := range ws.Packages {
+		for key, f := range pkg.Files {
+			switch {
+			case key == name || f.Path == name:
+				return f.OriginalContent, f.OriginalContent != nil
+			case byBase == nil && filepath.Base(f.Path) == filepath.Base(name):
+				byBase = f
+			}
+		}
+	}
+	if byBase == nil || byBase.OriginalContent == nil {
+		return nil, false
+	}
+	return byBase.OriginalContent, true
+}
+
+// closingLines returns end, or the last line of the statement starting
+// on lines start through end that continues past end with nothing but
+// closing brackets, as when a selection of a loop or block stops short of
+// its closing brace.
+func closingLines(fset *token.FileSet, f *ast.File, src []byte, start, end int) int {
+	line := func(pos token.Pos) int { return fset.Position(pos).Line }
+	tf := fset.File(f.Pos())
+	if tf == nil || end >= tf.LineCount() {
+		return end
+	}
+	last := end
+	ast.Inspect(f, func(n ast.Node) bool {
+		s, ok := n.(ast.Stmt)
+		if !ok || last != end {
+			return last == end
+		}
+		if _, block := s.(*ast.BlockStmt); block || line(s.Pos()) < start || line(s.Pos()) > end || line(s.End()) <= end {
+			return true
+		}
+		rest := src[tf.Offset(tf.LineStart(end+1)):tf.Offset(s.End())]
+		if strings.Trim(string(rest), " \t\n})]") == "" {
+			last = line(s.End())
+		}
+		return false
+	})
+	return last
+}
+
+// checkStatementLines returns an error unless lines start through end of
+// r's file hold whole statements of one statement list, apart from blank
+// lines and comments.
+func (r *astRewrite) checkStatementLines(start, end int) error {
+	line := func(pos token.Pos) int { return r.fset.Position(pos).Line }
+
+	var first, last ast.Stmt
+	ast.Inspect(r.ast, func(n ast.Node) bool {
+		if first != nil || n == nil {
+			return false
+		}
+		var list []ast.Stmt
+		switch n := n.(type) {
+		case *ast.BlockStmt:
+			list = n.List
+		case *ast.CaseClause:
+			list = n.Body
+		case *ast.CommClause:
+			list = n.Body
+		default:
+			return true
+		}
+		for _, s := range list {
+			if line(s.Pos()) >= start && line(s.End()) <= end {
+				if first == nil {
+					first = s
+				}
+				last = s
+			}
+		}
+		return first == nil
+	})
+
+	err := &types.RefactorError{
+		Type:    types.InvalidOperation,
+		Message: fmt.Sprintf("lines %d-%d of %s don't span whole statements", start, end, r.file),
+	}
+	if first == nil {
+		return err
+	}
+	tf := r.fset.File(first.Pos())
+	for l := start; l <= end && l <= tf.LineCount(); l++ {
+		lineStart := tf.Offset(tf.LineStart(l))
+		lineEnd := len(r.src)
+		if l < tf.LineCount() {
+			lineEnd = tf.Offset(tf.LineStart(l + 1))
+		}
+		text := strings.TrimSpace(string(r.src[lineStart:lineEnd]))
+		if text == "" || strings.HasPrefix(text, "//") {
+			continue
+		}
+		if l < line(first.Pos()) || l > line(last.End()) {
+			return err
+		}
+	}
+	return nil
+}
+
+// lineSpan returns the offsets of lines start through end of r's file,
+// from the first non-blank byte of start to the end of end's content,
+// excluding its newline.
+func (r *astRewrite) lineSpan(start, end int) (int, int) {
+	tf := r.fset.File(r.ast.Pos())
+	from := tf.Offset(tf.LineStart(start))
+	for from < len(r.src) && (r.src[from] == ' ' || r.src[from] == '\t') {
+		from++
+	}
+	to := len(r.src)
+	if end < tf.LineCount() {
+		to = tf.Offset(tf.LineStart(end+1)) - 1
+	}
+	return from, to
+}
+
+// indentAt returns the indentation of the line holding pos.
+func (r *astRewrite) indentAt(pos token.Pos) string {
+	tf := r.fset.File(pos)
+	lineStart := tf.Offset(tf.LineStart(tf.Line(pos)))
+	off := lineStart
+	for off < len(r.src) && (r.src[off] == ' ' || r.src[off] == '\t') {
+		off++
+	}
+	return string(r.src[lineStart:off])
+}
@@ -0,0 +1,139 @@
+package refactor
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// singleFileWorkspace returns a workspace holding src as /tmp/p/p.go.
+func singleFileWorkspace(src string) *types.Workspace {
+	return &types.Workspace{
+		RootPath: "/tmp/p",
+		Packages: map[string]*types.Package{
+			"/tmp/p": {
+				Name: "p",
+				Path: "/tmp/p",
+				Dir:  "/tmp/p",
+				Files: map[string]*types.File{
+					"p.go": {Path: "/tmp/p/p.go", OriginalContent: []byte(src)},
+				},
+			},
+		},
+	}
+}
+
+func TestExtractVariableOperation_ASTBackend(t *testing.T) {
+	src := "package p\n\nfunc f(s string) int {\n\tname := \"héllo\"\n\tif len(s)+len(name) > 2 {\n\t\treturn 1\n\t}\n\treturn 0\n}\n"
+	op := &ExtractVariableOperation{
+		SourceFile:   "p.go",
+		StartLine:    5,
+		EndLine:      5,
+		VariableName: "n",
+		Expression:   "len(s) + len(name)",
+	}
+	plan, err := op.Execute(singleFileWorkspace(src))
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	got, err := applyInMemory(src, plan.Changes)
+	if err != nil {
+		t.Fatalf("applying changes: %v", err)
+	}
+	want := "package p\n\nfunc f(s string) int {\n\tname := \"héllo\"\n\tn := len(s) + len(name)\n\tif n > 2 {\n\t\treturn 1\n\t}\n\treturn 0\n}\n"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+	for _, c := range plan.Changes {
+		if !c.Checked {
+			t.Errorf("change %q not marked checked", c.Description)
+		}
+	}
+}
+
+func TestExtractVariableOperation_TextBackend(t *testing.T) {
+	src := "package p\n\nfunc f(s string) int {\n\treturn len(s) + 1\n}\n"
+	op := &ExtractVariableOperation{
+		SourceFile:   "p.go",
+		StartLine:    4,
+		EndLine:      4,
+		VariableName: "n",
+		Expression:   "len(s) + 1",
+		Backend:      ChangeBackendText,
+	}
+	plan, err := op.Execute(singleFileWorkspace(src))
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if len(plan.Changes) != 2 || plan.Changes[1].NewText != "n" || plan.Changes[0].Checked {
+		t.Errorf("unexpected text backend changes %+v", plan.Changes)
+	}
+}
+
+func TestExtractFunctionOperation_PartialStatements(t *testing.T) {
+	src := "package p\n\nfunc f(xs []int) int {\n\ttotal := 0\n\tfor _, x := range xs {\n\t\ttotal += x\n\t}\n\treturn total\n}\n"
+	op := &ExtractFunctionOperation{SourceFile: "p.go", StartLine: 4, EndLine: 5, NewFunctionName: "sum"}
+	if _, err := op.Execute(singleFileWorkspace(src)); err == nil || !strings.Contains(err.Error(), "whole statements") {
+		t.Errorf("expected a whole statements error, got %v", err)
+	}
+}
+
+func TestExtractFunctionOperation_SelectionShortOfClosingBrace(t *testing.T) {
+	src := "package p\n\nfunc f(xs []int) int {\n\ttotal := 0\n\tfor _, x := range xs {\n\t\ttotal += x\n\t}\n\treturn total\n}\n"
+	op := &ExtractFunctionOperation{SourceFile: "p.go", StartLine: 4, EndLine: 6, NewFunctionName: "sum"}
+	plan, err := op.Execute(singleFileWorkspace(src))
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	got, err := applyInMemory(src, plan.Changes)
+	if err != nil {
+		t.Fatalf("applying changes: %v", err)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "p.go", got, 0); err != nil {
+		t.Errorf("expected the loop to be extracted with its closing brace, got:\n%s\n%v", got, err)
+	}
+}
+
+func TestASTRewrite_OverlappingEdits(t *testing.T) {
+	src := "package p\n\nvar x = 1 + 2\n"
+	r, err := newASTRewrite("p.go", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := strings.Index(src, "1 + 2")
+	r.edit(start, start+5, "3", "fold")
+	r.edit(start+4, start+5, "4", "bump")
+	if _, err := r.changes(); err == nil || !strings.Contains(err.Error(), "overlapping") {
+		t.Errorf("expected an overlapping edits error, got %v", err)
+	}
+}
+
+func TestASTRewrite_MovedCodeStaysOneChange(t *testing.T) {
+	src := "package p\n\nfunc a() {\n\tprintln(1)\n}\n\nfunc b() {\n\tprintln(1)\n\tprintln(2)\n}\n"
+	r, err := newASTRewrite("p.go", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := r.ast.Decls[1].(*ast.FuncDecl).Body
+	r.replace(body.List[0], body.List[1], "c()", "Replace with call to c")
+	r.insert(r.ast.Decls[0].End(), "\n\nfunc c() {\n\tprintln(1)\n\tprintln(2)\n}", "Add c")
+	changes, err := r.changes()
+	if err != nil {
+		t.Fatalf("changes: %v", err)
+	}
+	if len(changes) != 2 || !strings.Contains(changes[0].NewText, "func c() {\n\tprintln(1)\n\tprintln(2)\n}\n") {
+		t.Errorf("unexpected changes %+v", changes)
+	}
+	got, err := applyInMemory(src, changes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "package p\n\nfunc a() {\n\tprintln(1)\n}\n\nfunc c() {\n\tprintln(1)\n\tprintln(2)\n}\n\nfunc b() {\n\tc()\n}\n"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,302 @@
+package refactor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// ParseBatchFile decodes a batch file. YAML and JSON are both accepted.
+func ParseBatchFile(data []byte) (*types.BatchFile, error) {
+	var doc any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse batch file: %w", err)
+	}
+	if doc == nil {
+		return nil, fmt.Errorf("batch file is empty")
+	}
+	// Round-trip through JSON so the steps decode with the same field
+	// names and checks as every other request.
+	j, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse batch file: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(j))
+	dec.DisallowUnknownFields()
+	var file types.BatchFile
+	if err := dec.Decode(&file); err != nil {
+		return nil, fmt.Errorf("invalid batch file: %w", err)
+	}
+	if file.Version > 1 {
+		return nil, fmt.Errorf("unsupported batch file version %d", file.Version)
+	}
+	return &file, nil
+}
+
+// batchStep is a step ready to plan, from either a BatchStep or a legacy
+// operation string.
+type batchStep struct {
+	name      string
+	desc      string
+	dependsOn []string
+	optional  bool
+	plan      func(e *DefaultEngine, ws *types.Workspace) (*types.RefactoringPlan, error)
+}
+
+// batchSteps turns a request into steps, reading File if it is set.
+func batchSteps(root string, req types.BatchOperationRequest) ([]batchStep, bool, error) {
+	sources := 0
+	for _, set := range []bool{len(req.Operations) > 0, len(req.Steps) > 0, req.File != ""} {
+		if set {
+			sources++
+		}
+	}
+	switch {
+	case sources == 0:
+		return nil, false, fmt.Errorf("no operations specified for batch execution")
+	case sources > 1:
+		return nil, false, fmt.Errorf("specify only one of operations, steps and file")
+	}
+
+	rollback := req.RollbackOnFailure
+	specs := req.Steps
+	if req.File != "" {
+		path := req.File
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(root, path)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to read batch file: %w", err)
+		}
+		file, err := ParseBatchFile(data)
+		if err != nil {
+			return nil, false, err
+		}
+		specs = file.Steps
+		rollback = rollback || file.RollbackOnFailure
+	}
+
+	var steps []batchStep
+	for i, opStr := range req.Operations {
+		steps = append(steps, batchStep{
+			name: fmt.Sprintf("operation %d", i+1),
+			desc: opStr,
+			plan: func(_ *DefaultEngine, ws *types.Workspace) (*types.RefactoringPlan, error) {
+				op, err := parseOperationString(opStr)
+				if err != nil {
+					return nil, err
+				}
+				if err := op.Validate(ws); err != nil {
+					return nil, fmt.Errorf("validation failed: %w", err)
+				}
+				return op.Execute(ws)
+			},
+		})
+	}
+	for _, spec := range specs {
+		if spec.Name == "" {
+			return nil, false, fmt.Errorf("batch step with operation %q has no name", spec.Operation)
+		}
+		planner, ok := batchPlanners[spec.Operation]
+		if !ok {
+			return nil, false, fmt.Errorf("step %q: operation %q can't run in a batch; supported operations: %s",
+				spec.Name, spec.Operation, strings.Join(slices.Sorted(maps.Keys(batchPlanners)), ", "))
+		}
+		steps = append(steps, batchStep{
+			name:      spec.Name,
+			desc:      spec.Operation,
+			dependsOn: spec.DependsOn,
+			optional:  spec.Optional,
+			plan: func(e *DefaultEngine, ws *types.Workspace) (*types.RefactoringPlan, error) {
+				data, err := json.Marshal(batchRequest(ws, spec.Request))
+				if err != nil {
+					return nil, err
+				}
+				return planner(e, ws, spec.Operation, data)
+			},
+		})
+	}
+	return steps, rollback, nil
+}
+
+// orderBatchSteps returns the order to run steps in: as listed, except that
+// a step waits for the steps it depends on.
+func orderBatchSteps(steps []batchStep) ([]int, error) {
+	index := make(map[string]int, len(steps))
+	for i, s := range steps {
+		if _, dup := index[s.name]; dup {
+			return nil, fmt.Errorf("duplicate batch step name %q", s.name)
+		}
+		index[s.name] = i
+	}
+	waiting := make([]int, len(steps))
+	dependents := make([][]int, len(steps))
+	for i, s := range steps {
+		for _, dep := range s.dependsOn {
+			j, ok := index[dep]
+			if !ok {
+				return nil, fmt.Errorf("step %q depends on unknown step %q", s.name, dep)
+			}
+			if j == i {
+				return nil, fmt.Errorf("step %q depends on itself", s.name)
+			}
+			waiting[i]++
+			dependents[j] = append(dependents[j], i)
+		}
+	}
+
+	var order []int
+	done := make([]bool, len(steps))
+	for len(order) < len(steps) {
+		next := slices.IndexFunc(steps, func(s batchStep) bool {
+			i := index[s.name]
+			return !done[i] && waiting[i] == 0
+		})
+		if next < 0 {
+			var stuck []string
+			for i, s := range steps {
+				if !done[i] {
+					stuck = append(stuck, s.name)
+				}
+			}
+			return nil, fmt.Errorf("batch steps depend on each other in a cycle: %s", strings.Join(stuck, ", "))
+		}
+		done[next] = true
+		order = append(order, next)
+		for _, d := range dependents[next] {
+			waiting[d]--
+		}
+	}
+	return order, nil
+}
+
+// batchPlanner decodes a step's request and plans it.
+type batchPlanner func(e *DefaultEngine, ws *types.Workspace, name string, data []byte) (*types.RefactoringPlan, error)
+
+func planWith[R any](plan func(e *DefaultEngine, ws *types.Workspace, req R) (*types.RefactoringPlan, error)) batchPlanner {
+	return func(e *DefaultEngine, ws *types.Workspace, name string, data []byte) (*types.RefactoringPlan, error) {
+		var req R
+		if err := types.DecodeRequest(name, data, &req); err != nil {
+			return nil, err
+		}
+		return plan(e, ws, req)
+	}
+}
+
+// batchPlanners are the operations a batch step may run: those that plan
+// source changes. Batches, plan files, rollback and reports are left out.
+var batchPlanners = map[string]batchPlanner{
+	"move_symbol":             planWith((*DefaultEngine).MoveSymbol),
+	"rename_symbol":           planWith((*DefaultEngine).RenameSymbol),
+	"rename_package":          planWith((*DefaultEngine).RenamePackage),
+	"rename_interface_method": planWith((*DefaultEngine).RenameInterfaceMethod),
+	"rename_method":           planWith((*DefaultEngine).RenameMethod),
+	"extract_method":          planWith((*DefaultEngine).ExtractMethod),
+	"extract_function":        planWith((*DefaultEngine).ExtractFunction),
+	"extract_interface":       planWith((*DefaultEngine).ExtractInterface),
+	"extract_variable":        planWith((*DefaultEngine).ExtractVariable),
+	"inline_method":           planWith((*DefaultEngine).InlineMethod),
+	"inline_variable":         planWith((*DefaultEngine).InlineVariable),
+	"inline_function":         planWith((*DefaultEngine).InlineFunction),
+	"safe_delete":             planWith((*DefaultEngine).SafeDelete),
+	"encapsulate_field":       planWith((*DefaultEngine).EncapsulateField),
+	"shrink_interface":        planWith((*DefaultEngine).ShrinkInterface),
+	"segregate_interface":     planWith((*DefaultEngine).SegregateInterface),
+	"extract_test_helper":     planWith((*DefaultEngine).ExtractTestHelper),
+	"extract_pipeline_stages": planWith((*DefaultEngine).ExtractPipelineStages),
+	"build_tags":              planWith((*DefaultEngine).BuildTags),
+	"thread_context":          planWith((*DefaultEngine).ThreadContext),
+	"convert_type_alias":      planWith((*DefaultEngine).ConvertTypeAlias),
+	"introduce_type":          planWith((*DefaultEngine).IntroduceType),
+	"generate_mock":           planWith((*DefaultEngine).GenerateMock),
+	"update_mocks":            planWith((*DefaultEngine).UpdateMocks),
+	"fix_magic_numbers":       planWith((*DefaultEngine).FixMagicNumbers),
+	"organize_file":           planWith((*DefaultEngine).OrganizeFile),
+	"split_file":              planWith((*DefaultEngine).SplitFile),
+	"fix_error_returns":       planWith((*DefaultEngine).FixErrorReturns),
+	"fix_naming":              planWith((*DefaultEngine).FixNaming),
+	"bulk_rename":             planWith((*DefaultEngine).BulkRename),
+	"rewrite":                 planWith((*DefaultEngine).Rewrite),
+	"migrate":                 planWith((*DefaultEngine).Migrate),
+	"convert_receivers":       planWith((*DefaultEngine).ConvertReceivers),
+	"add_field":               planWith((*DefaultEngine).AddField),
+	"move_package":            planWith((*DefaultEngine).MovePackage),
+	"move_dir":                planWith((*DefaultEngine).MoveDir),
+	"move_packages":           planWith((*DefaultEngine).MovePackages),
+	"merge_packages":          planWith((*DefaultEngine).MergePackages),
+	"create_facade":           planWith((*DefaultEngine).CreateFacade),
+	"generate_facades":        planWith((*DefaultEngine).GenerateFacades),
+	"update_facades":          planWith((*DefaultEngine).UpdateFacades),
+	"clean_aliases":           planWith((*DefaultEngine).CleanAliases),
+	"standardize_imports":     planWith((*DefaultEngine).StandardizeImports),
+	"resolve_alias_conflicts": planWith((*DefaultEngine).ResolveAliasConflicts),
+	"convert_aliases":         planWith((*DefaultEngine).ConvertAliases),
+	"move_by_dependencies":    planWith((*DefaultEngine).MoveByDependencies),
+	"organize_by_layers":      planWith((*DefaultEngine).OrganizeByLayers),
+	"organize_by_domain":      planWith((*DefaultEngine).OrganizeByDomain),
+}
+
+// PlanStep plans one operation that a batch step may run, from its request
+// fields, resolving package and file paths the way a batch step does. The
+// plan is returned for review and is not applied.
+func (e *DefaultEngine) PlanStep(ws *types.Workspace, step types.BatchStep) (*types.RefactoringPlan, error) {
+	planner, ok := batchPlanners[step.Operation]
+	if !ok {
+		return nil, fmt.Errorf("operation %q can't be planned; supported operations: %s",
+			step.Operation, strings.Join(slices.Sorted(maps.Keys(batchPlanners)), ", "))
+	}
+	data, err := json.Marshal(batchRequest(ws, step.Request))
+	if err != nil {
+		return nil, err
+	}
+	return planner(e, ws, step.Operation, data)
+}
+
+// Request fields holding package paths and file paths. Package paths are
+// resolved the way the MCP tools resolve them; relative file paths are
+// taken from the workspace root.
+var (
+	batchPackageFields = []string{"package", "package_path", "from_package", "to_package", "source_package", "target_package"}
+	batchFileFields    = []string{"source_file", "target_file", "file"}
+)
+
+// batchRequest resolves the package and file paths of a step's request.
+func batchRequest(ws *types.Workspace, req map[string]any) map[string]any {
+	out := make(map[string]any, len(req))
+	for k, v := range req {
+		out[k] = v
+		s, ok := v.(string)
+		if !ok || s == "" {
+			continue
+		}
+		switch {
+		case slices.Contains(batchPackageFields, k):
+			out[k] = types.ResolvePackagePath(ws, s)
+		case slices.Contains(batchFileFields, k) && !filepath.IsAbs(s):
+			out[k] = filepath.Join(ws.RootPath, s)
+		}
+	}
+	return out
+}
+
+// batchStepError reports a failed step.
+type batchStepError struct {
+	step string
+	err  error
+}
+
+func (e *batchStepError) Error() string {
+	return fmt.Sprintf("step %q failed: %v", e.step, e.err)
+}
+
+func (e *batchStepError) Unwrap() error { return e.err }
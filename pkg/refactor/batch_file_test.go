@@ -0,0 +1,143 @@
+package refactor
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+func TestParseBatchFile(t *testing.T) {
+	file, err := ParseBatchFile([]byte(`
+version: 1
+steps:
+  - name: rename
+    operation: rename_symbol
+    request:
+      symbol_name: Add
+      new_name: Sum
+  - name: move
+    operation: move_symbol
+    depends_on: [rename]
+    optional: true
+    request:
+      symbol_name: Sum
+      from_package: calc
+      to_package: mathx
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(file.Steps) != 2 || file.Steps[1].DependsOn[0] != "rename" || !file.Steps[1].Optional {
+		t.Errorf("unexpected batch file: %+v", file)
+	}
+	if file.Steps[0].Request["new_name"] != "Sum" {
+		t.Errorf("request = %v", file.Steps[0].Request)
+	}
+
+	if _, err := ParseBatchFile([]byte("steps: []\nrollback: true\n")); err == nil {
+		t.Error("expected an error for an unknown field")
+	}
+	if _, err := ParseBatchFile([]byte(`{"version": 2, "steps": []}`)); err == nil {
+		t.Error("expected an error for an unsupported version")
+	}
+}
+
+func TestOrderBatchSteps(t *testing.T) {
+	steps := []batchStep{
+		{name: "c", dependsOn: []string{"b"}},
+		{name: "a"},
+		{name: "b", dependsOn: []string{"a"}},
+	}
+	order, err := orderBatchSteps(steps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, i := range order {
+		names = append(names, steps[i].name)
+	}
+	if got := strings.Join(names, ","); got != "a,b,c" {
+		t.Errorf("order = %s, want a,b,c", got)
+	}
+
+	tests := map[string][]batchStep{
+		"cycle":      {{name: "a", dependsOn: []string{"b"}}, {name: "b", dependsOn: []string{"a"}}},
+		"unknown":    {{name: "a", dependsOn: []string{"missing"}}},
+		"self":       {{name: "a", dependsOn: []string{"a"}}},
+		"duplicates": {{name: "a"}, {name: "a"}},
+	}
+	for name, steps := range tests {
+		if _, err := orderBatchSteps(steps); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestBatchOperations_StepsSeeEarlierSteps(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":       "module example.com/calc\n\ngo 1.21\n",
+		"calc/calc.go": "package calc\n\nfunc Add(a, b int) int {\n\treturn a + b\n}\n",
+		"main.go":      "package main\n\nimport \"example.com/calc/calc\"\n\nfunc main() {\n\t_ = calc.Add(1, 2)\n}\n",
+		"batch.yaml": `steps:
+  - name: rename
+    operation: rename_symbol
+    request: {symbol_name: Add, new_name: Sum}
+  - name: rename-again
+    operation: rename_symbol
+    depends_on: [rename]
+    request: {symbol_name: Sum, new_name: Total, package: calc}
+  - name: missing
+    operation: rename_symbol
+    optional: true
+    request: {symbol_name: Nope, new_name: Other}
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	engine := CreateEngineWithConfig(&EngineConfig{SkipCompilation: true}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ws, err := engine.LoadWorkspace(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := engine.BatchOperations(ws, types.BatchOperationRequest{File: "batch.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Changes) != 2 {
+		t.Fatalf("expected a change to each Go file, got %d", len(plan.Changes))
+	}
+	var warned bool
+	for _, issue := range plan.Impact.PotentialIssues {
+		warned = warned || (issue.Severity == types.Warning && strings.Contains(issue.Description, `"missing"`))
+	}
+	if !warned {
+		t.Errorf("expected a warning for the failed optional step, got %+v", plan.Impact.PotentialIssues)
+	}
+	if err := engine.ExecutePlan(plan); err != nil {
+		t.Fatal(err)
+	}
+
+	got, _ := os.ReadFile(filepath.Join(dir, "main.go"))
+	if !strings.Contains(string(got), "calc.Total(1, 2)") {
+		t.Errorf("main.go was not updated by both steps:\n%s", got)
+	}
+	if _, err := engine.BatchOperations(ws, types.BatchOperationRequest{
+		Steps: []types.BatchStep{{Name: "bad", Operation: "rename_symbol", Request: map[string]any{"symbol_name": "Total", "new_name": "Sum", "bogus": 1}}},
+	}); err == nil {
+		t.Error("expected an error for an invalid step request")
+	}
+}
@@ -3,10 +3,13 @@ package refactor
 import (
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"maps"
 	"os"
+	"slices"
 	"time"
 
+	"github.com/mamaar/gorefactor/pkg/history"
 	"github.com/mamaar/gorefactor/pkg/types"
 )
 
@@ -72,9 +75,12 @@ func parseOperationString(opStr string) (types.Operation, error) {
 	}
 }
 
-// BatchOperationOperation implements executing multiple operations atomically
+// BatchOperationOperation implements executing multiple operations atomically.
+// Steps are applied one after another to a VirtualWorkspace, and the plan
+// brings the files on disk to its final state.
 type BatchOperationOperation struct {
 	Request types.BatchOperationRequest
+	engine  *DefaultEngine
 }
 
 func (op *BatchOperationOperation) Type() types.OperationType {
@@ -82,69 +88,98 @@ func (op *BatchOperationOperation) Type() types.OperationType {
 }
 
 func (op *BatchOperationOperation) Description() string {
-	return fmt.Sprintf("Execute %d operations atomically", len(op.Request.Operations))
+	if op.Request.File != "" {
+		return fmt.Sprintf("Execute batch file %s", op.Request.File)
+	}
+	return fmt.Sprintf("Execute %d operations atomically", len(op.Request.Operations)+len(op.Request.Steps))
 }
 
 func (op *BatchOperationOperation) Validate(ws *types.Workspace) error {
-	if len(op.Request.Operations) == 0 {
-		return fmt.Errorf("no operations specified for batch execution")
+	steps, _, err := batchSteps(ws.RootPath, op.Request)
+	if err != nil {
+		return err
 	}
-	return nil
+	_, err = orderBatchSteps(steps)
+	return err
 }
 
 func (op *BatchOperationOperation) Execute(ws *types.Workspace) (*types.RefactoringPlan, error) {
-	// Parse all operation strings first so we fail fast on bad input.
-	operations := make([]types.Operation, 0, len(op.Request.Operations))
-	for i, opStr := range op.Request.Operations {
-		parsed, err := parseOperationString(opStr)
-		if err != nil {
-			return nil, fmt.Errorf("operation %d: %w", i+1, err)
-		}
-		operations = append(operations, parsed)
+	steps, rollback, err := batchSteps(ws.RootPath, op.Request)
+	if err != nil {
+		return nil, err
 	}
-
-	plan := &types.RefactoringPlan{
-		Operations:    []types.Operation{op},
-		Changes:       make([]types.Change, 0),
-		AffectedFiles: make([]string, 0),
-		Reversible:    true,
+	order, err := orderBatchSteps(steps)
+	if err != nil {
+		return nil, err
 	}
 
-	if op.Request.DryRun {
-		// Validate each and return descriptions only.
-		for i, parsed := range operations {
-			if err := parsed.Validate(ws); err != nil {
-				return nil, fmt.Errorf("operation %d validation failed: %w", i+1, err)
-			}
-			plan.Changes = append(plan.Changes, types.Change{
-				Description: fmt.Sprintf("Step %d: %s", i+1, parsed.Description()),
-			})
+	// The steps run on a workspace of their own, so the caller's stays as
+	// it is on disk.
+	cfg := DefaultConfig()
+	logger := slog.Default()
+	if op.engine != nil {
+		if op.engine.config != nil {
+			c := *op.engine.config
+			cfg = &c
 		}
-		return plan, nil
+		logger = op.engine.logger
 	}
+	child := CreateEngineWithConfig(cfg, logger).(*DefaultEngine)
+	scratch, err := child.LoadWorkspace(ws.RootPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load workspace for batch: %w", err)
+	}
+	vws := NewVirtualWorkspace(child, scratch)
+
+	var (
+		warnings []types.Issue
+		done     []int
+		failed   = make(map[string]bool) // Failed or skipped steps
+	)
+	for _, i := range order {
+		step := steps[i]
+		if dep := slices.IndexFunc(step.dependsOn, func(d string) bool { return failed[d] }); dep >= 0 {
+			failed[step.name] = true
+			warnings = append(warnings, types.Issue{
+				Type:        types.IssueCompilationError,
+				Description: fmt.Sprintf("batch step %q skipped: step %q did not run", step.name, step.dependsOn[dep]),
+				Severity:    types.Warning,
+			})
+			continue
+		}
 
-	// Execute each operation sequentially, collecting all changes.
-	fileSet := make(map[string]bool)
-	for i, parsed := range operations {
-		if err := parsed.Validate(ws); err != nil {
-			return nil, fmt.Errorf("operation %d validation failed: %w", i+1, err)
+		plan, err := step.plan(child, vws.Workspace())
+		if err == nil {
+			err = vws.Apply(plan)
 		}
-		result, err := parsed.Execute(ws)
 		if err != nil {
-			if op.Request.RollbackOnFailure {
-				return nil, fmt.Errorf("operation %d failed (no changes applied): %w", i+1, err)
-			}
-			return nil, fmt.Errorf("operation %d failed: %w", i+1, err)
-		}
-		plan.Changes = append(plan.Changes, result.Changes...)
-		for _, f := range result.AffectedFiles {
-			if !fileSet[f] {
-				plan.AffectedFiles = append(plan.AffectedFiles, f)
-				fileSet[f] = true
+			stepErr := &batchStepError{step: step.name, err: err}
+			if step.optional && !rollback {
+				failed[step.name] = true
+				warnings = append(warnings, types.Issue{
+					Type:        types.IssueCompilationError,
+					Description: stepErr.Error() + " (optional, skipped)",
+					Severity:    types.Warning,
+				})
+				continue
 			}
+			return nil, stepErr
 		}
+		done = append(done, i)
 	}
 
+	plan := vws.Pending()
+	plan.Operations = []types.Operation{op}
+	plan.Impact.PotentialIssues = warnings
+	plan.AllowBreaking = op.Request.AllowBreaking
+	if op.Request.DryRun {
+		plan.Changes = make([]types.Change, 0, len(done))
+		for n, i := range done {
+			plan.Changes = append(plan.Changes, types.Change{
+				Description: fmt.Sprintf("Step %d: %s (%s)", n+1, steps[i].name, steps[i].desc),
+			})
+		}
+	}
 	return plan, nil
 }
 
@@ -298,9 +333,11 @@ func (op *ExecuteOperation) Execute(ws *types.Workspace) (*types.RefactoringPlan
 	return plan, nil
 }
 
-// RollbackOperation implements rolling back operations
+// RollbackOperation implements rolling back plans recorded in the history journal
 type RollbackOperation struct {
 	Request types.RollbackOperationRequest
+
+	journal *history.Journal
 }
 
 func (op *RollbackOperation) Type() types.OperationType {
@@ -308,19 +345,60 @@ func (op *RollbackOperation) Type() types.OperationType {
 }
 
 func (op *RollbackOperation) Description() string {
-	if op.Request.LastBatch {
+	switch {
+	case op.Request.LastBatch:
 		return "Rollback last batch operation"
+	case op.Request.ToID != "":
+		return fmt.Sprintf("Rollback to before %s", op.Request.ToID)
+	default:
+		return fmt.Sprintf("Rollback to step %d", op.Request.ToStep)
 	}
-	return fmt.Sprintf("Rollback to step %d", op.Request.ToStep)
 }
 
 func (op *RollbackOperation) Validate(ws *types.Workspace) error {
-	if !op.Request.LastBatch && op.Request.ToStep <= 0 {
-		return fmt.Errorf("must specify either last batch rollback or valid step number")
+	if !op.Request.LastBatch && op.Request.ToID == "" && op.Request.ToStep <= 0 {
+		return fmt.Errorf("must specify either last batch rollback, a history entry ID or valid step number")
+	}
+	if op.journal == nil {
+		return fmt.Errorf("history journal is not enabled")
 	}
 	return nil
 }
 
+// Execute reverts the selected journal entry and every later one. Steps are
+// numbered from 1 in journal order, oldest first.
 func (op *RollbackOperation) Execute(_ *types.Workspace) (*types.RefactoringPlan, error) {
-	return nil, fmt.Errorf("rollback is not yet supported; use version control (git) to revert changes instead")
+	entries, err := op.journal.List()
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no applied plans in history")
+	}
+
+	id := op.Request.ToID
+	switch {
+	case op.Request.LastBatch:
+		id = entries[len(entries)-1].ID
+	case id == "":
+		if op.Request.ToStep > len(entries) {
+			return nil, fmt.Errorf("step %d out of range: history has %d entries", op.Request.ToStep, len(entries))
+		}
+		id = entries[op.Request.ToStep-1].ID
+	}
+
+	reverted, err := op.journal.Rollback(id, op.Request.Force)
+	if err != nil {
+		if len(reverted) > 0 {
+			return nil, fmt.Errorf("rolled back %d of the requested plans before failing: %w", len(reverted), err)
+		}
+		return nil, err
+	}
+
+	return &types.RefactoringPlan{
+		Operations:    []types.Operation{op},
+		Changes:       make([]types.Change, 0),
+		AffectedFiles: history.Paths(reverted),
+		Reversible:    false,
+	}, nil
 }
@@ -0,0 +1,91 @@
+package refactor
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// BreakingRule allows or forbids breaking changes in the packages matching
+// Package: a directory relative to the workspace root, where a trailing
+// "/..." also matches every directory below it.
+type BreakingRule struct {
+	Package string
+	Allow   bool
+}
+
+// match reports whether the rule covers the package directory rel, relative
+// to the workspace root, and how specific the match is.
+func (r BreakingRule) match(rel string) (int, bool) {
+	pattern := strings.TrimSuffix(filepath.ToSlash(r.Package), "/")
+	base, subtree := strings.CutSuffix(pattern, "/...")
+	if pattern == "..." {
+		base, subtree = ".", true
+	}
+	base = path.Clean(base)
+
+	specificity := 2 * len(base)
+	if base == "." {
+		specificity = 0
+	}
+	switch {
+	case rel == base:
+		return specificity + 1, true
+	case subtree && (base == "." || strings.HasPrefix(rel, base+"/")):
+		return specificity, true
+	}
+	return 0, false
+}
+
+// breakingAllowed reports whether critical issues in the package in dir may
+// be accepted. The most specific BreakingPolicy rule matching the directory
+// decides, the later one on a tie; AllowBreaking decides when none matches.
+func (c *EngineConfig) breakingAllowed(root, dir string) bool {
+	allow := c.AllowBreaking
+	rel, err := filepath.Rel(root, dir)
+	if root == "" || err != nil || strings.HasPrefix(rel, "..") {
+		return allow
+	}
+	rel = filepath.ToSlash(rel)
+
+	best := -1
+	for _, rule := range c.BreakingPolicy {
+		if specificity, ok := rule.match(rel); ok && specificity >= best {
+			best, allow = specificity, rule.Allow
+		}
+	}
+	return allow
+}
+
+// breakingPolicy returns the check ValidateRefactoring applies to the
+// critical issues of plan. The plan's own AllowBreaking overrides the
+// engine's policy; otherwise an issue is judged by the package of its file,
+// or by every package the plan changes when it has none.
+func (e *DefaultEngine) breakingPolicy(plan *types.RefactoringPlan) func(types.Issue) bool {
+	return func(issue types.Issue) bool {
+		if plan.AllowBreaking != nil {
+			return *plan.AllowBreaking
+		}
+		if e.config == nil {
+			return false
+		}
+		files := []string{issue.File}
+		if issue.File == "" {
+			files = plan.AffectedFiles
+		}
+		if len(files) == 0 {
+			return e.config.AllowBreaking
+		}
+		for _, file := range files {
+			if !filepath.IsAbs(file) {
+				file = filepath.Join(e.root, file)
+			}
+			if !e.config.breakingAllowed(e.root, filepath.Dir(file)) {
+				return false
+			}
+		}
+		return true
+	}
+}
@@ -0,0 +1,67 @@
+package refactor
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+func TestBreakingRule_Match(t *testing.T) {
+	tests := []struct {
+		pattern, rel string
+		want         bool
+	}{
+		{"internal/...", "internal", true},
+		{"internal/...", "internal/store", true},
+		{"internal/...", "internalx", false},
+		{"pkg/api", "pkg/api", true},
+		{"pkg/api", "pkg/api/v2", false},
+		{"./pkg/api/", "pkg/api", true},
+		{"./...", "cmd/tool", true},
+		{".", ".", true},
+	}
+	for _, tt := range tests {
+		if _, got := (BreakingRule{Package: tt.pattern}).match(tt.rel); got != tt.want {
+			t.Errorf("%q matches %q = %v, want %v", tt.pattern, tt.rel, got, tt.want)
+		}
+	}
+}
+
+func TestValidateRefactoring_BreakingPolicy(t *testing.T) {
+	dir := writeTestModule(t)
+	engine := CreateEngineWithConfig(&EngineConfig{
+		BreakingPolicy: []BreakingRule{
+			{Package: "./...", Allow: true},
+			{Package: "b", Allow: false},
+		},
+	}, slog.New(slog.NewTextHandler(io.Discard, nil))).(*DefaultEngine)
+	if _, err := engine.LoadWorkspace(dir); err != nil {
+		t.Fatal(err)
+	}
+	// Inverted bounds are a critical issue of the validator.
+	breaking := func(file string) *types.RefactoringPlan {
+		return &types.RefactoringPlan{
+			Changes:       []types.Change{{File: filepath.Join(dir, file), Start: 5, End: 2}},
+			AffectedFiles: []string{filepath.Join(dir, file)},
+		}
+	}
+
+	if err := engine.ValidateRefactoring(breaking("a/a.go")); err != nil {
+		t.Errorf("a is allowed to break: %v", err)
+	}
+	var verr *types.ValidationError
+	if err := engine.ValidateRefactoring(breaking("b/b.go")); !errors.As(err, &verr) {
+		t.Errorf("expected a validation error for b, got %v", err)
+	}
+
+	allow := true
+	plan := breaking("b/b.go")
+	plan.AllowBreaking = &allow
+	if err := engine.ValidateRefactoring(plan); err != nil {
+		t.Errorf("the plan's override should allow b: %v", err)
+	}
+}
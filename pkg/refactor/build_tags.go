@@ -0,0 +1,598 @@
+package refactor
+
+import (
+	"fmt"
+	"go/build/constraint"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// BuildTagsOperation rewrites the build constraints of the workspace's Go
+// files: it migrates legacy "// +build" lines to "//go:build", renames
+// custom build tags, and moves GOOS/GOARCH constraints into or out of file
+// name suffixes. Build files (Makefiles, scripts, CI configs) that pass the
+// renamed tags with -tags or mention renamed files are updated as well.
+type BuildTagsOperation struct {
+	Request types.BuildTagsRequest
+
+	// Set by Validate
+	root string
+}
+
+// knownOS and knownArch are the GOOS and GOARCH values go/build recognizes
+// in file name suffixes.
+var knownOS = map[string]bool{
+	"aix": true, "android": true, "darwin": true, "dragonfly": true, "freebsd": true, "hurd": true,
+	"illumos": true, "ios": true, "js": true, "linux": true, "nacl": true, "netbsd": true,
+	"openbsd": true, "plan9": true, "solaris": true, "wasip1": true, "windows": true, "zos": true,
+}
+
+var knownArch = map[string]bool{
+	"386": true, "amd64": true, "amd64p32": true, "arm": true, "armbe": true, "arm64": true,
+	"arm64be": true, "loong64": true, "mips": true, "mipsle": true, "mips64": true, "mips64le": true,
+	"mips64p32": true, "mips64p32le": true, "ppc": true, "ppc64": true, "ppc64le": true, "riscv": true,
+	"riscv64": true, "s390": true, "s390x": true, "sparc": true, "sparc64": true, "wasm": true,
+}
+
+// reservedTags are set by the toolchain and can't be renamed.
+var reservedTags = map[string]bool{"cgo": true, "gc": true, "gccgo": true, "unix": true, "ignore": true}
+
+var tagNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.]+$`)
+
+func (op *BuildTagsOperation) Type() types.OperationType {
+	return types.BuildTagsOperation
+}
+
+func (op *BuildTagsOperation) Description() string {
+	var parts []string
+	if op.Request.MigrateLegacy {
+		parts = append(parts, "migrate +build lines")
+	}
+	for _, old := range slices.Sorted(maps.Keys(op.Request.RenameTags)) {
+		parts = append(parts, fmt.Sprintf("rename tag %s to %s", old, op.Request.RenameTags[old]))
+	}
+	switch op.Request.FileSuffixes {
+	case types.SuffixesFromConstraints:
+		parts = append(parts, "add GOOS/GOARCH file suffixes")
+	case types.SuffixesToConstraints:
+		parts = append(parts, "replace GOOS/GOARCH file suffixes with constraints")
+	}
+	return "Build tags: " + strings.Join(parts, ", ")
+}
+
+func (op *BuildTagsOperation) Validate(ws *types.Workspace) error {
+	req := op.Request
+	if !req.MigrateLegacy && len(req.RenameTags) == 0 && req.FileSuffixes == "" {
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: "nothing to do: set migrate_legacy, rename_tags or file_suffixes",
+		}
+	}
+	switch req.FileSuffixes {
+	case "", types.SuffixesFromConstraints, types.SuffixesToConstraints:
+	default:
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("unknown file_suffixes mode %q (want %q or %q)", req.FileSuffixes, types.SuffixesFromConstraints, types.SuffixesToConstraints),
+		}
+	}
+	for old, renamed := range req.RenameTags {
+		for _, tag := range []string{old, renamed} {
+			if !tagNamePattern.MatchString(tag) {
+				return &types.RefactorError{
+					Type:    types.InvalidOperation,
+					Message: fmt.Sprintf("%q is not a valid build tag", tag),
+				}
+			}
+			if knownOS[tag] || knownArch[tag] || reservedTags[tag] || strings.HasPrefix(tag, "go1.") {
+				return &types.RefactorError{
+					Type:    types.InvalidOperation,
+					Message: fmt.Sprintf("%s is set by the Go toolchain; only custom build tags can be renamed", tag),
+				}
+			}
+		}
+	}
+	if req.Package != "" && !slices.ContainsFunc(slices.Collect(maps.Values(ws.Packages)), op.inScope) {
+		return &types.RefactorError{
+			Type:    types.SymbolNotFound,
+			Message: fmt.Sprintf("package %s not found", req.Package),
+		}
+	}
+	op.root = ws.RootPath
+	return nil
+}
+
+func (op *BuildTagsOperation) inScope(pkg *types.Package) bool {
+	return op.Request.Package == "" || pkg.Path == op.Request.Package || pkg.ImportPath == op.Request.Package
+}
+
+// textEdit replaces content[start:end] with text.
+type textEdit struct {
+	start, end int
+	text       string
+}
+
+func (op *BuildTagsOperation) Execute(ws *types.Workspace) (*types.RefactoringPlan, error) {
+	var changes []types.Change
+	var issues []types.Issue
+	renamedFiles := make(map[string]string)
+
+	// Paths taken by existing files or earlier renames.
+	taken := make(map[string]bool)
+	var files []*types.File
+	for _, pkgPath := range slices.Sorted(maps.Keys(ws.Packages)) {
+		pkg := ws.Packages[pkgPath]
+		for _, file := range pkg.Files {
+			taken[file.Path] = true
+		}
+		for _, file := range pkg.TestFiles {
+			taken[file.Path] = true
+		}
+		if !op.inScope(pkg) {
+			continue
+		}
+		for _, name := range slices.Sorted(maps.Keys(pkg.Files)) {
+			files = append(files, pkg.Files[name])
+		}
+		for _, name := range slices.Sorted(maps.Keys(pkg.TestFiles)) {
+			files = append(files, pkg.TestFiles[name])
+		}
+	}
+
+	for _, file := range files {
+		if file.AST == nil {
+			continue
+		}
+		edits, newPath, issue := op.rewriteFile(ws, file)
+		if issue != "" {
+			issues = append(issues, types.Issue{
+				Type:        types.IssueCompilationError,
+				Description: issue,
+				File:        file.Path,
+				Line:        1,
+				Severity:    types.Warning,
+			})
+		}
+		if newPath != "" && taken[newPath] {
+			issues = append(issues, types.Issue{
+				Type:        types.IssueNameConflict,
+				Description: fmt.Sprintf("cannot rename %s to %s: the file already exists", filepath.Base(file.Path), filepath.Base(newPath)),
+				File:        file.Path,
+				Line:        1,
+				Severity:    types.Warning,
+			})
+			continue
+		}
+		if newPath == "" {
+			for _, e := range edits {
+				changes = append(changes, types.Change{
+					File:        file.Path,
+					Start:       e.start,
+					End:         e.end,
+					OldText:     string(file.OriginalContent[e.start:e.end]),
+					NewText:     e.text,
+					Description: "Replace build constraint",
+				})
+			}
+			continue
+		}
+
+		taken[newPath] = true
+		renamedFiles[file.Path] = newPath
+		changes = append(changes,
+			types.Change{
+				File:        newPath,
+				NewText:     applyTextEdits(string(file.OriginalContent), edits),
+				Description: fmt.Sprintf("Move file %s to %s", file.Path, newPath),
+			},
+			types.Change{
+				File:        file.Path,
+				End:         len(file.OriginalContent),
+				OldText:     string(file.OriginalContent),
+				Description: fmt.Sprintf("Remove file %s (moved to %s)", file.Path, newPath),
+			})
+	}
+
+	refChanges, err := op.updateBuildFiles(renamedFiles)
+	if err != nil {
+		return nil, err
+	}
+	changes = append(changes, refChanges...)
+
+	affected := make([]string, 0)
+	for _, change := range changes {
+		if !slices.Contains(affected, change.File) {
+			affected = append(affected, change.File)
+		}
+	}
+
+	return &types.RefactoringPlan{
+		Changes:       changes,
+		AffectedFiles: affected,
+		Impact: &types.ImpactAnalysis{
+			AffectedFiles:   affected,
+			PotentialIssues: issues,
+		},
+		Reversible: true,
+	}, nil
+}
+
+// rewriteFile computes the edits to the build constraint of one file and its
+// new path when it is renamed. It returns a description of the problem when
+// the file can't be migrated completely.
+func (op *BuildTagsOperation) rewriteFile(ws *types.Workspace, file *types.File) ([]textEdit, string, string) {
+	req := op.Request
+	content := file.OriginalContent
+	header := ws.FileSet.Position(file.AST.Package).Offset
+	if file.AST.Doc != nil {
+		header = ws.FileSet.Position(file.AST.Doc.Pos()).Offset
+	}
+	lines := constraintLines(content, header)
+
+	var goBuild constraint.Expr
+	var plusBuild []constraint.Expr
+	for _, line := range lines {
+		expr, err := constraint.Parse(line.text)
+		if err != nil {
+			return nil, "", fmt.Sprintf("invalid build constraint %q: %v", line.text, err)
+		}
+		if constraint.IsGoBuild(line.text) {
+			goBuild = expr
+		} else {
+			plusBuild = append(plusBuild, expr)
+		}
+	}
+	expr := goBuild
+	if expr == nil {
+		for _, x := range plusBuild {
+			if expr == nil {
+				expr = x
+			} else {
+				expr = &constraint.AndExpr{X: expr, Y: x}
+			}
+		}
+	}
+
+	changed := req.MigrateLegacy && len(plusBuild) > 0 && (goBuild == nil || !req.KeepLegacy)
+	if expr != nil && len(req.RenameTags) > 0 && renameConstraintTags(expr, req.RenameTags) {
+		changed = true
+	}
+
+	newPath := ""
+	dir, base := filepath.Split(file.Path)
+	stem, test := strings.CutSuffix(strings.TrimSuffix(base, ".go"), "_test")
+	goos, goarch := fileSuffix(stem)
+	switch req.FileSuffixes {
+	case types.SuffixesFromConstraints:
+		if goos == "" && goarch == "" && expr != nil {
+			if exprOS, exprArch, ok := osArchConstraint(expr); ok {
+				newPath = dir + joinSuffix(stem, exprOS, exprArch, test)
+				expr = nil
+				changed = true
+			}
+		}
+	case types.SuffixesToConstraints:
+		if goos != "" || goarch != "" {
+			trimmed := stem
+			for _, s := range []string{goarch, goos} {
+				if s != "" {
+					trimmed = strings.TrimSuffix(trimmed, "_"+s)
+				}
+			}
+			newPath = dir + joinSuffix(trimmed, "", "", test)
+			var suffix constraint.Expr
+			for _, tag := range []string{goos, goarch} {
+				if tag == "" {
+					continue
+				}
+				if suffix == nil {
+					suffix = &constraint.TagExpr{Tag: tag}
+				} else {
+					suffix = &constraint.AndExpr{X: suffix, Y: &constraint.TagExpr{Tag: tag}}
+				}
+			}
+			if expr == nil {
+				expr = suffix
+			} else {
+				expr = &constraint.AndExpr{X: suffix, Y: expr}
+			}
+			changed = true
+		}
+	}
+	if !changed {
+		return nil, "", ""
+	}
+
+	var problem string
+	var block strings.Builder
+	if expr != nil {
+		if goBuild != nil || req.MigrateLegacy || len(plusBuild) == 0 {
+			fmt.Fprintf(&block, "//go:build %s\n", expr)
+		}
+		if len(plusBuild) > 0 && (req.KeepLegacy || !req.MigrateLegacy) {
+			plus, err := constraint.PlusBuildLines(expr)
+			if err != nil {
+				problem = fmt.Sprintf("constraint %s can't be written as +build lines; only //go:build was written", expr)
+				if block.Len() == 0 {
+					fmt.Fprintf(&block, "//go:build %s\n", expr)
+				}
+			}
+			for _, line := range plus {
+				block.WriteString(line + "\n")
+			}
+		}
+	}
+
+	var edits []textEdit
+	if len(lines) == 0 {
+		if block.Len() > 0 {
+			edits = append(edits, textEdit{start: header, end: header, text: block.String() + "\n"})
+		}
+		return edits, newPath, problem
+	}
+	// The first constraint line is replaced by the new block; the others are dropped.
+	for i, line := range lines {
+		e := textEdit{start: line.start, end: line.end}
+		if i == 0 {
+			e.text = block.String()
+		}
+		edits = append(edits, e)
+	}
+	return edits, newPath, problem
+}
+
+// constraintLine is a //go:build or // +build line; end includes the newline.
+type constraintLine struct {
+	start, end int
+	text       string
+}
+
+// constraintLines returns the build constraint lines in content before the
+// offset limit (the package clause or its doc comment).
+func constraintLines(content []byte, limit int) []constraintLine {
+	var lines []constraintLine
+	for offset := 0; offset < limit && offset < len(content); {
+		end := offset
+		for end < len(content) && content[end] != '\n' {
+			end++
+		}
+		text := strings.TrimSpace(string(content[offset:end]))
+		if end < len(content) {
+			end++
+		}
+		if constraint.IsGoBuild(text) || constraint.IsPlusBuild(text) {
+			lines = append(lines, constraintLine{start: offset, end: end, text: text})
+		}
+		offset = end
+	}
+	return lines
+}
+
+// renameConstraintTags renames the tags of expr in place.
+func renameConstraintTags(expr constraint.Expr, renames map[string]string) bool {
+	switch x := expr.(type) {
+	case *constraint.TagExpr:
+		if renamed, ok := renames[x.Tag]; ok {
+			x.Tag = renamed
+			return true
+		}
+	case *constraint.NotExpr:
+		return renameConstraintTags(x.X, renames)
+	case *constraint.AndExpr:
+		a := renameConstraintTags(x.X, renames)
+		return renameConstraintTags(x.Y, renames) || a
+	case *constraint.OrExpr:
+		a := renameConstraintTags(x.X, renames)
+		return renameConstraintTags(x.Y, renames) || a
+	}
+	return false
+}
+
+// osArchConstraint reports whether expr is exactly a GOOS tag, a GOARCH tag,
+// or both joined by &&, which a file name suffix can express.
+func osArchConstraint(expr constraint.Expr) (goos, goarch string, ok bool) {
+	switch x := expr.(type) {
+	case *constraint.TagExpr:
+		switch {
+		case knownOS[x.Tag]:
+			return x.Tag, "", true
+		case knownArch[x.Tag]:
+			return "", x.Tag, true
+		}
+	case *constraint.AndExpr:
+		a, okX := x.X.(*constraint.TagExpr)
+		b, okY := x.Y.(*constraint.TagExpr)
+		if !okX || !okY {
+			return "", "", false
+		}
+		if knownArch[a.Tag] {
+			a, b = b, a
+		}
+		if knownOS[a.Tag] && knownArch[b.Tag] {
+			return a.Tag, b.Tag, true
+		}
+	}
+	return "", "", false
+}
+
+// fileSuffix returns the GOOS and GOARCH of a file name stem (without .go and
+// _test), following the go/build rules: the first element is never a suffix.
+func fileSuffix(stem string) (goos, goarch string) {
+	parts := strings.Split(stem, "_")
+	n := len(parts)
+	switch {
+	case n >= 3 && knownOS[parts[n-2]] && knownArch[parts[n-1]]:
+		return parts[n-2], parts[n-1]
+	case n >= 2 && knownOS[parts[n-1]]:
+		return parts[n-1], ""
+	case n >= 2 && knownArch[parts[n-1]]:
+		return "", parts[n-1]
+	}
+	return "", ""
+}
+
+func joinSuffix(stem, goos, goarch string, test bool) string {
+	for _, s := range []string{goos, goarch} {
+		if s != "" {
+			stem += "_" + s
+		}
+	}
+	if test {
+		stem += "_test"
+	}
+	return stem + ".go"
+}
+
+// applyTextEdits applies non-overlapping edits to content.
+func applyTextEdits(content string, edits []textEdit) string {
+	sorted := slices.Clone(edits)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].start > sorted[j].start })
+	for _, e := range sorted {
+		content = content[:e.start] + e.text + content[e.end:]
+	}
+	return content
+}
+
+// buildFilePatterns match the non-Go files that commonly pass build tags or
+// name source files: Makefiles, shell scripts and CI configuration.
+var buildFilePatterns = []string{"Makefile", "GNUmakefile", "makefile", "*.mk", "*.sh", "*.bash", "*.yml", "*.yaml", "Dockerfile", "Dockerfile.*", "justfile", "Taskfile*"}
+
+// tagsFlagPattern matches the value of a -tags flag, quoted or not.
+var (
+	tagsFlagPattern = regexp.MustCompile(`-tags(?:=|\s+)(?:"([^"]*)"|'([^']*)'|([\w.,]+))`)
+	tagTokenPattern = regexp.MustCompile(`[\w.]+`)
+)
+
+// updateBuildFiles rewrites -tags flags and references to renamed files in
+// the workspace's build files.
+func (op *BuildTagsOperation) updateBuildFiles(renamedFiles map[string]string) ([]types.Change, error) {
+	if len(op.Request.RenameTags) == 0 && len(renamedFiles) == 0 {
+		return nil, nil
+	}
+	var changes []types.Change
+	err := filepath.WalkDir(op.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if path != op.root && (strings.HasPrefix(name, ".") || name == "vendor" || name == "testdata" || name == "node_modules") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !slices.ContainsFunc(buildFilePatterns, func(p string) bool { ok, _ := filepath.Match(p, name); return ok }) {
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		text := string(content)
+		var edits []textEdit
+		if len(op.Request.RenameTags) > 0 {
+			edits = append(edits, tagFlagEdits(text, op.Request.RenameTags)...)
+		}
+		for _, oldPath := range slices.Sorted(maps.Keys(renamedFiles)) {
+			edits = append(edits, pathEdits(text, filepath.Dir(path), op.root, oldPath, renamedFiles[oldPath], edits)...)
+		}
+		for _, e := range edits {
+			changes = append(changes, types.Change{
+				File:        path,
+				Start:       e.start,
+				End:         e.end,
+				OldText:     text[e.start:e.end],
+				NewText:     e.text,
+				Description: fmt.Sprintf("Replace build reference in %s", name),
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, &types.RefactorError{
+			Type:    types.FileSystemError,
+			Message: fmt.Sprintf("failed to scan build files: %v", err),
+			File:    op.root,
+			Cause:   err,
+		}
+	}
+	return changes, nil
+}
+
+// tagFlagEdits renames tags in the values of -tags flags.
+func tagFlagEdits(text string, renames map[string]string) []textEdit {
+	var edits []textEdit
+	for _, m := range tagsFlagPattern.FindAllStringSubmatchIndex(text, -1) {
+		for g := 1; g <= 3; g++ {
+			start, end := m[2*g], m[2*g+1]
+			if start < 0 {
+				continue
+			}
+			value := text[start:end]
+			renamed := tagTokenPattern.ReplaceAllStringFunc(value, func(tag string) string {
+				if r, ok := renames[tag]; ok {
+					return r
+				}
+				return tag
+			})
+			if renamed != value {
+				edits = append(edits, textEdit{start: start, end: end, text: renamed})
+			}
+		}
+	}
+	return edits
+}
+
+// pathEdits replaces references to oldPath in a build file in dir, written
+// relative to the workspace root or to dir, with the same form of newPath.
+// Ranges already covered by existing edits are left alone.
+func pathEdits(text, dir, root, oldPath, newPath string, existing []textEdit) []textEdit {
+	type form struct{ old, new string }
+	var forms []form
+	for _, base := range []string{root, dir} {
+		oldRel, err1 := filepath.Rel(base, oldPath)
+		newRel, err2 := filepath.Rel(base, newPath)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		oldRel, newRel = filepath.ToSlash(oldRel), filepath.ToSlash(newRel)
+		forms = append(forms, form{"./" + oldRel, "./" + newRel}, form{oldRel, newRel})
+	}
+
+	var edits []textEdit
+	overlaps := func(start, end int) bool {
+		for _, e := range slices.Concat(existing, edits) {
+			if start < e.end && e.start < end {
+				return true
+			}
+		}
+		return false
+	}
+	for _, f := range forms {
+		for i := 0; ; {
+			idx := strings.Index(text[i:], f.old)
+			if idx < 0 {
+				break
+			}
+			start, end := i+idx, i+idx+len(f.old)
+			i = end
+			if start > 0 && isPathByte(text[start-1]) || end < len(text) && isPathByte(text[end]) || overlaps(start, end) {
+				continue
+			}
+			edits = append(edits, textEdit{start: start, end: end, text: f.new})
+		}
+	}
+	return edits
+}
+
+func isPathByte(c byte) bool {
+	return c == '/' || c == '.' || c == '_' || c == '-' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
@@ -0,0 +1,115 @@
+package refactor
+
+import (
+	"go/build/constraint"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+func TestFileSuffix(t *testing.T) {
+	tests := []struct {
+		stem, goos, goarch string
+	}{
+		{"linux", "", ""},
+		{"conn_linux", "linux", ""},
+		{"conn_arm64", "", "arm64"},
+		{"conn_linux_arm64", "linux", "arm64"},
+		{"conn_arm64_linux", "linux", ""},
+		{"conn_unix", "", ""},
+	}
+	for _, tt := range tests {
+		goos, goarch := fileSuffix(tt.stem)
+		if goos != tt.goos || goarch != tt.goarch {
+			t.Errorf("fileSuffix(%q) = %q, %q; want %q, %q", tt.stem, goos, goarch, tt.goos, tt.goarch)
+		}
+	}
+}
+
+func TestOSArchConstraint(t *testing.T) {
+	tests := []struct {
+		expr, goos, goarch string
+		ok                 bool
+	}{
+		{"linux", "linux", "", true},
+		{"amd64 && darwin", "darwin", "amd64", true},
+		{"linux || darwin", "", "", false},
+		{"!windows", "", "", false},
+		{"linux && cgo", "", "", false},
+	}
+	for _, tt := range tests {
+		expr, err := constraint.Parse("//go:build " + tt.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		goos, goarch, ok := osArchConstraint(expr)
+		if goos != tt.goos || goarch != tt.goarch || ok != tt.ok {
+			t.Errorf("osArchConstraint(%s) = %q, %q, %v; want %q, %q, %v", tt.expr, goos, goarch, ok, tt.goos, tt.goarch, tt.ok)
+		}
+	}
+}
+
+func TestBuildTags_AddsSuffixes(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":           "module example.com/p\n\ngo 1.21\n",
+		"p/p.go":           "package p\n",
+		"p/conn.go":        "//go:build linux && amd64\n\npackage p\n\nfunc conn() {}\n",
+		"p/conn_test.go":   "//go:build windows\n\npackage p\n",
+		"p/other.go":       "//go:build linux || darwin\n\npackage p\n",
+		"p/taken.go":       "//go:build linux\n\npackage p\n",
+		"p/taken_linux.go": "package p\n",
+		"scripts/ci.sh":    "go vet ./p/conn.go\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	engine := CreateEngine(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ws, err := engine.LoadWorkspace(dir)
+	if err != nil {
+		t.Fatalf("LoadWorkspace: %v", err)
+	}
+
+	plan, err := engine.BuildTags(ws, types.BuildTagsRequest{FileSuffixes: types.SuffixesFromConstraints})
+	if err != nil {
+		t.Fatalf("BuildTags: %v", err)
+	}
+	if n := len(plan.Impact.PotentialIssues); n != 1 || !strings.Contains(plan.Impact.PotentialIssues[0].Description, "taken_linux.go") {
+		t.Errorf("expected a conflict for taken.go, got %+v", plan.Impact.PotentialIssues)
+	}
+	if err := engine.ExecutePlan(plan); err != nil {
+		t.Fatalf("ExecutePlan: %v", err)
+	}
+
+	for _, gone := range []string{"p/conn.go", "p/conn_test.go"} {
+		if _, err := os.Stat(filepath.Join(dir, gone)); !os.IsNotExist(err) {
+			t.Errorf("%s should have been renamed", gone)
+		}
+	}
+	for name, want := range map[string]string{
+		"p/conn_linux_amd64.go":  "package p\n\nfunc conn() {}\n",
+		"p/conn_windows_test.go": "package p\n",
+		"p/other.go":             "//go:build linux || darwin\n\npackage p\n",
+		"scripts/ci.sh":          "go vet ./p/conn_linux_amd64.go\n",
+	} {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Errorf("read %s: %v", name, err)
+			continue
+		}
+		if string(got) != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package refactor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Build validators the engine can run after applying a plan.
+const (
+	BuildGoBuild = "go_build" // go build in each affected package directory (default)
+	BuildGoVet   = "go_vet"   // go vet in each affected package directory
+	BuildBazel   = "bazel"    // The build command on the :all targets of the affected packages
+	BuildNone    = "none"     // No check, like SkipCompilation
+)
+
+// BuildValidator checks that the code builds after a plan is applied. Dirs
+// are the absolute directories of the packages the plan wrote Go files in;
+// directories a plan removed are left out.
+type BuildValidator interface {
+	Validate(ctx context.Context, dirs []string) error
+}
+
+// NewBuildValidator returns the validator with the given name. Command
+// replaces the build command of BuildBazel, e.g. [plz, build] for Please;
+// it defaults to [bazel, build].
+func NewBuildValidator(name string, command []string) (BuildValidator, error) {
+	switch name {
+	case "", BuildGoBuild:
+		return goValidator{"build", "-o", os.DevNull, "."}, nil
+	case BuildGoVet:
+		return goValidator{"vet", "."}, nil
+	case BuildBazel:
+		if len(command) == 0 {
+			command = []string{"bazel", "build"}
+		}
+		return targetValidator{command: command}, nil
+	case BuildNone:
+		return noBuildValidator{}, nil
+	}
+	return nil, fmt.Errorf("unknown build validator %q: want %s, %s, %s or %s", name, BuildGoBuild, BuildGoVet, BuildBazel, BuildNone)
+}
+
+// goValidator runs a go command in each package directory.
+type goValidator []string
+
+func (g goValidator) Validate(ctx context.Context, dirs []string) error {
+	for _, dir := range dirs {
+		cmd := exec.CommandContext(ctx, "go", g...)
+		cmd.Dir = dir
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("compilation failed in %s: go %s failed: %s", dir, g[0], string(output))
+		}
+	}
+	return nil
+}
+
+// targetValidator builds the targets of the affected packages with a
+// build system that labels them //dir:name, such as Bazel or Please. It
+// runs once, from the build root above the packages.
+type targetValidator struct {
+	command []string
+}
+
+// buildRootFiles mark the root of a Bazel or Please workspace.
+var buildRootFiles = []string{"MODULE.bazel", "WORKSPACE", "WORKSPACE.bazel", ".plzconfig"}
+
+func (t targetValidator) Validate(ctx context.Context, dirs []string) error {
+	if len(dirs) == 0 {
+		return nil
+	}
+	root := buildRoot(dirs[0])
+	if root == "" {
+		return fmt.Errorf("no %s found above %s", strings.Join(buildRootFiles, ", "), dirs[0])
+	}
+	args := slices.Clone(t.command[1:])
+	for _, dir := range dirs {
+		rel, err := filepath.Rel(root, dir)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return fmt.Errorf("%s is outside the build root %s", dir, root)
+		}
+		if rel == "." {
+			rel = ""
+		}
+		args = append(args, "//"+filepath.ToSlash(rel)+":all")
+	}
+	cmd := exec.CommandContext(ctx, t.command[0], args...)
+	cmd.Dir = root
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %s", strings.Join(t.command, " "), string(output))
+	}
+	return nil
+}
+
+// buildRoot returns the closest directory at or above dir holding one of
+// buildRootFiles, or "".
+func buildRoot(dir string) string {
+	for {
+		for _, name := range buildRootFiles {
+			if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+				return dir
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+type noBuildValidator struct{}
+
+func (noBuildValidator) Validate(context.Context, []string) error { return nil }
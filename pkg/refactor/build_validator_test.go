@@ -0,0 +1,43 @@
+package refactor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuildValidator_Targets(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "MODULE.bazel"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	pkg := filepath.Join(root, "svc", "api")
+	if err := os.MkdirAll(pkg, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	// Record the targets instead of building them.
+	v, err := NewBuildValidator(BuildBazel, []string{"sh", "-c", `printf '%s\n' "$@" > targets.txt`, "sh"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Validate(context.Background(), []string{root, pkg}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(filepath.Join(root, "targets.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "//:all\n//svc/api:all\n"; string(got) != want {
+		t.Errorf("targets = %q, want %q", got, want)
+	}
+
+	if err := v.Validate(context.Background(), []string{t.TempDir()}); err == nil || !strings.Contains(err.Error(), "MODULE.bazel") {
+		t.Errorf("expected a missing build root error, got %v", err)
+	}
+	if _, err := NewBuildValidator("make", nil); err == nil {
+		t.Error("expected an error for an unknown validator")
+	}
+}
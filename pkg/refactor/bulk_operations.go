@@ -1,9 +1,17 @@
 package refactor
 
 import (
+	"cmp"
+	"context"
 	"fmt"
+	"go/ast"
+	"go/token"
+	"maps"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/mamaar/gorefactor/pkg/types"
@@ -46,6 +54,12 @@ func (op *MovePackageOperation) Validate(ws *types.Workspace) error {
 }
 
 func (op *MovePackageOperation) Execute(ws *types.Workspace) (*types.RefactoringPlan, error) {
+	return op.ExecuteContext(context.Background(), ws)
+}
+
+// ExecuteContext generates the plan, returning ctx.Err() if ctx is canceled
+// before every package has been visited.
+func (op *MovePackageOperation) ExecuteContext(ctx context.Context, ws *types.Workspace) (*types.RefactoringPlan, error) {
 	plan := &types.RefactoringPlan{
 		Operations:    []types.Operation{op},
 		Changes:       make([]types.Change, 0),
@@ -65,6 +79,10 @@ func (op *MovePackageOperation) Execute(ws *types.Workspace) (*types.Refactoring
 	if sourcePackage == nil {
 		return nil, fmt.Errorf("source package %s not found", op.Request.SourcePackage)
 	}
+	// A target that doesn't exist yet is relative to the workspace root
+	if !filepath.IsAbs(op.Request.TargetPackage) {
+		op.Request.TargetPackage = filepath.Join(ws.RootPath, op.Request.TargetPackage)
+	}
 
 	sourceImportPath := packagePathToImportPath(ws, sourcePackage.Path)
 	targetImportPath := packagePathToImportPath(ws, op.Request.TargetPackage)
@@ -101,11 +119,47 @@ func (op *MovePackageOperation) Execute(ws *types.Workspace) (*types.Refactoring
 		plan.AffectedFiles = append(plan.AffectedFiles, file.Path, targetFilePath)
 	}
 
+	// Move the test files as well (if requested)
+	if op.Request.MoveTests {
+		for _, name := range slices.Sorted(maps.Keys(sourcePackage.TestFiles)) {
+			file := sourcePackage.TestFiles[name]
+			if len(file.OriginalContent) == 0 || file.AST == nil {
+				continue
+			}
+			content := string(file.OriginalContent)
+			newContent, err := movedTestFileContent(ws, file, sourcePackage.Name, sourceImportPath, targetImportPath, targetPkgName)
+			if err != nil {
+				return nil, err
+			}
+			targetFilePath := filepath.Join(op.Request.TargetPackage, name)
+
+			plan.Changes = append(plan.Changes, types.Change{
+				File:        targetFilePath,
+				NewText:     newContent,
+				Description: fmt.Sprintf("Move test file %s to %s", file.Path, targetFilePath),
+			})
+			plan.Changes = append(plan.Changes, types.Change{
+				File:        file.Path,
+				End:         len(file.OriginalContent),
+				OldText:     content,
+				Description: fmt.Sprintf("Remove test file %s (moved to %s)", file.Path, targetFilePath),
+			})
+			plan.AffectedFiles = append(plan.AffectedFiles, file.Path, targetFilePath)
+		}
+	}
+
 	// Update import paths in all dependent files
 	if op.Request.UpdateImports && sourceImportPath != "" && targetImportPath != "" {
 		quoted := `"` + sourceImportPath + `"`
 		for _, pkg := range ws.Packages {
-			for _, file := range pkg.Files {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			files := slices.Collect(maps.Values(pkg.Files))
+			if op.Request.MoveTests && pkg != sourcePackage {
+				files = packageFiles(pkg)
+			}
+			for _, file := range files {
 				if len(file.OriginalContent) == 0 {
 					continue
 				}
@@ -134,6 +188,58 @@ func (op *MovePackageOperation) Execute(ws *types.Workspace) (*types.Refactoring
 	return plan, nil
 }
 
+// movedTestFileContent returns the content of a test file of a package
+// moved to targetImportPath: its package clause names the target package,
+// or its _test variant, and an import of the package itself, in an
+// external test package, imports it from its new path under its new name.
+func movedTestFileContent(ws *types.Workspace, file *types.File, sourceName, sourceImportPath, targetImportPath, targetPkgName string) (string, error) {
+	content := string(file.OriginalContent)
+	offset := func(pos token.Pos) int { return ws.FileSet.Position(pos).Offset }
+
+	clause := targetPkgName
+	if file.AST.Name.Name != sourceName {
+		clause += "_test"
+	}
+	edits := []types.Change{{
+		Start:   offset(file.AST.Name.Pos()),
+		End:     offset(file.AST.Name.End()),
+		NewText: clause,
+	}}
+
+	requalify := false
+	for _, spec := range file.AST.Imports {
+		if path, err := strconv.Unquote(spec.Path.Value); err != nil || path != sourceImportPath {
+			continue
+		}
+		edits = append(edits, types.Change{
+			Start:   offset(spec.Path.Pos()),
+			End:     offset(spec.Path.End()),
+			NewText: strconv.Quote(targetImportPath),
+		})
+		requalify = spec.Name == nil && sourceName != targetPkgName
+	}
+	if requalify {
+		ast.Inspect(file.AST, func(n ast.Node) bool {
+			if sel, ok := n.(*ast.SelectorExpr); ok {
+				if id, ok := sel.X.(*ast.Ident); ok && id.Name == sourceName {
+					edits = append(edits, types.Change{
+						Start:   offset(id.Pos()),
+						End:     offset(id.End()),
+						NewText: targetPkgName,
+					})
+				}
+			}
+			return true
+		})
+	}
+
+	newContent, err := applyInMemory(content, edits)
+	if err != nil {
+		return "", fmt.Errorf("failed to move test file %s: %w", file.Path, err)
+	}
+	return newContent, nil
+}
+
 // MoveDirOperation implements moving directory structures
 type MoveDirOperation struct {
 	Request types.MoveDirRequest
@@ -165,6 +271,12 @@ func (op *MoveDirOperation) Validate(ws *types.Workspace) error {
 }
 
 func (op *MoveDirOperation) Execute(ws *types.Workspace) (*types.RefactoringPlan, error) {
+	return op.ExecuteContext(context.Background(), ws)
+}
+
+// ExecuteContext generates the plan, returning ctx.Err() if ctx is canceled
+// before every package has been visited.
+func (op *MoveDirOperation) ExecuteContext(ctx context.Context, ws *types.Workspace) (*types.RefactoringPlan, error) {
 	plan := &types.RefactoringPlan{
 		Operations:    []types.Operation{op},
 		Changes:       make([]types.Change, 0),
@@ -189,6 +301,9 @@ func (op *MoveDirOperation) Execute(ws *types.Workspace) (*types.RefactoringPlan
 
 	// Step 2: Generate file move changes for each package
 	for _, pkg := range sourcePackages {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		// Move each file in the package
 		for _, file := range pkg.Files {
 			if len(file.OriginalContent) == 0 {
@@ -235,6 +350,9 @@ func (op *MoveDirOperation) Execute(ws *types.Workspace) (*types.RefactoringPlan
 	// Step 3: Update import paths in all other files
 	if op.Request.UpdateImports {
 		for packagePath, pkg := range ws.Packages {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
 			// Skip packages we're moving
 			if strings.HasPrefix(packagePath, op.Request.SourceDir) {
 				continue
@@ -308,6 +426,8 @@ func (op *MoveDirOperation) generateImportPathUpdates(file *types.File, ws *type
 // MovePackagesOperation implements moving multiple packages atomically
 type MovePackagesOperation struct {
 	Request types.MovePackagesRequest
+
+	progress types.ProgressReporter // optional, receives a PhasePlan event per package
 }
 
 func (op *MovePackagesOperation) Type() types.OperationType {
@@ -352,6 +472,12 @@ func (op *MovePackagesOperation) Validate(ws *types.Workspace) error {
 }
 
 func (op *MovePackagesOperation) Execute(ws *types.Workspace) (*types.RefactoringPlan, error) {
+	return op.ExecuteContext(context.Background(), ws)
+}
+
+// ExecuteContext generates the plan, returning ctx.Err() if ctx is canceled
+// before every package has been visited.
+func (op *MovePackagesOperation) ExecuteContext(ctx context.Context, ws *types.Workspace) (*types.RefactoringPlan, error) {
 	plan := &types.RefactoringPlan{
 		Operations:    []types.Operation{op},
 		Changes:       make([]types.Change, 0),
@@ -359,7 +485,19 @@ func (op *MovePackagesOperation) Execute(ws *types.Workspace) (*types.Refactorin
 		Reversible:    true,
 	}
 
-	for _, mapping := range op.Request.Packages {
+	for i, mapping := range op.Request.Packages {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if op.progress != nil {
+			op.progress(types.ProgressEvent{
+				Phase:   types.PhasePlan,
+				Package: mapping.SourcePackage,
+				Current: i,
+				Total:   len(op.Request.Packages),
+				Message: fmt.Sprintf("planning move of %s to %s", mapping.SourcePackage, mapping.TargetPackage),
+			})
+		}
 		subReq := types.MovePackageRequest{
 			SourcePackage: mapping.SourcePackage,
 			TargetPackage: mapping.TargetPackage,
@@ -370,8 +508,11 @@ func (op *MovePackagesOperation) Execute(ws *types.Workspace) (*types.Refactorin
 		if err := subOp.Validate(ws); err != nil {
 			continue
 		}
-		subPlan, err := subOp.Execute(ws)
+		subPlan, err := subOp.ExecuteContext(ctx, ws)
 		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
 			continue
 		}
 		plan.Changes = append(plan.Changes, subPlan.Changes...)
@@ -427,11 +568,17 @@ func (op *CreateFacadeOperation) Execute(ws *types.Workspace) (*types.Refactorin
 		Reversible:    true,
 	}
 
+	// A target that doesn't exist yet is relative to the workspace root
+	target := op.Request.TargetPackage
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(ws.RootPath, target)
+	}
+
 	// Generate facade package content
 	var facadeContent strings.Builder
-	facadeContent.WriteString(fmt.Sprintf("// Package %s provides a facade for accessing related functionality.\n", filepath.Base(op.Request.TargetPackage)))
+	facadeContent.WriteString(fmt.Sprintf("// Package %s provides a facade for accessing related functionality.\n", filepath.Base(target)))
 	facadeContent.WriteString("// This file was generated by gorefactor.\n")
-	facadeContent.WriteString(fmt.Sprintf("package %s\n\n", filepath.Base(op.Request.TargetPackage)))
+	facadeContent.WriteString(fmt.Sprintf("package %s\n\n", filepath.Base(target)))
 
 	// Collect unique source package imports
 	imports := make(map[string]bool)
@@ -440,7 +587,7 @@ func (op *CreateFacadeOperation) Execute(ws *types.Workspace) (*types.Refactorin
 	}
 	if len(imports) > 0 {
 		facadeContent.WriteString("import (\n")
-		for imp := range imports {
+		for _, imp := range slices.Sorted(maps.Keys(imports)) {
 			facadeContent.WriteString(fmt.Sprintf("\t\"%s\"\n", imp))
 		}
 		facadeContent.WriteString(")\n\n")
@@ -452,7 +599,7 @@ func (op *CreateFacadeOperation) Execute(ws *types.Workspace) (*types.Refactorin
 		if outputName == "" {
 			outputName = export.SymbolName
 		}
-		pkgAlias := filepath.Base(export.SourcePackage)
+		pkgAlias := sourcePackageName(ws, export.SourcePackage)
 		kind := lookupSymbolKind(ws, export.SourcePackage, export.SymbolName)
 
 		facadeContent.WriteString(fmt.Sprintf("// %s is re-exported from %s\n", outputName, export.SourcePackage))
@@ -466,15 +613,24 @@ func (op *CreateFacadeOperation) Execute(ws *types.Workspace) (*types.Refactorin
 		}
 	}
 
-	facadeFile := filepath.Join(op.Request.TargetPackage, "facade.go")
-	plan.Changes = append(plan.Changes, types.Change{
+	facadeFile := filepath.Join(target, "facade.go")
+	change := types.Change{
 		File:        facadeFile,
 		Start:       0,
 		End:         0,
 		OldText:     "",
 		NewText:     facadeContent.String(),
 		Description: fmt.Sprintf("Create facade package %s", op.Request.TargetPackage),
-	})
+	}
+	// An existing facade.go is regenerated
+	if pkg, ok := ws.Packages[target]; ok {
+		if file, ok := pkg.Files["facade.go"]; ok {
+			change.End = len(file.OriginalContent)
+			change.OldText = string(file.OriginalContent)
+			change.Description = fmt.Sprintf("Regenerate facade package %s", op.Request.TargetPackage)
+		}
+	}
+	plan.Changes = append(plan.Changes, change)
 
 	plan.AffectedFiles = []string{facadeFile}
 
@@ -499,6 +655,48 @@ func lookupSymbolKind(ws *types.Workspace, sourcePackageImportPath, symbolName s
 	return sym.Kind
 }
 
+// sourcePackageName returns the name of the package with the given import
+// path, or the last element of the path for a package outside the workspace.
+func sourcePackageName(ws *types.Workspace, importPath string) string {
+	if pkg, ok := ws.Packages[ws.ImportToPath[importPath]]; ok && pkg.Name != "" {
+		return pkg.Name
+	}
+	return path.Base(importPath)
+}
+
+// facadeExports returns the exported symbols of pkg that a facade re-exports:
+// those whose names match one of include, or all when include is empty, and
+// none of exclude, sorted by name.
+func facadeExports(pkg *types.Package, include, exclude []string) []*types.Symbol {
+	var syms []*types.Symbol
+	for _, sym := range getAllExportedSymbols(pkg) {
+		if matchesNamePatterns(sym.Name, include, exclude) {
+			syms = append(syms, sym)
+		}
+	}
+	slices.SortFunc(syms, func(a, b *types.Symbol) int { return strings.Compare(a.Name, b.Name) })
+	return syms
+}
+
+// matchesNamePatterns reports whether name matches one of include, or
+// include is empty, and none of exclude.
+func matchesNamePatterns(name string, include, exclude []string) bool {
+	match := func(p string) bool { ok, _ := path.Match(p, name); return ok }
+	return (len(include) == 0 || slices.ContainsFunc(include, match)) && !slices.ContainsFunc(exclude, match)
+}
+
+// validNamePatterns returns an error for the first malformed pattern.
+func validNamePatterns(patterns ...[]string) error {
+	for _, list := range patterns {
+		for _, p := range list {
+			if _, err := path.Match(p, ""); err != nil {
+				return fmt.Errorf("invalid symbol pattern %q: %w", p, err)
+			}
+		}
+	}
+	return nil
+}
+
 // GenerateFacadesOperation implements auto-generating facades
 type GenerateFacadesOperation struct {
 	Request types.GenerateFacadesRequest
@@ -520,7 +718,7 @@ func (op *GenerateFacadesOperation) Validate(ws *types.Workspace) error {
 		return fmt.Errorf("target directory cannot be empty")
 	}
 
-	return nil
+	return validNamePatterns(op.Request.Include, op.Request.Exclude)
 }
 
 func (op *GenerateFacadesOperation) Execute(ws *types.Workspace) (*types.RefactoringPlan, error) {
@@ -533,7 +731,8 @@ func (op *GenerateFacadesOperation) Execute(ws *types.Workspace) (*types.Refacto
 
 	modulesDirAbs := filepath.Join(ws.RootPath, op.Request.ModulesDir)
 
-	for _, pkg := range ws.Packages {
+	for _, pkgPath := range slices.Sorted(maps.Keys(ws.Packages)) {
+		pkg := ws.Packages[pkgPath]
 		if !strings.HasPrefix(pkg.Path, modulesDirAbs) {
 			continue
 		}
@@ -541,7 +740,7 @@ func (op *GenerateFacadesOperation) Execute(ws *types.Workspace) (*types.Refacto
 		// Collect exported symbols matching ExportTypes filter (empty = all)
 		var exports []types.ExportSpec
 		if pkg.Symbols != nil {
-			for _, sym := range getAllExportedSymbols(pkg) {
+			for _, sym := range facadeExports(pkg, op.Request.Include, op.Request.Exclude) {
 				if len(op.Request.ExportTypes) > 0 {
 					matched := false
 					for _, et := range op.Request.ExportTypes {
@@ -592,6 +791,8 @@ func (op *GenerateFacadesOperation) Execute(ws *types.Workspace) (*types.Refacto
 // UpdateFacadesOperation implements updating existing facades
 type UpdateFacadesOperation struct {
 	Request types.UpdateFacadesRequest
+
+	dropped []types.Issue // Re-exports of symbols that no longer exist
 }
 
 func (op *UpdateFacadesOperation) Type() types.OperationType {
@@ -606,7 +807,7 @@ func (op *UpdateFacadesOperation) Validate(ws *types.Workspace) error {
 	if len(op.Request.FacadePackages) == 0 && !op.Request.AutoDetect {
 		return fmt.Errorf("no facade packages specified and auto_detect is false")
 	}
-	return nil
+	return validNamePatterns(op.Request.Include, op.Request.Exclude)
 }
 
 func (op *UpdateFacadesOperation) Execute(ws *types.Workspace) (*types.RefactoringPlan, error) {
@@ -616,15 +817,16 @@ func (op *UpdateFacadesOperation) Execute(ws *types.Workspace) (*types.Refactori
 		AffectedFiles: make([]string, 0),
 		Reversible:    true,
 	}
+	op.dropped = nil
 
 	facadePackages := op.Request.FacadePackages
 
 	// Auto-detect facade packages if requested
 	if op.Request.AutoDetect {
-		for _, pkg := range ws.Packages {
-			if isFacadePackage(pkg) {
-				if !contains(facadePackages, pkg.Path) {
-					facadePackages = append(facadePackages, pkg.Path)
+		for _, pkgPath := range slices.Sorted(maps.Keys(ws.Packages)) {
+			if isFacadePackage(ws.Packages[pkgPath]) {
+				if !contains(facadePackages, pkgPath) {
+					facadePackages = append(facadePackages, pkgPath)
 				}
 			}
 		}
@@ -641,27 +843,54 @@ func (op *UpdateFacadesOperation) Execute(ws *types.Workspace) (*types.Refactori
 			continue
 		}
 
-		// Collect source packages re-exported by this facade
-		sourceImports := collectFacadeSourceImports(facadePkg)
-		if len(sourceImports) == 0 {
-			continue
+		// The re-exports the facade declares, by source symbol, to keep
+		// their aliases
+		existing := declaredReexports(ws, facadePkg)
+		aliases := make(map[types.ExportSpec]string)
+		for _, re := range existing {
+			aliases[types.ExportSpec{SourcePackage: re.SourcePackage, SymbolName: re.SymbolName}] = re.Alias
+		}
+		gone := func(re types.ExportSpec) bool {
+			pkg, ok := ws.Packages[ws.ImportToPath[re.SourcePackage]]
+			if !ok {
+				// A package of the module that was moved or removed
+				return ws.Module != nil && strings.HasPrefix(re.SourcePackage+"/", ws.Module.Path+"/")
+			}
+			return pkg.Symbols == nil || pkg.Symbols.FindSymbol(re.SymbolName) == nil
 		}
 
-		// Re-generate exports from each source package
 		var exports []types.ExportSpec
-		for _, importPath := range sourceImports {
-			fsPath, ok := ws.ImportToPath[importPath]
-			if !ok {
-				continue
+		if op.Request.OnlyExisting {
+			for _, re := range existing {
+				if !gone(re) && matchesNamePatterns(re.SymbolName, op.Request.Include, op.Request.Exclude) {
+					exports = append(exports, re)
+				}
 			}
-			srcPkg, ok := ws.Packages[fsPath]
-			if !ok || srcPkg.Symbols == nil {
-				continue
+		} else {
+			// Re-generate exports from each source package
+			for _, importPath := range collectFacadeSourceImports(facadePkg) {
+				fsPath, ok := ws.ImportToPath[importPath]
+				if !ok {
+					continue
+				}
+				srcPkg, ok := ws.Packages[fsPath]
+				if !ok || srcPkg.Symbols == nil {
+					continue
+				}
+				for _, sym := range facadeExports(srcPkg, op.Request.Include, op.Request.Exclude) {
+					spec := types.ExportSpec{SourcePackage: importPath, SymbolName: sym.Name}
+					spec.Alias = aliases[spec]
+					exports = append(exports, spec)
+				}
 			}
-			for _, sym := range getAllExportedSymbols(srcPkg) {
-				exports = append(exports, types.ExportSpec{
-					SourcePackage: importPath,
-					SymbolName:    sym.Name,
+		}
+		for _, re := range existing {
+			if gone(re) {
+				op.dropped = append(op.dropped, types.Issue{
+					Type:        types.IssueCompilationError,
+					Description: fmt.Sprintf("facade %s no longer re-exports %s: %s.%s does not exist", facadePkg.Name, cmp.Or(re.Alias, re.SymbolName), re.SourcePackage, re.SymbolName),
+					File:        filepath.Join(fsPkgPath, "facade.go"),
+					Severity:    types.Warning,
 				})
 			}
 		}
@@ -685,13 +914,90 @@ func (op *UpdateFacadesOperation) Execute(ws *types.Workspace) (*types.Refactori
 				plan.AffectedFiles = append(plan.AffectedFiles, f)
 			}
 		}
+
+		// Other re-export files of the facade are merged into facade.go
+		for _, name := range slices.Sorted(maps.Keys(facadePkg.Files)) {
+			file := facadePkg.Files[name]
+			if name == "facade.go" || len(file.OriginalContent) == 0 || !isFacadeFile(file) {
+				continue
+			}
+			plan.Changes = append(plan.Changes, types.Change{
+				File:        file.Path,
+				End:         len(file.OriginalContent),
+				OldText:     string(file.OriginalContent),
+				Description: fmt.Sprintf("Remove %s (merged into facade.go)", file.Path),
+			})
+			plan.AffectedFiles = append(plan.AffectedFiles, file.Path)
+		}
 	}
 
 	return plan, nil
 }
 
-// isFacadePackage returns true if all non-trivial lines in the package files are
-// re-export declarations (type/var/const X = pkg.X).
+// declaredReexports returns the re-exports (type/var/const X = pkg.Y) the
+// files of pkg declare, with X as the alias when it differs from Y.
+func declaredReexports(ws *types.Workspace, pkg *types.Package) []types.ExportSpec {
+	var exports []types.ExportSpec
+	for _, name := range slices.Sorted(maps.Keys(pkg.Files)) {
+		file := pkg.Files[name]
+		if file.AST == nil {
+			continue
+		}
+		imports := make(map[string]string) // local name -> import path
+		for _, imp := range file.AST.Imports {
+			importPath, err := strconv.Unquote(imp.Path.Value)
+			if err != nil {
+				continue
+			}
+			local := sourcePackageName(ws, importPath)
+			if imp.Name != nil {
+				local = imp.Name.Name
+			}
+			imports[local] = importPath
+		}
+		for _, decl := range file.AST.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				var name string
+				var value ast.Expr
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					if !spec.Assign.IsValid() {
+						continue
+					}
+					name, value = spec.Name.Name, spec.Type
+				case *ast.ValueSpec:
+					if len(spec.Names) != 1 || len(spec.Values) != 1 {
+						continue
+					}
+					name, value = spec.Names[0].Name, spec.Values[0]
+				default:
+					continue
+				}
+				sel, ok := value.(*ast.SelectorExpr)
+				if !ok {
+					continue
+				}
+				x, ok := sel.X.(*ast.Ident)
+				if !ok || imports[x.Name] == "" {
+					continue
+				}
+				re := types.ExportSpec{SourcePackage: imports[x.Name], SymbolName: sel.Sel.Name}
+				if name != sel.Sel.Name {
+					re.Alias = name
+				}
+				exports = append(exports, re)
+			}
+		}
+	}
+	return exports
+}
+
+// isFacadePackage returns true if all the package files only declare
+// re-exports (type/var/const X = pkg.X).
 func isFacadePackage(pkg *types.Package) bool {
 	hasFiles := false
 	for _, file := range pkg.Files {
@@ -699,25 +1005,40 @@ func isFacadePackage(pkg *types.Package) bool {
 			continue
 		}
 		hasFiles = true
-		for line := range strings.SplitSeq(string(file.OriginalContent), "\n") {
-			trimmed := strings.TrimSpace(line)
-			switch {
-			case trimmed == "", strings.HasPrefix(trimmed, "//"),
-				strings.HasPrefix(trimmed, "package "),
-				strings.HasPrefix(trimmed, "import"),
-				trimmed == ")", trimmed == "(":
-				continue
-			}
-			isReexport := (strings.HasPrefix(trimmed, "type ") || strings.HasPrefix(trimmed, "var ") || strings.HasPrefix(trimmed, "const ")) &&
-				strings.Contains(trimmed, " = ")
-			if !isReexport {
-				return false
-			}
+		if !isFacadeFile(file) {
+			return false
 		}
 	}
 	return hasFiles
 }
 
+// isFacadeFile returns true if file only declares imports and re-exports:
+// type aliases, and variables and constants with values.
+func isFacadeFile(file *types.File) bool {
+	if file.AST == nil {
+		return false
+	}
+	for _, decl := range file.AST.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok {
+			return false
+		}
+		for _, spec := range gen.Specs {
+			switch spec := spec.(type) {
+			case *ast.TypeSpec:
+				if !spec.Assign.IsValid() {
+					return false
+				}
+			case *ast.ValueSpec:
+				if len(spec.Values) == 0 {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
 // collectFacadeSourceImports returns the distinct import paths used in the package's files.
 func collectFacadeSourceImports(pkg *types.Package) []string {
 	seen := make(map[string]bool)
@@ -0,0 +1,91 @@
+package refactor
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+var facadeFiles = map[string]string{
+	"a/a.go":        "package a\n\nfunc A() {}\n\nfunc NewThing() *Thing { return nil }\n\ntype Thing struct{}\n",
+	"api/facade.go": "package api\n\nimport (\n\t\"example.com/p/a\"\n)\n\ntype Item = a.Thing\n\nvar Gone = a.Gone\n",
+}
+
+func TestUpdateFacades(t *testing.T) {
+	engine, ws, dir := loadTestModuleWith(t, facadeFiles)
+	facade := filepath.Join(dir, "api", "facade.go")
+
+	tests := []struct {
+		name    string
+		req     types.UpdateFacadesRequest
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "all",
+			req:     types.UpdateFacadesRequest{AutoDetect: true},
+			want:    []string{"type Item = a.Thing", "var A = a.A", "var NewThing = a.NewThing"},
+			notWant: []string{"Gone", "type Thing = a.Thing"},
+		},
+		{
+			name:    "filtered",
+			req:     types.UpdateFacadesRequest{FacadePackages: []string{"api"}, Exclude: []string{"New*"}},
+			want:    []string{"type Item = a.Thing", "var A = a.A"},
+			notWant: []string{"NewThing", "Gone"},
+		},
+		{
+			name:    "only existing",
+			req:     types.UpdateFacadesRequest{FacadePackages: []string{"api"}, OnlyExisting: true},
+			want:    []string{"type Item = a.Thing"},
+			notWant: []string{"var A", "NewThing", "Gone"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := engine.UpdateFacades(ws, tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := planContent(t, plan, facade)
+			if strings.Count(got, "package api") != 1 {
+				t.Errorf("facade.go was not replaced:\n%s", got)
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(got, w) {
+					t.Errorf("facade.go lacks %q:\n%s", w, got)
+				}
+			}
+			for _, nw := range tt.notWant {
+				if strings.Contains(got, nw) {
+					t.Errorf("facade.go has %q:\n%s", nw, got)
+				}
+			}
+			var warned bool
+			for _, issue := range plan.Impact.PotentialIssues {
+				warned = warned || issue.Severity == types.Warning && strings.Contains(issue.Description, "Gone")
+			}
+			if !warned {
+				t.Errorf("no warning for the dropped re-export Gone: %+v", plan.Impact.PotentialIssues)
+			}
+		})
+	}
+
+	if _, err := engine.UpdateFacades(ws, types.UpdateFacadesRequest{AutoDetect: true, Include: []string{"["}}); err == nil {
+		t.Error("expected an error for a malformed pattern")
+	}
+}
+
+func TestGenerateFacades_Include(t *testing.T) {
+	engine, ws, dir := loadTestModuleWith(t, facadeFiles)
+
+	plan, err := engine.GenerateFacades(ws, types.GenerateFacadesRequest{ModulesDir: "a", TargetDir: "pub", Include: []string{"New*"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := planContent(t, plan, filepath.Join(dir, "pub", "a", "facade.go"))
+	if !strings.Contains(got, "var NewThing = a.NewThing") || strings.Contains(got, "var A =") {
+		t.Errorf("facade:\n%s", got)
+	}
+}
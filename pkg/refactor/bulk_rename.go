@@ -0,0 +1,205 @@
+package refactor
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/mamaar/gorefactor/pkg/analysis"
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// BulkRenameOperation renames many symbols and methods in one plan, from
+// the request's renames and the renames of a mapping file. Each rename is
+// planned as rename_symbol or rename_method would plan it on its own; the
+// renames that can't be made, because a name is missing or taken, another
+// rename of the batch takes the same name, or their edits overlap, are all
+// reported as errors of the plan, which then can't be applied.
+type BulkRenameOperation struct {
+	Request types.BulkRenameRequest
+	Parser  *analysis.GoParser // Type-checks packages on demand for method renames; may be nil if they already are
+
+	renames []types.SymbolRename // The request's and the file's, read by Validate
+}
+
+func (op *BulkRenameOperation) Type() types.OperationType {
+	return types.BulkRenameOperation
+}
+
+func (op *BulkRenameOperation) Description() string {
+	if op.Request.File != "" {
+		return fmt.Sprintf("Rename the symbols mapped in %s", op.Request.File)
+	}
+	return fmt.Sprintf("Rename %d symbols", len(op.Request.Renames))
+}
+
+func (op *BulkRenameOperation) Validate(ws *types.Workspace) error {
+	renames := slices.Clone(op.Request.Renames)
+	if op.Request.File != "" {
+		path := op.Request.File
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(ws.RootPath, path)
+		}
+		mapped, err := LoadRenameMap(path)
+		if err != nil {
+			return &types.RefactorError{Type: types.InvalidOperation, Message: err.Error(), File: path}
+		}
+		renames = append(renames, mapped...)
+	}
+	if len(renames) == 0 {
+		return &types.RefactorError{Type: types.InvalidOperation, Message: "no renames given"}
+	}
+
+	for i, r := range renames {
+		pkg, name := splitRenameTarget(r)
+		typeName, member, isMethod := strings.Cut(name, ".")
+		switch {
+		case !isValidGoIdentifier(r.New):
+			return &types.RefactorError{Type: types.InvalidOperation, Message: fmt.Sprintf("rename %d: invalid Go identifier: %q", i+1, r.New)}
+		case !isValidGoIdentifier(typeName) || isMethod && !isValidGoIdentifier(member):
+			return &types.RefactorError{Type: types.InvalidOperation, Message: fmt.Sprintf("rename %d: %q is not a name or Type.Method", i+1, r.Old)}
+		case pkg != "" && findRenamePackages(ws, pkg) == nil:
+			return &types.RefactorError{Type: types.SymbolNotFound, Message: fmt.Sprintf("rename %d: package %s not found", i+1, pkg)}
+		}
+	}
+	op.renames = renames
+	return nil
+}
+
+func (op *BulkRenameOperation) Execute(ws *types.Workspace) (*types.RefactoringPlan, error) {
+	if op.renames == nil {
+		if err := op.Validate(ws); err != nil {
+			return nil, err
+		}
+	}
+
+	var renames []symbolRename
+	var missing []types.Issue
+	for _, r := range op.renames {
+		pkgPath, name := splitRenameTarget(r)
+		typeName, member, isMethod := strings.Cut(name, ".")
+		candidates := slices.Collect(maps.Values(ws.Packages))
+		if pkgPath != "" {
+			candidates = findRenamePackages(ws, pkgPath)
+		}
+		slices.SortFunc(candidates, func(a, b *types.Package) int { return strings.Compare(a.Path, b.Path) })
+
+		found := false
+		for _, pkg := range candidates {
+			rename := symbolRename{Package: pkg.Path, Name: name, NewName: r.New}
+			var symbol *types.Symbol
+			if isMethod {
+				rename.TypeName, rename.Name = typeName, member
+				if pkg.Symbols != nil {
+					methods := pkg.Symbols.Methods[typeName]
+					if i := slices.IndexFunc(methods, func(m *types.Symbol) bool { return m.Name == member }); i >= 0 {
+						symbol = methods[i]
+					}
+				}
+			} else {
+				symbol = pkg.Symbols.FindSymbol(name)
+			}
+			if symbol == nil {
+				continue
+			}
+			rename.File, rename.Line = symbol.File, symbol.Line
+			renames = append(renames, rename)
+			found = true
+		}
+		if !found {
+			missing = append(missing, types.Issue{
+				Type:        types.IssueNameConflict,
+				Severity:    types.Error,
+				Description: fmt.Sprintf("%s not renamed to %s: not found", r.Old, r.New),
+			})
+		}
+	}
+
+	plan := planRenames(ws, op.Parser, renames, types.Error)
+	plan.Impact.PotentialIssues = append(missing, plan.Impact.PotentialIssues...)
+	return plan, nil
+}
+
+// splitRenameTarget returns the package a rename is qualified by, from its
+// Package or a "package:" prefix of Old, and the name it renames.
+func splitRenameTarget(r types.SymbolRename) (pkg, name string) {
+	if p, n, ok := strings.Cut(r.Old, ":"); ok {
+		return p, n
+	}
+	return r.Package, r.Old
+}
+
+// findRenamePackages returns the packages whose directory or import path
+// pkgPath names.
+func findRenamePackages(ws *types.Workspace, pkgPath string) []*types.Package {
+	resolved := types.ResolvePackagePath(ws, pkgPath)
+	var pkgs []*types.Package
+	for _, pkg := range ws.Packages {
+		if pkg.Path == resolved || pkg.ImportPath == pkgPath {
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	return pkgs
+}
+
+// LoadRenameMap reads the renames of a mapping file. A .json file holds
+// an array of {"old", "new", "package"} objects, or an object mapping old
+// names to new ones. Other files are CSV with old,new[,package] records;
+// a header record starting with "old" and lines starting with # are
+// skipped. Old names are Name or Type.Method, optionally prefixed with
+// "package:".
+func LoadRenameMap(path string) ([]types.SymbolRename, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		var renames []types.SymbolRename
+		if err := json.Unmarshal(data, &renames); err == nil {
+			return renames, nil
+		}
+		var mapping map[string]string
+		if err := json.Unmarshal(data, &mapping); err != nil {
+			return nil, fmt.Errorf("%s: want an array of {old, new, package} objects or an object of old to new names: %w", path, err)
+		}
+		for _, old := range slices.Sorted(maps.Keys(mapping)) {
+			renames = append(renames, types.SymbolRename{Old: old, New: mapping[old]})
+		}
+		return renames, nil
+	}
+
+	r := csv.NewReader(strings.NewReader(string(data)))
+	r.Comment = '#'
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	var renames []types.SymbolRename
+	for first := true; ; first = false {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return renames, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if first && strings.EqualFold(strings.TrimSpace(record[0]), "old") {
+			continue
+		}
+		line, _ := r.FieldPos(0)
+		if len(record) < 2 || len(record) > 3 {
+			return nil, fmt.Errorf("%s:%d: want old,new[,package], got %d fields", path, line, len(record))
+		}
+		rename := types.SymbolRename{Old: strings.TrimSpace(record[0]), New: strings.TrimSpace(record[1])}
+		if len(record) == 3 {
+			rename.Package = strings.TrimSpace(record[2])
+		}
+		renames = append(renames, rename)
+	}
+}
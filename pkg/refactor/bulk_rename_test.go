@@ -0,0 +1,151 @@
+package refactor
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+var bulkRenameFiles = map[string]string{
+	"store/store.go": `package store
+
+type Store struct{}
+
+func (s *Store) Fetch(key string) string { return key }
+
+func Open() *Store { return &Store{} }
+
+const Limit = 10
+`,
+	"cache/cache.go": `package cache
+
+func Open() int { return 0 }
+`,
+	"app/app.go": `package app
+
+import (
+	"example.com/p/cache"
+	"example.com/p/store"
+)
+
+func Run() string {
+	_ = cache.Open()
+	return store.Open().Fetch("k")
+}
+`,
+	"renames.csv": `old,new,package
+# Only the store's Open is renamed.
+Open,New,store
+Store.Fetch,Get
+cache:Open,Dial
+`,
+}
+
+func TestBulkRename(t *testing.T) {
+	engine, ws, dir := loadTestModuleWith(t, bulkRenameFiles)
+
+	plan, err := engine.BulkRename(ws, types.BulkRenameRequest{
+		File:    "renames.csv",
+		Renames: []types.SymbolRename{{Old: "Limit", New: "MaxKeys"}},
+	})
+	if err != nil {
+		t.Fatalf("BulkRename: %v", err)
+	}
+	if len(plan.Impact.PotentialIssues) > 0 {
+		t.Errorf("unexpected issues: %+v", plan.Impact.PotentialIssues)
+	}
+
+	store := planContent(t, plan, filepath.Join(dir, "store", "store.go"))
+	for _, want := range []string{"func (s *Store) Get(key string)", "func New() *Store", "const MaxKeys = 10"} {
+		if !strings.Contains(store, want) {
+			t.Errorf("store.go lacks %q:\n%s", want, store)
+		}
+	}
+	if cache := planContent(t, plan, filepath.Join(dir, "cache", "cache.go")); !strings.Contains(cache, "func Dial() int") {
+		t.Errorf("cache.Open should be renamed Dial:\n%s", cache)
+	}
+	app := planContent(t, plan, filepath.Join(dir, "app", "app.go"))
+	for _, want := range []string{"cache.Dial()", `store.New().Get("k")`} {
+		if !strings.Contains(app, want) {
+			t.Errorf("app.go lacks %q:\n%s", want, app)
+		}
+	}
+}
+
+func TestBulkRename_Conflicts(t *testing.T) {
+	engine, ws, _ := loadTestModuleWith(t, bulkRenameFiles)
+
+	plan, err := engine.BulkRename(ws, types.BulkRenameRequest{Renames: []types.SymbolRename{
+		{Old: "store:Open", New: "Make"},
+		{Old: "store:Limit", New: "Make"},
+		{Old: "Store.Fetch", New: "Fetch2"},
+		{Old: "Missing", New: "Found"},
+	}})
+	if err != nil {
+		t.Fatalf("BulkRename: %v", err)
+	}
+	var errs []string
+	for _, issue := range plan.Impact.PotentialIssues {
+		if issue.Severity == types.Error {
+			errs = append(errs, issue.Description)
+		}
+	}
+	if len(errs) != 2 || !strings.Contains(errs[0], "Missing") || !strings.Contains(errs[1], "Open is renamed to Make too") {
+		t.Errorf("expected the missing symbol and the duplicate name to be errors, got %q", errs)
+	}
+	if err := engine.checkPlan(plan); err == nil {
+		t.Error("a plan with conflicting renames should be refused")
+	}
+}
+
+func TestBulkRename_Invalid(t *testing.T) {
+	engine, ws, _ := loadTestModuleWith(t, bulkRenameFiles)
+	for _, req := range []types.BulkRenameRequest{
+		{},
+		{Renames: []types.SymbolRename{{Old: "Open", New: "1st"}}},
+		{Renames: []types.SymbolRename{{Old: "nowhere:Open", New: "Dial"}}},
+		{File: "missing.csv"},
+	} {
+		if _, err := engine.BulkRename(ws, req); err == nil {
+			t.Errorf("expected %+v to fail", req)
+		}
+	}
+}
+
+func TestLoadRenameMap(t *testing.T) {
+	dir := t.TempDir()
+	want := []types.SymbolRename{{Old: "Fetch", New: "Get"}, {Old: "Open", New: "New", Package: "store"}}
+	for name, content := range map[string]string{
+		"array.json":  `[{"old": "Fetch", "new": "Get"}, {"old": "Open", "new": "New", "package": "store"}]`,
+		"object.json": `{"Open": "New", "Fetch": "Get"}`,
+		"map.csv":     "Fetch, Get\nOpen,New,store\n",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := LoadRenameMap(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		expected := want
+		if name == "object.json" {
+			expected = []types.SymbolRename{{Old: "Fetch", New: "Get"}, {Old: "Open", New: "New"}}
+		}
+		if !slices.Equal(got, expected) {
+			t.Errorf("%s: got %+v, want %+v", name, got, expected)
+		}
+	}
+
+	bad := filepath.Join(dir, "bad.csv")
+	if err := os.WriteFile(bad, []byte("Open\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadRenameMap(bad); err == nil || !strings.Contains(err.Error(), "bad.csv:1") {
+		t.Errorf("expected a one-field record to fail with its line, got %v", err)
+	}
+}
@@ -6,11 +6,12 @@ import (
 	"go/token"
 	"io"
 	"log/slog"
-	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"golang.org/x/tools/go/ast/astutil"
+
 	"github.com/mamaar/gorefactor/pkg/analysis"
 	pkgtypes "github.com/mamaar/gorefactor/pkg/types"
 )
@@ -32,6 +33,8 @@ type ChangeSignatureRequest struct {
 	DefaultValue         string
 	NewParamPosition     int
 	CachedIndex          *analysis.ReferenceIndex
+	CheckDependents      bool  // Report uses in the engine's dependent repositories
+	ReturnOrder          []int // Reorder the results: new result i is old result ReturnOrder[i]
 }
 
 // ChangeSignatureOperation implements changing function/method signatures
@@ -47,8 +50,11 @@ type ChangeSignatureOperation struct {
 	NewReturnPosition    int                      // Position where a new return type was inserted (-1 if N/A)
 	RemovedReturnIndex   int                      // Which return was removed (-1 if N/A)
 	DefaultReturnValue   string                   // Default value for new return statements (e.g., "", nil, 0)
+	ReturnOrder          []int                    // New result i is old result ReturnOrder[i] (nil if N/A); NewReturns defaults to the results as written, reordered
 	CachedIndex          *analysis.ReferenceIndex // Optional pre-built reference index for performance
 	Logger               *slog.Logger             // Logger for progress reporting
+
+	reorderIssues []pkgtypes.Issue // Uses of the results that reordering can't update
 }
 
 func (op *ChangeSignatureOperation) Type() pkgtypes.OperationType {
@@ -117,6 +123,12 @@ func (op *ChangeSignatureOperation) Validate(ws *pkgtypes.Workspace) error {
 	}
 
 validateParams:
+	if op.ReturnOrder != nil {
+		if err := op.validateReturnOrder(functionNode); err != nil {
+			return err
+		}
+	}
+
 	// Validate parameter names are valid Go identifiers
 	for _, param := range op.NewParams {
 		if param.Name != "" && !isValidGoIdentifierExtract(param.Name) {
@@ -153,6 +165,10 @@ func (op *ChangeSignatureOperation) Execute(ws *pkgtypes.Workspace) (*pkgtypes.R
 		}
 	}
 
+	if op.ReturnOrder != nil && len(op.NewReturns) == 0 {
+		op.NewReturns = op.reorderedResults(ws, sourceFile)
+	}
+
 	// Preserve existing return types if not explicitly provided (fixes add_param/remove_param dropping returns)
 	if err := op.preserveExistingReturnsIfNeeded(sourceFile); err != nil {
 		return nil, err
@@ -199,11 +215,17 @@ func (op *ChangeSignatureOperation) Execute(ws *pkgtypes.Workspace) (*pkgtypes.R
 		}
 	}
 
-	callSiteCount := op.updateCallSites(ws, sourcePackage, allRefSymbols, resolver, idx, plan)
+	var callSiteCount int
+	if op.ReturnOrder != nil {
+		callSiteCount = op.reorderResults(ws, sourceFile, sourcePackage, primaryFuncDecl, allRefSymbols, resolver, idx, plan)
+	} else {
+		callSiteCount = op.updateCallSites(ws, sourcePackage, allRefSymbols, resolver, idx, plan)
+	}
 	op.updateReturnStatements(ws, sourcePackage, allRefSymbols, resolver, idx, plan)
 	op.updateAssignmentLHS(ws, sourcePackage, allRefSymbols, resolver, idx, plan)
 	op.addDefaultValueImports(ws, plan)
 	op.buildImpactAnalysis(ws, plan, primaryFuncDecl, callSiteCount)
+	plan.Impact.PotentialIssues = append(plan.Impact.PotentialIssues, op.reorderIssues...)
 
 	return plan, nil
 }
@@ -297,17 +319,21 @@ func (op *ChangeSignatureOperation) collectConcreteChanges(
 		}
 	}
 
-	newSignature := op.generateNewSignature(functionNode)
-	oldSignature := op.extractCurrentSignature(functionNode)
-
-	plan.Changes = append(plan.Changes, pkgtypes.Change{
-		File:        sourceFile.Path,
-		Start:       op.tokenPosToOffset(ws, functionNode.Type.Pos()),
-		End:         op.tokenPosToOffset(ws, functionNode.Type.End()),
-		OldText:     oldSignature,
-		NewText:     newSignature,
-		Description: fmt.Sprintf("Update signature of function %s", op.FunctionName),
-	})
+	if op.ReturnOrder != nil {
+		plan.Changes = append(plan.Changes, op.resultsChange(ws, sourceFile, functionNode))
+	} else {
+		newSignature := op.generateNewSignature(functionNode)
+		oldSignature := op.extractCurrentSignature(functionNode)
+
+		plan.Changes = append(plan.Changes, pkgtypes.Change{
+			File:        sourceFile.Path,
+			Start:       op.tokenPosToOffset(ws, functionNode.Type.Pos()),
+			End:         op.tokenPosToOffset(ws, functionNode.Type.End()),
+			OldText:     oldSignature,
+			NewText:     newSignature,
+			Description: fmt.Sprintf("Update signature of function %s", op.FunctionName),
+		})
+	}
 	plan.AffectedFiles = append(plan.AffectedFiles, sourceFile.Path)
 
 	symbol, err := resolver.ResolveSymbol(sourcePackage, op.FunctionName)
@@ -1079,13 +1105,18 @@ func receiverTypeName(funcDecl *ast.FuncDecl) string {
 	return baseTypeName(funcDecl.Recv.List[0].Type)
 }
 
-// baseTypeName strips the pointer star from a type expression and returns the ident name.
+// baseTypeName strips the pointer star and type arguments from a type
+// expression and returns the ident name.
 func baseTypeName(expr ast.Expr) string {
 	switch t := expr.(type) {
 	case *ast.Ident:
 		return t.Name
 	case *ast.StarExpr:
 		return baseTypeName(t.X)
+	case *ast.IndexExpr:
+		return baseTypeName(t.X)
+	case *ast.IndexListExpr:
+		return baseTypeName(t.X)
 	}
 	return ""
 }
@@ -1458,7 +1489,7 @@ func extractRequiredImport(defaultValue string) string {
 // hasImport checks if a file already has a specific import
 func hasImport(ws *pkgtypes.Workspace, filePath, importPath string) bool {
 	for _, pkg := range ws.Packages {
-		for _, file := range pkg.Files {
+		for _, file := range packageFiles(pkg) {
 			if file.Path == filePath && file.AST != nil {
 				for _, imp := range file.AST.Imports {
 					// Strip quotes from import path
@@ -1541,8 +1572,8 @@ func (op *ChangeSignatureOperation) updateReturnStatements(
 	allRefSymbols []*pkgtypes.Symbol, resolver *analysis.SymbolResolver,
 	idx *analysis.ReferenceIndex, plan *pkgtypes.RefactoringPlan,
 ) {
-	if op.NewReturnPosition < 0 && op.RemovedReturnIndex < 0 {
-		return // Not a return-type change operation
+	if op.ReturnOrder != nil || op.NewReturnPosition < 0 && op.RemovedReturnIndex < 0 {
+		return // Not a return-type change operation; reordering is done by reorderResults
 	}
 
 	logger := op.logger()
@@ -1563,7 +1594,7 @@ func (op *ChangeSignatureOperation) updateReturnStatements(
 			continue
 		}
 
-		content, err := os.ReadFile(file.Path)
+		content, err := readSource(ws, file.Path)
 		if err != nil {
 			continue
 		}
@@ -1663,8 +1694,8 @@ func (op *ChangeSignatureOperation) updateAssignmentLHS(
 	allRefSymbols []*pkgtypes.Symbol, resolver *analysis.SymbolResolver,
 	idx *analysis.ReferenceIndex, plan *pkgtypes.RefactoringPlan,
 ) {
-	if op.NewReturnPosition < 0 && op.RemovedReturnIndex < 0 {
-		return // Not a return-type change operation
+	if op.ReturnOrder != nil || op.NewReturnPosition < 0 && op.RemovedReturnIndex < 0 {
+		return // Not a return-type change operation; reordering is done by reorderResults
 	}
 
 	// Parse FunctionName to get method name
@@ -1690,7 +1721,7 @@ func (op *ChangeSignatureOperation) updateAssignmentLHS(
 			continue
 		}
 
-		content, err := os.ReadFile(file.Path)
+		content, err := readSource(ws, file.Path)
 		if err != nil {
 			continue
 		}
@@ -1834,3 +1865,214 @@ func collectAllWorkspaceFiles(ws *pkgtypes.Workspace) []*pkgtypes.File {
 	}
 	return files
 }
+
+// --- Result reordering ---
+
+// validateReturnOrder checks that ReturnOrder is a permutation of the
+// results of a concrete function.
+func (op *ChangeSignatureOperation) validateReturnOrder(funcDecl *ast.FuncDecl) error {
+	if funcDecl == nil {
+		return &pkgtypes.RefactorError{
+			Type:    pkgtypes.InvalidOperation,
+			Message: fmt.Sprintf("the results of interface method %s can't be reordered; reorder those of its implementations", op.FunctionName),
+		}
+	}
+	n := countFieldListEntries(funcDecl.Type.Results)
+	seen := make(map[int]bool)
+	for _, i := range op.ReturnOrder {
+		if i < 0 || i >= n || seen[i] {
+			seen = nil
+			break
+		}
+		seen[i] = true
+	}
+	if len(op.ReturnOrder) != n || seen == nil {
+		return &pkgtypes.RefactorError{
+			Type:    pkgtypes.InvalidOperation,
+			Message: fmt.Sprintf("return order %v is not a permutation of the %d results of %s", op.ReturnOrder, n, op.FunctionName),
+		}
+	}
+	return nil
+}
+
+// reorderedResults returns the results of the function as written, names
+// included, in the order ReturnOrder gives them.
+func (op *ChangeSignatureOperation) reorderedResults(ws *pkgtypes.Workspace, sourceFile *pkgtypes.File) []string {
+	funcDecl := op.findFunction(sourceFile, op.FunctionName)
+	content, err := readSource(ws, sourceFile.Path)
+	if funcDecl == nil || err != nil {
+		return nil
+	}
+	var results []string
+	for _, field := range funcDecl.Type.Results.List {
+		start, end := op.tokenPosToOffset(ws, field.Type.Pos()), op.tokenPosToOffset(ws, field.Type.End())
+		typ := string(content[start:end])
+		if len(field.Names) == 0 {
+			results = append(results, typ)
+		}
+		for _, name := range field.Names {
+			results = append(results, name.Name+" "+typ)
+		}
+	}
+	reordered := make([]string, len(op.ReturnOrder))
+	for i, old := range op.ReturnOrder {
+		reordered[i] = results[old]
+	}
+	return reordered
+}
+
+// resultsChange replaces the result list of funcDecl with NewReturns,
+// leaving the rest of the signature as written.
+func (op *ChangeSignatureOperation) resultsChange(ws *pkgtypes.Workspace, sourceFile *pkgtypes.File, funcDecl *ast.FuncDecl) pkgtypes.Change {
+	results := funcDecl.Type.Results
+	start, end := op.tokenPosToOffset(ws, results.Pos()), op.tokenPosToOffset(ws, results.End())
+	content, _ := readSource(ws, sourceFile.Path)
+	return pkgtypes.Change{
+		File:        sourceFile.Path,
+		Start:       start,
+		End:         end,
+		OldText:     string(content[start:end]),
+		NewText:     "(" + strings.Join(op.NewReturns, ", ") + ")",
+		Description: fmt.Sprintf("Reorder the results of %s", op.FunctionName),
+	}
+}
+
+// reorderResults puts the values of the function's return statements, and
+// the variables its calls assign to, in the new order of its results. Uses
+// it can't reorder, such as calls whose results are passed on whole or
+// references that aren't calls, are reported as issues. It returns the
+// number of call sites updated.
+func (op *ChangeSignatureOperation) reorderResults(
+	ws *pkgtypes.Workspace, sourceFile *pkgtypes.File, sourcePackage *pkgtypes.Package,
+	funcDecl *ast.FuncDecl, allRefSymbols []*pkgtypes.Symbol, resolver *analysis.SymbolResolver,
+	idx *analysis.ReferenceIndex, plan *pkgtypes.RefactoringPlan,
+) int {
+	permute := func(texts []string) string {
+		reordered := make([]string, len(op.ReturnOrder))
+		for i, old := range op.ReturnOrder {
+			reordered[i] = texts[old]
+		}
+		return strings.Join(reordered, ", ")
+	}
+	unhandled := func(file string, pos token.Pos, what string) {
+		op.reorderIssues = append(op.reorderIssues, pkgtypes.Issue{
+			Type:        pkgtypes.IssueCompilationError,
+			Severity:    pkgtypes.Warning,
+			Description: fmt.Sprintf("%s takes the results of %s in their old order; update it by hand", what, op.FunctionName),
+			File:        file,
+			Line:        ws.FileSet.Position(pos).Line,
+		})
+	}
+
+	content, err := readSource(ws, sourceFile.Path)
+	if err == nil && funcDecl.Body != nil {
+		walkBodyForReturnStmts(funcDecl.Body, func(retStmt *ast.ReturnStmt) {
+			switch len(retStmt.Results) {
+			case 0:
+				// A bare return: named results move with their names
+			case len(op.ReturnOrder):
+				start, end := op.tokenPosToOffset(ws, retStmt.Results[0].Pos()), op.tokenPosToOffset(ws, retStmt.End())
+				plan.Changes = append(plan.Changes, pkgtypes.Change{
+					File:        sourceFile.Path,
+					Start:       start,
+					End:         end,
+					OldText:     string(content[start:end]),
+					NewText:     permute(splitReturnValueTexts(retStmt, content, ws)),
+					Description: fmt.Sprintf("Reorder the values returned by %s", op.FunctionName),
+				})
+			default:
+				unhandled(sourceFile.Path, retStmt.Pos(), "A return statement")
+			}
+		})
+	}
+
+	packagesToSearch := make(map[string]*pkgtypes.Package)
+	if op.Scope == pkgtypes.PackageScope {
+		packagesToSearch[sourcePackage.Path] = sourcePackage
+	} else {
+		packagesToSearch = ws.Packages
+	}
+	seen := make(map[token.Pos]bool)
+	updated := 0
+	for _, sym := range allRefSymbols {
+		references, err := resolver.FindReferencesIndexedFiltered(sym, idx, packagesToSearch)
+		if err != nil {
+			continue
+		}
+		for _, ref := range references {
+			file := findFile(ws, ref.File)
+			if file == nil || file.AST == nil || seen[ref.Position] {
+				continue
+			}
+			seen[ref.Position] = true
+			content, err := readSource(ws, ref.File)
+			if err != nil {
+				continue
+			}
+			text := func(n ast.Node) string {
+				return string(content[op.tokenPosToOffset(ws, n.Pos()):op.tokenPosToOffset(ws, n.End())])
+			}
+			texts := func(exprs []ast.Expr) []string {
+				var ts []string
+				for _, e := range exprs {
+					ts = append(ts, text(e))
+				}
+				return ts
+			}
+
+			path, _ := astutil.PathEnclosingInterval(file.AST, ref.Position, ref.Position)
+			if len(path) < 2 {
+				continue
+			}
+			if _, ok := path[1].(*ast.FuncDecl); ok {
+				continue // The declaration
+			}
+			i := 1
+			if sel, ok := path[i].(*ast.SelectorExpr); ok && sel.Sel.Pos() == ref.Position {
+				i++
+			}
+			var call *ast.CallExpr
+			if i < len(path) {
+				call, _ = path[i].(*ast.CallExpr)
+			}
+			if call == nil || ast.Unparen(call.Fun) != path[i-1] || i+1 >= len(path) {
+				unhandled(ref.File, ref.Position, "A reference that isn't a call")
+				continue
+			}
+
+			var lhs []ast.Expr
+			switch parent := path[i+1].(type) {
+			case *ast.AssignStmt:
+				if len(parent.Rhs) == 1 && len(parent.Lhs) == len(op.ReturnOrder) {
+					lhs = parent.Lhs
+				}
+			case *ast.ValueSpec:
+				if len(parent.Values) == 1 && len(parent.Names) == len(op.ReturnOrder) {
+					for _, name := range parent.Names {
+						lhs = append(lhs, name)
+					}
+				}
+			case *ast.ExprStmt, *ast.GoStmt, *ast.DeferStmt:
+				continue // The results are dropped
+			}
+			if lhs == nil {
+				unhandled(ref.File, call.Pos(), "A call")
+				continue
+			}
+			start, end := op.tokenPosToOffset(ws, lhs[0].Pos()), op.tokenPosToOffset(ws, lhs[len(lhs)-1].End())
+			plan.Changes = append(plan.Changes, pkgtypes.Change{
+				File:        ref.File,
+				Start:       start,
+				End:         end,
+				OldText:     string(content[start:end]),
+				NewText:     permute(texts(lhs)),
+				Description: fmt.Sprintf("Reorder the variables assigned from the call to %s", op.FunctionName),
+			})
+			updated++
+			if !contains(plan.AffectedFiles, ref.File) {
+				plan.AffectedFiles = append(plan.AffectedFiles, ref.File)
+			}
+		}
+	}
+	return updated
+}
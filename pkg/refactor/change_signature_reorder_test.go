@@ -0,0 +1,46 @@
+package refactor
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+func TestChangeSignature_ReturnOrder(t *testing.T) {
+	engine, ws, dir := loadTestModuleWith(t, errorReturnFiles)
+	file := filepath.Join(dir, "store", "store.go")
+
+	plan, err := engine.ChangeSignature(ws, ChangeSignatureRequest{
+		FunctionName: "Load",
+		SourceFile:   file,
+		Scope:        types.WorkspaceScope,
+		ReturnOrder:  []int{1, 0},
+	})
+	if err != nil {
+		t.Fatalf("ChangeSignature: %v", err)
+	}
+	store := planContent(t, plan, file)
+	for _, want := range []string{"func Load(key string) (string, error) {", `return "value", nil`, "v, e := Load(key)"} {
+		if !strings.Contains(store, want) {
+			t.Errorf("store.go lacks %q:\n%s", want, store)
+		}
+	}
+	var byHand bool
+	for _, issue := range plan.Impact.PotentialIssues {
+		byHand = byHand || strings.Contains(issue.Description, "by hand")
+	}
+	if !byHand {
+		t.Errorf("expected Forward's return of Load's results to be reported, got %+v", plan.Impact.PotentialIssues)
+	}
+
+	if _, err := engine.ChangeSignature(ws, ChangeSignatureRequest{
+		FunctionName: "Load",
+		SourceFile:   file,
+		Scope:        types.WorkspaceScope,
+		ReturnOrder:  []int{0, 0},
+	}); err == nil {
+		t.Error("expected an order that isn't a permutation to fail")
+	}
+}
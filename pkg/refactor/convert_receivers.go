@@ -0,0 +1,662 @@
+package refactor
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	gotypes "go/types"
+	"maps"
+	"slices"
+
+	"github.com/mamaar/gorefactor/pkg/analysis"
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// ConvertReceiversOperation gives every method of a type the same receiver
+// form, pointer or value. Uses of the receiver in the converted methods
+// keep their type (r becomes *r or &r), calls that need an addressable
+// value are fixed where it is a composite literal, and method expressions
+// take the pointer type. What the conversion changes but can't fix is
+// reported in the plan's impact: methods whose writes to their receiver
+// change meaning, interfaces the type starts or stops implementing, values
+// used as an interface the type no longer implements, and locks copied by
+// value receivers.
+type ConvertReceiversOperation struct {
+	Request types.ConvertReceiversRequest
+	Parser  *analysis.GoParser // Type-checks packages on demand; may be nil if they already are
+
+	// Resolved by Validate
+	pkg     *types.Package
+	obj     *gotypes.TypeName
+	target  types.ReceiverKind
+	methods []receiverMethod // The methods not in the target form
+}
+
+type receiverMethod struct {
+	file *types.File
+	decl *ast.FuncDecl
+}
+
+func (op *ConvertReceiversOperation) Type() types.OperationType {
+	return types.ConvertReceiversOperation
+}
+
+func (op *ConvertReceiversOperation) Description() string {
+	if op.target != "" {
+		return fmt.Sprintf("Give the methods of %s %s receivers", op.Request.TypeName, op.target)
+	}
+	return fmt.Sprintf("Give the methods of %s consistent receivers", op.Request.TypeName)
+}
+
+func (op *ConvertReceiversOperation) Validate(ws *types.Workspace) error {
+	req := op.Request
+	if req.TypeName == "" {
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: "type name is required",
+		}
+	}
+	switch req.Receiver {
+	case "", types.PointerReceiver, types.ValueReceiver:
+	default:
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("unknown receiver %q; use pointer or value", req.Receiver),
+		}
+	}
+
+	var methods []receiverMethod
+	found := 0
+	for _, pkgPath := range slices.Sorted(maps.Keys(ws.Packages)) {
+		pkg := ws.Packages[pkgPath]
+		if req.Package != "" && pkg.Path != req.Package && pkg.ImportPath != req.Package {
+			continue
+		}
+		var declared []receiverMethod
+		for _, fileName := range slices.Sorted(maps.Keys(pkg.Files)) {
+			file := pkg.Files[fileName]
+			if file.AST == nil {
+				continue
+			}
+			for _, decl := range file.AST.Decls {
+				switch decl := decl.(type) {
+				case *ast.FuncDecl:
+					if receiverTypeName(decl) == req.TypeName {
+						declared = append(declared, receiverMethod{file, decl})
+					}
+				case *ast.GenDecl:
+					if decl.Tok != token.TYPE {
+						continue
+					}
+					for _, spec := range decl.Specs {
+						if spec.(*ast.TypeSpec).Name.Name == req.TypeName {
+							found++
+							op.pkg = pkg
+						}
+					}
+				}
+			}
+		}
+		if op.pkg == pkg {
+			methods = declared
+		}
+	}
+	switch {
+	case found == 0:
+		return &types.RefactorError{
+			Type:    types.SymbolNotFound,
+			Message: fmt.Sprintf("type %s not found", req.TypeName),
+		}
+	case found > 1:
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("type %s is declared in %d packages; specify the package", req.TypeName, found),
+		}
+	case len(methods) == 0:
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("%s declares no methods", req.TypeName),
+		}
+	}
+
+	if op.Parser != nil {
+		op.Parser.EnsureTypeChecked(ws, op.pkg)
+	}
+	if op.pkg.TypesPkg == nil || op.pkg.TypesInfo == nil {
+		return &types.RefactorError{
+			Type:    types.CompilationError,
+			Message: fmt.Sprintf("package %s could not be type-checked, which converting receivers needs", op.pkg.ImportPath),
+		}
+	}
+	op.obj, _ = op.pkg.TypesPkg.Scope().Lookup(req.TypeName).(*gotypes.TypeName)
+	if op.obj == nil || op.obj.IsAlias() {
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("%s is not a defined type of package %s", req.TypeName, op.pkg.ImportPath),
+		}
+	}
+
+	pointers := 0
+	for _, m := range methods {
+		if hasPointerReceiver(m.decl) {
+			pointers++
+		}
+	}
+	op.target = req.Receiver
+	if op.target == "" {
+		op.target = types.ValueReceiver
+		if 2*pointers >= len(methods) {
+			op.target = types.PointerReceiver
+		}
+	}
+	op.methods = nil
+	for _, m := range methods {
+		if hasPointerReceiver(m.decl) != (op.target == types.PointerReceiver) {
+			op.methods = append(op.methods, m)
+		}
+	}
+	if len(op.methods) == 0 {
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("the methods of %s already all have %s receivers", req.TypeName, op.target),
+		}
+	}
+	return nil
+}
+
+func (op *ConvertReceiversOperation) Execute(ws *types.Workspace) (*types.RefactoringPlan, error) {
+	c := &receiverConversion{
+		op:        op,
+		ws:        ws,
+		toPointer: op.target == types.PointerReceiver,
+		converted: make(map[string]bool),
+	}
+	for _, m := range op.methods {
+		c.converted[m.decl.Name.Name] = true
+	}
+	for _, m := range op.methods {
+		c.convertMethod(m)
+	}
+	c.checkInterfaces()
+	if c.toPointer {
+		c.fixUses()
+	} else {
+		c.checkLocks()
+	}
+
+	plan := &types.RefactoringPlan{Changes: c.changes, Reversible: true}
+	impact := &types.ImpactAnalysis{PotentialIssues: c.issues}
+	for _, change := range c.changes {
+		if !slices.Contains(plan.AffectedFiles, change.File) {
+			plan.AffectedFiles = append(plan.AffectedFiles, change.File)
+		}
+		if pkg := findPackageForFile(ws, change.File); pkg != nil && !slices.Contains(impact.AffectedPackages, pkg.Path) {
+			impact.AffectedPackages = append(impact.AffectedPackages, pkg.Path)
+		}
+	}
+	impact.AffectedFiles = plan.AffectedFiles
+	plan.Impact = impact
+	return plan, nil
+}
+
+// receiverConversion collects the changes and issues of converting the
+// receivers of a ConvertReceiversOperation's methods.
+type receiverConversion struct {
+	op        *ConvertReceiversOperation
+	ws        *types.Workspace
+	toPointer bool
+	converted map[string]bool // Names of the methods changing form
+
+	changes []types.Change
+	issues  []types.Issue
+}
+
+func (c *receiverConversion) replace(file *types.File, start, end token.Pos, text, desc string) {
+	c.changes = append(c.changes, types.Change{
+		File:        file.Path,
+		Start:       c.ws.FileSet.Position(start).Offset,
+		End:         c.ws.FileSet.Position(end).Offset,
+		OldText:     nodeText(c.ws, file, start, end),
+		NewText:     text,
+		Description: desc,
+	})
+}
+
+func (c *receiverConversion) issue(typ types.IssueType, severity types.IssueSeverity, pos token.Pos, msg string) {
+	p := c.ws.FileSet.Position(pos)
+	c.issues = append(c.issues, types.Issue{
+		Type:        typ,
+		Severity:    severity,
+		Description: msg,
+		File:        p.Filename,
+		Line:        p.Line,
+	})
+}
+
+// convertMethod changes the receiver of m and rewrites the uses of the
+// receiver in its body to keep their type.
+func (c *receiverConversion) convertMethod(m receiverMethod) {
+	field := m.decl.Recv.List[0]
+	method := c.op.Request.TypeName + "." + m.decl.Name.Name
+	desc := fmt.Sprintf("Give %s a %s receiver", method, c.op.target)
+	if c.toPointer {
+		c.replace(m.file, field.Type.Pos(), field.Type.Pos(), "*", desc)
+	} else {
+		star := field.Type.(*ast.StarExpr)
+		c.replace(m.file, star.Star, star.X.Pos(), "", desc)
+	}
+
+	info := c.op.pkg.TypesInfo
+	if len(field.Names) == 0 || m.decl.Body == nil {
+		return
+	}
+	recv := info.Defs[field.Names[0]]
+	if recv == nil {
+		return
+	}
+	desc = fmt.Sprintf("Keep the type of receiver %s in %s", recv.Name(), method)
+
+	var written token.Pos
+	write := func(expr ast.Expr, pos token.Pos) {
+		if !written.IsValid() && writesReceiver(info, recv, expr) {
+			written = pos
+		}
+	}
+	ast.PreorderStack(m.decl.Body, nil, func(n ast.Node, stack []ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			if n.Tok != token.DEFINE {
+				for _, lhs := range n.Lhs {
+					write(lhs, n.Pos())
+				}
+			}
+		case *ast.IncDecStmt:
+			write(n.X, n.Pos())
+		case *ast.UnaryExpr:
+			if n.Op == token.AND {
+				write(n.X, n.Pos())
+			}
+		case *ast.SelectorExpr:
+			// Pointer methods called on the receiver, or on a field of it,
+			// write to it too.
+			sel := info.Selections[n]
+			if sel == nil || sel.Kind() != gotypes.MethodVal || c.isConverted(sel.Obj()) {
+				break
+			}
+			if sig, ok := sel.Obj().Type().(*gotypes.Signature); ok && sig.Recv() != nil && isPointer(sig.Recv().Type()) && !isPointer(info.TypeOf(n.X)) {
+				write(n.X, n.Pos())
+			}
+		case *ast.Ident:
+			if info.Uses[n] == recv {
+				c.rewriteReceiverUse(m.file, n, stack[len(stack)-1], method, desc)
+			}
+		}
+		return true
+	})
+
+	if !written.IsValid() {
+		return
+	}
+	if c.toPointer {
+		c.issue(types.IssueSideEffect, types.Warning, written,
+			fmt.Sprintf("%s writes to its receiver, which with a pointer receiver changes the caller's %s instead of a copy", method, c.op.Request.TypeName))
+	} else {
+		c.issue(types.IssueSideEffect, types.Warning, written,
+			fmt.Sprintf("%s writes to its receiver, which with a value receiver changes a copy the caller doesn't see", method))
+	}
+}
+
+// rewriteReceiverUse rewrites a use of the receiver id, whose parent node
+// is parent, to keep its type once the receiver changes form.
+func (c *receiverConversion) rewriteReceiverUse(file *types.File, id *ast.Ident, parent ast.Node, method, desc string) {
+	info := c.op.pkg.TypesInfo
+	if sel, ok := parent.(*ast.SelectorExpr); ok && sel.X == id {
+		return // Fields and methods are selected through either
+	}
+
+	if c.toPointer {
+		primary := false
+		switch p := parent.(type) {
+		case *ast.UnaryExpr:
+			if p.Op == token.AND {
+				c.replace(file, p.OpPos, id.Pos(), "", desc)
+				return
+			}
+		case *ast.IndexExpr:
+			primary = p.X == id
+		case *ast.IndexListExpr:
+			primary = p.X == id
+		case *ast.SliceExpr:
+			primary = p.X == id
+		case *ast.CallExpr:
+			primary = p.Fun == id
+		}
+		if primary {
+			c.replace(file, id.Pos(), id.End(), "(*"+id.Name+")", desc)
+		} else {
+			c.replace(file, id.Pos(), id.Pos(), "*", desc)
+		}
+		return
+	}
+
+	switch p := parent.(type) {
+	case *ast.StarExpr:
+		c.replace(file, p.Star, id.Pos(), "", desc)
+		return
+	case *ast.AssignStmt:
+		if slices.Contains(p.Lhs, ast.Expr(id)) {
+			c.issue(types.IssueTypeMismatch, types.Error, id.Pos(),
+				fmt.Sprintf("%s assigns another pointer to its receiver %s; convert it by hand", method, id.Name))
+			return
+		}
+	case *ast.BinaryExpr:
+		if info.Types[p.X].IsNil() || info.Types[p.Y].IsNil() {
+			c.issue(types.IssueTypeMismatch, types.Warning, p.Pos(),
+				fmt.Sprintf("%s compares its receiver with nil; a value receiver is never nil, and calling %s on a nil pointer now panics", method, method))
+		}
+	}
+	c.replace(file, id.Pos(), id.Pos(), "&", desc)
+}
+
+// writesReceiver reports whether assigning to expr writes to recv or to
+// memory copied with it: its fields and array elements, but not what its
+// pointers, slices and maps refer to.
+func writesReceiver(info *gotypes.Info, recv gotypes.Object, expr ast.Expr) bool {
+	isRecv := func(e ast.Expr) bool {
+		id, ok := ast.Unparen(e).(*ast.Ident)
+		return ok && info.Uses[id] == recv
+	}
+	for {
+		switch e := ast.Unparen(expr).(type) {
+		case *ast.Ident:
+			return info.Uses[e] == recv
+		case *ast.StarExpr:
+			return isRecv(e.X)
+		case *ast.SelectorExpr:
+			sel := info.Selections[e]
+			switch {
+			case sel == nil || sel.Kind() != gotypes.FieldVal:
+				return false
+			case isRecv(e.X):
+				return true
+			case sel.Indirect():
+				return false
+			}
+			expr = e.X
+		case *ast.IndexExpr:
+			if _, ok := info.TypeOf(e.X).Underlying().(*gotypes.Array); !ok {
+				return false
+			}
+			expr = e.X
+		default:
+			return false
+		}
+	}
+}
+
+// isConverted reports whether obj is one of the methods changing form.
+func (c *receiverConversion) isConverted(obj gotypes.Object) bool {
+	fn, ok := obj.(*gotypes.Func)
+	if !ok || !c.converted[fn.Name()] {
+		return false
+	}
+	sig, ok := fn.Type().(*gotypes.Signature)
+	if !ok || sig.Recv() == nil {
+		return false
+	}
+	t := sig.Recv().Type()
+	if ptr, ok := t.(*gotypes.Pointer); ok {
+		t = ptr.Elem()
+	}
+	named, ok := t.(*gotypes.Named)
+	return ok && named.Obj().Name() == c.op.obj.Name() &&
+		named.Obj().Pkg() != nil && named.Obj().Pkg().Path() == c.op.obj.Pkg().Path()
+}
+
+// valueMethods returns the names of the methods of the type's values once
+// converted.
+func (c *receiverConversion) valueMethods() map[string]bool {
+	names := make(map[string]bool)
+	ms := gotypes.NewMethodSet(c.op.obj.Type())
+	for i := range ms.Len() {
+		names[ms.At(i).Obj().Name()] = true
+	}
+	for name := range c.converted {
+		names[name] = !c.toPointer
+	}
+	return names
+}
+
+// implementsAfter reports whether values of the type implement iface once
+// converted. The methods of pointers to it don't change.
+func (c *receiverConversion) implementsAfter(iface *gotypes.Interface, values map[string]bool) bool {
+	if !gotypes.Implements(gotypes.NewPointer(c.op.obj.Type()), iface) {
+		return false
+	}
+	for i := range iface.NumMethods() {
+		if !values[iface.Method(i).Name()] {
+			return false
+		}
+	}
+	return true
+}
+
+// checkInterfaces reports the interfaces of the workspace, and of the
+// packages it imports, that values of the type start or stop implementing.
+func (c *receiverConversion) checkInterfaces() {
+	t := c.op.obj.Type()
+	if named, ok := t.(*gotypes.Named); !ok || named.TypeParams().Len() > 0 {
+		return
+	}
+	values := c.valueMethods()
+	qualifier := func(p *gotypes.Package) string {
+		if p.Path() == c.op.obj.Pkg().Path() {
+			return ""
+		}
+		return p.Name()
+	}
+
+	scopes := map[string]*gotypes.Scope{"": gotypes.Universe}
+	for _, pkg := range c.ws.Packages {
+		if pkg.TypesPkg == nil {
+			continue
+		}
+		scopes[pkg.TypesPkg.Path()] = pkg.TypesPkg.Scope()
+		for _, imp := range pkg.TypesPkg.Imports() {
+			if _, ok := scopes[imp.Path()]; !ok {
+				scopes[imp.Path()] = imp.Scope()
+			}
+		}
+	}
+	for _, path := range slices.Sorted(maps.Keys(scopes)) {
+		scope := scopes[path]
+		for _, name := range scope.Names() {
+			tn, ok := scope.Lookup(name).(*gotypes.TypeName)
+			if !ok || tn.IsAlias() || (path != c.op.obj.Pkg().Path() && !tn.Exported() && path != "") {
+				continue
+			}
+			iface, ok := tn.Type().Underlying().(*gotypes.Interface)
+			if named, isNamed := tn.Type().(*gotypes.Named); !ok || iface.NumMethods() == 0 || isNamed && named.TypeParams().Len() > 0 {
+				continue
+			}
+			before, after := gotypes.Implements(t, iface), c.implementsAfter(iface, values)
+			ifaceName := gotypes.TypeString(tn.Type(), qualifier)
+			switch {
+			case before && !after:
+				c.issue(types.IssueTypeMismatch, types.Warning, c.op.obj.Pos(),
+					fmt.Sprintf("%s no longer implements %s, only *%s does; use a pointer where a %s is used as one", c.op.obj.Name(), ifaceName, c.op.obj.Name(), c.op.obj.Name()))
+			case !before && after:
+				c.issue(types.IssueTypeMismatch, types.Info, c.op.obj.Pos(),
+					fmt.Sprintf("%s now implements %s; type switches and assertions to it may start matching %s values", c.op.obj.Name(), ifaceName, c.op.obj.Name()))
+			}
+		}
+	}
+}
+
+// fixUses fixes what values of the type losing methods to pointer
+// receivers breaks, in its package and the packages importing it: calls on
+// values that aren't addressable, method expressions, and values used as
+// interfaces the type no longer implements. Composite literals have their
+// address taken; other uses are reported.
+func (c *receiverConversion) fixUses() {
+	t := c.op.obj.Type()
+	values := c.valueMethods()
+	for _, pkgPath := range slices.Sorted(maps.Keys(c.ws.Packages)) {
+		pkg := c.ws.Packages[pkgPath]
+		if pkg != c.op.pkg && !slices.Contains(pkg.Imports, c.op.pkg.ImportPath) {
+			continue
+		}
+		if c.op.Parser != nil {
+			c.op.Parser.EnsureTypeChecked(c.ws, pkg)
+		}
+		info := pkg.TypesInfo
+		if info == nil {
+			c.issue(types.IssueCompilationError, types.Warning, token.NoPos,
+				fmt.Sprintf("package %s could not be type-checked; its uses of %s were not checked", pkg.ImportPath, c.op.obj.Name()))
+			continue
+		}
+
+		// usedAs checks expr, used as a value of type target.
+		usedAs := func(file *types.File, expr ast.Expr, target gotypes.Type) {
+			iface, ok := target.Underlying().(*gotypes.Interface)
+			if typ := info.TypeOf(expr); !ok || typ == nil || iface.NumMethods() == 0 || !gotypes.Identical(typ, t) ||
+				!gotypes.Implements(t, iface) || c.implementsAfter(iface, values) {
+				return
+			}
+			if _, ok := ast.Unparen(expr).(*ast.CompositeLit); ok {
+				c.replace(file, expr.Pos(), expr.Pos(), "&", fmt.Sprintf("Use a *%s as %s", c.op.obj.Name(), target))
+				return
+			}
+			c.issue(types.IssueTypeMismatch, types.Error, expr.Pos(),
+				fmt.Sprintf("a %s is used as %s, which it no longer implements; use a pointer", c.op.obj.Name(), gotypes.TypeString(target, gotypes.RelativeTo(pkg.TypesPkg))))
+		}
+
+		for _, fileName := range slices.Sorted(maps.Keys(pkg.Files)) {
+			file := pkg.Files[fileName]
+			if file.AST == nil {
+				continue
+			}
+			ast.PreorderStack(file.AST, nil, func(n ast.Node, stack []ast.Node) bool {
+				switch n := n.(type) {
+				case *ast.SelectorExpr:
+					c.fixSelector(file, info, n)
+				case *ast.CallExpr:
+					sig, ok := info.TypeOf(n.Fun).(*gotypes.Signature)
+					if !ok || info.Types[n.Fun].IsType() {
+						break
+					}
+					params := sig.Params()
+					for i, arg := range n.Args {
+						switch {
+						case sig.Variadic() && i >= params.Len()-1 && !n.Ellipsis.IsValid():
+							usedAs(file, arg, params.At(params.Len()-1).Type().(*gotypes.Slice).Elem())
+						case i < params.Len():
+							usedAs(file, arg, params.At(i).Type())
+						}
+					}
+				case *ast.AssignStmt:
+					if n.Tok == token.ASSIGN && len(n.Lhs) == len(n.Rhs) {
+						for i, lhs := range n.Lhs {
+							if target := info.TypeOf(lhs); target != nil {
+								usedAs(file, n.Rhs[i], target)
+							}
+						}
+					}
+				case *ast.ValueSpec:
+					if n.Type != nil {
+						for _, value := range n.Values {
+							usedAs(file, value, info.TypeOf(n.Type))
+						}
+					}
+				case *ast.ReturnStmt:
+					var sig *gotypes.Signature
+					for _, node := range slices.Backward(stack) {
+						switch fn := node.(type) {
+						case *ast.FuncLit:
+							sig, _ = info.TypeOf(fn).(*gotypes.Signature)
+						case *ast.FuncDecl:
+							if obj := info.Defs[fn.Name]; obj != nil {
+								sig, _ = obj.Type().(*gotypes.Signature)
+							}
+						default:
+							continue
+						}
+						break
+					}
+					if sig != nil && sig.Results().Len() == len(n.Results) {
+						for i, result := range n.Results {
+							usedAs(file, result, sig.Results().At(i).Type())
+						}
+					}
+				}
+				return true
+			})
+		}
+	}
+}
+
+// fixSelector fixes a method value or expression of a method becoming a
+// pointer method, selected on a value.
+func (c *receiverConversion) fixSelector(file *types.File, info *gotypes.Info, sel *ast.SelectorExpr) {
+	selection := info.Selections[sel]
+	if selection == nil || !c.isConverted(selection.Obj()) || isPointer(selection.Recv()) {
+		return
+	}
+	method := c.op.obj.Name() + "." + sel.Sel.Name
+	switch selection.Kind() {
+	case gotypes.MethodExpr:
+		c.replace(file, sel.X.Pos(), sel.X.End(), "(*"+nodeText(c.ws, file, sel.X.Pos(), sel.X.End())+")",
+			fmt.Sprintf("Select %s through a pointer", method))
+	case gotypes.MethodVal:
+		if selection.Indirect() || receiverAddressable(info, sel.X) {
+			return
+		}
+		if lit, ok := ast.Unparen(sel.X).(*ast.CompositeLit); ok {
+			c.replace(file, sel.X.Pos(), sel.X.End(), "(&"+nodeText(c.ws, file, lit.Pos(), lit.End())+")",
+				fmt.Sprintf("Take the address of the %s %s is called on", c.op.obj.Name(), method))
+			return
+		}
+		c.issue(types.IssueCompilationError, types.Error, sel.Pos(),
+			fmt.Sprintf("%s is called on a %s that isn't addressable; assign it to a variable first", method, c.op.obj.Name()))
+	}
+}
+
+// checkLocks reports the sync types the type contains, which value
+// receivers copy.
+func (c *receiverConversion) checkLocks() {
+	if lock := containedLock(c.op.obj.Type(), nil); lock != "" {
+		c.issue(types.IssueSideEffect, types.Warning, c.op.obj.Pos(),
+			fmt.Sprintf("%s contains a %s, which value receivers copy", c.op.obj.Name(), lock))
+	}
+}
+
+// containedLock returns the name of a sync or sync/atomic type t contains
+// by value, or "".
+func containedLock(t gotypes.Type, seen []gotypes.Type) string {
+	if slices.ContainsFunc(seen, func(s gotypes.Type) bool { return gotypes.Identical(s, t) }) {
+		return ""
+	}
+	if named, ok := t.(*gotypes.Named); ok && named.Obj().Pkg() != nil {
+		switch named.Obj().Pkg().Path() {
+		case "sync", "sync/atomic":
+			return named.Obj().Pkg().Name() + "." + named.Obj().Name()
+		}
+	}
+	switch u := t.Underlying().(type) {
+	case *gotypes.Struct:
+		for i := range u.NumFields() {
+			if lock := containedLock(u.Field(i).Type(), append(seen, t)); lock != "" {
+				return lock
+			}
+		}
+	case *gotypes.Array:
+		return containedLock(u.Elem(), append(seen, t))
+	}
+	return ""
+}
+
+// hasPointerReceiver reports whether the method fd has a pointer receiver.
+func hasPointerReceiver(fd *ast.FuncDecl) bool {
+	_, ok := fd.Recv.List[0].Type.(*ast.StarExpr)
+	return ok
+}
@@ -0,0 +1,174 @@
+package refactor
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+var convertReceiversFiles = map[string]string{
+	"counter/counter.go": `package counter
+
+import (
+	"fmt"
+	"sync"
+)
+
+type Adder interface {
+	Add(d int)
+}
+
+type Counter struct {
+	n int
+}
+
+func (c Counter) Value() int { return c.n }
+
+func (c Counter) String() string { return fmt.Sprint(c.n) }
+
+func (c *Counter) Add(d int) { c.n += d }
+
+func (c *Counter) Reset() {
+	*c = Counter{}
+}
+
+func (c *Counter) Self() *Counter {
+	if c == nil {
+		return nil
+	}
+	return c
+}
+
+type Guarded struct {
+	mu sync.Mutex
+	n  int
+}
+
+func (g *Guarded) Inc() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.n++
+}
+
+func (g Guarded) N() int { return g.n }
+`,
+	"app/app.go": `package app
+
+import (
+	"fmt"
+
+	"example.com/p/counter"
+)
+
+func Print() {
+	fmt.Println(counter.Counter{}.Value())
+	var s fmt.Stringer = counter.Counter{}
+	value := counter.Counter.Value
+	fmt.Println(s, value, newCounter().String())
+}
+
+func newCounter() counter.Counter { return counter.Counter{} }
+`,
+}
+
+func TestConvertReceivers_ToPointer(t *testing.T) {
+	engine, ws, dir := loadTestModuleWith(t, convertReceiversFiles)
+
+	plan, err := engine.ConvertReceivers(ws, types.ConvertReceiversRequest{TypeName: "Counter"})
+	if err != nil {
+		t.Fatalf("ConvertReceivers: %v", err)
+	}
+	counter := planContent(t, plan, filepath.Join(dir, "counter", "counter.go"))
+	for _, want := range []string{"func (c *Counter) Value() int { return c.n }", "func (c *Counter) String() string"} {
+		if !strings.Contains(counter, want) {
+			t.Errorf("counter.go lacks %q:\n%s", want, counter)
+		}
+	}
+	app := planContent(t, plan, filepath.Join(dir, "app", "app.go"))
+	for _, want := range []string{
+		"fmt.Println((&counter.Counter{}).Value())",
+		"var s fmt.Stringer = &counter.Counter{}",
+		"value := (*counter.Counter).Value",
+	} {
+		if !strings.Contains(app, want) {
+			t.Errorf("app.go lacks %q:\n%s", want, app)
+		}
+	}
+
+	var stringer, addressable bool
+	for _, issue := range plan.Impact.PotentialIssues {
+		stringer = stringer || strings.Contains(issue.Description, "no longer implements fmt.Stringer")
+		addressable = addressable || issue.Severity == types.Error && strings.Contains(issue.Description, "isn't addressable")
+	}
+	if !stringer || !addressable {
+		t.Errorf("expected fmt.Stringer and the call on newCounter() to be reported, got %+v", plan.Impact.PotentialIssues)
+	}
+}
+
+func TestConvertReceivers_ToValue(t *testing.T) {
+	engine, ws, dir := loadTestModuleWith(t, convertReceiversFiles)
+	file := filepath.Join(dir, "counter", "counter.go")
+
+	plan, err := engine.ConvertReceivers(ws, types.ConvertReceiversRequest{TypeName: "Counter", Receiver: types.ValueReceiver})
+	if err != nil {
+		t.Fatalf("ConvertReceivers: %v", err)
+	}
+	counter := planContent(t, plan, file)
+	for _, want := range []string{
+		"func (c Counter) Add(d int) { c.n += d }",
+		"\tc = Counter{}\n",
+		"if &c == nil {",
+		"return &c\n",
+	} {
+		if !strings.Contains(counter, want) {
+			t.Errorf("counter.go lacks %q:\n%s", want, counter)
+		}
+	}
+	var writes, adder, nilCheck int
+	for _, issue := range plan.Impact.PotentialIssues {
+		switch {
+		case strings.Contains(issue.Description, "changes a copy"):
+			writes++
+		case strings.Contains(issue.Description, "now implements Adder"):
+			adder++
+		case strings.Contains(issue.Description, "compares its receiver with nil"):
+			nilCheck++
+		}
+	}
+	if writes != 2 || adder != 1 || nilCheck != 1 {
+		t.Errorf("expected Add and Reset, Adder and the nil check to be reported, got %+v", plan.Impact.PotentialIssues)
+	}
+
+	plan, err = engine.ConvertReceivers(ws, types.ConvertReceiversRequest{TypeName: "Guarded", Receiver: types.ValueReceiver})
+	if err != nil {
+		t.Fatalf("ConvertReceivers: %v", err)
+	}
+	var lock bool
+	for _, issue := range plan.Impact.PotentialIssues {
+		lock = lock || strings.Contains(issue.Description, "contains a sync.Mutex")
+	}
+	if !lock {
+		t.Errorf("expected the copied mutex to be reported, got %+v", plan.Impact.PotentialIssues)
+	}
+}
+
+func TestConvertReceivers_Errors(t *testing.T) {
+	engine, ws, _ := loadTestModuleWith(t, convertReceiversFiles)
+	for _, tt := range []struct {
+		name string
+		req  types.ConvertReceiversRequest
+		want string
+	}{
+		{"unknown receiver", types.ConvertReceiversRequest{TypeName: "Counter", Receiver: "reference"}, "unknown receiver"},
+		{"no methods", types.ConvertReceiversRequest{TypeName: "Adder"}, "declares no methods"},
+		{"unknown type", types.ConvertReceiversRequest{TypeName: "Gauge"}, "not found"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := engine.ConvertReceivers(ws, tt.req); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
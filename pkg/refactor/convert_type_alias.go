@@ -0,0 +1,306 @@
+package refactor
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	gotypes "go/types"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/mamaar/gorefactor/pkg/analysis"
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// ConvertTypeAliasOperation turns a type alias (type A = B) into a defined
+// type (type A B), or a defined type into an alias. Only the declaration
+// changes; uses whose meaning changes with it are reported as warnings in
+// the plan's impact. A defined type that declares methods, has type
+// parameters or refers to itself can't become an alias.
+type ConvertTypeAliasOperation struct {
+	Request types.ConvertTypeAliasRequest
+	Parser  *analysis.GoParser // Type-checks packages on demand; may be nil if they already are
+
+	// Resolved by Validate
+	pkg      *types.Package
+	file     *types.File
+	typeSpec *ast.TypeSpec
+	methods  []*ast.FuncDecl // Declared with the type as receiver
+}
+
+func (op *ConvertTypeAliasOperation) Type() types.OperationType {
+	return types.ConvertTypeAliasOperation
+}
+
+func (op *ConvertTypeAliasOperation) Description() string {
+	if op.Request.ToAlias {
+		return fmt.Sprintf("Convert defined type %s to a type alias", op.Request.TypeName)
+	}
+	return fmt.Sprintf("Convert type alias %s to a defined type", op.Request.TypeName)
+}
+
+func (op *ConvertTypeAliasOperation) Validate(ws *types.Workspace) error {
+	req := op.Request
+	if req.TypeName == "" {
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: "type name is required",
+		}
+	}
+	if err := op.findType(ws); err != nil {
+		return err
+	}
+
+	isAlias := op.typeSpec.Assign.IsValid()
+	switch {
+	case req.ToAlias && isAlias:
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("%s is already a type alias", req.TypeName),
+			File:    op.file.Path,
+		}
+	case !req.ToAlias && !isAlias:
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("%s is not a type alias; set to_alias to make it one", req.TypeName),
+			File:    op.file.Path,
+		}
+	case !req.ToAlias:
+		return nil
+	}
+
+	if op.typeSpec.TypeParams != nil {
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("%s has type parameters; generic aliases need Go 1.24, convert it by hand", req.TypeName),
+			File:    op.file.Path,
+		}
+	}
+	if len(op.methods) > 0 {
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("%s declares %d method(s), which an alias would add to the type it aliases; move them first", req.TypeName, len(op.methods)),
+			File:    op.file.Path,
+		}
+	}
+	selfRef := false
+	ast.Inspect(op.typeSpec.Type, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && id.Name == req.TypeName {
+			selfRef = true
+		}
+		return !selfRef
+	})
+	if selfRef {
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("%s refers to itself, which an alias can't", req.TypeName),
+			File:    op.file.Path,
+		}
+	}
+	return nil
+}
+
+// findType locates the type declaration and the methods declared on it,
+// restricted to Request.Package when set.
+func (op *ConvertTypeAliasOperation) findType(ws *types.Workspace) error {
+	req := op.Request
+	found := 0
+	for _, pkgPath := range slices.Sorted(maps.Keys(ws.Packages)) {
+		pkg := ws.Packages[pkgPath]
+		if req.Package != "" && pkg.Path != req.Package && pkg.ImportPath != req.Package {
+			continue
+		}
+		var methods []*ast.FuncDecl
+		declared := false
+		for _, fileName := range slices.Sorted(maps.Keys(pkg.Files)) {
+			file := pkg.Files[fileName]
+			if file.AST == nil {
+				continue
+			}
+			for _, decl := range file.AST.Decls {
+				switch decl := decl.(type) {
+				case *ast.FuncDecl:
+					if receiverTypeName(decl) == req.TypeName {
+						methods = append(methods, decl)
+					}
+				case *ast.GenDecl:
+					if decl.Tok != token.TYPE {
+						continue
+					}
+					for _, spec := range decl.Specs {
+						if ts := spec.(*ast.TypeSpec); ts.Name.Name == req.TypeName {
+							found++
+							declared = true
+							op.pkg, op.file, op.typeSpec = pkg, file, ts
+						}
+					}
+				}
+			}
+		}
+		if declared {
+			op.methods = methods
+		}
+	}
+
+	switch {
+	case found == 0:
+		return &types.RefactorError{
+			Type:    types.SymbolNotFound,
+			Message: fmt.Sprintf("type %s not found", req.TypeName),
+		}
+	case found > 1:
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("type %s is declared in %d packages; specify the package", req.TypeName, found),
+		}
+	}
+	return nil
+}
+
+func (op *ConvertTypeAliasOperation) Execute(ws *types.Workspace) (*types.RefactoringPlan, error) {
+	ts := op.typeSpec
+	change := types.Change{
+		File:        op.file.Path,
+		Start:       ws.FileSet.Position(ts.Type.Pos()).Offset,
+		End:         ws.FileSet.Position(ts.Type.Pos()).Offset,
+		NewText:     "= ",
+		Description: fmt.Sprintf("Replace defined type %s with an alias", op.Request.TypeName),
+	}
+	if !op.Request.ToAlias {
+		change.Start = ws.FileSet.Position(ts.Assign).Offset
+		change.OldText = nodeText(ws, op.file, ts.Assign, ts.Type.Pos())
+		change.NewText = ""
+		change.Description = fmt.Sprintf("Replace type alias %s with a defined type", op.Request.TypeName)
+	}
+
+	var issues []types.Issue
+	if op.Parser != nil {
+		op.Parser.EnsureTypeChecked(ws, op.pkg)
+	}
+	if info := op.pkg.TypesInfo; info != nil && op.pkg.TypesPkg != nil {
+		aliased := info.Types[ts.Type].Type
+		if op.Request.ToAlias {
+			issues = op.duplicateCases(ws, aliased)
+		} else if aliased != nil {
+			issues = op.definedTypeIssues(ws, aliased)
+		}
+	} else {
+		issues = append(issues, op.issue(ws, op.file, ts.Pos(),
+			fmt.Sprintf("package %s could not be type-checked; uses the conversion breaks were not looked for", op.pkg.ImportPath)))
+	}
+
+	return &types.RefactoringPlan{
+		Changes:       []types.Change{change},
+		AffectedFiles: []string{op.file.Path},
+		Impact: &types.ImpactAnalysis{
+			AffectedPackages: []string{op.pkg.Path},
+			AffectedFiles:    []string{op.file.Path},
+			PotentialIssues:  issues,
+		},
+		Reversible: true,
+	}, nil
+}
+
+// definedTypeIssues reports what an alias loses by becoming a defined type:
+// assignability from the aliased type, its methods, and methods declared
+// through the alias, which move from the aliased type to the new one.
+func (op *ConvertTypeAliasOperation) definedTypeIssues(ws *types.Workspace, aliased gotypes.Type) []types.Issue {
+	var issues []types.Issue
+	name := gotypes.TypeString(aliased, gotypes.RelativeTo(op.pkg.TypesPkg))
+	switch t := aliased.(type) {
+	case *gotypes.Named:
+		issues = append(issues, op.issue(ws, op.file, op.typeSpec.Pos(),
+			fmt.Sprintf("values of %s are no longer assignable to %s without a conversion", name, op.Request.TypeName)))
+		if t.NumMethods() > len(op.methods) {
+			issues = append(issues, op.issue(ws, op.file, op.typeSpec.Pos(),
+				fmt.Sprintf("methods of %s are not methods of %s", name, op.Request.TypeName)))
+		}
+	case *gotypes.Basic:
+		issues = append(issues, op.issue(ws, op.file, op.typeSpec.Pos(),
+			fmt.Sprintf("%s values other than constants are no longer assignable to %s without a conversion", name, op.Request.TypeName)))
+	}
+	if len(op.methods) > 0 {
+		var names []string
+		for _, m := range op.methods {
+			names = append(names, m.Name.Name)
+		}
+		issues = append(issues, op.issue(ws, op.file, op.typeSpec.Pos(),
+			fmt.Sprintf("methods %s become methods of %s instead of %s", strings.Join(names, ", "), op.Request.TypeName, name)))
+	}
+	return issues
+}
+
+// duplicateCases reports type switches with cases for both the type and
+// the type it becomes an alias of, which turn into duplicate cases.
+func (op *ConvertTypeAliasOperation) duplicateCases(ws *types.Workspace, aliased gotypes.Type) []types.Issue {
+	obj, _ := op.pkg.TypesPkg.Scope().Lookup(op.Request.TypeName).(*gotypes.TypeName)
+	if obj == nil || aliased == nil {
+		return nil
+	}
+	var issues []types.Issue
+	for _, pkgPath := range slices.Sorted(maps.Keys(ws.Packages)) {
+		pkg := ws.Packages[pkgPath]
+		for _, fileName := range slices.Sorted(maps.Keys(pkg.Files)) {
+			file := pkg.Files[fileName]
+			if file.AST == nil || !mentionsName(file.AST, op.Request.TypeName) {
+				continue
+			}
+			if op.Parser != nil {
+				op.Parser.EnsureTypeChecked(ws, pkg)
+			}
+			if pkg.TypesInfo == nil {
+				continue
+			}
+			ast.Inspect(file.AST, func(n ast.Node) bool {
+				sw, ok := n.(*ast.TypeSwitchStmt)
+				if !ok {
+					return true
+				}
+				var own, other *ast.CaseClause
+				for _, stmt := range sw.Body.List {
+					clause := stmt.(*ast.CaseClause)
+					for _, expr := range clause.List {
+						tv := pkg.TypesInfo.Types[expr]
+						switch {
+						case !tv.IsType():
+						case gotypes.Identical(tv.Type, obj.Type()):
+							own = clause
+						case gotypes.Identical(tv.Type, aliased):
+							other = clause
+						}
+					}
+				}
+				if own != nil && other != nil {
+					issues = append(issues, op.issue(ws, file, own.Pos(),
+						fmt.Sprintf("type switch has cases for both %s and the type it aliases, which become duplicates", op.Request.TypeName)))
+				}
+				return true
+			})
+		}
+	}
+	return issues
+}
+
+func (op *ConvertTypeAliasOperation) issue(ws *types.Workspace, file *types.File, pos token.Pos, msg string) types.Issue {
+	return types.Issue{
+		Type:        types.IssueTypeMismatch,
+		Description: fmt.Sprintf("%s: %s", op.Request.TypeName, msg),
+		File:        file.Path,
+		Line:        ws.FileSet.Position(pos).Line,
+		Severity:    types.Warning,
+	}
+}
+
+// mentionsName reports whether file has an identifier with one of names,
+// used to skip files that can't refer to a declaration.
+func mentionsName(file *ast.File, names ...string) bool {
+	found := false
+	ast.Inspect(file, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && slices.Contains(names, id.Name) {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
@@ -0,0 +1,79 @@
+package refactor
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+var convertTypeAliasFiles = map[string]string{
+	"a/a.go": `package a
+
+type Celsius = float64
+
+type Names []string
+
+type Meters float64
+
+func (m Meters) String() string { return "m" }
+
+func Kind(v any) string {
+	switch v.(type) {
+	case Names:
+		return "names"
+	case []string:
+		return "strings"
+	}
+	return ""
+}
+`,
+}
+
+func TestConvertTypeAlias(t *testing.T) {
+	engine, ws, dir := loadTestModuleWith(t, convertTypeAliasFiles)
+	file := filepath.Join(dir, "a", "a.go")
+
+	plan, err := engine.ConvertTypeAlias(ws, types.ConvertTypeAliasRequest{TypeName: "Celsius"})
+	if err != nil {
+		t.Fatalf("ConvertTypeAlias: %v", err)
+	}
+	if got := planContent(t, plan, file); !strings.Contains(got, "type Celsius float64\n") {
+		t.Errorf("expected a defined type, got:\n%s", got)
+	}
+	if n := len(plan.Impact.PotentialIssues); n != 1 {
+		t.Errorf("expected the lost assignability to be flagged, got %+v", plan.Impact.PotentialIssues)
+	}
+
+	plan, err = engine.ConvertTypeAlias(ws, types.ConvertTypeAliasRequest{TypeName: "Names", ToAlias: true})
+	if err != nil {
+		t.Fatalf("ConvertTypeAlias: %v", err)
+	}
+	if got := planContent(t, plan, file); !strings.Contains(got, "type Names = []string\n") {
+		t.Errorf("expected an alias, got:\n%s", got)
+	}
+	if issues := plan.Impact.PotentialIssues; len(issues) != 1 || !strings.Contains(issues[0].Description, "duplicates") {
+		t.Errorf("expected the duplicate type switch case to be flagged, got %+v", issues)
+	}
+}
+
+func TestConvertTypeAlias_Errors(t *testing.T) {
+	engine, ws, _ := loadTestModuleWith(t, convertTypeAliasFiles)
+	for _, tt := range []struct {
+		name string
+		req  types.ConvertTypeAliasRequest
+		want string
+	}{
+		{"already an alias", types.ConvertTypeAliasRequest{TypeName: "Celsius", ToAlias: true}, "already a type alias"},
+		{"not an alias", types.ConvertTypeAliasRequest{TypeName: "Names"}, "not a type alias"},
+		{"methods", types.ConvertTypeAliasRequest{TypeName: "Meters", ToAlias: true}, "declares 1 method(s)"},
+		{"unknown", types.ConvertTypeAliasRequest{TypeName: "Fahrenheit"}, "not found"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := engine.ConvertTypeAlias(ws, tt.req); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
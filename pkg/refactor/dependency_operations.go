@@ -1,10 +1,13 @@
 package refactor
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log/slog"
+	"maps"
+	"path"
 	"path/filepath"
 	"slices"
 	"sort"
@@ -229,11 +232,11 @@ func (op *MoveByDependenciesOperation) getTargetImportPath(ws *types.Workspace)
 func buildFilePackageIndex(ws *types.Workspace) map[string]*types.Package {
 	index := make(map[string]*types.Package)
 	for _, pkg := range ws.Packages {
-		for path := range pkg.Files {
-			index[path] = pkg
+		for _, file := range pkg.Files {
+			index[file.Path] = pkg
 		}
-		for path := range pkg.TestFiles {
-			index[path] = pkg
+		for _, file := range pkg.TestFiles {
+			index[file.Path] = pkg
 		}
 	}
 	return index
@@ -311,10 +314,21 @@ func (op *OrganizeByLayersOperation) Validate(ws *types.Workspace) error {
 	if op.Request.Workspace == "" {
 		return fmt.Errorf("workspace path cannot be empty")
 	}
+	for _, dir := range slices.Sorted(maps.Keys(op.Request.Layers)) {
+		if _, ok := layerTitles[op.Request.Layers[dir]]; !ok {
+			return fmt.Errorf("layer of %s must be domain, infrastructure, or application, got %q", dir, op.Request.Layers[dir])
+		}
+	}
 	return nil
 }
 
 func (op *OrganizeByLayersOperation) Execute(ws *types.Workspace) (*types.RefactoringPlan, error) {
+	return op.ExecuteContext(context.Background(), ws)
+}
+
+// ExecuteContext generates the plan, returning ctx.Err() if ctx is canceled
+// before every package has been visited.
+func (op *OrganizeByLayersOperation) ExecuteContext(ctx context.Context, ws *types.Workspace) (*types.RefactoringPlan, error) {
 	plan := &types.RefactoringPlan{
 		Operations:    []types.Operation{op},
 		Changes:       make([]types.Change, 0),
@@ -325,6 +339,9 @@ func (op *OrganizeByLayersOperation) Execute(ws *types.Workspace) (*types.Refact
 	// Analyze packages and organize by layers
 	if op.Request.ReorderImports {
 		for _, pkg := range ws.Packages {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
 			for _, file := range pkg.Files {
 				changes := op.reorderImportsByLayers(ws, file)
 				plan.Changes = append(plan.Changes, changes...)
@@ -491,33 +508,65 @@ func (op *OrganizeByLayersOperation) generateLayerReport(ws *types.Workspace) st
 	if op.Request.ApplicationLayer != "" {
 		report.WriteString(fmt.Sprintf("**Application Layer**: %s\n", op.Request.ApplicationLayer))
 	}
+	if len(op.Request.Layers) > 0 {
+		report.WriteString(fmt.Sprintf("**Assigned Packages**: %d\n", len(op.Request.Layers)))
+	}
 
 	report.WriteString("\n## Package Classification\n\n")
 
-	for pkgPath := range ws.Packages {
-		layer := op.classifyPackage(pkgPath)
-		report.WriteString(fmt.Sprintf("- `%s` → %s Layer\n", pkgPath, layer))
+	for _, pkgPath := range slices.Sorted(maps.Keys(ws.Packages)) {
+		rel, err := filepath.Rel(ws.RootPath, pkgPath)
+		if err != nil {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		report.WriteString(fmt.Sprintf("- `%s` → %s Layer\n", rel, op.classifyPackage(rel)))
 	}
 
 	return report.String()
 }
 
-func (op *OrganizeByLayersOperation) classifyPackage(packagePath string) string {
-	if strings.HasPrefix(packagePath, op.Request.DomainLayer) {
+// layerTitles are the report names of the layers a package can be
+// assigned to.
+var layerTitles = map[string]string{
+	analysis.LayerDomain:         "Domain",
+	analysis.LayerInfrastructure: "Infrastructure",
+	analysis.LayerApplication:    "Application",
+}
+
+// classifyPackage returns the layer of the package in rel, a directory
+// relative to the workspace root: its entry in Layers, or else the first
+// layer directory holding it.
+func (op *OrganizeByLayersOperation) classifyPackage(rel string) string {
+	if layer, ok := op.Request.Layers[rel]; ok {
+		return layerTitles[layer]
+	}
+	if inLayerDir(rel, op.Request.DomainLayer) {
 		return "Domain"
 	}
-	if strings.HasPrefix(packagePath, op.Request.InfrastructureLayer) {
+	if inLayerDir(rel, op.Request.InfrastructureLayer) {
 		return "Infrastructure"
 	}
-	if strings.HasPrefix(packagePath, op.Request.ApplicationLayer) {
+	if inLayerDir(rel, op.Request.ApplicationLayer) {
 		return "Application"
 	}
 	return "Unclassified"
 }
 
+// inLayerDir reports whether the directory rel is the layer directory dir
+// or below it. Both are relative to the workspace root; an empty dir holds
+// nothing.
+func inLayerDir(rel, dir string) bool {
+	dir = strings.Trim(path.Clean(filepath.ToSlash(dir)), "/")
+	return dir != "" && dir != "." && (rel == dir || strings.HasPrefix(rel, dir+"/"))
+}
+
 // FixCyclesOperation implements detecting and fixing circular dependencies
 type FixCyclesOperation struct {
 	Request types.FixCyclesRequest
+	// CallGraph, when set, lets the report show the calls behind each
+	// import of a cycle and which callees could move to break it.
+	CallGraph *analysis.CallGraph
 }
 
 func (op *FixCyclesOperation) Type() types.OperationType {
@@ -616,6 +665,9 @@ func (op *FixCyclesOperation) generateCycleReport(cycles [][]string) string {
 				report.WriteString(" → " + cycle[0]) // Complete the cycle
 			}
 			report.WriteString("\n```\n\n")
+			if op.CallGraph != nil {
+				report.WriteString(op.cycleCalls(cycle))
+			}
 		}
 	} else {
 		report.WriteString("✅ No circular dependencies detected!\n")
@@ -624,6 +676,67 @@ func (op *FixCyclesOperation) generateCycleReport(cycles [][]string) string {
 	return report.String()
 }
 
+// cycleCalls lists the calls behind each import of cycle. A function only
+// the importing package calls is a candidate to move there: the calls no
+// longer cross the import.
+func (op *FixCyclesOperation) cycleCalls(cycle []string) string {
+	nodes := make(map[string]*analysis.CallNode)
+	for _, n := range op.CallGraph.Nodes {
+		nodes[n.ID] = n
+	}
+	callers := callerPackages(op.CallGraph)
+
+	var report strings.Builder
+	report.WriteString("Calls behind each import:\n\n")
+	for i, from := range cycle {
+		to := cycle[(i+1)%len(cycle)]
+		var calls []*analysis.CallEdge
+		for _, e := range op.CallGraph.Edges {
+			if !e.Dynamic && nodes[e.Caller].Package == from && nodes[e.Callee].Package == to {
+				calls = append(calls, e)
+			}
+		}
+		if len(calls) == 0 {
+			report.WriteString(fmt.Sprintf("- `%s` → `%s`: no calls; the import is used for types, variables or constants\n", from, to))
+			continue
+		}
+		report.WriteString(fmt.Sprintf("- `%s` → `%s`: %d calls\n", from, to, len(calls)))
+		var movable []string
+		for _, e := range calls {
+			caller, callee := nodes[e.Caller], nodes[e.Callee]
+			report.WriteString(fmt.Sprintf("  - `%s` calls `%s` (%d×)\n", caller.Name, callee.Name, e.Count))
+			if pkgs := callers[callee.ID]; len(pkgs) == 1 && !callee.Interface && !strings.Contains(callee.Name, ".") && !containsString(movable, callee.Name) {
+				movable = append(movable, callee.Name)
+			}
+		}
+		for _, name := range movable {
+			report.WriteString(fmt.Sprintf("  - Suggestion: move `%s` to `%s`, the only package calling it\n", name, from))
+		}
+	}
+	report.WriteString("\n")
+	return report.String()
+}
+
+// callerPackages returns the packages calling each node of g directly,
+// by node ID.
+func callerPackages(g *analysis.CallGraph) map[string]map[string]bool {
+	packages := make(map[string]string)
+	for _, n := range g.Nodes {
+		packages[n.ID] = n.Package
+	}
+	callers := make(map[string]map[string]bool)
+	for _, e := range g.Edges {
+		if e.Dynamic {
+			continue
+		}
+		if callers[e.Callee] == nil {
+			callers[e.Callee] = make(map[string]bool)
+		}
+		callers[e.Callee][packages[e.Caller]] = true
+	}
+	return callers
+}
+
 func (op *FixCyclesOperation) generateCycleFixes(cycle []string) []types.Change {
 	// Cycle breaking requires moving code, extracting interfaces, or dependency
 	// injection — transformations too destructive to automate safely.
@@ -635,6 +748,9 @@ func (op *FixCyclesOperation) generateCycleFixes(cycle []string) []types.Change
 // AnalyzeDependenciesOperation implements analyzing dependency flow
 type AnalyzeDependenciesOperation struct {
 	Request types.AnalyzeDependenciesRequest
+	// CallGraph, when set, adds moves of functions called from a single
+	// other package to the suggested moves.
+	CallGraph *analysis.CallGraph
 }
 
 func (op *AnalyzeDependenciesOperation) Type() types.OperationType {
@@ -645,10 +761,20 @@ func (op *AnalyzeDependenciesOperation) Description() string {
 	return fmt.Sprintf("Analyze dependencies in workspace %s", op.Request.Workspace)
 }
 
+// Diagram formats of AnalyzeDependenciesRequest.Format, with the file
+// extension of their default output file.
+var diagramExtensions = map[string]string{
+	"dot":     ".dot",
+	"mermaid": ".mmd",
+}
+
 func (op *AnalyzeDependenciesOperation) Validate(ws *types.Workspace) error {
 	if op.Request.Workspace == "" {
 		return fmt.Errorf("workspace path cannot be empty")
 	}
+	if _, ok := diagramExtensions[op.Request.Format]; op.Request.Format != "" && !ok {
+		return fmt.Errorf("unknown format %q: want dot or mermaid", op.Request.Format)
+	}
 	return nil
 }
 
@@ -660,6 +786,29 @@ func (op *AnalyzeDependenciesOperation) Execute(ws *types.Workspace) (*types.Ref
 		Reversible:    true,
 	}
 
+	if op.Request.Format != "" {
+		var diagram strings.Builder
+		d := analysis.BuildDependencyDiagram(ws, op.packageLayer(ws))
+		write := d.WriteDOT
+		if op.Request.Format == "mermaid" {
+			write = d.WriteMermaid
+		}
+		if err := write(&diagram); err != nil {
+			return nil, err
+		}
+		file := op.Request.OutputFile
+		if file == "" {
+			file = filepath.Join(op.Request.Workspace, "dependencies"+diagramExtensions[op.Request.Format])
+		}
+		plan.Changes = append(plan.Changes, types.Change{
+			File:        file,
+			NewText:     diagram.String(),
+			Description: "Generate dependency diagram",
+		})
+		plan.AffectedFiles = []string{file}
+		return plan, nil
+	}
+
 	// Perform comprehensive dependency analysis
 	analysis := op.performDependencyAnalysis(ws)
 
@@ -764,6 +913,37 @@ func (op *AnalyzeDependenciesOperation) performDependencyAnalysis(ws *types.Work
 	return analysis
 }
 
+// packageLayer returns the layer of a package: the layer whose directory
+// holds it, or else the one its import path suggests by packageTier.
+func (op *AnalyzeDependenciesOperation) packageLayer(ws *types.Workspace) func(*types.Package) string {
+	dirs := []struct{ dir, layer string }{
+		{op.Request.DomainLayer, "domain"},
+		{op.Request.ApplicationLayer, "application"},
+		{op.Request.InfrastructureLayer, "infrastructure"},
+	}
+	return func(pkg *types.Package) string {
+		rel, err := filepath.Rel(ws.RootPath, pkg.Path)
+		if err != nil {
+			return ""
+		}
+		rel = filepath.ToSlash(rel)
+		for _, d := range dirs {
+			if inLayerDir(rel, d.dir) {
+				return d.layer
+			}
+		}
+		switch packageTier(pkg.ImportPath) {
+		case 0:
+			return "domain"
+		case 1:
+			return "application"
+		case 2:
+			return "infrastructure"
+		}
+		return ""
+	}
+}
+
 // packageTier returns a numeric tier for an import path based on naming conventions:
 //
 //	-1 = unclassified, 0 = inner (domain/core/model), 1 = middle (service/usecase),
@@ -838,20 +1018,34 @@ func (op *AnalyzeDependenciesOperation) generateSuggestedMoves(ws *types.Workspa
 	resolver := analysis.NewSymbolResolver(ws, logger)
 	idx := resolver.BuildReferenceIndex()
 
+	var callers map[string]map[string]bool
+	if op.CallGraph != nil {
+		callers = callerPackages(op.CallGraph)
+	}
+
 	var moves []SuggestedMove
 	for _, pkg := range ws.Packages {
 		if pkg.Symbols == nil {
 			continue
 		}
+		from := pkg.ImportPath
+		if from == "" {
+			from = pkg.Path
+		}
 		for _, sym := range getAllExportedSymbols(pkg) {
 			refs, err := resolver.FindReferencesIndexed(sym, idx)
 			if err != nil || len(refs) == 0 {
 				continue
 			}
 			externalSet := make(map[string]bool)
+			internal := false
 			for _, ref := range refs {
 				refPkg := fileToPackage[ref.File]
-				if refPkg == nil || refPkg == pkg {
+				if refPkg == nil {
+					continue
+				}
+				if refPkg == pkg {
+					internal = true
 					continue
 				}
 				pkgID := refPkg.ImportPath
@@ -860,17 +1054,26 @@ func (op *AnalyzeDependenciesOperation) generateSuggestedMoves(ws *types.Workspa
 				}
 				externalSet[pkgID] = true
 			}
-			if len(externalSet) >= 2 {
-				from := pkg.ImportPath
-				if from == "" {
-					from = pkg.Path
-				}
+			switch {
+			case len(externalSet) >= 2:
 				moves = append(moves, SuggestedMove{
 					Symbol:      sym.Name,
 					FromPackage: from,
 					ToPackage:   "pkg/shared",
 					Reason:      fmt.Sprintf("referenced by %d packages", len(externalSet)),
 				})
+			case len(externalSet) == 1 && !internal && sym.Kind == types.FunctionSymbol:
+				// Used by one other package only: if all those uses are
+				// calls, the function belongs with its callers.
+				to := slices.Collect(maps.Keys(externalSet))[0]
+				if pkgs := callers[from+"."+sym.Name]; len(pkgs) == 1 && pkgs[to] {
+					moves = append(moves, SuggestedMove{
+						Symbol:      sym.Name,
+						FromPackage: from,
+						ToPackage:   to,
+						Reason:      "only called from " + to,
+					})
+				}
 			}
 		}
 	}
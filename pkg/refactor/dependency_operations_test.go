@@ -0,0 +1,149 @@
+package refactor
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+func loadDependencyModule(t *testing.T, files map[string]string) (RefactorEngine, *types.Workspace) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	engine := CreateEngine(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ws, err := engine.LoadWorkspace(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return engine, ws
+}
+
+func TestFixCycles_CallGraph(t *testing.T) {
+	engine, ws := loadDependencyModule(t, map[string]string{
+		"go.mod": "module example.com/p\n\ngo 1.21\n",
+		"a/a.go": "package a\n\nimport \"example.com/p/b\"\n\nfunc A() { b.Helper() }\n\nfunc Base() {}\n",
+		"b/b.go": "package b\n\nimport \"example.com/p/a\"\n\nfunc B() { a.Base() }\n\nfunc Helper() { B() }\n",
+	})
+	plan, err := engine.FixCycles(ws, types.FixCyclesRequest{Workspace: ws.RootPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := plan.Changes[0].NewText
+	for _, want := range []string{
+		"Found 1 circular dependencies",
+		"  - `A` calls `Helper` (1×)",
+		"  - Suggestion: move `Helper` to `example.com/p/a`, the only package calling it",
+		"  - Suggestion: move `Base` to `example.com/p/b`, the only package calling it",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report lacks %q:\n%s", want, report)
+		}
+	}
+}
+
+func TestAnalyzeDependencies_CallGraphMoves(t *testing.T) {
+	engine, ws := loadDependencyModule(t, map[string]string{
+		"go.mod":     "module example.com/p\n\ngo 1.21\n",
+		"lib/lib.go": "package lib\n\nfunc Format() string { return \"\" }\n\nfunc Shared() {}\n\nvar Handler = Shared\n",
+		"app/app.go": "package app\n\nimport \"example.com/p/lib\"\n\nfunc Run() string { return lib.Format() }\n",
+	})
+	plan, err := engine.AnalyzeDependencies(ws, types.AnalyzeDependenciesRequest{Workspace: ws.RootPath, SuggestMoves: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := plan.Changes[0].NewText
+	if !strings.Contains(report, "- Move `Format` from `example.com/p/lib` to `example.com/p/app`: only called from example.com/p/app") {
+		t.Errorf("report lacks the move of Format:\n%s", report)
+	}
+	if strings.Contains(report, "`Shared`") {
+		t.Errorf("Shared is used inside lib and shouldn't move:\n%s", report)
+	}
+}
+
+func TestAnalyzeDependencies_Diagram(t *testing.T) {
+	engine, ws := loadDependencyModule(t, map[string]string{
+		"go.mod":                "module example.com/p\n\ngo 1.21\n",
+		"modules/orders/o.go":   "package orders\n\nimport \"example.com/p/pkg/db\"\n\nvar _ = db.Open\n",
+		"pkg/db/db.go":          "package db\n\nfunc Open() {}\n",
+		"internal/service/s.go": "package service\n",
+	})
+	plan, err := engine.AnalyzeDependencies(ws, types.AnalyzeDependenciesRequest{
+		Workspace:   ws.RootPath,
+		Format:      "mermaid",
+		DomainLayer: "modules/",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.AffectedFiles[0] != filepath.Join(ws.RootPath, "dependencies.mmd") {
+		t.Errorf("output file = %s", plan.AffectedFiles[0])
+	}
+	diagram := plan.Changes[0].NewText
+	for _, want := range []string{
+		`["modules/orders<br/><i>domain</i>"]`,
+		`["internal/service<br/><i>application</i>"]`,
+		`["pkg/db"]`,
+	} {
+		if !strings.Contains(diagram, want) {
+			t.Errorf("diagram lacks %s:\n%s", want, diagram)
+		}
+	}
+
+	if _, err := engine.AnalyzeDependencies(ws, types.AnalyzeDependenciesRequest{Workspace: ws.RootPath, Format: "svg"}); err == nil {
+		t.Error("expected an error for format svg")
+	}
+}
+
+func TestOrganizeByLayers_InferredLayers(t *testing.T) {
+	engine, ws := loadDependencyModule(t, map[string]string{
+		"go.mod":        "module example.com/p\n\ngo 1.21\n",
+		"main.go":       "package main\n\nimport \"example.com/p/store\"\n\nfunc main() { store.Open() }\n",
+		"store/db.go":   "package store\n\nimport (\n\t\"database/sql\"\n\n\t\"example.com/p/model\"\n)\n\nvar _ *sql.DB\n\nfunc Open() model.ID { return 0 }\n",
+		"model/id.go":   "package model\n\ntype ID int\n",
+		"tools/tool.go": "package tools\n",
+	})
+	assignments, err := engine.InferLayers(ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	layers := make(map[string]string)
+	for _, a := range assignments {
+		layers[a.Package] = a.Layer
+	}
+	// Accept the proposal after moving tools out of the domain.
+	layers["tools"] = "infrastructure"
+
+	plan, err := engine.OrganizeByLayers(ws, types.OrganizeByLayersRequest{Workspace: ws.RootPath, Layers: layers})
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := plan.Changes[len(plan.Changes)-1].NewText
+	for _, want := range []string{
+		"- `.` → Application Layer\n",
+		"- `model` → Domain Layer\n",
+		"- `store` → Infrastructure Layer\n",
+		"- `tools` → Infrastructure Layer\n",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report lacks %q:\n%s", want, report)
+		}
+	}
+
+	layers["tools"] = "presentation"
+	if _, err := engine.OrganizeByLayers(ws, types.OrganizeByLayersRequest{Workspace: ws.RootPath, Layers: layers}); err == nil {
+		t.Error("expected an error for layer presentation")
+	}
+}
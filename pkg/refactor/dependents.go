@@ -0,0 +1,238 @@
+package refactor
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/mamaar/gorefactor/pkg/analysis"
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// dependentTarget is an exported symbol whose uses outside the workspace a
+// plan breaks.
+type dependentTarget struct {
+	pkg     *types.Package
+	name    string
+	method  bool   // Uses are matched by name alone
+	change  string // Description of the change, for the report
+	newName string // Set for renames, which patches can follow
+}
+
+// checkDependents scans the engine's dependent repositories, read-only, for
+// uses of target and reports them, with a patch per repository renaming
+// them when patches is set and target is renamed. A repository that can't
+// be loaded is reported with its error rather than failing the plan.
+func (e *DefaultEngine) checkDependents(ws *types.Workspace, target dependentTarget, patches bool) (types.CompatibilityReport, error) {
+	report := types.CompatibilityReport{
+		Symbol: target.pkg.ImportPath + "." + target.name,
+		Change: target.change,
+	}
+	if e.config == nil || len(e.config.Dependents) == 0 {
+		return report, &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: "no dependent repositories configured (set dependents in .gorefactor.yaml)",
+		}
+	}
+	if !token.IsExported(target.name) {
+		return report, nil // Nothing outside the package can use it
+	}
+
+	for _, root := range e.config.Dependents {
+		if !filepath.IsAbs(root) {
+			root = filepath.Join(ws.RootPath, root)
+		}
+		dep := types.DependentReport{Root: root}
+		dws, err := analysis.NewParser(e.logger).ParseWorkspace(root)
+		if err != nil {
+			dep.Error = err.Error()
+			report.Dependents = append(report.Dependents, dep)
+			continue
+		}
+
+		var patch strings.Builder
+		for _, dir := range slices.Sorted(maps.Keys(dws.Packages)) {
+			for _, file := range packageFiles(dws.Packages[dir]) {
+				usages, edits := dependentUsages(dws.FileSet, file, target)
+				dep.Usages = append(dep.Usages, usages...)
+				if !patches || target.newName == "" || len(edits) == 0 {
+					continue
+				}
+				src := string(file.OriginalContent)
+				fixed, err := applyInMemory(src, edits)
+				if err != nil {
+					return report, fmt.Errorf("patch %s: %w", file.Path, err)
+				}
+				rel, err := filepath.Rel(root, file.Path)
+				if err != nil {
+					rel = file.Path
+				}
+				patch.WriteString(unifiedPatch(filepath.ToSlash(rel), src, fixed))
+			}
+		}
+		dep.Patch = patch.String()
+		report.Dependents = append(report.Dependents, dep)
+	}
+	return report, nil
+}
+
+// dependentUsages returns the uses of target in file, a file of a dependent
+// repository, and the changes renaming them: selectors qualified by an
+// import of target's package, identifiers in files dot-importing it and,
+// for a method, selectors of that name in files importing it.
+func dependentUsages(fset *token.FileSet, file *types.File, target dependentTarget) ([]types.DependentUsage, []types.Change) {
+	if file.AST == nil {
+		return nil, nil
+	}
+	qualifiers := make(map[string]bool)
+	dot := false
+	for _, imp := range file.AST.Imports {
+		if strings.Trim(imp.Path.Value, `"`) != target.pkg.ImportPath {
+			continue
+		}
+		switch {
+		case imp.Name == nil:
+			qualifiers[target.pkg.Name] = true
+		case imp.Name.Name == ".":
+			dot = true
+		case imp.Name.Name != "_":
+			qualifiers[imp.Name.Name] = true
+		}
+	}
+	if len(qualifiers) == 0 && !dot {
+		return nil, nil
+	}
+
+	lines := strings.Split(string(file.OriginalContent), "\n")
+	var usages []types.DependentUsage
+	var edits []types.Change
+	add := func(id *ast.Ident, confidence types.Confidence) {
+		pos := fset.Position(id.Pos())
+		text := ""
+		if pos.Line <= len(lines) {
+			text = strings.TrimSpace(lines[pos.Line-1])
+		}
+		usages = append(usages, types.DependentUsage{
+			File:       file.Path,
+			Line:       pos.Line,
+			Column:     pos.Column,
+			Text:       text,
+			Confidence: confidence,
+		})
+		edits = append(edits, types.Change{
+			File:    file.Path,
+			Start:   pos.Offset,
+			End:     pos.Offset + len(id.Name),
+			OldText: id.Name,
+			NewText: target.newName,
+		})
+	}
+
+	selected := make(map[*ast.Ident]bool)
+	ast.Inspect(file.AST, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			selected[n.Sel] = true
+			if n.Sel.Name != target.name {
+				break
+			}
+			x, ok := n.X.(*ast.Ident)
+			switch {
+			case ok && qualifiers[x.Name] && !target.method:
+				add(n.Sel, types.ConfidenceLikely)
+			case target.method && !(ok && qualifiers[x.Name]):
+				add(n.Sel, types.ConfidenceHeuristic)
+			}
+		case *ast.Ident:
+			if dot && !target.method && !selected[n] && n.Name == target.name && n.Obj == nil {
+				add(n, types.ConfidenceLikely)
+			}
+		}
+		return true
+	})
+	return usages, edits
+}
+
+// unifiedPatch returns a unified diff of a file from before to after,
+// both with the same number of lines as renames keep, with three lines of
+// context around each changed line.
+func unifiedPatch(path, before, after string) string {
+	const contextLines = 3
+	oldLines := strings.Split(strings.TrimSuffix(before, "\n"), "\n")
+	newLines := strings.Split(strings.TrimSuffix(after, "\n"), "\n")
+	if len(oldLines) != len(newLines) {
+		return ""
+	}
+	var changed []int
+	for i := range oldLines {
+		if oldLines[i] != newLines[i] {
+			changed = append(changed, i)
+		}
+	}
+	if len(changed) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- a/%s\n+++ b/%s\n", path, path)
+	for i := 0; i < len(changed); {
+		// A hunk spans the changed lines whose contexts overlap
+		j := i
+		for j+1 < len(changed) && changed[j+1]-changed[j] <= 2*contextLines {
+			j++
+		}
+		start := max(changed[i]-contextLines, 0)
+		end := min(changed[j]+contextLines+1, len(oldLines))
+		fmt.Fprintf(&b, "@@ -%d,%d +%d,%d @@\n", start+1, end-start, start+1, end-start)
+		for k := start; k < end; k++ {
+			if oldLines[k] == newLines[k] {
+				fmt.Fprintf(&b, " %s\n", oldLines[k])
+			} else {
+				fmt.Fprintf(&b, "-%s\n+%s\n", oldLines[k], newLines[k])
+			}
+		}
+		i = j + 1
+	}
+	return b.String()
+}
+
+// dependentIssues returns a warning per use in report.
+func dependentIssues(report types.CompatibilityReport) []types.Issue {
+	var issues []types.Issue
+	for _, dep := range report.Dependents {
+		if dep.Error != "" {
+			issues = append(issues, types.Issue{
+				Type:        types.IssueDependentUsage,
+				Description: fmt.Sprintf("dependent %s not checked: %s", dep.Root, dep.Error),
+				Severity:    types.Warning,
+			})
+		}
+		for _, u := range dep.Usages {
+			issues = append(issues, types.Issue{
+				Type:        types.IssueDependentUsage,
+				Description: fmt.Sprintf("%s uses %s, which this change breaks", u.Text, report.Symbol),
+				File:        u.File,
+				Line:        u.Line,
+				Severity:    types.Warning,
+			})
+		}
+	}
+	return issues
+}
+
+// isMethodIn reports whether the function name that file of pkg declares
+// is a method.
+func isMethodIn(pkg *types.Package, file, name string) bool {
+	f := pkg.Files[filepath.Base(file)]
+	if f == nil || f.AST == nil {
+		return false
+	}
+	return slices.ContainsFunc(f.AST.Decls, func(decl ast.Decl) bool {
+		fn, ok := decl.(*ast.FuncDecl)
+		return ok && fn.Recv != nil && fn.Name.Name == name
+	})
+}
@@ -0,0 +1,92 @@
+package refactor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// writeDependent writes a module importing the test module's package a
+// next to the workspace at dir.
+func writeDependent(t *testing.T, dir string) string {
+	t.Helper()
+	dep := filepath.Join(filepath.Dir(dir), "dep")
+	files := map[string]string{
+		"go.mod":   "module example.com/dep\n\ngo 1.21\n",
+		"use.go":   "package dep\n\nimport \"example.com/p/a\"\n\nfunc Use() {\n\ta.A()\n}\n",
+		"alias.go": "package dep\n\nimport x \"example.com/p/a\"\n\nvar f = x.A\n",
+		"other.go": "package dep\n\nfunc A() {}\n\nfunc useLocal() { A() }\n",
+	}
+	for name, content := range files {
+		if err := os.MkdirAll(dep, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dep, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dep
+}
+
+func TestRenameSymbol_CheckDependents(t *testing.T) {
+	engine, ws, dir := loadTestModuleWith(t, nil)
+	dep := writeDependent(t, dir)
+	engine.Config().Dependents = []string{"../dep"}
+
+	plan, err := engine.RenameSymbol(ws, types.RenameSymbolRequest{
+		SymbolName:       "A",
+		NewName:          "Z",
+		Package:          filepath.Join(dir, "a"),
+		Scope:            types.PackageScope,
+		CheckDependents:  true,
+		DependentPatches: true,
+	})
+	if err != nil {
+		t.Fatalf("RenameSymbol: %v", err)
+	}
+	if len(plan.Impact.Compatibility) != 1 {
+		t.Fatalf("expected one compatibility report, got %d", len(plan.Impact.Compatibility))
+	}
+	report := plan.Impact.Compatibility[0]
+	if report.Symbol != "example.com/p/a.A" || len(report.Dependents) != 1 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	got := report.Dependents[0]
+	if got.Root != dep || len(got.Usages) != 2 {
+		t.Fatalf("expected the two uses in %s, got %+v", dep, got)
+	}
+	for _, want := range []string{
+		"--- a/alias.go\n+++ b/alias.go\n@@ -2,4 +2,4 @@\n \n import x \"example.com/p/a\"\n \n-var f = x.A\n+var f = x.Z\n",
+		"--- a/use.go\n+++ b/use.go\n@@ -3,5 +3,5 @@\n import \"example.com/p/a\"\n \n func Use() {\n-\ta.A()\n+\ta.Z()\n }\n",
+	} {
+		if !strings.Contains(got.Patch, want) {
+			t.Errorf("patch lacks\n%s\ngot:\n%s", want, got.Patch)
+		}
+	}
+	warnings := 0
+	for _, issue := range plan.Impact.PotentialIssues {
+		if issue.Type == types.IssueDependentUsage {
+			warnings++
+		}
+	}
+	if warnings != 2 {
+		t.Errorf("expected a warning per dependent use, got %d", warnings)
+	}
+}
+
+func TestRenameSymbol_CheckDependentsUnconfigured(t *testing.T) {
+	engine, ws, dir := loadTestModuleWith(t, nil)
+	_, err := engine.RenameSymbol(ws, types.RenameSymbolRequest{
+		SymbolName:      "A",
+		NewName:         "Z",
+		Package:         filepath.Join(dir, "a"),
+		Scope:           types.PackageScope,
+		CheckDependents: true,
+	})
+	if err == nil || !strings.Contains(err.Error(), "no dependent repositories") {
+		t.Errorf("expected an error about missing dependents, got %v", err)
+	}
+}
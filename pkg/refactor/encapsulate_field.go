@@ -0,0 +1,635 @@
+package refactor
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	gotypes "go/types"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mamaar/gorefactor/pkg/analysis"
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// EncapsulateFieldOperation hides a struct field behind generated getter and
+// setter methods. Direct accesses from other packages are rewritten to call
+// the accessors; accesses inside the declaring package keep using the field.
+// Accesses that can't be rewritten mechanically (composite literal keys,
+// taking the field's address, multi-value assignments, ...) are reported as
+// warnings in the plan's impact instead.
+type EncapsulateFieldOperation struct {
+	Request types.EncapsulateFieldRequest
+	Parser  *analysis.GoParser // Type-checks packages on demand; may be nil if they already are
+
+	// Resolved by Validate
+	pkg      *types.Package
+	file     *types.File
+	typeSpec *ast.TypeSpec
+	genDecl  *ast.GenDecl
+	field    *ast.Field
+	ident    *ast.Ident
+	fieldVar *gotypes.Var
+	newName  string
+	getter   string
+	setter   string
+}
+
+func (op *EncapsulateFieldOperation) Type() types.OperationType {
+	return types.EncapsulateFieldOperation
+}
+
+func (op *EncapsulateFieldOperation) Description() string {
+	return fmt.Sprintf("Encapsulate field %s.%s", op.Request.TypeName, op.Request.FieldName)
+}
+
+func (op *EncapsulateFieldOperation) Validate(ws *types.Workspace) error {
+	req := op.Request
+	if req.TypeName == "" || req.FieldName == "" {
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: "type name and field name are required",
+		}
+	}
+
+	if err := op.findField(ws); err != nil {
+		return err
+	}
+	if op.Parser != nil {
+		op.Parser.EnsureTypeChecked(ws, op.pkg)
+	}
+	if op.pkg.TypesInfo == nil || op.pkg.TypesPkg == nil {
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("package %s could not be type-checked; field accesses cannot be resolved", op.pkg.ImportPath),
+		}
+	}
+	fieldVar, ok := op.pkg.TypesInfo.Defs[op.ident].(*gotypes.Var)
+	if !ok {
+		return &types.RefactorError{
+			Type:    types.SymbolNotFound,
+			Message: fmt.Sprintf("no type information for field %s.%s", req.TypeName, req.FieldName),
+		}
+	}
+	op.fieldVar = fieldVar
+
+	op.newName = req.FieldName
+	switch {
+	case req.NewFieldName != "":
+		op.newName = req.NewFieldName
+	case !req.KeepField:
+		op.newName = unexportName(req.FieldName)
+	}
+	op.getter = req.GetterName
+	if op.getter == "" {
+		op.getter = "Get" + exportFirst(req.FieldName)
+	}
+	op.setter = req.SetterName
+	if op.setter == "" {
+		op.setter = "Set" + exportFirst(req.FieldName)
+	}
+
+	for _, name := range []string{op.newName, op.getter, op.setter} {
+		if !isValidGoIdentifier(name) || token.IsKeyword(name) {
+			return &types.RefactorError{
+				Type:    types.InvalidOperation,
+				Message: fmt.Sprintf("%q is not a valid Go identifier; set new_field_name, getter_name or setter_name", name),
+			}
+		}
+	}
+	if op.getter == op.setter {
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("getter and setter cannot both be named %s", op.getter),
+		}
+	}
+
+	// The new names must not clash with existing fields or methods of the type.
+	typeName, _ := op.pkg.TypesPkg.Scope().Lookup(req.TypeName).(*gotypes.TypeName)
+	if typeName == nil {
+		return &types.RefactorError{
+			Type:    types.SymbolNotFound,
+			Message: fmt.Sprintf("type %s not found in package %s", req.TypeName, op.pkg.ImportPath),
+		}
+	}
+	ptr := gotypes.NewPointer(typeName.Type())
+	names := []string{op.getter, op.setter}
+	if op.newName != req.FieldName {
+		names = append(names, op.newName)
+	}
+	for _, name := range names {
+		if obj, _, _ := gotypes.LookupFieldOrMethod(ptr, true, op.pkg.TypesPkg, name); obj != nil {
+			return &types.RefactorError{
+				Type:    types.NameConflict,
+				Message: fmt.Sprintf("%s already has a field or method named %s", req.TypeName, name),
+			}
+		}
+	}
+	return nil
+}
+
+// findField locates the struct field declaration, restricted to
+// Request.Package when set.
+func (op *EncapsulateFieldOperation) findField(ws *types.Workspace) error {
+	req := op.Request
+	found := 0
+	for _, pkgPath := range slices.Sorted(maps.Keys(ws.Packages)) {
+		pkg := ws.Packages[pkgPath]
+		if req.Package != "" && pkg.Path != req.Package && pkg.ImportPath != req.Package {
+			continue
+		}
+		for _, fileName := range slices.Sorted(maps.Keys(pkg.Files)) {
+			file := pkg.Files[fileName]
+			if file.AST == nil {
+				continue
+			}
+			for _, decl := range file.AST.Decls {
+				genDecl, ok := decl.(*ast.GenDecl)
+				if !ok || genDecl.Tok != token.TYPE {
+					continue
+				}
+				for _, spec := range genDecl.Specs {
+					typeSpec := spec.(*ast.TypeSpec)
+					structType, ok := typeSpec.Type.(*ast.StructType)
+					if !ok || typeSpec.Name.Name != req.TypeName {
+						continue
+					}
+					for _, field := range structType.Fields.List {
+						if len(field.Names) == 0 {
+							if embeddedName(field.Type) == req.FieldName {
+								return &types.RefactorError{
+									Type:    types.InvalidOperation,
+									Message: fmt.Sprintf("%s.%s is an embedded field and cannot be encapsulated", req.TypeName, req.FieldName),
+									File:    file.Path,
+								}
+							}
+							continue
+						}
+						for _, name := range field.Names {
+							if name.Name == req.FieldName {
+								found++
+								op.pkg, op.file, op.genDecl, op.typeSpec, op.field, op.ident = pkg, file, genDecl, typeSpec, field, name
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+
+	switch {
+	case found == 0:
+		return &types.RefactorError{
+			Type:    types.SymbolNotFound,
+			Message: fmt.Sprintf("field %s.%s not found", req.TypeName, req.FieldName),
+		}
+	case found > 1:
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("field %s.%s is declared in %d packages; specify the package", req.TypeName, req.FieldName, found),
+		}
+	}
+	return nil
+}
+
+func (op *EncapsulateFieldOperation) Execute(ws *types.Workspace) (*types.RefactoringPlan, error) {
+	var changes []types.Change
+	var issues []types.Issue
+
+	desc := op.Description()
+	if op.newName != op.Request.FieldName {
+		changes = append(changes, op.replaceNode(ws, op.file, op.ident.Pos(), op.ident.End(), op.newName, desc))
+	}
+	changes = append(changes, types.Change{
+		File:        op.file.Path,
+		Start:       ws.FileSet.Position(op.genDecl.End()).Offset,
+		End:         ws.FileSet.Position(op.genDecl.End()).Offset,
+		NewText:     op.accessors(ws),
+		Description: fmt.Sprintf("Add %s and %s accessors", op.getter, op.setter),
+	})
+
+	for _, pkgPath := range slices.Sorted(maps.Keys(ws.Packages)) {
+		pkg := ws.Packages[pkgPath]
+		for _, fileName := range slices.Sorted(maps.Keys(pkg.Files)) {
+			file := pkg.Files[fileName]
+			if file.AST == nil || !op.mentionsField(file) {
+				continue
+			}
+			// Only packages that may access the field are type-checked.
+			if op.Parser != nil {
+				op.Parser.EnsureTypeChecked(ws, pkg)
+			}
+			if pkg.TypesInfo == nil {
+				issues = append(issues, op.issue(ws, file, file.AST.Package,
+					fmt.Sprintf("package %s could not be type-checked; accesses to %s in this file were not rewritten", pkg.ImportPath, op.Request.FieldName)))
+				continue
+			}
+			fileChanges, fileIssues := op.rewriteFile(ws, pkg, file)
+			changes = append(changes, fileChanges...)
+			issues = append(issues, fileIssues...)
+		}
+
+		// Test files are not type-checked, so accesses there can't be
+		// resolved reliably; point them out instead of guessing.
+		for _, fileName := range slices.Sorted(maps.Keys(pkg.TestFiles)) {
+			file := pkg.TestFiles[fileName]
+			if file.AST == nil || (pkg == op.pkg && file.AST.Name.Name == op.pkg.Name && op.newName == op.Request.FieldName) {
+				continue
+			}
+			if op.mentionsField(file) {
+				issues = append(issues, op.issue(ws, file, file.AST.Package,
+					fmt.Sprintf("test file may access %s.%s directly; update it to use %s/%s", op.Request.TypeName, op.Request.FieldName, op.getter, op.setter)))
+			}
+		}
+	}
+
+	affected := make([]string, 0)
+	for _, change := range changes {
+		if !slices.Contains(affected, change.File) {
+			affected = append(affected, change.File)
+		}
+	}
+
+	return &types.RefactoringPlan{
+		Changes:       changes,
+		AffectedFiles: affected,
+		Impact: &types.ImpactAnalysis{
+			AffectedPackages: []string{op.pkg.Path},
+			AffectedFiles:    affected,
+			PotentialIssues:  issues,
+		},
+		Reversible: true,
+	}, nil
+}
+
+// rewriteFile rewrites the field accesses in one type-checked file.
+func (op *EncapsulateFieldOperation) rewriteFile(ws *types.Workspace, pkg *types.Package, file *types.File) ([]types.Change, []types.Issue) {
+	var changes []types.Change
+	var issues []types.Issue
+	info := pkg.TypesInfo
+	internal := pkg == op.pkg
+	renamed := op.newName != op.Request.FieldName
+	desc := op.Description()
+
+	var stack []ast.Node
+	ast.Inspect(file.AST, func(n ast.Node) bool {
+		if n == nil {
+			stack = stack[:len(stack)-1]
+			return true
+		}
+		defer func() { stack = append(stack, n) }()
+
+		switch n := n.(type) {
+		case *ast.KeyValueExpr:
+			key, ok := n.Key.(*ast.Ident)
+			if !ok || info.Uses[key] != op.fieldVar {
+				return true
+			}
+			if internal {
+				if renamed {
+					changes = append(changes, op.replaceNode(ws, file, key.Pos(), key.End(), op.newName, desc))
+				}
+				return true
+			}
+			issues = append(issues, op.issue(ws, file, key.Pos(),
+				fmt.Sprintf("composite literal sets %s directly; set it with %s after construction", op.Request.FieldName, op.setter)))
+
+		case *ast.CompositeLit:
+			if internal || !renamed || len(n.Elts) == 0 {
+				return true
+			}
+			if _, keyed := n.Elts[0].(*ast.KeyValueExpr); keyed || !op.isFieldType(info.Types[n].Type) {
+				return true
+			}
+			issues = append(issues, op.issue(ws, file, n.Pos(),
+				fmt.Sprintf("unkeyed %s literal sets %s directly and will not compile once it is unexported", op.Request.TypeName, op.Request.FieldName)))
+
+		case *ast.SelectorExpr:
+			if info.Uses[n.Sel] != op.fieldVar {
+				return true
+			}
+			if internal {
+				if renamed {
+					changes = append(changes, op.replaceNode(ws, file, n.Sel.Pos(), n.Sel.End(), op.newName, desc))
+				}
+				return true
+			}
+			c, issue := op.rewriteAccess(ws, info, file, n, stack)
+			changes = append(changes, c...)
+			if issue != "" {
+				issues = append(issues, op.issue(ws, file, n.Pos(), issue))
+			}
+		}
+		return true
+	})
+	return changes, issues
+}
+
+// rewriteAccess rewrites one external access x.F depending on how it is used.
+// It returns a description of the problem instead when it can't.
+func (op *EncapsulateFieldOperation) rewriteAccess(ws *types.Workspace, info *gotypes.Info, file *types.File, sel *ast.SelectorExpr, stack []ast.Node) ([]types.Change, string) {
+	// The setter rewrites are fragments, which the validator only accepts
+	// for descriptions naming a replacement.
+	desc := fmt.Sprintf("Replace direct access to %s.%s with accessors", op.Request.TypeName, op.Request.FieldName)
+	field := op.Request.FieldName
+	if !receiverAddressable(info, sel.X) {
+		return nil, fmt.Sprintf("%s is accessed on a value that is not addressable; call %s/%s manually", field, op.getter, op.setter)
+	}
+
+	parent := stack[len(stack)-1]
+	switch p := parent.(type) {
+	case *ast.AssignStmt:
+		if !slices.Contains(p.Lhs, ast.Expr(sel)) {
+			break
+		}
+		if len(p.Lhs) != 1 || len(p.Rhs) != 1 {
+			return nil, fmt.Sprintf("%s is assigned in a multi-value assignment; rewrite it to use %s", field, op.setter)
+		}
+		rhs := p.Rhs[0]
+		prefix := op.setter + "("
+		suffix := ")"
+		if p.Tok != token.ASSIGN && p.Tok != token.DEFINE {
+			if containsCall(sel.X) {
+				return nil, fmt.Sprintf("%s %s would evaluate %s twice; rewrite it to use %s and %s", field, p.Tok, op.exprText(ws, info, file, sel.X), op.getter, op.setter)
+			}
+			binOp := strings.TrimSuffix(p.Tok.String(), "=")
+			prefix += op.exprText(ws, info, file, sel.X) + "." + op.getter + "() " + binOp + " "
+			if !isSimpleExpr(rhs) {
+				prefix += "("
+				suffix = "))"
+			}
+		}
+		return []types.Change{
+			op.replaceNode(ws, file, sel.Sel.Pos(), rhs.Pos(), prefix, desc),
+			op.replaceNode(ws, file, rhs.End(), rhs.End(), suffix, desc),
+		}, ""
+
+	case *ast.IncDecStmt:
+		binOp := "+"
+		if p.Tok == token.DEC {
+			binOp = "-"
+		}
+		if containsCall(sel.X) {
+			return nil, fmt.Sprintf("%s%s would evaluate %s twice; rewrite it to use %s and %s", field, p.Tok, op.exprText(ws, info, file, sel.X), op.getter, op.setter)
+		}
+		text := fmt.Sprintf("%s(%s.%s() %s 1)", op.setter, op.exprText(ws, info, file, sel.X), op.getter, binOp)
+		return []types.Change{op.replaceNode(ws, file, sel.Sel.Pos(), p.End(), text, desc)}, ""
+
+	case *ast.UnaryExpr:
+		if p.Op == token.AND {
+			return nil, fmt.Sprintf("the address of %s is taken; this can't be expressed with accessors", field)
+		}
+
+	case *ast.RangeStmt:
+		if p.Key == ast.Expr(sel) || p.Value == ast.Expr(sel) {
+			return nil, fmt.Sprintf("%s is assigned by a range clause; rewrite it to use %s", field, op.setter)
+		}
+	}
+
+	// A read. If the value is then modified in place (x.F.G = v, x.F[i]++,
+	// &x.F.G) the getter's copy would be modified instead, so flag it for
+	// value types.
+	if mutatedInPlace(info, sel, stack) && !isReferenceType(op.fieldVar.Type()) {
+		return nil, fmt.Sprintf("%s is modified in place; read it with %s, modify the copy and store it with %s", field, op.getter, op.setter)
+	}
+	return []types.Change{op.replaceNode(ws, file, sel.Sel.Pos(), sel.Sel.End(), op.getter+"()", desc)}, ""
+}
+
+// accessors returns the source of the getter and setter methods.
+func (op *EncapsulateFieldOperation) accessors(ws *types.Workspace) string {
+	recv := op.receiverName()
+	recvType := op.Request.TypeName
+	if op.typeSpec.TypeParams != nil {
+		var params []string
+		for _, field := range op.typeSpec.TypeParams.List {
+			for _, name := range field.Names {
+				params = append(params, name.Name)
+			}
+		}
+		recvType += "[" + strings.Join(params, ", ") + "]"
+	}
+	fieldType := nodeText(ws, op.file, op.field.Type.Pos(), op.field.Type.End())
+
+	param := unexportName(op.Request.FieldName)
+	if param == recv || token.IsKeyword(param) || !isValidGoIdentifier(param) {
+		param = "v"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n\n// %s returns the value of the %s field.\n", op.getter, op.newName)
+	fmt.Fprintf(&b, "func (%s *%s) %s() %s {\n\treturn %s.%s\n}\n", recv, recvType, op.getter, fieldType, recv, op.newName)
+	fmt.Fprintf(&b, "\n// %s sets the %s field.\n", op.setter, op.newName)
+	fmt.Fprintf(&b, "func (%s *%s) %s(%s %s) {\n\t%s.%s = %s\n}", recv, recvType, op.setter, param, fieldType, recv, op.newName, param)
+	return b.String()
+}
+
+// receiverName reuses the receiver name of the type's existing methods, or
+// derives one from the type name.
+func (op *EncapsulateFieldOperation) receiverName() string {
+	for _, file := range op.pkg.Files {
+		if file.AST == nil {
+			continue
+		}
+		for _, decl := range file.AST.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv == nil || len(fn.Recv.List) == 0 || len(fn.Recv.List[0].Names) == 0 {
+				continue
+			}
+			if receiverTypeName(fn) == op.Request.TypeName {
+				if name := fn.Recv.List[0].Names[0].Name; name != "_" {
+					return name
+				}
+			}
+		}
+	}
+	r, _ := utf8.DecodeRuneInString(op.Request.TypeName)
+	return string(unicode.ToLower(r))
+}
+
+// isFieldType reports whether t is the struct type declaring the field, or
+// an instantiation of it.
+func (op *EncapsulateFieldOperation) isFieldType(t gotypes.Type) bool {
+	named, ok := t.(*gotypes.Named)
+	if !ok {
+		return false
+	}
+	return named.Origin().Obj().Pkg() == op.pkg.TypesPkg && named.Obj().Name() == op.Request.TypeName
+}
+
+// mentionsField reports whether file syntactically refers to something named
+// like the field, used where type information is unavailable.
+func (op *EncapsulateFieldOperation) mentionsField(file *types.File) bool {
+	found := false
+	ast.Inspect(file.AST, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			found = found || n.Sel.Name == op.Request.FieldName
+		case *ast.KeyValueExpr:
+			if key, ok := n.Key.(*ast.Ident); ok {
+				found = found || key.Name == op.Request.FieldName
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// exprText returns the source of expr with reads of the field rewritten to
+// getter calls, for expressions that are duplicated by a rewrite.
+func (op *EncapsulateFieldOperation) exprText(ws *types.Workspace, info *gotypes.Info, file *types.File, expr ast.Expr) string {
+	var sels []*ast.Ident
+	ast.Inspect(expr, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok && info.Uses[sel.Sel] == op.fieldVar {
+			sels = append(sels, sel.Sel)
+		}
+		return true
+	})
+	sort.Slice(sels, func(i, j int) bool { return sels[i].Pos() < sels[j].Pos() })
+
+	var b strings.Builder
+	pos := expr.Pos()
+	for _, ident := range sels {
+		b.WriteString(nodeText(ws, file, pos, ident.Pos()))
+		b.WriteString(op.getter + "()")
+		pos = ident.End()
+	}
+	b.WriteString(nodeText(ws, file, pos, expr.End()))
+	return b.String()
+}
+
+func (op *EncapsulateFieldOperation) replaceNode(ws *types.Workspace, file *types.File, start, end token.Pos, text, desc string) types.Change {
+	return types.Change{
+		File:        file.Path,
+		Start:       ws.FileSet.Position(start).Offset,
+		End:         ws.FileSet.Position(end).Offset,
+		OldText:     nodeText(ws, file, start, end),
+		NewText:     text,
+		Description: desc,
+	}
+}
+
+func (op *EncapsulateFieldOperation) issue(ws *types.Workspace, file *types.File, pos token.Pos, msg string) types.Issue {
+	return types.Issue{
+		Type:        types.IssueVisibilityError,
+		Description: fmt.Sprintf("%s.%s: %s", op.Request.TypeName, op.Request.FieldName, msg),
+		File:        file.Path,
+		Line:        ws.FileSet.Position(pos).Line,
+		Severity:    types.Warning,
+	}
+}
+
+// nodeText returns the original source between start and end.
+func nodeText(ws *types.Workspace, file *types.File, start, end token.Pos) string {
+	return string(file.OriginalContent[ws.FileSet.Position(start).Offset:ws.FileSet.Position(end).Offset])
+}
+
+// receiverAddressable reports whether pointer-receiver methods can be called
+// on x: it is a pointer or an addressable value.
+func receiverAddressable(info *gotypes.Info, x ast.Expr) bool {
+	tv, ok := info.Types[x]
+	if !ok {
+		return true // Unknown; keep the rewrite rather than dropping it
+	}
+	if _, isPtr := tv.Type.Underlying().(*gotypes.Pointer); isPtr {
+		return true
+	}
+	return tv.Addressable()
+}
+
+// mutatedInPlace reports whether the value of sel is modified through a
+// field selection, index, address-of or pointer-method call further up the
+// expression.
+func mutatedInPlace(info *gotypes.Info, sel *ast.SelectorExpr, stack []ast.Node) bool {
+	var child ast.Node = sel
+	for i := len(stack) - 1; i >= 0; i-- {
+		switch p := stack[i].(type) {
+		case *ast.ParenExpr:
+		case *ast.SelectorExpr:
+			if p.X != child {
+				return false
+			}
+			if fn, ok := info.Uses[p.Sel].(*gotypes.Func); ok {
+				recv := fn.Signature().Recv()
+				if recv == nil {
+					return false
+				}
+				_, ptrRecv := recv.Type().(*gotypes.Pointer)
+				return ptrRecv
+			}
+		case *ast.IndexExpr:
+			if p.X != child {
+				return false
+			}
+		case *ast.UnaryExpr:
+			return p.Op == token.AND && child != ast.Node(sel)
+		case *ast.AssignStmt:
+			return child != ast.Node(sel) && slices.Contains(p.Lhs, child.(ast.Expr))
+		case *ast.IncDecStmt:
+			return child != ast.Node(sel)
+		default:
+			return false
+		}
+		child = stack[i]
+	}
+	return false
+}
+
+// isReferenceType reports whether values of t share their underlying data
+// when copied, so modifying a getter's result still modifies the field.
+func isReferenceType(t gotypes.Type) bool {
+	switch t.Underlying().(type) {
+	case *gotypes.Pointer, *gotypes.Slice, *gotypes.Map, *gotypes.Chan, *gotypes.Signature, *gotypes.Interface:
+		return true
+	}
+	return false
+}
+
+func containsCall(expr ast.Expr) bool {
+	found := false
+	ast.Inspect(expr, func(n ast.Node) bool {
+		_, isCall := n.(*ast.CallExpr)
+		found = found || isCall
+		return !found
+	})
+	return found
+}
+
+func isSimpleExpr(expr ast.Expr) bool {
+	switch expr.(type) {
+	case *ast.Ident, *ast.BasicLit, *ast.SelectorExpr, *ast.CallExpr, *ast.ParenExpr, *ast.IndexExpr:
+		return true
+	}
+	return false
+}
+
+func embeddedName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return t.Name
+	case *ast.StarExpr:
+		return embeddedName(t.X)
+	case *ast.SelectorExpr:
+		return t.Sel.Name
+	case *ast.IndexExpr:
+		return embeddedName(t.X)
+	case *ast.IndexListExpr:
+		return embeddedName(t.X)
+	}
+	return ""
+}
+
+// unexportName lowercases the first rune of name.
+func unexportName(name string) string {
+	r, size := utf8.DecodeRuneInString(name)
+	return string(unicode.ToLower(r)) + name[size:]
+}
+
+// exportFirst uppercases the first rune of name.
+func exportFirst(name string) string {
+	r, size := utf8.DecodeRuneInString(name)
+	return string(unicode.ToUpper(r)) + name[size:]
+}
@@ -0,0 +1,124 @@
+package refactor
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+func loadEncapsulateModule(t *testing.T, app string) (RefactorEngine, *types.Workspace) {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/p\n\ngo 1.21\n",
+		"m/m.go": `package m
+
+type Point struct{ X, Y int }
+
+func (p *Point) Move() { p.X++ }
+
+type Counter struct {
+	Hits  int
+	Pos   Point
+	GetN  int
+}
+
+func Make() Counter { return Counter{} }
+`,
+		"app/app.go": app,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	engine := CreateEngine(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ws, err := engine.LoadWorkspace(dir)
+	if err != nil {
+		t.Fatalf("LoadWorkspace: %v", err)
+	}
+	return engine, ws
+}
+
+func TestEncapsulateField_Rewrites(t *testing.T) {
+	engine, ws := loadEncapsulateModule(t, `package app
+
+import "example.com/p/m"
+
+func Use(c *m.Counter) int {
+	c.Hits++
+	c.Hits -= 2
+	return c.Hits
+}
+`)
+	plan, err := engine.EncapsulateField(ws, types.EncapsulateFieldRequest{TypeName: "Counter", FieldName: "Hits"})
+	if err != nil {
+		t.Fatalf("EncapsulateField: %v", err)
+	}
+	if len(plan.Impact.PotentialIssues) != 0 {
+		t.Errorf("expected no flagged accesses, got %+v", plan.Impact.PotentialIssues)
+	}
+
+	var newTexts []string
+	for _, c := range plan.Changes {
+		newTexts = append(newTexts, c.NewText)
+	}
+	got := strings.Join(newTexts, "|")
+	for _, want := range []string{"hits", "SetHits(c.GetHits() + 1)", "SetHits(c.GetHits() - ", "GetHits()"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected a change containing %q, got %s", want, got)
+		}
+	}
+}
+
+func TestEncapsulateField_FlagsUnconvertibleAccesses(t *testing.T) {
+	engine, ws := loadEncapsulateModule(t, `package app
+
+import "example.com/p/m"
+
+func Use(c *m.Counter) {
+	c.Pos.X = 1
+	c.Pos.Move()
+	m.Make().Pos.X++
+	_ = m.Counter{1, m.Point{}, 0}
+}
+`)
+	plan, err := engine.EncapsulateField(ws, types.EncapsulateFieldRequest{TypeName: "Counter", FieldName: "Pos"})
+	if err != nil {
+		t.Fatalf("EncapsulateField: %v", err)
+	}
+	// In-place modification, pointer method on a copy, non-addressable
+	// receiver and an unkeyed literal.
+	if n := len(plan.Impact.PotentialIssues); n != 4 {
+		t.Errorf("expected 4 flagged accesses, got %d: %+v", n, plan.Impact.PotentialIssues)
+	}
+}
+
+func TestEncapsulateField_Validate(t *testing.T) {
+	engine, ws := loadEncapsulateModule(t, "package app\n")
+	tests := []struct {
+		name string
+		req  types.EncapsulateFieldRequest
+	}{
+		{"missing field", types.EncapsulateFieldRequest{TypeName: "Counter", FieldName: "Missing"}},
+		{"new name conflicts with field", types.EncapsulateFieldRequest{TypeName: "Counter", FieldName: "Hits", NewFieldName: "Pos"}},
+		{"getter conflicts with field", types.EncapsulateFieldRequest{TypeName: "Counter", FieldName: "Hits", GetterName: "GetN"}},
+		{"keyword field name", types.EncapsulateFieldRequest{TypeName: "Counter", FieldName: "Hits", NewFieldName: "type"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := engine.EncapsulateField(ws, tt.req); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -1,13 +1,19 @@
 package refactor
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log/slog"
-	"os/exec"
+	"maps"
+	"os"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
 
 	"github.com/mamaar/gorefactor/pkg/analysis"
+	"github.com/mamaar/gorefactor/pkg/history"
 	"github.com/mamaar/gorefactor/pkg/types"
 )
 
@@ -15,6 +21,7 @@ import (
 type RefactorEngine interface {
 	// Workspace management
 	LoadWorkspace(path string) (*types.Workspace, error)
+	LoadWorkspaceContext(ctx context.Context, path string) (*types.Workspace, error)
 	SaveWorkspace(ws *types.Workspace) error
 
 	// Refactoring operations
@@ -31,6 +38,27 @@ type RefactorEngine interface {
 	InlineVariable(ws *types.Workspace, req types.InlineVariableRequest) (*types.RefactoringPlan, error)
 	InlineFunction(ws *types.Workspace, req types.InlineFunctionRequest) (*types.RefactoringPlan, error)
 	SafeDelete(ws *types.Workspace, req types.SafeDeleteRequest) (*types.RefactoringPlan, error)
+	EncapsulateField(ws *types.Workspace, req types.EncapsulateFieldRequest) (*types.RefactoringPlan, error)
+	ShrinkInterface(ws *types.Workspace, req types.ShrinkInterfaceRequest) (*types.RefactoringPlan, error)
+	SegregateInterface(ws *types.Workspace, req types.SegregateInterfaceRequest) (*types.RefactoringPlan, error)
+	BuildTags(ws *types.Workspace, req types.BuildTagsRequest) (*types.RefactoringPlan, error)
+	ThreadContext(ws *types.Workspace, req types.ThreadContextRequest) (*types.RefactoringPlan, error)
+	ExtractTestHelper(ws *types.Workspace, req types.ExtractTestHelperRequest) (*types.RefactoringPlan, error)
+	ExtractPipelineStages(ws *types.Workspace, req types.ExtractPipelineStagesRequest) (*types.RefactoringPlan, error)
+	ConvertTypeAlias(ws *types.Workspace, req types.ConvertTypeAliasRequest) (*types.RefactoringPlan, error)
+	ConvertReceivers(ws *types.Workspace, req types.ConvertReceiversRequest) (*types.RefactoringPlan, error)
+	AddField(ws *types.Workspace, req types.AddFieldRequest) (*types.RefactoringPlan, error)
+	IntroduceType(ws *types.Workspace, req types.IntroduceTypeRequest) (*types.RefactoringPlan, error)
+	GenerateMock(ws *types.Workspace, req types.GenerateMockRequest) (*types.RefactoringPlan, error)
+	UpdateMocks(ws *types.Workspace, req types.UpdateMocksRequest) (*types.RefactoringPlan, error)
+	FixMagicNumbers(ws *types.Workspace, req types.FixMagicNumbersRequest) (*types.RefactoringPlan, error)
+	OrganizeFile(ws *types.Workspace, req types.OrganizeFileRequest) (*types.RefactoringPlan, error)
+	SplitFile(ws *types.Workspace, req types.SplitFileRequest) (*types.RefactoringPlan, error)
+	FixErrorReturns(ws *types.Workspace, req types.FixErrorReturnsRequest) (*types.RefactoringPlan, error)
+	FixNaming(ws *types.Workspace, req types.FixNamingRequest) (*types.RefactoringPlan, error)
+	BulkRename(ws *types.Workspace, req types.BulkRenameRequest) (*types.RefactoringPlan, error)
+	Rewrite(ws *types.Workspace, req types.RewriteRequest) (*types.RefactoringPlan, error)
+	Migrate(ws *types.Workspace, req types.MigrateRequest) (*types.RefactoringPlan, error)
 	ChangeSignature(ws *types.Workspace, req ChangeSignatureRequest) (*types.RefactoringPlan, error)
 	BatchRefactor(ws *types.Workspace, ops []types.Operation) (*types.RefactoringPlan, error)
 
@@ -38,6 +66,10 @@ type RefactorEngine interface {
 	MovePackage(ws *types.Workspace, req types.MovePackageRequest) (*types.RefactoringPlan, error)
 	MoveDir(ws *types.Workspace, req types.MoveDirRequest) (*types.RefactoringPlan, error)
 	MovePackages(ws *types.Workspace, req types.MovePackagesRequest) (*types.RefactoringPlan, error)
+	MovePackageContext(ctx context.Context, ws *types.Workspace, req types.MovePackageRequest) (*types.RefactoringPlan, error)
+	MoveDirContext(ctx context.Context, ws *types.Workspace, req types.MoveDirRequest) (*types.RefactoringPlan, error)
+	MovePackagesContext(ctx context.Context, ws *types.Workspace, req types.MovePackagesRequest) (*types.RefactoringPlan, error)
+	MergePackages(ws *types.Workspace, req types.MergePackagesRequest) (*types.RefactoringPlan, error)
 	
 	// Facade operations
 	CreateFacade(ws *types.Workspace, req types.CreateFacadeRequest) (*types.RefactoringPlan, error)
@@ -53,6 +85,8 @@ type RefactorEngine interface {
 	// Dependency graph operations
 	MoveByDependencies(ws *types.Workspace, req types.MoveByDependenciesRequest) (*types.RefactoringPlan, error)
 	OrganizeByLayers(ws *types.Workspace, req types.OrganizeByLayersRequest) (*types.RefactoringPlan, error)
+	OrganizeByLayersContext(ctx context.Context, ws *types.Workspace, req types.OrganizeByLayersRequest) (*types.RefactoringPlan, error)
+	OrganizeByDomain(ws *types.Workspace, req types.OrganizeByDomainRequest) (*types.RefactoringPlan, error)
 	FixCycles(ws *types.Workspace, req types.FixCyclesRequest) (*types.RefactoringPlan, error)
 	AnalyzeDependencies(ws *types.Workspace, req types.AnalyzeDependenciesRequest) (*types.RefactoringPlan, error)
 	
@@ -64,11 +98,21 @@ type RefactorEngine interface {
 
 	// Analysis
 	AnalyzeImpact(ws *types.Workspace, op types.Operation) (*types.ImpactAnalysis, error)
+	CallGraph(ws *types.Workspace, req types.CallGraphRequest) (*analysis.CallGraph, error)
+	CheckArchitecture(ws *types.Workspace, req types.CheckArchitectureRequest) ([]*analysis.ArchViolation, error)
+	InferLayers(ws *types.Workspace) ([]*analysis.LayerAssignment, error)
+	InterfaceUsage(ws *types.Workspace) ([]*analysis.InterfaceUsage, error)
+	SuggestHome(ws *types.Workspace, req types.SuggestHomeRequest) ([]*analysis.HomeCandidate, error)
+	TypeHierarchy(ws *types.Workspace, req types.TypeHierarchyRequest) (*analysis.TypeHierarchy, error)
 	ValidateRefactoring(plan *types.RefactoringPlan) error
 
 	// Execution
 	ExecutePlan(plan *types.RefactoringPlan) error
+	ExecutePlanContext(ctx context.Context, plan *types.RefactoringPlan) error
 	PreviewPlan(plan *types.RefactoringPlan) (string, error)
+
+	// Progress
+	SetProgressReporter(r types.ProgressReporter)
 }
 
 // DefaultEngine implements the Engine interface
@@ -80,12 +124,37 @@ type DefaultEngine struct {
 	serializer *Serializer
 	config     *EngineConfig
 	logger     *slog.Logger
+	generated  *analysis.GeneratedFileDetector
+	progress   types.ProgressReporter
+	root       string // root of the loaded workspace, used for the history journal
 }
 
 // EngineConfig contains configuration options for the refactoring engine
 type EngineConfig struct {
 	SkipCompilation bool
 	AllowBreaking   bool
+	BreakingPolicy  []BreakingRule    // Per-package overrides of AllowBreaking
+	AllowGenerated  bool              // Permit plans that edit generated files
+	GeneratedDirs   []string          // Directory names treated as generated (default: analysis.DefaultGeneratedDirs)
+	MockSuffixes    []string          // File name suffixes of generated mocks (default: analysis.DefaultMockSuffixes)
+	ExcludeDirs     []string          // Directories relative to the workspace root that are not loaded
+	Journal         bool              // Record executed plans in .gorefactor/history so they can be rolled back
+	Format          FormatStyle       // Formatter and import grouping applied to written Go files
+	VerifyInternal  bool              // Check workspace invariants after loading and after each plan; always on in gorefactor_debug builds
+	Build           BuildValidator    // Checks the code builds after a plan is applied; nil runs go build
+	MinConfidence   types.Confidence  // Changes less sure than this are skipped when a plan is executed; empty applies all
+	ImportAliases   []types.AliasRule // Aliases of the imports operations add, as standardize_imports applies them
+	ChangeBackend   ChangeBackend     // How extract and inline operations compute their changes; empty is ChangeBackendAST
+	Loader          WorkspaceLoader   // How workspaces are loaded; empty is LoaderParser
+	Dependents      []string          // Roots of repositories importing the workspace, relative to it or absolute; scanned read-only
+	VerifyTests     TestVerification  // Tests run before and after a plan is applied, rolling it back on new failures; empty is off
+	Scope           []string          // Package directories relative to the workspace root operations are limited to; see analysis.GoParser.LimitScope
+	MapSources      bool              // Keep file contents in memory-mapped files instead of the Go heap
+
+	// ReferenceIndex returns an index of ws kept up to date by the caller,
+	// which operations find references in instead of indexing ws for each
+	// plan; nil, or a nil index, has them index it themselves
+	ReferenceIndex func(ws *types.Workspace) *analysis.ReferenceIndex
 }
 
 // WatchContext exposes the internal components needed by the watch subsystem.
@@ -99,7 +168,13 @@ type WatchContext struct {
 // LoadWorkspaceForWatch loads a workspace and returns the internal components
 // required for incremental watch-mode updates.
 func (e *DefaultEngine) LoadWorkspaceForWatch(path string) (*WatchContext, error) {
-	ws, err := e.LoadWorkspace(path)
+	return e.LoadWorkspaceForWatchContext(context.Background(), path)
+}
+
+// LoadWorkspaceForWatchContext is like LoadWorkspaceForWatch but honours ctx
+// cancellation while loading.
+func (e *DefaultEngine) LoadWorkspaceForWatchContext(ctx context.Context, path string) (*WatchContext, error) {
+	ws, err := e.LoadWorkspaceContext(ctx, path)
 	if err != nil {
 		return nil, err
 	}
@@ -111,6 +186,38 @@ func (e *DefaultEngine) LoadWorkspaceForWatch(path string) (*WatchContext, error
 	}, nil
 }
 
+// Config returns the engine's configuration. Callers may adjust it between
+// operations; it is read on every plan validation.
+func (e *DefaultEngine) Config() *EngineConfig {
+	return e.config
+}
+
+// referenceFinder returns a finder for one plan computation on ws, backed
+// by the index the config shares, if any.
+func (e *DefaultEngine) referenceFinder(ws *types.Workspace) *analysis.ReferenceFinder {
+	var idx *analysis.ReferenceIndex
+	if e.config != nil && e.config.ReferenceIndex != nil {
+		idx = e.config.ReferenceIndex(ws)
+	}
+	return newReferenceFinderWith(ws, idx)
+}
+
+// SetProgressReporter sets a reporter that receives progress events for the
+// parse, index, plan and apply phases of subsequent engine calls. Pass nil to
+// stop reporting.
+func (e *DefaultEngine) SetProgressReporter(r types.ProgressReporter) {
+	e.progress = r
+	e.parser.SetProgressReporter(r)
+	e.serializer.SetProgressReporter(r)
+}
+
+// report sends a progress event to the configured reporter, if any.
+func (e *DefaultEngine) report(event types.ProgressEvent) {
+	if e.progress != nil {
+		e.progress(event)
+	}
+}
+
 // DefaultConfig returns the default engine configuration
 func DefaultConfig() *EngineConfig {
 	return &EngineConfig{
@@ -124,21 +231,41 @@ func CreateEngine(logger *slog.Logger) RefactorEngine {
 }
 
 func CreateEngineWithConfig(config *EngineConfig, logger *slog.Logger) RefactorEngine {
-	return &DefaultEngine{
+	e := &DefaultEngine{
 		parser:     analysis.NewParser(logger),
 		validator:  NewValidator(logger),
 		serializer: NewSerializer(),
 		config:     config,
 		logger:     logger,
 	}
+	e.validator.references = e.referenceFinder
+	return e
 }
 
 // LoadWorkspace loads and parses a complete workspace
 func (e *DefaultEngine) LoadWorkspace(path string) (*types.Workspace, error) {
+	return e.LoadWorkspaceContext(context.Background(), path)
+}
+
+// LoadWorkspaceContext is like LoadWorkspace but stops parsing and indexing
+// once ctx is canceled.
+func (e *DefaultEngine) LoadWorkspaceContext(ctx context.Context, path string) (*types.Workspace, error) {
 	e.logger.Info("loading workspace", "path", path)
 
 	// Parse the workspace
-	workspace, err := e.parser.ParseWorkspace(path)
+	if e.config != nil {
+		e.parser.SetExcludedDirs(e.config.ExcludeDirs)
+		if !e.parser.SetMappedSources(e.config.MapSources) {
+			e.logger.Warn("memory-mapped sources are not supported on this platform; keeping file contents on the heap")
+		}
+	}
+	var workspace *types.Workspace
+	var err error
+	if e.config != nil && e.config.Loader == LoaderPackages {
+		workspace, err = e.parser.LoadWorkspacePackages(ctx, path)
+	} else {
+		workspace, err = e.parser.ParseWorkspaceContext(ctx, path)
+	}
 	if err != nil {
 		e.logger.Error("workspace parsing failed", "path", path, "err", err)
 		return nil, fmt.Errorf("failed to parse workspace: %w", err)
@@ -146,18 +273,36 @@ func (e *DefaultEngine) LoadWorkspace(path string) (*types.Workspace, error) {
 
 	// Create resolver with parsed workspace
 	e.resolver = analysis.NewSymbolResolver(workspace, e.logger)
+	e.generated = e.generatedDetector(workspace.RootPath)
+	e.root = workspace.RootPath
 
 	// Build symbol tables for all packages
 	e.logger.Debug("building symbol tables", "package_count", len(workspace.Packages))
+	indexed := 0
 	for _, pkg := range workspace.Packages {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		_, err := e.resolver.BuildSymbolTable(pkg)
 		if err != nil {
 			e.logger.Error("symbol table build failed", "package", pkg.Path, "err", err)
 			return nil, fmt.Errorf("failed to build symbol table for package %s: %w", pkg.Path, err)
 		}
+		indexed++
+		e.report(types.ProgressEvent{
+			Phase:   types.PhaseIndex,
+			Package: pkg.Path,
+			Current: indexed,
+			Total:   len(workspace.Packages),
+		})
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
 
 	// Create dependency analyzer and build dependency graph
+	e.report(types.ProgressEvent{Phase: types.PhaseIndex, Message: "building dependency graph"})
 	e.analyzer = analysis.NewDependencyAnalyzer(workspace, e.logger)
 	_, err = e.analyzer.BuildDependencyGraph()
 	if err != nil {
@@ -177,10 +322,55 @@ func (e *DefaultEngine) LoadWorkspace(path string) (*types.Workspace, error) {
 		}
 		e.serializer.SetModuleInfo(workspace.Module.Path, filtered)
 	}
+	if e.config != nil {
+		e.serializer.SetFormatStyle(e.config.Format)
+	}
+	if e.config != nil && len(e.config.Scope) > 0 {
+		e.report(types.ProgressEvent{Phase: types.PhaseIndex, Message: "limiting workspace to scope"})
+		e.parser.LimitScope(workspace, e.config.Scope)
+	}
 
+	if err := e.VerifyWorkspace(workspace, "after load"); err != nil {
+		return nil, err
+	}
 	return workspace, nil
 }
 
+// VerifyWorkspace checks the workspace invariants when internal verification
+// is on, reporting violations as an error that names stage. It is a no-op
+// otherwise.
+func (e *DefaultEngine) VerifyWorkspace(ws *types.Workspace, stage string) error {
+	if !verifyInternalDefault && (e.config == nil || !e.config.VerifyInternal) {
+		return nil
+	}
+	if err := ws.CheckInvariants(); err != nil {
+		e.logger.Error("workspace invariants violated", "stage", stage, "err", err)
+		return fmt.Errorf("internal verification %s: %w", stage, err)
+	}
+	return nil
+}
+
+// MemoryReport estimates the memory each package of ws holds; see
+// analysis.GoParser.MemoryReport.
+func (e *DefaultEngine) MemoryReport(ws *types.Workspace) []analysis.PackageMemory {
+	return e.parser.MemoryReport(ws)
+}
+
+// EnsureTypeChecked type-checks the given packages unless they already
+// are, for callers such as vet-style analyzers that need full type
+// information.
+func (e *DefaultEngine) EnsureTypeChecked(ws *types.Workspace, pkgs ...*types.Package) {
+	for _, pkg := range pkgs {
+		e.parser.EnsureTypeChecked(ws, pkg)
+	}
+}
+
+// ValidateWorkspace reports what keeps the workspace's packages from
+// building; see analysis.GoParser.ValidateWorkspace.
+func (e *DefaultEngine) ValidateWorkspace(ctx context.Context, ws *types.Workspace) ([]*analysis.WorkspaceDiagnostic, error) {
+	return e.parser.ValidateWorkspace(ctx, ws)
+}
+
 // SaveWorkspace saves all changes in the workspace to disk
 func (e *DefaultEngine) SaveWorkspace(ws *types.Workspace) error {
 	var allChanges []types.Change
@@ -209,18 +399,19 @@ func (e *DefaultEngine) MoveSymbol(ws *types.Workspace, req types.MoveSymbolRequ
 	req.CreateTarget = true
 	req.UpdateTests = true
 
-	operation := &MoveSymbolOperation{Request: req}
+	operation := &MoveSymbolOperation{Request: req, refs: e.referenceFinder(ws)}
 
 	// Validate the operation
 	if err := operation.Validate(ws); err != nil {
-		return nil, fmt.Errorf("move operation validation failed: %w", err)
+		return nil, fmt.Errorf("move operation validation failed: %w", withMoveSuggestions(ws, err, req))
 	}
 
 	// Execute the operation to generate the plan
 	plan, err := operation.Execute(ws)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate move plan: %w", err)
+		return nil, fmt.Errorf("failed to generate move plan: %w", withMoveSuggestions(ws, err, req))
 	}
+	e.manageImports(ws, plan, packagePathToImportPath(ws, req.ToPackage))
 
 	// Analyze impact
 	impact, err := e.analyzer.AnalyzeImpact(operation)
@@ -236,17 +427,17 @@ func (e *DefaultEngine) MoveSymbol(ws *types.Workspace, req types.MoveSymbolRequ
 
 // RenameSymbol implements symbol renaming
 func (e *DefaultEngine) RenameSymbol(ws *types.Workspace, req types.RenameSymbolRequest) (*types.RefactoringPlan, error) {
-	operation := &RenameSymbolOperation{Request: req}
+	operation := &RenameSymbolOperation{Request: req, refs: e.referenceFinder(ws)}
 
 	// Validate the operation
 	if err := operation.Validate(ws); err != nil {
-		return nil, fmt.Errorf("rename operation validation failed: %w", err)
+		return nil, fmt.Errorf("rename operation validation failed: %w", withSuggestions(ws, err, req.SymbolName))
 	}
 
 	// Execute the operation to generate the plan
 	plan, err := operation.Execute(ws)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate rename plan: %w", err)
+		return nil, fmt.Errorf("failed to generate rename plan: %w", withSuggestions(ws, err, req.SymbolName))
 	}
 
 	// Analyze impact
@@ -255,6 +446,40 @@ func (e *DefaultEngine) RenameSymbol(ws *types.Workspace, req types.RenameSymbol
 		return nil, fmt.Errorf("failed to analyze impact: %w", err)
 	}
 
+	// Strings naming the old identifier are left for the user to check
+	impact.PotentialIssues = append(impact.PotentialIssues, stringReferenceIssues(ws, req.SymbolName)...)
+
+	if req.CheckDependents {
+		symbols, err := operation.findTargetSymbols(ws, analysis.NewSymbolResolver(ws, e.logger))
+		if err != nil {
+			return nil, err
+		}
+		for _, symbol := range symbols {
+			pkg := ws.Packages[filepath.Dir(symbol.File)]
+			if pkg == nil {
+				continue
+			}
+			change := "rename to " + req.NewName
+			if req.KeepAlias {
+				change += ", keeping the old name as a deprecated alias"
+			}
+			report, err := e.checkDependents(ws, dependentTarget{
+				pkg:     pkg,
+				name:    symbol.Name,
+				method:  symbol.Kind == types.MethodSymbol,
+				change:  change,
+				newName: req.NewName,
+			}, req.DependentPatches)
+			if err != nil {
+				return nil, err
+			}
+			impact.Compatibility = append(impact.Compatibility, report)
+			if !req.KeepAlias { // The alias keeps the uses compiling
+				impact.PotentialIssues = append(impact.PotentialIssues, dependentIssues(report)...)
+			}
+		}
+	}
+
 	plan.Impact = impact
 	plan.Operations = []types.Operation{operation}
 
@@ -309,6 +534,9 @@ func (e *DefaultEngine) RenameInterfaceMethod(ws *types.Workspace, req types.Ren
 		return nil, fmt.Errorf("failed to analyze impact: %w", err)
 	}
 
+	// Strings naming the old identifier are left for the user to check
+	impact.PotentialIssues = append(impact.PotentialIssues, stringReferenceIssues(ws, req.MethodName)...)
+
 	plan.Impact = impact
 	plan.Operations = []types.Operation{operation}
 
@@ -320,17 +548,17 @@ func (e *DefaultEngine) RenameMethod(ws *types.Workspace, req types.RenameMethod
 	// Apply sensible defaults
 	req.UpdateImplementations = true
 
-	operation := &RenameMethodOperation{Request: req}
+	operation := &RenameMethodOperation{Request: req, Parser: e.parser}
 
 	// Validate the operation
 	if err := operation.Validate(ws); err != nil {
-		return nil, fmt.Errorf("rename method operation validation failed: %w", err)
+		return nil, fmt.Errorf("rename method operation validation failed: %w", withSuggestions(ws, err, req.MethodName))
 	}
 
 	// Execute the operation to generate the plan
 	plan, err := operation.Execute(ws)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate rename method plan: %w", err)
+		return nil, fmt.Errorf("failed to generate rename method plan: %w", withSuggestions(ws, err, req.MethodName))
 	}
 
 	// Analyze impact
@@ -339,6 +567,9 @@ func (e *DefaultEngine) RenameMethod(ws *types.Workspace, req types.RenameMethod
 		return nil, fmt.Errorf("failed to analyze impact: %w", err)
 	}
 
+	// Strings naming the old identifier are left for the user to check
+	impact.PotentialIssues = append(impact.PotentialIssues, stringReferenceIssues(ws, req.MethodName)...)
+
 	plan.Impact = impact
 	plan.Operations = []types.Operation{operation}
 
@@ -361,6 +592,7 @@ func (e *DefaultEngine) ExtractMethod(ws *types.Workspace, req types.ExtractMeth
 		TargetStruct:  req.TargetStruct,
 		Logger:        logger,
 		Parser:        e.parser,
+		Backend:       e.config.ChangeBackend,
 	}
 
 	// Validate the operation
@@ -373,6 +605,7 @@ func (e *DefaultEngine) ExtractMethod(ws *types.Workspace, req types.ExtractMeth
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate extract method plan: %w", err)
 	}
+	e.manageImports(ws, plan)
 
 	// Analyze impact
 	impact, err := e.analyzer.AnalyzeImpact(operation)
@@ -393,6 +626,7 @@ func (e *DefaultEngine) ExtractFunction(ws *types.Workspace, req types.ExtractFu
 		StartLine:       req.StartLine,
 		EndLine:         req.EndLine,
 		NewFunctionName: req.NewFunctionName,
+		Backend:         e.config.ChangeBackend,
 	}
 
 	// Validate the operation
@@ -405,6 +639,7 @@ func (e *DefaultEngine) ExtractFunction(ws *types.Workspace, req types.ExtractFu
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate extract function plan: %w", err)
 	}
+	e.manageImports(ws, plan)
 
 	// Analyze impact
 	impact, err := e.analyzer.AnalyzeImpact(operation)
@@ -420,11 +655,20 @@ func (e *DefaultEngine) ExtractFunction(ws *types.Workspace, req types.ExtractFu
 
 // ExtractInterface implements interface extraction from structs
 func (e *DefaultEngine) ExtractInterface(ws *types.Workspace, req types.ExtractInterfaceRequest) (*types.RefactoringPlan, error) {
+	if len(req.Adopt) > 0 {
+		// Uses of the adopted declarations are checked against the methods
+		for _, pkgPath := range slices.Sorted(maps.Keys(ws.Packages)) {
+			e.parser.EnsureTypeChecked(ws, ws.Packages[pkgPath])
+		}
+	}
+
 	operation := &ExtractInterfaceOperation{
 		SourceStruct:  req.SourceStruct,
 		InterfaceName: req.InterfaceName,
 		Methods:       req.Methods,
 		TargetPackage: req.TargetPackage,
+		TargetFile:    req.TargetFile,
+		Adopt:         req.Adopt,
 	}
 
 	// Validate the operation
@@ -437,6 +681,9 @@ func (e *DefaultEngine) ExtractInterface(ws *types.Workspace, req types.ExtractI
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate extract interface plan: %w", err)
 	}
+	if len(req.Adopt) > 0 {
+		e.manageImports(ws, plan)
+	}
 
 	// Analyze impact
 	impact, err := e.analyzer.AnalyzeImpact(operation)
@@ -458,6 +705,7 @@ func (e *DefaultEngine) ExtractVariable(ws *types.Workspace, req types.ExtractVa
 		EndLine:      req.EndLine,
 		VariableName: req.VariableName,
 		Expression:   req.Expression,
+		Backend:      e.config.ChangeBackend,
 	}
 
 	// Validate the operation
@@ -489,11 +737,12 @@ func (e *DefaultEngine) InlineMethod(ws *types.Workspace, req types.InlineMethod
 		MethodName:   req.MethodName,
 		SourceStruct: req.SourceStruct,
 		TargetFile:   req.TargetFile,
+		Backend:      e.config.ChangeBackend,
 	}
 
 	// Validate the operation
 	if err := operation.Validate(ws); err != nil {
-		return nil, fmt.Errorf("inline method operation validation failed: %w", err)
+		return nil, fmt.Errorf("inline method operation validation failed: %w", withSuggestions(ws, err, req.MethodName))
 	}
 
 	// Execute the operation to generate the plan
@@ -501,6 +750,7 @@ func (e *DefaultEngine) InlineMethod(ws *types.Workspace, req types.InlineMethod
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate inline method plan: %w", err)
 	}
+	e.manageImports(ws, plan)
 
 	// Analyze impact
 	impact, err := e.analyzer.AnalyzeImpact(operation)
@@ -521,6 +771,9 @@ func (e *DefaultEngine) InlineVariable(ws *types.Workspace, req types.InlineVari
 		SourceFile:   req.SourceFile,
 		StartLine:    1,    // Default - could be enhanced to specify line
 		EndLine:      1000, // Default - means all occurrences (large number)
+		Backend:      e.config.ChangeBackend,
+		Force:        req.Force,
+		Parser:       e.parser,
 	}
 
 	// Validate the operation
@@ -533,12 +786,14 @@ func (e *DefaultEngine) InlineVariable(ws *types.Workspace, req types.InlineVari
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate inline variable plan: %w", err)
 	}
+	e.manageImports(ws, plan)
 
 	// Analyze impact
 	impact, err := e.analyzer.AnalyzeImpact(operation)
 	if err != nil {
 		return nil, fmt.Errorf("failed to analyze impact: %w", err)
 	}
+	impact.PotentialIssues = append(impact.PotentialIssues, plan.Impact.PotentialIssues...)
 
 	plan.Impact = impact
 	plan.Operations = []types.Operation{operation}
@@ -552,24 +807,615 @@ func (e *DefaultEngine) InlineFunction(ws *types.Workspace, req types.InlineFunc
 		FunctionName: req.FunctionName,
 		SourceFile:   req.SourceFile,
 		TargetFiles:  req.TargetFiles,
+		Backend:      e.config.ChangeBackend,
+	}
+
+	// Validate the operation
+	if err := operation.Validate(ws); err != nil {
+		return nil, fmt.Errorf("inline function operation validation failed: %w", withSuggestions(ws, err, req.FunctionName))
+	}
+
+	// Execute the operation to generate the plan
+	plan, err := operation.Execute(ws)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate inline function plan: %w", withSuggestions(ws, err, req.FunctionName))
+	}
+	e.manageImports(ws, plan)
+
+	// Analyze impact
+	impact, err := e.analyzer.AnalyzeImpact(operation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze impact: %w", err)
+	}
+
+	plan.Impact = impact
+	plan.Operations = []types.Operation{operation}
+
+	return plan, nil
+}
+
+// SafeDelete implements safe deletion of symbols
+func (e *DefaultEngine) SafeDelete(ws *types.Workspace, req types.SafeDeleteRequest) (*types.RefactoringPlan, error) {
+	operation := &SafeDeleteOperation{
+		SymbolName: req.Symbol,
+		SourceFile: req.SourceFile,
+		Scope:      types.WorkspaceScope,
+		Force:      req.Force,
+		refs:       e.referenceFinder(ws),
+	}
+
+	if err := operation.Validate(ws); err != nil {
+		return nil, fmt.Errorf("safe delete operation validation failed: %w", withSuggestions(ws, err, req.Symbol))
+	}
+
+	plan, err := operation.Execute(ws)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate safe delete plan: %w", withSuggestions(ws, err, req.Symbol))
+	}
+
+	impact, err := e.analyzer.AnalyzeImpact(operation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze impact: %w", err)
+	}
+
+	plan.Impact = impact
+	plan.Operations = []types.Operation{operation}
+
+	return plan, nil
+}
+
+// EncapsulateField implements replacing direct access to a struct field with
+// generated accessors. Accesses that could not be rewritten are reported as
+// warnings in the plan's impact.
+func (e *DefaultEngine) EncapsulateField(ws *types.Workspace, req types.EncapsulateFieldRequest) (*types.RefactoringPlan, error) {
+	operation := &EncapsulateFieldOperation{Request: req, Parser: e.parser}
+
+	if err := operation.Validate(ws); err != nil {
+		return nil, fmt.Errorf("encapsulate field operation validation failed: %w", err)
+	}
+
+	plan, err := operation.Execute(ws)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate encapsulate field plan: %w", err)
+	}
+
+	impact, err := e.analyzer.AnalyzeImpact(operation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze impact: %w", err)
+	}
+	impact.AffectedFiles = plan.AffectedFiles
+	impact.PotentialIssues = append(impact.PotentialIssues, plan.Impact.PotentialIssues...)
+
+	plan.Impact = impact
+	plan.Operations = []types.Operation{operation}
+
+	return plan, nil
+}
+
+// ShrinkInterface implements removing the unused methods of an interface,
+// optionally splitting the rest into role interfaces. Implementer methods
+// that were kept because removing them isn't safe are reported in the
+// plan's impact.
+func (e *DefaultEngine) ShrinkInterface(ws *types.Workspace, req types.ShrinkInterfaceRequest) (*types.RefactoringPlan, error) {
+	operation := &ShrinkInterfaceOperation{Request: req, Parser: e.parser}
+
+	if err := operation.Validate(ws); err != nil {
+		return nil, fmt.Errorf("shrink interface operation validation failed: %w", withSuggestions(ws, err, req.InterfaceName))
+	}
+
+	plan, err := operation.Execute(ws)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate shrink interface plan: %w", err)
+	}
+
+	impact, err := e.analyzer.AnalyzeImpact(operation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze impact: %w", err)
+	}
+	impact.AffectedFiles = plan.AffectedFiles
+	impact.PotentialIssues = append(impact.PotentialIssues, plan.Impact.PotentialIssues...)
+
+	plan.Impact = impact
+	plan.Operations = []types.Operation{operation}
+
+	return plan, nil
+}
+
+// SegregateInterface implements splitting an interface into role interfaces
+// and narrowing its consumers to them. Consumers that keep the interface are
+// reported in the plan's impact.
+func (e *DefaultEngine) SegregateInterface(ws *types.Workspace, req types.SegregateInterfaceRequest) (*types.RefactoringPlan, error) {
+	operation := &SegregateInterfaceOperation{Request: req, Parser: e.parser}
+
+	if err := operation.Validate(ws); err != nil {
+		return nil, fmt.Errorf("segregate interface operation validation failed: %w", withSuggestions(ws, err, req.InterfaceName))
+	}
+
+	plan, err := operation.Execute(ws)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate segregate interface plan: %w", err)
+	}
+
+	impact, err := e.analyzer.AnalyzeImpact(operation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze impact: %w", err)
+	}
+	impact.AffectedFiles = plan.AffectedFiles
+	impact.PotentialIssues = append(impact.PotentialIssues, plan.Impact.PotentialIssues...)
+
+	plan.Impact = impact
+	plan.Operations = []types.Operation{operation}
+
+	return plan, nil
+}
+
+// BuildTags implements migrating and renaming build constraints across the
+// workspace. Files that could not be migrated are reported as warnings in
+// the plan's impact.
+func (e *DefaultEngine) BuildTags(ws *types.Workspace, req types.BuildTagsRequest) (*types.RefactoringPlan, error) {
+	operation := &BuildTagsOperation{Request: req}
+
+	if err := operation.Validate(ws); err != nil {
+		return nil, fmt.Errorf("build tags operation validation failed: %w", err)
+	}
+
+	plan, err := operation.Execute(ws)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate build tags plan: %w", err)
+	}
+
+	impact, err := e.analyzer.AnalyzeImpact(operation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze impact: %w", err)
+	}
+	impact.AffectedFiles = plan.AffectedFiles
+	impact.PotentialIssues = append(impact.PotentialIssues, plan.Impact.PotentialIssues...)
+
+	plan.Impact = impact
+	plan.Operations = []types.Operation{operation}
+
+	return plan, nil
+}
+
+// ThreadContext implements adding a ctx context.Context parameter to a
+// function and threading it through its callers. Every package is
+// type-checked first so that method calls are resolved.
+func (e *DefaultEngine) ThreadContext(ws *types.Workspace, req types.ThreadContextRequest) (*types.RefactoringPlan, error) {
+	for _, pkgPath := range slices.Sorted(maps.Keys(ws.Packages)) {
+		e.parser.EnsureTypeChecked(ws, ws.Packages[pkgPath])
+	}
+
+	operation := &ThreadContextOperation{Request: req, finder: e.referenceFinder(ws)}
+
+	if err := operation.Validate(ws); err != nil {
+		return nil, fmt.Errorf("thread context operation validation failed: %w", withSuggestions(ws, err, req.FunctionName))
+	}
+
+	plan, err := operation.Execute(ws)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate thread context plan: %w", withSuggestions(ws, err, req.Root))
+	}
+	e.manageImports(ws, plan)
+
+	impact, err := e.analyzer.AnalyzeImpact(operation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze impact: %w", err)
+	}
+	impact.AffectedFiles = plan.AffectedFiles
+	impact.PotentialIssues = append(impact.PotentialIssues, plan.Impact.PotentialIssues...)
+
+	plan.Impact = impact
+	plan.Operations = []types.Operation{operation}
+
+	return plan, nil
+}
+
+// ExtractTestHelper implements moving setup repeated across tests into a
+// helper function or TestMain.
+func (e *DefaultEngine) ExtractTestHelper(ws *types.Workspace, req types.ExtractTestHelperRequest) (*types.RefactoringPlan, error) {
+	operation := &ExtractTestHelperOperation{Request: req, Parser: e.parser}
+
+	if err := operation.Validate(ws); err != nil {
+		return nil, fmt.Errorf("extract test helper operation validation failed: %w", err)
+	}
+
+	plan, err := operation.Execute(ws)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate extract test helper plan: %w", err)
+	}
+
+	impact, err := e.analyzer.AnalyzeImpact(operation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze impact: %w", err)
+	}
+	impact.AffectedFiles = plan.AffectedFiles
+	impact.PotentialIssues = append(impact.PotentialIssues, plan.Impact.PotentialIssues...)
+
+	plan.Impact = impact
+	plan.Operations = []types.Operation{operation}
+
+	return plan, nil
+}
+
+// ExtractPipelineStages implements moving the stages of a long loop body
+// into functions
+func (e *DefaultEngine) ExtractPipelineStages(ws *types.Workspace, req types.ExtractPipelineStagesRequest) (*types.RefactoringPlan, error) {
+	operation := &ExtractPipelineStagesOperation{Request: req, Parser: e.parser}
+
+	if err := operation.Validate(ws); err != nil {
+		return nil, fmt.Errorf("extract pipeline stages operation validation failed: %w", err)
+	}
+
+	plan, err := operation.Execute(ws)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate extract pipeline stages plan: %w", err)
+	}
+
+	impact, err := e.analyzer.AnalyzeImpact(operation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze impact: %w", err)
+	}
+	impact.AffectedFiles = plan.AffectedFiles
+	impact.PotentialIssues = append(impact.PotentialIssues, plan.Impact.PotentialIssues...)
+
+	plan.Impact = impact
+	plan.Operations = []types.Operation{operation}
+
+	return plan, nil
+}
+
+// ConvertTypeAlias implements turning a type alias into a defined type and
+// back. Uses the conversion may break are reported in the plan's impact.
+func (e *DefaultEngine) ConvertTypeAlias(ws *types.Workspace, req types.ConvertTypeAliasRequest) (*types.RefactoringPlan, error) {
+	operation := &ConvertTypeAliasOperation{Request: req, Parser: e.parser}
+
+	if err := operation.Validate(ws); err != nil {
+		return nil, fmt.Errorf("convert type alias operation validation failed: %w", withSuggestions(ws, err, req.TypeName))
+	}
+
+	plan, err := operation.Execute(ws)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate convert type alias plan: %w", err)
+	}
+
+	impact, err := e.analyzer.AnalyzeImpact(operation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze impact: %w", err)
+	}
+	impact.AffectedFiles = plan.AffectedFiles
+	impact.PotentialIssues = append(impact.PotentialIssues, plan.Impact.PotentialIssues...)
+
+	plan.Impact = impact
+	plan.Operations = []types.Operation{operation}
+
+	return plan, nil
+}
+
+// IntroduceType implements declaring a named type for a recurring primitive
+// and retyping the chosen declarations to it. Every package is type-checked
+// first so that the values written to them everywhere are converted.
+func (e *DefaultEngine) IntroduceType(ws *types.Workspace, req types.IntroduceTypeRequest) (*types.RefactoringPlan, error) {
+	for _, pkgPath := range slices.Sorted(maps.Keys(ws.Packages)) {
+		e.parser.EnsureTypeChecked(ws, ws.Packages[pkgPath])
+	}
+
+	operation := &IntroduceTypeOperation{Request: req, Parser: e.parser}
+
+	if err := operation.Validate(ws); err != nil {
+		return nil, fmt.Errorf("introduce type operation validation failed: %w", err)
+	}
+
+	plan, err := operation.Execute(ws)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate introduce type plan: %w", err)
+	}
+	e.manageImports(ws, plan, packagePathToImportPath(ws, req.Package))
+
+	impact, err := e.analyzer.AnalyzeImpact(operation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze impact: %w", err)
+	}
+	impact.AffectedFiles = plan.AffectedFiles
+	impact.PotentialIssues = append(impact.PotentialIssues, plan.Impact.PotentialIssues...)
+
+	plan.Impact = impact
+	plan.Operations = []types.Operation{operation}
+
+	return plan, nil
+}
+
+// GenerateMock implements writing a test double for an interface
+func (e *DefaultEngine) GenerateMock(ws *types.Workspace, req types.GenerateMockRequest) (*types.RefactoringPlan, error) {
+	operation := &GenerateMockOperation{Request: req, Parser: e.parser}
+
+	if err := operation.Validate(ws); err != nil {
+		return nil, fmt.Errorf("generate mock operation validation failed: %w", withSuggestions(ws, err, req.InterfaceName))
+	}
+
+	plan, err := operation.Execute(ws)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate mock plan: %w", err)
+	}
+
+	impact, err := e.analyzer.AnalyzeImpact(operation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze impact: %w", err)
+	}
+	impact.AffectedFiles = plan.AffectedFiles
+
+	plan.Impact = impact
+	plan.Operations = []types.Operation{operation}
+
+	return plan, nil
+}
+
+// UpdateMocks implements regenerating the mocks generate_mock wrote. Mocks
+// of interfaces that no longer exist are reported as warnings.
+func (e *DefaultEngine) UpdateMocks(ws *types.Workspace, req types.UpdateMocksRequest) (*types.RefactoringPlan, error) {
+	operation := &UpdateMocksOperation{Request: req, Parser: e.parser}
+
+	if err := operation.Validate(ws); err != nil {
+		return nil, fmt.Errorf("update mocks operation validation failed: %w", err)
+	}
+
+	plan, err := operation.Execute(ws)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate update mocks plan: %w", err)
+	}
+
+	impact, err := e.analyzer.AnalyzeImpact(operation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze impact: %w", err)
+	}
+	impact.AffectedFiles = plan.AffectedFiles
+	impact.PotentialIssues = append(impact.PotentialIssues, plan.Impact.PotentialIssues...)
+
+	plan.Impact = impact
+	plan.Operations = []types.Operation{operation}
+
+	return plan, nil
+}
+
+// FixMagicNumbers implements replacing the literals the magicnumber
+// analyzer reports with named constants
+func (e *DefaultEngine) FixMagicNumbers(ws *types.Workspace, req types.FixMagicNumbersRequest) (*types.RefactoringPlan, error) {
+	operation := &FixMagicNumbersOperation{Request: req}
+
+	if err := operation.Validate(ws); err != nil {
+		return nil, fmt.Errorf("fix magic numbers operation validation failed: %w", err)
+	}
+
+	plan, err := operation.Execute(ws)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate fix magic numbers plan: %w", err)
+	}
+
+	impact, err := e.analyzer.AnalyzeImpact(operation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze impact: %w", err)
+	}
+	impact.AffectedFiles = plan.AffectedFiles
+
+	plan.Impact = impact
+	plan.Operations = []types.Operation{operation}
+
+	return plan, nil
+}
+
+// OrganizeFile implements reordering the declarations of a file by a
+// policy, optionally splitting an oversized file by receiver type
+func (e *DefaultEngine) OrganizeFile(ws *types.Workspace, req types.OrganizeFileRequest) (*types.RefactoringPlan, error) {
+	operation := &OrganizeFileOperation{Request: req}
+
+	if err := operation.Validate(ws); err != nil {
+		return nil, fmt.Errorf("organize file operation validation failed: %w", err)
+	}
+
+	plan, err := operation.Execute(ws)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate organize file plan: %w", err)
+	}
+
+	impact, err := e.analyzer.AnalyzeImpact(operation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze impact: %w", err)
+	}
+	impact.AffectedFiles = plan.AffectedFiles
+
+	plan.Impact = impact
+	plan.Operations = []types.Operation{operation}
+
+	return plan, nil
+}
+
+// SplitFile implements moving groups of the declarations of an oversized
+// file into new files of the same package
+func (e *DefaultEngine) SplitFile(ws *types.Workspace, req types.SplitFileRequest) (*types.RefactoringPlan, error) {
+	operation := &SplitFileOperation{Request: req, Parser: e.parser}
+
+	if err := operation.Validate(ws); err != nil {
+		return nil, fmt.Errorf("split file operation validation failed: %w", err)
+	}
+
+	plan, err := operation.Execute(ws)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate split file plan: %w", err)
+	}
+
+	impact, err := e.analyzer.AnalyzeImpact(operation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze impact: %w", err)
+	}
+	impact.AffectedFiles = plan.AffectedFiles
+
+	plan.Impact = impact
+	plan.Operations = []types.Operation{operation}
+
+	return plan, nil
+}
+
+// FixErrorReturns implements moving error results last and renaming error
+// variables to err
+func (e *DefaultEngine) FixErrorReturns(ws *types.Workspace, req types.FixErrorReturnsRequest) (*types.RefactoringPlan, error) {
+	operation := &FixErrorReturnsOperation{Request: req, Parser: e.parser}
+
+	if err := operation.Validate(ws); err != nil {
+		return nil, fmt.Errorf("fix error returns operation validation failed: %w", err)
+	}
+
+	plan, err := operation.Execute(ws)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate fix error returns plan: %w", err)
 	}
 
-	// Validate the operation
+	impact, err := e.analyzer.AnalyzeImpact(operation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze impact: %w", err)
+	}
+	impact.AffectedFiles = plan.AffectedFiles
+	impact.PotentialIssues = append(impact.PotentialIssues, plan.Impact.PotentialIssues...)
+
+	plan.Impact = impact
+	plan.Operations = []types.Operation{operation}
+
+	return plan, nil
+}
+
+// FixNaming implements renaming the names the naming analyzer reports in
+// one plan. Renames that can't be made are reported in the plan's impact.
+func (e *DefaultEngine) FixNaming(ws *types.Workspace, req types.FixNamingRequest) (*types.RefactoringPlan, error) {
+	operation := &FixNamingOperation{Request: req, Parser: e.parser}
+
+	if err := operation.Validate(ws); err != nil {
+		return nil, fmt.Errorf("fix naming operation validation failed: %w", err)
+	}
+
+	plan, err := operation.Execute(ws)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate fix naming plan: %w", err)
+	}
+
+	impact, err := e.analyzer.AnalyzeImpact(operation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze impact: %w", err)
+	}
+	impact.AffectedFiles = plan.AffectedFiles
+	impact.PotentialIssues = append(impact.PotentialIssues, plan.Impact.PotentialIssues...)
+
+	plan.Impact = impact
+	plan.Operations = []types.Operation{operation}
+
+	return plan, nil
+}
+
+// BulkRename implements renaming many symbols and methods in one plan.
+// Renames that can't be made are all reported as errors in the plan's
+// impact, which keep it from being applied.
+func (e *DefaultEngine) BulkRename(ws *types.Workspace, req types.BulkRenameRequest) (*types.RefactoringPlan, error) {
+	operation := &BulkRenameOperation{Request: req, Parser: e.parser}
+
+	if err := operation.Validate(ws); err != nil {
+		return nil, fmt.Errorf("bulk rename operation validation failed: %w", err)
+	}
+
+	plan, err := operation.Execute(ws)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate bulk rename plan: %w", err)
+	}
+
+	impact, err := e.analyzer.AnalyzeImpact(operation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze impact: %w", err)
+	}
+	impact.AffectedFiles = plan.AffectedFiles
+	impact.PotentialIssues = append(impact.PotentialIssues, plan.Impact.PotentialIssues...)
+
+	plan.Impact = impact
+	plan.Operations = []types.Operation{operation}
+
+	return plan, nil
+}
+
+// Rewrite implements replacing the expressions matching a pattern
+// throughout the workspace, adding the imports the replacements need.
+func (e *DefaultEngine) Rewrite(ws *types.Workspace, req types.RewriteRequest) (*types.RefactoringPlan, error) {
+	operation := &RewriteOperation{Request: req, Parser: e.parser}
+
+	if err := operation.Validate(ws); err != nil {
+		return nil, fmt.Errorf("rewrite operation validation failed: %w", err)
+	}
+
+	plan, err := operation.Execute(ws)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate rewrite plan: %w", err)
+	}
+	e.manageRewriteImports(ws, plan, operation.importPaths(), operation.names)
+
+	impact, err := e.analyzer.AnalyzeImpact(operation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze impact: %w", err)
+	}
+	impact.AffectedFiles = plan.AffectedFiles
+	impact.AffectedPackages = plan.Impact.AffectedPackages
+	impact.PotentialIssues = append(impact.PotentialIssues, plan.Impact.PotentialIssues...)
+
+	plan.Impact = impact
+	plan.Operations = []types.Operation{operation}
+
+	return plan, nil
+}
+
+// Migrate implements applying the rules of a migration pack as one plan,
+// adding the imports the replacements need.
+func (e *DefaultEngine) Migrate(ws *types.Workspace, req types.MigrateRequest) (*types.RefactoringPlan, error) {
+	operation := &MigrateOperation{Request: req, Parser: e.parser}
+
+	if err := operation.Validate(ws); err != nil {
+		return nil, fmt.Errorf("migrate operation validation failed: %w", err)
+	}
+
+	plan, err := operation.Execute(ws)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate migration plan: %w", err)
+	}
+	e.manageRewriteImports(ws, plan, operation.importPaths(), operation.names)
+
+	impact, err := e.analyzer.AnalyzeImpact(operation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze impact: %w", err)
+	}
+	impact.AffectedFiles = plan.AffectedFiles
+	impact.AffectedPackages = plan.Impact.AffectedPackages
+	impact.PotentialIssues = append(impact.PotentialIssues, plan.Impact.PotentialIssues...)
+
+	plan.Impact = impact
+	plan.Operations = []types.Operation{operation}
+
+	return plan, nil
+}
+
+// ConvertReceivers implements giving the methods of a type the same
+// receiver form. Uses the conversion breaks or changes the meaning of are
+// reported in the plan's impact.
+func (e *DefaultEngine) ConvertReceivers(ws *types.Workspace, req types.ConvertReceiversRequest) (*types.RefactoringPlan, error) {
+	operation := &ConvertReceiversOperation{Request: req, Parser: e.parser}
+
 	if err := operation.Validate(ws); err != nil {
-		return nil, fmt.Errorf("inline function operation validation failed: %w", err)
+		return nil, fmt.Errorf("convert receivers operation validation failed: %w", withSuggestions(ws, err, req.TypeName))
 	}
 
-	// Execute the operation to generate the plan
 	plan, err := operation.Execute(ws)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate inline function plan: %w", err)
+		return nil, fmt.Errorf("failed to generate convert receivers plan: %w", err)
 	}
 
-	// Analyze impact
 	impact, err := e.analyzer.AnalyzeImpact(operation)
 	if err != nil {
 		return nil, fmt.Errorf("failed to analyze impact: %w", err)
 	}
+	impact.AffectedFiles = plan.AffectedFiles
+	impact.AffectedPackages = plan.Impact.AffectedPackages
+	impact.PotentialIssues = append(impact.PotentialIssues, plan.Impact.PotentialIssues...)
 
 	plan.Impact = impact
 	plan.Operations = []types.Operation{operation}
@@ -577,28 +1423,28 @@ func (e *DefaultEngine) InlineFunction(ws *types.Workspace, req types.InlineFunc
 	return plan, nil
 }
 
-// SafeDelete implements safe deletion of symbols
-func (e *DefaultEngine) SafeDelete(ws *types.Workspace, req types.SafeDeleteRequest) (*types.RefactoringPlan, error) {
-	operation := &SafeDeleteOperation{
-		SymbolName: req.Symbol,
-		SourceFile: req.SourceFile,
-		Scope:      types.WorkspaceScope,
-		Force:      req.Force,
-	}
+// AddField implements adding a field to a struct type, updating its
+// positional literals and, when asked, its constructors.
+func (e *DefaultEngine) AddField(ws *types.Workspace, req types.AddFieldRequest) (*types.RefactoringPlan, error) {
+	operation := &AddFieldOperation{Request: req, Parser: e.parser}
 
 	if err := operation.Validate(ws); err != nil {
-		return nil, fmt.Errorf("safe delete operation validation failed: %w", err)
+		return nil, fmt.Errorf("add field operation validation failed: %w", withSuggestions(ws, err, req.TypeName))
 	}
 
 	plan, err := operation.Execute(ws)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate safe delete plan: %w", err)
+		return nil, fmt.Errorf("failed to generate add field plan: %w", err)
 	}
+	e.manageImports(ws, plan)
 
 	impact, err := e.analyzer.AnalyzeImpact(operation)
 	if err != nil {
 		return nil, fmt.Errorf("failed to analyze impact: %w", err)
 	}
+	impact.AffectedFiles = plan.AffectedFiles
+	impact.AffectedPackages = plan.Impact.AffectedPackages
+	impact.PotentialIssues = append(impact.PotentialIssues, plan.Impact.PotentialIssues...)
 
 	plan.Impact = impact
 	plan.Operations = []types.Operation{operation}
@@ -622,23 +1468,47 @@ func (e *DefaultEngine) ChangeSignature(ws *types.Workspace, req ChangeSignature
 		PropagateToInterface: req.PropagateToInterface,
 		DefaultValue:         req.DefaultValue,
 		NewParamPosition:     newParamPos,
+		ReturnOrder:          req.ReturnOrder,
 		CachedIndex:          req.CachedIndex,
 		Logger:               e.logger,
 	}
 
 	if err := operation.Validate(ws); err != nil {
-		return nil, fmt.Errorf("change signature operation validation failed: %w", err)
+		return nil, fmt.Errorf("change signature operation validation failed: %w", withSuggestions(ws, err, req.FunctionName))
 	}
 
 	plan, err := operation.Execute(ws)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate change signature plan: %w", err)
+		return nil, fmt.Errorf("failed to generate change signature plan: %w", withSuggestions(ws, err, req.FunctionName))
 	}
+	e.manageImports(ws, plan)
 
 	impact, err := e.analyzer.AnalyzeImpact(operation)
 	if err != nil {
 		return nil, fmt.Errorf("failed to analyze impact: %w", err)
 	}
+	impact.PotentialIssues = append(impact.PotentialIssues, operation.reorderIssues...)
+
+	if req.CheckDependents {
+		if pkg := ws.Packages[filepath.Dir(operation.SourceFile)]; pkg != nil {
+			// Methods may be named Type.Method
+			_, name, qualified := strings.Cut(req.FunctionName, ".")
+			if !qualified {
+				name = req.FunctionName
+			}
+			report, err := e.checkDependents(ws, dependentTarget{
+				pkg:    pkg,
+				name:   name,
+				method: qualified || isMethodIn(pkg, operation.SourceFile, name),
+				change: "signature change",
+			}, false)
+			if err != nil {
+				return nil, err
+			}
+			impact.Compatibility = append(impact.Compatibility, report)
+			impact.PotentialIssues = append(impact.PotentialIssues, dependentIssues(report)...)
+		}
+	}
 
 	plan.Impact = impact
 	plan.Operations = []types.Operation{operation}
@@ -739,88 +1609,412 @@ func (e *DefaultEngine) AnalyzeImpact(ws *types.Workspace, op types.Operation) (
 	return e.analyzer.AnalyzeImpact(op)
 }
 
+// InterfaceUsage reports which methods of each workspace interface are used.
+// Every package is type-checked first so that no call site is missed.
+func (e *DefaultEngine) InterfaceUsage(ws *types.Workspace) ([]*analysis.InterfaceUsage, error) {
+	if ws == nil {
+		return nil, fmt.Errorf("workspace not loaded")
+	}
+	for _, pkgPath := range slices.Sorted(maps.Keys(ws.Packages)) {
+		e.parser.EnsureTypeChecked(ws, ws.Packages[pkgPath])
+	}
+	return analysis.AnalyzeInterfaceUsage(ws), nil
+}
+
+// TypeHierarchy reports the embedding graph around the requested type and
+// the interfaces it satisfies or nearly satisfies. Every package is
+// type-checked first so that all embedders and interfaces are seen.
+func (e *DefaultEngine) TypeHierarchy(ws *types.Workspace, req types.TypeHierarchyRequest) (*analysis.TypeHierarchy, error) {
+	if ws == nil {
+		return nil, fmt.Errorf("workspace not loaded")
+	}
+	pkg, ok := ws.Packages[req.Package]
+	if !ok {
+		return nil, &types.RefactorError{
+			Type:    types.SymbolNotFound,
+			Message: fmt.Sprintf("package not found: %s", req.Package),
+		}
+	}
+	if req.MaxMissing < 0 {
+		return nil, &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("max_missing must not be negative, got %d", req.MaxMissing),
+		}
+	}
+	for _, pkgPath := range slices.Sorted(maps.Keys(ws.Packages)) {
+		e.parser.EnsureTypeChecked(ws, ws.Packages[pkgPath])
+	}
+
+	h, err := analysis.AnalyzeTypeHierarchy(ws, pkg, req.TypeName, req.MaxMissing)
+	if err != nil {
+		return nil, withSuggestions(ws, err, req.TypeName)
+	}
+	return h, nil
+}
+
+// CallGraph returns the static call graph of the workspace, or the part of
+// it reachable from the functions of the requested root package.
+func (e *DefaultEngine) CallGraph(ws *types.Workspace, req types.CallGraphRequest) (*analysis.CallGraph, error) {
+	if ws == nil {
+		return nil, fmt.Errorf("workspace not loaded")
+	}
+	if req.Depth < 0 {
+		return nil, &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("depth must not be negative, got %d", req.Depth),
+		}
+	}
+	var root *types.Package
+	if req.Root != "" {
+		var ok bool
+		if root, ok = ws.Packages[req.Root]; !ok {
+			return nil, &types.RefactorError{
+				Type:    types.SymbolNotFound,
+				Message: fmt.Sprintf("package not found: %s", req.Root),
+			}
+		}
+	}
+
+	g := e.callGraph(ws)
+	if root != nil {
+		g = g.Reachable(func(n *analysis.CallNode) bool { return n.Package == root.ImportPath }, req.Depth)
+	}
+	return g, nil
+}
+
+// callGraph builds the call graph of ws. Every package is type-checked
+// first so that no call site is missed.
+func (e *DefaultEngine) callGraph(ws *types.Workspace) *analysis.CallGraph {
+	for _, pkgPath := range slices.Sorted(maps.Keys(ws.Packages)) {
+		e.parser.EnsureTypeChecked(ws, ws.Packages[pkgPath])
+	}
+	return analysis.NewCallGraphAnalyzer(ws, e.logger).BuildCallGraph()
+}
+
+// CheckArchitecture reports the imports that break the requested
+// architecture rules, in every package or only the requested one.
+func (e *DefaultEngine) CheckArchitecture(ws *types.Workspace, req types.CheckArchitectureRequest) ([]*analysis.ArchViolation, error) {
+	if ws == nil {
+		return nil, fmt.Errorf("workspace not loaded")
+	}
+	for _, r := range req.Rules {
+		if err := analysis.ValidateImportRule(r); err != nil {
+			return nil, &types.RefactorError{Type: types.InvalidOperation, Message: err.Error()}
+		}
+	}
+	if req.Package != "" {
+		if _, ok := ws.Packages[req.Package]; !ok {
+			return nil, &types.RefactorError{
+				Type:    types.SymbolNotFound,
+				Message: fmt.Sprintf("package not found: %s", req.Package),
+			}
+		}
+	}
+
+	violations, err := analysis.CheckImportRules(ws, req.Rules)
+	if err != nil {
+		return nil, err
+	}
+	if req.Package != "" {
+		violations = slices.DeleteFunc(violations, func(v *analysis.ArchViolation) bool {
+			return filepath.Dir(v.File) != req.Package
+		})
+	}
+	return violations, nil
+}
+
+// InferLayers proposes an architectural layer for every package from the
+// workspace import graph, to be reviewed and passed to OrganizeByLayers as
+// OrganizeByLayersRequest.Layers.
+func (e *DefaultEngine) InferLayers(ws *types.Workspace) ([]*analysis.LayerAssignment, error) {
+	if ws == nil {
+		return nil, fmt.Errorf("workspace not loaded")
+	}
+	return analysis.InferLayers(ws), nil
+}
+
+// SuggestHome ranks the packages the requested symbol could be moved to.
+// Without a package, the symbol must be declared in only one. Every package
+// is type-checked first so that references are complete.
+func (e *DefaultEngine) SuggestHome(ws *types.Workspace, req types.SuggestHomeRequest) ([]*analysis.HomeCandidate, error) {
+	if ws == nil {
+		return nil, fmt.Errorf("workspace not loaded")
+	}
+	if req.Package == "" {
+		declaring := packagesDeclaring(ws, req.SymbolName)
+		switch len(declaring) {
+		case 0:
+			return nil, withSuggestions(ws, &types.RefactorError{
+				Type:    types.SymbolNotFound,
+				Message: fmt.Sprintf("no package declares %s", req.SymbolName),
+			}, req.SymbolName)
+		case 1:
+			req.Package = declaring[0].Value
+		default:
+			return nil, &types.RefactorError{
+				Type:        types.InvalidOperation,
+				Message:     fmt.Sprintf("%s is declared in several packages; name the package", req.SymbolName),
+				Suggestions: declaring,
+			}
+		}
+	}
+	pkg, ok := ws.Packages[req.Package]
+	if !ok {
+		return nil, &types.RefactorError{
+			Type:    types.SymbolNotFound,
+			Message: fmt.Sprintf("package not found: %s", req.Package),
+		}
+	}
+	for _, pkgPath := range slices.Sorted(maps.Keys(ws.Packages)) {
+		e.parser.EnsureTypeChecked(ws, ws.Packages[pkgPath])
+	}
+
+	finder := e.referenceFinder(ws)
+	symbol, err := finder.Resolver().ResolveSymbol(pkg, req.SymbolName)
+	if err != nil {
+		return nil, withSuggestions(ws, err, req.SymbolName)
+	}
+	if symbol.Kind == types.MethodSymbol {
+		return nil, &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("%s is a method; methods live with their receiver type", req.SymbolName),
+		}
+	}
+
+	return analysis.SuggestHome(ws, finder, symbol, analysis.LayerRules{
+		Domain:         req.DomainLayer,
+		Infrastructure: req.InfrastructureLayer,
+		Application:    req.ApplicationLayer,
+	})
+}
+
 // ValidateRefactoring validates a complete refactoring plan
 func (e *DefaultEngine) ValidateRefactoring(plan *types.RefactoringPlan) error {
-	return e.validator.ValidatePlanWithConfig(plan, e.config)
+	if err := e.checkGeneratedFiles(plan); err != nil {
+		return err
+	}
+	return e.validator.ValidatePlanWithPolicy(plan, e.breakingPolicy(plan))
+}
+
+// checkGeneratedFiles rejects plans that would edit generated files unless
+// AllowGenerated is set. This is enforced independently of AllowBreaking since
+// edits to generated code are silently lost on the next regeneration.
+func (e *DefaultEngine) checkGeneratedFiles(plan *types.RefactoringPlan) error {
+	if plan == nil || (e.config != nil && e.config.AllowGenerated) {
+		return nil
+	}
+	detector := e.generated
+	if detector == nil {
+		detector = e.generatedDetector("")
+	}
+
+	seen := make(map[string]bool)
+	var generated []string
+	for _, change := range plan.Changes {
+		if seen[change.File] {
+			continue
+		}
+		seen[change.File] = true
+		if generatesFile(plan, change.File) {
+			continue // The plan is the file's generator
+		}
+		if detector.IsGenerated(change.File) {
+			generated = append(generated, change.File)
+		}
+	}
+	if len(generated) == 0 {
+		return nil
+	}
+	sort.Strings(generated)
+	return &types.RefactorError{
+		Type: types.GeneratedFileViolation,
+		Message: fmt.Sprintf("plan would edit %d generated file(s): %s; regenerate them from their sources instead, or set AllowGenerated to override",
+			len(generated), strings.Join(generated, ", ")),
+		File: generated[0],
+	}
+}
+
+// generatedDetector returns the generated file detector for the workspace
+// at root, with the configured directories and mock suffixes.
+func (e *DefaultEngine) generatedDetector(root string) *analysis.GeneratedFileDetector {
+	if e.config == nil {
+		return analysis.NewGeneratedFileDetector(root)
+	}
+	return analysis.NewGeneratedFileDetector(root, e.config.GeneratedDirs...).WithMockSuffixes(e.config.MockSuffixes)
 }
 
 // ExecutePlan applies a refactoring plan to the workspace
 func (e *DefaultEngine) ExecutePlan(plan *types.RefactoringPlan) error {
-	// Final validation before execution
-	if err := e.ValidateRefactoring(plan); err != nil {
-		return err // Return the validation error directly to preserve its type
-	}
+	return e.ExecutePlanContext(context.Background(), plan)
+}
 
-	// Check for critical issues
-	for _, issue := range plan.Impact.PotentialIssues {
-		if issue.Severity == types.Error {
-			return &types.RefactorError{
-				Type:    types.InvalidOperation,
-				Message: fmt.Sprintf("cannot execute plan due to critical issue: %s", issue.Description),
-				File:    issue.File,
-				Line:    issue.Line,
-			}
+// ExecutePlanContext is like ExecutePlan but returns ctx.Err() without
+// touching disk if ctx is canceled before changes are applied. Once writing
+// has started, all changes are applied; cancellation then only aborts the
+// post-apply compilation check.
+//
+// With test verification configured, the selected tests run before the
+// plan is applied and again after it; when tests fail that passed before,
+// the plan is rolled back and the failures returned.
+func (e *DefaultEngine) ExecutePlanContext(ctx context.Context, plan *types.RefactoringPlan) error {
+	plan = e.confidentChanges(plan)
+	if err := e.checkPlan(plan); err != nil {
+		return err
+	}
+
+	scope := e.testVerification()
+	var baseline map[testFailure][]string
+	if scope != VerifyTestsOff && len(plan.Changes) > 0 {
+		var err error
+		if baseline, err = e.failingTests(ctx, e.testPatterns(scope, plan)); err != nil {
+			return fmt.Errorf("failed to run tests before applying the plan: %w", err)
 		}
 	}
 
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	// Apply changes
 	if len(plan.Changes) > 0 {
+		journal := e.Journal()
+		var entry *history.Entry
+		if journal != nil || scope != VerifyTestsOff {
+			var err error
+			if entry, err = history.Open(e.root).Snapshot(planDescription(plan), changedFiles(plan)); err != nil {
+				return fmt.Errorf("failed to snapshot files for history: %w", err)
+			}
+		}
+
 		err := e.serializer.ApplyChanges(nil, plan.Changes) // workspace will be inferred from changes
 		if err != nil {
 			return fmt.Errorf("failed to apply changes: %w", err)
 		}
-		
+
+		if journal != nil {
+			if err := journal.Commit(entry); err != nil {
+				e.logger.Error("failed to record plan in history", "err", err)
+			}
+		}
+
 		// Validate that the refactored code compiles (if not skipped)
 		if !e.shouldSkipCompilation() {
-			if err := e.validateCompilation(plan.AffectedFiles); err != nil {
+			if err := e.validateCompilation(ctx, plan.AffectedFiles); err != nil {
+				// Verifying tests promises the plan is kept only if it works.
+				if scope != VerifyTestsOff {
+					if rerr := rollbackPlan(journal, entry); rerr != nil {
+						return fmt.Errorf("refactored code does not compile and rolling it back failed: %w", rerr)
+					}
+					return fmt.Errorf("refactored code does not compile and was rolled back: %w", err)
+				}
 				return fmt.Errorf("refactored code does not compile: %w", err)
 			}
 		}
+
+		if scope != VerifyTestsOff {
+			after, err := e.failingTests(ctx, e.testPatterns(scope, plan))
+			if err != nil {
+				return fmt.Errorf("failed to run tests after applying the plan: %w", err)
+			}
+			if failures := newTestFailures(baseline, after); len(failures) > 0 {
+				if err := rollbackPlan(journal, entry); err != nil {
+					return fmt.Errorf("plan broke tests and rolling it back failed: %w", err)
+				}
+				return testFailureError(failures, after)
+			}
+		}
 	}
 
 	return nil
 }
 
+// checkPlan validates a plan before it is applied and rejects plans with
+// critical issues.
+func (e *DefaultEngine) checkPlan(plan *types.RefactoringPlan) error {
+	if err := e.ValidateRefactoring(plan); err != nil {
+		return err // Return the validation error directly to preserve its type
+	}
+	if plan.Impact == nil {
+		return nil
+	}
+	for _, issue := range plan.Impact.PotentialIssues {
+		if issue.Severity == types.Error {
+			return &types.RefactorError{
+				Type:    types.InvalidOperation,
+				Message: fmt.Sprintf("cannot execute plan due to critical issue: %s", issue.Description),
+				File:    issue.File,
+				Line:    issue.Line,
+			}
+		}
+	}
+	return nil
+}
+
+// Journal returns the history journal of the loaded workspace, or nil when
+// journaling is disabled or no workspace has been loaded.
+func (e *DefaultEngine) Journal() *history.Journal {
+	if e.config == nil || !e.config.Journal || e.root == "" {
+		return nil
+	}
+	return history.Open(e.root)
+}
+
+// planDescription summarizes the operations of a plan for the history journal.
+func planDescription(plan *types.RefactoringPlan) string {
+	var descs []string
+	for _, op := range plan.Operations {
+		descs = append(descs, op.Description())
+	}
+	if len(descs) == 0 {
+		return fmt.Sprintf("%d changes", len(plan.Changes))
+	}
+	return strings.Join(descs, "; ")
+}
+
+// changedFiles returns the files a plan writes, in order of first appearance.
+func changedFiles(plan *types.RefactoringPlan) []string {
+	var files []string
+	for _, change := range plan.Changes {
+		if !slices.Contains(files, change.File) {
+			files = append(files, change.File)
+		}
+	}
+	return files
+}
+
 // shouldSkipCompilation returns true if compilation validation should be skipped
 func (e *DefaultEngine) shouldSkipCompilation() bool {
 	return e.config != nil && e.config.SkipCompilation
 }
 
-// validateCompilation checks that the modified files still compile
-func (e *DefaultEngine) validateCompilation(affectedFiles []string) error {
-	if len(affectedFiles) == 0 {
-		return nil
-	}
-	
-	// Get unique directories that need compilation checking
-	dirsToCheck := make(map[string]bool)
+// validateCompilation checks that the packages of the modified files still
+// build, with the configured BuildValidator.
+func (e *DefaultEngine) validateCompilation(ctx context.Context, affectedFiles []string) error {
+	var dirs []string
 	for _, file := range affectedFiles {
+		if !strings.HasSuffix(file, ".go") {
+			continue
+		}
 		dir := filepath.Dir(file)
-		dirsToCheck[dir] = true
-	}
-	
-	// Check compilation for each affected directory
-	for dir := range dirsToCheck {
-		if err := e.checkDirectoryCompilation(dir); err != nil {
-			return fmt.Errorf("compilation failed in %s: %w", dir, err)
+		if slices.Contains(dirs, dir) {
+			continue
+		}
+		// A package a plan moved away has nothing left to build.
+		if _, err := os.Stat(dir); err != nil {
+			continue
 		}
+		dirs = append(dirs, dir)
 	}
-	
-	return nil
-}
+	if len(dirs) == 0 {
+		return nil
+	}
+	slices.Sort(dirs)
 
-// checkDirectoryCompilation runs go build on a directory to check compilation
-func (e *DefaultEngine) checkDirectoryCompilation(dir string) error {
-	// Use go build to check compilation without creating binaries
-	cmd := exec.Command("go", "build", "-o", "/dev/null", ".")
-	cmd.Dir = dir
-	
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("go build failed: %s", string(output))
+	var validator BuildValidator = goValidator{"build", "-o", os.DevNull, "."}
+	if e.config != nil && e.config.Build != nil {
+		validator = e.config.Build
 	}
-	
-	return nil
+	return validator.Validate(ctx, dirs)
 }
 
 // PreviewPlan generates a preview of the changes without applying them
@@ -899,6 +2093,11 @@ func (e *DefaultEngine) findOperationConflicts(changes []types.Change) []string
 
 // MovePackage implements moving entire packages
 func (e *DefaultEngine) MovePackage(ws *types.Workspace, req types.MovePackageRequest) (*types.RefactoringPlan, error) {
+	return e.MovePackageContext(context.Background(), ws, req)
+}
+
+// MovePackageContext is like MovePackage but stops planning once ctx is canceled.
+func (e *DefaultEngine) MovePackageContext(ctx context.Context, ws *types.Workspace, req types.MovePackageRequest) (*types.RefactoringPlan, error) {
 	// Apply sensible defaults
 	req.CreateTarget = true
 	req.UpdateImports = true
@@ -910,12 +2109,18 @@ func (e *DefaultEngine) MovePackage(ws *types.Workspace, req types.MovePackageRe
 		return nil, fmt.Errorf("move package operation validation failed: %w", err)
 	}
 
+	e.report(types.ProgressEvent{Phase: types.PhasePlan, Message: operation.Description()})
+
 	// Execute the operation to generate the plan
-	plan, err := operation.Execute(ws)
+	plan, err := operation.ExecuteContext(ctx, ws)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate move package plan: %w", err)
 	}
 
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	// Analyze impact
 	impact, err := e.analyzer.AnalyzeImpact(operation)
 	if err != nil {
@@ -930,6 +2135,11 @@ func (e *DefaultEngine) MovePackage(ws *types.Workspace, req types.MovePackageRe
 
 // MoveDir implements moving directory structures
 func (e *DefaultEngine) MoveDir(ws *types.Workspace, req types.MoveDirRequest) (*types.RefactoringPlan, error) {
+	return e.MoveDirContext(context.Background(), ws, req)
+}
+
+// MoveDirContext is like MoveDir but stops planning once ctx is canceled.
+func (e *DefaultEngine) MoveDirContext(ctx context.Context, ws *types.Workspace, req types.MoveDirRequest) (*types.RefactoringPlan, error) {
 	// Apply sensible defaults
 	req.UpdateImports = true
 
@@ -940,12 +2150,18 @@ func (e *DefaultEngine) MoveDir(ws *types.Workspace, req types.MoveDirRequest) (
 		return nil, fmt.Errorf("move directory operation validation failed: %w", err)
 	}
 
+	e.report(types.ProgressEvent{Phase: types.PhasePlan, Message: operation.Description()})
+
 	// Execute the operation to generate the plan
-	plan, err := operation.Execute(ws)
+	plan, err := operation.ExecuteContext(ctx, ws)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate move directory plan: %w", err)
 	}
 
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	// Analyze impact
 	impact, err := e.analyzer.AnalyzeImpact(operation)
 	if err != nil {
@@ -960,23 +2176,34 @@ func (e *DefaultEngine) MoveDir(ws *types.Workspace, req types.MoveDirRequest) (
 
 // MovePackages implements moving multiple packages atomically
 func (e *DefaultEngine) MovePackages(ws *types.Workspace, req types.MovePackagesRequest) (*types.RefactoringPlan, error) {
+	return e.MovePackagesContext(context.Background(), ws, req)
+}
+
+// MovePackagesContext is like MovePackages but stops planning once ctx is canceled.
+func (e *DefaultEngine) MovePackagesContext(ctx context.Context, ws *types.Workspace, req types.MovePackagesRequest) (*types.RefactoringPlan, error) {
 	// Apply sensible defaults
 	req.CreateTargets = true
 	req.UpdateImports = true
 
-	operation := &MovePackagesOperation{Request: req}
+	operation := &MovePackagesOperation{Request: req, progress: e.progress}
 
 	// Validate the operation
 	if err := operation.Validate(ws); err != nil {
 		return nil, fmt.Errorf("move packages operation validation failed: %w", err)
 	}
 
+	e.report(types.ProgressEvent{Phase: types.PhasePlan, Message: operation.Description()})
+
 	// Execute the operation to generate the plan
-	plan, err := operation.Execute(ws)
+	plan, err := operation.ExecuteContext(ctx, ws)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate move packages plan: %w", err)
 	}
 
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	// Analyze impact
 	impact, err := e.analyzer.AnalyzeImpact(operation)
 	if err != nil {
@@ -989,6 +2216,31 @@ func (e *DefaultEngine) MovePackages(ws *types.Workspace, req types.MovePackages
 	return plan, nil
 }
 
+// MergePackages implements merging packages into an existing one
+func (e *DefaultEngine) MergePackages(ws *types.Workspace, req types.MergePackagesRequest) (*types.RefactoringPlan, error) {
+	operation := &MergePackagesOperation{Request: req}
+
+	if err := operation.Validate(ws); err != nil {
+		return nil, fmt.Errorf("merge packages operation validation failed: %w", err)
+	}
+
+	plan, err := operation.Execute(ws)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate merge packages plan: %w", err)
+	}
+
+	impact, err := e.analyzer.AnalyzeImpact(operation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze impact: %w", err)
+	}
+	impact.AffectedFiles = plan.AffectedFiles
+
+	plan.Impact = impact
+	plan.Operations = []types.Operation{operation}
+
+	return plan, nil
+}
+
 // CreateFacade implements creating facade packages
 func (e *DefaultEngine) CreateFacade(ws *types.Workspace, req types.CreateFacadeRequest) (*types.RefactoringPlan, error) {
 	operation := &CreateFacadeOperation{Request: req}
@@ -1063,6 +2315,7 @@ func (e *DefaultEngine) UpdateFacades(ws *types.Workspace, req types.UpdateFacad
 	if err != nil {
 		return nil, fmt.Errorf("failed to analyze impact: %w", err)
 	}
+	impact.PotentialIssues = append(impact.PotentialIssues, operation.dropped...)
 
 	plan.Impact = impact
 	plan.Operations = []types.Operation{operation}
@@ -1187,11 +2440,14 @@ func (e *DefaultEngine) MoveByDependencies(ws *types.Workspace, req types.MoveBy
 		return nil, fmt.Errorf("move by dependencies operation validation failed: %w", err)
 	}
 
+	e.report(types.ProgressEvent{Phase: types.PhasePlan, Message: operation.Description()})
+
 	// Execute the operation to generate the plan
 	plan, err := operation.Execute(ws)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate move by dependencies plan: %w", err)
 	}
+	e.manageImports(ws, plan)
 
 	// Only set impact from analyzer if the operation didn't already compute it
 	if plan.Impact == nil {
@@ -1209,6 +2465,11 @@ func (e *DefaultEngine) MoveByDependencies(ws *types.Workspace, req types.MoveBy
 
 // OrganizeByLayers implements organizing packages by architectural layers
 func (e *DefaultEngine) OrganizeByLayers(ws *types.Workspace, req types.OrganizeByLayersRequest) (*types.RefactoringPlan, error) {
+	return e.OrganizeByLayersContext(context.Background(), ws, req)
+}
+
+// OrganizeByLayersContext is like OrganizeByLayers but stops planning once ctx is canceled.
+func (e *DefaultEngine) OrganizeByLayersContext(ctx context.Context, ws *types.Workspace, req types.OrganizeByLayersRequest) (*types.RefactoringPlan, error) {
 	operation := &OrganizeByLayersOperation{Request: req}
 
 	// Validate the operation
@@ -1216,12 +2477,18 @@ func (e *DefaultEngine) OrganizeByLayers(ws *types.Workspace, req types.Organize
 		return nil, fmt.Errorf("organize by layers operation validation failed: %w", err)
 	}
 
+	e.report(types.ProgressEvent{Phase: types.PhasePlan, Message: operation.Description()})
+
 	// Execute the operation to generate the plan
-	plan, err := operation.Execute(ws)
+	plan, err := operation.ExecuteContext(ctx, ws)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate organize by layers plan: %w", err)
 	}
 
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	// Analyze impact
 	impact, err := e.analyzer.AnalyzeImpact(operation)
 	if err != nil {
@@ -1234,15 +2501,43 @@ func (e *DefaultEngine) OrganizeByLayers(ws *types.Workspace, req types.Organize
 	return plan, nil
 }
 
+// OrganizeByDomain implements regrouping the declarations of a package into
+// one file per domain
+func (e *DefaultEngine) OrganizeByDomain(ws *types.Workspace, req types.OrganizeByDomainRequest) (*types.RefactoringPlan, error) {
+	operation := &OrganizeByDomainOperation{Request: req, Parser: e.parser}
+
+	if err := operation.Validate(ws); err != nil {
+		return nil, fmt.Errorf("organize by domain operation validation failed: %w", err)
+	}
+
+	plan, err := operation.Execute(ws)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate organize by domain plan: %w", err)
+	}
+
+	impact, err := e.analyzer.AnalyzeImpact(operation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze impact: %w", err)
+	}
+	impact.AffectedFiles = plan.AffectedFiles
+
+	plan.Impact = impact
+	plan.Operations = []types.Operation{operation}
+
+	return plan, nil
+}
+
 // FixCycles implements detecting and fixing circular dependencies
 func (e *DefaultEngine) FixCycles(ws *types.Workspace, req types.FixCyclesRequest) (*types.RefactoringPlan, error) {
-	operation := &FixCyclesOperation{Request: req}
+	operation := &FixCyclesOperation{Request: req, CallGraph: e.callGraph(ws)}
 
 	// Validate the operation
 	if err := operation.Validate(ws); err != nil {
 		return nil, fmt.Errorf("fix cycles operation validation failed: %w", err)
 	}
 
+	e.report(types.ProgressEvent{Phase: types.PhasePlan, Message: operation.Description()})
+
 	// Execute the operation to generate the plan
 	plan, err := operation.Execute(ws)
 	if err != nil {
@@ -1264,6 +2559,9 @@ func (e *DefaultEngine) FixCycles(ws *types.Workspace, req types.FixCyclesReques
 // AnalyzeDependencies implements analyzing dependency flow
 func (e *DefaultEngine) AnalyzeDependencies(ws *types.Workspace, req types.AnalyzeDependenciesRequest) (*types.RefactoringPlan, error) {
 	operation := &AnalyzeDependenciesOperation{Request: req}
+	if req.SuggestMoves {
+		operation.CallGraph = e.callGraph(ws)
+	}
 
 	// Validate the operation
 	if err := operation.Validate(ws); err != nil {
@@ -1290,7 +2588,7 @@ func (e *DefaultEngine) AnalyzeDependencies(ws *types.Workspace, req types.Analy
 
 // BatchOperations implements executing multiple operations atomically
 func (e *DefaultEngine) BatchOperations(ws *types.Workspace, req types.BatchOperationRequest) (*types.RefactoringPlan, error) {
-	operation := &BatchOperationOperation{Request: req}
+	operation := &BatchOperationOperation{Request: req, engine: e}
 
 	// Validate the operation
 	if err := operation.Validate(ws); err != nil {
@@ -1303,11 +2601,12 @@ func (e *DefaultEngine) BatchOperations(ws *types.Workspace, req types.BatchOper
 		return nil, fmt.Errorf("failed to generate batch operations plan: %w", err)
 	}
 
-	// Analyze impact
+	// Analyze impact, keeping the warnings about skipped steps
 	impact, err := e.analyzer.AnalyzeImpact(operation)
 	if err != nil {
 		return nil, fmt.Errorf("failed to analyze impact: %w", err)
 	}
+	impact.PotentialIssues = append(impact.PotentialIssues, plan.Impact.PotentialIssues...)
 
 	plan.Impact = impact
 	plan.Operations = []types.Operation{operation}
@@ -1378,35 +2677,28 @@ func (e *DefaultEngine) ExecutePlanFromFile(req types.ExecuteOperationRequest) (
 	return plan, nil
 }
 
-// RollbackOperations implements rolling back operations
+// RollbackOperations reverts plans recorded in the history journal. The
+// rollback is applied immediately; the returned plan carries no changes and
+// only lists the restored files.
 func (e *DefaultEngine) RollbackOperations(req types.RollbackOperationRequest) (*types.RefactoringPlan, error) {
-	operation := &RollbackOperation{Request: req}
-
-	// For rollback, we might not need a workspace initially
-	// This is a simplified approach - in reality we'd need better state management
-	ws, err := e.LoadWorkspace(".")
-	if err != nil {
-		return nil, fmt.Errorf("failed to load workspace for rollback: %w", err)
+	journal := e.Journal()
+	if journal == nil {
+		return nil, fmt.Errorf("rollback requires a loaded workspace with history journaling enabled")
 	}
+	operation := &RollbackOperation{Request: req, journal: journal}
 
 	// Validate the operation
-	if err := operation.Validate(ws); err != nil {
+	if err := operation.Validate(nil); err != nil {
 		return nil, fmt.Errorf("rollback operation validation failed: %w", err)
 	}
 
-	// Execute the operation to generate the plan
-	plan, err := operation.Execute(ws)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate rollback plan: %w", err)
-	}
-
-	// Analyze impact
-	impact, err := e.analyzer.AnalyzeImpact(operation)
+	// Execute the operation, which restores the files
+	plan, err := operation.Execute(nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to analyze impact: %w", err)
+		return nil, fmt.Errorf("rollback failed: %w", err)
 	}
 
-	plan.Impact = impact
+	plan.Impact = &types.ImpactAnalysis{AffectedFiles: plan.AffectedFiles}
 	plan.Operations = []types.Operation{operation}
 
 	return plan, nil
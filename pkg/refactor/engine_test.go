@@ -1,11 +1,18 @@
 package refactor
 
 import (
+	"context"
+	"errors"
 	"io"
+	"os"
+	"path/filepath"
 	"log/slog"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
 
+	"github.com/mamaar/gorefactor/pkg/analysis"
 	"github.com/mamaar/gorefactor/pkg/types"
 )
 
@@ -163,4 +170,282 @@ func TestDefaultEngine_findOperationConflicts(t *testing.T) {
 	if len(conflicts) == 0 {
 		t.Error("Expected conflicts with overlapping changes")
 	}
-}
\ No newline at end of file
+}
+
+func TestDefaultEngine_ValidateRefactoring_GeneratedFiles(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "zz_generated.go")
+	if err := os.WriteFile(file, []byte("// Code generated by stringer; DO NOT EDIT.\n\npackage p\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	plan := &types.RefactoringPlan{
+		Changes: []types.Change{{File: file, Start: 0, End: 0, NewText: "// edit\n"}},
+		Impact:  &types.ImpactAnalysis{},
+	}
+
+	engine := CreateEngine(slog.New(slog.NewTextHandler(io.Discard, nil))).(*DefaultEngine)
+	err := engine.ValidateRefactoring(plan)
+	var refErr *types.RefactorError
+	if !errors.As(err, &refErr) || refErr.Type != types.GeneratedFileViolation {
+		t.Fatalf("expected GeneratedFileViolation, got %v", err)
+	}
+
+	engine.Config().AllowGenerated = true
+	if err := engine.ValidateRefactoring(plan); err != nil {
+		t.Errorf("expected AllowGenerated to permit the plan, got %v", err)
+	}
+}
+
+// writeTestModule creates a small two-package module in a temp directory.
+func writeTestModule(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/p\n\ngo 1.21\n",
+		"a/a.go": "package a\n\nfunc A() {}\n",
+		"b/b.go": "package b\n\nfunc B() {}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestDefaultEngine_SetProgressReporter(t *testing.T) {
+	dir := writeTestModule(t)
+
+	var mu sync.Mutex
+	seen := map[types.ProgressPhase]int{}
+	engine := CreateEngine(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	engine.SetProgressReporter(func(event types.ProgressEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		seen[event.Phase]++
+	})
+
+	if _, err := engine.LoadWorkspace(dir); err != nil {
+		t.Fatalf("LoadWorkspace: %v", err)
+	}
+	plan := &types.RefactoringPlan{
+		Changes: []types.Change{{File: filepath.Join(dir, "a/a.go"), Start: 0, End: 0, NewText: "// edit\n"}},
+	}
+	if err := engine.ExecutePlan(plan); err != nil {
+		t.Fatalf("ExecutePlan: %v", err)
+	}
+
+	for _, phase := range []types.ProgressPhase{types.PhaseParse, types.PhaseIndex, types.PhaseApply} {
+		if seen[phase] == 0 {
+			t.Errorf("expected %s progress events, got none (%v)", phase, seen)
+		}
+	}
+}
+
+func TestDefaultEngine_ContextCanceled(t *testing.T) {
+	dir := writeTestModule(t)
+	engine := CreateEngine(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := engine.LoadWorkspaceContext(ctx, dir); !errors.Is(err, context.Canceled) {
+		t.Fatalf("LoadWorkspaceContext: expected context.Canceled, got %v", err)
+	}
+
+	ws, err := engine.LoadWorkspace(dir)
+	if err != nil {
+		t.Fatalf("LoadWorkspace: %v", err)
+	}
+	if _, err := engine.MovePackagesContext(ctx, ws, types.MovePackagesRequest{
+		Packages:  []types.PackageMapping{{SourcePackage: "a", TargetPackage: "c"}},
+		TargetDir: "c",
+	}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("MovePackagesContext: expected context.Canceled, got %v", err)
+	}
+
+	file := filepath.Join(dir, "a/a.go")
+	plan := &types.RefactoringPlan{
+		Changes: []types.Change{{File: file, Start: 0, End: 0, NewText: "// edit\n"}},
+		Impact:  &types.ImpactAnalysis{},
+	}
+	if err := engine.ExecutePlanContext(ctx, plan); !errors.Is(err, context.Canceled) {
+		t.Fatalf("ExecutePlanContext: expected context.Canceled, got %v", err)
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.HasPrefix(string(content), "// edit") {
+		t.Error("canceled ExecutePlanContext must not write changes")
+	}
+}
+
+func TestDefaultEngine_JournalRollback(t *testing.T) {
+	dir := writeTestModule(t)
+	engine := CreateEngineWithConfig(&EngineConfig{SkipCompilation: true, Journal: true}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if _, err := engine.LoadWorkspace(dir); err != nil {
+		t.Fatalf("LoadWorkspace: %v", err)
+	}
+
+	file := filepath.Join(dir, "a/a.go")
+	for _, text := range []string{"// first\n", "// second\n"} {
+		plan := &types.RefactoringPlan{
+			Changes: []types.Change{{File: file, Start: 0, End: 0, NewText: text}},
+			Impact:  &types.ImpactAnalysis{},
+		}
+		if err := engine.ExecutePlan(plan); err != nil {
+			t.Fatalf("ExecutePlan: %v", err)
+		}
+	}
+
+	entries, err := engine.(*DefaultEngine).Journal().List()
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("expected 2 history entries, got %d (%v)", len(entries), err)
+	}
+
+	plan, err := engine.RollbackOperations(types.RollbackOperationRequest{ToStep: 1})
+	if err != nil {
+		t.Fatalf("RollbackOperations: %v", err)
+	}
+	if len(plan.AffectedFiles) != 1 || plan.AffectedFiles[0] != file {
+		t.Errorf("AffectedFiles = %v, want [%s]", plan.AffectedFiles, file)
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "package a\n\nfunc A() {}\n" {
+		t.Errorf("expected original content after rollback, got %q", content)
+	}
+}
+
+func TestDefaultEngine_VerifyWorkspace(t *testing.T) {
+	dir := writeTestModule(t)
+	engine := CreateEngineWithConfig(&EngineConfig{SkipCompilation: true, VerifyInternal: true}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ws, err := engine.LoadWorkspace(dir)
+	if err != nil {
+		t.Fatalf("LoadWorkspace: %v", err)
+	}
+
+	for path := range ws.Packages {
+		ws.ImportToPath["example.com/stale"] = path + "/stale"
+		break
+	}
+	err = engine.(*DefaultEngine).VerifyWorkspace(ws, "after test")
+	var ierr *types.InvariantError
+	if !errors.As(err, &ierr) || !strings.Contains(err.Error(), "after test") {
+		t.Fatalf("expected an InvariantError naming the stage, got %v", err)
+	}
+	if verifyInternalDefault {
+		return
+	}
+	engine.(*DefaultEngine).Config().VerifyInternal = false
+	if err := engine.(*DefaultEngine).VerifyWorkspace(ws, "after test"); err != nil {
+		t.Errorf("verification is off, got %v", err)
+	}
+}
+
+func TestDefaultEngine_ExecutePlanMinConfidence(t *testing.T) {
+	dir := writeTestModule(t)
+	engine := CreateEngineWithConfig(&EngineConfig{SkipCompilation: true, MinConfidence: types.ConfidenceLikely},
+		slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if _, err := engine.LoadWorkspace(dir); err != nil {
+		t.Fatalf("LoadWorkspace: %v", err)
+	}
+	a, b := filepath.Join(dir, "a/a.go"), filepath.Join(dir, "b/b.go")
+	plan := &types.RefactoringPlan{
+		Changes: []types.Change{
+			{File: a, NewText: "// likely\n", Confidence: types.ConfidenceLikely},
+			{File: b, NewText: "// heuristic\n", Confidence: types.ConfidenceHeuristic},
+		},
+		AffectedFiles: []string{a, b},
+	}
+	if err := engine.ExecutePlan(plan); err != nil {
+		t.Fatalf("ExecutePlan: %v", err)
+	}
+	if src, _ := os.ReadFile(a); !strings.HasPrefix(string(src), "// likely\n") {
+		t.Errorf("likely change not applied:\n%s", src)
+	}
+	if src, _ := os.ReadFile(b); strings.Contains(string(src), "heuristic") {
+		t.Errorf("heuristic change applied:\n%s", src)
+	}
+}
+
+func TestDefaultEngine_RenameSymbolConfidence(t *testing.T) {
+	dir := writeTestModule(t)
+	b := filepath.Join(dir, "b/b.go")
+	if err := os.WriteFile(b, []byte("package b\n\nimport \"example.com/p/a\"\n\nfunc B() { a.A() }\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	engine := CreateEngineWithConfig(&EngineConfig{SkipCompilation: true}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ws, err := engine.LoadWorkspace(dir)
+	if err != nil {
+		t.Fatalf("LoadWorkspace: %v", err)
+	}
+	plan, err := engine.RenameSymbol(ws, types.RenameSymbolRequest{SymbolName: "A", NewName: "Renamed"})
+	if err != nil {
+		t.Fatalf("RenameSymbol: %v", err)
+	}
+	for _, c := range plan.Changes {
+		switch c.File {
+		case b:
+			// Without type information the reference matches by import.
+			if c.Confidence == "" || !c.Confidence.AtLeast(types.ConfidenceLikely) {
+				t.Errorf("reference confidence = %q", c.Confidence)
+			}
+		default:
+			if c.Confidence != "" && c.Confidence != types.ConfidenceCertain {
+				t.Errorf("definition confidence = %q", c.Confidence)
+			}
+		}
+	}
+}
+
+func TestDefaultEngine_SharedReferenceIndex(t *testing.T) {
+	dir := writeTestModule(t)
+	b := filepath.Join(dir, "b/b.go")
+	if err := os.WriteFile(b, []byte("package b\n\nimport \"example.com/p/a\"\n\nfunc B() { a.A() }\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	var shared *analysis.ReferenceIndex
+	calls := 0
+	engine := CreateEngineWithConfig(&EngineConfig{
+		SkipCompilation: true,
+		ReferenceIndex: func(ws *types.Workspace) *analysis.ReferenceIndex {
+			calls++
+			if shared == nil {
+				shared = analysis.NewSymbolResolver(ws, logger).BuildReferenceIndex()
+			}
+			return shared
+		},
+	}, logger).(*DefaultEngine)
+	ws, err := engine.LoadWorkspace(dir)
+	if err != nil {
+		t.Fatalf("LoadWorkspace: %v", err)
+	}
+
+	plan, err := engine.RenameSymbol(ws, types.RenameSymbolRequest{SymbolName: "A", NewName: "Renamed"})
+	if err != nil {
+		t.Fatalf("RenameSymbol: %v", err)
+	}
+	if !slices.ContainsFunc(plan.Changes, func(c types.Change) bool { return c.File == b }) {
+		t.Errorf("rename didn't update the reference in b.go: %+v", plan.Changes)
+	}
+	move := types.MoveSymbolRequest{SymbolName: "A", FromPackage: filepath.Join(dir, "a"), ToPackage: filepath.Join(dir, "b")}
+	if _, err := engine.MoveSymbol(ws, move); err != nil {
+		t.Fatalf("MoveSymbol: %v", err)
+	}
+	if issues := engine.validator.ValidateMove(ws, move); len(issues) != 0 {
+		t.Errorf("ValidateMove: %+v", issues)
+	}
+	if calls != 3 {
+		t.Errorf("the shared index was asked for %d times, want once per operation and once for the validator", calls)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"go/ast"
 	"go/token"
+	"path/filepath"
 
 	pkgtypes "github.com/mamaar/gorefactor/pkg/types"
 )
@@ -126,8 +127,7 @@ func (op *ExtractConstantOperation) Execute(ws *pkgtypes.Workspace) (*pkgtypes.R
 		}
 	}
 
-	// Get literal value and type
-	literalValue, literalType := op.getLiteralValueAndType(literal)
+	literalValue := op.getLiteralValue(literal)
 
 	// Find all occurrences of this literal in the scope
 	occurrences := op.findLiteralOccurrences(ws, literal, op.Scope, sourcePackage)
@@ -151,10 +151,11 @@ func (op *ExtractConstantOperation) Execute(ws *pkgtypes.Workspace) (*pkgtypes.R
 	}
 
 	// Generate constant declaration
-	constDecl := op.generateConstantDeclaration(op.ConstantName, literalValue, literalType)
+	constDecl := op.generateConstantDeclaration(op.ConstantName, literalValue)
 
-	// Find position to insert constant (after package declaration, before first non-import declaration)
-	insertPos := op.findConstantInsertPosition(targetFile)
+	// Find position to insert constant (after package declaration and imports).
+	// The declaration is always the plan's first change.
+	insertPos := op.findConstantInsertPosition(ws, targetFile)
 
 	// Add constant declaration change
 	plan.Changes = append(plan.Changes, pkgtypes.Change{
@@ -162,27 +163,25 @@ func (op *ExtractConstantOperation) Execute(ws *pkgtypes.Workspace) (*pkgtypes.R
 		Start:       insertPos,
 		End:         insertPos,
 		OldText:     "",
-		NewText:     constDecl + "\n",
+		NewText:     "\n" + constDecl + "\n",
 		Description: fmt.Sprintf("Add constant declaration %s", op.ConstantName),
 	})
 	plan.AffectedFiles = append(plan.AffectedFiles, targetFile.Path)
 
-	// Replace all occurrences with the constant name
+	// Replace all occurrences, the extracted literal included, with the constant name
 	for _, occurrence := range occurrences {
-		if occurrence.File != targetFile.Path || occurrence.Pos != op.Position {
-			plan.Changes = append(plan.Changes, pkgtypes.Change{
-				File:        occurrence.File,
-				Start:       int(occurrence.Pos),
-				End:         int(occurrence.End),
-				OldText:     literalValue,
-				NewText:     op.ConstantName,
-				Description: fmt.Sprintf("Replace literal with constant %s", op.ConstantName),
-			})
-			
-			// Add to affected files if not already present
-			if !contains(plan.AffectedFiles, occurrence.File) {
-				plan.AffectedFiles = append(plan.AffectedFiles, occurrence.File)
-			}
+		plan.Changes = append(plan.Changes, pkgtypes.Change{
+			File:        occurrence.File,
+			Start:       ws.FileSet.Position(occurrence.Pos).Offset,
+			End:         ws.FileSet.Position(occurrence.End).Offset,
+			OldText:     literalValue,
+			NewText:     op.ConstantName,
+			Description: fmt.Sprintf("Replace literal with constant %s", op.ConstantName),
+		})
+
+		// Add to affected files if not already present
+		if !contains(plan.AffectedFiles, occurrence.File) {
+			plan.AffectedFiles = append(plan.AffectedFiles, occurrence.File)
 		}
 	}
 
@@ -252,28 +251,21 @@ func (op *ExtractConstantOperation) isExtractableLiteral(expr ast.Expr) bool {
 	}
 }
 
-func (op *ExtractConstantOperation) getLiteralValueAndType(expr ast.Expr) (string, string) {
+func (op *ExtractConstantOperation) getLiteralValue(expr ast.Expr) string {
 	switch lit := expr.(type) {
 	case *ast.BasicLit:
-		switch lit.Kind {
-		case token.STRING:
-			return lit.Value, "string"
-		case token.INT:
-			return lit.Value, ""  // Type will be inferred
-		case token.FLOAT:
-			return lit.Value, "float64"
-		}
+		return lit.Value
 	case *ast.Ident:
 		if lit.Name == "true" || lit.Name == "false" {
-			return lit.Name, "bool"
+			return lit.Name
 		}
 	}
-	return "", ""
+	return ""
 }
 
 func (op *ExtractConstantOperation) findLiteralOccurrences(ws *pkgtypes.Workspace, literal ast.Expr, scope pkgtypes.RenameScope, sourcePackage *pkgtypes.Package) []LiteralOccurrence {
 	var occurrences []LiteralOccurrence
-	targetValue, _ := op.getLiteralValueAndType(literal)
+	targetValue := op.getLiteralValue(literal)
 
 	// Determine which packages to search based on scope
 	packagesToSearch := make(map[string]*pkgtypes.Package)
@@ -285,6 +277,7 @@ func (op *ExtractConstantOperation) findLiteralOccurrences(ws *pkgtypes.Workspac
 	}
 
 	// Search for occurrences in the determined packages
+	tags := make(map[*ast.BasicLit]bool)
 	for _, pkg := range packagesToSearch {
 		for _, file := range pkg.Files {
 			if file.AST == nil {
@@ -293,8 +286,16 @@ func (op *ExtractConstantOperation) findLiteralOccurrences(ws *pkgtypes.Workspac
 
 			ast.Inspect(file.AST, func(n ast.Node) bool {
 				switch lit := n.(type) {
+				case *ast.ImportSpec:
+					// Import paths can't be constants
+					return false
+				case *ast.Field:
+					// Nor can struct tags
+					if lit.Tag != nil {
+						tags[lit.Tag] = true
+					}
 				case *ast.BasicLit:
-					if lit.Value == targetValue {
+					if lit.Value == targetValue && !tags[lit] {
 						occurrences = append(occurrences, LiteralOccurrence{
 							File: file.Path,
 							Pos:  lit.Pos(),
@@ -318,64 +319,43 @@ func (op *ExtractConstantOperation) findLiteralOccurrences(ws *pkgtypes.Workspac
 	return occurrences
 }
 
-func (op *ExtractConstantOperation) generateConstantDeclaration(name, value, typeName string) string {
-	// For untyped constants (like integers), omit the type
-	if typeName == "" {
-		return fmt.Sprintf("const %s = %s", name, value)
-	}
-	return fmt.Sprintf("const %s %s = %s", name, typeName, value)
+// generateConstantDeclaration declares an untyped constant, which can
+// replace the literal wherever it appears: a typed one couldn't be used
+// where the literal has a named or differently sized type.
+func (op *ExtractConstantOperation) generateConstantDeclaration(name, value string) string {
+	return fmt.Sprintf("const %s = %s", name, value)
 }
 
-func (op *ExtractConstantOperation) findConstantInsertPosition(file *pkgtypes.File) int {
+// findConstantInsertPosition returns the offset of the line after the
+// file's imports, or after its package clause when it has none.
+func (op *ExtractConstantOperation) findConstantInsertPosition(ws *pkgtypes.Workspace, file *pkgtypes.File) int {
 	if file.AST == nil {
 		return 0
 	}
 
-	// Find the position after package declaration and imports
-	lastImportEnd := 0
-	packageEnd := 0
-
-	// Find package declaration end
-	if file.AST.Name != nil {
-		packageEnd = int(file.AST.Name.End())
-		// Skip to end of line
-		if packageEnd < len(file.OriginalContent) {
-			for i := packageEnd; i < len(file.OriginalContent); i++ {
-				if file.OriginalContent[i] == '\n' {
-					packageEnd = i + 1
-					break
-				}
-			}
+	end := file.AST.Name.End()
+	for _, decl := range file.AST.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			end = gen.End()
 		}
 	}
 
-	// Find last import end
-	if len(file.AST.Imports) > 0 {
-		lastImport := file.AST.Imports[len(file.AST.Imports)-1]
-		lastImportEnd = int(lastImport.End())
-		// Skip to end of line
-		if lastImportEnd < len(file.OriginalContent) {
-			for i := lastImportEnd; i < len(file.OriginalContent); i++ {
-				if file.OriginalContent[i] == '\n' {
-					lastImportEnd = i + 1
-					break
-				}
-			}
+	// Skip to the start of the next line
+	offset := ws.FileSet.Position(end).Offset
+	for offset < len(file.OriginalContent) {
+		offset++
+		if file.OriginalContent[offset-1] == '\n' {
+			break
 		}
 	}
-
-	// If there are imports, insert after them; otherwise after package
-	if lastImportEnd > 0 {
-		return lastImportEnd + 1 // Add extra newline
-	}
-	return packageEnd + 1
+	return offset
 }
 
 func (op *ExtractConstantOperation) getAffectedPackages(ws *pkgtypes.Workspace, affectedFiles []string) []string {
 	packageMap := make(map[string]bool)
 	for _, filePath := range affectedFiles {
 		for _, pkg := range ws.Packages {
-			if _, exists := pkg.Files[filePath]; exists {
+			if file, exists := pkg.Files[filepath.Base(filePath)]; exists && file.Path == filePath {
 				packageMap[pkg.Path] = true
 				break
 			}
@@ -0,0 +1,170 @@
+package refactor
+
+import (
+	"go/format"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+var extractInterfaceFiles = map[string]string{
+	"store/store.go":      "package store\n\ntype Store struct {\n\tdata map[string]string\n\tSize int\n}\n\nfunc (s *Store) Get(k string) string { return s.data[k] }\n\nfunc (s *Store) Set(k, v string) { s.data[k] = v }\n",
+	"store/interfaces.go": "package store\n\n// Reader reads values.\ntype Reader interface {\n\tGet(k string) string\n}\n",
+	"svc/svc.go":          "package svc\n\nimport \"example.com/p/store\"\n\ntype Service struct {\n\tst *store.Store\n}\n\nfunc New(st *store.Store) *Service { return &Service{st: st} }\n\nfunc (s *Service) Copy(k string) { s.st.Set(k, s.st.Get(k)) }\n",
+}
+
+func TestExtractInterface_AppendAndAdopt(t *testing.T) {
+	engine, ws, dir := loadTestModuleWith(t, extractInterfaceFiles)
+	plan, err := engine.ExtractInterface(ws, types.ExtractInterfaceRequest{
+		SourceStruct:  "Store",
+		InterfaceName: "Storage",
+		Methods:       []string{"Get", "Set"},
+		Adopt:         []string{"Service.st", "New.st"},
+	})
+	if err != nil {
+		t.Fatalf("ExtractInterface: %v", err)
+	}
+
+	want := map[string]string{
+		"store/interfaces.go": "package store\n\n// Reader reads values.\ntype Reader interface {\n\tGet(k string) string\n}\n\ntype Storage interface {\n\tGet(k string) string\n\tSet(k, v string)\n}\n",
+		"svc/svc.go":          "package svc\n\nimport \"example.com/p/store\"\n\ntype Service struct {\n\tst store.Storage\n}\n\nfunc New(st store.Storage) *Service { return &Service{st: st} }\n\nfunc (s *Service) Copy(k string) { s.st.Set(k, s.st.Get(k)) }\n",
+	}
+	for name, content := range want {
+		if got := planContent(t, plan, filepath.Join(dir, name)); got != content {
+			t.Errorf("%s:\n%s\nwant:\n%s", name, got, content)
+		}
+	}
+}
+
+func TestExtractInterface_AdoptInConsumerPackage(t *testing.T) {
+	engine, ws, dir := loadTestModuleWith(t, extractInterfaceFiles)
+	plan, err := engine.ExtractInterface(ws, types.ExtractInterfaceRequest{
+		SourceStruct:  "Store",
+		InterfaceName: "Storage",
+		Methods:       []string{"Get", "Set"},
+		TargetPackage: filepath.Join(dir, "svc"),
+		Adopt:         []string{"Service.st", "New.st"},
+	})
+	if err != nil {
+		t.Fatalf("ExtractInterface: %v", err)
+	}
+
+	// The store import is dropped; files are formatted when written
+	want := map[string]string{
+		"svc/interfaces.go": "package svc\n\ntype Storage interface {\n\tGet(k string) string\n\tSet(k, v string)\n}\n",
+		"svc/svc.go":        "package svc\n\ntype Service struct {\n\tst Storage\n}\n\nfunc New(st Storage) *Service { return &Service{st: st} }\n\nfunc (s *Service) Copy(k string) { s.st.Set(k, s.st.Get(k)) }\n",
+	}
+	for name, content := range want {
+		got, err := format.Source([]byte(planContent(t, plan, filepath.Join(dir, name))))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if string(got) != content {
+			t.Errorf("%s:\n%s\nwant:\n%s", name, got, content)
+		}
+	}
+}
+
+func TestExtractInterface_InferMethods(t *testing.T) {
+	files := map[string]string{
+		"store/store.go": extractInterfaceFiles["store/store.go"] + "\nfunc (s *Store) Delete(k string) { delete(s.data, k) }\n",
+		"svc/svc.go":     "package svc\n\nimport \"example.com/p/store\"\n\nfunc Lookup(st *store.Store, k string) string { return st.Get(k) }\n",
+	}
+	engine, ws, dir := loadTestModuleWith(t, files)
+	plan, err := engine.ExtractInterface(ws, types.ExtractInterfaceRequest{
+		SourceStruct:  "Store",
+		InterfaceName: "Getter",
+		TargetPackage: filepath.Join(dir, "svc"),
+		Adopt:         []string{"Lookup.st"},
+	})
+	if err != nil {
+		t.Fatalf("ExtractInterface: %v", err)
+	}
+
+	want := map[string]string{
+		"svc/interfaces.go": "package svc\n\ntype Getter interface {\n\tGet(k string) string\n}\n",
+		"svc/svc.go":        "package svc\n\nfunc Lookup(st Getter, k string) string { return st.Get(k) }\n",
+	}
+	for name, content := range want {
+		got, err := format.Source([]byte(planContent(t, plan, filepath.Join(dir, name))))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if string(got) != content {
+			t.Errorf("%s:\n%s\nwant:\n%s", name, got, content)
+		}
+	}
+}
+
+func TestExtractInterface_AdoptErrors(t *testing.T) {
+	files := map[string]string{
+		"svc/size.go": "package svc\n\nimport \"example.com/p/store\"\n\nfunc Size(st *store.Store) int { return st.Size }\n\nfunc Value(st store.Store) string { return st.Get(\"k\") }\n\nfunc Name(name string) string { return name }\n",
+	}
+	for k, v := range extractInterfaceFiles {
+		files[k] = v
+	}
+	tests := []struct {
+		adopt, want string
+	}{
+		{"Size.st", "Size.st is used for Size, which Storage doesn't declare"},
+		{"Value.st", "whose method Get has a pointer receiver"},
+		{"Name.name", "Name.name is declared as string, not Store or *Store"},
+		{"Missing.x", "declaration Missing.x not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.adopt, func(t *testing.T) {
+			engine, ws, _ := loadTestModuleWith(t, files)
+			_, err := engine.ExtractInterface(ws, types.ExtractInterfaceRequest{
+				SourceStruct:  "Store",
+				InterfaceName: "Storage",
+				Methods:       []string{"Get", "Set"},
+				Adopt:         []string{tt.adopt},
+			})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractInterface_InferMethodsErrors(t *testing.T) {
+	files := map[string]string{
+		"svc/size.go": "package svc\n\nimport \"example.com/p/store\"\n\nfunc Size(st *store.Store) int { return st.Size }\n\nfunc Keep(st *store.Store) *store.Store { return st }\n",
+	}
+	for k, v := range extractInterfaceFiles {
+		files[k] = v
+	}
+	tests := []struct {
+		adopt, want string
+	}{
+		{"Size.st", "Size.st is used for Size, which isn't a method Store declares"},
+		{"Keep.st", "Keep.st isn't used for any method of Store"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.adopt, func(t *testing.T) {
+			engine, ws, _ := loadTestModuleWith(t, files)
+			_, err := engine.ExtractInterface(ws, types.ExtractInterfaceRequest{
+				SourceStruct:  "Store",
+				InterfaceName: "Storage",
+				Adopt:         []string{tt.adopt},
+			})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractInterface_NameConflict(t *testing.T) {
+	engine, ws, _ := loadTestModuleWith(t, extractInterfaceFiles)
+	_, err := engine.ExtractInterface(ws, types.ExtractInterfaceRequest{
+		SourceStruct:  "Store",
+		InterfaceName: "Reader",
+		Methods:       []string{"Get"},
+	})
+	if types.CodeOf(err) != types.CodeNameConflict {
+		t.Errorf("got %v, want a name conflict", err)
+	}
+}
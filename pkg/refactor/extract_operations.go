@@ -5,13 +5,17 @@ import (
 	"go/ast"
 	"go/parser"
 	"go/token"
+	gotypes "go/types"
 	"io"
 	"log/slog"
-	"os"
+	"maps"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"sort"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/mamaar/gorefactor/pkg/analysis"
 	"github.com/mamaar/gorefactor/pkg/types"
@@ -26,6 +30,7 @@ type ExtractMethodOperation struct {
 	TargetStruct  string
 	Logger        *slog.Logger
 	Parser        *analysis.GoParser
+	Backend       ChangeBackend // ChangeBackendAST when empty
 }
 
 func (op *ExtractMethodOperation) buildImportedPackagesMap(astFile *ast.File, logger *slog.Logger) map[string]bool {
@@ -625,7 +630,51 @@ func (op *ExtractMethodOperation) handleEarlyReturns(
 func (op *ExtractMethodOperation) buildChanges(
 	sourceFile *types.File, sourcePackage *types.Package, astFile *ast.File, fset *token.FileSet,
 	extractedCode, callText, newMethod string,
-) *types.RefactoringPlan {
+) (*types.RefactoringPlan, error) {
+	var changes []types.Change
+	if op.Backend == ChangeBackendText {
+		changes = op.textChanges(sourceFile, astFile, fset, extractedCode, callText, newMethod)
+	} else {
+		var err error
+		if changes, err = op.rewriteChanges(sourceFile, callText, newMethod); err != nil {
+			return nil, err
+		}
+	}
+
+	return &types.RefactoringPlan{
+		Operations:    []types.Operation{op},
+		Changes:       changes,
+		AffectedFiles: []string{op.SourceFile},
+		Impact: &types.ImpactAnalysis{
+			AffectedFiles:    []string{op.SourceFile},
+			AffectedPackages: []string{sourcePackage.Path},
+		},
+		Reversible: true,
+	}, nil
+}
+
+// rewriteChanges replaces the statements on the extracted lines with the
+// call and inserts the method after the target struct.
+func (op *ExtractMethodOperation) rewriteChanges(sourceFile *types.File, callText, newMethod string) ([]types.Change, error) {
+	r, err := newASTRewrite(op.SourceFile, sourceFile.OriginalContent)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.checkStatementLines(op.StartLine, op.EndLine); err != nil {
+		return nil, err
+	}
+	start, end := r.lineSpan(op.StartLine, op.EndLine)
+	r.edit(start, end, callText, fmt.Sprintf("Replace extracted code with call to %s", op.NewMethodName))
+	insertionPoint := findInsertionPointWithFset(r.ast, op.TargetStruct, r.fset)
+	r.edit(insertionPoint, insertionPoint, "\n\n"+newMethod, fmt.Sprintf("Add extracted method %s", op.NewMethodName))
+	return r.changes()
+}
+
+// textChanges computes the changes from line offsets.
+func (op *ExtractMethodOperation) textChanges(
+	sourceFile *types.File, astFile *ast.File, fset *token.FileSet,
+	extractedCode, callText, newMethod string,
+) []types.Change {
 	content := string(sourceFile.OriginalContent)
 	startOffset := op.getLineOffset(content, op.StartLine)
 	endOffset := op.getLineOffset(content, op.EndLine+1) - 1
@@ -635,7 +684,7 @@ func (op *ExtractMethodOperation) buildChanges(
 		op.Logger.Info("offsets", "start", startOffset, "end", endOffset, "insertion", insertionPoint)
 	}
 
-	changes := []types.Change{
+	return []types.Change{
 		{
 			File:        op.SourceFile,
 			Start:       startOffset,
@@ -653,30 +702,15 @@ func (op *ExtractMethodOperation) buildChanges(
 			Description: fmt.Sprintf("Add extracted method %s", op.NewMethodName),
 		},
 	}
-
-	return &types.RefactoringPlan{
-		Operations:    []types.Operation{op},
-		Changes:       changes,
-		AffectedFiles: []string{op.SourceFile},
-		Impact: &types.ImpactAnalysis{
-			AffectedFiles:    []string{op.SourceFile},
-			AffectedPackages: []string{sourcePackage.Path},
-		},
-		Reversible: true,
-	}
 }
 
 func (op *ExtractMethodOperation) Execute(ws *types.Workspace) (*types.RefactoringPlan, error) {
 	defer func() {
 		if r := recover(); r != nil {
-			logFile, _ := os.Create("/tmp/gorefactor-panic.log")
-			if logFile != nil {
-				_, _ = fmt.Fprintf(logFile, "PANIC in ExtractMethodOperation.Execute: %v\n", r)
-				_, _ = fmt.Fprintf(logFile, "SourceFile: %s\n", op.SourceFile)
-				_, _ = fmt.Fprintf(logFile, "StartLine: %d, EndLine: %d\n", op.StartLine, op.EndLine)
-				_, _ = fmt.Fprintf(logFile, "NewMethodName: %s\n", op.NewMethodName)
-				_, _ = fmt.Fprintf(logFile, "TargetStruct: %s\n", op.TargetStruct)
-				_ = logFile.Close()
+			if op.Logger != nil {
+				op.Logger.Error("panic in ExtractMethodOperation.Execute", "panic", r,
+					"file", op.SourceFile, "start_line", op.StartLine, "end_line", op.EndLine,
+					"new_method", op.NewMethodName, "target_struct", op.TargetStruct)
 			}
 			panic(fmt.Sprintf("ExtractMethodOperation panic: %v", r))
 		}
@@ -696,6 +730,7 @@ func (op *ExtractMethodOperation) Execute(ws *types.Workspace) (*types.Refactori
 	if err != nil {
 		return nil, err
 	}
+	op.EndLine = closingLines(fset, astFile, sourceFile.OriginalContent, op.StartLine, op.EndLine)
 
 	extractedCode, err := op.extractCodeBlock(string(sourceFile.OriginalContent), op.StartLine, op.EndLine)
 	if err != nil {
@@ -733,7 +768,7 @@ func (op *ExtractMethodOperation) Execute(ws *types.Workspace) (*types.Refactori
 		op.Logger.Info("generated call", "callText", callText)
 	}
 
-	return op.buildChanges(sourceFile, sourcePackage, astFile, fset, extractedCode, callText, newMethod), nil
+	return op.buildChanges(sourceFile, sourcePackage, astFile, fset, extractedCode, callText, newMethod)
 }
 
 func (op *ExtractMethodOperation) Description() string {
@@ -835,7 +870,7 @@ func isBuiltinOrImported(name string) bool {
 	}
 
 	// Check if it starts with uppercase (likely imported)
-	return len(name) > 0 && name[0] >= 'A' && name[0] <= 'Z'
+	return token.IsExported(name)
 }
 
 // stripComments removes both single-line (//) and multi-line (/* */) comments from Go code
@@ -1449,6 +1484,7 @@ type ExtractFunctionOperation struct {
 	StartLine       int
 	EndLine         int
 	NewFunctionName string
+	Backend         ChangeBackend // ChangeBackendAST when empty
 }
 
 func (op *ExtractFunctionOperation) Type() types.OperationType {
@@ -1632,6 +1668,7 @@ func (op *ExtractFunctionOperation) Execute(ws *types.Workspace) (*types.Refacto
 			Message: fmt.Sprintf("failed to parse source file: %v", err),
 		}
 	}
+	op.EndLine = closingLines(fset, astFile, sourceFile.OriginalContent, op.StartLine, op.EndLine)
 
 	// Extract the code block
 	extractedCode, err := op.extractCodeBlock(string(sourceFile.OriginalContent), op.StartLine, op.EndLine)
@@ -1651,7 +1688,66 @@ func (op *ExtractFunctionOperation) Execute(ws *types.Workspace) (*types.Refacto
 	newFunction := op.generateFunction(params, returns, extractedCode)
 
 	// Create changes
-	changes := []types.Change{
+	var changes []types.Change
+	if op.Backend == ChangeBackendText {
+		changes = op.textChanges(sourceFile, astFile, extractedCode, params, newFunction)
+	} else if changes, err = op.rewriteChanges(sourceFile, params, newFunction); err != nil {
+		return nil, err
+	}
+
+	return &types.RefactoringPlan{
+		Operations:    []types.Operation{op},
+		Changes:       changes,
+		AffectedFiles: []string{op.SourceFile},
+		Impact: &types.ImpactAnalysis{
+			AffectedFiles:    []string{op.SourceFile},
+			AffectedPackages: []string{sourcePackage.Path},
+		},
+		Reversible: true,
+	}, nil
+}
+
+// rewriteChanges replaces the statements on the extracted lines with the
+// call and inserts the function before the file's first function.
+func (op *ExtractFunctionOperation) rewriteChanges(sourceFile *types.File, params []string, newFunction string) ([]types.Change, error) {
+	r, err := newASTRewrite(op.SourceFile, sourceFile.OriginalContent)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.checkStatementLines(op.StartLine, op.EndLine); err != nil {
+		return nil, err
+	}
+	start, end := r.lineSpan(op.StartLine, op.EndLine)
+	r.edit(start, end, op.generateFunctionCall(params), fmt.Sprintf("Replace extracted code with call to %s", op.NewFunctionName))
+
+	desc := fmt.Sprintf("Add extracted function %s", op.NewFunctionName)
+	var lastImport ast.Decl
+	for _, decl := range r.ast.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			pos := d.Pos()
+			if d.Doc != nil {
+				pos = d.Doc.Pos()
+			}
+			r.insert(pos, newFunction+"\n\n", desc)
+			return r.changes()
+		case *ast.GenDecl:
+			if d.Tok == token.IMPORT {
+				lastImport = d
+			}
+		}
+	}
+	if lastImport != nil {
+		r.insert(lastImport.End(), "\n\n"+newFunction, desc)
+	} else {
+		r.edit(len(r.src), len(r.src), "\n"+newFunction+"\n", desc)
+	}
+	return r.changes()
+}
+
+// textChanges computes the changes from line offsets.
+func (op *ExtractFunctionOperation) textChanges(sourceFile *types.File, astFile *ast.File, extractedCode string, params []string, newFunction string) []types.Change {
+	return []types.Change{
 		// Replace extracted code with function call
 		{
 			File:        op.SourceFile,
@@ -1671,17 +1767,6 @@ func (op *ExtractFunctionOperation) Execute(ws *types.Workspace) (*types.Refacto
 			Description: fmt.Sprintf("Add extracted function %s", op.NewFunctionName),
 		},
 	}
-
-	return &types.RefactoringPlan{
-		Operations:    []types.Operation{op},
-		Changes:       changes,
-		AffectedFiles: []string{op.SourceFile},
-		Impact: &types.ImpactAnalysis{
-			AffectedFiles:    []string{op.SourceFile},
-			AffectedPackages: []string{sourcePackage.Path},
-		},
-		Reversible: true,
-	}, nil
 }
 
 func (op *ExtractFunctionOperation) Description() string {
@@ -1967,7 +2052,7 @@ func (op *ExtractFunctionOperation) basicVariableAnalysis(code string) ([]string
 			continue
 		}
 		// Check if it's a simple lowercase identifier
-		if len(token) > 0 && token[0] >= 'a' && token[0] <= 'z' {
+		if first, _ := utf8.DecodeRuneInString(token); unicode.IsLower(first) {
 			// Special filtering: only include single character variables or common names
 			// This avoids picking up parts of keywords or function names
 			if len(token) == 1 || token == "err" || token == "ctx" {
@@ -2068,12 +2153,18 @@ func (op *ExtractFunctionOperation) findFunctionInsertionPoint(astFile *ast.File
 	return int(astFile.End())
 }
 
-// ExtractInterfaceOperation implements extracting an interface from a struct
+// ExtractInterfaceOperation implements extracting an interface from a struct.
+// The interface is appended to the target file when it exists. The
+// declarations named by Adopt, of the struct's type or a pointer to it, are
+// retyped to the interface; without Methods, the interface gets the methods
+// they are used for.
 type ExtractInterfaceOperation struct {
 	SourceStruct  string
 	InterfaceName string
 	Methods       []string
 	TargetPackage string
+	TargetFile    string   // interfaces.go when empty
+	Adopt         []string // Func.param, Type.Method.param, Type.Field or Var, optionally prefixed with "package:"
 }
 
 func (op *ExtractInterfaceOperation) Type() types.OperationType {
@@ -2093,10 +2184,10 @@ func (op *ExtractInterfaceOperation) Validate(ws *types.Workspace) error {
 			Message: "interface name cannot be empty",
 		}
 	}
-	if len(op.Methods) == 0 {
+	if len(op.Methods) == 0 && len(op.Adopt) == 0 {
 		return &types.RefactorError{
 			Type:    types.InvalidOperation,
-			Message: "methods list cannot be empty",
+			Message: "methods list cannot be empty without declarations to adopt to infer it from",
 		}
 	}
 	if !isValidGoIdentifierExtract(op.InterfaceName) {
@@ -2168,7 +2259,23 @@ func (op *ExtractInterfaceOperation) Validate(ws *types.Workspace) error {
 		}
 	}
 
-	return nil
+	if target := op.targetPackage(ws, sourcePackage); target != nil {
+		if _, err := resolver.ResolveSymbol(target, op.InterfaceName); err == nil {
+			return &types.RefactorError{
+				Type:    types.NameConflict,
+				Message: fmt.Sprintf("package %s already declares %s", target.ImportPath, op.InterfaceName),
+			}
+		}
+	}
+	if strings.ContainsAny(op.TargetFile, `/\`) || op.TargetFile != "" && !strings.HasSuffix(op.TargetFile, ".go") {
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("target file %s must be the name of a .go file in the target package", op.TargetFile),
+		}
+	}
+
+	_, err := op.adoptions(ws, sourcePackage)
+	return err
 }
 
 func (op *ExtractInterfaceOperation) Execute(ws *types.Workspace) (*types.RefactoringPlan, error) {
@@ -2196,32 +2303,72 @@ func (op *ExtractInterfaceOperation) Execute(ws *types.Workspace) (*types.Refact
 		}
 	}
 
+	adopted, err := op.adoptions(ws, sourcePackage)
+	if err != nil {
+		return nil, err
+	}
+
 	// Generate interface definition
-	interfaceCode := op.generateInterface(structSymbol, sourcePackage)
+	interfaceCode := op.generateInterface(ws, sourcePackage)
 
 	// Determine target file (absolute path so the serializer can write it)
-	targetFile := filepath.Join(sourcePackage.Dir, op.getTargetFileName())
-
-	// Generate complete file content including package declaration
-	packageName := op.getTargetPackageName(sourcePackage)
-	fileContent := fmt.Sprintf("package %s\n\n%s\n", packageName, interfaceCode)
-
-	changes := []types.Change{
-		{
-			File:        targetFile,
-			Start:       0, // Insert at beginning for now
-			End:         0,
-			OldText:     "",
-			NewText:     fileContent,
-			Description: fmt.Sprintf("Create interface %s", op.InterfaceName),
-		},
+	targetDir, packageName := op.targetLocation(ws, sourcePackage)
+	targetFile := filepath.Join(targetDir, op.targetFileName())
+
+	change := types.Change{File: targetFile}
+	if content, err := readSource(ws, targetFile); err == nil {
+		// Append to the existing file rather than writing over it
+		change.Start, change.End = len(content), len(content)
+		change.NewText = "\n" + interfaceCode + "\n"
+		if len(content) > 0 && content[len(content)-1] != '\n' {
+			change.NewText = "\n" + change.NewText
+		}
+		change.Description = fmt.Sprintf("Add interface %s", op.InterfaceName)
+	} else {
+		// Generate complete file content including package declaration
+		change.NewText = fmt.Sprintf("package %s\n\n%s\n", packageName, interfaceCode)
+		change.Description = fmt.Sprintf("Create interface %s", op.InterfaceName)
 	}
+	changes := []types.Change{change}
 
-	affectedFiles := []string{targetFile}
-	affectedPackages := []string{sourcePackage.Path}
+	retyped := make(map[token.Pos]bool)
+	for _, d := range adopted {
+		retyped[d.name.Pos()] = true
+	}
+	done := make(map[ast.Node]bool)
+	for _, d := range adopted {
+		var owner ast.Node = d.spec
+		if d.field != nil {
+			owner = d.field
+		}
+		if done[owner] {
+			continue
+		}
+		done[owner] = true
+		name := op.InterfaceName
+		if d.pkg.Dir != targetDir {
+			name = packageName + "." + name
+			if alias, ok := importedName(d.file.AST, op.targetImportPath(ws, targetDir)); ok {
+				name = alias + "." + op.InterfaceName
+			}
+		}
+		changes = append(changes, retypeDecl(ws, d, retyped, name, fmt.Sprintf("Replace type of %s with %s", d.path, op.InterfaceName)))
+	}
 
-	if op.TargetPackage != "" && op.TargetPackage != sourcePackage.Path {
-		affectedPackages = append(affectedPackages, op.TargetPackage)
+	var affectedFiles, affectedPackages []string
+	for _, change := range changes {
+		if !slices.Contains(affectedFiles, change.File) {
+			affectedFiles = append(affectedFiles, change.File)
+		}
+	}
+	affectedPackages = append(affectedPackages, sourcePackage.Path)
+	if targetDir != sourcePackage.Path {
+		affectedPackages = append(affectedPackages, targetDir)
+	}
+	for _, d := range adopted {
+		if !slices.Contains(affectedPackages, d.pkg.Path) {
+			affectedPackages = append(affectedPackages, d.pkg.Path)
+		}
 	}
 
 	return &types.RefactoringPlan{
@@ -2241,114 +2388,292 @@ func (op *ExtractInterfaceOperation) Description() string {
 		op.InterfaceName, op.SourceStruct, strings.Join(op.Methods, " "), op.TargetPackage)
 }
 
-func (op *ExtractInterfaceOperation) generateInterface(structSymbol *types.Symbol, sourcePackage *types.Package) string {
-	var interfaceCode strings.Builder
-	interfaceCode.WriteString(fmt.Sprintf("type %s interface {\n", op.InterfaceName))
-
-	for _, methodName := range op.Methods {
-		if methods, exists := sourcePackage.Symbols.Methods[op.SourceStruct]; exists {
-			for _, method := range methods {
-				if method.Name == methodName {
-					// Extract method signature without receiver
-					signature := op.extractMethodSignature(method.Signature)
-					interfaceCode.WriteString(fmt.Sprintf("\t%s%s\n", methodName, signature))
-					break
-				}
+// generateInterface declares the interface with the methods' parameters and
+// results as the struct's methods spell them.
+func (op *ExtractInterfaceOperation) generateInterface(ws *types.Workspace, sourcePackage *types.Package) string {
+	signatures := make(map[string]string)
+	for _, fileName := range slices.Sorted(maps.Keys(sourcePackage.Files)) {
+		file := sourcePackage.Files[fileName]
+		if file.AST == nil {
+			continue
+		}
+		for _, decl := range file.AST.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok && receiverTypeName(fn) == op.SourceStruct {
+				signatures[fn.Name.Name] = nodeText(ws, file, fn.Type.Params.Pos(), fn.Type.End())
 			}
 		}
 	}
 
+	var interfaceCode strings.Builder
+	fmt.Fprintf(&interfaceCode, "type %s interface {\n", op.InterfaceName)
+	for _, methodName := range op.Methods {
+		if signature, ok := signatures[methodName]; ok {
+			fmt.Fprintf(&interfaceCode, "\t%s%s\n", methodName, signature)
+		}
+	}
 	interfaceCode.WriteString("}")
 	return interfaceCode.String()
 }
 
-func (op *ExtractInterfaceOperation) extractMethodSignature(fullSignature string) string {
-	// Remove receiver from method signature
-	// Example: "func (c *Calculator) Add(a, b float64) float64" -> "(a, b float64) float64"
-
-	if fullSignature == "" {
-		return "()"
+func (op *ExtractInterfaceOperation) targetFileName() string {
+	if op.TargetFile != "" {
+		return op.TargetFile
 	}
+	return "interfaces.go"
+}
 
-	// Method signatures typically look like: "func (receiver Type) MethodName(params) returns"
-	// We want to extract: "(params) returns"
+// targetPackage returns the workspace package the interface is declared in,
+// or nil for a new package.
+func (op *ExtractInterfaceOperation) targetPackage(ws *types.Workspace, sourcePackage *types.Package) *types.Package {
+	if op.TargetPackage == "" {
+		return sourcePackage
+	}
+	return ws.Packages[op.TargetPackage]
+}
 
-	funcStart := strings.Index(fullSignature, "func")
-	if funcStart == -1 {
-		// Not a function signature, return as-is
-		return fullSignature
+// targetLocation returns the directory and name of the package the
+// interface is declared in. A target package the workspace doesn't have is
+// created under the source package's directory.
+func (op *ExtractInterfaceOperation) targetLocation(ws *types.Workspace, sourcePackage *types.Package) (string, string) {
+	if pkg := op.targetPackage(ws, sourcePackage); pkg != nil {
+		return pkg.Dir, pkg.Name
 	}
+	// Extract package name from path
+	parts := strings.Split(op.TargetPackage, "/")
+	return filepath.Join(sourcePackage.Dir, op.TargetPackage), parts[len(parts)-1]
+}
 
-	// Skip past the receiver: func (receiver Type)
-	receiverStart := strings.Index(fullSignature[funcStart:], "(")
-	if receiverStart == -1 {
-		return "()"
+func (op *ExtractInterfaceOperation) targetImportPath(ws *types.Workspace, targetDir string) string {
+	if pkg := ws.Packages[targetDir]; pkg != nil && pkg.ImportPath != "" {
+		return pkg.ImportPath
 	}
-	receiverStart += funcStart
+	return packagePathToImportPath(ws, targetDir)
+}
 
-	// Find the matching closing parenthesis for receiver
-	parenCount := 1
-	receiverEnd := receiverStart + 1
-	for receiverEnd < len(fullSignature) && parenCount > 0 {
-		switch fullSignature[receiverEnd] {
-		case '(':
-			parenCount++
-		case ')':
-			parenCount--
+// adoptions resolves the declarations to retype to the interface, inferring
+// its methods from their uses when none are given. They must be declared as
+// the struct, or a pointer to it when the interface's methods have pointer
+// receivers, and only be used through the interface's methods; uses other
+// than method calls and field selections are left to the build check.
+func (op *ExtractInterfaceOperation) adoptions(ws *types.Workspace, sourcePackage *types.Package) ([]*typeDecl, error) {
+	var decls []*typeDecl
+	pointers := make(map[*typeDecl]bool)
+	specNames := make(map[*ast.ValueSpec]int)
+	for _, target := range op.Adopt {
+		d, err := findTypeDecl(ws, target)
+		if err != nil {
+			return nil, err
+		}
+		if slices.ContainsFunc(decls, func(o *typeDecl) bool { return o.name == d.name }) {
+			continue
+		}
+		pointer, ok := op.namesStruct(d, sourcePackage)
+		if !ok {
+			declared := "untyped"
+			if te := d.typeExpr(); te != nil {
+				declared = "declared as " + gotypes.ExprString(te)
+			}
+			return nil, &types.RefactorError{
+				Type:    types.InvalidOperation,
+				Message: fmt.Sprintf("%s is %s, not %s or *%s", target, declared, op.SourceStruct, op.SourceStruct),
+				File:    d.file.Path,
+			}
 		}
-		receiverEnd++
+		if d.spec != nil {
+			specNames[d.spec]++
+		}
+		pointers[d] = pointer
+		decls = append(decls, d)
 	}
-
-	if receiverEnd >= len(fullSignature) {
-		return "()"
+	for _, d := range decls {
+		if d.spec != nil && specNames[d.spec] < len(d.spec.Names) {
+			return nil, &types.RefactorError{
+				Type:    types.InvalidOperation,
+				Message: fmt.Sprintf("%s shares its declaration with other names; declare it on its own or adopt them all", d.path),
+				File:    d.file.Path,
+			}
+		}
 	}
 
-	// Skip past method name to find parameters
-	remaining := strings.TrimSpace(fullSignature[receiverEnd:])
-
-	// Find method name (skip whitespace and method name)
-	spaceOrParen := -1
-	for i, r := range remaining {
-		if r == ' ' || r == '(' {
-			spaceOrParen = i
-			break
+	if len(op.Methods) == 0 && len(decls) > 0 {
+		methods, err := op.inferMethods(ws, sourcePackage, decls)
+		if err != nil {
+			return nil, err
 		}
+		op.Methods = methods
 	}
+	for _, d := range decls {
+		if method := op.pointerMethod(sourcePackage); !pointers[d] && method != "" {
+			return nil, &types.RefactorError{
+				Type:    types.InvalidOperation,
+				Message: fmt.Sprintf("%s holds a %s, whose method %s has a pointer receiver; declare it as *%s first", d.path, op.SourceStruct, method, op.SourceStruct),
+				File:    d.file.Path,
+			}
+		}
+		for _, sel := range op.selections(ws, d) {
+			if !slices.Contains(op.Methods, sel.name) {
+				return nil, &types.RefactorError{
+					Type:    types.InvalidOperation,
+					Message: fmt.Sprintf("%s is used for %s, which %s doesn't declare", d.path, sel.name, op.InterfaceName),
+					File:    sel.position.Filename,
+					Line:    sel.position.Line,
+					Column:  sel.position.Column,
+				}
+			}
+		}
+	}
+	return decls, nil
+}
 
-	if spaceOrParen == -1 {
-		return "()"
+// inferMethods returns the methods of the struct the declarations are used
+// for, in the order the struct declares them.
+func (op *ExtractInterfaceOperation) inferMethods(ws *types.Workspace, sourcePackage *types.Package, decls []*typeDecl) ([]string, error) {
+	declared := op.methodNames(sourcePackage)
+	used := make(map[string]bool)
+	for _, d := range decls {
+		if d.pkg.TypesInfo == nil {
+			return nil, &types.RefactorError{
+				Type:    types.InvalidOperation,
+				Message: fmt.Sprintf("can't infer the methods %s is used for: package %s doesn't type-check; list the methods", d.path, d.pkg.ImportPath),
+				File:    d.file.Path,
+			}
+		}
+		for _, sel := range op.selections(ws, d) {
+			if !slices.Contains(declared, sel.name) {
+				return nil, &types.RefactorError{
+					Type:    types.InvalidOperation,
+					Message: fmt.Sprintf("%s is used for %s, which isn't a method %s declares", d.path, sel.name, op.SourceStruct),
+					File:    sel.position.Filename,
+					Line:    sel.position.Line,
+					Column:  sel.position.Column,
+				}
+			}
+			used[sel.name] = true
+		}
 	}
 
-	// Find the opening parenthesis of parameters
-	paramStart := strings.Index(remaining[spaceOrParen:], "(")
-	if paramStart == -1 {
-		return "()"
+	var methods []string
+	for _, name := range declared {
+		if used[name] {
+			methods = append(methods, name)
+		}
 	}
-	paramStart += spaceOrParen
+	if len(methods) == 0 {
+		return nil, &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("%s isn't used for any method of %s; list the methods", strings.Join(op.Adopt, ", "), op.SourceStruct),
+		}
+	}
+	return methods, nil
+}
 
-	// Return everything from the parameters onward
-	result := strings.TrimSpace(remaining[paramStart:])
-	if result == "" {
-		return "()"
+// methodNames returns the names of the struct's methods in declaration
+// order.
+func (op *ExtractInterfaceOperation) methodNames(sourcePackage *types.Package) []string {
+	var names []string
+	for _, fileName := range slices.Sorted(maps.Keys(sourcePackage.Files)) {
+		file := sourcePackage.Files[fileName]
+		if file.AST == nil {
+			continue
+		}
+		for _, decl := range file.AST.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok && receiverTypeName(fn) == op.SourceStruct {
+				names = append(names, fn.Name.Name)
+			}
+		}
 	}
+	return names
+}
 
-	return result
+// namesStruct reports whether d is declared as the source struct or, with
+// pointer set, a pointer to it.
+func (op *ExtractInterfaceOperation) namesStruct(d *typeDecl, sourcePackage *types.Package) (pointer, ok bool) {
+	te := d.typeExpr()
+	if star, isStar := te.(*ast.StarExpr); isStar {
+		pointer, te = true, star.X
+	}
+	switch te := te.(type) {
+	case *ast.Ident:
+		return pointer, d.pkg == sourcePackage && te.Name == op.SourceStruct
+	case *ast.SelectorExpr:
+		x, isIdent := te.X.(*ast.Ident)
+		qualifier := sourcePackage.Name
+		if alias, aliased := importedName(d.file.AST, sourcePackage.ImportPath); aliased {
+			qualifier = alias
+		}
+		return pointer, isIdent && d.pkg != sourcePackage && x.Name == qualifier && te.Sel.Name == op.SourceStruct
+	}
+	return false, false
 }
 
-func (op *ExtractInterfaceOperation) getTargetFileName() string {
-	if op.TargetPackage != "" {
-		return op.TargetPackage + "/interfaces.go"
+// pointerMethod returns an interface method the struct declares with a
+// pointer receiver, if any.
+func (op *ExtractInterfaceOperation) pointerMethod(sourcePackage *types.Package) string {
+	for _, fileName := range slices.Sorted(maps.Keys(sourcePackage.Files)) {
+		file := sourcePackage.Files[fileName]
+		if file.AST == nil {
+			continue
+		}
+		for _, decl := range file.AST.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || receiverTypeName(fn) != op.SourceStruct || !slices.Contains(op.Methods, fn.Name.Name) {
+				continue
+			}
+			if _, ok := fn.Recv.List[0].Type.(*ast.StarExpr); ok {
+				return fn.Name.Name
+			}
+		}
 	}
-	return "interfaces.go"
+	return ""
 }
 
-func (op *ExtractInterfaceOperation) getTargetPackageName(sourcePackage *types.Package) string {
-	if op.TargetPackage != "" {
-		// Extract package name from path
-		parts := strings.Split(op.TargetPackage, "/")
-		return parts[len(parts)-1]
+// selection is a method called or field selected on an adopted
+// declaration.
+type selection struct {
+	name     string
+	position token.Position
+}
+
+// selections returns the methods called and fields selected on d in the
+// packages that are type-checked.
+func (op *ExtractInterfaceOperation) selections(ws *types.Workspace, d *typeDecl) []selection {
+	if d.pkg.TypesInfo == nil || d.pkg.TypesInfo.Defs[d.name] == nil {
+		return nil
+	}
+	obj := d.pkg.TypesInfo.Defs[d.name]
+	var sels []selection
+	for _, p := range slices.Sorted(maps.Keys(ws.Packages)) {
+		pkg := ws.Packages[p]
+		if pkg.TypesInfo == nil {
+			continue
+		}
+		for _, fileName := range slices.Sorted(maps.Keys(pkg.Files)) {
+			file := pkg.Files[fileName]
+			if file.AST == nil {
+				continue
+			}
+			ast.Inspect(file.AST, func(n ast.Node) bool {
+				sel, ok := n.(*ast.SelectorExpr)
+				if !ok {
+					return true
+				}
+				var id *ast.Ident
+				switch x := ast.Unparen(sel.X).(type) {
+				case *ast.Ident:
+					id = x
+				case *ast.SelectorExpr:
+					id = x.Sel
+				}
+				// Packages type-checked apart hold their own objects for
+				// the declaration, at the same position
+				if used := pkg.TypesInfo.Uses[id]; id != nil && used != nil && used.Pos() == obj.Pos() {
+					sels = append(sels, selection{name: sel.Sel.Name, position: ws.FileSet.Position(sel.Sel.Pos())})
+				}
+				return true
+			})
+		}
 	}
-	return sourcePackage.Name
+	return sels
 }
 
 // ExtractVariableOperation implements extracting a variable from an expression
@@ -2358,6 +2683,7 @@ type ExtractVariableOperation struct {
 	EndLine      int
 	VariableName string
 	Expression   string
+	Backend      ChangeBackend // ChangeBackendAST when empty
 }
 
 func (op *ExtractVariableOperation) Type() types.OperationType {
@@ -2421,6 +2747,92 @@ func (op *ExtractVariableOperation) Execute(ws *types.Workspace) (*types.Refacto
 	// Resolve absolute path for the serializer
 	absPath := filepath.Join(sourcePackage.Dir, op.SourceFile)
 
+	var changes []types.Change
+	if op.Backend == ChangeBackendText {
+		changes = op.textChanges(sourceFile, absPath)
+	} else {
+		var err error
+		if changes, err = op.rewriteChanges(sourceFile, absPath); err != nil {
+			return nil, err
+		}
+	}
+
+	return &types.RefactoringPlan{
+		Operations:    []types.Operation{op},
+		Changes:       changes,
+		AffectedFiles: []string{absPath},
+		Impact: &types.ImpactAnalysis{
+			AffectedFiles:    []string{absPath},
+			AffectedPackages: []string{sourcePackage.Path},
+		},
+		Reversible: true,
+	}, nil
+}
+
+// rewriteChanges declares the variable before the statement holding the
+// first occurrence of the expression on the operation's lines and replaces
+// that occurrence with it.
+func (op *ExtractVariableOperation) rewriteChanges(sourceFile *types.File, absPath string) ([]types.Change, error) {
+	r, err := newASTRewrite(absPath, sourceFile.OriginalContent)
+	if err != nil {
+		return nil, err
+	}
+
+	want := strings.Join(strings.Fields(op.Expression), "")
+	var expr ast.Expr
+	var stmt ast.Stmt
+	var path []ast.Node // nodes enclosing the current one
+	ast.Inspect(r.ast, func(n ast.Node) bool {
+		if n == nil {
+			path = path[:len(path)-1]
+			return true
+		}
+		if expr != nil {
+			return false
+		}
+		if e, ok := n.(ast.Expr); ok {
+			line := r.fset.Position(e.Pos()).Line
+			text := string(r.src[r.offset(e.Pos()):r.offset(e.End())])
+			if line >= op.StartLine && line <= op.EndLine && strings.Join(strings.Fields(text), "") == want {
+				expr = e
+				stmt = listStmt(path)
+				return false
+			}
+		}
+		path = append(path, n)
+		return true
+	})
+	if expr == nil || stmt == nil {
+		return nil, &types.RefactorError{
+			Type:    types.SymbolNotFound,
+			Message: fmt.Sprintf("expression %q not found in a function on lines %d-%d of %s", op.Expression, op.StartLine, op.EndLine, op.SourceFile),
+		}
+	}
+
+	r.insert(stmt.Pos(), fmt.Sprintf("%s := %s\n%s", op.VariableName, op.Expression, r.indentAt(stmt.Pos())),
+		fmt.Sprintf("Declare extracted variable %s", op.VariableName))
+	r.replace(expr, expr, op.VariableName, fmt.Sprintf("Replace expression with variable %s", op.VariableName))
+	return r.changes()
+}
+
+// listStmt returns the innermost statement of path that is an element of
+// a statement list, or nil.
+func listStmt(path []ast.Node) ast.Stmt {
+	for i := len(path) - 1; i > 0; i-- {
+		s, ok := path[i].(ast.Stmt)
+		if !ok {
+			continue
+		}
+		switch path[i-1].(type) {
+		case *ast.BlockStmt, *ast.CaseClause, *ast.CommClause:
+			return s
+		}
+	}
+	return nil
+}
+
+// textChanges computes the changes from line offsets.
+func (op *ExtractVariableOperation) textChanges(sourceFile *types.File, absPath string) []types.Change {
 	// Find insertion point for variable declaration (before the expression usage)
 	insertionPoint := op.findVariableInsertionPoint(string(sourceFile.OriginalContent), op.StartLine)
 
@@ -2447,17 +2859,7 @@ func (op *ExtractVariableOperation) Execute(ws *types.Workspace) (*types.Refacto
 			Description: fmt.Sprintf("Replace expression with variable %s", op.VariableName),
 		},
 	}
-
-	return &types.RefactoringPlan{
-		Operations:    []types.Operation{op},
-		Changes:       changes,
-		AffectedFiles: []string{absPath},
-		Impact: &types.ImpactAnalysis{
-			AffectedFiles:    []string{absPath},
-			AffectedPackages: []string{sourcePackage.Path},
-		},
-		Reversible: true,
-	}, nil
+	return changes
 }
 
 func (op *ExtractVariableOperation) Description() string {
@@ -2497,6 +2899,7 @@ type ExtractBlockOperation struct {
 	SourceFile      string
 	Position        int // Line or character position within the target block
 	NewFunctionName string
+	Backend         ChangeBackend // ChangeBackendAST when empty
 }
 
 func (op *ExtractBlockOperation) Type() types.OperationType {
@@ -2616,6 +3019,7 @@ func (op *ExtractBlockOperation) Execute(ws *types.Workspace) (*types.Refactorin
 		StartLine:       blockInfo.StartLine,
 		EndLine:         blockInfo.EndLine,
 		NewFunctionName: op.NewFunctionName,
+		Backend:         op.Backend,
 	}
 
 	// Validate the function operation
@@ -2783,25 +3187,7 @@ func getLineOffset(content string, line int) int {
 	return offset
 }
 
-// isValidGoIdentifier checks if identifier is valid
-// Note: This is already defined in operations.go, but we need it here too
+// isValidGoIdentifierExtract checks if identifier is valid
 func isValidGoIdentifierExtract(name string) bool {
-	if name == "" {
-		return false
-	}
-
-	// Check first character
-	first := rune(name[0])
-	if (first < 'a' || first > 'z') && (first < 'A' || first > 'Z') && first != '_' {
-		return false
-	}
-
-	// Check remaining characters
-	for _, r := range name[1:] {
-		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (r < '0' || r > '9') && r != '_' {
-			return false
-		}
-	}
-
-	return true
+	return isValidGoIdentifier(name)
 }
@@ -0,0 +1,504 @@
+package refactor
+
+import (
+	"cmp"
+	"fmt"
+	"go/ast"
+	"go/token"
+	gotypes "go/types"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mamaar/gorefactor/pkg/analysis"
+	"github.com/mamaar/gorefactor/pkg/analyzers/pipeline"
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// ExtractPipelineStagesOperation moves the stages pipeline.Split finds in
+// a loop body into functions declared after the enclosing function, and
+// replaces each stage with a call. A stage function takes the variables
+// the stage reads and returns those later stages read; stages that
+// continue the loop also return whether the iteration goes on. Stages
+// assigning variables declared outside them stay inline, since the copy a
+// function receives wouldn't carry the change back.
+type ExtractPipelineStagesOperation struct {
+	Request types.ExtractPipelineStagesRequest
+	Parser  *analysis.GoParser
+
+	// Resolved by Validate
+	file   *types.File
+	pkg    *types.Package
+	fn     *ast.FuncDecl
+	loop   ast.Stmt
+	stages []*stagePlan  // Stages to extract
+	inline []types.Issue // Why the other stages stay inline
+}
+
+// stagePlan is a stage of the loop and the function it moves into.
+type stagePlan struct {
+	*pipeline.Stage
+	name    string
+	params  []*gotypes.Var
+	results []*gotypes.Var
+}
+
+func (op *ExtractPipelineStagesOperation) Type() types.OperationType {
+	return types.ExtractPipelineStagesOperation
+}
+
+func (op *ExtractPipelineStagesOperation) Description() string {
+	return fmt.Sprintf("Extract pipeline stages of the loop at %s:%d", op.Request.SourceFile, op.Request.Line)
+}
+
+func (op *ExtractPipelineStagesOperation) Validate(ws *types.Workspace) error {
+	req := op.Request
+	for _, pkg := range ws.Packages {
+		for _, f := range pkg.Files {
+			if f.Path == req.SourceFile && f.AST != nil {
+				op.file, op.pkg = f, pkg
+			}
+		}
+	}
+	if op.file == nil {
+		return &types.RefactorError{
+			Type:    types.SymbolNotFound,
+			Message: fmt.Sprintf("source file not found: %s", req.SourceFile),
+		}
+	}
+
+	for _, decl := range op.file.AST.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Body == nil {
+			continue
+		}
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			if op.loop != nil {
+				return false
+			}
+			if pipeline.LoopBody(n) != nil && ws.FileSet.Position(n.Pos()).Line == req.Line {
+				op.fn, op.loop = fn, n.(ast.Stmt)
+			}
+			return true
+		})
+	}
+	if op.loop == nil {
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("no for statement at %s:%d", req.SourceFile, req.Line),
+		}
+	}
+	if op.fn.Type.TypeParams != nil || op.fn.Recv != nil && receiverTypeParams(op.fn) {
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("%s is generic; its stages can't be extracted", op.fn.Name.Name),
+		}
+	}
+
+	stages := pipeline.Split(ws.FileSet, op.loop, cmp.Or(req.MaxLive, pipeline.DefaultMaxLive), pipeline.DefaultMinStageStatements)
+	if len(stages) < 2 {
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("the loop at %s:%d doesn't split into stages", req.SourceFile, req.Line),
+		}
+	}
+	if len(req.StageNames) > len(stages) {
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("%d stage names given for %d stages", len(req.StageNames), len(stages)),
+		}
+	}
+
+	if op.Parser != nil {
+		op.Parser.EnsureTypeChecked(ws, op.pkg)
+	}
+	info := op.pkg.TypesInfo
+	if info == nil {
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("package %s has no type information", op.pkg.ImportPath),
+		}
+	}
+
+	taken := packageNames(op.pkg)
+	var reasons []string
+	for i, stage := range stages {
+		sp, reason := op.plan(info, stage)
+		if sp == nil {
+			reasons = append(reasons, fmt.Sprintf("%s stage (lines %d-%d) %s", stage.Kind, stage.StartLine, stage.EndLine, reason))
+			op.inline = append(op.inline, types.Issue{
+				Type:        types.IssueCompilationError,
+				Description: fmt.Sprintf("%s stage stays inline: it %s", stage.Kind, reason),
+				File:        op.file.Path,
+				Line:        stage.StartLine,
+				Severity:    types.Info,
+			})
+			continue
+		}
+		if i < len(req.StageNames) && req.StageNames[i] != "" {
+			sp.name = req.StageNames[i]
+		} else {
+			sp.name = stageName(sp)
+			for n := 2; taken[sp.name]; n++ {
+				sp.name = stageName(sp) + strconv.Itoa(n)
+			}
+		}
+		switch {
+		case !isValidGoIdentifier(sp.name) || token.IsKeyword(sp.name):
+			return &types.RefactorError{
+				Type:    types.InvalidOperation,
+				Message: fmt.Sprintf("%q is not a valid function name", sp.name),
+			}
+		case taken[sp.name]:
+			return &types.RefactorError{
+				Type:    types.NameConflict,
+				Message: fmt.Sprintf("%s is already declared in package %s", sp.name, op.pkg.ImportPath),
+			}
+		}
+		taken[sp.name] = true
+		op.stages = append(op.stages, sp)
+	}
+	if len(op.stages) == 0 {
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: "no stage can be extracted: " + strings.Join(reasons, "; "),
+		}
+	}
+	return nil
+}
+
+// plan works out the parameters and results of stage's function, or
+// returns why the stage can't move into one.
+func (op *ExtractPipelineStagesOperation) plan(info *gotypes.Info, stage *pipeline.Stage) (*stagePlan, string) {
+	body := pipeline.LoopBody(op.loop).List
+	stmts := body[stage.Start:stage.End]
+	start, end := stmts[0].Pos(), stmts[len(stmts)-1].End()
+	inStage := func(pos token.Pos) bool { return start <= pos && pos < end }
+	// outer reports whether obj is declared in the function but not in the stage.
+	outer := func(obj gotypes.Object) bool {
+		return obj != nil && op.fn.Pos() <= obj.Pos() && obj.Pos() < op.fn.End() && !inStage(obj.Pos())
+	}
+
+	sp := &stagePlan{Stage: stage}
+	reason := ""
+	for _, stmt := range stmts {
+		ast.Inspect(stmt, func(n ast.Node) bool {
+			if reason != "" {
+				return false
+			}
+			var targets []ast.Expr
+			switch x := n.(type) {
+			case *ast.Ident:
+				obj := info.Uses[x]
+				if !outer(obj) {
+					return true
+				}
+				v, ok := obj.(*gotypes.Var)
+				switch {
+				case !ok:
+					reason = fmt.Sprintf("uses %s, declared in %s", x.Name, op.fn.Name.Name)
+				case localType(v.Type()):
+					reason = fmt.Sprintf("uses %s, whose type is declared in %s", x.Name, op.fn.Name.Name)
+				case !v.IsField() && !slices.Contains(sp.params, v):
+					sp.params = append(sp.params, v)
+				}
+			case *ast.AssignStmt:
+				targets = x.Lhs
+			case *ast.IncDecStmt:
+				targets = []ast.Expr{x.X}
+			case *ast.RangeStmt:
+				if x.Tok == token.ASSIGN {
+					targets = []ast.Expr{x.Key, x.Value}
+				}
+			case *ast.UnaryExpr:
+				if x.Op == token.AND {
+					targets = []ast.Expr{x.X}
+				}
+			case *ast.SelectorExpr:
+				// Calling a pointer method on a variable takes its address.
+				if sel := info.Selections[x]; sel != nil && sel.Kind() == gotypes.MethodVal {
+					if sig, ok := sel.Obj().Type().(*gotypes.Signature); ok && sig.Recv() != nil && isPointer(sig.Recv().Type()) && !isPointer(info.TypeOf(x.X)) {
+						targets = []ast.Expr{x.X}
+					}
+				}
+			}
+			for _, t := range targets {
+				if v := assignedVar(info, t); v != nil && outer(v) {
+					reason = fmt.Sprintf("assigns %s, declared outside the stage", v.Name())
+				}
+			}
+			return reason == ""
+		})
+	}
+	if reason != "" {
+		return nil, reason
+	}
+
+	for _, id := range definedIdents(stmts) {
+		v, ok := info.Defs[id].(*gotypes.Var)
+		if !ok || !usedIn(info, body[stage.End:], v) {
+			continue
+		}
+		if localType(v.Type()) {
+			return nil, fmt.Sprintf("defines %s, whose type is declared in %s", v.Name(), op.fn.Name.Name)
+		}
+		sp.results = append(sp.results, v)
+	}
+	return sp, ""
+}
+
+func (op *ExtractPipelineStagesOperation) Execute(ws *types.Workspace) (*types.RefactoringPlan, error) {
+	var imports []string
+	qualifier := fileQualifier(op.file, op.pkg.ImportPath, &imports)
+	typeOf := func(v *gotypes.Var) string { return gotypes.TypeString(v.Type(), qualifier) }
+	ok := op.okName()
+
+	var funcs strings.Builder
+	var changes []types.Change
+	for _, sp := range op.stages {
+		var params, args, resultTypes, resultNames, zeros []string
+		for _, v := range sp.params {
+			params = append(params, v.Name()+" "+typeOf(v))
+			args = append(args, v.Name())
+		}
+		for _, v := range sp.results {
+			resultTypes = append(resultTypes, typeOf(v))
+			resultNames = append(resultNames, v.Name())
+			zeros = append(zeros, zeroValue(v.Type(), qualifier))
+		}
+		if sp.Filter {
+			resultTypes = append(resultTypes, "bool")
+		}
+
+		fmt.Fprintf(&funcs, "\n// %s is the %s stage of the loop in %s.\n", sp.name, sp.Kind, op.fn.Name.Name)
+		fmt.Fprintf(&funcs, "func %s(%s)", sp.name, strings.Join(params, ", "))
+		switch len(resultTypes) {
+		case 0:
+		case 1:
+			funcs.WriteString(" " + resultTypes[0])
+		default:
+			funcs.WriteString(" (" + strings.Join(resultTypes, ", ") + ")")
+		}
+		fmt.Fprintf(&funcs, " {\n%s\n", op.stageBody(ws, sp, strings.Join(append(zeros, "false"), ", ")))
+		switch {
+		case sp.Filter:
+			fmt.Fprintf(&funcs, "\treturn %s\n", strings.Join(append(resultNames, "true"), ", "))
+		case len(resultNames) > 0:
+			fmt.Fprintf(&funcs, "\treturn %s\n", strings.Join(resultNames, ", "))
+		}
+		funcs.WriteString("}\n")
+
+		call := fmt.Sprintf("%s(%s)", sp.name, strings.Join(args, ", "))
+		switch {
+		case sp.Filter && len(resultNames) == 0:
+			call = fmt.Sprintf("if !%s {\n\tcontinue\n}", call)
+		case sp.Filter:
+			call = fmt.Sprintf("%s, %s := %s\nif !%s {\n\tcontinue\n}", strings.Join(resultNames, ", "), ok, call, ok)
+		case len(resultNames) > 0:
+			call = strings.Join(resultNames, ", ") + " := " + call
+		}
+		stmts := pipeline.LoopBody(op.loop).List[sp.Start:sp.End]
+		start, end := stmts[0].Pos(), stmts[len(stmts)-1].End()
+		changes = append(changes, types.Change{
+			File:        op.file.Path,
+			Start:       ws.FileSet.Position(start).Offset,
+			End:         ws.FileSet.Position(end).Offset,
+			OldText:     nodeText(ws, op.file, start, end),
+			NewText:     indentLines(call, indentAt(op.file.OriginalContent, ws.FileSet.Position(start).Offset)),
+			Description: fmt.Sprintf("Replace %s stage with call to %s", sp.Kind, sp.name),
+		})
+	}
+
+	changes = append(changes, insertAt(op.file.Path, ws.FileSet.Position(op.fn.End()).Offset, "\n"+funcs.String(), "Add stage functions of the loop in "+op.fn.Name.Name))
+	for _, path := range imports {
+		if change := generateAddImportChange(ws, op.file.Path, path); change != nil {
+			changes = append(changes, *change)
+		}
+	}
+	slices.SortFunc(changes, func(a, b types.Change) int { return cmp.Compare(a.Start, b.Start) })
+
+	return &types.RefactoringPlan{
+		Changes:       changes,
+		AffectedFiles: []string{op.file.Path},
+		Impact: &types.ImpactAnalysis{
+			AffectedPackages: []string{op.pkg.Path},
+			AffectedFiles:    []string{op.file.Path},
+			PotentialIssues:  op.inline,
+		},
+		Reversible: true,
+	}, nil
+}
+
+// stageBody returns the source of sp's statements with each continue of
+// the loop replaced by a return of zeros.
+func (op *ExtractPipelineStagesOperation) stageBody(ws *types.Workspace, sp *stagePlan, zeros string) string {
+	stmts := pipeline.LoopBody(op.loop).List[sp.Start:sp.End]
+	base := ws.FileSet.Position(stmts[0].Pos()).Offset
+	text := nodeText(ws, op.file, stmts[0].Pos(), stmts[len(stmts)-1].End())
+	var continues []*ast.BranchStmt
+	for _, stmt := range stmts {
+		ast.Inspect(stmt, func(n ast.Node) bool {
+			switch x := n.(type) {
+			case *ast.FuncLit, *ast.ForStmt, *ast.RangeStmt:
+				return false
+			case *ast.BranchStmt:
+				if x.Tok == token.CONTINUE {
+					continues = append(continues, x)
+				}
+			}
+			return true
+		})
+	}
+	for _, c := range slices.Backward(continues) {
+		s := ws.FileSet.Position(c.Pos()).Offset - base
+		e := ws.FileSet.Position(c.End()).Offset - base
+		text = text[:s] + "return " + zeros + text[e:]
+	}
+	return "\t" + text
+}
+
+// okName returns a name for the flag filter stages return that the loop's
+// function doesn't use yet.
+func (op *ExtractPipelineStagesOperation) okName() string {
+	for i := 1; ; i++ {
+		name := "ok"
+		if i > 1 {
+			name += strconv.Itoa(i)
+		}
+		if !mentions(op.fn.Body, name) {
+			return name
+		}
+	}
+}
+
+// stageName names a stage after its kind and the first value it passes on,
+// or for the last stage the first value it takes: produceRecord.
+func stageName(sp *stagePlan) string {
+	switch {
+	case len(sp.results) > 0:
+		return sp.Kind + upperFirst(sp.results[0].Name())
+	case len(sp.params) > 0:
+		return sp.Kind + upperFirst(sp.params[0].Name())
+	}
+	return sp.Kind
+}
+
+// assignedVar returns the variable whose value an assignment to x changes:
+// x itself, or the struct or array x selects from. Assignments through
+// pointers, slices and maps change shared memory and return nil.
+func assignedVar(info *gotypes.Info, x ast.Expr) *gotypes.Var {
+	switch e := ast.Unparen(x).(type) {
+	case *ast.Ident:
+		v, _ := info.Uses[e].(*gotypes.Var)
+		return v
+	case *ast.SelectorExpr:
+		if info.Selections[e] != nil && !isPointer(info.TypeOf(e.X)) {
+			return assignedVar(info, e.X)
+		}
+	case *ast.IndexExpr:
+		if t := info.TypeOf(e.X); t != nil {
+			if _, ok := t.Underlying().(*gotypes.Array); ok {
+				return assignedVar(info, e.X)
+			}
+		}
+	}
+	return nil
+}
+
+// usedIn reports whether any of stmts refers to v.
+func usedIn(info *gotypes.Info, stmts []ast.Stmt, v *gotypes.Var) bool {
+	found := false
+	for _, stmt := range stmts {
+		ast.Inspect(stmt, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok && info.Uses[id] == v {
+				found = true
+			}
+			return !found
+		})
+	}
+	return found
+}
+
+// localType reports whether t refers to a type declared inside a function.
+func localType(t gotypes.Type) bool {
+	switch x := t.(type) {
+	case *gotypes.Named:
+		obj := x.Obj()
+		return obj.Pkg() != nil && obj.Parent() != nil && obj.Parent() != obj.Pkg().Scope()
+	case *gotypes.Pointer:
+		return localType(x.Elem())
+	case *gotypes.Slice:
+		return localType(x.Elem())
+	case *gotypes.Array:
+		return localType(x.Elem())
+	case *gotypes.Chan:
+		return localType(x.Elem())
+	case *gotypes.Map:
+		return localType(x.Key()) || localType(x.Elem())
+	}
+	return false
+}
+
+func isPointer(t gotypes.Type) bool {
+	if t == nil {
+		return false
+	}
+	_, ok := t.Underlying().(*gotypes.Pointer)
+	return ok
+}
+
+// zeroValue returns the literal of t's zero value.
+func zeroValue(t gotypes.Type, q gotypes.Qualifier) string {
+	switch u := t.Underlying().(type) {
+	case *gotypes.Basic:
+		switch {
+		case u.Info()&gotypes.IsBoolean != 0:
+			return "false"
+		case u.Info()&gotypes.IsString != 0:
+			return `""`
+		case u.Info()&gotypes.IsNumeric != 0:
+			return "0"
+		}
+	case *gotypes.Struct, *gotypes.Array:
+		return gotypes.TypeString(t, q) + "{}"
+	}
+	return "nil"
+}
+
+// receiverTypeParams reports whether fn's receiver is a generic type.
+func receiverTypeParams(fn *ast.FuncDecl) bool {
+	t := fn.Recv.List[0].Type
+	if star, ok := t.(*ast.StarExpr); ok {
+		t = star.X
+	}
+	switch t.(type) {
+	case *ast.IndexExpr, *ast.IndexListExpr:
+		return true
+	}
+	return false
+}
+
+// packageNames returns the package-level names declared by pkg's files.
+func packageNames(pkg *types.Package) map[string]bool {
+	names := make(map[string]bool)
+	if pkg.TypesPkg != nil {
+		for _, name := range pkg.TypesPkg.Scope().Names() {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// indentAt returns the whitespace starting the line of offset.
+func indentAt(content []byte, offset int) string {
+	start := offset
+	for start > 0 && (content[start-1] == ' ' || content[start-1] == '\t') {
+		start--
+	}
+	return string(content[start:offset])
+}
+
+// indentLines indents every line of text but the first.
+func indentLines(text, indent string) string {
+	return strings.ReplaceAll(text, "\n", "\n"+indent)
+}
@@ -0,0 +1,621 @@
+package refactor
+
+import (
+	"cmp"
+	"fmt"
+	"go/ast"
+	"go/token"
+	gotypes "go/types"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/mamaar/gorefactor/pkg/analysis"
+	"github.com/mamaar/gorefactor/pkg/analyzers/testsetup"
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// ExtractTestHelperOperation moves setup statements repeated at the start
+// of several tests, as found by testsetup.FindDuplicates, into a helper the
+// tests call, or into TestMain when every test of the package repeats them
+// and they don't use t. Helpers call t.Helper(), return the variables the
+// tests go on to use and turn deferred calls into t.Cleanup callbacks, which
+// still run when the test ends.
+type ExtractTestHelperOperation struct {
+	Request types.ExtractTestHelperRequest
+	Parser  *analysis.GoParser // Type-checks the tests; without it setup can't return variables
+
+	// Resolved by Validate
+	pkg    *types.Package
+	info   *gotypes.Info
+	files  map[*ast.File]*types.File
+	groups []*testsetup.Group
+	names  []string // Helper name of each group
+}
+
+func (op *ExtractTestHelperOperation) Type() types.OperationType {
+	return types.ExtractTestHelperOperation
+}
+
+func (op *ExtractTestHelperOperation) Description() string {
+	return fmt.Sprintf("Extract repeated test setup in %s", op.Request.Package)
+}
+
+func (op *ExtractTestHelperOperation) Validate(ws *types.Workspace) error {
+	req := op.Request
+	pkg, ok := ws.Packages[req.Package]
+	if !ok {
+		return &types.RefactorError{
+			Type:    types.SymbolNotFound,
+			Message: fmt.Sprintf("package not found: %s", req.Package),
+		}
+	}
+	op.pkg = pkg
+	op.files = make(map[*ast.File]*types.File)
+	var astFiles []*ast.File
+	for _, name := range slices.Sorted(maps.Keys(pkg.TestFiles)) {
+		if f := pkg.TestFiles[name]; f.AST != nil {
+			op.files[f.AST] = f
+			astFiles = append(astFiles, f.AST)
+		}
+	}
+	if len(astFiles) == 0 {
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("package %s has no test files", pkg.ImportPath),
+		}
+	}
+
+	for _, g := range testsetup.FindDuplicates(ws.FileSet, astFiles, cmp.Or(req.MinStatements, testsetup.DefaultMinStatements)) {
+		if req.Test == "" || slices.ContainsFunc(g.Tests, func(fd *ast.FuncDecl) bool { return fd.Name.Name == req.Test }) {
+			op.groups = append(op.groups, g)
+		}
+	}
+	switch {
+	case len(op.groups) == 0 && req.Test != "":
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("%s shares no setup with other tests", req.Test),
+		}
+	case len(op.groups) == 0:
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("no setup is repeated across the tests of %s", pkg.ImportPath),
+		}
+	case len(op.groups) > 1 && (req.HelperName != "" || req.TestMain):
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("%d different setups are repeated; choose one by naming a test", len(op.groups)),
+		}
+	}
+
+	if op.Parser != nil {
+		op.info = op.Parser.TypeCheckTests(ws, pkg)
+	}
+	if req.TestMain {
+		return op.checkTestMain(op.groups[0])
+	}
+
+	for _, g := range op.groups {
+		taken := op.declaredNames(g.Files[0].Name.Name)
+		name := req.HelperName
+		if name == "" {
+			name = helperName(g)
+			for i := 2; taken[name]; i++ {
+				name = helperName(g) + strconv.Itoa(i)
+			}
+		}
+		switch {
+		case !isValidGoIdentifier(name) || token.IsKeyword(name):
+			return &types.RefactorError{
+				Type:    types.InvalidOperation,
+				Message: fmt.Sprintf("%q is not a valid helper name", name),
+			}
+		case taken[name] || slices.Contains(op.names, name):
+			return &types.RefactorError{
+				Type:    types.NameConflict,
+				Message: fmt.Sprintf("%s is already declared in the tests of %s", name, pkg.ImportPath),
+			}
+		}
+		if _, err := op.results(g); err != nil {
+			return err
+		}
+		op.names = append(op.names, name)
+	}
+	return nil
+}
+
+// checkTestMain reports why g's setup can't run once in TestMain: it must be
+// shared by every test of the package, not use t and define nothing the
+// tests use afterwards.
+func (op *ExtractTestHelperOperation) checkTestMain(g *testsetup.Group) error {
+	clause := g.Files[0].Name.Name
+	for f := range op.files {
+		if f.Name.Name != clause {
+			continue
+		}
+		for _, decl := range f.Decls {
+			fd, ok := decl.(*ast.FuncDecl)
+			if !ok || fd.Recv != nil {
+				continue
+			}
+			if fd.Name.Name == "TestMain" {
+				return &types.RefactorError{
+					Type:    types.NameConflict,
+					Message: fmt.Sprintf("the tests of %s already have a TestMain", op.pkg.ImportPath),
+				}
+			}
+			if testsetup.IsTest(fd) && !slices.Contains(g.Tests, fd) {
+				return &types.RefactorError{
+					Type:    types.InvalidOperation,
+					Message: fmt.Sprintf("%s doesn't share the setup, and TestMain would run it for every test", fd.Name.Name),
+				}
+			}
+		}
+	}
+	for i, test := range g.Tests {
+		param := test.Type.Params.List[0].Names[0].Name
+		for _, stmt := range g.Setup(i) {
+			if mentions(stmt, param) {
+				return &types.RefactorError{
+					Type:    types.InvalidOperation,
+					Message: fmt.Sprintf("the setup in %s uses %s, which TestMain doesn't have", test.Name.Name, param),
+				}
+			}
+		}
+	}
+	results, err := op.results(g)
+	if err != nil {
+		return err
+	}
+	if len(results) > 0 {
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("the tests use %s after the setup; only a helper can return it", results[0].Name()),
+		}
+	}
+	return nil
+}
+
+func (op *ExtractTestHelperOperation) Execute(ws *types.Workspace) (*types.RefactoringPlan, error) {
+	var changes []types.Change
+	for i, g := range op.groups {
+		var gc []types.Change
+		var err error
+		if op.Request.TestMain {
+			gc, err = op.testMainChanges(ws, g)
+		} else {
+			gc, err = op.helperChanges(ws, g, op.names[i])
+		}
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, gc...)
+	}
+
+	slices.SortFunc(changes, func(a, b types.Change) int {
+		return cmp.Or(cmp.Compare(a.File, b.File), cmp.Compare(a.Start, b.Start))
+	})
+	var affected []string
+	for _, c := range changes {
+		if !slices.Contains(affected, c.File) {
+			affected = append(affected, c.File)
+		}
+	}
+
+	return &types.RefactoringPlan{
+		Changes:       changes,
+		AffectedFiles: affected,
+		Impact: &types.ImpactAnalysis{
+			AffectedPackages: []string{op.pkg.Path},
+			AffectedFiles:    affected,
+		},
+		Reversible: true,
+	}, nil
+}
+
+// helperChanges adds the helper after the first test's file and replaces
+// the setup of every test with a call to it.
+func (op *ExtractTestHelperOperation) helperChanges(ws *types.Workspace, g *testsetup.Group, name string) ([]types.Change, error) {
+	results, err := op.results(g)
+	if err != nil {
+		return nil, err
+	}
+	home := homeTest(g)
+	file := op.files[g.Files[home]]
+	param := g.Tests[home].Type.Params.List[0].Names[0].Name
+
+	var imports []string
+	self := op.pkg.ImportPath
+	if file.AST.Name.Name != op.pkg.Name {
+		self += "_test"
+	}
+	qualifier := fileQualifier(file, self, &imports)
+	var resultNames, resultTypes []string
+	for _, r := range results {
+		resultNames = append(resultNames, r.Name())
+		resultTypes = append(resultTypes, gotypes.TypeString(r.Type(), qualifier))
+	}
+	var testNames []string
+	for _, test := range g.Tests {
+		testNames = append(testNames, test.Name.Name)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n// %s holds the setup shared by %s.\n", name, strings.Join(testNames, ", "))
+	fmt.Fprintf(&b, "func %s(%s *testing.T)", name, param)
+	switch len(resultTypes) {
+	case 0:
+	case 1:
+		b.WriteString(" " + resultTypes[0])
+	default:
+		b.WriteString(" (" + strings.Join(resultTypes, ", ") + ")")
+	}
+	fmt.Fprintf(&b, " {\n\t%s.Helper()\n\t%s\n", param, op.setupText(ws, file, g.Setup(home), param))
+	if len(resultNames) > 0 {
+		fmt.Fprintf(&b, "\treturn %s\n", strings.Join(resultNames, ", "))
+	}
+	b.WriteString("}\n")
+
+	end := len(file.OriginalContent)
+	text := b.String()
+	if end > 0 && file.OriginalContent[end-1] != '\n' {
+		text = "\n" + text
+	}
+	changes := []types.Change{insertAt(file.Path, end, text, "Add test helper "+name)}
+	for _, path := range imports {
+		if change := generateAddImportChange(ws, file.Path, path); change != nil {
+			changes = append(changes, *change)
+		}
+	}
+
+	for i, test := range g.Tests {
+		f := op.files[g.Files[i]]
+		setup := g.Setup(i)
+		call := fmt.Sprintf("%s(%s)", name, test.Type.Params.List[0].Names[0].Name)
+		lhs := make([]string, len(results))
+		assigned := false
+		for j, r := range results {
+			lhs[j] = "_"
+			if op.usedAfter(g, i, r.Name()) {
+				lhs[j] = r.Name()
+				assigned = true
+			}
+		}
+		if assigned {
+			call = strings.Join(lhs, ", ") + " := " + call
+		}
+		changes = append(changes, types.Change{
+			File:        f.Path,
+			Start:       ws.FileSet.Position(setup[0].Pos()).Offset,
+			End:         ws.FileSet.Position(setup[len(setup)-1].End()).Offset,
+			OldText:     nodeText(ws, f, setup[0].Pos(), setup[len(setup)-1].End()),
+			NewText:     call,
+			Description: fmt.Sprintf("Replace repeated setup in %s with call to %s", test.Name.Name, name),
+		})
+	}
+	changes = append(changes, op.unusedImports(ws, g, g.Files[home])...)
+	return changes, nil
+}
+
+// testMainChanges adds a TestMain running the setup, and its deferred calls
+// after the tests, and removes the setup from every test.
+func (op *ExtractTestHelperOperation) testMainChanges(ws *types.Workspace, g *testsetup.Group) ([]types.Change, error) {
+	home := homeTest(g)
+	file := op.files[g.Files[home]]
+	var b strings.Builder
+	b.WriteString("\nfunc TestMain(m *testing.M) {\n")
+	var deferred []string
+	for _, stmt := range g.Setup(home) {
+		if d, ok := stmt.(*ast.DeferStmt); ok {
+			deferred = append(deferred, nodeText(ws, file, d.Call.Pos(), d.Call.End()))
+			continue
+		}
+		fmt.Fprintf(&b, "\t%s\n", nodeText(ws, file, stmt.Pos(), stmt.End()))
+	}
+	b.WriteString("\tcode := m.Run()\n")
+	for _, call := range slices.Backward(deferred) {
+		fmt.Fprintf(&b, "\t%s\n", call)
+	}
+	b.WriteString("\tos.Exit(code)\n}\n")
+
+	end := len(file.OriginalContent)
+	text := b.String()
+	if end > 0 && file.OriginalContent[end-1] != '\n' {
+		text = "\n" + text
+	}
+	changes := []types.Change{insertAt(file.Path, end, text, "Add TestMain running the shared test setup")}
+	if !hasImport(ws, file.Path, "os") {
+		if change := generateAddImportChange(ws, file.Path, "os"); change != nil {
+			changes = append(changes, *change)
+		}
+	}
+	for i, test := range g.Tests {
+		setup := g.Setup(i)
+		changes = append(changes, removeRange(ws, op.files[g.Files[i]], setup[0].Pos(), setup[len(setup)-1].End(),
+			fmt.Sprintf("Remove setup from %s, now run by TestMain", test.Name.Name)))
+	}
+	changes = append(changes, op.unusedImports(ws, g, g.Files[home])...)
+	return changes, nil
+}
+
+// setupText returns the source of stmts with deferred calls registered
+// through t.Cleanup instead, since a defer would run when the helper
+// returns.
+func (op *ExtractTestHelperOperation) setupText(ws *types.Workspace, file *types.File, stmts []ast.Stmt, param string) string {
+	start, end := stmts[0].Pos(), stmts[len(stmts)-1].End()
+	var b strings.Builder
+	pos := start
+	for _, stmt := range stmts {
+		d, ok := stmt.(*ast.DeferStmt)
+		if !ok {
+			continue
+		}
+		b.WriteString(nodeText(ws, file, pos, d.Pos()))
+		fmt.Fprintf(&b, "%s.Cleanup(func() { %s })", param, nodeText(ws, file, d.Call.Pos(), d.Call.End()))
+		pos = d.End()
+	}
+	b.WriteString(nodeText(ws, file, pos, end))
+	return b.String()
+}
+
+// results returns the variables defined by the setup of the first test that
+// some test uses after it, in order of definition.
+func (op *ExtractTestHelperOperation) results(g *testsetup.Group) ([]gotypes.Object, error) {
+	var results []gotypes.Object
+	for _, ident := range definedIdents(g.Setup(0)) {
+		used := false
+		for i := range g.Tests {
+			used = used || op.usedAfter(g, i, ident.Name)
+		}
+		if !used {
+			continue
+		}
+		var obj gotypes.Object
+		if op.info != nil {
+			obj = op.info.Defs[ident]
+		}
+		if obj == nil || obj.Type() == nil || obj.Type() == gotypes.Typ[gotypes.Invalid] {
+			return nil, &types.RefactorError{
+				Type:    types.InvalidOperation,
+				Message: fmt.Sprintf("cannot determine the type of %s, which %s uses after the setup", ident.Name, g.Tests[0].Name.Name),
+			}
+		}
+		results = append(results, obj)
+	}
+	return results, nil
+}
+
+// usedAfter reports whether the i-th test of g uses the variable its setup
+// defines as name after the setup.
+func (op *ExtractTestHelperOperation) usedAfter(g *testsetup.Group, i int, name string) bool {
+	var obj gotypes.Object
+	for _, ident := range definedIdents(g.Setup(i)) {
+		if ident.Name == name && op.info != nil {
+			obj = op.info.Defs[ident]
+		}
+	}
+	for _, stmt := range g.Tests[i].Body.List[g.Statements:] {
+		found := false
+		ast.Inspect(stmt, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok && id.Name == name && (obj == nil || op.info.Uses[id] == obj) {
+				found = true
+			}
+			return !found
+		})
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+// unusedImports removes the imports of the test files other than keep that
+// only the moved setup used.
+func (op *ExtractTestHelperOperation) unusedImports(ws *types.Workspace, g *testsetup.Group, keep *ast.File) []types.Change {
+	var changes []types.Change
+	for _, f := range slices.Compact(slices.Clone(g.Files)) {
+		if f == keep {
+			continue
+		}
+		var moved [][2]token.Pos
+		for i := range g.Tests {
+			if g.Files[i] == f {
+				setup := g.Setup(i)
+				moved = append(moved, [2]token.Pos{setup[0].Pos(), setup[len(setup)-1].End()})
+			}
+		}
+		for _, decl := range f.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.IMPORT {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				imp := spec.(*ast.ImportSpec)
+				path, err := strconv.Unquote(imp.Path.Value)
+				if err != nil || imp.Name != nil && (imp.Name.Name == "_" || imp.Name.Name == ".") {
+					continue
+				}
+				name := path[strings.LastIndex(path, "/")+1:]
+				if imp.Name != nil {
+					name = imp.Name.Name
+				}
+				if !usesOnlyIn(f, name, moved) {
+					continue
+				}
+				var start, end token.Pos = imp.Pos(), imp.End()
+				if len(gen.Specs) == 1 {
+					start, end = gen.Pos(), gen.End()
+				}
+				changes = append(changes, removeRange(ws, op.files[f], start, end, "Remove import of "+path+", used only by the moved setup"))
+			}
+		}
+	}
+	return changes
+}
+
+// declaredNames returns the package-level names declared by the files of a
+// package clause, so a new helper doesn't collide with them.
+func (op *ExtractTestHelperOperation) declaredNames(clause string) map[string]bool {
+	var files []*ast.File
+	for f := range op.files {
+		if f.Name.Name == clause {
+			files = append(files, f)
+		}
+	}
+	if clause == op.pkg.Name {
+		for _, f := range op.pkg.Files {
+			if f.AST != nil {
+				files = append(files, f.AST)
+			}
+		}
+	}
+	names := make(map[string]bool)
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			switch d := decl.(type) {
+			case *ast.FuncDecl:
+				if d.Recv == nil {
+					names[d.Name.Name] = true
+				}
+			case *ast.GenDecl:
+				for _, spec := range d.Specs {
+					switch s := spec.(type) {
+					case *ast.TypeSpec:
+						names[s.Name.Name] = true
+					case *ast.ValueSpec:
+						for _, n := range s.Names {
+							names[n.Name] = true
+						}
+					}
+				}
+			}
+		}
+	}
+	return names
+}
+
+// homeTest returns the index of the first test of g in the file declaring
+// most of them, which gets the helper.
+func homeTest(g *testsetup.Group) int {
+	home, most := 0, 0
+	for i, f := range g.Files {
+		if n := len(slices.DeleteFunc(slices.Clone(g.Files), func(x *ast.File) bool { return x != f })); n > most {
+			home, most = i, n
+		}
+	}
+	return home
+}
+
+// helperName names the helper after the words the test names share:
+// TestStoreGet and TestStorePut make setupStore.
+func helperName(g *testsetup.Group) string {
+	common := strings.TrimPrefix(g.Tests[0].Name.Name, "Test")
+	for _, test := range g.Tests[1:] {
+		name := strings.TrimPrefix(test.Name.Name, "Test")
+		n := 0
+		for n < len(common) && n < len(name) && common[n] == name[n] {
+			n++
+		}
+		// Cut back to a word boundary.
+		for n > 0 && n < len(name) && !unicode.IsUpper(rune(name[n])) && name[n] != '_' {
+			n--
+		}
+		common = common[:n]
+	}
+	return "setup" + strings.TrimRight(common, "_")
+}
+
+// definedIdents returns the variables declared directly by stmts.
+func definedIdents(stmts []ast.Stmt) []*ast.Ident {
+	var idents []*ast.Ident
+	add := func(id *ast.Ident) {
+		if id.Name != "_" && !slices.ContainsFunc(idents, func(x *ast.Ident) bool { return x.Name == id.Name }) {
+			idents = append(idents, id)
+		}
+	}
+	for _, stmt := range stmts {
+		switch s := stmt.(type) {
+		case *ast.AssignStmt:
+			if s.Tok != token.DEFINE {
+				continue
+			}
+			for _, lhs := range s.Lhs {
+				if id, ok := lhs.(*ast.Ident); ok {
+					add(id)
+				}
+			}
+		case *ast.DeclStmt:
+			if gen, ok := s.Decl.(*ast.GenDecl); ok && gen.Tok == token.VAR {
+				for _, spec := range gen.Specs {
+					for _, id := range spec.(*ast.ValueSpec).Names {
+						add(id)
+					}
+				}
+			}
+		}
+	}
+	return idents
+}
+
+// mentions reports whether node refers to name.
+func mentions(node ast.Node, name string) bool {
+	found := false
+	ast.Inspect(node, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && id.Name == name {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// usesOnlyIn reports whether name is used as a package qualifier in f, and
+// only within ranges.
+func usesOnlyIn(f *ast.File, name string, ranges [][2]token.Pos) bool {
+	inside, outside := false, false
+	ast.Inspect(f, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if x, ok := sel.X.(*ast.Ident); ok && x.Name == name && x.Obj == nil {
+			if slices.ContainsFunc(ranges, func(r [2]token.Pos) bool { return x.Pos() >= r[0] && x.Pos() < r[1] }) {
+				inside = true
+			} else {
+				outside = true
+			}
+		}
+		return true
+	})
+	return inside && !outside
+}
+
+// fileQualifier qualifies types as file refers to them, leaving those of
+// the package self unqualified and collecting the import paths file
+// doesn't import yet.
+func fileQualifier(file *types.File, self string, missing *[]string) gotypes.Qualifier {
+	local := make(map[string]string)
+	for _, imp := range file.AST.Imports {
+		path, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			continue
+		}
+		local[path] = ""
+		if imp.Name != nil {
+			local[path] = imp.Name.Name
+		}
+	}
+	return func(p *gotypes.Package) string {
+		if p.Path() == self {
+			return ""
+		}
+		name, ok := local[p.Path()]
+		if !ok && !slices.Contains(*missing, p.Path()) {
+			*missing = append(*missing, p.Path())
+		}
+		return cmp.Or(name, p.Name())
+	}
+}
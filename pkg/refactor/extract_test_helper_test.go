@@ -0,0 +1,79 @@
+package refactor
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+func TestExtractTestHelper_TestMain(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/p\n\ngo 1.21\n",
+		"p.go":   "package p\n\nfunc Hello() string { return \"hi\" }\n",
+		"p_test.go": `package p
+
+import (
+	"os"
+	"testing"
+)
+
+func TestHello(t *testing.T) {
+	os.Setenv("P_MODE", "test")
+	defer os.Unsetenv("P_MODE")
+	if Hello() != "hi" {
+		t.Fail()
+	}
+}
+
+func TestMode(t *testing.T) {
+	os.Setenv("P_MODE", "test")
+	defer os.Unsetenv("P_MODE")
+	if os.Getenv("P_MODE") != "test" {
+		t.Fail()
+	}
+}
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	engine := CreateEngine(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ws, err := engine.LoadWorkspace(dir)
+	if err != nil {
+		t.Fatalf("LoadWorkspace: %v", err)
+	}
+
+	plan, err := engine.ExtractTestHelper(ws, types.ExtractTestHelperRequest{Package: dir, TestMain: true})
+	if err != nil {
+		t.Fatalf("ExtractTestHelper: %v", err)
+	}
+	if err := engine.ExecutePlan(plan); err != nil {
+		t.Fatalf("ExecutePlan: %v", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(dir, "p_test.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `func TestMain(m *testing.M) {
+	os.Setenv("P_MODE", "test")
+	code := m.Run()
+	os.Unsetenv("P_MODE")
+	os.Exit(code)
+}
+`
+	if !strings.HasSuffix(string(got), want) {
+		t.Errorf("expected TestMain at the end of p_test.go, got:\n%s", got)
+	}
+	if strings.Count(string(got), `os.Setenv("P_MODE", "test")`) != 1 {
+		t.Errorf("expected the setup to be removed from the tests, got:\n%s", got)
+	}
+}
@@ -0,0 +1,229 @@
+package refactor
+
+import (
+	"fmt"
+	"go/ast"
+	gotypes "go/types"
+	"io"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/mamaar/gorefactor/pkg/analysis"
+	"github.com/mamaar/gorefactor/pkg/analyzers"
+	"github.com/mamaar/gorefactor/pkg/analyzers/errorreturn"
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// FixErrorReturnsOperation fixes what the errorreturn analyzer reports.
+// Functions returning an error before other results have their results
+// reordered by ChangeSignatureOperation, which updates their return
+// statements and the assignments from their calls. Error variables are
+// renamed err where the function uses no err already. Fixes whose edits
+// would overlap another's are left for a second run, and uses that can't
+// be updated are reported as issues.
+type FixErrorReturnsOperation struct {
+	Request types.FixErrorReturnsRequest
+	Parser  *analysis.GoParser // Type-checks packages on demand, which renaming local variables needs; may be nil if they already are
+}
+
+func (op *FixErrorReturnsOperation) Type() types.OperationType {
+	return types.FixErrorReturnsOperation
+}
+
+func (op *FixErrorReturnsOperation) Description() string {
+	if op.Request.Package != "" {
+		return fmt.Sprintf("Fix error results and names in package %s", op.Request.Package)
+	}
+	return "Fix error results and names"
+}
+
+func (op *FixErrorReturnsOperation) Validate(ws *types.Workspace) error {
+	if op.Request.Package == "" {
+		return nil
+	}
+	if _, ok := ws.Packages[types.ResolvePackagePath(ws, op.Request.Package)]; ok {
+		return nil
+	}
+	return &types.RefactorError{
+		Type:    types.SymbolNotFound,
+		Message: fmt.Sprintf("package %s not found", op.Request.Package),
+	}
+}
+
+func (op *FixErrorReturnsOperation) Execute(ws *types.Workspace) (*types.RefactoringPlan, error) {
+	wanted := ""
+	if op.Request.Package != "" {
+		wanted = types.ResolvePackagePath(ws, op.Request.Package)
+	}
+
+	var orders, names []*errorreturn.Result
+	for _, pkgPath := range slices.Sorted(maps.Keys(ws.Packages)) {
+		pkg := ws.Packages[pkgPath]
+		if wanted != "" && pkg.Path != wanted {
+			continue
+		}
+		if op.Parser != nil {
+			op.Parser.EnsureTypeChecked(ws, pkg)
+		}
+		rr, err := analyzers.Run(ws, errorreturn.Analyzer, pkg.Path)
+		if err != nil {
+			return nil, err
+		}
+		results, _ := rr.Result.([]*errorreturn.Result)
+		for _, r := range results {
+			if r.Kind == errorreturn.ErrorNotLast {
+				orders = append(orders, r)
+			} else {
+				names = append(names, r)
+			}
+		}
+	}
+
+	plan := &types.RefactoringPlan{Reversible: true}
+	var issues []types.Issue
+	skip := func(r *errorreturn.Result, why string) {
+		issues = append(issues, types.Issue{
+			Type:        types.IssueCompilationError,
+			Severity:    types.Info,
+			Description: fmt.Sprintf("%s in %s not fixed: %s", r.Kind, r.Function, why),
+			File:        r.File,
+			Line:        r.Line,
+		})
+	}
+	add := func(r *errorreturn.Result, changes []types.Change) bool {
+		for _, c := range changes {
+			if slices.ContainsFunc(plan.Changes, func(p types.Change) bool { return changesOverlap(c, p) }) {
+				skip(r, "its edits overlap another fix's; run the fix again")
+				return false
+			}
+		}
+		plan.Changes = append(plan.Changes, changes...)
+		return true
+	}
+
+	var idx *analysis.ReferenceIndex
+	var resolver *analysis.SymbolResolver
+	if len(orders) > 0 {
+		resolver = analysis.NewSymbolResolver(ws, slog.New(slog.NewTextHandler(io.Discard, nil)))
+		idx = resolver.BuildReferenceIndex()
+	}
+	for _, r := range orders {
+		if typeName, method, ok := strings.Cut(r.Function, "."); ok && len(findInterfacesForMethod(ws, resolver, typeName, method)) > 0 {
+			skip(r, "the method implements an interface whose results would have to change too")
+			continue
+		}
+		reorder := &ChangeSignatureOperation{
+			FunctionName:       r.Function,
+			SourceFile:         r.File,
+			Scope:              types.WorkspaceScope,
+			NewReturnPosition:  -1,
+			RemovedReturnIndex: -1,
+			ReturnOrder:        r.ReturnOrder,
+			CachedIndex:        idx,
+		}
+		if err := reorder.Validate(ws); err != nil {
+			skip(r, err.Error())
+			continue
+		}
+		reordered, err := reorder.Execute(ws)
+		if err != nil {
+			skip(r, err.Error())
+			continue
+		}
+		if add(r, reordered.Changes) {
+			issues = append(issues, reorder.reorderIssues...)
+		}
+	}
+
+	renamed := make(map[string]bool)
+	for _, r := range names {
+		if renamed[r.File+":"+r.Function] {
+			skip(r, "another error variable of the function is renamed err")
+			continue
+		}
+		changes, why := renameErrorVar(ws, r)
+		if changes == nil {
+			skip(r, why)
+			continue
+		}
+		renamed[r.File+":"+r.Function] = add(r, changes)
+	}
+
+	for _, c := range plan.Changes {
+		if !slices.Contains(plan.AffectedFiles, c.File) {
+			plan.AffectedFiles = append(plan.AffectedFiles, c.File)
+		}
+	}
+	plan.Impact = &types.ImpactAnalysis{AffectedFiles: plan.AffectedFiles, PotentialIssues: issues}
+	for _, file := range plan.AffectedFiles {
+		if pkg := findPackageForFile(ws, file); pkg != nil && !slices.Contains(plan.Impact.AffectedPackages, pkg.Path) {
+			plan.Impact.AffectedPackages = append(plan.Impact.AffectedPackages, pkg.Path)
+		}
+	}
+	return plan, nil
+}
+
+// renameErrorVar renames the variable r reports to err throughout its
+// function, or says why it can't: the package isn't type-checked, or the
+// function already refers to something named err.
+func renameErrorVar(ws *types.Workspace, r *errorreturn.Result) ([]types.Change, string) {
+	file := findFile(ws, r.File)
+	if file == nil || file.AST == nil || file.Package == nil || file.Package.TypesInfo == nil {
+		return nil, "the package isn't type-checked"
+	}
+	info := file.Package.TypesInfo
+	var fd *ast.FuncDecl
+	for _, d := range file.AST.Decls {
+		if f, ok := d.(*ast.FuncDecl); ok && f.Pos() <= r.Pos && r.Pos < f.End() {
+			fd = f
+		}
+	}
+	var obj gotypes.Object
+	var idents []*ast.Ident
+	if fd != nil {
+		ast.Inspect(fd, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok {
+				if id.Pos() == r.Pos {
+					obj = info.Defs[id]
+				}
+				idents = append(idents, id)
+			}
+			return true
+		})
+	}
+	if obj == nil {
+		return nil, "the package isn't type-checked"
+	}
+	content, err := readSource(ws, file.Path)
+	if err != nil {
+		return nil, err.Error()
+	}
+
+	var changes []types.Change
+	for _, id := range idents {
+		if id.Name == "err" {
+			return nil, "the function already uses the name err"
+		}
+		if info.Defs[id] != obj && info.Uses[id] != obj {
+			continue
+		}
+		start, end := ws.FileSet.Position(id.Pos()).Offset, ws.FileSet.Position(id.End()).Offset
+		changes = append(changes, types.Change{
+			File:        file.Path,
+			Start:       start,
+			End:         end,
+			OldText:     string(content[start:end]),
+			NewText:     "err",
+			Description: fmt.Sprintf("Rename error variable %s to err in %s", r.Name, r.Function),
+		})
+	}
+	return changes, ""
+}
+
+// changesOverlap reports whether two changes edit overlapping ranges of
+// the same file.
+func changesOverlap(a, b types.Change) bool {
+	return a.File == b.File && a.End > b.Start && b.End > a.Start
+}
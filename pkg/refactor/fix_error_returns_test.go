@@ -0,0 +1,105 @@
+package refactor
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+var errorReturnFiles = map[string]string{
+	"store/store.go": `package store
+
+import "errors"
+
+// Load returns the value stored under key.
+func Load(key string) (error, string) {
+	if key == "" {
+		return errors.New("empty key"), ""
+	}
+	return nil, "value"
+}
+
+func Describe(key string) string {
+	e, v := Load(key)
+	if e != nil {
+		return e.Error()
+	}
+	return v
+}
+
+func Forward(key string) (error, string) {
+	return Load(key)
+}
+
+func Validate(key string) (problem error) {
+	if key == "" {
+		problem = errors.New("empty key")
+	}
+	return
+}
+`,
+	"app/app.go": `package app
+
+import "example.com/p/store"
+
+func Run() string {
+	var failure, value = store.Load("k")
+	if failure != nil {
+		return ""
+	}
+	return value
+}
+`,
+}
+
+func TestFixErrorReturns(t *testing.T) {
+	engine, ws, dir := loadTestModuleWith(t, errorReturnFiles)
+
+	plan, err := engine.FixErrorReturns(ws, types.FixErrorReturnsRequest{})
+	if err != nil {
+		t.Fatalf("FixErrorReturns: %v", err)
+	}
+
+	store := planContent(t, plan, filepath.Join(dir, "store", "store.go"))
+	for _, want := range []string{
+		"func Load(key string) (string, error) {",
+		`return "", errors.New("empty key")`,
+		`return "value", nil`,
+		"v, e := Load(key)",
+		"func Validate(key string) (err error) {",
+		`err = errors.New("empty key")`,
+	} {
+		if !strings.Contains(store, want) {
+			t.Errorf("store.go lacks %q:\n%s", want, store)
+		}
+	}
+	app := planContent(t, plan, filepath.Join(dir, "app", "app.go"))
+	if !strings.Contains(app, `var value, failure = store.Load("k")`) {
+		t.Errorf("app.go should assign the reordered results:\n%s", app)
+	}
+
+	// Forward passes Load's results on whole and is itself reordered: its
+	// return statement is left to the user. Renaming e and failure would
+	// edit the reordered assignments, so it is left to a second run.
+	var byHand, again int
+	for _, issue := range plan.Impact.PotentialIssues {
+		switch {
+		case issue.Line == 22 && strings.Contains(issue.Description, "by hand"):
+			byHand++
+		case strings.Contains(issue.Description, "run the fix again"):
+			again++
+		}
+	}
+	if byHand == 0 || again != 2 {
+		t.Errorf("expected issues about Forward and the two overlapping renames, got %+v", plan.Impact.PotentialIssues)
+	}
+}
+
+func TestFixErrorReturns_UnknownPackage(t *testing.T) {
+	engine, ws, _ := loadTestModuleWith(t, errorReturnFiles)
+	if _, err := engine.FixErrorReturns(ws, types.FixErrorReturnsRequest{Package: "missing"}); err == nil {
+		t.Error("expected an unknown package to fail")
+	}
+}
@@ -0,0 +1,193 @@
+package refactor
+
+import (
+	"cmp"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mamaar/gorefactor/pkg/analyzers"
+	"github.com/mamaar/gorefactor/pkg/analyzers/magicnumber"
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// FixMagicNumbersOperation replaces the literals the magicnumber analyzer
+// reports with named constants. Each value is extracted once per package,
+// package-scoped, by ExtractConstantOperation; the declarations it would
+// add to a file are gathered into a single const block.
+type FixMagicNumbersOperation struct {
+	Request types.FixMagicNumbersRequest
+}
+
+func (op *FixMagicNumbersOperation) Type() types.OperationType {
+	return types.FixMagicNumbersOperation
+}
+
+func (op *FixMagicNumbersOperation) Description() string {
+	if op.Request.Package != "" {
+		return fmt.Sprintf("Replace magic numbers in package %s with constants", op.Request.Package)
+	}
+	return "Replace magic numbers with constants"
+}
+
+func (op *FixMagicNumbersOperation) Validate(ws *types.Workspace) error {
+	for value, name := range op.Request.Names {
+		if !isValidGoIdentifier(name) {
+			return &types.RefactorError{
+				Type:    types.InvalidOperation,
+				Message: fmt.Sprintf("invalid constant name %q for %s", name, value),
+			}
+		}
+	}
+	if op.Request.Package == "" {
+		return nil
+	}
+	if _, ok := ws.Packages[types.ResolvePackagePath(ws, op.Request.Package)]; ok {
+		return nil
+	}
+	return &types.RefactorError{
+		Type:    types.SymbolNotFound,
+		Message: fmt.Sprintf("package %s not found", op.Request.Package),
+	}
+}
+
+func (op *FixMagicNumbersOperation) Execute(ws *types.Workspace) (*types.RefactoringPlan, error) {
+	a := magicnumber.NewAnalyzer(magicnumber.WithMinOccurrences(cmp.Or(op.Request.MinOccurrences, magicnumber.DefaultMinOccurrences)))
+	wanted := ""
+	if op.Request.Package != "" {
+		wanted = types.ResolvePackagePath(ws, op.Request.Package)
+	}
+
+	plan := &types.RefactoringPlan{Reversible: true}
+	var packages []string
+	for _, pkgPath := range slices.Sorted(maps.Keys(ws.Packages)) {
+		pkg := ws.Packages[pkgPath]
+		if wanted != "" && pkg.Path != wanted {
+			continue
+		}
+		rr, err := analyzers.Run(ws, a, pkg.Path)
+		if err != nil {
+			return nil, err
+		}
+		results, _ := rr.Result.([]*magicnumber.Result)
+		if len(results) == 0 {
+			continue
+		}
+		changes, err := op.packageChanges(ws, pkg, results)
+		if err != nil {
+			return nil, err
+		}
+		plan.Changes = append(plan.Changes, changes...)
+		packages = append(packages, pkg.Path)
+	}
+
+	for _, c := range plan.Changes {
+		if !slices.Contains(plan.AffectedFiles, c.File) {
+			plan.AffectedFiles = append(plan.AffectedFiles, c.File)
+		}
+	}
+	plan.Impact = &types.ImpactAnalysis{AffectedFiles: plan.AffectedFiles, AffectedPackages: packages}
+	return plan, nil
+}
+
+// constantDecl is a constant to declare at an offset of a file.
+type constantDecl struct {
+	file   string
+	offset int
+	specs  []string
+}
+
+// packageChanges extracts a constant for each value reported in pkg, in the
+// order the values are first reported.
+func (op *FixMagicNumbersOperation) packageChanges(ws *types.Workspace, pkg *types.Package, results []*magicnumber.Result) ([]types.Change, error) {
+	slices.SortStableFunc(results, func(a, b *magicnumber.Result) int {
+		return cmp.Or(strings.Compare(a.File, b.File), cmp.Compare(a.Line, b.Line), cmp.Compare(a.Column, b.Column))
+	})
+
+	taken := packageIdentifiers(pkg)
+	extracted := make(map[string]bool)
+	var changes []types.Change
+	var decls []*constantDecl
+	for _, r := range results {
+		if extracted[r.Value] {
+			continue
+		}
+		extracted[r.Value] = true
+
+		name := cmp.Or(op.Request.Names[r.Value], r.SuggestedName)
+		if op.Request.Names[r.Value] == "" {
+			for i := 2; taken[name]; i++ {
+				name = r.SuggestedName + strconv.Itoa(i)
+			}
+		} else if taken[name] {
+			return nil, &types.RefactorError{
+				Type:    types.NameConflict,
+				Message: fmt.Sprintf("constant name %s for %s is already used in package %s", name, r.Value, pkg.Path),
+			}
+		}
+		taken[name] = true
+
+		extract := &ExtractConstantOperation{SourceFile: r.File, Position: r.Pos, ConstantName: name, Scope: types.PackageScope}
+		if err := extract.Validate(ws); err != nil {
+			return nil, err
+		}
+		plan, err := extract.Execute(ws)
+		if err != nil {
+			return nil, err
+		}
+
+		// The first change declares the constant; gather the declarations
+		// of each file into one block.
+		decl := plan.Changes[0]
+		i := slices.IndexFunc(decls, func(d *constantDecl) bool { return d.file == decl.File && d.offset == decl.Start })
+		if i < 0 {
+			decls = append(decls, &constantDecl{file: decl.File, offset: decl.Start})
+			i = len(decls) - 1
+		}
+		decls[i].specs = append(decls[i].specs, name+" = "+r.Value)
+		changes = append(changes, plan.Changes[1:]...)
+	}
+
+	for _, d := range decls {
+		src := "const " + d.specs[0] + "\n"
+		if len(d.specs) > 1 {
+			src = "const (\n" + strings.Join(d.specs, "\n") + "\n)\n"
+		}
+		formatted, err := format.Source([]byte(src))
+		if err != nil {
+			return nil, fmt.Errorf("failed to format constants: %w", err)
+		}
+		changes = append(changes, types.Change{
+			File:        d.file,
+			Start:       d.offset,
+			End:         d.offset,
+			NewText:     "\n" + string(formatted),
+			Description: fmt.Sprintf("Declare %d constant(s) for magic numbers", len(d.specs)),
+		})
+	}
+	return changes, nil
+}
+
+// packageIdentifiers returns every identifier spelled in the files of pkg,
+// which a new package-level constant must not shadow or collide with.
+func packageIdentifiers(pkg *types.Package) map[string]bool {
+	names := make(map[string]bool)
+	for _, files := range []map[string]*types.File{pkg.Files, pkg.TestFiles} {
+		for _, file := range files {
+			if file.AST == nil {
+				continue
+			}
+			ast.Inspect(file.AST, func(n ast.Node) bool {
+				if id, ok := n.(*ast.Ident); ok {
+					names[id.Name] = true
+				}
+				return true
+			})
+		}
+	}
+	return names
+}
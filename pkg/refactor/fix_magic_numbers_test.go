@@ -0,0 +1,93 @@
+package refactor
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+var magicNumberFiles = map[string]string{
+	"shop/shop.go": `package shop
+
+import (
+	"fmt"
+	"time"
+)
+
+type Order struct {
+	Items int ` + "`json:\"items\"`" + `
+}
+
+func Check(o Order) error {
+	if o.Items > 50 {
+		return fmt.Errorf("too many items")
+	}
+	return nil
+}
+
+func Wait() time.Duration {
+	var d time.Duration = 3600
+	return d + 3600*time.Second + 3600
+}
+`,
+	"shop/status.go": `package shop
+
+func Label(status string) string {
+	switch status {
+	case "pending":
+		return "items"
+	}
+	return "pending"
+}
+`,
+}
+
+func TestFixMagicNumbers(t *testing.T) {
+	engine, ws, dir := loadTestModuleWith(t, magicNumberFiles)
+
+	plan, err := engine.FixMagicNumbers(ws, types.FixMagicNumbersRequest{
+		Package: "shop",
+		Names:   map[string]string{"3600": "secondsPerHour"},
+	})
+	if err != nil {
+		t.Fatalf("FixMagicNumbers: %v", err)
+	}
+
+	shop := planContent(t, plan, filepath.Join(dir, "shop", "shop.go"))
+	for _, want := range []string{
+		")\n\nconst (\n\tvalue50        = 50\n\tsecondsPerHour = 3600\n)\n\ntype Order",
+		"Items int `json:\"items\"`",
+		"if o.Items > value50 {",
+		"var d time.Duration = secondsPerHour",
+		"return d + secondsPerHour*time.Second + secondsPerHour",
+	} {
+		if !strings.Contains(shop, want) {
+			t.Errorf("shop.go lacks %q:\n%s", want, shop)
+		}
+	}
+
+	status := planContent(t, plan, filepath.Join(dir, "shop", "status.go"))
+	for _, want := range []string{
+		"package shop\n\nconst pending = \"pending\"\n\nfunc Label",
+		"case pending:",
+		"return pending\n",
+		"return \"items\"",
+	} {
+		if !strings.Contains(status, want) {
+			t.Errorf("status.go lacks %q:\n%s", want, status)
+		}
+	}
+}
+
+func TestFixMagicNumbers_NameConflict(t *testing.T) {
+	engine, ws, _ := loadTestModuleWith(t, magicNumberFiles)
+
+	if _, err := engine.FixMagicNumbers(ws, types.FixMagicNumbersRequest{Names: map[string]string{"50": "Order"}}); err == nil {
+		t.Error("expected naming a constant after an existing type to fail")
+	}
+	if _, err := engine.FixMagicNumbers(ws, types.FixMagicNumbersRequest{Names: map[string]string{"50": "max items"}}); err == nil {
+		t.Error("expected an invalid constant name to fail")
+	}
+}
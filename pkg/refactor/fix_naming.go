@@ -0,0 +1,197 @@
+package refactor
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/mamaar/gorefactor/pkg/analysis"
+	"github.com/mamaar/gorefactor/pkg/analyzers"
+	"github.com/mamaar/gorefactor/pkg/analyzers/naming"
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// FixNamingOperation renames what the naming analyzer reports to the names
+// it suggests, all in one plan. Package-level symbols are renamed as
+// RenameSymbolOperation would and methods as RenameMethodOperation would.
+// Renames that fail validation, collide with another rename of the batch
+// or edit the same code as one are left out and reported as issues.
+type FixNamingOperation struct {
+	Request types.FixNamingRequest
+	Parser  *analysis.GoParser // Type-checks packages on demand for method renames; may be nil if they already are
+}
+
+func (op *FixNamingOperation) Type() types.OperationType {
+	return types.FixNamingOperation
+}
+
+func (op *FixNamingOperation) Description() string {
+	if op.Request.Package != "" {
+		return fmt.Sprintf("Fix names in package %s", op.Request.Package)
+	}
+	return "Fix names"
+}
+
+func (op *FixNamingOperation) Validate(ws *types.Workspace) error {
+	if op.Request.Package == "" {
+		return nil
+	}
+	if _, ok := ws.Packages[types.ResolvePackagePath(ws, op.Request.Package)]; ok {
+		return nil
+	}
+	return &types.RefactorError{
+		Type:    types.SymbolNotFound,
+		Message: fmt.Sprintf("package %s not found", op.Request.Package),
+	}
+}
+
+func (op *FixNamingOperation) Execute(ws *types.Workspace) (*types.RefactoringPlan, error) {
+	wanted := ""
+	if op.Request.Package != "" {
+		wanted = types.ResolvePackagePath(ws, op.Request.Package)
+	}
+
+	var renames []symbolRename
+	for _, pkgPath := range slices.Sorted(maps.Keys(ws.Packages)) {
+		pkg := ws.Packages[pkgPath]
+		if wanted != "" && pkg.Path != wanted {
+			continue
+		}
+		rr, err := analyzers.Run(ws, naming.Analyzer, pkg.Path)
+		if err != nil {
+			return nil, err
+		}
+		results, _ := rr.Result.([]*naming.Result)
+		for _, r := range results {
+			qualified := r.Name
+			if r.Receiver != "" {
+				qualified = r.Receiver + "." + r.Name
+			}
+			if len(op.Request.Names) > 0 && !slices.Contains(op.Request.Names, qualified) {
+				continue
+			}
+			renames = append(renames, symbolRename{
+				Package:  pkg.Path,
+				TypeName: r.Receiver,
+				Name:     r.Name,
+				NewName:  r.SuggestedName,
+				File:     r.File,
+				Line:     r.Line,
+			})
+		}
+	}
+	return planRenames(ws, op.Parser, renames, types.Warning), nil
+}
+
+// symbolRename is one rename of a plan planRenames composes: of a
+// package-level symbol, or of a method of TypeName.
+type symbolRename struct {
+	Package  string // Directory of the declaring package
+	TypeName string // The receiver type, for methods
+	Name     string
+	NewName  string
+	File     string // Where the symbol is declared, for issues; may be empty
+	Line     int
+}
+
+func (r symbolRename) String() string {
+	if r.TypeName != "" {
+		return r.TypeName + "." + r.Name
+	}
+	return r.Name
+}
+
+// planRenames composes renames into one plan. Each is validated and
+// planned on its own against ws, so a rename to a name another rename
+// frees is refused like any name conflict. Renames to a name an earlier
+// rename in the same scope takes, and renames editing code an earlier one
+// edits differently, are left out too. Every rename left out is reported
+// as an issue of the plan's impact with severity: a warning lets the rest
+// be applied, an error refuses the whole plan.
+func planRenames(ws *types.Workspace, parser *analysis.GoParser, renames []symbolRename, severity types.IssueSeverity) *types.RefactoringPlan {
+	plan := &types.RefactoringPlan{Reversible: true}
+	var issues []types.Issue
+	skip := func(r symbolRename, why string) {
+		issues = append(issues, types.Issue{
+			Type:        types.IssueNameConflict,
+			Severity:    severity,
+			Description: fmt.Sprintf("%s not renamed to %s: %s", r, r.NewName, why),
+			File:        r.File,
+			Line:        r.Line,
+		})
+	}
+
+	refs := newReferenceFinder(ws)
+	taken := make(map[string]symbolRename) // By package, type and new name
+	for _, r := range renames {
+		key := r.Package + "\x00" + r.TypeName + "\x00" + r.NewName
+		if other, ok := taken[key]; ok {
+			skip(r, fmt.Sprintf("%s is renamed to %s too", other, r.NewName))
+			continue
+		}
+
+		var rename types.Operation
+		if r.TypeName != "" {
+			rename = &RenameMethodOperation{
+				Request: types.RenameMethodRequest{
+					TypeName:              r.TypeName,
+					MethodName:            r.Name,
+					NewMethodName:         r.NewName,
+					PackagePath:           r.Package,
+					UpdateImplementations: true,
+				},
+				Parser: parser,
+			}
+		} else {
+			rename = &RenameSymbolOperation{
+				Request: types.RenameSymbolRequest{SymbolName: r.Name, NewName: r.NewName, Package: r.Package},
+				refs:    refs,
+			}
+		}
+		if err := rename.Validate(ws); err != nil {
+			skip(r, err.Error())
+			continue
+		}
+		renamed, err := rename.Execute(ws)
+		if err != nil {
+			skip(r, err.Error())
+			continue
+		}
+
+		// Renames through an interface and its implementations can edit the
+		// same identifiers the same way; those edits are kept once.
+		var changes []types.Change
+		conflict := false
+		for _, c := range renamed.Changes {
+			if slices.ContainsFunc(plan.Changes, func(p types.Change) bool {
+				return p.File == c.File && p.Start == c.Start && p.End == c.End && p.NewText == c.NewText
+			}) {
+				continue
+			}
+			if slices.ContainsFunc(plan.Changes, func(p types.Change) bool { return changesOverlap(c, p) }) {
+				conflict = true
+				break
+			}
+			changes = append(changes, c)
+		}
+		if conflict {
+			skip(r, "its edits overlap another rename's")
+			continue
+		}
+		taken[key] = r
+		plan.Changes = append(plan.Changes, changes...)
+	}
+
+	for _, c := range plan.Changes {
+		if !slices.Contains(plan.AffectedFiles, c.File) {
+			plan.AffectedFiles = append(plan.AffectedFiles, c.File)
+		}
+	}
+	plan.Impact = &types.ImpactAnalysis{AffectedFiles: plan.AffectedFiles, PotentialIssues: issues}
+	for _, file := range plan.AffectedFiles {
+		if pkg := findPackageForFile(ws, file); pkg != nil && !slices.Contains(plan.Impact.AffectedPackages, pkg.Path) {
+			plan.Impact.AffectedPackages = append(plan.Impact.AffectedPackages, pkg.Path)
+		}
+	}
+	return plan
+}
@@ -0,0 +1,87 @@
+package refactor
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+var namingFiles = map[string]string{
+	"client/client.go": `package client
+
+type ClientConfig struct {
+	Timeout int
+}
+
+func (c *ClientConfig) GetUrl() string { return "" }
+
+func NewClientConfig() *ClientConfig { return &ClientConfig{} }
+
+const max_retries = 3
+
+var user_id, userId int
+`,
+	"app/app.go": `package app
+
+import "example.com/p/client"
+
+func Run() string {
+	cfg := client.NewClientConfig()
+	return cfg.GetUrl()
+}
+`,
+}
+
+func TestFixNaming(t *testing.T) {
+	engine, ws, dir := loadTestModuleWith(t, namingFiles)
+
+	plan, err := engine.FixNaming(ws, types.FixNamingRequest{})
+	if err != nil {
+		t.Fatalf("FixNaming: %v", err)
+	}
+
+	client := planContent(t, plan, filepath.Join(dir, "client", "client.go"))
+	for _, want := range []string{
+		"type Config struct {",
+		"func (c *Config) GetURL() string",
+		"func NewClientConfig() *Config { return &Config{} }",
+		"const maxRetries = 3",
+		"var userID, userId int",
+	} {
+		if !strings.Contains(client, want) {
+			t.Errorf("client.go lacks %q:\n%s", want, client)
+		}
+	}
+	app := planContent(t, plan, filepath.Join(dir, "app", "app.go"))
+	for _, want := range []string{"client.NewClientConfig()", "cfg.GetURL()"} {
+		if !strings.Contains(app, want) {
+			t.Errorf("app.go lacks %q:\n%s", want, app)
+		}
+	}
+
+	// user_id and userId both become userID: only the first is renamed.
+	var skipped int
+	for _, issue := range plan.Impact.PotentialIssues {
+		if strings.Contains(issue.Description, "userId not renamed") {
+			skipped++
+		}
+	}
+	if skipped != 1 {
+		t.Errorf("expected the second rename to userID to be skipped, got %+v", plan.Impact.PotentialIssues)
+	}
+}
+
+func TestFixNaming_Names(t *testing.T) {
+	engine, ws, dir := loadTestModuleWith(t, namingFiles)
+
+	plan, err := engine.FixNaming(ws, types.FixNamingRequest{Package: "client", Names: []string{"ClientConfig.GetUrl"}})
+	if err != nil {
+		t.Fatalf("FixNaming: %v", err)
+	}
+	client := planContent(t, plan, filepath.Join(dir, "client", "client.go"))
+	if !strings.Contains(client, "GetURL()") || !strings.Contains(client, "type ClientConfig struct") || !strings.Contains(client, "max_retries") {
+		t.Errorf("only GetUrl should be renamed:\n%s", client)
+	}
+}
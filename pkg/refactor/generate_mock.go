@@ -0,0 +1,674 @@
+package refactor
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	gotypes "go/types"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mamaar/gorefactor/pkg/analysis"
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// mockDirective marks the files generate_mock writes. It is followed by the
+// interface=, name= and style= settings update_mocks regenerates them with.
+const mockDirective = "//gorefactor:mock"
+
+// mockHeader is the first line of a generated mock, which makes the
+// generated-file policy refuse edits by other operations.
+const mockHeader = "// Code generated by gorefactor generate_mock. DO NOT EDIT."
+
+// fileGenerator is implemented by operations that write generated files.
+// Their plans may replace those files despite the generated-file policy.
+type fileGenerator interface {
+	Generates(path string) bool
+}
+
+// generatesFile reports whether one of the plan's operations is the
+// generator of file.
+func generatesFile(plan *types.RefactoringPlan, file string) bool {
+	for _, op := range plan.Operations {
+		if g, ok := op.(fileGenerator); ok && g.Generates(file) {
+			return true
+		}
+	}
+	return false
+}
+
+// mockSpec is what a mock is generated from: the interface and where the
+// mock goes. It is recorded in the mock's directive.
+type mockSpec struct {
+	ifaceImportPath string
+	ifaceName       string
+	name            string
+	style           types.MockStyle
+
+	// Target package
+	dir        string
+	pkgName    string
+	importPath string
+}
+
+func (s mockSpec) path() string {
+	return filepath.Join(s.dir, "mock_"+strings.ToLower(s.ifaceName)+".go")
+}
+
+func (s mockSpec) directive() string {
+	return fmt.Sprintf("%s interface=%s.%s name=%s style=%s", mockDirective, s.ifaceImportPath, s.ifaceName, s.name, s.style)
+}
+
+// parseMockDirective returns the spec recorded in a mock file, and false
+// for files generate_mock didn't write.
+func parseMockDirective(file *types.File) (mockSpec, bool) {
+	if file.AST == nil {
+		return mockSpec{}, false
+	}
+	for _, group := range file.AST.Comments {
+		if group.Pos() > file.AST.Package {
+			break
+		}
+		for _, c := range group.List {
+			rest, ok := strings.CutPrefix(c.Text, mockDirective+" ")
+			if !ok {
+				continue
+			}
+			spec := mockSpec{dir: filepath.Dir(file.Path), pkgName: file.AST.Name.Name}
+			for _, field := range strings.Fields(rest) {
+				key, value, _ := strings.Cut(field, "=")
+				switch key {
+				case "interface":
+					if i := strings.LastIndex(value, "."); i > 0 {
+						spec.ifaceImportPath, spec.ifaceName = value[:i], value[i+1:]
+					}
+				case "name":
+					spec.name = value
+				case "style":
+					spec.style = types.MockStyle(value)
+				}
+			}
+			return spec, spec.ifaceName != "" && spec.name != ""
+		}
+	}
+	return mockSpec{}, false
+}
+
+// GenerateMockOperation writes a test double for an interface into a file
+// of its own, mock_<interface>.go, which update_mocks regenerates when the
+// interface changes. The func style has a function field per method and
+// returns zero values for unset ones; the moq style follows the layout of
+// github.com/matryer/moq, recording calls and panicking on unset fields.
+type GenerateMockOperation struct {
+	Request types.GenerateMockRequest
+	Parser  *analysis.GoParser // Type-checks packages on demand; may be nil if they already are
+
+	// Resolved by Validate
+	spec  mockSpec
+	named *gotypes.Named
+}
+
+func (op *GenerateMockOperation) Type() types.OperationType {
+	return types.GenerateMockOperation
+}
+
+func (op *GenerateMockOperation) Description() string {
+	return fmt.Sprintf("Generate mock of interface %s", op.Request.InterfaceName)
+}
+
+// Generates reports whether path is the mock file.
+func (op *GenerateMockOperation) Generates(path string) bool {
+	return op.spec.dir != "" && path == op.spec.path()
+}
+
+func (op *GenerateMockOperation) Validate(ws *types.Workspace) error {
+	req := op.Request
+	if req.InterfaceName == "" {
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: "interface name is required",
+		}
+	}
+	style := req.Style
+	if style == "" {
+		style = types.MockStyleFunc
+	}
+	if style != types.MockStyleFunc && style != types.MockStyleMoq {
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("unknown mock style %q: want %s or %s", style, types.MockStyleFunc, types.MockStyleMoq),
+		}
+	}
+	name := req.MockName
+	if name == "" {
+		name = req.InterfaceName + "Mock"
+	}
+	if !isValidGoIdentifier(name) {
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("invalid mock name: %s", name),
+		}
+	}
+
+	pkg, err := op.findInterface(ws)
+	if err != nil {
+		return err
+	}
+	named, err := mockedInterface(ws, op.Parser, pkg, req.InterfaceName)
+	if err != nil {
+		return err
+	}
+	op.named = named
+
+	op.spec = mockSpec{
+		ifaceImportPath: pkg.ImportPath,
+		ifaceName:       req.InterfaceName,
+		name:            name,
+		style:           style,
+		dir:             pkg.Dir,
+		pkgName:         pkg.Name,
+		importPath:      pkg.ImportPath,
+	}
+	target := pkg
+	if req.TargetPackage != "" && req.TargetPackage != pkg.Path && req.TargetPackage != pkg.ImportPath {
+		target = ws.Packages[req.TargetPackage]
+		if target == nil {
+			// A new package, relative to the interface's
+			dir := req.TargetPackage
+			if !filepath.IsAbs(dir) {
+				dir = filepath.Join(pkg.Dir, dir)
+			}
+			op.spec.dir, op.spec.pkgName = dir, filepath.Base(dir)
+			op.spec.importPath = packagePathToImportPath(ws, dir)
+		} else {
+			op.spec.dir, op.spec.pkgName = target.Dir, target.Name
+			op.spec.importPath = target.ImportPath
+		}
+	}
+
+	path := op.spec.path()
+	if target != nil {
+		if file := target.Files[filepath.Base(path)]; file != nil {
+			if _, ok := parseMockDirective(file); !ok {
+				return &types.RefactorError{
+					Type:    types.FileSystemError,
+					Message: fmt.Sprintf("%s exists and was not generated by generate_mock", path),
+					File:    path,
+				}
+			}
+		}
+		if sym := target.Symbols.FindSymbol(name); sym != nil && sym.File != path {
+			return &types.RefactorError{
+				Type:    types.NameConflict,
+				Message: fmt.Sprintf("package %s already declares %s", target.ImportPath, name),
+				File:    sym.File,
+			}
+		}
+	}
+	if op.spec.importPath != pkg.ImportPath {
+		if m := unexportedMethod(named); m != "" {
+			return &types.RefactorError{
+				Type:    types.VisibilityViolation,
+				Message: fmt.Sprintf("%s has unexported method %s, which only a mock in package %s can implement", req.InterfaceName, m, pkg.ImportPath),
+			}
+		}
+	}
+	return nil
+}
+
+// findInterface locates the package declaring the interface, restricted to
+// Request.Package when set.
+func (op *GenerateMockOperation) findInterface(ws *types.Workspace) (*types.Package, error) {
+	req := op.Request
+	var found []*types.Package
+	for _, pkgPath := range slices.Sorted(maps.Keys(ws.Packages)) {
+		pkg := ws.Packages[pkgPath]
+		if req.Package != "" && pkg.Path != req.Package && pkg.ImportPath != req.Package {
+			continue
+		}
+		if interfaceSpec(pkg, req.InterfaceName) != nil {
+			found = append(found, pkg)
+		}
+	}
+
+	switch {
+	case len(found) == 0:
+		return nil, &types.RefactorError{
+			Type:    types.SymbolNotFound,
+			Message: fmt.Sprintf("interface %s not found", req.InterfaceName),
+		}
+	case len(found) > 1:
+		return nil, &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("interface %s is declared in %d packages; specify the package", req.InterfaceName, len(found)),
+		}
+	}
+	return found[0], nil
+}
+
+func (op *GenerateMockOperation) Execute(ws *types.Workspace) (*types.RefactoringPlan, error) {
+	content, err := renderMock(op.spec, op.named)
+	if err != nil {
+		return nil, err
+	}
+	path := op.spec.path()
+	change := types.Change{
+		File:        path,
+		NewText:     string(content),
+		Description: fmt.Sprintf("Create mock %s of %s", op.spec.name, op.Request.InterfaceName),
+	}
+	if old, err := readSource(ws, path); err == nil {
+		change.End = len(old)
+		change.OldText = string(old)
+		change.Description = fmt.Sprintf("Regenerate mock %s of %s", op.spec.name, op.Request.InterfaceName)
+	}
+
+	return &types.RefactoringPlan{
+		Changes:       []types.Change{change},
+		AffectedFiles: []string{path},
+		Impact: &types.ImpactAnalysis{
+			AffectedFiles:    []string{path},
+			AffectedPackages: []string{op.spec.dir},
+		},
+		Reversible: true,
+	}, nil
+}
+
+// UpdateMocksOperation regenerates the files generate_mock wrote from the
+// current declarations of their interfaces. Mocks whose interface is gone
+// are left alone and reported as warnings.
+type UpdateMocksOperation struct {
+	Request types.UpdateMocksRequest
+	Parser  *analysis.GoParser // Type-checks packages on demand; may be nil if they already are
+
+	files []string // Mock files the plan regenerates
+}
+
+func (op *UpdateMocksOperation) Type() types.OperationType {
+	return types.UpdateMocksOperation
+}
+
+func (op *UpdateMocksOperation) Description() string {
+	if op.Request.Package != "" {
+		return fmt.Sprintf("Update mocks in package %s", op.Request.Package)
+	}
+	return "Update mocks"
+}
+
+// Generates reports whether path is a mock the plan regenerates.
+func (op *UpdateMocksOperation) Generates(path string) bool {
+	return slices.Contains(op.files, path)
+}
+
+func (op *UpdateMocksOperation) Validate(ws *types.Workspace) error {
+	if op.Request.Package == "" || op.inScope(ws, op.Request.Package) {
+		return nil
+	}
+	return &types.RefactorError{
+		Type:    types.SymbolNotFound,
+		Message: fmt.Sprintf("package %s not found", op.Request.Package),
+	}
+}
+
+func (op *UpdateMocksOperation) inScope(ws *types.Workspace, pkgPath string) bool {
+	for _, pkg := range ws.Packages {
+		if pkg.Path == pkgPath || pkg.ImportPath == pkgPath {
+			return op.Request.Package == "" || pkg.Path == op.Request.Package || pkg.ImportPath == op.Request.Package
+		}
+	}
+	return false
+}
+
+func (op *UpdateMocksOperation) Execute(ws *types.Workspace) (*types.RefactoringPlan, error) {
+	byImportPath := make(map[string]*types.Package)
+	for _, pkg := range ws.Packages {
+		byImportPath[pkg.ImportPath] = pkg
+	}
+
+	var changes []types.Change
+	var issues []types.Issue
+	var packages []string
+	op.files = nil
+	for _, pkgPath := range slices.Sorted(maps.Keys(ws.Packages)) {
+		pkg := ws.Packages[pkgPath]
+		if !op.inScope(ws, pkg.Path) {
+			continue
+		}
+		for _, fileName := range slices.Sorted(maps.Keys(pkg.Files)) {
+			file := pkg.Files[fileName]
+			spec, ok := parseMockDirective(file)
+			if !ok {
+				continue
+			}
+			spec.importPath = pkg.ImportPath
+			ifacePkg := byImportPath[spec.ifaceImportPath]
+			var named *gotypes.Named
+			err := fmt.Errorf("package %s not found", spec.ifaceImportPath)
+			if ifacePkg != nil {
+				named, err = mockedInterface(ws, op.Parser, ifacePkg, spec.ifaceName)
+			}
+			var content []byte
+			if err == nil {
+				content, err = renderMock(spec, named)
+			}
+			if err != nil {
+				issues = append(issues, types.Issue{
+					Type:        types.IssueCompilationError,
+					Description: fmt.Sprintf("mock %s of %s.%s was not updated: %v", spec.name, spec.ifaceImportPath, spec.ifaceName, err),
+					File:        file.Path,
+					Line:        1,
+					Severity:    types.Warning,
+				})
+				continue
+			}
+			if bytes.Equal(content, file.OriginalContent) {
+				continue
+			}
+			changes = append(changes, types.Change{
+				File:        file.Path,
+				End:         len(file.OriginalContent),
+				OldText:     string(file.OriginalContent),
+				NewText:     string(content),
+				Description: fmt.Sprintf("Regenerate mock %s of %s", spec.name, spec.ifaceName),
+			})
+			op.files = append(op.files, file.Path)
+			if !slices.Contains(packages, pkg.Path) {
+				packages = append(packages, pkg.Path)
+			}
+		}
+	}
+
+	return &types.RefactoringPlan{
+		Changes:       changes,
+		AffectedFiles: slices.Clone(op.files),
+		Impact: &types.ImpactAnalysis{
+			AffectedFiles:    slices.Clone(op.files),
+			AffectedPackages: packages,
+			PotentialIssues:  issues,
+		},
+		Reversible: true,
+	}, nil
+}
+
+// interfaceSpec returns the declaration of the interface type named name
+// in a non-test file of pkg.
+func interfaceSpec(pkg *types.Package, name string) *ast.TypeSpec {
+	for _, fileName := range slices.Sorted(maps.Keys(pkg.Files)) {
+		file := pkg.Files[fileName]
+		if file.AST == nil {
+			continue
+		}
+		for _, decl := range file.AST.Decls {
+			genDecl, ok := decl.(*ast.GenDecl)
+			if !ok || genDecl.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range genDecl.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if _, ok := ts.Type.(*ast.InterfaceType); ok && ts.Name.Name == name {
+					return ts
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// mockedInterface returns the type-checked interface a mock implements.
+// Type errors elsewhere in the package, such as those of a mock that no
+// longer implements it, don't matter. Generic interfaces aren't supported.
+func mockedInterface(ws *types.Workspace, parser *analysis.GoParser, pkg *types.Package, name string) (*gotypes.Named, error) {
+	spec := interfaceSpec(pkg, name)
+	if spec == nil {
+		return nil, &types.RefactorError{
+			Type:    types.SymbolNotFound,
+			Message: fmt.Sprintf("interface %s not found in package %s", name, pkg.ImportPath),
+		}
+	}
+	if parser != nil {
+		parser.EnsureTypeChecked(ws, pkg)
+	}
+	var tn *gotypes.TypeName
+	if pkg.TypesInfo != nil {
+		tn, _ = pkg.TypesInfo.Defs[spec.Name].(*gotypes.TypeName)
+	}
+	if tn == nil {
+		return nil, &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("package %s could not be type-checked; the methods of %s cannot be determined", pkg.ImportPath, name),
+		}
+	}
+	named, ok := tn.Type().(*gotypes.Named)
+	if !ok || named.TypeParams().Len() > 0 {
+		return nil, &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("%s has type parameters, which generated mocks don't support", name),
+		}
+	}
+	return named, nil
+}
+
+func unexportedMethod(named *gotypes.Named) string {
+	iface := named.Underlying().(*gotypes.Interface)
+	for i := range iface.NumMethods() {
+		if m := iface.Method(i); !m.Exported() {
+			return m.Name()
+		}
+	}
+	return ""
+}
+
+// mockParam is a parameter of a mocked method as the mock spells it.
+type mockParam struct {
+	name  string
+	typ   string // ...T for a variadic parameter
+	field string // Field of the recorded call in the moq style
+	slice string // typ with ...T as []T
+}
+
+// mockMethod is a method of the mocked interface.
+type mockMethod struct {
+	name    string
+	params  []mockParam
+	results []string
+}
+
+func (m mockMethod) funcType() string {
+	var params []string
+	for _, p := range m.params {
+		params = append(params, p.name+" "+p.typ)
+	}
+	sig := "func(" + strings.Join(params, ", ") + ")"
+	switch len(m.results) {
+	case 0:
+	case 1:
+		sig += " " + m.results[0]
+	default:
+		sig += " (" + strings.Join(m.results, ", ") + ")"
+	}
+	return sig
+}
+
+func (m mockMethod) args() string {
+	var args []string
+	for _, p := range m.params {
+		arg := p.name
+		if strings.HasPrefix(p.typ, "...") {
+			arg += "..."
+		}
+		args = append(args, arg)
+	}
+	return strings.Join(args, ", ")
+}
+
+// renderMock returns the formatted content of the mock file.
+func renderMock(spec mockSpec, named *gotypes.Named) ([]byte, error) {
+	imports := make(map[string]string) // Import path -> name
+	taken := map[string]bool{"mock": true}
+	qualifier := func(p *gotypes.Package) string {
+		if p.Path() == spec.importPath {
+			return ""
+		}
+		if name, ok := imports[p.Path()]; ok {
+			return name
+		}
+		name := p.Name()
+		for i := 2; taken[name]; i++ {
+			name = fmt.Sprintf("%s%d", p.Name(), i)
+		}
+		taken[name] = true
+		imports[p.Path()] = name
+		return name
+	}
+	var syncName string
+	if spec.style == types.MockStyleMoq {
+		syncName = qualifier(gotypes.NewPackage("sync", "sync"))
+	}
+	ifaceType := gotypes.TypeString(named, qualifier)
+
+	iface := named.Underlying().(*gotypes.Interface)
+	methods := make([]mockMethod, iface.NumMethods())
+	for i := range methods {
+		fn := iface.Method(i)
+		sig := fn.Type().(*gotypes.Signature)
+		m := mockMethod{name: fn.Name()}
+		for j := range sig.Results().Len() {
+			m.results = append(m.results, gotypes.TypeString(sig.Results().At(j).Type(), qualifier))
+		}
+		for j := range sig.Params().Len() {
+			v := sig.Params().At(j)
+			p := mockParam{name: v.Name(), slice: gotypes.TypeString(v.Type(), qualifier)}
+			p.typ = p.slice
+			if sig.Variadic() && j == sig.Params().Len()-1 {
+				p.typ = "..." + gotypes.TypeString(v.Type().(*gotypes.Slice).Elem(), qualifier)
+			}
+			m.params = append(m.params, p)
+		}
+		methods[i] = m
+	}
+	// Parameter names last, so they can't shadow an import
+	for i := range methods {
+		used := make(map[string]bool)
+		for j := range methods[i].results {
+			used[fmt.Sprintf("r%d", j)] = true // Named results of the func style
+		}
+		for j := range methods[i].params {
+			p := &methods[i].params[j]
+			if p.name == "" || p.name == "_" || taken[p.name] || used[p.name] || token.IsKeyword(p.name) {
+				p.name = fmt.Sprintf("in%d", j+1)
+			}
+			used[p.name] = true
+			r, size := utf8.DecodeRuneInString(p.name)
+			p.field = string(unicode.ToUpper(r)) + p.name[size:]
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n%s\n\npackage %s\n\n", mockHeader, spec.directive(), spec.pkgName)
+	var specs []string
+	for _, path := range slices.Sorted(maps.Keys(imports)) {
+		if name := imports[path]; name != filepath.Base(path) {
+			specs = append(specs, fmt.Sprintf("%s %q", name, path))
+		} else {
+			specs = append(specs, fmt.Sprintf("%q", path))
+		}
+	}
+	switch len(specs) {
+	case 0:
+	case 1:
+		fmt.Fprintf(&b, "import %s\n\n", specs[0])
+	default:
+		fmt.Fprintf(&b, "import (\n\t%s\n)\n\n", strings.Join(specs, "\n\t"))
+	}
+	fmt.Fprintf(&b, "// Ensure %s implements %s.\nvar _ %s = &%s{}\n\n", spec.name, spec.ifaceName, ifaceType, spec.name)
+	if spec.style == types.MockStyleMoq {
+		writeMoqMock(&b, spec, methods, syncName)
+	} else {
+		writeFuncMock(&b, spec, methods)
+	}
+
+	content, err := format.Source([]byte(b.String()))
+	if err != nil {
+		return nil, fmt.Errorf("generated mock %s does not parse: %w", spec.name, err)
+	}
+	return content, nil
+}
+
+func writeFuncMock(b *strings.Builder, spec mockSpec, methods []mockMethod) {
+	fmt.Fprintf(b, "// %s is a mock implementation of %s. Methods whose function field\n// is nil return zero values.\ntype %s struct {\n", spec.name, spec.ifaceName, spec.name)
+	for _, m := range methods {
+		fmt.Fprintf(b, "\t%sFunc %s\n", m.name, m.funcType())
+	}
+	b.WriteString("}\n")
+
+	for _, m := range methods {
+		sig := strings.TrimPrefix(mockMethod{params: m.params}.funcType(), "func")
+		if len(m.results) > 0 {
+			var results []string
+			for i, r := range m.results {
+				results = append(results, fmt.Sprintf("r%d %s", i, r))
+			}
+			sig += " (" + strings.Join(results, ", ") + ")"
+		}
+		call := fmt.Sprintf("mock.%sFunc(%s)", m.name, m.args())
+		fmt.Fprintf(b, "\n// %s calls %sFunc.\nfunc (mock *%s) %s%s {\n", m.name, m.name, spec.name, m.name, sig)
+		if len(m.results) == 0 {
+			fmt.Fprintf(b, "\tif mock.%sFunc != nil {\n\t\t%s\n\t}\n}\n", m.name, call)
+			continue
+		}
+		fmt.Fprintf(b, "\tif mock.%sFunc == nil {\n\t\treturn\n\t}\n\treturn %s\n}\n", m.name, call)
+	}
+}
+
+func writeMoqMock(b *strings.Builder, spec mockSpec, methods []mockMethod, syncName string) {
+	fmt.Fprintf(b, "// %s is a mock implementation of %s.\ntype %s struct {\n", spec.name, spec.ifaceName, spec.name)
+	for _, m := range methods {
+		fmt.Fprintf(b, "\t// %sFunc mocks the %s method.\n\t%sFunc %s\n\n", m.name, m.name, m.name, m.funcType())
+	}
+	b.WriteString("\t// calls tracks calls to the methods.\n\tcalls struct {\n")
+	for _, m := range methods {
+		fmt.Fprintf(b, "\t\t// %s holds details about calls to the %s method.\n\t\t%s []%s\n", m.name, m.name, m.name, m.callType())
+	}
+	b.WriteString("\t}\n")
+	for _, m := range methods {
+		fmt.Fprintf(b, "\tlock%s %s.RWMutex\n", m.name, syncName)
+	}
+	b.WriteString("}\n")
+
+	for _, m := range methods {
+		var fields []string
+		for _, p := range m.params {
+			fields = append(fields, fmt.Sprintf("%s: %s,", p.field, p.name))
+		}
+		fmt.Fprintf(b, "\n// %s calls %sFunc.\nfunc (mock *%s) %s%s {\n", m.name, m.name, spec.name, m.name, strings.TrimPrefix(m.funcType(), "func"))
+		fmt.Fprintf(b, "\tif mock.%sFunc == nil {\n\t\tpanic(%q)\n\t}\n", m.name, fmt.Sprintf("%s.%sFunc: method is nil but %s.%s was just called", spec.name, m.name, spec.ifaceName, m.name))
+		fmt.Fprintf(b, "\tcallInfo := %s{\n\t\t%s\n\t}\n", m.callType(), strings.Join(fields, "\n\t\t"))
+		fmt.Fprintf(b, "\tmock.lock%[1]s.Lock()\n\tmock.calls.%[1]s = append(mock.calls.%[1]s, callInfo)\n\tmock.lock%[1]s.Unlock()\n", m.name)
+		if len(m.results) == 0 {
+			fmt.Fprintf(b, "\tmock.%sFunc(%s)\n}\n", m.name, m.args())
+		} else {
+			fmt.Fprintf(b, "\treturn mock.%sFunc(%s)\n}\n", m.name, m.args())
+		}
+
+		fmt.Fprintf(b, "\n// %[1]sCalls gets all the calls that were made to %[1]s.\nfunc (mock *%[2]s) %[1]sCalls() []%[3]s {\n", m.name, spec.name, m.callType())
+		fmt.Fprintf(b, "\tmock.lock%[1]s.RLock()\n\tdefer mock.lock%[1]s.RUnlock()\n\treturn mock.calls.%[1]s\n}\n", m.name)
+	}
+}
+
+// callType is the struct the moq style records a call's arguments in.
+func (m mockMethod) callType() string {
+	if len(m.params) == 0 {
+		return "struct{}"
+	}
+	var fields []string
+	for _, p := range m.params {
+		fields = append(fields, p.field+" "+p.slice)
+	}
+	return "struct {\n" + strings.Join(fields, "\n") + "\n}"
+}
@@ -0,0 +1,130 @@
+package refactor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+var generateMockFiles = map[string]string{
+	"store/store.go": `package store
+
+import "context"
+
+type Item struct{ Name string }
+
+type Store interface {
+	Get(ctx context.Context, id string) (*Item, error)
+	Put(item *Item)
+	Tags(prefix string, names ...string) []string
+}
+`,
+}
+
+func TestGenerateMock(t *testing.T) {
+	engine, ws, dir := loadTestModuleWith(t, generateMockFiles)
+
+	plan, err := engine.GenerateMock(ws, types.GenerateMockRequest{InterfaceName: "Store"})
+	if err != nil {
+		t.Fatalf("GenerateMock: %v", err)
+	}
+	got := planContent(t, plan, filepath.Join(dir, "store", "mock_store.go"))
+	for _, want := range []string{
+		"// Code generated by gorefactor generate_mock. DO NOT EDIT.\n//gorefactor:mock interface=example.com/p/store.Store name=StoreMock style=func\n",
+		"var _ Store = &StoreMock{}",
+		"GetFunc  func(ctx context.Context, id string) (*Item, error)",
+		"func (mock *StoreMock) Get(ctx context.Context, id string) (r0 *Item, r1 error) {",
+		"return mock.TagsFunc(prefix, names...)",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("mock lacks %q:\n%s", want, got)
+		}
+	}
+
+	plan, err = engine.GenerateMock(ws, types.GenerateMockRequest{InterfaceName: "Store", TargetPackage: "mocks", Style: types.MockStyleMoq})
+	if err != nil {
+		t.Fatalf("GenerateMock: %v", err)
+	}
+	got = planContent(t, plan, filepath.Join(dir, "store", "mocks", "mock_store.go"))
+	for _, want := range []string{
+		"package mocks",
+		"\"example.com/p/store\"",
+		"var _ store.Store = &StoreMock{}",
+		"lockGet  sync.RWMutex",
+		"func (mock *StoreMock) PutCalls() []struct {\n\tItem *store.Item\n}",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("moq mock lacks %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestGenerateMock_Conflicts(t *testing.T) {
+	files := map[string]string{
+		"store/store.go":      generateMockFiles["store/store.go"] + "\ntype StoreMock struct{}\n",
+		"store/mock_store.go": "package store\n",
+	}
+	engine, ws, _ := loadTestModuleWith(t, files)
+
+	for _, req := range []types.GenerateMockRequest{
+		{InterfaceName: "Store"},
+		{InterfaceName: "Store", MockName: "FakeStore"},
+		{InterfaceName: "Item"},
+		{InterfaceName: "Store", Style: "gomock"},
+	} {
+		if _, err := engine.GenerateMock(ws, req); err == nil {
+			t.Errorf("GenerateMock(%+v): expected an error", req)
+		}
+	}
+}
+
+func TestUpdateMocks(t *testing.T) {
+	engine, ws, dir := loadTestModuleWith(t, generateMockFiles)
+	plan, err := engine.GenerateMock(ws, types.GenerateMockRequest{InterfaceName: "Store"})
+	if err != nil {
+		t.Fatalf("GenerateMock: %v", err)
+	}
+	if err := engine.ExecutePlan(plan); err != nil {
+		t.Fatalf("ExecutePlan: %v", err)
+	}
+
+	source := filepath.Join(dir, "store", "store.go")
+	content := strings.Replace(generateMockFiles["store/store.go"], "\tPut(item *Item)\n", "\tDelete(id string) error\n", 1)
+	if err := os.WriteFile(source, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ws, err = engine.LoadWorkspace(dir)
+	if err != nil {
+		t.Fatalf("LoadWorkspace: %v", err)
+	}
+
+	plan, err = engine.UpdateMocks(ws, types.UpdateMocksRequest{})
+	if err != nil {
+		t.Fatalf("UpdateMocks: %v", err)
+	}
+	got := planContent(t, plan, filepath.Join(dir, "store", "mock_store.go"))
+	if !strings.Contains(got, "DeleteFunc") || strings.Contains(got, "PutFunc") {
+		t.Errorf("expected the mock to follow the interface:\n%s", got)
+	}
+	if err := engine.ValidateRefactoring(plan); err != nil {
+		t.Errorf("regenerating a mock should be allowed despite the generated-file policy: %v", err)
+	}
+
+	if err := os.WriteFile(source, []byte("package store\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ws, err = engine.LoadWorkspace(dir)
+	if err != nil {
+		t.Fatalf("LoadWorkspace: %v", err)
+	}
+	plan, err = engine.UpdateMocks(ws, types.UpdateMocksRequest{})
+	if err != nil {
+		t.Fatalf("UpdateMocks: %v", err)
+	}
+	if len(plan.Changes) != 0 || len(plan.Impact.PotentialIssues) != 1 {
+		t.Errorf("expected the orphaned mock to be reported, got %d change(s) and issues %+v", len(plan.Changes), plan.Impact.PotentialIssues)
+	}
+}
@@ -0,0 +1,241 @@
+package refactor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// GitApplier applies plans through git. By default a plan is applied to the
+// checkout like ExecutePlan does, and committed when Commit is set. With a
+// Branch or Worktree the plan is applied in a new worktree, on a new branch
+// created from HEAD, so the checkout is left untouched: the worktree is
+// kept for review when Worktree is set, and otherwise committed and
+// removed, leaving the branch.
+type GitApplier struct {
+	Engine   *DefaultEngine
+	Branch   string // Branch to create; with Worktree alone a name is generated
+	Commit   bool   // Commit the plan's files with a message describing its operations
+	Worktree bool   // Keep the worktree the plan is applied in
+}
+
+// GitApplyResult is where a GitApplier put a plan.
+type GitApplyResult struct {
+	Branch   string `json:"branch,omitempty"`   // Branch created for the plan
+	Commit   string `json:"commit,omitempty"`   // Hash of the commit of the plan
+	Worktree string `json:"worktree,omitempty"` // Worktree the plan was applied in, when kept
+	InPlace  bool   `json:"in_place"`           // The plan was applied to the checkout
+}
+
+// Apply applies plan and returns where it went.
+func (g *GitApplier) Apply(ctx context.Context, plan *types.RefactoringPlan) (*GitApplyResult, error) {
+	e := g.Engine
+	if e.root == "" {
+		return nil, fmt.Errorf("git integration requires a loaded workspace")
+	}
+	top, err := runGit(ctx, e.root, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	// The workspace root relative to the repository's, both with symlinks
+	// resolved as git resolves them
+	root, err := filepath.EvalSymlinks(e.root)
+	if err != nil {
+		return nil, err
+	}
+	prefix, err := filepath.Rel(top, root)
+	if err != nil || !filepath.IsLocal(prefix) {
+		return nil, fmt.Errorf("workspace %s is outside the git repository %s", e.root, top)
+	}
+	// The files the plan writes, without those only changes below the
+	// minimum confidence would write
+	kept, _ := SelectConfidence(plan, e.config.MinConfidence)
+	paths, err := repoPaths(e.root, prefix, changedFiles(kept))
+	if err != nil {
+		return nil, err
+	}
+
+	if g.Branch == "" && !g.Worktree {
+		if err := e.ExecutePlanContext(ctx, plan); err != nil {
+			return nil, err
+		}
+		result := &GitApplyResult{InPlace: true}
+		if g.Commit {
+			if result.Commit, err = commitPaths(ctx, top, paths, commitMessage(plan)); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	}
+
+	// The plan was made from the checkout, so its files must match HEAD.
+	if len(paths) > 0 {
+		status, err := runGit(ctx, top, append([]string{"status", "--porcelain", "--"}, paths...)...)
+		if err != nil {
+			return nil, err
+		}
+		if status != "" {
+			return nil, fmt.Errorf("files of the plan differ from HEAD; commit them or apply the plan in place:\n%s", status)
+		}
+	}
+
+	branch := g.Branch
+	if branch == "" {
+		branch = "refactor/" + time.Now().Format("20060102-150405")
+	}
+	dir, err := os.MkdirTemp("", "gorefactor-worktree-")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := runGit(ctx, top, "worktree", "add", "-q", "-b", branch, dir, "HEAD"); err != nil {
+		_ = os.Remove(dir)
+		return nil, err
+	}
+	cleanup := func() {
+		_, _ = runGit(context.Background(), top, "worktree", "remove", "--force", dir)
+	}
+	// discard removes the worktree and the branch of a plan that failed.
+	discard := func() {
+		cleanup()
+		_, _ = runGit(context.Background(), top, "branch", "-D", branch)
+	}
+
+	wt := e.rooted(filepath.Join(dir, prefix))
+	if err := wt.ExecutePlanContext(ctx, rebasePlan(plan, e.root, wt.root)); err != nil {
+		discard()
+		return nil, err
+	}
+
+	result := &GitApplyResult{Branch: branch}
+	if g.Commit || !g.Worktree {
+		if result.Commit, err = commitPaths(ctx, dir, paths, commitMessage(plan)); err != nil {
+			discard()
+			return nil, err
+		}
+	}
+	if g.Worktree {
+		result.Worktree = dir
+	} else {
+		cleanup()
+	}
+	return result, nil
+}
+
+// rooted returns a copy of the engine that applies plans below root
+// without recording them in a history journal.
+func (e *DefaultEngine) rooted(root string) *DefaultEngine {
+	wt := *e
+	wt.root = root
+	if e.config != nil {
+		config := *e.config
+		config.Journal = false
+		wt.config = &config
+	}
+	return &wt
+}
+
+// rebasePlan returns plan with the files below from moved below to.
+func rebasePlan(plan *types.RefactoringPlan, from, to string) *types.RefactoringPlan {
+	move := func(path string) string {
+		if rel, err := filepath.Rel(from, path); err == nil && filepath.IsLocal(rel) {
+			return filepath.Join(to, rel)
+		}
+		return path
+	}
+	rebased := *plan
+	rebased.Changes = make([]types.Change, len(plan.Changes))
+	for i, c := range plan.Changes {
+		c.File = move(c.File)
+		rebased.Changes[i] = c
+	}
+	rebased.AffectedFiles = make([]string, len(plan.AffectedFiles))
+	for i, f := range plan.AffectedFiles {
+		rebased.AffectedFiles[i] = move(f)
+	}
+	return &rebased
+}
+
+// repoPaths returns files, which must lie below the workspace root, as
+// paths relative to the repository root; prefix is the workspace root
+// relative to the repository's.
+func repoPaths(root, prefix string, files []string) ([]string, error) {
+	var paths []string
+	for _, f := range files {
+		rel := f
+		if filepath.IsAbs(f) {
+			var err error
+			if rel, err = filepath.Rel(root, f); err != nil {
+				rel = f
+			}
+		}
+		if !filepath.IsLocal(rel) {
+			return nil, fmt.Errorf("%s is outside the workspace %s", f, root)
+		}
+		paths = append(paths, filepath.ToSlash(filepath.Join(prefix, rel)))
+	}
+	return paths, nil
+}
+
+// commitPaths commits the current content of paths, relative to the
+// repository root, in the worktree at dir, leaving anything else staged
+// alone, and returns the commit's hash.
+func commitPaths(ctx context.Context, dir string, paths []string, message string) (string, error) {
+	if len(paths) == 0 {
+		return "", fmt.Errorf("the plan changes no files to commit")
+	}
+	top, err := runGit(ctx, dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+	if _, err := runGit(ctx, top, append([]string{"add", "-A", "--"}, paths...)...); err != nil {
+		return "", err
+	}
+	if _, err := runGit(ctx, top, append([]string{"commit", "-q", "-m", message, "--"}, paths...)...); err != nil {
+		return "", err
+	}
+	return runGit(ctx, top, "rev-parse", "HEAD")
+}
+
+// commitMessage describes the operations of plan: the operation as the
+// subject, or a count of them with one line each in the body.
+func commitMessage(plan *types.RefactoringPlan) string {
+	var descs []string
+	for _, op := range plan.Operations {
+		descs = append(descs, op.Description())
+	}
+	switch len(descs) {
+	case 0:
+		return fmt.Sprintf("Apply %d refactoring changes", len(plan.Changes))
+	case 1:
+		return descs[0]
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Apply %d refactorings\n", len(descs))
+	for _, desc := range descs {
+		fmt.Fprintf(&b, "\n- %s", desc)
+	}
+	return b.String()
+}
+
+// runGit runs git in dir and returns its trimmed output.
+func runGit(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
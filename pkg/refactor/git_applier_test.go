@@ -0,0 +1,111 @@
+package refactor
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+func TestGitApplier(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	for name, value := range map[string]string{
+		"GIT_AUTHOR_NAME": "test", "GIT_AUTHOR_EMAIL": "test@example.com",
+		"GIT_COMMITTER_NAME": "test", "GIT_COMMITTER_EMAIL": "test@example.com",
+	} {
+		t.Setenv(name, value)
+	}
+	engine, _, dir := loadTestModuleWith(t, nil)
+	ctx := context.Background()
+	git := func(args ...string) string {
+		t.Helper()
+		out, err := runGit(ctx, dir, args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+	git("init", "-q")
+	git("add", "-A")
+	git("commit", "-q", "-m", "init")
+	head := git("rev-parse", "HEAD")
+
+	file := filepath.Join(dir, "a", "a.go")
+	src, _ := os.ReadFile(file)
+	start := strings.Index(string(src), "A()")
+	rename := &types.RefactoringPlan{
+		Changes:       []types.Change{{File: file, Start: start, End: start + 1, OldText: "A", NewText: "Z"}},
+		AffectedFiles: []string{file},
+	}
+
+	// A branch is committed in a worktree that is then removed.
+	res, err := (&GitApplier{Engine: engine, Branch: "refactor/z"}).Apply(ctx, rename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Branch != "refactor/z" || res.Commit == "" || res.Worktree != "" || res.InPlace {
+		t.Errorf("branch result = %+v", res)
+	}
+	if got, _ := os.ReadFile(file); string(got) != string(src) {
+		t.Errorf("the checkout was modified:\n%s", got)
+	}
+	if got := git("show", "refactor/z:a/a.go"); !strings.Contains(got, "func Z()") {
+		t.Errorf("branch content:\n%s", got)
+	}
+	if got := git("log", "-1", "--format=%s", "refactor/z"); got != "Apply 1 refactoring changes" {
+		t.Errorf("commit message = %q", got)
+	}
+	if got := git("worktree", "list"); strings.Count(got, "\n") != 0 {
+		t.Errorf("worktree left behind:\n%s", got)
+	}
+
+	// A kept worktree is only committed on request.
+	res, err = (&GitApplier{Engine: engine, Worktree: true}).Apply(ctx, rename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _, _ = runGit(context.Background(), dir, "worktree", "remove", "--force", res.Worktree) })
+	if !strings.HasPrefix(res.Branch, "refactor/") || res.Commit != "" || res.Worktree == "" {
+		t.Errorf("worktree result = %+v", res)
+	}
+	if got, _ := os.ReadFile(filepath.Join(res.Worktree, "a", "a.go")); !strings.Contains(string(got), "func Z()") {
+		t.Errorf("worktree content:\n%s", got)
+	}
+	if got := git("rev-parse", res.Branch); got != head {
+		t.Errorf("worktree branch moved to %s without -git-commit", got)
+	}
+
+	// In place, the plan's files are committed on the current branch.
+	res, err = (&GitApplier{Engine: engine, Commit: true}).Apply(ctx, rename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.InPlace || res.Commit != git("rev-parse", "HEAD") {
+		t.Errorf("in-place result = %+v", res)
+	}
+	if got := git("status", "--porcelain"); got != "" {
+		t.Errorf("uncommitted changes left:\n%s", got)
+	}
+
+	// A worktree starts from HEAD, which no longer matches the plan.
+	if _, err := (&GitApplier{Engine: engine, Branch: "refactor/stale"}).Apply(ctx, rename); err == nil {
+		t.Error("expected the stale plan to fail in a worktree")
+	}
+	if strings.Contains(git("branch", "--list", "refactor/stale"), "stale") {
+		t.Error("the branch of a failed plan was kept")
+	}
+
+	// Nor is the branch of a plan that fails to commit.
+	if _, err := (&GitApplier{Engine: engine, Branch: "refactor/empty"}).Apply(ctx, &types.RefactoringPlan{}); err == nil {
+		t.Error("expected a plan changing no files to fail to commit")
+	}
+	if strings.Contains(git("branch", "--list", "refactor/empty"), "empty") {
+		t.Error("the branch of a plan that failed to commit was kept")
+	}
+}
@@ -0,0 +1,557 @@
+package refactor
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"maps"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// ImportManager keeps the imports of the Go files a plan edits in line with
+// their edited source. Operations leave imports to it: it renders each
+// edited file in memory and, from the result's syntax tree, adds the imports
+// of packages the file newly refers to, removes the ones it no longer uses
+// and drops duplicates, rewriting the file's import declarations in a single
+// change.
+//
+// A package a file newly refers to is found among the offered import paths,
+// then the imports of the plan's files and of the other files of the file's
+// package, then the workspace packages; a name matching more than one
+// package is left unresolved. New imports take the alias of the first
+// standardize_imports rule their path matches. Imports whose package name
+// can't be known without loading the package, such as unaliased third-party
+// imports, are never removed.
+type ImportManager struct {
+	ws      *types.Workspace
+	aliases []types.AliasRule
+	offered []string
+	known   map[string]string // Package names by import path, as told by Name
+}
+
+// NewImportManager returns an import manager for plans on ws that names new
+// imports by the alias rules.
+func NewImportManager(ws *types.Workspace, aliases []types.AliasRule) *ImportManager {
+	return &ImportManager{ws: ws, aliases: aliases}
+}
+
+// Offer adds import paths to resolve the package names of edited files with
+// before any other import, e.g. the target package of a move.
+func (m *ImportManager) Offer(importPaths ...string) {
+	m.offered = append(m.offered, importPaths...)
+}
+
+// Name tells the import manager the package name of importPath, which it
+// otherwise guesses from the path for packages outside the workspace and
+// the standard library.
+func (m *ImportManager) Name(importPath, name string) {
+	if m.known == nil {
+		m.known = make(map[string]string)
+	}
+	m.known[importPath] = name
+}
+
+// Update adds to plan the import changes of every Go file it edits. Changes
+// of the plan within a file's import declarations are replaced by the new
+// declarations, which take their effect into account. A file whose edited
+// source doesn't parse, which imports "C", or whose changes overlap its
+// import declarations only in part, is left as the plan has it.
+func (m *ImportManager) Update(plan *types.RefactoringPlan) {
+	byFile := make(map[string][]int)
+	var files []string
+	for i, c := range plan.Changes {
+		if !strings.HasSuffix(c.File, ".go") {
+			continue
+		}
+		if _, ok := byFile[c.File]; !ok {
+			files = append(files, c.File)
+		}
+		byFile[c.File] = append(byFile[c.File], i)
+	}
+
+	candidates := m.candidates(files)
+	drop := make(map[int]bool)
+	var added []types.Change
+	for _, file := range files {
+		change, replaced := m.updateFile(file, plan.Changes, byFile[file], candidates)
+		if change == nil {
+			continue
+		}
+		for _, i := range replaced {
+			drop[i] = true
+		}
+		added = append(added, *change)
+	}
+
+	if len(added) == 0 {
+		return
+	}
+	changes := make([]types.Change, 0, len(plan.Changes)-len(drop)+len(added))
+	for i, c := range plan.Changes {
+		if !drop[i] {
+			changes = append(changes, c)
+		}
+	}
+	plan.Changes = append(changes, added...)
+	for _, c := range added {
+		if !slices.Contains(plan.AffectedFiles, c.File) {
+			plan.AffectedFiles = append(plan.AffectedFiles, c.File)
+		}
+	}
+}
+
+// manageImports updates the imports of the files plan edits with the
+// configured alias rules, offering importPaths first.
+func (e *DefaultEngine) manageImports(ws *types.Workspace, plan *types.RefactoringPlan, importPaths ...string) {
+	m := NewImportManager(ws, e.config.ImportAliases)
+	m.Offer(importPaths...)
+	m.Update(plan)
+}
+
+// importCandidate is an import path a package name may refer to, with the
+// alias it was imported by, if any.
+type importCandidate struct {
+	path  string
+	alias string
+}
+
+// candidateTiers are the import candidates for resolving package names,
+// in the order they are tried.
+type candidateTiers [][]importCandidate
+
+// candidates returns the offered import paths, the imports of files and
+// of the other files of their packages, and the workspace packages.
+func (m *ImportManager) candidates(files []string) candidateTiers {
+	var offered []importCandidate
+	for _, p := range m.offered {
+		offered = append(offered, importCandidate{path: p})
+	}
+
+	var imported []importCandidate
+	seenFile := make(map[string]bool)
+	addFile := func(f *types.File) {
+		if f == nil || f.AST == nil || seenFile[f.Path] {
+			return
+		}
+		seenFile[f.Path] = true
+		for _, spec := range f.AST.Imports {
+			p, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				continue
+			}
+			c := importCandidate{path: p}
+			if spec.Name != nil {
+				c.alias = spec.Name.Name
+			}
+			imported = append(imported, c)
+		}
+	}
+	for _, file := range files {
+		if pkg := m.ws.Packages[filepath.Dir(file)]; pkg != nil {
+			for _, f := range packageFiles(pkg) {
+				if f.Path == file {
+					addFile(f)
+				}
+			}
+		}
+	}
+	for _, file := range files {
+		if pkg := m.ws.Packages[filepath.Dir(file)]; pkg != nil {
+			for _, f := range packageFiles(pkg) {
+				addFile(f)
+			}
+		}
+	}
+
+	var workspace []importCandidate
+	for _, pkg := range m.ws.Packages {
+		if pkg.ImportPath != "" {
+			workspace = append(workspace, importCandidate{path: pkg.ImportPath})
+		}
+	}
+	sort.Slice(workspace, func(i, j int) bool { return workspace[i].path < workspace[j].path })
+	return candidateTiers{offered, imported, workspace}
+}
+
+// updateFile returns the change rewriting the import declarations of file
+// after the plan's changes at idx, and which of those changes it replaces,
+// or a nil change when the imports are fine or the file is left alone.
+func (m *ImportManager) updateFile(file string, all []types.Change, idx []int, candidates candidateTiers) (*types.Change, []int) {
+	src, err := readSource(m.ws, file)
+	if err != nil {
+		src = nil // A file the plan creates
+	}
+	changes := make([]types.Change, len(idx))
+	for n, i := range idx {
+		changes[n] = all[i]
+	}
+	edited, err := applyInMemory(string(src), changes)
+	if err != nil || edited == "" {
+		return nil, nil
+	}
+	block, summary, ok := m.fixSource(file, edited, candidates)
+	if !ok {
+		return nil, nil
+	}
+	// Import declarations inserted by the operation are merged even when
+	// the imports themselves are fine.
+	if summary == "" {
+		if !slices.ContainsFunc(changes, func(c types.Change) bool { return isImportDecl(c.NewText) }) {
+			return nil, nil
+		}
+		summary = "merge import declarations"
+	}
+	desc := "Update imports: " + summary
+
+	// A file created by a single change gets the fixed content instead.
+	if len(src) == 0 {
+		if len(idx) != 1 {
+			return nil, nil
+		}
+		start, end, ok := importRegion(edited)
+		if !ok {
+			return nil, nil
+		}
+		c := all[idx[0]]
+		c.NewText = edited[:start] + importReplacement(edited, start, end, block) + edited[end:]
+		c.Description += "; " + summary
+		return &c, idx
+	}
+
+	start, end, ok := importRegion(string(src))
+	if !ok {
+		return nil, nil
+	}
+	var replaced []int
+	for _, i := range idx {
+		c := all[i]
+		switch {
+		case c.Start == c.End && (c.Start == start || c.Start == end) && isImportDecl(c.NewText):
+			replaced = append(replaced, i)
+		case start == end && c.Start == start && c.End == start:
+			// Only import declarations may be inserted where the new ones go.
+			return nil, nil
+		case c.Start == c.End && (c.Start <= start || c.Start >= end):
+			// Other insertions before or after the declarations stay.
+		case c.Start >= start && c.End <= end:
+			replaced = append(replaced, i)
+		case c.Start < end && start < c.End:
+			return nil, nil
+		}
+	}
+	return &types.Change{
+		File:        file,
+		Start:       start,
+		End:         end,
+		OldText:     string(src[start:end]),
+		NewText:     importReplacement(string(src), start, end, block),
+		Description: desc,
+	}, replaced
+}
+
+// isImportDecl reports whether text inserted into a file declares imports.
+func isImportDecl(text string) bool {
+	return strings.HasPrefix(strings.TrimSpace(text), "import")
+}
+
+// importReplacement returns the text replacing the import region start:end
+// of src with the import declaration block, empty for none.
+func importReplacement(src string, start, end int, block string) string {
+	switch {
+	case block == "":
+		return ""
+	case start == end:
+		return "\n" + block + "\n"
+	case src[end-1] == '\n':
+		return block + "\n"
+	}
+	return block
+}
+
+// fixSource returns the import declaration block src should have and a
+// summary of how its imports differ from src's, empty when they don't, or
+// false when src can't be handled.
+func (m *ImportManager) fixSource(file, src string, candidates candidateTiers) (string, string, bool) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, file, src, parser.ParseComments)
+	if err != nil {
+		return "", "", false
+	}
+
+	self := ""
+	declared := make(map[string]bool)
+	if pkg := m.ws.Packages[filepath.Dir(file)]; pkg != nil {
+		if pkg.Name == f.Name.Name {
+			self = pkg.ImportPath
+		}
+		for _, pf := range packageFiles(pkg) {
+			if pf.Path != file && pf.AST != nil && pf.AST.Name.Name == f.Name.Name {
+				for name := range topLevelNames(pf.AST) {
+					declared[name] = true
+				}
+			}
+		}
+	}
+
+	// Package names the file refers to: selector operands not resolved
+	// within the file.
+	used := make(map[string]bool)
+	ast.Inspect(f, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if id, ok := sel.X.(*ast.Ident); ok && id.Obj == nil {
+				used[id.Name] = true
+			}
+		}
+		return true
+	})
+
+	var entries []importEntry
+	var removed, added []string
+	names := make(map[string]bool)
+	seen := make(map[importEntry]bool)
+	for _, spec := range f.Imports {
+		p, err := strconv.Unquote(spec.Path.Value)
+		if err != nil || p == "C" {
+			return "", "", false
+		}
+		e := importEntry{path: p}
+		if spec.Name != nil {
+			e.alias = spec.Name.Name
+		}
+		if spec.Comment != nil {
+			e.comment = spec.Comment.List[len(spec.Comment.List)-1].Text
+		}
+		name, known := e.alias, e.alias != ""
+		if !known {
+			name, known = m.packageName(p)
+		}
+		key := importEntry{alias: name, path: p}
+		switch {
+		case seen[key]:
+			removed = append(removed, strconv.Quote(p)+" (duplicate)")
+			continue
+		case known && name != "_" && name != "." && !used[name]:
+			removed = append(removed, strconv.Quote(p))
+			continue
+		}
+		seen[key] = true
+		names[name] = true
+		entries = append(entries, e)
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(used)) {
+		if names[name] || declared[name] || f.Scope.Lookup(name) != nil || isUniverseName(name) {
+			continue
+		}
+		c, ok := m.resolve(name, candidates, self)
+		if !ok {
+			continue
+		}
+		e := importEntry{path: c.path}
+		if natural, _ := m.packageName(c.path); natural != name {
+			e.alias = name
+		}
+		entries = append(entries, e)
+		names[name] = true
+		added = append(added, strconv.Quote(c.path))
+	}
+	var block string
+	switch len(entries) {
+	case 0:
+	case 1:
+		var b strings.Builder
+		b.WriteString("import ")
+		writeImportLine(&b, entries[0])
+		block = b.String()
+	default:
+		modulePath := ""
+		if m.ws.Module != nil {
+			modulePath = m.ws.Module.Path
+		}
+		block = renderImportBlock(sortImportGroups(entries, func(p string) ImportGroup {
+			return classifyImport(p, modulePath, nil)
+		}), nil)
+	}
+
+	var parts []string
+	if len(added) > 0 {
+		parts = append(parts, "add "+strings.Join(added, ", "))
+	}
+	if len(removed) > 0 {
+		parts = append(parts, "remove "+strings.Join(removed, ", "))
+	}
+	return block, strings.Join(parts, "; "), true
+}
+
+// resolve returns the import the package name refers to, from the first
+// tier of candidates naming exactly one import path.
+func (m *ImportManager) resolve(name string, tiers candidateTiers, self string) (importCandidate, bool) {
+	for _, tier := range tiers {
+		var match []importCandidate
+		for _, c := range tier {
+			if c.path == self || !m.names(c, name) {
+				continue
+			}
+			if !slices.ContainsFunc(match, func(o importCandidate) bool { return o.path == c.path }) {
+				match = append(match, c)
+			}
+		}
+		switch len(match) {
+		case 1:
+			return match[0], true
+		case 0:
+			continue
+		}
+		return importCandidate{}, false
+	}
+	return importCandidate{}, false
+}
+
+// names reports whether name refers to c's package: by an alias rule
+// matching its path, the alias it was imported by or its package name.
+func (m *ImportManager) names(c importCandidate, name string) bool {
+	if alias := m.ruleAlias(c.path); alias != "" {
+		return alias == name
+	}
+	if c.alias != "" {
+		return c.alias == name
+	}
+	pkgName, _ := m.packageName(c.path)
+	return pkgName == name
+}
+
+// ruleAlias returns the alias of the first rule matching importPath.
+func (m *ImportManager) ruleAlias(importPath string) string {
+	for _, r := range m.aliases {
+		if matchesAliasPattern(importPath, r.PackagePattern) {
+			return r.Alias
+		}
+	}
+	return ""
+}
+
+// versionSuffix matches the major version element of a module path.
+var versionSuffix = regexp.MustCompile(`^v[0-9]+$`)
+
+// packageName returns the name of the package at importPath and whether it
+// is known, for a workspace or standard library package. Otherwise it is
+// guessed from the path, as goimports does.
+func (m *ImportManager) packageName(importPath string) (string, bool) {
+	if name, ok := m.known[importPath]; ok {
+		return name, true
+	}
+	if dir, ok := m.ws.ImportToPath[importPath]; ok {
+		if pkg := m.ws.Packages[dir]; pkg != nil && pkg.Name != "" {
+			return pkg.Name, true
+		}
+	}
+	base := path.Base(importPath)
+	if versionSuffix.MatchString(base) && strings.Contains(importPath, "/") {
+		base = path.Base(path.Dir(importPath))
+	}
+	if classifyImport(importPath, "", nil) == ImportGroupStdlib {
+		return base, true
+	}
+	base = strings.TrimPrefix(base, "go-")
+	if i := strings.IndexAny(base, ".-"); i > 0 {
+		base = base[:i]
+	}
+	return base, false
+}
+
+// importRegion returns the byte range of the import declarations of src,
+// through the newline ending the last one, or, when it has none, the empty
+// range at the start of the line after the package clause.
+func importRegion(src string) (start, end int, ok bool) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ImportsOnly|parser.ParseComments)
+	if err != nil {
+		return 0, 0, false
+	}
+	start, end = -1, -1
+	for _, decl := range f.Decls {
+		if d, ok := decl.(*ast.GenDecl); ok && d.Tok == token.IMPORT {
+			if start < 0 {
+				start = fset.Position(d.Pos()).Offset
+			}
+			end = fset.Position(d.End()).Offset
+		}
+	}
+	if start >= 0 && end < len(src) && src[end] == '\n' {
+		end++
+	}
+	if start < 0 {
+		end = fset.Position(f.Name.End()).Offset
+		if i := strings.IndexByte(src[end:], '\n'); i >= 0 {
+			end += i + 1
+		} else {
+			end = len(src)
+		}
+		return end, end, true
+	}
+	return start, end, true
+}
+
+// topLevelNames returns the names f declares at package level.
+func topLevelNames(f *ast.File) map[string]bool {
+	names := make(map[string]bool)
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Recv == nil {
+				names[d.Name.Name] = true
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					names[s.Name.Name] = true
+				case *ast.ValueSpec:
+					for _, n := range s.Names {
+						names[n.Name] = true
+					}
+				}
+			}
+		}
+	}
+	return names
+}
+
+// isUniverseName reports whether name is predeclared, so never a package.
+func isUniverseName(name string) bool {
+	switch name {
+	case "bool", "byte", "complex64", "complex128", "error", "float32", "float64",
+		"int", "int8", "int16", "int32", "int64", "rune", "string",
+		"uint", "uint8", "uint16", "uint32", "uint64", "uintptr", "any", "comparable",
+		"true", "false", "iota", "nil":
+		return true
+	}
+	return false
+}
+
+// applyInMemory returns src with changes applied, as the serializer would
+// before formatting.
+func applyInMemory(src string, changes []types.Change) (string, error) {
+	var s Serializer
+	sorted := slices.Clone(changes)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Start > sorted[j].Start })
+	if err := s.validateChangePositions(sorted); err != nil {
+		return "", fmt.Errorf("invalid change positions: %v", err)
+	}
+	for _, c := range sorted {
+		var err error
+		if src, err = s.applyChange(src, c); err != nil {
+			return "", err
+		}
+	}
+	return src, nil
+}
@@ -0,0 +1,132 @@
+package refactor
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// loadImportTestWorkspace writes b.go over the test module and loads it.
+func loadImportTestWorkspace(t *testing.T, b string) (*types.Workspace, string) {
+	t.Helper()
+	dir := writeTestModule(t)
+	path := filepath.Join(dir, "b/b.go")
+	if err := os.WriteFile(path, []byte(b), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	engine := CreateEngineWithConfig(&EngineConfig{SkipCompilation: true}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ws, err := engine.LoadWorkspace(dir)
+	if err != nil {
+		t.Fatalf("LoadWorkspace: %v", err)
+	}
+	return ws, path
+}
+
+// replaceChange returns the change replacing old with new in src.
+func replaceChange(file, src, old, new string) types.Change {
+	start := strings.Index(src, old)
+	return types.Change{File: file, Start: start, End: start + len(old), OldText: old, NewText: new}
+}
+
+// applyPlanTo returns the source of file after plan's changes.
+func applyPlanTo(t *testing.T, src, file string, plan *types.RefactoringPlan) string {
+	t.Helper()
+	var changes []types.Change
+	for _, c := range plan.Changes {
+		if c.File == file {
+			changes = append(changes, c)
+		}
+	}
+	out, err := applyInMemory(src, changes)
+	if err != nil {
+		t.Fatalf("applying plan: %v", err)
+	}
+	return out
+}
+
+func TestImportManager_AddsWorkspaceImport(t *testing.T) {
+	src := "package b\n\nfunc B() {}\n"
+	ws, b := loadImportTestWorkspace(t, src)
+	plan := &types.RefactoringPlan{
+		Changes:       []types.Change{replaceChange(b, src, "{}", "{ a.A() }")},
+		AffectedFiles: []string{b},
+	}
+	NewImportManager(ws, nil).Update(plan)
+
+	want := "package b\n\nimport \"example.com/p/a\"\n\nfunc B() { a.A() }\n"
+	if got := applyPlanTo(t, src, b, plan); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestImportManager_RemovesUnusedImport(t *testing.T) {
+	src := "package b\n\nimport (\n\t\"fmt\"\n\n\t\"example.com/p/a\"\n)\n\nfunc B() { a.A(); fmt.Println() }\n"
+	ws, b := loadImportTestWorkspace(t, src)
+	plan := &types.RefactoringPlan{
+		Changes:       []types.Change{replaceChange(b, src, "a.A(); ", "")},
+		AffectedFiles: []string{b},
+	}
+	NewImportManager(ws, nil).Update(plan)
+
+	want := "package b\n\nimport \"fmt\"\n\nfunc B() { fmt.Println() }\n"
+	if got := applyPlanTo(t, src, b, plan); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestImportManager_RemovesDuplicateImport(t *testing.T) {
+	src := "package b\n\nimport (\n\t\"fmt\"\n\t\"fmt\"\n)\n\nfunc B() { fmt.Println() }\n"
+	ws, b := loadImportTestWorkspace(t, src)
+	plan := &types.RefactoringPlan{
+		Changes:       []types.Change{replaceChange(b, src, "fmt.Println()", "fmt.Print()")},
+		AffectedFiles: []string{b},
+	}
+	NewImportManager(ws, nil).Update(plan)
+
+	want := "package b\n\nimport \"fmt\"\n\nfunc B() { fmt.Print() }\n"
+	if got := applyPlanTo(t, src, b, plan); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestImportManager_AliasRule(t *testing.T) {
+	src := "package b\n\nfunc B() {}\n"
+	ws, b := loadImportTestWorkspace(t, src)
+	plan := &types.RefactoringPlan{
+		Changes:       []types.Change{replaceChange(b, src, "{}", "{ pa.A() }")},
+		AffectedFiles: []string{b},
+	}
+	NewImportManager(ws, []types.AliasRule{{PackagePattern: "example.com/p/a", Alias: "pa"}}).Update(plan)
+
+	want := "package b\n\nimport pa \"example.com/p/a\"\n\nfunc B() { pa.A() }\n"
+	if got := applyPlanTo(t, src, b, plan); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestImportManager_ReplacesImportInsertion(t *testing.T) {
+	src := "package b\n\nimport \"fmt\"\n\nfunc B() { fmt.Println() }\n"
+	ws, b := loadImportTestWorkspace(t, src)
+	importEnd := strings.Index(src, "\n\nfunc") + 1
+	plan := &types.RefactoringPlan{
+		Changes: []types.Change{
+			{File: b, Start: importEnd, End: importEnd, NewText: "import \"example.com/p/a\"\n"},
+			replaceChange(b, src, "fmt.Println()", "fmt.Println(); a.A()"),
+		},
+		AffectedFiles: []string{b},
+	}
+	NewImportManager(ws, nil).Update(plan)
+
+	if len(plan.Changes) != 2 {
+		t.Fatalf("expected the insertion to be replaced, got %d changes", len(plan.Changes))
+	}
+	want := "package b\n\nimport (\n\t\"fmt\"\n\n\t\"example.com/p/a\"\n)\n\nfunc B() { fmt.Println(); a.A() }\n"
+	if got := applyPlanTo(t, src, b, plan); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
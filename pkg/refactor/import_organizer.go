@@ -30,6 +30,14 @@ type importEntry struct {
 // blank lines, with alphabetical sorting within each group.  On any error
 // (parse failure, etc.) the original source is returned unchanged.
 func organizeImports(src string, modulePath string, workspaceModules []string) string {
+	return groupImports(src, func(path string) ImportGroup {
+		return classifyImport(path, modulePath, workspaceModules)
+	})
+}
+
+// groupImports is organizeImports with the group of each import path
+// decided by classify.
+func groupImports(src string, classify func(path string) ImportGroup) string {
 	fset := token.NewFileSet()
 	f, err := parser.ParseFile(fset, "", src, parser.ParseComments)
 	if err != nil {
@@ -95,22 +103,8 @@ func organizeImports(src string, modulePath string, workspaceModules []string) s
 		return src
 	}
 
-	// Classify and group.
-	groups := make(map[ImportGroup][]importEntry)
-	for _, e := range entries {
-		g := classifyImport(e.path, modulePath, workspaceModules)
-		groups[g] = append(groups[g], e)
-	}
-
-	// Sort within each group.
-	for g := range groups {
-		sort.Slice(groups[g], func(i, j int) bool {
-			return groups[g][i].path < groups[g][j].path
-		})
-	}
-
 	// Render the new import block.
-	newBlock := renderImportBlock(groups, cgoEntries)
+	newBlock := renderImportBlock(sortImportGroups(entries, classify), cgoEntries)
 
 	// Replace the old import declarations with the new block.
 	startOff := fset.Position(firstImportPos).Offset
@@ -124,6 +118,21 @@ func organizeImports(src string, modulePath string, workspaceModules []string) s
 	return result
 }
 
+// sortImportGroups groups entries by classify and sorts each group by path.
+func sortImportGroups(entries []importEntry, classify func(path string) ImportGroup) map[ImportGroup][]importEntry {
+	groups := make(map[ImportGroup][]importEntry)
+	for _, e := range entries {
+		g := classify(e.path)
+		groups[g] = append(groups[g], e)
+	}
+	for g := range groups {
+		sort.Slice(groups[g], func(i, j int) bool {
+			return groups[g][i].path < groups[g][j].path
+		})
+	}
+	return groups
+}
+
 // classifyImport determines which group an import path belongs to.
 func classifyImport(importPath, modulePath string, workspaceModules []string) ImportGroup {
 	// Module match: import path starts with the module path.
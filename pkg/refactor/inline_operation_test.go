@@ -1,6 +1,10 @@
 package refactor
 
 import (
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/mamaar/gorefactor/pkg/types"
@@ -417,4 +421,184 @@ func (t *TestStruct) inlineMe() {
 			"main": pkg,
 		},
 	}
-}
\ No newline at end of file
+}
+
+func TestReplaceIdentToken_Unicode(t *testing.T) {
+	testCases := []struct {
+		src, old, replacement, want string
+	}{
+		{"x := größe + 1", "größe", "size", "x := size + 1"},
+		{"x := größeA + größe", "größe", "size", "x := größeA + size"},
+		{"x := äb + b", "b", "c", "x := äb + c"},
+		{"x := bä + b", "b", "c", "x := bä + c"},
+	}
+	for _, tc := range testCases {
+		if got := replaceIdentToken(tc.src, tc.old, tc.replacement); got != tc.want {
+			t.Errorf("replaceIdentToken(%q, %q, %q) = %q, want %q", tc.src, tc.old, tc.replacement, got, tc.want)
+		}
+	}
+}
+
+var inlineEffectsFiles = map[string]string{
+	"a/effects.go": `package a
+
+func load() int { return 1 }
+
+func Twice() int {
+	n := load()
+	return n + n
+}
+
+func Loop(xs []int) int {
+	total := 0
+	step := load()
+	for range xs {
+		total += step
+	}
+	return total
+}
+
+func Once() int {
+	v := int64(len("abc"))
+	return int(v)
+}
+
+type counter struct{ count int }
+
+func MapWrite(m map[string]int) int {
+	read := m["a"]
+	m["a"] = 5
+	return read
+}
+
+func OtherKey(m map[string]int, k string) int {
+	keyed := m["a"]
+	delete(m, k)
+	return keyed
+}
+
+func FieldWrite(c *counter) int {
+	field := c.count
+	c.count++
+	return field
+}
+
+func CallThenWrite(c *counter) int {
+	called := load()
+	c.count = 2
+	return called
+}
+
+func WriteAfterUse(m map[string]int) int {
+	copied := m["a"]
+	m["b"] = copied
+	return 0
+}
+
+func UnrelatedWrite(m map[string]int, c *counter) int {
+	unrelated := m["a"]
+	c.count = 1
+	return unrelated
+}
+`,
+}
+
+func TestInlineVariable_SideEffects(t *testing.T) {
+	engine, ws, dir := loadTestModuleWith(t, inlineEffectsFiles)
+	file := filepath.Join(dir, "a", "effects.go")
+
+	for _, name := range []string{"n", "step"} {
+		_, err := engine.InlineVariable(ws, types.InlineVariableRequest{VariableName: name, SourceFile: file})
+		if err == nil || !strings.Contains(err.Error(), "call to load") {
+			t.Errorf("inlining %s: got %v, want a refusal naming the call", name, err)
+		}
+	}
+
+	plan, err := engine.InlineVariable(ws, types.InlineVariableRequest{VariableName: "n", SourceFile: file, Force: true})
+	if err != nil {
+		t.Fatalf("InlineVariable with force: %v", err)
+	}
+	var effects []types.Issue
+	for _, issue := range plan.Impact.PotentialIssues {
+		if issue.Type == types.IssueSideEffect {
+			effects = append(effects, issue)
+		}
+	}
+	if len(effects) != 1 || effects[0].Severity != types.Warning || effects[0].Line != 6 {
+		t.Errorf("expected a warning for the repeated call, got %+v", effects)
+	}
+
+	plan, err = engine.InlineVariable(ws, types.InlineVariableRequest{VariableName: "v", SourceFile: file})
+	if err != nil {
+		t.Fatalf("InlineVariable: %v", err)
+	}
+	for _, issue := range plan.Impact.PotentialIssues {
+		if issue.Type == types.IssueSideEffect {
+			t.Errorf("conversions and len have no side effects, got %+v", issue)
+		}
+	}
+}
+
+func TestInlineVariable_SideEffectsResolveFile(t *testing.T) {
+	files := map[string]string{
+		// Same base name as a/effects.go, where n's initializer is a call.
+		"b/effects.go": "package b\n\nfunc Twice() int {\n\tn := 2\n\treturn n + n\n}\n",
+		"a/effects_test.go": `package a
+
+import "testing"
+
+func TestTwice(t *testing.T) {
+	got := load()
+	if got+got != 2 {
+		t.Fail()
+	}
+}
+`,
+	}
+	maps.Copy(files, inlineEffectsFiles)
+	engine, ws, dir := loadTestModuleWith(t, files)
+
+	for range 5 { // Package order must not matter
+		if _, err := engine.InlineVariable(ws, types.InlineVariableRequest{VariableName: "n", SourceFile: filepath.Join(dir, "b", "effects.go")}); err != nil {
+			t.Fatalf("inlining the pure n of b: %v", err)
+		}
+	}
+	_, err := engine.InlineVariable(ws, types.InlineVariableRequest{VariableName: "got", SourceFile: filepath.Join(dir, "a", "effects_test.go")})
+	if err == nil || !strings.Contains(err.Error(), "call to load") {
+		t.Errorf("inlining got in a test file: got %v, want a refusal naming the call", err)
+	}
+}
+
+func TestInlineVariable_ReadMovedPastWrite(t *testing.T) {
+	engine, ws, dir := loadTestModuleWith(t, inlineEffectsFiles)
+	file := filepath.Join(dir, "a", "effects.go")
+
+	for _, tc := range []struct {
+		name, written string
+	}{
+		{"read", `m["a"]`},
+		{"keyed", "m"},
+		{"field", "c.count"},
+		{"called", "c.count"},
+	} {
+		_, err := engine.InlineVariable(ws, types.InlineVariableRequest{VariableName: tc.name, SourceFile: file})
+		if err == nil || !strings.Contains(err.Error(), tc.written+" is written") {
+			t.Errorf("inlining %s: got %v, want a refusal naming the write to %s", tc.name, err, tc.written)
+		}
+		plan, err := engine.InlineVariable(ws, types.InlineVariableRequest{VariableName: tc.name, SourceFile: file, Force: true})
+		if err != nil {
+			t.Fatalf("inlining %s with force: %v", tc.name, err)
+		}
+		if !slices.ContainsFunc(plan.Impact.PotentialIssues, func(i types.Issue) bool {
+			return i.Type == types.IssueSideEffect && i.Severity == types.Warning
+		}) {
+			t.Errorf("inlining %s with force: want a warning, got %+v", tc.name, plan.Impact.PotentialIssues)
+		}
+	}
+
+	for _, name := range []string{"copied", "unrelated"} {
+		if _, err := engine.InlineVariable(ws, types.InlineVariableRequest{VariableName: name, SourceFile: file}); err != nil {
+			t.Errorf("inlining %s: %v", name, err)
+		}
+	}
+}
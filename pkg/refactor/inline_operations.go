@@ -5,10 +5,14 @@ import (
 	"go/ast"
 	"go/parser"
 	"go/token"
+	gotypes "go/types"
 	"io"
 	"log/slog"
+	"maps"
 	"path/filepath"
+	"slices"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/mamaar/gorefactor/pkg/analysis"
 	"github.com/mamaar/gorefactor/pkg/types"
@@ -19,6 +23,7 @@ type InlineMethodOperation struct {
 	MethodName   string
 	SourceStruct string
 	TargetFile   string
+	Backend      ChangeBackend // ChangeBackendAST when empty
 }
 
 func (op *InlineMethodOperation) Type() types.OperationType {
@@ -128,6 +133,11 @@ func (op *InlineMethodOperation) Execute(ws *types.Workspace) (*types.Refactorin
 			Description: fmt.Sprintf("Inline method call %s", op.MethodName),
 		})
 	}
+	if op.Backend != ChangeBackendText {
+		if changes, err = rewritePlanChanges(ws, changes); err != nil {
+			return nil, err
+		}
+	}
 
 	var sourcePackage *types.Package
 	for _, pkg := range ws.Packages {
@@ -404,9 +414,11 @@ func replaceIdentToken(src, oldIdent, newText string) string {
 			break
 		}
 		// Check that the character before and after are not identifier characters
-		before := idx == 0 || !isIdentChar(remainder[idx-1])
+		prev, _ := utf8.DecodeLastRuneInString(remainder[:idx])
+		before := idx == 0 || !isIdentRune(prev)
 		afterIdx := idx + len(oldIdent)
-		after := afterIdx >= len(remainder) || !isIdentChar(remainder[afterIdx])
+		next, _ := utf8.DecodeRuneInString(remainder[afterIdx:])
+		after := afterIdx >= len(remainder) || !isIdentRune(next)
 		if before && after {
 			result.WriteString(remainder[:idx])
 			result.WriteString(newText)
@@ -420,12 +432,19 @@ func replaceIdentToken(src, oldIdent, newText string) string {
 }
 
 
-// InlineVariableOperation implements inlining a variable with its value
+// InlineVariableOperation implements inlining a variable with its value.
+// An initializer with side effects is only inlined into a single use outside
+// loops and function literals, since anything else would evaluate it more or
+// less often than once; Force inlines it anyway. Its effects are reported as
+// issues of the plan either way.
 type InlineVariableOperation struct {
 	VariableName string
 	SourceFile   string
 	StartLine    int
 	EndLine      int
+	Backend      ChangeBackend      // ChangeBackendAST when empty
+	Force        bool               // Inline even when that repeats or drops the initializer's side effects
+	Parser       *analysis.GoParser // Type-checks the package on demand; may be nil
 }
 
 func (op *InlineVariableOperation) Type() types.OperationType {
@@ -467,6 +486,10 @@ func (op *InlineVariableOperation) Execute(ws *types.Workspace) (*types.Refactor
 	if err != nil {
 		return nil, err
 	}
+	issues, err := op.effectIssues(ws, len(references))
+	if err != nil {
+		return nil, err
+	}
 
 	var changes []types.Change
 	
@@ -494,23 +517,15 @@ func (op *InlineVariableOperation) Execute(ws *types.Workspace) (*types.Refactor
 			Description: fmt.Sprintf("Remove variable declaration %s", op.VariableName),
 		})
 	}
+	if op.Backend != ChangeBackendText {
+		if changes, err = rewritePlanChanges(ws, changes); err != nil {
+			return nil, err
+		}
+	}
 
 	var sourcePackage *types.Package
-	for _, pkg := range ws.Packages {
-		if _, exists := pkg.Files[op.SourceFile]; exists {
-			sourcePackage = pkg
-			break
-		}
-		// Also try to match by comparing file paths (for absolute paths from MCP)
-		for _, file := range pkg.Files {
-			if file.Path == op.SourceFile {
-				sourcePackage = pkg
-				break
-			}
-		}
-		if sourcePackage != nil {
-			break
-		}
+	if file := op.sourceFile(ws); file != nil {
+		sourcePackage = file.Package
 	}
 
 	return &types.RefactoringPlan{
@@ -520,40 +535,322 @@ func (op *InlineVariableOperation) Execute(ws *types.Workspace) (*types.Refactor
 		Impact: &types.ImpactAnalysis{
 			AffectedFiles:    []string{op.SourceFile},
 			AffectedPackages: []string{sourcePackage.Path},
+			PotentialIssues:  issues,
 		},
 		Reversible: false, // Inlining is typically not easily reversible
 	}, nil
 }
 
+// sourceFile returns the file SourceFile names, test files included: the
+// file at that path, or else one of that base name, as the name may be
+// relative. Nil when there is none.
+func (op *InlineVariableOperation) sourceFile(ws *types.Workspace) *types.File {
+	paths := slices.Sorted(maps.Keys(ws.Packages))
+	for _, path := range paths {
+		pkg := ws.Packages[path]
+		for _, files := range []map[string]*types.File{pkg.Files, pkg.TestFiles} {
+			if f := files[filepath.Base(op.SourceFile)]; f != nil && f.Path == op.SourceFile {
+				return f
+			}
+		}
+	}
+	for _, path := range paths {
+		pkg := ws.Packages[path]
+		for _, files := range []map[string]*types.File{pkg.Files, pkg.TestFiles} {
+			if f := files[op.SourceFile]; f != nil {
+				return f
+			}
+		}
+	}
+	return nil
+}
+
 func (op *InlineVariableOperation) Description() string {
 	return fmt.Sprintf("Inline variable '%s' from lines %d-%d in %s",
 		op.VariableName, op.StartLine, op.EndLine, op.SourceFile)
 }
 
-type VariableReference struct {
-	Start int
-	End   int
+// effectIssues reports the side effects of the variable's initializer. When
+// inlining would evaluate them more or less often than once, because the
+// variable is used several times, never, or in a loop or function literal
+// it isn't declared in, it fails unless Force is set.
+func (op *InlineVariableOperation) effectIssues(ws *types.Workspace, uses int) ([]types.Issue, error) {
+	file := op.sourceFile(ws)
+	if file == nil || file.AST == nil {
+		return nil, nil
+	}
+	decl, init := variableInitializer(file.AST, op.VariableName)
+	if init == nil {
+		return nil, nil
+	}
+	var info *gotypes.Info
+	if op.Parser != nil && file.Package != nil {
+		op.Parser.EnsureTypeChecked(ws, file.Package)
+	}
+	if file.Package != nil {
+		info = file.Package.TypesInfo
+	}
+	effects := analysis.ExprEffects(init, info)
+	write, read := movedPastWrite(file.AST, decl, init, len(effects) > 0)
+	if len(effects) == 0 && write == nil {
+		return nil, nil
+	}
+
+	var descs []string
+	for _, e := range effects {
+		descs = append(descs, e.Description)
+	}
+	what := strings.Join(descs, ", ")
+	var problem string
+	switch {
+	case len(effects) == 0:
+	case uses == 0:
+		problem = fmt.Sprintf("%s is never used; removing its declaration drops the %s", op.VariableName, what)
+	case uses > 1:
+		problem = fmt.Sprintf("%s is used %d times; inlining repeats the %s at each use", op.VariableName, uses, what)
+	default:
+		if repeated := repeatingContext(file.AST, decl); repeated != "" {
+			problem = fmt.Sprintf("%s is used in a %s; inlining repeats the %s every time it runs", op.VariableName, repeated, what)
+		}
+	}
+	if problem == "" && write != nil {
+		problem = fmt.Sprintf("%s is written on line %d, after %s is declared and before it is used; inlining moves the read of %s after the write",
+			gotypes.ExprString(write), ws.FileSet.Position(write.Pos()).Line, op.VariableName, read)
+	}
+
+	severity := types.Warning
+	if problem == "" {
+		severity = types.Info
+		problem = fmt.Sprintf("inlining %s moves the %s to its use, after the statements in between", op.VariableName, what)
+	}
+	var issues []types.Issue
+	for _, e := range effects {
+		issues = append(issues, types.Issue{
+			Type:        types.IssueSideEffect,
+			Description: fmt.Sprintf("%s: %s", e.Description, problem),
+			File:        file.Path,
+			Line:        ws.FileSet.Position(e.Pos).Line,
+			Severity:    severity,
+		})
+	}
+	if len(effects) == 0 {
+		issues = append(issues, types.Issue{
+			Type:        types.IssueSideEffect,
+			Description: problem,
+			File:        file.Path,
+			Line:        ws.FileSet.Position(write.Pos()).Line,
+			Severity:    severity,
+		})
+	}
+	if severity == types.Warning && !op.Force {
+		return nil, &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: problem + "; set force to inline anyway",
+			File:    file.Path,
+			Line:    ws.FileSet.Position(decl.Pos()).Line,
+		}
+	}
+	return issues, nil
 }
 
-func (op *InlineVariableOperation) findVariableValue(ws *types.Workspace) (string, error) {
-	// Find the file and parse it
-	var sourceFile *types.File
-	for _, pkg := range ws.Packages {
-		if file, exists := pkg.Files[op.SourceFile]; exists {
-			sourceFile = file
-			break
+// movedPastWrite returns a write, between the declaration of decl and its
+// last use, to something its initializer init reads, and what it reads; nil
+// when there is none. Inlining would move the read after the write: in
+// y := m["a"]; m["a"] = 5; return y, the inlined m["a"] is 5. A write
+// conflicts with a read of the same variable, field, element or pointee,
+// of one containing it or contained in it, and with a read of another
+// element of the same map or slice. When init calls functions, which may
+// read any memory, every write other than to a variable conflicts.
+func movedPastWrite(file *ast.File, decl *ast.Ident, init ast.Expr, calls bool) (ast.Expr, string) {
+	// The outermost variables, fields, elements and pointees init reads,
+	// such as m["a"] but not m. Index expressions are read too.
+	var reads []string
+	ast.Inspect(init, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.Ident:
+			reads = append(reads, n.Name)
+		case *ast.SelectorExpr, *ast.StarExpr:
+			reads = append(reads, gotypes.ExprString(n.(ast.Expr)))
+			return false
+		case *ast.IndexExpr:
+			reads = append(reads, gotypes.ExprString(n))
+			ast.Inspect(n.Index, func(m ast.Node) bool {
+				if id, ok := m.(*ast.Ident); ok {
+					reads = append(reads, id.Name)
+				}
+				return true
+			})
+			return false
 		}
-		// Also try to match by comparing file paths (for absolute paths from MCP)
-		for _, file := range pkg.Files {
-			if file.Path == op.SourceFile {
-				sourceFile = file
-				break
+		return true
+	})
+
+	// The uses and writes are those in the declaration declaring decl, such
+	// as its function.
+	var scope ast.Node = file
+	for _, d := range file.Decls {
+		if d.Pos() <= decl.Pos() && decl.End() <= d.End() {
+			scope = d
+		}
+	}
+	var lastUse token.Pos
+	ast.Inspect(scope, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && id != decl && id.Name == decl.Name && id.Pos() > init.End() {
+			lastUse = max(lastUse, id.Pos())
+		}
+		return true
+	})
+	if !lastUse.IsValid() {
+		return nil, ""
+	}
+
+	var write ast.Expr
+	var read string
+	check := func(target ast.Expr) {
+		if write != nil {
+			return
+		}
+		target = ast.Unparen(target)
+		w := gotypes.ExprString(target)
+		var container string // An element of it is written
+		if ix, ok := target.(*ast.IndexExpr); ok {
+			container = gotypes.ExprString(ix.X) + "["
+		}
+		for _, r := range reads {
+			if r == w || within(r, w) || within(w, r) || (container != "" && strings.HasPrefix(r, container)) {
+				write, read = target, r
+				return
 			}
 		}
-		if sourceFile != nil {
-			break
+		if _, ok := target.(*ast.Ident); calls && !ok && w != "_" {
+			write, read = target, "memory the call reads"
 		}
 	}
+	ast.Inspect(scope, func(n ast.Node) bool {
+		if n == nil || n.Pos() >= lastUse {
+			return false
+		}
+		// A statement holding the use, as m["a"] = y does, reads y first.
+		if n.Pos() < init.End() || n.End() > lastUse {
+			return true
+		}
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			if n.Tok != token.DEFINE {
+				for _, lhs := range n.Lhs {
+					check(lhs)
+				}
+			}
+		case *ast.IncDecStmt:
+			check(n.X)
+		case *ast.RangeStmt:
+			if n.Tok == token.ASSIGN {
+				for _, e := range []ast.Expr{n.Key, n.Value} {
+					if e != nil {
+						check(e)
+					}
+				}
+			}
+		case *ast.CallExpr:
+			if fun, ok := ast.Unparen(n.Fun).(*ast.Ident); ok && (fun.Name == "delete" || fun.Name == "clear") && len(n.Args) > 0 {
+				check(n.Args[0])
+			}
+		}
+		return true
+	})
+	return write, read
+}
+
+// within reports whether the expression inner names a field or element of
+// outer, as x.f and x[i] do of x.
+func within(inner, outer string) bool {
+	return strings.HasPrefix(inner, outer+".") || strings.HasPrefix(inner, outer+"[")
+}
+
+// variableInitializer returns the identifier declaring name in file and the
+// expression it is initialized with, the first declaration as the rest of
+// the operation finds it.
+func variableInitializer(file *ast.File, name string) (*ast.Ident, ast.Expr) {
+	var decl *ast.Ident
+	var init ast.Expr
+	ast.Inspect(file, func(n ast.Node) bool {
+		if decl != nil {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.ValueSpec:
+			for i, id := range n.Names {
+				if id.Name == name && i < len(n.Values) {
+					decl, init = id, n.Values[i]
+				}
+			}
+		case *ast.AssignStmt:
+			if n.Tok != token.DEFINE || len(n.Lhs) != len(n.Rhs) {
+				return true
+			}
+			for i, lhs := range n.Lhs {
+				if id, ok := lhs.(*ast.Ident); ok && id.Name == name {
+					decl, init = id, n.Rhs[i]
+				}
+			}
+		}
+		return true
+	})
+	return decl, init
+}
+
+// repeatingContext returns "loop" or "function literal" when a use of decl
+// runs repeatedly, or at another time, than its declaration: inside a loop
+// body or function literal that doesn't contain the declaration.
+func repeatingContext(file *ast.File, decl *ast.Ident) string {
+	var context string
+	var stack []ast.Node
+	ast.Inspect(file, func(n ast.Node) bool {
+		if n == nil {
+			stack = stack[:len(stack)-1]
+			return false
+		}
+		stack = append(stack, n)
+		id, ok := n.(*ast.Ident)
+		if !ok || id.Name != decl.Name || id == decl || context != "" {
+			return true
+		}
+		for i := len(stack) - 2; i >= 0; i-- {
+			outer := stack[i]
+			if outer.Pos() <= decl.Pos() && decl.End() <= outer.End() {
+				break
+			}
+			switch outer := outer.(type) {
+			case *ast.ForStmt:
+				if outer.Init == nil || id.Pos() >= outer.Init.End() {
+					context = "loop"
+				}
+			case *ast.RangeStmt:
+				if id.Pos() >= outer.Body.Pos() {
+					context = "loop"
+				}
+			case *ast.FuncLit:
+				context = "function literal"
+			}
+			if context != "" {
+				break
+			}
+		}
+		return true
+	})
+	return context
+}
+
+type VariableReference struct {
+	Start int
+	End   int
+}
+
+func (op *InlineVariableOperation) findVariableValue(ws *types.Workspace) (string, error) {
+	// Find the file and parse it
+	sourceFile := op.sourceFile(ws)
 
 	if sourceFile == nil {
 		return "", &types.RefactorError{
@@ -625,22 +922,7 @@ func (op *InlineVariableOperation) findVariableValue(ws *types.Workspace) (strin
 
 func (op *InlineVariableOperation) findVariableReferences(ws *types.Workspace) ([]VariableReference, error) {
 	// Find the file and parse it
-	var sourceFile *types.File
-	for _, pkg := range ws.Packages {
-		if file, exists := pkg.Files[op.SourceFile]; exists {
-			sourceFile = file
-			break
-		}
-		for _, file := range pkg.Files {
-			if file.Path == op.SourceFile {
-				sourceFile = file
-				break
-			}
-		}
-		if sourceFile != nil {
-			break
-		}
-	}
+	sourceFile := op.sourceFile(ws)
 
 	if sourceFile == nil {
 		return nil, &types.RefactorError{
@@ -714,22 +996,7 @@ func (op *InlineVariableOperation) findVariableReferences(ws *types.Workspace) (
 }
 
 func (op *InlineVariableOperation) findVariableDeclaration(ws *types.Workspace) (int, int, error) {
-	var sourceFile *types.File
-	for _, pkg := range ws.Packages {
-		if file, exists := pkg.Files[op.SourceFile]; exists {
-			sourceFile = file
-			break
-		}
-		for _, file := range pkg.Files {
-			if file.Path == op.SourceFile {
-				sourceFile = file
-				break
-			}
-		}
-		if sourceFile != nil {
-			break
-		}
-	}
+	sourceFile := op.sourceFile(ws)
 
 	if sourceFile == nil {
 		return 0, 0, &types.RefactorError{
@@ -820,6 +1087,7 @@ type InlineFunctionOperation struct {
 	FunctionName string
 	SourceFile   string
 	TargetFiles  []string
+	Backend      ChangeBackend // ChangeBackendAST when empty
 }
 
 func (op *InlineFunctionOperation) Type() types.OperationType {
@@ -883,6 +1151,11 @@ func (op *InlineFunctionOperation) Execute(ws *types.Workspace) (*types.Refactor
 			affectedFiles = append(affectedFiles, targetFile)
 		}
 	}
+	if op.Backend != ChangeBackendText {
+		if changes, err = rewritePlanChanges(ws, changes); err != nil {
+			return nil, err
+		}
+	}
 
 	var affectedPackages []string
 	for _, file := range affectedFiles {
@@ -1096,6 +1369,7 @@ type InlineConstantOperation struct {
 	ConstantName string
 	SourceFile   string
 	Scope        types.RenameScope
+	Backend      ChangeBackend // ChangeBackendAST when empty
 }
 
 func (op *InlineConstantOperation) Type() types.OperationType {
@@ -1152,6 +1426,11 @@ func (op *InlineConstantOperation) Execute(ws *types.Workspace) (*types.Refactor
 			fileSet[ref.File] = true
 		}
 	}
+	if op.Backend != ChangeBackendText {
+		if changes, err = rewritePlanChanges(ws, changes); err != nil {
+			return nil, err
+		}
+	}
 
 	var affectedPackages []string
 	for _, file := range affectedFiles {
@@ -1277,7 +1556,7 @@ func (op *InlineConstantOperation) findConstantReferences(ws *types.Workspace) (
 
 	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
 	resolver := analysis.NewSymbolResolver(ws, logger)
-	idx := resolver.BuildReferenceIndex()
+	idx := resolver.BuildReferenceIndexFor([]*types.Symbol{sym}, nil)
 	refs, err := resolver.FindReferencesIndexed(sym, idx)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,732 @@
+package refactor
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	gotypes "go/types"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/mamaar/gorefactor/pkg/analysis"
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// IntroduceTypeOperation declares a named type for a primitive that recurs
+// across the workspace, e.g. type UserID string, and retypes the
+// declarations the request chooses to it: parameters and results, struct
+// fields, and package-level variables and constants. Values written to them
+// that aren't constants (call arguments, returned and assigned values,
+// composite literal elements and initializers) are converted to the new
+// type; reads that still need the underlying type are left to the build
+// check. Declarations of the underlying type named like the new type that
+// weren't chosen are listed in the plan's impact as candidates.
+type IntroduceTypeOperation struct {
+	Request types.IntroduceTypeRequest
+	Parser  *analysis.GoParser // Type-checks test files; may be nil to leave them alone
+
+	// Resolved by Validate
+	pkg        *types.Package // Declares the type
+	underlying string         // Request.Underlying as go/types prints it
+	declared   bool           // The package already declares the type
+	targets    []*typeDecl
+}
+
+// typeDecl is a declaration introduce_type can retype: a parameter or
+// result, a struct field, or a package-level variable or constant.
+type typeDecl struct {
+	path  string // As targets name it: Func.param, Type.Method.param, Type.Field or Var
+	pkg   *types.Package
+	file  *types.File
+	name  *ast.Ident
+	field *ast.Field     // Declares the parameter, result or struct field; nil for a value spec
+	spec  *ast.ValueSpec // Declares the variable or constant; nil for a field
+	fn    *ast.FuncDecl  // Declares the parameter or result
+}
+
+// typeExpr returns the declared type, the element type for a variadic
+// parameter, or nil for a value spec without one.
+func (d *typeDecl) typeExpr() ast.Expr {
+	if d.field == nil {
+		return d.spec.Type
+	}
+	if ell, ok := d.field.Type.(*ast.Ellipsis); ok {
+		return ell.Elt
+	}
+	return d.field.Type
+}
+
+// eachTypeDecl calls yield with every declaration of file that
+// introduce_type can retype.
+func eachTypeDecl(pkg *types.Package, file *types.File, yield func(*typeDecl)) {
+	for _, decl := range file.AST.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			prefix := decl.Name.Name
+			if recv := receiverTypeName(decl); recv != "" {
+				prefix = recv + "." + prefix
+			}
+			for _, list := range []*ast.FieldList{decl.Type.Params, decl.Type.Results} {
+				if list == nil {
+					continue
+				}
+				for _, field := range list.List {
+					for _, name := range field.Names {
+						if name.Name != "_" {
+							yield(&typeDecl{path: prefix + "." + name.Name, pkg: pkg, file: file, name: name, field: field, fn: decl})
+						}
+					}
+				}
+			}
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					st, ok := spec.Type.(*ast.StructType)
+					if !ok {
+						continue
+					}
+					for _, field := range st.Fields.List {
+						for _, name := range field.Names {
+							yield(&typeDecl{path: spec.Name.Name + "." + name.Name, pkg: pkg, file: file, name: name, field: field})
+						}
+					}
+				case *ast.ValueSpec:
+					for _, name := range spec.Names {
+						if name.Name != "_" {
+							yield(&typeDecl{path: name.Name, pkg: pkg, file: file, name: name, spec: spec})
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func (op *IntroduceTypeOperation) Type() types.OperationType {
+	return types.IntroduceTypeOperation
+}
+
+func (op *IntroduceTypeOperation) Description() string {
+	return fmt.Sprintf("Introduce type %s %s", op.Request.TypeName, op.Request.Underlying)
+}
+
+func (op *IntroduceTypeOperation) Validate(ws *types.Workspace) error {
+	req := op.Request
+	if !isValidGoIdentifier(req.TypeName) || token.IsKeyword(req.TypeName) {
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("%q is not a valid type name", req.TypeName),
+		}
+	}
+	expr, err := parser.ParseExpr(req.Underlying)
+	if req.Underlying == "" || err != nil {
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("%q is not a type", req.Underlying),
+		}
+	}
+	op.underlying = gotypes.ExprString(expr)
+	pkg, ok := ws.Packages[req.Package]
+	if !ok {
+		return &types.RefactorError{
+			Type:    types.SymbolNotFound,
+			Message: fmt.Sprintf("package not found: %s", req.Package),
+		}
+	}
+	op.pkg = pkg
+	if err := op.checkName(); err != nil {
+		return err
+	}
+
+	retyped := make(map[*ast.ValueSpec]int)
+	for _, target := range req.Targets {
+		d, err := findTypeDecl(ws, target)
+		if err != nil {
+			return err
+		}
+		if slices.ContainsFunc(op.targets, func(t *typeDecl) bool { return t.name == d.name }) {
+			continue
+		}
+		if te := d.typeExpr(); te != nil && gotypes.ExprString(te) != op.underlying {
+			return &types.RefactorError{
+				Type:    types.InvalidOperation,
+				Message: fmt.Sprintf("%s is declared as %s, not %s", target, gotypes.ExprString(te), op.underlying),
+				File:    d.file.Path,
+			}
+		}
+		if d.spec != nil {
+			if d.spec.Type == nil && len(d.spec.Values) == 0 {
+				return &types.RefactorError{
+					Type:    types.InvalidOperation,
+					Message: fmt.Sprintf("%s repeats the constant declaration before it; give it a type or value of its own first", target),
+					File:    d.file.Path,
+				}
+			}
+			retyped[d.spec]++
+		}
+		op.targets = append(op.targets, d)
+	}
+	for _, d := range op.targets {
+		if d.spec != nil && retyped[d.spec] < len(d.spec.Names) {
+			return &types.RefactorError{
+				Type:    types.InvalidOperation,
+				Message: fmt.Sprintf("%s shares its declaration with other names; declare it on its own or retype them all", d.path),
+				File:    d.file.Path,
+			}
+		}
+	}
+	return nil
+}
+
+// checkName makes sure the package doesn't declare the type's name, unless
+// it is the type itself, which is then reused.
+func (op *IntroduceTypeOperation) checkName() error {
+	name := op.Request.TypeName
+	for _, fileName := range slices.Sorted(maps.Keys(op.pkg.Files)) {
+		file := op.pkg.Files[fileName]
+		if file.AST == nil {
+			continue
+		}
+		for _, decl := range file.AST.Decls {
+			var clash bool
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				clash = decl.Recv == nil && decl.Name.Name == name
+			case *ast.GenDecl:
+				for _, spec := range decl.Specs {
+					switch spec := spec.(type) {
+					case *ast.TypeSpec:
+						if spec.Name.Name != name {
+							continue
+						}
+						if !spec.Assign.IsValid() && spec.TypeParams == nil && gotypes.ExprString(spec.Type) == op.underlying {
+							op.declared = true
+							continue
+						}
+						clash = true
+					case *ast.ValueSpec:
+						clash = clash || slices.ContainsFunc(spec.Names, func(id *ast.Ident) bool { return id.Name == name })
+					}
+				}
+			}
+			if clash {
+				return &types.RefactorError{
+					Type:    types.NameConflict,
+					Message: fmt.Sprintf("package %s already declares %s", op.pkg.ImportPath, name),
+					File:    file.Path,
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// findTypeDecl resolves a target, Func.param, Type.Method.param, Type.Field
+// or Var, optionally prefixed with "package:".
+func findTypeDecl(ws *types.Workspace, target string) (*typeDecl, error) {
+	pkgPath, path, qualified := strings.Cut(target, ":")
+	if !qualified {
+		pkgPath, path = "", target
+	}
+	resolved := ""
+	if pkgPath != "" {
+		resolved = types.ResolvePackagePath(ws, pkgPath)
+	}
+
+	var matches []*typeDecl
+	for _, p := range slices.Sorted(maps.Keys(ws.Packages)) {
+		pkg := ws.Packages[p]
+		if pkgPath != "" && pkg.Path != resolved && pkg.ImportPath != pkgPath {
+			continue
+		}
+		for _, fileName := range slices.Sorted(maps.Keys(pkg.Files)) {
+			if file := pkg.Files[fileName]; file.AST != nil {
+				eachTypeDecl(pkg, file, func(d *typeDecl) {
+					if d.path == path {
+						matches = append(matches, d)
+					}
+				})
+			}
+		}
+	}
+
+	switch {
+	case len(matches) == 0:
+		return nil, &types.RefactorError{
+			Type:    types.SymbolNotFound,
+			Message: fmt.Sprintf("declaration %s not found; targets are Func.param, Type.Method.param, Type.Field or Var", target),
+		}
+	case len(matches) > 1:
+		return nil, &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("%s is declared in %d packages; prefix it with the package, as in %s:%s", path, len(matches), matches[0].pkg.ImportPath, path),
+		}
+	}
+	return matches[0], nil
+}
+
+func (op *IntroduceTypeOperation) Execute(ws *types.Workspace) (*types.RefactoringPlan, error) {
+	var changes []types.Change
+	if !op.declared {
+		changes = append(changes, op.declaration(ws))
+	}
+
+	retyped := make(map[token.Pos]bool)
+	for _, d := range op.targets {
+		retyped[d.name.Pos()] = true
+	}
+	done := make(map[ast.Node]bool)
+	for _, d := range op.targets {
+		var owner ast.Node = d.spec
+		if d.field != nil {
+			owner = d.field
+		}
+		if !done[owner] {
+			done[owner] = true
+			changes = append(changes, retypeDecl(ws, d, retyped, op.typeName(d.file), fmt.Sprintf("Replace type of %s with %s", d.path, op.Request.TypeName)))
+		}
+	}
+	conversions, issues := op.conversions(ws, retyped)
+	changes = append(changes, conversions...)
+	issues = append(issues, op.candidates(ws, retyped)...)
+
+	var affected, packages []string
+	for _, change := range changes {
+		if !slices.Contains(affected, change.File) {
+			affected = append(affected, change.File)
+		}
+		if dir := filepath.Dir(change.File); !slices.Contains(packages, dir) {
+			packages = append(packages, dir)
+		}
+	}
+
+	return &types.RefactoringPlan{
+		Changes:       changes,
+		AffectedFiles: affected,
+		Impact: &types.ImpactAnalysis{
+			AffectedPackages: packages,
+			AffectedFiles:    affected,
+			PotentialIssues:  issues,
+		},
+		Reversible: true,
+	}, nil
+}
+
+// declaration returns the change declaring the type before the first
+// declaration of the file of the first target in its package, or of the
+// package's first file.
+func (op *IntroduceTypeOperation) declaration(ws *types.Workspace) types.Change {
+	var file *types.File
+	for _, d := range op.targets {
+		if d.pkg == op.pkg {
+			file = d.file
+			break
+		}
+	}
+	if file == nil {
+		for _, fileName := range slices.Sorted(maps.Keys(op.pkg.Files)) {
+			if f := op.pkg.Files[fileName]; f.AST != nil {
+				file = f
+				break
+			}
+		}
+	}
+
+	name := op.Request.TypeName
+	text := fmt.Sprintf("// %s is a distinct %s type.\ntype %s %s\n", name, op.underlying, name, op.underlying)
+	change := types.Change{
+		File:        file.Path,
+		Start:       len(file.OriginalContent),
+		End:         len(file.OriginalContent),
+		NewText:     "\n" + text,
+		Description: fmt.Sprintf("Declare type %s", name),
+	}
+	for _, decl := range file.AST.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			continue
+		}
+		pos := decl.Pos()
+		if doc := declDoc(decl); doc != nil {
+			pos = doc.Pos()
+		}
+		change.Start = ws.FileSet.Position(pos).Offset
+		change.End = change.Start
+		change.NewText = text + "\n"
+		break
+	}
+	return change
+}
+
+// declDoc returns the doc comment of a top-level declaration.
+func declDoc(decl ast.Decl) *ast.CommentGroup {
+	switch decl := decl.(type) {
+	case *ast.FuncDecl:
+		return decl.Doc
+	case *ast.GenDecl:
+		return decl.Doc
+	}
+	return nil
+}
+
+// retypeDecl returns the change giving type name to the parameters, results
+// or fields a field declares, splitting off the names that keep their type,
+// or to the variables or constants a value spec declares.
+func retypeDecl(ws *types.Workspace, d *typeDecl, retyped map[token.Pos]bool, name, desc string) types.Change {
+	if d.field == nil {
+		if d.spec.Type != nil {
+			return replaceText(ws, d.file, d.spec.Type.Pos(), d.spec.Type.End(), name, desc)
+		}
+		last := d.spec.Names[len(d.spec.Names)-1]
+		return replaceText(ws, d.file, last.End(), last.End(), " "+name, desc)
+	}
+
+	field := d.field
+	if !slices.ContainsFunc(field.Names, func(id *ast.Ident) bool { return !retyped[id.Pos()] }) {
+		te := d.typeExpr()
+		return replaceText(ws, d.file, te.Pos(), te.End(), name, desc)
+	}
+
+	// Consecutive names keep sharing their type.
+	old := nodeText(ws, d.file, field.Type.Pos(), field.Type.End())
+	var parts []string
+	for i := 0; i < len(field.Names); {
+		j := i + 1
+		for j < len(field.Names) && retyped[field.Names[j].Pos()] == retyped[field.Names[i].Pos()] {
+			j++
+		}
+		var names []string
+		for _, id := range field.Names[i:j] {
+			names = append(names, id.Name)
+		}
+		typ := old
+		if retyped[field.Names[i].Pos()] {
+			typ = name
+		}
+		part := strings.Join(names, ", ") + " " + typ
+		if field.Tag != nil {
+			part += " " + field.Tag.Value
+		}
+		parts = append(parts, part)
+		i = j
+	}
+	sep := ", "
+	if d.fn == nil {
+		start := ws.FileSet.Position(field.Pos())
+		line := string(d.file.OriginalContent[start.Offset-start.Column+1 : start.Offset])
+		sep = "\n" + line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+	}
+	return replaceText(ws, d.file, field.Pos(), field.End(), strings.Join(parts, sep), desc)
+}
+
+// typeName returns how file refers to the new type.
+func (op *IntroduceTypeOperation) typeName(file *types.File) string {
+	if filepath.Dir(file.Path) == op.pkg.Path && file.AST.Name.Name == op.pkg.Name {
+		return op.Request.TypeName
+	}
+	qualifier := op.pkg.Name
+	if name, ok := importedName(file.AST, op.pkg.ImportPath); ok {
+		qualifier = name
+	}
+	if qualifier == "" {
+		return op.Request.TypeName
+	}
+	return qualifier + "." + op.Request.TypeName
+}
+
+// conversions converts the values written to the retyped declarations in
+// every package, test files included.
+func (op *IntroduceTypeOperation) conversions(ws *types.Workspace, retyped map[token.Pos]bool) ([]types.Change, []types.Issue) {
+	// Indices of the retyped parameters and results, by function name
+	params := make(map[token.Pos]map[int]bool)
+	results := make(map[token.Pos]map[int]bool)
+	var names []string
+	for _, d := range op.targets {
+		names = append(names, d.name.Name)
+		if d.fn == nil {
+			continue
+		}
+		names = append(names, d.fn.Name.Name)
+		indices, list := params, d.fn.Type.Params
+		if d.fn.Type.Results != nil && slices.Contains(d.fn.Type.Results.List, d.field) {
+			indices, list = results, d.fn.Type.Results
+		}
+		if indices[d.fn.Name.Pos()] == nil {
+			indices[d.fn.Name.Pos()] = make(map[int]bool)
+		}
+		i := 0
+		for _, field := range list.List {
+			for _, id := range field.Names {
+				if id == d.name {
+					indices[d.fn.Name.Pos()][i] = true
+				}
+				i++
+			}
+		}
+	}
+
+	var changes []types.Change
+	var issues []types.Issue
+	for _, pkgPath := range slices.Sorted(maps.Keys(ws.Packages)) {
+		pkg := ws.Packages[pkgPath]
+		var testInfo *gotypes.Info
+		for _, tests := range []bool{false, true} {
+			files := pkg.Files
+			if tests {
+				files = pkg.TestFiles
+			}
+			for _, fileName := range slices.Sorted(maps.Keys(files)) {
+				file := files[fileName]
+				if file.AST == nil || !mentionsName(file.AST, names...) {
+					continue
+				}
+				info := pkg.TypesInfo
+				if tests {
+					if op.Parser == nil {
+						continue
+					}
+					if testInfo == nil {
+						testInfo = op.Parser.TypeCheckTests(ws, pkg)
+					}
+					info = testInfo
+				}
+				if info == nil {
+					issues = append(issues, op.issue(ws, file, file.AST.Package, types.Warning,
+						fmt.Sprintf("package %s could not be type-checked; values written to the retyped declarations in this file were not converted", pkg.ImportPath)))
+					continue
+				}
+				c := &typeConverter{op: op, ws: ws, info: info, file: file, name: op.typeName(file), retyped: retyped, results: results}
+				c.convertFile(params)
+				changes = append(changes, c.changes...)
+				issues = append(issues, c.issues...)
+			}
+		}
+	}
+	return changes, issues
+}
+
+// typeConverter converts the values written to retyped declarations in one
+// file.
+type typeConverter struct {
+	op      *IntroduceTypeOperation
+	ws      *types.Workspace
+	info    *gotypes.Info
+	file    *types.File
+	name    string // How the file refers to the new type
+	retyped map[token.Pos]bool
+	results map[token.Pos]map[int]bool
+	changes []types.Change
+	issues  []types.Issue
+}
+
+func (c *typeConverter) convertFile(params map[token.Pos]map[int]bool) {
+	retyped, results, writes := c.retyped, c.results, c.isRetyped
+
+	ast.Inspect(c.file.AST, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.CallExpr:
+			fn := calledFunc(c.info, n)
+			if fn == nil || params[fn.Origin().Pos()] == nil {
+				return true
+			}
+			indices := params[fn.Origin().Pos()]
+			sig := fn.Type().(*gotypes.Signature)
+			last := sig.Params().Len() - 1
+			if tuple, ok := c.info.Types[n.Args[0]].Type.(*gotypes.Tuple); len(n.Args) == 1 && ok && tuple.Len() > 1 {
+				c.issue(n.Pos(), fmt.Sprintf("the results of a call are passed to %s; convert them by hand", fn.Name()))
+				return true
+			}
+			for i, arg := range n.Args {
+				p := i
+				if sig.Variadic() && p > last {
+					p = last
+				}
+				if !indices[p] {
+					continue
+				}
+				if sig.Variadic() && p == last && n.Ellipsis.IsValid() {
+					c.issue(arg.Pos(), fmt.Sprintf("a slice is spread into %s; convert its elements by hand", fn.Name()))
+					continue
+				}
+				c.convert(arg)
+			}
+
+		case *ast.AssignStmt:
+			if n.Tok == token.DEFINE {
+				return true
+			}
+			for i, lhs := range n.Lhs {
+				if !writes(lhs) {
+					continue
+				}
+				if len(n.Rhs) != len(n.Lhs) {
+					c.issue(n.Pos(), "a multi-value assignment sets a retyped declaration; convert the value by hand")
+					break
+				}
+				c.convert(n.Rhs[i])
+			}
+
+		case *ast.ValueSpec:
+			for i, name := range n.Names {
+				if !retyped[name.Pos()] || len(n.Values) == 0 {
+					continue
+				}
+				if len(n.Values) != len(n.Names) {
+					c.issue(n.Pos(), "a multi-value initializer sets a retyped declaration; convert the value by hand")
+					break
+				}
+				c.convert(n.Values[i])
+			}
+
+		case *ast.CompositeLit:
+			t := c.info.Types[n].Type
+			if t == nil {
+				return true
+			}
+			st, ok := t.Underlying().(*gotypes.Struct)
+			if !ok {
+				return true
+			}
+			for i, elt := range n.Elts {
+				if kv, ok := elt.(*ast.KeyValueExpr); ok {
+					if key, ok := kv.Key.(*ast.Ident); ok && writes(key) {
+						c.convert(kv.Value)
+					}
+				} else if i < st.NumFields() && retyped[st.Field(i).Pos()] {
+					c.convert(elt)
+				}
+			}
+
+		case *ast.FuncDecl:
+			indices := results[n.Name.Pos()]
+			if indices == nil || n.Body == nil {
+				return true
+			}
+			count := n.Type.Results.NumFields()
+			ast.Inspect(n.Body, func(m ast.Node) bool {
+				switch m := m.(type) {
+				case *ast.FuncLit:
+					return false
+				case *ast.ReturnStmt:
+					if len(m.Results) == count {
+						for i := range indices {
+							c.convert(m.Results[i])
+						}
+					} else if len(m.Results) > 0 {
+						c.issue(m.Pos(), fmt.Sprintf("%s returns the results of a call; convert them by hand", n.Name.Name))
+					}
+				}
+				return true
+			})
+		}
+		return true
+	})
+}
+
+// isRetyped reports whether expr refers to a retyped declaration.
+func (c *typeConverter) isRetyped(expr ast.Expr) bool {
+	var id *ast.Ident
+	switch expr := ast.Unparen(expr).(type) {
+	case *ast.Ident:
+		id = expr
+	case *ast.SelectorExpr:
+		id = expr.Sel
+	}
+	obj := c.info.Uses[id]
+	return obj != nil && c.retyped[obj.Pos()]
+}
+
+// convert wraps expr in a conversion to the new type unless it is a
+// constant, nil, or of the type already, such as a retyped declaration or a
+// call returning one.
+func (c *typeConverter) convert(expr ast.Expr) {
+	tv, ok := c.info.Types[expr]
+	if !ok || tv.Value != nil || tv.IsNil() || c.isRetyped(expr) {
+		return
+	}
+	if call, ok := ast.Unparen(expr).(*ast.CallExpr); ok {
+		if fn := calledFunc(c.info, call); fn != nil && c.results[fn.Origin().Pos()][0] {
+			return
+		}
+	}
+	if named, ok := tv.Type.(*gotypes.Named); ok && named.Obj().Name() == c.op.Request.TypeName &&
+		named.Obj().Pkg() != nil && named.Obj().Pkg().Path() == c.op.pkg.ImportPath {
+		return
+	}
+	desc := fmt.Sprintf("Wrap value in conversion to %s", c.op.Request.TypeName)
+	c.changes = append(c.changes,
+		replaceText(c.ws, c.file, expr.Pos(), expr.Pos(), c.name+"(", desc),
+		replaceText(c.ws, c.file, expr.End(), expr.End(), ")", desc))
+}
+
+func (c *typeConverter) issue(pos token.Pos, msg string) {
+	c.issues = append(c.issues, c.op.issue(c.ws, c.file, pos, types.Warning, msg))
+}
+
+// calledFunc returns the function or method call calls, if it is a static
+// call.
+func calledFunc(info *gotypes.Info, call *ast.CallExpr) *gotypes.Func {
+	var id *ast.Ident
+	switch fun := ast.Unparen(call.Fun).(type) {
+	case *ast.Ident:
+		id = fun
+	case *ast.SelectorExpr:
+		id = fun.Sel
+	case *ast.IndexExpr:
+		id, _ = fun.X.(*ast.Ident)
+	}
+	fn, _ := info.Uses[id].(*gotypes.Func)
+	return fn
+}
+
+// candidates lists the declarations of the underlying type named like the
+// new type that aren't retyped.
+func (op *IntroduceTypeOperation) candidates(ws *types.Workspace, retyped map[token.Pos]bool) []types.Issue {
+	var issues []types.Issue
+	lower := strings.ToLower(op.Request.TypeName)
+	for _, pkgPath := range slices.Sorted(maps.Keys(ws.Packages)) {
+		pkg := ws.Packages[pkgPath]
+		for _, fileName := range slices.Sorted(maps.Keys(pkg.Files)) {
+			file := pkg.Files[fileName]
+			if file.AST == nil {
+				continue
+			}
+			eachTypeDecl(pkg, file, func(d *typeDecl) {
+				te := d.typeExpr()
+				if retyped[d.name.Pos()] || te == nil || gotypes.ExprString(te) != op.underlying ||
+					!strings.Contains(strings.ToLower(d.name.Name), lower) {
+					return
+				}
+				issues = append(issues, op.issue(ws, file, d.name.Pos(), types.Info,
+					fmt.Sprintf("%s is also a %s; add target %s:%s to retype it", d.path, op.underlying, pkg.ImportPath, d.path)))
+			})
+		}
+	}
+	return issues
+}
+
+func (op *IntroduceTypeOperation) issue(ws *types.Workspace, file *types.File, pos token.Pos, severity types.IssueSeverity, msg string) types.Issue {
+	return types.Issue{
+		Type:        types.IssueTypeMismatch,
+		Description: fmt.Sprintf("%s: %s", op.Request.TypeName, msg),
+		File:        file.Path,
+		Line:        ws.FileSet.Position(pos).Line,
+		Severity:    severity,
+	}
+}
+
+// replaceText returns the change replacing the source between start and
+// end of file with text.
+func replaceText(ws *types.Workspace, file *types.File, start, end token.Pos, text, desc string) types.Change {
+	return types.Change{
+		File:        file.Path,
+		Start:       ws.FileSet.Position(start).Offset,
+		End:         ws.FileSet.Position(end).Offset,
+		OldText:     nodeText(ws, file, start, end),
+		NewText:     text,
+		Description: desc,
+	}
+}
@@ -0,0 +1,135 @@
+package refactor
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+var introduceTypeFiles = map[string]string{
+	"a/a.go": `package a
+
+// User is a user.
+type User struct {
+	ID, Name string
+}
+
+// Find looks a user up.
+func Find(id string, users []User) (found string) {
+	for _, u := range users {
+		if string(u.ID) == string(id) {
+			return u.ID
+		}
+	}
+	return ""
+}
+
+var ownerUserID string
+`,
+	"b/b.go": `package b
+
+import (
+	"strings"
+
+	"example.com/p/a"
+)
+
+func Lookup(raw string) string {
+	key := strings.TrimSpace(raw)
+	u := a.User{ID: key, Name: raw}
+	u.ID = key
+	return string(a.Find(key, []a.User{u, {key, "x"}}))
+}
+`,
+}
+
+func TestIntroduceType(t *testing.T) {
+	engine, ws, dir := loadTestModuleWith(t, introduceTypeFiles)
+	plan, err := engine.IntroduceType(ws, types.IntroduceTypeRequest{
+		TypeName:   "UserID",
+		Underlying: "string",
+		Package:    filepath.Join(dir, "a"),
+		Targets:    []string{"User.ID", "Find.id", "Find.found"},
+	})
+	if err != nil {
+		t.Fatalf("IntroduceType: %v", err)
+	}
+
+	want := map[string]string{
+		"a/a.go": `package a
+
+// UserID is a distinct string type.
+type UserID string
+
+// User is a user.
+type User struct {
+	ID UserID
+	Name string
+}
+
+// Find looks a user up.
+func Find(id UserID, users []User) (found UserID) {
+	for _, u := range users {
+		if string(u.ID) == string(id) {
+			return u.ID
+		}
+	}
+	return ""
+}
+
+var ownerUserID string
+`,
+		"b/b.go": `package b
+
+import (
+	"strings"
+
+	"example.com/p/a"
+)
+
+func Lookup(raw string) string {
+	key := strings.TrimSpace(raw)
+	u := a.User{ID: a.UserID(key), Name: raw}
+	u.ID = a.UserID(key)
+	return string(a.Find(a.UserID(key), []a.User{u, {a.UserID(key), "x"}}))
+}
+`,
+	}
+	for name, content := range want {
+		if got := planContent(t, plan, filepath.Join(dir, name)); got != content {
+			t.Errorf("%s:\ngot:\n%s\nwant:\n%s", name, got, content)
+		}
+	}
+	var candidates []string
+	for _, issue := range plan.Impact.PotentialIssues {
+		if issue.Severity == types.Info {
+			candidates = append(candidates, issue.Description)
+		}
+	}
+	if len(candidates) != 1 || !strings.Contains(candidates[0], "example.com/p/a:ownerUserID") {
+		t.Errorf("expected ownerUserID as the only candidate, got %q", candidates)
+	}
+}
+
+func TestIntroduceType_Errors(t *testing.T) {
+	engine, ws, dir := loadTestModuleWith(t, introduceTypeFiles)
+	for _, tt := range []struct {
+		name string
+		req  types.IntroduceTypeRequest
+		want string
+	}{
+		{"unknown target", types.IntroduceTypeRequest{TypeName: "UserID", Underlying: "string", Targets: []string{"Find.nope"}}, "not found"},
+		{"other type", types.IntroduceTypeRequest{TypeName: "UserID", Underlying: "int", Targets: []string{"Find.id"}}, "declared as string, not int"},
+		{"name taken", types.IntroduceTypeRequest{TypeName: "Find", Underlying: "string"}, "already declares Find"},
+		{"not a type", types.IntroduceTypeRequest{TypeName: "UserID", Underlying: "[[", Targets: []string{"Find.id"}}, "is not a type"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.req.Package = filepath.Join(dir, "a")
+			if _, err := engine.IntroduceType(ws, tt.req); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
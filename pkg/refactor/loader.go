@@ -0,0 +1,28 @@
+package refactor
+
+import "fmt"
+
+// WorkspaceLoader selects how the engine loads a workspace.
+type WorkspaceLoader string
+
+const (
+	// LoaderParser parses every Go file below the workspace root and
+	// type-checks packages on demand, importing dependencies from export
+	// data. It is the default.
+	LoaderParser WorkspaceLoader = "parser"
+	// LoaderPackages loads the module's packages with go/packages, so the
+	// go command decides their files (cgo, vendoring, build constraints)
+	// and every package is type-checked against its real dependencies.
+	LoaderPackages WorkspaceLoader = "packages"
+)
+
+// ParseWorkspaceLoader returns the loader named s; empty is the parser.
+func ParseWorkspaceLoader(s string) (WorkspaceLoader, error) {
+	switch l := WorkspaceLoader(s); l {
+	case "", LoaderParser:
+		return LoaderParser, nil
+	case LoaderPackages:
+		return l, nil
+	}
+	return "", fmt.Errorf("unknown workspace loader %q (want parser or packages)", s)
+}
@@ -0,0 +1,50 @@
+package refactor
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+func TestLoadWorkspace_PackagesLoader(t *testing.T) {
+	dir := writeTestModule(t)
+	if err := os.WriteFile(filepath.Join(dir, "b/b.go"), []byte("package b\n\nimport \"example.com/p/a\"\n\nfunc B() { a.A() }\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	engine := CreateEngineWithConfig(&EngineConfig{SkipCompilation: true, Loader: LoaderPackages}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ws, err := engine.LoadWorkspace(dir)
+	if err != nil {
+		t.Fatalf("LoadWorkspace: %v", err)
+	}
+	if pkg := ws.Packages[filepath.Join(dir, "b")]; pkg == nil || pkg.TypesPkg == nil {
+		t.Fatal("package b is not loaded with type information")
+	}
+
+	plan, err := engine.RenameSymbol(ws, types.RenameSymbolRequest{
+		SymbolName: "A",
+		NewName:    "Z",
+		Package:    filepath.Join(dir, "a"),
+		Scope:      types.WorkspaceScope,
+	})
+	if err != nil {
+		t.Fatalf("RenameSymbol: %v", err)
+	}
+	if got, want := planContent(t, plan, filepath.Join(dir, "b/b.go")), "package b\n\nimport \"example.com/p/a\"\n\nfunc B() { a.Z() }\n"; got != want {
+		t.Errorf("b/b.go:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestParseWorkspaceLoader(t *testing.T) {
+	for s, want := range map[string]WorkspaceLoader{"": LoaderParser, "parser": LoaderParser, "packages": LoaderPackages} {
+		if got, err := ParseWorkspaceLoader(s); err != nil || got != want {
+			t.Errorf("ParseWorkspaceLoader(%q) = %q, %v; want %q", s, got, err, want)
+		}
+	}
+	if _, err := ParseWorkspaceLoader("gopls"); err == nil {
+		t.Error("expected an error for an unknown loader")
+	}
+}
@@ -0,0 +1,544 @@
+package refactor
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// MergePackagesOperation merges packages into an existing one. The Go files
+// of each source package move to the target's directory with its package
+// clause. A file whose name the target already uses gets the source
+// package's name as a prefix, and so do the other files of its stem, so
+// that a test file stays next to the file it tests. Tests, benchmarks and
+// examples keep their names, since what they are about moves with them,
+// except examples of a whole source package, which become examples of the
+// target named Example_<source>. Imports of the sources become imports of
+// the target, with their qualifiers adjusted, and the merged packages stop
+// importing each other.
+type MergePackagesOperation struct {
+	Request types.MergePackagesRequest
+
+	// Resolved by Validate
+	target  *types.Package
+	sources []*types.Package
+	merged  map[string]*types.Package // Import path -> target or source package
+	dest    map[string]string         // Source file -> its path in the target
+}
+
+func (op *MergePackagesOperation) Type() types.OperationType {
+	return types.MergePackagesOperation
+}
+
+func (op *MergePackagesOperation) Description() string {
+	return fmt.Sprintf("Merge %s into %s", strings.Join(op.Request.SourcePackages, ", "), op.Request.TargetPackage)
+}
+
+func (op *MergePackagesOperation) Validate(ws *types.Workspace) error {
+	req := op.Request
+	target, ok := ws.Packages[req.TargetPackage]
+	if !ok {
+		return &types.RefactorError{
+			Type:    types.SymbolNotFound,
+			Message: fmt.Sprintf("package not found: %s", req.TargetPackage),
+		}
+	}
+	if len(req.SourcePackages) == 0 {
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: "no packages to merge given",
+		}
+	}
+	op.target = target
+	op.sources = nil
+	op.merged = map[string]*types.Package{target.ImportPath: target}
+	for _, path := range req.SourcePackages {
+		pkg, ok := ws.Packages[path]
+		if !ok {
+			return &types.RefactorError{
+				Type:    types.SymbolNotFound,
+				Message: fmt.Sprintf("package not found: %s", path),
+			}
+		}
+		switch {
+		case pkg == target:
+			return &types.RefactorError{
+				Type:    types.InvalidOperation,
+				Message: fmt.Sprintf("can't merge %s into itself", pkg.ImportPath),
+			}
+		case op.merged[pkg.ImportPath] != nil:
+			return &types.RefactorError{
+				Type:    types.InvalidOperation,
+				Message: fmt.Sprintf("%s is merged more than once", pkg.ImportPath),
+			}
+		}
+		if err := checkMergeable(pkg); err != nil {
+			return err
+		}
+		op.sources = append(op.sources, pkg)
+		op.merged[pkg.ImportPath] = pkg
+	}
+	if target.Name == "main" {
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("%s is a main package, which nothing can import", target.ImportPath),
+		}
+	}
+
+	if err := op.checkNames(); err != nil {
+		return err
+	}
+	if through := op.importCycle(ws); through != "" {
+		return &types.RefactorError{
+			Type:    types.CyclicDependency,
+			Message: fmt.Sprintf("merging would create an import cycle through %s", through),
+		}
+	}
+	dest, err := op.destinations()
+	if err != nil {
+		return err
+	}
+	op.dest = dest
+	return nil
+}
+
+// checkMergeable reports what of pkg would not move with its Go files.
+func checkMergeable(pkg *types.Package) error {
+	if pkg.Name == "main" {
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("%s is a main package", pkg.ImportPath),
+		}
+	}
+	if info, err := os.Stat(filepath.Join(pkg.Path, "testdata")); err == nil && info.IsDir() {
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("the testdata directory of %s would not move with its tests", pkg.ImportPath),
+		}
+	}
+	for _, file := range packageFiles(pkg) {
+		if file.AST == nil {
+			continue
+		}
+		for _, group := range file.AST.Comments {
+			for _, c := range group.List {
+				if strings.HasPrefix(c.Text, "//go:embed") {
+					return &types.RefactorError{
+						Type:    types.InvalidOperation,
+						Message: fmt.Sprintf("%s embeds files, which would not move with it", filepath.Base(file.Path)),
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// packages returns the target and then the sources.
+func (op *MergePackagesOperation) packages() []*types.Package {
+	return append([]*types.Package{op.target}, op.sources...)
+}
+
+// checkNames reports a name that two of the merged packages declare, at
+// package level or in their external tests, and a file import that a
+// package-level name of another merged package would clash with.
+func (op *MergePackagesOperation) checkNames() error {
+	declaredBy := map[bool]map[string]*types.Package{false: {}, true: {}} // By whether the tests are external
+	var testMain *types.Package
+	for _, pkg := range op.packages() {
+		for _, file := range sortedMergeFiles(pkg) {
+			external := file.AST.Name.Name != pkg.Name
+			for _, decl := range file.AST.Decls {
+				for _, name := range declaredNames(decl) {
+					if pkg != op.target && isPackageExample(name) {
+						name = mergedExampleName(pkg.Name, name)
+					}
+					if name == "_" || name == "init" {
+						continue
+					}
+					if name == "TestMain" {
+						if testMain != nil && testMain != pkg {
+							return nameClash(name, testMain, pkg)
+						}
+						testMain = pkg
+					}
+					if other := declaredBy[external][name]; other != nil && other != pkg {
+						return nameClash(name, other, pkg)
+					}
+					declaredBy[external][name] = pkg
+				}
+			}
+		}
+	}
+
+	for _, pkg := range op.packages() {
+		for _, file := range sortedMergeFiles(pkg) {
+			if file.AST.Name.Name != pkg.Name {
+				continue
+			}
+			for _, spec := range file.AST.Imports {
+				name := importName(spec)
+				if other := declaredBy[false][name]; other != nil && other != pkg && op.merged[importPathOf(spec)] == nil && name != "_" && name != "." {
+					return &types.RefactorError{
+						Type:    types.NameConflict,
+						Message: fmt.Sprintf("%s imports %s as %s, which %s declares", filepath.Base(file.Path), spec.Path.Value, name, other.ImportPath),
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func nameClash(name string, a, b *types.Package) error {
+	return &types.RefactorError{
+		Type:    types.NameConflict,
+		Message: fmt.Sprintf("%s is declared in both %s and %s", name, a.ImportPath, b.ImportPath),
+	}
+}
+
+// mergedExampleName returns the name an example of the package named pkg
+// as a whole takes in the package it merges into: Example_pkg for Example
+// and Example_pkg_suffix for Example_suffix.
+func mergedExampleName(pkg, name string) string {
+	return "Example_" + pkg + strings.TrimPrefix(name, "Example")
+}
+
+// importCycle returns a workspace package that the merged package would
+// import and be imported by, or "".
+func (op *MergePackagesOperation) importCycle(ws *types.Workspace) string {
+	var queue []string
+	for _, pkg := range op.packages() {
+		queue = append(queue, packageImports(pkg)...)
+	}
+	seen := make(map[string]bool)
+	for len(queue) > 0 {
+		path := queue[0]
+		queue = queue[1:]
+		if seen[path] || op.merged[path] != nil {
+			continue
+		}
+		seen[path] = true
+		pkg := ws.Packages[ws.ImportToPath[path]]
+		if pkg == nil {
+			continue
+		}
+		imports := packageImports(pkg)
+		if slices.ContainsFunc(imports, func(p string) bool { return op.merged[p] != nil }) {
+			return path
+		}
+		queue = append(queue, imports...)
+	}
+	return ""
+}
+
+// packageImports returns the import paths of the non-test files of pkg.
+func packageImports(pkg *types.Package) []string {
+	var paths []string
+	for _, file := range sortedFiles(pkg.Files) {
+		for _, spec := range file.AST.Imports {
+			paths = append(paths, importPathOf(spec))
+		}
+	}
+	return paths
+}
+
+// destinations returns the path each file of the sources moves to. A file
+// whose name the target or an earlier source has is prefixed with its
+// package's name, along with the other files of its stem.
+func (op *MergePackagesOperation) destinations() (map[string]string, error) {
+	taken := make(map[string]bool)
+	for _, file := range packageFiles(op.target) {
+		taken[filepath.Base(file.Path)] = true
+	}
+	dest := make(map[string]string)
+	for _, pkg := range op.sources {
+		files := sortedMergeFiles(pkg)
+		prefixed := make(map[string]bool) // By stem
+		for _, file := range files {
+			if name := filepath.Base(file.Path); taken[name] {
+				prefixed[fileStemOf(name)] = true
+			}
+		}
+		for _, file := range files {
+			base := filepath.Base(file.Path)
+			name := base
+			if prefixed[fileStemOf(base)] {
+				name = pkg.Name + "_" + base
+				goos, goarch := fileSuffix(fileStemOf(base))
+				if prefixedOS, prefixedArch := fileSuffix(fileStemOf(name)); prefixedOS != goos || prefixedArch != goarch {
+					return nil, &types.RefactorError{
+						Type:    types.NameConflict,
+						Message: fmt.Sprintf("%s is taken in %s, and %s would change its build constraints", base, op.target.ImportPath, name),
+					}
+				}
+			}
+			if taken[name] {
+				return nil, &types.RefactorError{
+					Type:    types.NameConflict,
+					Message: fmt.Sprintf("%s is taken in %s", name, op.target.ImportPath),
+				}
+			}
+			taken[name] = true
+			dest[file.Path] = filepath.Join(op.target.Path, name)
+		}
+	}
+	return dest, nil
+}
+
+// sortedMergeFiles returns the parsed files of pkg, tests last.
+func sortedMergeFiles(pkg *types.Package) []*types.File {
+	return append(sortedFiles(pkg.Files), sortedFiles(pkg.TestFiles)...)
+}
+
+func (op *MergePackagesOperation) Execute(ws *types.Workspace) (*types.RefactoringPlan, error) {
+	var changes []types.Change
+	var affected []string
+	paths := make([]string, 0, len(ws.Packages))
+	for path := range ws.Packages {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+	for _, path := range paths {
+		pkg := ws.Packages[path]
+		merged := op.merged[pkg.ImportPath] == pkg
+		source := merged && pkg != op.target
+		for _, file := range sortedMergeFiles(pkg) {
+			internal := merged && file.AST.Name.Name == pkg.Name
+			edits := op.importEdits(ws, file, internal)
+			if source {
+				edits = append(edits, op.clauseEdits(ws, pkg, file)...)
+			}
+			if len(edits) == 0 && !source {
+				continue
+			}
+			content := formatted(applyTextEdits(string(file.OriginalContent), edits))
+			if !source {
+				changes = append(changes, types.Change{
+					File:        file.Path,
+					End:         len(file.OriginalContent),
+					OldText:     string(file.OriginalContent),
+					NewText:     content,
+					Description: fmt.Sprintf("Update imports of merged packages in %s", filepath.Base(file.Path)),
+				})
+				affected = append(affected, file.Path)
+				continue
+			}
+			to := op.dest[file.Path]
+			changes = append(changes,
+				types.Change{
+					File:        to,
+					NewText:     content,
+					Description: fmt.Sprintf("Move file %s to %s", file.Path, to),
+				},
+				types.Change{
+					File:        file.Path,
+					End:         len(file.OriginalContent),
+					OldText:     string(file.OriginalContent),
+					Description: fmt.Sprintf("Remove file %s (moved to %s)", file.Path, to),
+				})
+			// Only the new path is affected: the emptied source directory
+			// has nothing left to build.
+			affected = append(affected, to)
+		}
+	}
+
+	return &types.RefactoringPlan{
+		Changes:       changes,
+		AffectedFiles: affected,
+		Impact: &types.ImpactAnalysis{
+			AffectedPackages: []string{op.target.Path},
+			AffectedFiles:    affected,
+		},
+		Reversible: true,
+	}, nil
+}
+
+// clauseEdits give a file of a source package the package clause of the
+// target, and the examples of the source as a whole their merged names.
+func (op *MergePackagesOperation) clauseEdits(ws *types.Workspace, pkg *types.Package, file *types.File) []textEdit {
+	clause := op.target.Name
+	if file.AST.Name.Name != pkg.Name {
+		clause += "_test"
+	}
+	edits := []textEdit{identEdit(ws, file.AST.Name, clause)}
+	for _, decl := range file.AST.Decls {
+		if fd, ok := decl.(*ast.FuncDecl); ok && fd.Recv == nil && isPackageExample(fd.Name.Name) {
+			edits = append(edits, identEdit(ws, fd.Name, mergedExampleName(pkg.Name, fd.Name.Name)))
+		}
+	}
+	return edits
+}
+
+// importEdits rewrite the imports of merged packages in file. Files of the
+// merged package drop them and refer to their declarations unqualified;
+// other files keep one import of the target, named as the merged package
+// unless the file uses that name otherwise.
+func (op *MergePackagesOperation) importEdits(ws *types.Workspace, file *types.File, internal bool) []textEdit {
+	var specs []*ast.ImportSpec
+	for _, spec := range file.AST.Imports {
+		if op.merged[importPathOf(spec)] != nil {
+			specs = append(specs, spec)
+		}
+	}
+	if len(specs) == 0 {
+		return nil
+	}
+	uses := make(map[*ast.ImportSpec][]*ast.SelectorExpr)
+	ast.Inspect(file.AST, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if x, ok := sel.X.(*ast.Ident); ok && x.Obj == nil {
+				for _, spec := range specs {
+					if x.Name == op.qualifier(spec) {
+						uses[spec] = append(uses[spec], sel)
+					}
+				}
+			}
+		}
+		return true
+	})
+
+	var edits []textEdit
+	var removed []*ast.ImportSpec
+	qualify := func(spec *ast.ImportSpec, qualifier string) {
+		for _, sel := range uses[spec] {
+			if qualifier == "" || qualifier == "." {
+				edits = append(edits, textEdit{start: offsetOf(ws, sel.X.Pos()), end: offsetOf(ws, sel.Sel.Pos())})
+			} else if qualifier != op.qualifier(spec) {
+				edits = append(edits, identEdit(ws, sel.X.(*ast.Ident), qualifier))
+			}
+		}
+	}
+	if internal {
+		for _, spec := range specs {
+			qualify(spec, "")
+		}
+		return append(edits, importRemovals(ws, file, specs)...)
+	}
+
+	keep := slices.IndexFunc(specs, func(s *ast.ImportSpec) bool {
+		return importPathOf(s) == op.target.ImportPath && (s.Name == nil || s.Name.Name != "_")
+	})
+	if keep < 0 {
+		keep = slices.IndexFunc(specs, func(s *ast.ImportSpec) bool { return s.Name == nil || s.Name.Name != "_" })
+	}
+	if keep < 0 {
+		keep = 0
+	}
+	kept := specs[keep]
+	qualifier := op.qualifier(kept)
+	alias := ""
+	if kept.Name == nil && qualifier != op.target.Name {
+		if nameUnused(file, op.target.Name, uses) {
+			qualifier = op.target.Name
+		} else {
+			alias = qualifier + " "
+		}
+	}
+	if alias != "" || importPathOf(kept) != op.target.ImportPath {
+		edits = append(edits, textEdit{
+			start: offsetOf(ws, kept.Path.Pos()),
+			end:   offsetOf(ws, kept.Path.End()),
+			text:  alias + strconv.Quote(op.target.ImportPath),
+		})
+	}
+	for _, spec := range specs {
+		qualify(spec, qualifier)
+		if spec != kept {
+			removed = append(removed, spec)
+		}
+	}
+	return append(edits, importRemovals(ws, file, removed)...)
+}
+
+// qualifier returns the name a file refers to a merged package by.
+func (op *MergePackagesOperation) qualifier(spec *ast.ImportSpec) string {
+	if spec.Name != nil {
+		return spec.Name.Name
+	}
+	return op.merged[importPathOf(spec)].Name
+}
+
+// nameUnused reports whether file uses name for nothing but the qualifiers
+// in uses.
+func nameUnused(file *types.File, name string, uses map[*ast.ImportSpec][]*ast.SelectorExpr) bool {
+	qualifiers := make(map[*ast.Ident]bool)
+	for _, sels := range uses {
+		for _, sel := range sels {
+			qualifiers[sel.X.(*ast.Ident)] = true
+		}
+	}
+	unused := true
+	ast.Inspect(file.AST, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && id.Name == name && !qualifiers[id] && id != file.AST.Name {
+			unused = false
+		}
+		return unused
+	})
+	return unused
+}
+
+// importRemovals remove the import specs from file, and import
+// declarations left empty.
+func importRemovals(ws *types.Workspace, file *types.File, specs []*ast.ImportSpec) []textEdit {
+	var edits []textEdit
+	for _, decl := range file.AST.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.IMPORT {
+			continue
+		}
+		var gone []ast.Spec
+		for _, spec := range gen.Specs {
+			if slices.Contains(specs, spec.(*ast.ImportSpec)) {
+				gone = append(gone, spec)
+			}
+		}
+		switch {
+		case len(gone) == 0:
+		case len(gone) == len(gen.Specs):
+			edits = append(edits, lineEdit(ws, file, gen.Pos(), gen.End()))
+		default:
+			for _, spec := range gone {
+				start := spec.Pos()
+				if doc := spec.(*ast.ImportSpec).Doc; doc != nil {
+					start = doc.Pos()
+				}
+				edits = append(edits, lineEdit(ws, file, start, spec.End()))
+			}
+		}
+	}
+	return edits
+}
+
+// lineEdit removes the lines from start to end, newline included.
+func lineEdit(ws *types.Workspace, file *types.File, start, end token.Pos) textEdit {
+	content := file.OriginalContent
+	s, e := offsetOf(ws, start), offsetOf(ws, end)
+	for s > 0 && content[s-1] != '\n' {
+		s--
+	}
+	for e < len(content) && content[e] != '\n' {
+		e++
+	}
+	if e < len(content) {
+		e++
+	}
+	return textEdit{start: s, end: e}
+}
+
+// identEdit renames an identifier.
+func identEdit(ws *types.Workspace, id *ast.Ident, name string) textEdit {
+	start := offsetOf(ws, id.Pos())
+	return textEdit{start: start, end: start + len(id.Name), text: name}
+}
+
+func offsetOf(ws *types.Workspace, pos token.Pos) int {
+	return ws.FileSet.Position(pos).Offset
+}
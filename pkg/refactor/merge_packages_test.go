@@ -0,0 +1,215 @@
+package refactor
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// writeModule writes files, by slash-separated path, into a temp directory.
+func writeModule(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// vetModule type-checks the module in dir, tests included, and checks that
+// its examples name what they document.
+func vetModule(t *testing.T, dir string) {
+	t.Helper()
+	cmd := exec.Command("go", "vet", "./...")
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go vet: %v\n%s", err, out)
+	}
+}
+
+func fileContent(t *testing.T, path string) string {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestMergePackages(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"go.mod": "module example.com/p\n\ngo 1.21\n",
+		"text/text.go": `package text
+
+import "example.com/p/words"
+
+// Title capitalizes every word of s.
+func Title(s string) string {
+	return words.Join(words.Split(s))
+}
+`,
+		"text/text_test.go": `package text
+
+import "testing"
+
+func TestTitle(t *testing.T) {
+	if Title("a b") != "a b" {
+		t.Fail()
+	}
+}
+`,
+		"words/text.go": `package words
+
+import "strings"
+
+// Split splits s into words.
+func Split(s string) []string { return strings.Fields(s) }
+
+// Join joins words with spaces.
+func Join(words []string) string { return strings.Join(words, " ") }
+`,
+		"words/text_test.go": `package words
+
+import "testing"
+
+func BenchmarkSplit(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		Split("a b c")
+	}
+}
+`,
+		"words/example_test.go": `package words_test
+
+import (
+	"fmt"
+
+	"example.com/p/words"
+)
+
+func Example() {
+	fmt.Println(words.Join([]string{"a", "b"}))
+	// Output: a b
+}
+
+func ExampleSplit() {
+	fmt.Println(len(words.Split("a b")))
+	// Output: 2
+}
+`,
+		"app/app.go": `package app
+
+import (
+	"example.com/p/text"
+	"example.com/p/words"
+)
+
+func Run() string { return text.Title(words.Join(nil)) }
+`,
+	})
+	engine := CreateEngine(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ws, err := engine.LoadWorkspace(dir)
+	if err != nil {
+		t.Fatalf("LoadWorkspace: %v", err)
+	}
+
+	plan, err := engine.MergePackages(ws, types.MergePackagesRequest{
+		SourcePackages: []string{filepath.Join(dir, "words")},
+		TargetPackage:  filepath.Join(dir, "text"),
+	})
+	if err != nil {
+		t.Fatalf("MergePackages: %v", err)
+	}
+	if err := engine.ExecutePlan(plan); err != nil {
+		t.Fatalf("ExecutePlan: %v", err)
+	}
+	vetModule(t, dir)
+
+	for _, name := range []string{"words/text.go", "words/text_test.go", "words/example_test.go"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be moved", name)
+		}
+	}
+	// text.go is taken, so the words files of its stem are both prefixed
+	// and the benchmark stays next to Split.
+	if got := fileContent(t, filepath.Join(dir, "text/words_text.go")); !strings.Contains(got, "func Split(") {
+		t.Errorf("expected Split in text/words_text.go, got:\n%s", got)
+	}
+	if got := fileContent(t, filepath.Join(dir, "text/words_text_test.go")); !strings.Contains(got, "package text\n") || !strings.Contains(got, "func BenchmarkSplit(") {
+		t.Errorf("expected BenchmarkSplit in text/words_text_test.go, got:\n%s", got)
+	}
+	got := fileContent(t, filepath.Join(dir, "text/example_test.go"))
+	for _, want := range []string{"package text_test", `"example.com/p/text"`, "func Example_words() {", "text.Join(", "func ExampleSplit() {", "text.Split("} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in text/example_test.go, got:\n%s", want, got)
+		}
+	}
+	if got := fileContent(t, filepath.Join(dir, "text/text.go")); strings.Contains(got, "words.") || strings.Contains(got, "import") {
+		t.Errorf("expected text.go to use Split and Join unqualified, got:\n%s", got)
+	}
+	if got := fileContent(t, filepath.Join(dir, "app/app.go")); strings.Contains(got, "words") || !strings.Contains(got, "text.Join(nil)") {
+		t.Errorf("expected app.go to import only text, got:\n%s", got)
+	}
+}
+
+func TestMergePackages_Refused(t *testing.T) {
+	files := map[string]string{
+		"go.mod": "module example.com/p\n\ngo 1.21\n",
+		"a/a.go": "package a\n\nfunc Name() string { return \"a\" }\n",
+		"b/b.go": "package b\n\nimport \"example.com/p/c\"\n\nfunc Name() string { return c.C }\n",
+		"c/c.go": "package c\n\nimport \"example.com/p/d\"\n\nvar C = d.D\n",
+		"d/d.go": "package d\n\nconst D = \"d\"\n",
+		"e/e.go": "package e\n\nimport \"example.com/p/d\"\n\nvar E = d.D\n",
+		"e/e_test.go": "package e\n\nimport \"testing\"\n\nfunc TestMain(m *testing.M) { m.Run() }\n",
+		"f/f_test.go": "package f\n\nimport \"testing\"\n\nfunc TestMain(m *testing.M) { m.Run() }\n",
+		"f/f.go": "package f\n\nconst F = 1\n",
+	}
+	dir := writeModule(t, files)
+	engine := CreateEngine(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ws, err := engine.LoadWorkspace(dir)
+	if err != nil {
+		t.Fatalf("LoadWorkspace: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		sources []string
+		target  string
+		want    string
+	}{
+		{"name clash", []string{"a"}, "b", "Name is declared in both"},
+		{"target imports the source", []string{"d"}, "c", ""},
+		{"cycle through another package", []string{"d"}, "b", "import cycle through example.com/p/c"},
+		{"two TestMains", []string{"f"}, "e", "TestMain is declared in both"},
+		{"into itself", []string{"a"}, "a", "into itself"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sources []string
+			for _, s := range tt.sources {
+				sources = append(sources, filepath.Join(dir, s))
+			}
+			_, err := engine.MergePackages(ws, types.MergePackagesRequest{SourcePackages: sources, TargetPackage: filepath.Join(dir, tt.target)})
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("MergePackages: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected an error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
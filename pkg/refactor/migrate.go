@@ -0,0 +1,216 @@
+package refactor
+
+import (
+	"embed"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/mamaar/gorefactor/pkg/analysis"
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// builtinPacks holds the migration packs shipped with gorefactor.
+//
+//go:embed migrations/*.yaml
+var builtinPacks embed.FS
+
+// MigrationPack is a bundle of rewrite rules for an upgrade, such as moving
+// off a deprecated package, read from YAML.
+type MigrationPack struct {
+	Name        string          `yaml:"name" json:"name"`
+	Description string          `yaml:"description" json:"description"`
+	Rules       []MigrationRule `yaml:"rules" json:"rules,omitempty"`
+}
+
+// MigrationRule is a rewrite of a migration pack, in the syntax of the
+// rewrite operation.
+type MigrationRule struct {
+	Name        string            `yaml:"name" json:"name"`
+	Pattern     string            `yaml:"pattern" json:"pattern"`
+	Replacement string            `yaml:"replacement" json:"replacement"`
+	Where       map[string]string `yaml:"where,omitempty" json:"where,omitempty"`
+	Note        string            `yaml:"note,omitempty" json:"note,omitempty"` // What to check where the rule rewrote code
+}
+
+// MigrationRuleStats is how many expressions a rule of a pack rewrote.
+type MigrationRuleStats struct {
+	Rule    string `json:"rule"`
+	Matches int    `json:"matches"`
+	Note    string `json:"note,omitempty"`
+}
+
+// MigrationPacks returns the built-in migration packs, by name.
+func MigrationPacks() ([]*MigrationPack, error) {
+	entries, err := builtinPacks.ReadDir("migrations")
+	if err != nil {
+		return nil, err
+	}
+	var packs []*MigrationPack
+	for _, e := range entries {
+		data, err := builtinPacks.ReadFile(path.Join("migrations", e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		pack, err := parseMigrationPack(e.Name(), data)
+		if err != nil {
+			return nil, err
+		}
+		packs = append(packs, pack)
+	}
+	slices.SortFunc(packs, func(a, b *MigrationPack) int { return strings.Compare(a.Name, b.Name) })
+	return packs, nil
+}
+
+// LoadMigrationPack returns the built-in pack named name or, when name is
+// a .yaml or .yml file, the pack it holds; relative paths are relative to
+// root.
+func LoadMigrationPack(name, root string) (*MigrationPack, error) {
+	if ext := filepath.Ext(name); ext == ".yaml" || ext == ".yml" {
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(root, name)
+		}
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		return parseMigrationPack(name, data)
+	}
+
+	packs, err := MigrationPacks()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, p := range packs {
+		if p.Name == name {
+			return p, nil
+		}
+		names = append(names, p.Name)
+	}
+	return nil, fmt.Errorf("unknown migration pack %q (built-in packs: %s)", name, strings.Join(names, ", "))
+}
+
+// parseMigrationPack parses the YAML of the pack in file and checks that
+// its rules compile.
+func parseMigrationPack(file string, data []byte) (*MigrationPack, error) {
+	var pack MigrationPack
+	if err := yaml.Unmarshal(data, &pack); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	if pack.Name == "" {
+		pack.Name = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	}
+	if len(pack.Rules) == 0 {
+		return nil, fmt.Errorf("%s: the pack has no rules", file)
+	}
+	for i, r := range pack.Rules {
+		if r.Name == "" {
+			pack.Rules[i].Name = r.Pattern
+		}
+		if _, err := compileRewriteRule(r.Pattern, r.Replacement, r.Where); err != nil {
+			return nil, fmt.Errorf("%s: rule %s: %w", file, pack.Rules[i].Name, err)
+		}
+	}
+	return &pack, nil
+}
+
+// MigrateOperation applies the rules of a migration pack as one plan: the
+// first rule matching an expression rewrites it, as RewriteOperation
+// would. Execute records how many expressions each rule rewrote; the notes
+// of the rules that rewrote any are reported in the plan's impact.
+type MigrateOperation struct {
+	Request types.MigrateRequest
+	Parser  *analysis.GoParser // Type-checks packages on demand for guards; may be nil if they already are
+
+	pack  *MigrationPack // Loaded by Validate
+	rules []*rewriteRule
+	names map[string]string    // Package names of the imports the plan leaves unused, set by Execute
+	Stats []MigrationRuleStats // Set by Execute
+}
+
+func (op *MigrateOperation) Type() types.OperationType {
+	return types.MigrateOperation
+}
+
+func (op *MigrateOperation) Description() string {
+	return fmt.Sprintf("Apply migration pack %s", op.Request.Pack)
+}
+
+func (op *MigrateOperation) Validate(ws *types.Workspace) error {
+	if op.Request.Pack == "" {
+		return &types.RefactorError{Type: types.InvalidOperation, Message: "migration pack is required"}
+	}
+	pack, err := LoadMigrationPack(op.Request.Pack, ws.RootPath)
+	if err != nil {
+		return &types.RefactorError{Type: types.InvalidOperation, Message: err.Error()}
+	}
+	if op.Request.Package != "" {
+		if _, ok := ws.Packages[types.ResolvePackagePath(ws, op.Request.Package)]; !ok {
+			return &types.RefactorError{
+				Type:    types.SymbolNotFound,
+				Message: fmt.Sprintf("package %s not found", op.Request.Package),
+			}
+		}
+	}
+	op.pack, op.rules = pack, nil
+	for _, r := range pack.Rules {
+		rule, err := compileRewriteRule(r.Pattern, r.Replacement, r.Where)
+		if err != nil {
+			return &types.RefactorError{Type: types.InvalidOperation, Message: err.Error()}
+		}
+		rule.name = r.Name
+		op.rules = append(op.rules, rule)
+	}
+	return nil
+}
+
+func (op *MigrateOperation) Execute(ws *types.Workspace) (*types.RefactoringPlan, error) {
+	if op.pack == nil {
+		if err := op.Validate(ws); err != nil {
+			return nil, err
+		}
+	}
+	pkgPath := ""
+	if op.Request.Package != "" {
+		pkgPath = types.ResolvePackagePath(ws, op.Request.Package)
+	}
+	plan, result, err := rewriteWorkspace(ws, op.Parser, op.rules, pkgPath)
+	if err != nil {
+		return nil, err
+	}
+	counts := result.counts
+	op.names = result.names
+
+	op.Stats = nil
+	for i, r := range op.pack.Rules {
+		op.Stats = append(op.Stats, MigrationRuleStats{Rule: r.Name, Matches: counts[i], Note: r.Note})
+		if counts[i] > 0 && r.Note != "" {
+			plan.Impact.PotentialIssues = append(plan.Impact.PotentialIssues, types.Issue{
+				Type:        types.IssueSideEffect,
+				Severity:    types.Info,
+				Description: fmt.Sprintf("%s (%d rewritten): %s", r.Name, counts[i], r.Note),
+			})
+		}
+	}
+	return plan, nil
+}
+
+// importPaths returns the import paths the pack's replacements name
+// packages by.
+func (op *MigrateOperation) importPaths() []string {
+	var paths []string
+	for _, r := range op.rules {
+		for _, p := range r.importPaths() {
+			if !slices.Contains(paths, p) {
+				paths = append(paths, p)
+			}
+		}
+	}
+	return paths
+}
@@ -0,0 +1,142 @@
+package refactor
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+var migrateFiles = map[string]string{
+	"pkgerrors/errors.go": `package errors
+
+func New(msg string) error { return nil }
+
+func Wrapf(err error, format string, args ...any) error { return err }
+
+func Cause(err error) error { return err }
+`,
+	"app/read.go": `package app
+
+import (
+	"io/ioutil"
+	"os"
+)
+
+func Read(path string) ([]byte, int, error) {
+	entries, _ := ioutil.ReadDir(path)
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	data, err := ioutil.ReadAll(f)
+	return data, len(entries), err
+}
+`,
+	"app/errs.go": `package app
+
+import "example.com/p/pkgerrors"
+
+var ErrEmpty = errors.New("empty")
+
+func Check(n int, err error) error {
+	if err != nil {
+		return errors.Wrapf(err, "check %d", n)
+	}
+	return errors.Wrapf(ErrEmpty, "check")
+}
+`,
+	"app/cause.go": `package app
+
+import "example.com/p/pkgerrors"
+
+var ErrCause = errors.Cause(errors.New("cause"))
+`,
+	"pkgerrors.yaml": `name: pkgerrors
+rules:
+  - name: Wrapf
+    pattern: '"example.com/p/pkgerrors".Wrapf(:[err], :[format], :[args...])'
+    replacement: '"fmt".Errorf(:[format] + ": %w", :[args...], :[err])'
+    note: Wrapf returns nil for a nil error
+  - name: New
+    pattern: '"example.com/p/pkgerrors".New'
+    replacement: '"errors".New'
+`,
+}
+
+func TestMigrate(t *testing.T) {
+	engine, ws, dir := loadTestModuleWith(t, migrateFiles)
+
+	plan, err := engine.Migrate(ws, types.MigrateRequest{Pack: "ioutil"})
+	if err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	read := planContent(t, plan, filepath.Join(dir, "app", "read.go"))
+	for _, want := range []string{"data, err := io.ReadAll(f)", "ioutil.ReadDir(path)", `"io"`, `"io/ioutil"`} {
+		if !strings.Contains(read, want) {
+			t.Errorf("read.go lacks %q:\n%s", want, read)
+		}
+	}
+	stats := plan.Operations[0].(*MigrateOperation).Stats
+	if i := slices.IndexFunc(stats, func(s MigrationRuleStats) bool { return s.Rule == "ReadAll" }); i < 0 || stats[i].Matches != 1 {
+		t.Errorf("expected ReadAll to rewrite one call, got %+v", stats)
+	}
+}
+
+func TestMigrate_PackFile(t *testing.T) {
+	engine, ws, dir := loadTestModuleWith(t, migrateFiles)
+
+	plan, err := engine.Migrate(ws, types.MigrateRequest{Pack: "pkgerrors.yaml"})
+	if err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	errs := planContent(t, plan, filepath.Join(dir, "app", "errs.go"))
+	for _, want := range []string{
+		`var ErrEmpty = errors.New("empty")`,
+		`return fmt.Errorf("check %d"+": %w", n, err)`,
+		`return fmt.Errorf("check"+": %w", ErrEmpty)`,
+		`"errors"`,
+		`"fmt"`,
+	} {
+		if !strings.Contains(errs, want) {
+			t.Errorf("errs.go lacks %q:\n%s", want, errs)
+		}
+	}
+	if strings.Contains(errs, "pkgerrors") {
+		t.Errorf("errs.go should no longer import pkgerrors:\n%s", errs)
+	}
+
+	// cause.go still uses the package by the name errors.
+	var conflict, note bool
+	for _, issue := range plan.Impact.PotentialIssues {
+		conflict = conflict || strings.Contains(issue.Description, "cause.go not rewritten")
+		note = note || strings.Contains(issue.Description, "Wrapf (2 rewritten)")
+	}
+	if !conflict || !note {
+		t.Errorf("expected cause.go to be reported and the Wrapf note, got %+v", plan.Impact.PotentialIssues)
+	}
+	for _, c := range plan.Changes {
+		if strings.HasSuffix(c.File, "cause.go") {
+			t.Errorf("cause.go should be left alone: %+v", c)
+		}
+	}
+}
+
+func TestMigrationPacks(t *testing.T) {
+	packs, err := MigrationPacks()
+	if err != nil {
+		t.Fatalf("MigrationPacks: %v", err)
+	}
+	var names []string
+	for _, p := range packs {
+		names = append(names, p.Name)
+	}
+	if want := []string{"ioutil", "pkg-errors", "x-net-context"}; !slices.Equal(names, want) {
+		t.Errorf("got packs %v, want %v", names, want)
+	}
+	if _, err := LoadMigrationPack("nowhere", t.TempDir()); err == nil || !strings.Contains(err.Error(), "ioutil") {
+		t.Errorf("expected an unknown pack to list the built-in ones, got %v", err)
+	}
+}
@@ -0,0 +1,268 @@
+package refactor
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// movedTest is a test function that moves along with the symbol it
+// exercises.
+type movedTest struct {
+	file       *types.File
+	decl       *ast.FuncDecl
+	start, end int    // Offsets of the declaration, doc comment included
+	target     string // Test file of the target package it moves to
+	pkgName    string // Package clause of the target file
+}
+
+// covers reports whether offset in file lies within the test.
+func (t movedTest) covers(file string, offset int) bool {
+	return t.file.Path == file && offset >= t.start && offset < t.end
+}
+
+// inMovedTests reports whether ref lies within one of tests.
+func inMovedTests(tests []movedTest, ref *types.Reference) bool {
+	return slices.ContainsFunc(tests, func(t movedTest) bool { return t.covers(ref.File, ref.Offset) })
+}
+
+// testsToMove returns the test functions of the source package that refer to
+// symbol and can follow it to the target package: those whose only
+// unqualified references to package-level names of their package are to
+// symbol, and whose names the target package doesn't declare yet. A test
+// moves to the test file of the target package with its file's name, or to
+// another one with the same package clause, the package's name or its _test
+// variant, if that name is taken by the other variant.
+func (op *MoveSymbolOperation) testsToMove(ws *types.Workspace, symbol *types.Symbol, references []*types.Reference) []movedTest {
+	if !op.Request.MoveTests {
+		return nil
+	}
+	sourcePackage := ws.Packages[op.Request.FromPackage]
+	targetPackage := ws.Packages[op.Request.ToPackage]
+	targetName := lastPathComponent(op.Request.ToPackage)
+	taken := make(map[string]bool)
+	if targetPackage != nil {
+		targetName = targetPackage.Name
+		for _, f := range packageFiles(targetPackage) {
+			if f.AST != nil {
+				maps.Copy(taken, topLevelNames(f.AST))
+			}
+		}
+	}
+
+	var tests []movedTest
+	for _, name := range slices.Sorted(maps.Keys(sourcePackage.TestFiles)) {
+		file := sourcePackage.TestFiles[name]
+		if file.AST == nil {
+			continue
+		}
+		external := file.AST.Name.Name != sourcePackage.Name
+		pkgName := targetName
+		if external {
+			pkgName += "_test"
+		}
+		target := op.testTarget(targetPackage, name, pkgName)
+		if target == "" {
+			continue
+		}
+
+		// Package-level names the file's tests may refer to unqualified
+		scope := make(map[string]bool)
+		for _, f := range packageFiles(sourcePackage) {
+			if f.AST != nil && f.AST.Name.Name == file.AST.Name.Name {
+				maps.Copy(scope, topLevelNames(f.AST))
+			}
+		}
+
+		for _, decl := range file.AST.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || !isTestFunc(fn) || taken[fn.Name.Name] {
+				continue
+			}
+			start := fn.Pos()
+			if fn.Doc != nil {
+				start = fn.Doc.Pos()
+			}
+			t := movedTest{
+				file:    file,
+				decl:    fn,
+				start:   ws.FileSet.Position(start).Offset,
+				end:     ws.FileSet.Position(fn.End()).Offset,
+				target:  target,
+				pkgName: pkgName,
+			}
+			refersToSymbol := slices.ContainsFunc(references, func(ref *types.Reference) bool {
+				return t.covers(ref.File, ref.Offset)
+			})
+			if refersToSymbol && !usesPackageNames(fn, scope, symbol.Name) {
+				tests = append(tests, t)
+			}
+		}
+	}
+	return tests
+}
+
+// testTarget returns the path of the test file of targetPackage the tests of
+// the source test file name move to, or "" when none has pkgName as its
+// package clause and a new one can't take the name.
+func (op *MoveSymbolOperation) testTarget(targetPackage *types.Package, name, pkgName string) string {
+	path := filepath.Join(op.Request.ToPackage, name)
+	if targetPackage == nil {
+		return path
+	}
+	if f := targetPackage.TestFiles[name]; f == nil || f.AST != nil && f.AST.Name.Name == pkgName {
+		return path
+	}
+	for _, other := range slices.Sorted(maps.Keys(targetPackage.TestFiles)) {
+		if f := targetPackage.TestFiles[other]; f.AST != nil && f.AST.Name.Name == pkgName {
+			return f.Path
+		}
+	}
+	return ""
+}
+
+// usesPackageNames reports whether fn refers unqualified to a name in scope
+// other than allowed and its own.
+func usesPackageNames(fn *ast.FuncDecl, scope map[string]bool, allowed string) bool {
+	used := false
+	ast.Inspect(fn, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			ast.Inspect(n.X, func(n ast.Node) bool {
+				if id, ok := n.(*ast.Ident); ok && id.Name != allowed && scope[id.Name] {
+					used = true
+				}
+				return !used
+			})
+			return false
+		case *ast.Ident:
+			if n != fn.Name && n.Name != allowed && scope[n.Name] {
+				used = true
+			}
+		}
+		return !used
+	})
+	return used
+}
+
+// generateTestMoveChanges returns the changes removing tests from their
+// files and adding them to their target files. References to the symbol
+// in tests of an external test package are qualified with the target
+// package; a source test file left without declarations is deleted.
+func (op *MoveSymbolOperation) generateTestMoveChanges(ws *types.Workspace, tests []movedTest, references []*types.Reference, targetPackageName string) ([]types.Change, error) {
+	var changes []types.Change
+	moved := make(map[string][]string)
+	var targets []string
+	bySource := make(map[*types.File][]movedTest)
+	var sources []*types.File
+
+	for _, t := range tests {
+		src := string(t.file.OriginalContent)
+		text := src[t.start:t.end]
+		if strings.HasSuffix(t.pkgName, "_test") {
+			var edits []types.Change
+			for _, ref := range references {
+				if !t.covers(ref.File, ref.Offset) {
+					continue
+				}
+				edit, err := op.generateReferenceUpdateChange(ws, ref, op.Request.ToPackage, targetPackageName)
+				if err != nil {
+					return nil, err
+				}
+				if edit != nil {
+					edit.Start -= t.start
+					edit.End -= t.start
+					edits = append(edits, *edit)
+				}
+			}
+			var err error
+			if text, err = applyInMemory(text, edits); err != nil {
+				return nil, fmt.Errorf("failed to update references in %s: %w", t.decl.Name.Name, err)
+			}
+		}
+		if _, ok := moved[t.target]; !ok {
+			targets = append(targets, t.target)
+		}
+		moved[t.target] = append(moved[t.target], text)
+		if _, ok := bySource[t.file]; !ok {
+			sources = append(sources, t.file)
+		}
+		bySource[t.file] = append(bySource[t.file], t)
+	}
+
+	for _, file := range sources {
+		changes = append(changes, removeTests(file, bySource[file])...)
+	}
+
+	for _, target := range targets {
+		text := strings.Join(moved[target], "\n\n")
+		if content, err := readSource(ws, target); err == nil {
+			changes = append(changes, types.Change{
+				File:        target,
+				Start:       len(content),
+				End:         len(content),
+				NewText:     "\n" + text + "\n",
+				Description: fmt.Sprintf("Move %d test function(s) to %s", len(moved[target]), target),
+			})
+			continue
+		}
+		pkgName := ""
+		for _, t := range tests {
+			if t.target == target {
+				pkgName = t.pkgName
+				break
+			}
+		}
+		changes = append(changes, types.Change{
+			File:        target,
+			NewText:     fmt.Sprintf("package %s\n\n%s\n", pkgName, text),
+			Description: fmt.Sprintf("Create %s with %d moved test function(s)", target, len(moved[target])),
+		})
+	}
+	return changes, nil
+}
+
+// removeTests returns the changes removing tests from file, with the blank
+// line before each, or deleting the file when nothing else is declared in it.
+func removeTests(file *types.File, tests []movedTest) []types.Change {
+	content := string(file.OriginalContent)
+	others := slices.ContainsFunc(file.AST.Decls, func(decl ast.Decl) bool {
+		if d, ok := decl.(*ast.GenDecl); ok && d.Tok == token.IMPORT {
+			return false
+		}
+		return !slices.ContainsFunc(tests, func(t movedTest) bool { return t.decl == decl })
+	})
+	if !others {
+		return []types.Change{{
+			File:        file.Path,
+			End:         len(content),
+			OldText:     content,
+			Description: fmt.Sprintf("Remove %s (all of its tests moved)", file.Path),
+		}}
+	}
+
+	var changes []types.Change
+	for _, t := range tests {
+		start, end := t.start, t.end
+		if end < len(content) && content[end] == '\n' {
+			end++
+		}
+		if strings.HasSuffix(content[:start], "\n\n") {
+			start--
+		}
+		changes = append(changes, types.Change{
+			File:        file.Path,
+			Start:       start,
+			End:         end,
+			OldText:     content[start:end],
+			Description: fmt.Sprintf("Remove test %s (moved to %s)", t.decl.Name.Name, t.target),
+		})
+	}
+	return changes
+}
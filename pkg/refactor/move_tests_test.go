@@ -0,0 +1,128 @@
+package refactor
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// loadTestModuleWith writes files over the test module and loads it.
+func loadTestModuleWith(t *testing.T, files map[string]string) (*DefaultEngine, *types.Workspace, string) {
+	t.Helper()
+	dir := writeTestModule(t)
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	engine := CreateEngineWithConfig(&EngineConfig{SkipCompilation: true}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ws, err := engine.LoadWorkspace(dir)
+	if err != nil {
+		t.Fatalf("LoadWorkspace: %v", err)
+	}
+	return engine.(*DefaultEngine), ws, dir
+}
+
+// planContent returns the content of file after plan's changes, "" for a
+// file the plan deletes or leaves missing.
+func planContent(t *testing.T, plan *types.RefactoringPlan, file string) string {
+	t.Helper()
+	src, _ := os.ReadFile(file)
+	return applyPlanTo(t, string(src), file, plan)
+}
+
+var moveTestsFiles = map[string]string{
+	"a/a.go":        "package a\n\nfunc A() {}\n\nfunc C() {}\n",
+	"a/a_test.go":   "package a\n\nimport \"testing\"\n\n// TestA checks A.\nfunc TestA(t *testing.T) { A() }\n\nfunc TestHelped(t *testing.T) { A(); helper() }\n\nfunc TestC(t *testing.T) { C() }\n\nfunc helper() {}\n",
+	"a/ext_test.go": "package a_test\n\nimport (\n\t\"testing\"\n\n\t\"example.com/p/a\"\n)\n\nfunc TestExtA(t *testing.T) { a.A() }\n",
+}
+
+func TestMoveSymbol_MoveTests(t *testing.T) {
+	engine, ws, dir := loadTestModuleWith(t, moveTestsFiles)
+	plan, err := engine.MoveSymbol(ws, types.MoveSymbolRequest{
+		SymbolName:  "A",
+		FromPackage: filepath.Join(dir, "a"),
+		ToPackage:   filepath.Join(dir, "b"),
+		MoveTests:   true,
+	})
+	if err != nil {
+		t.Fatalf("MoveSymbol: %v", err)
+	}
+
+	want := map[string]string{
+		"a/a_test.go":   "package a\n\nimport (\n\t\"testing\"\n\n\t\"example.com/p/b\"\n)\n\nfunc TestHelped(t *testing.T) { b.A(); helper() }\n\nfunc TestC(t *testing.T) { C() }\n\nfunc helper() {}\n",
+		"b/a_test.go":   "package b\n\nimport \"testing\"\n\n// TestA checks A.\nfunc TestA(t *testing.T) { A() }\n",
+		"a/ext_test.go": "",
+		"b/ext_test.go": "package b_test\n\nimport (\n\t\"testing\"\n\n\t\"example.com/p/b\"\n)\n\nfunc TestExtA(t *testing.T) { b.A() }\n",
+	}
+	for name, content := range want {
+		if got := planContent(t, plan, filepath.Join(dir, name)); got != content {
+			t.Errorf("%s:\ngot:\n%s\nwant:\n%s", name, got, content)
+		}
+	}
+}
+
+func TestMoveSymbol_KeepsTestsByDefault(t *testing.T) {
+	engine, ws, dir := loadTestModuleWith(t, moveTestsFiles)
+	plan, err := engine.MoveSymbol(ws, types.MoveSymbolRequest{
+		SymbolName:  "A",
+		FromPackage: filepath.Join(dir, "a"),
+		ToPackage:   filepath.Join(dir, "b"),
+	})
+	if err != nil {
+		t.Fatalf("MoveSymbol: %v", err)
+	}
+	for _, c := range plan.Changes {
+		if filepath.Dir(c.File) == filepath.Join(dir, "b") && filepath.Base(c.File) != "b.go" {
+			t.Errorf("unexpected change to %s", c.File)
+		}
+	}
+}
+
+func TestMovePackage_MoveTests(t *testing.T) {
+	engine, ws, dir := loadTestModuleWith(t, moveTestsFiles)
+	plan, err := engine.MovePackage(ws, types.MovePackageRequest{
+		SourcePackage: filepath.Join(dir, "a"),
+		TargetPackage: filepath.Join(dir, "c"),
+		MoveTests:     true,
+	})
+	if err != nil {
+		t.Fatalf("MovePackage: %v", err)
+	}
+
+	want := map[string]string{
+		"a/ext_test.go": "",
+		"c/ext_test.go": "package c_test\n\nimport (\n\t\"testing\"\n\n\t\"example.com/p/c\"\n)\n\nfunc TestExtA(t *testing.T) { c.A() }\n",
+		"c/a_test.go":   "package c\n\nimport \"testing\"\n\n// TestA checks A.\nfunc TestA(t *testing.T) { A() }\n\nfunc TestHelped(t *testing.T) { A(); helper() }\n\nfunc TestC(t *testing.T) { C() }\n\nfunc helper() {}\n",
+	}
+	for name, content := range want {
+		if got := planContent(t, plan, filepath.Join(dir, name)); got != content {
+			t.Errorf("%s:\ngot:\n%s\nwant:\n%s", name, got, content)
+		}
+	}
+}
+
+func TestRenamePackage_UpdatesTestFiles(t *testing.T) {
+	engine, ws, dir := loadTestModuleWith(t, moveTestsFiles)
+	plan, err := engine.RenamePackage(ws, types.RenamePackageRequest{
+		PackagePath:    filepath.Join(dir, "a"),
+		OldPackageName: "a",
+		NewPackageName: "z",
+	})
+	if err != nil {
+		t.Fatalf("RenamePackage: %v", err)
+	}
+	for name, clause := range map[string]string{"a/a_test.go": "package z\n", "a/ext_test.go": "package z_test\n"} {
+		if got := planContent(t, plan, filepath.Join(dir, name)); got[:len(clause)] != clause {
+			t.Errorf("%s: got package clause %q, want %q", name, got[:len(clause)], clause)
+		}
+	}
+}
@@ -3,13 +3,18 @@ package refactor
 import (
 	"fmt"
 	"go/ast"
+	"go/token"
+	gotypes "go/types"
 	"io"
 	"log/slog"
+	"maps"
 	"os"
 	"path/filepath"
 	"slices"
+	"strconv"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 
 	"github.com/mamaar/gorefactor/pkg/analysis"
 	"github.com/mamaar/gorefactor/pkg/types"
@@ -18,6 +23,8 @@ import (
 // MoveSymbolOperation implements moving symbols between packages
 type MoveSymbolOperation struct {
 	Request types.MoveSymbolRequest
+
+	refs *analysis.ReferenceFinder
 }
 
 func (op *MoveSymbolOperation) Type() types.OperationType {
@@ -62,7 +69,8 @@ func (op *MoveSymbolOperation) Validate(ws *types.Workspace) error {
 	}
 
 	// Find the symbol to move
-	resolver := analysis.NewSymbolResolver(ws, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	refs := op.referenceFinder(ws)
+	resolver := refs.Resolver()
 	symbol, err := resolver.ResolveSymbol(sourcePackage, op.Request.SymbolName)
 	if err != nil {
 		return &types.RefactorError{
@@ -107,14 +115,18 @@ func (op *MoveSymbolOperation) Validate(ws *types.Workspace) error {
 		}
 	}
 
+	references, err := refs.FindReferences(symbol)
+	if err != nil {
+		return err
+	}
+	tests := op.testsToMove(ws, symbol, references)
+
 	// Check that move won't break visibility rules
 	if !symbol.Exported && op.Request.FromPackage != op.Request.ToPackage {
-		references, err := resolver.FindReferences(symbol)
-		if err != nil {
-			return err
-		}
-
 		for _, ref := range references {
+			if inMovedTests(tests, ref) {
+				continue // Moves to the target package along with the symbol
+			}
 			refPackage := findPackageForFile(ws, ref.File)
 			if refPackage != nil && refPackage.Path != op.Request.ToPackage {
 				return &types.RefactorError{
@@ -127,11 +139,17 @@ func (op *MoveSymbolOperation) Validate(ws *types.Workspace) error {
 		}
 	}
 
-	// Check that move won't create import cycles
-	if wouldCreateImportCycle(ws, op.Request.FromPackage, op.Request.ToPackage) {
+	// Check that the packages still using the symbol can import the target
+	var kept []*types.Reference
+	for _, ref := range references {
+		if !inMovedTests(tests, ref) {
+			kept = append(kept, ref)
+		}
+	}
+	if pkg, ok := moveImportCycle(ws, op.Request.FromPackage, op.Request.ToPackage, symbol, kept); ok {
 		return &types.RefactorError{
 			Type:    types.CyclicDependency,
-			Message: fmt.Sprintf("moving symbol would create import cycle between %s and %s", op.Request.FromPackage, op.Request.ToPackage),
+			Message: fmt.Sprintf("moving symbol would create import cycle between %s and %s: %s still uses %s, and %s imports it", pkg, op.Request.ToPackage, pkg, op.Request.SymbolName, op.Request.ToPackage),
 		}
 	}
 
@@ -147,14 +165,15 @@ func (op *MoveSymbolOperation) Execute(ws *types.Workspace) (*types.RefactoringP
 
 	// Find the symbol to move
 	sourcePackage := ws.Packages[op.Request.FromPackage]
-	resolver := analysis.NewSymbolResolver(ws, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	refs := op.referenceFinder(ws)
+	resolver := refs.Resolver()
 	symbol, err := resolver.ResolveSymbol(sourcePackage, op.Request.SymbolName)
 	if err != nil {
 		return nil, err
 	}
 
 	// Find all references to the symbol
-	references, err := resolver.FindReferences(symbol)
+	references, err := refs.FindReferences(symbol)
 	if err != nil {
 		return nil, err
 	}
@@ -186,9 +205,30 @@ func (op *MoveSymbolOperation) Execute(ws *types.Workspace) (*types.RefactoringP
 		plan.AffectedFiles = append(plan.AffectedFiles, targetFile.Path)
 	}
 
+	// Move the tests exercising the symbol (if requested)
+	tests := op.testsToMove(ws, symbol, references)
+	if len(tests) > 0 {
+		testChanges, err := op.generateTestMoveChanges(ws, tests, references, targetPackage.Name)
+		if err != nil {
+			return nil, err
+		}
+		plan.Changes = append(plan.Changes, testChanges...)
+		for _, change := range testChanges {
+			if !contains(plan.AffectedFiles, change.File) {
+				plan.AffectedFiles = append(plan.AffectedFiles, change.File)
+			}
+		}
+	}
+
 	// Update all reference sites
 	// But skip references that are within the removal changes (since we're removing that code anyway)
+	var remaining []*types.Reference
 	for _, ref := range references {
+		if inMovedTests(tests, ref) {
+			continue // Updated by the test move
+		}
+		remaining = append(remaining, ref)
+
 		// Check if this reference is within a removal change
 		isWithinRemoval := false
 		if ref.File == sourceFile.Path {
@@ -205,7 +245,7 @@ func (op *MoveSymbolOperation) Execute(ws *types.Workspace) (*types.RefactoringP
 			continue
 		}
 
-		updateChange, err := op.generateReferenceUpdateChange(ref, op.Request.ToPackage, targetPackage.Name)
+		updateChange, err := op.generateReferenceUpdateChange(ws, ref, op.Request.ToPackage, targetPackage.Name)
 		if err != nil {
 			return nil, err
 		}
@@ -218,12 +258,20 @@ func (op *MoveSymbolOperation) Execute(ws *types.Workspace) (*types.RefactoringP
 	}
 
 	// Generate import statement changes
-	importChanges := op.generateImportChanges(ws, references, op.Request.ToPackage, targetPackage.Name)
+	importChanges := op.generateImportChanges(ws, remaining, op.Request.ToPackage, targetPackage.Name)
 	plan.Changes = append(plan.Changes, importChanges...)
 
 	return plan, nil
 }
 
+// referenceFinder returns the finder shared by Validate and Execute.
+func (op *MoveSymbolOperation) referenceFinder(ws *types.Workspace) *analysis.ReferenceFinder {
+	if op.refs == nil {
+		op.refs = newReferenceFinder(ws)
+	}
+	return op.refs
+}
+
 func (op *MoveSymbolOperation) Description() string {
 	return fmt.Sprintf("Move %s from %s to %s", op.Request.SymbolName, op.Request.FromPackage, op.Request.ToPackage)
 }
@@ -231,6 +279,8 @@ func (op *MoveSymbolOperation) Description() string {
 // RenameSymbolOperation implements symbol renaming
 type RenameSymbolOperation struct {
 	Request types.RenameSymbolRequest
+
+	refs *analysis.ReferenceFinder
 }
 
 func (op *RenameSymbolOperation) Type() types.OperationType {
@@ -258,6 +308,16 @@ func (op *RenameSymbolOperation) Validate(ws *types.Workspace) error {
 		if err := op.checkNameConflict(ws, symbol, op.Request.NewName); err != nil {
 			return err
 		}
+		if op.Request.RenameTests {
+			if err := checkLinkedTestConflicts(ws, linkedTests(ws, symbol, op.Request.NewName)); err != nil {
+				return err
+			}
+		}
+		if op.Request.KeepAlias {
+			if _, err := deprecatedAlias(ws, symbol, op.Request.NewName); err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
@@ -371,7 +431,8 @@ func (op *RenameSymbolOperation) Execute(ws *types.Workspace) (*types.Refactorin
 
 	// Find all symbols to rename
 	var targetSymbols []*types.Symbol
-	resolver := analysis.NewSymbolResolver(ws, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	refs := op.referenceFinder(ws)
+	resolver := refs.Resolver()
 
 	if op.Request.Package != "" {
 		// Package-scoped rename
@@ -396,13 +457,13 @@ func (op *RenameSymbolOperation) Execute(ws *types.Workspace) (*types.Refactorin
 	// Process each symbol
 	for _, symbol := range targetSymbols {
 		// Find all references to this symbol
-		references, err := resolver.FindReferences(symbol)
+		references, err := refs.FindReferences(symbol)
 		if err != nil {
 			return nil, err
 		}
 
 		// Update symbol definition
-		defChange := op.generateDefinitionRenameChange(symbol, op.Request.NewName)
+		defChange := op.generateDefinitionRenameChange(ws, symbol, op.Request.NewName)
 		plan.Changes = append(plan.Changes, defChange)
 		if !contains(plan.AffectedFiles, symbol.File) {
 			plan.AffectedFiles = append(plan.AffectedFiles, symbol.File)
@@ -410,17 +471,45 @@ func (op *RenameSymbolOperation) Execute(ws *types.Workspace) (*types.Refactorin
 
 		// Update all references
 		for _, ref := range references {
-			refChange := op.generateReferenceRenameChange(ref, op.Request.NewName)
+			refChange := op.generateReferenceRenameChange(ws, ref, op.Request.NewName)
 			plan.Changes = append(plan.Changes, refChange)
 			if !contains(plan.AffectedFiles, ref.File) {
 				plan.AffectedFiles = append(plan.AffectedFiles, ref.File)
 			}
 		}
+
+		// Rename the tests named after the symbol (if requested)
+		if op.Request.RenameTests {
+			tests := linkedTests(ws, symbol, op.Request.NewName)
+			for _, change := range generateLinkedTestChanges(ws, tests, symbol.Name, op.Request.NewName) {
+				plan.Changes = append(plan.Changes, change)
+				if !contains(plan.AffectedFiles, change.File) {
+					plan.AffectedFiles = append(plan.AffectedFiles, change.File)
+				}
+			}
+		}
+
+		// Keep the old name working for code outside the workspace (if requested)
+		if op.Request.KeepAlias {
+			alias, err := deprecatedAlias(ws, symbol, op.Request.NewName)
+			if err != nil {
+				return nil, err
+			}
+			plan.Changes = append(plan.Changes, alias)
+		}
 	}
 
 	return plan, nil
 }
 
+// referenceFinder returns the finder shared by Validate and Execute.
+func (op *RenameSymbolOperation) referenceFinder(ws *types.Workspace) *analysis.ReferenceFinder {
+	if op.refs == nil {
+		op.refs = newReferenceFinder(ws)
+	}
+	return op.refs
+}
+
 func (op *RenameSymbolOperation) Description() string {
 	return fmt.Sprintf("Rename %s to %s", op.Request.SymbolName, op.Request.NewName)
 }
@@ -490,9 +579,14 @@ func (op *RenamePackageOperation) Execute(ws *types.Workspace) (*types.Refactori
 	// Find the package to rename
 	targetPackage := ws.Packages[op.Request.PackagePath]
 
-	// Step 1: Update package declaration in all files within the package
-	for _, file := range targetPackage.Files {
-		change, err := op.generatePackageDeclarationChange(file, op.Request.OldPackageName, op.Request.NewPackageName)
+	// Step 1: Update package declaration in all files within the package,
+	// test files of the external _test package included
+	for _, file := range packageFiles(targetPackage) {
+		oldName, newName := op.Request.OldPackageName, op.Request.NewPackageName
+		if file.AST != nil && file.AST.Name.Name == oldName+"_test" {
+			oldName, newName = oldName+"_test", newName+"_test"
+		}
+		change, err := op.generatePackageDeclarationChange(file, oldName, newName)
 		if err != nil {
 			return nil, fmt.Errorf("failed to generate package declaration change for %s: %v", file.Path, err)
 		}
@@ -891,21 +985,35 @@ func (op *MoveSymbolOperation) generateSymbolAdditionChange(targetFile *types.Fi
 	return change, nil
 }
 
-func (op *MoveSymbolOperation) generateReferenceUpdateChange(ref *types.Reference, targetPackagePath, targetPackageName string) (*types.Change, error) {
-	// Update reference to use new package qualified name
-	// Skip references from the same package as the target
-	if strings.HasSuffix(ref.File, filepath.Join(targetPackagePath, "*.go")) {
-		return nil, nil // No change needed for references in the same package
-	}
-
+// generateReferenceUpdateChange rewrites a reference to the moved symbol for
+// its new package. References within the target package lose their package
+// qualifier; all others, those left in the source package included, are
+// qualified with the name their file imports the target package by.
+func (op *MoveSymbolOperation) generateReferenceUpdateChange(ws *types.Workspace, ref *types.Reference, targetPackagePath, targetPackageName string) (*types.Change, error) {
 	// Read the file content to detect if this is a qualified reference
-	content, err := os.ReadFile(ref.File)
+	content, err := readSource(ws, ref.File)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read file %s: %w", ref.File, err)
 	}
 
+	qualifier := targetPackageName
+	inTarget := filepath.Clean(filepath.Dir(ref.File)) == filepath.Clean(targetPackagePath)
+	if file := findFile(ws, ref.File); file != nil && file.AST != nil {
+		// An external test package in the target directory still imports it
+		inTarget = inTarget && file.AST.Name.Name == targetPackageName
+		if name, ok := importedName(file.AST, packagePathToImportPath(ws, targetPackagePath)); ok {
+			qualifier = name
+		}
+	}
+	if inTarget {
+		qualifier = ""
+	}
+
 	oldRef := ref.Symbol.Name
-	newRef := targetPackageName + "." + ref.Symbol.Name
+	newRef := ref.Symbol.Name
+	if qualifier != "" {
+		newRef = qualifier + "." + ref.Symbol.Name
+	}
 	startPos := ref.Offset
 	endPos := startPos + len(oldRef)
 
@@ -913,11 +1021,14 @@ func (op *MoveSymbolOperation) generateReferenceUpdateChange(ref *types.Referenc
 	// Look backwards for a dot and package name
 	if startPos > 0 && content[startPos-1] == '.' {
 		// Find the start of the package name
-		pkgStart := startPos - 2 // Position before the dot
-		for pkgStart >= 0 && (isIdentChar(content[pkgStart]) || content[pkgStart] == '_') {
-			pkgStart--
+		pkgStart := startPos - 1 // Position of the dot
+		for pkgStart > 0 {
+			r, size := utf8.DecodeLastRune(content[:pkgStart])
+			if !isIdentRune(r) {
+				break
+			}
+			pkgStart -= size
 		}
-		pkgStart++ // Move to first char of package name
 
 		// Extract the old package name
 		oldPkg := string(content[pkgStart : startPos-1])
@@ -925,9 +1036,11 @@ func (op *MoveSymbolOperation) generateReferenceUpdateChange(ref *types.Referenc
 			// This is a qualified reference - replace the whole thing
 			oldRef = oldPkg + "." + ref.Symbol.Name
 			startPos = pkgStart
-			// newRef stays as targetPackageName + "." + ref.Symbol.Name
 		}
 	}
+	if oldRef == newRef {
+		return nil, nil // Already refers to the symbol as the target package does
+	}
 
 	change := &types.Change{
 		File:        ref.File,
@@ -936,14 +1049,15 @@ func (op *MoveSymbolOperation) generateReferenceUpdateChange(ref *types.Referenc
 		OldText:     oldRef,
 		NewText:     newRef,
 		Description: fmt.Sprintf("Update reference to %s at line %d", ref.Symbol.Name, ref.Line),
+		Confidence:  ref.Confidence,
 	}
 
 	return change, nil
 }
 
-// isIdentChar returns true if the byte is a valid Go identifier character
-func isIdentChar(b byte) bool {
-	return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9') || b == '_'
+// isIdentRune returns true if r may appear in a Go identifier
+func isIdentRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
 }
 
 func (op *MoveSymbolOperation) generateImportChanges(ws *types.Workspace, references []*types.Reference, targetPackagePath, targetPackageName string) []types.Change {
@@ -1052,8 +1166,8 @@ func (op *RenameSymbolOperation) checkNameConflict(ws *types.Workspace, symbol *
 	return nil
 }
 
-func (op *RenameSymbolOperation) generateDefinitionRenameChange(symbol *types.Symbol, newName string) types.Change {
-	start := calculateByteOffset(symbol.File, symbol.Line, symbol.Column)
+func (op *RenameSymbolOperation) generateDefinitionRenameChange(ws *types.Workspace, symbol *types.Symbol, newName string) types.Change {
+	start := calculateByteOffset(ws, symbol.File, symbol.Line, symbol.Column)
 	return types.Change{
 		File:        symbol.File,
 		Start:       start,
@@ -1064,8 +1178,8 @@ func (op *RenameSymbolOperation) generateDefinitionRenameChange(symbol *types.Sy
 	}
 }
 
-func (op *RenameSymbolOperation) generateReferenceRenameChange(ref *types.Reference, newName string) types.Change {
-	start := calculateByteOffset(ref.File, ref.Line, ref.Column)
+func (op *RenameSymbolOperation) generateReferenceRenameChange(ws *types.Workspace, ref *types.Reference, newName string) types.Change {
+	start := calculateByteOffset(ws, ref.File, ref.Line, ref.Column)
 	return types.Change{
 		File:        ref.File,
 		Start:       start,
@@ -1073,6 +1187,7 @@ func (op *RenameSymbolOperation) generateReferenceRenameChange(ref *types.Refere
 		OldText:     ref.Symbol.Name,
 		NewText:     newName,
 		Description: fmt.Sprintf("Rename reference to %s", newName),
+		Confidence:  ref.Confidence,
 	}
 }
 
@@ -1098,36 +1213,190 @@ func findPackageForFile(ws *types.Workspace, filePath string) *types.Package {
 	return nil
 }
 
+// filePackage returns the workspace file at filePath, test files included,
+// and the package it is in.
+func filePackage(ws *types.Workspace, filePath string) (*types.Package, *types.File) {
+	for _, pkg := range ws.Packages {
+		for _, file := range packageFiles(pkg) {
+			if file.Path == filePath {
+				return pkg, file
+			}
+		}
+	}
+	return nil, nil
+}
+
+// findFile returns the workspace file at filePath, test files included.
+func findFile(ws *types.Workspace, filePath string) *types.File {
+	for _, pkg := range ws.Packages {
+		for _, file := range packageFiles(pkg) {
+			if file.Path == filePath {
+				return file
+			}
+		}
+	}
+	return nil
+}
+
+// importedName returns the name file refers to the package at importPath
+// by: its alias, or "" for a dot import. ok is false when file doesn't name
+// the import itself, leaving the package name to the caller.
+func importedName(file *ast.File, importPath string) (name string, ok bool) {
+	for _, spec := range file.Imports {
+		if spec.Name == nil || spec.Name.Name == "_" {
+			continue
+		}
+		if path, err := strconv.Unquote(spec.Path.Value); err == nil && path == importPath {
+			if spec.Name.Name == "." {
+				return "", true
+			}
+			return spec.Name.Name, true
+		}
+	}
+	return "", false
+}
+
+// wouldCreateImportCycle reports whether fromPkg importing toPkg, as it
+// does once a symbol it still uses moves there, would be a cycle: whether
+// toPkg imports fromPkg already, directly or through other packages.
 func wouldCreateImportCycle(ws *types.Workspace, fromPkg, toPkg string) bool {
-	if ws.Dependencies == nil {
-		return false
+	return importsTransitively(ws, toPkg, fromPkg, nil)
+}
+
+// importsTransitively reports whether the package at from imports the one
+// at to, directly or through other workspace packages, both named by their
+// Package.Path. The imports are read from the files as they are rather than
+// from ws.Dependencies, which may predate them. The in-package tests of
+// from count, as importing to from them is a cycle too. Imports dropped
+// reports true for are left out, dropped being nil for none.
+func importsTransitively(ws *types.Workspace, from, to string, dropped func(file *types.File, spec *ast.ImportSpec, dep string) bool) bool {
+	seen := map[string]bool{from: true}
+	queue := []string{from}
+	for len(queue) > 0 {
+		pkg := ws.Packages[queue[0]]
+		queue = queue[1:]
+		if pkg == nil {
+			continue
+		}
+		files := slices.Collect(maps.Values(pkg.Files))
+		if pkg.Path == from {
+			files = packageFiles(pkg)
+		}
+		for _, file := range files {
+			if file.AST == nil || file.AST.Name.Name != pkg.Name {
+				continue // External tests can't be imported
+			}
+			for _, spec := range file.AST.Imports {
+				importPath, err := strconv.Unquote(spec.Path.Value)
+				if err != nil {
+					continue
+				}
+				dep, ok := ws.ImportToPath[importPath]
+				if !ok || seen[dep] || dropped != nil && dropped(file, spec, dep) {
+					continue
+				}
+				if dep == to {
+					return true
+				}
+				seen[dep] = true
+				queue = append(queue, dep)
+			}
+		}
 	}
+	return false
+}
 
-	// Check if toPkg already depends on fromPkg
-	toDeps, exists := ws.Dependencies.PackageDeps[toPkg]
-	if !exists {
+// moveImportCycle returns the package that would import itself through
+// toPkg if the symbol moved there from fromPkg: one that keeps references to
+// the symbol, and so imports toPkg after the move, while toPkg imports it
+// already. References inside the declarations moving with the symbol don't
+// count. ok is false when the move creates no cycle.
+func moveImportCycle(ws *types.Workspace, fromPkg, toPkg string, symbol *types.Symbol, references []*types.Reference) (pkg string, ok bool) {
+	moved := movedDeclarations(ws, fromPkg, symbol)
+	// The target stops importing the source if it only used the symbol
+	dropped := func(file *types.File, spec *ast.ImportSpec, dep string) bool {
+		return dep == fromPkg && filepath.Dir(file.Path) == toPkg && onlySelects(file.AST, spec, ws.Packages[fromPkg].Name, symbol.Name)
+	}
+	checked := make(map[string]bool)
+	for _, ref := range references {
+		if ref.File == symbol.File && slices.ContainsFunc(moved, func(r [2]int) bool { return ref.Offset >= r[0] && ref.Offset < r[1] }) {
+			continue
+		}
+		refPkg, file := filePackage(ws, ref.File)
+		if refPkg == nil || file.AST == nil || file.AST.Name.Name != refPkg.Name {
+			continue // External tests can't be imported, so can't be part of a cycle
+		}
+		if refPkg.Path == toPkg || checked[refPkg.Path] {
+			continue
+		}
+		checked[refPkg.Path] = true
+		if importsTransitively(ws, toPkg, refPkg.Path, dropped) {
+			return refPkg.Path, true
+		}
+	}
+	return "", false
+}
+
+// onlySelects reports whether the only thing file uses of the package
+// spec imports, by the name pkgName unless spec renames it, is name.
+func onlySelects(file *ast.File, spec *ast.ImportSpec, pkgName, name string) bool {
+	if spec.Name != nil {
+		pkgName = spec.Name.Name
+	}
+	if pkgName == "." || pkgName == "_" {
 		return false
 	}
+	only := true
+	ast.Inspect(file, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if x, ok := sel.X.(*ast.Ident); ok && x.Name == pkgName && x.Obj == nil && sel.Sel.Name != name {
+				only = false
+			}
+		}
+		return only
+	})
+	return only
+}
 
-	return slices.Contains(toDeps, fromPkg)
+// movedDeclarations returns the byte ranges, in the file declaring symbol,
+// of the declarations that move with it: its own and, for a type, those of
+// its methods.
+func movedDeclarations(ws *types.Workspace, fromPkg string, symbol *types.Symbol) [][2]int {
+	pkg := ws.Packages[fromPkg]
+	if pkg == nil {
+		return nil
+	}
+	file := findFileContainingSymbol(pkg, symbol)
+	if file == nil || file.AST == nil {
+		return nil
+	}
+	var ranges [][2]int
+	for _, decl := range file.AST.Decls {
+		moves := decl.Pos() <= symbol.Position && symbol.Position < decl.End()
+		if fn, ok := decl.(*ast.FuncDecl); ok && symbol.Kind == types.TypeSymbol && receiverTypeName(fn) == symbol.Name {
+			moves = true
+		}
+		if moves {
+			ranges = append(ranges, [2]int{ws.FileSet.Position(decl.Pos()).Offset, ws.FileSet.Position(decl.End()).Offset})
+		}
+	}
+	return ranges
 }
 
+// isValidGoIdentifier reports whether name is a syntactically valid Go
+// identifier. Letters and digits are Unicode letters and digits, as in the
+// language spec; keywords are not rejected here.
 func isValidGoIdentifier(name string) bool {
 	if len(name) == 0 {
 		return false
 	}
 
-	// First character must be letter or underscore
-	first := rune(name[0])
-	if !unicode.IsLetter(first) && first != '_' {
-		return false
-	}
-
-	// Remaining characters must be letters, digits, or underscores
-	for _, r := range name[1:] {
-		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
-			return false
+	for i, r := range name {
+		// First character must be letter or underscore; the rest may also be digits
+		if unicode.IsLetter(r) || r == '_' || (i > 0 && unicode.IsDigit(r)) {
+			continue
 		}
+		return false
 	}
 
 	return true
@@ -1150,12 +1419,12 @@ func contains(slice []string, item string) bool {
 func generateAddImportChange(ws *types.Workspace, filePath string, importPath string) *types.Change {
 	// Find the file and its AST
 	for _, pkg := range ws.Packages {
-		for _, file := range pkg.Files {
+		for _, file := range packageFiles(pkg) {
 			if file.Path == filePath && file.AST != nil {
 				// Check if we have imports and if they're in single-line or multi-line format
 				if len(file.AST.Imports) > 0 {
 					// Read file content to check import format
-					content, err := os.ReadFile(filePath)
+					content, err := readSource(ws, filePath)
 					if err != nil {
 						return nil
 					}
@@ -1171,7 +1440,7 @@ func generateAddImportChange(ws *types.Workspace, filePath string, importPath st
 						lastImport := file.AST.Imports[len(file.AST.Imports)-1]
 						if ws.FileSet != nil {
 							pos := ws.FileSet.Position(lastImport.End())
-							byteOffset := calculateByteOffset(filePath, pos.Line, pos.Column)
+							byteOffset := calculateByteOffset(ws, filePath, pos.Line, pos.Column)
 
 							// Find the next newline after the import
 							for i := byteOffset; i < len(content); i++ {
@@ -1191,9 +1460,9 @@ func generateAddImportChange(ws *types.Workspace, filePath string, importPath st
 				} else if file.AST.Name != nil && ws.FileSet != nil {
 					// No imports exist, add new import block after package declaration
 					pos := ws.FileSet.Position(file.AST.Name.End())
-					byteOffset := calculateByteOffset(filePath, pos.Line, pos.Column)
+					byteOffset := calculateByteOffset(ws, filePath, pos.Line, pos.Column)
 
-					content, err := os.ReadFile(filePath)
+					content, err := readSource(ws, filePath)
 					if err != nil {
 						return nil
 					}
@@ -1218,6 +1487,12 @@ func generateAddImportChange(ws *types.Workspace, filePath string, importPath st
 	return nil
 }
 
+// packageFiles returns the files of pkg, test files included.
+func packageFiles(pkg *types.Package) []*types.File {
+	files := slices.Collect(maps.Values(pkg.Files))
+	return append(files, slices.Collect(maps.Values(pkg.TestFiles))...)
+}
+
 // isSingleLineImport checks if imports are in single-line format (no parentheses)
 func isSingleLineImport(astFile *ast.File, content []byte) bool {
 	if len(astFile.Imports) == 0 {
@@ -1503,8 +1778,8 @@ func packagePathToImportPath(ws *types.Workspace, packagePath string) string {
 }
 
 // calculateByteOffset calculates the byte offset in a file from line and column numbers
-func calculateByteOffset(filePath string, line, column int) int {
-	content, err := os.ReadFile(filePath)
+func calculateByteOffset(ws *types.Workspace, filePath string, line, column int) int {
+	content, err := readSource(ws, filePath)
 	if err != nil {
 		return 0 // Return 0 if we can't read the file
 	}
@@ -1653,13 +1928,9 @@ func (op *RenamePackageOperation) generateImportUpdates(ws *types.Workspace, pac
 	// Get the import path for this package
 	importPath := packagePathToImportPath(ws, packagePath)
 
-	// Find all files that import this package
+	// Find all files that import this package, its external tests included
 	for _, pkg := range ws.Packages {
-		if pkg.Path == packagePath {
-			continue // Skip the package itself
-		}
-
-		for _, file := range pkg.Files {
+		for _, file := range packageFiles(pkg) {
 			hasImport, fileChanges, err := op.generateFileImportUpdate(file, importPath, newPackageName)
 			if err != nil {
 				return nil, nil, fmt.Errorf("failed to update imports in %s: %v", file.Path, err)
@@ -2219,6 +2490,7 @@ func (op *RenameInterfaceMethodOperation) generateFileMethodCallChanges(file *ty
 						OldText:     op.Request.MethodName,
 						NewText:     op.Request.NewMethodName,
 						Description: fmt.Sprintf("Rename method call %s to %s", op.Request.MethodName, op.Request.NewMethodName),
+						Confidence:  types.ConfidenceHeuristic, // Any call of a method with this name
 					}
 					changes = append(changes, change)
 				}
@@ -2231,12 +2503,13 @@ func (op *RenameInterfaceMethodOperation) generateFileMethodCallChanges(file *ty
 }
 
 func (op *RenameInterfaceMethodOperation) isExported(name string) bool {
-	return len(name) > 0 && unicode.IsUpper(rune(name[0]))
+	return token.IsExported(name)
 }
 
 // RenameMethodOperation implements renaming methods on specific types (structs or interfaces)
 type RenameMethodOperation struct {
 	Request types.RenameMethodRequest
+	Parser  *analysis.GoParser // Type-checks packages on demand; may be nil if they already are
 }
 
 func (op *RenameMethodOperation) Type() types.OperationType {
@@ -2278,7 +2551,8 @@ func (op *RenameMethodOperation) Validate(ws *types.Workspace) error {
 		return err
 	}
 
-	return nil
+	// Check that the new name doesn't clash where the method is promoted
+	return op.checkPromotionConflicts(ws, typeSymbol)
 }
 
 func (op *RenameMethodOperation) Execute(ws *types.Workspace) (*types.RefactoringPlan, error) {
@@ -2448,6 +2722,83 @@ func (op *RenameMethodOperation) checkMethodNameConflict(ws *types.Workspace, ty
 	return nil
 }
 
+// checkPromotionConflicts makes sure that in the types the method is promoted
+// into, and in its own type, no field or method of the new name sits at the
+// same embedding depth, which would make selectors ambiguous, or at another
+// depth, where one would hide the other and calls would change target.
+func (op *RenameMethodOperation) checkPromotionConflicts(ws *types.Workspace, typeSymbol *types.Symbol) error {
+	op.typeCheck(ws, typeSymbol.Name)
+	pkg := resolveSymbolPackage(ws, typeSymbol)
+	if pkg == nil || pkg.TypesPkg == nil {
+		return nil
+	}
+	typeName, _ := pkg.TypesPkg.Scope().Lookup(typeSymbol.Name).(*gotypes.TypeName)
+	if typeName == nil {
+		return nil
+	}
+	method, _, _ := gotypes.LookupFieldOrMethod(typeName.Type(), true, pkg.TypesPkg, op.Request.MethodName)
+	if method == nil {
+		return nil
+	}
+
+	newName := op.Request.NewMethodName
+	for _, pkgPath := range slices.Sorted(maps.Keys(ws.Packages)) {
+		other := ws.Packages[pkgPath]
+		if other.TypesPkg == nil {
+			continue
+		}
+		scope := other.TypesPkg.Scope()
+		for _, name := range scope.Names() {
+			outer, ok := scope.Lookup(name).(*gotypes.TypeName)
+			if !ok || outer.IsAlias() {
+				continue
+			}
+			obj, index, _ := gotypes.LookupFieldOrMethod(outer.Type(), true, outer.Pkg(), op.Request.MethodName)
+			if fn, ok := obj.(*gotypes.Func); !ok || fn.Origin() != method {
+				continue
+			}
+			_, clash, _ := gotypes.LookupFieldOrMethod(outer.Type(), true, outer.Pkg(), newName)
+			if clash == nil {
+				continue
+			}
+
+			var msg string
+			switch {
+			case len(clash) < len(index):
+				msg = fmt.Sprintf("%s embeds %s but has its own %s, which would hide the renamed method", outer.Name(), typeSymbol.Name, newName)
+			case len(clash) > len(index):
+				msg = fmt.Sprintf("%s has %s from a more deeply embedded type, which the renamed method would hide", outer.Name(), newName)
+			case outer == typeName:
+				msg = fmt.Sprintf("type %s has a field %s", typeSymbol.Name, newName)
+			default:
+				msg = fmt.Sprintf("%s embeds %s next to another %s, which would make %s.%s ambiguous", outer.Name(), typeSymbol.Name, newName, outer.Name(), newName)
+			}
+			return &types.RefactorError{
+				Type:    types.NameConflict,
+				Message: msg,
+				File:    ws.FileSet.Position(outer.Pos()).Filename,
+				Line:    ws.FileSet.Position(outer.Pos()).Line,
+			}
+		}
+	}
+	return nil
+}
+
+// typeCheck type-checks the packages with files mentioning one of names.
+func (op *RenameMethodOperation) typeCheck(ws *types.Workspace, names ...string) {
+	if op.Parser == nil {
+		return
+	}
+	for _, pkg := range ws.Packages {
+		for _, file := range pkg.Files {
+			if file.AST != nil && mentionsName(file.AST, names...) {
+				op.Parser.EnsureTypeChecked(ws, pkg)
+				break
+			}
+		}
+	}
+}
+
 func (op *RenameMethodOperation) generateMethodDefinitionChange(ws *types.Workspace, typeSymbol *types.Symbol, methodSymbol *types.Symbol) (*types.Change, error) {
 	pkg := resolveSymbolPackage(ws, typeSymbol)
 	if pkg == nil {
@@ -2467,7 +2818,7 @@ func (op *RenameMethodOperation) generateMethodDefinitionChange(ws *types.Worksp
 	}
 
 	// Calculate the byte position for the method name change
-	startByte := int(methodSymbol.Position) - 1 // Convert to 0-based
+	startByte := ws.FileSet.Position(methodSymbol.Position).Offset
 	endByte := startByte + len(op.Request.MethodName)
 
 	return &types.Change{
@@ -2481,6 +2832,16 @@ func (op *RenameMethodOperation) generateMethodDefinitionChange(ws *types.Worksp
 }
 
 func (op *RenameMethodOperation) generateMethodReferenceChanges(ws *types.Workspace, typeSymbol *types.Symbol, methodSymbol *types.Symbol) ([]types.Change, error) {
+	// With type information the references are resolved exactly, including
+	// calls promoted through embedding types (outer.Method()); interface
+	// methods, renamed on their implementations too, fall back on names
+	if typeSymbol.Kind != types.InterfaceSymbol {
+		op.typeCheck(ws, op.Request.MethodName)
+		if pkg := resolveSymbolPackage(ws, typeSymbol); pkg != nil && pkg.TypesPkg != nil {
+			return op.resolvedReferenceChanges(ws, methodSymbol)
+		}
+	}
+
 	var changes []types.Change
 
 	// Find all method calls and references across the workspace
@@ -2498,8 +2859,7 @@ func (op *RenameMethodOperation) generateMethodReferenceChanges(ws *types.Worksp
 							// This is a potential method call - we need to verify it's on our type
 							// For now, we'll create the change (a more sophisticated implementation
 							// would verify the receiver type)
-							pos := selExpr.Sel.Pos()
-							startByte := int(pos) - 1
+							startByte := ws.FileSet.Position(selExpr.Sel.Pos()).Offset
 							endByte := startByte + len(op.Request.MethodName)
 
 							change := types.Change{
@@ -2509,6 +2869,7 @@ func (op *RenameMethodOperation) generateMethodReferenceChanges(ws *types.Worksp
 								OldText:     op.Request.MethodName,
 								NewText:     op.Request.NewMethodName,
 								Description: fmt.Sprintf("Rename method call %s to %s", op.Request.MethodName, op.Request.NewMethodName),
+								Confidence:  types.ConfidenceHeuristic, // Any call of a method with this name
 							}
 							changes = append(changes, change)
 						}
@@ -2522,6 +2883,29 @@ func (op *RenameMethodOperation) generateMethodReferenceChanges(ws *types.Worksp
 	return changes, nil
 }
 
+// resolvedReferenceChanges renames the references the reference index
+// resolves to the method.
+func (op *RenameMethodOperation) resolvedReferenceChanges(ws *types.Workspace, methodSymbol *types.Symbol) ([]types.Change, error) {
+	resolver := analysis.NewSymbolResolver(ws, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	refs, err := resolver.FindReferencesIndexedFiltered(methodSymbol, resolver.BuildReferenceIndexFor([]*types.Symbol{methodSymbol}, nil), nil)
+	if err != nil {
+		return nil, err
+	}
+	var changes []types.Change
+	for _, ref := range refs {
+		changes = append(changes, types.Change{
+			File:        ref.File,
+			Start:       ref.Offset,
+			End:         ref.Offset + len(op.Request.MethodName),
+			OldText:     op.Request.MethodName,
+			NewText:     op.Request.NewMethodName,
+			Description: fmt.Sprintf("Rename method reference %s to %s", op.Request.MethodName, op.Request.NewMethodName),
+			Confidence:  ref.Confidence,
+		})
+	}
+	return changes, nil
+}
+
 func (op *RenameMethodOperation) generateImplementationChanges(ws *types.Workspace, interfaceSymbol *types.Symbol, methodSymbol *types.Symbol) ([]types.Change, error) {
 	var changes []types.Change
 
@@ -2555,3 +2939,15 @@ func (op *RenameMethodOperation) generateImplementationChanges(ws *types.Workspa
 
 	return changes, nil
 }
+
+// newReferenceFinder creates a reference finder for a single plan computation.
+func newReferenceFinder(ws *types.Workspace) *analysis.ReferenceFinder {
+	return newReferenceFinderWith(ws, nil)
+}
+
+// newReferenceFinderWith is like newReferenceFinder but finds references in
+// idx, an index of ws, unless it is nil.
+func newReferenceFinderWith(ws *types.Workspace, idx *analysis.ReferenceIndex) *analysis.ReferenceFinder {
+	resolver := analysis.NewSymbolResolver(ws, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	return analysis.NewReferenceFinder(resolver, idx)
+}
@@ -0,0 +1,133 @@
+package refactor
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+func TestMoveSymbol_RequalifiesReferences(t *testing.T) {
+	engine, ws, dir := loadTestModuleWith(t, map[string]string{
+		"a/a.go": "package a\n\nfunc A() {}\n\nfunc C() { A() }\n",
+		"b/b.go": "package b\n\nimport \"example.com/p/a\"\n\nfunc B() {}\n\nfunc D() { a.A() }\n",
+		"c/c.go": "package c\n\nimport (\n\t\"example.com/p/a\"\n\tbee \"example.com/p/b\"\n)\n\nfunc E() { a.A(); bee.B() }\n",
+	})
+	plan, err := engine.MoveSymbol(ws, types.MoveSymbolRequest{
+		SymbolName:  "A",
+		FromPackage: filepath.Join(dir, "a"),
+		ToPackage:   filepath.Join(dir, "b"),
+	})
+	if err != nil {
+		t.Fatalf("MoveSymbol: %v", err)
+	}
+
+	want := map[string]string{
+		// Left in the source package: qualified, with the import added
+		"a/a.go": "package a\n\nimport \"example.com/p/b\"\n\n\nfunc C() { b.A() }\n",
+		// In the target package: unqualified, with the unused import removed
+		"b/b.go": "package b\n\n\nfunc B() {}\n\nfunc D() { A() }\n\n// A was moved from " + filepath.Join(dir, "a") + "\nfunc A() {}\n",
+		// Elsewhere: qualified by the file's alias for the target
+		"c/c.go": "package c\n\nimport bee \"example.com/p/b\"\n\nfunc E() { bee.A(); bee.B() }\n",
+	}
+	for name, content := range want {
+		if got := planContent(t, plan, filepath.Join(dir, name)); got != content {
+			t.Errorf("%s:\ngot:\n%s\nwant:\n%s", name, got, content)
+		}
+	}
+}
+
+func TestRenameMethod_PromotedReferences(t *testing.T) {
+	engine, ws, dir := loadTestModuleWith(t, map[string]string{
+		"a/a.go": "package a\n\ntype Inner struct{}\n\nfunc (Inner) Run() {}\n\ntype Other struct{}\n\nfunc (Other) Run() {}\n",
+		"b/b.go": "package b\n\nimport \"example.com/p/a\"\n\ntype Outer struct {\n\t*a.Inner\n}\n\nfunc B(o Outer, x a.Other) {\n\to.Run()\n\tf := o.Inner.Run\n\tf()\n\tx.Run()\n}\n",
+	})
+	plan, err := engine.RenameMethod(ws, types.RenameMethodRequest{
+		TypeName:      "Inner",
+		MethodName:    "Run",
+		NewMethodName: "Start",
+	})
+	if err != nil {
+		t.Fatalf("RenameMethod: %v", err)
+	}
+
+	want := map[string]string{
+		"a/a.go": "package a\n\ntype Inner struct{}\n\nfunc (Inner) Start() {}\n\ntype Other struct{}\n\nfunc (Other) Run() {}\n",
+		"b/b.go": "package b\n\nimport \"example.com/p/a\"\n\ntype Outer struct {\n\t*a.Inner\n}\n\nfunc B(o Outer, x a.Other) {\n\to.Start()\n\tf := o.Inner.Start\n\tf()\n\tx.Run()\n}\n",
+	}
+	for name, content := range want {
+		if got := planContent(t, plan, filepath.Join(dir, name)); got != content {
+			t.Errorf("%s:\ngot:\n%s\nwant:\n%s", name, got, content)
+		}
+	}
+}
+
+func TestRenameMethod_PromotionConflicts(t *testing.T) {
+	tests := []struct {
+		name  string
+		outer string
+		want  string
+	}{
+		{
+			name:  "own method hides",
+			outer: "type Outer struct{ Inner }\n\nfunc (Outer) Start() {}\n",
+			want:  "Outer embeds Inner but has its own Start, which would hide the renamed method",
+		},
+		{
+			name:  "same depth",
+			outer: "type Engine struct{}\n\nfunc (Engine) Start() {}\n\ntype Outer struct {\n\tInner\n\tEngine\n}\n",
+			want:  "Outer embeds Inner next to another Start, which would make Outer.Start ambiguous",
+		},
+		{
+			name:  "hides deeper",
+			outer: "type Engine struct{}\n\nfunc (Engine) Start() {}\n\ntype Car struct{ Engine }\n\ntype Outer struct {\n\tInner\n\tCar\n}\n",
+			want:  "Outer has Start from a more deeply embedded type, which the renamed method would hide",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine, ws, _ := loadTestModuleWith(t, map[string]string{
+				"a/a.go": "package a\n\ntype Inner struct{}\n\nfunc (Inner) Run() {}\n\n" + tt.outer,
+			})
+			_, err := engine.RenameMethod(ws, types.RenameMethodRequest{
+				TypeName:      "Inner",
+				MethodName:    "Run",
+				NewMethodName: "Start",
+			})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoveSymbol_RefusesImportCycle(t *testing.T) {
+	files := map[string]string{
+		"a/a.go": "package a\n\ntype Point struct{ X int }\n\nfunc Helper() {}\n\nfunc Use() { Helper() }\n",
+		// Imports a for Point, which stays there
+		"b/b.go": "package b\n\nimport \"example.com/p/a\"\n\nfunc Origin() a.Point { return a.Point{} }\n",
+		// Imports a through b
+		"c/c.go": "package c\n\nimport \"example.com/p/b\"\n\nvar O = b.Origin()\n",
+	}
+	for _, tt := range []struct {
+		name, symbol, to string
+		wantCycle        bool
+	}{
+		{"direct", "Helper", "b", true},
+		{"transitive", "Helper", "c", true},
+		{"source stops using it", "Point", "b", false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			engine, ws, dir := loadTestModuleWith(t, files)
+			_, err := engine.MoveSymbol(ws, types.MoveSymbolRequest{
+				SymbolName:  tt.symbol,
+				FromPackage: filepath.Join(dir, "a"),
+				ToPackage:   filepath.Join(dir, tt.to),
+			})
+			if got := types.CodeOf(err) == types.CodeImportCycle; got != tt.wantCycle {
+				t.Errorf("MoveSymbol: %v, want an import cycle: %v", err, tt.wantCycle)
+			}
+		})
+	}
+}
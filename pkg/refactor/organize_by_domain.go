@@ -0,0 +1,665 @@
+package refactor
+
+import (
+	"cmp"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	gotypes "go/types"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mamaar/gorefactor/pkg/analysis"
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// OrganizeByDomainOperation regroups the declarations of a package into one
+// file per domain. The symbols a domain names move to <domain>.go, a type
+// with its methods and the functions constructing it (NewT, NewTFrom...),
+// and the tests, benchmarks, fuzz tests and examples about them, as
+// testSubject tells, to <domain>_test.go in their own test package.
+// Declarations no domain names stay where they are; files left without
+// declarations or a package comment are removed.
+type OrganizeByDomainOperation struct {
+	Request types.OrganizeByDomainRequest
+	Parser  *analysis.GoParser // Type-checks the tests; without it their imports are matched by name
+
+	// Resolved by Validate
+	pkg     *types.Package
+	domains map[string]string // Package-level name -> domain
+	named   []string          // Types the domains list, longest first
+	moves   []domainMove
+}
+
+// domainMove is a declaration leaving its file for the file of its domain.
+type domainMove struct {
+	file *types.File
+	decl ast.Decl
+	to   string // Base name of the destination file
+}
+
+func (op *OrganizeByDomainOperation) Type() types.OperationType {
+	return types.OrganizeByDomainOperation
+}
+
+func (op *OrganizeByDomainOperation) Description() string {
+	return fmt.Sprintf("Organize %s by domain", op.Request.Package)
+}
+
+func (op *OrganizeByDomainOperation) Validate(ws *types.Workspace) error {
+	req := op.Request
+	pkg, ok := ws.Packages[req.Package]
+	if !ok {
+		return &types.RefactorError{
+			Type:    types.SymbolNotFound,
+			Message: fmt.Sprintf("package not found: %s", req.Package),
+		}
+	}
+	if len(req.Domains) == 0 {
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: "no domains given",
+		}
+	}
+	op.pkg = pkg
+
+	declared := make(map[string]bool)
+	typeNames := make(map[string]bool)
+	for _, file := range sortedFiles(pkg.Files) {
+		for _, decl := range file.AST.Decls {
+			for _, name := range declaredNames(decl) {
+				declared[name] = true
+			}
+			if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.TYPE {
+				for _, spec := range gen.Specs {
+					typeNames[spec.(*ast.TypeSpec).Name.Name] = true
+				}
+			}
+		}
+	}
+
+	op.domains = make(map[string]string)
+	op.named = nil
+	for _, d := range req.Domains {
+		if err := checkDomainName(d.Name); err != nil {
+			return err
+		}
+		if len(d.Symbols) == 0 {
+			return &types.RefactorError{
+				Type:    types.InvalidOperation,
+				Message: fmt.Sprintf("domain %s names no symbols", d.Name),
+			}
+		}
+		for _, symbol := range d.Symbols {
+			if !declared[symbol] {
+				return &types.RefactorError{
+					Type:    types.SymbolNotFound,
+					Message: fmt.Sprintf("%s is not declared in %s", symbol, pkg.ImportPath),
+				}
+			}
+			if other, dup := op.domains[symbol]; dup {
+				return &types.RefactorError{
+					Type:    types.InvalidOperation,
+					Message: fmt.Sprintf("%s is listed in domains %s and %s", symbol, other, d.Name),
+				}
+			}
+			op.domains[symbol] = d.Name
+			if typeNames[symbol] {
+				op.named = append(op.named, symbol)
+			}
+		}
+	}
+	slices.SortFunc(op.named, func(a, b string) int { return cmp.Compare(len(b), len(a)) })
+
+	moves, err := op.plannedMoves(declared)
+	if err != nil {
+		return err
+	}
+	if len(moves) == 0 {
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("the declarations of %s are already in the files of their domains", pkg.ImportPath),
+		}
+	}
+	op.moves = moves
+	return nil
+}
+
+// checkDomainName reports why name can't name the file of a domain: go
+// build would ignore it or only build it for some platforms.
+func checkDomainName(name string) error {
+	valid := name != "" && !strings.HasPrefix(name, "_") && !strings.HasSuffix(name, "_test")
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '-' {
+			valid = false
+		}
+	}
+	if goos, goarch := fileSuffix(name); goos != "" || goarch != "" {
+		valid = false
+	}
+	if !valid {
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("%q can't name a domain's file", name),
+		}
+	}
+	return nil
+}
+
+// plannedMoves returns the declarations to move, in file and declaration
+// order: those of the domains, then the test functions about them.
+func (op *OrganizeByDomainOperation) plannedMoves(declared map[string]bool) ([]domainMove, error) {
+	var moves []domainMove
+	for _, file := range sortedFiles(op.pkg.Files) {
+		for _, decl := range file.AST.Decls {
+			domain, err := op.domainOf(decl)
+			if err != nil {
+				return nil, err
+			}
+			if domain == "" || filepath.Base(file.Path) == domain+".go" {
+				continue
+			}
+			if err := checkMovable(file, decl, op.pkg.Files[domain+".go"]); err != nil {
+				return nil, err
+			}
+			moves = append(moves, domainMove{file: file, decl: decl, to: domain + ".go"})
+		}
+	}
+
+	clauses := make(map[string]string) // Test file -> its package clause
+	for name, file := range op.pkg.TestFiles {
+		if file.AST != nil {
+			clauses[name] = file.AST.Name.Name
+		}
+	}
+	// In-package tests claim <domain>_test.go first.
+	testFiles := sortedFiles(op.pkg.TestFiles)
+	external := func(f *types.File) int {
+		if f.AST.Name.Name != op.pkg.Name {
+			return 1
+		}
+		return 0
+	}
+	slices.SortStableFunc(testFiles, func(a, b *types.File) int { return external(a) - external(b) })
+	for _, file := range testFiles {
+		for _, decl := range file.AST.Decls {
+			fd, ok := decl.(*ast.FuncDecl)
+			if !ok || !isTestFunc(fd) {
+				continue
+			}
+			domain := op.domainOfName(subjectIn(fd.Name.Name, declared))
+			if domain == "" {
+				continue
+			}
+			to, err := op.testFileFor(domain, file.AST.Name.Name, clauses)
+			if err != nil {
+				return nil, err
+			}
+			if filepath.Base(file.Path) == to {
+				continue
+			}
+			if err := checkMovable(file, decl, op.pkg.TestFiles[to]); err != nil {
+				return nil, err
+			}
+			moves = append(moves, domainMove{file: file, decl: decl, to: to})
+		}
+	}
+	return moves, nil
+}
+
+// domainOf returns the domain of a declaration, or "" if it has none. A
+// declaration grouping names of different domains can't be split up.
+func (op *OrganizeByDomainOperation) domainOf(decl ast.Decl) (string, error) {
+	if fd, ok := decl.(*ast.FuncDecl); ok {
+		if fd.Recv != nil {
+			return op.domains[receiverTypeName(fd)], nil
+		}
+		return op.domainOfName(fd.Name.Name), nil
+	}
+	names := declaredNames(decl)
+	var domain string
+	for i, name := range names {
+		d := op.domains[name]
+		if i > 0 && d != domain {
+			return "", &types.RefactorError{
+				Type:    types.InvalidOperation,
+				Message: fmt.Sprintf("%s and %s are declared together; list them in the same domain", names[0], name),
+			}
+		}
+		domain = d
+	}
+	return domain, nil
+}
+
+// domainOfName returns the domain of a package-level name: the one listing
+// it, or for a constructor, NewT or newT followed by nothing or by a new
+// word, the domain of T.
+func (op *OrganizeByDomainOperation) domainOfName(name string) string {
+	if name == "" || name == "init" {
+		return ""
+	}
+	if domain, ok := op.domains[name]; ok {
+		return domain
+	}
+	rest, ok := strings.CutPrefix(name, "New")
+	if !ok {
+		if rest, ok = strings.CutPrefix(name, "new"); !ok {
+			return ""
+		}
+	}
+	for _, t := range op.named {
+		after, ok := strings.CutPrefix(rest, exportFirst(t))
+		if !ok {
+			continue
+		}
+		if r, _ := utf8.DecodeRuneInString(after); after == "" || unicode.IsUpper(r) || unicode.IsDigit(r) || r == '_' {
+			return op.domains[t]
+		}
+	}
+	return ""
+}
+
+// testFileFor returns the test file of a domain for tests with the package
+// clause: <domain>_test.go, unless the other test package has it, then
+// <domain>_internal_test.go or <domain>_external_test.go. clauses records
+// the package clause of existing and chosen test files.
+func (op *OrganizeByDomainOperation) testFileFor(domain, clause string, clauses map[string]string) (string, error) {
+	name := domain + "_test.go"
+	if c, ok := clauses[name]; ok && c != clause {
+		variant := "internal"
+		if clause != op.pkg.Name {
+			variant = "external"
+		}
+		name = domain + "_" + variant + "_test.go"
+	}
+	if c, ok := clauses[name]; ok && c != clause {
+		return "", &types.RefactorError{
+			Type:    types.NameConflict,
+			Message: fmt.Sprintf("%s belongs to package %s, not %s", name, c, clause),
+		}
+	}
+	clauses[name] = clause
+	return name, nil
+}
+
+// checkMovable reports why decl can't move from file to dest, an existing
+// file or nil: build constraints or a cgo preamble of either file would not
+// apply to it as before, and neither would the dot imports of file.
+func checkMovable(file *types.File, decl ast.Decl, dest *types.File) error {
+	name := strings.Join(declaredNames(decl), ", ")
+	if fd, ok := decl.(*ast.FuncDecl); ok && fd.Recv != nil {
+		name = receiverTypeName(fd) + "." + fd.Name.Name
+	}
+	for _, f := range []*types.File{file, dest} {
+		if f != nil && constrained(f) {
+			return &types.RefactorError{
+				Type:    types.InvalidOperation,
+				Message: fmt.Sprintf("%s can't move: %s has build constraints", name, filepath.Base(f.Path)),
+			}
+		}
+	}
+	for _, spec := range file.AST.Imports {
+		if spec.Name != nil && spec.Name.Name == "." {
+			return &types.RefactorError{
+				Type:    types.InvalidOperation,
+				Message: fmt.Sprintf("%s can't move: %s dot-imports %s", name, filepath.Base(file.Path), spec.Path.Value),
+			}
+		}
+	}
+	return nil
+}
+
+// constrained reports whether file is only built for some platforms or
+// tags, or with cgo.
+func constrained(file *types.File) bool {
+	if goos, goarch := fileSuffix(fileStemOf(filepath.Base(file.Path))); goos != "" || goarch != "" {
+		return true
+	}
+	if len(constraintLines(file.OriginalContent, headerOffset(file))) > 0 {
+		return true
+	}
+	return slices.ContainsFunc(file.AST.Imports, func(spec *ast.ImportSpec) bool { return importPathOf(spec) == "C" })
+}
+
+func (op *OrganizeByDomainOperation) Execute(ws *types.Workspace) (*types.RefactoringPlan, error) {
+	var testInfo *gotypes.Info
+	if op.Parser != nil && len(op.pkg.TestFiles) > 0 {
+		testInfo = op.Parser.TypeCheckTests(ws, op.pkg)
+	}
+	infoFor := func(file *types.File) *gotypes.Info {
+		if strings.HasSuffix(file.Path, "_test.go") {
+			return testInfo
+		}
+		return op.pkg.TypesInfo
+	}
+
+	leaving := make(map[string][]domainMove)  // By the base name of their file
+	arriving := make(map[string][]domainMove) // By the base name of their destination
+	for _, m := range op.moves {
+		from := filepath.Base(m.file.Path)
+		leaving[from] = append(leaving[from], m)
+		arriving[m.to] = append(arriving[m.to], m)
+	}
+	names := slices.Sorted(maps.Keys(leaving))
+	for name := range arriving {
+		if _, ok := leaving[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	var changes []types.Change
+	var affected []string
+	for _, name := range names {
+		path := filepath.Join(op.pkg.Path, name)
+		file := op.pkg.Files[name]
+		if strings.HasSuffix(name, "_test.go") {
+			file = op.pkg.TestFiles[name]
+		}
+		if file == nil {
+			clause := arriving[name][0].file.AST.Name.Name
+			changes = append(changes, types.Change{
+				File:        path,
+				NewText:     newDomainFile(ws, clause, arriving[name], infoFor),
+				Description: fmt.Sprintf("Create %s", name),
+			})
+			affected = append(affected, path)
+			continue
+		}
+		content := rewriteDomainFile(ws, file, leaving[name], arriving[name], infoFor)
+		changes = append(changes, types.Change{
+			File:        path,
+			End:         len(file.OriginalContent),
+			OldText:     string(file.OriginalContent),
+			NewText:     content,
+			Description: fmt.Sprintf("Regroup the declarations of %s by domain", name),
+		})
+		affected = append(affected, path)
+	}
+
+	return &types.RefactoringPlan{
+		Changes:       changes,
+		AffectedFiles: affected,
+		Impact: &types.ImpactAnalysis{
+			AffectedPackages: []string{op.pkg.Path},
+			AffectedFiles:    affected,
+		},
+		Reversible: true,
+	}, nil
+}
+
+// newDomainFile renders a new file holding the arriving declarations.
+func newDomainFile(ws *types.Workspace, clause string, arriving []domainMove, infoFor func(*types.File) *gotypes.Info) string {
+	var imports []*ast.ImportSpec
+	var decls []string
+	for _, m := range arriving {
+		imports = appendImports(imports, usedImports(m.file, []ast.Decl{m.decl}, infoFor(m.file)))
+		decls = append(decls, declSource(ws, m.file, m.decl))
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "package %s\n\n", clause)
+	if len(imports) > 0 {
+		b.WriteString(importBlock(imports) + "\n\n")
+	}
+	b.WriteString(strings.Join(decls, "\n\n") + "\n")
+	return formatted(b.String())
+}
+
+// rewriteDomainFile removes the leaving declarations from file, appends the
+// arriving ones and keeps the imports the declarations then use. A file
+// left with neither declarations nor a package comment is removed: ""
+// is returned.
+func rewriteDomainFile(ws *types.Workspace, file *types.File, leaving, arriving []domainMove, infoFor func(*types.File) *gotypes.Info) string {
+	content := string(file.OriginalContent)
+	var remaining []ast.Decl
+	var importDecls []*ast.GenDecl
+	for _, decl := range file.AST.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			importDecls = append(importDecls, gen)
+			continue
+		}
+		if !slices.ContainsFunc(leaving, func(m domainMove) bool { return m.decl == decl }) {
+			remaining = append(remaining, decl)
+		}
+	}
+	if len(remaining) == 0 && len(arriving) == 0 && file.AST.Doc == nil {
+		return ""
+	}
+
+	var edits []textEdit
+	for _, m := range leaving {
+		start, end := declLines(ws, file, m.decl)
+		edits = append(edits, textEdit{start: start, end: end})
+	}
+	if len(arriving) > 0 {
+		var b strings.Builder
+		for _, m := range arriving {
+			b.WriteString("\n" + declSource(ws, m.file, m.decl) + "\n")
+		}
+		edits = append(edits, textEdit{start: len(content), end: len(content), text: b.String()})
+	}
+
+	// Keep the imports still used, blank imports and those the arriving
+	// declarations need.
+	used := usedImports(file, remaining, infoFor(file))
+	var imports []*ast.ImportSpec
+	for _, spec := range file.AST.Imports {
+		if spec.Name != nil && spec.Name.Name == "_" || slices.Contains(used, spec) {
+			imports = append(imports, spec)
+		}
+	}
+	kept := len(imports)
+	for _, m := range arriving {
+		imports = appendImports(imports, usedImports(m.file, []ast.Decl{m.decl}, infoFor(m.file)))
+	}
+	if kept != len(file.AST.Imports) || len(imports) != kept {
+		block := ""
+		if len(imports) > 0 {
+			block = importBlock(imports)
+		}
+		if len(importDecls) > 0 {
+			start := ws.FileSet.Position(importDecls[0].Pos()).Offset
+			end := ws.FileSet.Position(importDecls[len(importDecls)-1].End()).Offset
+			edits = append(edits, textEdit{start: start, end: end, text: block})
+		} else {
+			end := ws.FileSet.Position(file.AST.Name.End()).Offset
+			edits = append(edits, textEdit{start: end, end: end, text: "\n\n" + block})
+		}
+	}
+	return formatted(applyTextEdits(content, edits))
+}
+
+// usedImports returns the import specs of file that decls refer to,
+// going by the type information when there is some and by the names the
+// imports are known by otherwise.
+func usedImports(file *types.File, decls []ast.Decl, info *gotypes.Info) []*ast.ImportSpec {
+	var used []*ast.ImportSpec
+	for _, decl := range decls {
+		ast.Inspect(decl, func(n ast.Node) bool {
+			sel, ok := n.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			x, ok := sel.X.(*ast.Ident)
+			if !ok {
+				return true
+			}
+			for _, spec := range file.AST.Imports {
+				if slices.Contains(used, spec) || !refersToImport(x, spec, info) {
+					continue
+				}
+				used = append(used, spec)
+			}
+			return true
+		})
+	}
+	return used
+}
+
+// refersToImport reports whether the qualifier x refers to the import spec.
+func refersToImport(x *ast.Ident, spec *ast.ImportSpec, info *gotypes.Info) bool {
+	if info != nil {
+		if pkgName, ok := info.Uses[x].(*gotypes.PkgName); ok {
+			return pkgName.Imported().Path() == importPathOf(spec)
+		}
+	}
+	return x.Obj == nil && x.Name == importName(spec)
+}
+
+// importPathOf returns the unquoted path of an import spec.
+func importPathOf(spec *ast.ImportSpec) string {
+	path, _ := strconv.Unquote(spec.Path.Value)
+	return path
+}
+
+// importName returns the name an import is known by in its file: its alias,
+// or the last element of its path.
+func importName(spec *ast.ImportSpec) string {
+	if spec.Name != nil {
+		return spec.Name.Name
+	}
+	return lastPathComponent(importPathOf(spec))
+}
+
+// appendImports adds the specs not imported yet under the same name.
+func appendImports(imports, specs []*ast.ImportSpec) []*ast.ImportSpec {
+	for _, spec := range specs {
+		if !slices.ContainsFunc(imports, func(s *ast.ImportSpec) bool {
+			return importPathOf(s) == importPathOf(spec) && importName(s) == importName(spec)
+		}) {
+			imports = append(imports, spec)
+		}
+	}
+	return imports
+}
+
+// importBlock renders an import declaration of specs, sorted by path.
+func importBlock(specs []*ast.ImportSpec) string {
+	specs = slices.Clone(specs)
+	slices.SortFunc(specs, func(a, b *ast.ImportSpec) int { return cmp.Compare(importPathOf(a), importPathOf(b)) })
+	var b strings.Builder
+	b.WriteString("import (\n")
+	for _, spec := range specs {
+		b.WriteString("\t")
+		if spec.Name != nil {
+			b.WriteString(spec.Name.Name + " ")
+		}
+		b.WriteString(strconv.Quote(importPathOf(spec)) + "\n")
+	}
+	b.WriteString(")")
+	return b.String()
+}
+
+// declSource returns the source of decl with its doc comment and any
+// comment after it on its last line.
+func declSource(ws *types.Workspace, file *types.File, decl ast.Decl) string {
+	start, end := declSpan(ws, file, decl)
+	return string(file.OriginalContent[start:end])
+}
+
+// declSpan returns the offsets of decl in file, from its doc comment to the
+// end of any comment following it on its last line.
+func declSpan(ws *types.Workspace, file *types.File, decl ast.Decl) (int, int) {
+	pos := decl.Pos()
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		if d.Doc != nil {
+			pos = d.Doc.Pos()
+		}
+	case *ast.GenDecl:
+		if d.Doc != nil {
+			pos = d.Doc.Pos()
+		}
+	}
+	content := file.OriginalContent
+	start := ws.FileSet.Position(pos).Offset
+	end := ws.FileSet.Position(decl.End()).Offset
+	lineEnd := end
+	for lineEnd < len(content) && content[lineEnd] != '\n' {
+		lineEnd++
+	}
+	if rest := strings.TrimSpace(string(content[end:lineEnd])); strings.HasPrefix(rest, "//") || strings.HasPrefix(rest, "/*") {
+		end = lineEnd
+	}
+	return start, end
+}
+
+// declLines returns the offsets of the lines decl occupies in file,
+// with a blank line following them, so that removing them leaves no gap.
+func declLines(ws *types.Workspace, file *types.File, decl ast.Decl) (int, int) {
+	content := file.OriginalContent
+	start, end := declSpan(ws, file, decl)
+	for start > 0 && (content[start-1] == ' ' || content[start-1] == '\t') {
+		start--
+	}
+	for end < len(content) && content[end] != '\n' {
+		end++
+	}
+	if end < len(content) {
+		end++
+	}
+	if end < len(content) && content[end] == '\n' {
+		end++
+	}
+	return start, end
+}
+
+// headerOffset returns the offset of file's package clause or of the
+// package comment before it.
+func headerOffset(file *types.File) int {
+	pos := file.AST.Package
+	if file.AST.Doc != nil {
+		pos = file.AST.Doc.Pos()
+	}
+	return int(pos) - int(file.AST.FileStart)
+}
+
+// declaredNames returns the package-level names a declaration declares;
+// methods declare none.
+func declaredNames(decl ast.Decl) []string {
+	var names []string
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		if d.Recv == nil {
+			names = append(names, d.Name.Name)
+		}
+	case *ast.GenDecl:
+		for _, spec := range d.Specs {
+			switch s := spec.(type) {
+			case *ast.TypeSpec:
+				names = append(names, s.Name.Name)
+			case *ast.ValueSpec:
+				for _, n := range s.Names {
+					names = append(names, n.Name)
+				}
+			}
+		}
+	}
+	return names
+}
+
+// sortedFiles returns the parsed files of a package file map by name.
+func sortedFiles(files map[string]*types.File) []*types.File {
+	var sorted []*types.File
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		if files[name].AST != nil {
+			sorted = append(sorted, files[name])
+		}
+	}
+	return sorted
+}
+
+// formatted returns gofmt'ed src, or src itself if it doesn't parse.
+func formatted(src string) string {
+	out, err := format.Source([]byte(src))
+	if err != nil {
+		return src
+	}
+	return string(out)
+}
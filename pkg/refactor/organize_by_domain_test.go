@@ -0,0 +1,165 @@
+package refactor
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+func TestOrganizeByDomain(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"go.mod": "module example.com/store\n\ngo 1.21\n",
+		"store.go": `package store
+
+import (
+	"errors"
+	"strings"
+)
+
+// User is an account.
+type User struct{ Name string }
+
+// NewUser returns a user named name.
+func NewUser(name string) *User { return &User{Name: strings.TrimSpace(name)} }
+
+// Order is a purchase.
+type Order struct{ ID int }
+
+func (o *Order) Valid() error {
+	if o.ID == 0 {
+		return errors.New("no id")
+	}
+	return nil
+}
+
+func (u *User) Greeting() string { return "hi " + u.Name }
+`,
+		"store_test.go": `package store
+
+import "testing"
+
+func TestUser_Greeting(t *testing.T) {
+	if NewUser("a").Greeting() != "hi a" {
+		t.Fail()
+	}
+}
+
+func BenchmarkNewUser(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		NewUser("a")
+	}
+}
+
+func TestOrder(t *testing.T) {
+	if (&Order{}).Valid() == nil {
+		t.Fail()
+	}
+}
+`,
+		"example_test.go": `package store_test
+
+import (
+	"fmt"
+
+	"example.com/store"
+)
+
+func ExampleUser_Greeting() {
+	fmt.Println(store.NewUser("a").Greeting())
+	// Output: hi a
+}
+
+func Example() {
+	fmt.Println(store.Order{ID: 1})
+	// Output: {1}
+}
+`,
+	})
+	engine := CreateEngine(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ws, err := engine.LoadWorkspace(dir)
+	if err != nil {
+		t.Fatalf("LoadWorkspace: %v", err)
+	}
+
+	plan, err := engine.OrganizeByDomain(ws, types.OrganizeByDomainRequest{
+		Package: dir,
+		Domains: []types.Domain{
+			{Name: "user", Symbols: []string{"User"}},
+			{Name: "order", Symbols: []string{"Order"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("OrganizeByDomain: %v", err)
+	}
+	if err := engine.ExecutePlan(plan); err != nil {
+		t.Fatalf("ExecutePlan: %v", err)
+	}
+	vetModule(t, dir)
+
+	if _, err := os.Stat(filepath.Join(dir, "store.go")); !os.IsNotExist(err) {
+		t.Errorf("expected store.go to be removed once empty")
+	}
+	tests := []struct {
+		file        string
+		want, avoid []string
+	}{
+		{"user.go", []string{"type User struct", "func NewUser(", "func (u *User) Greeting()", `"strings"`}, []string{"Order", `"errors"`}},
+		{"order.go", []string{"type Order struct", "func (o *Order) Valid()", `"errors"`}, []string{"User", `"strings"`}},
+		{"user_test.go", []string{"package store\n", "func TestUser_Greeting(", "func BenchmarkNewUser("}, []string{"TestOrder"}},
+		{"order_test.go", []string{"func TestOrder("}, []string{"User"}},
+		{"user_external_test.go", []string{"package store_test", "func ExampleUser_Greeting("}, []string{"func Example()"}},
+		{"example_test.go", []string{"func Example() {"}, []string{"ExampleUser_Greeting"}},
+	}
+	for _, tt := range tests {
+		got := fileContent(t, filepath.Join(dir, tt.file))
+		for _, want := range tt.want {
+			if !strings.Contains(got, want) {
+				t.Errorf("expected %q in %s, got:\n%s", want, tt.file, got)
+			}
+		}
+		for _, avoid := range tt.avoid {
+			if strings.Contains(got, avoid) {
+				t.Errorf("expected no %q in %s, got:\n%s", avoid, tt.file, got)
+			}
+		}
+	}
+}
+
+func TestOrganizeByDomain_Refused(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"go.mod":     "module example.com/p\n\ngo 1.21\n",
+		"p.go":       "package p\n\ntype (\n\tA int\n\tB int\n)\n\nfunc C() {}\n",
+		"p_linux.go": "package p\n\nfunc D() {}\n",
+	})
+	engine := CreateEngine(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ws, err := engine.LoadWorkspace(dir)
+	if err != nil {
+		t.Fatalf("LoadWorkspace: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		domains []types.Domain
+		want    string
+	}{
+		{"split group", []types.Domain{{Name: "a", Symbols: []string{"A"}}}, "A and B are declared together"},
+		{"constrained file", []types.Domain{{Name: "d", Symbols: []string{"D"}}}, "p_linux.go has build constraints"},
+		{"platform file name", []types.Domain{{Name: "c_windows", Symbols: []string{"C"}}}, "can't name a domain's file"},
+		{"listed twice", []types.Domain{{Name: "c", Symbols: []string{"C"}}, {Name: "e", Symbols: []string{"C"}}}, "C is listed in domains c and e"},
+		{"unknown symbol", []types.Domain{{Name: "e", Symbols: []string{"E"}}}, "E is not declared"},
+		{"already organized", []types.Domain{{Name: "p", Symbols: []string{"C"}}}, "already in the files of their domains"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := engine.OrganizeByDomain(ws, types.OrganizeByDomainRequest{Package: dir, Domains: tt.domains})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected an error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,677 @@
+package refactor
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/tools/go/ast/astutil"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// defaultMaxFileLines is the size above which organize_file splits a file
+// by receiver type.
+const defaultMaxFileLines = 500
+
+// OrganizeFileOperation reorders the declarations of a file by a policy
+// and, when asked, moves the types with methods of an oversized file into
+// files of their own. Declarations keep their doc comments and the
+// comments before them; build constraints, the package clause and imports
+// stay first.
+type OrganizeFileOperation struct {
+	Request types.OrganizeFileRequest
+
+	file   *types.File
+	layout *fileLayout
+}
+
+func (op *OrganizeFileOperation) Type() types.OperationType {
+	return types.OrganizeFileOperation
+}
+
+func (op *OrganizeFileOperation) Description() string {
+	return fmt.Sprintf("Organize %s by %s", filepath.Base(op.Request.File), op.policy())
+}
+
+func (op *OrganizeFileOperation) policy() types.FileOrderPolicy {
+	return cmp.Or(op.Request.Policy, types.FileOrderKind)
+}
+
+func (op *OrganizeFileOperation) Validate(ws *types.Workspace) error {
+	switch op.policy() {
+	case types.FileOrderKind, types.FileOrderCalls:
+	default:
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("unknown policy %q: want %s or %s", op.Request.Policy, types.FileOrderKind, types.FileOrderCalls),
+		}
+	}
+	op.file = findFile(ws, op.Request.File)
+	if op.file == nil || op.file.AST == nil {
+		return &types.RefactorError{
+			Type:    types.FileSystemError,
+			Message: fmt.Sprintf("file not found: %s", op.Request.File),
+			File:    op.Request.File,
+		}
+	}
+	op.layout = layoutFile(ws, op.file)
+	return nil
+}
+
+func (op *OrganizeFileOperation) Execute(ws *types.Workspace) (*types.RefactoringPlan, error) {
+	chunks := op.layout.chunks
+	plan := &types.RefactoringPlan{Reversible: true}
+
+	lines := bytes.Count(op.file.OriginalContent, []byte("\n"))
+	if op.Request.SplitByReceiver && lines > cmp.Or(op.Request.MaxLines, defaultMaxFileLines) {
+		var moved []types.Change
+		var err error
+		chunks, moved, err = op.splitByReceiver(ws, chunks)
+		if err != nil {
+			return nil, err
+		}
+		plan.Changes = append(plan.Changes, moved...)
+	}
+
+	var ordered []*declChunk
+	switch op.policy() {
+	case types.FileOrderCalls:
+		ordered = orderByCalls(chunks)
+	default:
+		ordered = orderByKind(chunks)
+	}
+	if len(plan.Changes) > 0 || !slices.Equal(ordered, op.layout.chunks) {
+		content, err := op.layout.render(ws, op.file.Package, ordered)
+		if err != nil {
+			return nil, err
+		}
+		if content != string(op.file.OriginalContent) {
+			plan.Changes = append([]types.Change{{
+				File:        op.file.Path,
+				End:         len(op.file.OriginalContent),
+				OldText:     string(op.file.OriginalContent),
+				NewText:     content,
+				Description: fmt.Sprintf("Reorder the declarations of %s by %s", filepath.Base(op.file.Path), op.policy()),
+			}}, plan.Changes...)
+		}
+	}
+
+	for _, c := range plan.Changes {
+		plan.AffectedFiles = append(plan.AffectedFiles, c.File)
+	}
+	plan.Impact = &types.ImpactAnalysis{AffectedFiles: plan.AffectedFiles}
+	if op.file.Package != nil {
+		plan.Impact.AffectedPackages = []string{op.file.Package.Path}
+	}
+	return plan, nil
+}
+
+// splitByReceiver moves each type with methods in the file, along with its
+// constructors and methods, into a file named after the type. The type the
+// file is named after stays. It returns the declarations left behind and
+// the changes creating the new files.
+func (op *OrganizeFileOperation) splitByReceiver(ws *types.Workspace, chunks []*declChunk) ([]*declChunk, []types.Change, error) {
+	return op.layout.split(ws, receiverGroups(op.file.Path, chunks), orderByKind)
+}
+
+// fileGroup is a set of declarations to move into a file of their own.
+type fileGroup struct {
+	stem   string // The file name without its .go or _test.go suffix
+	chunks []*declChunk
+}
+
+// receiverGroups groups each type with methods of the file at path with its
+// typed constants, constructors and methods, except the type the file is
+// named after.
+func receiverGroups(path string, chunks []*declChunk) []*fileGroup {
+	base, _ := fileStem(path)
+	var groups []*fileGroup
+	for _, g := range groupByType(chunks) {
+		if g.name != "" && g.hasMethods && snakeCase(g.name) != base {
+			groups = append(groups, &fileGroup{stem: snakeCase(g.name), chunks: g.chunks})
+		}
+	}
+	return groups
+}
+
+// fileStem returns the name of the file at path without its suffix, and
+// the suffix: _test.go for test files, .go otherwise.
+func fileStem(path string) (string, string) {
+	base := strings.TrimSuffix(filepath.Base(path), ".go")
+	if trimmed, ok := strings.CutSuffix(base, "_test"); ok {
+		return trimmed, "_test.go"
+	}
+	return base, ".go"
+}
+
+// split moves each group into a new file of the same package next to the
+// file, its declarations put in order by ordered, and returns the
+// declarations left behind and the changes creating the files. Groups with
+// the same stem share a file.
+func (l *fileLayout) split(ws *types.Workspace, groups []*fileGroup, ordered func([]*declChunk) []*declChunk) ([]*declChunk, []types.Change, error) {
+	for _, imp := range l.file.AST.Imports {
+		if imp.Path.Value == `"C"` {
+			return nil, nil, &types.RefactorError{
+				Type:    types.InvalidOperation,
+				Message: "files using cgo can't be split: the moved declarations would lose the preamble",
+				File:    l.file.Path,
+			}
+		}
+	}
+
+	_, suffix := fileStem(l.file.Path)
+	var stems []string
+	byStem := make(map[string][]*declChunk)
+	moved := make(map[*declChunk]bool)
+	for _, g := range groups {
+		if _, ok := byStem[g.stem]; !ok {
+			stems = append(stems, g.stem)
+		}
+		byStem[g.stem] = append(byStem[g.stem], g.chunks...)
+		for _, c := range g.chunks {
+			moved[c] = true
+		}
+	}
+
+	var changes []types.Change
+	for _, stem := range stems {
+		target := filepath.Join(filepath.Dir(l.file.Path), stem+suffix)
+		chunks := byStem[stem]
+		if _, err := readSource(ws, target); err == nil {
+			return nil, nil, &types.RefactorError{
+				Type:    types.NameConflict,
+				Message: fmt.Sprintf("can't move %s to %s: the file exists", chunkNames(chunks), filepath.Base(target)),
+				File:    target,
+			}
+		}
+		content, err := l.renderNew(ws, l.file.Package, ordered(chunks))
+		if err != nil {
+			return nil, nil, err
+		}
+		changes = append(changes, types.Change{
+			File:        target,
+			NewText:     content,
+			Description: fmt.Sprintf("Move %s to %s", chunkNames(chunks), filepath.Base(target)),
+		})
+	}
+
+	var kept []*declChunk
+	for _, c := range l.chunks {
+		if !moved[c] {
+			kept = append(kept, c)
+		}
+	}
+	return kept, changes, nil
+}
+
+// chunkNames lists the names chunks declare, methods as Type.Method.
+func chunkNames(chunks []*declChunk) string {
+	var names []string
+	for _, c := range chunks {
+		if fd, ok := c.decl.(*ast.FuncDecl); ok && fd.Recv != nil {
+			names = append(names, receiverTypeName(fd)+"."+fd.Name.Name)
+		} else {
+			names = append(names, declNames(c.decl)...)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// declChunk is a top-level declaration as written, with its doc comment and
+// any other comments between it and the previous declaration.
+type declChunk struct {
+	decl ast.Decl
+	text string
+}
+
+// fileLayout is a file cut into the header before its declarations, the
+// declarations and the comments after the last of them.
+type fileLayout struct {
+	file    *types.File
+	header  string // Build constraints, package clause and imports
+	chunks  []*declChunk
+	trailer string
+}
+
+func layoutFile(ws *types.Workspace, file *types.File) *fileLayout {
+	content := file.OriginalContent
+	offset := func(p token.Pos) int { return ws.FileSet.Position(p).Offset }
+	lineEnd := func(off int) int {
+		for off < len(content) && content[off] != '\n' {
+			off++
+		}
+		return off
+	}
+
+	start := lineEnd(offset(file.AST.Name.End()))
+	var decls []ast.Decl
+	for _, d := range file.AST.Decls {
+		if gen, ok := d.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			start = lineEnd(offset(gen.End()))
+			continue
+		}
+		decls = append(decls, d)
+	}
+
+	l := &fileLayout{file: file, header: string(content[:start])}
+	for i, d := range decls {
+		end := lineEnd(offset(d.End()))
+		if i+1 < len(decls) {
+			end = min(end, offset(declStart(decls[i+1])))
+		}
+		l.chunks = append(l.chunks, &declChunk{decl: d, text: strings.TrimSpace(string(content[start:end]))})
+		start = end
+	}
+	l.trailer = strings.TrimSpace(string(content[start:]))
+	return l
+}
+
+// declStart returns the start of d's doc comment, or of d without one.
+func declStart(d ast.Decl) token.Pos {
+	switch d := d.(type) {
+	case *ast.FuncDecl:
+		if d.Doc != nil {
+			return d.Doc.Pos()
+		}
+	case *ast.GenDecl:
+		if d.Doc != nil {
+			return d.Doc.Pos()
+		}
+	}
+	return d.Pos()
+}
+
+// render returns the file with its declarations replaced by chunks, without
+// the imports they no longer use.
+func (l *fileLayout) render(ws *types.Workspace, pkg *types.Package, chunks []*declChunk) (string, error) {
+	var b strings.Builder
+	b.WriteString(l.header)
+	b.WriteString("\n")
+	for _, c := range chunks {
+		b.WriteString("\n" + c.text + "\n")
+	}
+	if l.trailer != "" {
+		b.WriteString("\n" + l.trailer + "\n")
+	}
+	return l.pruneImports(ws, pkg, b.String())
+}
+
+// renderNew returns a new file of the same package holding chunks, with the
+// file's build constraints and the imports chunks use.
+func (l *fileLayout) renderNew(ws *types.Workspace, pkg *types.Package, chunks []*declChunk) (string, error) {
+	var b strings.Builder
+	for _, group := range l.file.AST.Comments {
+		if group.Pos() >= l.file.AST.Package {
+			break
+		}
+		for _, c := range group.List {
+			if strings.HasPrefix(c.Text, "//go:build") || strings.HasPrefix(c.Text, "// +build") {
+				b.WriteString(c.Text + "\n")
+			}
+		}
+	}
+	if b.Len() > 0 {
+		b.WriteString("\n")
+	}
+	b.WriteString("package " + l.file.AST.Name.Name + "\n")
+	if imports := l.importDecls(ws); imports != "" {
+		b.WriteString("\n" + imports + "\n")
+	}
+	for _, c := range chunks {
+		b.WriteString("\n" + c.text + "\n")
+	}
+	return l.pruneImports(ws, pkg, b.String())
+}
+
+// importDecls returns the import declarations of the file as written.
+func (l *fileLayout) importDecls(ws *types.Workspace) string {
+	var decls []string
+	for _, d := range l.file.AST.Decls {
+		if gen, ok := d.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			start, end := ws.FileSet.Position(gen.Pos()).Offset, ws.FileSet.Position(gen.End()).Offset
+			decls = append(decls, string(l.file.OriginalContent[start:end]))
+		}
+	}
+	return strings.Join(decls, "\n")
+}
+
+// pruneImports removes the imports src doesn't use and formats it. Blank
+// and dot imports are kept.
+func (l *fileLayout) pruneImports(ws *types.Workspace, pkg *types.Package, src string) (string, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, l.file.Path, src, parser.ParseComments)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse reorganized %s: %w", filepath.Base(l.file.Path), err)
+	}
+	used := make(map[string]bool)
+	for _, id := range f.Unresolved {
+		used[id.Name] = true
+	}
+	for _, imp := range slices.Clone(f.Imports) {
+		importPath, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			continue
+		}
+		name := l.packageName(ws, pkg, importPath)
+		if imp.Name != nil {
+			name = imp.Name.Name
+		}
+		if name != "_" && name != "." && !used[name] {
+			astutil.DeleteNamedImport(fset, f, nameOrEmpty(imp.Name), importPath)
+		}
+	}
+	// A single import left of a group loses its parentheses, as gofmt
+	// wouldn't drop them.
+	for _, d := range f.Decls {
+		if gen, ok := d.(*ast.GenDecl); ok && gen.Tok == token.IMPORT && len(gen.Specs) == 1 {
+			if spec := gen.Specs[0].(*ast.ImportSpec); spec.Doc == nil && spec.Comment == nil {
+				gen.Lparen, gen.Rparen = token.NoPos, token.NoPos
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, f); err != nil {
+		return "", fmt.Errorf("failed to format reorganized %s: %w", filepath.Base(l.file.Path), err)
+	}
+	return buf.String(), nil
+}
+
+// packageName returns the name of the package at importPath: as type-checked,
+// as loaded into the workspace, or guessed from the path.
+func (l *fileLayout) packageName(ws *types.Workspace, pkg *types.Package, importPath string) string {
+	if pkg != nil && pkg.TypesInfo != nil {
+		for _, imp := range l.file.AST.Imports {
+			if imp.Path.Value == strconv.Quote(importPath) {
+				if name := pkg.TypesInfo.PkgNameOf(imp); name != nil {
+					return name.Imported().Name()
+				}
+			}
+		}
+	}
+	for _, p := range ws.Packages {
+		if p.ImportPath == importPath {
+			return p.Name
+		}
+	}
+	name := path.Base(importPath)
+	if len(name) > 1 && name[0] == 'v' && strings.Trim(name[1:], "0123456789") == "" {
+		name = path.Base(path.Dir(importPath))
+	}
+	name, _, _ = strings.Cut(strings.TrimPrefix(name, "go-"), ".")
+	return strings.ReplaceAll(name, "-", "")
+}
+
+func nameOrEmpty(id *ast.Ident) string {
+	if id == nil {
+		return ""
+	}
+	return id.Name
+}
+
+// typeGroup is a type of the file with the declarations that belong with
+// it: typed constants, constructors and methods. Methods of types declared
+// elsewhere form groups too, without a type declaration.
+type typeGroup struct {
+	name       string
+	chunks     []*declChunk
+	hasMethods bool
+}
+
+// groupByType returns the type groups of chunks in the order their types
+// first appear. Declarations outside any group are collected in a group
+// with an empty name.
+func groupByType(chunks []*declChunk) []*typeGroup {
+	declared := make(map[string]bool)
+	for _, c := range chunks {
+		if gen, ok := c.decl.(*ast.GenDecl); ok && gen.Tok == token.TYPE {
+			for _, spec := range gen.Specs {
+				declared[spec.(*ast.TypeSpec).Name.Name] = true
+			}
+		}
+	}
+
+	rest := &typeGroup{}
+	groups := []*typeGroup{rest}
+	byName := make(map[string]*typeGroup)
+	add := func(name string, c *declChunk) *typeGroup {
+		g := byName[name]
+		if g == nil {
+			g = &typeGroup{name: name}
+			byName[name] = g
+			groups = append(groups, g)
+		}
+		g.chunks = append(g.chunks, c)
+		return g
+	}
+	for _, c := range chunks {
+		switch name := owningType(c.decl, declared); {
+		case name == "":
+			rest.chunks = append(rest.chunks, c)
+		case isMethod(c.decl):
+			add(name, c).hasMethods = true
+		default:
+			add(name, c)
+		}
+	}
+	return groups
+}
+
+// owningType returns the type a declaration belongs with, or "".
+func owningType(d ast.Decl, declared map[string]bool) string {
+	switch d := d.(type) {
+	case *ast.FuncDecl:
+		if d.Recv != nil {
+			return receiverTypeName(d)
+		}
+		if !strings.HasPrefix(d.Name.Name, "New") && !strings.HasPrefix(d.Name.Name, "new") {
+			return ""
+		}
+		if d.Type.Results != nil && len(d.Type.Results.List) > 0 {
+			if name := baseTypeName(d.Type.Results.List[0].Type); declared[name] {
+				return name
+			}
+		}
+	case *ast.GenDecl:
+		switch d.Tok {
+		case token.TYPE:
+			return d.Specs[0].(*ast.TypeSpec).Name.Name
+		case token.CONST:
+			if vs := d.Specs[0].(*ast.ValueSpec); vs.Type != nil {
+				if id, ok := vs.Type.(*ast.Ident); ok && declared[id.Name] {
+					return id.Name
+				}
+			}
+		}
+	}
+	return ""
+}
+
+func isMethod(d ast.Decl) bool {
+	fd, ok := d.(*ast.FuncDecl)
+	return ok && fd.Recv != nil
+}
+
+// orderByKind orders chunks as constants, variables and init functions,
+// then each type with its typed constants, constructors, exported methods
+// and unexported methods, then exported and unexported functions. The
+// order within each kind is kept, which keeps variable initialization and
+// init functions in their order.
+func orderByKind(chunks []*declChunk) []*declChunk {
+	groups := groupByType(chunks)
+
+	var head, tail []*declChunk
+	for _, c := range groups[0].chunks {
+		if fd, ok := c.decl.(*ast.FuncDecl); ok && fd.Name.Name != "init" {
+			tail = append(tail, c)
+		} else {
+			head = append(head, c)
+		}
+	}
+	slices.SortStableFunc(head, func(a, b *declChunk) int { return cmp.Compare(outerRank(a.decl), outerRank(b.decl)) })
+	slices.SortStableFunc(tail, func(a, b *declChunk) int { return cmp.Compare(outerRank(a.decl), outerRank(b.decl)) })
+
+	ordered := head
+	for _, g := range groups[1:] {
+		members := slices.Clone(g.chunks)
+		slices.SortStableFunc(members, func(a, b *declChunk) int { return cmp.Compare(memberRank(a.decl), memberRank(b.decl)) })
+		ordered = append(ordered, members...)
+	}
+	return append(ordered, tail...)
+}
+
+// outerRank ranks the declarations outside type groups: constants,
+// variables, init functions, exported functions (and main), then
+// unexported functions.
+func outerRank(d ast.Decl) int {
+	switch d := d.(type) {
+	case *ast.GenDecl:
+		if d.Tok == token.VAR {
+			return 1
+		}
+		return 0
+	case *ast.FuncDecl:
+		switch {
+		case d.Name.Name == "init":
+			return 2
+		case d.Name.IsExported() || d.Name.Name == "main":
+			return 3
+		}
+	}
+	return 4
+}
+
+// memberRank ranks the declarations of a type group: the type, its typed
+// constants, constructors, exported methods, then unexported methods.
+func memberRank(d ast.Decl) int {
+	switch d := d.(type) {
+	case *ast.GenDecl:
+		if d.Tok == token.CONST {
+			return 1
+		}
+		return 0
+	case *ast.FuncDecl:
+		switch {
+		case d.Recv == nil:
+			return 2
+		case d.Name.IsExported():
+			return 3
+		}
+	}
+	return 4
+}
+
+// orderByCalls orders chunks as constants, variables and types, then
+// functions and methods by the step-down rule: each one follows the first
+// function that calls it, callees in the order they are first called.
+// Functions no other function in the file calls start a new descent, in
+// their order in the file.
+func orderByCalls(chunks []*declChunk) []*declChunk {
+	var ordered, funcs []*declChunk
+	for _, c := range chunks {
+		if _, ok := c.decl.(*ast.FuncDecl); ok {
+			funcs = append(funcs, c)
+		} else {
+			ordered = append(ordered, c)
+		}
+	}
+	slices.SortStableFunc(ordered, func(a, b *declChunk) int { return cmp.Compare(genRank(a.decl), genRank(b.decl)) })
+
+	callees := make(map[*declChunk][]*declChunk)
+	called := make(map[*declChunk]bool)
+	for _, c := range funcs {
+		fd := c.decl.(*ast.FuncDecl)
+		if fd.Body == nil {
+			continue
+		}
+		ast.Inspect(fd.Body, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			for _, callee := range funcs {
+				if callee != c && calls(call, callee.decl.(*ast.FuncDecl)) && !slices.Contains(callees[c], callee) {
+					callees[c] = append(callees[c], callee)
+					called[callee] = true
+				}
+			}
+			return true
+		})
+	}
+
+	visited := make(map[*declChunk]bool)
+	var visit func(c *declChunk)
+	visit = func(c *declChunk) {
+		if visited[c] {
+			return
+		}
+		visited[c] = true
+		ordered = append(ordered, c)
+		for _, callee := range callees[c] {
+			visit(callee)
+		}
+	}
+	for _, c := range funcs {
+		if !called[c] {
+			visit(c)
+		}
+	}
+	for _, c := range funcs {
+		visit(c)
+	}
+	return ordered
+}
+
+// genRank ranks constants before variables before types.
+func genRank(d ast.Decl) int {
+	switch d.(*ast.GenDecl).Tok {
+	case token.CONST:
+		return 0
+	case token.VAR:
+		return 1
+	}
+	return 2
+}
+
+// calls reports whether call may call fd, going by names alone: a function
+// by its identifier, a method by any selector of its name.
+func calls(call *ast.CallExpr, fd *ast.FuncDecl) bool {
+	switch fun := ast.Unparen(call.Fun).(type) {
+	case *ast.Ident:
+		return fd.Recv == nil && fun.Name == fd.Name.Name
+	case *ast.IndexExpr:
+		id, ok := fun.X.(*ast.Ident)
+		return ok && fd.Recv == nil && id.Name == fd.Name.Name
+	case *ast.SelectorExpr:
+		return fd.Recv != nil && fun.Sel.Name == fd.Name.Name
+	}
+	return false
+}
+
+// snakeCase converts a Go identifier to the snake_case of file names,
+// keeping initialisms together: HTTPServer becomes http_server.
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			prevLower := unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if prevLower || nextLower && unicode.IsUpper(runes[i-1]) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
@@ -0,0 +1,160 @@
+package refactor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+var organizeFileFiles = map[string]string{
+	"shop/shop.go": `package shop
+
+import (
+	"fmt"
+	"strings"
+)
+
+// format renders a price.
+func format(p int) string {
+	return fmt.Sprint(p)
+}
+
+// Describe lists the cart.
+func (c *Cart) Describe() string {
+	return strings.Join(c.names(), ", ")
+}
+
+func (c *Cart) names() []string { return nil } // helper
+
+// Total sums the prices.
+func Total(c *Cart) string {
+	return format(c.sum())
+}
+
+var defaultCart = NewCart()
+
+// Cart holds items.
+type Cart struct {
+	items []int
+}
+
+func (c *Cart) sum() int { return len(c.items) }
+
+// NewCart returns an empty cart.
+func NewCart() *Cart {
+	return &Cart{}
+}
+
+const maxItems = 10
+
+// trailing note
+`,
+}
+
+func TestOrganizeFile_Kind(t *testing.T) {
+	engine, ws, dir := loadTestModuleWith(t, organizeFileFiles)
+	file := filepath.Join(dir, "shop", "shop.go")
+
+	plan, err := engine.OrganizeFile(ws, types.OrganizeFileRequest{File: file})
+	if err != nil {
+		t.Fatalf("OrganizeFile: %v", err)
+	}
+	got := planContent(t, plan, file)
+	order := []string{
+		"const maxItems = 10",
+		"var defaultCart = NewCart()",
+		"// Cart holds items.\ntype Cart struct",
+		"// NewCart returns an empty cart.\nfunc NewCart()",
+		"// Describe lists the cart.\nfunc (c *Cart) Describe()",
+		"func (c *Cart) names() []string { return nil } // helper",
+		"func (c *Cart) sum() int",
+		"// Total sums the prices.\nfunc Total(",
+		"// format renders a price.\nfunc format(",
+		"// trailing note",
+	}
+	assertOrder(t, got, order)
+
+	again, err := engine.OrganizeFile(ws, types.OrganizeFileRequest{File: file, Policy: types.FileOrderCalls})
+	if err != nil {
+		t.Fatalf("OrganizeFile: %v", err)
+	}
+	assertOrder(t, planContent(t, again, file), []string{
+		"const maxItems",
+		"var defaultCart",
+		"type Cart struct",
+		"func (c *Cart) Describe()",
+		"func (c *Cart) names()",
+		"func Total(",
+		"func format(",
+		"func (c *Cart) sum()",
+		"func NewCart()",
+	})
+}
+
+func TestOrganizeFile_SplitByReceiver(t *testing.T) {
+	files := map[string]string{
+		"shop/shop.go": organizeFileFiles["shop/shop.go"] + `
+type Order struct{ id string }
+
+func (o Order) ID() string { return strings.ToUpper(o.id) }
+`,
+	}
+	engine, ws, dir := loadTestModuleWith(t, files)
+	file := filepath.Join(dir, "shop", "shop.go")
+
+	plan, err := engine.OrganizeFile(ws, types.OrganizeFileRequest{File: file, SplitByReceiver: true, MaxLines: 10})
+	if err != nil {
+		t.Fatalf("OrganizeFile: %v", err)
+	}
+	if err := engine.ExecutePlan(plan); err != nil {
+		t.Fatalf("ExecutePlan: %v", err)
+	}
+
+	cart := readFile(t, filepath.Join(dir, "shop", "cart.go"))
+	for _, want := range []string{"\t\"strings\"\n", "// Cart holds items.\ntype Cart struct", "func NewCart()", "func (c *Cart) sum()"} {
+		if !strings.Contains(cart, want) {
+			t.Errorf("cart.go lacks %q:\n%s", want, cart)
+		}
+	}
+	order := readFile(t, filepath.Join(dir, "shop", "order.go"))
+	if !strings.Contains(order, "func (o Order) ID() string") {
+		t.Errorf("order.go lacks the Order method:\n%s", order)
+	}
+	shop := readFile(t, file)
+	if strings.Contains(shop, "type Cart") || strings.Contains(shop, "\"strings\"") || !strings.Contains(shop, "func Total(") {
+		t.Errorf("shop.go should keep only the functions and the imports they use:\n%s", shop)
+	}
+
+	plan, err = engine.OrganizeFile(ws, types.OrganizeFileRequest{File: file, SplitByReceiver: true, MaxLines: 10})
+	if err == nil && len(plan.Changes) > 0 {
+		t.Errorf("expected splitting into existing files to fail, got %d change(s)", len(plan.Changes))
+	}
+}
+
+func assertOrder(t *testing.T, got string, order []string) {
+	t.Helper()
+	last := -1
+	for _, want := range order {
+		i := strings.Index(got, want)
+		if i < 0 {
+			t.Errorf("missing %q in:\n%s", want, got)
+			continue
+		}
+		if i < last {
+			t.Errorf("%q is out of order in:\n%s", want, got)
+		}
+		last = i
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
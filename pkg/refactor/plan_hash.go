@@ -0,0 +1,60 @@
+package refactor
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+	"maps"
+	"slices"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// PlanHash returns a digest of the changes of plan. Plans making the same
+// edits hash the same, whatever order they list them in.
+func PlanHash(plan *types.RefactoringPlan) string {
+	changes := slices.Clone(plan.Changes)
+	slices.SortFunc(changes, func(a, b types.Change) int {
+		return cmp.Or(cmp.Compare(a.File, b.File), cmp.Compare(a.Start, b.Start), cmp.Compare(a.End, b.End),
+			cmp.Compare(a.OldText, b.OldText), cmp.Compare(a.NewText, b.NewText))
+	})
+	h := sha256.New()
+	for _, c := range changes {
+		writeField(h, []byte(c.File))
+		binary.Write(h, binary.LittleEndian, [2]int64{int64(c.Start), int64(c.End)})
+		writeField(h, []byte(c.OldText))
+		writeField(h, []byte(c.NewText))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// WorkspaceFingerprint returns a digest of the Go files of ws as loaded,
+// which is what a plan is computed from. Workspaces with the same files at
+// the same paths have the same fingerprint, in any process.
+func WorkspaceFingerprint(ws *types.Workspace) string {
+	h := sha256.New()
+	writeField(h, []byte(ws.RootPath))
+	if ws.Module != nil {
+		writeField(h, []byte(ws.Module.Path))
+	}
+	for _, dir := range slices.Sorted(maps.Keys(ws.Packages)) {
+		pkg := ws.Packages[dir]
+		writeField(h, []byte(dir))
+		for _, files := range []map[string]*types.File{pkg.Files, pkg.TestFiles} {
+			for _, name := range slices.Sorted(maps.Keys(files)) {
+				writeField(h, []byte(name))
+				writeField(h, files[name].OriginalContent)
+			}
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeField writes b to h prefixed with its length, so consecutive fields
+// can't run into each other.
+func writeField(h hash.Hash, b []byte) {
+	binary.Write(h, binary.LittleEndian, int64(len(b)))
+	h.Write(b)
+}
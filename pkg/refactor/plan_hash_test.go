@@ -0,0 +1,48 @@
+package refactor
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+func TestPlanHash(t *testing.T) {
+	a := types.Change{File: "a.go", Start: 1, End: 2, OldText: "x", NewText: "y"}
+	b := types.Change{File: "b.go", Start: 0, End: 0, NewText: "// b\n"}
+	h := PlanHash(&types.RefactoringPlan{Changes: []types.Change{a, b}})
+	if got := PlanHash(&types.RefactoringPlan{Changes: []types.Change{b, a}}); got != h {
+		t.Error("hash depends on the order of the changes")
+	}
+	a.NewText = "z"
+	if got := PlanHash(&types.RefactoringPlan{Changes: []types.Change{a, b}}); got == h {
+		t.Error("different changes hash the same")
+	}
+}
+
+func TestWorkspaceFingerprint(t *testing.T) {
+	dir := writeTestModule(t)
+	engine := CreateEngine(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	load := func() string {
+		t.Helper()
+		ws, err := engine.LoadWorkspace(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return WorkspaceFingerprint(ws)
+	}
+
+	before := load()
+	if again := load(); again != before {
+		t.Error("reloading an unchanged workspace changed the fingerprint")
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a/a.go"), []byte("package a\n\nfunc A() { _ = 1 }\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if after := load(); after == before {
+		t.Error("editing a file left the fingerprint unchanged")
+	}
+}
@@ -0,0 +1,67 @@
+package refactor
+
+import (
+	"slices"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// SelectChanges returns a copy of plan with only the changes keep accepts,
+// by index, so that part of a reviewed plan can be validated and applied on
+// its own. The affected files, and the files and issues of the impact, are
+// narrowed to the files still changed; issues naming no file are kept.
+func SelectChanges(plan *types.RefactoringPlan, keep func(i int) bool) *types.RefactoringPlan {
+	out := *plan
+	out.Changes = make([]types.Change, 0, len(plan.Changes))
+	changed := make(map[string]bool)
+	for i, c := range plan.Changes {
+		if keep(i) {
+			out.Changes = append(out.Changes, c)
+			changed[c.File] = true
+		}
+	}
+
+	out.AffectedFiles = slices.DeleteFunc(slices.Clone(plan.AffectedFiles), func(f string) bool { return !changed[f] })
+	for _, c := range out.Changes {
+		if !slices.Contains(out.AffectedFiles, c.File) {
+			out.AffectedFiles = append(out.AffectedFiles, c.File)
+		}
+	}
+
+	if plan.Impact != nil {
+		impact := *plan.Impact
+		impact.AffectedFiles = slices.DeleteFunc(slices.Clone(impact.AffectedFiles), func(f string) bool { return !changed[f] })
+		impact.PotentialIssues = slices.DeleteFunc(slices.Clone(impact.PotentialIssues), func(issue types.Issue) bool {
+			return issue.File != "" && !changed[issue.File]
+		})
+		out.Impact = &impact
+	}
+	return &out
+}
+
+// SelectConfidence splits plan into a copy with the changes at least as sure
+// as level and the changes left out. An empty level keeps every change, and
+// plan itself is returned when nothing is left out.
+func SelectConfidence(plan *types.RefactoringPlan, level types.Confidence) (*types.RefactoringPlan, []types.Change) {
+	var skipped []types.Change
+	for _, c := range plan.Changes {
+		if level != "" && !c.Confidence.AtLeast(level) {
+			skipped = append(skipped, c)
+		}
+	}
+	if len(skipped) == 0 {
+		return plan, nil
+	}
+	return SelectChanges(plan, func(i int) bool { return plan.Changes[i].Confidence.AtLeast(level) }), skipped
+}
+
+// confidentChanges returns plan without the changes below the engine's
+// MinConfidence.
+func (e *DefaultEngine) confidentChanges(plan *types.RefactoringPlan) *types.RefactoringPlan {
+	kept, skipped := SelectConfidence(plan, e.config.MinConfidence)
+	if len(skipped) > 0 {
+		e.logger.Info("skipping changes below the minimum confidence",
+			"min_confidence", e.config.MinConfidence, "skipped", len(skipped))
+	}
+	return kept
+}
@@ -0,0 +1,62 @@
+package refactor
+
+import (
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+func TestSelectChanges(t *testing.T) {
+	plan := &types.RefactoringPlan{
+		Changes:       []types.Change{{File: "a.go"}, {File: "b.go"}, {File: "a.go", Start: 5}},
+		AffectedFiles: []string{"a.go", "b.go"},
+		Impact: &types.ImpactAnalysis{
+			AffectedFiles: []string{"a.go", "b.go"},
+			PotentialIssues: []types.Issue{
+				{File: "a.go", Description: "in a"},
+				{File: "b.go", Description: "in b"},
+				{Description: "plan-wide"},
+			},
+		},
+	}
+	got := SelectChanges(plan, func(i int) bool { return i != 1 })
+	if len(got.Changes) != 2 || got.Changes[1].Start != 5 {
+		t.Errorf("changes = %+v", got.Changes)
+	}
+	if len(got.AffectedFiles) != 1 || got.AffectedFiles[0] != "a.go" || len(got.Impact.AffectedFiles) != 1 {
+		t.Errorf("affected files = %v, impact %v", got.AffectedFiles, got.Impact.AffectedFiles)
+	}
+	if len(got.Impact.PotentialIssues) != 2 || got.Impact.PotentialIssues[1].Description != "plan-wide" {
+		t.Errorf("issues = %+v", got.Impact.PotentialIssues)
+	}
+	if len(plan.Changes) != 3 || len(plan.Impact.PotentialIssues) != 3 {
+		t.Error("SelectChanges modified the plan")
+	}
+}
+
+func TestSelectConfidence(t *testing.T) {
+	plan := &types.RefactoringPlan{
+		Changes: []types.Change{
+			{File: "a.go"},
+			{File: "a.go", Confidence: types.ConfidenceLikely},
+			{File: "b.go", Confidence: types.ConfidenceHeuristic},
+		},
+		AffectedFiles: []string{"a.go", "b.go"},
+	}
+	if got, skipped := SelectConfidence(plan, ""); got != plan || skipped != nil {
+		t.Error("an empty level should keep the plan")
+	}
+	if got, skipped := SelectConfidence(plan, types.ConfidenceHeuristic); got != plan || skipped != nil {
+		t.Error("heuristic should keep every change")
+	}
+	got, skipped := SelectConfidence(plan, types.ConfidenceLikely)
+	if len(got.Changes) != 2 || len(skipped) != 1 || skipped[0].File != "b.go" {
+		t.Errorf("changes = %+v, skipped %+v", got.Changes, skipped)
+	}
+	if len(got.AffectedFiles) != 1 || got.AffectedFiles[0] != "a.go" {
+		t.Errorf("affected files = %v", got.AffectedFiles)
+	}
+	if got, _ := SelectConfidence(plan, types.ConfidenceCertain); len(got.Changes) != 1 {
+		t.Errorf("certain kept %+v", got.Changes)
+	}
+}
@@ -0,0 +1,129 @@
+package refactor
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"path/filepath"
+	"strings"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// deprecatedAlias returns the change adding, after the declaration of the
+// exported symbol, a shim that keeps its old name working for code outside
+// the workspace: a wrapper calling the renamed function, a type alias, or
+// a var or const initialized from the new name. The shim is documented as
+// deprecated in favour of newName.
+func deprecatedAlias(ws *types.Workspace, symbol *types.Symbol, newName string) (types.Change, error) {
+	fail := func(format string, args ...any) (types.Change, error) {
+		return types.Change{}, &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("can't keep %s as an alias: "+format, append([]any{symbol.Name}, args...)...),
+			File:    symbol.File,
+			Line:    symbol.Line,
+		}
+	}
+	if !symbol.Exported {
+		return fail("only exported symbols keep their old name")
+	}
+	var file *types.File
+	if pkg := ws.Packages[filepath.Dir(symbol.File)]; pkg != nil {
+		file = pkg.Files[filepath.Base(symbol.File)]
+	}
+	if file == nil || file.AST == nil {
+		return fail("its file is not loaded")
+	}
+	src := string(file.OriginalContent)
+	offset := func(pos token.Pos) int { return ws.FileSet.Position(pos).Offset }
+
+	var decl ast.Decl
+	for _, d := range file.AST.Decls {
+		if d.Pos() <= symbol.Position && symbol.Position < d.End() {
+			decl = d
+			break
+		}
+	}
+
+	var shim string
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		if d.Recv != nil {
+			return fail("methods can't be aliased")
+		}
+		var err error
+		if shim, err = wrapperFunc(src, offset, d, newName); err != nil {
+			return fail("%v", err)
+		}
+	case *ast.GenDecl:
+		for _, spec := range d.Specs {
+			if spec.Pos() > symbol.Position || symbol.Position >= spec.End() {
+				continue
+			}
+			switch s := spec.(type) {
+			case *ast.TypeSpec:
+				if s.TypeParams != nil {
+					return fail("generic types can't be aliased")
+				}
+				shim = fmt.Sprintf("type %s = %s", symbol.Name, newName)
+			case *ast.ValueSpec:
+				shim = fmt.Sprintf("%s %s = %s", d.Tok, symbol.Name, newName)
+			}
+		}
+	}
+	if shim == "" {
+		return fail("its declaration is not found")
+	}
+
+	end := offset(decl.End())
+	return types.Change{
+		File:        symbol.File,
+		Start:       end,
+		End:         end,
+		NewText:     fmt.Sprintf("\n\n// %s is a deprecated alias of %s.\n//\n// Deprecated: Use %s instead.\n%s", symbol.Name, newName, newName, shim),
+		Description: fmt.Sprintf("Keep %s as a deprecated alias of %s", symbol.Name, newName),
+	}, nil
+}
+
+// wrapperFunc returns a function with fn's name and signature that calls
+// newName with its arguments.
+func wrapperFunc(src string, offset func(token.Pos) int, fn *ast.FuncDecl, newName string) (string, error) {
+	var typeParams string
+	var typeArgs []string
+	if fn.Type.TypeParams != nil {
+		for _, field := range fn.Type.TypeParams.List {
+			for _, name := range field.Names {
+				typeArgs = append(typeArgs, name.Name)
+			}
+		}
+		typeParams = src[offset(fn.Type.TypeParams.Opening) : offset(fn.Type.TypeParams.Closing)+1]
+	}
+
+	var args []string
+	for _, field := range fn.Type.Params.List {
+		if len(field.Names) == 0 {
+			return "", fmt.Errorf("its parameters are unnamed")
+		}
+		for _, name := range field.Names {
+			if name.Name == "_" {
+				return "", fmt.Errorf("it has a blank parameter")
+			}
+			arg := name.Name
+			if _, ok := field.Type.(*ast.Ellipsis); ok {
+				arg += "..."
+			}
+			args = append(args, arg)
+		}
+	}
+
+	call := newName
+	if len(typeArgs) > 0 {
+		call += "[" + strings.Join(typeArgs, ", ") + "]"
+	}
+	call += "(" + strings.Join(args, ", ") + ")"
+	if fn.Type.Results != nil && len(fn.Type.Results.List) > 0 {
+		call = "return " + call
+	}
+	signature := src[offset(fn.Type.Params.Opening):offset(fn.Type.End())]
+	return fmt.Sprintf("func %s%s%s {\n\t%s\n}", fn.Name.Name, typeParams, signature, call), nil
+}
@@ -0,0 +1,81 @@
+package refactor
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+func TestRenameSymbol_KeepAlias(t *testing.T) {
+	tests := []struct {
+		name, src, symbol, want string
+	}{
+		{
+			name:   "function",
+			src:    "package a\n\n// Join joins.\nfunc Join(sep string, parts ...string) string { return \"\" }\n",
+			symbol: "Join",
+			want:   "package a\n\n// Join joins.\nfunc Concat(sep string, parts ...string) string { return \"\" }\n\n// Join is a deprecated alias of Concat.\n//\n// Deprecated: Use Concat instead.\nfunc Join(sep string, parts ...string) string {\n\treturn Concat(sep, parts...)\n}\n",
+		},
+		{
+			name:   "generic function",
+			src:    "package a\n\nfunc Each[T any](xs []T, f func(T)) {}\n",
+			symbol: "Each",
+			want:   "package a\n\nfunc Concat[T any](xs []T, f func(T)) {}\n\n// Each is a deprecated alias of Concat.\n//\n// Deprecated: Use Concat instead.\nfunc Each[T any](xs []T, f func(T)) {\n\tConcat[T](xs, f)\n}\n",
+		},
+		{
+			name:   "type",
+			src:    "package a\n\ntype Point struct{ X, Y int }\n",
+			symbol: "Point",
+			want:   "package a\n\ntype Concat struct{ X, Y int }\n\n// Point is a deprecated alias of Concat.\n//\n// Deprecated: Use Concat instead.\ntype Point = Concat\n",
+		},
+		{
+			name:   "grouped const",
+			src:    "package a\n\nconst (\n\tLimit = 10\n\tOther = 2\n)\n",
+			symbol: "Limit",
+			want:   "package a\n\nconst (\n\tConcat = 10\n\tOther = 2\n)\n\n// Limit is a deprecated alias of Concat.\n//\n// Deprecated: Use Concat instead.\nconst Limit = Concat\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine, ws, dir := loadTestModuleWith(t, map[string]string{"a/x.go": tt.src})
+			plan, err := engine.RenameSymbol(ws, types.RenameSymbolRequest{
+				SymbolName: tt.symbol,
+				NewName:    "Concat",
+				Package:    filepath.Join(dir, "a"),
+				Scope:      types.PackageScope,
+				KeepAlias:  true,
+			})
+			if err != nil {
+				t.Fatalf("RenameSymbol: %v", err)
+			}
+			if got := planContent(t, plan, filepath.Join(dir, "a/x.go")); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenameSymbol_KeepAliasRefused(t *testing.T) {
+	tests := map[string]struct{ src, symbol, want string }{
+		"unexported": {"package a\n\nfunc helper() {}\n", "helper", "only exported symbols"},
+		"unnamed":    {"package a\n\nfunc F(int) {}\n", "F", "unnamed"},
+		"generic":    {"package a\n\ntype Box[T any] struct{ v T }\n", "Box", "generic types"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			engine, ws, dir := loadTestModuleWith(t, map[string]string{"a/x.go": tt.src})
+			_, err := engine.RenameSymbol(ws, types.RenameSymbolRequest{
+				SymbolName: tt.symbol,
+				NewName:    "Renamed",
+				Package:    filepath.Join(dir, "a"),
+				Scope:      types.PackageScope,
+				KeepAlias:  true,
+			})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected an error mentioning %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package refactor
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"maps"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// linkedTest is a test function named after a symbol by Go's conventions,
+// e.g. TestFoo, BenchmarkFoo, FuzzFoo or ExampleFoo_second for Foo.
+type linkedTest struct {
+	file    *types.File
+	decl    *ast.FuncDecl
+	newName string
+}
+
+// linkedTestName returns the name of the test function name once the symbol
+// it is named after is renamed from oldName to newName, and whether it is
+// named after it at all.
+func linkedTestName(name, oldName, newName string) (string, bool) {
+	for _, prefix := range testFuncPrefixes {
+		rest, ok := strings.CutPrefix(name, prefix)
+		if !ok {
+			continue
+		}
+		sep := ""
+		if r, ok := strings.CutPrefix(rest, "_"); ok {
+			rest, sep = r, "_" // Test_foo
+		}
+		if suffix, ok := strings.CutPrefix(rest, oldName); ok && (suffix == "" || suffix[0] == '_') {
+			return prefix + sep + newName + suffix, true
+		}
+	}
+	return "", false
+}
+
+// linkedTests returns the test functions of symbol's package named after
+// it, for functions and types.
+func linkedTests(ws *types.Workspace, symbol *types.Symbol, newName string) []linkedTest {
+	switch symbol.Kind {
+	case types.FunctionSymbol, types.TypeSymbol, types.InterfaceSymbol:
+	default:
+		return nil
+	}
+	pkg := ws.Packages[filepath.Dir(symbol.File)]
+	if pkg == nil {
+		return nil
+	}
+
+	var tests []linkedTest
+	for _, name := range slices.Sorted(maps.Keys(pkg.TestFiles)) {
+		file := pkg.TestFiles[name]
+		if file.AST == nil {
+			continue
+		}
+		for _, decl := range file.AST.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || !isTestFunc(fn) {
+				continue
+			}
+			if renamed, ok := linkedTestName(fn.Name.Name, symbol.Name, newName); ok {
+				tests = append(tests, linkedTest{file: file, decl: fn, newName: renamed})
+			}
+		}
+	}
+	return tests
+}
+
+// checkLinkedTestConflicts returns a NameConflict error when the new name of
+// one of tests is already declared in its test package.
+func checkLinkedTestConflicts(ws *types.Workspace, tests []linkedTest) error {
+	for _, t := range tests {
+		pkg := ws.Packages[filepath.Dir(t.file.Path)]
+		for _, f := range packageFiles(pkg) {
+			if f.AST != nil && f.AST.Name.Name == t.file.AST.Name.Name && topLevelNames(f.AST)[t.newName] {
+				return &types.RefactorError{
+					Type:    types.NameConflict,
+					Message: fmt.Sprintf("name conflict: renaming %s to %s, which already exists in %s", t.decl.Name.Name, t.newName, f.Path),
+					File:    t.file.Path,
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// outputComment matches the comment starting the output of an example.
+var outputComment = regexp.MustCompile(`(?i)^//\s*(unordered )?output:`)
+
+// generateLinkedTestChanges returns the changes renaming tests and the
+// mentions of their old names, and of the symbol's, in their comments. In
+// the output comments of an example, where the symbol's name only changes
+// when printed qualified, e.g. by %T, only pkg.Name is renamed.
+func generateLinkedTestChanges(ws *types.Workspace, tests []linkedTest, oldName, newName string) []types.Change {
+	offset := func(pos token.Pos) int { return ws.FileSet.Position(pos).Offset }
+	var changes []types.Change
+	for _, t := range tests {
+		pkgName := strings.TrimSuffix(t.file.AST.Name.Name, "_test")
+		printed := regexp.MustCompile(`\b` + regexp.QuoteMeta(pkgName+"."+oldName) + `\b`)
+		oldTest := t.decl.Name.Name
+		changes = append(changes, types.Change{
+			File:        t.file.Path,
+			Start:       offset(t.decl.Name.Pos()),
+			End:         offset(t.decl.Name.End()),
+			OldText:     oldTest,
+			NewText:     t.newName,
+			Description: fmt.Sprintf("Rename linked test %s to %s", oldTest, t.newName),
+		})
+
+		words := regexp.MustCompile(`\b(` + regexp.QuoteMeta(oldTest) + `|` + regexp.QuoteMeta(oldName) + `)\b`)
+		for _, group := range t.file.AST.Comments {
+			if group != t.decl.Doc && (group.Pos() < t.decl.Pos() || group.End() > t.decl.End()) {
+				continue
+			}
+			output := slices.ContainsFunc(group.List, func(c *ast.Comment) bool { return outputComment.MatchString(c.Text) })
+			for _, c := range group.List {
+				var text string
+				if output {
+					text = printed.ReplaceAllLiteralString(c.Text, pkgName+"."+newName)
+				} else {
+					text = words.ReplaceAllStringFunc(c.Text, func(word string) string {
+						if word == oldTest {
+							return t.newName
+						}
+						return newName
+					})
+				}
+				if text == c.Text {
+					continue
+				}
+				changes = append(changes, types.Change{
+					File:        t.file.Path,
+					Start:       offset(c.Pos()),
+					End:         offset(c.End()),
+					OldText:     c.Text,
+					NewText:     text,
+					Description: fmt.Sprintf("Update comment of linked test %s", t.newName),
+				})
+			}
+		}
+	}
+	return changes
+}
@@ -0,0 +1,93 @@
+package refactor
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+func TestLinkedTestName(t *testing.T) {
+	tests := map[string]string{
+		"TestA":           "TestZ",
+		"TestA_empty":     "TestZ_empty",
+		"Test_A":          "Test_Z",
+		"BenchmarkA":      "BenchmarkZ",
+		"ExampleA_second": "ExampleZ_second",
+		"FuzzA":           "FuzzZ",
+		"TestAB":          "",
+		"TestB":           "",
+	}
+	for name, want := range tests {
+		got, ok := linkedTestName(name, "A", "Z")
+		if ok != (want != "") || got != want {
+			t.Errorf("linkedTestName(%q) = %q, %v; want %q", name, got, ok, want)
+		}
+	}
+}
+
+func TestRenameSymbol_RenameTests(t *testing.T) {
+	engine, ws, dir := loadTestModuleWith(t, map[string]string{
+		"a/a_test.go":       "package a\n\nimport \"testing\"\n\n// TestA checks A.\nfunc TestA(t *testing.T) { A() }\n\nfunc BenchmarkA(b *testing.B) { A() }\n\nfunc TestAll(t *testing.T) {}\n",
+		"a/example_test.go": "package a_test\n\nimport (\n\t\"fmt\"\n\n\t\"example.com/p/a\"\n)\n\nfunc ExampleA() {\n\ta.A()\n\tfmt.Println(\"A done\")\n\t// Output: A done\n}\n",
+	})
+	plan, err := engine.RenameSymbol(ws, types.RenameSymbolRequest{
+		SymbolName:  "A",
+		NewName:     "Z",
+		Package:     filepath.Join(dir, "a"),
+		Scope:       types.PackageScope,
+		RenameTests: true,
+	})
+	if err != nil {
+		t.Fatalf("RenameSymbol: %v", err)
+	}
+
+	want := map[string]string{
+		"a/a_test.go":       "package a\n\nimport \"testing\"\n\n// TestZ checks Z.\nfunc TestZ(t *testing.T) { Z() }\n\nfunc BenchmarkZ(b *testing.B) { Z() }\n\nfunc TestAll(t *testing.T) {}\n",
+		"a/example_test.go": "package a_test\n\nimport (\n\t\"fmt\"\n\n\t\"example.com/p/a\"\n)\n\nfunc ExampleZ() {\n\ta.Z()\n\tfmt.Println(\"A done\")\n\t// Output: A done\n}\n",
+	}
+	for name, content := range want {
+		if got := planContent(t, plan, filepath.Join(dir, name)); got != content {
+			t.Errorf("%s:\ngot:\n%s\nwant:\n%s", name, got, content)
+		}
+	}
+}
+
+func TestRenameSymbol_RenameTestsExampleOutput(t *testing.T) {
+	engine, ws, dir := loadTestModuleWith(t, map[string]string{
+		"a/a.go":            "package a\n\ntype T struct{}\n",
+		"a/example_test.go": "package a_test\n\nimport (\n\t\"fmt\"\n\n\t\"example.com/p/a\"\n)\n\n// ExampleT prints a T.\nfunc ExampleT() {\n\tfmt.Printf(\"%T\\n\", a.T{})\n\tfmt.Println(\"T done\")\n\t// Output:\n\t// a.T\n\t// T done\n}\n",
+	})
+	plan, err := engine.RenameSymbol(ws, types.RenameSymbolRequest{
+		SymbolName:  "T",
+		NewName:     "U",
+		Package:     filepath.Join(dir, "a"),
+		Scope:       types.PackageScope,
+		RenameTests: true,
+	})
+	if err != nil {
+		t.Fatalf("RenameSymbol: %v", err)
+	}
+
+	want := "package a_test\n\nimport (\n\t\"fmt\"\n\n\t\"example.com/p/a\"\n)\n\n// ExampleU prints a U.\nfunc ExampleU() {\n\tfmt.Printf(\"%T\\n\", a.U{})\n\tfmt.Println(\"T done\")\n\t// Output:\n\t// a.U\n\t// T done\n}\n"
+	if got := planContent(t, plan, filepath.Join(dir, "a/example_test.go")); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRenameSymbol_RenameTestsConflict(t *testing.T) {
+	engine, ws, dir := loadTestModuleWith(t, map[string]string{
+		"a/a_test.go": "package a\n\nimport \"testing\"\n\nfunc TestA(t *testing.T) { A() }\n\nfunc TestZ(t *testing.T) {}\n",
+	})
+	_, err := engine.RenameSymbol(ws, types.RenameSymbolRequest{
+		SymbolName:  "A",
+		NewName:     "Z",
+		Package:     filepath.Join(dir, "a"),
+		Scope:       types.PackageScope,
+		RenameTests: true,
+	})
+	if err == nil || !strings.Contains(err.Error(), "TestZ") {
+		t.Errorf("expected a TestZ name conflict, got %v", err)
+	}
+}
@@ -0,0 +1,1029 @@
+package refactor
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	gotypes "go/types"
+	"maps"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mamaar/gorefactor/pkg/analysis"
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// RewriteOperation replaces the expressions matching a pattern throughout
+// the workspace, as gofmt -r does, with comby-style holes:
+//
+//	errors.Wrap(:[err], :[msg]) -> fmt.Errorf("%s: %w", :[msg], :[err])
+//
+// A hole :[name] matches any expression, :[name...] any number of the
+// elements of a list, such as call arguments, and :[name~regexp] an
+// expression whose source matches regexp in full. A hole used twice must
+// match the same expression both times; holes named _ match without being
+// bound. Where guards restrict holes to expressions assignable to a type,
+// which takes the package to be type-checked.
+//
+// A selector on a quoted import path, as in "github.com/pkg/errors".Wrap,
+// names a package by its path: in the pattern it only matches references
+// to that package, and in the replacement it is written with the name the
+// file imports the package by, adding the import when the file lacks it.
+// Imports the rewritten files no longer use are removed. Expressions found
+// in the holes of a match are rewritten as well.
+type RewriteOperation struct {
+	Request types.RewriteRequest
+	Parser  *analysis.GoParser // Type-checks packages on demand for guards; may be nil if they already are
+
+	rule    *rewriteRule      // Compiled by Validate
+	names   map[string]string // Package names of the imports the plan leaves unused, set by Execute
+	Matches int               // Expressions the plan rewrites, set by Execute
+}
+
+func (op *RewriteOperation) Type() types.OperationType {
+	return types.RewriteOperation
+}
+
+func (op *RewriteOperation) Description() string {
+	return fmt.Sprintf("Rewrite %s to %s", op.Request.Pattern, op.Request.Replacement)
+}
+
+func (op *RewriteOperation) Validate(ws *types.Workspace) error {
+	req := op.Request
+	if req.Pattern == "" || req.Replacement == "" {
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: "pattern and replacement are required",
+		}
+	}
+	rule, err := compileRewriteRule(req.Pattern, req.Replacement, req.Where)
+	if err != nil {
+		return &types.RefactorError{Type: types.InvalidOperation, Message: err.Error()}
+	}
+	if req.Package != "" {
+		if _, ok := ws.Packages[types.ResolvePackagePath(ws, req.Package)]; !ok {
+			return &types.RefactorError{
+				Type:    types.SymbolNotFound,
+				Message: fmt.Sprintf("package %s not found", req.Package),
+			}
+		}
+	}
+	op.rule = rule
+	return nil
+}
+
+func (op *RewriteOperation) Execute(ws *types.Workspace) (*types.RefactoringPlan, error) {
+	if op.rule == nil {
+		if err := op.Validate(ws); err != nil {
+			return nil, err
+		}
+	}
+	pkgPath := ""
+	if op.Request.Package != "" {
+		pkgPath = types.ResolvePackagePath(ws, op.Request.Package)
+	}
+	plan, result, err := rewriteWorkspace(ws, op.Parser, []*rewriteRule{op.rule}, pkgPath)
+	if err != nil {
+		return nil, err
+	}
+	op.Matches, op.names = result.counts[0], result.names
+	return plan, nil
+}
+
+// importPaths returns the import paths the replacement names packages by.
+func (op *RewriteOperation) importPaths() []string {
+	if op.rule == nil {
+		return nil
+	}
+	return op.rule.importPaths()
+}
+
+// holePattern matches the holes of rewrite patterns: :[name], :[name...]
+// and :[name~regexp].
+var holePattern = regexp.MustCompile(`:\[(\w+)(\.\.\.)?(?:~([^\]]*))?\]`)
+
+// holePrefix and pathPrefix start the identifiers holes and import paths
+// are parsed and rendered as.
+const (
+	holePrefix = "gorefactorHole_"
+	pathPrefix = "gorefactorPath_"
+)
+
+// rewriteRule is a compiled pattern and replacement, with the holes parsed
+// as identifiers starting with holePrefix.
+type rewriteRule struct {
+	name        string
+	pattern     ast.Expr
+	replacement string   // Source, with the holes as identifiers
+	root        ast.Expr // Parsed replacement
+	slots       []rewriteSlot
+	holes       map[string]*rewriteHole
+	typed       bool // Guards or import paths in the pattern need type information
+}
+
+// rewriteHole is a hole of a pattern.
+type rewriteHole struct {
+	variadic bool
+	re       *regexp.Regexp
+	guard    ast.Expr // Type the expressions must be assignable to
+}
+
+// rewriteSlot is a hole or a quoted import path of a replacement, at the
+// offsets start:end of its source.
+type rewriteSlot struct {
+	start, end int
+	hole       string
+	path       string
+	context    exprContext
+}
+
+// compileRewriteRule parses a pattern, its replacement and the guards of
+// its holes.
+func compileRewriteRule(pattern, replacement string, where map[string]string) (*rewriteRule, error) {
+	r := &rewriteRule{name: pattern, holes: make(map[string]*rewriteHole)}
+
+	var err error
+	unnamed := 0
+	src := holePattern.ReplaceAllStringFunc(pattern, func(m string) string {
+		sub := holePattern.FindStringSubmatch(m)
+		name, variadic := sub[1], sub[2] != ""
+		hole := &rewriteHole{variadic: variadic}
+		if sub[3] != "" {
+			re, reErr := regexp.Compile("^(?:" + sub[3] + ")$")
+			if reErr != nil && err == nil {
+				err = fmt.Errorf("hole %s: %v", name, reErr)
+			}
+			hole.re = re
+		}
+		if name == "_" {
+			unnamed++
+			name = "_" + strconv.Itoa(unnamed)
+		} else if prev, ok := r.holes[name]; ok {
+			if prev.variadic != variadic && err == nil {
+				err = fmt.Errorf("hole %s is used both with and without ...", name)
+			}
+			if hole.re == nil {
+				return holePrefix + name
+			}
+		}
+		r.holes[name] = hole
+		return holePrefix + name
+	})
+	if err != nil {
+		return nil, err
+	}
+	if r.pattern, err = parser.ParseExpr(src); err != nil {
+		return nil, fmt.Errorf("pattern %q is not a Go expression: %v", pattern, err)
+	}
+	ast.Inspect(r.pattern, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if lit, ok := sel.X.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+				r.typed = true
+			}
+		}
+		return true
+	})
+
+	r.replacement = holePattern.ReplaceAllStringFunc(replacement, func(m string) string {
+		sub := holePattern.FindStringSubmatch(m)
+		name := sub[1]
+		hole, ok := r.holes[name]
+		switch {
+		case err != nil:
+		case name == "_" || !ok:
+			err = fmt.Errorf("the replacement uses %s, which the pattern doesn't bind", m)
+		case sub[3] != "":
+			err = fmt.Errorf("the replacement can't constrain %s", m)
+		case hole.variadic != (sub[2] != ""):
+			err = fmt.Errorf("hole %s is used both with and without ...", name)
+		}
+		return holePrefix + name
+	})
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	if r.root, err = parser.ParseExprFrom(fset, "", r.replacement, 0); err != nil {
+		return nil, fmt.Errorf("replacement %q is not a Go expression: %v", replacement, err)
+	}
+	offset := func(pos token.Pos) int { return fset.Position(pos).Offset }
+	var stack []ast.Node
+	ast.Inspect(r.root, func(n ast.Node) bool {
+		if n == nil {
+			stack = stack[:len(stack)-1]
+			return true
+		}
+		var parent ast.Node
+		if len(stack) > 0 {
+			parent = stack[len(stack)-1]
+		}
+		switch n := n.(type) {
+		case *ast.Ident:
+			if name, ok := strings.CutPrefix(n.Name, holePrefix); ok {
+				slot := rewriteSlot{start: offset(n.Pos()), end: offset(n.End()), hole: name}
+				if e, ok := parent.(ast.Expr); ok {
+					slot.context = contextOf(e, n)
+				}
+				r.slots = append(r.slots, slot)
+			}
+		case *ast.SelectorExpr:
+			if lit, ok := n.X.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+				path, _ := strconv.Unquote(lit.Value)
+				r.slots = append(r.slots, rewriteSlot{start: offset(lit.Pos()), end: offset(lit.End()), path: path})
+			}
+		}
+		stack = append(stack, n)
+		return true
+	})
+	sort.Slice(r.slots, func(i, j int) bool { return r.slots[i].start < r.slots[j].start })
+
+	for _, name := range slices.Sorted(maps.Keys(where)) {
+		hole, ok := r.holes[name]
+		if !ok {
+			return nil, fmt.Errorf("where: the pattern has no hole %s", name)
+		}
+		if hole.guard, err = parser.ParseExpr(where[name]); err != nil {
+			return nil, fmt.Errorf("where: %q is not a type: %v", where[name], err)
+		}
+		r.typed = true
+	}
+	return r, nil
+}
+
+// importPaths returns the import paths the replacement names packages by.
+func (r *rewriteRule) importPaths() []string {
+	var paths []string
+	for _, s := range r.slots {
+		if s.path != "" && !slices.Contains(paths, s.path) {
+			paths = append(paths, s.path)
+		}
+	}
+	return paths
+}
+
+// exprContext is the least precedence an expression needs not to be
+// parenthesized where it is placed.
+type exprContext int
+
+const (
+	freeContext    exprContext = 0                     // An argument, an element, a statement
+	unaryContext   exprContext = token.UnaryPrec + 1   // The operand of a unary expression
+	primaryContext exprContext = token.HighestPrec + 1 // The operand of a selector, index, slice, type assertion or call
+)
+
+// contextOf returns the context child has in parent.
+func contextOf(parent ast.Node, child ast.Expr) exprContext {
+	switch p := parent.(type) {
+	case *ast.BinaryExpr:
+		// Binary operators are left-associative.
+		if p.Y == child {
+			return exprContext(p.Op.Precedence() + 1)
+		}
+		return exprContext(p.Op.Precedence())
+	case *ast.UnaryExpr, *ast.StarExpr:
+		return unaryContext
+	case *ast.SelectorExpr:
+		if p.X == child {
+			return primaryContext
+		}
+	case *ast.IndexExpr:
+		if p.X == child {
+			return primaryContext
+		}
+	case *ast.IndexListExpr:
+		if p.X == child {
+			return primaryContext
+		}
+	case *ast.SliceExpr:
+		if p.X == child {
+			return primaryContext
+		}
+	case *ast.TypeAssertExpr:
+		if p.X == child {
+			return primaryContext
+		}
+	case *ast.CallExpr:
+		if p.Fun == child {
+			return primaryContext
+		}
+	}
+	return freeContext
+}
+
+// wrap returns text, the source of e, parenthesized if e binds less
+// tightly than c asks.
+func (c exprContext) wrap(e ast.Expr, text string) string {
+	prec := token.HighestPrec + 1
+	switch e := e.(type) {
+	case *ast.BinaryExpr:
+		prec = e.Op.Precedence()
+	case *ast.UnaryExpr, *ast.StarExpr:
+		prec = token.UnaryPrec
+	}
+	if exprContext(prec) < c {
+		return "(" + text + ")"
+	}
+	return text
+}
+
+// manageRewriteImports updates the imports of the files plan edits as
+// manageImports does, telling the import manager the package names of the
+// imports the rewrite leaves unused so it can remove them.
+func (e *DefaultEngine) manageRewriteImports(ws *types.Workspace, plan *types.RefactoringPlan, importPaths []string, names map[string]string) {
+	m := NewImportManager(ws, e.config.ImportAliases)
+	m.Offer(importPaths...)
+	for path, name := range names {
+		m.Name(path, name)
+	}
+	m.Update(plan)
+}
+
+// rewriteResult is what rewriting the workspace found besides the plan.
+type rewriteResult struct {
+	counts []int             // Expressions rewritten by each rule
+	names  map[string]string // Package names by import path of the imports left unused, which the import manager may not know
+}
+
+// rewriteWorkspace applies rules to the Go files of the packages of ws, or
+// of the package at pkgPath alone, the first rule matching an expression
+// rewriting it.
+func rewriteWorkspace(ws *types.Workspace, goParser *analysis.GoParser, rules []*rewriteRule, pkgPath string) (*types.RefactoringPlan, *rewriteResult, error) {
+	typed, guarded := false, false
+	for _, r := range rules {
+		typed = typed || r.typed
+		for _, h := range r.holes {
+			guarded = guarded || h.guard != nil
+		}
+	}
+
+	plan := &types.RefactoringPlan{Reversible: true}
+	var issues []types.Issue
+	result := &rewriteResult{counts: make([]int, len(rules)), names: make(map[string]string)}
+	imports := NewImportManager(ws, nil)
+	for _, path := range slices.Sorted(maps.Keys(ws.Packages)) {
+		pkg := ws.Packages[path]
+		if pkgPath != "" && pkg.Path != pkgPath {
+			continue
+		}
+		if typed && goParser != nil {
+			goParser.EnsureTypeChecked(ws, pkg)
+		}
+		if guarded && pkg.TypesInfo == nil {
+			issues = append(issues, types.Issue{
+				Type:        types.IssueCompilationError,
+				Severity:    types.Warning,
+				Description: fmt.Sprintf("package %s isn't type-checked: its expressions are only rewritten by rules without where guards", pkg.ImportPath),
+			})
+		}
+
+		declared := make(map[string]bool)
+		files := packageFiles(pkg)
+		for _, f := range files {
+			if f.AST != nil {
+				maps.Copy(declared, topLevelNames(f.AST))
+			}
+		}
+		slices.SortFunc(files, func(a, b *types.File) int { return strings.Compare(a.Path, b.Path) })
+		for _, file := range files {
+			if file.AST == nil || file.OriginalContent == nil {
+				continue
+			}
+			rf := &rewriteFile{
+				ws:       ws,
+				rules:    rules,
+				file:     file,
+				src:      file.OriginalContent,
+				names:    imports,
+				declared: declared,
+				counts:   make([]int, len(rules)),
+				unused:   make(map[string]string),
+				guards:   make(map[ast.Expr]gotypes.Type),
+			}
+			if pkg.TypesInfo != nil && pkg.TypesPkg != nil && pkg.Files[filepath.Base(file.Path)] == file {
+				rf.info, rf.pkg = pkg.TypesInfo, pkg.TypesPkg
+			}
+			changes, issue, err := rf.rewrite()
+			if err != nil {
+				return nil, nil, err
+			}
+			if issue != nil {
+				issues = append(issues, *issue)
+				continue
+			}
+			if len(changes) == 0 {
+				continue
+			}
+			plan.Changes = append(plan.Changes, changes...)
+			plan.AffectedFiles = append(plan.AffectedFiles, file.Path)
+			for i, n := range rf.counts {
+				result.counts[i] += n
+			}
+			maps.Copy(result.names, rf.unused)
+		}
+	}
+
+	plan.Impact = &types.ImpactAnalysis{AffectedFiles: plan.AffectedFiles, PotentialIssues: issues}
+	for _, file := range plan.AffectedFiles {
+		if pkg := findPackageForFile(ws, file); pkg != nil && !slices.Contains(plan.Impact.AffectedPackages, pkg.Path) {
+			plan.Impact.AffectedPackages = append(plan.Impact.AffectedPackages, pkg.Path)
+		}
+	}
+	return plan, result, nil
+}
+
+// rewriteFile applies rewrite rules to one Go file of the workspace.
+type rewriteFile struct {
+	ws       *types.Workspace
+	rules    []*rewriteRule
+	file     *types.File
+	src      []byte
+	info     *gotypes.Info    // Nil when the file isn't type-checked
+	pkg      *gotypes.Package // Nil when the file isn't type-checked
+	names    *ImportManager   // Knows the package names of import paths
+	declared map[string]bool  // Top-level names of the file's package
+	counts   []int            // Expressions rewritten by each rule
+
+	imports map[string]string // Import paths by the name the file imports them by
+	paths   []string          // Import paths the rendered replacements name packages by
+	unused  map[string]string // Package names by import path of the imports the rewrite leaves unused
+	bound   map[string][]ast.Expr
+	guards  map[ast.Expr]gotypes.Type
+}
+
+// rewriteMatch is an expression a rule matches, with the expressions bound
+// to its holes.
+type rewriteMatch struct {
+	rule    *rewriteRule
+	node    ast.Expr
+	bound   map[string][]ast.Expr
+	context exprContext
+}
+
+// rewrite returns the changes rewriting the file, or the issue keeping it
+// from being rewritten.
+func (f *rewriteFile) rewrite() ([]types.Change, *types.Issue, error) {
+	f.imports = make(map[string]string)
+	for _, spec := range f.file.AST.Imports {
+		if name, path := f.importName(spec); name != "" {
+			f.imports[name] = path
+		}
+	}
+
+	matches := f.find(f.file.AST, freeContext)
+	if len(matches) == 0 {
+		return nil, nil, nil
+	}
+	var changes []types.Change
+	for _, m := range matches {
+		start, end := f.offset(m.node.Pos()), f.offset(m.node.End())
+		changes = append(changes, types.Change{
+			File:        f.file.Path,
+			Start:       start,
+			End:         end,
+			OldText:     string(f.src[start:end]),
+			NewText:     f.render(m),
+			Description: "rewrite " + m.rule.name,
+		})
+	}
+
+	// Package names the rewritten file refers to, to drop the imports it no
+	// longer uses and name the packages of the replacements by.
+	edited, err := applyInMemory(string(f.src), changes)
+	if err != nil {
+		return nil, nil, err
+	}
+	fset := token.NewFileSet()
+	af, err := parser.ParseFile(fset, f.file.Path, edited, 0)
+	if err != nil {
+		return nil, nil, &types.RefactorError{
+			Type:    types.ParseError,
+			Message: fmt.Sprintf("rewriting %s doesn't produce valid Go: %v", f.file.Path, err),
+		}
+	}
+	used, usedBefore := packageNamesUsed(af), packageNamesUsed(f.file.AST)
+
+	kept := make(map[string]string)
+	unused := make(map[string]*ast.ImportSpec) // By name
+	for _, spec := range f.file.AST.Imports {
+		name, path := f.importName(spec)
+		if name == "" {
+			continue
+		}
+		// An import unused before may be named otherwise than guessed.
+		if used[name] || !usedBefore[name] {
+			kept[name] = path
+			continue
+		}
+		unused[name] = spec
+	}
+
+	names := make([]string, len(f.paths))
+	for i, path := range f.paths {
+		for name, p := range kept {
+			if p == path {
+				names[i] = name
+			}
+		}
+		if names[i] != "" {
+			continue
+		}
+		name, _ := f.names.packageName(path)
+		if other, ok := kept[name]; ok || f.declared[name] {
+			what := "a declaration of the package"
+			if ok {
+				what = strconv.Quote(other)
+			}
+			return nil, &types.Issue{
+				Type:        types.IssueNameConflict,
+				Severity:    types.Warning,
+				Description: fmt.Sprintf("%s not rewritten: %q can't be imported as %s, which names %s there", f.file.Path, path, name, what),
+				File:        f.file.Path,
+			}, nil
+		}
+		names[i] = name
+		kept[name] = path
+		// The import the name no longer refers to imports the path instead.
+		if spec := unused[name]; spec != nil {
+			start, end := f.offset(spec.Path.Pos()), f.offset(spec.Path.End())
+			changes = append(changes, types.Change{
+				File:        f.file.Path,
+				Start:       start,
+				End:         end,
+				OldText:     spec.Path.Value,
+				NewText:     strconv.Quote(path),
+				Description: fmt.Sprintf("import %q in place of %s", path, spec.Path.Value),
+			})
+			delete(unused, name)
+		}
+	}
+	// The import manager removes the imports left unused, once told the
+	// names it can't know.
+	for name, spec := range unused {
+		if spec.Name == nil {
+			_, path := f.importName(spec)
+			f.unused[path] = name
+		}
+	}
+	for i := range changes {
+		for j, name := range names {
+			changes[i].NewText = strings.ReplaceAll(changes[i].NewText, pathPrefix+strconv.Itoa(j), name)
+		}
+	}
+
+	changes, err = rewriteChanges(f.file.Path, f.src, changes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return changes, nil, nil
+}
+
+// importName returns the name spec imports its package by and its path;
+// the name is empty for blank and dot imports.
+func (f *rewriteFile) importName(spec *ast.ImportSpec) (string, string) {
+	path, err := strconv.Unquote(spec.Path.Value)
+	if err != nil {
+		return "", ""
+	}
+	if spec.Name != nil {
+		if spec.Name.Name == "_" || spec.Name.Name == "." {
+			return "", path
+		}
+		return spec.Name.Name, path
+	}
+	if f.info != nil {
+		if pn := f.info.PkgNameOf(spec); pn != nil {
+			return pn.Name(), path
+		}
+	}
+	name, _ := f.names.packageName(path)
+	return name, path
+}
+
+// packageNamesUsed returns the names f refers to packages by: the
+// operands of selectors not resolved within the file.
+func packageNamesUsed(f *ast.File) map[string]bool {
+	used := make(map[string]bool)
+	ast.Inspect(f, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if id, ok := sel.X.(*ast.Ident); ok && id.Obj == nil {
+				used[id.Name] = true
+			}
+		}
+		return true
+	})
+	return used
+}
+
+// offset returns the offset of pos in the file's source.
+func (f *rewriteFile) offset(pos token.Pos) int {
+	return f.ws.FileSet.Position(pos).Offset
+}
+
+// find returns the outermost expressions under root, root included, that
+// a rule matches. ctx is the context of root.
+func (f *rewriteFile) find(root ast.Node, ctx exprContext) []*rewriteMatch {
+	var matches []*rewriteMatch
+	var stack []ast.Node
+	ast.Inspect(root, func(n ast.Node) bool {
+		if n == nil {
+			stack = stack[:len(stack)-1]
+			return true
+		}
+		if gd, ok := n.(*ast.GenDecl); ok && gd.Tok == token.IMPORT {
+			return false
+		}
+		if e, ok := n.(ast.Expr); ok {
+			c := ctx
+			var parent ast.Node
+			if len(stack) > 0 {
+				parent = stack[len(stack)-1]
+				c = contextOf(parent, e)
+			}
+			if parent == nil || isExprPosition(parent, e) {
+				for _, rule := range f.rules {
+					if f.match(rule, e) {
+						matches = append(matches, &rewriteMatch{rule: rule, node: e, bound: f.bound, context: c})
+						return false
+					}
+				}
+			}
+		}
+		stack = append(stack, n)
+		return true
+	})
+	return matches
+}
+
+// isExprPosition reports whether e stands for a value or a type where it
+// is in parent, rather than naming what parent declares, a field or a
+// label.
+func isExprPosition(parent ast.Node, e ast.Expr) bool {
+	switch p := parent.(type) {
+	case *ast.File:
+		return p.Name != e
+	case *ast.SelectorExpr:
+		return p.Sel != e
+	case *ast.FuncDecl:
+		return p.Name != e
+	case *ast.TypeSpec:
+		return p.Name != e
+	case *ast.ValueSpec:
+		return !slices.ContainsFunc(p.Names, func(n *ast.Ident) bool { return n == e })
+	case *ast.Field:
+		return !slices.ContainsFunc(p.Names, func(n *ast.Ident) bool { return n == e })
+	case *ast.LabeledStmt:
+		return p.Label != e
+	case *ast.BranchStmt:
+		return p.Label != e
+	case *ast.AssignStmt:
+		return p.Tok != token.DEFINE || !slices.Contains(p.Lhs, e)
+	case *ast.RangeStmt:
+		return p.Tok != token.DEFINE || p.Key != e && p.Value != e
+	}
+	return true
+}
+
+// render returns the replacement of m, with the holes' expressions
+// rewritten in turn and the packages named by placeholders for the names
+// rewrite picks.
+func (f *rewriteFile) render(m *rewriteMatch) string {
+	r := m.rule
+	var b []byte
+	prev := 0
+	for _, s := range r.slots {
+		b = append(b, r.replacement[prev:s.start]...)
+		prev = s.end
+		if s.path != "" {
+			i := slices.Index(f.paths, s.path)
+			if i < 0 {
+				i = len(f.paths)
+				f.paths = append(f.paths, s.path)
+			}
+			b = append(b, pathPrefix+strconv.Itoa(i)...)
+			continue
+		}
+		nodes := m.bound[s.hole]
+		if len(nodes) == 0 {
+			// An empty list takes a comma next to it along.
+			rest := strings.TrimLeft(r.replacement[prev:], " ")
+			if after, ok := strings.CutPrefix(rest, ","); ok {
+				prev = len(r.replacement) - len(strings.TrimLeft(after, " "))
+			} else {
+				b = bytes.TrimRight(b, " ")
+				b = bytes.TrimSuffix(b, []byte(","))
+			}
+			continue
+		}
+		ctx := s.context
+		if s.start == 0 && s.end == len(r.replacement) {
+			ctx = m.context
+		}
+		text := f.text(nodes, ctx)
+		if len(nodes) == 1 {
+			text = ctx.wrap(nodes[0], text)
+		}
+		b = append(b, text...)
+	}
+	b = append(b, r.replacement[prev:]...)
+	f.counts[slices.Index(f.rules, r)]++
+	return m.context.wrap(r.root, string(b))
+}
+
+// text returns the source of nodes, the elements of a list or a single
+// expression in context ctx, with the expressions in them rules match
+// rewritten.
+func (f *rewriteFile) text(nodes []ast.Expr, ctx exprContext) string {
+	if len(nodes) == 0 {
+		return ""
+	}
+	if len(nodes) > 1 {
+		ctx = freeContext
+	}
+	start, end := f.offset(nodes[0].Pos()), f.offset(nodes[len(nodes)-1].End())
+	var b strings.Builder
+	prev := start
+	for _, n := range nodes {
+		for _, m := range f.find(n, ctx) {
+			b.Write(f.src[prev:f.offset(m.node.Pos())])
+			b.WriteString(f.render(m))
+			prev = f.offset(m.node.End())
+		}
+	}
+	b.Write(f.src[prev:end])
+	return b.String()
+}
+
+var (
+	identType     = reflect.TypeFor[*ast.Ident]()
+	selectorType  = reflect.TypeFor[*ast.SelectorExpr]()
+	callType      = reflect.TypeFor[*ast.CallExpr]()
+	exprSliceType = reflect.TypeFor[[]ast.Expr]()
+	objectType    = reflect.TypeFor[*ast.Object]()
+	commentsType  = reflect.TypeFor[*ast.CommentGroup]()
+	posType       = reflect.TypeFor[token.Pos]()
+)
+
+// match reports whether rule's pattern matches e, binding the holes in
+// f.bound.
+func (f *rewriteFile) match(rule *rewriteRule, e ast.Expr) bool {
+	f.bound = make(map[string][]ast.Expr)
+	return f.matchValue(rule, reflect.ValueOf(rule.pattern), reflect.ValueOf(e))
+}
+
+// matchValue matches the pattern node p against v, as gofmt -r does:
+// field by field, ignoring positions and comments.
+func (f *rewriteFile) matchValue(rule *rewriteRule, p, v reflect.Value) bool {
+	if p.IsValid() && p.Type() == identType {
+		if id := p.Interface().(*ast.Ident); id != nil && strings.HasPrefix(id.Name, holePrefix) {
+			if !v.IsValid() || v.Kind() != reflect.Pointer || v.IsNil() {
+				return false
+			}
+			e, ok := v.Interface().(ast.Expr)
+			return ok && f.bind(rule, strings.TrimPrefix(id.Name, holePrefix), []ast.Expr{e})
+		}
+	}
+	if !p.IsValid() || !v.IsValid() {
+		return !p.IsValid() && !v.IsValid()
+	}
+	if p.Type() != v.Type() {
+		return false
+	}
+
+	switch p.Type() {
+	case identType:
+		pi, vi := p.Interface().(*ast.Ident), v.Interface().(*ast.Ident)
+		return pi == nil && vi == nil || pi != nil && vi != nil && pi.Name == vi.Name
+	case objectType, commentsType, posType:
+		return true
+	case selectorType:
+		// A quoted import path names the package the selector must refer to.
+		ps, vs := p.Interface().(*ast.SelectorExpr), v.Interface().(*ast.SelectorExpr)
+		if lit, ok := ps.X.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+			path, _ := strconv.Unquote(lit.Value)
+			id, ok := vs.X.(*ast.Ident)
+			return ok && f.importPathOf(id) == path && f.matchValue(rule, reflect.ValueOf(ps.Sel), reflect.ValueOf(vs.Sel))
+		}
+	case callType:
+		// f(x) and f(x...) differ only by the position of the ellipsis.
+		if p.Interface().(*ast.CallExpr).Ellipsis.IsValid() != v.Interface().(*ast.CallExpr).Ellipsis.IsValid() {
+			return false
+		}
+	case exprSliceType:
+		return f.matchList(rule, p.Interface().([]ast.Expr), v.Interface().([]ast.Expr))
+	}
+
+	p, v = reflect.Indirect(p), reflect.Indirect(v)
+	if !p.IsValid() || !v.IsValid() {
+		return !p.IsValid() && !v.IsValid()
+	}
+	switch p.Kind() {
+	case reflect.Slice:
+		if p.Len() != v.Len() {
+			return false
+		}
+		for i := range p.Len() {
+			if !f.matchValue(rule, p.Index(i), v.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Struct:
+		for i := range p.NumField() {
+			if !f.matchValue(rule, p.Field(i), v.Field(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Interface:
+		return f.matchValue(rule, p.Elem(), v.Elem())
+	}
+	return p.Interface() == v.Interface()
+}
+
+// matchList matches a list of pattern expressions, in which the first
+// variadic hole takes the elements the others leave.
+func (f *rewriteFile) matchList(rule *rewriteRule, ps, vs []ast.Expr) bool {
+	k := slices.IndexFunc(ps, func(e ast.Expr) bool {
+		id, ok := e.(*ast.Ident)
+		if !ok {
+			return false
+		}
+		name, ok := strings.CutPrefix(id.Name, holePrefix)
+		return ok && rule.holes[name].variadic
+	})
+	if k < 0 {
+		if len(ps) != len(vs) {
+			return false
+		}
+		k = len(ps)
+	} else if len(vs) < len(ps)-1 {
+		return false
+	}
+	tail := len(ps) - k - 1
+	for i := range k {
+		if !f.matchValue(rule, reflect.ValueOf(ps[i]), reflect.ValueOf(vs[i])) {
+			return false
+		}
+	}
+	if k == len(ps) {
+		return true
+	}
+	for i := range tail {
+		if !f.matchValue(rule, reflect.ValueOf(ps[k+1+i]), reflect.ValueOf(vs[len(vs)-tail+i])) {
+			return false
+		}
+	}
+	name := strings.TrimPrefix(ps[k].(*ast.Ident).Name, holePrefix)
+	return f.bind(rule, name, vs[k:len(vs)-tail])
+}
+
+// bind binds nodes to the hole name if they meet its constraints and, when
+// it is bound already, are the same expressions.
+func (f *rewriteFile) bind(rule *rewriteRule, name string, nodes []ast.Expr) bool {
+	hole := rule.holes[name]
+	if hole.re != nil && !hole.re.MatchString(f.source(nodes)) {
+		return false
+	}
+	if hole.guard != nil {
+		for _, n := range nodes {
+			if !f.assignable(n, hole.guard) {
+				return false
+			}
+		}
+	}
+	if strings.HasPrefix(name, "_") {
+		return true
+	}
+	if prev, ok := f.bound[name]; ok {
+		return slices.EqualFunc(prev, nodes, func(a, b ast.Expr) bool {
+			return gotypes.ExprString(a) == gotypes.ExprString(b)
+		})
+	}
+	f.bound[name] = nodes
+	return true
+}
+
+// source returns the source nodes span.
+func (f *rewriteFile) source(nodes []ast.Expr) string {
+	if len(nodes) == 0 {
+		return ""
+	}
+	return string(f.src[f.offset(nodes[0].Pos()):f.offset(nodes[len(nodes)-1].End())])
+}
+
+// importPathOf returns the import path of the package id refers to, or ""
+// when it doesn't refer to one.
+func (f *rewriteFile) importPathOf(id *ast.Ident) string {
+	if f.info != nil {
+		if obj := f.info.Uses[id]; obj != nil {
+			if pn, ok := obj.(*gotypes.PkgName); ok {
+				return pn.Imported().Path()
+			}
+			return ""
+		}
+	}
+	if id.Obj != nil {
+		return ""
+	}
+	return f.imports[id.Name]
+}
+
+// assignable reports whether e is of a type assignable to the guard type.
+func (f *rewriteFile) assignable(e ast.Expr, guard ast.Expr) bool {
+	if f.info == nil {
+		return false
+	}
+	// Untyped nil has no type to meet the guard with.
+	t := f.info.TypeOf(e)
+	if t == nil || t == gotypes.Typ[gotypes.UntypedNil] {
+		return false
+	}
+	g, ok := f.guards[guard]
+	if !ok {
+		g = f.guardType(guard)
+		f.guards[guard] = g
+	}
+	return g != nil && gotypes.AssignableTo(t, g)
+}
+
+// guardType returns the type a guard expression denotes: a predeclared
+// type, one of the package or of a package it imports, named by its
+// package name or quoted import path, or a pointer, slice or map of those.
+func (f *rewriteFile) guardType(e ast.Expr) gotypes.Type {
+	switch e := e.(type) {
+	case *ast.ParenExpr:
+		return f.guardType(e.X)
+	case *ast.Ident:
+		if tn, ok := gotypes.Universe.Lookup(e.Name).(*gotypes.TypeName); ok {
+			return tn.Type()
+		}
+		if tn, ok := f.pkg.Scope().Lookup(e.Name).(*gotypes.TypeName); ok {
+			return tn.Type()
+		}
+	case *ast.SelectorExpr:
+		var pkg *gotypes.Package
+		switch x := e.X.(type) {
+		case *ast.BasicLit:
+			path, _ := strconv.Unquote(x.Value)
+			pkg = findImportedPackage(f.pkg, func(p *gotypes.Package) bool { return p.Path() == path })
+		case *ast.Ident:
+			path, imported := f.imports[x.Name]
+			pkg = findImportedPackage(f.pkg, func(p *gotypes.Package) bool {
+				return imported && p.Path() == path || !imported && p.Name() == x.Name
+			})
+		}
+		if pkg == nil {
+			return nil
+		}
+		if tn, ok := pkg.Scope().Lookup(e.Sel.Name).(*gotypes.TypeName); ok {
+			return tn.Type()
+		}
+	case *ast.StarExpr:
+		if elem := f.guardType(e.X); elem != nil {
+			return gotypes.NewPointer(elem)
+		}
+	case *ast.ArrayType:
+		if elem := f.guardType(e.Elt); elem != nil && e.Len == nil {
+			return gotypes.NewSlice(elem)
+		}
+	case *ast.MapType:
+		key, elem := f.guardType(e.Key), f.guardType(e.Value)
+		if key != nil && elem != nil {
+			return gotypes.NewMap(key, elem)
+		}
+	case *ast.InterfaceType:
+		if len(e.Methods.List) == 0 {
+			return gotypes.Universe.Lookup("any").Type()
+		}
+	}
+	return nil
+}
+
+// findImportedPackage returns the first package pkg imports, directly or
+// not, for which match reports true.
+func findImportedPackage(pkg *gotypes.Package, match func(*gotypes.Package) bool) *gotypes.Package {
+	seen := map[*gotypes.Package]bool{pkg: true}
+	queue := []*gotypes.Package{pkg}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		for _, imp := range p.Imports() {
+			if seen[imp] {
+				continue
+			}
+			if match(imp) {
+				return imp
+			}
+			seen[imp] = true
+			queue = append(queue, imp)
+		}
+	}
+	return nil
+}
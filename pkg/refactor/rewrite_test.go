@@ -0,0 +1,116 @@
+package refactor
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+var rewriteFiles = map[string]string{
+	"xerrors/xerrors.go": `package xerrors
+
+func Wrap(err error, msg string) error { return err }
+`,
+	"app/load.go": `package app
+
+import (
+	"os"
+
+	"example.com/p/xerrors"
+)
+
+func Load(path string) error {
+	_, err := os.ReadFile(path)
+	return xerrors.Wrap(xerrors.Wrap(err, "read"), "load "+path)
+}
+`,
+	"app/nil.go": `package app
+
+import "example.com/p/xerrors"
+
+func Nil() error {
+	return xerrors.Wrap(nil, "nothing")
+}
+`,
+	"app/math.go": `package app
+
+import "fmt"
+
+func double(x int) int { return x * 2 }
+
+func Sum(a, b, y int) string {
+	n := double(a+b) - double(y)
+	n = -double(-n)
+	return fmt.Sprintf("%d", n) + fmt.Sprintf("%s: %d", "n", n) + fmt.Sprintf("%s", "x", "y")
+}
+`,
+}
+
+func TestRewrite(t *testing.T) {
+	engine, ws, dir := loadTestModuleWith(t, rewriteFiles)
+
+	plan, err := engine.Rewrite(ws, types.RewriteRequest{
+		Pattern:     `"example.com/p/xerrors".Wrap(:[err], :[msg])`,
+		Replacement: `fmt.Errorf("%s: %w", :[msg], :[err])`,
+		Where:       map[string]string{"err": "error"},
+	})
+	if err != nil {
+		t.Fatalf("Rewrite: %v", err)
+	}
+
+	load := planContent(t, plan, filepath.Join(dir, "app", "load.go"))
+	if want := `return fmt.Errorf("%s: %w", "load "+path, fmt.Errorf("%s: %w", "read", err))`; !strings.Contains(load, want) {
+		t.Errorf("load.go lacks %q:\n%s", want, load)
+	}
+	if strings.Contains(load, "xerrors") || !strings.Contains(load, `"fmt"`) {
+		t.Errorf("load.go should import fmt instead of xerrors:\n%s", load)
+	}
+	for _, c := range plan.Changes {
+		if strings.HasSuffix(c.File, "nil.go") {
+			t.Errorf("nil isn't an error, nil.go should be left alone: %+v", c)
+		}
+	}
+}
+
+func TestRewrite_Holes(t *testing.T) {
+	engine, ws, dir := loadTestModuleWith(t, rewriteFiles)
+	path := filepath.Join(dir, "app", "math.go")
+
+	plan, err := engine.Rewrite(ws, types.RewriteRequest{Pattern: "double(:[x])", Replacement: ":[x] * 2", Package: "app"})
+	if err != nil {
+		t.Fatalf("Rewrite: %v", err)
+	}
+	math := planContent(t, plan, path)
+	for _, want := range []string{"n := (a+b)*2 - y*2", "n = -(-n * 2)"} {
+		if !strings.Contains(math, want) {
+			t.Errorf("math.go lacks %q:\n%s", want, math)
+		}
+	}
+
+	plan, err = engine.Rewrite(ws, types.RewriteRequest{Pattern: `fmt.Sprintf(:[f~"%s.*"], :[_], :[args...])`, Replacement: "fmt.Sprint(:[args...])"})
+	if err != nil {
+		t.Fatalf("Rewrite: %v", err)
+	}
+	if want := `fmt.Sprintf("%d", n) + fmt.Sprint(n) + fmt.Sprint("y")`; !strings.Contains(planContent(t, plan, path), want) {
+		t.Errorf("math.go lacks %q:\n%s", want, planContent(t, plan, path))
+	}
+}
+
+func TestRewrite_Invalid(t *testing.T) {
+	engine, ws, _ := loadTestModuleWith(t, rewriteFiles)
+	for _, req := range []types.RewriteRequest{
+		{Pattern: "f(:[x])"},
+		{Pattern: "f(:[x]", Replacement: "g(:[x])"},
+		{Pattern: "f(:[x])", Replacement: "g(:[y])"},
+		{Pattern: "f(:[x...])", Replacement: "g(:[x])"},
+		{Pattern: "f(:[x])", Replacement: "g(:[x])", Where: map[string]string{"y": "int"}},
+		{Pattern: "f(:[x~(])", Replacement: "g(:[x])"},
+		{Pattern: "f(:[x])", Replacement: "g(:[x])", Package: "nowhere"},
+	} {
+		if _, err := engine.Rewrite(ws, req); err == nil {
+			t.Errorf("expected %+v to fail", req)
+		}
+	}
+}
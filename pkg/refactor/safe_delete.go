@@ -3,8 +3,6 @@ package refactor
 import (
 	"fmt"
 	"go/ast"
-	"io"
-	"log/slog"
 
 	"github.com/mamaar/gorefactor/pkg/analysis"
 	pkgtypes "github.com/mamaar/gorefactor/pkg/types"
@@ -16,6 +14,8 @@ type SafeDeleteOperation struct {
 	SourceFile string
 	Scope      pkgtypes.RenameScope // PackageScope or WorkspaceScope
 	Force      bool                 // If true, delete even if references exist
+
+	refs *analysis.ReferenceFinder
 }
 
 func (op *SafeDeleteOperation) Type() pkgtypes.OperationType {
@@ -65,7 +65,8 @@ func (op *SafeDeleteOperation) Validate(ws *pkgtypes.Workspace) error {
 	}
 
 	// Find the symbol to delete
-	resolver := analysis.NewSymbolResolver(ws, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	refs := op.referenceFinder(ws)
+	resolver := refs.Resolver()
 	symbol, err := resolver.ResolveSymbol(sourcePackage, op.SymbolName)
 	if err != nil {
 		return &pkgtypes.RefactorError{
@@ -76,7 +77,7 @@ func (op *SafeDeleteOperation) Validate(ws *pkgtypes.Workspace) error {
 
 	// Check if symbol is safe to delete (no references unless forced)
 	if !op.Force {
-		references, err := resolver.FindReferences(symbol)
+		references, err := refs.FindReferences(symbol)
 		if err != nil {
 			return &pkgtypes.RefactorError{
 				Type:    pkgtypes.InvalidOperation,
@@ -102,6 +103,14 @@ func (op *SafeDeleteOperation) Validate(ws *pkgtypes.Workspace) error {
 	return nil
 }
 
+// referenceFinder returns the finder shared by Validate and Execute.
+func (op *SafeDeleteOperation) referenceFinder(ws *pkgtypes.Workspace) *analysis.ReferenceFinder {
+	if op.refs == nil {
+		op.refs = newReferenceFinder(ws)
+	}
+	return op.refs
+}
+
 func (op *SafeDeleteOperation) Execute(ws *pkgtypes.Workspace) (*pkgtypes.RefactoringPlan, error) {
 	// Find the source file and symbol
 	var sourceFile *pkgtypes.File
@@ -125,7 +134,8 @@ func (op *SafeDeleteOperation) Execute(ws *pkgtypes.Workspace) (*pkgtypes.Refact
 		}
 	}
 
-	resolver := analysis.NewSymbolResolver(ws, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	refs := op.referenceFinder(ws)
+	resolver := refs.Resolver()
 	symbol, err := resolver.ResolveSymbol(sourcePackage, op.SymbolName)
 	if err != nil {
 		return nil, err
@@ -153,7 +163,7 @@ func (op *SafeDeleteOperation) Execute(ws *pkgtypes.Workspace) (*pkgtypes.Refact
 
 	// If forced deletion, also remove all references
 	if op.Force {
-		references, err := resolver.FindReferences(symbol)
+		references, err := refs.FindReferences(symbol)
 		if err != nil {
 			return nil, err
 		}
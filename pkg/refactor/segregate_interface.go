@@ -0,0 +1,422 @@
+package refactor
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	gotypes "go/types"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mamaar/gorefactor/pkg/analysis"
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// SegregateInterfaceOperation splits an interface into role interfaces that
+// the original embeds, so it keeps its method set, and narrows the
+// parameters of its consumers to the smallest role covering the methods
+// they call. Without explicit roles, one role is derived for each distinct
+// set of methods the consumers call, as reported by
+// analysis.AnalyzeInterfaceConsumers. Roles may overlap.
+type SegregateInterfaceOperation struct {
+	Request types.SegregateInterfaceRequest
+	Parser  *analysis.GoParser // Type-checks packages on demand; may be nil if they already are
+
+	// Resolved by Validate
+	iface     *ShrinkInterfaceOperation // Locates the interface and renders the split
+	consumers []*analysis.InterfaceConsumer
+}
+
+func (op *SegregateInterfaceOperation) Type() types.OperationType {
+	return types.SegregateInterfaceOperation
+}
+
+func (op *SegregateInterfaceOperation) Description() string {
+	return fmt.Sprintf("Segregate interface %s", op.Request.InterfaceName)
+}
+
+func (op *SegregateInterfaceOperation) Validate(ws *types.Workspace) error {
+	req := op.Request
+	if req.InterfaceName == "" {
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: "interface name is required",
+		}
+	}
+	op.iface = &ShrinkInterfaceOperation{Request: types.ShrinkInterfaceRequest{
+		InterfaceName:       req.InterfaceName,
+		Package:             req.Package,
+		KeepImplementations: true,
+	}}
+	if err := op.iface.findInterface(ws); err != nil {
+		return err
+	}
+
+	// Consumers are only complete when every package is type-checked.
+	if op.Parser != nil {
+		for _, pkgPath := range slices.Sorted(maps.Keys(ws.Packages)) {
+			op.Parser.EnsureTypeChecked(ws, ws.Packages[pkgPath])
+		}
+	}
+	pkg := op.iface.pkg
+	if pkg.TypesInfo == nil {
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("package %s could not be type-checked; interface consumers cannot be determined", pkg.ImportPath),
+		}
+	}
+	tn, _ := pkg.TypesInfo.Defs[op.iface.typeSpec().Name].(*gotypes.TypeName)
+	if tn == nil {
+		return &types.RefactorError{
+			Type:    types.SymbolNotFound,
+			Message: fmt.Sprintf("no type information for interface %s", req.InterfaceName),
+		}
+	}
+	op.iface.named = tn.Type().(*gotypes.Named)
+	op.consumers = analysis.AnalyzeInterfaceConsumers(ws, op.iface.named)
+
+	roles := req.Roles
+	if len(roles) == 0 {
+		roles = op.deriveRoles()
+		if len(roles) == 0 {
+			return &types.RefactorError{
+				Type:    types.InvalidOperation,
+				Message: fmt.Sprintf("no consumer of %s calls a subset of its own methods; specify the roles", req.InterfaceName),
+			}
+		}
+	}
+
+	declared := op.iface.declaredMethods()
+	op.iface.roleOf = make(map[string]string)
+	roleNames := make(map[string]bool)
+	for _, role := range roles {
+		if !isValidGoIdentifier(role.Name) || token.IsKeyword(role.Name) {
+			return &types.RefactorError{
+				Type:    types.InvalidOperation,
+				Message: fmt.Sprintf("%q is not a valid role interface name", role.Name),
+			}
+		}
+		if pkg.TypesPkg.Scope().Lookup(role.Name) != nil || roleNames[role.Name] {
+			return &types.RefactorError{
+				Type:    types.NameConflict,
+				Message: fmt.Sprintf("%s is already declared in package %s; specify the roles", role.Name, pkg.ImportPath),
+			}
+		}
+		roleNames[role.Name] = true
+		if len(role.Methods) == 0 {
+			return &types.RefactorError{
+				Type:    types.InvalidOperation,
+				Message: fmt.Sprintf("role interface %s has no methods", role.Name),
+			}
+		}
+		for _, method := range role.Methods {
+			if declared[method] == nil {
+				return &types.RefactorError{
+					Type:    types.InvalidOperation,
+					Message: fmt.Sprintf("%s does not declare method %s itself; only its own methods can move to role %s", req.InterfaceName, method, role.Name),
+				}
+			}
+			if op.iface.roleOf[method] == "" {
+				op.iface.roleOf[method] = role.Name
+			}
+		}
+	}
+	op.iface.Request.Roles = roles
+	return nil
+}
+
+// deriveRoles returns a role for each distinct set of the interface's own
+// methods that a consumer calls, smaller than the interface itself.
+func (op *SegregateInterfaceOperation) deriveRoles() []types.RoleInterface {
+	declared := op.iface.declaredMethods()
+	var order []string
+	for _, field := range op.iface.iface.Methods.List {
+		if len(field.Names) == 1 {
+			order = append(order, field.Names[0].Name)
+		}
+	}
+	total := op.iface.named.Underlying().(*gotypes.Interface).NumMethods()
+	exported := token.IsExported(op.Request.InterfaceName)
+
+	var roles []types.RoleInterface
+	seen := make(map[string]bool)
+	for _, c := range op.consumers {
+		if c.Escapes != "" || len(c.Methods) == 0 || len(c.Methods) == total {
+			continue
+		}
+		if slices.ContainsFunc(c.Methods, func(m string) bool { return declared[m] == nil }) {
+			continue
+		}
+		methods := slices.DeleteFunc(slices.Clone(order), func(m string) bool { return !slices.Contains(c.Methods, m) })
+		key := strings.Join(methods, ",")
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		roles = append(roles, types.RoleInterface{Name: roleName(methods, exported), Methods: methods})
+	}
+	return roles
+}
+
+func (op *SegregateInterfaceOperation) Execute(ws *types.Workspace) (*types.RefactoringPlan, error) {
+	plan, err := op.iface.Execute(ws)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, issues := op.narrowConsumers(ws)
+	plan.Changes = append(plan.Changes, changes...)
+	plan.Impact.PotentialIssues = append(plan.Impact.PotentialIssues, issues...)
+	for _, change := range changes {
+		if !slices.Contains(plan.AffectedFiles, change.File) {
+			plan.AffectedFiles = append(plan.AffectedFiles, change.File)
+		}
+	}
+	plan.Impact.AffectedFiles = plan.AffectedFiles
+	return plan, nil
+}
+
+// narrowConsumers rewrites consumer parameters to the smallest role covering
+// the methods they call, and explains why the others keep the interface.
+func (op *SegregateInterfaceOperation) narrowConsumers(ws *types.Workspace) ([]types.Change, []types.Issue) {
+	var changes []types.Change
+	var issues []types.Issue
+	keep := func(c *analysis.InterfaceConsumer, reason string) {
+		issues = append(issues, types.Issue{
+			Type:        types.IssueTypeMismatch,
+			Description: fmt.Sprintf("parameter %s of %s keeps %s: %s", c.Param, c.Function, op.Request.InterfaceName, reason),
+			File:        c.File,
+			Line:        c.Line,
+			Severity:    types.Info,
+		})
+	}
+
+	valueUses := funcValueUses(ws)
+	referenced := analysis.ReferencedInterfaces(ws)
+	byField := make(map[*ast.Field]map[string]string)
+	var fields []*ast.Field
+	fieldFile := make(map[*ast.Field]*types.File)
+	fieldFunc := make(map[*ast.Field]*ast.FuncDecl)
+
+	for _, c := range op.consumers {
+		file := ws.Packages[c.Package].Files[filepath.Base(c.File)]
+		if file == nil {
+			continue
+		}
+		fd, field := paramField(file.AST, c.Pos)
+		if field == nil {
+			continue
+		}
+		role := op.narrowestRole(c)
+		switch {
+		case c.Escapes != "":
+			keep(c, c.Escapes)
+			continue
+		case len(c.Methods) == 0:
+			keep(c, "it calls no methods")
+			continue
+		case role == "":
+			keep(c, "no role covers the methods it calls")
+			continue
+		case file.Package != op.iface.pkg && !token.IsExported(role):
+			keep(c, fmt.Sprintf("role %s is not exported", role))
+			continue
+		}
+		if reason := op.signatureFixed(ws, file, fd, valueUses, referenced); reason != "" {
+			keep(c, reason)
+			continue
+		}
+		if byField[field] == nil {
+			byField[field] = make(map[string]string)
+			fields = append(fields, field)
+			fieldFile[field], fieldFunc[field] = file, fd
+		}
+		byField[field][c.Param] = role
+	}
+
+	for _, field := range fields {
+		file, roles := fieldFile[field], byField[field]
+		typeText := func(role string) string {
+			if sel, ok := field.Type.(*ast.SelectorExpr); ok {
+				return nodeText(ws, file, sel.X.Pos(), sel.X.End()) + "." + role
+			}
+			return role
+		}
+		desc := fmt.Sprintf("Narrow parameter of %s to role interfaces of %s", funcName(fieldFunc[field]), op.Request.InterfaceName)
+
+		distinct := slices.Compact(slices.Sorted(maps.Values(roles)))
+		if len(roles) == len(field.Names) && len(distinct) == 1 {
+			changes = append(changes, types.Change{
+				File:        file.Path,
+				Start:       ws.FileSet.Position(field.Type.Pos()).Offset,
+				End:         ws.FileSet.Position(field.Type.End()).Offset,
+				OldText:     nodeText(ws, file, field.Type.Pos(), field.Type.End()),
+				NewText:     typeText(distinct[0]),
+				Description: desc,
+			})
+			continue
+		}
+		// The names sharing the type now need different ones.
+		original := nodeText(ws, file, field.Type.Pos(), field.Type.End())
+		var parts []string
+		for _, name := range field.Names {
+			t := original
+			if role, ok := roles[name.Name]; ok {
+				t = typeText(role)
+			}
+			parts = append(parts, name.Name+" "+t)
+		}
+		changes = append(changes, types.Change{
+			File:        file.Path,
+			Start:       ws.FileSet.Position(field.Pos()).Offset,
+			End:         ws.FileSet.Position(field.Type.End()).Offset,
+			OldText:     nodeText(ws, file, field.Pos(), field.Type.End()),
+			NewText:     strings.Join(parts, ", "),
+			Description: desc,
+		})
+	}
+	return changes, issues
+}
+
+// narrowestRole returns the role with the fewest methods that covers the
+// methods c calls, or "" when none does.
+func (op *SegregateInterfaceOperation) narrowestRole(c *analysis.InterfaceConsumer) string {
+	best := -1
+	for i, role := range op.iface.Request.Roles {
+		if !slices.ContainsFunc(c.Methods, func(m string) bool { return !slices.Contains(role.Methods, m) }) &&
+			(best < 0 || len(role.Methods) < len(op.iface.Request.Roles[best].Methods)) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return ""
+	}
+	return op.iface.Request.Roles[best].Name
+}
+
+// signatureFixed explains why the signature of fd must not change, or
+// returns "" when it may.
+func (op *SegregateInterfaceOperation) signatureFixed(ws *types.Workspace, file *types.File, fd *ast.FuncDecl, valueUses map[gotypes.Object]bool, referenced []*gotypes.Named) string {
+	info := file.Package.TypesInfo
+	if info == nil {
+		return "its package could not be type-checked"
+	}
+	obj := info.Defs[fd.Name]
+	if valueUses[obj] {
+		return "the function is used as a value"
+	}
+	fn, ok := obj.(*gotypes.Func)
+	if !ok || fd.Recv == nil {
+		return ""
+	}
+	recv := fn.Type().(*gotypes.Signature).Recv().Type()
+	if p, ok := recv.(*gotypes.Pointer); ok {
+		recv = p.Elem()
+	}
+	for _, other := range referenced {
+		iface := other.Underlying().(*gotypes.Interface)
+		if !slices.ContainsFunc(slices.Collect(iface.Methods()), func(m *gotypes.Func) bool { return m.Name() == fn.Name() }) {
+			continue
+		}
+		if gotypes.Implements(recv, iface) || gotypes.Implements(gotypes.NewPointer(recv), iface) {
+			return fmt.Sprintf("the method implements %s", other.Obj().Name())
+		}
+	}
+	return ""
+}
+
+// funcValueUses collects the functions and methods referred to other than
+// by calling them, whose signatures other code may depend on.
+func funcValueUses(ws *types.Workspace) map[gotypes.Object]bool {
+	uses := make(map[gotypes.Object]bool)
+	for _, pkg := range ws.Packages {
+		if pkg.TypesInfo == nil {
+			continue
+		}
+		for _, file := range pkg.Files {
+			if file.AST == nil {
+				continue
+			}
+			called := make(map[*ast.Ident]bool)
+			ast.Inspect(file.AST, func(n ast.Node) bool {
+				if call, ok := n.(*ast.CallExpr); ok {
+					if id := calleeIdent(call.Fun); id != nil {
+						called[id] = true
+					}
+				}
+				return true
+			})
+			ast.Inspect(file.AST, func(n ast.Node) bool {
+				if id, ok := n.(*ast.Ident); ok && !called[id] {
+					if fn, ok := pkg.TypesInfo.Uses[id].(*gotypes.Func); ok {
+						uses[fn] = true
+					}
+				}
+				return true
+			})
+		}
+	}
+	return uses
+}
+
+// paramField returns the function declaration and parameter field declaring
+// the parameter named at pos.
+func paramField(file *ast.File, pos token.Pos) (*ast.FuncDecl, *ast.Field) {
+	if file == nil {
+		return nil, nil
+	}
+	for _, decl := range file.Decls {
+		fd, ok := decl.(*ast.FuncDecl)
+		if !ok || pos < fd.Type.Params.Pos() || pos >= fd.Type.Params.End() {
+			continue
+		}
+		for _, field := range fd.Type.Params.List {
+			for _, name := range field.Names {
+				if name.Pos() == pos {
+					return fd, field
+				}
+			}
+		}
+	}
+	return nil, nil
+}
+
+// roleName names a role after its methods the way io names its interfaces:
+// Get and Put make GetPutter.
+func roleName(methods []string, exported bool) string {
+	last := methods[len(methods)-1]
+	var agent string
+	switch n := len(last); {
+	case strings.HasSuffix(last, "e"):
+		agent = last + "r"
+	case n > 1 && last[n-1] == 'y' && !strings.ContainsRune("aeiou", rune(last[n-2])):
+		agent = last[:n-1] + "ier"
+	case n == 3 && !strings.ContainsRune("aeiouwxy", rune(last[2])) &&
+		strings.ContainsRune("aeiou", rune(last[1])) && !strings.ContainsRune("aeiou", rune(last[0])):
+		// Get -> Getter
+		agent = last + last[2:] + "er"
+	default:
+		agent = last + "er"
+	}
+
+	var b strings.Builder
+	for _, m := range methods[:len(methods)-1] {
+		b.WriteString(upperFirst(m))
+	}
+	b.WriteString(upperFirst(agent))
+	name := b.String()
+	if !exported {
+		r, size := utf8.DecodeRuneInString(name)
+		name = string(unicode.ToLower(r)) + name[size:]
+	}
+	return name
+}
+
+func upperFirst(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(r)) + s[size:]
+}
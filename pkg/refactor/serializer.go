@@ -19,6 +19,8 @@ type Serializer struct {
 	fileSet          *token.FileSet
 	modulePath       string
 	workspaceModules []string
+	style            FormatStyle
+	progress         refactorTypes.ProgressReporter
 }
 
 func NewSerializer() *Serializer {
@@ -34,6 +36,18 @@ func (s *Serializer) SetModuleInfo(modulePath string, workspaceModules []string)
 	s.workspaceModules = workspaceModules
 }
 
+// SetFormatStyle configures the formatter and import grouping applied to
+// every Go file written by ApplyChanges.
+func (s *Serializer) SetFormatStyle(style FormatStyle) {
+	s.style = style
+}
+
+// SetProgressReporter sets a reporter that receives a PhaseApply event for
+// every file written by ApplyChanges.
+func (s *Serializer) SetProgressReporter(r refactorTypes.ProgressReporter) {
+	s.progress = r
+}
+
 // ApplyChanges applies a list of changes to the workspace files
 func (s *Serializer) ApplyChanges(ws *refactorTypes.Workspace, changes []refactorTypes.Change) error {
 	if len(changes) == 0 {
@@ -47,6 +61,7 @@ func (s *Serializer) ApplyChanges(ws *refactorTypes.Workspace, changes []refacto
 	}
 
 	// Apply changes to each file
+	applied := 0
 	for filePath, changesForFile := range fileChanges {
 		if err := s.applyChangesToFile(filePath, changesForFile); err != nil {
 			return &refactorTypes.RefactorError{
@@ -54,6 +69,15 @@ func (s *Serializer) ApplyChanges(ws *refactorTypes.Workspace, changes []refacto
 				Message: fmt.Sprintf("failed to apply changes to file %s: %v", filePath, err),
 			}
 		}
+		applied++
+		if s.progress != nil {
+			s.progress(refactorTypes.ProgressEvent{
+				Phase:   refactorTypes.PhaseApply,
+				Package: filePath,
+				Current: applied,
+				Total:   len(fileChanges),
+			})
+		}
 	}
 
 	return nil
@@ -123,6 +147,34 @@ func (s *Serializer) applyChangesToFile(filePath string, changes []refactorTypes
 		}
 	}
 
+	modifiedContent, err := s.renderFile(filePath, string(content), changes)
+	if err != nil {
+		return err
+	}
+
+	// A file whose whole content was removed (e.g. moved or renamed) is deleted.
+	if modifiedContent == "" && len(content) > 0 {
+		if err := os.Remove(filePath); err != nil {
+			return fmt.Errorf("failed to remove file: %v", err)
+		}
+		return nil
+	}
+
+	// Write the modified content back to the file
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filePath, []byte(modifiedContent), 0644); err != nil {
+		return fmt.Errorf("failed to write file: %v", err)
+	}
+
+	return nil
+}
+
+// renderFile applies changes to the content of filePath and, for Go files,
+// organizes imports and formats the result, without touching disk. An empty
+// result means the file is to be deleted.
+func (s *Serializer) renderFile(filePath, content string, changes []refactorTypes.Change) (string, error) {
 	// Sort changes by position in reverse order so we can apply them without affecting positions
 	sort.Slice(changes, func(i, j int) bool {
 		return changes[i].Start > changes[j].Start
@@ -130,22 +182,33 @@ func (s *Serializer) applyChangesToFile(filePath string, changes []refactorTypes
 
 	// Validate that changes don't overlap
 	if err := s.validateChangePositions(changes); err != nil {
-		return fmt.Errorf("invalid change positions: %v", err)
+		return "", fmt.Errorf("invalid change positions: %v", err)
 	}
 
 	// Apply changes
-	modifiedContent := string(content)
+	modifiedContent := content
 	for _, change := range changes {
+		var err error
 		modifiedContent, err = s.applyChange(modifiedContent, change)
 		if err != nil {
-			return fmt.Errorf("failed to apply change: %v", err)
+			return "", fmt.Errorf("failed to apply change: %v", err)
 		}
 	}
+	if modifiedContent == "" {
+		return "", nil
+	}
 
 	// Organize imports and format the modified content if it's Go code
 	if strings.HasSuffix(filePath, ".go") {
-		if s.modulePath != "" {
-			modifiedContent = organizeImports(modifiedContent, s.modulePath, s.workspaceModules)
+		if s.style.FixImports {
+			if fixed, err := fixImports(filePath, modifiedContent); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to fix imports of %s: %v\n", filePath, err)
+			} else {
+				modifiedContent = fixed
+			}
+		}
+		if classify := s.style.classifier(s.modulePath, s.workspaceModules); classify != nil {
+			modifiedContent = groupImports(modifiedContent, classify)
 		}
 
 		formatted, err := s.formatGoCode(modifiedContent)
@@ -155,18 +218,17 @@ func (s *Serializer) applyChangesToFile(filePath string, changes []refactorTypes
 			fmt.Fprintf(os.Stderr, "Warning: failed to format %s: %v\n", filePath, err)
 		} else {
 			modifiedContent = formatted
+			if command := s.style.command(); len(command) > 0 {
+				if formatted, err := runFormatter(command, filepath.Dir(filePath), modifiedContent); err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: failed to format %s: %v\n", filePath, err)
+				} else {
+					modifiedContent = formatted
+				}
+			}
 		}
 	}
 
-	// Write the modified content back to the file
-	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
-		return fmt.Errorf("failed to create directory: %v", err)
-	}
-	if err := os.WriteFile(filePath, []byte(modifiedContent), 0644); err != nil {
-		return fmt.Errorf("failed to write file: %v", err)
-	}
-
-	return nil
+	return modifiedContent, nil
 }
 
 // applyChange applies a single change to the content
@@ -686,4 +686,103 @@ line 5
 			t.Errorf("Expected line %d to be '%s', got '%s'", i, expectedLine, lines[i])
 		}
 	}
-}
\ No newline at end of file
+}
+
+func TestSerializer_FormatStyle(t *testing.T) {
+	src := `package test
+
+import (
+	"example.com/m/store"
+	"github.com/acme/log"
+	"fmt"
+)
+
+var _ = fmt.Sprint(store.X, log.Y)
+
+func Original() {}
+`
+	tests := []struct {
+		name  string
+		style FormatStyle
+		want  string
+	}{
+		{
+			name:  "grouped",
+			style: FormatStyle{},
+			want:  "import (\n\t\"fmt\"\n\n\t\"github.com/acme/log\"\n\n\t\"example.com/m/store\"\n)",
+		},
+		{
+			name:  "std",
+			style: FormatStyle{Imports: ImportsStd},
+			want:  "import (\n\t\"fmt\"\n\n\t\"example.com/m/store\"\n\t\"github.com/acme/log\"\n)",
+		},
+		{
+			name:  "std with local prefixes",
+			style: FormatStyle{Imports: ImportsStd, LocalPrefixes: []string{"github.com/acme"}},
+			want:  "import (\n\t\"fmt\"\n\n\t\"example.com/m/store\"\n\n\t\"github.com/acme/log\"\n)",
+		},
+		{
+			name:  "none",
+			style: FormatStyle{Imports: ImportsNone},
+			want:  "import (\n\t\"example.com/m/store\"\n\t\"fmt\"\n\t\"github.com/acme/log\"\n)", // Only sorted by gofmt
+		},
+		{
+			name:  "command",
+			style: FormatStyle{Formatter: FormatterCommand, Command: []string{"sh", "-c", "cat; echo '// formatted'"}},
+			want:  "func Modified() {}\n// formatted\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "test.go")
+			if err := os.WriteFile(file, []byte(src), 0644); err != nil {
+				t.Fatal(err)
+			}
+			serializer := NewSerializer()
+			serializer.SetModuleInfo("example.com/m", nil)
+			serializer.SetFormatStyle(tt.style)
+			start := strings.Index(src, "Original")
+			err := serializer.ApplyChanges(nil, []refactorTypes.Change{
+				{File: file, Start: start, End: start + len("Original"), OldText: "Original", NewText: "Modified"},
+			})
+			if err != nil {
+				t.Fatalf("ApplyChanges: %v", err)
+			}
+			got, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(got), tt.want) {
+				t.Errorf("expected output to contain\n%s\ngot\n%s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSerializer_ApplyChanges_FixImports(t *testing.T) {
+	src := "package test\n\nimport \"os\"\n\nfunc Name() string { return os.Args[0] }\n"
+	edit := "strings.ToUpper(\"x\")"
+	for _, fix := range []bool{false, true} {
+		file := filepath.Join(t.TempDir(), "test.go")
+		if err := os.WriteFile(file, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		serializer := NewSerializer()
+		serializer.SetFormatStyle(FormatStyle{FixImports: fix})
+		start := strings.Index(src, "os.Args[0]")
+		err := serializer.ApplyChanges(nil, []refactorTypes.Change{
+			{File: file, Start: start, End: start + len("os.Args[0]"), OldText: "os.Args[0]", NewText: edit},
+		})
+		if err != nil {
+			t.Fatalf("ApplyChanges: %v", err)
+		}
+		got, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fixed := strings.Contains(string(got), "import \"strings\"\n") && !strings.Contains(string(got), "\"os\"")
+		if fixed != fix {
+			t.Errorf("FixImports %v:\n%s", fix, got)
+		}
+	}
+}
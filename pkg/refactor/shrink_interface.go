@@ -0,0 +1,510 @@
+package refactor
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	gotypes "go/types"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/mamaar/gorefactor/pkg/analysis"
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// ShrinkInterfaceOperation removes the methods of an interface that are never
+// called through it, as reported by analysis.AnalyzeInterfaceUsage, and can
+// split the remaining methods into role interfaces that the original embeds.
+// Implementations of removed methods are deleted as well, but only where
+// nothing else refers to them and no other interface the implementing type
+// satisfies declares them.
+type ShrinkInterfaceOperation struct {
+	Request types.ShrinkInterfaceRequest
+	Parser  *analysis.GoParser // Type-checks packages on demand; may be nil if they already are
+
+	// Resolved by Validate
+	pkg       *types.Package
+	file      *types.File
+	genDecl   *ast.GenDecl
+	iface     *ast.InterfaceType
+	named     *gotypes.Named
+	removed   []string          // Explicitly declared methods to remove
+	roleOf    map[string]string // Method name -> role interface it moves to
+	inherited []string          // Unused methods from embedded interfaces, which are left alone
+}
+
+func (op *ShrinkInterfaceOperation) Type() types.OperationType {
+	return types.ShrinkInterfaceOperation
+}
+
+func (op *ShrinkInterfaceOperation) Description() string {
+	return fmt.Sprintf("Shrink interface %s", op.Request.InterfaceName)
+}
+
+func (op *ShrinkInterfaceOperation) Validate(ws *types.Workspace) error {
+	req := op.Request
+	if req.InterfaceName == "" {
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: "interface name is required",
+		}
+	}
+	if err := op.findInterface(ws); err != nil {
+		return err
+	}
+
+	// Usage is only complete when every package is type-checked.
+	if op.Parser != nil {
+		for _, pkgPath := range slices.Sorted(maps.Keys(ws.Packages)) {
+			op.Parser.EnsureTypeChecked(ws, ws.Packages[pkgPath])
+		}
+	}
+	if op.pkg.TypesInfo == nil {
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("package %s could not be type-checked; interface usage cannot be determined", op.pkg.ImportPath),
+		}
+	}
+	tn, _ := op.pkg.TypesInfo.Defs[op.typeSpec().Name].(*gotypes.TypeName)
+	if tn == nil {
+		return &types.RefactorError{
+			Type:    types.SymbolNotFound,
+			Message: fmt.Sprintf("no type information for interface %s", req.InterfaceName),
+		}
+	}
+	op.named = tn.Type().(*gotypes.Named)
+
+	var usage *analysis.InterfaceUsage
+	for _, u := range analysis.AnalyzeInterfaceUsage(ws) {
+		if u.File == op.file.Path && u.Name == req.InterfaceName {
+			usage = u
+		}
+	}
+	if usage == nil {
+		return &types.RefactorError{
+			Type:    types.SymbolNotFound,
+			Message: fmt.Sprintf("no usage information for interface %s", req.InterfaceName),
+		}
+	}
+
+	keep := append(slices.Clone(usage.Called), usage.Required...)
+	if len(req.Keep) > 0 {
+		for _, name := range req.Keep {
+			if !slices.Contains(usage.Methods, name) {
+				return &types.RefactorError{
+					Type:    types.SymbolNotFound,
+					Message: fmt.Sprintf("interface %s has no method %s", req.InterfaceName, name),
+				}
+			}
+		}
+		for _, name := range keep {
+			if !slices.Contains(req.Keep, name) {
+				return &types.RefactorError{
+					Type:    types.InvalidOperation,
+					Message: fmt.Sprintf("method %s of %s is still in use (%s) and cannot be removed", name, req.InterfaceName, usageReason(usage, name)),
+				}
+			}
+		}
+		keep = req.Keep
+	}
+
+	declared := op.declaredMethods()
+	op.roleOf = make(map[string]string)
+	roleNames := make(map[string]bool)
+	for _, role := range req.Roles {
+		if !isValidGoIdentifier(role.Name) || token.IsKeyword(role.Name) {
+			return &types.RefactorError{
+				Type:    types.InvalidOperation,
+				Message: fmt.Sprintf("%q is not a valid role interface name", role.Name),
+			}
+		}
+		if op.pkg.TypesPkg.Scope().Lookup(role.Name) != nil || roleNames[role.Name] {
+			return &types.RefactorError{
+				Type:    types.NameConflict,
+				Message: fmt.Sprintf("%s is already declared in package %s", role.Name, op.pkg.ImportPath),
+			}
+		}
+		roleNames[role.Name] = true
+		if len(role.Methods) == 0 {
+			return &types.RefactorError{
+				Type:    types.InvalidOperation,
+				Message: fmt.Sprintf("role interface %s has no methods", role.Name),
+			}
+		}
+		for _, method := range role.Methods {
+			switch {
+			case declared[method] == nil:
+				return &types.RefactorError{
+					Type:    types.InvalidOperation,
+					Message: fmt.Sprintf("%s does not declare method %s itself; only its own methods can move to role %s", req.InterfaceName, method, role.Name),
+				}
+			case !slices.Contains(keep, method):
+				return &types.RefactorError{
+					Type:    types.InvalidOperation,
+					Message: fmt.Sprintf("method %s is unused and removed; it cannot move to role %s", method, role.Name),
+				}
+			case op.roleOf[method] != "":
+				return &types.RefactorError{
+					Type:    types.InvalidOperation,
+					Message: fmt.Sprintf("method %s is assigned to both %s and %s", method, op.roleOf[method], role.Name),
+				}
+			}
+			op.roleOf[method] = role.Name
+		}
+	}
+
+	for _, name := range usage.Methods {
+		if slices.Contains(keep, name) {
+			continue
+		}
+		if declared[name] != nil {
+			op.removed = append(op.removed, name)
+		} else {
+			op.inherited = append(op.inherited, name)
+		}
+	}
+	if len(op.removed) == 0 && len(req.Roles) == 0 {
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("interface %s has no unused methods of its own to remove", req.InterfaceName),
+		}
+	}
+	return nil
+}
+
+// findInterface locates the interface declaration, restricted to
+// Request.Package when set.
+func (op *ShrinkInterfaceOperation) findInterface(ws *types.Workspace) error {
+	req := op.Request
+	found := 0
+	for _, pkgPath := range slices.Sorted(maps.Keys(ws.Packages)) {
+		pkg := ws.Packages[pkgPath]
+		if req.Package != "" && pkg.Path != req.Package && pkg.ImportPath != req.Package {
+			continue
+		}
+		for _, fileName := range slices.Sorted(maps.Keys(pkg.Files)) {
+			file := pkg.Files[fileName]
+			if file.AST == nil {
+				continue
+			}
+			for _, decl := range file.AST.Decls {
+				genDecl, ok := decl.(*ast.GenDecl)
+				if !ok || genDecl.Tok != token.TYPE {
+					continue
+				}
+				for _, spec := range genDecl.Specs {
+					typeSpec := spec.(*ast.TypeSpec)
+					iface, ok := typeSpec.Type.(*ast.InterfaceType)
+					if !ok || typeSpec.Name.Name != req.InterfaceName {
+						continue
+					}
+					found++
+					op.pkg, op.file, op.genDecl, op.iface = pkg, file, genDecl, iface
+				}
+			}
+		}
+	}
+
+	switch {
+	case found == 0:
+		return &types.RefactorError{
+			Type:    types.SymbolNotFound,
+			Message: fmt.Sprintf("interface %s not found", req.InterfaceName),
+		}
+	case found > 1:
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("interface %s is declared in %d packages; specify the package", req.InterfaceName, found),
+		}
+	}
+	return nil
+}
+
+func (op *ShrinkInterfaceOperation) Execute(ws *types.Workspace) (*types.RefactoringPlan, error) {
+	var changes []types.Change
+	var issues []types.Issue
+
+	body := op.interfaceBody(ws)
+	roles := op.roleDecls(ws)
+	change := types.Change{
+		File:        op.file.Path,
+		Start:       ws.FileSet.Position(op.iface.Pos()).Offset,
+		End:         ws.FileSet.Position(op.iface.End()).Offset,
+		OldText:     nodeText(ws, op.file, op.iface.Pos(), op.iface.End()),
+		NewText:     body,
+		Description: fmt.Sprintf("Replace methods of %s", op.Request.InterfaceName),
+	}
+	if roles != "" && !op.genDecl.Rparen.IsValid() {
+		// The declaration ends with the interface; insert the roles right after.
+		change.NewText += roles
+		roles = ""
+	}
+	changes = append(changes, change)
+	if roles != "" {
+		end := ws.FileSet.Position(op.genDecl.End()).Offset
+		changes = append(changes, types.Change{
+			File:        op.file.Path,
+			Start:       end,
+			End:         end,
+			NewText:     roles,
+			Description: fmt.Sprintf("Split role interfaces out of %s", op.Request.InterfaceName),
+		})
+	}
+
+	for _, name := range op.inherited {
+		issues = append(issues, types.Issue{
+			Type:        types.IssueTypeMismatch,
+			Description: fmt.Sprintf("%s.%s is unused but comes from an embedded interface; it was not removed", op.Request.InterfaceName, name),
+			File:        op.file.Path,
+			Line:        ws.FileSet.Position(op.iface.Pos()).Line,
+			Severity:    types.Info,
+		})
+	}
+
+	if !op.Request.KeepImplementations {
+		implChanges, implIssues := op.removeImplementations(ws)
+		changes = append(changes, implChanges...)
+		issues = append(issues, implIssues...)
+	}
+
+	affected := make([]string, 0)
+	for _, change := range changes {
+		if !slices.Contains(affected, change.File) {
+			affected = append(affected, change.File)
+		}
+	}
+
+	return &types.RefactoringPlan{
+		Changes:       changes,
+		AffectedFiles: affected,
+		Impact: &types.ImpactAnalysis{
+			AffectedPackages: []string{op.pkg.Path},
+			AffectedFiles:    affected,
+			PotentialIssues:  issues,
+		},
+		Reversible: true,
+	}, nil
+}
+
+// interfaceBody returns the new interface type: role interfaces first, then
+// the embedded interfaces and kept methods that stay, with their comments.
+func (op *ShrinkInterfaceOperation) interfaceBody(ws *types.Workspace) string {
+	var b strings.Builder
+	b.WriteString("interface {\n")
+	for _, role := range op.Request.Roles {
+		fmt.Fprintf(&b, "\t%s\n", role.Name)
+	}
+	for _, field := range op.iface.Methods.List {
+		if len(field.Names) == 1 && (slices.Contains(op.removed, field.Names[0].Name) || op.roleOf[field.Names[0].Name] != "") {
+			continue
+		}
+		fmt.Fprintf(&b, "\t%s\n", op.fieldText(ws, field))
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// roleDecls returns the declarations of the role interfaces.
+func (op *ShrinkInterfaceOperation) roleDecls(ws *types.Workspace) string {
+	declared := op.declaredMethods()
+	var b strings.Builder
+	for _, role := range op.Request.Roles {
+		fmt.Fprintf(&b, "\n\n// %s is the %s role of %s.\n", role.Name, strings.Join(role.Methods, "/"), op.Request.InterfaceName)
+		fmt.Fprintf(&b, "type %s interface {\n", role.Name)
+		for _, method := range role.Methods {
+			fmt.Fprintf(&b, "\t%s\n", op.fieldText(ws, declared[method]))
+		}
+		b.WriteString("}")
+	}
+	return b.String()
+}
+
+// fieldText returns the source of an interface element with its comments.
+func (op *ShrinkInterfaceOperation) fieldText(ws *types.Workspace, field *ast.Field) string {
+	start, end := field.Pos(), field.End()
+	if field.Doc != nil {
+		start = field.Doc.Pos()
+	}
+	if field.Comment != nil {
+		end = field.Comment.End()
+	}
+	return nodeText(ws, op.file, start, end)
+}
+
+// removeImplementations deletes the methods implementing removed interface
+// methods where that is safe, and explains where it is not.
+func (op *ShrinkInterfaceOperation) removeImplementations(ws *types.Workspace) ([]types.Change, []types.Issue) {
+	var changes []types.Change
+	var issues []types.Issue
+
+	original := op.named.Underlying().(*gotypes.Interface)
+	referenced := analysis.ReferencedInterfaces(ws)
+	mentionedInTests := op.testSelectors(ws)
+	done := make(map[*gotypes.Func]bool)
+
+	for _, pkgPath := range slices.Sorted(maps.Keys(ws.Packages)) {
+		pkg := ws.Packages[pkgPath]
+		if pkg.TypesPkg == nil {
+			continue
+		}
+		scope := pkg.TypesPkg.Scope()
+		for _, name := range scope.Names() {
+			tn, ok := scope.Lookup(name).(*gotypes.TypeName)
+			if !ok || tn.IsAlias() {
+				continue
+			}
+			named, ok := tn.Type().(*gotypes.Named)
+			if !ok || gotypes.IsInterface(named) || named.TypeParams().Len() > 0 {
+				continue
+			}
+			ptr := gotypes.NewPointer(named)
+			if !gotypes.Implements(ptr, original) {
+				continue
+			}
+			for _, method := range op.removed {
+				obj, _, _ := gotypes.LookupFieldOrMethod(ptr, false, tn.Pkg(), method)
+				fn, ok := obj.(*gotypes.Func)
+				if !ok || done[fn] {
+					continue
+				}
+				done[fn] = true
+				decl, declFile := findFuncDecl(ws, fn)
+				if decl == nil {
+					continue
+				}
+				methodName := fmt.Sprintf("%s.%s", receiverTypeName(decl), method)
+				reason := op.implementationInUse(ws, fn, ptr, referenced, mentionedInTests)
+				if reason != "" {
+					issues = append(issues, types.Issue{
+						Type:        types.IssueTypeMismatch,
+						Description: fmt.Sprintf("%s was kept: %s", methodName, reason),
+						File:        declFile.Path,
+						Line:        ws.FileSet.Position(decl.Pos()).Line,
+						Severity:    types.Info,
+					})
+					continue
+				}
+				start := decl.Pos()
+				if decl.Doc != nil {
+					start = decl.Doc.Pos()
+				}
+				changes = append(changes, types.Change{
+					File:        declFile.Path,
+					Start:       ws.FileSet.Position(start).Offset,
+					End:         ws.FileSet.Position(decl.End()).Offset,
+					OldText:     nodeText(ws, declFile, start, decl.End()),
+					NewText:     "",
+					Description: fmt.Sprintf("Remove %s, no longer required by %s", methodName, op.Request.InterfaceName),
+				})
+			}
+		}
+	}
+	return changes, issues
+}
+
+// implementationInUse explains why the implementation fn of a removed method
+// must stay, or returns "" when it can be deleted.
+func (op *ShrinkInterfaceOperation) implementationInUse(ws *types.Workspace, fn *gotypes.Func, implementer gotypes.Type, referenced []*gotypes.Named, mentionedInTests map[string]bool) string {
+	for _, pkg := range ws.Packages {
+		if pkg.TypesInfo == nil {
+			continue
+		}
+		for _, obj := range pkg.TypesInfo.Uses {
+			if obj == fn {
+				return "it is called directly"
+			}
+		}
+	}
+	recv := fn.Type().(*gotypes.Signature).Recv().Type()
+	if p, ok := recv.(*gotypes.Pointer); ok {
+		recv = p.Elem()
+	}
+	for _, other := range referenced {
+		if other == op.named {
+			continue
+		}
+		iface := other.Underlying().(*gotypes.Interface)
+		if !slices.ContainsFunc(slices.Collect(iface.Methods()), func(m *gotypes.Func) bool { return m.Name() == fn.Name() }) {
+			continue
+		}
+		if gotypes.Implements(implementer, iface) || gotypes.Implements(gotypes.NewPointer(recv), iface) {
+			return fmt.Sprintf("it also implements %s", other.Obj().Name())
+		}
+	}
+	if mentionedInTests[fn.Name()] {
+		return "test files may call it"
+	}
+	return ""
+}
+
+// testSelectors collects the selector names used in test files, which are not
+// type-checked, so calls there can only be matched by name.
+func (op *ShrinkInterfaceOperation) testSelectors(ws *types.Workspace) map[string]bool {
+	names := make(map[string]bool)
+	for _, pkg := range ws.Packages {
+		for _, file := range pkg.TestFiles {
+			if file.AST == nil {
+				continue
+			}
+			ast.Inspect(file.AST, func(n ast.Node) bool {
+				if sel, ok := n.(*ast.SelectorExpr); ok && slices.Contains(op.removed, sel.Sel.Name) {
+					names[sel.Sel.Name] = true
+				}
+				return true
+			})
+		}
+	}
+	return names
+}
+
+// declaredMethods returns the methods the interface declares itself, by name.
+func (op *ShrinkInterfaceOperation) declaredMethods() map[string]*ast.Field {
+	methods := make(map[string]*ast.Field)
+	for _, field := range op.iface.Methods.List {
+		if len(field.Names) == 1 {
+			methods[field.Names[0].Name] = field
+		}
+	}
+	return methods
+}
+
+func (op *ShrinkInterfaceOperation) typeSpec() *ast.TypeSpec {
+	for _, spec := range op.genDecl.Specs {
+		if ts := spec.(*ast.TypeSpec); ts.Type == op.iface {
+			return ts
+		}
+	}
+	return nil
+}
+
+// usageReason explains why a method of the interface is in use.
+func usageReason(usage *analysis.InterfaceUsage, method string) string {
+	if slices.Contains(usage.Called, method) {
+		return "called through the interface"
+	}
+	if len(usage.RequiredBy) > 0 {
+		return "required to implement " + strings.Join(usage.RequiredBy, ", ")
+	}
+	return "may be called dynamically"
+}
+
+// findFuncDecl returns the declaration of fn in the workspace and its file.
+func findFuncDecl(ws *types.Workspace, fn *gotypes.Func) (*ast.FuncDecl, *types.File) {
+	for _, pkg := range ws.Packages {
+		if pkg.TypesPkg != fn.Pkg() || pkg.TypesInfo == nil {
+			continue
+		}
+		for _, file := range pkg.Files {
+			if file.AST == nil {
+				continue
+			}
+			for _, decl := range file.AST.Decls {
+				if fd, ok := decl.(*ast.FuncDecl); ok && pkg.TypesInfo.Defs[fd.Name] == fn {
+					return fd, file
+				}
+			}
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,435 @@
+package refactor
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"path/filepath"
+	"strings"
+
+	"github.com/mamaar/gorefactor/pkg/analysis"
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// SplitFileOperation moves groups of the declarations of an oversized file
+// into new files of the same package, each declaration with its doc
+// comment. Package scope is unchanged, so nothing refers to the moved
+// declarations differently; the new files get the imports they use and the
+// file loses the ones it no longer does. Variables and init functions stay,
+// keeping the order they initialize in.
+//
+// Examples and benchmarks follow what they are about, by the names go doc
+// and go test give them: those of moved declarations in the file's own test
+// file move to the test file of their new file, and in a split test file
+// they are grouped by their subject rather than as examples or benchmarks.
+type SplitFileOperation struct {
+	Request types.SplitFileRequest
+	Parser  *analysis.GoParser // Checks the split package still compiles; skipped when nil
+
+	file   *types.File
+	layout *fileLayout
+}
+
+func (op *SplitFileOperation) Type() types.OperationType {
+	return types.SplitFileOperation
+}
+
+func (op *SplitFileOperation) Description() string {
+	return fmt.Sprintf("Split %s by %s", filepath.Base(op.Request.File), op.strategy())
+}
+
+func (op *SplitFileOperation) strategy() types.SplitStrategy {
+	return cmp.Or(op.Request.Strategy, types.SplitReceiver)
+}
+
+func (op *SplitFileOperation) Validate(ws *types.Workspace) error {
+	switch op.strategy() {
+	case types.SplitReceiver, types.SplitPrefix, types.SplitDependency:
+	default:
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("unknown strategy %q: want %s, %s or %s", op.Request.Strategy, types.SplitReceiver, types.SplitPrefix, types.SplitDependency),
+		}
+	}
+	op.file = findFile(ws, op.Request.File)
+	if op.file == nil || op.file.AST == nil {
+		return &types.RefactorError{
+			Type:    types.FileSystemError,
+			Message: fmt.Sprintf("file not found: %s", op.Request.File),
+			File:    op.Request.File,
+		}
+	}
+	maxLines := cmp.Or(op.Request.MaxLines, defaultMaxFileLines)
+	if lines := bytes.Count(op.file.OriginalContent, []byte("\n")); lines <= maxLines {
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("%s has %d lines, not more than %d: nothing to split", filepath.Base(op.file.Path), lines, maxLines),
+			File:    op.file.Path,
+		}
+	}
+	op.layout = layoutFile(ws, op.file)
+	return nil
+}
+
+func (op *SplitFileOperation) Execute(ws *types.Workspace) (*types.RefactoringPlan, error) {
+	var groups []*fileGroup
+	switch op.strategy() {
+	case types.SplitPrefix:
+		groups = prefixGroups(op.file.Path, op.layout.chunks)
+	case types.SplitDependency:
+		groups = dependencyGroups(op.file.Path, op.layout.chunks)
+	default:
+		groups = receiverGroups(op.file.Path, op.layout.chunks)
+	}
+
+	plan := &types.RefactoringPlan{Reversible: true}
+	if len(groups) > 0 {
+		kept, moved, err := op.layout.split(ws, groups, func(chunks []*declChunk) []*declChunk { return chunks })
+		if err != nil {
+			return nil, err
+		}
+		content, err := op.layout.render(ws, op.file.Package, kept)
+		if err != nil {
+			return nil, err
+		}
+		plan.Changes = append([]types.Change{{
+			File:        op.file.Path,
+			End:         len(op.file.OriginalContent),
+			OldText:     string(op.file.OriginalContent),
+			NewText:     content,
+			Description: fmt.Sprintf("Keep the rest of %s", filepath.Base(op.file.Path)),
+		}}, moved...)
+		tests, err := op.splitTests(ws, groups)
+		if err != nil {
+			return nil, err
+		}
+		plan.Changes = append(plan.Changes, tests...)
+		if err := op.checkCompiles(ws, plan.Changes); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, c := range plan.Changes {
+		plan.AffectedFiles = append(plan.AffectedFiles, c.File)
+	}
+	plan.Impact = &types.ImpactAnalysis{AffectedFiles: plan.AffectedFiles}
+	if op.file.Package != nil {
+		plan.Impact.AffectedPackages = []string{op.file.Package.Path}
+	}
+	return plan, nil
+}
+
+// checkCompiles type-checks the package and its tests with the files
+// changes write and fails on the first error they introduce.
+func (op *SplitFileOperation) checkCompiles(ws *types.Workspace, changes []types.Change) error {
+	if op.Parser == nil || op.file.Package == nil {
+		return nil
+	}
+	sources := make(map[string][]byte)
+	for _, c := range changes {
+		sources[c.File] = []byte(c.NewText)
+	}
+	diags := op.Parser.NewTypeErrors(ws, op.file.Package, sources)
+	if len(diags) == 0 {
+		return nil
+	}
+	d := diags[0]
+	return &types.RefactorError{
+		Type:    types.CompilationError,
+		Message: fmt.Sprintf("splitting %s would break compilation: %s:%d: %s", filepath.Base(op.file.Path), filepath.Base(d.File), d.Line, d.Message),
+		File:    d.File,
+		Line:    d.Line,
+	}
+}
+
+// splitTests moves the examples and benchmarks of the declarations in groups
+// out of the file's own test file, the one named after it, into the test
+// file of each group's new file, and returns the changes doing so. Those in
+// other test files, such as example_test.go, stay.
+func (op *SplitFileOperation) splitTests(ws *types.Workspace, groups []*fileGroup) ([]types.Change, error) {
+	stem, suffix := fileStem(op.file.Path)
+	if suffix != ".go" {
+		return nil, nil
+	}
+	file := findFile(ws, filepath.Join(filepath.Dir(op.file.Path), stem+"_test.go"))
+	if file == nil || file.AST == nil {
+		return nil, nil
+	}
+	layout := layoutFile(ws, file)
+	var testGroups []*fileGroup
+	taken := make(map[*declChunk]bool)
+	for _, g := range groups {
+		subjects := make(map[string]bool)
+		for _, c := range g.chunks {
+			for _, name := range declNames(c.decl) {
+				subjects[name] = true
+			}
+			if fd, ok := c.decl.(*ast.FuncDecl); ok && fd.Recv != nil {
+				subjects[receiverTypeName(fd)] = true
+			}
+		}
+		tg := &fileGroup{stem: g.stem}
+		for _, c := range layout.chunks {
+			if !taken[c] && exampleOrBenchmarkOf(c.decl, subjects) {
+				taken[c] = true
+				tg.chunks = append(tg.chunks, c)
+			}
+		}
+		if len(tg.chunks) > 0 {
+			testGroups = append(testGroups, tg)
+		}
+	}
+	if len(testGroups) == 0 {
+		return nil, nil
+	}
+
+	kept, moved, err := layout.split(ws, testGroups, func(chunks []*declChunk) []*declChunk { return chunks })
+	if err != nil {
+		return nil, err
+	}
+	content, err := layout.render(ws, file.Package, kept)
+	if err != nil {
+		return nil, err
+	}
+	return append([]types.Change{{
+		File:        file.Path,
+		End:         len(file.OriginalContent),
+		OldText:     string(file.OriginalContent),
+		NewText:     content,
+		Description: fmt.Sprintf("Keep the rest of %s", filepath.Base(file.Path)),
+	}}, moved...), nil
+}
+
+// exampleOrBenchmarkOf reports whether d is an example or benchmark of one
+// of the declarations named in subjects; see testSubject.
+func exampleOrBenchmarkOf(d ast.Decl, subjects map[string]bool) bool {
+	fd, ok := d.(*ast.FuncDecl)
+	if !ok || !isTestFunc(fd) || !strings.HasPrefix(fd.Name.Name, "Example") && !strings.HasPrefix(fd.Name.Name, "Benchmark") {
+		return false
+	}
+	return subjectIn(fd.Name.Name, subjects) != ""
+}
+
+// movable reports whether a declaration may leave its file. Variables and
+// init functions may not: files initialize in the order of their names.
+func movable(c *declChunk) bool {
+	switch d := c.decl.(type) {
+	case *ast.GenDecl:
+		return d.Tok != token.VAR
+	case *ast.FuncDecl:
+		return d.Recv != nil || d.Name.Name != "init"
+	}
+	return false
+}
+
+// prefixGroups groups the declarations of the file at path by the first
+// word of their names, methods, constructors and typed constants by their
+// type's, and tests, examples and benchmarks by their subject's. Words
+// shared by two declarations or more make a group, except the word the
+// file is named after.
+func prefixGroups(path string, chunks []*declChunk) []*fileGroup {
+	base, _ := fileStem(path)
+	declared := make(map[string]bool)
+	for _, c := range chunks {
+		if gen, ok := c.decl.(*ast.GenDecl); ok && gen.Tok == token.TYPE {
+			for _, spec := range gen.Specs {
+				declared[spec.(*ast.TypeSpec).Name.Name] = true
+			}
+		}
+	}
+
+	var groups []*fileGroup
+	byWord := make(map[string]*fileGroup)
+	for _, c := range chunks {
+		if !movable(c) {
+			continue
+		}
+		name := owningType(c.decl, declared)
+		if fd, ok := c.decl.(*ast.FuncDecl); ok && isTestFunc(fd) {
+			if name = testSubject(fd.Name.Name); name == "" {
+				continue // About the package
+			}
+		}
+		if name == "" {
+			name = firstName(c.decl)
+		}
+		word, _, _ := strings.Cut(snakeCase(strings.TrimLeft(name, "_")), "_")
+		if word == "" || word == base {
+			continue
+		}
+		g := byWord[word]
+		if g == nil {
+			g = &fileGroup{stem: word}
+			byWord[word] = g
+			groups = append(groups, g)
+		}
+		g.chunks = append(g.chunks, c)
+	}
+
+	var shared []*fileGroup
+	for _, g := range groups {
+		if len(g.chunks) > 1 {
+			shared = append(shared, g)
+		}
+	}
+	return shared
+}
+
+// dependencyGroups groups the declarations of the file at path into
+// clusters that refer to one another by name, methods with their receiver
+// type and tests, examples and benchmarks with the others of their subject.
+// Every cluster of two declarations or more but the largest makes a
+// group, named after its first exported type, or else its first exported
+// declaration.
+func dependencyGroups(path string, chunks []*declChunk) []*fileGroup {
+	var nodes []*declChunk
+	for _, c := range chunks {
+		if movable(c) {
+			nodes = append(nodes, c)
+		}
+	}
+	index := make(map[string]int)
+	for i, c := range nodes {
+		if !isMethod(c.decl) {
+			for _, name := range declNames(c.decl) {
+				index[name] = i
+			}
+		}
+	}
+
+	parent := make([]int, len(nodes))
+	for i := range parent {
+		parent[i] = i
+	}
+	var root func(i int) int
+	root = func(i int) int {
+		if parent[i] != i {
+			parent[i] = root(parent[i])
+		}
+		return parent[i]
+	}
+	bySubject := make(map[string]int) // First test function about each subject
+	for i, c := range nodes {
+		if fd, ok := c.decl.(*ast.FuncDecl); ok && fd.Recv != nil {
+			if j, ok := index[receiverTypeName(fd)]; ok {
+				parent[root(i)] = root(j)
+			}
+		}
+		// Tests, examples and benchmarks of one subject stay together
+		if fd, ok := c.decl.(*ast.FuncDecl); ok && isTestFunc(fd) {
+			if subject := testSubject(fd.Name.Name); subject != "" {
+				if j, ok := bySubject[subject]; ok {
+					parent[root(i)] = root(j)
+				} else {
+					bySubject[subject] = i
+				}
+			}
+		}
+		ast.Inspect(c.decl, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok {
+				if j, ok := index[id.Name]; ok {
+					parent[root(i)] = root(j)
+				}
+			}
+			return true
+		})
+	}
+
+	var clusters [][]*declChunk
+	byRoot := make(map[int]int)
+	for i, c := range nodes {
+		k, ok := byRoot[root(i)]
+		if !ok {
+			k = len(clusters)
+			byRoot[root(i)] = k
+			clusters = append(clusters, nil)
+		}
+		clusters[k] = append(clusters[k], c)
+	}
+	largest := 0
+	for k, cluster := range clusters {
+		if len(cluster) > len(clusters[largest]) {
+			largest = k
+		}
+	}
+
+	base, _ := fileStem(path)
+	var groups []*fileGroup
+	for k, cluster := range clusters {
+		if k == largest || len(cluster) < 2 {
+			continue
+		}
+		if stem := snakeCase(clusterName(cluster)); stem != base {
+			groups = append(groups, &fileGroup{stem: stem, chunks: cluster})
+		}
+	}
+	return groups
+}
+
+// clusterName returns the name to call a cluster of declarations by: its
+// first exported type, its first exported declaration, or its first one.
+// Test functions go by the name of their subject.
+func clusterName(cluster []*declChunk) string {
+	var exported string
+	for _, c := range cluster {
+		if gen, ok := c.decl.(*ast.GenDecl); ok && gen.Tok == token.TYPE {
+			for _, spec := range gen.Specs {
+				if name := spec.(*ast.TypeSpec).Name; name.IsExported() {
+					return name.Name
+				}
+			}
+		}
+		if name := subjectName(c.decl); exported == "" && ast.IsExported(name) && !isMethod(c.decl) {
+			exported = name
+		}
+	}
+	return cmp.Or(exported, subjectName(cluster[0].decl))
+}
+
+// subjectName returns the first name a declaration declares, or the
+// subject of a test function about one.
+func subjectName(d ast.Decl) string {
+	if fd, ok := d.(*ast.FuncDecl); ok && isTestFunc(fd) {
+		if subject := testSubject(fd.Name.Name); subject != "" {
+			return subject
+		}
+	}
+	return firstName(d)
+}
+
+// firstName returns the first name a declaration declares; a method's name
+// for methods.
+func firstName(d ast.Decl) string {
+	if fd, ok := d.(*ast.FuncDecl); ok {
+		return fd.Name.Name
+	}
+	if names := declNames(d); len(names) > 0 {
+		return names[0]
+	}
+	return ""
+}
+
+// declNames returns the package-level names a declaration declares.
+func declNames(d ast.Decl) []string {
+	var names []string
+	switch d := d.(type) {
+	case *ast.FuncDecl:
+		if d.Recv == nil {
+			names = append(names, d.Name.Name)
+		}
+	case *ast.GenDecl:
+		for _, spec := range d.Specs {
+			switch s := spec.(type) {
+			case *ast.TypeSpec:
+				names = append(names, s.Name.Name)
+			case *ast.ValueSpec:
+				for _, n := range s.Names {
+					names = append(names, n.Name)
+				}
+			}
+		}
+	}
+	return names
+}
@@ -0,0 +1,209 @@
+package refactor
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+var splitFileFiles = map[string]string{
+	"pages/pages.go": `package pages
+
+import (
+	"fmt"
+	"strings"
+)
+
+var registry = map[string]Page{}
+
+// Page is a rendered page.
+type Page struct {
+	Title string
+}
+
+// Lookup finds a page by title.
+func Lookup(title string) Page { return registry[title] }
+
+// parseHeader reads the title line.
+func parseHeader(s string) string {
+	return strings.TrimSpace(parseLine(s))
+}
+
+func parseLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
+
+// renderTitle formats the title.
+func renderTitle(p Page) string { return fmt.Sprintf("# %s", p.Title) }
+
+func renderBody(body string) string { return body }
+`,
+}
+
+func TestSplitFile_Prefix(t *testing.T) {
+	engine, ws, dir := loadTestModuleWith(t, splitFileFiles)
+	file := filepath.Join(dir, "pages", "pages.go")
+
+	plan, err := engine.SplitFile(ws, types.SplitFileRequest{File: file, Strategy: types.SplitPrefix, MaxLines: 10})
+	if err != nil {
+		t.Fatalf("SplitFile: %v", err)
+	}
+	parse := planContent(t, plan, filepath.Join(dir, "pages", "parse.go"))
+	for _, want := range []string{"import \"strings\"\n", "// parseHeader reads the title line.\nfunc parseHeader(", "func parseLine("} {
+		if !strings.Contains(parse, want) {
+			t.Errorf("parse.go lacks %q:\n%s", want, parse)
+		}
+	}
+	render := planContent(t, plan, filepath.Join(dir, "pages", "render.go"))
+	if !strings.Contains(render, "import \"fmt\"\n") || !strings.Contains(render, "func renderBody(") {
+		t.Errorf("render.go should hold the render functions and import fmt:\n%s", render)
+	}
+	pages := planContent(t, plan, file)
+	for _, want := range []string{"var registry", "type Page struct", "func Lookup("} {
+		if !strings.Contains(pages, want) {
+			t.Errorf("pages.go lacks %q:\n%s", want, pages)
+		}
+	}
+	if strings.Contains(pages, "import") || strings.Contains(pages, "func parse") {
+		t.Errorf("pages.go should keep neither the parse functions nor the imports:\n%s", pages)
+	}
+}
+
+func TestSplitFile_Dependency(t *testing.T) {
+	engine, ws, dir := loadTestModuleWith(t, splitFileFiles)
+	file := filepath.Join(dir, "pages", "pages.go")
+
+	plan, err := engine.SplitFile(ws, types.SplitFileRequest{File: file, Strategy: types.SplitDependency, MaxLines: 10})
+	if err != nil {
+		t.Fatalf("SplitFile: %v", err)
+	}
+	// Page, Lookup and renderTitle form the largest cluster and stay; the
+	// parse functions refer to each other and move; renderBody is alone.
+	moved := planContent(t, plan, filepath.Join(dir, "pages", "parse_header.go"))
+	if !strings.Contains(moved, "func parseHeader(") || !strings.Contains(moved, "func parseLine(") {
+		t.Errorf("parse_header.go should hold the parse functions:\n%s", moved)
+	}
+	pages := planContent(t, plan, file)
+	for _, want := range []string{"func renderTitle(", "func renderBody(", "\"fmt\""} {
+		if !strings.Contains(pages, want) {
+			t.Errorf("pages.go lacks %q:\n%s", want, pages)
+		}
+	}
+}
+
+func TestSplitFile_Errors(t *testing.T) {
+	engine, ws, dir := loadTestModuleWith(t, splitFileFiles)
+	file := filepath.Join(dir, "pages", "pages.go")
+
+	if _, err := engine.SplitFile(ws, types.SplitFileRequest{File: file}); err == nil {
+		t.Error("expected splitting a file under the size threshold to fail")
+	}
+	if _, err := engine.SplitFile(ws, types.SplitFileRequest{File: file, Strategy: "alphabetical", MaxLines: 10}); err == nil {
+		t.Error("expected an unknown strategy to fail")
+	}
+}
+
+func TestSplitFile_ExamplesAndBenchmarksFollowSubjects(t *testing.T) {
+	files := map[string]string{
+		"pages/pages_test.go": `package pages
+
+import (
+	"fmt"
+	"testing"
+)
+
+func Example() { fmt.Println(len(registry)) }
+
+func ExampleLookup() { fmt.Println(Lookup("home").Title) }
+
+func BenchmarkParseHeader(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		parseHeader("# title")
+	}
+}
+
+func TestParseLine(t *testing.T) { parseLine("") }
+
+// BenchmarkRenderTitle measures titles.
+func BenchmarkRenderTitle(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		renderTitle(Page{})
+	}
+}
+`,
+	}
+	for name, content := range splitFileFiles {
+		files[name] = content
+	}
+	engine, ws, dir := loadTestModuleWith(t, files)
+	plan, err := engine.SplitFile(ws, types.SplitFileRequest{File: filepath.Join(dir, "pages", "pages.go"), Strategy: types.SplitPrefix, MaxLines: 10})
+	if err != nil {
+		t.Fatalf("SplitFile: %v", err)
+	}
+
+	want := map[string]string{
+		"parse_test.go":  "package pages\n\nimport \"testing\"\n\nfunc BenchmarkParseHeader(b *testing.B) {\n\tfor i := 0; i < b.N; i++ {\n\t\tparseHeader(\"# title\")\n\t}\n}\n",
+		"render_test.go": "package pages\n\nimport \"testing\"\n\n// BenchmarkRenderTitle measures titles.\nfunc BenchmarkRenderTitle(b *testing.B) {\n\tfor i := 0; i < b.N; i++ {\n\t\trenderTitle(Page{})\n\t}\n}\n",
+		// The package example, the example of Lookup, which stays, and tests
+		"pages_test.go": "package pages\n\nimport (\n\t\"fmt\"\n\t\"testing\"\n)\n\nfunc Example() { fmt.Println(len(registry)) }\n\nfunc ExampleLookup() { fmt.Println(Lookup(\"home\").Title) }\n\nfunc TestParseLine(t *testing.T) { parseLine(\"\") }\n",
+	}
+	for name, content := range want {
+		if got := planContent(t, plan, filepath.Join(dir, "pages", name)); got != content {
+			t.Errorf("%s:\ngot:\n%s\nwant:\n%s", name, got, content)
+		}
+	}
+}
+
+func TestSplitFile_TestFileBySubject(t *testing.T) {
+	engine, ws, dir := loadTestModuleWith(t, map[string]string{
+		"pages/pages_test.go": `package pages
+
+import "testing"
+
+func TestLookup(t *testing.T) {}
+
+func TestParseHeader(t *testing.T) {}
+
+func BenchmarkParseHeader(b *testing.B) {}
+
+func ExampleRenderTitle() {}
+
+func Example_renderBody() {}
+
+func TestRenderTitle(t *testing.T) {}
+`,
+		"pages/pages.go": splitFileFiles["pages/pages.go"],
+	})
+	file := filepath.Join(dir, "pages", "pages_test.go")
+
+	plan, err := engine.SplitFile(ws, types.SplitFileRequest{File: file, Strategy: types.SplitPrefix, MaxLines: 5})
+	if err != nil {
+		t.Fatalf("SplitFile: %v", err)
+	}
+	parse := planContent(t, plan, filepath.Join(dir, "pages", "parse_test.go"))
+	if !strings.Contains(parse, "func TestParseHeader(") || !strings.Contains(parse, "func BenchmarkParseHeader(") {
+		t.Errorf("parse_test.go should hold the tests and benchmarks of parseHeader:\n%s", parse)
+	}
+	render := planContent(t, plan, filepath.Join(dir, "pages", "render_test.go"))
+	if !strings.Contains(render, "func ExampleRenderTitle(") || !strings.Contains(render, "func TestRenderTitle(") {
+		t.Errorf("render_test.go should hold the example and test of RenderTitle:\n%s", render)
+	}
+	// Example_renderBody is a package example; TestLookup has no group
+	pages := planContent(t, plan, file)
+	if !strings.Contains(pages, "func Example_renderBody(") || !strings.Contains(pages, "func TestLookup(") {
+		t.Errorf("pages_test.go should keep the package example and TestLookup:\n%s", pages)
+	}
+
+	// The parse cluster is the largest and stays
+	plan, err = engine.SplitFile(ws, types.SplitFileRequest{File: file, Strategy: types.SplitDependency, MaxLines: 5})
+	if err != nil {
+		t.Fatalf("SplitFile by dependency: %v", err)
+	}
+	render = planContent(t, plan, filepath.Join(dir, "pages", "render_title_test.go"))
+	if !strings.Contains(render, "func ExampleRenderTitle(") || !strings.Contains(render, "func TestRenderTitle(") {
+		t.Errorf("render_title_test.go should hold the example and test of RenderTitle:\n%s", render)
+	}
+}
@@ -0,0 +1,24 @@
+package refactor
+
+import (
+	"fmt"
+
+	"github.com/mamaar/gorefactor/pkg/analysis"
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// stringReferenceIssues returns a warning for each string literal of ws
+// that may refer to name, which renaming the identifier leaves as it is.
+func stringReferenceIssues(ws *types.Workspace, name string) []types.Issue {
+	var issues []types.Issue
+	for _, ref := range analysis.FindStringReferences(ws, name) {
+		issues = append(issues, types.Issue{
+			Type:        types.IssueStringReference,
+			Description: fmt.Sprintf("%s may refer to %s by name (%s) and is not renamed", ref.Text, name, ref.Kind),
+			File:        ref.File,
+			Line:        ref.Line,
+			Severity:    types.Warning,
+		})
+	}
+	return issues
+}
@@ -0,0 +1,39 @@
+package refactor
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+func TestRenameSymbol_WarnsAboutStringReferences(t *testing.T) {
+	engine, ws, dir := loadTestModuleWith(t, map[string]string{
+		"b/b.go": "package b\n\nimport \"reflect\"\n\nfunc B(v any) any { return reflect.ValueOf(v).MethodByName(\"A\") }\n",
+	})
+	plan, err := engine.RenameSymbol(ws, types.RenameSymbolRequest{
+		SymbolName: "A",
+		NewName:    "Z",
+		Package:    filepath.Join(dir, "a"),
+		Scope:      types.PackageScope,
+	})
+	if err != nil {
+		t.Fatalf("RenameSymbol: %v", err)
+	}
+
+	var warnings []types.Issue
+	for _, issue := range plan.Impact.PotentialIssues {
+		if issue.Type == types.IssueStringReference {
+			warnings = append(warnings, issue)
+		}
+	}
+	if len(warnings) != 1 || warnings[0].Severity != types.Warning || warnings[0].Line != 5 || !strings.Contains(warnings[0].Description, `"A"`) {
+		t.Errorf("unexpected string reference warnings %+v", warnings)
+	}
+	for _, c := range plan.Changes {
+		if strings.Contains(c.OldText, `"A"`) {
+			t.Errorf("string reference edited: %+v", c)
+		}
+	}
+}
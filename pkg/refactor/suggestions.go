@@ -0,0 +1,192 @@
+package refactor
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// suggestionLimit caps the number of suggestions of each kind attached to an error.
+const suggestionLimit = 5
+
+// withSuggestions attaches recovery suggestions for the named symbol to err
+// when it is a symbol-not-found RefactorError: the packages that do declare
+// the symbol and similarly named symbols. Other errors are returned as is.
+func withSuggestions(ws *types.Workspace, err error, symbol string) error {
+	var rerr *types.RefactorError
+	if ws == nil || symbol == "" || !errors.As(err, &rerr) || len(rerr.Suggestions) > 0 {
+		return err
+	}
+	if rerr.Type == types.SymbolNotFound {
+		rerr.Suggestions = append(packagesDeclaring(ws, symbol), similarSymbols(ws, symbol)...)
+	}
+	return err
+}
+
+// withMoveSuggestions is withSuggestions for moves, additionally suggesting
+// target packages that would neither create an import cycle nor clash with
+// an existing symbol when the requested target does.
+func withMoveSuggestions(ws *types.Workspace, err error, req types.MoveSymbolRequest) error {
+	var rerr *types.RefactorError
+	if ws == nil || !errors.As(err, &rerr) || len(rerr.Suggestions) > 0 {
+		return err
+	}
+	switch rerr.Type {
+	case types.CyclicDependency, types.NameConflict:
+		rerr.Suggestions = moveTargets(ws, req)
+		return err
+	}
+	return withSuggestions(ws, err, req.SymbolName)
+}
+
+// packagesDeclaring suggests the packages whose top-level declarations include name.
+func packagesDeclaring(ws *types.Workspace, name string) []types.Suggestion {
+	var suggestions []types.Suggestion
+	for _, path := range slices.Sorted(maps.Keys(ws.Packages)) {
+		pkg := ws.Packages[path]
+		if pkg.Symbols.FindSymbol(name) == nil {
+			continue
+		}
+		suggestions = append(suggestions, types.Suggestion{
+			Kind:   types.SuggestPackage,
+			Value:  path,
+			Reason: fmt.Sprintf("declares %s", name),
+		})
+		if len(suggestions) == suggestionLimit {
+			break
+		}
+	}
+	return suggestions
+}
+
+// similarSymbols suggests workspace symbols whose names are within a small
+// edit distance of name, or equal ignoring case, closest first.
+func similarSymbols(ws *types.Workspace, name string) []types.Suggestion {
+	type candidate struct {
+		name string
+		pkg  string
+		dist int
+	}
+	maxDist := max(1, len([]rune(name))/3)
+	best := make(map[string]candidate)
+	for _, path := range slices.Sorted(maps.Keys(ws.Packages)) {
+		pkg := ws.Packages[path]
+		if pkg.Symbols == nil {
+			continue
+		}
+		for _, sym := range symbolNames(pkg.Symbols) {
+			if sym == name {
+				continue
+			}
+			dist := editDistance(strings.ToLower(sym), strings.ToLower(name))
+			if dist > maxDist {
+				continue
+			}
+			if c, ok := best[sym]; !ok || dist < c.dist {
+				best[sym] = candidate{name: sym, pkg: path, dist: dist}
+			}
+		}
+	}
+
+	candidates := slices.Collect(maps.Values(best))
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].dist != candidates[j].dist {
+			return candidates[i].dist < candidates[j].dist
+		}
+		return candidates[i].name < candidates[j].name
+	})
+	var suggestions []types.Suggestion
+	for _, c := range candidates[:min(len(candidates), suggestionLimit)] {
+		suggestions = append(suggestions, types.Suggestion{
+			Kind:   types.SuggestSymbol,
+			Value:  c.name,
+			Reason: fmt.Sprintf("declared in %s", c.pkg),
+		})
+	}
+	return suggestions
+}
+
+// moveTargets suggests packages the symbol could be moved to instead,
+// preferring those closest to the requested target in the directory tree.
+func moveTargets(ws *types.Workspace, req types.MoveSymbolRequest) []types.Suggestion {
+	var targets []string
+	for path, pkg := range ws.Packages {
+		if path == req.FromPackage || path == req.ToPackage {
+			continue
+		}
+		if pkg.Symbols.FindSymbol(req.SymbolName) != nil || wouldCreateImportCycle(ws, req.FromPackage, path) {
+			continue
+		}
+		targets = append(targets, path)
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		pi, pj := commonPathPrefix(targets[i], req.ToPackage), commonPathPrefix(targets[j], req.ToPackage)
+		if pi != pj {
+			return pi > pj
+		}
+		return targets[i] < targets[j]
+	})
+
+	var suggestions []types.Suggestion
+	for _, path := range targets[:min(len(targets), suggestionLimit)] {
+		suggestions = append(suggestions, types.Suggestion{
+			Kind:   types.SuggestTargetPackage,
+			Value:  path,
+			Reason: fmt.Sprintf("no import cycle and no existing %s", req.SymbolName),
+		})
+	}
+	return suggestions
+}
+
+// symbolNames lists the names declared in a symbol table, including methods.
+func symbolNames(st *types.SymbolTable) []string {
+	var names []string
+	for _, m := range []map[string]*types.Symbol{st.Functions, st.Types, st.Variables, st.Constants} {
+		names = append(names, slices.Collect(maps.Keys(m))...)
+	}
+	for _, methods := range st.Methods {
+		for _, m := range methods {
+			names = append(names, m.Name)
+		}
+	}
+	return names
+}
+
+// commonPathPrefix returns the number of leading path elements a and b share.
+func commonPathPrefix(a, b string) int {
+	as := strings.Split(filepath.ToSlash(a), "/")
+	bs := strings.Split(filepath.ToSlash(b), "/")
+	n := 0
+	for n < len(as) && n < len(bs) && as[n] == bs[n] {
+		n++
+	}
+	return n
+}
+
+// editDistance returns the Levenshtein distance between a and b in runes.
+func editDistance(a, b string) int {
+	ar, br := []rune(a), []rune(b)
+	prev := make([]int, len(br)+1)
+	cur := make([]int, len(br)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ar); i++ {
+		cur[0] = i
+		for j := 1; j <= len(br); j++ {
+			cost := 1
+			if ar[i-1] == br[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(br)]
+}
@@ -0,0 +1,56 @@
+package refactor
+
+import (
+	"io"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "abc", 0},
+		{"abc", "abd", 1},
+		{"Handler", "Handlr", 1},
+		{"kitten", "sitting", 3},
+		{"größe", "grösse", 2},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestDefaultEngine_SymbolNotFoundSuggestions(t *testing.T) {
+	dir := writeTestModule(t)
+	engine := CreateEngine(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ws, err := engine.LoadWorkspace(dir)
+	if err != nil {
+		t.Fatalf("LoadWorkspace: %v", err)
+	}
+
+	_, err = engine.SafeDelete(ws, types.SafeDeleteRequest{
+		Symbol:     "B",
+		SourceFile: filepath.Join(dir, "a/a.go"),
+	})
+	if err == nil {
+		t.Fatal("expected B not to be found in package a")
+	}
+	suggestions := types.SuggestionsOf(err)
+	if !slices.Contains(suggestions, types.Suggestion{Kind: types.SuggestPackage, Value: filepath.Join(dir, "b"), Reason: "declares B"}) {
+		t.Errorf("expected package b to be suggested, got %+v", suggestions)
+	}
+	if !slices.ContainsFunc(suggestions, func(s types.Suggestion) bool {
+		return s.Kind == types.SuggestSymbol && s.Value == "A"
+	}) {
+		t.Errorf("expected similarly named A to be suggested, got %+v", suggestions)
+	}
+}
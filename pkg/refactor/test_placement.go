@@ -0,0 +1,82 @@
+package refactor
+
+import (
+	"go/ast"
+	"slices"
+	"strings"
+)
+
+// Go ties tests to the code they exercise by name alone: go doc shows
+// ExampleF, ExampleT and ExampleT_M with the function F, the type T and its
+// method M, go vet reports examples naming nothing the package declares,
+// and benchmarks and tests are named after their subjects by convention, in
+// a test file named after their subject's file. Operations moving
+// declarations between files or packages use the rules below to keep these
+// functions, and test files, with what they are about.
+
+// testFuncPrefixes are the name prefixes of the functions go test runs.
+var testFuncPrefixes = []string{"Test", "Benchmark", "Example", "Fuzz"}
+
+// isTestFunc reports whether decl is a function go test runs.
+func isTestFunc(decl *ast.FuncDecl) bool {
+	if decl.Recv != nil || decl.Body == nil {
+		return false
+	}
+	return slices.ContainsFunc(testFuncPrefixes, func(prefix string) bool {
+		return strings.HasPrefix(decl.Name.Name, prefix)
+	})
+}
+
+// testSubject returns the name of the declaration a test function is about,
+// going by the names go doc and go vet expect of examples: ExampleF and
+// ExampleT are about F and T, ExampleT_M is about the method M of T and
+// reported as T, and a lowercase suffix, as in ExampleF_second, only tells
+// examples apart. Example and Example_suffix are about the package, which
+// has no name: "" is returned. Tests, benchmarks and fuzz tests are taken
+// to be named alike, with any underscore after their prefix dropped, and
+// may name an unexported subject with its first letter capitalized.
+func testSubject(name string) string {
+	for _, prefix := range testFuncPrefixes {
+		rest, ok := strings.CutPrefix(name, prefix)
+		if !ok {
+			continue
+		}
+		if prefix != "Example" {
+			rest = strings.TrimLeft(rest, "_")
+		}
+		subject, _, _ := strings.Cut(rest, "_")
+		return subject
+	}
+	return ""
+}
+
+// subjectIn returns the name in declared that the test function name is
+// about, in either spelling testSubject allows, or "" if it is about none
+// of them.
+func subjectIn(name string, declared map[string]bool) string {
+	subject := testSubject(name)
+	switch {
+	case subject == "":
+		return ""
+	case declared[subject]:
+		return subject
+	case declared[unexportName(subject)]:
+		return unexportName(subject)
+	}
+	return ""
+}
+
+// isPackageExample reports whether name is an example of a whole package:
+// Example or Example_suffix.
+func isPackageExample(name string) bool {
+	rest, ok := strings.CutPrefix(name, "Example")
+	return ok && (rest == "" || strings.HasPrefix(rest, "_"))
+}
+
+// fileStemOf returns the name of a Go file without .go, and without _test
+// for a test file, so that a file and the test file exercising it share a
+// stem.
+func fileStemOf(name string) string {
+	stem := strings.TrimSuffix(name, ".go")
+	return strings.TrimSuffix(stem, "_test")
+}
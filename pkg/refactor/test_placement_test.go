@@ -0,0 +1,30 @@
+package refactor
+
+import "testing"
+
+func TestTestSubject(t *testing.T) {
+	declared := map[string]bool{"Store": true, "parse": true, "Load": true}
+	tests := []struct {
+		name, subject, in string
+	}{
+		{"ExampleStore", "Store", "Store"},
+		{"ExampleStore_Get", "Store", "Store"},
+		{"ExampleLoad_second", "Load", "Load"},
+		{"Example", "", ""},
+		{"Example_pipeline", "", ""},
+		{"TestStore_Get", "Store", "Store"},
+		{"Test_parse", "parse", "parse"},
+		{"TestParse", "Parse", "parse"},
+		{"BenchmarkLoad", "Load", "Load"},
+		{"FuzzDecode", "Decode", ""},
+		{"helper", "", ""},
+	}
+	for _, tt := range tests {
+		if got := testSubject(tt.name); got != tt.subject {
+			t.Errorf("testSubject(%q) = %q, want %q", tt.name, got, tt.subject)
+		}
+		if got := subjectIn(tt.name, declared); got != tt.in {
+			t.Errorf("subjectIn(%q) = %q, want %q", tt.name, got, tt.in)
+		}
+	}
+}
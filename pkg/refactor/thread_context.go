@@ -0,0 +1,561 @@
+package refactor
+
+import (
+	"cmp"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/mamaar/gorefactor/pkg/analysis"
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// ThreadContextOperation adds a ctx context.Context parameter to a function
+// and to the callers that should pass their own context down to it. Callers
+// join the chain transitively until they already accept a context, are main,
+// init or a test, or — when Root is set — are not on a call path from Root.
+// Call sites of chain functions pass ctx, an existing context parameter,
+// context.Background() from main and init, or DefaultValue elsewhere, and
+// context.TODO()/Background() calls inside chain functions become ctx.
+type ThreadContextOperation struct {
+	Request types.ThreadContextRequest
+
+	// Resolved by Validate
+	finder *analysis.ReferenceFinder
+	target *ctxFunc
+	files  map[string]*types.File
+}
+
+// ctxFunc is a function declaration taking part in the call graph.
+type ctxFunc struct {
+	file   *types.File
+	decl   *ast.FuncDecl
+	symbol *types.Symbol // nil when the declaration couldn't be resolved
+	param  string        // name of an existing context parameter
+	hasCtx bool          // the function already accepts a context
+}
+
+// ctxCall is a call to a function in the graph.
+type ctxCall struct {
+	file   *types.File
+	call   *ast.CallExpr
+	callee *ctxFunc
+	caller *ctxFunc // nil for calls outside function declarations
+}
+
+func (op *ThreadContextOperation) Type() types.OperationType {
+	return types.ThreadContextOperation
+}
+
+func (op *ThreadContextOperation) Description() string {
+	return fmt.Sprintf("Thread context through %s", op.Request.FunctionName)
+}
+
+func (op *ThreadContextOperation) Validate(ws *types.Workspace) error {
+	req := op.Request
+	if req.FunctionName == "" {
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: "function name is required",
+		}
+	}
+	pkg, ok := ws.Packages[req.Package]
+	if !ok {
+		return &types.RefactorError{
+			Type:    types.SymbolNotFound,
+			Message: fmt.Sprintf("package not found: %s", req.Package),
+		}
+	}
+
+	op.files = make(map[string]*types.File)
+	for _, p := range ws.Packages {
+		for _, f := range p.Files {
+			op.files[f.Path] = f
+		}
+	}
+	if op.finder == nil {
+		op.finder = newReferenceFinder(ws)
+	}
+	symbol, err := op.finder.Resolver().ResolveSymbol(pkg, req.FunctionName)
+	if err != nil {
+		return err
+	}
+	if symbol.Kind != types.FunctionSymbol && symbol.Kind != types.MethodSymbol {
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("%s is not a function or method", req.FunctionName),
+		}
+	}
+	file := op.files[symbol.File]
+	var decl *ast.FuncDecl
+	if file != nil {
+		decl = funcDeclAt(file.AST, symbol.Position)
+	}
+	if decl == nil || decl.Body == nil {
+		return &types.RefactorError{
+			Type:    types.SymbolNotFound,
+			Message: fmt.Sprintf("declaration of %s not found", req.FunctionName),
+		}
+	}
+	op.target = newCtxFunc(file, decl, symbol)
+	if op.target.hasCtx {
+		return &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("%s already accepts a context.Context", req.FunctionName),
+		}
+	}
+	return nil
+}
+
+func (op *ThreadContextOperation) Execute(ws *types.Workspace) (*types.RefactoringPlan, error) {
+	calls, callers, values, err := op.callGraph(ws)
+	if err != nil {
+		return nil, err
+	}
+	chain, err := op.chain(callers)
+	if err != nil {
+		return nil, err
+	}
+
+	inChain := make(map[*ctxFunc]bool)
+	for _, f := range chain {
+		inChain[f] = true
+	}
+
+	var issues []types.Issue
+	for _, v := range values {
+		if inChain[v.callee] {
+			issues = append(issues, types.Issue{
+				Type:        types.IssueCompilationError,
+				Description: fmt.Sprintf("%s is used as a value; update this use to the new signature by hand", funcName(v.callee.decl)),
+				File:        v.ref.File,
+				Line:        v.ref.Line,
+				Severity:    types.Warning,
+			})
+		}
+	}
+
+	var changes []types.Change
+	needsImport := make(map[string]bool)
+	for _, f := range chain {
+		fc, err := op.addParam(ws, f)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, fc...)
+		needsImport[f.file.Path] = true
+		if f.decl.Recv != nil {
+			issues = append(issues, types.Issue{
+				Type:        types.IssueTypeMismatch,
+				Description: fmt.Sprintf("method %s now takes a context; interfaces it implements are not updated", funcName(f.decl)),
+				File:        f.file.Path,
+				Line:        ws.FileSet.Position(f.decl.Pos()).Line,
+				Severity:    types.Warning,
+			})
+		}
+	}
+
+	defaultValue := cmp.Or(op.Request.DefaultValue, "context.TODO()")
+	for _, c := range calls {
+		if !inChain[c.callee] {
+			continue
+		}
+		arg := defaultValue
+		switch {
+		case c.caller == nil:
+		case inChain[c.caller]:
+			arg = "ctx"
+		case c.caller.hasCtx && c.caller.param != "":
+			arg = c.caller.param
+		case isEntryPoint(c.caller.decl):
+			arg = "context.Background()"
+		}
+		if strings.HasPrefix(arg, "context.") {
+			needsImport[c.file.Path] = true
+		}
+		changes = append(changes, insertArg(ws, c, arg))
+	}
+
+	for _, path := range slices.Sorted(maps.Keys(needsImport)) {
+		if hasImport(ws, path, "context") {
+			continue
+		}
+		if change := generateAddImportChange(ws, path, "context"); change != nil {
+			changes = append(changes, *change)
+		}
+	}
+
+	slices.SortFunc(changes, func(a, b types.Change) int {
+		return cmp.Or(cmp.Compare(a.File, b.File), cmp.Compare(a.Start, b.Start))
+	})
+	var affected []string
+	for _, c := range changes {
+		if !slices.Contains(affected, c.File) {
+			affected = append(affected, c.File)
+		}
+	}
+
+	return &types.RefactoringPlan{
+		Changes:       changes,
+		AffectedFiles: affected,
+		Impact: &types.ImpactAnalysis{
+			AffectedFiles:   affected,
+			PotentialIssues: issues,
+		},
+		Reversible: true,
+	}, nil
+}
+
+// ctxValue is a use of a function in the graph other than a call.
+type ctxValue struct {
+	callee *ctxFunc
+	ref    *types.Reference
+}
+
+// callGraph collects the calls to the target and, transitively, to the
+// callers that may take part in the chain. Callers stop the walk when they
+// already accept a context, are entry points, or are Root.
+func (op *ThreadContextOperation) callGraph(ws *types.Workspace) ([]ctxCall, map[*ctxFunc][]*ctxFunc, []ctxValue, error) {
+	funcs := map[*ast.FuncDecl]*ctxFunc{op.target.decl: op.target}
+	callers := make(map[*ctxFunc][]*ctxFunc)
+	var calls []ctxCall
+	var values []ctxValue
+
+	queue := []*ctxFunc{op.target}
+	queued := map[*ctxFunc]bool{op.target: true}
+	for len(queue) > 0 {
+		f := queue[0]
+		queue = queue[1:]
+		refs, err := op.finder.FindReferences(f.symbol)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		// Root gets ctx, but the walk doesn't go past it.
+		expand := f == op.target || funcName(f.decl) != op.Request.Root
+		for _, ref := range refs {
+			file := op.files[ref.File]
+			if file == nil || file.AST == nil {
+				continue
+			}
+			call, decl := callAt(file.AST, ref.Position)
+			if call == nil {
+				values = append(values, ctxValue{callee: f, ref: ref})
+				continue
+			}
+			c := ctxCall{file: file, call: call, callee: f}
+			if decl != nil {
+				caller, ok := funcs[decl]
+				if !ok {
+					caller = newCtxFunc(file, decl, op.resolve(file, decl))
+					funcs[decl] = caller
+				}
+				if expand && !queued[caller] && caller.symbol != nil && !caller.hasCtx && !isEntryPoint(decl) {
+					queued[caller] = true
+					queue = append(queue, caller)
+				}
+				c.caller = caller
+				if !slices.Contains(callers[f], caller) {
+					callers[f] = append(callers[f], caller)
+				}
+			}
+			calls = append(calls, c)
+		}
+	}
+	return calls, callers, values, nil
+}
+
+// chain returns the functions that get a ctx parameter: every function the
+// walk reached that can take one or, with Root set, those with Root among
+// their transitive callers.
+func (op *ThreadContextOperation) chain(callers map[*ctxFunc][]*ctxFunc) ([]*ctxFunc, error) {
+	eligible := func(f *ctxFunc) bool {
+		return f.symbol != nil && !f.hasCtx && !isEntryPoint(f.decl)
+	}
+
+	reachesRoot := make(map[*ctxFunc]bool)
+	var visit func(f *ctxFunc, seen map[*ctxFunc]bool) bool
+	visit = func(f *ctxFunc, seen map[*ctxFunc]bool) bool {
+		if r, ok := reachesRoot[f]; ok {
+			return r
+		}
+		if seen[f] {
+			return false
+		}
+		seen[f] = true
+		if funcName(f.decl) == op.Request.Root {
+			reachesRoot[f] = true
+			return true
+		}
+		for _, caller := range callers[f] {
+			if visit(caller, seen) {
+				reachesRoot[f] = true
+				return true
+			}
+		}
+		return false
+	}
+
+	var chain []*ctxFunc
+	seen := map[*ctxFunc]bool{op.target: true}
+	queue := []*ctxFunc{op.target}
+	for len(queue) > 0 {
+		f := queue[0]
+		queue = queue[1:]
+		if f != op.target && (!eligible(f) || (op.Request.Root != "" && !visit(f, make(map[*ctxFunc]bool)))) {
+			continue
+		}
+		chain = append(chain, f)
+		if funcName(f.decl) == op.Request.Root && f != op.target {
+			continue
+		}
+		for _, caller := range callers[f] {
+			if !seen[caller] {
+				seen[caller] = true
+				queue = append(queue, caller)
+			}
+		}
+	}
+
+	if op.Request.Root != "" && funcName(op.target.decl) != op.Request.Root && !visit(op.target, make(map[*ctxFunc]bool)) {
+		return nil, &types.RefactorError{
+			Type:    types.InvalidOperation,
+			Message: fmt.Sprintf("%s is not called from %s", op.Request.FunctionName, op.Request.Root),
+		}
+	}
+	return chain, nil
+}
+
+// addParam prepends ctx to the parameters of f and replaces the contexts it
+// creates with it.
+func (op *ThreadContextOperation) addParam(ws *types.Workspace, f *ctxFunc) ([]types.Change, error) {
+	params := f.decl.Type.Params
+	for _, field := range params.List {
+		for _, name := range field.Names {
+			if name.Name == "ctx" {
+				return nil, &types.RefactorError{
+					Type:    types.NameConflict,
+					Message: fmt.Sprintf("%s already has a parameter named ctx", funcName(f.decl)),
+					File:    f.file.Path,
+					Line:    ws.FileSet.Position(name.Pos()).Line,
+				}
+			}
+		}
+	}
+
+	name := funcName(f.decl)
+	var changes []types.Change
+	if len(params.List) == 0 {
+		offset := ws.FileSet.Position(params.Opening).Offset + 1
+		changes = append(changes, insertAt(f.file.Path, offset, "ctx context.Context", "Add context parameter to "+name))
+	} else {
+		offset := ws.FileSet.Position(params.List[0].Pos()).Offset
+		changes = append(changes, insertAt(f.file.Path, offset, "ctx context.Context, ", "Add context parameter to "+name))
+	}
+
+	ast.Inspect(f.decl.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			// ctx := context.Background() would now redeclare the parameter.
+			if n.Tok == token.DEFINE && len(n.Lhs) == 1 && len(n.Rhs) == 1 {
+				if id, ok := n.Lhs[0].(*ast.Ident); ok && id.Name == "ctx" && isContextConstructor(n.Rhs[0]) {
+					changes = append(changes, removeStmt(ws, f.file, n, "Replace local context with ctx parameter in "+name))
+					return false
+				}
+			}
+		case *ast.CallExpr:
+			if isContextConstructor(n) {
+				changes = append(changes, types.Change{
+					File:        f.file.Path,
+					Start:       ws.FileSet.Position(n.Pos()).Offset,
+					End:         ws.FileSet.Position(n.End()).Offset,
+					OldText:     nodeText(ws, f.file, n.Pos(), n.End()),
+					NewText:     "ctx",
+					Description: "Replace created context with ctx parameter in " + name,
+				})
+				return false
+			}
+		}
+		return true
+	})
+	return changes, nil
+}
+
+func (op *ThreadContextOperation) resolve(file *types.File, decl *ast.FuncDecl) *types.Symbol {
+	if file.Package == nil {
+		return nil
+	}
+	symbol, err := op.finder.Resolver().ResolveSymbol(file.Package, funcName(decl))
+	if err != nil || symbol.File != file.Path || symbol.Position != decl.Name.Pos() {
+		return nil
+	}
+	return symbol
+}
+
+func newCtxFunc(file *types.File, decl *ast.FuncDecl, symbol *types.Symbol) *ctxFunc {
+	f := &ctxFunc{file: file, decl: decl, symbol: symbol}
+	for _, field := range decl.Type.Params.List {
+		if !isContextType(field.Type) {
+			continue
+		}
+		f.hasCtx = true
+		if len(field.Names) > 0 && field.Names[0].Name != "_" {
+			f.param = field.Names[0].Name
+		}
+		break
+	}
+	return f
+}
+
+// insertArg passes arg as the first argument of a call.
+func insertArg(ws *types.Workspace, c ctxCall, arg string) types.Change {
+	desc := "Pass context in call to " + funcName(c.callee.decl)
+	if len(c.call.Args) == 0 {
+		return insertAt(c.file.Path, ws.FileSet.Position(c.call.Lparen).Offset+1, arg, desc)
+	}
+	return insertAt(c.file.Path, ws.FileSet.Position(c.call.Args[0].Pos()).Offset, arg+", ", desc)
+}
+
+func insertAt(path string, offset int, text, desc string) types.Change {
+	return types.Change{File: path, Start: offset, End: offset, NewText: text, Description: desc}
+}
+
+// removeStmt deletes a statement together with its line when it stands alone.
+func removeStmt(ws *types.Workspace, file *types.File, stmt ast.Stmt, desc string) types.Change {
+	return removeRange(ws, file, stmt.Pos(), stmt.End(), desc)
+}
+
+// removeRange deletes the source from pos to end, together with its lines
+// when nothing else is on them.
+func removeRange(ws *types.Workspace, file *types.File, pos, endPos token.Pos, desc string) types.Change {
+	content := file.OriginalContent
+	start := ws.FileSet.Position(pos).Offset
+	end := ws.FileSet.Position(endPos).Offset
+	lineStart := start
+	for lineStart > 0 && (content[lineStart-1] == ' ' || content[lineStart-1] == '\t') {
+		lineStart--
+	}
+	if (lineStart == 0 || content[lineStart-1] == '\n') && end < len(content) && content[end] == '\n' {
+		start, end = lineStart, end+1
+	}
+	return types.Change{
+		File:        file.Path,
+		Start:       start,
+		End:         end,
+		OldText:     string(content[start:end]),
+		Description: desc,
+	}
+}
+
+// funcDeclAt returns the function declaration whose name is at pos.
+func funcDeclAt(file *ast.File, pos token.Pos) *ast.FuncDecl {
+	if file == nil {
+		return nil
+	}
+	for _, decl := range file.Decls {
+		if fd, ok := decl.(*ast.FuncDecl); ok && fd.Name.Pos() == pos {
+			return fd
+		}
+	}
+	return nil
+}
+
+// callAt returns the call whose callee name is at pos and the function
+// declaration containing it. The call is nil when the name is not called.
+func callAt(file *ast.File, pos token.Pos) (*ast.CallExpr, *ast.FuncDecl) {
+	var call *ast.CallExpr
+	var decl *ast.FuncDecl
+	ast.Inspect(file, func(n ast.Node) bool {
+		if n == nil || call != nil || pos < n.Pos() || pos >= n.End() {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			decl = n
+		case *ast.CallExpr:
+			if id := calleeIdent(n.Fun); id != nil && id.Pos() == pos {
+				call = n
+				return false
+			}
+		}
+		return true
+	})
+	return call, decl
+}
+
+func calleeIdent(fun ast.Expr) *ast.Ident {
+	switch f := fun.(type) {
+	case *ast.Ident:
+		return f
+	case *ast.SelectorExpr:
+		return f.Sel
+	case *ast.IndexExpr:
+		return calleeIdent(f.X)
+	case *ast.IndexListExpr:
+		return calleeIdent(f.X)
+	case *ast.ParenExpr:
+		return calleeIdent(f.X)
+	}
+	return nil
+}
+
+// funcName returns the name of a function as ResolveSymbol accepts it:
+// Type.Method for methods.
+func funcName(decl *ast.FuncDecl) string {
+	if recv := receiverTypeName(decl); recv != "" {
+		return recv + "." + decl.Name.Name
+	}
+	return decl.Name.Name
+}
+
+// isEntryPoint reports whether a function's signature is fixed by the
+// toolchain: main, init and test functions.
+func isEntryPoint(decl *ast.FuncDecl) bool {
+	if decl.Recv != nil {
+		return false
+	}
+	switch name := decl.Name.Name; {
+	case name == "main", name == "init":
+		return true
+	case strings.HasPrefix(name, "Test"), strings.HasPrefix(name, "Benchmark"),
+		strings.HasPrefix(name, "Fuzz"), strings.HasPrefix(name, "Example"):
+		params := decl.Type.Params.List
+		if len(params) == 1 {
+			if star, ok := params[0].Type.(*ast.StarExpr); ok {
+				if sel, ok := star.X.(*ast.SelectorExpr); ok {
+					if pkg, ok := sel.X.(*ast.Ident); ok && pkg.Name == "testing" {
+						return true
+					}
+				}
+			}
+		}
+		return strings.HasPrefix(name, "Example") && len(params) == 0
+	}
+	return false
+}
+
+func isContextType(expr ast.Expr) bool {
+	sel, ok := expr.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	pkg, ok := sel.X.(*ast.Ident)
+	return ok && pkg.Name == "context" && sel.Sel.Name == "Context"
+}
+
+// isContextConstructor reports whether expr is context.TODO() or
+// context.Background().
+func isContextConstructor(expr ast.Expr) bool {
+	call, ok := expr.(*ast.CallExpr)
+	if !ok || len(call.Args) != 0 {
+		return false
+	}
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	pkg, ok := sel.X.(*ast.Ident)
+	return ok && pkg.Name == "context" && (sel.Sel.Name == "TODO" || sel.Sel.Name == "Background")
+}
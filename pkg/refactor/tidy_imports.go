@@ -0,0 +1,56 @@
+package refactor
+
+import (
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// ImportTidier formats single Go files the way the serializer formats the
+// files a refactoring writes. It only reads go.mod and go.work, so checking
+// a handful of changed files doesn't pay for loading the workspace.
+type ImportTidier struct {
+	style            FormatStyle
+	modulePath       string
+	workspaceModules []string
+}
+
+// NewImportTidier returns a tidier for the module rooted at root.
+func NewImportTidier(root string, style FormatStyle) (*ImportTidier, error) {
+	content, err := os.ReadFile(filepath.Join(root, "go.mod"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read go.mod: %w", err)
+	}
+	t := &ImportTidier{style: style, modulePath: parseModuleName(content)}
+	modules, _ := discoverWorkspaceModules(root)
+	t.workspaceModules = slices.DeleteFunc(modules, func(m string) bool { return m == t.modulePath })
+	return t, nil
+}
+
+// Tidy groups the imports of src and gofmts it.
+func (t *ImportTidier) Tidy(src []byte) ([]byte, error) {
+	code := string(src)
+	if classify := t.style.classifier(t.modulePath, t.workspaceModules); classify != nil {
+		code = groupImports(code, classify)
+	}
+	return format.Source([]byte(code))
+}
+
+// Format tidies src, then runs the configured formatter on it in dir.
+func (t *ImportTidier) Format(dir string, src []byte) ([]byte, error) {
+	tidied, err := t.Tidy(src)
+	if err != nil {
+		return nil, err
+	}
+	command := t.style.command()
+	if len(command) == 0 {
+		return tidied, nil
+	}
+	formatted, err := runFormatter(command, dir, string(tidied))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(formatted), nil
+}
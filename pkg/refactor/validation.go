@@ -7,9 +7,7 @@ import (
 	"go/types"
 	"io"
 	"log/slog"
-	"slices"
 	"strings"
-	"unicode"
 
 	"github.com/mamaar/gorefactor/pkg/analysis"
 	refactorTypes "github.com/mamaar/gorefactor/pkg/types"
@@ -19,12 +17,16 @@ import (
 type Validator struct {
 	typeChecker *types.Checker
 	logger      *slog.Logger
+
+	// Finder for one validation; the engine's shares its reference index
+	references func(ws *refactorTypes.Workspace) *analysis.ReferenceFinder
 }
 
 func NewValidator(logger *slog.Logger) *Validator {
 	return &Validator{
 		typeChecker: &types.Checker{},
 		logger:      logger,
+		references:  newReferenceFinder,
 	}
 }
 
@@ -35,6 +37,16 @@ func (v *Validator) ValidatePlan(plan *refactorTypes.RefactoringPlan) error {
 
 // ValidatePlanWithConfig validates a complete refactoring plan with configuration options
 func (v *Validator) ValidatePlanWithConfig(plan *refactorTypes.RefactoringPlan, config *EngineConfig) error {
+	// Use default config if none provided
+	if config == nil {
+		config = DefaultConfig()
+	}
+	return v.ValidatePlanWithPolicy(plan, func(refactorTypes.Issue) bool { return config.AllowBreaking })
+}
+
+// ValidatePlanWithPolicy validates a complete refactoring plan, accepting the
+// critical issues for which allowBreaking returns true
+func (v *Validator) ValidatePlanWithPolicy(plan *refactorTypes.RefactoringPlan, allowBreaking func(refactorTypes.Issue) bool) error {
 	if plan == nil {
 		return &refactorTypes.RefactorError{
 			Type:    refactorTypes.InvalidOperation,
@@ -42,11 +54,6 @@ func (v *Validator) ValidatePlanWithConfig(plan *refactorTypes.RefactoringPlan,
 		}
 	}
 
-	// Use default config if none provided
-	if config == nil {
-		config = DefaultConfig()
-	}
-
 	var allIssues []refactorTypes.Issue
 
 	// Validate each operation
@@ -70,9 +77,14 @@ func (v *Validator) ValidatePlanWithConfig(plan *refactorTypes.RefactoringPlan,
 	cycleIssues := v.validateImportCycles(plan)
 	allIssues = append(allIssues, cycleIssues...)
 
-	// Return validation error if any critical issues found, unless AllowBreaking is enabled
-	criticalIssues := v.filterCriticalIssues(allIssues)
-	if len(criticalIssues) > 0 && !config.AllowBreaking {
+	// Return validation error if any critical issues found that the breaking-change policy doesn't allow
+	var criticalIssues []refactorTypes.Issue
+	for _, issue := range v.filterCriticalIssues(allIssues) {
+		if !allowBreaking(issue) {
+			criticalIssues = append(criticalIssues, issue)
+		}
+	}
+	if len(criticalIssues) > 0 {
 		return &refactorTypes.ValidationError{
 			Issues: criticalIssues,
 		}
@@ -122,7 +134,8 @@ func (v *Validator) ValidateMove(ws *refactorTypes.Workspace, req refactorTypes.
 		return issues
 	}
 
-	resolver := analysis.NewSymbolResolver(ws, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	refs := v.references(ws)
+	resolver := refs.Resolver()
 	symbol, err := resolver.ResolveSymbol(sourcePackage, req.SymbolName)
 	if err != nil {
 		issues = append(issues, refactorTypes.Issue{
@@ -164,18 +177,19 @@ func (v *Validator) ValidateMove(ws *refactorTypes.Workspace, req refactorTypes.
 		}
 	}
 
+	references, err := refs.FindReferences(symbol)
+
 	// Check for import cycle creation
-	if v.wouldCreateImportCycle(ws, req.FromPackage, req.ToPackage) {
+	if pkg, ok := moveImportCycle(ws, req.FromPackage, req.ToPackage, symbol, references); err == nil && ok {
 		issues = append(issues, refactorTypes.Issue{
 			Type:        refactorTypes.IssueImportCycle,
-			Description: fmt.Sprintf("moving symbol would create import cycle between %s and %s", req.FromPackage, req.ToPackage),
+			Description: fmt.Sprintf("moving symbol would create import cycle between %s and %s: %s still uses %s, and %s imports it", pkg, req.ToPackage, pkg, req.SymbolName, req.ToPackage),
 			Severity:    refactorTypes.Error,
 		})
 	}
 
 	// Check visibility rules (unexported symbols crossing packages)
 	if !symbol.Exported && req.FromPackage != req.ToPackage {
-		references, err := resolver.FindReferences(symbol)
 		if err == nil {
 			for _, ref := range references {
 				refPackage := v.findPackageForFile(ws, ref.File)
@@ -366,7 +380,7 @@ func (v *Validator) validateCompilation(plan *refactorTypes.RefactoringPlan) []r
 
 	for _, change := range plan.Changes {
 		// Basic syntax validation of new text
-		if change.NewText != "" {
+		if change.NewText != "" && !change.Checked {
 			// Skip validation for code fragments that aren't valid standalone Go:
 			// - Interface method signatures (method name + signature)
 			// - Parameter list changes (just parameter declarations)
@@ -438,39 +452,8 @@ func (v *Validator) isSymbolMoveable(symbol *refactorTypes.Symbol) bool {
 	}
 }
 
-func (v *Validator) wouldCreateImportCycle(ws *refactorTypes.Workspace, fromPkg, toPkg string) bool {
-	if ws.Dependencies == nil {
-		return false
-	}
-
-	// Check if toPkg already depends on fromPkg
-	toDeps, exists := ws.Dependencies.PackageDeps[toPkg]
-	if !exists {
-		return false
-	}
-
-	return slices.Contains(toDeps, fromPkg)
-}
-
 func (v *Validator) isValidGoIdentifier(name string) bool {
-	if len(name) == 0 {
-		return false
-	}
-
-	// First character must be letter or underscore
-	first := rune(name[0])
-	if !unicode.IsLetter(first) && first != '_' {
-		return false
-	}
-
-	// Remaining characters must be letters, digits, or underscores
-	for _, r := range name[1:] {
-		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
-			return false
-		}
-	}
-
-	return true
+	return isValidGoIdentifier(name)
 }
 
 func (v *Validator) isGoKeyword(name string) bool {
@@ -638,5 +621,5 @@ func (v *Validator) findPackageForFile(ws *refactorTypes.Workspace, filePath str
 }
 
 func (v *Validator) isExported(name string) bool {
-	return len(name) > 0 && unicode.IsUpper(rune(name[0]))
+	return token.IsExported(name)
 }
@@ -371,6 +371,11 @@ func TestValidator_isValidGoIdentifier(t *testing.T) {
 		{"invalid.name", false},
 		{"", false},
 		{"validName_123", true},
+		{"Ünïcode", true},
+		{"变量", true},
+		{"größe2", true},
+		{"٣abc", false}, // Arabic-Indic digit cannot start an identifier
+		{"a→b", false},
 	}
 
 	for _, tc := range testCases {
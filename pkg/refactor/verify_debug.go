@@ -0,0 +1,7 @@
+//go:build gorefactor_debug
+
+package refactor
+
+// verifyInternalDefault turns workspace invariant checks on in debug builds
+// (go build -tags gorefactor_debug), whatever EngineConfig.VerifyInternal says.
+const verifyInternalDefault = true
@@ -0,0 +1,7 @@
+//go:build !gorefactor_debug
+
+package refactor
+
+// verifyInternalDefault leaves workspace invariant checks to
+// EngineConfig.VerifyInternal outside debug builds.
+const verifyInternalDefault = false
@@ -0,0 +1,218 @@
+package refactor
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/mamaar/gorefactor/pkg/history"
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// TestVerification selects the tests ExecutePlan runs before and after
+// applying a plan to check that the plan kept the code's behaviour.
+type TestVerification string
+
+const (
+	// VerifyTestsOff runs no tests. It is the default.
+	VerifyTestsOff TestVerification = "off"
+	// VerifyTestsAffected tests the packages of the plan's affected files
+	// and affected packages.
+	VerifyTestsAffected TestVerification = "affected"
+	// VerifyTestsAll tests every package of the workspace, ./...
+	VerifyTestsAll TestVerification = "all"
+)
+
+// ParseTestVerification returns the verification named s; empty is off.
+func ParseTestVerification(s string) (TestVerification, error) {
+	switch v := TestVerification(s); v {
+	case "", VerifyTestsOff:
+		return VerifyTestsOff, nil
+	case VerifyTestsAffected, VerifyTestsAll:
+		return v, nil
+	}
+	return "", fmt.Errorf("unknown test verification %q (want off, affected or all)", s)
+}
+
+// maxFailureOutput bounds the lines of output reported per new failure.
+const maxFailureOutput = 20
+
+// testFailure is a failed test, or a package that failed without a failing
+// test, such as one that doesn't build.
+type testFailure struct {
+	Package string
+	Test    string // Empty for the package itself
+}
+
+func (f testFailure) String() string {
+	if f.Test == "" {
+		return f.Package
+	}
+	return f.Package + "." + f.Test
+}
+
+// testVerification returns the configured test verification.
+func (e *DefaultEngine) testVerification() TestVerification {
+	if e.config == nil || e.config.VerifyTests == "" {
+		return VerifyTestsOff
+	}
+	return e.config.VerifyTests
+}
+
+// testPatterns returns the go test patterns that scope selects for plan,
+// relative to the workspace root. Directories that don't exist, such as
+// the target of a move before it is applied, are left out.
+func (e *DefaultEngine) testPatterns(scope TestVerification, plan *types.RefactoringPlan) []string {
+	if scope == VerifyTestsAll {
+		return []string{"./..."}
+	}
+	dirs := make(map[string]bool)
+	for _, file := range plan.AffectedFiles {
+		if strings.HasSuffix(file, ".go") {
+			dirs[filepath.Dir(file)] = true
+		}
+	}
+	if plan.Impact != nil {
+		for _, pkg := range plan.Impact.AffectedPackages {
+			if !filepath.IsAbs(pkg) {
+				pkg = filepath.Join(e.root, pkg)
+			}
+			dirs[pkg] = true
+		}
+	}
+
+	var patterns []string
+	for _, dir := range slices.Sorted(maps.Keys(dirs)) {
+		rel, err := filepath.Rel(e.root, dir)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			continue
+		}
+		patterns = append(patterns, "./"+filepath.ToSlash(rel))
+	}
+	return patterns
+}
+
+// failingTests runs go test on patterns from the workspace root and returns
+// what failed, with the output of each failure.
+func (e *DefaultEngine) failingTests(ctx context.Context, patterns []string) (map[testFailure][]string, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	cmd := exec.CommandContext(ctx, "go", append([]string{"test", "-json"}, patterns...)...)
+	cmd.Dir = e.root
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, runErr := cmd.Output()
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
+
+	type event struct {
+		Action  string
+		Package string
+		Test    string
+		Output  string
+	}
+	output := make(map[testFailure][]string)
+	failed := make(map[testFailure]bool)
+	events := 0
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var ev event
+		if json.Unmarshal(scanner.Bytes(), &ev) != nil || ev.Package == "" {
+			continue
+		}
+		events++
+		key := testFailure{Package: ev.Package, Test: ev.Test}
+		switch ev.Action {
+		case "output":
+			output[key] = append(output[key], strings.TrimRight(ev.Output, "\n"))
+		case "fail":
+			failed[key] = true
+		}
+	}
+	if runErr != nil && events == 0 {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = runErr.Error()
+		}
+		return nil, fmt.Errorf("go test %s: %s", strings.Join(patterns, " "), msg)
+	}
+
+	// A package fails along with its failing tests; it is only reported when
+	// nothing else explains the failure.
+	withTests := make(map[string]bool)
+	for f := range failed {
+		if f.Test != "" {
+			withTests[f.Package] = true
+		}
+	}
+	failures := make(map[testFailure][]string)
+	for f := range failed {
+		if f.Test == "" && withTests[f.Package] {
+			continue
+		}
+		failures[f] = output[f]
+	}
+	return failures, nil
+}
+
+// newTestFailures returns the failures of after that baseline doesn't have,
+// sorted. Tests of a package that failed as a whole before are not new.
+func newTestFailures(baseline, after map[testFailure][]string) []testFailure {
+	var failures []testFailure
+	for f := range after {
+		if _, ok := baseline[f]; ok {
+			continue
+		}
+		if _, ok := baseline[testFailure{Package: f.Package}]; ok {
+			continue
+		}
+		failures = append(failures, f)
+	}
+	slices.SortFunc(failures, func(a, b testFailure) int {
+		return cmp.Or(cmp.Compare(a.Package, b.Package), cmp.Compare(a.Test, b.Test))
+	})
+	return failures
+}
+
+// testFailureError describes failures, with the output of each, as the
+// error of a plan that was rolled back.
+func testFailureError(failures []testFailure, after map[testFailure][]string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "plan broke %d tests and was rolled back:", len(failures))
+	for _, f := range failures {
+		fmt.Fprintf(&b, "\n--- %s", f)
+		lines := after[f]
+		if len(lines) > maxFailureOutput {
+			lines = append(lines[:maxFailureOutput:maxFailureOutput], "...")
+		}
+		for _, line := range lines {
+			fmt.Fprintf(&b, "\n    %s", line)
+		}
+	}
+	return &types.RefactorError{Type: types.InvalidOperation, Message: b.String()}
+}
+
+// rollbackPlan restores the files of an applied plan from snapshot and, if
+// journal recorded the plan, drops its entry.
+func rollbackPlan(journal *history.Journal, snapshot *history.Entry) error {
+	if journal != nil && snapshot.ID != "" {
+		_, err := journal.Rollback(snapshot.ID, false)
+		return err
+	}
+	return snapshot.Restore()
+}
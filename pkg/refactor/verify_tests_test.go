@@ -0,0 +1,86 @@
+package refactor
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+func TestExecutePlan_VerifyTests(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not installed")
+	}
+	src := "package a\n\nfunc A() int { return 1 }\n"
+	engine, _, dir := loadTestModuleWith(t, map[string]string{
+		"a/a.go":      src,
+		"a/a_test.go": "package a\n\nimport \"testing\"\n\nfunc TestA(t *testing.T) {\n\tif A() != 1 {\n\t\tt.Fatal(\"A changed\")\n\t}\n}\n",
+		// Already failing, so not the plan's fault
+		"b/b_test.go": "package b\n\nimport \"testing\"\n\nfunc TestB(t *testing.T) { t.Fatal(\"broken\") }\n",
+	})
+	engine.Config().VerifyTests = VerifyTestsAll
+	file := filepath.Join(dir, "a", "a.go")
+	returning := func(value string) *types.RefactoringPlan {
+		start := strings.Index(src, "1 }")
+		return &types.RefactoringPlan{
+			Changes:       []types.Change{{File: file, Start: start, End: start + 1, OldText: "1", NewText: value}},
+			AffectedFiles: []string{file},
+		}
+	}
+
+	err := engine.ExecutePlan(returning("2"))
+	if err == nil || !strings.Contains(err.Error(), "example.com/p/a.TestA") || !strings.Contains(err.Error(), "A changed") {
+		t.Fatalf("expected TestA to be reported, got %v", err)
+	}
+	if strings.Contains(err.Error(), "TestB") {
+		t.Errorf("a test failing before the plan was reported: %v", err)
+	}
+	if got, _ := os.ReadFile(file); string(got) != src {
+		t.Errorf("plan was not rolled back:\n%s", got)
+	}
+
+	if err := engine.ExecutePlan(returning("2 - 1")); err != nil {
+		t.Fatalf("plan keeping the tests passing: %v", err)
+	}
+	if got, _ := os.ReadFile(file); !strings.Contains(string(got), "return 2 - 1") {
+		t.Errorf("plan was not applied:\n%s", got)
+	}
+}
+
+func TestParseTestVerification(t *testing.T) {
+	for in, want := range map[string]TestVerification{"": VerifyTestsOff, "off": VerifyTestsOff, "affected": VerifyTestsAffected, "all": VerifyTestsAll} {
+		if got, err := ParseTestVerification(in); err != nil || got != want {
+			t.Errorf("ParseTestVerification(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseTestVerification("some"); err == nil {
+		t.Error("expected an error for an unknown scope")
+	}
+}
+
+func TestExecutePlan_VerifyTestsRollsBackCompileFailure(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not installed")
+	}
+	src := "package a\n\nfunc A() int { return 1 }\n"
+	engine, _, dir := loadTestModuleWith(t, map[string]string{"a/a.go": src})
+	engine.Config().VerifyTests = VerifyTestsAffected
+	engine.Config().SkipCompilation = false
+	file := filepath.Join(dir, "a", "a.go")
+	start := strings.Index(src, "1 }")
+	plan := &types.RefactoringPlan{
+		Changes:       []types.Change{{File: file, Start: start, End: start + 1, OldText: "1", NewText: "undefinedName"}},
+		AffectedFiles: []string{file},
+	}
+
+	err := engine.ExecutePlan(plan)
+	if err == nil || !strings.Contains(err.Error(), "does not compile and was rolled back") {
+		t.Fatalf("expected the compile failure to be reported, got %v", err)
+	}
+	if got, _ := os.ReadFile(file); string(got) != src {
+		t.Errorf("plan was not rolled back:\n%s", got)
+	}
+}
@@ -0,0 +1,260 @@
+package refactor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/mamaar/gorefactor/pkg/analysis"
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// VirtualWorkspace applies plans to a loaded workspace in memory: file
+// contents, ASTs and symbol tables are updated as if the plan had been
+// written, so the next plan is made against the code the previous ones
+// produce without the offsets going stale. Nothing touches disk until
+// Flush.
+//
+// The workspace must be one the engine loaded, or a later version of it,
+// and is modified in place; reload it to drop staged changes.
+type VirtualWorkspace struct {
+	engine   *DefaultEngine
+	ws       *types.Workspace
+	resolver *analysis.SymbolResolver     // Rebuilds the symbol tables of changed packages
+	analyzer *analysis.DependencyAnalyzer // Rebuilds the dependency graph of ws
+	original map[string][]byte            // Disk content of every staged file; nil when it didn't exist
+	current  map[string][]byte            // Staged content; nil when deleted
+	plans    []string                     // Descriptions of the staged plans
+}
+
+// NewVirtualWorkspace stages plans on ws, which e must have loaded.
+func NewVirtualWorkspace(e *DefaultEngine, ws *types.Workspace) *VirtualWorkspace {
+	return &VirtualWorkspace{
+		engine:   e,
+		ws:       ws,
+		resolver: analysis.NewSymbolResolver(ws, e.logger),
+		analyzer: analysis.NewDependencyAnalyzer(ws, e.logger),
+		original: make(map[string][]byte),
+		current:  make(map[string][]byte),
+	}
+}
+
+// Workspace returns the workspace with the staged changes applied.
+func (v *VirtualWorkspace) Workspace() *types.Workspace {
+	return v.ws
+}
+
+// Apply validates plan and applies it to the workspace in memory. If any
+// file fails to apply or to parse, nothing is staged.
+func (v *VirtualWorkspace) Apply(plan *types.RefactoringPlan) error {
+	if err := v.engine.checkPlan(plan); err != nil {
+		return err
+	}
+
+	byFile := make(map[string][]types.Change)
+	for _, c := range plan.Changes {
+		byFile[c.File] = append(byFile[c.File], c)
+	}
+	contents := make(map[string][]byte, len(byFile))
+	parsed := make(map[string]*types.File)
+	for _, path := range slices.Sorted(maps.Keys(byFile)) {
+		before, err := v.read(path)
+		if err != nil {
+			return err
+		}
+		after, err := v.engine.serializer.renderFile(path, string(before), slices.Clone(byFile[path]))
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if after == "" && len(before) > 0 {
+			contents[path] = nil
+			continue
+		}
+		contents[path] = []byte(after)
+		if strings.HasSuffix(path, ".go") {
+			if parsed[path], err = v.engine.parser.ParseSource(path, contents[path]); err != nil {
+				return err
+			}
+		}
+	}
+
+	for path, content := range contents {
+		if _, ok := v.original[path]; !ok {
+			orig, err := os.ReadFile(path)
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+			v.original[path] = orig
+		}
+		v.current[path] = content
+	}
+	v.update(contents, parsed)
+	v.plans = append(v.plans, planDescription(plan))
+	return nil
+}
+
+// Dirty reports whether any change is staged.
+func (v *VirtualWorkspace) Dirty() bool {
+	return len(v.current) > 0
+}
+
+// Pending returns a plan that writes the staged state to disk: one change
+// replacing the whole content of each staged file that differs from disk.
+// Each change carries the disk content it expects as OldText, so a file
+// edited on disk in the meantime makes the plan fail rather than lose the
+// edit.
+func (v *VirtualWorkspace) Pending() *types.RefactoringPlan {
+	plan := &types.RefactoringPlan{
+		Changes:       make([]types.Change, 0),
+		AffectedFiles: make([]string, 0),
+		Impact:        &types.ImpactAnalysis{},
+		Reversible:    true,
+	}
+	desc := strings.Join(v.plans, "; ")
+	for _, path := range slices.Sorted(maps.Keys(v.current)) {
+		orig, content := v.original[path], v.current[path]
+		if string(orig) == string(content) {
+			continue
+		}
+		plan.Changes = append(plan.Changes, types.Change{
+			File:        path,
+			Start:       0,
+			End:         len(orig),
+			OldText:     string(orig),
+			NewText:     string(content),
+			Description: desc,
+		})
+		plan.AffectedFiles = append(plan.AffectedFiles, path)
+	}
+	return plan
+}
+
+// Flush writes the staged changes through the engine, as one plan, and
+// clears them.
+func (v *VirtualWorkspace) Flush(ctx context.Context) error {
+	plan := v.Pending()
+	if len(plan.Changes) > 0 {
+		if err := v.engine.ExecutePlanContext(ctx, plan); err != nil {
+			return err
+		}
+	}
+	clear(v.original)
+	clear(v.current)
+	v.plans = nil
+	return nil
+}
+
+// read returns the staged content of path, or its content on disk. A file
+// that doesn't exist is empty.
+func (v *VirtualWorkspace) read(path string) ([]byte, error) {
+	if content, ok := v.current[path]; ok {
+		return content, nil
+	}
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return content, err
+}
+
+// update brings the packages holding the changed Go files in line with
+// their new contents. Type information is dropped everywhere, since any
+// package may depend on the changed ones; it is rebuilt on demand.
+func (v *VirtualWorkspace) update(contents map[string][]byte, parsed map[string]*types.File) {
+	touched := make(map[*types.Package]bool)
+	for _, path := range slices.Sorted(maps.Keys(contents)) {
+		if !strings.HasSuffix(path, ".go") {
+			continue
+		}
+		dir, base := filepath.Dir(path), filepath.Base(path)
+		isTest := strings.HasSuffix(base, "_test.go")
+		pkg := v.ws.Packages[dir]
+		v.resolver.InvalidateCacheForFile(path)
+
+		file := parsed[path]
+		if file == nil {
+			if pkg != nil {
+				delete(pkg.Files, base)
+				delete(pkg.TestFiles, base)
+				touched[pkg] = true
+			}
+			continue
+		}
+		if pkg == nil {
+			if isTest {
+				continue // Not loadable without a non-test file, as on disk
+			}
+			pkg = &types.Package{
+				Dir:       dir,
+				Path:      dir,
+				Name:      file.AST.Name.Name,
+				Files:     make(map[string]*types.File),
+				TestFiles: make(map[string]*types.File),
+			}
+			pkg.ImportPath = analysis.ComputeImportPath(v.ws, dir)
+			v.ws.Packages[dir] = pkg
+			if pkg.ImportPath != "" {
+				v.ws.ImportToPath[pkg.ImportPath] = dir
+			}
+		}
+		file.Package = pkg
+		if isTest {
+			pkg.TestFiles[base] = file
+		} else {
+			pkg.Files[base] = file
+		}
+		touched[pkg] = true
+	}
+
+	for pkg := range touched {
+		v.resolver.InvalidateCacheForPackage(pkg.Path)
+		if len(pkg.Files) == 0 {
+			delete(v.ws.Packages, pkg.Path)
+			if pkg.ImportPath != "" {
+				delete(v.ws.ImportToPath, pkg.ImportPath)
+			}
+			continue
+		}
+		pkg.Imports = pkg.Imports[:0]
+		for _, name := range slices.Sorted(maps.Keys(pkg.Files)) {
+			for _, imp := range pkg.Files[name].AST.Imports {
+				if path := strings.Trim(imp.Path.Value, `"`); !slices.Contains(pkg.Imports, path) {
+					pkg.Imports = append(pkg.Imports, path)
+				}
+			}
+		}
+		if _, err := v.resolver.BuildSymbolTable(pkg); err != nil {
+			v.engine.logger.Warn("symbol table rebuild failed", "package", pkg.Path, "err", err)
+		}
+	}
+	for _, pkg := range v.ws.Packages {
+		pkg.TypesInfo, pkg.TypesPkg = nil, nil
+	}
+	if _, err := v.analyzer.BuildDependencyGraph(); err != nil {
+		v.engine.logger.Warn("dependency graph rebuild failed", "err", err)
+	}
+}
+
+// readSource returns the content of a workspace file as the workspace holds
+// it, which differs from disk while a VirtualWorkspace has changes staged.
+// Files the workspace doesn't hold are read from disk.
+func readSource(ws *types.Workspace, path string) ([]byte, error) {
+	if ws != nil {
+		if pkg := ws.Packages[filepath.Dir(path)]; pkg != nil {
+			base := filepath.Base(path)
+			if f := pkg.Files[base]; f != nil && f.OriginalContent != nil {
+				return f.OriginalContent, nil
+			}
+			if f := pkg.TestFiles[base]; f != nil && f.OriginalContent != nil {
+				return f.OriginalContent, nil
+			}
+		}
+	}
+	return os.ReadFile(path)
+}
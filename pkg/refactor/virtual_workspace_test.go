@@ -0,0 +1,83 @@
+package refactor
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+func TestVirtualWorkspace_ComposesPlans(t *testing.T) {
+	dir := writeTestModule(t)
+	path := filepath.Join(dir, "a/a.go")
+	engine := CreateEngineWithConfig(&EngineConfig{SkipCompilation: true}, slog.New(slog.NewTextHandler(io.Discard, nil))).(*DefaultEngine)
+	ws, err := engine.LoadWorkspace(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vws := NewVirtualWorkspace(engine, ws)
+
+	// The second rename only finds its symbol if the first one is staged.
+	for _, req := range []types.RenameSymbolRequest{
+		{SymbolName: "A", NewName: "First"},
+		{SymbolName: "First", NewName: "Second"},
+	} {
+		plan, err := engine.RenameSymbol(vws.Workspace(), req)
+		if err != nil {
+			t.Fatalf("rename %s: %v", req.SymbolName, err)
+		}
+		if err := vws.Apply(plan); err != nil {
+			t.Fatalf("apply rename %s: %v", req.SymbolName, err)
+		}
+	}
+	if got, _ := os.ReadFile(path); string(got) != "package a\n\nfunc A() {}\n" {
+		t.Fatalf("a.go was written before Flush:\n%s", got)
+	}
+	if !vws.Dirty() || len(vws.Pending().Changes) != 1 {
+		t.Fatalf("expected one pending change, got %+v", vws.Pending().Changes)
+	}
+
+	if err := vws.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(path); !strings.Contains(string(got), "func Second()") {
+		t.Errorf("a.go does not have both renames:\n%s", got)
+	}
+	if vws.Dirty() {
+		t.Error("changes still staged after Flush")
+	}
+}
+
+func TestVirtualWorkspace_FlushRefusesChangedFiles(t *testing.T) {
+	dir := writeTestModule(t)
+	path := filepath.Join(dir, "a/a.go")
+	engine := CreateEngineWithConfig(&EngineConfig{SkipCompilation: true}, slog.New(slog.NewTextHandler(io.Discard, nil))).(*DefaultEngine)
+	ws, err := engine.LoadWorkspace(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vws := NewVirtualWorkspace(engine, ws)
+	plan, err := engine.RenameSymbol(ws, types.RenameSymbolRequest{SymbolName: "A", NewName: "First"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := vws.Apply(plan); err != nil {
+		t.Fatal(err)
+	}
+
+	edited := "package a\n\n// edited\nfunc A() {}\n"
+	if err := os.WriteFile(path, []byte(edited), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := vws.Flush(context.Background()); err == nil {
+		t.Error("expected Flush to fail after a.go changed on disk")
+	}
+	if got, _ := os.ReadFile(path); string(got) != edited {
+		t.Errorf("the edit on disk was overwritten:\n%s", got)
+	}
+}
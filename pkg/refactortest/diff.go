@@ -0,0 +1,94 @@
+package refactortest
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+// Diff returns a unified diff from expected to actual, with hunks of changed
+// lines surrounded by a few lines of context. It returns "" if they are equal.
+func Diff(expected, actual string) string {
+	if expected == actual {
+		return ""
+	}
+	a := strings.Split(expected, "\n")
+	b := strings.Split(actual, "\n")
+	ops := diffLines(a, b)
+
+	var buf strings.Builder
+	buf.WriteString("--- expected\n+++ actual\n")
+	for start := 0; start < len(ops); {
+		// Find the next change and the extent of its hunk.
+		first := start
+		for first < len(ops) && ops[first].kind == ' ' {
+			first++
+		}
+		if first == len(ops) {
+			break
+		}
+		lo := max(first-diffContext, start)
+		hi := first
+		for i := first; i < len(ops); i++ {
+			if ops[i].kind != ' ' {
+				hi = i + 1
+			} else if i-hi >= 2*diffContext {
+				break
+			}
+		}
+		hi = min(hi+diffContext, len(ops))
+
+		fmt.Fprintf(&buf, "@@ -%d +%d @@\n", ops[lo].aLine, ops[lo].bLine)
+		for _, op := range ops[lo:hi] {
+			fmt.Fprintf(&buf, "%c%s\n", op.kind, op.text)
+		}
+		start = hi
+	}
+	return buf.String()
+}
+
+// diffOp is one line of a diff: ' ' kept, '-' removed from expected, '+'
+// added in actual. aLine and bLine are the 1-based positions in each input.
+type diffOp struct {
+	kind         byte
+	text         string
+	aLine, bLine int
+}
+
+// diffLines computes a minimal line diff using the longest common subsequence.
+// Fixture files are small, so the quadratic table is fine.
+func diffLines(a, b []string) []diffOp {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var ops []diffOp
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i], i + 1, j + 1})
+			i++
+			j++
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, diffOp{'-', a[i], i + 1, j + 1})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j], i + 1, j + 1})
+			j++
+		}
+	}
+	return ops
+}
@@ -0,0 +1,142 @@
+package refactortest
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/refactor"
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// DefaultGoMod is the go.mod WriteModule adds to a file map without one.
+const DefaultGoMod = "module example.com/refactortest\n\ngo 1.22\n"
+
+// Case is a table-driven test of an operation: the module it runs against,
+// given as a file map, and what the files are after its plan is applied.
+type Case struct {
+	Name  string
+	Files map[string]string // Module files by slash-separated relative path; DefaultGoMod when there is no go.mod
+	Op    OperationFunc
+
+	WantErr string            // Planning fails with an error containing this; nothing else is checked
+	Want    map[string]string // Expected contents of files after the plan is applied; other files aren't checked
+	Deleted []string          // Files the plan removes
+	NoBuild bool              // Don't compile the module afterwards, for operations leaving it broken on purpose
+
+	// Check, when set, makes further assertions on the module directory.
+	Check func(t testing.TB, dir string)
+}
+
+// RunCases runs each case as a subtest named after it.
+func RunCases(t *testing.T, cases []Case) {
+	t.Helper()
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			RunCase(t, c)
+		})
+	}
+}
+
+// RunCase writes the module of c to a temp dir, loads it, plans c.Op,
+// executes the plan, compiles the module unless c.NoBuild, and checks the
+// files against c.Want and c.Deleted. It returns the temp dir.
+func RunCase(t testing.TB, c Case) string {
+	t.Helper()
+	dir := WriteModule(t, c.Files)
+	eng := NewEngine(t)
+	ws := LoadWorkspace(t, eng, dir)
+
+	plan, err := c.Op(eng, ws)
+	if c.WantErr != "" {
+		if err == nil || !strings.Contains(err.Error(), c.WantErr) {
+			t.Fatalf("plan operation: got error %v, want one containing %q", err, c.WantErr)
+		}
+		return dir
+	}
+	if err != nil {
+		t.Fatalf("plan operation: %v", err)
+	}
+	if err := eng.ExecutePlan(plan); err != nil {
+		t.Fatalf("ExecutePlan: %v", err)
+	}
+	if !c.NoBuild {
+		Build(t, dir)
+	}
+	AssertFiles(t, dir, c.Want)
+	for _, name := range c.Deleted {
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name))); err == nil {
+			t.Errorf("expected %s to be deleted, but it still exists", name)
+		}
+	}
+	if c.Check != nil {
+		c.Check(t, dir)
+	}
+	return dir
+}
+
+// Operation adapts op to an OperationFunc that validates it against the
+// workspace before executing it, as the engine does for its own operations.
+func Operation(op types.Operation) OperationFunc {
+	return func(_ refactor.RefactorEngine, ws *types.Workspace) (*types.RefactoringPlan, error) {
+		if err := op.Validate(ws); err != nil {
+			return nil, err
+		}
+		return op.Execute(ws)
+	}
+}
+
+// WriteModule writes files to a temp dir, adding DefaultGoMod when they
+// hold no go.mod, and returns the dir.
+func WriteModule(t testing.TB, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if _, ok := files["go.mod"]; !ok {
+		if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(DefaultGoMod), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// Build compiles the module at dir with go build, failing the test with
+// the compiler's output when it doesn't compile. Test files aren't
+// compiled.
+func Build(t testing.TB, dir string) {
+	t.Helper()
+	cmd := exec.Command("go", "build", "./...")
+	cmd.Dir = dir
+	// Keep the go command from picking up a go.work around the temp dir or
+	// downloading a newer toolchain.
+	cmd.Env = append(os.Environ(), "GOWORK=off", "GOTOOLCHAIN=local")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("module doesn't compile after the operation: %v\n%s", err, NormalizeTempPaths(string(out), dir))
+	}
+}
+
+// AssertFiles compares the files at dir with want, by slash-separated
+// relative path, reporting mismatches as unified diffs.
+func AssertFiles(t testing.TB, dir string, want map[string]string) {
+	t.Helper()
+	for name, expected := range want {
+		actual, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Errorf("cannot read %s: %v", name, err)
+			continue
+		}
+		if got := NormalizeTempPaths(string(actual), dir); got != expected {
+			t.Errorf("mismatch for %s:\n%s", name, Diff(expected, got))
+		}
+	}
+}
@@ -0,0 +1,211 @@
+// Package refactortest runs refactoring operations end to end against
+// testdata fixtures and compares the resulting files with golden snapshots,
+// or against modules given as file maps in table-driven tests (see Case).
+//
+// A fixture is a directory holding a small Go module. Next to each source file
+// that the operation is expected to change sits a "<file>.golden" with the
+// expected content; files the operation creates only have a ".golden", and a
+// "<file>.deleted" marker asserts that the operation removes <file>. Running
+// the tests with -update rewrites the golden files from the actual output.
+//
+// A Case holds its module inline instead, with the expected contents of the
+// files it checks; RunCase also compiles the module after the operation, so
+// operations and analyzer fixes maintained outside this repository can be
+// tested against the engine the way its own tests are.
+package refactortest
+
+import (
+	"flag"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/refactor"
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+// Update makes CompareGolden write the actual output to the golden files
+// instead of comparing against them. It is set by the -update test flag.
+var Update = flag.Bool("update", false, "update golden files")
+
+// TempDirPlaceholder replaces the temp workspace path in compared output, so
+// golden files don't embed host-specific paths.
+const TempDirPlaceholder = "$TMPDIR"
+
+// OperationFunc plans an operation against a loaded workspace.
+type OperationFunc func(eng refactor.RefactorEngine, ws *types.Workspace) (*types.RefactoringPlan, error)
+
+// Run copies the fixture into a temp dir, loads it, plans op, executes the
+// plan and checks the result against the fixture's golden and .deleted
+// files. It returns the temp dir for further assertions.
+func Run(t testing.TB, fixtureDir string, op OperationFunc) string {
+	t.Helper()
+	dir := CopyFixture(t, fixtureDir)
+	eng := NewEngine(t)
+	ws := LoadWorkspace(t, eng, dir)
+
+	plan, err := op(eng, ws)
+	if err != nil {
+		t.Fatalf("plan operation: %v", err)
+	}
+	if err := eng.ExecutePlan(plan); err != nil {
+		t.Fatalf("ExecutePlan: %v", err)
+	}
+	CompareGolden(t, fixtureDir, dir)
+	CheckDeleted(t, fixtureDir, dir)
+	return dir
+}
+
+// CopyFixture copies fixtureDir to a temp dir, skipping .golden and .deleted files.
+func CopyFixture(t testing.TB, fixtureDir string) string {
+	t.Helper()
+	dst := t.TempDir()
+
+	err := filepath.WalkDir(fixtureDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(fixtureDir, path)
+		target := filepath.Join(dst, rel)
+
+		if d.IsDir() {
+			return os.MkdirAll(target, 0o755)
+		}
+		if isMarker(path) {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, 0o644)
+	})
+	if err != nil {
+		t.Fatalf("CopyFixture(%s): %v", fixtureDir, err)
+	}
+	return dst
+}
+
+// NewEngine creates a refactoring engine with SkipCompilation and AllowBreaking
+// enabled, so fixtures don't need to compile after every operation.
+func NewEngine(t testing.TB) refactor.RefactorEngine {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return refactor.CreateEngineWithConfig(&refactor.EngineConfig{
+		SkipCompilation: true,
+		AllowBreaking:   true,
+	}, logger)
+}
+
+// LoadWorkspace loads the workspace at dir, failing the test on error.
+func LoadWorkspace(t testing.TB, eng refactor.RefactorEngine, dir string) *types.Workspace {
+	t.Helper()
+	ws, err := eng.LoadWorkspace(dir)
+	if err != nil {
+		t.Fatalf("LoadWorkspace(%s): %v", dir, err)
+	}
+	return ws
+}
+
+// CompareGolden compares every *.golden file in fixtureDir against the file
+// at the same relative path in dir, reporting mismatches as unified diffs.
+//
+// With -update it instead writes the output for every source file of the
+// fixture (except go.mod) to its golden file, which also creates golden files
+// for fixtures that have none yet.
+func CompareGolden(t testing.TB, fixtureDir, dir string) {
+	t.Helper()
+
+	if *Update {
+		err := filepath.WalkDir(fixtureDir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || isMarker(path) || d.Name() == "go.mod" {
+				return err
+			}
+			rel, _ := filepath.Rel(fixtureDir, path)
+			actual, err := os.ReadFile(filepath.Join(dir, rel))
+			if err != nil {
+				// File may have been deleted by the refactoring; skip.
+				return nil
+			}
+			goldenPath := path + ".golden"
+			if err := os.WriteFile(goldenPath, []byte(NormalizeTempPaths(string(actual), dir)), 0o644); err != nil {
+				t.Errorf("failed to update golden file %s: %v", goldenPath, err)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("walking source files for update: %v", err)
+		}
+		return
+	}
+
+	found := 0
+	err := filepath.WalkDir(fixtureDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, ".golden") {
+			return err
+		}
+		found++
+
+		rel, _ := filepath.Rel(fixtureDir, path)
+		actualRel := strings.TrimSuffix(rel, ".golden")
+		actual, err := os.ReadFile(filepath.Join(dir, actualRel))
+		if err != nil {
+			t.Errorf("cannot read actual file %s: %v", actualRel, err)
+			return nil
+		}
+		golden, err := os.ReadFile(path)
+		if err != nil {
+			t.Errorf("cannot read golden file %s: %v", path, err)
+			return nil
+		}
+
+		actualStr := NormalizeTempPaths(string(actual), dir)
+		goldenStr := NormalizeTempPaths(string(golden), dir)
+		if actualStr != goldenStr {
+			t.Errorf("mismatch for %s:\n%s", actualRel, Diff(goldenStr, actualStr))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walking golden files: %v", err)
+	}
+	if found == 0 {
+		t.Fatal("no golden files found")
+	}
+}
+
+// CheckDeleted asserts that every file with a *.deleted marker in fixtureDir
+// no longer exists in dir.
+func CheckDeleted(t testing.TB, fixtureDir, dir string) {
+	t.Helper()
+	err := filepath.WalkDir(fixtureDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, ".deleted") {
+			return err
+		}
+		rel, _ := filepath.Rel(fixtureDir, path)
+		actualRel := strings.TrimSuffix(rel, ".deleted")
+		if _, err := os.Stat(filepath.Join(dir, actualRel)); err == nil {
+			t.Errorf("expected %s to be deleted, but it still exists", actualRel)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walking deleted markers: %v", err)
+	}
+}
+
+// NormalizeTempPaths replaces occurrences of dir in s with TempDirPlaceholder.
+func NormalizeTempPaths(s, dir string) string {
+	if dir != "" {
+		s = strings.ReplaceAll(s, dir, TempDirPlaceholder)
+	}
+	return s
+}
+
+func isMarker(path string) bool {
+	return strings.HasSuffix(path, ".golden") || strings.HasSuffix(path, ".deleted")
+}
@@ -0,0 +1,110 @@
+package refactortest_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mamaar/gorefactor/pkg/refactor"
+	"github.com/mamaar/gorefactor/pkg/refactortest"
+	"github.com/mamaar/gorefactor/pkg/types"
+)
+
+func TestRun(t *testing.T) {
+	refactortest.Run(t, "testdata/rename", func(eng refactor.RefactorEngine, ws *types.Workspace) (*types.RefactoringPlan, error) {
+		return eng.RenameSymbol(ws, types.RenameSymbolRequest{
+			SymbolName: "Add",
+			NewName:    "Sum",
+			Scope:      types.WorkspaceScope,
+		})
+	})
+}
+
+func TestRunCases(t *testing.T) {
+	files := map[string]string{
+		"calc/calc.go": `package calc
+
+func Add(a, b int) int {
+	return a + b
+}
+`,
+		"main.go": `package main
+
+import (
+	"example.com/refactortest/calc"
+)
+
+func main() {
+	println(calc.Add(1, 2))
+}
+`,
+	}
+	rename := func(from, to string) refactortest.OperationFunc {
+		return func(eng refactor.RefactorEngine, ws *types.Workspace) (*types.RefactoringPlan, error) {
+			return eng.RenameSymbol(ws, types.RenameSymbolRequest{SymbolName: from, NewName: to, Scope: types.WorkspaceScope})
+		}
+	}
+	wantSum := map[string]string{
+		"calc/calc.go": `package calc
+
+func Sum(a, b int) int {
+	return a + b
+}
+`,
+		"main.go": `package main
+
+import (
+	"example.com/refactortest/calc"
+)
+
+func main() {
+	println(calc.Sum(1, 2))
+}
+`,
+	}
+
+	refactortest.RunCases(t, []refactortest.Case{
+		{Name: "engine", Files: files, Op: rename("Add", "Sum"), Want: wantSum},
+		{
+			Name:  "operation",
+			Files: files,
+			Op: refactortest.Operation(&refactor.RenameSymbolOperation{Request: types.RenameSymbolRequest{
+				SymbolName: "Add", NewName: "Sum", Scope: types.WorkspaceScope,
+			}}),
+			Want: wantSum,
+		},
+		{Name: "missing symbol", Files: files, Op: rename("Missing", "Sum"), WantErr: "Missing"},
+	})
+}
+
+func TestDiff(t *testing.T) {
+	expected := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\n"
+	actual := "a\nb\nC\nd\ne\nf\ng\nh\ni\nj\nk\nl\n"
+
+	got := refactortest.Diff(expected, actual)
+	want := `--- expected
++++ actual
+@@ -1 +1 @@
+ a
+ b
+-c
++C
+ d
+ e
+ f
+@@ -9 +9 @@
+ i
+ j
+ k
++l
+ 
+`
+	if got != want {
+		t.Errorf("Diff() =\n%s\nwant:\n%s", got, want)
+	}
+	if d := refactortest.Diff(expected, expected); d != "" {
+		t.Errorf("expected no diff for equal input, got:\n%s", d)
+	}
+	if !strings.Contains(refactortest.Diff("x", "y"), "-x\n+y\n") {
+		t.Error("expected a single-line replacement")
+	}
+}
@@ -0,0 +1,9 @@
+package calc
+
+func Add(a, b int) int {
+	return a + b
+}
+
+func Twice(a int) int {
+	return Add(a, a)
+}
@@ -0,0 +1,262 @@
+// Package sarif writes analyzer findings as SARIF 2.1.0 logs, the format
+// GitHub code scanning and most static analysis dashboards ingest.
+package sarif
+
+import (
+	"cmp"
+	"go/token"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+const (
+	Version = "2.1.0"
+	Schema  = "https://json.schemastore.org/sarif-2.1.0.json"
+
+	// SrcRoot is the base id artifact URIs inside the workspace are
+	// relative to.
+	SrcRoot = "SRCROOT"
+)
+
+// Result levels.
+const (
+	LevelError   = "error"
+	LevelWarning = "warning"
+	LevelNote    = "note"
+)
+
+// Log is a SARIF log file.
+type Log struct {
+	Version string `json:"version"`
+	Schema  string `json:"$schema"`
+	Runs    []*Run `json:"runs"`
+}
+
+// Run holds the rules and results of one tool invocation.
+type Run struct {
+	Tool               Tool                        `json:"tool"`
+	OriginalURIBaseIDs map[string]ArtifactLocation `json:"originalUriBaseIds,omitempty"`
+	Results            []*Result                   `json:"results"`
+
+	root  string
+	rules map[string]int // Rule id -> index into Tool.Driver.Rules
+}
+
+type Tool struct {
+	Driver Driver `json:"driver"`
+}
+
+type Driver struct {
+	Name           string  `json:"name"`
+	Version        string  `json:"version,omitempty"`
+	InformationURI string  `json:"informationUri,omitempty"`
+	Rules          []*Rule `json:"rules"`
+}
+
+// Rule describes one kind of finding.
+type Rule struct {
+	ID                   string         `json:"id"`
+	Name                 string         `json:"name,omitempty"`
+	ShortDescription     Message        `json:"shortDescription"`
+	FullDescription      *Message       `json:"fullDescription,omitempty"`
+	HelpURI              string         `json:"helpUri,omitempty"`
+	DefaultConfiguration *Configuration `json:"defaultConfiguration,omitempty"`
+}
+
+type Configuration struct {
+	Level string `json:"level"`
+}
+
+type Message struct {
+	Text string `json:"text"`
+}
+
+// Result is a single finding.
+type Result struct {
+	RuleID    string     `json:"ruleId"`
+	RuleIndex int        `json:"ruleIndex"`
+	Level     string     `json:"level,omitempty"`
+	Message   Message    `json:"message"`
+	Locations []Location `json:"locations"`
+	Fixes     []Fix      `json:"fixes,omitempty"`
+}
+
+type Location struct {
+	PhysicalLocation PhysicalLocation `json:"physicalLocation"`
+}
+
+type PhysicalLocation struct {
+	ArtifactLocation ArtifactLocation `json:"artifactLocation"`
+	Region           *Region          `json:"region,omitempty"`
+}
+
+type ArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+// Region is a 1-based line and column range; EndColumn is exclusive.
+type Region struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+	EndLine     int `json:"endLine,omitempty"`
+	EndColumn   int `json:"endColumn,omitempty"`
+}
+
+// Fix is a suggested fix, as a set of replacements.
+type Fix struct {
+	Description     Message          `json:"description"`
+	ArtifactChanges []ArtifactChange `json:"artifactChanges"`
+}
+
+type ArtifactChange struct {
+	ArtifactLocation ArtifactLocation `json:"artifactLocation"`
+	Replacements     []Replacement    `json:"replacements"`
+}
+
+type Replacement struct {
+	DeletedRegion   Region           `json:"deletedRegion"`
+	InsertedContent *ArtifactContent `json:"insertedContent,omitempty"`
+}
+
+type ArtifactContent struct {
+	Text string `json:"text"`
+}
+
+// NewLog returns a log holding runs.
+func NewLog(runs ...*Run) *Log {
+	return &Log{Version: Version, Schema: Schema, Runs: runs}
+}
+
+// NewRun starts a run of the named tool. Files under root are reported
+// relative to SrcRoot, which maps to root.
+func NewRun(name, version, informationURI, root string) *Run {
+	r := &Run{
+		Tool: Tool{Driver: Driver{
+			Name:           name,
+			Version:        version,
+			InformationURI: informationURI,
+			Rules:          []*Rule{},
+		}},
+		Results: []*Result{},
+		root:    root,
+		rules:   make(map[string]int),
+	}
+	if root != "" {
+		r.OriginalURIBaseIDs = map[string]ArtifactLocation{
+			SrcRoot: {URI: fileURI(root) + "/"},
+		}
+	}
+	return r
+}
+
+// AddRule registers a rule; results may only name registered rules.
+func (r *Run) AddRule(rule *Rule) {
+	if _, ok := r.rules[rule.ID]; ok {
+		return
+	}
+	r.rules[rule.ID] = len(r.Tool.Driver.Rules)
+	r.Tool.Driver.Rules = append(r.Tool.Driver.Rules, rule)
+}
+
+// AddResult records a finding of ruleID at region of file. An empty level
+// leaves the rule's default in effect.
+func (r *Run) AddResult(ruleID, level, message, file string, region *Region) *Result {
+	res := &Result{
+		RuleID:    ruleID,
+		RuleIndex: r.rules[ruleID],
+		Level:     level,
+		Message:   Message{Text: message},
+		Locations: []Location{{PhysicalLocation: PhysicalLocation{
+			ArtifactLocation: r.artifact(file),
+			Region:           region,
+		}}},
+	}
+	r.Results = append(r.Results, res)
+	return res
+}
+
+// AddDiagnostic records an analyzer diagnostic as a finding of ruleID,
+// turning its first suggested fix into a SARIF fix.
+func (r *Run) AddDiagnostic(fset *token.FileSet, ruleID, level string, d analysis.Diagnostic) *Result {
+	start := fset.Position(d.Pos)
+	res := r.AddResult(ruleID, level, d.Message, start.Filename, span(fset, d.Pos, d.End))
+	if len(d.SuggestedFixes) == 0 {
+		return res
+	}
+	fix := d.SuggestedFixes[0]
+	changes := make(map[string]*ArtifactChange)
+	var order []string
+	for _, edit := range fix.TextEdits {
+		file := fset.Position(edit.Pos).Filename
+		change, ok := changes[file]
+		if !ok {
+			change = &ArtifactChange{ArtifactLocation: r.artifact(file)}
+			changes[file] = change
+			order = append(order, file)
+		}
+		change.Replacements = append(change.Replacements, Replacement{
+			DeletedRegion:   *span(fset, edit.Pos, edit.End),
+			InsertedContent: &ArtifactContent{Text: string(edit.NewText)},
+		})
+	}
+	f := Fix{Description: Message{Text: fix.Message}}
+	for _, file := range order {
+		f.ArtifactChanges = append(f.ArtifactChanges, *changes[file])
+	}
+	res.Fixes = []Fix{f}
+	return res
+}
+
+// artifact returns the location of file, relative to SrcRoot when it is
+// inside the run's root.
+func (r *Run) artifact(file string) ArtifactLocation {
+	if r.root != "" {
+		if rel, err := filepath.Rel(r.root, file); err == nil && !strings.HasPrefix(rel, "..") {
+			return ArtifactLocation{URI: filepath.ToSlash(rel), URIBaseID: SrcRoot}
+		}
+	}
+	return ArtifactLocation{URI: fileURI(file)}
+}
+
+// span returns the region between two positions; an invalid end yields a
+// region covering only the start.
+func span(fset *token.FileSet, pos, end token.Pos) *Region {
+	start := fset.Position(pos)
+	region := &Region{StartLine: start.Line, StartColumn: start.Column}
+	if end.IsValid() && end >= pos {
+		stop := fset.Position(end)
+		region.EndLine, region.EndColumn = stop.Line, stop.Column
+	}
+	return region
+}
+
+func fileURI(path string) string {
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path // Windows drive letters
+	}
+	return "file://" + path
+}
+
+// SortResults orders the results by file, then position, so runs over a
+// map of packages are reproducible.
+func (r *Run) SortResults() {
+	slices.SortStableFunc(r.Results, func(a, b *Result) int {
+		la, lb := a.Locations[0].PhysicalLocation, b.Locations[0].PhysicalLocation
+		if c := strings.Compare(la.ArtifactLocation.URI, lb.ArtifactLocation.URI); c != 0 {
+			return c
+		}
+		ra, rb := la.Region, lb.Region
+		if ra == nil || rb == nil {
+			return 0
+		}
+		if c := cmp.Compare(ra.StartLine, rb.StartLine); c != 0 {
+			return c
+		}
+		return cmp.Compare(ra.StartColumn, rb.StartColumn)
+	})
+}
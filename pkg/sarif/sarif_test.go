@@ -0,0 +1,115 @@
+package sarif_test
+
+import (
+	"encoding/json"
+	"go/token"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+
+	"github.com/mamaar/gorefactor/pkg/sarif"
+)
+
+func TestRun(t *testing.T) {
+	fset := token.NewFileSet()
+	src := "package a\n\nfunc f() error {\n\treturn err\n}\n"
+	f := fset.AddFile("/ws/a/a.go", -1, len(src))
+	f.SetLinesForContent([]byte(src))
+	ret := f.Pos(29) // "return err"
+
+	run := sarif.NewRun("gorefactor", "1.0.0", "", "/ws")
+	run.AddRule(&sarif.Rule{ID: "unused", ShortDescription: sarif.Message{Text: "Unused symbol"}})
+	run.AddRule(&sarif.Rule{ID: "errorwrap", ShortDescription: sarif.Message{Text: "Bare error"}})
+	run.AddRule(&sarif.Rule{ID: "errorwrap"})
+
+	run.AddDiagnostic(fset, "errorwrap", "", analysis.Diagnostic{
+		Pos:     ret,
+		End:     ret + 10,
+		Message: "bare error return",
+		SuggestedFixes: []analysis.SuggestedFix{{
+			Message:   "Wrap it",
+			TextEdits: []analysis.TextEdit{{Pos: ret + 7, End: ret + 10, NewText: []byte(`fmt.Errorf("f: %w", err)`)}},
+		}},
+	})
+	run.AddResult("unused", sarif.LevelNote, "func f is unused", "/elsewhere/b.go", &sarif.Region{StartLine: 3, StartColumn: 6})
+	run.SortResults()
+
+	b, err := json.Marshal(sarif.NewLog(run))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var log struct {
+		Version string `json:"version"`
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Rules []struct {
+						ID string `json:"id"`
+					} `json:"rules"`
+				} `json:"driver"`
+			} `json:"tool"`
+			OriginalURIBaseIDs map[string]struct {
+				URI string `json:"uri"`
+			} `json:"originalUriBaseIds"`
+			Results []struct {
+				RuleID    string `json:"ruleId"`
+				RuleIndex int    `json:"ruleIndex"`
+				Level     string `json:"level"`
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct {
+							URI       string `json:"uri"`
+							URIBaseID string `json:"uriBaseId"`
+						} `json:"artifactLocation"`
+						Region sarif.Region `json:"region"`
+					} `json:"physicalLocation"`
+				} `json:"locations"`
+				Fixes []struct {
+					ArtifactChanges []struct {
+						Replacements []struct {
+							DeletedRegion   sarif.Region `json:"deletedRegion"`
+							InsertedContent struct {
+								Text string `json:"text"`
+							} `json:"insertedContent"`
+						} `json:"replacements"`
+					} `json:"artifactChanges"`
+				} `json:"fixes"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(b, &log); err != nil {
+		t.Fatal(err)
+	}
+
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("unexpected log: %s", b)
+	}
+	r := log.Runs[0]
+	if got := len(r.Tool.Driver.Rules); got != 2 {
+		t.Errorf("expected duplicate rules to be dropped, got %d rules", got)
+	}
+	if got := r.OriginalURIBaseIDs[sarif.SrcRoot].URI; got != "file:///ws/" {
+		t.Errorf("SRCROOT = %q", got)
+	}
+	if len(r.Results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(r.Results))
+	}
+
+	// Relative URIs sort before file:// ones.
+	wrap, unused := r.Results[0], r.Results[1]
+	loc := wrap.Locations[0].PhysicalLocation
+	if wrap.RuleID != "errorwrap" || wrap.RuleIndex != 1 || loc.ArtifactLocation.URI != "a/a.go" || loc.ArtifactLocation.URIBaseID != sarif.SrcRoot {
+		t.Errorf("unexpected errorwrap result: %+v", wrap)
+	}
+	if want := (sarif.Region{StartLine: 4, StartColumn: 2, EndLine: 4, EndColumn: 12}); loc.Region != want {
+		t.Errorf("region = %+v, want %+v", loc.Region, want)
+	}
+	if len(wrap.Fixes) != 1 || wrap.Fixes[0].ArtifactChanges[0].Replacements[0].DeletedRegion.StartColumn != 9 {
+		t.Errorf("unexpected fixes: %+v", wrap.Fixes)
+	}
+
+	uloc := unused.Locations[0].PhysicalLocation.ArtifactLocation
+	if unused.Level != sarif.LevelNote || uloc.URI != "file:///elsewhere/b.go" || uloc.URIBaseID != "" {
+		t.Errorf("unexpected unused result: %+v", unused)
+	}
+}
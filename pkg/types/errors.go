@@ -1,15 +1,19 @@
 package types
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
 
 // RefactorError represents errors in refactoring operations
 type RefactorError struct {
-	Type    ErrorType
-	Message string
-	File    string
-	Line    int
-	Column  int
-	Cause   error
+	Type        ErrorType
+	Message     string
+	File        string
+	Line        int
+	Column      int
+	Cause       error
+	Suggestions []Suggestion // Possible corrections a client can retry with
 }
 
 func (e *RefactorError) Error() string {
@@ -23,6 +27,37 @@ func (e *RefactorError) Unwrap() error {
 	return e.Cause
 }
 
+// Code returns the stable code of the error's type.
+func (e *RefactorError) Code() ErrorCode {
+	return e.Type.Code()
+}
+
+// Suggestion is a possible correction for a failed operation, in a form a
+// client can retry with directly.
+type Suggestion struct {
+	Kind   SuggestionKind `json:"kind"`
+	Value  string         `json:"value"`
+	Reason string         `json:"reason,omitempty"`
+}
+
+type SuggestionKind string
+
+const (
+	SuggestSymbol        SuggestionKind = "symbol"         // A similarly named symbol
+	SuggestPackage       SuggestionKind = "package"        // A package that declares the requested symbol
+	SuggestTargetPackage SuggestionKind = "target_package" // A move target that avoids the failure
+)
+
+// SuggestionsOf returns the suggestions attached to the first RefactorError
+// in err's chain.
+func SuggestionsOf(err error) []Suggestion {
+	var rerr *RefactorError
+	if errors.As(err, &rerr) {
+		return rerr.Suggestions
+	}
+	return nil
+}
+
 type ErrorType int
 
 const (
@@ -34,8 +69,84 @@ const (
 	VisibilityViolation
 	NameConflict
 	FileSystemError
+	GeneratedFileViolation
+	StaleWorkspace
+)
+
+// ErrorCode is a stable, machine-readable code for a kind of failure or
+// issue. Codes never change meaning; GR1xxx are problems with the request,
+// GR2xxx problems with the code being refactored, GR3xxx problems with the
+// environment and GR4xxx advisories about code the change may miss.
+type ErrorCode string
+
+const (
+	CodeSymbolNotFound      ErrorCode = "GR1001"
+	CodeInvalidOperation    ErrorCode = "GR1002"
+	CodeNameConflict        ErrorCode = "GR1003"
+	CodeVisibilityViolation ErrorCode = "GR1004"
+	CodeGeneratedFile       ErrorCode = "GR1005"
+	CodeParseError          ErrorCode = "GR2001"
+	CodeCompilationError    ErrorCode = "GR2002"
+	CodeImportCycle         ErrorCode = "GR2003"
+	CodeTypeMismatch        ErrorCode = "GR2004"
+	CodeSideEffect          ErrorCode = "GR2005"
+	CodeFileSystem          ErrorCode = "GR3001"
+	CodeStaleWorkspace      ErrorCode = "GR3002"
+	CodeStringReference     ErrorCode = "GR4001"
+	CodeDependentUsage      ErrorCode = "GR4002"
 )
 
+// Code returns the stable code of t, or "" for an unknown type.
+func (t ErrorType) Code() ErrorCode {
+	switch t {
+	case ParseError:
+		return CodeParseError
+	case SymbolNotFound:
+		return CodeSymbolNotFound
+	case InvalidOperation:
+		return CodeInvalidOperation
+	case CompilationError:
+		return CodeCompilationError
+	case CyclicDependency:
+		return CodeImportCycle
+	case VisibilityViolation:
+		return CodeVisibilityViolation
+	case NameConflict:
+		return CodeNameConflict
+	case FileSystemError:
+		return CodeFileSystem
+	case GeneratedFileViolation:
+		return CodeGeneratedFile
+	case StaleWorkspace:
+		return CodeStaleWorkspace
+	}
+	return ""
+}
+
+// CodeOf returns the code of the first RefactorError in err's chain or,
+// for a validation error, the code of its first issue; "" when err carries
+// none.
+func CodeOf(err error) ErrorCode {
+	var rerr *RefactorError
+	if errors.As(err, &rerr) {
+		return rerr.Code()
+	}
+	var verr *ValidationError
+	if errors.As(err, &verr) && len(verr.Issues) > 0 {
+		return verr.Issues[0].Code()
+	}
+	return ""
+}
+
+// IssuesOf returns the issues of the first ValidationError in err's chain.
+func IssuesOf(err error) []Issue {
+	var verr *ValidationError
+	if errors.As(err, &verr) {
+		return verr.Issues
+	}
+	return nil
+}
+
 // ValidationError represents validation failures
 type ValidationError struct {
 	Issues []Issue
@@ -2,6 +2,7 @@ package types
 
 import (
 	"errors"
+	"fmt"
 	"strings"
 	"testing"
 )
@@ -116,6 +117,7 @@ func TestErrorType(t *testing.T) {
 		{"VisibilityViolation", VisibilityViolation, 5},
 		{"NameConflict", NameConflict, 6},
 		{"FileSystemError", FileSystemError, 7},
+		{"GeneratedFileViolation", GeneratedFileViolation, 8},
 	}
 
 	for _, tc := range testCases {
@@ -285,4 +287,24 @@ func TestErrorMessages(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestCodeOf(t *testing.T) {
+	testCases := []struct {
+		name string
+		err  error
+		want ErrorCode
+	}{
+		{"Refactor error", &RefactorError{Type: SymbolNotFound, Message: "symbol Foo not found"}, CodeSymbolNotFound},
+		{"Wrapped", fmt.Errorf("move operation validation failed: %w", &RefactorError{Type: CyclicDependency}), CodeImportCycle},
+		{"Validation error", &ValidationError{Issues: []Issue{{Type: IssueNameConflict}, {Type: IssueTypeMismatch}}}, CodeNameConflict},
+		{"Plain error", errors.New("boom"), ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := CodeOf(tc.err); got != tc.want {
+				t.Errorf("CodeOf = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package types
+
+import (
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// maxListedViolations caps the violations InvariantError spells out.
+const maxListedViolations = 20
+
+// InvariantError reports workspace data structures that contradict each
+// other. It always points at a bug in gorefactor, never in the code being
+// refactored; the violations are meant to be pasted into a bug report.
+type InvariantError struct {
+	Violations []string
+}
+
+func (e *InvariantError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "workspace invariants violated (%d), please report this as a bug:", len(e.Violations))
+	for i, v := range e.Violations {
+		if i == maxListedViolations {
+			fmt.Fprintf(&b, "\n  ... and %d more", len(e.Violations)-i)
+			break
+		}
+		b.WriteString("\n  - " + v)
+	}
+	return b.String()
+}
+
+// CheckInvariants verifies the memory model documented on Workspace and
+// returns an *InvariantError listing every violation, or nil. It walks the
+// whole workspace, so callers only run it when internal verification is on.
+func (ws *Workspace) CheckInvariants() error {
+	var violations []string
+	report := func(format string, args ...any) {
+		violations = append(violations, fmt.Sprintf(format, args...))
+	}
+
+	if ws.Packages == nil {
+		report("Packages map is nil")
+	}
+	for _, key := range slices.Sorted(maps.Keys(ws.Packages)) {
+		pkg := ws.Packages[key]
+		if pkg == nil {
+			report("Packages[%q] is nil", key)
+			continue
+		}
+		if pkg.Path != key {
+			report("Packages[%q] has Path %q", key, pkg.Path)
+		}
+		if pkg.ImportPath != "" {
+			if got, ok := ws.ImportToPath[pkg.ImportPath]; !ok {
+				report("package %s: ImportToPath has no entry for import path %q", key, pkg.ImportPath)
+			} else if got != key {
+				report("package %s: ImportToPath[%q] is %q", key, pkg.ImportPath, got)
+			}
+		}
+		checkFiles(ws, pkg, pkg.Files, false, report)
+		checkFiles(ws, pkg, pkg.TestFiles, true, report)
+		checkSymbols(ws, pkg, report)
+	}
+	for _, importPath := range slices.Sorted(maps.Keys(ws.ImportToPath)) {
+		fsPath := ws.ImportToPath[importPath]
+		pkg, ok := ws.Packages[fsPath]
+		if !ok {
+			report("ImportToPath[%q] is %q, which is not a loaded package", importPath, fsPath)
+		} else if pkg != nil && pkg.ImportPath != importPath {
+			report("ImportToPath[%q] is %q, whose ImportPath is %q", importPath, fsPath, pkg.ImportPath)
+		}
+	}
+
+	if len(violations) > 0 {
+		return &InvariantError{Violations: violations}
+	}
+	return nil
+}
+
+// checkFiles verifies that files are keyed by base name, point back at pkg
+// and sit in the right map.
+func checkFiles(ws *Workspace, pkg *Package, files map[string]*File, tests bool, report func(string, ...any)) {
+	for _, key := range slices.Sorted(maps.Keys(files)) {
+		file := files[key]
+		if file == nil {
+			report("package %s: file %q is nil", pkg.Path, key)
+			continue
+		}
+		if filepath.Base(file.Path) != key {
+			report("package %s: file %q has Path %q", pkg.Path, key, file.Path)
+		}
+		if file.Package != pkg {
+			report("file %s does not point back at package %s", file.Path, pkg.Path)
+		}
+		if isTest := strings.HasSuffix(key, "_test.go"); isTest != tests {
+			report("package %s: %q is in the wrong file map (test files: %v)", pkg.Path, key, tests)
+		}
+		if file.AST == nil {
+			report("file %s has no AST", file.Path)
+		} else if ws.FileSet != nil && ws.FileSet.File(file.AST.Pos()) == nil {
+			report("file %s was parsed into a different FileSet", file.Path)
+		}
+	}
+}
+
+// checkSymbols verifies that every symbol of pkg lies within the file it
+// names.
+func checkSymbols(ws *Workspace, pkg *Package, report func(string, ...any)) {
+	st := pkg.Symbols
+	if st == nil {
+		return
+	}
+	if st.Package != pkg {
+		report("package %s: symbol table belongs to another package", pkg.Path)
+	}
+	var symbols []*Symbol
+	for _, m := range []map[string]*Symbol{st.Functions, st.Types, st.Variables, st.Constants} {
+		for _, key := range slices.Sorted(maps.Keys(m)) {
+			if m[key] == nil || m[key].Name != key {
+				report("package %s: symbol table entry %q holds a different symbol", pkg.Path, key)
+				continue
+			}
+			symbols = append(symbols, m[key])
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(st.Methods)) {
+		symbols = append(symbols, st.Methods[key]...)
+	}
+
+	for _, sym := range symbols {
+		if sym == nil {
+			report("package %s: nil method symbol", pkg.Path)
+			continue
+		}
+		file := pkg.Files[filepath.Base(sym.File)]
+		if file == nil {
+			file = pkg.TestFiles[filepath.Base(sym.File)]
+		}
+		if file == nil || file.Path != sym.File {
+			report("symbol %s.%s names file %s, which is not in the package", pkg.Path, sym.Name, sym.File)
+			continue
+		}
+		if ws.FileSet == nil || !sym.Position.IsValid() {
+			continue
+		}
+		tf := ws.FileSet.File(sym.Position)
+		switch {
+		case tf == nil || tf.Name() != sym.File:
+			report("symbol %s.%s: position %d is not in %s", pkg.Path, sym.Name, sym.Position, sym.File)
+		case sym.End.IsValid() && (sym.End < sym.Position || int(sym.End) > tf.Base()+tf.Size()):
+			report("symbol %s.%s: end %d is outside %s", pkg.Path, sym.Name, sym.End, sym.File)
+		case sym.Line != 0 && sym.Line != tf.Line(sym.Position):
+			report("symbol %s.%s: Line %d, but its position is on line %d", pkg.Path, sym.Name, sym.Line, tf.Line(sym.Position))
+		}
+	}
+}
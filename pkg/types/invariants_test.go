@@ -0,0 +1,139 @@
+package types
+
+import (
+	"errors"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+// newVerifiedWorkspace builds a one-package workspace that satisfies every
+// invariant.
+func newVerifiedWorkspace(t *testing.T) *Workspace {
+	t.Helper()
+	fset := token.NewFileSet()
+	src := "package calc\n\nfunc Add(a, b int) int {\n\treturn a + b\n}\n"
+	astFile, err := parser.ParseFile(fset, "/ws/calc/calc.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := &Package{
+		Path:       "/ws/calc",
+		ImportPath: "example.com/ws/calc",
+		Name:       "calc",
+		Dir:        "/ws/calc",
+		Files:      map[string]*File{},
+		TestFiles:  map[string]*File{},
+	}
+	file := &File{Path: "/ws/calc/calc.go", Package: pkg, AST: astFile, OriginalContent: []byte(src)}
+	pkg.Files["calc.go"] = file
+	fn := astFile.Decls[0]
+	pkg.Symbols = &SymbolTable{
+		Package: pkg,
+		Functions: map[string]*Symbol{
+			"Add": {Name: "Add", Kind: FunctionSymbol, File: file.Path, Position: fn.Pos() + 5, End: fn.End(), Line: 3},
+		},
+		Types:     map[string]*Symbol{},
+		Variables: map[string]*Symbol{},
+		Constants: map[string]*Symbol{},
+		Methods:   map[string][]*Symbol{},
+	}
+	return &Workspace{
+		RootPath:     "/ws",
+		Packages:     map[string]*Package{pkg.Path: pkg},
+		ImportToPath: map[string]string{pkg.ImportPath: pkg.Path},
+		FileSet:      fset,
+	}
+}
+
+func TestCheckInvariants(t *testing.T) {
+	if err := newVerifiedWorkspace(t).CheckInvariants(); err != nil {
+		t.Fatalf("unexpected violations: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		corrupt func(ws *Workspace)
+		want    string
+	}{
+		{
+			name: "package keyed by another path",
+			corrupt: func(ws *Workspace) {
+				ws.Packages["/ws/other"] = ws.Packages["/ws/calc"]
+				delete(ws.Packages, "/ws/calc")
+			},
+			want: `Packages["/ws/other"] has Path "/ws/calc"`,
+		},
+		{
+			name:    "stale import path",
+			corrupt: func(ws *Workspace) { ws.ImportToPath["example.com/ws/gone"] = "/ws/gone" },
+			want:    `ImportToPath["example.com/ws/gone"] is "/ws/gone", which is not a loaded package`,
+		},
+		{
+			name:    "missing import path",
+			corrupt: func(ws *Workspace) { delete(ws.ImportToPath, "example.com/ws/calc") },
+			want:    `ImportToPath has no entry for import path "example.com/ws/calc"`,
+		},
+		{
+			name: "file keyed by full path",
+			corrupt: func(ws *Workspace) {
+				pkg := ws.Packages["/ws/calc"]
+				pkg.Files["/ws/calc/calc.go"] = pkg.Files["calc.go"]
+				delete(pkg.Files, "calc.go")
+			},
+			want: `file "/ws/calc/calc.go" has Path "/ws/calc/calc.go"`,
+		},
+		{
+			name:    "file of another package",
+			corrupt: func(ws *Workspace) { ws.Packages["/ws/calc"].Files["calc.go"].Package = &Package{} },
+			want:    "file /ws/calc/calc.go does not point back at package /ws/calc",
+		},
+		{
+			name: "test file in Files",
+			corrupt: func(ws *Workspace) {
+				pkg := ws.Packages["/ws/calc"]
+				f := *pkg.Files["calc.go"]
+				f.Path = "/ws/calc/calc_test.go"
+				pkg.Files["calc_test.go"] = &f
+			},
+			want: `"calc_test.go" is in the wrong file map`,
+		},
+		{
+			name:    "symbol outside its file",
+			corrupt: func(ws *Workspace) { ws.Packages["/ws/calc"].Symbols.Functions["Add"].End += 1000 },
+			want:    "symbol /ws/calc.Add: end",
+		},
+		{
+			name:    "symbol in an unknown file",
+			corrupt: func(ws *Workspace) { ws.Packages["/ws/calc"].Symbols.Functions["Add"].File = "/ws/calc/gone.go" },
+			want:    "names file /ws/calc/gone.go, which is not in the package",
+		},
+		{
+			name:    "stale line",
+			corrupt: func(ws *Workspace) { ws.Packages["/ws/calc"].Symbols.Functions["Add"].Line = 9 },
+			want:    "Line 9, but its position is on line 3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := newVerifiedWorkspace(t)
+			tt.corrupt(ws)
+			err := ws.CheckInvariants()
+			var ierr *InvariantError
+			if !errors.As(err, &ierr) {
+				t.Fatalf("expected an InvariantError, got %v", err)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error does not mention %q:\n%v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestInvariantErrorTruncates(t *testing.T) {
+	err := &InvariantError{Violations: make([]string, maxListedViolations+5)}
+	if msg := err.Error(); !strings.Contains(msg, "... and 5 more") {
+		t.Errorf("expected the list to be truncated:\n%s", msg)
+	}
+}
@@ -1,5 +1,11 @@
 package types
 
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
 // Operation represents any refactoring operation
 type Operation interface {
 	Type() OperationType
@@ -31,57 +37,372 @@ const (
 	ConvertAliasesOperation
 	MoveByDependenciesOperation
 	OrganizeByLayersOperation
+	MergePackagesOperation
+	OrganizeByDomainOperation
 	FixCyclesOperation
 	AnalyzeDependenciesOperation
 	BatchOperations
 	PlanOperation
 	ExecuteOperation
 	RollbackOperation
+	EncapsulateFieldOperation
+	ShrinkInterfaceOperation
+	BuildTagsOperation
+	ThreadContextOperation
+	SegregateInterfaceOperation
+	ExtractTestHelperOperation
+	ExtractPipelineStagesOperation
+	ConvertTypeAliasOperation
+	IntroduceTypeOperation
+	GenerateMockOperation
+	UpdateMocksOperation
+	FixMagicNumbersOperation
+	OrganizeFileOperation
+	SplitFileOperation
+	FixErrorReturnsOperation
+	ConvertReceiversOperation
+	AddFieldOperation
+	FixNamingOperation
+	BulkRenameOperation
+	RewriteOperation
+	MigrateOperation
 )
 
 // MoveSymbolRequest represents moving a symbol between packages
 type MoveSymbolRequest struct {
-	SymbolName   string
-	FromPackage  string
-	ToPackage    string
-	CreateTarget bool   // Create target package if it doesn't exist
-	UpdateTests  bool   // Update test files as well
+	SymbolName   string `json:"symbol_name"`
+	FromPackage  string `json:"from_package"`
+	ToPackage    string `json:"to_package"`
+	CreateTarget bool   `json:"create_target,omitempty"` // Create target package if it doesn't exist
+	UpdateTests  bool   `json:"update_tests,omitempty"`  // Update test files as well
+	MoveTests    bool   `json:"move_tests,omitempty"`    // Move the tests exercising the symbol along with it
+}
+
+// SuggestHomeRequest asks which package a symbol should live in. The layer
+// directories are relative to the workspace root; empty ones are ignored.
+// An empty Package is the one package declaring the symbol.
+type SuggestHomeRequest struct {
+	SymbolName          string `json:"symbol_name"`
+	Package             string `json:"package,omitempty"`
+	DomainLayer         string `json:"domain_layer,omitempty"`
+	InfrastructureLayer string `json:"infrastructure_layer,omitempty"`
+	ApplicationLayer    string `json:"application_layer,omitempty"`
+}
+
+// TypeHierarchyRequest asks where a named type sits in the embedding graph
+// and which interfaces it satisfies. Interfaces the type misses at most
+// MaxMissing methods of are reported as nearly satisfied.
+type TypeHierarchyRequest struct {
+	TypeName   string `json:"type_name"`
+	Package    string `json:"package"`
+	MaxMissing int    `json:"max_missing,omitempty"`
+}
+
+// CallGraphRequest selects the part of the workspace call graph to report.
+type CallGraphRequest struct {
+	Root  string `json:"root,omitempty"`  // Package directory whose functions start the graph; the whole workspace when empty
+	Depth int    `json:"depth,omitempty"` // Calls followed from Root; all of them when 0
+}
+
+// CheckArchitectureRequest checks the imports of the workspace packages
+// against architecture rules.
+type CheckArchitectureRequest struct {
+	Rules   []ImportRule `json:"rules"`
+	Package string       `json:"package,omitempty"` // Only check the package in this directory; every package when empty
+}
+
+// ImportRule constrains the imports of the packages matching Package, a
+// directory relative to the workspace root in which path.Match wildcards
+// may stand for a path element and a trailing "/..." also matches every
+// directory below it. Imports matching a Forbid pattern are violations;
+// when Allow is set, so are imports of workspace packages matching none of
+// its patterns. Import patterns match the directory of a workspace package
+// or the import path of any package.
+type ImportRule struct {
+	Package string   `json:"package"`
+	Allow   []string `json:"allow,omitempty"`
+	Forbid  []string `json:"forbid,omitempty"`
+	Reason  string   `json:"reason,omitempty"` // Why the rule exists, repeated in its violations
 }
 
 // RenameSymbolRequest represents renaming a symbol
 type RenameSymbolRequest struct {
-	SymbolName string
-	NewName    string
-	Package    string  // Empty means workspace-wide
-	Scope      RenameScope
+	SymbolName  string      `json:"symbol_name"`
+	NewName     string      `json:"new_name"`
+	Package     string      `json:"package,omitempty"` // Empty means workspace-wide
+	Scope       RenameScope `json:"scope,omitempty"`
+	RenameTests bool        `json:"rename_tests,omitempty"` // Also rename TestFoo, BenchmarkFoo, ExampleFoo and FuzzFoo for Foo
+	KeepAlias   bool        `json:"keep_alias,omitempty"`   // Keep the old name of an exported symbol as a deprecated alias
+
+	CheckDependents  bool `json:"check_dependents,omitempty"`  // Report uses in the engine's dependent repositories
+	DependentPatches bool `json:"dependent_patches,omitempty"` // With CheckDependents, include patches renaming them
 }
 
 // RenamePackageRequest represents renaming a package
 type RenamePackageRequest struct {
-	OldPackageName string
-	NewPackageName string
-	PackagePath    string // Path to the package directory
-	UpdateImports  bool   // Whether to update import statements in other packages
+	OldPackageName string `json:"old_package_name"`
+	NewPackageName string `json:"new_package_name"`
+	PackagePath    string `json:"package_path"`             // Path to the package directory
+	UpdateImports  bool   `json:"update_imports,omitempty"` // Whether to update import statements in other packages
 }
 
 // RenameInterfaceMethodRequest represents renaming a method on an interface
 type RenameInterfaceMethodRequest struct {
-	InterfaceName     string  // Name of the interface
-	MethodName        string  // Current method name
-	NewMethodName     string  // New method name
-	PackagePath       string  // Path to the package containing the interface (optional, "" means workspace-wide)
-	UpdateImplementations bool // Whether to update all implementations of the interface
+	InterfaceName         string `json:"interface_name"`                   // Name of the interface
+	MethodName            string `json:"method_name"`                      // Current method name
+	NewMethodName         string `json:"new_method_name"`                  // New method name
+	PackagePath           string `json:"package_path,omitempty"`           // Path to the package containing the interface (optional, "" means workspace-wide)
+	UpdateImplementations bool   `json:"update_implementations,omitempty"` // Whether to update all implementations of the interface
 }
 
 // RenameMethodRequest represents renaming a method on a specific type (struct or interface)
 type RenameMethodRequest struct {
-	TypeName          string  // Name of the type (struct or interface) that owns the method
-	MethodName        string  // Current method name
-	NewMethodName     string  // New method name
-	PackagePath       string  // Path to the package containing the type (optional, "" means workspace-wide)
-	UpdateImplementations bool // For interfaces: whether to update all implementations
+	TypeName              string `json:"type_name"`                        // Name of the type (struct or interface) that owns the method
+	MethodName            string `json:"method_name"`                      // Current method name
+	NewMethodName         string `json:"new_method_name"`                  // New method name
+	PackagePath           string `json:"package_path,omitempty"`           // Path to the package containing the type (optional, "" means workspace-wide)
+	UpdateImplementations bool   `json:"update_implementations,omitempty"` // For interfaces: whether to update all implementations
+}
+
+// EncapsulateFieldRequest represents hiding a struct field behind accessor methods
+type EncapsulateFieldRequest struct {
+	TypeName     string `json:"type_name"`                // Struct type that declares the field
+	FieldName    string `json:"field_name"`               // Field to encapsulate
+	Package      string `json:"package,omitempty"`        // Package path of the type (optional, "" means workspace-wide)
+	KeepField    bool   `json:"keep_field,omitempty"`     // Keep the field's name (and visibility) instead of unexporting it
+	NewFieldName string `json:"new_field_name,omitempty"` // New field name; defaults to the field name with a lowercase first letter
+	GetterName   string `json:"getter_name,omitempty"`    // Defaults to Get<Field>
+	SetterName   string `json:"setter_name,omitempty"`    // Defaults to Set<Field>
+}
+
+// ShrinkInterfaceRequest represents removing the unused methods of an
+// interface, optionally splitting the remaining ones into role interfaces
+type ShrinkInterfaceRequest struct {
+	InterfaceName       string          `json:"interface_name"`
+	Package             string          `json:"package,omitempty"`              // Package path of the interface (optional, "" means workspace-wide)
+	Keep                []string        `json:"keep,omitempty"`                 // Methods to keep; defaults to the methods in use
+	Roles               []RoleInterface `json:"roles,omitempty"`                // Role interfaces to split kept methods into; the interface embeds them
+	KeepImplementations bool            `json:"keep_implementations,omitempty"` // Don't delete implementer methods that become unused
+}
+
+// RoleInterface is a new interface declaring a subset of a shrunk interface's methods
+type RoleInterface struct {
+	Name    string   `json:"name"`
+	Methods []string `json:"methods"`
+}
+
+// SegregateInterfaceRequest represents splitting an interface into role
+// interfaces by how its consumers use it
+type SegregateInterfaceRequest struct {
+	InterfaceName string          `json:"interface_name"`
+	Package       string          `json:"package,omitempty"` // Package path of the interface (optional, "" means workspace-wide)
+	Roles         []RoleInterface `json:"roles,omitempty"`   // Role interfaces to split into; derived from consumer usage when empty
+}
+
+// ExtractTestHelperRequest represents moving setup repeated at the start of
+// several tests into a t.Helper() function or TestMain
+type ExtractTestHelperRequest struct {
+	Package       string `json:"package"`                  // Package whose tests to deduplicate
+	Test          string `json:"test,omitempty"`           // Only extract the setup this test shares ("" means every repeated setup)
+	HelperName    string `json:"helper_name,omitempty"`    // Name of the helper; derived from the test names when empty
+	TestMain      bool   `json:"test_main,omitempty"`      // Move the setup into TestMain instead of a helper
+	MinStatements int    `json:"min_statements,omitempty"` // Leading statements the tests must share (default 2)
+}
+
+// ExtractPipelineStagesRequest represents moving the produce, transform
+// and consume stages of a loop body into functions
+type ExtractPipelineStagesRequest struct {
+	SourceFile string   `json:"source_file"`
+	Line       int      `json:"line"`                  // Line of the for statement
+	StageNames []string `json:"stage_names,omitempty"` // Function name of each stage in order; "" or missing entries are derived from the values the stage passes on
+	MaxLive    int      `json:"max_live,omitempty"`    // Values that may cross a stage boundary (default 2)
+}
+
+// BuildTagsRequest represents migrating and renaming build constraints across the workspace
+type BuildTagsRequest struct {
+	MigrateLegacy bool              `json:"migrate_legacy,omitempty"` // Replace "// +build" lines with an equivalent "//go:build" line
+	KeepLegacy    bool              `json:"keep_legacy,omitempty"`    // Keep "// +build" lines in sync with "//go:build" for Go versions before 1.17
+	RenameTags    map[string]string `json:"rename_tags,omitempty"`    // Custom build tags to rename, old name -> new name
+	FileSuffixes  FileSuffixMode    `json:"file_suffixes,omitempty"`  // Move GOOS/GOARCH constraints into or out of file names
+	Package       string            `json:"package,omitempty"`        // Package path to restrict to ("" means workspace-wide)
+}
+
+// FileSuffixMode selects how GOOS/GOARCH file name suffixes are migrated
+type FileSuffixMode string
+
+const (
+	// SuffixesFromConstraints renames files constrained only by GOOS and/or
+	// GOARCH to use the _GOOS_GOARCH suffix and drops the constraint.
+	SuffixesFromConstraints FileSuffixMode = "add"
+	// SuffixesToConstraints renames files with a _GOOS_GOARCH suffix to drop
+	// it and adds an equivalent //go:build constraint.
+	SuffixesToConstraints FileSuffixMode = "remove"
+)
+
+// ThreadContextRequest represents adding a ctx context.Context parameter to a
+// function and its transitive callers
+type ThreadContextRequest struct {
+	FunctionName string `json:"function_name"`           // Function to add ctx to (Type.Method for methods)
+	Package      string `json:"package"`                 // Package declaring the function
+	Root         string `json:"root,omitempty"`          // Caller to stop at; only callers on a path to it get ctx ("" means all callers)
+	DefaultValue string `json:"default_value,omitempty"` // Argument for call sites outside the chain, default context.TODO()
+}
+
+// ConvertTypeAliasRequest represents turning a type alias into a defined
+// type, or a defined type into an alias
+type ConvertTypeAliasRequest struct {
+	TypeName string `json:"type_name"`
+	Package  string `json:"package,omitempty"`  // Package path of the type (optional, "" means workspace-wide)
+	ToAlias  bool   `json:"to_alias,omitempty"` // Make a defined type an alias; by default an alias becomes a defined type
+}
+
+// IntroduceTypeRequest represents declaring a named type for a recurring
+// primitive, e.g. type UserID string, and retyping chosen declarations to it
+type IntroduceTypeRequest struct {
+	TypeName   string   `json:"type_name"`         // Name of the new type
+	Underlying string   `json:"underlying"`        // Type it is defined as, e.g. string
+	Package    string   `json:"package"`           // Package to declare the type in
+	Targets    []string `json:"targets,omitempty"` // Declarations to retype: Func.param, Type.Method.param, Type.Field or Var, each optionally prefixed with "package:"
+}
+
+// GenerateMockRequest represents writing a test double for an interface
+type GenerateMockRequest struct {
+	InterfaceName string    `json:"interface_name"`
+	Package       string    `json:"package,omitempty"`        // Package declaring the interface; searched workspace-wide when empty
+	TargetPackage string    `json:"target_package,omitempty"` // Package to write the mock to; the interface's when empty
+	MockName      string    `json:"mock_name,omitempty"`      // <Interface>Mock when empty
+	Style         MockStyle `json:"style,omitempty"`          // MockStyleFunc when empty
+}
+
+// MockStyle selects the layout of a generated mock.
+type MockStyle string
+
+const (
+	MockStyleFunc MockStyle = "func" // A function field per method, returning zero values when unset
+	MockStyleMoq  MockStyle = "moq"  // The layout of github.com/matryer/moq: function fields and recorded calls
+)
+
+// UpdateMocksRequest represents regenerating the mocks generate_mock wrote
+// from the current interfaces
+type UpdateMocksRequest struct {
+	Package string `json:"package,omitempty"` // Only the mocks in this package; every mock when empty
+}
+
+// OrganizeFileRequest represents reordering the declarations of a file,
+// optionally moving the types of an oversized file into files of their own
+type OrganizeFileRequest struct {
+	File            string          `json:"file"`
+	Policy          FileOrderPolicy `json:"policy,omitempty"`            // FileOrderKind when empty
+	SplitByReceiver bool            `json:"split_by_receiver,omitempty"` // Move each type with methods to <type>.go when the file has more than MaxLines lines
+	MaxLines        int             `json:"max_lines,omitempty"`         // Size above which the file is split (default 500)
+}
+
+// FileOrderPolicy selects the order organize_file puts declarations in.
+type FileOrderPolicy string
+
+const (
+	FileOrderKind  FileOrderPolicy = "kind"       // Constants, variables, init; each type with its constructors, exported and unexported methods; exported, then unexported functions
+	FileOrderCalls FileOrderPolicy = "call_order" // Constants, variables and types; then each function after its first caller
+)
+
+// SplitFileRequest represents moving groups of the declarations of an
+// oversized file into new files of the same package
+type SplitFileRequest struct {
+	File     string        `json:"file"`
+	Strategy SplitStrategy `json:"strategy,omitempty"`  // SplitReceiver when empty
+	MaxLines int           `json:"max_lines,omitempty"` // Size the file must exceed to be split (default 500)
+}
+
+// SplitStrategy selects how split_file groups declarations into files.
+type SplitStrategy string
+
+const (
+	SplitReceiver   SplitStrategy = "receiver"   // Each type with methods, with its constants and constructors, to <type>.go
+	SplitPrefix     SplitStrategy = "prefix"     // Declarations whose names start with the same word to <word>.go
+	SplitDependency SplitStrategy = "dependency" // Each cluster of declarations referring to one another, but the largest, to a file named after its main type or function
+)
+
+// FixMagicNumbersRequest represents replacing the literals the magicnumber
+// analyzer reports with named constants
+type FixMagicNumbersRequest struct {
+	Package        string            `json:"package,omitempty"`         // Only this package; every package when empty
+	MinOccurrences int               `json:"min_occurrences,omitempty"` // Repetitions that make a literal magic outside conditionals (default 3)
+	Names          map[string]string `json:"names,omitempty"`           // Constant names by literal as written, e.g. {"3600": "secondsPerHour"}; suggested when absent
+}
+
+// FixErrorReturnsRequest represents moving error results last and renaming
+// error variables to err, as the errorreturn analyzer reports
+type FixErrorReturnsRequest struct {
+	Package string `json:"package,omitempty"` // Only this package; every package when empty
+}
+
+// FixNamingRequest represents renaming the names the naming analyzer
+// reports to the names it suggests
+type FixNamingRequest struct {
+	Package string   `json:"package,omitempty"` // Only this package; every package when empty
+	Names   []string `json:"names,omitempty"`   // Only these reported names, each Name or Type.Method; all when empty
+}
+
+// BulkRenameRequest represents renaming many symbols and methods in one
+// plan, listed in the request or in a mapping file
+type BulkRenameRequest struct {
+	Renames []SymbolRename `json:"renames,omitempty"`
+	File    string         `json:"file,omitempty"` // CSV (old,new[,package]) or JSON mapping file, relative to the workspace root
+}
+
+// SymbolRename is one rename of a BulkRenameRequest.
+type SymbolRename struct {
+	Old     string `json:"old"`               // Name or Type.Method, optionally prefixed with "package:"
+	New     string `json:"new"`               // The new name of the symbol or method
+	Package string `json:"package,omitempty"` // Directory or import path of the declaring package; every package declaring Old when empty
+}
+
+// RewriteRequest represents replacing the expressions matching a pattern
+// with holes, such as errors.Wrap(:[err], :[msg]), throughout the workspace
+type RewriteRequest struct {
+	Pattern     string            `json:"pattern"`           // Go expression with :[name], :[name...] and :[name~regexp] holes
+	Replacement string            `json:"replacement"`       // Go expression with the pattern's holes
+	Where       map[string]string `json:"where,omitempty"`   // Type by hole that its expressions must be assignable to, e.g. {"err": "error"}
+	Package     string            `json:"package,omitempty"` // Only this package; every package when empty
+}
+
+// MigrateRequest represents applying the rewrite rules of a migration pack,
+// such as replacing io/ioutil, as one plan
+type MigrateRequest struct {
+	Pack    string `json:"pack"`              // Name of a built-in pack, or a .yaml file relative to the workspace root
+	Package string `json:"package,omitempty"` // Only this package; every package when empty
+}
+
+// ConvertReceiversRequest represents giving all the methods of a type the
+// same receiver form, pointer or value
+type ConvertReceiversRequest struct {
+	TypeName string       `json:"type_name"`
+	Package  string       `json:"package,omitempty"`  // Package path of the type (optional, "" means workspace-wide)
+	Receiver ReceiverKind `json:"receiver,omitempty"` // The form most of the methods have when empty, pointer on a tie
+}
+
+// AddFieldRequest represents adding a field to a struct type and to its
+// positional literals
+type AddFieldRequest struct {
+	TypeName     string `json:"type_name"`
+	FieldName    string `json:"field_name"`
+	FieldType    string `json:"field_type"`             // Type expression as written in the struct's file, e.g. "time.Duration"
+	Package      string `json:"package,omitempty"`      // Package path of the type (optional, "" means workspace-wide)
+	Before       string `json:"before,omitempty"`       // Field to add it before
+	After        string `json:"after,omitempty"`        // Field to add it after; last when neither is set
+	Tag          string `json:"tag,omitempty"`          // Struct tag without the backquotes, e.g. json:"timeout"
+	Default      string `json:"default,omitempty"`      // Value positional literals and constructor calls get; the zero value when empty
+	Constructors bool   `json:"constructors,omitempty"` // Add a parameter for the field to New<Type> functions that set every field from one
 }
 
+// ReceiverKind selects the receiver form convert_receivers gives methods.
+type ReceiverKind string
+
+const (
+	PointerReceiver ReceiverKind = "pointer"
+	ValueReceiver   ReceiverKind = "value"
+)
+
 type RenameScope int
 
 const (
@@ -91,84 +412,129 @@ const (
 
 // ExtractMethodRequest represents extracting a method from code
 type ExtractMethodRequest struct {
-	SourceFile    string
-	StartLine     int
-	EndLine       int
-	NewMethodName string
-	TargetStruct  string
+	SourceFile    string `json:"source_file"`
+	StartLine     int    `json:"start_line"`
+	EndLine       int    `json:"end_line"`
+	NewMethodName string `json:"new_method_name"`
+	TargetStruct  string `json:"target_struct,omitempty"`
 }
 
 // ExtractFunctionRequest represents extracting a function from code
 type ExtractFunctionRequest struct {
-	SourceFile      string
-	StartLine       int
-	EndLine         int
-	NewFunctionName string
+	SourceFile      string `json:"source_file"`
+	StartLine       int    `json:"start_line"`
+	EndLine         int    `json:"end_line"`
+	NewFunctionName string `json:"new_function_name"`
 }
 
 // ExtractInterfaceRequest represents extracting an interface from a struct
 type ExtractInterfaceRequest struct {
-	SourceStruct  string
-	InterfaceName string
-	Methods       []string
-	TargetPackage string
+	SourceStruct  string   `json:"source_struct"`
+	InterfaceName string   `json:"interface_name"`
+	Methods       []string `json:"methods,omitempty"`
+	TargetPackage string   `json:"target_package,omitempty"`
+	TargetFile    string   `json:"target_file,omitempty"` // File of the target package to declare the interface in; interfaces.go when empty
+	Adopt         []string `json:"adopt,omitempty"`       // Declarations of the struct's type to retype to the interface, as introduce_type targets
 }
 
 // ExtractVariableRequest represents extracting a variable from an expression
 type ExtractVariableRequest struct {
-	SourceFile   string
-	StartLine    int
-	EndLine      int
-	VariableName string
-	Expression   string
+	SourceFile   string `json:"source_file"`
+	StartLine    int    `json:"start_line"`
+	EndLine      int    `json:"end_line"`
+	VariableName string `json:"variable_name"`
+	Expression   string `json:"expression,omitempty"`
 }
 
 // InlineMethodRequest represents inlining a method call with its implementation
 type InlineMethodRequest struct {
-	MethodName   string
-	SourceStruct string
-	TargetFile   string
-	CallSites    []CallSite // Specific call sites to inline, empty means all
+	MethodName   string     `json:"method_name"`
+	SourceStruct string     `json:"source_struct,omitempty"`
+	TargetFile   string     `json:"target_file,omitempty"`
+	CallSites    []CallSite `json:"call_sites,omitempty"` // Specific call sites to inline, empty means all
 }
 
 // InlineVariableRequest represents inlining a variable with its value
 type InlineVariableRequest struct {
-	VariableName string
-	SourceFile   string
-	TargetFiles  []string // Files where to inline the variable
+	VariableName string   `json:"variable_name"`
+	SourceFile   string   `json:"source_file"`
+	TargetFiles  []string `json:"target_files,omitempty"` // Files where to inline the variable
+	Force        bool     `json:"force,omitempty"`        // Inline even when that duplicates or drops the initializer's side effects
 }
 
-// InlineFunctionRequest represents inlining a function call with its implementation  
+// InlineFunctionRequest represents inlining a function call with its implementation
 type InlineFunctionRequest struct {
-	FunctionName string
-	SourceFile   string
-	TargetFiles  []string // Files where to inline the function
+	FunctionName string   `json:"function_name"`
+	SourceFile   string   `json:"source_file"`
+	TargetFiles  []string `json:"target_files,omitempty"` // Files where to inline the function
 }
 
 // CallSite represents a specific location where a method/function is called
 type CallSite struct {
-	File   string
-	Line   int
-	Column int
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
 }
 
 // RefactoringPlan represents a planned set of changes
 type RefactoringPlan struct {
-	Operations    []Operation
-	Changes       []Change
-	AffectedFiles []string
-	Impact        *ImpactAnalysis
-	Reversible    bool
+	Operations    []Operation     `json:"-"`
+	Changes       []Change        `json:"changes"`
+	AffectedFiles []string        `json:"affected_files"`
+	Impact        *ImpactAnalysis `json:"impact,omitempty"`
+	Reversible    bool            `json:"reversible"`
+
+	// AllowBreaking, when set, overrides the engine's breaking-change policy
+	// for this plan.
+	AllowBreaking *bool `json:"-"`
 }
 
 // Change represents a specific change to be made
 type Change struct {
-	File        string
-	Start       int
-	End         int
-	OldText     string
-	NewText     string
-	Description string
+	File        string     `json:"file"`
+	Start       int        `json:"start"`
+	End         int        `json:"end"`
+	OldText     string     `json:"old_text"`
+	NewText     string     `json:"new_text"`
+	Description string     `json:"description"`
+	Confidence  Confidence `json:"confidence,omitempty"` // How sure the operation is that the edit is wanted; empty is certain
+	Checked     bool       `json:"checked,omitempty"`    // The file parses with the change applied; NewText may not parse on its own
+}
+
+// Confidence grades how an operation matched the code a change edits.
+type Confidence string
+
+// Confidence levels, from the most to the least sure.
+const (
+	ConfidenceCertain   Confidence = "certain"   // Matched by type identity
+	ConfidenceLikely    Confidence = "likely"    // Matched by name and package or import, without type information
+	ConfidenceHeuristic Confidence = "heuristic" // Matched by name alone
+)
+
+// ParseConfidence parses a confidence level; the empty string is certain.
+func ParseConfidence(s string) (Confidence, error) {
+	switch c := Confidence(s); c {
+	case "":
+		return ConfidenceCertain, nil
+	case ConfidenceCertain, ConfidenceLikely, ConfidenceHeuristic:
+		return c, nil
+	}
+	return "", fmt.Errorf("unknown confidence %q: want %s, %s or %s", s, ConfidenceCertain, ConfidenceLikely, ConfidenceHeuristic)
+}
+
+// AtLeast reports whether c is at least as sure as level.
+func (c Confidence) AtLeast(level Confidence) bool {
+	return c.rank() >= level.rank()
+}
+
+func (c Confidence) rank() int {
+	switch c {
+	case ConfidenceHeuristic:
+		return 0
+	case ConfidenceLikely:
+		return 1
+	}
+	return 2
 }
 
 // SuggestedMove represents a symbol that would benefit from being moved
@@ -189,21 +555,62 @@ type PackageCouplingInfo struct {
 
 // ImpactAnalysis shows what will be affected by a refactoring
 type ImpactAnalysis struct {
-	AffectedPackages []string
-	AffectedFiles    []string
-	AffectedSymbols  []*Symbol
-	PotentialIssues  []Issue
-	ImportChanges    []ImportChange
+	AffectedPackages []string                       `json:"affected_packages"`
+	AffectedFiles    []string                       `json:"affected_files"`
+	AffectedSymbols  []*Symbol                      `json:"affected_symbols,omitempty"`
+	PotentialIssues  []Issue                        `json:"potential_issues,omitempty"`
+	ImportChanges    []ImportChange                 `json:"import_changes,omitempty"`
 	SuggestedMoves   []SuggestedMove                `json:"suggested_moves,omitempty"`
-	PackageCoupling  map[string]PackageCouplingInfo  `json:"package_coupling,omitempty"`
+	PackageCoupling  map[string]PackageCouplingInfo `json:"package_coupling,omitempty"`
+	Compatibility    []CompatibilityReport          `json:"compatibility,omitempty"` // Uses in dependent repositories, when checked
+}
+
+// CompatibilityReport lists the uses dependent repositories make of an
+// exported symbol the plan changes, which the change breaks.
+type CompatibilityReport struct {
+	Symbol     string            `json:"symbol"` // Import path and name, e.g. example.com/m/pkg.Func
+	Change     string            `json:"change"` // What the plan does to the symbol
+	Dependents []DependentReport `json:"dependents"`
+}
+
+// DependentReport is the part of a CompatibilityReport about one dependent
+// repository.
+type DependentReport struct {
+	Root   string           `json:"root"`
+	Usages []DependentUsage `json:"usages,omitempty"`
+	Patch  string           `json:"patch,omitempty"` // Unified diff updating the usages, with paths relative to Root
+	Error  string           `json:"error,omitempty"` // Why the repository couldn't be scanned
+}
+
+// DependentUsage is a use of a symbol in a dependent repository.
+type DependentUsage struct {
+	File       string     `json:"file"`
+	Line       int        `json:"line"`
+	Column     int        `json:"column"`
+	Text       string     `json:"text"` // The line of the use
+	Confidence Confidence `json:"confidence"`
 }
 
 type Issue struct {
-	Type        IssueType
-	Description string
-	File        string
-	Line        int
-	Severity    IssueSeverity
+	Type        IssueType     `json:"type"`
+	Description string        `json:"description"`
+	File        string        `json:"file,omitempty"`
+	Line        int           `json:"line,omitempty"`
+	Severity    IssueSeverity `json:"severity"`
+}
+
+// Code returns the stable code of the issue's type.
+func (i Issue) Code() ErrorCode {
+	return i.Type.Code()
+}
+
+// MarshalJSON adds the issue's code to its fields.
+func (i Issue) MarshalJSON() ([]byte, error) {
+	type issue Issue
+	return json.Marshal(struct {
+		issue
+		Code ErrorCode `json:"code,omitempty"`
+	}{issue(i), i.Code()})
 }
 
 type IssueType int
@@ -214,8 +621,35 @@ const (
 	IssueVisibilityError
 	IssueNameConflict
 	IssueTypeMismatch
+	IssueStringReference // A string that may name a renamed identifier
+	IssueDependentUsage  // A use in a dependent repository the change breaks
+	IssueSideEffect      // An effect the change duplicates, drops or reorders
 )
 
+// Code returns the stable code of t, or "" for an unknown type. Issues
+// share their codes with the RefactorError types for the same problem.
+func (t IssueType) Code() ErrorCode {
+	switch t {
+	case IssueCompilationError:
+		return CodeCompilationError
+	case IssueImportCycle:
+		return CodeImportCycle
+	case IssueVisibilityError:
+		return CodeVisibilityViolation
+	case IssueNameConflict:
+		return CodeNameConflict
+	case IssueTypeMismatch:
+		return CodeTypeMismatch
+	case IssueStringReference:
+		return CodeStringReference
+	case IssueDependentUsage:
+		return CodeDependentUsage
+	case IssueSideEffect:
+		return CodeSideEffect
+	}
+	return ""
+}
+
 type IssueSeverity int
 
 const (
@@ -238,11 +672,41 @@ func (s IssueSeverity) String() string {
 	}
 }
 
+// MarshalText encodes the severity as error, warning or info.
+func (s IssueSeverity) MarshalText() ([]byte, error) {
+	return []byte(strings.ToLower(s.String())), nil
+}
+
+// UnmarshalJSON accepts the names MarshalText writes and, for plans saved
+// before severities were named, their numbers.
+func (s *IssueSeverity) UnmarshalJSON(data []byte) error {
+	var n int
+	if err := json.Unmarshal(data, &n); err == nil {
+		*s = IssueSeverity(n)
+		return nil
+	}
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return err
+	}
+	switch strings.ToLower(name) {
+	case "error":
+		*s = Error
+	case "warning":
+		*s = Warning
+	case "info":
+		*s = Info
+	default:
+		return fmt.Errorf("unknown issue severity %q", name)
+	}
+	return nil
+}
+
 type ImportChange struct {
-	File      string
-	OldImport string
-	NewImport string
-	Action    ImportAction
+	File      string       `json:"file"`
+	OldImport string       `json:"old_import"`
+	NewImport string       `json:"new_import"`
+	Action    ImportAction `json:"action"`
 }
 
 type ImportAction int
@@ -255,86 +719,117 @@ const (
 
 // SafeDeleteRequest represents safely deleting a symbol
 type SafeDeleteRequest struct {
-	Symbol     string
-	SourceFile string
-	Force      bool
+	Symbol     string `json:"symbol"`
+	SourceFile string `json:"source_file"`
+	Force      bool   `json:"force,omitempty"`
 }
 
 // MovePackageRequest represents moving an entire package
 type MovePackageRequest struct {
-	SourcePackage string
-	TargetPackage string
-	CreateTarget  bool
-	UpdateImports bool
+	SourcePackage string `json:"source_package"`
+	TargetPackage string `json:"target_package"`
+	CreateTarget  bool   `json:"create_target,omitempty"`
+	UpdateImports bool   `json:"update_imports,omitempty"`
+	MoveTests     bool   `json:"move_tests,omitempty"` // Move the package's test files along with it
 }
 
 // MoveDirRequest represents moving a directory structure
 type MoveDirRequest struct {
-	SourceDir     string
-	TargetDir     string
-	PreserveStructure bool
-	UpdateImports bool
+	SourceDir         string `json:"source_dir"`
+	TargetDir         string `json:"target_dir"`
+	PreserveStructure bool   `json:"preserve_structure,omitempty"`
+	UpdateImports     bool   `json:"update_imports,omitempty"`
 }
 
 // MovePackagesRequest represents moving multiple packages atomically
 type MovePackagesRequest struct {
-	Packages      []PackageMapping
-	TargetDir     string
-	CreateTargets bool
-	UpdateImports bool
+	Packages      []PackageMapping `json:"packages"`
+	TargetDir     string           `json:"target_dir"`
+	CreateTargets bool             `json:"create_targets,omitempty"`
+	UpdateImports bool             `json:"update_imports,omitempty"`
 }
 
 type PackageMapping struct {
-	SourcePackage string
-	TargetPackage string
+	SourcePackage string `json:"source_package"`
+	TargetPackage string `json:"target_package"`
+}
+
+// MergePackagesRequest represents merging packages into an existing one
+type MergePackagesRequest struct {
+	SourcePackages []string `json:"source_packages"` // Package paths whose files move into TargetPackage
+	TargetPackage  string   `json:"target_package"`
+}
+
+// OrganizeByDomainRequest represents regrouping the declarations of a
+// package into one file per domain
+type OrganizeByDomainRequest struct {
+	Package string   `json:"package"`
+	Domains []Domain `json:"domains"`
+}
+
+// Domain names the package-level declarations that belong together. They
+// move to the file <name>.go, a type with its methods and constructors.
+type Domain struct {
+	Name    string   `json:"name"`
+	Symbols []string `json:"symbols"`
 }
 
 // CreateFacadeRequest represents creating a facade package
 type CreateFacadeRequest struct {
-	TargetPackage string
-	Exports       []ExportSpec
+	TargetPackage string       `json:"target_package"`
+	Exports       []ExportSpec `json:"exports"`
 }
 
 type ExportSpec struct {
-	SourcePackage string
-	SymbolName    string
-	Alias         string // optional alias for the export
+	SourcePackage string `json:"source_package"`
+	SymbolName    string `json:"symbol_name"`
+	Alias         string `json:"alias,omitempty"` // optional alias for the export
 }
 
 // GenerateFacadesRequest represents auto-generating facades for modules
 type GenerateFacadesRequest struct {
-	ModulesDir string
-	TargetDir  string
-	ExportTypes []string // e.g., "commands", "models", "events"
+	ModulesDir  string   `json:"modules_dir"`
+	TargetDir   string   `json:"target_dir"`
+	ExportTypes []string `json:"export_types,omitempty"` // e.g., "commands", "models", "events"
+	Include     []string `json:"include,omitempty"`      // name patterns (path.Match) of the symbols to export; empty = all
+	Exclude     []string `json:"exclude,omitempty"`      // name patterns of the symbols not to export
 }
 
-// UpdateFacadesRequest represents updating existing facades
+// UpdateFacadesRequest represents updating existing facades. Aliases of
+// existing re-exports are kept, and re-exports of symbols that no longer
+// exist are dropped with a warning.
 type UpdateFacadesRequest struct {
-	FacadePackages []string
-	AutoDetect     bool
+	FacadePackages []string `json:"facade_packages,omitempty"`
+	AutoDetect     bool     `json:"auto_detect,omitempty"`
+	Include        []string `json:"include,omitempty"`       // name patterns (path.Match) of the symbols to export; empty = all
+	Exclude        []string `json:"exclude,omitempty"`       // name patterns of the symbols not to export
+	OnlyExisting   bool     `json:"only_existing,omitempty"` // refresh the existing re-exports without adding newly exported symbols
 }
 
 // CleanAliasesRequest represents removing import aliases
 type CleanAliasesRequest struct {
-	Workspace      string
-	PreserveConflicts bool // keep aliases only where needed to resolve conflicts
+	Workspace         string   `json:"workspace"`
+	PreserveConflicts bool     `json:"preserve_conflicts,omitempty"` // keep aliases only where needed to resolve conflicts
+	Packages          []string `json:"packages,omitempty"`           // package directories to limit the operation to; empty = all
 }
 
 // StandardizeImportsRequest represents standardizing import aliases
 type StandardizeImportsRequest struct {
-	Workspace string
-	Rules     []AliasRule
+	Workspace string      `json:"workspace"`
+	Rules     []AliasRule `json:"rules,omitempty"`
+	Packages  []string    `json:"packages,omitempty"` // package directories to limit the operation to; empty = all
 }
 
 type AliasRule struct {
-	PackagePattern string // e.g., "github.com/user/repo/pkg/events"
-	Alias          string // e.g., "events"
+	PackagePattern string `json:"package_pattern"` // e.g., "github.com/user/repo/pkg/events"
+	Alias          string `json:"alias"`           // e.g., "events"
 }
 
 // ResolveAliasConflictsRequest represents resolving import alias conflicts
 type ResolveAliasConflictsRequest struct {
-	Workspace string
-	Strategy  ConflictStrategy
+	Workspace string           `json:"workspace"`
+	Strategy  ConflictStrategy `json:"strategy,omitempty"`
+	Packages  []string         `json:"packages,omitempty"` // package directories to limit the operation to; empty = all
 }
 
 type ConflictStrategy int
@@ -347,55 +842,93 @@ const (
 
 // ConvertAliasesRequest represents converting between aliased and non-aliased imports
 type ConvertAliasesRequest struct {
-	Workspace    string
-	ToFullNames  bool
-	FromFullNames bool
+	Workspace     string   `json:"workspace"`
+	ToFullNames   bool     `json:"to_full_names,omitempty"`
+	FromFullNames bool     `json:"from_full_names,omitempty"`
+	Packages      []string `json:"packages,omitempty"` // package directories to limit the operation to; empty = all
 }
 
 // MoveByDependenciesRequest represents moving symbols based on dependency analysis
 type MoveByDependenciesRequest struct {
-	Workspace      string
-	MoveSharedTo   string // e.g., "pkg/"
-	KeepInternal   []string // e.g., ["internal/app", "internal/handlers"]
-	AnalyzeOnly    bool // If true, only analyze and suggest moves
+	Workspace    string   `json:"workspace"`
+	MoveSharedTo string   `json:"move_shared_to,omitempty"` // e.g., "pkg/"
+	KeepInternal []string `json:"keep_internal,omitempty"`  // e.g., ["internal/app", "internal/handlers"]
+	AnalyzeOnly  bool     `json:"analyze_only,omitempty"`   // If true, only analyze and suggest moves
 }
 
 // OrganizeByLayersRequest represents organizing imports/packages by architectural layers
 type OrganizeByLayersRequest struct {
-	Workspace      string
-	DomainLayer    string // e.g., "modules/"
-	InfrastructureLayer string // e.g., "pkg/"
-	ApplicationLayer string // e.g., "internal/"
-	ReorderImports bool // Whether to reorder imports according to layers
+	Workspace           string `json:"workspace"`
+	DomainLayer         string `json:"domain_layer,omitempty"`         // e.g., "modules/"
+	InfrastructureLayer string `json:"infrastructure_layer,omitempty"` // e.g., "pkg/"
+	ApplicationLayer    string `json:"application_layer,omitempty"`    // e.g., "internal/"
+	ReorderImports      bool   `json:"reorder_imports,omitempty"`      // Whether to reorder imports according to layers
+	// Layers assigns packages, by directory relative to the workspace root,
+	// to the domain, infrastructure or application layer, ahead of the
+	// layer directories; infer_layers proposes one.
+	Layers map[string]string `json:"layers,omitempty"`
 }
 
 // FixCyclesRequest represents detecting and fixing circular dependencies
 type FixCyclesRequest struct {
-	Workspace    string
-	AutoFix      bool // If true, attempt automatic fixes
-	OutputReport string // Optional: file to write cycle analysis report
+	Workspace    string `json:"workspace"`
+	AutoFix      bool   `json:"auto_fix,omitempty"`      // If true, attempt automatic fixes
+	OutputReport string `json:"output_report,omitempty"` // Optional: file to write cycle analysis report
 }
 
 // AnalyzeDependenciesRequest represents analyzing dependency flow
 type AnalyzeDependenciesRequest struct {
-	Workspace           string
-	DetectBackwardsDeps bool
-	SuggestMoves       bool
-	OutputFile         string // File to write analysis results
-}
-
-// BatchOperationRequest represents executing multiple operations atomically
+	Workspace           string `json:"workspace"`
+	DetectBackwardsDeps bool   `json:"detect_backwards_deps,omitempty"`
+	SuggestMoves        bool   `json:"suggest_moves,omitempty"`
+	OutputFile          string `json:"output_file,omitempty"` // File to write analysis results
+	// Format "dot" or "mermaid" exports the package dependency graph as a
+	// diagram instead of the analysis. The layer directories, relative to
+	// the workspace, color its packages; packages outside them are placed
+	// by naming conventions.
+	Format              string `json:"format,omitempty"`
+	DomainLayer         string `json:"domain_layer,omitempty"`
+	InfrastructureLayer string `json:"infrastructure_layer,omitempty"`
+	ApplicationLayer    string `json:"application_layer,omitempty"`
+}
+
+// BatchOperationRequest represents executing multiple operations atomically.
+// The operations come from exactly one of Operations, Steps or File. Each
+// step is planned against the workspace as the steps before it leave it.
 type BatchOperationRequest struct {
-	Operations       []string // Command strings to execute
-	RollbackOnFailure bool
-	DryRun           bool
+	Operations        []string    `json:"operations,omitempty"` // Command strings to execute
+	Steps             []BatchStep `json:"steps,omitempty"`
+	File              string      `json:"file,omitempty"` // YAML or JSON BatchFile, relative to the workspace root
+	RollbackOnFailure bool        `json:"rollback_on_failure,omitempty"`
+	DryRun            bool        `json:"dry_run,omitempty"`
+	AllowBreaking     *bool       `json:"allow_breaking,omitempty"` // Overrides the engine's breaking-change policy for the batch
+}
+
+// BatchFile is the declarative batch format read from BatchOperationRequest.File.
+type BatchFile struct {
+	Version           int         `json:"version,omitempty"`
+	RollbackOnFailure bool        `json:"rollback_on_failure,omitempty"`
+	Steps             []BatchStep `json:"steps"`
+}
+
+// BatchStep is one named operation of a batch. Request holds the fields of
+// the operation's request type (see RequestSchema); package and file paths
+// may be relative to the workspace root. A step runs after the steps it
+// depends on; when an optional step fails, it and the steps depending on it
+// are skipped instead of failing the batch.
+type BatchStep struct {
+	Name      string         `json:"name"`
+	Operation string         `json:"operation"`
+	Request   map[string]any `json:"request,omitempty"`
+	DependsOn []string       `json:"depends_on,omitempty"`
+	Optional  bool           `json:"optional,omitempty"`
 }
 
 // PlanOperationRequest represents creating a refactoring plan
 type PlanOperationRequest struct {
-	Operations []PlanStep
-	OutputFile string
-	DryRun     bool
+	Operations []PlanStep `json:"operations"`
+	OutputFile string     `json:"output_file"`
+	DryRun     bool       `json:"dry_run,omitempty"`
 }
 
 type PlanStep struct {
@@ -405,11 +938,13 @@ type PlanStep struct {
 
 // ExecuteOperationRequest represents executing a previously created plan
 type ExecuteOperationRequest struct {
-	PlanFile string
+	PlanFile string `json:"plan_file"`
 }
 
 // RollbackOperationRequest represents rolling back operations
 type RollbackOperationRequest struct {
-	LastBatch bool
-	ToStep    int // Rollback to specific step number
-}
\ No newline at end of file
+	LastBatch bool   `json:"last_batch,omitempty"`
+	ToStep    int    `json:"to_step,omitempty"` // Rollback to specific step number
+	ToID      string `json:"to_id,omitempty"`   // Rollback this history entry and every later one
+	Force     bool   `json:"force,omitempty"`   // Overwrite files edited since the plan was applied
+}
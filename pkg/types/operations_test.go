@@ -1,6 +1,8 @@
 package types
 
 import (
+	"encoding/json"
+	"strings"
 	"testing"
 )
 
@@ -416,4 +418,49 @@ func TestMockOperation(t *testing.T) {
 	if plan == nil {
 		t.Error("Expected Execute to return a plan")
 	}
-}
\ No newline at end of file
+}
+
+func TestParseConfidence(t *testing.T) {
+	tests := map[string]Confidence{
+		"":          ConfidenceCertain,
+		"certain":   ConfidenceCertain,
+		"likely":    ConfidenceLikely,
+		"heuristic": ConfidenceHeuristic,
+	}
+	for in, want := range tests {
+		if got, err := ParseConfidence(in); err != nil || got != want {
+			t.Errorf("ParseConfidence(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseConfidence("maybe"); err == nil {
+		t.Error("expected an error for an unknown level")
+	}
+	if !Confidence("").AtLeast(ConfidenceCertain) || ConfidenceLikely.AtLeast(ConfidenceCertain) || !ConfidenceLikely.AtLeast(ConfidenceHeuristic) {
+		t.Error("unexpected confidence order")
+	}
+}
+
+func TestIssue_JSON(t *testing.T) {
+	issue := Issue{Type: IssueImportCycle, Description: "import cycle", File: "a.go", Line: 3, Severity: Warning}
+	data, err := json.Marshal(issue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"code":"GR2003"`, `"severity":"warning"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("%s lacks %s", data, want)
+		}
+	}
+
+	var got Issue
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got != issue {
+		t.Errorf("round trip = %+v, want %+v", got, issue)
+	}
+	// Plans saved before severities were named hold numbers
+	if err := json.Unmarshal([]byte(`{"type":1,"severity":2}`), &got); err != nil || got.Severity != Info {
+		t.Errorf("numeric severity = %v, %v; want Info", got.Severity, err)
+	}
+}
@@ -0,0 +1,34 @@
+package types
+
+// ProgressPhase names a stage of a long-running engine call.
+type ProgressPhase string
+
+const (
+	PhaseParse ProgressPhase = "parse" // Parsing package sources
+	PhaseIndex ProgressPhase = "index" // Building symbol tables and the dependency graph
+	PhasePlan  ProgressPhase = "plan"  // Computing a refactoring plan
+	PhaseApply ProgressPhase = "apply" // Writing changes to disk
+)
+
+// ProgressEvent reports how far a phase has progressed. Current and Total count
+// the units of work (packages or files) in the phase; Total is 0 when unknown.
+type ProgressEvent struct {
+	Phase   ProgressPhase
+	Package string // Package or file the event relates to, if any
+	Current int
+	Total   int
+	Message string
+}
+
+// Percent returns the completion of the phase in the range [0, 100], or 0 when
+// the total is unknown.
+func (e ProgressEvent) Percent() float64 {
+	if e.Total <= 0 {
+		return 0
+	}
+	return float64(e.Current) / float64(e.Total) * 100
+}
+
+// ProgressReporter receives progress events. Implementations must be safe for
+// concurrent use since packages are parsed in parallel.
+type ProgressReporter func(ProgressEvent)
@@ -0,0 +1,155 @@
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+// requestTypes maps operation names (matching the MCP tool names where one
+// exists) to the request type the engine accepts for that operation.
+var requestTypes = map[string]reflect.Type{
+	"move_symbol":             reflect.TypeFor[MoveSymbolRequest](),
+	"rename_symbol":           reflect.TypeFor[RenameSymbolRequest](),
+	"rename_package":          reflect.TypeFor[RenamePackageRequest](),
+	"rename_interface_method": reflect.TypeFor[RenameInterfaceMethodRequest](),
+	"rename_method":           reflect.TypeFor[RenameMethodRequest](),
+	"extract_method":          reflect.TypeFor[ExtractMethodRequest](),
+	"extract_function":        reflect.TypeFor[ExtractFunctionRequest](),
+	"extract_interface":       reflect.TypeFor[ExtractInterfaceRequest](),
+	"extract_variable":        reflect.TypeFor[ExtractVariableRequest](),
+	"inline_method":           reflect.TypeFor[InlineMethodRequest](),
+	"inline_variable":         reflect.TypeFor[InlineVariableRequest](),
+	"inline_function":         reflect.TypeFor[InlineFunctionRequest](),
+	"safe_delete":             reflect.TypeFor[SafeDeleteRequest](),
+	"encapsulate_field":       reflect.TypeFor[EncapsulateFieldRequest](),
+	"shrink_interface":        reflect.TypeFor[ShrinkInterfaceRequest](),
+	"segregate_interface":     reflect.TypeFor[SegregateInterfaceRequest](),
+	"extract_test_helper":     reflect.TypeFor[ExtractTestHelperRequest](),
+	"extract_pipeline_stages": reflect.TypeFor[ExtractPipelineStagesRequest](),
+	"build_tags":              reflect.TypeFor[BuildTagsRequest](),
+	"thread_context":          reflect.TypeFor[ThreadContextRequest](),
+	"convert_type_alias":      reflect.TypeFor[ConvertTypeAliasRequest](),
+	"introduce_type":          reflect.TypeFor[IntroduceTypeRequest](),
+	"generate_mock":           reflect.TypeFor[GenerateMockRequest](),
+	"update_mocks":            reflect.TypeFor[UpdateMocksRequest](),
+	"fix_magic_numbers":       reflect.TypeFor[FixMagicNumbersRequest](),
+	"organize_file":           reflect.TypeFor[OrganizeFileRequest](),
+	"split_file":              reflect.TypeFor[SplitFileRequest](),
+	"fix_error_returns":       reflect.TypeFor[FixErrorReturnsRequest](),
+	"fix_naming":              reflect.TypeFor[FixNamingRequest](),
+	"bulk_rename":             reflect.TypeFor[BulkRenameRequest](),
+	"rewrite":                 reflect.TypeFor[RewriteRequest](),
+	"migrate":                 reflect.TypeFor[MigrateRequest](),
+	"convert_receivers":       reflect.TypeFor[ConvertReceiversRequest](),
+	"add_field":               reflect.TypeFor[AddFieldRequest](),
+	"move_package":            reflect.TypeFor[MovePackageRequest](),
+	"move_dir":                reflect.TypeFor[MoveDirRequest](),
+	"move_packages":           reflect.TypeFor[MovePackagesRequest](),
+	"merge_packages":          reflect.TypeFor[MergePackagesRequest](),
+	"create_facade":           reflect.TypeFor[CreateFacadeRequest](),
+	"generate_facades":        reflect.TypeFor[GenerateFacadesRequest](),
+	"update_facades":          reflect.TypeFor[UpdateFacadesRequest](),
+	"clean_aliases":           reflect.TypeFor[CleanAliasesRequest](),
+	"standardize_imports":     reflect.TypeFor[StandardizeImportsRequest](),
+	"resolve_alias_conflicts": reflect.TypeFor[ResolveAliasConflictsRequest](),
+	"convert_aliases":         reflect.TypeFor[ConvertAliasesRequest](),
+	"move_by_dependencies":    reflect.TypeFor[MoveByDependenciesRequest](),
+	"organize_by_layers":      reflect.TypeFor[OrganizeByLayersRequest](),
+	"organize_by_domain":      reflect.TypeFor[OrganizeByDomainRequest](),
+	"fix_cycles":              reflect.TypeFor[FixCyclesRequest](),
+	"analyze_dependencies":    reflect.TypeFor[AnalyzeDependenciesRequest](),
+	"batch_operations":        reflect.TypeFor[BatchOperationRequest](),
+	"create_plan":             reflect.TypeFor[PlanOperationRequest](),
+	"execute_plan":            reflect.TypeFor[ExecuteOperationRequest](),
+	"rollback":                reflect.TypeFor[RollbackOperationRequest](),
+}
+
+// schemaOptions stops inference at Symbol, whose Parent/Children links are
+// cyclic; plans describe affected symbols as opaque objects.
+var schemaOptions = &jsonschema.ForOptions{
+	TypeSchemas: map[reflect.Type]*jsonschema.Schema{
+		reflect.TypeFor[Symbol](): {Type: "object"},
+	},
+}
+
+// RequestNames returns the names of all operations with a request schema, sorted.
+func RequestNames() []string {
+	return slices.Sorted(maps.Keys(requestTypes))
+}
+
+// RequestSchema returns the JSON Schema for the named operation's request.
+func RequestSchema(name string) (*jsonschema.Schema, error) {
+	t, ok := requestTypes[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown operation %q", name)
+	}
+	return jsonschema.ForType(t, schemaOptions)
+}
+
+// PlanSchema returns the JSON Schema for RefactoringPlan, the response of
+// every planning call.
+func PlanSchema() (*jsonschema.Schema, error) {
+	return jsonschema.ForType(reflect.TypeFor[RefactoringPlan](), schemaOptions)
+}
+
+// Schemas returns the request schema of every operation keyed by name, plus
+// the plan schema under "plan".
+func Schemas() (map[string]*jsonschema.Schema, error) {
+	schemas := make(map[string]*jsonschema.Schema, len(requestTypes)+1)
+	for _, name := range RequestNames() {
+		s, err := RequestSchema(name)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		schemas[name] = s
+	}
+	plan, err := PlanSchema()
+	if err != nil {
+		return nil, fmt.Errorf("plan: %w", err)
+	}
+	schemas["plan"] = plan
+	return schemas, nil
+}
+
+// DecodeRequest validates data against the named operation's request schema
+// and decodes it into req, which must be a pointer to that operation's
+// request type. Unknown fields, missing required fields and mistyped values
+// are reported as InvalidOperation errors before anything is planned.
+func DecodeRequest(name string, data []byte, req any) error {
+	t, ok := requestTypes[name]
+	if !ok {
+		return &RefactorError{Type: InvalidOperation, Message: fmt.Sprintf("unknown operation %q", name)}
+	}
+	if rt := reflect.TypeOf(req); rt == nil || rt.Kind() != reflect.Pointer || rt.Elem() != t {
+		return fmt.Errorf("DecodeRequest(%q): req must be *%s, got %T", name, t.Name(), req)
+	}
+	schema, err := RequestSchema(name)
+	if err != nil {
+		return err
+	}
+	resolved, err := schema.Resolve(nil)
+	if err != nil {
+		return fmt.Errorf("resolve %s schema: %w", name, err)
+	}
+
+	var instance any
+	if err := json.Unmarshal(data, &instance); err != nil {
+		return &RefactorError{Type: InvalidOperation, Message: fmt.Sprintf("invalid %s request: %v", name, err), Cause: err}
+	}
+	if err := resolved.Validate(instance); err != nil {
+		return &RefactorError{Type: InvalidOperation, Message: fmt.Sprintf("invalid %s request: %v", name, err), Cause: err}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(req); err != nil {
+		return &RefactorError{Type: InvalidOperation, Message: fmt.Sprintf("invalid %s request: %v", name, err), Cause: err}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package types
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSchemas(t *testing.T) {
+	schemas, err := Schemas()
+	if err != nil {
+		t.Fatalf("Schemas: %v", err)
+	}
+	if len(schemas) != len(RequestNames())+1 {
+		t.Errorf("expected %d schemas, got %d", len(RequestNames())+1, len(schemas))
+	}
+
+	s := schemas["move_symbol"]
+	if s == nil || s.Properties["symbol_name"] == nil {
+		t.Fatalf("move_symbol schema missing symbol_name property: %+v", s)
+	}
+	if got := s.Required; len(got) != 3 {
+		t.Errorf("expected 3 required move_symbol fields, got %v", got)
+	}
+	if schemas["plan"].Properties["changes"] == nil {
+		t.Error("plan schema missing changes property")
+	}
+}
+
+func TestDecodeRequest(t *testing.T) {
+	var req MovePackageRequest
+	if err := DecodeRequest("move_package", []byte(`{"source_package":"a","target_package":"b"}`), &req); err != nil {
+		t.Fatalf("DecodeRequest: %v", err)
+	}
+	if req.SourcePackage != "a" || req.TargetPackage != "b" {
+		t.Errorf("unexpected request: %+v", req)
+	}
+
+	tests := map[string]string{
+		"missing required": `{"source_package":"a"}`,
+		"unknown field":    `{"source_package":"a","target_package":"b","bogus":1}`,
+		"wrong type":       `{"source_package":"a","target_package":2}`,
+		"malformed":        `{`,
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := DecodeRequest("move_package", []byte(data), &MovePackageRequest{})
+			var refErr *RefactorError
+			if !errors.As(err, &refErr) || refErr.Type != InvalidOperation {
+				t.Errorf("expected InvalidOperation error, got %v", err)
+			}
+		})
+	}
+
+	if err := DecodeRequest("move_package", []byte(`{}`), &MoveDirRequest{}); err == nil {
+		t.Error("expected error for mismatched request type")
+	}
+}
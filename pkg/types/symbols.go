@@ -121,4 +121,5 @@ type Reference struct {
 	Line     int
 	Column   int
 	Context  string  // Surrounding code context
+	Confidence Confidence // How the reference was matched to Symbol; empty is certain
 }
\ No newline at end of file
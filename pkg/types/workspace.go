@@ -6,13 +6,27 @@ import (
 	gotypes "go/types"
 )
 
-// Workspace represents a complete Go workspace (module or GOPATH)
+// Workspace represents a complete Go workspace (module or GOPATH).
+//
+// Memory model: the workspace owns every Package, File and Symbol reachable
+// from it, and all of them are kept in sync in place as files change.
+//   - Packages is keyed by Package.Path, the absolute package directory.
+//   - ImportToPath maps each non-empty Package.ImportPath back to that key,
+//     and holds nothing else.
+//   - Package.Files and Package.TestFiles are keyed by file base name; each
+//     File points back at its Package, and _test.go files are only in
+//     TestFiles.
+//   - Every AST, and every Symbol position, belongs to FileSet, and a
+//     symbol lies within the File its Symbol.File names.
+//
+// CheckInvariants verifies these rules; the engine runs it after loading
+// and after each plan when internal verification is on.
 type Workspace struct {
 	RootPath     string
 	Module       *Module
-	Packages     map[string]*Package // filesystem path -> Package
-	ImportToPath map[string]string   // import path -> filesystem path
-	FileSet      *token.FileSet
+	Packages     map[string]*Package // Package.Path -> Package
+	ImportToPath map[string]string   // Package.ImportPath -> Package.Path
+	FileSet      *token.FileSet      // Shared by every file in the workspace
 	Dependencies *DependencyGraph
 }
 
@@ -22,10 +36,10 @@ type Package struct {
 	ImportPath   string              // Go import path (e.g., "github.com/foo/bar")
 	Name         string              // Package name
 	Dir          string              // Filesystem directory
-	Files        map[string]*File    // filename -> File
+	Files        map[string]*File    // base filename -> File, excluding tests
 	Symbols      *SymbolTable
 	Imports      []string            // Direct imports
-	TestFiles    map[string]*File    // Test files
+	TestFiles    map[string]*File    // base filename -> _test.go File
 	TypesInfo    *gotypes.Info       // Semantic type info (may be nil if type-checking failed)
 	TypesPkg     *gotypes.Package    // Type-checked package (may be nil)
 }
@@ -37,13 +51,15 @@ type File struct {
 	AST             *ast.File
 	OriginalContent []byte
 	Modifications   []Modification
+	Pruned          bool // AST holds only the package clause and imports; see analysis.GoParser.LimitScope
 }
 
 // Module represents Go module information
 type Module struct {
-	Path    string
-	Version string
-	GoMod   string  // Contents of go.mod
+	Path      string
+	Version   string
+	GoVersion string  // Of the go directive, such as "1.22"
+	GoMod     string  // Contents of go.mod
 }
 
 // Modification tracks changes to be made to a file
@@ -3,8 +3,10 @@ package watch
 import (
 	"fmt"
 	"log/slog"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
@@ -20,6 +22,7 @@ type WorkspaceUpdater struct {
 	resolver  *analysis.SymbolResolver
 	analyzer  *analysis.DependencyAnalyzer
 	logger    *slog.Logger
+	owned     map[*types.Package]bool // Packages a fork has copied; nil when it may change them all
 }
 
 // NewUpdater creates a WorkspaceUpdater from the components exposed by WatchContext.
@@ -39,6 +42,56 @@ func NewUpdater(
 	}
 }
 
+// Fork returns an updater for a copy of the workspace, so the changes it
+// handles leave the workspace as it is for those still reading it. The copy
+// shares the packages no change touches; the fork copies a package the
+// first time it changes it.
+func (u *WorkspaceUpdater) Fork() *WorkspaceUpdater {
+	ws := *u.workspace
+	ws.Packages = maps.Clone(u.workspace.Packages)
+	ws.ImportToPath = maps.Clone(u.workspace.ImportToPath)
+	return &WorkspaceUpdater{
+		workspace: &ws,
+		parser:    u.parser,
+		resolver:  u.resolver.Fork(&ws),
+		analyzer:  analysis.NewDependencyAnalyzer(&ws, u.logger),
+		logger:    u.logger,
+		owned:     make(map[*types.Package]bool),
+	}
+}
+
+// own returns the package to change in place of pkg: pkg itself, unless the
+// updater is a fork sharing it, in which case pkg is replaced in the fork's
+// workspace by a copy of its own.
+func (u *WorkspaceUpdater) own(pkg *types.Package) *types.Package {
+	if u.owned == nil || u.owned[pkg] {
+		return pkg
+	}
+	owned := *pkg
+	owned.Files = ownFiles(pkg.Files, &owned)
+	owned.TestFiles = ownFiles(pkg.TestFiles, &owned)
+	owned.Imports = slices.Clone(pkg.Imports)
+	if pkg.Symbols != nil {
+		symbols := *pkg.Symbols
+		symbols.Package = &owned
+		owned.Symbols = &symbols
+	}
+	u.workspace.Packages[pkg.Path] = &owned
+	u.owned[&owned] = true
+	return &owned
+}
+
+// ownFiles copies files for pkg, a copy of their package.
+func ownFiles(files map[string]*types.File, pkg *types.Package) map[string]*types.File {
+	owned := make(map[string]*types.File, len(files))
+	for name, f := range files {
+		file := *f
+		file.Package = pkg
+		owned[name] = &file
+	}
+	return owned
+}
+
 // HandleChanges processes a batch of file-change events.
 // It groups them by package directory and incrementally re-evaluates each.
 func (u *WorkspaceUpdater) HandleChanges(events []ChangeEvent) {
@@ -94,6 +147,7 @@ func (u *WorkspaceUpdater) handleModify(dir, path, base string, isTest bool) {
 		u.logger.Error("modify: parse failed", "file", path, "err", err)
 		return
 	}
+	pkg = u.own(pkg)
 	file.Package = pkg
 
 	if isTest {
@@ -137,6 +191,7 @@ func (u *WorkspaceUpdater) handleCreate(dir, path, base string, isTest bool) {
 		return
 	}
 
+	pkg = u.own(pkg)
 	file.Package = pkg
 	if isTest {
 		pkg.TestFiles[base] = file
@@ -157,6 +212,7 @@ func (u *WorkspaceUpdater) handleDelete(dir, path, base string, isTest bool) {
 	if pkg == nil {
 		return
 	}
+	pkg = u.own(pkg)
 
 	if isTest {
 		delete(pkg.TestFiles, base)
@@ -173,6 +229,7 @@ func (u *WorkspaceUpdater) handleDelete(dir, path, base string, isTest bool) {
 			delete(u.workspace.ImportToPath, pkg.ImportPath)
 		}
 		u.resolver.InvalidateCacheForPackage(pkg.Path)
+		u.dropTypes(pkg)
 		u.logger.Info("delete: removed empty package",
 			"dir", dir,
 			"elapsed", time.Since(start).Round(time.Millisecond),
@@ -188,6 +245,7 @@ func (u *WorkspaceUpdater) handleDelete(dir, path, base string, isTest bool) {
 // and logs the result.
 func (u *WorkspaceUpdater) rebuildPackage(pkg *types.Package, action, path string, start time.Time) {
 	u.resolver.InvalidateCacheForPackage(pkg.Path)
+	u.dropTypes(pkg)
 
 	st, err := u.resolver.BuildSymbolTable(pkg)
 	if err != nil {
@@ -240,12 +298,16 @@ func (u *WorkspaceUpdater) createPackage(dir string, file *types.File, isTest bo
 
 	// Register in workspace.
 	u.workspace.Packages[pkg.Path] = pkg
+	if u.owned != nil {
+		u.owned[pkg] = true
+	}
 	if importPath != "" {
 		u.workspace.ImportToPath[importPath] = pkg.Path
 	}
 
 	// Build symbol table and dependency graph.
 	u.resolver.InvalidateCacheForPackage(pkg.Path)
+	u.dropTypes(pkg)
 	if _, err := u.resolver.BuildSymbolTable(pkg); err != nil {
 		u.logger.Error("create: symbol table build failed", "dir", dir, "err", err)
 	}
@@ -256,6 +318,39 @@ func (u *WorkspaceUpdater) createPackage(dir string, file *types.File, isTest bo
 	return pkg
 }
 
+// dropTypes clears the type information of pkg and of the workspace
+// packages importing it, directly or not, whose information refers to its
+// old objects. They are type-checked again when next needed, so importers
+// pruned by GoParser.LimitScope are restored for it. A fork copies the
+// importers it shares.
+func (u *WorkspaceUpdater) dropTypes(pkg *types.Package) {
+	queue := []*types.Package{pkg}
+	seen := map[string]bool{pkg.Path: true}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		p.TypesInfo = nil
+		p.TypesPkg = nil
+		if restored, err := u.parser.RestorePruned(p); err != nil {
+			u.logger.Error("restoring pruned files failed", "package", p.Path, "err", err)
+		} else if restored {
+			u.resolver.InvalidateCacheForPackage(p.Path)
+			if _, err := u.resolver.BuildSymbolTable(p); err != nil {
+				u.logger.Error("symbol table rebuild failed", "package", p.Path, "err", err)
+			}
+		}
+		if p.ImportPath == "" {
+			continue
+		}
+		for _, other := range u.workspace.Packages {
+			if !seen[other.Path] && slices.Contains(other.Imports, p.ImportPath) {
+				seen[other.Path] = true
+				queue = append(queue, u.own(other))
+			}
+		}
+	}
+}
+
 // findPackageByDir returns the workspace package whose Dir matches dir.
 func (u *WorkspaceUpdater) findPackageByDir(dir string) *types.Package {
 	for _, pkg := range u.workspace.Packages {
@@ -310,6 +405,12 @@ func (u *WorkspaceUpdater) Workspace() *types.Workspace {
 	return u.workspace
 }
 
+// Resolver returns the resolver the updater keeps in sync with its
+// workspace.
+func (u *WorkspaceUpdater) Resolver() *analysis.SymbolResolver {
+	return u.resolver
+}
+
 // String implements fmt.Stringer for logging convenience.
 func (u *WorkspaceUpdater) String() string {
 	return fmt.Sprintf("WorkspaceUpdater{packages=%d}", len(u.workspace.Packages))
@@ -174,6 +174,82 @@ func TestUpdater_NewDirectoryCreatesPackage(t *testing.T) {
 	}
 }
 
+func TestUpdater_ModifyDropsStaleTypes(t *testing.T) {
+	u, dir := setupWorkspace(t)
+	aPath := filepath.Join(dir, "pkg", "a", "a.go")
+	bDir := filepath.Join(dir, "pkg", "b")
+	_ = os.MkdirAll(bDir, 0755)
+	bPath := filepath.Join(bDir, "b.go")
+	_ = os.WriteFile(bPath, []byte("package b\n\nimport \"example.com/test/pkg/a\"\n\nfunc Run() { a.Hello() }\n"), 0644)
+	u.HandleChanges([]ChangeEvent{{Path: bPath, Op: fsnotify.Create}})
+
+	a, b := u.FindPackage(filepath.Join(dir, "pkg", "a")), u.FindPackage(bDir)
+	if a == nil || b == nil {
+		t.Fatal("expected packages at pkg/a and pkg/b")
+	}
+	u.parser.EnsureTypeChecked(u.Workspace(), b)
+	if a.TypesPkg == nil || b.TypesPkg == nil {
+		t.Fatal("expected both packages to be type-checked")
+	}
+
+	_ = os.WriteFile(aPath, []byte("package a\n\nfunc Hello() {}\nfunc World() {}\n"), 0644)
+	u.HandleChanges([]ChangeEvent{{Path: aPath, Op: fsnotify.Write}})
+
+	// b's type information refers to a's old objects.
+	if a.TypesPkg != nil || a.TypesInfo != nil || b.TypesPkg != nil || b.TypesInfo != nil {
+		t.Fatal("expected the type information of pkg/a and its importer pkg/b to be dropped")
+	}
+	u.parser.EnsureTypeChecked(u.Workspace(), b)
+	if a.TypesPkg == nil || a.TypesPkg.Scope().Lookup("World") == nil {
+		t.Fatal("expected pkg/a to be type-checked again with World")
+	}
+}
+
+func TestUpdater_ForkLeavesWorkspace(t *testing.T) {
+	u, dir := setupWorkspace(t)
+	aDir, bDir := filepath.Join(dir, "pkg", "a"), filepath.Join(dir, "pkg", "b")
+	_ = os.MkdirAll(bDir, 0755)
+	bPath := filepath.Join(bDir, "b.go")
+	_ = os.WriteFile(bPath, []byte("package b\n\nimport \"example.com/test/pkg/a\"\n\nfunc Run() { a.Hello() }\n"), 0644)
+	cDir := filepath.Join(dir, "pkg", "c")
+	_ = os.MkdirAll(cDir, 0755)
+	cPath := filepath.Join(cDir, "c.go")
+	_ = os.WriteFile(cPath, []byte("package c\n\nfunc Other() {}\n"), 0644)
+	u.HandleChanges([]ChangeEvent{{Path: bPath, Op: fsnotify.Create}, {Path: cPath, Op: fsnotify.Create}})
+	for _, pkg := range u.Workspace().Packages {
+		u.parser.EnsureTypeChecked(u.Workspace(), pkg)
+	}
+	a, b, c := u.FindPackage(aDir), u.FindPackage(bDir), u.FindPackage(cDir)
+
+	aPath := filepath.Join(aDir, "a.go")
+	_ = os.WriteFile(aPath, []byte("package a\n\nfunc Hello() {}\nfunc World() {}\n"), 0644)
+	fork := u.Fork()
+	fork.HandleChanges([]ChangeEvent{{Path: aPath, Op: fsnotify.Write}})
+
+	// The original workspace is as it was, types included.
+	if u.FindPackage(aDir) != a || len(a.Symbols.Functions) != 1 || a.TypesPkg == nil || b.TypesPkg == nil {
+		t.Fatal("expected the forked workspace to be left unchanged")
+	}
+	if a.Files["a.go"].Package != a {
+		t.Fatal("expected the original file to stay in the original package")
+	}
+	forkA, forkB := fork.FindPackage(aDir), fork.FindPackage(bDir)
+	if forkA == a || len(forkA.Symbols.Functions) != 2 || forkA.Files["a.go"].Package != forkA {
+		t.Fatal("expected the fork to hold a changed copy of pkg/a")
+	}
+	if forkB == b || forkB.TypesPkg != nil || forkB.Files["b.go"].Package != forkB {
+		t.Fatal("expected the fork to copy pkg/b, an importer of pkg/a, without its type information")
+	}
+	if fork.FindPackage(cDir) != c {
+		t.Fatal("expected the fork to share pkg/c, which the change doesn't touch")
+	}
+
+	fork.parser.EnsureTypeChecked(fork.Workspace(), forkB)
+	if forkA.TypesPkg == nil || forkA.TypesPkg.Scope().Lookup("World") == nil || a.TypesPkg.Scope().Lookup("World") != nil {
+		t.Fatal("expected the fork's pkg/a to be type-checked with World apart from the original")
+	}
+}
+
 func safeFuncCount(pkg *types.Package) int {
 	if pkg.Symbols == nil {
 		return 0
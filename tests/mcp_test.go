@@ -2,7 +2,9 @@ package tests_test
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
+	"os"
 	"path/filepath"
 	"strings"
 	"testing"
@@ -177,6 +179,58 @@ func TestMCPTools(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "encapsulate_field", fixture: "encapsulate_field", tool: "encapsulate_field",
+			args: func(dir string) map[string]any {
+				return map[string]any{"type_name": "Config", "field_name": "Name"}
+			},
+		},
+		{
+			name: "shrink_interface", fixture: "shrink_interface", tool: "shrink_interface",
+			args: func(dir string) map[string]any {
+				return map[string]any{
+					"interface_name": "Store",
+					"roles": []map[string]any{
+						{"name": "Reader", "methods": []string{"Get"}},
+						{"name": "Writer", "methods": []string{"Put"}},
+					},
+				}
+			},
+		},
+		{
+			name: "segregate_interface", fixture: "segregate_interface", tool: "segregate_interface",
+			args: func(dir string) map[string]any {
+				return map[string]any{"interface_name": "Store"}
+			},
+		},
+		{
+			name: "build_tags", fixture: "build_tags", tool: "build_tags",
+			args: func(dir string) map[string]any {
+				return map[string]any{
+					"migrate_legacy": true,
+					"rename_tags":    map[string]string{"integration": "e2e"},
+					"file_suffixes":  "remove",
+				}
+			},
+		},
+		{
+			name: "thread_context", fixture: "thread_context", tool: "thread_context",
+			args: func(dir string) map[string]any {
+				return map[string]any{"function_name": "fetch", "package": ".", "root": "Handle"}
+			},
+		},
+		{
+			name: "extract_pipeline_stages", fixture: "extract_pipeline_stages", tool: "extract_pipeline_stages",
+			args: func(dir string) map[string]any {
+				return map[string]any{"source_file": "importer.go", "line": 23}
+			},
+		},
+		{
+			name: "extract_test_helper", fixture: "extract_test_helper", tool: "extract_test_helper",
+			args: func(dir string) map[string]any {
+				return map[string]any{"package": "."}
+			},
+		},
 		// --- Code smell fixers ---
 		{
 			name: "fix_if_init", fixture: "fix_if_init", tool: "fix_if_init_assignments",
@@ -202,6 +256,12 @@ func TestMCPTools(t *testing.T) {
 				return map[string]any{}
 			},
 		},
+		{
+			name: "fix_error_string_checks", fixture: "fix_error_sentinels", tool: "fix_error_string_checks",
+			args: func(dir string) map[string]any {
+				return map[string]any{}
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -234,3 +294,367 @@ func TestMCPTools(t *testing.T) {
 		})
 	}
 }
+
+func TestMCPShutdownRejectsNewPlans(t *testing.T) {
+	if *transportFlag != "inprocess" {
+		t.Skip("shutdown is only observable in-process")
+	}
+	dir := copyFixture(t, "rename_symbol")
+	ctx := context.Background()
+	sess := mcptest.Dial(ctx, t, mcpTransport(), dir)
+	defer sess.Close()
+
+	if err := sess.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	result, err := sess.CallTool(ctx, &mcpsdk.CallToolParams{
+		Name:      "rename_symbol",
+		Arguments: map[string]any{"symbol": "Add", "new_name": "Sum"},
+	})
+	if err != nil {
+		t.Fatalf("CallTool: %v", err)
+	}
+	if !result.IsError {
+		t.Fatal("expected plans submitted after shutdown to be rejected")
+	}
+}
+
+func TestMCPStaging(t *testing.T) {
+	dir := copyFixture(t, "rename_symbol")
+	ctx := context.Background()
+	sess := mcptest.Dial(ctx, t, mcpTransport(), dir)
+	defer sess.Close()
+
+	call := func(tool string, args map[string]any) {
+		t.Helper()
+		result, err := sess.CallTool(ctx, &mcpsdk.CallToolParams{Name: tool, Arguments: args})
+		if err != nil {
+			t.Fatalf("CallTool(%s): %v", tool, err)
+		}
+		if result.IsError {
+			t.Fatalf("CallTool(%s) returned error: %v", tool, result.Content)
+		}
+	}
+	path := filepath.Join(dir, "main.go")
+	before, _ := os.ReadFile(path)
+
+	call("begin_staging", map[string]any{})
+	call("rename_symbol", map[string]any{"symbol": "Add", "new_name": "Sum"})
+	// Only resolves if the first rename is visible.
+	call("rename_symbol", map[string]any{"symbol": "Sum", "new_name": "Total"})
+	if got, _ := os.ReadFile(path); string(got) != string(before) {
+		t.Fatalf("main.go was written while staging:\n%s", got)
+	}
+
+	call("apply_staged", map[string]any{})
+	got, _ := os.ReadFile(path)
+	if !strings.Contains(string(got), "func Total(") || strings.Contains(string(got), "Sum") {
+		t.Errorf("main.go does not have both renames:\n%s", got)
+	}
+}
+
+func TestMCPWorkspaceSessions(t *testing.T) {
+	first := copyFixture(t, "rename_symbol")
+	second := copyFixture(t, "rename_symbol")
+	ctx := context.Background()
+	sess := mcptest.Dial(ctx, t, mcpTransport(), first)
+	defer sess.Close()
+
+	call := func(tool string, args map[string]any, out any) {
+		t.Helper()
+		result, err := sess.CallTool(ctx, &mcpsdk.CallToolParams{Name: tool, Arguments: args})
+		if err != nil {
+			t.Fatalf("CallTool(%s): %v", tool, err)
+		}
+		if result.IsError {
+			t.Fatalf("CallTool(%s) returned error: %v", tool, result.Content)
+		}
+		if out != nil {
+			if err := json.Unmarshal([]byte(result.Content[0].(*mcpsdk.TextContent).Text), out); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	tools, err := sess.ListTools(ctx, &mcpsdk.ListToolsParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tool := range tools.Tools {
+		if schema, _ := json.Marshal(tool.InputSchema); tool.Name == "rename_symbol" && !strings.Contains(string(schema), `"workspace_id"`) {
+			t.Errorf("rename_symbol doesn't take workspace_id: %s", schema)
+		}
+	}
+
+	type opened struct {
+		WorkspaceID string `json:"workspace_id"`
+	}
+	var a, b opened
+	call("workspace_status", map[string]any{}, &a)
+	call("open_workspace", map[string]any{"path": second}, &b)
+	if a.WorkspaceID == "" || b.WorkspaceID == "" || a.WorkspaceID == b.WorkspaceID {
+		t.Fatalf("workspace IDs %q and %q", a.WorkspaceID, b.WorkspaceID)
+	}
+
+	// The opened workspace is the default; the first one is named.
+	call("rename_symbol", map[string]any{"symbol": "Add", "new_name": "Sum", "workspace_id": a.WorkspaceID}, nil)
+	firstSrc, _ := os.ReadFile(filepath.Join(first, "main.go"))
+	secondSrc, _ := os.ReadFile(filepath.Join(second, "main.go"))
+	if !strings.Contains(string(firstSrc), "func Sum(") {
+		t.Errorf("first workspace was not renamed:\n%s", firstSrc)
+	}
+	if strings.Contains(string(secondSrc), "Sum") {
+		t.Errorf("second workspace was renamed:\n%s", secondSrc)
+	}
+
+	var list []struct {
+		WorkspaceID string `json:"workspace_id"`
+		RootPath    string `json:"root_path"`
+		Default     bool   `json:"default"`
+	}
+	call("list_workspaces", map[string]any{}, &list)
+	if len(list) != 2 || list[0].WorkspaceID != a.WorkspaceID || list[0].Default || !list[1].Default {
+		t.Errorf("list_workspaces = %+v", list)
+	}
+
+	call("close_workspace", map[string]any{"workspace_id": b.WorkspaceID}, nil)
+	call("list_workspaces", map[string]any{}, &list)
+	if len(list) != 1 || list[0].WorkspaceID != a.WorkspaceID || !list[0].Default {
+		t.Errorf("list_workspaces after close = %+v", list)
+	}
+	result, err := sess.CallTool(ctx, &mcpsdk.CallToolParams{
+		Name:      "workspace_status",
+		Arguments: map[string]any{"workspace_id": b.WorkspaceID},
+	})
+	if err != nil || !result.IsError {
+		t.Errorf("closed workspace still answers: %v %v", result, err)
+	}
+}
+
+func TestMCPRepeatedCall(t *testing.T) {
+	dir := copyFixture(t, "rename_symbol")
+	ctx := context.Background()
+	sess := mcptest.Dial(ctx, t, mcpTransport(), dir)
+	defer sess.Close()
+
+	var results []string
+	for range 2 {
+		result, err := sess.CallTool(ctx, &mcpsdk.CallToolParams{
+			Name:      "rename_symbol",
+			Arguments: map[string]any{"symbol": "Add", "new_name": "Sum"},
+		})
+		if err != nil {
+			t.Fatalf("CallTool: %v", err)
+		}
+		if result.IsError {
+			t.Fatalf("rename_symbol returned error: %v", result.Content)
+		}
+		results = append(results, result.Content[0].(*mcpsdk.TextContent).Text)
+	}
+	// The retry finds Add gone, but the workspace is as the first call left
+	// it, so it gets the same answer.
+	if results[0] != results[1] {
+		t.Errorf("retry returned a different result:\n%s\n%s", results[0], results[1])
+	}
+	compareGoldenFiles(t, "rename_symbol", dir)
+}
+
+func TestMCPQueryCache(t *testing.T) {
+	dir := copyFixture(t, "rename_symbol")
+	ctx := context.Background()
+	sess := mcptest.Dial(ctx, t, mcpTransport(), dir)
+	defer sess.Close()
+
+	call := func(name string, args map[string]any) string {
+		t.Helper()
+		result, err := sess.CallTool(ctx, &mcpsdk.CallToolParams{Name: name, Arguments: args})
+		if err != nil {
+			t.Fatalf("CallTool: %v", err)
+		}
+		if result.IsError {
+			t.Fatalf("%s returned error: %v", name, result.Content)
+		}
+		return result.Content[0].(*mcpsdk.TextContent).Text
+	}
+	stats := func() (s struct {
+		QueryCache struct {
+			Entries       int `json:"entries"`
+			Hits          int `json:"hits"`
+			Misses        int `json:"misses"`
+			Invalidations int `json:"invalidations"`
+		} `json:"query_cache"`
+	}) {
+		t.Helper()
+		res, err := sess.ReadResource(ctx, &mcpsdk.ReadResourceParams{URI: "workspace://stats"})
+		if err != nil {
+			t.Fatalf("ReadResource: %v", err)
+		}
+		if err := json.Unmarshal([]byte(res.Contents[0].Text), &s); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	args := map[string]any{"min_complexity": 1}
+	first := call("complexity", args)
+	if again := call("complexity", map[string]any{"min_complexity": 1}); again != first {
+		t.Errorf("cached result differs:\n%s\n%s", first, again)
+	}
+	if s := stats(); s.QueryCache.Hits != 1 || s.QueryCache.Misses != 1 || s.QueryCache.Entries != 1 {
+		t.Errorf("unexpected stats after a repeated query: %+v", s.QueryCache)
+	}
+
+	call("rename_symbol", map[string]any{"symbol": "Add", "new_name": "Sum"})
+	if renamed := call("complexity", args); !strings.Contains(renamed, "Sum") {
+		t.Errorf("expected the query to see the rename, got:\n%s", renamed)
+	}
+	if s := stats(); s.QueryCache.Hits != 1 || s.QueryCache.Misses != 2 || s.QueryCache.Invalidations == 0 {
+		t.Errorf("unexpected stats after the workspace changed: %+v", s.QueryCache)
+	}
+}
+
+func TestMCPPackageAPIResource(t *testing.T) {
+	dir := copyFixture(t, "rename_symbol")
+	ctx := context.Background()
+	sess := mcptest.Dial(ctx, t, mcpTransport(), dir)
+	defer sess.Close()
+
+	res, err := sess.ReadResource(ctx, &mcpsdk.ReadResourceParams{URI: "workspace://api/tests/rename_symbol"})
+	if err != nil {
+		t.Fatalf("ReadResource: %v", err)
+	}
+	var api struct {
+		Name      string `json:"name"`
+		Functions []struct {
+			Name      string `json:"name"`
+			Signature string `json:"signature"`
+		} `json:"functions"`
+	}
+	if err := json.Unmarshal([]byte(res.Contents[0].Text), &api); err != nil {
+		t.Fatal(err)
+	}
+	if api.Name != "main" || len(api.Functions) != 1 || api.Functions[0].Signature != "func Add(a, b int) int" {
+		t.Errorf("unexpected API: %s", res.Contents[0].Text)
+	}
+
+	if _, err := sess.ReadResource(ctx, &mcpsdk.ReadResourceParams{URI: "workspace://api/example.com/missing"}); err == nil {
+		t.Error("expected an error for an unknown package")
+	}
+}
+
+func TestMCPXRefResource(t *testing.T) {
+	dir := copyFixture(t, "rename_symbol")
+	ctx := context.Background()
+	sess := mcptest.Dial(ctx, t, mcpTransport(), dir)
+	defer sess.Close()
+
+	res, err := sess.ReadResource(ctx, &mcpsdk.ReadResourceParams{URI: "workspace://xref/tests/rename_symbol"})
+	if err != nil {
+		t.Fatalf("ReadResource: %v", err)
+	}
+	var graph struct {
+		Edges []struct {
+			From  string `json:"from"`
+			To    string `json:"to"`
+			Count int    `json:"count"`
+		} `json:"edges"`
+	}
+	if err := json.Unmarshal([]byte(res.Contents[0].Text), &graph); err != nil {
+		t.Fatal(err)
+	}
+	if len(graph.Edges) != 1 || graph.Edges[0].From != "tests/rename_symbol.main" || graph.Edges[0].To != "tests/rename_symbol.Add" || graph.Edges[0].Count != 2 {
+		t.Errorf("unexpected graph: %s", res.Contents[0].Text)
+	}
+}
+
+func TestMCPCallGraph(t *testing.T) {
+	dir := copyFixture(t, "rename_symbol")
+	ctx := context.Background()
+	sess := mcptest.Dial(ctx, t, mcpTransport(), dir)
+	defer sess.Close()
+
+	result, err := sess.CallTool(ctx, &mcpsdk.CallToolParams{
+		Name:      "call_graph",
+		Arguments: map[string]any{"root": "tests/rename_symbol", "format": "dot"},
+	})
+	if err != nil {
+		t.Fatalf("CallTool: %v", err)
+	}
+	if result.IsError || len(result.Content) != 1 {
+		t.Fatalf("call_graph failed: %v", result.Content)
+	}
+	dot := result.Content[0].(*mcpsdk.TextContent).Text
+	if !strings.HasPrefix(dot, "digraph callgraph {") || !strings.Contains(dot, `"tests/rename_symbol.main" -> "tests/rename_symbol.Add" [label="2"];`) {
+		t.Errorf("unexpected graph:\n%s", dot)
+	}
+}
+
+func TestMCPRunAnalyzersSARIF(t *testing.T) {
+	dir := copyFixture(t, "fix_error_wrapping")
+	ctx := context.Background()
+	sess := mcptest.Dial(ctx, t, mcpTransport(), dir)
+	defer sess.Close()
+
+	result, err := sess.CallTool(ctx, &mcpsdk.CallToolParams{
+		Name:      "run_analyzers",
+		Arguments: map[string]any{"analyzers": []string{"errorwrap", "unused"}, "format": "sarif"},
+	})
+	if err != nil {
+		t.Fatalf("CallTool: %v", err)
+	}
+	if result.IsError || len(result.Content) != 1 {
+		t.Fatalf("run_analyzers failed: %v", result.Content)
+	}
+
+	var log struct {
+		Version string `json:"version"`
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Rules []struct {
+						ID string `json:"id"`
+					} `json:"rules"`
+				} `json:"driver"`
+			} `json:"tool"`
+			Results []struct {
+				RuleID    string `json:"ruleId"`
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct {
+							URI string `json:"uri"`
+						} `json:"artifactLocation"`
+					} `json:"physicalLocation"`
+				} `json:"locations"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal([]byte(result.Content[0].(*mcpsdk.TextContent).Text), &log); err != nil {
+		t.Fatal(err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("unexpected SARIF log: %+v", log)
+	}
+	run := log.Runs[0]
+	if len(run.Tool.Driver.Rules) != 2 {
+		t.Errorf("expected rules for the two analyzers, got %+v", run.Tool.Driver.Rules)
+	}
+	found := false
+	for _, r := range run.Results {
+		if r.RuleID == "errorwrap" && r.Locations[0].PhysicalLocation.ArtifactLocation.URI == "main.go" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected an errorwrap finding in main.go, got %+v", run.Results)
+	}
+
+	result, err = sess.CallTool(ctx, &mcpsdk.CallToolParams{
+		Name:      "run_analyzers",
+		Arguments: map[string]any{"analyzers": []string{"nope"}},
+	})
+	if err != nil {
+		t.Fatalf("CallTool: %v", err)
+	}
+	if !result.IsError {
+		t.Error("expected an unknown analyzer to be rejected")
+	}
+}
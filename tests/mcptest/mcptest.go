@@ -31,6 +31,15 @@ func (s *Session) Close() {
 	}
 }
 
+// Shutdown gracefully shuts down an in-process server's state. It is a no-op
+// for subprocess sessions.
+func (s *Session) Shutdown(ctx context.Context) error {
+	if s.state == nil {
+		return nil
+	}
+	return s.state.Shutdown(ctx)
+}
+
 // Transport selects how the MCP server is reached.
 type Transport interface {
 	connect(ctx context.Context, t testing.TB) (*Session, error)
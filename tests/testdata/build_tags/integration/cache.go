@@ -0,0 +1,7 @@
+//go:build integration || smoke
+// +build integration smoke
+
+package integration
+
+// CacheAddr is the cache used by the checks.
+const CacheAddr = "localhost:6379"
@@ -0,0 +1,7 @@
+// +build integration,!fast
+
+// Package integration holds slow end-to-end checks.
+package integration
+
+// DSN is the database used by the checks.
+const DSN = "postgres://localhost/test"
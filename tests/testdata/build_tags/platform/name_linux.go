@@ -0,0 +1,7 @@
+// Copyright 2024 The Tags Authors.
+
+package platform
+
+func name() string {
+	return "linux"
+}
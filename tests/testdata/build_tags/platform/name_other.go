@@ -0,0 +1,7 @@
+//go:build !linux
+
+package platform
+
+func name() string {
+	return "other"
+}
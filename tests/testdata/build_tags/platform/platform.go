@@ -0,0 +1,6 @@
+package platform
+
+// Name returns the name of the platform.
+func Name() string {
+	return name()
+}
@@ -0,0 +1,13 @@
+package app
+
+import "example.com/encap/model"
+
+func Use(c *model.Config) string {
+	c.Name = "x"
+	c.Name += "y" + "z"
+	n := c.Name
+	_ = &c.Name
+	lit := model.Config{Name: "z"}
+	_ = lit
+	return n + c.Describe()
+}
@@ -0,0 +1,3 @@
+module example.com/encap
+
+go 1.21
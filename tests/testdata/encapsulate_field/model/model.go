@@ -0,0 +1,18 @@
+package model
+
+// Config holds settings.
+type Config struct {
+	Name  string
+	Count int
+	Tags  []string
+}
+
+// New creates a Config.
+func New(name string) *Config {
+	return &Config{Name: name}
+}
+
+// Describe returns a summary of the config.
+func (c *Config) Describe() string {
+	return c.Name
+}
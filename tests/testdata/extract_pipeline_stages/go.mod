@@ -0,0 +1,3 @@
+module tests/extract_pipeline_stages
+
+go 1.21
@@ -0,0 +1,41 @@
+package importer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type Record struct {
+	Name string
+	Age  int
+	Row  int
+}
+
+type Sink interface {
+	Save(r Record) error
+}
+
+// Import parses name,age lines and saves the adults to sink.
+func Import(lines []string, sink Sink) (int, []error) {
+	saved := 0
+	var errs []error
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		fields := strings.Split(trimmed, ",")
+		if len(fields) != 2 {
+			continue
+		}
+		age, err := strconv.Atoi(strings.TrimSpace(fields[1]))
+		if err != nil || age < 18 {
+			continue
+		}
+		rec := Record{Name: strings.ToUpper(fields[0]), Age: age, Row: i + 1}
+		if err := sink.Save(rec); err != nil {
+			errs = append(errs, fmt.Errorf("row %d: %w", rec.Row, err))
+			continue
+		}
+		saved++
+	}
+	return saved, errs
+}
@@ -0,0 +1,3 @@
+module tests/extract_test_helper
+
+go 1.21
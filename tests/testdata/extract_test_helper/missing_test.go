@@ -0,0 +1,22 @@
+package store
+
+import (
+	"os"
+	"testing"
+)
+
+func TestStoreMissing(t *testing.T) {
+	dir, err := os.MkdirTemp("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Get("nope"); err == nil {
+		t.Error("expected an error")
+	}
+}
@@ -0,0 +1,28 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Store keeps records in a directory.
+type Store struct {
+	dir string
+}
+
+// Open returns a Store writing to dir.
+func Open(dir string) (*Store, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir}, nil
+}
+
+func (s *Store) Put(key, value string) error {
+	return os.WriteFile(filepath.Join(s.dir, key), []byte(value), 0o644)
+}
+
+func (s *Store) Get(key string) (string, error) {
+	b, err := os.ReadFile(filepath.Join(s.dir, key))
+	return string(b), err
+}
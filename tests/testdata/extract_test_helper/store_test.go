@@ -0,0 +1,44 @@
+package store
+
+import (
+	"os"
+	"testing"
+)
+
+func TestStoreGet(t *testing.T) {
+	dir, err := os.MkdirTemp("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Put("a", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.Get("a"); got != "1" {
+		t.Errorf("Get = %q, want 1", got)
+	}
+}
+
+func TestStorePut(t *testing.T) {
+	dir, err := os.MkdirTemp("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Put("b", "2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(dir + "/b"); err != nil {
+		t.Error(err)
+	}
+}
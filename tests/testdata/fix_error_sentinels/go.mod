@@ -0,0 +1,3 @@
+module tests/fix_error_sentinels
+
+go 1.21
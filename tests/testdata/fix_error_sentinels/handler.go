@@ -0,0 +1,32 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+)
+
+func Lookup(s *Store, key string) (string, bool) {
+	v, err := s.Get(key)
+	if err != nil && err.Error() == "item not found" {
+		return "", false
+	}
+	return v, true
+}
+
+func Save(s *Store, key, value string) error {
+	err := s.Put(key, value)
+	if err != nil && strings.Contains(err.Error(), "read only") {
+		return nil
+	}
+	if err == fmt.Errorf("store is read only") {
+		return nil
+	}
+	return err
+}
+
+func Describe(err error) string {
+	if err.Error() == "timeout" {
+		return "timed out"
+	}
+	return err.Error()
+}
@@ -0,0 +1,29 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+)
+
+type Store struct {
+	items map[string]string
+}
+
+func (s *Store) Get(key string) (string, error) {
+	v, ok := s.items[key]
+	if !ok {
+		return "", errors.New("item not found")
+	}
+	return v, nil
+}
+
+func (s *Store) Put(key, value string) error {
+	if s.items == nil {
+		return fmt.Errorf("store is read only")
+	}
+	if key == "" {
+		return fmt.Errorf("invalid key %q", key)
+	}
+	s.items[key] = value
+	return nil
+}
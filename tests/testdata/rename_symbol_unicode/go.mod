@@ -0,0 +1,3 @@
+module tests/rename_symbol_unicode
+
+go 1.21
@@ -0,0 +1,13 @@
+package main
+
+func ölfeld(a, b int) int {
+	return a * b
+}
+
+func berechne() int {
+	return ölfeld(2, 3) + ölfeld(4, 5)
+}
+
+func main() {
+	_ = berechne()
+}
@@ -0,0 +1,31 @@
+package app
+
+import "example.com/segregate/store"
+
+// Show returns the record under key.
+func Show(s store.Store, key string) string {
+	v, _ := s.Get(key)
+	return v
+}
+
+// Copy copies key from src to dst.
+func Copy(src, dst store.Store, key string) error {
+	v, err := src.Get(key)
+	if err != nil {
+		return err
+	}
+	return dst.Put(key, v)
+}
+
+// Wipe deletes every record.
+func Wipe(s store.Store) {
+	for _, k := range s.List() {
+		_ = s.Delete(k)
+	}
+}
+
+// Remember keeps s for later.
+func Remember(s store.Store) store.Store {
+	_, _ = s.Get("warmup")
+	return s
+}
@@ -0,0 +1,3 @@
+module example.com/segregate
+
+go 1.21
@@ -0,0 +1,36 @@
+package store
+
+// Store persists records.
+type Store interface {
+	// Get returns the record stored under key.
+	Get(key string) (string, error)
+	// Put stores a record.
+	Put(key, value string) error
+	Delete(key string) error
+	List() []string
+}
+
+// Memory is an in-memory Store.
+type Memory struct {
+	data map[string]string
+}
+
+func (m *Memory) Get(key string) (string, error) { return m.data[key], nil }
+
+func (m *Memory) Put(key, value string) error {
+	m.data[key] = value
+	return nil
+}
+
+func (m *Memory) Delete(key string) error {
+	delete(m.data, key)
+	return nil
+}
+
+func (m *Memory) List() []string {
+	var keys []string
+	for k := range m.data {
+		keys = append(keys, k)
+	}
+	return keys
+}
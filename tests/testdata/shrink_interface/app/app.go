@@ -0,0 +1,20 @@
+package app
+
+import "example.com/shrink/store"
+
+// Copy copies key from src to dst.
+func Copy(src, dst store.Store, key string) error {
+	defer src.Close()
+	v, err := src.Get(key)
+	if err != nil {
+		return err
+	}
+	return dst.Put(key, v)
+}
+
+// Reset clears a memory store directly.
+func Reset(m *store.Memory, keys []string) {
+	for _, k := range keys {
+		m.Delete(k)
+	}
+}
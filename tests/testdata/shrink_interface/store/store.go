@@ -0,0 +1,49 @@
+package store
+
+import "io"
+
+// Store persists records.
+type Store interface {
+	// Get returns the record stored under key.
+	Get(key string) (string, error)
+	// Put stores a record.
+	Put(key, value string) error
+	// Delete removes a record.
+	Delete(key string) error
+	Stats() map[string]int // Internal counters
+	io.Closer
+}
+
+// Memory is an in-memory Store.
+type Memory struct {
+	data map[string]string
+}
+
+// NewMemory returns an empty Memory store.
+func NewMemory() *Memory {
+	return &Memory{data: make(map[string]string)}
+}
+
+func (m *Memory) Get(key string) (string, error) {
+	return m.data[key], nil
+}
+
+func (m *Memory) Put(key, value string) error {
+	m.data[key] = value
+	return nil
+}
+
+// Delete removes key from memory.
+func (m *Memory) Delete(key string) error {
+	delete(m.data, key)
+	return nil
+}
+
+// Stats reports the number of records.
+func (m *Memory) Stats() map[string]int {
+	return map[string]int{"records": len(m.data)}
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
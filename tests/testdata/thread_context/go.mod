@@ -0,0 +1,3 @@
+module tests/thread_context
+
+go 1.21
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+func query(ctx context.Context, key string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return "value:" + key, nil
+}
+
+func fetch(key string) (string, error) {
+	return query(context.TODO(), key)
+}
+
+func process(keys []string) []string {
+	var out []string
+	for _, k := range keys {
+		v, err := fetch(k)
+		if err != nil {
+			continue
+		}
+		out = append(out, v)
+	}
+	return out
+}
+
+func Handle(keys []string) string {
+	return fmt.Sprint(process(keys))
+}
+
+func Lookup(ctx context.Context, key string) string {
+	v, _ := fetch(key)
+	return v
+}
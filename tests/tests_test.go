@@ -4,11 +4,15 @@ import (
 	"io"
 	"log/slog"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"golang.org/x/tools/go/analysis"
+
 	"github.com/mamaar/gorefactor/pkg/analyzers"
 	"github.com/mamaar/gorefactor/pkg/analyzers/booleanbranch"
 	"github.com/mamaar/gorefactor/pkg/analyzers/deepifelse"
+	"github.com/mamaar/gorefactor/pkg/analyzers/errorsentinel"
 	"github.com/mamaar/gorefactor/pkg/analyzers/errorwrap"
 	"github.com/mamaar/gorefactor/pkg/analyzers/ifinit"
 	"github.com/mamaar/gorefactor/pkg/refactor"
@@ -36,6 +40,25 @@ func TestRenameSymbol(t *testing.T) {
 	compareGoldenFiles(t, "rename_symbol", tmpDir)
 }
 
+func TestRenameSymbol_Unicode(t *testing.T) {
+	tmpDir := copyFixture(t, "rename_symbol_unicode")
+	eng := createEngine(t)
+	ws := loadWorkspace(t, eng, tmpDir)
+
+	plan, err := eng.RenameSymbol(ws, types.RenameSymbolRequest{
+		SymbolName: "ölfeld",
+		NewName:    "Übermaß",
+		Scope:      types.WorkspaceScope,
+	})
+	if err != nil {
+		t.Fatalf("RenameSymbol: %v", err)
+	}
+	if err := eng.ExecutePlan(plan); err != nil {
+		t.Fatalf("ExecutePlan: %v", err)
+	}
+	compareGoldenFiles(t, "rename_symbol_unicode", tmpDir)
+}
+
 func TestRenameMethod(t *testing.T) {
 	tmpDir := copyFixture(t, "rename_method")
 	eng := createEngine(t)
@@ -407,3 +430,198 @@ func TestFixErrorWrapping(t *testing.T) {
 	}
 	compareGoldenFiles(t, "fix_error_wrapping", tmpDir)
 }
+
+func TestFixErrorSentinels(t *testing.T) {
+	tmpDir := copyFixture(t, "fix_error_sentinels")
+	eng := createEngine(t)
+	ws := loadWorkspace(t, eng, tmpDir)
+
+	rr, err := analyzers.Run(ws, errorsentinel.Analyzer, "")
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	changes := analyzers.DiagnosticsToChanges(ws.FileSet, rr.Diagnostics)
+	plan := analyzers.ChangesToPlan(changes)
+	if err := eng.ExecutePlan(plan); err != nil {
+		t.Fatalf("ExecutePlan: %v", err)
+	}
+	compareGoldenFiles(t, "fix_error_sentinels", tmpDir)
+}
+
+func TestRunAllMatchesIndividualRuns(t *testing.T) {
+	tmpDir := copyFixture(t, "fix_error_wrapping")
+	eng := createEngine(t)
+	ws := loadWorkspace(t, eng, tmpDir)
+
+	as := []*analysis.Analyzer{
+		ifinit.Analyzer,
+		errorwrap.Analyzer,
+		booleanbranch.Analyzer,
+		deepifelse.NewAnalyzer(deepifelse.WithMaxNesting(2), deepifelse.WithMinElseLines(3)),
+	}
+	all, err := analyzers.RunAll(ws, as, "")
+	if err != nil {
+		t.Fatalf("RunAll: %v", err)
+	}
+	if len(all) != len(as) {
+		t.Fatalf("expected %d results, got %d", len(as), len(all))
+	}
+	for i, a := range as {
+		rr, err := analyzers.Run(ws, a, "")
+		if err != nil {
+			t.Fatalf("Run(%s): %v", a.Name, err)
+		}
+		if len(all[i].Diagnostics) != len(rr.Diagnostics) {
+			t.Errorf("%s: RunAll reported %d diagnostics, Run reported %d", a.Name, len(all[i].Diagnostics), len(rr.Diagnostics))
+		}
+	}
+}
+
+func TestEncapsulateField(t *testing.T) {
+	tmpDir := copyFixture(t, "encapsulate_field")
+	eng := createEngine(t)
+	ws := loadWorkspace(t, eng, tmpDir)
+
+	plan, err := eng.EncapsulateField(ws, types.EncapsulateFieldRequest{
+		TypeName:  "Config",
+		FieldName: "Name",
+	})
+	if err != nil {
+		t.Fatalf("EncapsulateField: %v", err)
+	}
+	// Taking the address and the keyed literal in app.go can't be rewritten.
+	if n := len(plan.Impact.PotentialIssues); n != 2 {
+		t.Errorf("expected 2 flagged accesses, got %d: %+v", n, plan.Impact.PotentialIssues)
+	}
+	if err := eng.ExecutePlan(plan); err != nil {
+		t.Fatalf("ExecutePlan: %v", err)
+	}
+	compareGoldenFiles(t, "encapsulate_field", tmpDir)
+}
+
+func TestShrinkInterface(t *testing.T) {
+	tmpDir := copyFixture(t, "shrink_interface")
+	eng := createEngine(t)
+	ws := loadWorkspace(t, eng, tmpDir)
+
+	plan, err := eng.ShrinkInterface(ws, types.ShrinkInterfaceRequest{
+		InterfaceName: "Store",
+		Roles: []types.RoleInterface{
+			{Name: "Reader", Methods: []string{"Get"}},
+			{Name: "Writer", Methods: []string{"Put"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("ShrinkInterface: %v", err)
+	}
+	// Memory.Delete is called directly in app.go, so only Memory.Stats goes.
+	if n := len(plan.Impact.PotentialIssues); n != 1 {
+		t.Errorf("expected 1 kept implementation, got %d: %+v", n, plan.Impact.PotentialIssues)
+	}
+	if err := eng.ExecutePlan(plan); err != nil {
+		t.Fatalf("ExecutePlan: %v", err)
+	}
+	compareGoldenFiles(t, "shrink_interface", tmpDir)
+}
+
+func TestBuildTags(t *testing.T) {
+	tmpDir := copyFixture(t, "build_tags")
+	eng := createEngine(t)
+	ws := loadWorkspace(t, eng, tmpDir)
+
+	plan, err := eng.BuildTags(ws, types.BuildTagsRequest{
+		MigrateLegacy: true,
+		RenameTags:    map[string]string{"integration": "e2e"},
+		FileSuffixes:  types.SuffixesToConstraints,
+	})
+	if err != nil {
+		t.Fatalf("BuildTags: %v", err)
+	}
+	if err := eng.ExecutePlan(plan); err != nil {
+		t.Fatalf("ExecutePlan: %v", err)
+	}
+	compareGoldenFiles(t, "build_tags", tmpDir)
+	checkDeleted(t, "build_tags", tmpDir)
+}
+
+func TestThreadContext(t *testing.T) {
+	tmpDir := copyFixture(t, "thread_context")
+	eng := createEngine(t)
+	ws := loadWorkspace(t, eng, tmpDir)
+
+	if _, err := eng.ThreadContext(ws, types.ThreadContextRequest{
+		FunctionName: "fetch",
+		Package:      tmpDir,
+		Root:         "query",
+	}); err == nil || !strings.Contains(err.Error(), "fetch is not called from query") {
+		t.Errorf("expected unreachable root error, got %v", err)
+	}
+
+	plan, err := eng.ThreadContext(ws, types.ThreadContextRequest{
+		FunctionName: "fetch",
+		Package:      tmpDir,
+		Root:         "Handle",
+	})
+	if err != nil {
+		t.Fatalf("ThreadContext: %v", err)
+	}
+	if err := eng.ExecutePlan(plan); err != nil {
+		t.Fatalf("ExecutePlan: %v", err)
+	}
+	compareGoldenFiles(t, "thread_context", tmpDir)
+}
+
+func TestSegregateInterface(t *testing.T) {
+	tmpDir := copyFixture(t, "segregate_interface")
+	eng := createEngine(t)
+	ws := loadWorkspace(t, eng, tmpDir)
+
+	plan, err := eng.SegregateInterface(ws, types.SegregateInterfaceRequest{InterfaceName: "Store"})
+	if err != nil {
+		t.Fatalf("SegregateInterface: %v", err)
+	}
+	// Remember returns its parameter, so it keeps the full interface.
+	if n := len(plan.Impact.PotentialIssues); n != 1 {
+		t.Errorf("expected 1 kept consumer, got %d: %+v", n, plan.Impact.PotentialIssues)
+	}
+	if err := eng.ExecutePlan(plan); err != nil {
+		t.Fatalf("ExecutePlan: %v", err)
+	}
+	compareGoldenFiles(t, "segregate_interface", tmpDir)
+}
+
+func TestExtractPipelineStages(t *testing.T) {
+	tmpDir := copyFixture(t, "extract_pipeline_stages")
+	eng := createEngine(t)
+	ws := loadWorkspace(t, eng, tmpDir)
+
+	plan, err := eng.ExtractPipelineStages(ws, types.ExtractPipelineStagesRequest{
+		SourceFile: filepath.Join(tmpDir, "importer.go"),
+		Line:       23,
+	})
+	if err != nil {
+		t.Fatalf("ExtractPipelineStages: %v", err)
+	}
+	if issues := plan.Impact.PotentialIssues; len(issues) != 1 || !strings.Contains(issues[0].Description, "consume stage stays inline: it assigns errs") {
+		t.Errorf("expected the consume stage to stay inline, got %+v", issues)
+	}
+	if err := eng.ExecutePlan(plan); err != nil {
+		t.Fatalf("ExecutePlan: %v", err)
+	}
+	compareGoldenFiles(t, "extract_pipeline_stages", tmpDir)
+}
+
+func TestExtractTestHelper(t *testing.T) {
+	tmpDir := copyFixture(t, "extract_test_helper")
+	eng := createEngine(t)
+	ws := loadWorkspace(t, eng, tmpDir)
+
+	plan, err := eng.ExtractTestHelper(ws, types.ExtractTestHelperRequest{Package: tmpDir})
+	if err != nil {
+		t.Fatalf("ExtractTestHelper: %v", err)
+	}
+	if err := eng.ExecutePlan(plan); err != nil {
+		t.Fatalf("ExecutePlan: %v", err)
+	}
+	compareGoldenFiles(t, "extract_test_helper", tmpDir)
+}